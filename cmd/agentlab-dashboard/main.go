@@ -19,12 +19,37 @@
 // behind TLS or a trusted encrypted tunnel, since the token travels in
 // cleartext over HTTP otherwise (see docs/review-2026-08-11.md C2).
 //
+// # Web terminal
+//
+// With --sandbox-key, each running sandbox gets a Terminal button that opens
+// an interactive shell over a WebSocket. The dashboard dials the sandbox over
+// SSH the same way agentlab-ssh-gateway does, so teammates need only the
+// dashboard token, not an SSH key on the gateway. --record records every
+// terminal session as an asciicast v2 file and uploads it to agentlabd as an
+// artifact of the sandbox, like the gateway's --record.
+//
+// # Single sign-on
+//
+// With --oidc-redirect-url, the dashboard signs users in through the identity
+// provider agentlabd is configured with (oidc_issuer). Requests from a
+// signed-in browser reach the daemon with a token acting for that user, so
+// roles, team ownership and access grants apply as they do on the CLI. The
+// browser token keeps working for operators.
+//
 // # Flags
 //
-//	--listen         Address to bind (default "127.0.0.1:8080")
-//	--socket         Path to agentlabd Unix socket
-//	--token          Bearer token for daemon (outbound) authentication
-//	--browser-token  Inbound token gating /api/* (required for non-loopback)
+//	--listen                 Address to bind (default "127.0.0.1:8080")
+//	--socket                 Path to agentlabd Unix socket
+//	--token                  Bearer token for daemon (outbound) authentication
+//	--browser-token          Inbound token gating /api/* (required for non-loopback)
+//	--sandbox-key            SSH private key for the web terminal (terminal disabled when empty)
+//	--sandbox-user           SSH user for sandbox terminals (default "agent")
+//	--sandbox-port           SSH port for sandbox terminals (default 22)
+//	--terminal-idle-timeout  Close a terminal after this long without input or output (default 15m)
+//	--record                 Record terminal sessions as sandbox artifacts
+//	--record-spool           Directory for recordings in progress and failed uploads
+//	--oidc-redirect-url      External URL of /auth/callback; enables single sign-on
+//	--oidc-client-secret-file  File holding the OIDC client secret (confidential clients only)
 package main
 
 import (
@@ -34,7 +59,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/agentlab/agentlab/internal/buildinfo"
 	"github.com/agentlab/agentlab/internal/dashboard"
@@ -47,6 +74,14 @@ func main() {
 		socketPath   string
 		token        string
 		browserToken string
+		sandboxKey   string
+		sandboxUser  string
+		sandboxPort  int
+		idleTimeout  time.Duration
+		record       bool
+		recordSpool  string
+		oidcRedirect string
+		oidcSecret   string
 	)
 
 	flag.BoolVar(&showVersion, "version", false, "print version and exit")
@@ -54,6 +89,14 @@ func main() {
 	flag.StringVar(&socketPath, "socket", "", "path to agentlabd socket (default: /run/agentlab/agentlabd.sock)")
 	flag.StringVar(&token, "token", "", "bearer token for daemon authentication")
 	flag.StringVar(&browserToken, "browser-token", "", "inbound token gating browser /api/* requests (required for non-loopback binds)")
+	flag.StringVar(&sandboxKey, "sandbox-key", "", "SSH private key used to open web terminals on sandboxes (terminal disabled when empty)")
+	flag.StringVar(&sandboxUser, "sandbox-user", "agent", "SSH user for web terminal sessions")
+	flag.IntVar(&sandboxPort, "sandbox-port", 22, "SSH port for web terminal sessions")
+	flag.DurationVar(&idleTimeout, "terminal-idle-timeout", 15*time.Minute, "close a web terminal after this long without input or output")
+	flag.BoolVar(&record, "record", false, "record web terminal sessions and upload them to agentlabd as sandbox artifacts")
+	flag.StringVar(&recordSpool, "record-spool", "", "directory for recordings in progress and failed uploads (default: system temp dir)")
+	flag.StringVar(&oidcRedirect, "oidc-redirect-url", "", "external URL of the dashboard's /auth/callback; enables single sign-on through agentlabd's identity provider")
+	flag.StringVar(&oidcSecret, "oidc-client-secret-file", "", "file holding the OIDC client secret (omit for public clients)")
 	flag.Parse()
 
 	if showVersion {
@@ -65,11 +108,30 @@ func main() {
 		socketPath = dashboard.DefaultSocketPath()
 	}
 
+	var clientSecret string
+	if oidcSecret != "" {
+		data, err := os.ReadFile(oidcSecret)
+		if err != nil {
+			log.Fatalf("read oidc client secret: %v", err)
+		}
+		clientSecret = strings.TrimSpace(string(data))
+	}
+
 	cfg := dashboard.Config{
 		Listen:       listen,
 		SocketPath:   socketPath,
 		Token:        token,
 		BrowserToken: browserToken,
+
+		SandboxKeyPath:      sandboxKey,
+		SandboxUser:         sandboxUser,
+		SandboxPort:         sandboxPort,
+		TerminalIdleTimeout: idleTimeout,
+		Record:              record,
+		RecordSpool:         recordSpool,
+
+		OIDCRedirectURL:  oidcRedirect,
+		OIDCClientSecret: clientSecret,
 	}
 
 	srv := dashboard.NewServer(cfg, log.Default())
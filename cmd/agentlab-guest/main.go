@@ -0,0 +1,121 @@
+// ABOUTME: Guest agent entry point for the agentlab-guest binary.
+// ABOUTME: Runs the job assigned to the VM and answers sandbox metadata queries.
+
+// Package main implements agentlab-guest, the binary baked into AgentLab
+// guest templates.
+//
+// agentlab-guest run is started by agent-runner.service at boot. It exchanges
+// the one-time token in /etc/agentlab/bootstrap.json for the job payload,
+// checks out the repo, runs the agent, and reports progress, heartbeats,
+// streamed logs, and artifacts back to agentlabd. SIGTERM stops the agent
+// gracefully; artifacts and the final report are still sent.
+//
+// The remaining subcommands read the sandbox metadata service at
+// http://169.254.169.254 for agents and users inside the VM.
+//
+// # Usage
+//
+//	agentlab-guest run
+//	agentlab-guest identity
+//	agentlab-guest metadata
+//	agentlab-guest secret <name>
+//	agentlab-guest prompt
+//	agentlab-guest proxy <path>
+//	agentlab-guest ask <question> [option...]
+//	agentlab-guest version
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/agentlab/agentlab/internal/buildinfo"
+	"github.com/agentlab/agentlab/internal/guest"
+)
+
+const usageText = `AgentLab Guest - job runner and sandbox metadata helper.
+
+Usage:
+  agentlab-guest run              Run the job assigned to this VM (systemd)
+  agentlab-guest identity         Show sandbox identity (name, vmid, profile)
+  agentlab-guest metadata         Show all sandbox metadata key-value pairs
+  agentlab-guest secret <name>    Retrieve a named secret value
+  agentlab-guest prompt           Get the initial agent prompt (if set)
+  agentlab-guest proxy <path>     Make a request through the credential proxy
+  agentlab-guest ask <question> [option...]
+                                  Ask an operator and wait for the answer
+  agentlab-guest version          Print version and exit
+
+The metadata endpoint is available at http://169.254.169.254 inside all
+sandboxes. Every request must carry the sandbox secret from
+AGENTLAB_SANDBOX_SECRET_FILE (default /run/agentlab/secrets/sandbox-secret)
+in the X-AgentLab-Sandbox-Secret header.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	cmd := "help"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	arg := func() string {
+		if len(args) > 1 {
+			return args[1]
+		}
+		return ""
+	}
+	helper := guest.NewHelper()
+	var err error
+	switch cmd {
+	case "run":
+		err = runJob(ctx, stderr)
+	case "identity":
+		err = helper.Identity(ctx, stdout)
+	case "metadata":
+		err = helper.Metadata(ctx, stdout)
+	case "secret":
+		err = helper.Secret(ctx, arg(), stdout)
+	case "prompt":
+		err = helper.Prompt(ctx, stdout)
+	case "proxy":
+		err = helper.Proxy(ctx, arg(), stdout)
+	case "ask":
+		var options []string
+		if len(args) > 2 {
+			options = args[2:]
+		}
+		err = helper.Ask(ctx, arg(), options, stdout)
+	case "version", "--version":
+		fmt.Fprintln(stdout, buildinfo.String())
+	case "help", "--help", "-h":
+		fmt.Fprint(stdout, usageText)
+	default:
+		fmt.Fprintf(stderr, "unknown command: %s\nRun 'agentlab-guest help' for usage.\n", cmd)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "agentlab-guest: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runJob(ctx context.Context, stderr io.Writer) error {
+	cfg, err := guest.LoadConfig()
+	if err != nil {
+		return err
+	}
+	logger := log.New(stderr, "agent-runner: ", 0)
+	logger.Printf("starting (%s)", buildinfo.String())
+	return guest.NewRunner(cfg, logger).Run(ctx)
+}
@@ -0,0 +1,57 @@
+//go:build sshgateway
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckAccess covers the daemon access check the gateway runs before it
+// proxies a session: sandbox.ssh with the vmid for existing sandboxes,
+// sandbox.create for new ones, and the user a new sandbox is owned by.
+func TestCheckAccess(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agentlabd.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/access/check", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		resp := accessCheckResponse{User: "alice", Permission: q.Get("permission")}
+		switch {
+		case q.Get("fingerprint") != "SHA256:alice":
+		case q.Get("permission") == "sandbox.create":
+			resp.Allowed = true
+		case q.Get("permission") == "sandbox.ssh" && q.Get("vmid") == "1001":
+			resp.Allowed = true
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	client := newAPIClient(socketPath, time.Second)
+	ctx := context.Background()
+
+	owner, err := checkAccess(ctx, client, "SHA256:alice", routeTarget{profile: "default", isNew: true})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", owner)
+
+	_, err = checkAccess(ctx, client, "SHA256:alice", routeTarget{vmid: 1001})
+	require.NoError(t, err)
+
+	_, err = checkAccess(ctx, client, "SHA256:alice", routeTarget{vmid: 1002})
+	assert.EqualError(t, err, "access to sandbox 1002 denied")
+
+	_, err = checkAccess(ctx, client, "SHA256:mallory", routeTarget{profile: "default", isNew: true})
+	assert.EqualError(t, err, "not allowed to create sandboxes")
+}
@@ -8,11 +8,8 @@ import (
 	"crypto/rand"
 	"encoding/pem"
 	"fmt"
-	"log"
-	"net"
 	"os"
 	"path/filepath"
-	"sync"
 	"sync/atomic"
 	"time"
 
@@ -93,65 +90,6 @@ func (t *trackedChannel) Write(p []byte) (int, error) {
 	return n, err
 }
 
-// hostKeyPinStore records the first observed sandbox host key for each VMID
-// (TOFU) and rejects any later differing key, so a host reusing an agent-subnet
-// address cannot impersonate the target sandbox (review M7).
-//
-// Rotation policy: a freshly allocated VMID has no pin, so its first-presented
-// key is recorded. An existing VMID whose VM is recreated must have its pin
-// cleared via rotate() before the new key is accepted; otherwise the mismatch
-// is treated as impersonation and the connection is refused.
-type hostKeyPinStore struct {
-	mu   sync.Mutex
-	pins map[int]string // vmid -> marshaled public key wire format
-}
-
-func newHostKeyPinStore() *hostKeyPinStore {
-	return &hostKeyPinStore{pins: make(map[int]string)}
-}
-
-// callback returns an ssh.HostKeyCallback that enforces the per-VMID pin.
-func (s *hostKeyPinStore) callback(vmid int, logger *log.Logger) ssh.HostKeyCallback {
-	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
-		wire := string(key.Marshal())
-		s.mu.Lock()
-		defer s.mu.Unlock()
-		existing, ok := s.pins[vmid]
-		if !ok {
-			s.pins[vmid] = wire
-			if logger != nil {
-				logger.Printf("pinning sandbox host key for vmid %d (%s)", vmid, ssh.FingerprintSHA256(key))
-			}
-			return nil
-		}
-		if existing != wire {
-			expected := "unknown"
-			if pub, err := ssh.ParsePublicKey([]byte(existing)); err == nil {
-				expected = ssh.FingerprintSHA256(pub)
-			}
-			return fmt.Errorf("sandbox host key for vmid %d changed: expected %s, got %s (possible impersonation)",
-				vmid, expected, ssh.FingerprintSHA256(key))
-		}
-		return nil
-	}
-}
-
-// rotate drops the pinned host key for a VMID so a recreated VM may present a
-// fresh key (review M7 rotation policy).
-func (s *hostKeyPinStore) rotate(vmid int) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.pins, vmid)
-}
-
-// hasPin reports whether a host key is already pinned for the VMID.
-func (s *hostKeyPinStore) hasPin(vmid int) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	_, ok := s.pins[vmid]
-	return ok
-}
-
 // generateHostSignerPEM creates a fresh ed25519 key pair and returns both the
 // SSH signer and the OpenSSH-format PEM bytes (for persistence).
 func generateHostSignerPEM() (ssh.Signer, []byte, error) {
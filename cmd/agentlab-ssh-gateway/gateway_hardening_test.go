@@ -4,8 +4,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/ed25519"
-	"crypto/rand"
 	"io"
 	"log"
 	"os"
@@ -94,61 +92,6 @@ func TestLoadHostSigner_EphemeralWhenPathEmpty(t *testing.T) {
 	assert.NotNil(t, signer.PublicKey())
 }
 
-// TestHostKeyPinStore_TOFUAndRotation proves the per-VMID host-key rotation
-// policy (review M7):
-//   - the first key observed for a VMID is pinned (TOFU),
-//   - a differing key for the same VMID is rejected as impersonation,
-//   - rotate() drops the pin so a recreated VM may present a fresh key,
-//   - pins are independent per VMID.
-func TestHostKeyPinStore_TOFUAndRotation(t *testing.T) {
-	pins := newHostKeyPinStore()
-	logger := log.New(io.Discard, "", 0)
-	vmid := 4011
-
-	keyA := testSSHPublicKey(t)
-	keyB := testSSHPublicKey(t)
-	require.NotEqual(t,
-		ssh.FingerprintSHA256(keyA), ssh.FingerprintSHA256(keyB),
-		"precondition: distinct keys")
-
-	cb := pins.callback(vmid, logger)
-
-	// First observed key is pinned (TOFU).
-	require.NoError(t, cb("sbx:22", nil, keyA))
-	assert.True(t, pins.hasPin(vmid))
-
-	// A different host presenting a different key is rejected.
-	err := cb("sbx:22", nil, keyB)
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "changed")
-
-	// The originally pinned key is still accepted.
-	require.NoError(t, cb("sbx:22", nil, keyA))
-
-	// After rotate(), a recreated VM may present a fresh key.
-	pins.rotate(vmid)
-	assert.False(t, pins.hasPin(vmid))
-	require.NoError(t, cb("sbx:22", nil, keyB), "post-rotation new key must pin")
-	assert.True(t, pins.hasPin(vmid))
-
-	// And the old key is now the one rejected.
-	require.Error(t, cb("sbx:22", nil, keyA))
-}
-
-// TestHostKeyPinStore_IndependentPerVMID proves that pinning keyA to one VMID
-// does not constrain a different VMID, which must pin its own first-seen key.
-func TestHostKeyPinStore_IndependentPerVMID(t *testing.T) {
-	pins := newHostKeyPinStore()
-	logger := log.New(io.Discard, "", 0)
-	keyA := testSSHPublicKey(t)
-
-	require.NoError(t, pins.callback(100, logger)("a:22", nil, keyA))
-	// A different VMID is unpinned; its first key (even keyA) is accepted.
-	require.NoError(t, pins.callback(200, logger)("b:22", nil, keyA))
-	assert.True(t, pins.hasPin(100))
-	assert.True(t, pins.hasPin(200))
-}
-
 // TestWritePrivateKeyAtomic_Mode0600 covers the atomic-write helper directly,
 // including that the parent directory is created with mode 0700.
 func TestWritePrivateKeyAtomic_Mode0600(t *testing.T) {
@@ -168,12 +111,3 @@ func TestWritePrivateKeyAtomic_Mode0600(t *testing.T) {
 	_, err = os.Stat(path + ".tmp")
 	assert.True(t, os.IsNotExist(err), "temp file must be renamed away")
 }
-
-func testSSHPublicKey(t *testing.T) ssh.PublicKey {
-	t.Helper()
-	pub, _, err := ed25519.GenerateKey(rand.Reader)
-	require.NoError(t, err)
-	sshPub, err := ssh.NewPublicKey(pub)
-	require.NoError(t, err)
-	return sshPub
-}
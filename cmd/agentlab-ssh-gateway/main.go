@@ -3,7 +3,8 @@
 
 // ABOUTME: SSH gateway that provides remote access to the agentlab daemon API.
 // ABOUTME: Supports CLI command execution (ssh host sandbox list --json) and
-// ABOUTME: interactive sandbox proxy sessions (ssh new@host).
+// ABOUTME: interactive sandbox proxy sessions (ssh new@host), optionally
+// ABOUTME: recorded as asciicast v2 artifacts of the sandbox (--record).
 package main
 
 import (
@@ -18,6 +19,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -30,6 +32,7 @@ import (
 
 	"github.com/agentlab/agentlab/internal/api"
 	"github.com/agentlab/agentlab/internal/argv"
+	"github.com/agentlab/agentlab/internal/sandboxssh"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -61,6 +64,8 @@ type gatewayConfig struct {
 	cliPath           string
 	idleTimeout       time.Duration
 	keepaliveInterval time.Duration
+	record            bool
+	recordSpool       string
 }
 
 // routeTarget describes where to route a proxy-mode SSH session.
@@ -68,15 +73,16 @@ type routeTarget struct {
 	vmid    int
 	profile string
 	isNew   bool
+	owner   string // registered user a new sandbox is created for
 }
 
 // server tracks active sessions and shared resources.
 type server struct {
 	cfg           gatewayConfig
-	allowedKeys   map[string]bool
+	allowedKeys   map[string]string
 	hostSigner    ssh.Signer
 	sandboxSigner ssh.Signer
-	hostKeyPins   *hostKeyPinStore
+	hostKeyPins   *sandboxssh.HostKeyPins
 	client        *apiClient
 	logger        *log.Logger
 
@@ -101,6 +107,8 @@ func main() {
 	flag.StringVar(&cfg.cliPath, "cli-path", "", "path to agentlab CLI binary (auto-detected if empty)")
 	flag.DurationVar(&cfg.idleTimeout, "idle-timeout", defaultIdleTimeout, "idle timeout for SSH connections")
 	flag.DurationVar(&cfg.keepaliveInterval, "keepalive-interval", defaultKeepaliveInterval, "SSH keepalive interval")
+	flag.BoolVar(&cfg.record, "record", false, "record interactive proxy sessions and upload them to agentlabd as sandbox artifacts")
+	flag.StringVar(&cfg.recordSpool, "record-spool", "", "directory for recordings in progress and failed uploads (default: system temp dir)")
 	flag.Parse()
 
 	logger := log.New(os.Stdout, "ssh-gateway: ", log.LstdFlags)
@@ -118,7 +126,7 @@ func main() {
 		logger.Fatalf("load host key: %v", err)
 	}
 
-	sandboxSigner, err := loadPrivateKey(cfg.sandboxKeyPath)
+	sandboxSigner, err := sandboxssh.LoadSigner(cfg.sandboxKeyPath)
 	if err != nil {
 		logger.Fatalf("load sandbox key: %v", err)
 	}
@@ -137,7 +145,7 @@ func main() {
 		allowedKeys:   allowedKeys,
 		hostSigner:    hostSigner,
 		sandboxSigner: sandboxSigner,
-		hostKeyPins:   newHostKeyPinStore(),
+		hostKeyPins:   sandboxssh.NewHostKeyPins(),
 		client:        client,
 		logger:        logger,
 		conns:         make(map[*ssh.ServerConn]struct{}),
@@ -215,8 +223,8 @@ func (s *server) handleConnection(conn net.Conn) {
 	serverConfig := &ssh.ServerConfig{
 		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
 			fp := ssh.FingerprintSHA256(key)
-			if s.allowedKeys[fp] {
-				return &ssh.Permissions{Extensions: map[string]string{"fingerprint": fp}}, nil
+			if user, ok := s.allowedKeys[fp]; ok {
+				return &ssh.Permissions{Extensions: map[string]string{"fingerprint": fp, "user": user}}, nil
 			}
 			return nil, fmt.Errorf("unauthorized key %s", fp)
 		},
@@ -242,7 +250,9 @@ func (s *server) handleConnection(conn net.Conn) {
 	go s.sendKeepalives(sshConn, reqs)
 
 	username := sshConn.User()
-	s.logger.Printf("connection from %s as %q (fp=%s)", conn.RemoteAddr(), username, sshConn.Permissions.Extensions["fingerprint"])
+	keyUser := sshConn.Permissions.Extensions["user"]
+	fingerprint := sshConn.Permissions.Extensions["fingerprint"]
+	s.logger.Printf("connection from %s as %q (fp=%s)", conn.RemoteAddr(), username, fingerprint)
 
 	for newChannel := range chans {
 		// A client opening a channel is activity even before data flows.
@@ -251,7 +261,7 @@ func (s *server) handleConnection(conn net.Conn) {
 			_ = newChannel.Reject(ssh.UnknownChannelType, "only session channels supported")
 			continue
 		}
-		go s.handleSession(newChannel, username, tracker)
+		go s.handleSession(newChannel, username, keyUser, fingerprint, tracker)
 	}
 }
 
@@ -284,7 +294,10 @@ func (s *server) sendKeepalives(conn *ssh.ServerConn, reqs <-chan *ssh.Request)
 //     (e.g., "sandbox list --json"), execute it via the agentlab binary.
 //  2. Proxy mode: When the client requests an interactive shell (or exec with a
 //     sandbox routing command like "new" or "sbx-123"), proxy the session to a sandbox.
-func (s *server) handleSession(newChannel ssh.NewChannel, username string, tracker *activityTracker) {
+//
+// keyUser names the person behind the authenticating key, for recordings;
+// fingerprint identifies the key to the daemon's access check.
+func (s *server) handleSession(newChannel ssh.NewChannel, username, keyUser, fingerprint string, tracker *activityTracker) {
 	channel, requests, err := newChannel.Accept()
 	if err != nil {
 		s.logger.Printf("accept channel: %v", err)
@@ -337,8 +350,10 @@ func (s *server) handleSession(newChannel ssh.NewChannel, username string, track
 			_ = req.Reply(true, nil)
 			determined = true
 			cmd := strings.TrimSpace(execReq.Command)
+			session := proxySession{user: keyUser, fingerprint: fingerprint, pty: ptyReq, env: envRequests}
 			if cmd == "" {
-				s.handleProxySession(ctx, tc, username, ptyReq, envRequests)
+				session.resizes = forwardWindowChanges(requests, tracker)
+				s.handleProxySession(ctx, tc, username, session)
 			} else if isCLICommand(cmd) {
 				s.handleCLIExec(ctx, tc, cmd)
 			} else {
@@ -348,7 +363,8 @@ func (s *server) handleSession(newChannel ssh.NewChannel, username string, track
 					// Unknown command; try CLI as fallback.
 					s.handleCLIExec(ctx, tc, cmd)
 				} else {
-					s.handleProxySessionWithRoute(ctx, tc, route, ptyReq, envRequests)
+					session.resizes = forwardWindowChanges(requests, tracker)
+					s.handleProxySessionWithRoute(ctx, tc, route, session)
 				}
 			}
 			return
@@ -356,11 +372,13 @@ func (s *server) handleSession(newChannel ssh.NewChannel, username string, track
 		case "shell":
 			_ = req.Reply(true, nil)
 			determined = true
-			s.handleProxySession(ctx, tc, username, ptyReq, envRequests)
+			session := proxySession{user: keyUser, fingerprint: fingerprint, pty: ptyReq, env: envRequests, resizes: forwardWindowChanges(requests, tracker)}
+			s.handleProxySession(ctx, tc, username, session)
 			return
 
 		case "window-change":
-			// Forwarded inside proxy session if active.
+			// Before the shell starts the initial size comes from pty-req;
+			// later changes are forwarded by forwardWindowChanges.
 			_ = req.Reply(true, nil)
 
 		case "subsystem":
@@ -464,39 +482,99 @@ func buildCLIArgs(socketPath, cmd string) ([]string, error) {
 
 // --- Proxy mode (sandbox create/connect) ---
 
+// proxySession carries what a proxied session needs from the client's
+// session requests.
+type proxySession struct {
+	user        string
+	fingerprint string
+	pty         *ptyRequest
+	env         []envRequest
+	resizes     <-chan windowChangeRequest
+}
+
+// forwardWindowChanges keeps serving a session's requests once the shell is
+// running, passing terminal resizes on. The returned channel closes when the
+// client's session channel does.
+func forwardWindowChanges(requests <-chan *ssh.Request, tracker *activityTracker) <-chan windowChangeRequest {
+	resizes := make(chan windowChangeRequest, 8)
+	go func() {
+		defer close(resizes)
+		for req := range requests {
+			tracker.touch()
+			if req.Type != "window-change" {
+				if req.WantReply {
+					_ = req.Reply(false, nil)
+				}
+				continue
+			}
+			var wc windowChangeRequest
+			if err := ssh.Unmarshal(req.Payload, &wc); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			if req.WantReply {
+				_ = req.Reply(true, nil)
+			}
+			resizes <- wc
+		}
+	}()
+	return resizes
+}
+
 // handleProxySession parses the username to determine a sandbox route and proxies the session.
-func (s *server) handleProxySession(ctx context.Context, channel ssh.Channel, username string, ptyReq *ptyRequest, envRequests []envRequest) {
+func (s *server) handleProxySession(ctx context.Context, channel ssh.Channel, username string, session proxySession) {
 	route, err := parseRoute(username, s.cfg.defaultProfile)
 	if err != nil {
 		writeSessionError(channel, err.Error())
+		drainResizes(session.resizes)
 		return
 	}
-	s.handleProxySessionWithRoute(ctx, channel, route, ptyReq, envRequests)
+	s.handleProxySessionWithRoute(ctx, channel, route, session)
 }
 
 // handleProxySessionWithRoute proxies an SSH session to a sandbox VM identified by route.
-func (s *server) handleProxySessionWithRoute(ctx context.Context, channel ssh.Channel, route routeTarget, ptyReq *ptyRequest, envRequests []envRequest) {
+func (s *server) handleProxySessionWithRoute(ctx context.Context, channel ssh.Channel, route routeTarget, session proxySession) {
 	var (
+		mu            sync.Mutex
 		remoteClient  *ssh.Client
 		remoteSession *ssh.Session
+		recorder      *sessionRecorder
+		vmid          int
 		once          sync.Once
-		closed        bool
+		stdinClosed   = &eofNotifier{done: make(chan struct{})}
 	)
 
 	closeSession := func(status uint32) {
 		once.Do(func() {
-			if !closed {
-				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{Status: status}))
-				closed = true
-			}
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{Status: status}))
 			_ = channel.Close()
 		})
 	}
 
+	// Resizes arrive for as long as the client channel is open, including
+	// while the sandbox is still being prepared; only the latest matters
+	// until the remote session exists.
+	go func() {
+		for wc := range session.resizes {
+			mu.Lock()
+			if remoteSession != nil {
+				_ = remoteSession.WindowChange(int(wc.Rows), int(wc.Cols))
+			} else if session.pty != nil {
+				session.pty.Cols, session.pty.Rows = wc.Cols, wc.Rows
+			}
+			if recorder != nil {
+				recorder.resize(wc.Cols, wc.Rows)
+			}
+			mu.Unlock()
+		}
+	}()
+
 	ensureRemote := func() error {
-		if remoteSession != nil {
-			return nil
+		owner, err := checkAccess(ctx, s.client, session.fingerprint, route)
+		if err != nil {
+			return err
 		}
+		route.owner = owner
 		if route.isNew {
 			fmt.Fprintf(channel, "agentlab: creating sandbox (profile=%s)\n", route.profile)
 		}
@@ -508,59 +586,120 @@ func (s *server) handleProxySessionWithRoute(ctx context.Context, channel ssh.Ch
 			fmt.Fprintf(channel, "agentlab: sandbox %d ready (%s)\n", sandbox.VMID, sandbox.IP)
 			// Freshly allocated VM: clear any stale pin left by a recycled VMID
 			// before TOFU pins the new key (review M7 rotation policy).
-			s.hostKeyPins.rotate(sandbox.VMID)
+			s.hostKeyPins.Rotate(sandbox.VMID)
 		}
-		remoteClient, err = dialSandbox(ctx, sandbox.IP, s.cfg.sandboxPort, s.cfg.sandboxUser, s.sandboxSigner, sandbox.VMID, s.hostKeyPins, s.logger)
+		client, err := sandboxssh.Dial(ctx, sandbox.IP, s.cfg.sandboxPort, s.cfg.sandboxUser, s.sandboxSigner, sandbox.VMID, s.hostKeyPins, s.logger)
 		if err != nil {
 			return err
 		}
-		remoteSession, err = remoteClient.NewSession()
+		remote, err := client.NewSession()
 		if err != nil {
+			_ = client.Close()
 			return err
 		}
-		remoteSession.Stdout = channel
-		remoteSession.Stderr = channel.Stderr()
-		remoteSession.Stdin = channel
-		if ptyReq != nil {
-			_ = remoteSession.RequestPty(ptyReq.Term, int(ptyReq.Rows), int(ptyReq.Cols), ssh.TerminalModes{})
+
+		mu.Lock()
+		defer mu.Unlock()
+		vmid = sandbox.VMID
+		remoteClient, remoteSession = client, remote
+		stdinClosed.r = channel
+		var stdin io.Reader = stdinClosed
+		var stdout, stderr io.Writer = channel, channel.Stderr()
+		if s.cfg.record {
+			rec, err := newSessionRecorder(s.cfg.recordSpool, vmid, session.pty)
+			if err != nil {
+				// An operator who asked for recordings gets none rather
+				// than an unrecorded session.
+				return fmt.Errorf("session recording unavailable: %w", err)
+			}
+			recorder = rec
+			stdin, stdout, stderr = rec.input(stdin), rec.output(stdout), rec.output(stderr)
+		}
+		remote.Stdin, remote.Stdout, remote.Stderr = stdin, stdout, stderr
+		if session.pty != nil {
+			_ = remote.RequestPty(session.pty.Term, int(session.pty.Rows), int(session.pty.Cols), ssh.TerminalModes{})
 		}
-		for _, env := range envRequests {
-			_ = remoteSession.Setenv(env.Name, env.Value)
+		for _, env := range session.env {
+			_ = remote.Setenv(env.Name, env.Value)
 		}
 		return nil
 	}
 
-	waitRemote := func() {
-		if remoteSession == nil {
-			return
-		}
-		err := remoteSession.Wait()
-		status := exitStatus(err)
+	// finish ends the session; started reports whether the shell ran, since
+	// a recording without a session is not worth keeping.
+	finish := func(status uint32, started bool) {
 		closeSession(status)
-		if remoteClient != nil {
-			_ = remoteClient.Close()
+		mu.Lock()
+		client, rec, id := remoteClient, recorder, vmid
+		mu.Unlock()
+		if client != nil {
+			_ = client.Close()
+		}
+		if rec != nil && !started {
+			rec.discard()
+		} else if rec != nil {
+			uploadCtx, cancel := context.WithTimeout(context.Background(), s.cfg.waitTimeout)
+			defer cancel()
+			if err := rec.upload(uploadCtx, s.client, id, session.user); err != nil {
+				s.logger.Printf("sandbox %d: %v", id, err)
+			}
 		}
 	}
 
 	if err := ensureRemote(); err != nil {
 		writeSessionError(channel, fmt.Sprintf("gateway error: %v", err))
-		closeSession(1)
+		finish(1, false)
 		return
 	}
 	if err := remoteSession.Shell(); err != nil {
 		writeSessionError(channel, fmt.Sprintf("remote shell failed: %v", err))
-		closeSession(1)
+		finish(1, false)
 		return
 	}
-	go waitRemote()
+	waited := make(chan error, 1)
+	go func() { waited <- remoteSession.Wait() }()
+	// Without a PTY, the end of the client's input is just EOF on the
+	// command's stdin. A terminal shell does not exit on it, so a client that
+	// hangs up ends the session from this side.
+	var hangup <-chan struct{}
+	if session.pty != nil {
+		hangup = stdinClosed.done
+	}
+	select {
+	case err := <-waited:
+		finish(exitStatus(err), true)
+	case <-hangup:
+		_ = remoteClient.Close()
+		finish(exitStatus(<-waited), true)
+	}
+}
 
-	// Block until channel closes (EOF from client).
-	buf := make([]byte, 1)
-	for {
-		if _, err := channel.Read(buf); err != nil {
-			break
-		}
+// eofNotifier closes done once reads from r stop, whether the client sent
+// EOF or its channel went away.
+type eofNotifier struct {
+	r    io.Reader
+	once sync.Once
+	done chan struct{}
+}
+
+func (e *eofNotifier) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil {
+		e.once.Do(func() { close(e.done) })
 	}
+	return n, err
+}
+
+// drainResizes discards resizes for a session that never reached a sandbox,
+// so the request-serving goroutine can finish.
+func drainResizes(resizes <-chan windowChangeRequest) {
+	if resizes == nil {
+		return
+	}
+	go func() {
+		for range resizes {
+		}
+	}()
 }
 
 // --- SSH request types ---
@@ -630,11 +769,41 @@ func parseRoute(username, defaultProfile string) (routeTarget, error) {
 
 // --- Sandbox resolution ---
 
+// checkAccess asks agentlabd whether the user behind the key may take route:
+// sandbox.ssh on an existing sandbox, sandbox.create for a new one. The
+// daemon answers with the same ownership, team and grant rules its API
+// applies. It returns the registered user, if any, so a new sandbox is owned
+// by them rather than by the gateway.
+func checkAccess(ctx context.Context, client *apiClient, fingerprint string, route routeTarget) (string, error) {
+	query := url.Values{"fingerprint": {fingerprint}}
+	if route.isNew {
+		query.Set("permission", "sandbox.create")
+	} else {
+		query.Set("permission", "sandbox.ssh")
+		query.Set("vmid", strconv.Itoa(route.vmid))
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/access/check?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("access check: %w", err)
+	}
+	var resp accessCheckResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return "", fmt.Errorf("access check: %w", err)
+	}
+	if !resp.Allowed {
+		if route.isNew {
+			return "", fmt.Errorf("not allowed to create sandboxes")
+		}
+		return "", fmt.Errorf("access to sandbox %d denied", route.vmid)
+	}
+	return resp.User, nil
+}
+
 func resolveSandbox(ctx context.Context, client *apiClient, route routeTarget, cfg gatewayConfig) (sandboxResponse, error) {
 	var sandbox sandboxResponse
 	var err error
 	if route.isNew {
-		sandbox, err = createSandbox(ctx, client, route.profile, cfg.keepalive)
+		sandbox, err = createSandbox(ctx, client, route.profile, route.owner, cfg.keepalive)
 		if err != nil {
 			return sandboxResponse{}, err
 		}
@@ -678,31 +847,6 @@ func waitForSandboxIP(ctx context.Context, client *apiClient, vmid int, interval
 	}
 }
 
-// dialSandbox dials a sandbox SSH server, enforcing TOFU host-key pinning per
-// VMID (review M7). pins records the first key seen for vmid and rejects any
-// later mismatch, so a different host reusing the agent-subnet address cannot
-// impersonate the target sandbox. Recreating a VM requires rotate() first.
-func dialSandbox(ctx context.Context, ip string, port int, user string, signer ssh.Signer, vmid int, pins *hostKeyPinStore, logger *log.Logger) (*ssh.Client, error) {
-	address := net.JoinHostPort(ip, strconv.Itoa(port))
-	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
-		HostKeyCallback: pins.callback(vmid, logger),
-		Timeout:         10 * time.Second,
-	}
-	for {
-		client, err := ssh.Dial("tcp", address, config)
-		if err == nil {
-			return client, nil
-		}
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("dial sandbox %s: %w", address, err)
-		case <-time.After(2 * time.Second):
-		}
-	}
-}
-
 func exitStatus(err error) uint32 {
 	if err == nil {
 		return 0
@@ -716,23 +860,29 @@ func exitStatus(err error) uint32 {
 
 // --- Key management ---
 
-func loadAuthorizedKeys(path string) (map[string]bool, error) {
+// loadAuthorizedKeys maps each key's fingerprint to the person it belongs
+// to: the key's comment, or the fingerprint itself when the comment is empty.
+func loadAuthorizedKeys(path string) (map[string]string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	allowed := make(map[string]bool)
+	allowed := make(map[string]string)
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
 		if err != nil {
 			return nil, fmt.Errorf("parse authorized key: %w", err)
 		}
-		allowed[ssh.FingerprintSHA256(pub)] = true
+		fp := ssh.FingerprintSHA256(pub)
+		if comment = strings.TrimSpace(comment); comment == "" {
+			comment = fp
+		}
+		allowed[fp] = comment
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
@@ -772,17 +922,6 @@ func generateHostSigner() ssh.Signer {
 	return signer
 }
 
-func loadPrivateKey(path string) (ssh.Signer, error) {
-	if path == "" {
-		return nil, fmt.Errorf("sandbox key path is required")
-	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	return ssh.ParsePrivateKey(data)
-}
-
 // resolveCLIPath finds the agentlab CLI binary.
 func resolveCLIPath(explicit string) (string, error) {
 	if explicit != "" {
@@ -818,6 +957,13 @@ type sandboxCreateRequest struct {
 	Name      string `json:"name,omitempty"`
 	Profile   string `json:"profile"`
 	Keepalive *bool  `json:"keepalive,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+}
+
+type accessCheckResponse struct {
+	Allowed    bool   `json:"allowed"`
+	User       string `json:"user,omitempty"`
+	Permission string `json:"permission"`
 }
 
 type sandboxResponse struct {
@@ -865,6 +1011,12 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, payload any
 	if payload != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	return c.do(req)
+}
+
+// do sends req and returns the response body, turning an error status into
+// an error carrying the daemon's message.
+func (c *apiClient) do(req *http.Request) ([]byte, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -885,12 +1037,12 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, payload any
 	return nil, fmt.Errorf("api error: status %d", resp.StatusCode)
 }
 
-func createSandbox(ctx context.Context, client *apiClient, profile string, keepalive bool) (sandboxResponse, error) {
+func createSandbox(ctx context.Context, client *apiClient, profile, owner string, keepalive bool) (sandboxResponse, error) {
 	profile = strings.TrimSpace(profile)
 	if profile == "" {
 		return sandboxResponse{}, fmt.Errorf("profile is required")
 	}
-	req := sandboxCreateRequest{Profile: profile, Keepalive: &keepalive}
+	req := sandboxCreateRequest{Profile: profile, Keepalive: &keepalive, Owner: owner}
 	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/sandboxes", req)
 	if err != nil {
 		return sandboxResponse{}, err
@@ -0,0 +1,143 @@
+//go:build sshgateway
+// +build sshgateway
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/agentlab/agentlab/internal/recording"
+)
+
+// sessionRecorder tees a proxied session into an asciicast v2 spool file.
+// When the session ends the file is uploaded to the daemon, which stores it
+// as an artifact of the sandbox, attributed to the connecting user.
+type sessionRecorder struct {
+	file *os.File
+	rec  *recording.Writer
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newSessionRecorder spools to a temp file under dir (the system temp dir
+// when empty). The header carries the client's terminal size and TERM.
+func newSessionRecorder(dir string, vmid int, pty *ptyRequest) (*sessionRecorder, error) {
+	file, err := os.CreateTemp(dir, fmt.Sprintf("agentlab-session-%d-*.cast", vmid))
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+	header := recording.Header{Title: fmt.Sprintf("sandbox %d", vmid)}
+	if pty != nil {
+		header.Width = int(pty.Cols)
+		header.Height = int(pty.Rows)
+		if pty.Term != "" {
+			header.Env = map[string]string{"TERM": pty.Term}
+		}
+	}
+	rec, err := recording.NewWriter(file, header)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+	return &sessionRecorder{file: file, rec: rec}, nil
+}
+
+// output returns a writer that records what it passes through to w.
+func (r *sessionRecorder) output(w io.Writer) io.Writer {
+	return &recordingTee{w: w, record: r.rec.Output}
+}
+
+// input returns a reader that records what the user sends.
+func (r *sessionRecorder) input(src io.Reader) io.Reader {
+	return &recordingReader{r: src, record: r.rec.Input}
+}
+
+func (r *sessionRecorder) resize(cols, rows uint32) {
+	_ = r.rec.Resize(int(cols), int(rows))
+}
+
+func (r *sessionRecorder) close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = r.file.Close()
+	})
+	return r.closeErr
+}
+
+// upload sends the finished recording to the daemon and removes the spool
+// file. On failure the file is kept so the audit trail is not lost, and its
+// path is part of the returned error.
+func (r *sessionRecorder) upload(ctx context.Context, client *apiClient, vmid int, user string) error {
+	path := r.file.Name()
+	if err := r.close(); err != nil {
+		return fmt.Errorf("close recording %s: %w", path, err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open recording %s: %w", path, err)
+	}
+	defer file.Close()
+	query := url.Values{}
+	if user != "" {
+		query.Set("user", user)
+	}
+	endpoint := "/v1/sandboxes/" + strconv.Itoa(vmid) + "/recordings"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	if _, err := client.doUpload(ctx, endpoint, "application/x-asciicast", file); err != nil {
+		return fmt.Errorf("upload recording (kept at %s): %w", path, err)
+	}
+	_ = os.Remove(path)
+	return nil
+}
+
+// discard drops a recording that never got past its header.
+func (r *sessionRecorder) discard() {
+	_ = r.close()
+	_ = os.Remove(r.file.Name())
+}
+
+type recordingTee struct {
+	w      io.Writer
+	record func([]byte) error
+}
+
+func (t *recordingTee) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if n > 0 {
+		_ = t.record(p[:n])
+	}
+	return n, err
+}
+
+type recordingReader struct {
+	r      io.Reader
+	record func([]byte) error
+}
+
+func (t *recordingReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		_ = t.record(p[:n])
+	}
+	return n, err
+}
+
+// doUpload posts a raw body and returns the response payload.
+func (c *apiClient) doUpload(ctx context.Context, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://unix"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req)
+}
@@ -0,0 +1,75 @@
+//go:build sshgateway
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/recording"
+)
+
+func TestSessionRecorder_RecordsBothDirectionsAndResizes(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := newSessionRecorder(dir, 1001, &ptyRequest{Term: "xterm-256color", Cols: 100, Rows: 30})
+	require.NoError(t, err)
+
+	var terminal bytes.Buffer
+	_, err = rec.output(&terminal).Write([]byte("$ "))
+	require.NoError(t, err)
+	typed, err := io.ReadAll(rec.input(strings.NewReader("ls\r")))
+	require.NoError(t, err)
+	assert.Equal(t, "ls\r", string(typed))
+	rec.resize(120, 40)
+	require.NoError(t, rec.close())
+	assert.Equal(t, "$ ", terminal.String(), "output still reaches the client")
+
+	file, err := os.Open(rec.file.Name())
+	require.NoError(t, err)
+	defer file.Close()
+	reader, header, err := recording.NewReader(file)
+	require.NoError(t, err)
+	assert.Equal(t, 100, header.Width)
+	assert.Equal(t, 30, header.Height)
+	assert.Equal(t, "xterm-256color", header.Env["TERM"])
+
+	var codes, data []string
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		codes = append(codes, ev.Code)
+		data = append(data, ev.Data)
+	}
+	assert.Equal(t, []string{"o", "i", "r"}, codes)
+	assert.Equal(t, []string{"$ ", "ls\r", "120x40"}, data)
+}
+
+func TestLoadAuthorizedKeys_NamesUserByComment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	keys := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGb6p9Jm1zG3m2j7F0cQv1n3o8Y2P3Jv0oYy3xH6bq1m alice@laptop\n" +
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIO0D2kF4pX3Wc5ZgC8mF2sI1yqPz0n0KqR9B7hXj2uLd\n"
+	require.NoError(t, os.WriteFile(path, []byte(keys), 0o600))
+
+	allowed, err := loadAuthorizedKeys(path)
+	require.NoError(t, err)
+	require.Len(t, allowed, 2)
+	var users []string
+	for fp, user := range allowed {
+		if user == fp {
+			users = append(users, "<fingerprint>")
+			continue
+		}
+		users = append(users, user)
+	}
+	assert.ElementsMatch(t, []string{"alice@laptop", "<fingerprint>"}, users)
+}
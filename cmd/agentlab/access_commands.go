@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// accessGrant mirrors one grant of the daemon's /v1/access response.
+type accessGrant struct {
+	ID        int64  `json:"id"`
+	Resource  string `json:"resource"`
+	User      string `json:"user"`
+	Level     string `json:"level"`
+	GrantedBy string `json:"granted_by,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type accessListResponse struct {
+	Resource string        `json:"resource,omitempty"`
+	Owner    string        `json:"owner,omitempty"`
+	Team     string        `json:"team,omitempty"`
+	Grants   []accessGrant `json:"grants"`
+}
+
+func runAccessCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printAccessUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("access command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printAccessUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "ls":
+		return runAccessList(ctx, args[1:], base)
+	case "grant":
+		return runAccessGrant(ctx, args[1:], base)
+	case "revoke":
+		return runAccessRevoke(ctx, args[1:], base)
+	case "team":
+		return runAccessTeam(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printAccessUsage()
+		}
+		return unknownSubcommandError("access", args[0], accessSubcommands)
+	}
+}
+
+func printAccessUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab access <command>
+
+Commands:
+  ls        List the owner, team and grants of a resource (or every grant)
+  grant     Share a resource with a user at read, operate or admin
+  revoke    Remove an access grant
+  team      Hand a resource to a team, or back to its owner alone
+
+Resources are sandbox:<vmid> (or a bare vmid), workspace:<id|name> and
+session:<id|name>. The owner and owning team of a resource hold admin access
+to it when rbac_enabled is set on agentlabd.
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printAccessListUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab access ls [<resource>]")
+}
+
+func printAccessGrantUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab access grant --user NAME --level LEVEL <resource>
+
+Levels:
+  read       Inspect the resource, its events and artifacts
+  operate    Also start, stop, snapshot, attach and ssh
+  admin      Also update, revert, destroy and manage access
+
+Flags:
+  --user     User to share with
+  --level    read, operate or admin
+  --json     Output JSON
+`)
+}
+
+func printAccessRevokeUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab access revoke <grant-id>")
+}
+
+func printAccessTeamUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab access team <resource> <team|->")
+}
+
+// parseAccessResource accepts sandbox:<vmid>, workspace:<ref> and
+// session:<ref>, plus a bare vmid for sandboxes.
+func parseAccessResource(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if vmid, err := strconv.Atoi(raw); err == nil {
+		if vmid <= 0 {
+			return "", fmt.Errorf("vmid must be positive")
+		}
+		return "sandbox:" + raw, nil
+	}
+	kind, ref, ok := strings.Cut(raw, ":")
+	if !ok || strings.TrimSpace(ref) == "" {
+		return "", fmt.Errorf("resource must be sandbox:<vmid>, workspace:<id> or session:<id>")
+	}
+	switch kind {
+	case "sandbox", "workspace", "session":
+	default:
+		return "", fmt.Errorf("resource must be sandbox:<vmid>, workspace:<id> or session:<id>")
+	}
+	return kind + ":" + strings.TrimSpace(ref), nil
+}
+
+func runAccessList(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("access ls")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAccessListUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	path := "/v1/access"
+	if fs.NArg() == 1 {
+		resource, err := parseAccessResource(fs.Arg(0))
+		if err != nil {
+			return newUsageError(err, true)
+		}
+		path += "?resource=" + url.QueryEscape(resource)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp accessListResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	return writeAccessList(os.Stdout, resp)
+}
+
+func writeAccessList(out io.Writer, resp accessListResponse) error {
+	if resp.Resource != "" {
+		fmt.Fprintf(out, "Resource: %s\n", resp.Resource)
+		fmt.Fprintf(out, "Owner: %s\n", orDash(resp.Owner))
+		fmt.Fprintf(out, "Team: %s\n", orDash(resp.Team))
+		fmt.Fprintln(out)
+	}
+	if len(resp.Grants) == 0 {
+		fmt.Fprintln(out, "No access grants.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRESOURCE\tUSER\tLEVEL\tGRANTED BY")
+	for _, g := range resp.Grants {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", g.ID, g.Resource, g.User, g.Level, orDash(g.GrantedBy))
+	}
+	return w.Flush()
+}
+
+func runAccessGrant(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("access grant")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var userName, level string
+	fs.StringVar(&userName, "user", "", "user to share with")
+	fs.StringVar(&level, "level", "", "read, operate or admin")
+	if err := parseFlags(fs, args, printAccessGrantUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("resource is required"), true)
+	}
+	resource, err := parseAccessResource(fs.Arg(0))
+	if err != nil {
+		return newUsageError(err, true)
+	}
+	userName, level = strings.TrimSpace(userName), strings.TrimSpace(level)
+	if userName == "" {
+		return newUsageError(fmt.Errorf("--user is required"), true)
+	}
+	switch level {
+	case "read", "operate", "admin":
+	default:
+		return newUsageError(fmt.Errorf("--level must be read, operate or admin"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/access", map[string]string{
+		"resource": resource,
+		"user":     userName,
+		"level":    level,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var grant accessGrant
+	if err := json.Unmarshal(payload, &grant); err != nil {
+		return err
+	}
+	fmt.Printf("Granted %s %s access to %s (grant %d)\n", grant.User, grant.Level, grant.Resource, grant.ID)
+	return nil
+}
+
+func runAccessRevoke(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("access revoke")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAccessRevokeUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("grant id is required"), true)
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || id <= 0 {
+		return newUsageError(fmt.Errorf("grant id must be a positive integer"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodDelete, "/v1/access/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	fmt.Printf("Access grant %d revoked\n", id)
+	return nil
+}
+
+func runAccessTeam(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("access team")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAccessTeamUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return newUsageError(fmt.Errorf("resource and team are required (use - to clear the team)"), true)
+	}
+	resource, err := parseAccessResource(fs.Arg(0))
+	if err != nil {
+		return newUsageError(err, true)
+	}
+	team := strings.TrimSpace(fs.Arg(1))
+	if team == "-" {
+		team = ""
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/access/team", map[string]string{
+		"resource": resource,
+		"team":     team,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp accessListResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	if resp.Team == "" {
+		fmt.Printf("%s no longer belongs to a team\n", resp.Resource)
+		return nil
+	}
+	fmt.Printf("%s now belongs to team %s\n", resp.Resource, resp.Team)
+	return nil
+}
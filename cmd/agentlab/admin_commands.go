@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// configReloadResponse mirrors the daemon's POST /v1/admin/reload result.
+type configReloadResponse struct {
+	Trigger         string   `json:"trigger"`
+	Profiles        int      `json:"profiles"`
+	ProfilesAdded   []string `json:"profiles_added"`
+	ProfilesRemoved []string `json:"profiles_removed"`
+	ProfilesChanged []string `json:"profiles_changed"`
+	ConfigChanged   []string `json:"config_changed"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// backupInfo mirrors one backup in the daemon's backup responses.
+type backupInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	Encrypted bool      `json:"encrypted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupResponse mirrors the daemon's POST /v1/admin/backups result.
+type backupResponse struct {
+	backupInfo
+	Trigger       string   `json:"trigger"`
+	SchemaVersion int      `json:"schema_version"`
+	Pruned        []string `json:"pruned"`
+}
+
+type backupsResponse struct {
+	Backups []backupInfo `json:"backups"`
+}
+
+// restoreResponse mirrors the daemon's POST /v1/admin/restore result.
+type restoreResponse struct {
+	Source          string `json:"source"`
+	StagedPath      string `json:"staged_path"`
+	SchemaVersion   int    `json:"schema_version"`
+	RestartRequired bool   `json:"restart_required"`
+}
+
+// rotateKeyResponse mirrors the daemon's POST /v1/admin/rotate-key result.
+type rotateKeyResponse struct {
+	Version     int   `json:"version"`
+	Reencrypted int   `json:"reencrypted"`
+	Retired     []int `json:"retired"`
+}
+
+func runAdminCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printAdminUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("admin command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printAdminUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "reload":
+		return runAdminReload(ctx, args[1:], base)
+	case "backup":
+		return runAdminBackup(ctx, args[1:], base)
+	case "restore":
+		return runAdminRestore(ctx, args[1:], base)
+	case "events":
+		return runAdminEventsCommand(ctx, args[1:], base)
+	case "rotate-key":
+		return runAdminRotateKey(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printAdminUsage()
+		}
+		return unknownSubcommandError("admin", args[0], []string{"reload", "backup", "restore", "events", "rotate-key"})
+	}
+}
+
+func printAdminUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin <command>
+
+Commands:
+  reload        Re-read the daemon config file and profiles without restarting
+  backup        Back up the daemon database, or list backups
+  restore       Stage a backup to replace the daemon database on next start
+  events        Export archived and live events, messages and audit log
+  rotate-key    Re-encrypt integration secrets under a new key
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printAdminReloadUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin reload [flags]
+
+Re-read the daemon config file and profiles_dir, the same as SIGHUP.
+Running jobs and sandboxes are not interrupted. An invalid config or
+profile is rejected and the previous configuration stays active.
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printAdminBackupUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin backup [--list]
+
+Write a consistent copy of the daemon database to backup_dir while
+agentlabd keeps running. The copy is age-encrypted when backup_encrypt
+is set, and backups beyond backup_retention are removed.
+
+Flags:
+  --list    List existing backups instead of writing one
+  --json    Output JSON
+`)
+}
+
+func printAdminRestoreUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin restore <backup>
+
+Verify a backup and stage it to replace the daemon database. <backup> is
+a file name in backup_dir or an absolute path on the daemon host; .age
+backups are decrypted with secrets_age_key_path. The backup must pass an
+integrity check and must not come from a newer schema.
+
+The restore is applied when agentlabd next starts. The current database
+is kept beside it, and sandboxes are then reconciled against the backend
+inventory.
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printAdminRotateKeyUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin rotate-key
+
+Generate a new integration encryption key and re-encrypt every
+integration secret under it in one transaction. The keyring in
+integration_keyring_path is saved with the old and new keys first, then
+pruned to the new key once every secret has moved.
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printAdminEventsUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin events <command>
+
+Commands:
+  export    Export events, messages and audit log entries as JSONL
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printAdminEventsExportUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab admin events export [--since TIME] [--output FILE]
+
+Stream every event, message and audit log entry recorded at or after
+--since as JSONL, one record per line. Rows already compacted into the
+archive_dir archives are included, so the export covers the full history
+the daemon still holds. Archives come first, then the live tables.
+
+Flags:
+  --since     RFC3339 timestamp, or a duration such as 720h meaning that
+              long ago (default: everything)
+  --output    Write to FILE (mode 0600) instead of stdout
+  --json      With --output, print a JSON summary
+`)
+}
+
+func runAdminReload(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("admin reload")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAdminReloadUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/admin/reload", nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp configReloadResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	printConfigReload(os.Stdout, resp)
+	return nil
+}
+
+func printConfigReload(w io.Writer, resp configReloadResponse) {
+	fmt.Fprintf(w, "Reloaded %d %s\n", resp.Profiles, plural(resp.Profiles, "profile", "profiles"))
+	for _, row := range []struct {
+		label  string
+		values []string
+	}{
+		{"Profiles added", resp.ProfilesAdded},
+		{"Profiles removed", resp.ProfilesRemoved},
+		{"Profiles changed", resp.ProfilesChanged},
+		{"Config applied", resp.ConfigChanged},
+		{"Restart required", resp.RestartRequired},
+	} {
+		if len(row.values) > 0 {
+			fmt.Fprintf(w, "%s: %s\n", row.label, strings.Join(row.values, ", "))
+		}
+	}
+}
+
+func runAdminBackup(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("admin backup")
+	opts := base
+	opts.bind(fs)
+	var list bool
+	fs.BoolVar(&list, "list", false, "list existing backups")
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAdminBackupUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	if list {
+		payload, err := client.doJSON(ctx, http.MethodGet, "/v1/admin/backups", nil)
+		if err != nil {
+			return err
+		}
+		if opts.jsonOutput {
+			return prettyPrintJSON(os.Stdout, payload)
+		}
+		var resp backupsResponse
+		if err := json.Unmarshal(payload, &resp); err != nil {
+			return err
+		}
+		printBackupList(os.Stdout, resp.Backups)
+		return nil
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/admin/backups", nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp backupResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	encrypted := ""
+	if resp.Encrypted {
+		encrypted = ", age-encrypted"
+	}
+	fmt.Fprintf(os.Stdout, "Backup written to %s (%d bytes, schema v%d%s)\n", resp.Path, resp.SizeBytes, resp.SchemaVersion, encrypted)
+	if len(resp.Pruned) > 0 {
+		fmt.Fprintf(os.Stdout, "Pruned %d old %s: %s\n", len(resp.Pruned), plural(len(resp.Pruned), "backup", "backups"), strings.Join(resp.Pruned, ", "))
+	}
+	return nil
+}
+
+func printBackupList(w io.Writer, backups []backupInfo) {
+	if len(backups) == 0 {
+		fmt.Fprintln(w, "No backups")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCREATED\tSIZE\tENCRYPTED")
+	for _, backup := range backups {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%t\n", backup.Name, backup.CreatedAt.UTC().Format(time.RFC3339), backup.SizeBytes, backup.Encrypted)
+	}
+	_ = tw.Flush()
+}
+
+func runAdminRestore(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("admin restore")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAdminRestoreUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || strings.TrimSpace(fs.Arg(0)) == "" {
+		if !opts.jsonOutput {
+			printAdminRestoreUsage()
+		}
+		return newUsageError(fmt.Errorf("backup is required"), false)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/admin/restore", map[string]string{"backup": strings.TrimSpace(fs.Arg(0))})
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp restoreResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Verified %s (schema v%d) and staged it at %s\n", resp.Source, resp.SchemaVersion, resp.StagedPath)
+	if resp.RestartRequired {
+		fmt.Fprintln(os.Stdout, "Restart agentlabd to apply the restore; sandboxes are reconciled against the backend on startup.")
+	}
+	return nil
+}
+
+func runAdminRotateKey(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("admin rotate-key")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAdminRotateKeyUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/admin/rotate-key", nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp rotateKeyResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Rotated integration key to version %d (%d %s re-encrypted)\n", resp.Version, resp.Reencrypted, plural(resp.Reencrypted, "secret", "secrets"))
+	if len(resp.Retired) > 0 {
+		retired := make([]string, 0, len(resp.Retired))
+		for _, version := range resp.Retired {
+			retired = append(retired, strconv.Itoa(version))
+		}
+		fmt.Fprintf(os.Stdout, "Retired key %s: %s\n", plural(len(retired), "version", "versions"), strings.Join(retired, ", "))
+	}
+	return nil
+}
+
+func runAdminEventsCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printAdminEventsUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("admin events command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printAdminEventsUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "export":
+		return runAdminEventsExport(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printAdminEventsUsage()
+		}
+		return unknownSubcommandError("admin events", args[0], []string{"export"})
+	}
+}
+
+// exportRecord is the part of an export line the CLI inspects.
+type exportRecord struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+func runAdminEventsExport(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("admin events export")
+	opts := base
+	opts.bind(fs)
+	var sinceFlag, output string
+	fs.StringVar(&sinceFlag, "since", "", "RFC3339 timestamp or duration ago")
+	fs.StringVar(&output, "output", "", "write the export to a file")
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printAdminEventsExportUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	path := "/v1/admin/events/export"
+	if strings.TrimSpace(sinceFlag) != "" {
+		since, err := parseExportSince(sinceFlag, time.Now())
+		if err != nil {
+			return newUsageError(err, false)
+		}
+		path += "?since=" + url.QueryEscape(since.Format(time.RFC3339))
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	resp, err := client.doStream(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if strings.TrimSpace(output) == "" {
+		_, err := copyExport(os.Stdout, resp.Body)
+		return err
+	}
+	output = strings.TrimSpace(output)
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	records, err := copyExport(tmp, resp.Body)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		data, err := json.Marshal(map[string]any{"output": output, "records": records})
+		if err != nil {
+			return err
+		}
+		_, _ = os.Stdout.Write(append(data, '\n'))
+		return nil
+	}
+	fmt.Fprintf(os.Stdout, "Exported %d %s to %s\n", records, plural(records, "record", "records"), output)
+	return nil
+}
+
+// parseExportSince accepts an RFC3339 timestamp or a duration before now.
+func parseExportSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts.UTC(), nil
+	}
+	dur, err := time.ParseDuration(value)
+	if err != nil || dur < 0 {
+		return time.Time{}, fmt.Errorf("--since must be an RFC3339 timestamp or a positive duration")
+	}
+	return now.Add(-dur).UTC(), nil
+}
+
+// copyExport copies JSONL records from r to w and returns how many it
+// copied. A trailing error record from the daemon becomes an error.
+func copyExport(w io.Writer, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record exportRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return count, fmt.Errorf("decode export record: %w", err)
+		}
+		if record.Type == "error" {
+			return count, errors.New("export failed: " + record.Error)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("read export: %w", err)
+	}
+	return count, nil
+}
@@ -50,8 +50,12 @@ const (
 	apiErrorCodeServerError             = "v1/internal/server_error"
 	apiErrorCodeServiceUnavailable      = "v1/internal/unavailable"
 	apiErrorCodeInternalError           = "v1/internal/error"
+	apiErrorCodeApprovalRequired        = "v1/approval/required"
 )
 
+// pendingOperationHeader marks a response holding the request for approval.
+const pendingOperationHeader = "X-AgentLab-Pending-Operation"
+
 // apiClient is an HTTP client for communicating with agentlabd.
 type apiClient struct {
 	socketPath string
@@ -104,6 +108,70 @@ type jobCreateRequest struct {
 	WorkspaceCreate      *workspaceCreateRequest `json:"workspace_create,omitempty"`
 	WorkspaceWaitSeconds *int                    `json:"workspace_wait_seconds,omitempty"`
 	SessionID            *string                 `json:"session_id,omitempty"`
+	Env                  map[string]string       `json:"env,omitempty"`
+	DependsOn            []string                `json:"depends_on,omitempty"`
+	DependsCondition     string                  `json:"depends_condition,omitempty"`
+	ParentArtifacts      bool                    `json:"parent_artifacts,omitempty"`
+	Result               *jobResultOptions       `json:"result,omitempty"`
+}
+
+// jobResultOptions asks the daemon to push the job's commits and optionally
+// open a pull request once the job completes.
+type jobResultOptions struct {
+	PushBranch  string `json:"push_branch,omitempty"`
+	OpenPR      bool   `json:"open_pr,omitempty"`
+	PRBase      string `json:"pr_base,omitempty"`
+	PRTitle     string `json:"pr_title,omitempty"`
+	Integration string `json:"integration,omitempty"`
+}
+
+// jobPublishResult is the "publish" entry of a job result.
+type jobPublishResult struct {
+	Branch string `json:"branch"`
+	Commit string `json:"commit,omitempty"`
+	PRURL  string `json:"pr_url,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// jobGroupCreateRequest fans a base job spec out across matrix axes.
+type jobGroupCreateRequest struct {
+	Name   string           `json:"name,omitempty"`
+	Base   jobCreateRequest `json:"base"`
+	Matrix jobMatrix        `json:"matrix"`
+}
+
+type jobMatrix struct {
+	Ref     []string               `json:"ref,omitempty"`
+	Profile []string               `json:"profile,omitempty"`
+	Task    []jobMatrixTaskVariant `json:"task,omitempty"`
+	Env     []jobMatrixEnvVariant  `json:"env,omitempty"`
+}
+
+type jobMatrixTaskVariant struct {
+	Name string `json:"name,omitempty"`
+	Task string `json:"task"`
+}
+
+type jobMatrixEnvVariant struct {
+	Name string            `json:"name,omitempty"`
+	Env  map[string]string `json:"env"`
+}
+
+// jobGroupResponse reports a job group and its children.
+type jobGroupResponse struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name,omitempty"`
+	Status    string         `json:"status"`
+	Axes      []string       `json:"axes"`
+	Counts    map[string]int `json:"counts"`
+	Jobs      []jobResponse  `json:"jobs"`
+	CreatedAt string         `json:"created_at"`
+	UpdatedAt string         `json:"updated_at"`
+}
+
+type jobGroupArtifactsResponse struct {
+	GroupID string              `json:"group_id"`
+	Jobs    []artifactsResponse `json:"jobs"`
 }
 
 type preflightIssue struct {
@@ -149,22 +217,28 @@ type jobValidatePlanResponse struct {
 
 // jobResponse represents a job returned from the API.
 type jobResponse struct {
-	ID          string          `json:"id"`
-	RepoURL     string          `json:"repo_url"`
-	Ref         string          `json:"ref"`
-	Profile     string          `json:"profile"`
-	Task        string          `json:"task,omitempty"`
-	Mode        string          `json:"mode,omitempty"`
-	TTLMinutes  *int            `json:"ttl_minutes,omitempty"`
-	Keepalive   bool            `json:"keepalive"`
-	WorkspaceID *string         `json:"workspace_id,omitempty"`
-	SessionID   *string         `json:"session_id,omitempty"`
-	Status      string          `json:"status"`
-	SandboxVMID *int            `json:"sandbox_vmid,omitempty"`
-	Result      json.RawMessage `json:"result,omitempty"`
-	Events      []eventResponse `json:"events,omitempty"`
-	CreatedAt   string          `json:"created_at"`
-	UpdatedAt   string          `json:"updated_at"`
+	ID               string            `json:"id"`
+	RepoURL          string            `json:"repo_url"`
+	Ref              string            `json:"ref"`
+	Profile          string            `json:"profile"`
+	Task             string            `json:"task,omitempty"`
+	Mode             string            `json:"mode,omitempty"`
+	TTLMinutes       *int              `json:"ttl_minutes,omitempty"`
+	Keepalive        bool              `json:"keepalive"`
+	WorkspaceID      *string           `json:"workspace_id,omitempty"`
+	SessionID        *string           `json:"session_id,omitempty"`
+	GroupID          *string           `json:"group_id,omitempty"`
+	Matrix           map[string]string `json:"matrix,omitempty"`
+	DependsOn        []string          `json:"depends_on,omitempty"`
+	DependsCondition string            `json:"depends_condition,omitempty"`
+	ParentArtifacts  bool              `json:"parent_artifacts,omitempty"`
+	ResultOptions    *jobResultOptions `json:"result_options,omitempty"`
+	Status           string            `json:"status"`
+	SandboxVMID      *int              `json:"sandbox_vmid,omitempty"`
+	Result           json.RawMessage   `json:"result,omitempty"`
+	Events           []eventResponse   `json:"events,omitempty"`
+	CreatedAt        string            `json:"created_at"`
+	UpdatedAt        string            `json:"updated_at"`
 }
 
 // artifactInfo represents a single artifact uploaded from a sandbox.
@@ -183,6 +257,23 @@ type artifactsResponse struct {
 	Artifacts []artifactInfo `json:"artifacts"`
 }
 
+// sandboxRecording describes a recorded SSH gateway session of a sandbox.
+type sandboxRecording struct {
+	ID        int64  `json:"id"`
+	VMID      int    `json:"vmid"`
+	User      string `json:"user,omitempty"`
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	Sha256    string `json:"sha256"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// sandboxRecordingsResponse contains the session recordings of a sandbox.
+type sandboxRecordingsResponse struct {
+	VMID       int                `json:"vmid"`
+	Recordings []sandboxRecording `json:"recordings"`
+}
+
 // sandboxCreateRequest contains parameters for creating a new sandbox.
 type sandboxCreateRequest struct {
 	Name       string   `json:"name,omitempty"`
@@ -196,6 +287,7 @@ type sandboxCreateRequest struct {
 	Image      string   `json:"image,omitempty"`
 	Prompt     string   `json:"prompt,omitempty"`
 	Tags       []string `json:"tags,omitempty"`
+	Team       string   `json:"team,omitempty"`
 }
 
 type sandboxValidatePlanRequest struct {
@@ -261,15 +353,27 @@ type sandboxResponse struct {
 	State         string                    `json:"state"`
 	IP            string                    `json:"ip,omitempty"`
 	WorkspaceID   *string                   `json:"workspace_id,omitempty"`
+	Owner         string                    `json:"owner,omitempty"`
+	Team          string                    `json:"team,omitempty"`
 	Network       *sandboxNetworkResponse   `json:"network,omitempty"`
 	Keepalive     bool                      `json:"keepalive"`
 	LeaseExpires  *string                   `json:"lease_expires_at,omitempty"`
 	LastUsedAt    *string                   `json:"last_used_at,omitempty"`
 	Resources     *sandboxResourcesResponse `json:"resources,omitempty"`
+	KV            []sandboxKVResponse       `json:"kv,omitempty"`
 	CreatedAt     string                    `json:"created_at"`
 	LastUpdatedAt string                    `json:"updated_at"`
 }
 
+// sandboxKVResponse is a status value the guest published through the
+// metadata service.
+type sandboxKVResponse struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	JobID     string `json:"job_id,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
 type sandboxNetworkResponse struct {
 	Mode          string `json:"mode,omitempty"`
 	Firewall      *bool  `json:"firewall,omitempty"`
@@ -314,6 +418,7 @@ type sandboxReconcileResponse struct {
 type profileResponse struct {
 	Name         string `json:"name"`
 	TemplateVMID int    `json:"template_vmid"`
+	Template     string `json:"template,omitempty"`
 	UpdatedAt    string `json:"updated_at"`
 }
 
@@ -429,6 +534,7 @@ type workspaceCreateRequest struct {
 	Name    string `json:"name"`
 	SizeGB  int    `json:"size_gb"`
 	Storage string `json:"storage,omitempty"`
+	Team    string `json:"team,omitempty"`
 }
 
 // workspaceForkRequest contains parameters for forking a workspace.
@@ -457,6 +563,8 @@ type workspaceResponse struct {
 	VolumeID     string `json:"volid"`
 	SizeGB       int    `json:"size_gb"`
 	AttachedVMID *int   `json:"attached_vmid,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	Team         string `json:"team,omitempty"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 }
@@ -501,6 +609,7 @@ type sessionCreateRequest struct {
 	WorkspaceID     *string                 `json:"workspace_id,omitempty"`
 	WorkspaceCreate *workspaceCreateRequest `json:"workspace_create,omitempty"`
 	Branch          string                  `json:"branch,omitempty"`
+	Team            string                  `json:"team,omitempty"`
 }
 
 // sessionResponse represents a session returned from the API.
@@ -511,6 +620,8 @@ type sessionResponse struct {
 	CurrentVMID *int   `json:"current_vmid,omitempty"`
 	Profile     string `json:"profile"`
 	Branch      string `json:"branch,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Team        string `json:"team,omitempty"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 }
@@ -652,6 +763,7 @@ type messageResponse struct {
 	Kind      string          `json:"kind,omitempty"`
 	Text      string          `json:"text,omitempty"`
 	Payload   json.RawMessage `json:"json,omitempty"`
+	ReplyTo   int64           `json:"reply_to,omitempty"`
 }
 
 type messagesResponse struct {
@@ -783,9 +895,30 @@ func (c *apiClient) doJSON(ctx context.Context, method, path string, payload any
 	if resp.StatusCode >= 400 {
 		return nil, parseAPIError(resp.StatusCode, data)
 	}
+	if id := strings.TrimSpace(resp.Header.Get(pendingOperationHeader)); id != "" {
+		return nil, pendingOperationError(id, data)
+	}
 	return data, nil
 }
 
+// pendingOperationError reports a request the daemon held for approval
+// instead of running. Callers expecting the operation's own response must not
+// mistake the 202 for success.
+func pendingOperationError(id string, data []byte) error {
+	var op pendingOperationResponse
+	_ = json.Unmarshal(data, &op)
+	msg := fmt.Sprintf("operation %s is awaiting approval", id)
+	if op.RequiredApprovals > 0 && op.ApproverRole != "" {
+		msg = fmt.Sprintf("operation %s is awaiting approval by %d %s user(s)", id, op.RequiredApprovals, op.ApproverRole)
+	}
+	return withHints(apiResponseError{
+		Status:  http.StatusAccepted,
+		ErrorID: "approval required",
+		Code:    apiErrorCodeApprovalRequired,
+		Message: msg,
+	}, "agentlab approval show "+id)
+}
+
 // doRequest sends an HTTP request with a raw body and custom headers.
 // Returns the raw HTTP response for streaming operations.
 func (c *apiClient) doRequest(ctx context.Context, method, path string, body io.Reader, headers map[string]string) (*http.Response, error) {
@@ -816,6 +949,29 @@ func (c *apiClient) doRequest(ctx context.Context, method, path string, body io.
 	return resp, nil
 }
 
+// doStream sends a GET request for a streamed response. Unlike doRequest it
+// does not apply the client timeout, which would cancel the body while it is
+// still being read; the caller must close the body.
+func (c *apiClient) doStream(ctx context.Context, path string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s %s via %s: %w", http.MethodGet, path, c.target(), err)
+	}
+	if resp.StatusCode >= 400 {
+		data, readErr := io.ReadAll(io.LimitReader(resp.Body, maxJSONOutputBytes))
+		_ = resp.Body.Close()
+		if readErr != nil {
+			return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+		}
+		return nil, parseAPIError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
 // parseAPIError converts an HTTP error response into an error.
 // It attempts to parse the response as JSON and extract the error message.
 func parseAPIError(status int, data []byte) error {
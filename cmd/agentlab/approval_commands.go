@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// pendingOperationResponse mirrors an operation held by the daemon's approval
+// policy.
+type pendingOperationResponse struct {
+	ID                string                      `json:"id"`
+	Permission        string                      `json:"permission"`
+	Method            string                      `json:"method"`
+	Path              string                      `json:"path"`
+	Body              json.RawMessage             `json:"body,omitempty"`
+	RequestedBy       string                      `json:"requested_by"`
+	ApproverRole      string                      `json:"approver_role"`
+	RequiredApprovals int                         `json:"required_approvals"`
+	Approvals         []operationApprovalResponse `json:"approvals"`
+	Status            string                      `json:"status"`
+	CreatedAt         string                      `json:"created_at"`
+	ExpiresAt         string                      `json:"expires_at"`
+	DecidedAt         string                      `json:"decided_at,omitempty"`
+	DecidedBy         string                      `json:"decided_by,omitempty"`
+	Reason            string                      `json:"reason,omitempty"`
+	ResultStatus      int                         `json:"result_status,omitempty"`
+	Result            json.RawMessage             `json:"result,omitempty"`
+}
+
+type operationApprovalResponse struct {
+	User       string `json:"user"`
+	ApprovedAt string `json:"approved_at"`
+}
+
+type pendingOperationsResponse struct {
+	Operations []pendingOperationResponse `json:"operations"`
+}
+
+func runApprovalCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printApprovalUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("approval command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printApprovalUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "ls":
+		return runApprovalList(ctx, args[1:], base)
+	case "show":
+		return runApprovalShow(ctx, args[1:], base)
+	case "approve":
+		return runApprovalDecide(ctx, "approve", args[1:], base)
+	case "reject":
+		return runApprovalDecide(ctx, "reject", args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printApprovalUsage()
+		}
+		return unknownSubcommandError("approval", args[0], approvalSubcommands)
+	}
+}
+
+func printApprovalUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab approval <command>
+
+Commands:
+  ls         List operations held for approval
+  show       Show one operation, its approvals and its result
+  approve    Approve an operation; it runs once enough users approve
+  reject     Reject an operation so it never runs
+
+Operations are held when agentlabd's approval_policy_path marks their
+permission as needing approval by other users.
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printApprovalListUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab approval ls [--status STATUS]
+
+Flags:
+  --status    pending (default), executing, executed, failed, rejected, expired or all
+  --json      Output JSON
+`)
+}
+
+func printApprovalShowUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab approval show <operation-id>")
+}
+
+func printApprovalApproveUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab approval approve <operation-id>")
+}
+
+func printApprovalRejectUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab approval reject [--reason TEXT] <operation-id>
+
+Flags:
+  --reason    Why the operation was rejected
+  --json      Output JSON
+`)
+}
+
+func runApprovalList(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("approval ls")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var status string
+	fs.StringVar(&status, "status", "", "filter by status")
+	if err := parseFlags(fs, args, printApprovalListUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	path := "/v1/approvals"
+	if status = strings.TrimSpace(status); status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp pendingOperationsResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	return writeApprovalList(os.Stdout, resp.Operations)
+}
+
+func writeApprovalList(out io.Writer, ops []pendingOperationResponse) error {
+	if len(ops) == 0 {
+		fmt.Fprintln(out, "No operations.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tPERMISSION\tREQUEST\tREQUESTED BY\tAPPROVALS\tEXPIRES")
+	for _, op := range ops {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s %s\t%s\t%d/%d %s\t%s\n",
+			op.ID, op.Status, op.Permission, op.Method, op.Path, op.RequestedBy,
+			len(op.Approvals), op.RequiredApprovals, op.ApproverRole, op.ExpiresAt)
+	}
+	return w.Flush()
+}
+
+func runApprovalShow(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("approval show")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printApprovalShowUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("operation id is required"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/approvals/"+url.PathEscape(fs.Arg(0)), nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var op pendingOperationResponse
+	if err := json.Unmarshal(payload, &op); err != nil {
+		return err
+	}
+	writePendingOperation(os.Stdout, op)
+	return nil
+}
+
+func runApprovalDecide(ctx context.Context, action string, args []string, base commonFlags) error {
+	fs := newFlagSet("approval " + action)
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	usage := printApprovalApproveUsage
+	var reason string
+	if action == "reject" {
+		usage = printApprovalRejectUsage
+		fs.StringVar(&reason, "reason", "", "why the operation was rejected")
+	}
+	if err := parseFlags(fs, args, usage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("operation id is required"), true)
+	}
+	var body any
+	if reason = strings.TrimSpace(reason); reason != "" {
+		body = map[string]string{"reason": reason}
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/approvals/"+url.PathEscape(fs.Arg(0))+"/"+action, body)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var op pendingOperationResponse
+	if err := json.Unmarshal(payload, &op); err != nil {
+		return err
+	}
+	switch op.Status {
+	case "pending":
+		fmt.Printf("Operation %s approved (%d/%d)\n", op.ID, len(op.Approvals), op.RequiredApprovals)
+	case "executed":
+		fmt.Printf("Operation %s approved and executed (status %d)\n", op.ID, op.ResultStatus)
+	case "failed":
+		fmt.Printf("Operation %s approved but failed (status %d)\n", op.ID, op.ResultStatus)
+		writeOperationResult(os.Stdout, op)
+	default:
+		fmt.Printf("Operation %s %s\n", op.ID, op.Status)
+	}
+	return nil
+}
+
+func writePendingOperation(out io.Writer, op pendingOperationResponse) {
+	fmt.Fprintf(out, "ID:           %s\n", op.ID)
+	fmt.Fprintf(out, "Status:       %s\n", op.Status)
+	fmt.Fprintf(out, "Permission:   %s\n", op.Permission)
+	fmt.Fprintf(out, "Request:      %s %s\n", op.Method, op.Path)
+	fmt.Fprintf(out, "Requested by: %s\n", op.RequestedBy)
+	fmt.Fprintf(out, "Created:      %s\n", op.CreatedAt)
+	fmt.Fprintf(out, "Expires:      %s\n", op.ExpiresAt)
+	fmt.Fprintf(out, "Approvals:    %d/%d by %s\n", len(op.Approvals), op.RequiredApprovals, op.ApproverRole)
+	for _, a := range op.Approvals {
+		fmt.Fprintf(out, "  %s at %s\n", a.User, a.ApprovedAt)
+	}
+	if op.DecidedBy != "" {
+		fmt.Fprintf(out, "Decided by:   %s at %s\n", op.DecidedBy, op.DecidedAt)
+	}
+	if op.Reason != "" {
+		fmt.Fprintf(out, "Reason:       %s\n", op.Reason)
+	}
+	if len(op.Body) > 0 {
+		fmt.Fprintf(out, "Body:         %s\n", string(op.Body))
+	}
+	writeOperationResult(out, op)
+}
+
+func writeOperationResult(out io.Writer, op pendingOperationResponse) {
+	if op.ResultStatus == 0 {
+		return
+	}
+	fmt.Fprintf(out, "Result:       %d %s\n", op.ResultStatus, strings.TrimSpace(string(op.Result)))
+}
@@ -43,6 +43,8 @@ import (
 	"strings"
 	"text/tabwriter"
 	"time"
+
+	"github.com/agentlab/agentlab/internal/oidc"
 )
 
 const (
@@ -318,6 +320,8 @@ type connectOutput struct {
 	JumpUser     string             `json:"jump_user,omitempty"`
 	ConfigPath   string             `json:"config_path"`
 	TokenSet     bool               `json:"token_set"`
+	User         string             `json:"user,omitempty"`
+	TokenExpires string             `json:"token_expires_at,omitempty"`
 	Host         *hostResponse      `json:"host,omitempty"`
 	TailnetRoute *tailnetRouteCheck `json:"tailnet_route,omitempty"`
 }
@@ -328,9 +332,11 @@ func runConnectCommand(ctx context.Context, args []string, base commonFlags) err
 	opts.bind(fs)
 	var jumpHost string
 	var jumpUser string
+	var useOIDC bool
 	help := bindHelpFlag(fs)
 	fs.StringVar(&jumpHost, "jump-host", "", "default SSH jump host (used when subnet route is unavailable)")
 	fs.StringVar(&jumpUser, "jump-user", "", "default SSH jump username")
+	fs.BoolVar(&useOIDC, "oidc", false, "sign in through the control plane's identity provider instead of --token")
 	if err := parseFlags(fs, args, printConnectUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -355,6 +361,17 @@ func runConnectCommand(ctx context.Context, args []string, base commonFlags) err
 		return err
 	}
 	token := strings.TrimSpace(opts.token)
+	var login *oidcLoginResponse
+	if useOIDC {
+		if token != "" {
+			return newUsageError(errors.New("--oidc and --token are mutually exclusive"), true)
+		}
+		login, err = oidcDeviceLogin(ctx, newAPIClient(clientOptions{Endpoint: endpoint}, opts.timeout), os.Stderr)
+		if err != nil {
+			return err
+		}
+		token = login.Token
+	}
 	client := newAPIClient(clientOptions{Endpoint: endpoint, Token: token}, opts.timeout)
 
 	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/status", nil)
@@ -407,12 +424,19 @@ func runConnectCommand(ctx context.Context, args []string, base commonFlags) err
 			Host:         hostInfo,
 			TailnetRoute: &routeCheck,
 		}
+		if login != nil {
+			out.User = login.User
+			out.TokenExpires = login.ExpiresAt
+		}
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetEscapeHTML(false)
 		return enc.Encode(out)
 	}
 
 	fmt.Fprintf(os.Stdout, "Connected to %s\n", endpoint)
+	if login != nil {
+		fmt.Fprintf(os.Stdout, "Signed in as %s (token expires %s; run connect --oidc again to renew)\n", login.User, login.ExpiresAt)
+	}
 	fmt.Fprintf(os.Stdout, "Config written to %s\n", path)
 	if hostInfo != nil && strings.TrimSpace(hostInfo.TailscaleDNS) != "" {
 		fmt.Fprintf(os.Stdout, "Tailscale DNS: %s\n", strings.TrimSpace(hostInfo.TailscaleDNS))
@@ -421,6 +445,66 @@ func runConnectCommand(ctx context.Context, args []string, base commonFlags) err
 	return nil
 }
 
+// oidcLoginResponse is the daemon's answer to a single sign-on login.
+type oidcLoginResponse struct {
+	Token     string   `json:"token"`
+	ExpiresAt string   `json:"expires_at"`
+	User      string   `json:"user"`
+	Role      string   `json:"role"`
+	Teams     []string `json:"teams"`
+}
+
+// oidcDeviceLogin signs in through the control plane's identity provider with
+// the device authorization grant, which needs no browser on this machine:
+// the user opens the printed URL anywhere and enters the code. The resulting
+// ID token is exchanged for an AgentLab token.
+func oidcDeviceLogin(ctx context.Context, client *apiClient, out io.Writer) (*oidcLoginResponse, error) {
+	payload, err := client.doJSON(ctx, http.MethodGet, "/auth/oidc/config", nil)
+	if err != nil {
+		var apiErr apiResponseError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return nil, withHints(errors.New("single sign-on is not enabled on this control plane"), "connect with --token instead, or set oidc_issuer on the daemon")
+		}
+		return nil, err
+	}
+	var cfg struct {
+		Issuer   string   `json:"issuer"`
+		ClientID string   `json:"client_id"`
+		Scopes   []string `json:"scopes"`
+	}
+	if err := json.Unmarshal(payload, &cfg); err != nil {
+		return nil, fmt.Errorf("decode single sign-on config: %w", err)
+	}
+	provider, err := oidc.Discover(ctx, cfg.Issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	da, err := provider.StartDevice(ctx, cfg.ClientID, cfg.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "To sign in, open %s\n", da.VerificationURIComplete)
+		fmt.Fprintf(out, "and confirm the code %s\n", da.UserCode)
+	} else {
+		fmt.Fprintf(out, "To sign in, open %s and enter the code %s\n", da.VerificationURI, da.UserCode)
+	}
+	fmt.Fprintln(out, "Waiting for approval...")
+	tokens, err := provider.PollDevice(ctx, cfg.ClientID, da)
+	if err != nil {
+		return nil, fmt.Errorf("sign in: %w", err)
+	}
+	payload, err = client.doJSON(ctx, http.MethodPost, "/auth/oidc/token", map[string]string{"id_token": tokens.IDToken})
+	if err != nil {
+		return nil, fmt.Errorf("sign in: %w", err)
+	}
+	var login oidcLoginResponse
+	if err := json.Unmarshal(payload, &login); err != nil {
+		return nil, fmt.Errorf("decode sign-in response: %w", err)
+	}
+	return &login, nil
+}
+
 func runDisconnectCommand(ctx context.Context, args []string, base commonFlags) error {
 	_ = ctx
 	fs := newFlagSet("disconnect")
@@ -484,13 +568,17 @@ func runJobCommand(ctx context.Context, args []string, base commonFlags) error {
 		return runJobShow(ctx, args[1:], base)
 	case "artifacts":
 		return runJobArtifacts(ctx, args[1:], base)
+	case "diff":
+		return runJobDiff(ctx, args[1:], base)
 	case "doctor":
 		return runJobDoctor(ctx, args[1:], base)
+	case "group":
+		return runJobGroupCommand(ctx, args[1:], base)
 	default:
 		if !base.jsonOutput {
 			printJobUsage()
 		}
-		return unknownSubcommandError("job", args[0], []string{"run", "validate", "show", "artifacts", "doctor"})
+		return unknownSubcommandError("job", args[0], []string{"run", "validate", "show", "artifacts", "diff", "doctor", "group"})
 	}
 }
 
@@ -513,6 +601,17 @@ func runJobRun(ctx context.Context, args []string, base commonFlags) error {
 	var workspaceWait string
 	var stateful bool
 	var keepalive optionalBool
+	var matrix stringSliceFlag
+	var env stringSliceFlag
+	var groupName string
+	var dependsOn stringSliceFlag
+	var dependsCondition string
+	var parentArtifacts bool
+	var pushBranch string
+	var openPR bool
+	var prBase string
+	var prTitle string
+	var pushIntegration string
 	help := bindHelpFlag(fs)
 	fs.StringVar(&repo, "repo", "", "git repository url")
 	fs.StringVar(&ref, "ref", "", "git ref (default main)")
@@ -528,9 +627,74 @@ func runJobRun(ctx context.Context, args []string, base commonFlags) error {
 	fs.StringVar(&workspaceWait, "workspace-wait", "", "wait for workspace detach (e.g. 2m, 30s)")
 	fs.BoolVar(&stateful, "stateful", false, "create a default workspace for a stateful job")
 	fs.Var(&keepalive, "keepalive", "keep sandbox after job completion")
+	fs.Var(&matrix, "matrix", "matrix axis (ref=a,b | profile=a,b | task[:name]=<task> | env[:name]=K=V[,K=V]); repeatable")
+	fs.Var(&env, "env", "environment override KEY=VALUE; repeatable")
+	fs.StringVar(&groupName, "name", "", "job group name (with --matrix)")
+	fs.Var(&dependsOn, "depends-on", "parent job id (comma-separated or repeatable); the job waits until parents finish")
+	fs.StringVar(&dependsCondition, "depends-condition", "", "run when parents end in success (default), failure, or always")
+	fs.BoolVar(&parentArtifacts, "parent-artifacts", false, "download parent job artifacts into the sandbox")
+	fs.StringVar(&pushBranch, "push-branch", "", "push the job's commits to this branch on completion (default agent/<job_id> with --open-pr)")
+	fs.BoolVar(&openPR, "open-pr", false, "open a pull request from the pushed branch on completion")
+	fs.StringVar(&prBase, "pr-base", "", "pull request target branch (default --ref)")
+	fs.StringVar(&prTitle, "pr-title", "", "pull request title (default from the task)")
+	fs.StringVar(&pushIntegration, "push-integration", "", "git-proxy integration to push with (default: matched by repo url)")
 	if err := parseFlags(fs, args, printJobRunUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
+	envOverrides, err := parseEnvAssignments(env.values)
+	if err != nil {
+		return err
+	}
+	parents := splitCommaValues(dependsOn.values)
+	dependsCondition = strings.TrimSpace(dependsCondition)
+	if len(parents) == 0 && (dependsCondition != "" || parentArtifacts) {
+		return fmt.Errorf("--depends-condition and --parent-artifacts require --depends-on")
+	}
+	resultOpts, err := parseJobResultFlags(pushBranch, openPR, prBase, prTitle, pushIntegration)
+	if err != nil {
+		return err
+	}
+	if matrix.set {
+		if repo == "" {
+			if !opts.jsonOutput {
+				printJobRunUsage()
+			}
+			return fmt.Errorf("repo is required")
+		}
+		if strings.TrimSpace(branch) != "" || strings.TrimSpace(workspace) != "" || strings.TrimSpace(workspaceCreate) != "" || strings.TrimSpace(workspaceWait) != "" || stateful {
+			return fmt.Errorf("--matrix cannot be combined with --branch, --workspace, --workspace-create, --workspace-wait, or --stateful")
+		}
+		ttlMinutes, err := parseTTLMinutes(ttl)
+		if err != nil {
+			return err
+		}
+		axes, err := parseJobMatrixFlags(matrix.values)
+		if err != nil {
+			return err
+		}
+		req := jobGroupCreateRequest{
+			Name: strings.TrimSpace(groupName),
+			Base: jobCreateRequest{
+				RepoURL:          repo,
+				Ref:              ref,
+				Profile:          profile,
+				Task:             task,
+				Mode:             mode,
+				TTLMinutes:       ttlMinutes,
+				Keepalive:        keepalive.Ptr(),
+				Env:              envOverrides,
+				DependsOn:        parents,
+				DependsCondition: dependsCondition,
+				ParentArtifacts:  parentArtifacts,
+				Result:           resultOpts,
+			},
+			Matrix: axes,
+		}
+		return runJobGroupCreate(ctx, opts, req)
+	}
+	if strings.TrimSpace(groupName) != "" {
+		return fmt.Errorf("--name requires --matrix")
+	}
 	if repo == "" || profile == "" || task == "" {
 		if !opts.jsonOutput {
 			printJobRunUsage()
@@ -645,6 +809,11 @@ func runJobRun(ctx context.Context, args []string, base commonFlags) error {
 		WorkspaceCreate:      workspaceCreateReq,
 		WorkspaceWaitSeconds: workspaceWaitSecs,
 		SessionID:            sessionID,
+		Env:                  envOverrides,
+		DependsOn:            parents,
+		DependsCondition:     dependsCondition,
+		ParentArtifacts:      parentArtifacts,
+		Result:               resultOpts,
 	}
 	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/jobs", req)
 	if err != nil {
@@ -664,6 +833,42 @@ func runJobRun(ctx context.Context, args []string, base commonFlags) error {
 	return nil
 }
 
+// parseJobResultFlags builds the result options for job run. It returns nil
+// when no publishing flag is set.
+func parseJobResultFlags(pushBranch string, openPR bool, prBase, prTitle, integration string) (*jobResultOptions, error) {
+	opts := jobResultOptions{
+		PushBranch:  strings.TrimSpace(pushBranch),
+		OpenPR:      openPR,
+		PRBase:      strings.TrimSpace(prBase),
+		PRTitle:     strings.TrimSpace(prTitle),
+		Integration: strings.TrimSpace(integration),
+	}
+	if !opts.OpenPR && (opts.PRBase != "" || opts.PRTitle != "") {
+		return nil, fmt.Errorf("--pr-base and --pr-title require --open-pr")
+	}
+	if opts.PushBranch == "" && !opts.OpenPR {
+		if opts.Integration != "" {
+			return nil, fmt.Errorf("--push-integration requires --push-branch or --open-pr")
+		}
+		return nil, nil
+	}
+	return &opts, nil
+}
+
+// splitCommaValues flattens repeated, comma-separated flag values, dropping
+// blanks.
+func splitCommaValues(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
 func runJobValidate(ctx context.Context, args []string, base commonFlags) error {
 	fs := newFlagSet("job validate")
 	opts := base
@@ -1128,7 +1333,7 @@ func runSandboxCommand(ctx context.Context, args []string, base commonFlags) err
 		return runSandboxNew(ctx, args[1:], base)
 	case "validate":
 		return runSandboxValidate(ctx, args[1:], base)
-	case "list":
+	case "list", "ls":
 		return runSandboxList(ctx, args[1:], base)
 	case "inventory":
 		return runSandboxInventory(ctx, args[1:], base)
@@ -1164,11 +1369,13 @@ func runSandboxCommand(ctx context.Context, args []string, base commonFlags) err
 		return runSandboxUnexpose(ctx, args[1:], base)
 	case "doctor":
 		return runSandboxDoctor(ctx, args[1:], base)
+	case "recordings":
+		return runSandboxRecordingsCommand(ctx, args[1:], base)
 	default:
 		if !base.jsonOutput {
 			printSandboxUsage()
 		}
-		return unknownSubcommandError("sandbox", args[0], []string{"new", "validate", "list", "inventory", "reconcile", "show", "update", "start", "stop", "pause", "resume", "revert", "snapshot", "destroy", "lease", "prune", "expose", "exposed", "unexpose", "doctor"})
+		return unknownSubcommandError("sandbox", args[0], []string{"new", "validate", "list", "inventory", "reconcile", "show", "update", "start", "stop", "pause", "resume", "revert", "snapshot", "destroy", "lease", "prune", "expose", "exposed", "unexpose", "doctor", "recordings"})
 	}
 }
 
@@ -1268,11 +1475,13 @@ func runProfileCommand(ctx context.Context, args []string, base commonFlags) err
 	switch args[0] {
 	case "list":
 		return runProfileList(ctx, args[1:], base)
+	case "upgrade":
+		return runProfileUpgrade(ctx, args[1:], base)
 	default:
 		if !base.jsonOutput {
 			printProfileUsage()
 		}
-		return unknownSubcommandError("profile", args[0], []string{"list"})
+		return unknownSubcommandError("profile", args[0], []string{"list", "upgrade"})
 	}
 }
 
@@ -1294,11 +1503,15 @@ func runMsgCommand(ctx context.Context, args []string, base commonFlags) error {
 		return runMsgPost(ctx, args[1:], base)
 	case "tail":
 		return runMsgTail(ctx, args[1:], base)
+	case "inbox":
+		return runMsgInbox(ctx, args[1:], base)
+	case "reply":
+		return runMsgReply(ctx, args[1:], base)
 	default:
 		if !base.jsonOutput {
 			printMsgUsage()
 		}
-		return unknownSubcommandError("msg", args[0], []string{"post", "tail"})
+		return unknownSubcommandError("msg", args[0], []string{"post", "tail", "inbox", "reply"})
 	}
 }
 
@@ -1345,6 +1558,7 @@ func runSandboxNew(ctx context.Context, args []string, base commonFlags) error {
 	var image string
 	var prompt string
 	var tags stringSliceFlag
+	var team string
 	help := bindHelpFlag(fs)
 	fs.StringVar(&name, "name", "", "sandbox name")
 	fs.StringVar(&profile, "profile", "", "profile name")
@@ -1358,6 +1572,7 @@ func runSandboxNew(ctx context.Context, args []string, base commonFlags) error {
 	fs.StringVar(&image, "image", "", "container image for LXC sandboxes (e.g., ubuntu:22.04)")
 	fs.StringVar(&prompt, "prompt", "", "initial agent prompt for agent-ready sandboxes")
 	fs.Var(&tags, "tag", "integration-attachment tag (repeatable; normalized to lowercase)")
+	fs.StringVar(&team, "team", "", "team that co-owns the sandbox")
 	if err := parseFlags(fs, args, printSandboxNewUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -1436,6 +1651,7 @@ func runSandboxNew(ctx context.Context, args []string, base commonFlags) error {
 		Image:      image,
 		Prompt:     prompt,
 		Tags:       tags.values,
+		Team:       strings.TrimSpace(team),
 	}
 	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/sandboxes", req)
 	if err != nil {
@@ -1466,6 +1682,7 @@ func runSandboxValidate(ctx context.Context, args []string, base commonFlags) er
 	var vmid int
 	var jobID string
 	var keepalive optionalBool
+	var tags stringSliceFlag
 	help := bindHelpFlag(fs)
 	fs.StringVar(&name, "name", "", "sandbox name")
 	fs.StringVar(&profile, "profile", "", "profile name")
@@ -1474,6 +1691,7 @@ func runSandboxValidate(ctx context.Context, args []string, base commonFlags) er
 	fs.IntVar(&vmid, "vmid", 0, "vmid override")
 	fs.StringVar(&jobID, "job", "", "attach to existing job id")
 	fs.Var(&keepalive, "keepalive", "enable keepalive lease for sandbox")
+	fs.Var(&tags, "tag", "integration-attachment tag (repeatable; normalized to lowercase)")
 	if err := parseFlags(fs, args, printSandboxValidateUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -1535,6 +1753,7 @@ func runSandboxValidate(ctx context.Context, args []string, base commonFlags) er
 		Workspace:  workspaceID,
 		VMID:       vmidPtr,
 		JobID:      jobID,
+		Tags:       tags.values,
 	}
 	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/sandboxes/validate-plan", req)
 	if err != nil {
@@ -1572,7 +1791,9 @@ func runSandboxList(ctx context.Context, args []string, base commonFlags) error
 	fs := newFlagSet("sandbox list")
 	opts := base
 	opts.bind(fs)
+	var team string
 	help := bindHelpFlag(fs)
+	fs.StringVar(&team, "team", "", "only list sandboxes owned by team")
 	if err := parseFlags(fs, args, printSandboxListUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -1581,7 +1802,11 @@ func runSandboxList(ctx context.Context, args []string, base commonFlags) error
 	if err != nil {
 		return err
 	}
-	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/sandboxes", nil)
+	path := "/v1/sandboxes"
+	if team = strings.TrimSpace(team); team != "" {
+		path += "?team=" + url.QueryEscape(team)
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return err
 	}
@@ -2567,10 +2792,12 @@ func runWorkspaceCreate(ctx context.Context, args []string, base commonFlags) er
 	var name string
 	var size string
 	var storage string
+	var team string
 	help := bindHelpFlag(fs)
 	fs.StringVar(&name, "name", "", "workspace name")
 	fs.StringVar(&size, "size", "", "workspace size (e.g. 80G)")
 	fs.StringVar(&storage, "storage", "", "Proxmox storage (default local-zfs)")
+	fs.StringVar(&team, "team", "", "team that co-owns the workspace")
 	if err := parseFlags(fs, args, printWorkspaceCreateUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -2596,6 +2823,7 @@ func runWorkspaceCreate(ctx context.Context, args []string, base commonFlags) er
 		Name:    name,
 		SizeGB:  sizeGB,
 		Storage: storage,
+		Team:    strings.TrimSpace(team),
 	}
 	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/workspaces", req)
 	if err != nil {
@@ -2616,7 +2844,9 @@ func runWorkspaceList(ctx context.Context, args []string, base commonFlags) erro
 	fs := newFlagSet("workspace list")
 	opts := base
 	opts.bind(fs)
+	var team string
 	help := bindHelpFlag(fs)
+	fs.StringVar(&team, "team", "", "only list workspaces owned by team")
 	if err := parseFlags(fs, args, printWorkspaceListUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -2624,7 +2854,11 @@ func runWorkspaceList(ctx context.Context, args []string, base commonFlags) erro
 	if err != nil {
 		return err
 	}
-	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/workspaces", nil)
+	path := "/v1/workspaces"
+	if team = strings.TrimSpace(team); team != "" {
+		path += "?team=" + url.QueryEscape(team)
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return err
 	}
@@ -3174,6 +3408,7 @@ func runSessionCreate(ctx context.Context, args []string, base commonFlags) erro
 	var workspaceCreate string
 	var workspaceSize string
 	var workspaceStorage string
+	var team string
 	help := bindHelpFlag(fs)
 	fs.StringVar(&name, "name", "", "session name")
 	fs.StringVar(&profile, "profile", "", "profile name")
@@ -3182,6 +3417,7 @@ func runSessionCreate(ctx context.Context, args []string, base commonFlags) erro
 	fs.StringVar(&workspaceCreate, "workspace-create", "", "create workspace with name")
 	fs.StringVar(&workspaceSize, "workspace-size", "", "workspace size for creation (e.g. 80G)")
 	fs.StringVar(&workspaceStorage, "workspace-storage", "", "workspace storage (default local-zfs)")
+	fs.StringVar(&team, "team", "", "team that co-owns the session and any workspace it creates")
 	if err := parseFlags(fs, args, printSessionCreateUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -3224,6 +3460,7 @@ func runSessionCreate(ctx context.Context, args []string, base commonFlags) erro
 		WorkspaceID:     workspaceID,
 		WorkspaceCreate: workspaceCreateReq,
 		Branch:          branch,
+		Team:            strings.TrimSpace(team),
 	}
 	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/sessions", req)
 	if err != nil {
@@ -3245,7 +3482,9 @@ func runSessionList(ctx context.Context, args []string, base commonFlags) error
 	fs := newFlagSet("session list")
 	opts := base
 	opts.bind(fs)
+	var team string
 	help := bindHelpFlag(fs)
+	fs.StringVar(&team, "team", "", "only list sessions owned by team")
 	if err := parseFlags(fs, args, printSessionListUsage, help, opts.jsonOutput); err != nil {
 		return err
 	}
@@ -3253,7 +3492,11 @@ func runSessionList(ctx context.Context, args []string, base commonFlags) error
 	if err != nil {
 		return err
 	}
-	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/sessions", nil)
+	path := "/v1/sessions"
+	if team = strings.TrimSpace(team); team != "" {
+		path += "?team=" + url.QueryEscape(team)
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return err
 	}
@@ -3944,6 +4187,12 @@ func printSandbox(sb sandboxResponse) {
 	fmt.Printf("State: %s\n", sb.State)
 	fmt.Printf("IP: %s\n", orDash(sb.IP))
 	fmt.Printf("Workspace: %s\n", orDashPtr(sb.WorkspaceID))
+	if sb.Owner != "" {
+		fmt.Printf("Owner: %s\n", sb.Owner)
+	}
+	if sb.Team != "" {
+		fmt.Printf("Team: %s\n", sb.Team)
+	}
 	mode := "-"
 	firewall := "-"
 	firewallGroup := "-"
@@ -3972,6 +4221,12 @@ func printSandbox(sb sandboxResponse) {
 	fmt.Printf("Last Used At: %s\n", orDashPtr(sb.LastUsedAt))
 	fmt.Printf("Created At: %s\n", sb.CreatedAt)
 	fmt.Printf("Updated At: %s\n", sb.LastUpdatedAt)
+	if len(sb.KV) > 0 {
+		fmt.Println("Status:")
+		for _, kv := range sb.KV {
+			fmt.Printf("  %s: %s\n", kv.Key, kv.Value)
+		}
+	}
 }
 
 func printStatus(resp statusResponse) {
@@ -4214,6 +4469,12 @@ func printWorkspace(ws workspaceResponse) {
 	fmt.Printf("Volume ID: %s\n", ws.VolumeID)
 	fmt.Printf("Size GB: %d\n", ws.SizeGB)
 	fmt.Printf("Attached VMID: %s\n", vmidString(ws.AttachedVMID))
+	if ws.Owner != "" {
+		fmt.Printf("Owner: %s\n", ws.Owner)
+	}
+	if ws.Team != "" {
+		fmt.Printf("Team: %s\n", ws.Team)
+	}
 	fmt.Printf("Created At: %s\n", ws.CreatedAt)
 	fmt.Printf("Updated At: %s\n", ws.UpdatedAt)
 }
@@ -4250,6 +4511,12 @@ func printSession(session sessionResponse) {
 	fmt.Printf("Current VMID: %s\n", vmidString(session.CurrentVMID))
 	fmt.Printf("Profile: %s\n", session.Profile)
 	fmt.Printf("Branch: %s\n", orDash(session.Branch))
+	if session.Owner != "" {
+		fmt.Printf("Owner: %s\n", session.Owner)
+	}
+	if session.Team != "" {
+		fmt.Printf("Team: %s\n", session.Team)
+	}
 	fmt.Printf("Created At: %s\n", orDash(session.CreatedAt))
 	fmt.Printf("Updated At: %s\n", orDash(session.UpdatedAt))
 }
@@ -4353,7 +4620,16 @@ func printProfileList(profiles []profileResponse) {
 	w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
 	fmt.Fprintln(w, "NAME\tTEMPLATE\tUPDATED")
 	for _, profile := range profiles {
-		fmt.Fprintf(w, "%s\t%d\t%s\n", orDash(profile.Name), profile.TemplateVMID, orDash(profile.UpdatedAt))
+		template := strconv.Itoa(profile.TemplateVMID)
+		if profile.Template != "" {
+			// Built templates show the reference and the VMID it resolves to.
+			resolved := "unresolved"
+			if profile.TemplateVMID > 0 {
+				resolved = template
+			}
+			template = fmt.Sprintf("%s (%s)", profile.Template, resolved)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", orDash(profile.Name), template, orDash(profile.UpdatedAt))
 	}
 	_ = w.Flush()
 }
@@ -4379,6 +4655,15 @@ func printJob(job jobResponse) {
 	fmt.Printf("Task: %s\n", job.Task)
 	fmt.Printf("Mode: %s\n", job.Mode)
 	fmt.Printf("Status: %s\n", job.Status)
+	if len(job.DependsOn) > 0 {
+		fmt.Printf("Depends On: %s (%s)\n", strings.Join(job.DependsOn, ", "), orDash(job.DependsCondition))
+		if job.ParentArtifacts {
+			fmt.Println("Parent Artifacts: true")
+		}
+	}
+	if job.ResultOptions != nil {
+		printJobPublish(*job.ResultOptions, job.Result)
+	}
 	fmt.Printf("Keepalive: %t\n", job.Keepalive)
 	fmt.Printf("TTL Minutes: %s\n", ttlMinutesString(job.TTLMinutes))
 	fmt.Printf("Sandbox VMID: %s\n", vmidString(job.SandboxVMID))
@@ -4386,6 +4671,31 @@ func printJob(job jobResponse) {
 	fmt.Printf("Updated At: %s\n", job.UpdatedAt)
 }
 
+// printJobPublish shows the push target and, once the job completed, the
+// pushed commit and pull request.
+func printJobPublish(opts jobResultOptions, result json.RawMessage) {
+	fmt.Printf("Push Branch: %s\n", opts.PushBranch)
+	var parsed struct {
+		Publish *jobPublishResult `json:"publish"`
+	}
+	if len(result) > 0 {
+		_ = json.Unmarshal(result, &parsed)
+	}
+	switch {
+	case parsed.Publish == nil:
+		if opts.OpenPR {
+			fmt.Printf("Pull Request: pending (into %s)\n", orDash(opts.PRBase))
+		}
+	case parsed.Publish.Error != "":
+		fmt.Printf("Publish Error: %s\n", parsed.Publish.Error)
+	default:
+		fmt.Printf("Pushed Commit: %s\n", orDash(parsed.Publish.Commit))
+		if parsed.Publish.PRURL != "" {
+			fmt.Printf("Pull Request: %s\n", parsed.Publish.PRURL)
+		}
+	}
+}
+
 func printArtifactsList(artifacts []artifactInfo) {
 	w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
 	fmt.Fprintln(w, "NAME\tPATH\tSIZE(B)\tMIME\tCREATED\tSHA256")
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessGrantCommandSendsResourceAndLevel(t *testing.T) {
+	var got map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/access", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("method = %s, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		writeJSON(t, w, http.StatusCreated, accessGrant{ID: 4, Resource: got["resource"], User: got["user"], Level: got["level"]})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		args := []string{"grant", "--user", "bob", "--level", "operate", "1001"}
+		if err := runAccessCommand(context.Background(), args, base); err != nil {
+			t.Fatalf("access grant error = %v", err)
+		}
+	})
+	if got["resource"] != "sandbox:1001" || got["user"] != "bob" || got["level"] != "operate" {
+		t.Fatalf("unexpected request: %v", got)
+	}
+	if !strings.Contains(out, "grant 4") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestAccessGrantCommandValidatesArguments(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"grant", "--level", "read", "sandbox:1001"}, "--user"},
+		{[]string{"grant", "--user", "bob", "--level", "owner", "sandbox:1001"}, "--level"},
+		{[]string{"grant", "--user", "bob", "--level", "read", "job:1"}, "resource must be"},
+	} {
+		err := runAccessCommand(context.Background(), tc.args, commonFlags{timeout: time.Second})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%v: expected %q error, got %v", tc.args, tc.want, err)
+		}
+	}
+}
+
+func TestAccessListAndTeamCommands(t *testing.T) {
+	var teamReq map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/access", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("resource"); got != "workspace:shared" {
+			t.Fatalf("resource = %q, want workspace:shared", got)
+		}
+		writeJSON(t, w, http.StatusOK, accessListResponse{
+			Resource: "workspace:ws-1",
+			Owner:    "alice",
+			Team:     "infra",
+			Grants:   []accessGrant{{ID: 2, Resource: "workspace:ws-1", User: "bob", Level: "read", GrantedBy: "alice"}},
+		})
+	})
+	mux.HandleFunc("/v1/access/team", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&teamReq); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		writeJSON(t, w, http.StatusOK, accessListResponse{Resource: "session:sess-1", Owner: "alice", Team: teamReq["team"]})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runAccessCommand(context.Background(), []string{"ls", "workspace:shared"}, base); err != nil {
+			t.Fatalf("access ls error = %v", err)
+		}
+	})
+	for _, want := range []string{"Owner: alice", "Team: infra", "bob", "read"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() {
+		if err := runAccessCommand(context.Background(), []string{"team", "session:sess-1", "-"}, base); err != nil {
+			t.Fatalf("access team error = %v", err)
+		}
+	})
+	if teamReq["resource"] != "session:sess-1" || teamReq["team"] != "" {
+		t.Fatalf("unexpected request: %v", teamReq)
+	}
+	if !strings.Contains(out, "no longer belongs to a team") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminReloadCommand(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(t, w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, configReloadResponse{
+			Trigger:         "api",
+			Profiles:        2,
+			ProfilesAdded:   []string{"gpu"},
+			ConfigChanged:   []string{"provisioning_timeout"},
+			RestartRequired: []string{"metrics_listen"},
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"reload"}, base); err != nil {
+			t.Fatalf("admin reload error = %v", err)
+		}
+	})
+	for _, want := range []string{"Reloaded 2 profiles", "Profiles added: gpu", "Config applied: provisioning_timeout", "Restart required: metrics_listen"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Profiles removed") {
+		t.Fatalf("empty sections should be omitted:\n%s", out)
+	}
+}
+
+func TestAdminBackupAndRestoreCommands(t *testing.T) {
+	var restoreBody map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/admin/backups", func(w http.ResponseWriter, r *http.Request) {
+		created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		switch r.Method {
+		case http.MethodPost:
+			writeJSON(t, w, http.StatusCreated, backupResponse{
+				backupInfo: backupInfo{
+					Name:      "agentlab-20240501T120000Z.db.age",
+					Path:      "/var/lib/agentlab/backups/agentlab-20240501T120000Z.db.age",
+					SizeBytes: 4096,
+					Encrypted: true,
+					CreatedAt: created,
+				},
+				Trigger:       "api",
+				SchemaVersion: 23,
+				Pruned:        []string{"agentlab-20240424T120000Z.db.age"},
+			})
+		case http.MethodGet:
+			writeJSON(t, w, http.StatusOK, backupsResponse{Backups: []backupInfo{{
+				Name:      "agentlab-20240501T120000Z.db.age",
+				SizeBytes: 4096,
+				Encrypted: true,
+				CreatedAt: created,
+			}}})
+		default:
+			writeJSON(t, w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+	mux.HandleFunc("/v1/admin/restore", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&restoreBody); err != nil {
+			t.Fatalf("decode restore body: %v", err)
+		}
+		writeJSON(t, w, http.StatusOK, restoreResponse{
+			Source:          "/var/lib/agentlab/backups/" + restoreBody["backup"],
+			StagedPath:      "/var/lib/agentlab/agentlab.db.restore",
+			SchemaVersion:   23,
+			RestartRequired: true,
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"backup"}, base); err != nil {
+			t.Fatalf("admin backup error = %v", err)
+		}
+	})
+	for _, want := range []string{"Backup written to /var/lib/agentlab/backups/agentlab-20240501T120000Z.db.age", "schema v23, age-encrypted", "Pruned 1 old backup: agentlab-20240424T120000Z.db.age"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected backup output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"backup", "--list"}, base); err != nil {
+			t.Fatalf("admin backup --list error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "NAME") || !strings.Contains(out, "agentlab-20240501T120000Z.db.age") || !strings.Contains(out, "2024-05-01T12:00:00Z") {
+		t.Fatalf("unexpected backup list output:\n%s", out)
+	}
+
+	if err := runAdminCommand(context.Background(), []string{"restore", "--json"}, base); err == nil {
+		t.Fatalf("expected restore without a backup to fail")
+	}
+	out = captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"restore", "agentlab-20240501T120000Z.db.age"}, base); err != nil {
+			t.Fatalf("admin restore error = %v", err)
+		}
+	})
+	if restoreBody["backup"] != "agentlab-20240501T120000Z.db.age" {
+		t.Fatalf("restore body = %v", restoreBody)
+	}
+	for _, want := range []string{"staged it at /var/lib/agentlab/agentlab.db.restore", "Restart agentlabd"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected restore output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestAdminEventsExportCommand(t *testing.T) {
+	var gotSince string
+	fail := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/admin/events/export", func(w http.ResponseWriter, r *http.Request) {
+		gotSince = r.URL.Query().Get("since")
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = w.Write([]byte(`{"type":"event","ts":"2024-05-01T12:00:00Z","event":{"id":1,"kind":"job.created"}}` + "\n"))
+		_, _ = w.Write([]byte(`{"type":"audit","ts":"2024-05-02T12:00:00Z","audit":{"id":7,"action":"user.add"}}` + "\n"))
+		if fail {
+			_, _ = w.Write([]byte(`{"type":"error","error":"read archive: unexpected EOF"}` + "\n"))
+		}
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"events", "export", "--since", "2024-05-01T00:00:00Z"}, base); err != nil {
+			t.Fatalf("admin events export error = %v", err)
+		}
+	})
+	if gotSince != "2024-05-01T00:00:00Z" {
+		t.Fatalf("since = %q", gotSince)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"user.add"`) {
+		t.Fatalf("unexpected export output:\n%s", out)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "export.jsonl")
+	out = captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"events", "export", "--since", "720h", "--output", outPath}, base); err != nil {
+			t.Fatalf("admin events export --output error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Exported 2 records to "+outPath) {
+		t.Fatalf("unexpected export summary:\n%s", out)
+	}
+	since, err := time.Parse(time.RFC3339, gotSince)
+	if err != nil || time.Since(since) < 719*time.Hour {
+		t.Fatalf("duration since = %q (%v)", gotSince, err)
+	}
+	info, err := os.Stat(outPath)
+	if err != nil {
+		t.Fatalf("stat export: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("export mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := runAdminCommand(context.Background(), []string{"events", "export", "--since", "last week"}, base); err == nil {
+		t.Fatalf("expected invalid --since to fail")
+	}
+
+	fail = true
+	failedPath := filepath.Join(t.TempDir(), "failed.jsonl")
+	err = runAdminCommand(context.Background(), []string{"events", "export", "--output", failedPath}, base)
+	if err == nil || !strings.Contains(err.Error(), "unexpected EOF") {
+		t.Fatalf("expected trailing error record to fail the export, got %v", err)
+	}
+	if _, err := os.Stat(failedPath); !os.IsNotExist(err) {
+		t.Fatalf("failed export must not leave %s behind", failedPath)
+	}
+}
+
+func TestAdminRotateKeyCommand(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/admin/rotate-key", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(t, w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		calls++
+		writeJSON(t, w, http.StatusOK, rotateKeyResponse{Version: 3, Reencrypted: 4, Retired: []int{1, 2}})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runAdminCommand(context.Background(), []string{"rotate-key"}, base); err != nil {
+			t.Fatalf("admin rotate-key error = %v", err)
+		}
+	})
+	for _, want := range []string{"Rotated integration key to version 3 (4 secrets re-encrypted)", "Retired key versions: 1, 2"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if err := runAdminCommand(context.Background(), []string{"rotate-key", "extra"}, base); err == nil {
+		t.Fatalf("expected extra argument to fail")
+	}
+	if calls != 1 {
+		t.Fatalf("rotate-key calls = %d, want 1", calls)
+	}
+}
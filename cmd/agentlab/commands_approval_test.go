@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeldOperationIsReportedAsAwaitingApproval(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/sandboxes/prune", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(pendingOperationHeader, "op_1")
+		writeJSON(t, w, http.StatusAccepted, pendingOperationResponse{
+			ID:                "op_1",
+			Status:            "pending",
+			ApproverRole:      "admin",
+			RequiredApprovals: 2,
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	client := newAPIClient(clientOptions{SocketPath: socketPath}, time.Second)
+
+	_, err := client.doJSON(context.Background(), http.MethodPost, "/v1/sandboxes/prune", nil)
+	var apiErr apiResponseError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected api error, got %v", err)
+	}
+	if apiErr.Status != http.StatusAccepted || apiErr.Code != apiErrorCodeApprovalRequired {
+		t.Fatalf("unexpected error: %+v", apiErr)
+	}
+	msg, _, hints := describeError(err)
+	if msg != "operation op_1 is awaiting approval by 2 admin user(s)" {
+		t.Fatalf("message = %q", msg)
+	}
+	if len(hints) != 1 || hints[0] != "agentlab approval show op_1" {
+		t.Fatalf("hints = %v", hints)
+	}
+}
+
+func TestApprovalApproveCommandReportsProgress(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/approvals/op_1/approve", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("method = %s, want POST", r.Method)
+		}
+		writeJSON(t, w, http.StatusOK, pendingOperationResponse{
+			ID:                "op_1",
+			Status:            "pending",
+			RequiredApprovals: 2,
+			Approvals:         []operationApprovalResponse{{User: "alice"}},
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runApprovalCommand(context.Background(), []string{"approve", "op_1"}, base); err != nil {
+			t.Fatalf("approval approve error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "approved (1/2)") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestApprovalListCommandFiltersByStatus(t *testing.T) {
+	var status string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/approvals", func(w http.ResponseWriter, r *http.Request) {
+		status = r.URL.Query().Get("status")
+		writeJSON(t, w, http.StatusOK, pendingOperationsResponse{Operations: []pendingOperationResponse{{
+			ID:                "op_1",
+			Status:            "rejected",
+			Permission:        "workspace.rebind",
+			Method:            "POST",
+			Path:              "/v1/workspaces/ws-1/rebind",
+			RequestedBy:       "bob",
+			ApproverRole:      "admin",
+			RequiredApprovals: 1,
+		}}})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runApprovalCommand(context.Background(), []string{"ls", "--status", "all"}, base); err != nil {
+			t.Fatalf("approval ls error = %v", err)
+		}
+	})
+	if status != "all" {
+		t.Fatalf("status = %q, want all", status)
+	}
+	for _, want := range []string{"op_1", "rejected", "POST /v1/workspaces/ws-1/rebind", "0/1 admin"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/oidc/oidctest"
 )
 
 func TestConnectWritesConfigAndOverwrites(t *testing.T) {
@@ -56,6 +58,55 @@ func TestConnectWritesConfigAndOverwrites(t *testing.T) {
 	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
 }
 
+func TestConnectOIDCDeviceLogin(t *testing.T) {
+	useTempClientConfig(t)
+	provider := oidctest.New(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/oidc/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]any{"issuer": provider.URL, "client_id": oidctest.ClientID})
+	})
+	mux.HandleFunc("/auth/oidc/token", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req["id_token"] == "" {
+			writeJSON(t, w, http.StatusBadRequest, map[string]string{"error": "id_token is required"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, oidcLoginResponse{Token: "agentlab-user-token", ExpiresAt: "2030-01-01T00:00:00Z", User: "alice", Role: "user"})
+	})
+	mux.HandleFunc("/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer agentlab-user-token" {
+			writeJSON(t, w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		writeJSON(t, w, http.StatusOK, statusResponse{Sandboxes: map[string]int{}, Jobs: map[string]int{}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	base := commonFlags{jsonOutput: true, timeout: 5 * time.Second}
+	err := runConnectCommand(context.Background(), []string{"--endpoint", server.URL, "--oidc", "--token", "x"}, base)
+	require.Error(t, err, "--oidc and --token are mutually exclusive")
+
+	out := captureStdout(t, func() {
+		err = runConnectCommand(context.Background(), []string{"--endpoint", server.URL, "--oidc"}, base)
+	})
+	require.NoError(t, err)
+	var result connectOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, "alice", result.User)
+	assert.True(t, result.TokenSet)
+
+	path, err := clientConfigPath()
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var cfg clientConfig
+	require.NoError(t, json.Unmarshal(data, &cfg))
+	assert.Equal(t, "agentlab-user-token", cfg.Token)
+}
+
 func TestConnectRejectsEndpointWithPath(t *testing.T) {
 	useTempClientConfig(t)
 
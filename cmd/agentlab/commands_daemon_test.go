@@ -219,6 +219,10 @@ func TestCLIReadOnly_WithData(t *testing.T) {
 	err = store.RecordEvent(ctx, "sandbox.started", &sandbox.VMID, &job.ID, "sandbox started", "")
 	require.NoError(t, err)
 
+	// Publish a guest status value
+	err = store.PutSandboxKV(ctx, db.SandboxKV{VMID: sandbox.VMID, Key: "tests", Value: "passing", JobID: job.ID}, 0)
+	require.NoError(t, err)
+
 	t.Run("sandbox list shows data", func(t *testing.T) {
 		out := captureStdout(t, func() {
 			err := runSandboxList(ctx, nil, base)
@@ -245,6 +249,7 @@ func TestCLIReadOnly_WithData(t *testing.T) {
 		assert.Contains(t, out, "IP: 10.77.0.10")
 		assert.Contains(t, out, "Network Mode: nat")
 		assert.Contains(t, out, "Firewall Group: agent_nat_default")
+		assert.Contains(t, out, "Status:\n  tests: passing")
 	})
 
 	t.Run("job show shows details", func(t *testing.T) {
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDiffPatch = "diff --git a/f.txt b/f.txt\n" +
+	"index 422c2b7..6372083 100644\n" +
+	"--- a/f.txt\n" +
+	"+++ b/f.txt\n" +
+	"@@ -1,2 +1,2 @@\n" +
+	" a\n" +
+	"-b\n" +
+	"+c\n"
+
+func TestJobDiffCommand(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/jobs/job_1/diff", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "patch" {
+			w.Header().Set("Content-Type", "text/x-diff")
+			_, _ = w.Write([]byte(testDiffPatch))
+			return
+		}
+		writeJSON(t, w, http.StatusOK, jobDiffResponse{
+			JobID:        "job_1",
+			Ref:          "main",
+			Base:         "0123456789abcdef0123",
+			Files:        []diffFileStat{{Path: "f.txt", Status: "modified", Additions: 1, Deletions: 1}},
+			FilesChanged: 1,
+			Additions:    1,
+			Deletions:    1,
+			Patch:        testDiffPatch,
+		})
+	})
+	mux.HandleFunc("/v1/jobs/job_none/diff", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusNotFound, map[string]string{"error": "job has no patch artifact"})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+	run := func(args ...string) string {
+		t.Helper()
+		return captureStdout(t, func() {
+			if err := runJobDiff(context.Background(), args, base); err != nil {
+				t.Fatalf("runJobDiff(%v) error = %v", args, err)
+			}
+		})
+	}
+
+	out := run("--stat", "job_1")
+	for _, want := range []string{"Job job_1 against main (0123456789ab)", "modified f.txt | +1 -1", "1 file changed, 1 insertion(+), 1 deletion(-)"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected stat output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "@@") {
+		t.Fatalf("--stat should not print the patch:\n%s", out)
+	}
+
+	out = run("--color", "never", "job_1")
+	if !strings.HasSuffix(out, testDiffPatch) || strings.Contains(out, "\x1b[") {
+		t.Fatalf("expected uncolored patch, got:\n%q", out)
+	}
+
+	out = run("--color", "always", "job_1")
+	for _, want := range []string{ansiGreen + "+c" + ansiReset, ansiRed + "-b" + ansiReset, ansiCyan + "@@ -1,2 +1,2 @@" + ansiReset, ansiBold + "--- a/f.txt" + ansiReset} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected colored output to contain %q, got:\n%q", want, out)
+		}
+	}
+
+	if out = run("--patch", "job_1"); out != testDiffPatch {
+		t.Fatalf("--patch output = %q, want raw patch", out)
+	}
+
+	if err := runJobDiff(context.Background(), []string{"--stat", "--patch", "job_1"}, base); err == nil {
+		t.Fatalf("expected error for --stat with --patch")
+	}
+	if err := runJobDiff(context.Background(), []string{"--color", "rainbow", "job_1"}, base); err == nil {
+		t.Fatalf("expected error for invalid --color")
+	}
+	if err := runJobDiff(context.Background(), []string{"job_none"}, base); err == nil || !strings.Contains(err.Error(), "no patch artifact") {
+		t.Fatalf("expected no patch artifact error, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJobMatrixFlags(t *testing.T) {
+	matrix, err := parseJobMatrixFlags([]string{
+		"ref=main, dev",
+		"profile=small",
+		"task:lint=run the linter",
+		"task=run tests",
+		"env:fast=MODEL=fast,DEBUG=1",
+	})
+	if err != nil {
+		t.Fatalf("parseJobMatrixFlags() error = %v", err)
+	}
+	want := jobMatrix{
+		Ref:     []string{"main", "dev"},
+		Profile: []string{"small"},
+		Task: []jobMatrixTaskVariant{
+			{Name: "lint", Task: "run the linter"},
+			{Task: "run tests"},
+		},
+		Env: []jobMatrixEnvVariant{
+			{Name: "fast", Env: map[string]string{"MODEL": "fast", "DEBUG": "1"}},
+		},
+	}
+	if !reflect.DeepEqual(matrix, want) {
+		t.Fatalf("parseJobMatrixFlags() = %+v, want %+v", matrix, want)
+	}
+
+	errCases := map[string]string{
+		"ref":             "expected <axis>=<values>",
+		"ref=a,,b":        "empty ref value",
+		"profile:x=a":     "profile values cannot be named",
+		"task=":           "empty task value",
+		"env=NOVALUE":     "expected KEY=VALUE",
+		"os=linux,darwin": `unknown axis "os"`,
+	}
+	for input, wantErr := range errCases {
+		if _, err := parseJobMatrixFlags([]string{input}); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseJobMatrixFlags(%q) error = %v, want %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestParseEnvAssignments(t *testing.T) {
+	env, err := parseEnvAssignments([]string{"A=1", "", "B=x=y", "C="})
+	if err != nil {
+		t.Fatalf("parseEnvAssignments() error = %v", err)
+	}
+	want := map[string]string{"A": "1", "B": "x=y", "C": ""}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("parseEnvAssignments() = %v, want %v", env, want)
+	}
+	if env, err := parseEnvAssignments(nil); err != nil || env != nil {
+		t.Fatalf("parseEnvAssignments(nil) = %v, %v", env, err)
+	}
+	if _, err := parseEnvAssignments([]string{"=1"}); err == nil {
+		t.Fatalf("expected error for empty key")
+	}
+}
+
+func TestJobRunMatrixCreatesGroup(t *testing.T) {
+	var gotReq jobGroupCreateRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/job-groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Fatalf("decode group request: %v", err)
+		}
+		groupID := "jobgrp_1"
+		writeJSON(t, w, http.StatusCreated, jobGroupResponse{
+			ID:     groupID,
+			Name:   gotReq.Name,
+			Status: "RUNNING",
+			Axes:   []string{"ref", "profile"},
+			Counts: map[string]int{"COMPLETED": 2, "FAILED": 1, "RUNNING": 1},
+			Jobs: []jobResponse{
+				{ID: "job-1", GroupID: &groupID, Status: "COMPLETED", Matrix: map[string]string{"ref": "main", "profile": "small"}},
+				{ID: "job-2", GroupID: &groupID, Status: "FAILED", Matrix: map[string]string{"ref": "main", "profile": "large"}},
+				{ID: "job-3", GroupID: &groupID, Status: "COMPLETED", Matrix: map[string]string{"ref": "dev", "profile": "small"}},
+				{ID: "job-4", GroupID: &groupID, Status: "RUNNING", Matrix: map[string]string{"ref": "dev", "profile": "large"}},
+			},
+			CreatedAt: "2026-02-10T12:00:00Z",
+			UpdatedAt: "2026-02-10T12:00:00Z",
+		})
+	})
+
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, jsonOutput: false, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		err := runJobRun(context.Background(), []string{
+			"--repo", "https://example.com/repo.git",
+			"--task", "run tests",
+			"--env", "LOG=1",
+			"--matrix", "ref=main,dev",
+			"--matrix", "profile=small,large",
+			"--name", "compare",
+		}, base)
+		if err != nil {
+			t.Fatalf("runJobRun() error = %v", err)
+		}
+	})
+
+	if gotReq.Name != "compare" || gotReq.Base.Task != "run tests" || gotReq.Base.Env["LOG"] != "1" {
+		t.Fatalf("unexpected group request %+v", gotReq)
+	}
+	if !reflect.DeepEqual(gotReq.Matrix.Ref, []string{"main", "dev"}) || !reflect.DeepEqual(gotReq.Matrix.Profile, []string{"small", "large"}) {
+		t.Fatalf("unexpected matrix %+v", gotReq.Matrix)
+	}
+	for _, want := range []string{
+		"Group ID: jobgrp_1",
+		"Jobs: 4 (completed=2 failed=1 running=1)",
+		"REF \\ PROFILE  small  large",
+		"main           pass   FAIL",
+		"dev            pass   running",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestJobRunMatrixRejectsWorkspace(t *testing.T) {
+	base := commonFlags{socketPath: "/nonexistent", timeout: time.Second}
+	err := runJobRun(context.Background(), []string{
+		"--repo", "https://example.com/repo.git",
+		"--task", "t",
+		"--matrix", "ref=main",
+		"--workspace", "ws-1",
+	}, base)
+	if err == nil || !strings.Contains(err.Error(), "--matrix") {
+		t.Fatalf("expected --matrix conflict error, got %v", err)
+	}
+	err = runJobRun(context.Background(), []string{"--repo", "r", "--task", "t", "--profile", "p", "--name", "x"}, base)
+	if err == nil || !strings.Contains(err.Error(), "--name requires --matrix") {
+		t.Fatalf("expected --name error, got %v", err)
+	}
+}
+
+func TestJobRunDependsOn(t *testing.T) {
+	var gotReq jobCreateRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Fatalf("decode job request: %v", err)
+		}
+		writeJSON(t, w, http.StatusCreated, jobResponse{
+			ID:               "job-child",
+			Status:           "QUEUED",
+			DependsOn:        gotReq.DependsOn,
+			DependsCondition: "always",
+			ParentArtifacts:  true,
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		err := runJobRun(context.Background(), []string{
+			"--repo", "https://example.com/repo.git",
+			"--task", "summarize",
+			"--profile", "small",
+			"--depends-on", "job-a, job-b",
+			"--depends-on", "job-c",
+			"--depends-condition", "always",
+			"--parent-artifacts",
+		}, base)
+		if err != nil {
+			t.Fatalf("runJobRun() error = %v", err)
+		}
+	})
+	if !reflect.DeepEqual(gotReq.DependsOn, []string{"job-a", "job-b", "job-c"}) || gotReq.DependsCondition != "always" || !gotReq.ParentArtifacts {
+		t.Fatalf("unexpected dependency request %+v", gotReq)
+	}
+	for _, want := range []string{"Depends On: job-a, job-b, job-c (always)", "Parent Artifacts: true"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	err := runJobRun(context.Background(), []string{"--repo", "r", "--task", "t", "--profile", "p", "--parent-artifacts"}, base)
+	if err == nil || !strings.Contains(err.Error(), "require --depends-on") {
+		t.Fatalf("expected --depends-on error, got %v", err)
+	}
+}
+
+func TestJobRunOpenPR(t *testing.T) {
+	var gotReq jobCreateRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Fatalf("decode job request: %v", err)
+		}
+		writeJSON(t, w, http.StatusCreated, jobResponse{
+			ID:            "job-pr",
+			Status:        "COMPLETED",
+			ResultOptions: &jobResultOptions{PushBranch: "agent/job-pr", OpenPR: true, PRBase: "main"},
+			Result:        json.RawMessage(`{"status":"COMPLETED","publish":{"branch":"agent/job-pr","commit":"abc123","pr_url":"https://git.example.com/org/repo/pulls/7"}}`),
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		err := runJobRun(context.Background(), []string{
+			"--repo", "https://git.example.com/org/repo.git",
+			"--task", "fix it",
+			"--profile", "small",
+			"--open-pr",
+			"--pr-title", "Fix it",
+		}, base)
+		if err != nil {
+			t.Fatalf("runJobRun() error = %v", err)
+		}
+	})
+	if gotReq.Result == nil || !gotReq.Result.OpenPR || gotReq.Result.PRTitle != "Fix it" || gotReq.Result.PushBranch != "" {
+		t.Fatalf("unexpected result options %+v", gotReq.Result)
+	}
+	for _, want := range []string{"Push Branch: agent/job-pr", "Pushed Commit: abc123", "Pull Request: https://git.example.com/org/repo/pulls/7"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	err := runJobRun(context.Background(), []string{"--repo", "r", "--task", "t", "--profile", "p", "--pr-base", "dev"}, base)
+	if err == nil || !strings.Contains(err.Error(), "require --open-pr") {
+		t.Fatalf("expected --open-pr error, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileUpgradeStartSendsVersionAndPercent(t *testing.T) {
+	var got map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/profiles/agent/upgrade", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(t, w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode upgrade request: %v", err)
+		}
+		writeJSON(t, w, http.StatusCreated, templateRolloutResponse{
+			Profile: "agent", Template: "agent-base", From: "agent-base@v1", To: "agent-base@v2",
+			Percent: 25, Status: "active", Verdict: "insufficient_data",
+			Baseline: templateVersionStatsResponse{Version: "v1", VMID: 9000},
+			Canary:   templateVersionStatsResponse{Version: "v2", VMID: 9001},
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runProfileCommand(context.Background(), []string{"upgrade", "start", "--to", "v2", "--percent", "25", "agent"}, base); err != nil {
+			t.Fatalf("profile upgrade start error = %v", err)
+		}
+	})
+	if got["to"] != "v2" || got["percent"] != float64(25) {
+		t.Fatalf("sent request = %#v", got)
+	}
+	for _, want := range []string{"agent-base@v1 -> agent-base@v2", "25%", "insufficient_data"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestProfileUpgradeStatusShowsComparison(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/profiles/agent/upgrade", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, templateRolloutResponse{
+			Profile: "agent", Template: "agent-base", From: "agent-base@v1", To: "agent-base@v2",
+			Percent: 50, Status: "active", Verdict: "regressed",
+			Baseline: templateVersionStatsResponse{Version: "v1", VMID: 9000, Sandboxes: 10, Ready: 10, ReadyP95MS: 2000, Jobs: 10, JobsFailed: 1, JobFailureRate: 0.1},
+			Canary:   templateVersionStatsResponse{Version: "v2", VMID: 9001, Sandboxes: 10, Ready: 10, ReadyP95MS: 2100, Jobs: 10, JobsFailed: 5, JobFailureRate: 0.5},
+			Reasons:  []string{"job failure rate 50.0% vs 10.0%"},
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runProfileCommand(context.Background(), []string{"upgrade", "status", "agent"}, base); err != nil {
+			t.Fatalf("profile upgrade status error = %v", err)
+		}
+	})
+	for _, want := range []string{"baseline", "canary", "50.0%", "regressed", "- job failure rate 50.0% vs 10.0%"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestProfileUpgradePercentRequiresValue(t *testing.T) {
+	err := runProfileCommand(context.Background(), []string{"upgrade", "percent", "agent"}, commonFlags{jsonOutput: true})
+	if err == nil || !strings.Contains(err.Error(), "--percent") {
+		t.Fatalf("expected --percent usage error, got %v", err)
+	}
+}
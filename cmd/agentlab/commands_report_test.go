@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportUsageCommandWritesCSV(t *testing.T) {
+	var gotQuery string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/reports/usage", func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		writeJSON(t, w, http.StatusOK, usageReportResponse{
+			From:     "2026-03-01T00:00:00Z",
+			To:       "2026-04-01T00:00:00Z",
+			GroupBy:  "team",
+			Currency: "EUR",
+			Rows: []usageReportRow{
+				{Group: "platform", Sandboxes: 2, Hours: 10, CoreHours: 20, MemoryGiBHours: 40, InputTokens: 1000, ComputeCost: 12.5, TotalCost: 12.5},
+			},
+			Total: usageReportRow{Group: "total", Sandboxes: 2, Hours: 10, CoreHours: 20, MemoryGiBHours: 40, InputTokens: 1000, ComputeCost: 12.5, TotalCost: 12.5},
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		args := []string{"usage", "--from", "2026-03-01", "--to", "2026-04-01", "--group-by", "team", "--format", "csv"}
+		if err := runReportCommand(context.Background(), args, base); err != nil {
+			t.Fatalf("report usage error = %v", err)
+		}
+	})
+	for _, want := range []string{"from=2026-03-01", "to=2026-04-01", "group_by=team"} {
+		if !strings.Contains(gotQuery, want) {
+			t.Fatalf("query %q missing %q", gotQuery, want)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("csv lines = %d, want 3:\n%s", len(lines), out)
+	}
+	if !strings.HasPrefix(lines[0], "team,sandboxes,hours,core_hours") {
+		t.Fatalf("unexpected header: %s", lines[0])
+	}
+	if lines[1] != "platform,2,10,20,40,0,1000,0,12.5,0,12.5,EUR" {
+		t.Fatalf("unexpected row: %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "total,") {
+		t.Fatalf("unexpected total: %s", lines[2])
+	}
+}
+
+func TestReportUsageCommandRejectsUnknownFormat(t *testing.T) {
+	err := runReportCommand(context.Background(), []string{"usage", "--format", "xml"}, commonFlags{timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "--format") {
+		t.Fatalf("expected format error, got %v", err)
+	}
+}
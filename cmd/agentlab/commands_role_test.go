@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoleBindCommandSendsSubjectAndScope(t *testing.T) {
+	var got map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/role-bindings", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("method = %s, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		writeJSON(t, w, http.StatusCreated, roleBinding{ID: 7, Role: got["role"], Subject: got["subject"], Scope: got["scope"]})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		args := []string{"bind", "--team", "infra", "--scope", "team:infra", "operator"}
+		if err := runRoleCommand(context.Background(), args, base); err != nil {
+			t.Fatalf("role bind error = %v", err)
+		}
+	})
+	if got["role"] != "operator" || got["subject"] != "team:infra" || got["scope"] != "team:infra" {
+		t.Fatalf("unexpected request: %v", got)
+	}
+	if !strings.Contains(out, "binding 7") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestRoleBindCommandNeedsOneSubject(t *testing.T) {
+	for _, args := range [][]string{
+		{"bind", "operator"},
+		{"bind", "--user", "alice", "--team", "infra", "operator"},
+	} {
+		err := runRoleCommand(context.Background(), args, commonFlags{timeout: time.Second})
+		if err == nil || !strings.Contains(err.Error(), "--user or --team") {
+			t.Fatalf("%v: expected subject error, got %v", args, err)
+		}
+	}
+}
+
+func TestRoleListCommandPrintsRolesAndBindings(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/roles", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, roleListResponse{
+			Roles:    []customRole{{Name: "operator", Permissions: []string{"sandbox", "workspace.read"}}},
+			Bindings: []roleBinding{{ID: 3, Role: "operator", Subject: "team:infra", Scope: "team:infra"}},
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runRoleCommand(context.Background(), []string{"ls"}, base); err != nil {
+			t.Fatalf("role ls error = %v", err)
+		}
+	})
+	for _, want := range []string{"operator", "sandbox,workspace.read", "team:infra"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCast = `{"version":2,"width":80,"height":24}
+[0.01,"o","$ "]
+[0.02,"i","ls\r"]
+[0.03,"o","README.md\r\n"]
+`
+
+func newRecordingsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/sandboxes/1001/recordings", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, sandboxRecordingsResponse{
+			VMID: 1001,
+			Recordings: []sandboxRecording{
+				{ID: 7, VMID: 1001, User: "alice@laptop", Name: "session.cast", SizeBytes: int64(len(testCast)), CreatedAt: "2026-03-01T12:00:00Z"},
+			},
+		})
+	})
+	mux.HandleFunc("/v1/sandboxes/1001/recordings/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-asciicast")
+		_, _ = w.Write([]byte(testCast))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSandboxRecordingsList(t *testing.T) {
+	server := newRecordingsServer(t)
+	base := commonFlags{endpoint: server.URL, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		require.NoError(t, runSandboxRecordingsCommand(context.Background(), []string{"ls", "1001"}, base))
+	})
+	assert.Contains(t, out, "USER")
+	assert.Contains(t, out, "alice@laptop")
+	assert.Contains(t, out, "session.cast")
+}
+
+func TestSandboxRecordingsPlay(t *testing.T) {
+	server := newRecordingsServer(t)
+	base := commonFlags{endpoint: server.URL, timeout: time.Second}
+
+	t.Run("replays output only", func(t *testing.T) {
+		out := captureStdout(t, func() {
+			require.NoError(t, runSandboxRecordingsCommand(context.Background(), []string{"play", "--speed", "100", "1001", "7"}, base))
+		})
+		assert.Equal(t, "$ README.md\r\n", out)
+	})
+
+	t.Run("saves the file", func(t *testing.T) {
+		dir := t.TempDir() + string(os.PathSeparator)
+		captureStdout(t, func() {
+			require.NoError(t, runSandboxRecordingsCommand(context.Background(), []string{"play", "--out", dir, "1001", "7"}, base))
+		})
+		data, err := os.ReadFile(filepath.Join(dir, "sandbox-1001-recording-7.cast"))
+		require.NoError(t, err)
+		assert.Equal(t, testCast, string(data))
+	})
+
+	t.Run("rejects bad speed", func(t *testing.T) {
+		err := runSandboxRecordingsCommand(context.Background(), []string{"play", "--speed", "0", "1001", "7"}, base)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateBuildCommandWaits(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "agent-base.yaml")
+	spec := "name: agent-base\nimage:\n  url: https://example.test/noble.img\n"
+	if err := os.WriteFile(specPath, []byte(spec), 0o600); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+
+	var gotSpec string
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/templates", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(t, w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode build request: %v", err)
+		}
+		gotSpec = req["spec"]
+		writeJSON(t, w, http.StatusAccepted, templateResponse{Name: "agent-base", Version: "v3", Ref: "agent-base@v3", VMID: 9003, Status: "building"})
+	})
+	mux.HandleFunc("/v1/templates/agent-base/v3", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		writeJSON(t, w, http.StatusOK, templateResponse{Name: "agent-base", Version: "v3", Ref: "agent-base@v3", VMID: 9003, Status: "ready"})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runTemplateCommand(context.Background(), []string{"build", "--wait", specPath}, base); err != nil {
+			t.Fatalf("template build error = %v", err)
+		}
+	})
+	if gotSpec != spec {
+		t.Fatalf("sent spec = %q, want %q", gotSpec, spec)
+	}
+	if polls != 1 {
+		t.Fatalf("polls = %d, want 1", polls)
+	}
+	if !strings.Contains(out, "Template agent-base@v3 is ready (VM 9003)") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestTemplateBuildCommandReportsFailure(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "agent-base.yaml")
+	if err := os.WriteFile(specPath, []byte("name: agent-base\n"), 0o600); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/templates", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusAccepted, templateResponse{Name: "agent-base", Version: "v1", Ref: "agent-base@v1", VMID: 9000, Status: "building"})
+	})
+	mux.HandleFunc("/v1/templates/agent-base/v1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, templateResponse{Name: "agent-base", Version: "v1", Ref: "agent-base@v1", VMID: 9000, Status: "failed", Error: "image checksum mismatch"})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	var err error
+	_ = captureStdout(t, func() {
+		err = runTemplateCommand(context.Background(), []string{"build", "--wait", specPath}, base)
+	})
+	if err == nil || !strings.Contains(err.Error(), "image checksum mismatch") {
+		t.Fatalf("expected build failure, got %v", err)
+	}
+}
+
+func TestTemplateListAndShowCommands(t *testing.T) {
+	var listQuery string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/templates", func(w http.ResponseWriter, r *http.Request) {
+		listQuery = r.URL.RawQuery
+		writeJSON(t, w, http.StatusOK, templatesResponse{Templates: []templateResponse{
+			{Ref: "agent-base@v2", VMID: 9001, Status: "ready", BuiltBy: "alice", CreatedAt: "2026-10-01T09:00:00Z"},
+			{Ref: "agent-base@v1", VMID: 9000, Status: "failed", CreatedAt: "2026-09-01T09:00:00Z"},
+		}})
+	})
+	mux.HandleFunc("/v1/templates/agent-base/v2", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, templateResponse{
+			Name: "agent-base", Version: "v2", Ref: "agent-base@v2", VMID: 9001, Status: "ready",
+			ImageURL: "https://example.test/noble.img", Spec: "name: agent-base\n",
+		})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runTemplateCommand(context.Background(), []string{"list", "--name", "agent-base"}, base); err != nil {
+			t.Fatalf("template list error = %v", err)
+		}
+	})
+	if listQuery != "name=agent-base" {
+		t.Fatalf("list query = %q, want name=agent-base", listQuery)
+	}
+	for _, want := range []string{"TEMPLATE", "agent-base@v2", "alice", "failed"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected list output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() {
+		if err := runTemplateCommand(context.Background(), []string{"show", "agent-base@v2"}, base); err != nil {
+			t.Fatalf("template show error = %v", err)
+		}
+	})
+	for _, want := range []string{"agent-base@v2", "9001", "https://example.test/noble.img", "Spec:\nname: agent-base\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected show output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	if err := runTemplateCommand(context.Background(), []string{"show", "agent-base"}, base); err == nil {
+		t.Fatalf("expected show without a version to fail")
+	}
+}
@@ -59,16 +59,18 @@ var (
 		"profile", "secrets", "msg", "ssh", "logs",
 		"connect", "disconnect", "token", "integration",
 		"user", "team", "defaults", "version", "completion",
+		"template", "report", "role", "access", "approval",
 	}
 
-	jobSubcommands = []string{"run", "validate", "show", "artifacts", "doctor"}
+	jobSubcommands = []string{"run", "validate", "show", "artifacts", "diff", "doctor", "group"}
 	sandboxSubcommands = []string{
 		"new", "validate", "list", "inventory", "reconcile",
 		"show", "update", "start", "stop", "pause", "resume",
 		"revert", "destroy", "lease", "prune", "expose",
-		"exposed", "unexpose", "doctor",
+		"exposed", "unexpose", "doctor", "recordings",
 	}
 	sandboxSnapshotSubcommands = []string{"save", "list", "restore"}
+	sandboxRecordingsSubcommands = []string{"ls", "play"}
 	workspaceSubcommands = []string{
 		"create", "list", "check", "fsck",
 		"attach", "detach", "lease", "rebind", "fork", "snapshot",
@@ -78,13 +80,19 @@ var (
 		"create", "list", "show", "resume", "stop",
 		"fork", "branch", "doctor",
 	}
-	profileSubcommands = []string{"list"}
-	msgSubcommands = []string{"post", "tail"}
+	profileSubcommands = []string{"list", "upgrade"}
+	profileUpgradeSubcommands = []string{"start", "status", "percent", "promote", "rollback"}
+	msgSubcommands = []string{"post", "tail", "inbox", "reply"}
 	tokenSubcommands = []string{"create", "list", "inspect"}
 	integrationSubcommands = []string{"add", "list", "rm", "status"}
-	userSubcommands = []string{"add", "list", "rm"}
+	userSubcommands = []string{"add", "identity", "list", "rm"}
 	teamSubcommands = []string{"add", "members", "rm"}
-	secretsSubcommands = []string{"show", "validate", "add-ssh-key", "remove-ssh-key", "set-tailscale", "clear-tailscale"}
+	templateSubcommands = []string{"build", "list", "show"}
+	reportSubcommands = []string{"usage"}
+	roleSubcommands = []string{"create", "rm", "ls", "bind", "unbind"}
+	accessSubcommands = []string{"ls", "grant", "revoke", "team"}
+	approvalSubcommands = []string{"ls", "show", "approve", "reject"}
+	secretsSubcommands = []string{"show", "validate", "add-ssh-key", "remove-ssh-key", "set-tailscale", "clear-tailscale", "set-policy", "clear-policy", "requests", "approve", "deny"}
 	defaultsSubcommands = []string{"write", "read", "list", "delete"}
 	completionShells = []string{"bash", "zsh", "fish"}
 )
@@ -139,8 +147,11 @@ _agentlab_completion() {
 		job)
 			case "$subcmd" in
 				"") COMPREPLY=($(compgen -W "` + strings.Join(jobSubcommands, " ") + `" -- "$cur")) ;;
-				run|validate)
+				run)
+					COMPREPLY=($(compgen -W "--repo --task --profile --ref --branch --mode --ttl --keepalive --workspace --workspace-create --workspace-size --workspace-storage --workspace-wait --stateful --env --matrix --name --json --help" -- "$cur")) ;;
+				validate)
 					COMPREPLY=($(compgen -W "--repo --task --profile --ref --branch --mode --ttl --keepalive --workspace --workspace-create --workspace-size --workspace-storage --workspace-wait --stateful --json --help" -- "$cur")) ;;
+				group) COMPREPLY=($(compgen -W "show artifacts --json --help" -- "$cur")) ;;
 				show) COMPREPLY=($(compgen -W "--events-tail --json --help" -- "$cur")) ;;
 				artifacts)
 					if [[ "$subsub" == "download" ]]; then
@@ -164,7 +175,14 @@ _agentlab_completion() {
 						restore) COMPREPLY=($(compgen -W "--force --json --help" -- "$cur")) ;;
 					esac
 					;;
-				new) COMPREPLY=($(compgen -W "--name --ttl --keepalive --workspace --vmid --job --and-ssh --type --image --prompt --profile --json --help -h" -- "$cur")) ;;
+				recordings)
+					case "$subsub" in
+						"") COMPREPLY=($(compgen -W "` + strings.Join(sandboxRecordingsSubcommands, " ") + `" -- "$cur")) ;;
+						ls) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+						play) COMPREPLY=($(compgen -W "--speed --max-idle --out --help" -- "$cur")) ;;
+					esac
+					;;
+				new) COMPREPLY=($(compgen -W "--name --ttl --keepalive --workspace --vmid --job --and-ssh --type --image --prompt --profile --team --json --help -h" -- "$cur")) ;;
 				validate) COMPREPLY=($(compgen -W "--name --ttl --keepalive --workspace --vmid --job --profile --json --help" -- "$cur")) ;;
 				update) COMPREPLY=($(compgen -W "--cores --memory --json --help" -- "$cur")) ;;
 				stop) COMPREPLY=($(compgen -W "--all --force --json --help" -- "$cur")) ;;
@@ -178,6 +196,7 @@ _agentlab_completion() {
 					;;
 				expose) COMPREPLY=($(compgen -W "--force --json --help" -- "$cur")) ;;
 				doctor) COMPREPLY=($(compgen -W "--out --json --help" -- "$cur")) ;;
+				list) COMPREPLY=($(compgen -W "--team --json --help" -- "$cur")) ;;
 				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
 			esac
 			return
@@ -190,7 +209,8 @@ _agentlab_completion() {
 						"") COMPREPLY=($(compgen -W "` + strings.Join(workspaceSnapshotSubcommands, " ") + `" -- "$cur")) ;;
 					esac
 					;;
-				create) COMPREPLY=($(compgen -W "--name --size --storage --json --help" -- "$cur")) ;;
+				create) COMPREPLY=($(compgen -W "--name --size --storage --team --json --help" -- "$cur")) ;;
+				list) COMPREPLY=($(compgen -W "--team --json --help" -- "$cur")) ;;
 				fsck) COMPREPLY=($(compgen -W "--repair --json --help" -- "$cur")) ;;
 				rebind) COMPREPLY=($(compgen -W "--profile --ttl --keep-old --json --help" -- "$cur")) ;;
 				fork) COMPREPLY=($(compgen -W "--name --from-snapshot --json --help" -- "$cur")) ;;
@@ -201,7 +221,8 @@ _agentlab_completion() {
 		session)
 			case "$subcmd" in
 				"") COMPREPLY=($(compgen -W "` + strings.Join(sessionSubcommands, " ") + `" -- "$cur")) ;;
-				create) COMPREPLY=($(compgen -W "--name --profile --workspace --workspace-create --workspace-size --workspace-storage --branch --json --help" -- "$cur")) ;;
+				create) COMPREPLY=($(compgen -W "--name --profile --workspace --workspace-create --workspace-size --workspace-storage --branch --team --json --help" -- "$cur")) ;;
+				list) COMPREPLY=($(compgen -W "--team --json --help" -- "$cur")) ;;
 				fork) COMPREPLY=($(compgen -W "--name --workspace --workspace-create --workspace-size --workspace-storage --profile --branch --json --help" -- "$cur")) ;;
 				branch) COMPREPLY=($(compgen -W "--profile --workspace --workspace-create --workspace-size --workspace-storage --json --help" -- "$cur")) ;;
 				doctor) COMPREPLY=($(compgen -W "--out --json --help" -- "$cur")) ;;
@@ -210,7 +231,19 @@ _agentlab_completion() {
 			return
 			;;
 		profile)
-			COMPREPLY=($(compgen -W "list --json --help" -- "$cur"))
+			case "$subcmd" in
+				"") COMPREPLY=($(compgen -W "` + strings.Join(profileSubcommands, " ") + `" -- "$cur")) ;;
+				upgrade)
+					case "$subsub" in
+						"") COMPREPLY=($(compgen -W "` + strings.Join(profileUpgradeSubcommands, " ") + `" -- "$cur")) ;;
+						start) COMPREPLY=($(compgen -W "--to --percent --json --help" -- "$cur")) ;;
+						percent) COMPREPLY=($(compgen -W "--percent --json --help" -- "$cur")) ;;
+						promote) COMPREPLY=($(compgen -W "--force --json --help" -- "$cur")) ;;
+						*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+					esac
+					;;
+				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+			esac
 			return
 			;;
 		secrets)
@@ -248,6 +281,49 @@ _agentlab_completion() {
 			esac
 			return
 			;;
+		template)
+			case "$subcmd" in
+				"") COMPREPLY=($(compgen -W "` + strings.Join(templateSubcommands, " ") + `" -- "$cur")) ;;
+				build) COMPREPLY=($(compgen -f -W "--wait --json --help" -- "$cur")) ;;
+				list) COMPREPLY=($(compgen -W "--name --json --help" -- "$cur")) ;;
+				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+			esac
+			return
+			;;
+		report)
+			case "$subcmd" in
+				"") COMPREPLY=($(compgen -W "` + strings.Join(reportSubcommands, " ") + `" -- "$cur")) ;;
+				usage) COMPREPLY=($(compgen -W "--from --to --group-by --format --json --help" -- "$cur")) ;;
+				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+			esac
+			return
+			;;
+		role)
+			case "$subcmd" in
+				"") COMPREPLY=($(compgen -W "` + strings.Join(roleSubcommands, " ") + `" -- "$cur")) ;;
+				create) COMPREPLY=($(compgen -W "--permissions --description --json --help" -- "$cur")) ;;
+				bind) COMPREPLY=($(compgen -W "--user --team --scope --json --help" -- "$cur")) ;;
+				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+			esac
+			return
+			;;
+		access)
+			case "$subcmd" in
+				"") COMPREPLY=($(compgen -W "` + strings.Join(accessSubcommands, " ") + `" -- "$cur")) ;;
+				grant) COMPREPLY=($(compgen -W "--user --level --json --help" -- "$cur")) ;;
+				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+			esac
+			return
+			;;
+		approval)
+			case "$subcmd" in
+				"") COMPREPLY=($(compgen -W "` + strings.Join(approvalSubcommands, " ") + `" -- "$cur")) ;;
+				ls) COMPREPLY=($(compgen -W "--status --json --help" -- "$cur")) ;;
+				reject) COMPREPLY=($(compgen -W "--reason --json --help" -- "$cur")) ;;
+				*) COMPREPLY=($(compgen -W "--json --help" -- "$cur")) ;;
+			esac
+			return
+			;;
 		defaults)
 			case "$subcmd" in
 				"") COMPREPLY=($(compgen -W "` + strings.Join(defaultsSubcommands, " ") + `" -- "$cur")) ;;
@@ -315,7 +391,7 @@ _agentlab() {
 				'sandbox:Manage sandboxes'
 				'workspace:Manage workspaces'
 				'session:Manage sessions'
-				'profile:List profiles'
+				'profile:Manage profiles'
 				'secrets:Manage secrets'
 				'msg:Message box'
 				'ssh:SSH into a sandbox'
@@ -326,6 +402,11 @@ _agentlab() {
 				'integration:Manage integrations'
 				'user:Manage users'
 				'team:Manage teams'
+				'template:Build VM templates'
+				'report:Usage and cost reports'
+				'role:Manage custom roles'
+				'access:Share sandboxes, workspaces and sessions'
+				'approval:Approve held operations'
 				'defaults:Set CLI preferences'
 				'version:Show version info'
 				'completion:Generate shell completions'
@@ -336,42 +417,60 @@ _agentlab() {
 			case $words[1] in
 				job)
 					case $words[2] in
-						run) _arguments '--repo[Repository URL]:url:' '--task[Task description]:task:' '--profile[Profile name]:profile:' '--ref[Git ref]:ref:' '--branch[Git branch]:branch:' '--mode[Mode]:mode:' '--ttl[Time to live]:duration:' '--keepalive[Keep alive]' '--workspace[Workspace]:workspace:' '--stateful[Stateful]' ;;
+						run) _arguments '--repo[Repository URL]:url:' '--task[Task description]:task:' '--profile[Profile name]:profile:' '--ref[Git ref]:ref:' '--branch[Git branch]:branch:' '--mode[Mode]:mode:' '--ttl[Time to live]:duration:' '--keepalive[Keep alive]' '--workspace[Workspace]:workspace:' '--stateful[Stateful]' '--env[Environment override]:env:' '--matrix[Matrix axis]:axis:' '--name[Group name]:name:' ;;
 						validate) _arguments '--repo[Repository URL]:url:' '--task[Task description]:task:' '--profile[Profile name]:profile:' ;;
 						show) _arguments '--events-tail[Tail events]:n:' ;;
 						artifacts) _arguments '2:subcommand:(download)' ;;
 						doctor) _arguments '--out[Output path]:path:_files' ;;
-						*) _describe 'job subcommand' '(run validate show artifacts doctor)' ;;
+						group) _arguments '2:subcommand:(show artifacts)' ;;
+						*) _describe 'job subcommand' '(run validate show artifacts doctor group)' ;;
 					esac
 					;;
 				sandbox)
 					case $words[2] in
-						new) _arguments '--name[Name]:name:' '--profile[Profile]:profile:' '--ttl[TTL]:duration:' '--type[Type]:type:(lxc vm)' '--image[Image]:image:' '--prompt[Prompt]:text:' ;;
+						new) _arguments '--name[Name]:name:' '--profile[Profile]:profile:' '--ttl[TTL]:duration:' '--type[Type]:type:(lxc vm)' '--image[Image]:image:' '--prompt[Prompt]:text:' '--team[Team]:team:' ;;
 						snapshot) _describe 'snapshot subcommand' '(save list restore)' ;;
-						*) _describe 'sandbox subcommand' '(new validate list inventory reconcile show update start stop pause resume revert snapshot destroy lease prune expose exposed unexpose doctor)' ;;
+						recordings) _describe 'recordings subcommand' '(ls play)' ;;
+						*) _describe 'sandbox subcommand' '(new validate list inventory reconcile show update start stop pause resume revert snapshot destroy lease prune expose exposed unexpose doctor recordings)' ;;
 					esac
 					;;
 				workspace)
 					case $words[2] in
-						create) _arguments '--name[Name]:name:' '--size[Size]:size:' '--storage[Storage]:storage:' ;;
+						create) _arguments '--name[Name]:name:' '--size[Size]:size:' '--storage[Storage]:storage:' '--team[Team]:team:' ;;
 						snapshot) _describe 'snapshot subcommand' '(create list restore)' ;;
 						*) _describe 'workspace subcommand' '(create list check fsck attach detach lease rebind fork snapshot)' ;;
 					esac
 					;;
 				session)
 					case $words[2] in
-						create) _arguments '--name[Name]:name:' '--profile[Profile]:profile:' '--workspace[Workspace]:workspace:' ;;
+						create) _arguments '--name[Name]:name:' '--profile[Profile]:profile:' '--workspace[Workspace]:workspace:' '--team[Team]:team:' ;;
 						*) _describe 'session subcommand' '(create list show resume stop fork branch doctor)' ;;
 					esac
 					;;
+				profile)
+					case $words[2] in
+						upgrade) _describe 'upgrade subcommand' '(start status percent promote rollback)' ;;
+						*) _describe 'profile subcommand' '(list upgrade)' ;;
+					esac
+					;;
 				token)
 					_describe 'token subcommand' '(create list inspect)' ;;
 				integration)
 					_describe 'integration subcommand' '(add list rm status)' ;;
 				user)
-					_describe 'user subcommand' '(add list rm)' ;;
+					_describe 'user subcommand' '(add identity list rm)' ;;
 				team)
 					_describe 'team subcommand' '(add members rm)' ;;
+				template)
+					_describe 'template subcommand' '(build list show)' ;;
+				report)
+					_describe 'report subcommand' '(usage)' ;;
+				role)
+					_describe 'role subcommand' '(create rm ls bind unbind)' ;;
+				access)
+					_describe 'access subcommand' '(ls grant revoke team)' ;;
+				approval)
+					_describe 'approval subcommand' '(ls show approve reject)' ;;
 				defaults)
 					case $words[2] in
 						write|read|delete) _describe 'defaults key' '(default-profile default-image default-backend output-format default-timeout default-socket)' ;;
@@ -412,7 +511,7 @@ complete -c agentlab -n '__fish_use_subcommand' -a 'job' -d 'Manage jobs'
 complete -c agentlab -n '__fish_use_subcommand' -a 'sandbox' -d 'Manage sandboxes'
 complete -c agentlab -n '__fish_use_subcommand' -a 'workspace' -d 'Manage workspaces'
 complete -c agentlab -n '__fish_use_subcommand' -a 'session' -d 'Manage sessions'
-complete -c agentlab -n '__fish_use_subcommand' -a 'profile' -d 'List profiles'
+complete -c agentlab -n '__fish_use_subcommand' -a 'profile' -d 'Manage profiles'
 complete -c agentlab -n '__fish_use_subcommand' -a 'secrets' -d 'Manage secrets'
 complete -c agentlab -n '__fish_use_subcommand' -a 'msg' -d 'Message box'
 complete -c agentlab -n '__fish_use_subcommand' -a 'ssh' -d 'SSH into sandbox'
@@ -423,6 +522,11 @@ complete -c agentlab -n '__fish_use_subcommand' -a 'token' -d 'Manage API tokens
 complete -c agentlab -n '__fish_use_subcommand' -a 'integration' -d 'Manage integrations'
 complete -c agentlab -n '__fish_use_subcommand' -a 'user' -d 'Manage users'
 complete -c agentlab -n '__fish_use_subcommand' -a 'team' -d 'Manage teams'
+complete -c agentlab -n '__fish_use_subcommand' -a 'template' -d 'Build VM templates'
+complete -c agentlab -n '__fish_use_subcommand' -a 'report' -d 'Usage and cost reports'
+complete -c agentlab -n '__fish_use_subcommand' -a 'role' -d 'Manage custom roles'
+complete -c agentlab -n '__fish_use_subcommand' -a 'access' -d 'Share sandboxes, workspaces and sessions'
+complete -c agentlab -n '__fish_use_subcommand' -a 'approval' -d 'Approve held operations'
 complete -c agentlab -n '__fish_use_subcommand' -a 'defaults' -d 'CLI preferences'
 complete -c agentlab -n '__fish_use_subcommand' -a 'version' -d 'Show version'
 complete -c agentlab -n '__fish_use_subcommand' -a 'completion' -d 'Shell completions'
@@ -442,6 +546,7 @@ complete -c agentlab -n '__fish_seen_subcommand_from job' -a 'validate' -d 'Vali
 complete -c agentlab -n '__fish_seen_subcommand_from job' -a 'show' -d 'Show job details'
 complete -c agentlab -n '__fish_seen_subcommand_from job' -a 'artifacts' -d 'Job artifacts'
 complete -c agentlab -n '__fish_seen_subcommand_from job' -a 'doctor' -d 'Debug job'
+complete -c agentlab -n '__fish_seen_subcommand_from job' -a 'group' -d 'Job groups'
 
 # Sandbox subcommands
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'new' -d 'Create sandbox'
@@ -451,6 +556,7 @@ complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'start' -d 'Sta
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'stop' -d 'Stop sandbox'
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'destroy' -d 'Destroy sandbox'
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'snapshot' -d 'Snapshots'
+complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'recordings' -d 'Session recordings'
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'expose' -d 'Expose port'
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'ssh' -d 'SSH into sandbox'
 complete -c agentlab -n '__fish_seen_subcommand_from sandbox' -a 'logs' -d 'View logs'
@@ -484,11 +590,43 @@ complete -c agentlab -n '__fish_seen_subcommand_from integration' -a 'status' -d
 complete -c agentlab -n '__fish_seen_subcommand_from user' -a 'add' -d 'Add user'
 complete -c agentlab -n '__fish_seen_subcommand_from user' -a 'list' -d 'List users'
 complete -c agentlab -n '__fish_seen_subcommand_from user' -a 'rm' -d 'Remove user'
+complete -c agentlab -n '__fish_seen_subcommand_from user' -a 'identity' -d 'Link single sign-on identities'
 
 # Team subcommands
 complete -c agentlab -n '__fish_seen_subcommand_from team' -a 'add' -d 'Add team'
 complete -c agentlab -n '__fish_seen_subcommand_from team' -a 'members' -d 'List members'
 complete -c agentlab -n '__fish_seen_subcommand_from team' -a 'rm' -d 'Remove team'
+
+# Profile subcommands
+complete -c agentlab -n '__fish_seen_subcommand_from profile' -a 'list' -d 'List profiles'
+complete -c agentlab -n '__fish_seen_subcommand_from profile' -a 'upgrade' -d 'Canary a template upgrade'
+
+# Template subcommands
+complete -c agentlab -n '__fish_seen_subcommand_from template' -a 'build' -d 'Build a template'
+complete -c agentlab -n '__fish_seen_subcommand_from template' -a 'list' -d 'List templates'
+complete -c agentlab -n '__fish_seen_subcommand_from template' -a 'show' -d 'Show a template'
+
+# Report subcommands
+complete -c agentlab -n '__fish_seen_subcommand_from report' -a 'usage' -d 'Usage and cost per group'
+
+# Role subcommands
+complete -c agentlab -n '__fish_seen_subcommand_from role' -a 'create' -d 'Create a custom role'
+complete -c agentlab -n '__fish_seen_subcommand_from role' -a 'rm' -d 'Remove a custom role'
+complete -c agentlab -n '__fish_seen_subcommand_from role' -a 'ls' -d 'List roles and bindings'
+complete -c agentlab -n '__fish_seen_subcommand_from role' -a 'bind' -d 'Grant a role'
+complete -c agentlab -n '__fish_seen_subcommand_from role' -a 'unbind' -d 'Remove a role binding'
+
+# Access subcommands
+complete -c agentlab -n '__fish_seen_subcommand_from access' -a 'ls' -d 'List owner, team and grants'
+complete -c agentlab -n '__fish_seen_subcommand_from access' -a 'grant' -d 'Share a resource with a user'
+complete -c agentlab -n '__fish_seen_subcommand_from access' -a 'revoke' -d 'Remove an access grant'
+complete -c agentlab -n '__fish_seen_subcommand_from access' -a 'team' -d 'Hand a resource to a team'
+
+# Approval subcommands
+complete -c agentlab -n '__fish_seen_subcommand_from approval' -a 'ls' -d 'List held operations'
+complete -c agentlab -n '__fish_seen_subcommand_from approval' -a 'show' -d 'Show a held operation'
+complete -c agentlab -n '__fish_seen_subcommand_from approval' -a 'approve' -d 'Approve an operation'
+complete -c agentlab -n '__fish_seen_subcommand_from approval' -a 'reject' -d 'Reject an operation'
 `
 	fmt.Fprint(w, script)
 	return nil
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// jobDiffResponse is the daemon's view of a job's patch artifact.
+type jobDiffResponse struct {
+	JobID        string         `json:"job_id"`
+	Ref          string         `json:"ref"`
+	Base         string         `json:"base,omitempty"`
+	Head         string         `json:"head,omitempty"`
+	Files        []diffFileStat `json:"files"`
+	FilesChanged int            `json:"files_changed"`
+	Additions    int            `json:"additions"`
+	Deletions    int            `json:"deletions"`
+	Patch        string         `json:"patch"`
+	Truncated    bool           `json:"truncated,omitempty"`
+}
+
+type diffFileStat struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// runJobDiff prints the changes a job made against its base ref.
+func runJobDiff(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("job diff")
+	opts := base
+	opts.bind(fs)
+	var statOnly bool
+	var rawPatch bool
+	var color string
+	help := bindHelpFlag(fs)
+	fs.BoolVar(&statOnly, "stat", false, "print only the per-file summary")
+	fs.BoolVar(&rawPatch, "patch", false, "print the full unified diff without the summary (suitable for git apply)")
+	fs.StringVar(&color, "color", "auto", "colorize output: auto, always, or never")
+	if err := parseFlags(fs, args, printJobDiffUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		if !opts.jsonOutput {
+			printJobDiffUsage()
+		}
+		return fmt.Errorf("job_id is required")
+	}
+	jobID := strings.TrimSpace(fs.Arg(0))
+	if jobID == "" {
+		return fmt.Errorf("job_id is required")
+	}
+	if statOnly && rawPatch {
+		return fmt.Errorf("--stat and --patch are mutually exclusive")
+	}
+	useColor, err := resolveColor(color, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	path, err := endpointPath("/v1/jobs", jobID, "diff")
+	if err != nil {
+		return err
+	}
+	if rawPatch {
+		resp, err := client.doRequest(ctx, http.MethodGet, path+"?format=patch", nil, map[string]string{"Accept": "text/x-diff"})
+		if err != nil {
+			return wrapJobNotFound(jobID, err)
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return wrapJobNotFound(jobID, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var diff jobDiffResponse
+	if err := json.Unmarshal(payload, &diff); err != nil {
+		return err
+	}
+	printJobDiffStat(os.Stdout, diff, useColor)
+	if statOnly {
+		return nil
+	}
+	fmt.Fprintln(os.Stdout)
+	writeColorizedDiff(os.Stdout, diff.Patch, useColor)
+	if diff.Truncated {
+		fmt.Fprintf(os.Stderr, "warning: diff truncated; run `agentlab job diff --patch %s` for the full patch\n", jobID)
+	}
+	return nil
+}
+
+// resolveColor decides whether to emit ANSI colors. auto honors NO_COLOR and
+// only colors terminals.
+func resolveColor(mode string, out *os.File) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "auto":
+		if os.Getenv("NO_COLOR") != "" || out == nil {
+			return false, nil
+		}
+		return isatty.IsTerminal(out.Fd()), nil
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	default:
+		return false, fmt.Errorf("--color must be auto, always, or never")
+	}
+}
+
+func printJobDiffStat(w io.Writer, diff jobDiffResponse, useColor bool) {
+	base := diff.Ref
+	if diff.Base != "" {
+		base = fmt.Sprintf("%s (%s)", diff.Ref, shortCommit(diff.Base))
+	}
+	fmt.Fprintf(w, "Job %s against %s\n", diff.JobID, base)
+	for _, f := range diff.Files {
+		name := f.Path
+		if f.OldPath != "" {
+			name = f.OldPath + " => " + f.Path
+		}
+		change := "Bin"
+		if !f.Binary {
+			change = colorize(fmt.Sprintf("+%d", f.Additions), ansiGreen, useColor) + " " + colorize(fmt.Sprintf("-%d", f.Deletions), ansiRed, useColor)
+		}
+		fmt.Fprintf(w, " %-8s %s | %s\n", f.Status, name, change)
+	}
+	fmt.Fprintf(w, " %d %s changed, %d %s(+), %d %s(-)\n",
+		diff.FilesChanged, plural(diff.FilesChanged, "file", "files"),
+		diff.Additions, plural(diff.Additions, "insertion", "insertions"),
+		diff.Deletions, plural(diff.Deletions, "deletion", "deletions"))
+}
+
+// writeColorizedDiff prints a unified diff, coloring headers, hunk markers,
+// and added and removed lines the way git does.
+func writeColorizedDiff(w io.Writer, patch string, useColor bool) {
+	if !useColor {
+		fmt.Fprint(w, patch)
+		return
+	}
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	scanner.Buffer(make([]byte, 64*1024), len(patch)+1)
+	inHunk := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHunk = false
+			line = colorize(line, ansiBold, true)
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+			line = colorize(line, ansiCyan, true)
+		case inHunk && strings.HasPrefix(line, "+"):
+			line = colorize(line, ansiGreen, true)
+		case inHunk && strings.HasPrefix(line, "-"):
+			line = colorize(line, ansiRed, true)
+		case !inHunk:
+			line = colorize(line, ansiBold, true)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func colorize(text, code string, enabled bool) string {
+	if !enabled || text == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+func shortCommit(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
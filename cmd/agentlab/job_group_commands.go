@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const jobGroupUsage = `Usage:
+  agentlab job run --repo <url> [--task <task>] [--profile <profile>] --matrix <axis>=<values> [--matrix ...] [--env KEY=VALUE ...] [--name <name>]
+  agentlab job group show <group_id>
+  agentlab job group artifacts <group_id>
+
+Matrix axes:
+  ref=<a>,<b>            One child per git ref
+  profile=<a>,<b>        One child per profile
+  task[:<name>]=<task>   One task variant per flag (named task-N when unnamed)
+  env[:<name>]=K=V[,K=V] One env variant per flag, merged over --env
+
+Every combination of the given axes becomes one child job (at most 64).
+
+Examples:
+  # Same task against two refs and two profiles (4 jobs):
+  agentlab job run --repo https://github.com/org/repo --task "run tests" \
+    --matrix ref=main,feature --matrix profile=small,large
+
+  # Compare two models:
+  agentlab job run --repo https://github.com/org/repo --task "fix lint" --profile yolo \
+    --matrix env:opus=MODEL=opus --matrix env:haiku=MODEL=haiku
+
+  # Show the pass/fail grid:
+  agentlab job group show jobgrp_0123456789abcdef
+`
+
+func printJobGroupUsage() {
+	fmt.Fprint(os.Stdout, jobGroupUsage)
+}
+
+// runJobGroupCommand handles job group subcommands.
+func runJobGroupCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printJobGroupUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("job group command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printJobGroupUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "show":
+		return runJobGroupShow(ctx, args[1:], base)
+	case "artifacts":
+		return runJobGroupArtifacts(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printJobGroupUsage()
+		}
+		return unknownSubcommandError("job group", args[0], []string{"show", "artifacts"})
+	}
+}
+
+func runJobGroupCreate(ctx context.Context, opts commonFlags, req jobGroupCreateRequest) error {
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/job-groups", req)
+	if err != nil {
+		return wrapUnknownProfileError(ctx, client, req.Base.Profile, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp jobGroupResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	printJobGroup(resp)
+	return nil
+}
+
+func runJobGroupShow(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("job group show")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printJobGroupUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	groupID, err := jobGroupIDArg(fs.Args(), opts.jsonOutput)
+	if err != nil {
+		return err
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	path, err := endpointPath("/v1/job-groups", groupID)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return wrapJobGroupNotFound(groupID, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp jobGroupResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	printJobGroup(resp)
+	return nil
+}
+
+func runJobGroupArtifacts(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("job group artifacts")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printJobGroupUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	groupID, err := jobGroupIDArg(fs.Args(), opts.jsonOutput)
+	if err != nil {
+		return err
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	path, err := endpointPath("/v1/job-groups", groupID, "artifacts")
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return wrapJobGroupNotFound(groupID, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp jobGroupArtifactsResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	for i, job := range resp.Jobs {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Job %s:\n", job.JobID)
+		if len(job.Artifacts) == 0 {
+			fmt.Println("  (no artifacts)")
+			continue
+		}
+		printArtifactsList(job.Artifacts)
+	}
+	return nil
+}
+
+func jobGroupIDArg(args []string, jsonOutput bool) (string, error) {
+	if len(args) < 1 {
+		if !jsonOutput {
+			printJobGroupUsage()
+		}
+		return "", fmt.Errorf("group_id is required")
+	}
+	groupID := strings.TrimSpace(args[0])
+	if groupID == "" {
+		return "", fmt.Errorf("group_id is required")
+	}
+	return groupID, nil
+}
+
+func wrapJobGroupNotFound(groupID string, err error) error {
+	if err == nil || !isNotFoundError(err, "job group") {
+		return err
+	}
+	return wrapCLIError(err, fmt.Sprintf("job group %s not found", groupID), "agentlab job group --help")
+}
+
+// parseJobMatrixFlags turns repeated --matrix values into matrix axes. ref and
+// profile take comma-separated lists; each task or env flag adds one variant.
+func parseJobMatrixFlags(values []string) (jobMatrix, error) {
+	var matrix jobMatrix
+	for _, raw := range values {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok {
+			return jobMatrix{}, fmt.Errorf("invalid --matrix %q (expected <axis>=<values>)", raw)
+		}
+		axis, name, _ := strings.Cut(strings.TrimSpace(key), ":")
+		name = strings.TrimSpace(name)
+		switch axis {
+		case "ref", "profile":
+			if name != "" {
+				return jobMatrixAxisError(raw, "%s values cannot be named")
+			}
+			for _, item := range strings.Split(value, ",") {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					return jobMatrixAxisError(raw, "empty %s value")
+				}
+				if axis == "ref" {
+					matrix.Ref = append(matrix.Ref, item)
+				} else {
+					matrix.Profile = append(matrix.Profile, item)
+				}
+			}
+		case "task":
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return jobMatrixAxisError(raw, "empty %s value")
+			}
+			matrix.Task = append(matrix.Task, jobMatrixTaskVariant{Name: name, Task: value})
+		case "env":
+			env, err := parseEnvAssignments(strings.Split(value, ","))
+			if err != nil {
+				return jobMatrix{}, fmt.Errorf("invalid --matrix %q: %w", raw, err)
+			}
+			if len(env) == 0 {
+				return jobMatrixAxisError(raw, "empty %s value")
+			}
+			matrix.Env = append(matrix.Env, jobMatrixEnvVariant{Name: name, Env: env})
+		default:
+			return jobMatrix{}, fmt.Errorf("invalid --matrix %q: unknown axis %q (expected ref, profile, task, or env)", raw, axis)
+		}
+	}
+	return matrix, nil
+}
+
+func jobMatrixAxisError(raw, format string) (jobMatrix, error) {
+	axis, _, _ := strings.Cut(raw, "=")
+	axis, _, _ = strings.Cut(axis, ":")
+	return jobMatrix{}, fmt.Errorf("invalid --matrix %q: "+format, raw, strings.TrimSpace(axis))
+}
+
+// parseEnvAssignments parses KEY=VALUE pairs. Blank entries are skipped.
+func parseEnvAssignments(values []string) (map[string]string, error) {
+	var env map[string]string
+	for _, raw := range values {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(raw, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid env %q (expected KEY=VALUE)", raw)
+		}
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+func printJobGroup(group jobGroupResponse) {
+	fmt.Printf("Group ID: %s\n", group.ID)
+	if strings.TrimSpace(group.Name) != "" {
+		fmt.Printf("Name: %s\n", group.Name)
+	}
+	fmt.Printf("Status: %s\n", group.Status)
+	fmt.Printf("Jobs: %d (%s)\n", len(group.Jobs), jobGroupCountsString(group.Counts))
+	fmt.Printf("Created At: %s\n", group.CreatedAt)
+	fmt.Println()
+	printJobGroupGrid(group)
+}
+
+// printJobGroupGrid renders a two-axis group as a pivot grid (first axis down,
+// second axis across) and any other shape as one row per child.
+func printJobGroupGrid(group jobGroupResponse) {
+	w := tabwriter.NewWriter(os.Stdout, 2, 8, 2, ' ', 0)
+	defer func() { _ = w.Flush() }()
+	if len(group.Axes) == 2 {
+		rowAxis, colAxis := group.Axes[0], group.Axes[1]
+		rows := orderedMatrixValues(group.Jobs, rowAxis)
+		cols := orderedMatrixValues(group.Jobs, colAxis)
+		cells := make(map[[2]string]string, len(group.Jobs))
+		for _, job := range group.Jobs {
+			cells[[2]string{job.Matrix[rowAxis], job.Matrix[colAxis]}] = jobGridMark(job.Status)
+		}
+		fmt.Fprintf(w, "%s \\ %s\t%s\n", strings.ToUpper(rowAxis), strings.ToUpper(colAxis), strings.Join(cols, "\t"))
+		for _, row := range rows {
+			marks := make([]string, 0, len(cols))
+			for _, col := range cols {
+				marks = append(marks, orDash(cells[[2]string{row, col}]))
+			}
+			fmt.Fprintf(w, "%s\t%s\n", row, strings.Join(marks, "\t"))
+		}
+		return
+	}
+	header := make([]string, 0, len(group.Axes)+2)
+	for _, axis := range group.Axes {
+		header = append(header, strings.ToUpper(axis))
+	}
+	header = append(header, "RESULT", "JOB")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, job := range group.Jobs {
+		row := make([]string, 0, len(header))
+		for _, axis := range group.Axes {
+			row = append(row, orDash(job.Matrix[axis]))
+		}
+		row = append(row, jobGridMark(job.Status), job.ID)
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+}
+
+// orderedMatrixValues returns the distinct values of one axis in the order the
+// matrix was expanded.
+func orderedMatrixValues(jobs []jobResponse, axis string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, job := range jobs {
+		value := job.Matrix[axis]
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		out = append(out, value)
+	}
+	return out
+}
+
+func jobGridMark(status string) string {
+	switch strings.ToUpper(strings.TrimSpace(status)) {
+	case "COMPLETED":
+		return "pass"
+	case "FAILED":
+		return "FAIL"
+	case "TIMEOUT":
+		return "timeout"
+	case "RUNNING":
+		return "running"
+	case "QUEUED":
+		return "queued"
+	default:
+		return strings.ToLower(status)
+	}
+}
+
+func jobGroupCountsString(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", strings.ToLower(key), counts[key]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -65,15 +65,18 @@ Usage:
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] schema
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] init [--apply] [--backend <backend>] [--smoke-test] [--assets <path>] [--force] [--control-port <port>] [--control-token <token>] [--rotate-control-token] [--tailscale-serve|--no-tailscale-serve]
   agentlab [--json] bootstrap --host <ssh_host> [--ssh-user <user>] [--ssh-port <port>] [--identity <path>] [--assets <path>] [--control-port <port>] [--control-token <token>] [--rotate-control-token] [--tailscale-serve|--no-tailscale-serve] [--tailscale-authkey <key>] [--tailscale-hostname <name>] [--tailscale-tailnet <name>] [--tailscale-api-key <key>] [--tailscale-oauth-client-id <id>] [--tailscale-oauth-client-secret <secret>] [--tailscale-oauth-scopes <scopes>] [--release-url <url>] [--agentlab-bin <path>] [--agentlabd-bin <path>] [--agentlab-url <url>] [--agentlabd-url <url>] [--force] [--keep-temp] [--verbose]
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job run --repo <url> --task <task> --profile <profile> [--ref <ref>] [--branch <branch>] [--mode <mode>] [--ttl <ttl>] [--keepalive] [--workspace <id|name|new:name>] [--workspace-create <name>] [--workspace-size <size>] [--workspace-storage <storage>] [--workspace-wait <duration>] [--stateful]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job run --repo <url> --task <task> --profile <profile> [--ref <ref>] [--branch <branch>] [--mode <mode>] [--ttl <ttl>] [--keepalive] [--workspace <id|name|new:name>] [--workspace-create <name>] [--workspace-size <size>] [--workspace-storage <storage>] [--workspace-wait <duration>] [--stateful] [--env KEY=VALUE...] [--matrix <axis>=<values>...] [--name <group>] [--depends-on <job_id>...] [--depends-condition <success|failure|always>] [--parent-artifacts] [--push-branch <branch>] [--open-pr] [--pr-base <branch>] [--pr-title <title>] [--push-integration <name>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job validate --repo <url> --task <task> --profile <profile> [--ref <ref>] [--branch <branch>] [--mode <mode>] [--ttl <ttl>] [--keepalive] [--workspace <id|name|new:name>] [--workspace-create <name>] [--workspace-size <size>] [--workspace-storage <storage>] [--workspace-wait <duration>] [--stateful]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job show <job_id> [--events-tail <n>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job artifacts <job_id>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job artifacts download <job_id> [--out <path>] [--path <path>] [--name <name>] [--latest] [--bundle]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job diff <job_id> [--stat] [--patch] [--color <auto|always|never>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job doctor <job_id> [--out <path>]
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox new [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] [--and-ssh] [--type <type>] [--image <image>] [--prompt <text>] [--tag <tag>...] (--profile <profile> | +mod [+mod...])
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox validate [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] (+mod [+mod...] | --profile <profile>)
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox list
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job group show <group_id>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] job group artifacts <group_id>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox new [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] [--and-ssh] [--type <type>] [--image <image>] [--prompt <text>] [--tag <tag>...] [--team <team>] (--profile <profile> | +mod [+mod...])
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox validate [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] [--tag <tag>...] (+mod [+mod...] | --profile <profile>)
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox list [--team <team>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox inventory
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox reconcile [--apply]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox show <vmid>
@@ -94,8 +97,8 @@ Usage:
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox exposed
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox unexpose <name>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] sandbox doctor <vmid> [--out <path>]
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace create --name <name> --size <size> [--storage <storage>]
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace list
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace create --name <name> --size <size> [--storage <storage>] [--team <team>]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace list [--team <team>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace check <workspace>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace fsck <workspace> [--repair]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace attach <workspace> <vmid>
@@ -106,8 +109,8 @@ Usage:
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace snapshot create <workspace> <name>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace snapshot list <workspace>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] workspace snapshot restore <workspace> <name>
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session create --name <name> --profile <profile> (--workspace <id|name|new:name> | --workspace-create <name>) [--workspace-size <size>] [--workspace-storage <storage>] [--branch <branch>]
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session list
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session create --name <name> --profile <profile> (--workspace <id|name|new:name> | --workspace-create <name>) [--workspace-size <size>] [--workspace-storage <storage>] [--branch <branch>] [--team <team>]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session list [--team <team>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session show <session>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session resume <session>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session stop <session>
@@ -115,7 +118,12 @@ Usage:
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session branch <branch> --profile <profile> [--workspace <id|name|new:name>] [--workspace-create <name>] [--workspace-size <size>] [--workspace-storage <storage>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] session doctor <session> [--out <path>]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] profile list
-  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] secrets <show|validate|set-env|set-git|add-ssh-key|remove-ssh-key|set-tailscale|clear-tailscale> [...]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] profile upgrade start [--to <version>] [--percent <n>] <profile>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] profile upgrade status <profile>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] profile upgrade percent --percent <n> <profile>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] profile upgrade promote [--force] <profile>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] profile upgrade rollback <profile>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] secrets <show|validate|set-env|set-git|add-ssh-key|remove-ssh-key|set-tailscale|clear-tailscale|set-policy|clear-policy|requests|approve|deny> [...]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] token <create|list|inspect> [...]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] integration <add|list|rm|status> [...]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] user <add|list|show|rm|key> [...]
@@ -123,8 +131,11 @@ Usage:
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] ssh <vmid> [--user <user>] [--port <port>] [--identity <path>] [--jump-host <host>] [--jump-user <user>] [--exec] [--no-start] [--wait] [-- <remote command>...]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] msg post (--job <id> | --workspace <id> | --session <id>) [--author <name>] [--kind <kind>] [--text <text>] [--payload <json>] [message...]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] msg tail (--job <id> | --workspace <id> | --session <id>) [--follow] [--tail <n>]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] msg inbox
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] msg reply [--author <name>] <id> <answer...>
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] logs <vmid> [--follow] [--tail <n>]
   agentlab connect --endpoint <url> --token <token> [--jump-host <host>] [--jump-user <user>]
+  agentlab connect --endpoint <url> --oidc [--jump-host <host>] [--jump-user <user>]
   agentlab disconnect
   agentlab defaults write <key> <value>
   agentlab defaults read <key>
@@ -132,6 +143,28 @@ Usage:
   agentlab defaults delete <key>
   agentlab version [--json]
   agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] pool status
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] admin reload
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] admin backup [--list]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] admin restore <backup>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] admin events export [--since TIME] [--output FILE]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] admin rotate-key
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] template build [--wait] <spec.yaml>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] template list [--name <name>]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] template show <name@version>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] report usage [--from TIME] [--to TIME] [--group-by owner|team|profile|tag] [--format table|csv|json]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] role create --permissions LIST [--description TEXT] <name>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] role rm <name>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] role ls
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] role bind (--user NAME | --team NAME) [--scope global|team:NAME|tag:TAG] <role>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] role unbind <binding-id>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] access ls [<resource>]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] access grant --user NAME --level read|operate|admin <resource>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] access revoke <grant-id>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] access team <resource> <team|->
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] approval ls [--status STATUS]
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] approval show <operation-id>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] approval approve <operation-id>
+  agentlab [--endpoint URL] [--token TOKEN] [--socket PATH] [--json] [--timeout DURATION] approval reject [--reason TEXT] <operation-id>
   agentlab completion <bash|zsh|fish>
 
 Global Flags:
@@ -349,11 +382,23 @@ func dispatch(ctx context.Context, args []string, base commonFlags) error {
 		return withDefaultNext(runCompletionCommand(args[1:], base), "agentlab completion --help")
 	case "pool":
 		return withDefaultNext(runPoolCommand(ctx, args[1:], base), "agentlab pool --help")
+	case "admin":
+		return withDefaultNext(runAdminCommand(ctx, args[1:], base), "agentlab admin --help")
+	case "template":
+		return withDefaultNext(runTemplateCommand(ctx, args[1:], base), "agentlab template --help")
+	case "report":
+		return withDefaultNext(runReportCommand(ctx, args[1:], base), "agentlab report --help")
+	case "role":
+		return withDefaultNext(runRoleCommand(ctx, args[1:], base), "agentlab role --help")
+	case "access":
+		return withDefaultNext(runAccessCommand(ctx, args[1:], base), "agentlab access --help")
+	case "approval":
+		return withDefaultNext(runApprovalCommand(ctx, args[1:], base), "agentlab approval --help")
 	default:
 		if !base.jsonOutput {
 			printUsage()
 		}
-		return unknownCommandError(args[0], []string{"new", "ls", "rm", "show", "start", "stop", "status", "schema", "init", "bootstrap", "job", "sandbox", "workspace", "session", "profile", "secrets", "msg", "ssh", "logs", "connect", "disconnect", "token", "integration", "user", "team", "defaults", "version", "completion", "pool"})
+		return unknownCommandError(args[0], []string{"new", "ls", "rm", "show", "start", "stop", "status", "schema", "init", "bootstrap", "job", "sandbox", "workspace", "session", "profile", "secrets", "msg", "ssh", "logs", "connect", "disconnect", "token", "integration", "user", "team", "defaults", "version", "completion", "pool", "admin", "template", "report", "role", "access", "approval"})
 	}
 }
 
@@ -420,7 +465,7 @@ func writeJSONError(w io.Writer, err error) {
 }
 
 func printJobUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab job <run|validate|show|artifacts|doctor> [flags]")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab job <run|validate|show|artifacts|diff|doctor|group> [flags]")
 }
 
 func printStatusUsage() {
@@ -440,7 +485,9 @@ func printBootstrapUsage() {
 }
 
 func printJobRunUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab job run --repo <url> --task <task> --profile <profile> [--ref <ref>] [--branch <branch>] [--mode <mode>] [--ttl <ttl>] [--keepalive] [--workspace <id|name|new:name>] [--workspace-create <name>] [--workspace-size <size>] [--workspace-storage <storage>] [--workspace-wait <duration>] [--stateful]")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab job run --repo <url> --task <task> --profile <profile> [--ref <ref>] [--branch <branch>] [--mode <mode>] [--ttl <ttl>] [--keepalive] [--workspace <id|name|new:name>] [--workspace-create <name>] [--workspace-size <size>] [--workspace-storage <storage>] [--workspace-wait <duration>] [--stateful] [--env KEY=VALUE...] [--matrix <axis>=<values>...] [--name <group>] [--depends-on <job_id>...] [--depends-condition <success|failure|always>] [--parent-artifacts] [--push-branch <branch>] [--open-pr] [--pr-base <branch>] [--pr-title <title>] [--push-integration <name>]")
+	fmt.Fprintln(os.Stdout, "Note: Jobs with --depends-on stay QUEUED until every parent finishes; --parent-artifacts downloads parent artifacts into the sandbox.")
+	fmt.Fprintln(os.Stdout, "Note: --push-branch/--open-pr push the job's commits with the repo's git-proxy integration when it completes; the PR URL is recorded in the job result.")
 }
 
 func printJobValidateUsage() {
@@ -462,27 +509,32 @@ func printJobArtifactsDownloadUsage() {
 	fmt.Fprintln(os.Stdout, "Note: By default, downloads the latest bundle (agentlab-artifacts.tar.gz) when available.")
 }
 
+func printJobDiffUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab job diff <job_id> [--stat] [--patch] [--color <auto|always|never>]")
+	fmt.Fprintln(os.Stdout, "Note: --patch prints the raw unified diff, e.g. agentlab job diff --patch <job_id> | git apply")
+}
+
 func printJobDoctorUsage() {
 	fmt.Fprintln(os.Stdout, "Usage: agentlab job doctor <job_id> [--out <path>]")
 	fmt.Fprintln(os.Stdout, "Note: --out may be a directory or file path.")
 }
 
 func printSandboxUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox <new|validate|list|inventory|reconcile|show|update|start|stop|pause|resume|revert|snapshot|destroy|lease|prune|expose|exposed|unexpose|doctor>")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox <new|validate|list|inventory|reconcile|show|update|start|stop|pause|resume|revert|snapshot|destroy|lease|prune|expose|exposed|unexpose|doctor|recordings>")
 }
 
 func printSandboxNewUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox new [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] [--and-ssh] (--profile <profile> | +mod [+mod...])")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox new [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] [--and-ssh] [--team <team>] (--profile <profile> | +mod [+mod...])")
 	fmt.Fprintln(os.Stdout, "Note: Modifiers are resolved by sorting and joining with '-' (e.g., +secure +small -> secure-small).")
 }
 
 func printSandboxValidateUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox validate [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] (--profile <profile> | +mod [+mod...])")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox validate [--name <name>] [--ttl <ttl>] [--keepalive] [--workspace <id>] [--vmid <vmid>] [--job <id>] [--tag <tag>...] (--profile <profile> | +mod [+mod...])")
 	fmt.Fprintln(os.Stdout, "Note: Validation returns plan details and exits with non-zero status when preflight validation fails.")
 }
 
 func printSandboxListUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox list")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox list [--team <team>]")
 	fmt.Fprintln(os.Stdout, "Note: Lists AgentLab database records only. Use sandbox inventory for live Proxmox/Tailscale state.")
 }
 
@@ -547,6 +599,20 @@ func printSandboxSnapshotRestoreUsage() {
 	fmt.Fprintln(os.Stdout, "Note: By default, restores require the sandbox to be stopped and workspace detached.")
 }
 
+func printSandboxRecordingsUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox recordings <ls|play>")
+}
+
+func printSandboxRecordingsListUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox recordings ls <vmid>")
+	fmt.Fprintln(os.Stdout, "Note: Lists SSH gateway session recordings; they remain until artifact retention removes them.")
+}
+
+func printSandboxRecordingsPlayUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox recordings play [--speed <factor>] [--max-idle <duration>] [--out <path>] <vmid> <id>")
+	fmt.Fprintln(os.Stdout, "Note: Replays the recording in this terminal; --out saves the asciicast file instead.")
+}
+
 func printSandboxDestroyUsage() {
 	fmt.Fprintln(os.Stdout, "Usage: agentlab sandbox destroy [--force] <vmid>")
 	fmt.Fprintln(os.Stdout, "Note: --force bypasses state restrictions and destroys sandbox in any state")
@@ -589,11 +655,11 @@ func printWorkspaceUsage() {
 }
 
 func printWorkspaceCreateUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab workspace create --name <name> --size <size> [--storage <storage>]")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab workspace create --name <name> --size <size> [--storage <storage>] [--team <team>]")
 }
 
 func printWorkspaceListUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab workspace list")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab workspace list [--team <team>]")
 }
 
 func printWorkspaceCheckUsage() {
@@ -650,11 +716,11 @@ func printSessionUsage() {
 }
 
 func printSessionCreateUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab session create --name <name> --profile <profile> (--workspace <id|name|new:name> | --workspace-create <name>) [--workspace-size <size>] [--workspace-storage <storage>] [--branch <branch>]")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab session create --name <name> --profile <profile> (--workspace <id|name|new:name> | --workspace-create <name>) [--workspace-size <size>] [--workspace-storage <storage>] [--branch <branch>] [--team <team>]")
 }
 
 func printSessionListUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab session list")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab session list [--team <team>]")
 }
 
 func printSessionShowUsage() {
@@ -683,7 +749,7 @@ func printSessionDoctorUsage() {
 }
 
 func printProfileUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab profile <list>")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab profile <list|upgrade>")
 }
 
 func printProfileListUsage() {
@@ -691,7 +757,7 @@ func printProfileListUsage() {
 }
 
 func printMsgUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab msg <post|tail>")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab msg <post|tail|inbox|reply>")
 }
 
 func printMsgPostUsage() {
@@ -704,6 +770,16 @@ func printMsgTailUsage() {
 	fmt.Fprintln(os.Stdout, "Note: --json outputs one JSON object per line.")
 }
 
+func printMsgInboxUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab msg inbox")
+	fmt.Fprintln(os.Stdout, "Note: Lists questions agents are waiting on, oldest first.")
+}
+
+func printMsgReplyUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab msg reply [--author <name>] <id> <answer...>")
+	fmt.Fprintln(os.Stdout, "Note: Trailing arguments are joined as the answer. A question with options only accepts one of them.")
+}
+
 func printLogsUsage() {
 	fmt.Fprintln(os.Stdout, "Usage: agentlab logs <vmid> [--follow] [--tail <n>]")
 	fmt.Fprintln(os.Stdout, "Note: --json outputs one JSON object per line.")
@@ -711,6 +787,8 @@ func printLogsUsage() {
 
 func printConnectUsage() {
 	fmt.Fprintln(os.Stdout, "Usage: agentlab connect --endpoint <url> --token <token> [--jump-host <host>] [--jump-user <user>]")
+	fmt.Fprintln(os.Stdout, "       agentlab connect --endpoint <url> --oidc [--jump-host <host>] [--jump-user <user>]")
+	fmt.Fprintln(os.Stdout, "Note: --oidc signs in through the control plane's identity provider (device code flow) and stores a short-lived token.")
 }
 
 func printDisconnectUsage() {
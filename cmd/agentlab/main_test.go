@@ -709,12 +709,12 @@ func TestUsagePrints(t *testing.T) {
 
 	t.Run("printJobUsage outputs job usage", func(t *testing.T) {
 		output := CaptureOutput(printJobUsage)
-		assert.Contains(t, output, "job <run|validate|show|artifacts|doctor>")
+		assert.Contains(t, output, "job <run|validate|show|artifacts|diff|doctor|group>")
 	})
 
 	t.Run("printSandboxUsage outputs sandbox usage", func(t *testing.T) {
 		output := CaptureOutput(printSandboxUsage)
-		assert.Contains(t, output, "sandbox <new|validate|list|inventory|reconcile|show|update|start|stop|pause|resume|revert|snapshot|destroy|lease|prune|expose|exposed|unexpose|doctor>")
+		assert.Contains(t, output, "sandbox <new|validate|list|inventory|reconcile|show|update|start|stop|pause|resume|revert|snapshot|destroy|lease|prune|expose|exposed|unexpose|doctor|recordings>")
 	})
 
 	t.Run("printWorkspaceUsage outputs workspace usage", func(t *testing.T) {
@@ -785,13 +785,13 @@ func TestGoldenFileUsageOutput(t *testing.T) {
 func TestGoldenFileJobUsageOutput(t *testing.T) {
 	got := CaptureOutput(printJobUsage)
 
-	assert.Contains(t, got, "agentlab job <run|validate|show|artifacts|doctor>")
+	assert.Contains(t, got, "agentlab job <run|validate|show|artifacts|diff|doctor|group>")
 }
 
 func TestGoldenFileSandboxUsageOutput(t *testing.T) {
 	got := CaptureOutput(printSandboxUsage)
 
-	assert.Contains(t, got, "agentlab sandbox <new|validate|list|inventory|reconcile|show|update|start|stop|pause|resume|revert|snapshot|destroy|lease|prune|expose|exposed|unexpose|doctor>")
+	assert.Contains(t, got, "agentlab sandbox <new|validate|list|inventory|reconcile|show|update|start|stop|pause|resume|revert|snapshot|destroy|lease|prune|expose|exposed|unexpose|doctor|recordings>")
 }
 
 func TestGoldenFileWorkspaceUsageOutput(t *testing.T) {
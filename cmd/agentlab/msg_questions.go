@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// questionOptions returns the answers a question accepts, or nil when any
+// answer is allowed.
+func questionOptions(msg messageResponse) []string {
+	var payload struct {
+		Options []string `json:"options"`
+	}
+	if len(msg.Payload) == 0 || json.Unmarshal(msg.Payload, &payload) != nil {
+		return nil
+	}
+	return payload.Options
+}
+
+// runMsgInbox lists agent questions waiting for an answer.
+func runMsgInbox(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("msg inbox")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printMsgInboxUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.doJSON(ctx, http.MethodGet, "/v1/messages/questions", nil)
+	if err != nil {
+		return fmt.Errorf("list questions: %w", err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, data)
+	}
+	var resp messagesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	printQuestionInbox(os.Stdout, resp.Messages)
+	return nil
+}
+
+func printQuestionInbox(w io.Writer, questions []messageResponse) {
+	if len(questions) == 0 {
+		fmt.Fprintln(w, "No open questions")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tFROM\tSCOPE\tASKED\tQUESTION\tOPTIONS")
+	for _, q := range questions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			q.ID, orDash(q.Author), q.ScopeType+":"+q.ScopeID, orDash(q.Timestamp), q.Text, orDash(strings.Join(questionOptions(q), ", ")))
+	}
+	_ = tw.Flush()
+}
+
+// runMsgReply answers an agent's question; the agent's long-poll returns
+// the answer.
+func runMsgReply(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("msg reply")
+	opts := base
+	opts.bind(fs)
+	var author string
+	help := bindHelpFlag(fs)
+	fs.StringVar(&author, "author", "", "answer author (default: your identity)")
+	if err := parseFlags(fs, args, printMsgReplyUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		if !opts.jsonOutput {
+			printMsgReplyUsage()
+		}
+		return newUsageError(fmt.Errorf("question id and answer are required"), false)
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(fs.Arg(0)), 10, 64)
+	if err != nil || id <= 0 {
+		return newUsageError(fmt.Errorf("invalid question id %q", fs.Arg(0)), true)
+	}
+	answer := strings.TrimSpace(strings.Join(fs.Args()[1:], " "))
+	if answer == "" {
+		return newUsageError(fmt.Errorf("answer is required"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	body := map[string]string{"text": answer}
+	if author = strings.TrimSpace(author); author != "" {
+		body["author"] = author
+	}
+	data, err := client.doJSON(ctx, http.MethodPost, "/v1/messages/"+strconv.FormatInt(id, 10)+"/reply", body)
+	if err != nil {
+		return fmt.Errorf("reply to question %d: %w", id, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, data)
+	}
+	var reply messageResponse
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Answered question %d: %s\n", id, reply.Text)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgInboxAndReplyCommands(t *testing.T) {
+	var replyPath string
+	var replyBody map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/messages/questions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, messagesResponse{Messages: []messageResponse{{
+			ID:        42,
+			Timestamp: "2026-10-18T12:00:00Z",
+			ScopeType: "job",
+			ScopeID:   "job-1",
+			Author:    "sandbox:1001",
+			Kind:      "question",
+			Text:      "Deploy to prod?",
+			Payload:   json.RawMessage(`{"options":["yes","no"]}`),
+		}}})
+	})
+	mux.HandleFunc("/v1/messages/", func(w http.ResponseWriter, r *http.Request) {
+		replyPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&replyBody); err != nil {
+			t.Fatalf("decode reply body: %v", err)
+		}
+		writeJSON(t, w, http.StatusCreated, messageResponse{ID: 43, Kind: "answer", Text: replyBody["text"], ReplyTo: 42})
+	})
+	socketPath := startUnixHTTPServer(t, mux)
+	base := commonFlags{socketPath: socketPath, timeout: time.Second}
+
+	out := captureStdout(t, func() {
+		if err := runMsgCommand(context.Background(), []string{"inbox"}, base); err != nil {
+			t.Fatalf("msg inbox: %v", err)
+		}
+	})
+	for _, want := range []string{"ID", "42", "sandbox:1001", "job:job-1", "Deploy to prod?", "yes, no"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("inbox output missing %q:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() {
+		if err := runMsgCommand(context.Background(), []string{"reply", "--author", "alice", "42", "ship", "it"}, base); err != nil {
+			t.Fatalf("msg reply: %v", err)
+		}
+	})
+	if replyPath != "/v1/messages/42/reply" || replyBody["text"] != "ship it" || replyBody["author"] != "alice" {
+		t.Fatalf("reply request = %s %v", replyPath, replyBody)
+	}
+	if !strings.Contains(out, "Answered question 42: ship it") {
+		t.Fatalf("unexpected reply output %q", out)
+	}
+
+	if err := runMsgCommand(context.Background(), []string{"reply", "--json", "42"}, base); err == nil {
+		t.Fatal("reply without an answer: expected usage error")
+	}
+	if err := runMsgCommand(context.Background(), []string{"reply", "--json", "abc", "yes"}, base); err == nil {
+		t.Fatal("reply with a non-numeric id: expected usage error")
+	}
+}
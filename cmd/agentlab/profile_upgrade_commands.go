@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// templateVersionStatsResponse mirrors the per-version SLO numbers in a
+// profile upgrade response.
+type templateVersionStatsResponse struct {
+	Version        string  `json:"version"`
+	VMID           int     `json:"vmid"`
+	Sandboxes      int     `json:"sandboxes"`
+	Ready          int     `json:"ready"`
+	ReadyP50MS     int64   `json:"ready_p50_ms"`
+	ReadyP95MS     int64   `json:"ready_p95_ms"`
+	Jobs           int     `json:"jobs"`
+	JobsFailed     int     `json:"jobs_failed"`
+	JobFailureRate float64 `json:"job_failure_rate"`
+}
+
+// templateRolloutResponse mirrors /v1/profiles/{name}/upgrade responses.
+type templateRolloutResponse struct {
+	Profile    string                       `json:"profile"`
+	Template   string                       `json:"template"`
+	From       string                       `json:"from"`
+	To         string                       `json:"to"`
+	Percent    int                          `json:"percent"`
+	Status     string                       `json:"status"`
+	StartedBy  string                       `json:"started_by,omitempty"`
+	CreatedAt  string                       `json:"created_at"`
+	UpdatedAt  string                       `json:"updated_at"`
+	FinishedAt string                       `json:"finished_at,omitempty"`
+	Baseline   templateVersionStatsResponse `json:"baseline"`
+	Canary     templateVersionStatsResponse `json:"canary"`
+	Verdict    string                       `json:"verdict"`
+	Reasons    []string                     `json:"reasons,omitempty"`
+}
+
+func runProfileUpgrade(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printProfileUpgradeUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("profile upgrade command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printProfileUpgradeUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "start":
+		return runProfileUpgradeStart(ctx, args[1:], base)
+	case "status":
+		return runProfileUpgradeStatus(ctx, args[1:], base)
+	case "percent":
+		return runProfileUpgradePercent(ctx, args[1:], base)
+	case "promote":
+		return runProfileUpgradePromote(ctx, args[1:], base)
+	case "rollback":
+		return runProfileUpgradeRollback(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printProfileUpgradeUsage()
+		}
+		return unknownSubcommandError("profile upgrade", args[0], []string{"start", "status", "percent", "promote", "rollback"})
+	}
+}
+
+func printProfileUpgradeUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab profile upgrade <command>
+
+Canary a new template version for a profile. A share of new sandboxes and
+jobs provisions from the new version while agentlabd compares readiness
+and job failures against the current one.
+
+Commands:
+  start       Start routing a percentage of provisioning to a new version
+  status      Compare the baseline and canary versions
+  percent     Change the share routed to the canary
+  promote     Route everything to the canary version
+  rollback    Route everything back to the baseline version
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printProfileUpgradeStartUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab profile upgrade start [--to <version>] [--percent <n>] <profile>
+
+The profile must pin its template as name@version. Without --to, the newest
+ready version of that template is used.
+
+Flags:
+  --to         Template version to canary
+  --percent    Share of new sandboxes and jobs to route to it (default 10)
+  --json       Output JSON
+`)
+}
+
+func printProfileUpgradeStatusUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab profile upgrade status <profile>
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printProfileUpgradePercentUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab profile upgrade percent --percent <n> <profile>
+
+Flags:
+  --percent    Share of new sandboxes and jobs to route to the canary (0-100)
+  --json       Output JSON
+`)
+}
+
+func printProfileUpgradePromoteUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab profile upgrade promote [--force] <profile>
+
+Promotion is refused while the canary is worse than the baseline.
+
+Flags:
+  --force    Promote even if the canary regressed
+  --json     Output JSON
+`)
+}
+
+func printProfileUpgradeRollbackUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab profile upgrade rollback <profile>
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func runProfileUpgradeStart(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("profile upgrade start")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var to string
+	var percent int
+	fs.StringVar(&to, "to", "", "template version to canary")
+	fs.IntVar(&percent, "percent", 0, "share of provisioning routed to the canary")
+	if err := parseFlags(fs, args, printProfileUpgradeStartUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	profile, err := profileUpgradeArg(fs.Args(), "start")
+	if err != nil {
+		return err
+	}
+	if percent < 0 || percent > 100 {
+		return newUsageError(fmt.Errorf("--percent must be between 0 and 100"), true)
+	}
+	req := map[string]any{"to": strings.TrimSpace(to)}
+	if percent > 0 {
+		req["percent"] = percent
+	}
+	return doProfileUpgrade(ctx, opts, http.MethodPost, profileUpgradePath(profile, ""), req)
+}
+
+func runProfileUpgradeStatus(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("profile upgrade status")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printProfileUpgradeStatusUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	profile, err := profileUpgradeArg(fs.Args(), "status")
+	if err != nil {
+		return err
+	}
+	return doProfileUpgrade(ctx, opts, http.MethodGet, profileUpgradePath(profile, ""), nil)
+}
+
+func runProfileUpgradePercent(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("profile upgrade percent")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	percent := -1
+	fs.IntVar(&percent, "percent", -1, "share of provisioning routed to the canary")
+	if err := parseFlags(fs, args, printProfileUpgradePercentUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	profile, err := profileUpgradeArg(fs.Args(), "percent")
+	if err != nil {
+		return err
+	}
+	if percent < 0 || percent > 100 {
+		return newUsageError(fmt.Errorf("--percent is required and must be between 0 and 100"), true)
+	}
+	return doProfileUpgrade(ctx, opts, http.MethodPost, profileUpgradePath(profile, "percent"), map[string]int{"percent": percent})
+}
+
+func runProfileUpgradePromote(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("profile upgrade promote")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var force bool
+	fs.BoolVar(&force, "force", false, "promote even if the canary regressed")
+	if err := parseFlags(fs, args, printProfileUpgradePromoteUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	profile, err := profileUpgradeArg(fs.Args(), "promote")
+	if err != nil {
+		return err
+	}
+	return doProfileUpgrade(ctx, opts, http.MethodPost, profileUpgradePath(profile, "promote"), map[string]bool{"force": force})
+}
+
+func runProfileUpgradeRollback(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("profile upgrade rollback")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printProfileUpgradeRollbackUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	profile, err := profileUpgradeArg(fs.Args(), "rollback")
+	if err != nil {
+		return err
+	}
+	return doProfileUpgrade(ctx, opts, http.MethodPost, profileUpgradePath(profile, "rollback"), nil)
+}
+
+func profileUpgradeArg(args []string, command string) (string, error) {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return "", newUsageError(errors.New("profile upgrade "+command+" requires exactly one profile"), true)
+	}
+	return strings.TrimSpace(args[0]), nil
+}
+
+func profileUpgradePath(profile, action string) string {
+	path := "/v1/profiles/" + url.PathEscape(profile) + "/upgrade"
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+func doProfileUpgrade(ctx context.Context, opts commonFlags, method, path string, req any) error {
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, method, path, req)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp templateRolloutResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	return printTemplateRollout(resp)
+}
+
+func printTemplateRollout(rollout templateRolloutResponse) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Profile:\t%s\n", rollout.Profile)
+	fmt.Fprintf(w, "Upgrade:\t%s -> %s\n", rollout.From, rollout.To)
+	fmt.Fprintf(w, "Status:\t%s\n", rollout.Status)
+	fmt.Fprintf(w, "Canary share:\t%d%%\n", rollout.Percent)
+	if rollout.StartedBy != "" {
+		fmt.Fprintf(w, "Started by:\t%s\n", rollout.StartedBy)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", rollout.CreatedAt)
+	if rollout.FinishedAt != "" {
+		fmt.Fprintf(w, "Finished:\t%s\n", rollout.FinishedAt)
+	}
+	fmt.Fprintf(w, "Verdict:\t%s\n", rollout.Verdict)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout)
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\tVERSION\tVMID\tSANDBOXES\tREADY\tREADY P50\tREADY P95\tJOBS\tFAILED\tFAILURE RATE")
+	for _, row := range []struct {
+		label string
+		stats templateVersionStatsResponse
+	}{
+		{label: "baseline", stats: rollout.Baseline},
+		{label: "canary", stats: rollout.Canary},
+	} {
+		s := row.stats
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%dms\t%dms\t%d\t%d\t%.1f%%\n",
+			row.label, s.Version, s.VMID, s.Sandboxes, s.Ready, s.ReadyP50MS, s.ReadyP95MS, s.Jobs, s.JobsFailed, s.JobFailureRate*100)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, reason := range rollout.Reasons {
+		fmt.Fprintf(os.Stdout, "- %s\n", reason)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/recording"
+)
+
+// defaultRecordingMaxIdle caps pauses during playback so an idle session
+// does not replay as minutes of nothing.
+const defaultRecordingMaxIdle = 2 * time.Second
+
+func runSandboxRecordingsCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printSandboxRecordingsUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("sandbox recordings command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printSandboxRecordingsUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "ls", "list":
+		return runSandboxRecordingsList(ctx, args[1:], base)
+	case "play":
+		return runSandboxRecordingsPlay(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printSandboxRecordingsUsage()
+		}
+		return unknownSubcommandError("sandbox recordings", args[0], []string{"ls", "play"})
+	}
+}
+
+func runSandboxRecordingsList(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("sandbox recordings ls")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printSandboxRecordingsListUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		if !opts.jsonOutput {
+			printSandboxRecordingsListUsage()
+		}
+		return fmt.Errorf("vmid is required")
+	}
+	vmid, err := parseVMID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	path, err := endpointPath("/v1/sandboxes", strconv.Itoa(vmid), "recordings")
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return wrapSandboxNotFound(ctx, client, vmid, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp sandboxRecordingsResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	printSandboxRecordings(os.Stdout, resp.Recordings)
+	return nil
+}
+
+func printSandboxRecordings(w io.Writer, recordings []sandboxRecording) {
+	if len(recordings) == 0 {
+		fmt.Fprintln(w, "No recordings")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSER\tCREATED\tSIZE\tNAME")
+	for _, rec := range recordings {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", rec.ID, orDash(rec.User), orDash(rec.CreatedAt), rec.SizeBytes, rec.Name)
+	}
+	_ = tw.Flush()
+}
+
+// runSandboxRecordingsPlay replays a recording to stdout with its original
+// timing, or saves the raw asciicast file with --out for other players.
+func runSandboxRecordingsPlay(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("sandbox recordings play")
+	opts := base
+	opts.bind(fs)
+	var out string
+	var speed float64
+	var maxIdle time.Duration
+	help := bindHelpFlag(fs)
+	fs.StringVar(&out, "out", "", "save the asciicast file to this path or directory instead of playing it")
+	fs.Float64Var(&speed, "speed", 1, "playback speed factor")
+	fs.DurationVar(&maxIdle, "max-idle", defaultRecordingMaxIdle, "longest pause between events (0 keeps recorded pauses)")
+	if err := parseFlags(fs, args, printSandboxRecordingsPlayUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		if !opts.jsonOutput {
+			printSandboxRecordingsPlayUsage()
+		}
+		return fmt.Errorf("vmid and recording id are required")
+	}
+	vmid, err := parseVMID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(fs.Arg(1)), 10, 64)
+	if err != nil || id <= 0 {
+		return newUsageError(fmt.Errorf("invalid recording id %q", fs.Arg(1)), true)
+	}
+	if speed <= 0 {
+		return newUsageError(fmt.Errorf("speed must be positive"), true)
+	}
+	if maxIdle < 0 {
+		return newUsageError(fmt.Errorf("max-idle must not be negative"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	path, err := endpointPath("/v1/sandboxes", strconv.Itoa(vmid), "recordings", strconv.FormatInt(id, 10))
+	if err != nil {
+		return err
+	}
+	// Playback takes as long as the session did, so the client timeout must
+	// not apply to reading the body.
+	resp, err := client.doStream(ctx, path)
+	if err != nil {
+		return wrapSandboxNotFound(ctx, client, vmid, err)
+	}
+	defer resp.Body.Close()
+
+	if strings.TrimSpace(out) == "" {
+		return recording.Play(ctx, resp.Body, os.Stdout, recording.PlayOptions{Speed: speed, MaxIdle: maxIdle})
+	}
+	targetPath, err := resolveArtifactOutPath(out, fmt.Sprintf("sandbox-%d-recording-%d.cast", vmid, id))
+	if err != nil {
+		return err
+	}
+	outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+	if _, err := io.Copy(outFile, resp.Body); err != nil {
+		return err
+	}
+	if err := outFile.Sync(); err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		data, err := json.Marshal(map[string]any{"vmid": vmid, "id": id, "out": targetPath})
+		if err != nil {
+			return err
+		}
+		_, _ = os.Stdout.Write(append(data, '\n'))
+		return nil
+	}
+	fmt.Printf("saved recording %d to %s\n", id, targetPath)
+	return nil
+}
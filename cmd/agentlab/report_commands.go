@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// usageReportRow mirrors one row of the daemon's /v1/reports/usage response.
+type usageReportRow struct {
+	Group          string  `json:"group"`
+	Sandboxes      int     `json:"sandboxes"`
+	Hours          float64 `json:"hours"`
+	CoreHours      float64 `json:"core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	LLMRequests    int64   `json:"llm_requests"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	ComputeCost    float64 `json:"compute_cost"`
+	TokenCost      float64 `json:"token_cost"`
+	TotalCost      float64 `json:"total_cost"`
+}
+
+type usageReportResponse struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	GroupBy  string           `json:"group_by"`
+	Currency string           `json:"currency"`
+	Rows     []usageReportRow `json:"rows"`
+	Total    usageReportRow   `json:"total"`
+}
+
+func runReportCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printReportUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("report command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printReportUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "usage":
+		return runReportUsage(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printReportUsage()
+		}
+		return unknownSubcommandError("report", args[0], []string{"usage"})
+	}
+}
+
+func printReportUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab report <command>
+
+Commands:
+  usage    Report resource-hours, LLM tokens and cost per owner, team, profile or tag
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printReportUsageUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab report usage [--from TIME] [--to TIME] [--group-by owner|team|profile|tag] [--format table|csv|json]
+
+Report the resource-hours sandboxes spent READY or RUNNING, the LLM tokens
+they used through the credential proxy, and their cost at the unit prices
+configured on agentlabd. A sandbox counts toward every team its owner is in
+and every tag it carries, so team and tag rows can add up to more than the
+total.
+
+Flags:
+  --from        Start of the report, RFC3339 or YYYY-MM-DD (default 30 days before --to)
+  --to          End of the report, RFC3339 or YYYY-MM-DD (default now)
+  --group-by    owner, team, profile or tag (default owner)
+  --format      table, csv or json (default table; --json implies json)
+  --json        Output JSON
+`)
+}
+
+func runReportUsage(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("report usage")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var from, to, groupBy, format string
+	fs.StringVar(&from, "from", "", "report start (RFC3339 or YYYY-MM-DD)")
+	fs.StringVar(&to, "to", "", "report end (RFC3339 or YYYY-MM-DD)")
+	fs.StringVar(&groupBy, "group-by", "owner", "owner, team, profile or tag")
+	fs.StringVar(&format, "format", "table", "table, csv or json")
+	if err := parseFlags(fs, args, printReportUsageUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if opts.jsonOutput {
+		format = "json"
+	}
+	switch format {
+	case "table", "csv", "json":
+	default:
+		return newUsageError(fmt.Errorf("--format must be table, csv or json"), true)
+	}
+	query := url.Values{}
+	query.Set("group_by", strings.TrimSpace(groupBy))
+	if from = strings.TrimSpace(from); from != "" {
+		query.Set("from", from)
+	}
+	if to = strings.TrimSpace(to); to != "" {
+		query.Set("to", to)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/reports/usage?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp usageReportResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	if format == "csv" {
+		return writeUsageReportCSV(os.Stdout, resp)
+	}
+	return writeUsageReportTable(os.Stdout, resp)
+}
+
+// writeUsageReportCSV writes one row per group followed by the total. Costs
+// keep full precision so spreadsheets can round them.
+func writeUsageReportCSV(out io.Writer, resp usageReportResponse) error {
+	w := csv.NewWriter(out)
+	header := []string{resp.GroupBy, "sandboxes", "hours", "core_hours", "memory_gib_hours", "llm_requests",
+		"input_tokens", "output_tokens", "compute_cost", "token_cost", "total_cost", "currency"}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, row := range append(resp.Rows, resp.Total) {
+		record := []string{
+			row.Group,
+			strconv.Itoa(row.Sandboxes),
+			formatFloat(row.Hours),
+			formatFloat(row.CoreHours),
+			formatFloat(row.MemoryGiBHours),
+			strconv.FormatInt(row.LLMRequests, 10),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			formatFloat(row.ComputeCost),
+			formatFloat(row.TokenCost),
+			formatFloat(row.TotalCost),
+			resp.Currency,
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeUsageReportTable(out io.Writer, resp usageReportResponse) error {
+	fmt.Fprintf(out, "Usage %s to %s by %s\n", resp.From, resp.To, resp.GroupBy)
+	if len(resp.Rows) == 0 {
+		fmt.Fprintln(out, "No usage in this period.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tSANDBOXES\tCORE-H\tMEM GIB-H\tIN TOKENS\tOUT TOKENS\tCOST (%s)\n", strings.ToUpper(resp.GroupBy), resp.Currency)
+	printRow := func(group string, row usageReportRow) {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%d\t%d\t%.2f\n", group, row.Sandboxes, row.CoreHours, row.MemoryGiBHours,
+			row.InputTokens, row.OutputTokens, row.TotalCost)
+	}
+	for _, row := range resp.Rows {
+		printRow(row.Group, row)
+	}
+	printRow("TOTAL", resp.Total)
+	return w.Flush()
+}
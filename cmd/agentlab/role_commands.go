@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// customRole mirrors one role of the daemon's /v1/roles response.
+type customRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// roleBinding mirrors one binding of the daemon's /v1/roles response.
+type roleBinding struct {
+	ID      int64  `json:"id"`
+	Role    string `json:"role"`
+	Subject string `json:"subject"`
+	Scope   string `json:"scope"`
+}
+
+type roleListResponse struct {
+	Roles    []customRole  `json:"roles"`
+	Bindings []roleBinding `json:"bindings"`
+}
+
+func runRoleCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printRoleUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("role command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printRoleUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "create":
+		return runRoleCreate(ctx, args[1:], base)
+	case "rm":
+		return runRoleRemove(ctx, args[1:], base)
+	case "ls":
+		return runRoleList(ctx, args[1:], base)
+	case "bind":
+		return runRoleBind(ctx, args[1:], base)
+	case "unbind":
+		return runRoleUnbind(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printRoleUsage()
+		}
+		return unknownSubcommandError("role", args[0], roleSubcommands)
+	}
+}
+
+func printRoleUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab role <command>
+
+Commands:
+  create    Create a custom role from permissions in the catalog
+  rm        Remove a custom role and its bindings
+  ls        List custom roles and bindings
+  bind      Grant a role to a user or team at a scope
+  unbind    Remove a role binding
+
+Custom roles confine registered non-admin users when rbac_enabled is set on
+agentlabd. Permissions are catalog names (sandbox.start) or namespaces
+(sandbox).
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printRoleCreateUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab role create --permissions LIST [--description TEXT] <name>
+
+Flags:
+  --permissions    Comma-separated permissions or namespaces, e.g. sandbox,workspace.read
+  --description    Optional description
+  --json           Output JSON
+`)
+}
+
+func printRoleRemoveUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab role rm <name>")
+}
+
+func printRoleListUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab role ls [--json]")
+}
+
+func printRoleBindUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab role bind (--user NAME | --team NAME) [--scope SCOPE] <role>
+
+Scopes:
+  global       Everything, including global resources (default)
+  team:NAME    Sandboxes owned by members of team NAME
+  tag:TAG      Sandboxes carrying tag TAG
+
+Flags:
+  --user     Bind to a user
+  --team     Bind to every member of a team
+  --scope    global, team:NAME or tag:TAG
+  --json     Output JSON
+`)
+}
+
+func printRoleUnbindUsage() {
+	_, _ = fmt.Fprintln(os.Stderr, "Usage: agentlab role unbind <binding-id>")
+}
+
+func runRoleCreate(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("role create")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var permissions, description string
+	fs.StringVar(&permissions, "permissions", "", "comma-separated permissions")
+	fs.StringVar(&description, "description", "", "role description")
+	if err := parseFlags(fs, args, printRoleCreateUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("role name is required"), true)
+	}
+	var perms []string
+	for _, perm := range strings.Split(permissions, ",") {
+		if perm = strings.TrimSpace(perm); perm != "" {
+			perms = append(perms, perm)
+		}
+	}
+	if len(perms) == 0 {
+		return newUsageError(fmt.Errorf("--permissions is required"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/roles", map[string]any{
+		"name":        fs.Arg(0),
+		"description": description,
+		"permissions": perms,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var role customRole
+	if err := json.Unmarshal(payload, &role); err != nil {
+		return err
+	}
+	fmt.Printf("Role %s created (%s)\n", role.Name, strings.Join(role.Permissions, ","))
+	return nil
+}
+
+func runRoleRemove(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("role rm")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printRoleRemoveUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("role name is required"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodDelete, "/v1/roles/"+url.PathEscape(fs.Arg(0)), nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	fmt.Printf("Role %s removed\n", fs.Arg(0))
+	return nil
+}
+
+func runRoleList(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("role ls")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printRoleListUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/roles", nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp roleListResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	return writeRoleList(os.Stdout, resp)
+}
+
+func writeRoleList(out io.Writer, resp roleListResponse) error {
+	if len(resp.Roles) == 0 {
+		fmt.Fprintln(out, "No custom roles.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tPERMISSIONS\tDESCRIPTION")
+	for _, role := range resp.Roles {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", role.Name, strings.Join(role.Permissions, ","), role.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if len(resp.Bindings) == 0 {
+		fmt.Fprintln(out, "No role bindings.")
+		return nil
+	}
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tROLE\tSUBJECT\tSCOPE")
+	for _, b := range resp.Bindings {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", b.ID, b.Role, b.Subject, b.Scope)
+	}
+	return w.Flush()
+}
+
+func runRoleBind(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("role bind")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var userName, teamName, scope string
+	fs.StringVar(&userName, "user", "", "user to bind")
+	fs.StringVar(&teamName, "team", "", "team to bind")
+	fs.StringVar(&scope, "scope", "global", "global, team:NAME or tag:TAG")
+	if err := parseFlags(fs, args, printRoleBindUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("role name is required"), true)
+	}
+	userName, teamName = strings.TrimSpace(userName), strings.TrimSpace(teamName)
+	if (userName == "") == (teamName == "") {
+		return newUsageError(fmt.Errorf("exactly one of --user or --team is required"), true)
+	}
+	subject := "user:" + userName
+	if teamName != "" {
+		subject = "team:" + teamName
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/role-bindings", map[string]string{
+		"role":    fs.Arg(0),
+		"subject": subject,
+		"scope":   strings.TrimSpace(scope),
+	})
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var binding roleBinding
+	if err := json.Unmarshal(payload, &binding); err != nil {
+		return err
+	}
+	fmt.Printf("Bound role %s to %s at %s (binding %d)\n", binding.Role, binding.Subject, binding.Scope, binding.ID)
+	return nil
+}
+
+func runRoleUnbind(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("role unbind")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printRoleUnbindUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(fmt.Errorf("binding id is required"), true)
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || id <= 0 {
+		return newUsageError(fmt.Errorf("binding id must be a positive integer"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodDelete, "/v1/role-bindings/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	fmt.Printf("Role binding %d removed\n", id)
+	return nil
+}
@@ -47,11 +47,11 @@ func runSecretsCommand(ctx context.Context, args []string, base commonFlags) err
 		}
 		return newUsageError(fmt.Errorf("secrets command is required"), false)
 	}
-	// set-env/set-git always go through the daemon (they have no local-file
-	// variant). The remaining subcommands talk to the daemon over HTTP when an
-	// endpoint is configured (a remote/laptop agent) and edit bundle files
-	// directly otherwise (the host operator).
-	if args[0] == "set-env" || args[0] == "set-git" || secretsRemoteMode(base) {
+	// set-env/set-git, policies and access requests always go through the
+	// daemon (they have no local-file variant). The remaining subcommands talk
+	// to the daemon over HTTP when an endpoint is configured (a remote/laptop
+	// agent) and edit bundle files directly otherwise (the host operator).
+	if _, ok := secretsDaemonOnly[args[0]]; ok || secretsRemoteMode(base) {
 		return runSecretsRemote(ctx, args, base)
 	}
 	switch args[0] {
@@ -68,7 +68,7 @@ func runSecretsCommand(ctx context.Context, args []string, base commonFlags) err
 	case "clear-tailscale":
 		return runSecretsClearTailscaleCommand(args[1:], base)
 	default:
-		return unknownSubcommandError("secrets", args[0], []string{"show", "validate", "set-env", "set-git", "add-ssh-key", "remove-ssh-key", "set-tailscale", "clear-tailscale"})
+		return unknownSubcommandError("secrets", args[0], []string{"show", "validate", "set-env", "set-git", "add-ssh-key", "remove-ssh-key", "set-tailscale", "clear-tailscale", "set-policy", "clear-policy", "requests", "approve", "deny"})
 	}
 }
 
@@ -519,7 +519,7 @@ func printSecretsMutationResult(jsonOutput bool, payload map[string]any, text st
 }
 
 func printSecretsUsage() {
-	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets <show|validate|set-env|set-git|add-ssh-key|remove-ssh-key|set-tailscale|clear-tailscale>")
+	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets <show|validate|set-env|set-git|add-ssh-key|remove-ssh-key|set-tailscale|clear-tailscale|set-policy|clear-policy|requests|approve|deny>")
 }
 
 func printSecretsShowUsage() {
@@ -15,8 +15,8 @@ import (
 // secretsRemoteMode reports whether secrets subcommands should talk to the
 // daemon over HTTP rather than editing bundle files locally. A configured
 // endpoint means a remote/laptop agent (which has no local age key); an absent
-// endpoint means the host operator editing files directly. set-env/set-git are
-// always remote — they have no local-file variant.
+// endpoint means the host operator editing files directly. set-env/set-git,
+// policies and access requests are always remote; see secretsDaemonOnly.
 func secretsRemoteMode(base commonFlags) bool {
 	return strings.TrimSpace(base.endpoint) != ""
 }
@@ -48,10 +48,18 @@ func runSecretsRemote(ctx context.Context, args []string, base commonFlags) erro
 		return runSecretsAddSSHKeyRemote(ctx, rest, base)
 	case "remove-ssh-key":
 		return runSecretsRemoveSSHKeyRemote(ctx, rest, base)
+	case "set-policy":
+		return runSecretsSetPolicyCommand(ctx, rest, base)
+	case "clear-policy":
+		return runSecretsClearPolicyCommand(ctx, rest, base)
+	case "requests":
+		return runSecretsRequestsCommand(ctx, rest, base)
+	case "approve", "deny":
+		return runSecretsDecideCommand(ctx, args[0], rest, base)
 	case "validate":
 		return newUsageError(errors.New("secrets validate is a local-only diagnostic; run it on the host without --endpoint"), true)
 	default:
-		return unknownSubcommandError("secrets", args[0], []string{"show", "set-env", "set-git", "set-tailscale", "clear-tailscale", "add-ssh-key", "remove-ssh-key"})
+		return unknownSubcommandError("secrets", args[0], []string{"show", "set-env", "set-git", "set-tailscale", "clear-tailscale", "add-ssh-key", "remove-ssh-key", "set-policy", "clear-policy", "requests", "approve", "deny"})
 	}
 }
 
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// secretsDaemonOnly lists secrets subcommands that always go through the
+// daemon: policies live beside the access requests the daemon tracks, and
+// requests exist only in its database.
+var secretsDaemonOnly = map[string]struct{}{
+	"set-env":      {},
+	"set-git":      {},
+	"set-policy":   {},
+	"clear-policy": {},
+	"requests":     {},
+	"approve":      {},
+	"deny":         {},
+}
+
+type secretAccessRequest struct {
+	ID              string `json:"id"`
+	Secret          string `json:"secret"`
+	VMID            int    `json:"vmid"`
+	SandboxName     string `json:"sandbox_name,omitempty"`
+	JobID           string `json:"job_id,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+	Status          string `json:"status"`
+	GrantTTLSeconds int64  `json:"grant_ttl_seconds,omitempty"`
+	RequestedAt     string `json:"requested_at"`
+	DecidedAt       string `json:"decided_at,omitempty"`
+	DecidedBy       string `json:"decided_by,omitempty"`
+	ExpiresAt       string `json:"expires_at,omitempty"`
+}
+
+type secretAccessRequestsResponse struct {
+	Requests []secretAccessRequest `json:"requests"`
+}
+
+func runSecretsSetPolicyCommand(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("secrets set-policy")
+	opts := base
+	opts.bind(fs)
+	var (
+		name            string
+		profiles        stringListFlag
+		tags            stringListFlag
+		owners          stringListFlag
+		jobs            stringListFlag
+		requireApproval bool
+		grantTTL        string
+	)
+	help := bindHelpFlag(fs)
+	fs.StringVar(&name, "name", "", "secret name (an env or metadata key in the bundle)")
+	fs.Var(&profiles, "profile", "profile allowed to read the secret (glob, repeatable)")
+	fs.Var(&tags, "tag", "sandbox tag allowed to read the secret (glob, repeatable)")
+	fs.Var(&owners, "owner", "sandbox owner allowed to read the secret (glob, repeatable)")
+	fs.Var(&jobs, "job", "job id allowed to read the secret (glob, repeatable)")
+	fs.BoolVar(&requireApproval, "require-approval", false, "require an operator to approve each sandbox's read")
+	fs.StringVar(&grantTTL, "grant-ttl", "", "lifetime of an approved grant (e.g. 30m; default from secrets_grant_ttl)")
+	if err := parseFlags(fs, args, printSecretsSetPolicyUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return newUsageError(errors.New("--name is required"), true)
+	}
+	if raw := strings.TrimSpace(grantTTL); raw != "" {
+		if ttl, err := time.ParseDuration(raw); err != nil || ttl <= 0 {
+			return newUsageError(fmt.Errorf("--grant-ttl must be a positive duration"), true)
+		}
+	}
+	body := map[string]any{}
+	if len(profiles) > 0 {
+		body["profiles"] = []string(profiles)
+	}
+	if len(tags) > 0 {
+		body["tags"] = []string(tags)
+	}
+	if len(owners) > 0 {
+		body["owners"] = []string(owners)
+	}
+	if len(jobs) > 0 {
+		body["jobs"] = []string(jobs)
+	}
+	if requireApproval {
+		body["require_approval"] = true
+	}
+	if raw := strings.TrimSpace(grantTTL); raw != "" {
+		body["grant_ttl"] = raw
+	}
+	if len(body) == 0 {
+		return newUsageError(errors.New("at least one of --profile/--tag/--owner/--job/--require-approval is required"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.doJSON(ctx, http.MethodPut, "/v1/secrets/policies/"+url.PathEscape(name), body)
+	if err != nil {
+		return fmt.Errorf("set policy: %w", err)
+	}
+	return printSecretsRemoteResult(opts.jsonOutput, data, fmt.Sprintf("Updated secrets bundle (policy for %q)", name))
+}
+
+func runSecretsClearPolicyCommand(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("secrets clear-policy")
+	opts := base
+	opts.bind(fs)
+	var name string
+	help := bindHelpFlag(fs)
+	fs.StringVar(&name, "name", "", "secret name")
+	if err := parseFlags(fs, args, printSecretsClearPolicyUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return newUsageError(errors.New("--name is required"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.doJSON(ctx, http.MethodDelete, "/v1/secrets/policies/"+url.PathEscape(name), nil)
+	if err != nil {
+		return fmt.Errorf("clear policy: %w", err)
+	}
+	return printSecretsRemoteResult(opts.jsonOutput, data, fmt.Sprintf("Updated secrets bundle (policy for %q removed)", name))
+}
+
+func runSecretsRequestsCommand(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("secrets requests")
+	opts := base
+	opts.bind(fs)
+	status := "pending"
+	help := bindHelpFlag(fs)
+	fs.StringVar(&status, "status", status, "filter: pending, approved, denied, or all")
+	if err := parseFlags(fs, args, printSecretsRequestsUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.doJSON(ctx, http.MethodGet, "/v1/secrets/requests?status="+url.QueryEscape(strings.TrimSpace(status)), nil)
+	if err != nil {
+		return fmt.Errorf("list secret requests: %w", err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, data)
+	}
+	var resp secretAccessRequestsResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	printSecretRequestList(os.Stdout, resp.Requests)
+	return nil
+}
+
+func printSecretRequestList(w io.Writer, requests []secretAccessRequest) {
+	if len(requests) == 0 {
+		fmt.Fprintln(w, "No secret requests")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSECRET\tVMID\tJOB\tSTATUS\tREQUESTED\tEXPIRES")
+	for _, req := range requests {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			req.ID, req.Secret, req.VMID, orDash(req.JobID), req.Status, req.RequestedAt, orDash(req.ExpiresAt))
+	}
+	_ = tw.Flush()
+}
+
+// runSecretsDecideCommand approves or denies a pending access request.
+func runSecretsDecideCommand(ctx context.Context, action string, args []string, base commonFlags) error {
+	fs := newFlagSet("secrets " + action)
+	opts := base
+	opts.bind(fs)
+	usage := printSecretsDenyUsage
+	var ttl string
+	if action == "approve" {
+		usage = printSecretsApproveUsage
+		fs.StringVar(&ttl, "ttl", "", "grant lifetime (e.g. 30m; default from the secret's policy or secrets_grant_ttl)")
+	}
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, usage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || strings.TrimSpace(fs.Arg(0)) == "" {
+		if !opts.jsonOutput {
+			usage()
+		}
+		return newUsageError(fmt.Errorf("request id is required"), false)
+	}
+	id := strings.TrimSpace(fs.Arg(0))
+	body := map[string]string{}
+	if raw := strings.TrimSpace(ttl); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err != nil || parsed <= 0 {
+			return newUsageError(fmt.Errorf("--ttl must be a positive duration"), true)
+		}
+		body["ttl"] = raw
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.doJSON(ctx, http.MethodPost, "/v1/secrets/requests/"+url.PathEscape(id)+"/"+action, body)
+	if err != nil {
+		return fmt.Errorf("%s secret request: %w", action, err)
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, data)
+	}
+	var req secretAccessRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+	if req.Status == "approved" {
+		fmt.Fprintf(os.Stdout, "Approved %s: sandbox %d may read %s until %s\n", req.ID, req.VMID, req.Secret, req.ExpiresAt)
+		return nil
+	}
+	fmt.Fprintf(os.Stdout, "Denied %s: sandbox %d may not read %s\n", req.ID, req.VMID, req.Secret)
+	return nil
+}
+
+func printSecretsSetPolicyUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets set-policy [--endpoint <url> --token <token>] [--socket <path>] [--json] --name <secret> [--profile <glob>]... [--tag <glob>]... [--owner <glob>]... [--job <glob>]... [--require-approval] [--grant-ttl <duration>]")
+}
+
+func printSecretsClearPolicyUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets clear-policy [--endpoint <url> --token <token>] [--socket <path>] [--json] --name <secret>")
+}
+
+func printSecretsRequestsUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets requests [--endpoint <url> --token <token>] [--socket <path>] [--json] [--status pending|approved|denied|all]")
+}
+
+func printSecretsApproveUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets approve [--endpoint <url> --token <token>] [--socket <path>] [--json] [--ttl <duration>] <request-id>")
+}
+
+func printSecretsDenyUsage() {
+	fmt.Fprintln(os.Stdout, "Usage: agentlab secrets deny [--endpoint <url> --token <token>] [--socket <path>] [--json] <request-id>")
+}
@@ -365,3 +365,93 @@ func TestSecretsSetTailscaleRemoteAdminKeyMutualExclusivity(t *testing.T) {
 		t.Fatalf("expected a mutual-exclusivity error, got %v", err)
 	}
 }
+
+func TestSecretsSetPolicyRemote(t *testing.T) {
+	var gotMethod, gotPath, gotBody string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/secrets/policies/", requestRecorder(t, &gotMethod, &gotPath, &gotBody))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	base := commonFlags{jsonOutput: true, timeout: time.Second, endpoint: server.URL}
+	_ = captureStdout(t, func() {
+		if err := runSecretsCommand(context.Background(), []string{
+			"set-policy", "--name", "PROD_DB_URL", "--profile", "prod-*", "--tag", "team-data", "--require-approval", "--grant-ttl", "30m",
+		}, base); err != nil {
+			t.Fatalf("set-policy: %v", err)
+		}
+	})
+
+	if gotMethod != http.MethodPut || gotPath != "/v1/secrets/policies/PROD_DB_URL" {
+		t.Fatalf("request = %s %s", gotMethod, gotPath)
+	}
+	var reqBody map[string]any
+	if err := json.Unmarshal([]byte(gotBody), &reqBody); err != nil {
+		t.Fatalf("parse request body: %v", err)
+	}
+	if reqBody["require_approval"] != true || reqBody["grant_ttl"] != "30m" {
+		t.Fatalf("request body = %#v", reqBody)
+	}
+	if profiles, _ := reqBody["profiles"].([]any); len(profiles) != 1 || profiles[0] != "prod-*" {
+		t.Fatalf("request profiles = %#v", reqBody["profiles"])
+	}
+
+	if err := runSecretsCommand(context.Background(), []string{"set-policy", "--name", "PROD_DB_URL"}, base); err == nil {
+		t.Fatal("expected set-policy without constraints to fail")
+	}
+}
+
+func TestSecretsRequestsAndApproveRemote(t *testing.T) {
+	var gotMethod, gotPath, gotQuery, gotBody string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/secrets/requests", func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotQuery = r.Method, r.URL.Path, r.URL.RawQuery
+		writeJSON(t, w, http.StatusOK, map[string]any{"requests": []map[string]any{{
+			"id": "secreq_abc", "secret": "PROD_DB_URL", "vmid": 1001, "job_id": "job-1",
+			"status": "pending", "requested_at": "2026-10-18T12:00:00Z",
+		}}})
+	})
+	mux.HandleFunc("/v1/secrets/requests/", func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		writeJSON(t, w, http.StatusOK, map[string]any{
+			"id": "secreq_abc", "secret": "PROD_DB_URL", "vmid": 1001, "status": "approved",
+			"requested_at": "2026-10-18T12:00:00Z", "expires_at": "2026-10-18T12:30:00Z",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	base := commonFlags{timeout: time.Second, endpoint: server.URL}
+	out := captureStdout(t, func() {
+		if err := runSecretsCommand(context.Background(), []string{"requests"}, base); err != nil {
+			t.Fatalf("requests: %v", err)
+		}
+	})
+	if gotMethod != http.MethodGet || gotPath != "/v1/secrets/requests" || gotQuery != "status=pending" {
+		t.Fatalf("request = %s %s?%s", gotMethod, gotPath, gotQuery)
+	}
+	if !strings.Contains(out, "secreq_abc") || !strings.Contains(out, "PROD_DB_URL") || !strings.Contains(out, "job-1") {
+		t.Fatalf("unexpected requests output %q", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := runSecretsCommand(context.Background(), []string{"approve", "--ttl", "30m", "secreq_abc"}, base); err != nil {
+			t.Fatalf("approve: %v", err)
+		}
+	})
+	if gotMethod != http.MethodPost || gotPath != "/v1/secrets/requests/secreq_abc/approve" {
+		t.Fatalf("request = %s %s", gotMethod, gotPath)
+	}
+	if !strings.Contains(gotBody, `"ttl":"30m"`) {
+		t.Fatalf("approve body = %q", gotBody)
+	}
+	if !strings.Contains(out, "until 2026-10-18T12:30:00Z") {
+		t.Fatalf("unexpected approve output %q", out)
+	}
+
+	if err := runSecretsCommand(context.Background(), []string{"deny"}, commonFlags{jsonOutput: true, timeout: time.Second, endpoint: server.URL}); err == nil {
+		t.Fatal("expected deny without a request id to fail")
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const templatePollInterval = 5 * time.Second
+
+// templateResponse mirrors one template version in the daemon's
+// /v1/templates responses.
+type templateResponse struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Ref            string `json:"ref"`
+	VMID           int    `json:"vmid"`
+	Status         string `json:"status"`
+	ImageURL       string `json:"image_url"`
+	ImageSHA256    string `json:"image_sha256"`
+	SpecSHA256     string `json:"spec_sha256"`
+	Spec           string `json:"spec,omitempty"`
+	BuiltBy        string `json:"built_by,omitempty"`
+	BuilderVersion string `json:"builder_version,omitempty"`
+	Error          string `json:"error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+	FinishedAt     string `json:"finished_at,omitempty"`
+}
+
+type templatesResponse struct {
+	Templates []templateResponse `json:"templates"`
+}
+
+func runTemplateCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 {
+		if !base.jsonOutput {
+			printTemplateUsage()
+			return nil
+		}
+		return newUsageError(fmt.Errorf("template command is required"), false)
+	}
+	if isHelpToken(args[0]) {
+		printTemplateUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "build":
+		return runTemplateBuild(ctx, args[1:], base)
+	case "list":
+		return runTemplateList(ctx, args[1:], base)
+	case "show":
+		return runTemplateShow(ctx, args[1:], base)
+	default:
+		if !base.jsonOutput {
+			printTemplateUsage()
+		}
+		return unknownSubcommandError("template", args[0], []string{"build", "list", "show"})
+	}
+}
+
+func printTemplateUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab template <command>
+
+Commands:
+  build    Build a new template version from a spec file
+  list     List template versions
+  show     Show one template version and the spec it was built from
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func printTemplateBuildUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab template build [--wait] <spec.yaml>
+
+Send a template spec to agentlabd and build it as a new template version.
+agentlabd downloads and verifies the base image, boots a builder VM that
+installs the packages and guest agent and runs the scripts, then converts
+it to a template. Use - to read the spec from stdin.
+
+Flags:
+  --wait    Wait until the build is ready or failed
+  --json    Output JSON
+`)
+}
+
+func printTemplateListUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab template list [--name <name>]
+
+Flags:
+  --name    Only list versions of this template
+  --json    Output JSON
+`)
+}
+
+func printTemplateShowUsage() {
+	_, _ = fmt.Fprint(os.Stderr, `Usage: agentlab template show <name@version>
+
+Flags:
+  --json    Output JSON
+`)
+}
+
+func runTemplateBuild(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("template build")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var wait bool
+	fs.BoolVar(&wait, "wait", false, "wait for the build to finish")
+	if err := parseFlags(fs, args, printTemplateBuildUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(errors.New("template build requires exactly one spec file"), true)
+	}
+	spec, err := readTemplateSpec(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodPost, "/v1/templates", map[string]string{"spec": spec})
+	if err != nil {
+		return err
+	}
+	var resp templateResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	if !wait {
+		if opts.jsonOutput {
+			return prettyPrintJSON(os.Stdout, payload)
+		}
+		fmt.Fprintf(os.Stdout, "Building template %s as VM %d\n", resp.Ref, resp.VMID)
+		fmt.Fprintf(os.Stdout, "Follow it with: agentlab template show %s\n", resp.Ref)
+		return nil
+	}
+	if !opts.jsonOutput {
+		fmt.Fprintf(os.Stdout, "Building template %s as VM %d...\n", resp.Ref, resp.VMID)
+	}
+	resp, payload, err = waitForTemplate(ctx, client, resp)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		if err := prettyPrintJSON(os.Stdout, payload); err != nil {
+			return err
+		}
+	} else if resp.Status == "ready" {
+		fmt.Fprintf(os.Stdout, "Template %s is ready (VM %d)\n", resp.Ref, resp.VMID)
+	}
+	if resp.Status != "ready" {
+		return fmt.Errorf("template %s %s: %s", resp.Ref, resp.Status, resp.Error)
+	}
+	return nil
+}
+
+func readTemplateSpec(path string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read template spec: %w", err)
+	}
+	return string(data), nil
+}
+
+// waitForTemplate polls a template version until its build finishes. It
+// returns the final version and its raw JSON.
+func waitForTemplate(ctx context.Context, client *apiClient, tmpl templateResponse) (templateResponse, []byte, error) {
+	ticker := time.NewTicker(templatePollInterval)
+	defer ticker.Stop()
+	path := "/v1/templates/" + url.PathEscape(tmpl.Name) + "/" + url.PathEscape(tmpl.Version)
+	for {
+		payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return templateResponse{}, nil, err
+		}
+		var resp templateResponse
+		if err := json.Unmarshal(payload, &resp); err != nil {
+			return templateResponse{}, nil, err
+		}
+		if resp.Status != "building" {
+			return resp, payload, nil
+		}
+		select {
+		case <-ctx.Done():
+			return templateResponse{}, nil, waitError(ctx.Err(), "template "+tmpl.Ref)
+		case <-ticker.C:
+		}
+	}
+}
+
+func runTemplateList(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("template list")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	var name string
+	fs.StringVar(&name, "name", "", "only list versions of this template")
+	if err := parseFlags(fs, args, printTemplateListUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return newUsageError(fmt.Errorf("unexpected extra arguments"), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	path := "/v1/templates"
+	if name = strings.TrimSpace(name); name != "" {
+		path += "?name=" + url.QueryEscape(name)
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var resp templatesResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return err
+	}
+	if len(resp.Templates) == 0 {
+		fmt.Fprintln(os.Stdout, "No templates built.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TEMPLATE\tVMID\tSTATUS\tBUILT BY\tCREATED")
+	for _, tmpl := range resp.Templates {
+		builtBy := tmpl.BuiltBy
+		if builtBy == "" {
+			builtBy = "-"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", tmpl.Ref, tmpl.VMID, tmpl.Status, builtBy, tmpl.CreatedAt)
+	}
+	return w.Flush()
+}
+
+func runTemplateShow(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("template show")
+	opts := base
+	opts.bind(fs)
+	help := bindHelpFlag(fs)
+	if err := parseFlags(fs, args, printTemplateShowUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return newUsageError(errors.New("template show requires <name@version>"), true)
+	}
+	name, version, ok := strings.Cut(strings.TrimSpace(fs.Arg(0)), "@")
+	if !ok || name == "" || version == "" {
+		return newUsageError(fmt.Errorf("template reference %q must be name@version", fs.Arg(0)), true)
+	}
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	payload, err := client.doJSON(ctx, http.MethodGet, "/v1/templates/"+url.PathEscape(name)+"/"+url.PathEscape(version), nil)
+	if err != nil {
+		return err
+	}
+	if opts.jsonOutput {
+		return prettyPrintJSON(os.Stdout, payload)
+	}
+	var tmpl templateResponse
+	if err := json.Unmarshal(payload, &tmpl); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Template:\t%s\n", tmpl.Ref)
+	fmt.Fprintf(w, "VMID:\t%d\n", tmpl.VMID)
+	fmt.Fprintf(w, "Status:\t%s\n", tmpl.Status)
+	if tmpl.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", tmpl.Error)
+	}
+	fmt.Fprintf(w, "Image:\t%s\n", tmpl.ImageURL)
+	fmt.Fprintf(w, "Image SHA256:\t%s\n", tmpl.ImageSHA256)
+	fmt.Fprintf(w, "Spec SHA256:\t%s\n", tmpl.SpecSHA256)
+	if tmpl.BuiltBy != "" {
+		fmt.Fprintf(w, "Built by:\t%s\n", tmpl.BuiltBy)
+	}
+	if tmpl.BuilderVersion != "" {
+		fmt.Fprintf(w, "Builder version:\t%s\n", tmpl.BuilderVersion)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", tmpl.CreatedAt)
+	if tmpl.FinishedAt != "" {
+		fmt.Fprintf(w, "Finished:\t%s\n", tmpl.FinishedAt)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if tmpl.Spec != "" {
+		fmt.Fprintf(os.Stdout, "\nSpec:\n%s", tmpl.Spec)
+		if !strings.HasSuffix(tmpl.Spec, "\n") {
+			fmt.Fprintln(os.Stdout)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"text/tabwriter"
 )
@@ -16,6 +17,8 @@ const userUsage = `Usage:
   agentlab user rm <name>
   agentlab user key add --name <name> --key <ssh-public-key>
   agentlab user key rm --name <name> --fingerprint <fingerprint>
+  agentlab user identity add --name <name> --subject <subject> [--issuer <url>]
+  agentlab user identity rm --name <name> --subject <subject> [--issuer <url>]
 
 Roles:
   admin   Full access: all sandboxes, user management, team management
@@ -23,6 +26,10 @@ Roles:
 
 Note: The first user added is automatically assigned the admin role.
 
+Single sign-on provisions users on first login, but never claims an existing
+user by name: link the user's identity provider subject with "user identity
+add" first. --issuer defaults to the daemon's oidc_issuer.
+
 Examples:
   # Add an admin user:
   agentlab user add --name alice --key "ssh-ed25519 AAAA..." --role admin
@@ -67,6 +74,8 @@ func runUserCommand(ctx context.Context, args []string, base commonFlags) error
 		return runUserRm(ctx, args[1:], base)
 	case "key":
 		return runUserKeyCommand(ctx, args[1:], base)
+	case "identity":
+		return runUserIdentityCommand(ctx, args[1:], base)
 	default:
 		return newUsageError(fmt.Errorf("unknown user subcommand %q", args[0]), true)
 	}
@@ -219,6 +228,14 @@ func runUserShow(ctx context.Context, args []string, base commonFlags) error {
 			}
 		}
 	}
+	if identities, ok := resp["identities"].([]any); ok && len(identities) > 0 {
+		fmt.Println("Identities:")
+		for _, item := range identities {
+			if im, ok := item.(map[string]any); ok {
+				fmt.Printf("  %s  %s\n", im["issuer"], im["subject"])
+			}
+		}
+	}
 	return nil
 }
 
@@ -350,6 +367,107 @@ func runUserKeyRm(ctx context.Context, args []string, base commonFlags) error {
 	return nil
 }
 
+// --- User Identity subcommands ---
+
+func runUserIdentityCommand(ctx context.Context, args []string, base commonFlags) error {
+	if len(args) == 0 || isHelpToken(args[0]) {
+		printUserUsage()
+		return errHelp
+	}
+	switch args[0] {
+	case "add":
+		return runUserIdentityAdd(ctx, args[1:], base)
+	case "rm":
+		return runUserIdentityRm(ctx, args[1:], base)
+	default:
+		return newUsageError(fmt.Errorf("unknown user identity subcommand %q", args[0]), true)
+	}
+}
+
+func runUserIdentityAdd(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("user identity add")
+	opts := base
+	opts.bind(fs)
+
+	var name, subject, issuer string
+	help := bindHelpFlag(fs)
+	fs.StringVar(&name, "name", "", "user name")
+	fs.StringVar(&subject, "subject", "", "identity provider subject (sub claim)")
+	fs.StringVar(&issuer, "issuer", "", "identity provider issuer URL (default: the daemon's oidc_issuer)")
+
+	if err := parseFlags(fs, args, printUserUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if name == "" {
+		return newUsageError(errors.New("--name is required"), true)
+	}
+	if subject == "" {
+		return newUsageError(errors.New("--subject is required"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	data, err := client.doJSON(ctx, "POST", "/v1/users/"+name+"/identities", map[string]string{"issuer": issuer, "subject": subject})
+	if err != nil {
+		return fmt.Errorf("link identity: %w", err)
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(json.RawMessage(data))
+	}
+
+	fmt.Printf("Identity %q linked to user %q.\n", subject, name)
+	return nil
+}
+
+func runUserIdentityRm(ctx context.Context, args []string, base commonFlags) error {
+	fs := newFlagSet("user identity rm")
+	opts := base
+	opts.bind(fs)
+
+	var name, subject, issuer string
+	help := bindHelpFlag(fs)
+	fs.StringVar(&name, "name", "", "user name")
+	fs.StringVar(&subject, "subject", "", "identity provider subject (sub claim)")
+	fs.StringVar(&issuer, "issuer", "", "identity provider issuer URL (default: the daemon's oidc_issuer)")
+
+	if err := parseFlags(fs, args, printUserUsage, help, opts.jsonOutput); err != nil {
+		return err
+	}
+	if name == "" {
+		return newUsageError(errors.New("--name is required"), true)
+	}
+	if subject == "" {
+		return newUsageError(errors.New("--subject is required"), true)
+	}
+
+	client, err := apiClientFromFlags(opts)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"subject": {subject}}
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	data, err := client.doJSON(ctx, "DELETE", "/v1/users/"+name+"/identities?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("unlink identity: %w", err)
+	}
+
+	if opts.jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(json.RawMessage(data))
+	}
+
+	fmt.Printf("Identity %q unlinked from user %q.\n", subject, name)
+	return nil
+}
+
 // --- Team commands ---
 
 func runTeamCommand(ctx context.Context, args []string, base commonFlags) error {
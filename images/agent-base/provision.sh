@@ -60,37 +60,20 @@ sed -i 's/#PasswordAuthentication yes/PasswordAuthentication no/' /etc/ssh/sshd_
 mkdir -p /workspace
 chmod 777 /workspace
 
-# Install agentlab-guest helper.
+# Install the agentlab-guest binary. Pass a local build with
+# AGENTLAB_GUEST_BIN (for example after `pct push`); otherwise the latest
+# release is downloaded.
 mkdir -p /usr/local/bin
-cat > /usr/local/bin/agentlab-guest <<'GUEST_EOF'
-#!/usr/bin/env bash
-# AgentLab guest helper - fetches sandbox metadata and secrets.
-set -euo pipefail
-META="http://169.254.169.254"
-SECRET_FILE="${AGENTLAB_SANDBOX_SECRET_FILE:-/run/agentlab/secrets/sandbox-secret}"
-SECRET=""
-if [[ -r "$SECRET_FILE" ]]; then
-    SECRET="$(cat "$SECRET_FILE")"
+if [[ -n "${AGENTLAB_GUEST_BIN:-}" ]]; then
+    install -m 0755 "$AGENTLAB_GUEST_BIN" /usr/local/bin/agentlab-guest
+else
+    GUEST_REPO="nibzard/agentlab"
+    GUEST_ARCH="$(dpkg --print-architecture)"
+    GUEST_TAG="$(curl -fsSL "https://api.github.com/repos/${GUEST_REPO}/releases/latest" | jq -r '.tag_name')"
+    curl -fsSL "https://github.com/${GUEST_REPO}/releases/download/${GUEST_TAG}/agentlab-guest_${GUEST_TAG}_linux-${GUEST_ARCH}.tar.gz" \
+        | tar -C /usr/local/bin -xzf - agentlab-guest
+    chmod 0755 /usr/local/bin/agentlab-guest
 fi
-# Every metadata request must carry the per-sandbox secret. Requests
-# without it are rejected.
-meta_curl() {
-    if [[ -n "$SECRET" ]]; then
-        curl -sf -H "X-AgentLab-Sandbox-Secret: $SECRET" "$@"
-    else
-        echo "agentlab-guest: missing sandbox secret at $SECRET_FILE" >&2
-        return 1
-    fi
-}
-case "${1:-help}" in
-    identity)  meta_curl "$META/identity" | jq . ;;
-    metadata)  meta_curl "$META/metadata" | jq . ;;
-    secret)    meta_curl "$META/secrets/${2:?secret name required}" | jq -r '.value' ;;
-    prompt)    meta_curl "$META/metadata" | jq -r '.prompt // empty' ;;
-    help|*)    echo "Usage: agentlab-guest {identity|metadata|secret <name>|prompt}" ;;
-esac
-GUEST_EOF
-chmod +x /usr/local/bin/agentlab-guest
 
 # Install agent entrypoint script.
 cat > /usr/local/bin/agent-entrypoint.sh <<'ENTRYPOINT_EOF'
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Issuer signs short-lived API tokens on behalf of registered users who hold
+// no SSH key of their own, such as users who sign in through OIDC. Its key
+// never leaves the daemon. Middleware.WithIssuer trusts the key and reads the
+// acting user from the token's usr claim; tokens signed by any other key
+// cannot set that claim.
+type Issuer struct {
+	signer ssh.Signer
+	ttl    time.Duration
+}
+
+// NewIssuer creates an issuer around signer. Tokens live for ttl, or the
+// default token lifetime when ttl is zero.
+func NewIssuer(signer ssh.Signer, ttl time.Duration) *Issuer {
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	return &Issuer{signer: signer, ttl: ttl}
+}
+
+// LoadOrCreateIssuer loads the issuer key at path, generating and persisting
+// a new ed25519 key (mode 0600) when the file does not exist yet.
+func LoadOrCreateIssuer(path string, ttl time.Duration) (*Issuer, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, errors.New("issuer key path is required")
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse issuer key %s: %w", path, err)
+		}
+		return NewIssuer(signer, ttl), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read issuer key %s: %w", path, err)
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate issuer key: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("create issuer signer: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "agentlabd token issuer")
+	if err != nil {
+		return nil, fmt.Errorf("marshal issuer key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create issuer key dir: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, fmt.Errorf("write issuer key %s: %w", path, err)
+	}
+	return NewIssuer(signer, ttl), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the issuer key, which every
+// token it signs carries as its iss claim.
+func (i *Issuer) Fingerprint() string {
+	return FingerprintForPublicKey(i.signer.PublicKey())
+}
+
+// PublicKey returns the issuer's public key.
+func (i *Issuer) PublicKey() ssh.PublicKey {
+	return i.signer.PublicKey()
+}
+
+// TTL returns the lifetime of the tokens the issuer signs.
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// Issue signs a token that acts for userID with every command allowed;
+// the user's role and custom-role bindings then decide what it may do.
+// subject labels the token, e.g. "oidc:alice".
+func (i *Issuer) Issue(userID, subject string) (string, time.Time, error) {
+	if strings.TrimSpace(userID) == "" {
+		return "", time.Time{}, errors.New("user is required")
+	}
+	expires := time.Now().Add(i.ttl)
+	token, err := CreateToken(i.signer, TokenCreateRequest{
+		Commands: []string{"*"},
+		TTL:      i.ttl,
+		Subject:  subject,
+		User:     userID,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Unix(expires.Unix(), 0), nil
+}
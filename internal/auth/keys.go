@@ -84,6 +84,13 @@ func (ks *KeyStore) Lookup(fingerprint string) *KeyIdentity {
 	return ks.keys[fingerprint]
 }
 
+// Add trusts an additional public key, such as the daemon's own token issuer
+// key.
+func (ks *KeyStore) Add(pubKey ssh.PublicKey, comment string) {
+	fp := ssh.FingerprintSHA256(pubKey)
+	ks.keys[fp] = &KeyIdentity{Fingerprint: fp, Comment: comment, PublicKey: pubKey}
+}
+
 // Identities returns all loaded key identities.
 func (ks *KeyStore) Identities() []*KeyIdentity {
 	result := make([]*KeyIdentity, 0, len(ks.keys))
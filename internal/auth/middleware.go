@@ -15,6 +15,7 @@ type Middleware struct {
 	keyStore   *KeyStore
 	legacyToken string // optional pre-shared bearer token for backward compat
 	allowCIDRs []*net.IPNet
+	issuer     string // fingerprint of the daemon's own token issuer, if any
 }
 
 // MiddlewareConfig holds configuration for creating an auth middleware.
@@ -68,6 +69,20 @@ func NewMiddlewareWithStore(store *KeyStore, legacyToken string, allowCIDRs []st
 	}, nil
 }
 
+// WithIssuer trusts tokens signed by the daemon's own issuer and maps their
+// usr claim to RequestIdentity.UserID. It returns m for chaining.
+func (m *Middleware) WithIssuer(iss *Issuer) *Middleware {
+	if m == nil || iss == nil {
+		return m
+	}
+	if m.keyStore == nil {
+		m.keyStore = NewKeyStore()
+	}
+	m.keyStore.Add(iss.PublicKey(), "agentlabd token issuer")
+	m.issuer = iss.Fingerprint()
+	return m
+}
+
 // Wrap returns a handler that enforces authentication for /v1/* requests.
 // Health and non-v1 endpoints are passed through without auth.
 //
@@ -124,6 +139,36 @@ func (m *Middleware) WrapNetwork(next http.Handler) http.Handler {
 	})
 }
 
+// WrapLocal serves the trusted local Unix socket. Requests there keep full
+// trust, except those presenting a token from the daemon's issuer: they are
+// authenticated and act as the token's user, so a local proxy such as
+// agentlab-dashboard can forward a signed-in user's requests without
+// widening their access. An invalid or expired issuer token is rejected
+// rather than falling back to full trust.
+func (m *Middleware) WrapLocal(next http.Handler) http.Handler {
+	if m == nil || next == nil || m.issuer == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenStr := extractBearerToken(r.Header.Get("Authorization"))
+		if !strings.HasPrefix(tokenStr, tokenPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if unverified, err := ParseTokenUnverified(tokenStr); err != nil || unverified.Claims.Issuer != m.issuer {
+			next.ServeHTTP(w, r)
+			return
+		}
+		identity, err := m.authenticate(tokenStr)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAuthError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
+
 // authenticateRequest performs CIDR and bearer-token authentication for /v1/*
 // requests, shared by Wrap and WrapNetwork. Health and non-v1 paths bypass
 // authentication. On failure it writes the response and returns (nil, false).
@@ -171,6 +216,18 @@ func (m *Middleware) authenticate(tokenStr string) (*RequestIdentity, error) {
 		if err != nil {
 			return nil, err
 		}
+		if m.issuer != "" && tok.Claims.Issuer == m.issuer {
+			if strings.TrimSpace(tok.Claims.User) == "" {
+				return nil, errors.New("issued token names no user")
+			}
+			return &RequestIdentity{
+				Fingerprint: tok.Claims.Issuer,
+				Subject:     tok.Claims.Subject,
+				UserID:      tok.Claims.User,
+				Token:       tok,
+				Method:      "user-token",
+			}, nil
+		}
 		return &RequestIdentity{
 			Fingerprint: tok.Claims.Issuer,
 			Subject:     tok.Claims.Subject,
@@ -225,8 +282,9 @@ const identityKey contextKey = "auth-identity"
 type RequestIdentity struct {
 	Fingerprint string // SSH key fingerprint or "legacy"
 	Subject     string // Token subject / label
+	UserID      string // Registered user a daemon-issued token acts for
 	Token       *Token // Parsed SSH-signed token (nil for legacy auth)
-	Method      string // "ssh-token" or "legacy-token"
+	Method      string // "ssh-token", "user-token" or "legacy-token"
 }
 
 // IsCommandAllowed checks if the authenticated identity allows a command.
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -364,3 +365,91 @@ func TestIsScopedToken(t *testing.T) {
 	assert.False(t, isScopedToken(star([]string{"*", "sandbox"}, nil)))
 	assert.True(t, isScopedToken(star(nil, nil)))
 }
+
+func TestIssuer_TokensActForTheirUser(t *testing.T) {
+	mw, userSigner := testAuthMiddleware(t)
+	keyPath := filepath.Join(t.TempDir(), "keys", "issuer")
+	iss, err := LoadOrCreateIssuer(keyPath, 10*time.Minute)
+	require.NoError(t, err)
+	mw.WithIssuer(iss)
+
+	var got *RequestIdentity
+	handler := mw.WrapNetwork(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(token string) int {
+		got = nil
+		req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	token, expires, err := iss.Issue("alice", "oidc:alice")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), expires, 5*time.Second)
+	require.Equal(t, http.StatusOK, serve(token))
+	require.NotNil(t, got)
+	assert.Equal(t, "alice", got.UserID)
+	assert.Equal(t, "user-token", got.Method)
+
+	// A key holder cannot act for another user by setting usr themselves.
+	forged, err := CreateToken(userSigner, TokenCreateRequest{Commands: []string{"*"}, User: "admin"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, serve(forged))
+	assert.Empty(t, got.UserID)
+	assert.Equal(t, "ssh-token", got.Method)
+
+	// The key persists across restarts, so issued tokens stay valid.
+	again, err := LoadOrCreateIssuer(keyPath, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, iss.Fingerprint(), again.Fingerprint())
+}
+
+func TestWrapLocal_OnlyIssuerTokensNarrowTrust(t *testing.T) {
+	_, userSigner := testAuthMiddleware(t)
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	iss := NewIssuer(signer, time.Minute)
+	local, err := NewMiddlewareWithStore(nil, "", nil)
+	require.NoError(t, err)
+	local.WithIssuer(iss)
+
+	var got *RequestIdentity
+	handler := local.WrapLocal(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(header string) int {
+		got = nil
+		req := httptest.NewRequest(http.MethodGet, "/v1/sandboxes", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// No token, a legacy token and a foreign SSH token keep the socket's trust.
+	foreign, err := CreateToken(userSigner, TokenCreateRequest{Commands: []string{"sandbox.list"}})
+	require.NoError(t, err)
+	for _, header := range []string{"", "Bearer legacy-secret", "Bearer " + foreign} {
+		require.Equal(t, http.StatusOK, serve(header), header)
+		assert.Nil(t, got, header)
+	}
+
+	token, _, err := iss.Issue("bob", "oidc:bob")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, serve("Bearer "+token))
+	require.NotNil(t, got)
+	assert.Equal(t, "bob", got.UserID)
+
+	expired, err := CreateToken(signer, TokenCreateRequest{Commands: []string{"*"}, User: "bob", TTL: -time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer "+expired))
+}
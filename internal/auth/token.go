@@ -71,6 +71,10 @@ type TokenClaims struct {
 
 	// TokenID is a unique identifier for revocation support.
 	TokenID string `json:"jti,omitempty"`
+
+	// User is the registered user a token issued by the daemon acts for
+	// (see Issuer). It is ignored on tokens signed by any other key.
+	User string `json:"usr,omitempty"`
 }
 
 // Token is a parsed and verified API token.
@@ -96,6 +100,10 @@ type TokenCreateRequest struct {
 
 	// Subject is an optional human-readable label.
 	Subject string
+
+	// User is the registered user the token acts for. Only the daemon's
+	// Issuer sets it.
+	User string
 }
 
 // CreateToken signs a new API token using an SSH private key.
@@ -132,6 +140,7 @@ func CreateToken(signer ssh.Signer, req TokenCreateRequest) (string, error) {
 		ExpiresAt: now.Add(ttl).Unix(),
 		NotBefore: now.Unix(),
 		TokenID:   tokenID,
+		User:      req.User,
 	}
 
 	headerB64, err := encodePart(header)
@@ -9,10 +9,12 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
@@ -113,6 +115,10 @@ type Config struct {
 	// Integration / secret injection configuration
 	IntegrationsEnabled bool   // Enable the integrations system
 	IntegrationEncKey   string // Hex-encoded AES-256 key for encrypting integration secrets at rest
+	// IntegrationKeyringPath holds the versioned integration keys written by
+	// key rotation. When the file exists it takes precedence over
+	// IntegrationEncKey, which is only the initial key version.
+	IntegrationKeyringPath string
 	// IntegrationTargetAllowlist restricts the hosts a proxy integration may
 	// target when it is non-empty. Hostnames and literal IPs are allowed;
 	// ports and schemes are not part of an entry.
@@ -130,6 +136,57 @@ type Config struct {
 	PoolCPUOverCommit float64       // CPU over-commit ratio (default 1.0, e.g., 4.0 = 4x cores)
 	PoolMemOverCommit float64       // Memory over-commit ratio (default 1.0, e.g., 2.0 = 2x RAM)
 	PoolBurstDuration time.Duration // How long burst allocations may exceed commit limit (0 = disabled)
+	// Database backup configuration
+	BackupDir       string        // Directory for database backups (default <data_dir>/backups)
+	BackupInterval  time.Duration // Interval between scheduled backups (0 = disabled)
+	BackupRetention int           // Number of backups to keep (0 = keep all)
+	BackupEncrypt   bool          // Age-encrypt backups with secrets_age_key_path
+	// Event, message and audit log retention configuration
+	EventRetention     map[string]time.Duration // Retention per event domain; the "default" key covers other domains (0 = keep forever)
+	MessageRetention   time.Duration            // Retention for messagebox entries (0 = keep forever)
+	AuditLogRetention  time.Duration            // Retention for audit log entries (0 = keep forever)
+	CompactionInterval time.Duration            // Interval between compaction runs (default 1h)
+	ArchiveDir         string                   // Directory for compressed JSONL archives (default <data_dir>/archive)
+	// Template builds
+	TemplateImageDir string // Cache for verified template base images (default <data_dir>/images)
+	// External secret providers referenced from bundle fields
+	SecretsVaultAddr      string        // Vault-compatible server address for vault: references
+	SecretsVaultTokenPath string        // File holding the Vault token (default: VAULT_TOKEN env)
+	SecretsVaultNamespace string        // Optional Vault namespace
+	SecretsCacheTTL       time.Duration // How long resolved provider secrets are cached (default 5m, 0 = no cache)
+	SecretsFileRefs       bool          // Allow file: references to files below secrets_dir
+	SecretsEnvAllowlist   []string      // Daemon environment variables env: references may read (empty: env: disabled)
+	// Just-in-time secret approvals
+	SecretsGrantTTL time.Duration // Default lifetime of an approved secret grant (default 1h)
+	// Operator notifications for agent questions
+	NotifyWebhookURL      string // URL that receives a JSON POST for each notification
+	NotifySlackWebhookURL string // Slack incoming-webhook URL for the same notifications
+	// OpenTelemetry trace export
+	TracingEndpoint string            // OTLP/HTTP collector base URL, e.g. "http://127.0.0.1:4318" (disabled if empty)
+	TracingHeaders  map[string]string // Headers sent with each export, e.g. collector auth
+	// Per-sandbox resource usage history
+	UsageSampleInterval time.Duration // How often running sandboxes are sampled (default 1m, 0 = disabled)
+	// Unit prices for usage reports
+	UsagePriceCurrency      string  // Currency label shown on reports (default "USD")
+	UsagePriceCoreHour      float64 // Price of one vCPU running for an hour
+	UsagePriceMemoryGiBHour float64 // Price of one GiB of memory allocated for an hour
+	UsagePriceInputMTok     float64 // Price of one million LLM input tokens
+	UsagePriceOutputMTok    float64 // Price of one million LLM output tokens
+	// Role-based access control for registered non-admin users
+	RBACEnabled bool // Confine registered non-admin users to their bound custom roles
+	// Approval workflow for privileged operations
+	ApprovalPolicyPath string // Policy file marking permissions that need approval (disabled if empty)
+	// Admission control for sandbox and job creation
+	AdmissionPolicyPath string // Policy file with organization rules for creates (disabled if empty)
+	// Single sign-on through an OpenID Connect provider
+	OIDCIssuer         string            // Provider issuer URL (disabled if empty)
+	OIDCClientID       string            // Client ID that ID tokens must be issued to
+	OIDCUsernameClaim  string            // Claim that names provisioned users (default "preferred_username")
+	OIDCGroupsClaim    string            // Claim listing the user's groups (default "groups")
+	OIDCAdminGroups    []string          // Groups whose members get the admin role (empty: roles are not synced)
+	OIDCTeamGroups     map[string]string // Group -> team mapping (empty: groups map to teams of the same ID)
+	OIDCTokenTTL       time.Duration     // Lifetime of AgentLab tokens issued at login (default 1h)
+	OIDCSigningKeyPath string            // Key that signs issued tokens (generated when missing)
 }
 
 // FileConfig represents supported YAML config overrides.
@@ -151,6 +208,10 @@ type FileConfig struct {
 	ControlAuthToken           string   `yaml:"control_auth_token"`
 	ControlAllowCIDRs          []string `yaml:"control_allow_cidrs"`
 	DBPath                     string   `yaml:"db_path"`
+	BackupDir                  string   `yaml:"backup_dir"`
+	BackupInterval             string   `yaml:"backup_interval"`
+	BackupRetention            *int     `yaml:"backup_retention"`
+	BackupEncrypt              *bool    `yaml:"backup_encrypt"`
 	BootstrapListen            string   `yaml:"bootstrap_listen"`
 	ArtifactListen             string   `yaml:"artifact_listen"`
 	MetricsListen              string   `yaml:"metrics_listen"`
@@ -207,6 +268,7 @@ type FileConfig struct {
 	AuthorizedKeysPath         string   `yaml:"authorized_keys_path"`
 	IntegrationsEnabled        *bool    `yaml:"integrations_enabled"`
 	IntegrationEncKey          string   `yaml:"integration_enc_key"`
+	IntegrationKeyringPath     string   `yaml:"integration_keyring_path"`
 	IntegrationTargetAllowlist []string `yaml:"integration_target_allowlist"`
 	Offline                    *bool    `yaml:"offline"`
 	TrustAgentSubnet           *bool    `yaml:"trust_agent_subnet"`
@@ -216,6 +278,52 @@ type FileConfig struct {
 	PoolCPUOverCommit *float64 `yaml:"pool_cpu_over_commit"`
 	PoolMemOverCommit *float64 `yaml:"pool_mem_over_commit"`
 	PoolBurstDuration string   `yaml:"pool_burst_duration"`
+	// Event, message and audit log retention
+	EventRetention     map[string]string `yaml:"event_retention"`
+	MessageRetention   string            `yaml:"message_retention"`
+	AuditLogRetention  string            `yaml:"audit_log_retention"`
+	CompactionInterval string            `yaml:"compaction_interval"`
+	ArchiveDir         string            `yaml:"archive_dir"`
+	// Template builds
+	TemplateImageDir string `yaml:"template_image_dir"`
+	// External secret providers
+	SecretsVaultAddr      string   `yaml:"secrets_vault_addr"`
+	SecretsVaultTokenPath string   `yaml:"secrets_vault_token_path"`
+	SecretsVaultNamespace string   `yaml:"secrets_vault_namespace"`
+	SecretsCacheTTL       string   `yaml:"secrets_cache_ttl"`
+	SecretsFileRefs       *bool    `yaml:"secrets_file_references"`
+	SecretsEnvAllowlist   []string `yaml:"secrets_env_allowlist"`
+	// Just-in-time secret approvals
+	SecretsGrantTTL string `yaml:"secrets_grant_ttl"`
+	// Operator notifications
+	NotifyWebhookURL      string `yaml:"notify_webhook_url"`
+	NotifySlackWebhookURL string `yaml:"notify_slack_webhook_url"`
+	// OpenTelemetry trace export
+	TracingEndpoint string            `yaml:"tracing_endpoint"`
+	TracingHeaders  map[string]string `yaml:"tracing_headers"`
+	// Per-sandbox resource usage history
+	UsageSampleInterval string `yaml:"usage_sample_interval"`
+	// Unit prices for usage reports
+	UsagePriceCurrency      string   `yaml:"usage_price_currency"`
+	UsagePriceCoreHour      *float64 `yaml:"usage_price_core_hour"`
+	UsagePriceMemoryGiBHour *float64 `yaml:"usage_price_memory_gib_hour"`
+	UsagePriceInputMTok     *float64 `yaml:"usage_price_input_mtok"`
+	UsagePriceOutputMTok    *float64 `yaml:"usage_price_output_mtok"`
+	// Role-based access control for registered non-admin users
+	RBACEnabled *bool `yaml:"rbac_enabled"`
+	// Approval workflow for privileged operations
+	ApprovalPolicyPath string `yaml:"approval_policy_path"`
+	// Admission control for sandbox and job creation
+	AdmissionPolicyPath string `yaml:"admission_policy_path"`
+	// Single sign-on through an OpenID Connect provider
+	OIDCIssuer         string            `yaml:"oidc_issuer"`
+	OIDCClientID       string            `yaml:"oidc_client_id"`
+	OIDCUsernameClaim  string            `yaml:"oidc_username_claim"`
+	OIDCGroupsClaim    string            `yaml:"oidc_groups_claim"`
+	OIDCAdminGroups    []string          `yaml:"oidc_admin_groups"`
+	OIDCTeamGroups     map[string]string `yaml:"oidc_team_groups"`
+	OIDCTokenTTL       string            `yaml:"oidc_token_ttl"`
+	OIDCSigningKeyPath string            `yaml:"oidc_signing_key_path"`
 }
 
 // DefaultConfig returns a Config struct with all default values set.
@@ -231,6 +339,17 @@ type FileConfig struct {
 //   - BootstrapListen: 10.77.0.1:8844
 //   - ArtifactListen: 10.77.0.1:8846
 //   - MetricsListen: "" (disabled)
+//   - BackupDir: /var/lib/agentlab/backups
+//   - BackupInterval: 0 (scheduled backups disabled)
+//   - BackupRetention: 7
+//   - EventRetention, MessageRetention, AuditLogRetention: unset (keep forever)
+//   - CompactionInterval: 1 hour
+//   - ArchiveDir: /var/lib/agentlab/archive
+//   - TemplateImageDir: /var/lib/agentlab/images
+//   - SecretsCacheTTL: 5 minutes
+//   - SecretsFileRefs: false, SecretsEnvAllowlist: empty (file: and env: references disabled)
+//   - SecretsGrantTTL: 1 hour
+//   - IntegrationKeyringPath: /var/lib/agentlab/integration-keyring.json
 //   - ArtifactMaxBytes: 256 MB
 //   - ArtifactTokenTTLMinutes: 1440 (24 hours)
 //   - BootstrapRateLimitQPS: 1 (per IP)
@@ -247,6 +366,16 @@ type FileConfig struct {
 //   - IdleStopInterval: 1 minute
 //   - IdleStopMinutesDefault: 30 minutes
 //   - IdleStopCPUThreshold: 0.05
+//   - UsageSampleInterval: 1 minute
+//   - UsagePriceCurrency: "USD" (all unit prices default to 0)
+//   - RBACEnabled: false
+//   - ApprovalPolicyPath: "" (no operation needs approval)
+//   - AdmissionPolicyPath: "" (every valid create is admitted)
+//   - OIDCIssuer: "" (single sign-on disabled)
+//   - OIDCUsernameClaim: "preferred_username"
+//   - OIDCGroupsClaim: "groups"
+//   - OIDCTokenTTL: 1 hour
+//   - OIDCSigningKeyPath: /var/lib/agentlab/token-issuer.key
 //
 // The returned configuration is valid and ready to use without modification.
 // Use Load() to apply overrides from a configuration file.
@@ -264,6 +393,14 @@ func DefaultConfig() Config {
 		ControlAuthToken:        "",
 		ControlAllowCIDRs:       nil,
 		DBPath:                  filepath.Join(dataDir, "agentlab.db"),
+		BackupDir:               filepath.Join(dataDir, "backups"),
+		BackupRetention:         7,
+		CompactionInterval:      time.Hour,
+		ArchiveDir:              filepath.Join(dataDir, "archive"),
+		TemplateImageDir:        filepath.Join(dataDir, "images"),
+		IntegrationKeyringPath:  filepath.Join(dataDir, "integration-keyring.json"),
+		SecretsCacheTTL:         5 * time.Minute,
+		SecretsGrantTTL:         time.Hour,
 		BootstrapListen:         "10.77.0.1:8844",
 		ArtifactListen:          "10.77.0.1:8846",
 		MetricsListen:           "",
@@ -286,6 +423,12 @@ func DefaultConfig() Config {
 		IdleStopInterval:        1 * time.Minute,
 		IdleStopMinutesDefault:  30,
 		IdleStopCPUThreshold:    0.05,
+		UsageSampleInterval:     time.Minute,
+		UsagePriceCurrency:      "USD",
+		OIDCUsernameClaim:       "preferred_username",
+		OIDCGroupsClaim:         "groups",
+		OIDCTokenTTL:            time.Hour,
+		OIDCSigningKeyPath:      filepath.Join(dataDir, "token-issuer.key"),
 		ProxmoxBackend:          "shell",
 		ProxmoxCloneMode:        "linked",
 		ProxmoxAPIURL:           "https://localhost:8006",
@@ -338,6 +481,18 @@ func Load(path string) (Config, error) {
 	if fileCfg.DataDir != "" && fileCfg.ArtifactDir == "" {
 		cfg.ArtifactDir = filepath.Join(cfg.DataDir, "artifacts")
 	}
+	if fileCfg.DataDir != "" && fileCfg.BackupDir == "" {
+		cfg.BackupDir = filepath.Join(cfg.DataDir, "backups")
+	}
+	if fileCfg.DataDir != "" && fileCfg.ArchiveDir == "" {
+		cfg.ArchiveDir = filepath.Join(cfg.DataDir, "archive")
+	}
+	if fileCfg.DataDir != "" && fileCfg.TemplateImageDir == "" {
+		cfg.TemplateImageDir = filepath.Join(cfg.DataDir, "images")
+	}
+	if fileCfg.DataDir != "" && fileCfg.IntegrationKeyringPath == "" {
+		cfg.IntegrationKeyringPath = filepath.Join(cfg.DataDir, "integration-keyring.json")
+	}
 	if fileCfg.RunDir != "" && fileCfg.SocketPath == "" {
 		cfg.SocketPath = filepath.Join(cfg.RunDir, "agentlabd.sock")
 	}
@@ -382,6 +537,169 @@ func applyFileConfig(cfg *Config, fileCfg FileConfig) error {
 	if fileCfg.DBPath != "" {
 		cfg.DBPath = fileCfg.DBPath
 	}
+	if fileCfg.BackupDir != "" {
+		cfg.BackupDir = fileCfg.BackupDir
+	}
+	if fileCfg.BackupInterval != "" {
+		interval, err := parseDurationField(fileCfg.BackupInterval, "backup_interval")
+		if err != nil {
+			return err
+		}
+		cfg.BackupInterval = interval
+	}
+	if fileCfg.BackupRetention != nil {
+		cfg.BackupRetention = *fileCfg.BackupRetention
+	}
+	if fileCfg.BackupEncrypt != nil {
+		cfg.BackupEncrypt = *fileCfg.BackupEncrypt
+	}
+	if len(fileCfg.EventRetention) > 0 {
+		retention := make(map[string]time.Duration, len(fileCfg.EventRetention))
+		for domain, value := range fileCfg.EventRetention {
+			domain = strings.ToLower(strings.TrimSpace(domain))
+			if domain == "" {
+				return fmt.Errorf("event_retention domain must not be empty")
+			}
+			dur, err := parseDurationField(value, "event_retention."+domain)
+			if err != nil {
+				return err
+			}
+			retention[domain] = dur
+		}
+		cfg.EventRetention = retention
+	}
+	if fileCfg.MessageRetention != "" {
+		retention, err := parseDurationField(fileCfg.MessageRetention, "message_retention")
+		if err != nil {
+			return err
+		}
+		cfg.MessageRetention = retention
+	}
+	if fileCfg.AuditLogRetention != "" {
+		retention, err := parseDurationField(fileCfg.AuditLogRetention, "audit_log_retention")
+		if err != nil {
+			return err
+		}
+		cfg.AuditLogRetention = retention
+	}
+	if fileCfg.CompactionInterval != "" {
+		interval, err := parseDurationField(fileCfg.CompactionInterval, "compaction_interval")
+		if err != nil {
+			return err
+		}
+		cfg.CompactionInterval = interval
+	}
+	if fileCfg.ArchiveDir != "" {
+		cfg.ArchiveDir = fileCfg.ArchiveDir
+	}
+	if fileCfg.TemplateImageDir != "" {
+		cfg.TemplateImageDir = strings.TrimSpace(fileCfg.TemplateImageDir)
+	}
+	if fileCfg.SecretsVaultAddr != "" {
+		cfg.SecretsVaultAddr = strings.TrimSpace(fileCfg.SecretsVaultAddr)
+	}
+	if fileCfg.SecretsVaultTokenPath != "" {
+		cfg.SecretsVaultTokenPath = fileCfg.SecretsVaultTokenPath
+	}
+	if fileCfg.SecretsVaultNamespace != "" {
+		cfg.SecretsVaultNamespace = strings.TrimSpace(fileCfg.SecretsVaultNamespace)
+	}
+	if fileCfg.SecretsCacheTTL != "" {
+		ttl, err := parseDurationField(fileCfg.SecretsCacheTTL, "secrets_cache_ttl")
+		if err != nil {
+			return err
+		}
+		cfg.SecretsCacheTTL = ttl
+	}
+	if fileCfg.SecretsFileRefs != nil {
+		cfg.SecretsFileRefs = *fileCfg.SecretsFileRefs
+	}
+	if len(fileCfg.SecretsEnvAllowlist) > 0 {
+		cfg.SecretsEnvAllowlist = make([]string, 0, len(fileCfg.SecretsEnvAllowlist))
+		for _, name := range fileCfg.SecretsEnvAllowlist {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.SecretsEnvAllowlist = append(cfg.SecretsEnvAllowlist, name)
+			}
+		}
+	}
+	if fileCfg.SecretsGrantTTL != "" {
+		ttl, err := parseDurationField(fileCfg.SecretsGrantTTL, "secrets_grant_ttl")
+		if err != nil {
+			return err
+		}
+		cfg.SecretsGrantTTL = ttl
+	}
+	if fileCfg.NotifyWebhookURL != "" {
+		cfg.NotifyWebhookURL = strings.TrimSpace(fileCfg.NotifyWebhookURL)
+	}
+	if fileCfg.NotifySlackWebhookURL != "" {
+		cfg.NotifySlackWebhookURL = strings.TrimSpace(fileCfg.NotifySlackWebhookURL)
+	}
+	if fileCfg.TracingEndpoint != "" {
+		cfg.TracingEndpoint = strings.TrimSpace(fileCfg.TracingEndpoint)
+	}
+	if len(fileCfg.TracingHeaders) > 0 {
+		cfg.TracingHeaders = fileCfg.TracingHeaders
+	}
+	if fileCfg.UsageSampleInterval != "" {
+		interval, err := parseDurationField(fileCfg.UsageSampleInterval, "usage_sample_interval")
+		if err != nil {
+			return err
+		}
+		cfg.UsageSampleInterval = interval
+	}
+	if fileCfg.UsagePriceCurrency != "" {
+		cfg.UsagePriceCurrency = strings.TrimSpace(fileCfg.UsagePriceCurrency)
+	}
+	if fileCfg.UsagePriceCoreHour != nil {
+		cfg.UsagePriceCoreHour = *fileCfg.UsagePriceCoreHour
+	}
+	if fileCfg.UsagePriceMemoryGiBHour != nil {
+		cfg.UsagePriceMemoryGiBHour = *fileCfg.UsagePriceMemoryGiBHour
+	}
+	if fileCfg.UsagePriceInputMTok != nil {
+		cfg.UsagePriceInputMTok = *fileCfg.UsagePriceInputMTok
+	}
+	if fileCfg.UsagePriceOutputMTok != nil {
+		cfg.UsagePriceOutputMTok = *fileCfg.UsagePriceOutputMTok
+	}
+	if fileCfg.RBACEnabled != nil {
+		cfg.RBACEnabled = *fileCfg.RBACEnabled
+	}
+	if fileCfg.ApprovalPolicyPath != "" {
+		cfg.ApprovalPolicyPath = strings.TrimSpace(fileCfg.ApprovalPolicyPath)
+	}
+	if fileCfg.AdmissionPolicyPath != "" {
+		cfg.AdmissionPolicyPath = strings.TrimSpace(fileCfg.AdmissionPolicyPath)
+	}
+	if fileCfg.OIDCIssuer != "" {
+		cfg.OIDCIssuer = strings.TrimSpace(fileCfg.OIDCIssuer)
+	}
+	if fileCfg.OIDCClientID != "" {
+		cfg.OIDCClientID = strings.TrimSpace(fileCfg.OIDCClientID)
+	}
+	if fileCfg.OIDCUsernameClaim != "" {
+		cfg.OIDCUsernameClaim = strings.TrimSpace(fileCfg.OIDCUsernameClaim)
+	}
+	if fileCfg.OIDCGroupsClaim != "" {
+		cfg.OIDCGroupsClaim = strings.TrimSpace(fileCfg.OIDCGroupsClaim)
+	}
+	if len(fileCfg.OIDCAdminGroups) > 0 {
+		cfg.OIDCAdminGroups = fileCfg.OIDCAdminGroups
+	}
+	if len(fileCfg.OIDCTeamGroups) > 0 {
+		cfg.OIDCTeamGroups = fileCfg.OIDCTeamGroups
+	}
+	if fileCfg.OIDCTokenTTL != "" {
+		ttl, err := parseDurationField(fileCfg.OIDCTokenTTL, "oidc_token_ttl")
+		if err != nil {
+			return err
+		}
+		cfg.OIDCTokenTTL = ttl
+	}
+	if fileCfg.OIDCSigningKeyPath != "" {
+		cfg.OIDCSigningKeyPath = strings.TrimSpace(fileCfg.OIDCSigningKeyPath)
+	}
 	if fileCfg.BootstrapListen != "" {
 		cfg.BootstrapListen = fileCfg.BootstrapListen
 	}
@@ -566,6 +884,9 @@ func applyFileConfig(cfg *Config, fileCfg FileConfig) error {
 	if fileCfg.IntegrationEncKey != "" {
 		cfg.IntegrationEncKey = fileCfg.IntegrationEncKey
 	}
+	if fileCfg.IntegrationKeyringPath != "" {
+		cfg.IntegrationKeyringPath = fileCfg.IntegrationKeyringPath
+	}
 	if len(fileCfg.IntegrationTargetAllowlist) > 0 {
 		cfg.IntegrationTargetAllowlist = fileCfg.IntegrationTargetAllowlist
 	}
@@ -642,6 +963,30 @@ func (c Config) OrphanGracePeriodOrDefault() time.Duration {
 	return d
 }
 
+// ChangedFields reports which settings differ between a and b, in struct
+// order. Settings are named by their config.yaml key; a field with no file
+// counterpart uses its Go name. Values are never included, so the result is
+// safe to log even when tokens or keys changed.
+func ChangedFields(a, b Config) []string {
+	av := reflect.ValueOf(a)
+	bv := reflect.ValueOf(b)
+	fileType := reflect.TypeOf(FileConfig{})
+	var changed []string
+	for i := 0; i < av.NumField(); i++ {
+		if reflect.DeepEqual(av.Field(i).Interface(), bv.Field(i).Interface()) {
+			continue
+		}
+		name := av.Type().Field(i).Name
+		if field, ok := fileType.FieldByName(name); ok {
+			if key, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); key != "" {
+				name = key
+			}
+		}
+		changed = append(changed, name)
+	}
+	return changed
+}
+
 func (c Config) Validate() error {
 	if c.ConfigPath == "" {
 		return fmt.Errorf("config_path is required")
@@ -759,6 +1104,102 @@ func (c Config) Validate() error {
 	if c.ProvisioningTimeout < 0 {
 		return fmt.Errorf("provisioning_timeout must be non-negative")
 	}
+	if c.BackupInterval < 0 {
+		return fmt.Errorf("backup_interval must be non-negative")
+	}
+	if c.BackupRetention < 0 {
+		return fmt.Errorf("backup_retention must be non-negative")
+	}
+	if c.BackupEncrypt && strings.TrimSpace(c.SecretsAgeKeyPath) == "" {
+		return fmt.Errorf("backup_encrypt requires secrets_age_key_path")
+	}
+	for domain, retention := range c.EventRetention {
+		if retention < 0 {
+			return fmt.Errorf("event_retention.%s must be non-negative", domain)
+		}
+	}
+	if c.MessageRetention < 0 {
+		return fmt.Errorf("message_retention must be non-negative")
+	}
+	if c.AuditLogRetention < 0 {
+		return fmt.Errorf("audit_log_retention must be non-negative")
+	}
+	if c.CompactionInterval < 0 {
+		return fmt.Errorf("compaction_interval must be non-negative")
+	}
+	if c.SecretsCacheTTL < 0 {
+		return fmt.Errorf("secrets_cache_ttl must be non-negative")
+	}
+	if c.SecretsGrantTTL < 0 || c.SecretsGrantTTL > 24*time.Hour {
+		return fmt.Errorf("secrets_grant_ttl must be between 0 and 24h")
+	}
+	if c.SecretsVaultAddr != "" {
+		parsed, err := url.Parse(c.SecretsVaultAddr)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("secrets_vault_addr must be an http or https URL")
+		}
+	}
+	if c.NotifyWebhookURL != "" {
+		if err := validateURL(c.NotifyWebhookURL, "notify_webhook_url"); err != nil {
+			return err
+		}
+	}
+	if c.NotifySlackWebhookURL != "" {
+		if err := validateURL(c.NotifySlackWebhookURL, "notify_slack_webhook_url"); err != nil {
+			return err
+		}
+	}
+	if c.TracingEndpoint != "" {
+		if err := validateURL(c.TracingEndpoint, "tracing_endpoint"); err != nil {
+			return err
+		}
+	}
+	if len(c.TracingHeaders) > 0 && c.TracingEndpoint == "" {
+		return fmt.Errorf("tracing_headers requires tracing_endpoint")
+	}
+	if c.UsageSampleInterval < 0 {
+		return fmt.Errorf("usage_sample_interval must be non-negative")
+	}
+	if c.UsageSampleInterval > 0 && c.UsageSampleInterval < 10*time.Second {
+		return fmt.Errorf("usage_sample_interval must be at least 10s")
+	}
+	for _, price := range []struct {
+		name  string
+		value float64
+	}{
+		{"usage_price_core_hour", c.UsagePriceCoreHour},
+		{"usage_price_memory_gib_hour", c.UsagePriceMemoryGiBHour},
+		{"usage_price_input_mtok", c.UsagePriceInputMTok},
+		{"usage_price_output_mtok", c.UsagePriceOutputMTok},
+	} {
+		if price.value < 0 || math.IsNaN(price.value) || math.IsInf(price.value, 0) {
+			return fmt.Errorf("%s must be a non-negative number", price.name)
+		}
+	}
+	if c.OIDCIssuer != "" {
+		if err := validateURL(c.OIDCIssuer, "oidc_issuer"); err != nil {
+			return err
+		}
+		if strings.TrimSpace(c.OIDCClientID) == "" {
+			return fmt.Errorf("oidc_client_id is required when oidc_issuer is set")
+		}
+		if strings.TrimSpace(c.OIDCUsernameClaim) == "" || strings.TrimSpace(c.OIDCGroupsClaim) == "" {
+			return fmt.Errorf("oidc_username_claim and oidc_groups_claim must not be empty")
+		}
+		if c.OIDCTokenTTL < time.Minute || c.OIDCTokenTTL > 24*time.Hour {
+			return fmt.Errorf("oidc_token_ttl must be between 1m and 24h")
+		}
+		if strings.TrimSpace(c.OIDCSigningKeyPath) == "" {
+			return fmt.Errorf("oidc_signing_key_path is required when oidc_issuer is set")
+		}
+		// Sign-in provisions any user the provider vouches for; without RBAC
+		// every one of them would hold full access.
+		if !c.RBACEnabled {
+			return fmt.Errorf("oidc_issuer requires rbac_enabled: true")
+		}
+	} else if c.OIDCClientID != "" {
+		return fmt.Errorf("oidc_client_id requires oidc_issuer")
+	}
 	if c.IdleStopInterval < 0 {
 		return fmt.Errorf("idle_stop_interval must be non-negative")
 	}
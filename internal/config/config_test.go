@@ -1151,3 +1151,264 @@ func TestLoadConfigIntegrationTargetAllowlist(t *testing.T) {
 		assert.Empty(t, cfg.IntegrationTargetAllowlist)
 	})
 }
+
+func TestChangedFields(t *testing.T) {
+	base := DefaultConfig()
+	assert.Empty(t, ChangedFields(base, base))
+
+	next := base
+	next.IdleStopMinutesDefault = 45
+	next.ArtifactTokenTTLMinutes = 60
+	next.ControlAllowCIDRs = []string{"10.0.0.0/8"}
+	next.ConfigPath = "/tmp/other.yaml"
+	assert.Equal(t, []string{"ConfigPath", "control_allow_cidrs", "artifact_token_ttl_minutes", "idle_stop_minutes_default"}, ChangedFields(base, next))
+}
+
+func TestLoadConfigRetention(t *testing.T) {
+	t.Run("retention set from yaml", func(t *testing.T) {
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		payload := "data_dir: " + filepath.Join(root, "data") + "\n" +
+			"event_retention:\n  default: 720h\n  Sandbox: 2160h\n  job: 0\n" +
+			"message_retention: 168h\naudit_log_retention: 8760h\ncompaction_interval: 15m\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+
+		cfg, err := Load(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]time.Duration{
+			"default": 720 * time.Hour,
+			"sandbox": 2160 * time.Hour,
+			"job":     0,
+		}, cfg.EventRetention)
+		assert.Equal(t, 168*time.Hour, cfg.MessageRetention)
+		assert.Equal(t, 8760*time.Hour, cfg.AuditLogRetention)
+		assert.Equal(t, 15*time.Minute, cfg.CompactionInterval)
+		assert.Equal(t, filepath.Join(root, "data", "archive"), cfg.ArchiveDir)
+	})
+
+	t.Run("retention unset keeps everything", func(t *testing.T) {
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		payload := "data_dir: " + filepath.Join(root, "data") + "\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+
+		cfg, err := Load(configPath)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.EventRetention)
+		assert.Zero(t, cfg.MessageRetention)
+		assert.Zero(t, cfg.AuditLogRetention)
+		assert.Equal(t, time.Hour, cfg.CompactionInterval)
+	})
+
+	t.Run("invalid retention is rejected", func(t *testing.T) {
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		payload := "event_retention:\n  sandbox: 30d\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+
+		_, err := Load(configPath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "event_retention.sandbox")
+	})
+}
+
+func TestLoadConfigSecretProviders(t *testing.T) {
+	t.Run("providers set from yaml", func(t *testing.T) {
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		payload := "secrets_vault_addr: https://vault.internal:8200\n" +
+			"secrets_vault_token_path: /etc/agentlab/keys/vault-token\n" +
+			"secrets_vault_namespace: platform\n" +
+			"secrets_cache_ttl: 30s\n" +
+			"secrets_grant_ttl: 15m\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+
+		cfg, err := Load(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Minute, cfg.SecretsGrantTTL)
+		assert.Equal(t, "https://vault.internal:8200", cfg.SecretsVaultAddr)
+		assert.Equal(t, "/etc/agentlab/keys/vault-token", cfg.SecretsVaultTokenPath)
+		assert.Equal(t, "platform", cfg.SecretsVaultNamespace)
+		assert.Equal(t, 30*time.Second, cfg.SecretsCacheTTL)
+	})
+
+	t.Run("cache ttl defaults to five minutes", func(t *testing.T) {
+		assert.Equal(t, 5*time.Minute, DefaultConfig().SecretsCacheTTL)
+		assert.Equal(t, time.Hour, DefaultConfig().SecretsGrantTTL)
+	})
+
+	t.Run("invalid vault address is rejected", func(t *testing.T) {
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("secrets_vault_addr: vault.internal:8200\n"), 0o600))
+
+		_, err := Load(configPath)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secrets_vault_addr")
+	})
+}
+
+func TestLoadConfigIntegrationKeyringPath(t *testing.T) {
+	t.Run("defaults under data dir", func(t *testing.T) {
+		assert.Equal(t, "/var/lib/agentlab/integration-keyring.json", DefaultConfig().IntegrationKeyringPath)
+
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("data_dir: "+filepath.Join(root, "data")+"\n"), 0o600))
+		cfg, err := Load(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "data", "integration-keyring.json"), cfg.IntegrationKeyringPath)
+	})
+
+	t.Run("explicit path wins", func(t *testing.T) {
+		root := t.TempDir()
+		configPath := filepath.Join(root, "config.yaml")
+		payload := "data_dir: " + filepath.Join(root, "data") + "\n" +
+			"integration_keyring_path: /etc/agentlab/keys/integrations.json\n"
+		require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+		cfg, err := Load(configPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/etc/agentlab/keys/integrations.json", cfg.IntegrationKeyringPath)
+	})
+}
+
+func TestLoadConfigNotifyWebhooks(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	payload := "notify_webhook_url: https://hooks.internal/agentlab\n" +
+		"notify_slack_webhook_url: https://hooks.slack.com/services/T0/B0/x\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+
+	cfg, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.internal/agentlab", cfg.NotifyWebhookURL)
+	assert.Equal(t, "https://hooks.slack.com/services/T0/B0/x", cfg.NotifySlackWebhookURL)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("notify_webhook_url: hooks.internal/agentlab\n"), 0o600))
+	_, err = Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "notify_webhook_url")
+}
+
+func TestLoadConfigTracing(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	payload := "tracing_endpoint: http://127.0.0.1:4318\n" +
+		"tracing_headers:\n  Authorization: Bearer collector\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(payload), 0o600))
+
+	cfg, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:4318", cfg.TracingEndpoint)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer collector"}, cfg.TracingHeaders)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("tracing_endpoint: 127.0.0.1:4318\n"), 0o600))
+	_, err = Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tracing_endpoint")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("tracing_headers:\n  Authorization: x\n"), 0o600))
+	_, err = Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tracing_headers")
+}
+
+func TestLoadConfigUsageSampleInterval(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	assert.Equal(t, time.Minute, DefaultConfig().UsageSampleInterval)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("usage_sample_interval: 30s\n"), 0o600))
+	cfg, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.UsageSampleInterval)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("usage_sample_interval: 0s\n"), 0o600))
+	cfg, err = Load(configPath)
+	require.NoError(t, err)
+	assert.Zero(t, cfg.UsageSampleInterval)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("usage_sample_interval: 2s\n"), 0o600))
+	_, err = Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "usage_sample_interval")
+}
+
+func TestLoadConfigUsagePrices(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	defaults := DefaultConfig()
+	assert.Equal(t, "USD", defaults.UsagePriceCurrency)
+	assert.Zero(t, defaults.UsagePriceCoreHour)
+
+	raw := "usage_price_currency: EUR\nusage_price_core_hour: 0.04\nusage_price_memory_gib_hour: 0.005\nusage_price_input_mtok: 3\nusage_price_output_mtok: 15\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(raw), 0o600))
+	cfg, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", cfg.UsagePriceCurrency)
+	assert.InDelta(t, 0.04, cfg.UsagePriceCoreHour, 1e-12)
+	assert.InDelta(t, 0.005, cfg.UsagePriceMemoryGiBHour, 1e-12)
+	assert.InDelta(t, 3, cfg.UsagePriceInputMTok, 1e-12)
+	assert.InDelta(t, 15, cfg.UsagePriceOutputMTok, 1e-12)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("usage_price_output_mtok: -1\n"), 0o600))
+	_, err = Load(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "usage_price_output_mtok")
+}
+
+func TestLoadConfigRBACEnabled(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	assert.False(t, DefaultConfig().RBACEnabled)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("rbac_enabled: true\n"), 0o600))
+	cfg, err := Load(configPath)
+	require.NoError(t, err)
+	assert.True(t, cfg.RBACEnabled)
+}
+
+func TestLoadConfigOIDC(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	defaults := DefaultConfig()
+	assert.Empty(t, defaults.OIDCIssuer)
+	assert.Equal(t, "preferred_username", defaults.OIDCUsernameClaim)
+	assert.Equal(t, "groups", defaults.OIDCGroupsClaim)
+	assert.Equal(t, time.Hour, defaults.OIDCTokenTTL)
+
+	data := `oidc_issuer: https://idp.example.com/realms/lab
+oidc_client_id: agentlab
+oidc_groups_claim: roles
+oidc_admin_groups: [lab-admins]
+oidc_team_groups:
+  lab-infra: infra
+oidc_token_ttl: 15m
+rbac_enabled: true
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(data), 0o600))
+	cfg, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/realms/lab", cfg.OIDCIssuer)
+	assert.Equal(t, "agentlab", cfg.OIDCClientID)
+	assert.Equal(t, "roles", cfg.OIDCGroupsClaim)
+	assert.Equal(t, []string{"lab-admins"}, cfg.OIDCAdminGroups)
+	assert.Equal(t, map[string]string{"lab-infra": "infra"}, cfg.OIDCTeamGroups)
+	assert.Equal(t, 15*time.Minute, cfg.OIDCTokenTTL)
+
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{"oidc_issuer: https://idp.example.com\n", "oidc_client_id is required"},
+		{"oidc_client_id: agentlab\n", "oidc_client_id requires oidc_issuer"},
+		{"oidc_issuer: ftp://idp.example.com\noidc_client_id: agentlab\n", "oidc_issuer"},
+		{"oidc_issuer: https://idp.example.com\noidc_client_id: agentlab\noidc_token_ttl: 48h\n", "oidc_token_ttl"},
+		{"oidc_issuer: https://idp.example.com\noidc_client_id: agentlab\n", "requires rbac_enabled"},
+	} {
+		require.NoError(t, os.WriteFile(configPath, []byte(tc.data), 0o600))
+		_, err := Load(configPath)
+		require.Error(t, err, tc.data)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+}
@@ -0,0 +1,544 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+// Resource access: team ownership and access grants.
+//
+// Sandboxes, workspaces and sessions record the registered user who created
+// them and, optionally, a team that co-owns them. Under custom roles the owner
+// and every member of the owning team hold admin access to the resource, and
+// an access grant shares it with one more user at read, operate or admin.
+// accessLevelFor maps each resource-bound permission to the lowest level that
+// carries it; permissions on global resources, collections and bulk
+// operations map to none and stay with the roles (rbac.go).
+//
+// The SSH gateway asks /v1/access/check before it proxies a session, so SSH
+// routing honours the same ownership, grants and roles as the API.
+
+// authzResource names a workspace or session (or, for grants, a sandbox) a
+// request acts on. The zero value names nothing.
+type authzResource struct {
+	Type string
+	ID   string
+}
+
+func (res authzResource) String() string {
+	return res.Type + ":" + res.ID
+}
+
+// accessLevelPermissions maps each resource-bound permission to the lowest
+// access level that carries it.
+var accessLevelPermissions = map[string]user.AccessLevel{
+	permSandboxRead:           user.AccessRead,
+	permSandboxEvents:         user.AccessRead,
+	permSandboxUsage:          user.AccessRead,
+	permSandboxRecordingsRead: user.AccessRead,
+	permJobRead:               user.AccessRead,
+	permJobArtifacts:          user.AccessRead,
+	permWorkspaceRead:         user.AccessRead,
+	permWorkspaceCheck:        user.AccessRead,
+	permSessionRead:           user.AccessRead,
+	permMessageRead:           user.AccessRead,
+	permAccessRead:            user.AccessRead,
+
+	permSandboxStart:           user.AccessOperate,
+	permSandboxStop:            user.AccessOperate,
+	permSandboxPause:           user.AccessOperate,
+	permSandboxResume:          user.AccessOperate,
+	permSandboxTouch:           user.AccessOperate,
+	permSandboxLease:           user.AccessOperate,
+	permSandboxDoctor:          user.AccessOperate,
+	permSandboxSnapshot:        user.AccessOperate,
+	permSandboxRecordingsWrite: user.AccessOperate,
+	permSandboxValidate:        user.AccessOperate,
+	permSandboxSSH:             user.AccessOperate,
+	permJobCreate:              user.AccessOperate,
+	permJobValidate:            user.AccessOperate,
+	permJobDoctor:              user.AccessOperate,
+	permWorkspaceAttach:        user.AccessOperate,
+	permWorkspaceDetach:        user.AccessOperate,
+	permWorkspaceSnapshot:      user.AccessOperate,
+	permWorkspaceLease:         user.AccessOperate,
+	permWorkspaceFSCK:          user.AccessOperate,
+	permSessionCreate:          user.AccessOperate,
+	permSessionResume:          user.AccessOperate,
+	permSessionStop:            user.AccessOperate,
+	permSessionFork:            user.AccessOperate,
+	permSessionDoctor:          user.AccessOperate,
+	permExposureCreate:         user.AccessOperate,
+	permExposureDelete:         user.AccessOperate,
+	permMessageSend:            user.AccessOperate,
+
+	permSandboxUpdate:            user.AccessAdmin,
+	permSandboxRevert:            user.AccessAdmin,
+	permSandboxDestroy:           user.AccessAdmin,
+	permSandboxSnapshotRestore:   user.AccessAdmin,
+	permWorkspaceRebind:          user.AccessAdmin,
+	permWorkspaceFork:            user.AccessAdmin,
+	permWorkspaceSnapshotRestore: user.AccessAdmin,
+	permAccessWrite:              user.AccessAdmin,
+}
+
+// accessLevelFor returns the lowest access level carrying perm, or
+// user.AccessNone when resource access never grants it.
+func accessLevelFor(perm string) user.AccessLevel {
+	return accessLevelPermissions[perm]
+}
+
+// parseAccessResource parses "sandbox:<vmid>", "workspace:<id>" or
+// "session:<id>".
+func parseAccessResource(raw string) (authzResource, error) {
+	kind, id, ok := strings.Cut(strings.TrimSpace(raw), ":")
+	if !ok || id == "" {
+		return authzResource{}, errors.New("resource must be sandbox:<vmid>, workspace:<id> or session:<id>")
+	}
+	switch kind {
+	case user.ResourceSandbox:
+		vmid, err := strconv.Atoi(id)
+		if err != nil || vmid <= 0 {
+			return authzResource{}, errors.New("sandbox resource needs a positive vmid")
+		}
+	case user.ResourceWorkspace, user.ResourceSession:
+	default:
+		return authzResource{}, errors.New("resource must be sandbox:<vmid>, workspace:<id> or session:<id>")
+	}
+	return authzResource{Type: kind, ID: id}, nil
+}
+
+// lookupResourceOwnership resolves a resource to its canonical ID, owner and
+// owning team. Workspaces and sessions may be named by ID or name, like their
+// routes. It returns sql.ErrNoRows when the resource does not exist.
+func lookupResourceOwnership(ctx context.Context, store *db.Store, res authzResource) (authzResource, string, string, error) {
+	if store == nil {
+		return authzResource{}, "", "", errors.New("db store is nil")
+	}
+	switch res.Type {
+	case user.ResourceSandbox:
+		vmid, err := strconv.Atoi(res.ID)
+		if err != nil {
+			return authzResource{}, "", "", sql.ErrNoRows
+		}
+		sb, err := store.GetSandbox(ctx, vmid)
+		if err != nil {
+			return authzResource{}, "", "", err
+		}
+		return res, sb.Owner, sb.Team, nil
+	case user.ResourceWorkspace:
+		ws, err := store.GetWorkspace(ctx, res.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			ws, err = store.GetWorkspaceByName(ctx, res.ID)
+		}
+		if err != nil {
+			return authzResource{}, "", "", err
+		}
+		return authzResource{Type: res.Type, ID: ws.ID}, ws.Owner, ws.Team, nil
+	case user.ResourceSession:
+		sess, err := store.GetSession(ctx, res.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			sess, err = store.GetSessionByName(ctx, res.ID)
+		}
+		if err != nil {
+			return authzResource{}, "", "", err
+		}
+		return authzResource{Type: res.Type, ID: sess.ID}, sess.Owner, sess.Team, nil
+	}
+	return authzResource{}, "", "", sql.ErrNoRows
+}
+
+// sharedWithCaller reports whether a confined caller holds access to a
+// workspace or session. Listings show such resources even when the sandbox
+// they resolve to is outside the caller's roles. Sandbox-scoped tokens stay
+// bounded by their scope.
+func sharedWithCaller(r *http.Request, res authzResource) bool {
+	ctx := r.Context()
+	if id := auth.FromContext(ctx); id != nil && id.Token != nil && len(id.Token.Claims.Scope) > 0 {
+		return false
+	}
+	policy := confinedPolicy(ctx)
+	return policy != nil && policy.resourceAccess(ctx, res) != user.AccessNone
+}
+
+// ownershipFor validates the owner and team a create request asks for and
+// returns the ones to record. The owner defaults to the calling user; only
+// trusted callers on the local socket (the SSH gateway) may name another. A
+// registered non-admin caller may only hand a resource to a team they belong
+// to. On failure it has written the error response.
+func (api *ControlAPI) ownershipFor(w http.ResponseWriter, r *http.Request, owner, team string) (string, string, bool) {
+	ctx := r.Context()
+	owner = strings.TrimSpace(owner)
+	team = strings.TrimSpace(team)
+	if owner == "" {
+		owner = callerUserID(ctx)
+	} else {
+		if auth.FromContext(ctx) != nil {
+			writeError(w, http.StatusForbidden, "owner can only be set on the local socket")
+			return "", "", false
+		}
+		if api.users == nil {
+			writeError(w, http.StatusBadRequest, "user registry unavailable")
+			return "", "", false
+		}
+		if _, err := api.users.Store().GetUser(ctx, owner); err != nil {
+			writeError(w, http.StatusBadRequest, "unknown owner")
+			return "", "", false
+		}
+	}
+	if team != "" && !api.checkTeam(w, r, team) {
+		return "", "", false
+	}
+	return owner, team, true
+}
+
+// checkTeam validates that team exists and that a registered non-admin caller
+// belongs to it. On failure it has written the error response.
+func (api *ControlAPI) checkTeam(w http.ResponseWriter, r *http.Request, team string) bool {
+	ctx := r.Context()
+	if api.users == nil {
+		writeError(w, http.StatusBadRequest, "teams are unavailable")
+		return false
+	}
+	if _, err := api.users.Store().GetTeam(ctx, team); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusBadRequest, "unknown team")
+			return false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load team")
+		return false
+	}
+	caller := callerUserID(ctx)
+	if caller == "" || api.users.IsAdmin(ctx, caller) {
+		return true
+	}
+	member, err := api.users.IsTeamMember(ctx, team, caller)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load team")
+		return false
+	}
+	if !member {
+		writeError(w, http.StatusForbidden, "not a member of team "+team)
+		return false
+	}
+	return true
+}
+
+// accessResolver returns the sandbox a resource resolves to for the
+// authorize helpers, and the resource itself when it carries its own
+// ownership.
+func (api *ControlAPI) accessResolver(ctx context.Context, res authzResource) (func() int, authzResource) {
+	switch res.Type {
+	case user.ResourceSandbox:
+		vmid, _ := strconv.Atoi(res.ID)
+		return func() int { return vmid }, authzResource{}
+	case user.ResourceWorkspace:
+		return func() int { return api.workspaceSandboxVMID(ctx, res.ID) }, res
+	case user.ResourceSession:
+		return func() int { return api.sessionSandboxVMID(ctx, res.ID) }, res
+	}
+	return nil, authzResource{}
+}
+
+func (api *ControlAPI) handleAccess(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		raw := strings.TrimSpace(r.URL.Query().Get("resource"))
+		if raw == "" {
+			// Every grant on every resource: global.
+			if !authorizeStandalone(w, r, permAccessRead, true) {
+				return
+			}
+			api.listAccess(w, r, authzResource{})
+			return
+		}
+		res, err := parseAccessResource(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		resolve, target := api.accessResolver(r.Context(), res)
+		if !api.authorizeResource(w, r, permAccessRead, resolve, target) {
+			return
+		}
+		api.listAccess(w, r, res)
+	case http.MethodPost:
+		var req V1AccessGrantRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeJSONDecodeError(w, err)
+			return
+		}
+		res, err := parseAccessResource(req.Resource)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		resolve, target := api.accessResolver(r.Context(), res)
+		if !api.authorizeResource(w, r, permAccessWrite, resolve, target) {
+			return
+		}
+		api.grantAccess(w, r, res, req)
+	default:
+		writeMethodNotAllowed(w, []string{http.MethodGet, http.MethodPost})
+	}
+}
+
+func (api *ControlAPI) handleAccessByID(w http.ResponseWriter, r *http.Request) {
+	parts := pathTail(r, "/v1/access/")
+	if len(parts) != 1 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch parts[0] {
+	case "team":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, []string{http.MethodPost})
+			return
+		}
+		api.handleAccessTeam(w, r)
+		return
+	case "check":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, []string{http.MethodGet})
+			return
+		}
+		api.handleAccessCheck(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, []string{http.MethodDelete})
+		return
+	}
+	if api.users == nil {
+		writeError(w, http.StatusServiceUnavailable, "access grants unavailable")
+		return
+	}
+	ctx := r.Context()
+	grant, err := api.users.GetAccessGrant(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deny before revealing whether the grant exists.
+			if authorizeChecked(w, r, permAccessWrite, false) {
+				writeError(w, http.StatusNotFound, "access grant not found")
+			}
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load access grant")
+		return
+	}
+	res := authzResource{Type: grant.ResourceType, ID: grant.ResourceID}
+	resolve, target := api.accessResolver(ctx, res)
+	if !api.authorizeResource(w, r, permAccessWrite, resolve, target) {
+		return
+	}
+	if err := api.users.RevokeAccess(ctx, id, callerUserID(ctx)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "access grant not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to revoke access")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "deleted", "id": id})
+}
+
+func (api *ControlAPI) listAccess(w http.ResponseWriter, r *http.Request, res authzResource) {
+	if api.users == nil {
+		writeError(w, http.StatusServiceUnavailable, "access grants unavailable")
+		return
+	}
+	ctx := r.Context()
+	resp := V1AccessResponse{Grants: []V1AccessGrant{}}
+	if res.ID != "" {
+		canonical, owner, team, err := lookupResourceOwnership(ctx, api.store, res)
+		if err != nil {
+			writeAccessResourceError(w, err)
+			return
+		}
+		res = canonical
+		resp.Resource, resp.Owner, resp.Team = res.String(), owner, team
+	}
+	grants, err := api.users.ListAccessGrants(ctx, res.Type, res.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list access grants")
+		return
+	}
+	for _, g := range grants {
+		resp.Grants = append(resp.Grants, accessGrantToV1(g))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (api *ControlAPI) grantAccess(w http.ResponseWriter, r *http.Request, res authzResource, req V1AccessGrantRequest) {
+	if api.users == nil {
+		writeError(w, http.StatusServiceUnavailable, "access grants unavailable")
+		return
+	}
+	ctx := r.Context()
+	res, _, _, err := lookupResourceOwnership(ctx, api.store, res)
+	if err != nil {
+		writeAccessResourceError(w, err)
+		return
+	}
+	grant, err := api.users.GrantAccess(ctx, user.AccessGrant{
+		ResourceType: res.Type,
+		ResourceID:   res.ID,
+		UserID:       strings.TrimSpace(req.User),
+		Level:        user.AccessLevel(strings.TrimSpace(req.Level)),
+	}, callerUserID(ctx))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, accessGrantToV1(grant))
+}
+
+// handleAccessTeam hands a resource to a team, or back to its owner alone
+// when team is empty.
+func (api *ControlAPI) handleAccessTeam(w http.ResponseWriter, r *http.Request) {
+	var req V1AccessTeamRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	res, err := parseAccessResource(req.Resource)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx := r.Context()
+	resolve, target := api.accessResolver(ctx, res)
+	if !api.authorizeResource(w, r, permAccessWrite, resolve, target) {
+		return
+	}
+	team := strings.TrimSpace(req.Team)
+	if team != "" && !api.checkTeam(w, r, team) {
+		return
+	}
+	res, owner, _, err := lookupResourceOwnership(ctx, api.store, res)
+	if err != nil {
+		writeAccessResourceError(w, err)
+		return
+	}
+	switch res.Type {
+	case user.ResourceSandbox:
+		vmid, _ := strconv.Atoi(res.ID)
+		err = api.store.UpdateSandboxOwnership(ctx, vmid, owner, team)
+	case user.ResourceWorkspace:
+		err = api.store.UpdateWorkspaceOwnership(ctx, res.ID, owner, team)
+	case user.ResourceSession:
+		err = api.store.UpdateSessionOwnership(ctx, res.ID, owner, team)
+	}
+	if err != nil {
+		writeAccessResourceError(w, err)
+		return
+	}
+	if api.users != nil {
+		_ = api.users.RecordAction(ctx, callerUserID(ctx), "access.team", res.String(), "team="+team)
+	}
+	writeJSON(w, http.StatusOK, V1AccessResponse{Resource: res.String(), Owner: owner, Team: team, Grants: []V1AccessGrant{}})
+}
+
+// handleAccessCheck answers whether the user holding an SSH key fingerprint,
+// or the user with a given ID, may use a permission on a sandbox, the way the
+// API would decide for that user's own requests. The SSH gateway and the
+// dashboard call it over the local socket. An unknown user ID is denied,
+// unlike an unregistered fingerprint.
+func (api *ControlAPI) handleAccessCheck(w http.ResponseWriter, r *http.Request) {
+	if !authorizeStandalone(w, r, permAccessRead, true) {
+		return
+	}
+	query := r.URL.Query()
+	fingerprint := strings.TrimSpace(query.Get("fingerprint"))
+	userID := strings.TrimSpace(query.Get("user"))
+	if (fingerprint == "") == (userID == "") {
+		writeError(w, http.StatusBadRequest, "exactly one of fingerprint or user is required")
+		return
+	}
+	perm := strings.TrimSpace(query.Get("permission"))
+	if perm == "" {
+		perm = permSandboxSSH
+	}
+	if !slices.Contains(permissionCatalog, perm) {
+		writeError(w, http.StatusBadRequest, "unknown permission")
+		return
+	}
+	vmid := 0
+	if raw := strings.TrimSpace(query.Get("vmid")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "vmid must be a positive integer")
+			return
+		}
+		vmid = parsed
+	}
+	resp := V1AccessCheckResponse{Allowed: true, Permission: perm}
+	if api.rbac == nil {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	ctx := r.Context()
+	var policy *rbacPolicy
+	var err error
+	if userID != "" {
+		policy, err = api.rbac.policyForUser(ctx, userID)
+	} else {
+		policy, err = api.rbac.policyFor(ctx, fingerprint)
+	}
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "authorization unavailable")
+		return
+	}
+	if policy == nil && userID != "" {
+		resp.Allowed = false
+	}
+	if policy != nil {
+		resp.User = policy.userID
+		if policy.confined && !policy.allows(perm, true) {
+			if vmid > 0 {
+				resp.Allowed = policy.allowsSandbox(ctx, perm, vmid)
+			} else {
+				resp.Allowed = policy.allows(perm, false)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeAccessResourceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "failed to load resource")
+}
+
+func accessGrantToV1(g user.AccessGrant) V1AccessGrant {
+	return V1AccessGrant{
+		ID:        g.ID,
+		Resource:  g.Resource(),
+		User:      g.UserID,
+		Level:     string(g.Level),
+		GrantedBy: g.GrantedBy,
+		CreatedAt: g.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,257 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/integrations"
+)
+
+// AdminAPI exposes daemon administration endpoints on the control API.
+//
+// Reloading touches every profile consumer and the daemon's settings, so it is
+// a global operation: sandbox-scoped tokens are refused and other tokens need
+// the admin.reload permission. Backups and restores are global in the same
+// way and need admin.backup and admin.restore. Exporting events, messages and
+// the audit log needs admin.events.export, and rotating the integration key
+// needs admin.rotate_key.
+type AdminAPI struct {
+	reloader  ConfigReloader
+	backups   *BackupManager
+	retention *RetentionManager
+	keys      *IntegrationKeyRotator
+}
+
+// NewAdminAPI creates a new admin API handler.
+func NewAdminAPI(reloader ConfigReloader) *AdminAPI {
+	return &AdminAPI{reloader: reloader}
+}
+
+// WithBackupManager enables the backup and restore endpoints.
+func (api *AdminAPI) WithBackupManager(backups *BackupManager) *AdminAPI {
+	if api == nil || backups == nil {
+		return api
+	}
+	api.backups = backups
+	return api
+}
+
+// WithRetentionManager enables the event export endpoint.
+func (api *AdminAPI) WithRetentionManager(retention *RetentionManager) *AdminAPI {
+	if api == nil || retention == nil {
+		return api
+	}
+	api.retention = retention
+	return api
+}
+
+// WithIntegrationKeyRotator enables the integration key rotation endpoint.
+func (api *AdminAPI) WithIntegrationKeyRotator(keys *IntegrationKeyRotator) *AdminAPI {
+	if api == nil || keys == nil {
+		return api
+	}
+	api.keys = keys
+	return api
+}
+
+// Register registers admin API routes on the given mux.
+func (api *AdminAPI) Register(mux *http.ServeMux) {
+	if api == nil || mux == nil {
+		return
+	}
+	mux.HandleFunc("/v1/admin/reload", api.handleReload)
+	mux.HandleFunc("/v1/admin/backups", api.handleBackups)
+	mux.HandleFunc("/v1/admin/restore", api.handleRestore)
+	mux.HandleFunc("/v1/admin/events/export", api.handleEventsExport)
+	mux.HandleFunc("/v1/admin/rotate-key", api.handleRotateKey)
+}
+
+func (api *AdminAPI) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+		return
+	}
+	if !authorizeStandalone(w, r, permAdminReload, true) {
+		return
+	}
+	if api.reloader == nil {
+		writeError(w, http.StatusServiceUnavailable, "config reload unavailable")
+		return
+	}
+	result, err := api.reloader.Reload(r.Context(), ReloadTriggerAPI)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "config reload rejected: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, V1ConfigReloadResponse(result))
+}
+
+func (api *AdminAPI) handleBackups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+	default:
+		writeMethodNotAllowed(w, []string{http.MethodGet, http.MethodPost})
+		return
+	}
+	if !authorizeStandalone(w, r, permAdminBackup, true) {
+		return
+	}
+	if api.backups == nil {
+		writeError(w, http.StatusServiceUnavailable, "backups unavailable")
+		return
+	}
+	if r.Method == http.MethodGet {
+		backups, err := api.backups.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, V1BackupsResponse{Backups: backups})
+		return
+	}
+	result, err := api.backups.Backup(r.Context(), BackupTriggerAPI)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "backup failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, V1BackupResponse(result))
+}
+
+func (api *AdminAPI) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+		return
+	}
+	if !authorizeStandalone(w, r, permAdminRestore, true) {
+		return
+	}
+	if api.backups == nil {
+		writeError(w, http.StatusServiceUnavailable, "backups unavailable")
+		return
+	}
+	var req V1RestoreRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Backup) == "" {
+		writeError(w, http.StatusBadRequest, "backup is required")
+		return
+	}
+	result, err := api.backups.StageRestore(r.Context(), req.Backup)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "restore rejected: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, V1RestoreResponse(result))
+}
+
+func (api *AdminAPI) handleRotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+		return
+	}
+	if !authorizeStandalone(w, r, permAdminRotateKey, true) {
+		return
+	}
+	if api.keys == nil {
+		writeError(w, http.StatusServiceUnavailable, "integration key rotation unavailable")
+		return
+	}
+	result, err := api.keys.Rotate(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "key rotation failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, V1RotateKeyResponse(result))
+}
+
+// handleEventsExport streams archived and live records as JSONL. Once the
+// stream has started an error can no longer change the status code, so it is
+// reported as a final record of type "error".
+func (api *AdminAPI) handleEventsExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if !authorizeStandalone(w, r, permAdminEventsExport, true) {
+		return
+	}
+	if api.retention == nil {
+		writeError(w, http.StatusServiceUnavailable, "event export unavailable")
+		return
+	}
+	var since time.Time
+	if raw := strings.TrimSpace(r.URL.Query().Get("since")); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = parsed.UTC()
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if _, err := api.retention.Export(r.Context(), since, w); err != nil {
+		_ = json.NewEncoder(w).Encode(V1ArchiveRecord{Type: ArchiveRecordError, Error: err.Error()})
+	}
+}
+
+// V1ConfigReloadResponse is the JSON body returned by POST /v1/admin/reload.
+type V1ConfigReloadResponse ConfigReloadResult
+
+// V1BackupResponse is the JSON body returned by POST /v1/admin/backups.
+type V1BackupResponse BackupResult
+
+// V1BackupsResponse is the JSON body returned by GET /v1/admin/backups.
+type V1BackupsResponse struct {
+	Backups []BackupInfo `json:"backups"`
+}
+
+// V1RestoreRequest names the backup to restore: a path, or a file name in
+// backup_dir.
+type V1RestoreRequest struct {
+	Backup string `json:"backup"`
+}
+
+// V1RestoreResponse is the JSON body returned by POST /v1/admin/restore.
+type V1RestoreResponse RestoreResult
+
+// V1RotateKeyResponse is the JSON body returned by POST /v1/admin/rotate-key.
+type V1RotateKeyResponse integrations.RotateResult
+
+// V1ArchiveRecord is one line of an archive file and of the JSONL stream
+// returned by GET /v1/admin/events/export. Exactly one of Event, Message or
+// Audit is set, matching Type.
+type V1ArchiveRecord struct {
+	Type      string         `json:"type"`
+	Timestamp string         `json:"ts,omitempty"`
+	Event     *V1Event       `json:"event,omitempty"`
+	Message   *V1Message     `json:"message,omitempty"`
+	Audit     *V1AuditRecord `json:"audit,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// V1AuditRecord is an audit log entry in an archive or export.
+type V1AuditRecord struct {
+	ID        int64  `json:"id"`
+	UserID    string `json:"user_id,omitempty"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	Timestamp string `json:"ts"`
+}
+
+func auditRecordToV1(record db.AuditRecord) V1AuditRecord {
+	return V1AuditRecord{
+		ID:        record.ID,
+		UserID:    record.UserID,
+		Action:    record.Action,
+		Resource:  record.Resource,
+		Detail:    record.Detail,
+		Timestamp: record.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,422 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/agentlab/agentlab/internal/integrations"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+const (
+	admissionKindSandbox = "sandbox"
+	admissionKindJob     = "job"
+
+	// admissionIssueCode marks preflight errors raised by admission rules.
+	admissionIssueCode = "policy_violation"
+)
+
+// AdmissionRequest describes a sandbox or job about to be created, or a
+// sandbox whose lease is being extended, with profile defaults applied.
+type AdmissionRequest struct {
+	Kind        string    // "sandbox" or "job"
+	UserID      string    // registered caller; "" for trusted callers
+	Role        user.Role // role of UserID; "" for trusted callers
+	Profile     string
+	NetworkMode string   // resolved from the profile
+	Tags        []string // normalized sandbox tags; a job's sandbox has none
+	TTLMinutes  int      // 0 means the lease never expires
+	Mode        string   // job mode; "" for a sandbox without a job
+	RepoURL     string   // job repository; "" for a sandbox without a job
+}
+
+// AdmissionController decides whether a sandbox or job may be created. It
+// returns one error issue per violated rule; none admits the request.
+type AdmissionController interface {
+	Admit(ctx context.Context, req AdmissionRequest) []V1PreflightIssue
+}
+
+// AdmissionPolicy holds organization rules for creates, read from
+// admission_policy_path:
+//
+//	rules:
+//	  - name: prod-data-offline
+//	    when: {tags: [prod-data]}
+//	    require: {network_modes: ["off", allowlist]}
+//	  - name: ttl-cap
+//	    when: {roles: [user]}
+//	    require: {max_ttl: 8h}
+//
+// A rule applies when every condition in when holds, and is violated when a
+// condition in require does not. A rule without require denies whatever
+// when matches. Every violated rule is reported.
+type AdmissionPolicy struct {
+	Rules []AdmissionRule `yaml:"rules"`
+}
+
+// AdmissionRule is one named rule of the admission policy. Message, when
+// set, replaces the generated explanation of a violation.
+type AdmissionRule struct {
+	Name    string          `yaml:"name"`
+	Message string          `yaml:"message"`
+	When    AdmissionMatch  `yaml:"when"`
+	Require *AdmissionMatch `yaml:"require"`
+}
+
+// AdmissionMatch lists conditions on a request. Each list holds when the
+// request's value is one of its entries; profiles and repo_urls entries are
+// path.Match patterns, and tags holds when any of the request's tags is
+// listed. max_ttl holds for a TTL no longer than it.
+//
+// A condition on a value the request does not carry (modes or repo_urls on a
+// sandbox without a job) never holds in when and is skipped in require. A job's
+// sandbox is created untagged, so tags never holds for a job in when and
+// fails for it in require.
+// Trusted callers have no role: roles never holds for them in when, and they
+// satisfy it in require.
+type AdmissionMatch struct {
+	Kinds        []string      `yaml:"kinds"`
+	Roles        []string      `yaml:"roles"`
+	Profiles     []string      `yaml:"profiles"`
+	NetworkModes []string      `yaml:"network_modes"`
+	Tags         []string      `yaml:"tags"`
+	Modes        []string      `yaml:"modes"`
+	RepoURLs     []string      `yaml:"repo_urls"`
+	MaxTTL       time.Duration `yaml:"max_ttl"`
+}
+
+// LoadAdmissionPolicy reads the policy file at path. An empty path yields an
+// empty policy, which admits everything.
+func LoadAdmissionPolicy(path string) (AdmissionPolicy, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return AdmissionPolicy{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AdmissionPolicy{}, fmt.Errorf("read admission policy: %w", err)
+	}
+	policy, err := parseAdmissionPolicy(data)
+	if err != nil {
+		return AdmissionPolicy{}, fmt.Errorf("admission policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func parseAdmissionPolicy(data []byte) (AdmissionPolicy, error) {
+	var policy AdmissionPolicy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return AdmissionPolicy{}, err
+	}
+	names := make(map[string]bool, len(policy.Rules))
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if err := rule.normalize(); err != nil {
+			if rule.Name != "" {
+				return AdmissionPolicy{}, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			return AdmissionPolicy{}, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if names[rule.Name] {
+			return AdmissionPolicy{}, fmt.Errorf("rule %s is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return policy, nil
+}
+
+func (r *AdmissionRule) normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Message = strings.TrimSpace(r.Message)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := r.When.normalize(); err != nil {
+		return fmt.Errorf("when: %w", err)
+	}
+	if r.Require == nil {
+		if r.When.empty() {
+			return errors.New("when or require is required")
+		}
+		return nil
+	}
+	if err := r.Require.normalize(); err != nil {
+		return fmt.Errorf("require: %w", err)
+	}
+	if r.Require.empty() {
+		return errors.New("require needs at least one condition")
+	}
+	return nil
+}
+
+func (m *AdmissionMatch) normalize() error {
+	for _, list := range []*[]string{&m.Kinds, &m.Roles, &m.Profiles, &m.NetworkModes, &m.Modes, &m.RepoURLs} {
+		for i, value := range *list {
+			(*list)[i] = strings.TrimSpace(value)
+		}
+	}
+	for _, kind := range m.Kinds {
+		if kind != admissionKindSandbox && kind != admissionKindJob {
+			return fmt.Errorf("kind %q must be sandbox or job", kind)
+		}
+	}
+	for _, role := range m.Roles {
+		if role != string(user.RoleAdmin) && role != string(user.RoleUser) {
+			return fmt.Errorf("role %q must be admin or user", role)
+		}
+	}
+	for _, mode := range m.NetworkModes {
+		if _, err := normalizeNetworkMode(mode); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range append(slices.Clone(m.Profiles), m.RepoURLs...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	if len(m.Tags) > 0 {
+		tags, err := integrations.NormalizeTags(m.Tags)
+		if err != nil {
+			return err
+		}
+		m.Tags = tags
+	}
+	if m.MaxTTL < 0 || (m.MaxTTL > 0 && m.MaxTTL < time.Minute) {
+		return errors.New("max_ttl must be at least 1m")
+	}
+	return nil
+}
+
+func (m AdmissionMatch) empty() bool {
+	return len(m.Kinds) == 0 && len(m.Roles) == 0 && len(m.Profiles) == 0 && len(m.NetworkModes) == 0 &&
+		len(m.Tags) == 0 && len(m.Modes) == 0 && len(m.RepoURLs) == 0 && m.MaxTTL == 0
+}
+
+// admissionCheck is one condition of a match evaluated against a request.
+type admissionCheck struct {
+	field   string
+	skipped bool // the request does not carry the value
+	ok      bool
+	message string
+}
+
+func (m AdmissionMatch) checks(req AdmissionRequest) []admissionCheck {
+	var checks []admissionCheck
+	if len(m.Kinds) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "policy",
+			ok:      slices.Contains(m.Kinds, req.Kind),
+			message: fmt.Sprintf("%s creation is not allowed", req.Kind),
+		})
+	}
+	if len(m.Roles) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "policy",
+			skipped: req.Role == "",
+			ok:      slices.Contains(m.Roles, string(req.Role)),
+			message: fmt.Sprintf("requires role %s", strings.Join(m.Roles, " or ")),
+		})
+	}
+	if len(m.Profiles) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "profile",
+			skipped: req.Profile == "",
+			ok:      matchesAnyPattern(m.Profiles, req.Profile),
+			message: fmt.Sprintf("profile %q is not allowed", req.Profile),
+		})
+	}
+	if len(m.NetworkModes) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "profile",
+			skipped: req.NetworkMode == "",
+			ok:      slices.Contains(m.NetworkModes, req.NetworkMode),
+			message: fmt.Sprintf("profile %q uses network mode %s; allowed: %s", req.Profile, req.NetworkMode, strings.Join(m.NetworkModes, ", ")),
+		})
+	}
+	if len(m.Tags) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "tags",
+			ok:      slices.ContainsFunc(req.Tags, func(tag string) bool { return slices.Contains(m.Tags, tag) }),
+			message: fmt.Sprintf("tags must include one of: %s", strings.Join(m.Tags, ", ")),
+		})
+	}
+	if len(m.Modes) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "mode",
+			skipped: req.Mode == "",
+			ok:      slices.Contains(m.Modes, req.Mode),
+			message: fmt.Sprintf("mode %q is not allowed", req.Mode),
+		})
+	}
+	if len(m.RepoURLs) > 0 {
+		checks = append(checks, admissionCheck{
+			field:   "repo_url",
+			skipped: req.RepoURL == "",
+			ok:      matchesAnyPattern(m.RepoURLs, req.RepoURL),
+			message: fmt.Sprintf("repo_url %q does not match an approved pattern", req.RepoURL),
+		})
+	}
+	if m.MaxTTL > 0 {
+		maxMinutes := int(m.MaxTTL / time.Minute)
+		checks = append(checks, admissionCheck{
+			field:   "ttl_minutes",
+			ok:      req.TTLMinutes > 0 && req.TTLMinutes <= maxMinutes,
+			message: fmt.Sprintf("ttl_minutes must be set and at most %d", maxMinutes),
+		})
+	}
+	return checks
+}
+
+func matchesAnyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate reports the rule's violation by req, if any.
+func (r AdmissionRule) evaluate(req AdmissionRequest) (V1PreflightIssue, bool) {
+	field := "policy"
+	for _, check := range r.When.checks(req) {
+		if check.skipped || !check.ok {
+			return V1PreflightIssue{}, false
+		}
+		if field == "policy" {
+			field = check.field
+		}
+	}
+	if r.Require == nil {
+		return r.issue(field, "not allowed"), true
+	}
+	for _, check := range r.Require.checks(req) {
+		if !check.skipped && !check.ok {
+			return r.issue(check.field, check.message), true
+		}
+	}
+	return V1PreflightIssue{}, false
+}
+
+func (r AdmissionRule) issue(field, message string) V1PreflightIssue {
+	if r.Message != "" {
+		message = r.Message
+	}
+	return V1PreflightIssue{Code: admissionIssueCode, Field: field, Message: r.Name + ": " + message}
+}
+
+// Admit reports every rule req violates.
+func (p AdmissionPolicy) Admit(_ context.Context, req AdmissionRequest) []V1PreflightIssue {
+	var issues []V1PreflightIssue
+	for _, rule := range p.Rules {
+		if issue, violated := rule.evaluate(req); violated {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// PolicyAdmission admits creates against the admission policy file, which
+// config reloads replace.
+type PolicyAdmission struct {
+	policy atomic.Pointer[AdmissionPolicy]
+}
+
+// NewPolicyAdmission creates an admission controller enforcing policy.
+func NewPolicyAdmission(policy AdmissionPolicy) *PolicyAdmission {
+	a := &PolicyAdmission{}
+	a.SetPolicy(policy)
+	return a
+}
+
+// SetPolicy replaces the policy for requests received from now on.
+func (a *PolicyAdmission) SetPolicy(policy AdmissionPolicy) {
+	if a == nil {
+		return
+	}
+	a.policy.Store(&policy)
+}
+
+// Admit evaluates req against the current policy.
+func (a *PolicyAdmission) Admit(ctx context.Context, req AdmissionRequest) []V1PreflightIssue {
+	if a == nil {
+		return nil
+	}
+	return a.policy.Load().Admit(ctx, req)
+}
+
+// admit runs the admission controller, if any, for the caller of ctx.
+func (api *ControlAPI) admit(ctx context.Context, req AdmissionRequest) []V1PreflightIssue {
+	if api.admission == nil {
+		return nil
+	}
+	req.UserID = callerUserID(ctx)
+	req.Role = callerRole(ctx)
+	if profile, ok := api.profile(req.Profile); ok {
+		req.NetworkMode = profileNetworkMode(profile)
+	}
+	return api.admission.Admit(ctx, req)
+}
+
+// sandboxAdmission describes a sandbox of profile for admission. A sandbox
+// started for jobID carries the job's mode and repository.
+func (api *ControlAPI) sandboxAdmission(ctx context.Context, profile string, tags []string, ttlMinutes int, jobID string) AdmissionRequest {
+	req := AdmissionRequest{Kind: admissionKindSandbox, Profile: profile, Tags: tags, TTLMinutes: ttlMinutes}
+	if jobID == "" || api.store == nil {
+		return req
+	}
+	if job, err := api.store.GetJob(ctx, jobID); err == nil {
+		req.Mode = strings.TrimSpace(job.Mode)
+		if req.Mode == "" {
+			req.Mode = defaultJobMode
+		}
+		req.RepoURL = job.RepoURL
+	}
+	return req
+}
+
+// admitLeaseRenew admits extending sandbox vmid's lease by ttlMinutes as if
+// the sandbox were created now with that TTL, so max_ttl bounds renewals too.
+func (api *ControlAPI) admitLeaseRenew(ctx context.Context, vmid, ttlMinutes int) []V1PreflightIssue {
+	if api.admission == nil || api.store == nil {
+		return nil
+	}
+	sb, err := api.store.GetSandbox(ctx, vmid)
+	if err != nil {
+		// The renew itself reports a missing sandbox.
+		return nil
+	}
+	jobID := ""
+	if job, err := api.store.GetJobBySandboxVMID(ctx, vmid); err == nil {
+		jobID = job.ID
+	}
+	return api.admit(ctx, api.sandboxAdmission(ctx, sb.Profile, parseTags(sb.Tags), ttlMinutes, jobID))
+}
+
+// writeAdmissionDenied rejects a create that violates admission rules,
+// listing every violation.
+func writeAdmissionDenied(w http.ResponseWriter, issues []V1PreflightIssue) {
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.Message)
+	}
+	resp := daemonErrorResponse(http.StatusForbidden, "denied by admission policy: "+strings.Join(messages, "; "))
+	resp.Code = daemonErrorCodePolicyDenied
+	resp.Issues = issues
+	writeJSON(w, http.StatusForbidden, resp)
+}
@@ -0,0 +1,266 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+const testAdmissionPolicy = `
+rules:
+  - name: prod-data-offline
+    when: {kinds: [sandbox], tags: [prod-data]}
+    require: {network_modes: ["off", allowlist]}
+  - name: ttl-cap
+    when: {roles: [user]}
+    require: {max_ttl: 8h}
+  - name: approved-repos
+    when: {kinds: [job]}
+    require: {repo_urls: ["https://git.example.com/*"]}
+  - name: gpu-admins
+    when: {profiles: [gpu-*]}
+    require: {roles: [admin]}
+  - name: no-dangerous-jobs
+    message: dangerous mode is disabled on this host
+    when: {modes: [dangerous]}
+`
+
+func TestAdmissionPolicyReportsViolatedRules(t *testing.T) {
+	policy, err := parseAdmissionPolicy([]byte(testAdmissionPolicy))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  AdmissionRequest
+		want []string
+	}{
+		{
+			name: "prod-data sandbox on nat",
+			req:  AdmissionRequest{Kind: admissionKindSandbox, Profile: "base", NetworkMode: "nat", Tags: []string{"prod-data"}},
+			want: []string{"prod-data-offline: profile \"base\" uses network mode nat; allowed: off, allowlist"},
+		},
+		{
+			name: "prod-data sandbox offline",
+			req:  AdmissionRequest{Kind: admissionKindSandbox, Profile: "base", NetworkMode: "off", Tags: []string{"web", "prod-data"}},
+		},
+		{
+			name: "user without ttl",
+			req:  AdmissionRequest{Kind: admissionKindSandbox, Role: user.RoleUser, Profile: "base", NetworkMode: "nat"},
+			want: []string{"ttl-cap: ttl_minutes must be set and at most 480"},
+		},
+		{
+			name: "user within ttl",
+			req:  AdmissionRequest{Kind: admissionKindSandbox, Role: user.RoleUser, Profile: "base", NetworkMode: "nat", TTLMinutes: 480},
+		},
+		{
+			name: "trusted caller is not a user",
+			req:  AdmissionRequest{Kind: admissionKindSandbox, Profile: "gpu-a100", NetworkMode: "nat"},
+		},
+		{
+			name: "gpu profile for a user",
+			req:  AdmissionRequest{Kind: admissionKindSandbox, Role: user.RoleUser, Profile: "gpu-a100", NetworkMode: "nat", TTLMinutes: 60},
+			want: []string{"gpu-admins: requires role admin"},
+		},
+		{
+			name: "job from an unapproved repo in dangerous mode",
+			req:  AdmissionRequest{Kind: admissionKindJob, Role: user.RoleAdmin, Profile: "base", Mode: "dangerous", RepoURL: "https://github.com/x/y"},
+			want: []string{
+				"approved-repos: repo_url \"https://github.com/x/y\" does not match an approved pattern",
+				"no-dangerous-jobs: dangerous mode is disabled on this host",
+			},
+		},
+		{
+			name: "job from an approved repo",
+			req:  AdmissionRequest{Kind: admissionKindJob, Role: user.RoleAdmin, Profile: "base", Mode: "safe", RepoURL: "https://git.example.com/team"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range policy.Admit(context.Background(), tt.req) {
+				assert.Equal(t, admissionIssueCode, issue.Code)
+				got = append(got, issue.Message)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	empty, err := parseAdmissionPolicy(nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Admit(context.Background(), AdmissionRequest{Kind: admissionKindJob}))
+}
+
+func TestAdmissionPolicyRejectsInvalidRules(t *testing.T) {
+	for _, raw := range []string{
+		"rules:\n  - when: {tags: [x]}\n",
+		"rules:\n  - name: a\n",
+		"rules:\n  - name: a\n    when: {tags: [x]}\n    require: {}\n",
+		"rules:\n  - name: a\n    when: {kinds: [vm]}\n",
+		"rules:\n  - name: a\n    when: {roles: [owner]}\n",
+		"rules:\n  - name: a\n    require: {network_modes: [wide]}\n",
+		"rules:\n  - name: a\n    require: {profiles: [\"[\"]}\n",
+		"rules:\n  - name: a\n    require: {max_ttl: 10s}\n",
+		"rules:\n  - name: a\n    when: {tags: [x]}\n  - name: a\n    when: {tags: [y]}\n",
+		"rules:\n  - name: a\n    unless: {tags: [x]}\n",
+	} {
+		_, err := parseAdmissionPolicy([]byte(raw))
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestAdmissionDeniesCreatesAndFlagsPlans(t *testing.T) {
+	store := newTestStore(t)
+	profiles := map[string]models.Profile{
+		"base": {Name: "base", TemplateVM: 9000, RawYAML: "name: base\ntemplate_vmid: 9000\n"},
+	}
+	policy, err := parseAdmissionPolicy([]byte(testAdmissionPolicy))
+	require.NoError(t, err)
+	admission := NewPolicyAdmission(policy)
+	api := NewControlAPI(store, profiles, nil, nil, nil, "", log.New(io.Discard, "", 0)).WithAdmission(admission)
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/v1/sandboxes/validate-plan", `{"profile":"base","tags":["prod-data"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var plan V1SandboxValidatePlanResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+	assert.False(t, plan.OK)
+	assert.Contains(t, plan.Errors, V1PreflightIssue{
+		Code:    admissionIssueCode,
+		Field:   "profile",
+		Message: "prod-data-offline: profile \"base\" uses network mode nat; allowed: off, allowlist",
+	})
+
+	rec = post("/v1/sandboxes", `{"profile":"base","tags":["prod-data"]}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	var denied V1ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &denied))
+	assert.Equal(t, daemonErrorCodePolicyDenied, denied.Code)
+	assert.Equal(t, "denied by admission policy: prod-data-offline: profile \"base\" uses network mode nat; allowed: off, allowlist", denied.Error)
+	require.Len(t, denied.Issues, 1)
+	sandboxes, err := store.ListSandboxes(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, sandboxes, "a denied create allocates nothing")
+
+	rec = post("/v1/jobs", `{"repo_url":"https://github.com/x/y","profile":"base","task":"t","mode":"safe"}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &denied))
+	require.Len(t, denied.Issues, 1)
+	assert.Equal(t, "repo_url", denied.Issues[0].Field)
+
+	// A reload that drops the rules admits the same create.
+	admission.SetPolicy(AdmissionPolicy{})
+	rec = post("/v1/jobs/validate-plan", `{"repo_url":"https://github.com/x/y","profile":"base","task":"t","mode":"safe"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), admissionIssueCode)
+}
+
+func TestAdmissionCoversRenewalsRebindsAndSessions(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	profiles := map[string]models.Profile{
+		"base":  {Name: "base", TemplateVM: 9000, RawYAML: "name: base\ntemplate_vmid: 9000\n"},
+		"short": {Name: "short", TemplateVM: 9000, RawYAML: "name: short\ntemplate_vmid: 9000\nbehavior:\n  ttl_minutes_default: 30\n"},
+	}
+	policy, err := parseAdmissionPolicy([]byte(`
+rules:
+  - name: sandbox-ttl-cap
+    when: {kinds: [sandbox]}
+    require: {max_ttl: 1h}
+`))
+	require.NoError(t, err)
+	backend := &stubBackend{}
+	api := NewControlAPI(store, profiles, NewSandboxManager(store, backend, logger), NewWorkspaceManager(store, backend, logger), &JobOrchestrator{}, "", logger).
+		WithAdmission(NewPolicyAdmission(policy))
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	lease := time.Now().UTC().Add(30 * time.Minute).Truncate(time.Second)
+	require.NoError(t, store.CreateSandbox(ctx, models.Sandbox{
+		VMID:          120,
+		Name:          "renew-sb",
+		Profile:       "short",
+		State:         models.SandboxRunning,
+		LeaseExpires:  lease,
+		CreatedAt:     time.Now().UTC(),
+		LastUpdatedAt: time.Now().UTC(),
+	}))
+	rec := post("/v1/sandboxes/120/lease/renew", `{"ttl_minutes":600}`)
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "sandbox-ttl-cap: ttl_minutes must be set and at most 60")
+	sb, err := store.GetSandbox(ctx, 120)
+	require.NoError(t, err)
+	assert.True(t, sb.LeaseExpires.Equal(lease), "a denied renewal leaves the lease alone")
+	rec = post("/v1/sandboxes/120/lease/renew", `{"ttl_minutes":45}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = post("/v1/workspaces/ws-1/rebind", `{"profile":"base"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	rec = post("/v1/sessions", `{"name":"s1","profile":"base","workspace_id":"ws-1"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	_, err = store.GetSessionByName(ctx, "s1")
+	assert.Error(t, err, "a denied session is not recorded")
+	rec = post("/v1/sessions", `{"name":"s1","profile":"short","workspace_id":"ws-1"}`)
+	assert.NotEqual(t, http.StatusForbidden, rec.Code, "the profile's default TTL is within the cap")
+
+	require.NoError(t, store.CreateSession(ctx, models.Session{
+		ID:          "sess-1",
+		Name:        "s2",
+		WorkspaceID: "ws-1",
+		Profile:     "base",
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}))
+	rec = post("/v1/sessions/sess-1/resume", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+}
+
+func TestAdmissionEvaluatesJobsAsUntagged(t *testing.T) {
+	policy, err := parseAdmissionPolicy([]byte(`
+rules:
+  - name: tagged-only
+    when: {profiles: [base]}
+    require: {tags: [approved]}
+  - name: no-dangerous
+    when: {modes: [dangerous]}
+`))
+	require.NoError(t, err)
+
+	issues := policy.Admit(context.Background(), AdmissionRequest{Kind: admissionKindJob, Profile: "base", Mode: "safe"})
+	require.Len(t, issues, 1)
+	assert.Equal(t, "tagged-only: tags must include one of: approved", issues[0].Message)
+
+	assert.Empty(t, policy.Admit(context.Background(), AdmissionRequest{Kind: admissionKindSandbox, Profile: "base", Tags: []string{"approved"}}),
+		"a sandbox without a job carries no mode")
+	issues = policy.Admit(context.Background(), AdmissionRequest{Kind: admissionKindSandbox, Profile: "base", Tags: []string{"approved"}, Mode: "dangerous"})
+	require.Len(t, issues, 1)
+	assert.Equal(t, "no-dangerous", strings.SplitN(issues[0].Message, ":", 2)[0])
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/agentlab/agentlab/internal/pool"
 	"github.com/agentlab/agentlab/internal/proxmox"
 	"github.com/agentlab/agentlab/internal/proxy"
+	"github.com/agentlab/agentlab/internal/user"
 )
 
 const (
@@ -74,6 +75,7 @@ var (
 		"job":       {},
 		"workspace": {},
 		"session":   {},
+		"sandbox":   {},
 	}
 	// exposureNamePattern constrains exposure names to a subdomain-safe
 	// charset so a name cannot carry markup into the dashboard or collide
@@ -91,6 +93,7 @@ var (
 //   - POST   /v1/jobs/validate-plan         - Validate a job plan without creating resources
 //   - GET    /v1/jobs/{id}            - Get job details
 //   - GET    /v1/jobs/{id}/artifacts  - List job artifacts
+//   - GET    /v1/jobs/{id}/diff       - Job patch with per-file stats
 //   - GET    /v1/jobs/{id}/artifacts/download - Download job artifacts
 //   - POST   /v1/jobs/{id}/doctor     - Create job doctor bundle
 //   - GET    /v1/profiles             - List available profiles
@@ -115,9 +118,15 @@ var (
 //   - POST   /v1/sandboxes/{vmid}/destroy   - Destroy a sandbox
 //   - POST   /v1/sandboxes/{vmid}/lease/renew - Renew sandbox lease
 //   - GET    /v1/sandboxes/{vmid}/events - Get sandbox events
+//   - GET    /v1/sandboxes/{vmid}/usage - Get sandbox resource usage history
 //   - POST   /v1/sandboxes/{vmid}/doctor - Create sandbox doctor bundle
+//   - GET    /v1/sandboxes/{vmid}/recordings      - List session recordings
+//   - POST   /v1/sandboxes/{vmid}/recordings      - Upload a session recording
+//   - GET    /v1/sandboxes/{vmid}/recordings/{id} - Download a session recording
 //   - POST   /v1/messages             - Post a message to the messagebox
 //   - GET    /v1/messages             - List messagebox entries by scope
+//   - GET    /v1/messages/questions   - List agent questions awaiting an answer
+//   - POST   /v1/messages/{id}/reply  - Answer an agent's question
 //   - POST   /v1/sandboxes/prune      - Prune orphaned sandboxes
 //   - POST   /v1/workspaces           - Create a workspace
 //   - GET    /v1/workspaces           - List workspaces
@@ -144,13 +153,14 @@ var (
 //   - DELETE /v1/exposures/{name}     - Delete exposure
 type ControlAPI struct {
 	store              *db.Store
-	profiles           map[string]models.Profile
+	profiles           *ProfileRegistry
 	backend            proxmox.Backend
 	sandboxManager     *SandboxManager
 	workspaceMgr       *WorkspaceManager
 	jobOrchestrator    *JobOrchestrator
 	exposurePublisher  ExposurePublisher
 	artifactRoot       string
+	artifactMaxBytes   int64
 	metrics            *Metrics
 	metricsEnabled     bool
 	agentSubnet        string
@@ -162,6 +172,10 @@ type ControlAPI struct {
 	skillBundleVersion string
 	now                func() time.Time
 	resourcePool       *pool.Pool
+	questions          *Questions
+	users              *user.Registry
+	rbac               *RBAC
+	admission          AdmissionController
 	// workspaceWaitPoll overrides the initial backoff between lease-acquire
 	// attempts during a workspace wait (0 => package default). Tests set this
 	// small so the wait path runs deterministically fast.
@@ -195,7 +209,7 @@ func NewControlAPI(store *db.Store, profiles map[string]models.Profile, manager
 	}
 	return &ControlAPI{
 		store:           store,
-		profiles:        profiles,
+		profiles:        NewProfileRegistry(profiles),
 		sandboxManager:  manager,
 		workspaceMgr:    workspaceMgr,
 		jobOrchestrator: orchestrator,
@@ -205,6 +219,15 @@ func NewControlAPI(store *db.Store, profiles map[string]models.Profile, manager
 	}
 }
 
+// WithProfileRegistry shares a profile registry so config reloads reach the API.
+func (api *ControlAPI) WithProfileRegistry(profiles *ProfileRegistry) *ControlAPI {
+	if api == nil || profiles == nil {
+		return api
+	}
+	api.profiles = profiles
+	return api
+}
+
 // WithMetricsEnabled annotates the status response with metrics listener state.
 func (api *ControlAPI) WithMetricsEnabled(enabled bool) *ControlAPI {
 	if api == nil {
@@ -296,6 +319,48 @@ func (api *ControlAPI) WithResourcePool(p *pool.Pool) *ControlAPI {
 	return api
 }
 
+// WithQuestions enables the open-question inbox and replies to agent
+// questions.
+func (api *ControlAPI) WithQuestions(questions *Questions) *ControlAPI {
+	if api == nil {
+		return api
+	}
+	api.questions = questions
+	return api
+}
+
+// WithAccessControl enables team ownership and access grants on sandboxes,
+// workspaces and sessions, and the access check the SSH gateway asks before
+// proxying a session.
+func (api *ControlAPI) WithAccessControl(users *user.Registry, rbac *RBAC) *ControlAPI {
+	if api == nil {
+		return api
+	}
+	api.users = users
+	api.rbac = rbac
+	return api
+}
+
+// WithAdmission checks sandbox and job creates, and their validate-plan
+// previews, against organization rules.
+func (api *ControlAPI) WithAdmission(admission AdmissionController) *ControlAPI {
+	if api == nil {
+		return api
+	}
+	api.admission = admission
+	return api
+}
+
+// WithArtifactMaxBytes caps session recordings uploaded through the control
+// API, matching the limit on guest artifact uploads.
+func (api *ControlAPI) WithArtifactMaxBytes(maxBytes int64) *ControlAPI {
+	if api == nil {
+		return api
+	}
+	api.artifactMaxBytes = maxBytes
+	return api
+}
+
 // WithBackgroundRunner sets the daemon lifecycle runner used so synchronous
 // provisioning inside an HTTP handler is not coupled to the request's lifetime
 // but is still cancelled and awaited at shutdown (review H2).
@@ -315,8 +380,10 @@ func (api *ControlAPI) WithBackgroundRunner(r BackgroundRunner) *ControlAPI {
 //
 // After registration, the following routes are available:
 //   - /v1/jobs - Job management
+//   - /v1/job-groups - Matrix (fan-out) job groups
 //   - /v1/sandboxes - Sandbox management
 //   - /v1/workspaces - Workspace management
+//   - /v1/access - Team ownership and access grants
 //
 // --- scope-resolver audit (review T33, 2026-08-14) ---
 //
@@ -328,6 +395,8 @@ func (api *ControlAPI) WithBackgroundRunner(r BackgroundRunner) *ControlAPI {
 //	POST /v1/jobs                        jobTargetScopeVMID           Body names workspace_id or session_id; the job runs in that workspace's sandbox. Resolved.
 //	POST /v1/jobs/validate-plan          jobTargetScopeVMID           Same body shape as job create; the plan discloses target state. Resolved.
 //	GET  /v1/jobs/{id}[/...]             jobSandboxVMID               Path id resolves to the job's sandbox. Resolved (pre-existing).
+//	POST /v1/job-groups                  none                         Base spec may not name a workspace or session; every child gets a fresh sandbox.
+//	GET  /v1/job-groups/{id}[/...]       none (list)                  Spans many sandboxes; children filtered by sandboxScopeFilter.
 //	POST /v1/sandboxes                   sandboxCreateVMID            Body may carry vmid. Resolved (review F1).
 //	GET  /v1/sandboxes                   none (list)                  Response filtered by sandboxScopeFilter.
 //	GET  /v1/sandboxes/inventory         none (list)                  Response filtered; unmanaged records dropped (review F10).
@@ -343,11 +412,18 @@ func (api *ControlAPI) WithBackgroundRunner(r BackgroundRunner) *ControlAPI {
 //	POST /v1/sessions                    sessionCreateScopeVMID       Body names workspace_id; the session runs in that workspace's sandbox. Resolved.
 //	GET  /v1/sessions                    none (list)                  Response filtered by current vmid.
 //	/v1/sessions/{id}[/...]              sessionSandboxVMID           Path id resolves to the session's sandbox. Resolved (pre-existing).
+//	GET  /v1/access?resource=            accessResolver               Query names the resource; workspaces and sessions resolve to their sandbox. Resolved.
+//	GET  /v1/access                      none (global)                Every grant on every resource: any scoped token is denied outright.
+//	POST /v1/access, /v1/access/team     accessResolver               Body names the resource. Resolved.
+//	DELETE /v1/access/{id}               accessResolver               Path id resolves to the grant's resource. Resolved.
+//	GET  /v1/access/check                none (global)                Answers for another user's key: any scoped token is denied outright.
 //	POST /v1/exposures                   exposureCreateVMID           Body carries vmid. Resolved (review F2).
 //	GET  /v1/exposures                   none (list)                  Response filtered by the bound sandbox.
 //	DELETE /v1/exposures/{name}          exposureSandboxVMID          Path name resolves to the bound sandbox. Resolved (pre-existing).
 //	POST /v1/messages                    messageBodyScopeVMID         Body scope (job, workspace, or session) resolves to a sandbox. Resolved.
 //	GET  /v1/messages                    messageQueryScopeVMID        Query scope resolves the same way. Resolved.
+//	GET  /v1/messages/questions          none (list)                  Response filtered by each question's scope sandbox.
+//	POST /v1/messages/{id}/reply         questionScopeVMID            Path id resolves to the question's scope, then its sandbox. Resolved.
 //	GET  /v1/profiles, /v1/schema        none                         No sandbox target exists.
 //	GET  /v1/status, /v1/host            none                         Host-wide reads; no sandbox target.
 //
@@ -368,11 +444,14 @@ func (api *ControlAPI) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/jobs/validate-plan", api.handleJobValidatePlan)
 	mux.HandleFunc("/v1/jobs", api.handleJobs)
 	mux.HandleFunc("/v1/jobs/", api.handleJobByID)
+	mux.HandleFunc("/v1/job-groups", api.handleJobGroups)
+	mux.HandleFunc("/v1/job-groups/", api.handleJobGroupByID)
 	mux.HandleFunc("/v1/profiles", api.handleProfiles)
 	mux.HandleFunc("/v1/schema", api.handleSchema)
 	mux.HandleFunc("/v1/status", api.handleStatus)
 	mux.HandleFunc("/v1/host", api.handleHost)
 	mux.HandleFunc("/v1/messages", api.handleMessages)
+	mux.HandleFunc("/v1/messages/", api.handleMessageByID)
 	mux.HandleFunc("/v1/sandboxes/inventory", api.handleSandboxInventory)
 	mux.HandleFunc("/v1/sandboxes/reconcile", api.handleSandboxReconcile)
 	mux.HandleFunc("/v1/sandboxes/validate-plan", api.handleSandboxValidatePlan)
@@ -386,6 +465,8 @@ func (api *ControlAPI) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/sessions/", api.handleSessionByID)
 	mux.HandleFunc("/v1/exposures", api.handleExposures)
 	mux.HandleFunc("/v1/exposures/", api.handleExposureByName)
+	mux.HandleFunc("/v1/access", api.handleAccess)
+	mux.HandleFunc("/v1/access/", api.handleAccessByID)
 }
 
 func (api *ControlAPI) handleJobs(w http.ResponseWriter, r *http.Request) {
@@ -608,13 +689,20 @@ func (api *ControlAPI) handleJobValidatePlan(w http.ResponseWriter, r *http.Requ
 				resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "precondition_missing", Field: "controller_url", Message: "controller URL unavailable"})
 			}
 			if len(resp.Errors) == 0 {
-				if err := api.jobOrchestrator.backend.ValidateTemplate(ctx, proxmox.VMID(profile.TemplateVM)); err != nil {
+				resolved, err := resolveProfileTemplate(ctx, api.store, profile, "")
+				if err != nil {
+					resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "template_unresolved", Field: "profile", Message: err.Error()})
+				} else if err := api.jobOrchestrator.backend.ValidateTemplate(ctx, proxmox.VMID(resolved.TemplateVM)); err != nil {
 					resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "template_validation_failed", Field: "profile", Message: fmt.Sprintf("template validation failed: %s", err.Error())})
 				}
 			}
 		}
 	}
 
+	if profile.Name != "" {
+		resp.Errors = append(resp.Errors, api.admit(ctx, AdmissionRequest{Kind: admissionKindJob, Profile: profile.Name, TTLMinutes: ttlMinutes, Mode: req.Mode, RepoURL: req.RepoURL})...)
+	}
+
 	if req.WorkspaceCreate != nil {
 		if req.WorkspaceCreate.Name == "" {
 			resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "missing_required_field", Field: "workspace_create.name", Message: "workspace_create.name is required"})
@@ -780,6 +868,9 @@ func (api *ControlAPI) handleSandboxValidatePlan(w http.ResponseWriter, r *http.
 		value := ttlMinutes
 		plan.TTLMinutes = &value
 	}
+	if profile.Name != "" {
+		resp.Errors = append(resp.Errors, api.admit(ctx, api.sandboxAdmission(ctx, profile.Name, normalizedTags, ttlMinutes, strings.TrimSpace(req.JobID)))...)
+	}
 
 	if req.Workspace != nil {
 		if api.workspaceMgr == nil {
@@ -851,7 +942,10 @@ func (api *ControlAPI) handleSandboxValidatePlan(w http.ResponseWriter, r *http.
 				resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "precondition_missing", Field: "controller_url", Message: "controller URL unavailable"})
 			}
 			if len(resp.Errors) == 0 {
-				if err := api.jobOrchestrator.backend.ValidateTemplate(ctx, proxmox.VMID(profile.TemplateVM)); err != nil {
+				resolved, err := resolveProfileTemplate(ctx, api.store, profile, "")
+				if err != nil {
+					resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "template_unresolved", Field: "profile", Message: err.Error()})
+				} else if err := api.jobOrchestrator.backend.ValidateTemplate(ctx, proxmox.VMID(resolved.TemplateVM)); err != nil {
 					resp.Errors = append(resp.Errors, V1PreflightIssue{Code: "template_validation_failed", Field: "profile", Message: fmt.Sprintf("template validation failed: %s", err.Error())})
 				}
 			}
@@ -910,6 +1004,14 @@ func (api *ControlAPI) handleJobByID(w http.ResponseWriter, r *http.Request) {
 			api.handleJobArtifactsList(w, r, jobID)
 			return
 		}
+		if parts[1] == "diff" {
+			if r.Method != http.MethodGet {
+				writeMethodNotAllowed(w, []string{http.MethodGet})
+				return
+			}
+			api.handleJobDiff(w, r, jobID)
+			return
+		}
 		if parts[1] == "doctor" {
 			if r.Method != http.MethodPost {
 				writeMethodNotAllowed(w, []string{http.MethodPost})
@@ -994,6 +1096,21 @@ func (api *ControlAPI) handleJobCreate(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "session_id cannot be combined with workspace_create")
 		return
 	}
+	envJSON, err := encodeJobEnv(req.Env)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	deps, status, err := api.resolveJobDependencies(r, req)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	resultOpts, err := normalizeJobResultOptions(req.Result, req.RepoURL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	ctx := r.Context()
 	var resolvedSessionID *string
 	if req.SessionID != nil {
@@ -1055,6 +1172,10 @@ func (api *ControlAPI) handleJobCreate(w http.ResponseWriter, r *http.Request) {
 	} else {
 		ttlMinutes = derefInt(req.TTLMinutes)
 	}
+	if issues := api.admit(ctx, AdmissionRequest{Kind: admissionKindJob, Profile: req.Profile, TTLMinutes: ttlMinutes, Mode: req.Mode, RepoURL: req.RepoURL}); len(issues) > 0 {
+		writeAdmissionDenied(w, issues)
+		return
+	}
 
 	var (
 		workspaceID       *string
@@ -1085,6 +1206,10 @@ func (api *ControlAPI) handleJobCreate(w http.ResponseWriter, r *http.Request) {
 				writeError(w, http.StatusInternalServerError, "failed to create workspace")
 				return
 			}
+			if created, err = api.recordWorkspaceOwnership(ctx, created, callerUserID(ctx), ""); err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to record workspace owner")
+				return
+			}
 			workspace = created
 		} else if req.WorkspaceID != nil {
 			resolved, err := api.workspaceMgr.Resolve(ctx, *req.WorkspaceID)
@@ -1161,10 +1286,21 @@ func (api *ControlAPI) handleJobCreate(w http.ResponseWriter, r *http.Request) {
 			Keepalive:   keepalive,
 			WorkspaceID: workspaceID,
 			SessionID:   resolvedSessionID,
+			EnvJSON:     envJSON,
+			DependsOn:   deps.Parents,
 			Status:      models.JobQueued,
 			CreatedAt:   now,
 			UpdatedAt:   now,
 		}
+		if len(deps.Parents) > 0 {
+			job.DependsCondition = deps.Condition
+			job.ParentArtifacts = deps.ParentArtifacts
+		}
+		job.PublishJSON, err = encodeJobPublish(resultOpts, jobID, job.Ref)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 		createErr = api.store.CreateJob(ctx, job)
 		if createErr == nil {
 			if workspaceID != nil && leaseNonce != "" {
@@ -1189,7 +1325,7 @@ func (api *ControlAPI) handleJobCreate(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "job orchestration unavailable")
 		return
 	}
-	api.jobOrchestrator.Start(job.ID)
+	api.jobOrchestrator.Schedule(ctx, job)
 	writeJSON(w, http.StatusCreated, jobToV1(job))
 }
 
@@ -1233,7 +1369,11 @@ func (api *ControlAPI) handleJobGet(w http.ResponseWriter, r *http.Request, jobI
 			resp.Events = append(resp.Events, eventToV1(ev))
 		}
 	}
-	projection := NewEventProjection()
+	projection, err := loadEventProjection(r.Context(), api.store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build job timeline")
+		return
+	}
 	jobEvents, err := api.store.ListEventsByJobAll(r.Context(), job.ID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to build job timeline")
@@ -1388,20 +1528,23 @@ func (api *ControlAPI) handleProfiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	resp := V1ProfilesResponse{Profiles: []V1Profile{}}
-	if api.profiles == nil {
+	profiles := api.profiles.Snapshot()
+	if profiles == nil {
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
-	names := make([]string, 0, len(api.profiles))
-	for name := range api.profiles {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 	resp.Profiles = make([]V1Profile, 0, len(names))
 	for _, name := range names {
-		profile, ok := api.profiles[name]
-		if !ok {
-			continue
+		profile := profiles[name]
+		// Show the VMID a template reference resolves to right now; an
+		// unresolvable reference is reported as 0.
+		if resolved, err := resolveProfileTemplate(r.Context(), api.store, profile, ""); err == nil {
+			profile = resolved
 		}
 		resp.Profiles = append(resp.Profiles, profileToV1(profile))
 	}
@@ -1433,7 +1576,11 @@ func (api *ControlAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to load recent failures")
 		return
 	}
-	projection := NewEventProjection()
+	projection, err := loadEventProjection(ctx, api.store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load event projection")
+		return
+	}
 	allEvents, err := api.store.ListAllEvents(ctx)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to load event projection")
@@ -1604,6 +1751,89 @@ func (api *ControlAPI) handleMessageList(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// handleMessageByID serves the question inbox and replies to questions.
+func (api *ControlAPI) handleMessageByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/messages/"), "/")
+	if rest == "questions" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, []string{http.MethodGet})
+			return
+		}
+		if !api.authorize(w, r, permMessageRead, nil, false) {
+			return
+		}
+		api.handleQuestionList(w, r)
+		return
+	}
+	idText, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil || id <= 0 || action != "reply" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+		return
+	}
+	// The question's scope resolves to the sandbox that asked it, so a
+	// scoped token can only answer its own sandbox's questions.
+	if !api.authorize(w, r, permMessageSend, func() int { return api.questionScopeVMID(r.Context(), id) }, false) {
+		return
+	}
+	api.handleQuestionReply(w, r, id)
+}
+
+func (api *ControlAPI) handleQuestionList(w http.ResponseWriter, r *http.Request) {
+	if api.questions == nil {
+		writeError(w, http.StatusServiceUnavailable, "questions unavailable")
+		return
+	}
+	questions, err := api.questions.Open(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load questions", err)
+		return
+	}
+	allowed := sandboxScopeFilter(r)
+	resp := V1MessagesResponse{Messages: make([]V1Message, 0, len(questions))}
+	for _, msg := range questions {
+		if allowed != nil && !allowed(api.messageScopeVMID(r.Context(), msg.ScopeType, msg.ScopeID)) {
+			continue
+		}
+		resp.Messages = append(resp.Messages, messageToV1(msg))
+		resp.LastID = msg.ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (api *ControlAPI) handleQuestionReply(w http.ResponseWriter, r *http.Request, id int64) {
+	if api.questions == nil {
+		writeError(w, http.StatusServiceUnavailable, "questions unavailable")
+		return
+	}
+	var req V1MessageReplyRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", err)
+		return
+	}
+	author := strings.TrimSpace(req.Author)
+	if author == "" {
+		author = secretDecider(r)
+	}
+	reply, err := api.questions.Answer(r.Context(), id, req.Text, author)
+	switch {
+	case errors.Is(err, errQuestionNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrMessageAnswered):
+		writeError(w, http.StatusConflict, "question already answered")
+	case isQuestionInputError(err):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "failed to record reply", err)
+	default:
+		writeJSON(w, http.StatusCreated, messageToV1(reply))
+	}
+}
+
 func (api *ControlAPI) handleSandboxes(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -1739,6 +1969,14 @@ func (api *ControlAPI) handleSandboxByID(w http.ResponseWriter, r *http.Request)
 			api.handleSandboxEvents(w, r, vmid)
 			return
 		}
+		if parts[1] == "usage" {
+			if r.Method != http.MethodGet {
+				writeMethodNotAllowed(w, []string{http.MethodGet})
+				return
+			}
+			api.handleSandboxUsage(w, r, vmid)
+			return
+		}
 		if parts[1] == "doctor" {
 			if r.Method != http.MethodPost {
 				writeMethodNotAllowed(w, []string{http.MethodPost})
@@ -1747,7 +1985,28 @@ func (api *ControlAPI) handleSandboxByID(w http.ResponseWriter, r *http.Request)
 			api.handleSandboxDoctor(w, r, vmid)
 			return
 		}
+		if parts[1] == "recordings" {
+			switch r.Method {
+			case http.MethodGet:
+				api.handleSandboxRecordingsList(w, r, vmid)
+				return
+			case http.MethodPost:
+				api.handleSandboxRecordingUpload(w, r, vmid)
+				return
+			default:
+				writeMethodNotAllowed(w, []string{http.MethodGet, http.MethodPost})
+				return
+			}
+		}
 	case 3:
+		if parts[1] == "recordings" {
+			if r.Method != http.MethodGet {
+				writeMethodNotAllowed(w, []string{http.MethodGet})
+				return
+			}
+			api.handleSandboxRecordingDownload(w, r, vmid, parts[2])
+			return
+		}
 		if parts[1] == "lease" && parts[2] == "renew" {
 			if r.Method != http.MethodPost {
 				writeMethodNotAllowed(w, []string{http.MethodPost})
@@ -1796,16 +2055,22 @@ func (api *ControlAPI) handleWorkspaceByID(w http.ResponseWriter, r *http.Reques
 	}
 	id := parts[0]
 	// Authorize before dispatching. The sandbox scope is resolved from the
-	// workspace's attached sandbox (DB lookup, scoped tokens only).
+	// workspace's attached sandbox (DB lookup, scoped tokens only); ownership
+	// and access grants on the workspace itself also admit the caller.
 	if perm := workspaceActionPermission(parts, r.Method); perm != "" {
-		resolve := func() int { return api.workspaceSandboxVMID(r.Context(), id) }
 		if len(parts) == 2 && parts[1] == "attach" && r.Method == http.MethodPost {
 			// Attach names its own target in the request body, so the scope
-			// check resolves that vmid first (review F5).
-			resolve = func() int { return api.workspaceAttachScopeVMID(r.Context(), r, id) }
-		}
-		if !api.authorize(w, r, perm, resolve, false) {
-			return
+			// check resolves that vmid first (review F5). Access to the
+			// workspace alone does not open up the target sandbox.
+			resolve := func() int { return api.workspaceAttachScopeVMID(r.Context(), r, id) }
+			if !api.authorize(w, r, perm, resolve, false) {
+				return
+			}
+		} else {
+			resolve := func() int { return api.workspaceSandboxVMID(r.Context(), id) }
+			if !api.authorizeResource(w, r, perm, resolve, authzResource{Type: user.ResourceWorkspace, ID: id}) {
+				return
+			}
 		}
 	}
 	switch len(parts) {
@@ -1923,9 +2188,11 @@ func (api *ControlAPI) handleSessionByID(w http.ResponseWriter, r *http.Request)
 	}
 	id := parts[0]
 	// Authorize before dispatching. The sandbox scope is resolved from the
-	// session's current sandbox (DB lookup, scoped tokens only).
+	// session's current sandbox (DB lookup, scoped tokens only); ownership and
+	// access grants on the session itself also admit the caller.
 	if perm := sessionActionPermission(parts, r.Method); perm != "" {
-		if !api.authorize(w, r, perm, func() int { return api.sessionSandboxVMID(r.Context(), id) }, false) {
+		resolve := func() int { return api.sessionSandboxVMID(r.Context(), id) }
+		if !api.authorizeResource(w, r, perm, resolve, authzResource{Type: user.ResourceSession, ID: id}) {
 			return
 		}
 	}
@@ -2018,10 +2285,14 @@ func (api *ControlAPI) handleSandboxList(w http.ResponseWriter, r *http.Request)
 	}
 	resp := V1SandboxesResponse{Sandboxes: make([]V1SandboxResponse, 0, len(sandboxes))}
 	allowed := sandboxScopeFilter(r)
+	team := strings.TrimSpace(r.URL.Query().Get("team"))
 	for _, sb := range sandboxes {
 		if allowed != nil && !allowed(sb.VMID) {
 			continue
 		}
+		if team != "" && sb.Team != team {
+			continue
+		}
 		resp.Sandboxes = append(resp.Sandboxes, api.sandboxToV1(sb))
 	}
 	writeJSON(w, http.StatusOK, resp)
@@ -2039,7 +2310,11 @@ func (api *ControlAPI) handleSandboxGet(w http.ResponseWriter, r *http.Request,
 	}
 	resp := api.sandboxToV1(sandbox)
 	resp.Resources = api.loadSandboxResources(r.Context(), vmid)
-	projection := NewEventProjection()
+	projection, err := loadEventProjection(r.Context(), api.store)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build sandbox health")
+		return
+	}
 	sandboxEvents, err := api.store.ListEventsBySandboxAll(r.Context(), vmid)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to build sandbox health")
@@ -2049,6 +2324,11 @@ func (api *ControlAPI) handleSandboxGet(w http.ResponseWriter, r *http.Request,
 	if summary, ok := projection.SandboxHealth[vmid]; ok {
 		resp.Health = &summary
 	}
+	resp.KV, err = sandboxKVEntries(r.Context(), api.store, vmid)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load sandbox status values")
+		return
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -2107,6 +2387,14 @@ func (api *ControlAPI) handleSandboxCreate(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, "vmid must be positive")
 		return
 	}
+	owner, team, ok := api.ownershipFor(w, r, req.Owner, req.Team)
+	if !ok {
+		return
+	}
+	if issues := api.admit(r.Context(), api.sandboxAdmission(r.Context(), req.Profile, tags, ttlMinutes, req.JobID)); len(issues) > 0 {
+		writeAdmissionDenied(w, issues)
+		return
+	}
 
 	if provisionSandbox && api.jobOrchestrator == nil {
 		ctx := r.Context()
@@ -2198,6 +2486,8 @@ func (api *ControlAPI) handleSandboxCreate(w http.ResponseWriter, r *http.Reques
 		Keepalive:     keepalive,
 		LeaseExpires:  leaseExpires,
 		Tags:          integrations.JoinTags(tags),
+		Owner:         owner,
+		Team:          team,
 		CreatedAt:     now,
 		LastUpdatedAt: now,
 	}
@@ -2226,7 +2516,7 @@ func (api *ControlAPI) handleSandboxCreate(w http.ResponseWriter, r *http.Reques
 	if req.VMID != nil {
 		// Reserve pool resources for the explicit VMID; roll back on any failure
 		// so a dropped row cannot leak a phantom allocation (review H3).
-		if err := reservePoolForSandbox(api.resourcePool, sandbox.VMID, sandbox.Name, req.Profile, api.profiles); err != nil {
+		if err := reservePoolForSandbox(api.resourcePool, sandbox.VMID, sandbox.Name, req.Profile, api.profiles.Snapshot()); err != nil {
 			writeError(w, http.StatusConflict, err.Error())
 			return
 		}
@@ -2245,7 +2535,7 @@ func (api *ControlAPI) handleSandboxCreate(w http.ResponseWriter, r *http.Reques
 		// (successfully created) VMID and rolls back on every failure path,
 		// including VMID collisions (review H3).
 		var err error
-		createdSandbox, err = createSandboxWithRetry(ctx, api.store, sandbox, api.resourcePool, api.profiles)
+		createdSandbox, err = createSandboxWithRetry(ctx, api.store, sandbox, api.resourcePool, api.profiles.Snapshot())
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "failed to create sandbox")
 			return
@@ -2378,6 +2668,10 @@ func (api *ControlAPI) handleWorkspaceCreate(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusBadRequest, "size_gb must be positive")
 		return
 	}
+	owner, team, ok := api.ownershipFor(w, r, "", req.Team)
+	if !ok {
+		return
+	}
 	workspace, err := api.workspaceMgr.Create(r.Context(), req.Name, req.Storage, req.SizeGB)
 	if err != nil {
 		switch {
@@ -2388,9 +2682,27 @@ func (api *ControlAPI) handleWorkspaceCreate(w http.ResponseWriter, r *http.Requ
 		}
 		return
 	}
+	workspace, err = api.recordWorkspaceOwnership(r.Context(), workspace, owner, team)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to record workspace owner")
+		return
+	}
 	writeJSON(w, http.StatusCreated, workspaceToV1(workspace))
 }
 
+// recordWorkspaceOwnership stamps a newly created workspace with its owner and
+// team. The workspace manager creates volumes without them.
+func (api *ControlAPI) recordWorkspaceOwnership(ctx context.Context, ws models.Workspace, owner, team string) (models.Workspace, error) {
+	if owner == "" && team == "" {
+		return ws, nil
+	}
+	if err := api.store.UpdateWorkspaceOwnership(ctx, ws.ID, owner, team); err != nil {
+		return ws, err
+	}
+	ws.Owner, ws.Team = owner, team
+	return ws, nil
+}
+
 // workspaceInScope reports whether a scoped caller may see a workspace. An
 // attached workspace belongs to its attachment. A detached workspace keeps
 // belonging to its last attachment, so a foreign detached workspace stays
@@ -2417,8 +2729,13 @@ func (api *ControlAPI) handleWorkspaceList(w http.ResponseWriter, r *http.Reques
 	}
 	resp := V1WorkspacesResponse{Workspaces: make([]V1WorkspaceResponse, 0, len(workspaces))}
 	allowed := sandboxScopeFilter(r)
+	team := strings.TrimSpace(r.URL.Query().Get("team"))
 	for _, ws := range workspaces {
-		if allowed != nil && !workspaceInScope(ws, allowed) {
+		if allowed != nil && !workspaceInScope(ws, allowed) &&
+			!sharedWithCaller(r, authzResource{Type: user.ResourceWorkspace, ID: ws.ID}) {
+			continue
+		}
+		if team != "" && ws.Team != team {
 			continue
 		}
 		resp.Workspaces = append(resp.Workspaces, workspaceToV1(ws))
@@ -2597,10 +2914,29 @@ func (api *ControlAPI) messageScopeVMID(ctx context.Context, scopeType, scopeID
 		return api.workspaceSandboxVMID(ctx, id)
 	case "session":
 		return api.sessionScopeVMID(ctx, id)
+	case "sandbox":
+		vmid, err := strconv.Atoi(id)
+		if err != nil || vmid <= 0 {
+			return 0
+		}
+		return vmid
 	}
 	return 0
 }
 
+// questionScopeVMID resolves the sandbox a question belongs to through its
+// message scope.
+func (api *ControlAPI) questionScopeVMID(ctx context.Context, id int64) int {
+	if api == nil || api.store == nil {
+		return 0
+	}
+	msg, err := api.store.GetMessage(ctx, id)
+	if err != nil {
+		return 0
+	}
+	return api.messageScopeVMID(ctx, msg.ScopeType, msg.ScopeID)
+}
+
 // workspaceAttachScopeVMID resolves the sandbox scope for a workspace attach
 // request: the target vmid from the body when present, otherwise the
 // workspace's own sandbox (review F5).
@@ -2750,11 +3086,22 @@ func (api *ControlAPI) handleWorkspaceRebind(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusBadRequest, "unknown profile")
 		return
 	}
+	ttlMinutes := derefInt(req.TTLMinutes)
 	if profile, ok := api.profile(req.Profile); ok {
 		if err := validateProfileForProvisioning(profile); err != nil {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		appliedTTL, _, err := applyProfileBehaviorDefaults(profile, req.TTLMinutes, nil)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid profile behavior defaults")
+			return
+		}
+		ttlMinutes = appliedTTL
+	}
+	if issues := api.admit(r.Context(), api.sandboxAdmission(r.Context(), req.Profile, nil, ttlMinutes, "")); len(issues) > 0 {
+		writeAdmissionDenied(w, issues)
+		return
 	}
 	result, err := api.jobOrchestrator.RebindWorkspace(r.Context(), id, req.Profile, req.TTLMinutes, req.KeepOld)
 	if err != nil {
@@ -2962,8 +3309,18 @@ func (api *ControlAPI) handleSessionCreate(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, "workspace_id or workspace_create is required")
 		return
 	}
+	owner, team, ok := api.ownershipFor(w, r, "", req.Team)
+	if !ok {
+		return
+	}
 
 	ctx := r.Context()
+	// A session's sandboxes are provisioned by resume with the profile's
+	// defaults; admit them up front so a denied session is never created.
+	if issues := api.admit(ctx, api.sandboxAdmission(ctx, req.Profile, nil, api.profileDefaultTTL(req.Profile), "")); len(issues) > 0 {
+		writeAdmissionDenied(w, issues)
+		return
+	}
 	if _, err := api.store.GetSessionByName(ctx, req.Name); err == nil {
 		writeError(w, http.StatusConflict, "session already exists")
 		return
@@ -2992,6 +3349,10 @@ func (api *ControlAPI) handleSessionCreate(w http.ResponseWriter, r *http.Reques
 			writeError(w, http.StatusInternalServerError, "failed to create workspace")
 			return
 		}
+		if created, err = api.recordWorkspaceOwnership(ctx, created, owner, team); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to record workspace owner")
+			return
+		}
 		workspace = created
 	} else if req.WorkspaceID != nil {
 		resolved, err := api.workspaceMgr.Resolve(ctx, *req.WorkspaceID)
@@ -3052,6 +3413,8 @@ func (api *ControlAPI) handleSessionCreate(w http.ResponseWriter, r *http.Reques
 		CurrentVMID: currentVMID,
 		Profile:     req.Profile,
 		Branch:      req.Branch,
+		Owner:       owner,
+		Team:        team,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
@@ -3082,8 +3445,13 @@ func (api *ControlAPI) handleSessionList(w http.ResponseWriter, r *http.Request)
 	}
 	resp := V1SessionsResponse{Sessions: make([]V1SessionResponse, 0, len(sessions))}
 	allowed := sandboxScopeFilter(r)
+	team := strings.TrimSpace(r.URL.Query().Get("team"))
 	for _, session := range sessions {
-		if allowed != nil && session.CurrentVMID != nil && !allowed(*session.CurrentVMID) {
+		if allowed != nil && session.CurrentVMID != nil && !allowed(*session.CurrentVMID) &&
+			!sharedWithCaller(r, authzResource{Type: user.ResourceSession, ID: session.ID}) {
+			continue
+		}
+		if team != "" && session.Team != team {
 			continue
 		}
 		resp.Sessions = append(resp.Sessions, api.sessionToV1(session))
@@ -3132,6 +3500,10 @@ func (api *ControlAPI) handleSessionResume(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, "session workspace_id is required")
 		return
 	}
+	if issues := api.admit(ctx, api.sandboxAdmission(ctx, session.Profile, nil, api.profileDefaultTTL(session.Profile), "")); len(issues) > 0 {
+		writeAdmissionDenied(w, issues)
+		return
+	}
 	workspace, err := api.workspaceMgr.Resolve(ctx, session.WorkspaceID)
 	if err != nil {
 		if errors.Is(err, ErrWorkspaceNotFound) {
@@ -3353,6 +3725,10 @@ func (api *ControlAPI) handleSessionFork(w http.ResponseWriter, r *http.Request,
 			writeError(w, http.StatusInternalServerError, "failed to create workspace")
 			return
 		}
+		if created, err = api.recordWorkspaceOwnership(ctx, created, callerUserID(ctx), origin.Team); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to record workspace owner")
+			return
+		}
 		workspace = created
 	} else if req.WorkspaceID != nil {
 		resolved, err := api.workspaceMgr.Resolve(ctx, *req.WorkspaceID)
@@ -3415,6 +3791,8 @@ func (api *ControlAPI) handleSessionFork(w http.ResponseWriter, r *http.Request,
 		CurrentVMID: currentVMID,
 		Profile:     profile,
 		Branch:      branch,
+		Owner:       callerUserID(ctx),
+		Team:        origin.Team,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
@@ -4252,6 +4630,10 @@ func (api *ControlAPI) handleSandboxLeaseRenew(w http.ResponseWriter, r *http.Re
 		writeError(w, http.StatusBadRequest, "ttl_minutes must be positive")
 		return
 	}
+	if issues := api.admitLeaseRenew(r.Context(), vmid, req.TTLMinutes); len(issues) > 0 {
+		writeAdmissionDenied(w, issues)
+		return
+	}
 	lease, err := api.sandboxManager.RenewLease(r.Context(), vmid, time.Duration(req.TTLMinutes)*time.Minute)
 	if err != nil {
 		switch {
@@ -4363,10 +4745,11 @@ func (api *ControlAPI) profileExists(name string) bool {
 	if name == "" {
 		return false
 	}
-	if api.profiles == nil {
+	profiles := api.profiles.Snapshot()
+	if profiles == nil {
 		return true
 	}
-	_, ok := api.profiles[name]
+	_, ok := profiles[name]
 	return ok
 }
 
@@ -4374,11 +4757,7 @@ func (api *ControlAPI) profile(name string) (models.Profile, bool) {
 	if name == "" {
 		return models.Profile{}, false
 	}
-	if api.profiles == nil {
-		return models.Profile{}, false
-	}
-	profile, ok := api.profiles[name]
-	return profile, ok
+	return api.profiles.Get(name)
 }
 
 func jobToV1(job models.Job) V1JobResponse {
@@ -4392,6 +4771,9 @@ func jobToV1(job models.Job) V1JobResponse {
 		Keepalive:   job.Keepalive,
 		WorkspaceID: job.WorkspaceID,
 		SessionID:   job.SessionID,
+		GroupID:     job.GroupID,
+		Matrix:      decodeJobMatrix(job.MatrixJSON),
+		DependsOn:   job.DependsOn,
 		Status:      string(job.Status),
 		CreatedAt:   job.CreatedAt.UTC().Format(time.RFC3339Nano),
 		UpdatedAt:   job.UpdatedAt.UTC().Format(time.RFC3339Nano),
@@ -4403,6 +4785,11 @@ func jobToV1(job models.Job) V1JobResponse {
 	if job.SandboxVMID != nil && *job.SandboxVMID > 0 {
 		resp.SandboxVMID = job.SandboxVMID
 	}
+	if len(job.DependsOn) > 0 {
+		resp.DependsCondition = string(job.DependsCondition)
+		resp.ParentArtifacts = job.ParentArtifacts
+	}
+	resp.ResultOptions = decodeJobPublish(job.PublishJSON)
 	if job.ResultJSON != "" {
 		resp.Result = json.RawMessage(job.ResultJSON)
 	}
@@ -4417,6 +4804,8 @@ func (api *ControlAPI) sessionToV1(session models.Session) V1SessionResponse {
 		CurrentVMID: session.CurrentVMID,
 		Profile:     session.Profile,
 		Branch:      session.Branch,
+		Owner:       session.Owner,
+		Team:        session.Team,
 	}
 	if !session.CreatedAt.IsZero() {
 		resp.CreatedAt = session.CreatedAt.UTC().Format(time.RFC3339Nano)
@@ -4538,6 +4927,8 @@ func (api *ControlAPI) sandboxToV1(sb models.Sandbox) V1SandboxResponse {
 		State:         string(sb.State),
 		IP:            sb.IP,
 		WorkspaceID:   sb.WorkspaceID,
+		Owner:         sb.Owner,
+		Team:          sb.Team,
 		Keepalive:     sb.Keepalive,
 		CreatedAt:     sb.CreatedAt.UTC().Format(time.RFC3339Nano),
 		LastUpdatedAt: sb.LastUpdatedAt.UTC().Format(time.RFC3339Nano),
@@ -4629,6 +5020,7 @@ func profileToV1(profile models.Profile) V1Profile {
 	resp := V1Profile{
 		Name:         profile.Name,
 		TemplateVMID: profile.TemplateVM,
+		Template:     profile.Template,
 		Type:         string(profile.Type),
 		Image:        profile.Image,
 	}
@@ -4645,6 +5037,8 @@ func workspaceToV1(ws models.Workspace) V1WorkspaceResponse {
 		Storage:   ws.Storage,
 		VolumeID:  ws.VolumeID,
 		SizeGB:    ws.SizeGB,
+		Owner:     ws.Owner,
+		Team:      ws.Team,
 		CreatedAt: ws.CreatedAt.UTC().Format(time.RFC3339Nano),
 		UpdatedAt: ws.LastUpdated.UTC().Format(time.RFC3339Nano),
 	}
@@ -4801,7 +5195,7 @@ func (api *ControlAPI) buildNetworkModeCounts(ctx context.Context) map[string]in
 		api.logger.Printf("status: list sandboxes for network modes: %v", err)
 		return out
 	}
-	profileModes := buildProfileNetworkModes(api.profiles)
+	profileModes := buildProfileNetworkModes(api.profiles.Snapshot())
 	for _, sb := range sandboxes {
 		mode := profileModes[sb.Profile]
 		if mode == "" {
@@ -4815,18 +5209,39 @@ func (api *ControlAPI) buildNetworkModeCounts(ctx context.Context) map[string]in
 func buildProfileNetworkModes(profiles map[string]models.Profile) map[string]string {
 	out := make(map[string]string, len(profiles))
 	for name, profile := range profiles {
-		mode := defaultNetworkMode
-		spec, err := parseProfileProvisionSpec(profile.RawYAML)
-		if err == nil {
-			if resolved, err := resolveNetworkMode(spec.Network); err == nil && resolved != "" {
-				mode = resolved
-			}
-		}
-		out[name] = mode
+		out[name] = profileNetworkMode(profile)
 	}
 	return out
 }
 
+// profileNetworkMode returns the network mode sandboxes of profile get,
+// falling back to the default when the profile does not set a valid one.
+func profileNetworkMode(profile models.Profile) string {
+	spec, err := parseProfileProvisionSpec(profile.RawYAML)
+	if err != nil {
+		return defaultNetworkMode
+	}
+	if resolved, err := resolveNetworkMode(spec.Network); err == nil && resolved != "" {
+		return resolved
+	}
+	return defaultNetworkMode
+}
+
+// profileDefaultTTL returns the lease TTL, in minutes, that a sandbox of the
+// named profile gets when the caller does not set one. Unknown profiles and
+// profiles without a valid default get 0.
+func (api *ControlAPI) profileDefaultTTL(name string) int {
+	profile, ok := api.profile(name)
+	if !ok {
+		return 0
+	}
+	ttl, _, err := applyProfileBehaviorDefaults(profile, nil, nil)
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
+
 func buildArtifactStatus(root string) V1StatusArtifacts {
 	root = strings.TrimSpace(root)
 	status := V1StatusArtifacts{
@@ -5028,6 +5443,8 @@ func eventToV1(ev db.Event) V1Event {
 		Kind:      ev.Kind,
 		Timestamp: ev.Timestamp.UTC().Format(time.RFC3339Nano),
 		Message:   strings.TrimSpace(ev.Message),
+		TraceID:   ev.TraceID,
+		SpanID:    ev.SpanID,
 	}
 	if ev.SandboxVMID != nil {
 		resp.SandboxVMID = ev.SandboxVMID
@@ -5064,6 +5481,7 @@ func messageToV1(msg db.Message) V1Message {
 		Author:    msg.Author,
 		Kind:      msg.Kind,
 		Text:      msg.Text,
+		ReplyTo:   msg.ReplyTo,
 	}
 	if !msg.Timestamp.IsZero() {
 		resp.Timestamp = msg.Timestamp.UTC().Format(time.RFC3339Nano)
@@ -5085,6 +5503,7 @@ func artifactToV1(artifact db.Artifact) V1Artifact {
 		SizeBytes: artifact.SizeBytes,
 		Sha256:    artifact.Sha256,
 		MIME:      artifact.MIME,
+		Kind:      string(artifact.Kind),
 	}
 	if !artifact.CreatedAt.IsZero() {
 		resp.CreatedAt = artifact.CreatedAt.UTC().Format(time.RFC3339Nano)
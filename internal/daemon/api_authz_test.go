@@ -33,7 +33,7 @@ type authzPlane struct {
 }
 
 // newAuthzPlane seeds two sandboxes (1001 in scope, 1002 out of scope) with a
-// job, workspace, session, and exposure on each, plus pool allocations, an
+// job, workspace, session, exposure, and open question on each, plus pool allocations, an
 // integration, and an unmanaged Proxmox VM. Every route then has a target on
 // both sides of the scope boundary.
 func newAuthzPlane(t *testing.T) *authzPlane {
@@ -50,7 +50,10 @@ func newAuthzPlane(t *testing.T) *authzPlane {
 	profiles := map[string]models.Profile{
 		"default": {Name: "default", TemplateVM: 9000, RawYAML: "name: default\ntemplate_vmid: 9000\n"},
 	}
-	ctrl := NewControlAPI(store, profiles, manager, workspaceMgr, nil, "", log.New(io.Discard, "", 0)).WithBackend(backend)
+	ctrl := NewControlAPI(store, profiles, manager, workspaceMgr, nil, "", log.New(io.Discard, "", 0)).
+		WithBackend(backend).
+		WithQuestions(NewQuestions(store, log.New(io.Discard, "", 0))).
+		WithAccessControl(user.NewRegistry(user.NewStore(store)), nil)
 
 	ctx := context.Background()
 	now := time.Now().UTC()
@@ -115,6 +118,19 @@ func newAuthzPlane(t *testing.T) *authzPlane {
 			t.Fatalf("create exposure %d: %v", vmid, err)
 		}
 	}
+	// Questions 1 and 2, asked by sandboxes 1001 and 1002 from their jobs.
+	for _, vmid := range []int{1001, 1002} {
+		if _, err := store.CreateMessage(ctx, db.Message{
+			Timestamp: now,
+			ScopeType: "job",
+			ScopeID:   "job-" + strconv.Itoa(vmid),
+			Author:    sandboxMessageAuthor(vmid),
+			Kind:      db.MessageKindQuestion,
+			Text:      "proceed?",
+		}); err != nil {
+			t.Fatalf("create question %d: %v", vmid, err)
+		}
+	}
 
 	// Standalone APIs, registered exactly as daemon.go registers them.
 	resourcePool := pool.New(pool.Config{TotalCores: 8, TotalMemoryMB: 8192})
@@ -144,6 +160,11 @@ func newAuthzPlane(t *testing.T) *authzPlane {
 	NewSecretsAPI(secretsStore, "default", nil, log.New(io.Discard, "", 0)).Register(mux)
 	NewIntegrationAPI(intStore, log.New(io.Discard, "", 0)).Register(mux)
 	NewUserAPI(user.NewRegistry(user.NewStore(store))).Register(mux)
+	NewAdminAPI(nil).Register(mux)
+	NewTemplateAPI(store, NewTemplateBuilds(store, backend, proxmox.SnippetStore{}, t.TempDir(), log.New(io.Discard, "", 0))).Register(mux)
+	NewTemplateRolloutAPI(NewTemplateRollouts(store, backend, NewProfileRegistry(profiles), log.New(io.Discard, "", 0))).Register(mux)
+	NewReportAPI(store, NewProfileRegistry(profiles), user.NewRegistry(user.NewStore(store)), UsagePrices{}).Register(mux)
+	NewRoleAPI(user.NewRegistry(user.NewStore(store))).Register(mux)
 	// The CLI path never runs: a scoped token is refused by execAllowed before
 	// the handler decodes the body.
 	execapi.NewExecAPI("/nonexistent/agentlab", "/nonexistent/agentlab.sock", log.New(io.Discard, "", 0)).Register(mux)
@@ -309,6 +330,15 @@ func TestControlAPI_Authorization(t *testing.T) {
 			t.Errorf("session list leaked out-of-scope sess-1002: %s", sbody)
 		}
 
+		// The question inbox drops questions asked from out-of-scope jobs.
+		qcode, qbody := doReq(t, scopedRead, http.MethodGet, "/v1/messages/questions", "")
+		if qcode != http.StatusOK {
+			t.Fatalf("GET /v1/messages/questions: got %d, want 200", qcode)
+		}
+		if !bytes.Contains(qbody, []byte("job-1001")) || bytes.Contains(qbody, []byte("job-1002")) {
+			t.Errorf("question inbox = %s, want only job-1001", qbody)
+		}
+
 		// Pool status narrows its allocations and recomputes the aggregates
 		// from the surviving subset (review F12).
 		pcode, pbody := doReq(t, scopedRead, http.MethodGet, "/v1/pool/status", "")
@@ -353,13 +383,19 @@ func TestControlAPI_Authorization(t *testing.T) {
 			{http.MethodGet, "/v1/jobs/job-1001", ""},
 			{http.MethodGet, "/v1/jobs/job-1001/artifacts", ""},
 			{http.MethodGet, "/v1/jobs/job-1001/artifacts/download?path=out.txt", ""},
+			{http.MethodGet, "/v1/jobs/job-1001/diff", ""},
 			{http.MethodPost, "/v1/jobs/job-1001/doctor", ""},
+			{http.MethodPost, "/v1/job-groups", `{"base":{"repo_url":"https://example.com/r.git","profile":"default","task":"t"},"matrix":{"ref":["a","b"]}}`},
+			{http.MethodGet, "/v1/job-groups/jobgrp-1", ""},
+			{http.MethodGet, "/v1/job-groups/jobgrp-1/artifacts", ""},
 			{http.MethodGet, "/v1/profiles", ""},
 			{http.MethodGet, "/v1/schema", ""},
 			{http.MethodGet, "/v1/status", ""},
 			{http.MethodGet, "/v1/host", ""},
 			{http.MethodPost, "/v1/messages", `{"scope_type":"workspace","scope_id":"ws-1001","text":"hi"}`},
 			{http.MethodGet, "/v1/messages?scope_type=workspace&scope_id=ws-1001", ""},
+			{http.MethodGet, "/v1/messages/questions", ""},
+			{http.MethodPost, "/v1/messages/1/reply", `{"text":"yes"}`},
 			{http.MethodGet, "/v1/sandboxes", ""},
 			{http.MethodPost, "/v1/sandboxes", `{"profile":"default"}`},
 			{http.MethodGet, "/v1/sandboxes/inventory", ""},
@@ -380,7 +416,11 @@ func TestControlAPI_Authorization(t *testing.T) {
 			{http.MethodPost, "/v1/sandboxes/1001/snapshots", `{"name":"snap"}`},
 			{http.MethodPost, "/v1/sandboxes/1001/snapshots/snap/restore", ""},
 			{http.MethodGet, "/v1/sandboxes/1001/events", ""},
+			{http.MethodGet, "/v1/sandboxes/1001/usage", ""},
 			{http.MethodPost, "/v1/sandboxes/1001/doctor", ""},
+			{http.MethodGet, "/v1/sandboxes/1001/recordings", ""},
+			{http.MethodPost, "/v1/sandboxes/1001/recordings", `{"version":2}`},
+			{http.MethodGet, "/v1/sandboxes/1001/recordings/1", ""},
 			{http.MethodPost, "/v1/sandboxes/1001/lease/renew", `{}`},
 			{http.MethodGet, "/v1/workspaces", ""},
 			{http.MethodPost, "/v1/workspaces", `{"name":"ws","size_gb":1}`},
@@ -431,6 +471,34 @@ func TestControlAPI_Authorization(t *testing.T) {
 			{http.MethodPost, "/v1/teams/team-a/members", `{"name":"alice"}`},
 			{http.MethodDelete, "/v1/teams/team-a/members/alice", ""},
 			{http.MethodGet, "/v1/pool/status", ""},
+			{http.MethodPost, "/v1/admin/reload", ""},
+			{http.MethodGet, "/v1/admin/backups", ""},
+			{http.MethodPost, "/v1/admin/backups", ""},
+			{http.MethodPost, "/v1/admin/restore", `{"backup":"agentlab-20240101T000000Z.db"}`},
+			{http.MethodGet, "/v1/admin/events/export", ""},
+			{http.MethodPost, "/v1/admin/rotate-key", ""},
+			{http.MethodGet, "/v1/templates", ""},
+			{http.MethodPost, "/v1/templates", `{"spec":"name: agent-base\n"}`},
+			{http.MethodGet, "/v1/templates/agent-base", ""},
+			{http.MethodGet, "/v1/templates/agent-base/v1", ""},
+			{http.MethodGet, "/v1/profiles/default/upgrade", ""},
+			{http.MethodPost, "/v1/profiles/default/upgrade", `{"to":"v2"}`},
+			{http.MethodPost, "/v1/profiles/default/upgrade/percent", `{"percent":50}`},
+			{http.MethodPost, "/v1/profiles/default/upgrade/promote", ""},
+			{http.MethodPost, "/v1/profiles/default/upgrade/rollback", ""},
+			{http.MethodGet, "/v1/reports/usage", ""},
+			{http.MethodGet, "/v1/roles", ""},
+			{http.MethodPost, "/v1/roles", `{"name":"ops","permissions":["sandbox"]}`},
+			{http.MethodDelete, "/v1/roles/ops", ""},
+			{http.MethodGet, "/v1/role-bindings", ""},
+			{http.MethodPost, "/v1/role-bindings", `{"role":"ops","subject":"user:alice"}`},
+			{http.MethodDelete, "/v1/role-bindings/1", ""},
+			{http.MethodGet, "/v1/access", ""},
+			{http.MethodGet, "/v1/access?resource=sandbox:1001", ""},
+			{http.MethodPost, "/v1/access", `{"resource":"sandbox:1001","user":"alice","level":"read"}`},
+			{http.MethodPost, "/v1/access/team", `{"resource":"sandbox:1001","team":"infra"}`},
+			{http.MethodGet, "/v1/access/check?fingerprint=SHA256:x", ""},
+			{http.MethodDelete, "/v1/access/1", ""},
 			{http.MethodPost, "/v1/exec", `{"command":"sandbox list"}`},
 			{http.MethodPost, "/v1/exec/dry-run", `{"command":"sandbox list"}`},
 		}
@@ -514,6 +582,18 @@ func TestControlAPIScopeEnforcement(t *testing.T) {
 			allowed:       authzRequest{http.MethodPost, "/v1/jobs", `{"repo_url":"https://example.com/r.git","profile":"default","task":"t","session_id":"sess-1001"}`},
 			allowedStatus: http.StatusConflict, // workspace already attached: past authorization
 		},
+		{
+			name:          "access grants by resource vmid",
+			denied:        authzRequest{http.MethodGet, "/v1/access?resource=sandbox:1002", ""},
+			allowed:       authzRequest{http.MethodGet, "/v1/access?resource=sandbox:1001", ""},
+			allowedStatus: http.StatusOK,
+		},
+		{
+			name:          "access team by resource vmid",
+			denied:        authzRequest{http.MethodPost, "/v1/access/team", `{"resource":"sandbox:1002"}`},
+			allowed:       authzRequest{http.MethodPost, "/v1/access/team", `{"resource":"sandbox:1001"}`},
+			allowedStatus: http.StatusOK,
+		},
 		{
 			name:          "workspace read by path id",
 			denied:        authzRequest{http.MethodGet, "/v1/workspaces/ws-1002", ""},
@@ -570,6 +650,13 @@ func TestControlAPIScopeEnforcement(t *testing.T) {
 			allowed:       authzRequest{http.MethodPost, "/v1/messages", `{"scope_type":"job","scope_id":"job-1001","text":"hi"}`},
 			allowedStatus: http.StatusCreated,
 		},
+		{
+			// A question's job scope resolves to the sandbox that asked it.
+			name:          "question reply by path id",
+			denied:        authzRequest{http.MethodPost, "/v1/messages/2/reply", `{"text":"yes"}`},
+			allowed:       authzRequest{http.MethodPost, "/v1/messages/1/reply", `{"text":"yes"}`},
+			allowedStatus: http.StatusCreated,
+		},
 	}
 
 	for _, tc := range cases {
@@ -648,7 +735,7 @@ func TestBodyTargetResolvers(t *testing.T) {
 		}
 	})
 
-	t.Run("message scope resolves job, workspace, and session", func(t *testing.T) {
+	t.Run("message scope resolves job, workspace, session, and sandbox", func(t *testing.T) {
 		ctx := context.Background()
 		for _, tc := range []struct {
 			scopeType, scopeID string
@@ -659,7 +746,9 @@ func TestBodyTargetResolvers(t *testing.T) {
 			{"session", "sess-1002", 1002},
 			{"JOB", "job-1002", 1002},
 			{"job", "missing", 0},
-			{"sandbox", "1001", 0},
+			{"sandbox", "1001", 1001},
+			{"sandbox", "sb-1001", 0},
+			{"vm", "1001", 0},
 			{"", "ws-1001", 0},
 			{"workspace", "", 0},
 		} {
@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/models"
 )
 
@@ -121,6 +122,32 @@ func TestSandboxUpdateHandler(t *testing.T) {
 	}
 }
 
+func TestSandboxGetIncludesKV(t *testing.T) {
+	store := newTestStore(t)
+	api := NewControlAPI(store, map[string]models.Profile{}, nil, nil, nil, "", log.New(io.Discard, "", 0))
+	if err := store.CreateSandbox(context.Background(), models.Sandbox{
+		VMID: 111, Name: "kv-sb", Profile: "default", State: models.SandboxRunning, CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+	if err := store.PutSandboxKV(context.Background(), db.SandboxKV{VMID: 111, Key: "pr_url", Value: "https://example.test/pr/7", JobID: "job_1"}, 0); err != nil {
+		t.Fatalf("put kv: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	api.handleSandboxByID(rec, httptest.NewRequest(http.MethodGet, "/v1/sandboxes/111", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp V1SandboxResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode get response: %v", err)
+	}
+	if len(resp.KV) != 1 || resp.KV[0].Key != "pr_url" || resp.KV[0].JobID != "job_1" {
+		t.Fatalf("kv = %#v", resp.KV)
+	}
+}
+
 func TestSandboxStopAllHandlerMixedStates(t *testing.T) {
 	store := newTestStore(t)
 	backend := &stubBackend{}
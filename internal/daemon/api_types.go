@@ -7,6 +7,8 @@ type V1ErrorResponse struct {
 	Code    string `json:"code,omitempty"`
 	Message string `json:"message,omitempty"`
 	Details string `json:"details,omitempty"`
+	// Issues lists the admission rules a denied create violated.
+	Issues []V1PreflightIssue `json:"issues,omitempty"`
 }
 
 type V1StatusArtifacts struct {
@@ -133,14 +135,34 @@ type V1BootstrapTailscale struct {
 	ExtraArgs []string `json:"extra_args,omitempty"`
 }
 
+// V1BootstrapParentArtifact is a parent job's artifact the guest may fetch
+// from URL with its artifact token before starting the task.
+type V1BootstrapParentArtifact struct {
+	JobID     string `json:"job_id"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Sha256    string `json:"sha256"`
+	URL       string `json:"url"`
+}
+
+// V1BootstrapPush asks the guest to upload its commits as a git bundle so the
+// daemon can push them to Branch after the job completes.
+type V1BootstrapPush struct {
+	Branch     string `json:"branch"`
+	BundlePath string `json:"bundle_path"`
+}
+
 type V1BootstrapFetchResponse struct {
-	Job                V1BootstrapJob        `json:"job"`
-	Git                *V1BootstrapGit       `json:"git,omitempty"`
-	Env                map[string]string     `json:"env,omitempty"`
-	ClaudeSettingsJSON string                `json:"claude_settings_json,omitempty"`
-	Artifact           *V1BootstrapArtifact  `json:"artifact,omitempty"`
-	Policy             *V1BootstrapPolicy    `json:"policy,omitempty"`
-	Tailscale          *V1BootstrapTailscale `json:"tailscale,omitempty"`
+	Job                V1BootstrapJob              `json:"job"`
+	Git                *V1BootstrapGit             `json:"git,omitempty"`
+	Env                map[string]string           `json:"env,omitempty"`
+	ClaudeSettingsJSON string                      `json:"claude_settings_json,omitempty"`
+	Artifact           *V1BootstrapArtifact        `json:"artifact,omitempty"`
+	Policy             *V1BootstrapPolicy          `json:"policy,omitempty"`
+	Tailscale          *V1BootstrapTailscale       `json:"tailscale,omitempty"`
+	ParentArtifacts    []V1BootstrapParentArtifact `json:"parent_artifacts,omitempty"`
+	Push               *V1BootstrapPush            `json:"push,omitempty"`
 	// SandboxSecret proves this sandbox's identity to the /metadata/* and
 	// /proxy/* endpoints. The daemon stores only its hash and rotates it on
 	// every bootstrap fetch (review F4).
@@ -199,6 +221,47 @@ type V1SecretsView struct {
 	Git       *V1SecretsGitView              `json:"git,omitempty"`
 	SSH       map[string]V1SecretsSSHKeyView `json:"ssh,omitempty"`
 	Tailscale *V1SecretsTailscaleView        `json:"tailscale,omitempty"`
+	Policies  map[string]V1SecretPolicy      `json:"policies,omitempty"`
+}
+
+// V1SecretPolicy restricts which sandboxes may read a named secret. It is
+// both the PUT /v1/secrets/policies/{name} body and the view of a policy.
+// Each non-empty list must match the sandbox; entries are glob patterns.
+type V1SecretPolicy struct {
+	Profiles        []string `json:"profiles,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Owners          []string `json:"owners,omitempty"`
+	Jobs            []string `json:"jobs,omitempty"`
+	RequireApproval bool     `json:"require_approval,omitempty"`
+	GrantTTL        string   `json:"grant_ttl,omitempty"`
+}
+
+// V1SecretAccessRequest is a sandbox's request to read an approval-gated
+// secret. ExpiresAt is set once the request is approved.
+type V1SecretAccessRequest struct {
+	ID              string `json:"id"`
+	Secret          string `json:"secret"`
+	VMID            int    `json:"vmid"`
+	SandboxName     string `json:"sandbox_name,omitempty"`
+	JobID           string `json:"job_id,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+	Status          string `json:"status"`
+	GrantTTLSeconds int64  `json:"grant_ttl_seconds,omitempty"`
+	RequestedAt     string `json:"requested_at"`
+	DecidedAt       string `json:"decided_at,omitempty"`
+	DecidedBy       string `json:"decided_by,omitempty"`
+	ExpiresAt       string `json:"expires_at,omitempty"`
+}
+
+// V1SecretAccessRequestsResponse lists secret access requests, newest first.
+type V1SecretAccessRequestsResponse struct {
+	Requests []V1SecretAccessRequest `json:"requests"`
+}
+
+// V1SecretAccessDecisionRequest approves or denies a secret access request.
+// TTL, a Go duration, overrides the grant lifetime from the secret's policy.
+type V1SecretAccessDecisionRequest struct {
+	TTL string `json:"ttl,omitempty"`
 }
 
 // V1SecretsGitView shows which git fields are configured without their values.
@@ -247,6 +310,97 @@ type V1JobCreateRequest struct {
 	WorkspaceCreate      *V1WorkspaceCreateRequest `json:"workspace_create,omitempty"`
 	WorkspaceWaitSeconds *int                      `json:"workspace_wait_seconds,omitempty"`
 	SessionID            *string                   `json:"session_id,omitempty"`
+	Env                  map[string]string         `json:"env,omitempty"`
+	// DependsOn holds the job in QUEUED until every listed parent job has
+	// finished; DependsCondition (success, failure, always; default success)
+	// then decides whether it runs or fails without provisioning.
+	DependsOn        []string `json:"depends_on,omitempty"`
+	DependsCondition string   `json:"depends_condition,omitempty"`
+	// ParentArtifacts offers the parents' artifacts to the guest at bootstrap.
+	ParentArtifacts bool `json:"parent_artifacts,omitempty"`
+	// Result asks the daemon to push the job's commits and optionally open a
+	// pull request once the job completes.
+	Result *V1JobResultOptions `json:"result,omitempty"`
+}
+
+// V1JobResultOptions controls result publishing. The guest uploads its
+// commits as a git bundle; the daemon pushes them with the matching git-proxy
+// integration's credentials, which never reach the sandbox.
+type V1JobResultOptions struct {
+	// PushBranch is the branch to push (default agent/<job_id>).
+	PushBranch string `json:"push_branch,omitempty"`
+	// OpenPR opens a pull (merge) request from PushBranch into PRBase.
+	OpenPR bool `json:"open_pr,omitempty"`
+	// PRBase is the target branch (default: the job ref).
+	PRBase  string `json:"pr_base,omitempty"`
+	PRTitle string `json:"pr_title,omitempty"`
+	// Integration names the git-proxy integration to use; by default the one
+	// whose target covers repo_url.
+	Integration string `json:"integration,omitempty"`
+	// Provider overrides the git host type (github, gitlab, gitea).
+	Provider string `json:"provider,omitempty"`
+}
+
+// V1JobPublishResult is recorded under "publish" in the job result once the
+// daemon has pushed (or failed to push) the job's commits.
+type V1JobPublishResult struct {
+	Branch      string `json:"branch"`
+	Commit      string `json:"commit,omitempty"`
+	PRURL       string `json:"pr_url,omitempty"`
+	Provider    string `json:"provider,omitempty"`
+	Error       string `json:"error,omitempty"`
+	PublishedAt string `json:"published_at"`
+}
+
+// V1JobGroupCreateRequest fans a base job spec out across matrix axes. Every
+// combination of the non-empty axes becomes one child job; an axis left empty
+// keeps the base value. Children cannot share a workspace, so the base spec
+// must not name one.
+type V1JobGroupCreateRequest struct {
+	Name   string             `json:"name,omitempty"`
+	Base   V1JobCreateRequest `json:"base"`
+	Matrix V1JobMatrix        `json:"matrix"`
+}
+
+// V1JobMatrix lists the values of each matrix axis. Task and env variants are
+// named so the result grid can label them; unnamed variants are numbered.
+type V1JobMatrix struct {
+	Ref     []string                 `json:"ref,omitempty"`
+	Profile []string                 `json:"profile,omitempty"`
+	Task    []V1JobMatrixTaskVariant `json:"task,omitempty"`
+	Env     []V1JobMatrixEnvVariant  `json:"env,omitempty"`
+}
+
+type V1JobMatrixTaskVariant struct {
+	Name string `json:"name,omitempty"`
+	Task string `json:"task"`
+}
+
+// V1JobMatrixEnvVariant is merged over the base env for its children.
+type V1JobMatrixEnvVariant struct {
+	Name string            `json:"name,omitempty"`
+	Env  map[string]string `json:"env"`
+}
+
+// V1JobGroupResponse reports a job group. Status aggregates the children:
+// QUEUED until any child starts, RUNNING while any child is unfinished, then
+// COMPLETED only when every child completed, otherwise FAILED (or TIMEOUT when
+// no child failed outright).
+type V1JobGroupResponse struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name,omitempty"`
+	Status    string          `json:"status"`
+	Axes      []string        `json:"axes"`
+	Counts    map[string]int  `json:"counts"`
+	Jobs      []V1JobResponse `json:"jobs"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
+}
+
+// V1JobGroupArtifactsResponse lists the artifacts of every child job.
+type V1JobGroupArtifactsResponse struct {
+	GroupID string                `json:"group_id"`
+	Jobs    []V1ArtifactsResponse `json:"jobs"`
 }
 
 type V1JobValidatePlanRequest struct {
@@ -285,23 +439,29 @@ type V1JobValidatePlanResponse struct {
 }
 
 type V1JobResponse struct {
-	ID          string                `json:"id"`
-	RepoURL     string                `json:"repo_url"`
-	Ref         string                `json:"ref"`
-	Profile     string                `json:"profile"`
-	Task        string                `json:"task,omitempty"`
-	Mode        string                `json:"mode,omitempty"`
-	TTLMinutes  *int                  `json:"ttl_minutes,omitempty"`
-	Keepalive   bool                  `json:"keepalive"`
-	WorkspaceID *string               `json:"workspace_id,omitempty"`
-	SessionID   *string               `json:"session_id,omitempty"`
-	Status      string                `json:"status"`
-	SandboxVMID *int                  `json:"sandbox_vmid,omitempty"`
-	Result      json.RawMessage       `json:"result,omitempty"`
-	Events      []V1Event             `json:"events,omitempty"`
-	Timeline    *V1JobTimelineSummary `json:"timeline,omitempty"`
-	CreatedAt   string                `json:"created_at"`
-	UpdatedAt   string                `json:"updated_at"`
+	ID               string                `json:"id"`
+	RepoURL          string                `json:"repo_url"`
+	Ref              string                `json:"ref"`
+	Profile          string                `json:"profile"`
+	Task             string                `json:"task,omitempty"`
+	Mode             string                `json:"mode,omitempty"`
+	TTLMinutes       *int                  `json:"ttl_minutes,omitempty"`
+	Keepalive        bool                  `json:"keepalive"`
+	WorkspaceID      *string               `json:"workspace_id,omitempty"`
+	SessionID        *string               `json:"session_id,omitempty"`
+	GroupID          *string               `json:"group_id,omitempty"`
+	Matrix           map[string]string     `json:"matrix,omitempty"`
+	DependsOn        []string              `json:"depends_on,omitempty"`
+	DependsCondition string                `json:"depends_condition,omitempty"`
+	ParentArtifacts  bool                  `json:"parent_artifacts,omitempty"`
+	ResultOptions    *V1JobResultOptions   `json:"result_options,omitempty"`
+	Status           string                `json:"status"`
+	SandboxVMID      *int                  `json:"sandbox_vmid,omitempty"`
+	Result           json.RawMessage       `json:"result,omitempty"`
+	Events           []V1Event             `json:"events,omitempty"`
+	Timeline         *V1JobTimelineSummary `json:"timeline,omitempty"`
+	CreatedAt        string                `json:"created_at"`
+	UpdatedAt        string                `json:"updated_at"`
 }
 
 type V1SandboxCreateRequest struct {
@@ -316,6 +476,8 @@ type V1SandboxCreateRequest struct {
 	Image      string   `json:"image,omitempty"`  // Container image for LXC (e.g., "ubuntu:22.04")
 	Prompt     string   `json:"prompt,omitempty"` // Initial agent prompt for agent-ready sandboxes
 	Tags       []string `json:"tags,omitempty"`   // Integration-attachment tags (normalized, case-insensitive)
+	Team       string   `json:"team,omitempty"`   // Team that co-owns the sandbox
+	Owner      string   `json:"owner,omitempty"`  // Owner user ID; local socket only (defaults to the caller)
 }
 
 type V1SandboxValidatePlanRequest struct {
@@ -390,12 +552,15 @@ type V1SandboxResponse struct {
 	State         string                     `json:"state"`
 	IP            string                     `json:"ip,omitempty"`
 	WorkspaceID   *string                    `json:"workspace_id,omitempty"`
+	Owner         string                     `json:"owner,omitempty"`
+	Team          string                     `json:"team,omitempty"`
 	Network       *V1SandboxNetwork          `json:"network,omitempty"`
 	Keepalive     bool                       `json:"keepalive"`
 	LeaseExpires  *string                    `json:"lease_expires_at,omitempty"`
 	LastUsedAt    *string                    `json:"last_used_at,omitempty"`
 	Resources     *V1SandboxResources        `json:"resources,omitempty"`
 	Health        *V1SandboxLifecycleSummary `json:"health,omitempty"`
+	KV            []MetadataKVEntry          `json:"kv,omitempty"`
 	CreatedAt     string                     `json:"created_at"`
 	LastUpdatedAt string                     `json:"updated_at"`
 }
@@ -443,6 +608,7 @@ type V1SandboxReconcileResponse struct {
 type V1Profile struct {
 	Name         string `json:"name"`
 	TemplateVMID int    `json:"template_vmid"`
+	Template     string `json:"template,omitempty"`
 	Type         string `json:"type,omitempty"`
 	Image        string `json:"image,omitempty"`
 	UpdatedAt    string `json:"updated_at"`
@@ -452,6 +618,89 @@ type V1ProfilesResponse struct {
 	Profiles []V1Profile `json:"profiles"`
 }
 
+// V1TemplateBuildRequest starts a template build. Spec is the YAML template
+// spec.
+type V1TemplateBuildRequest struct {
+	Spec string `json:"spec"`
+}
+
+// V1Template is one version of a built template and its provenance. Spec is
+// set only when a single version is requested.
+type V1Template struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Ref            string `json:"ref"`
+	VMID           int    `json:"vmid"`
+	Status         string `json:"status"`
+	ImageURL       string `json:"image_url"`
+	ImageSHA256    string `json:"image_sha256"`
+	SpecSHA256     string `json:"spec_sha256"`
+	Spec           string `json:"spec,omitempty"`
+	BuiltBy        string `json:"built_by,omitempty"`
+	BuilderVersion string `json:"builder_version,omitempty"`
+	Error          string `json:"error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+	FinishedAt     string `json:"finished_at,omitempty"`
+}
+
+type V1TemplatesResponse struct {
+	Templates []V1Template `json:"templates"`
+}
+
+// V1TemplateRolloutRequest starts moving a profile to another version of its
+// template. To is a version or name@version; empty means the newest ready
+// version. Percent defaults to 10.
+type V1TemplateRolloutRequest struct {
+	To      string `json:"to,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+}
+
+// V1TemplateRolloutPercentRequest changes the canary share of an active
+// rollout. Zero pauses the canary.
+type V1TemplateRolloutPercentRequest struct {
+	Percent *int `json:"percent"`
+}
+
+// V1TemplateRolloutPromoteRequest promotes a rollout. Force promotes a canary
+// that regressed.
+type V1TemplateRolloutPromoteRequest struct {
+	Force bool `json:"force,omitempty"`
+}
+
+// V1TemplateVersionStats summarises the sandboxes one template version
+// provisioned since the rollout started.
+type V1TemplateVersionStats struct {
+	Version        string  `json:"version"`
+	VMID           int     `json:"vmid"`
+	Sandboxes      int     `json:"sandboxes"`
+	Ready          int     `json:"ready"`
+	ReadyP50MS     int64   `json:"ready_p50_ms"`
+	ReadyP95MS     int64   `json:"ready_p95_ms"`
+	Jobs           int     `json:"jobs"`
+	JobsFailed     int     `json:"jobs_failed"`
+	JobFailureRate float64 `json:"job_failure_rate"`
+}
+
+// V1TemplateRollout is a profile's template rollout with the canary compared
+// against the version it replaces.
+type V1TemplateRollout struct {
+	Profile    string                 `json:"profile"`
+	Template   string                 `json:"template"`
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	Percent    int                    `json:"percent"`
+	Status     string                 `json:"status"`
+	StartedBy  string                 `json:"started_by,omitempty"`
+	CreatedAt  string                 `json:"created_at"`
+	UpdatedAt  string                 `json:"updated_at"`
+	FinishedAt string                 `json:"finished_at,omitempty"`
+	Baseline   V1TemplateVersionStats `json:"baseline"`
+	Canary     V1TemplateVersionStats `json:"canary"`
+	Verdict    string                 `json:"verdict"`
+	Reasons    []string               `json:"reasons,omitempty"`
+}
+
 type V1SandboxRevertResponse struct {
 	Sandbox    V1SandboxResponse `json:"sandbox"`
 	Restarted  bool              `json:"restarted"`
@@ -503,6 +752,7 @@ type V1WorkspaceCreateRequest struct {
 	Name    string `json:"name"`
 	SizeGB  int    `json:"size_gb"`
 	Storage string `json:"storage,omitempty"`
+	Team    string `json:"team,omitempty"`
 }
 
 type V1WorkspaceForkRequest struct {
@@ -527,6 +777,8 @@ type V1WorkspaceResponse struct {
 	VolumeID     string `json:"volid"`
 	SizeGB       int    `json:"size_gb"`
 	AttachedVMID *int   `json:"attached_vmid,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	Team         string `json:"team,omitempty"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 }
@@ -622,6 +874,7 @@ type V1SessionCreateRequest struct {
 	WorkspaceID     *string                   `json:"workspace_id,omitempty"`
 	WorkspaceCreate *V1WorkspaceCreateRequest `json:"workspace_create,omitempty"`
 	Branch          string                    `json:"branch,omitempty"`
+	Team            string                    `json:"team,omitempty"`
 }
 
 type V1SessionResponse struct {
@@ -631,6 +884,8 @@ type V1SessionResponse struct {
 	CurrentVMID *int   `json:"current_vmid,omitempty"`
 	Profile     string `json:"profile"`
 	Branch      string `json:"branch,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Team        string `json:"team,omitempty"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 }
@@ -654,6 +909,48 @@ type V1SessionForkRequest struct {
 	Branch          string                    `json:"branch,omitempty"`
 }
 
+// V1AccessGrant shares a resource ("sandbox:<vmid>", "workspace:<id>" or
+// "session:<id>") with a user at read, operate or admin level.
+type V1AccessGrant struct {
+	ID        int64  `json:"id"`
+	Resource  string `json:"resource"`
+	User      string `json:"user"`
+	Level     string `json:"level"`
+	GrantedBy string `json:"granted_by,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// V1AccessGrantRequest grants access to a resource. Granting again replaces
+// the level.
+type V1AccessGrantRequest struct {
+	Resource string `json:"resource"`
+	User     string `json:"user"`
+	Level    string `json:"level"`
+}
+
+// V1AccessTeamRequest hands a resource to a team; an empty team clears it.
+type V1AccessTeamRequest struct {
+	Resource string `json:"resource"`
+	Team     string `json:"team"`
+}
+
+// V1AccessResponse lists the owner, owning team and grants of one resource,
+// or every grant when no resource was named.
+type V1AccessResponse struct {
+	Resource string          `json:"resource,omitempty"`
+	Owner    string          `json:"owner,omitempty"`
+	Team     string          `json:"team,omitempty"`
+	Grants   []V1AccessGrant `json:"grants"`
+}
+
+// V1AccessCheckResponse answers whether the user holding a key may use a
+// permission on a sandbox.
+type V1AccessCheckResponse struct {
+	Allowed    bool   `json:"allowed"`
+	User       string `json:"user,omitempty"`
+	Permission string `json:"permission"`
+}
+
 // V1ExposureCreateRequest creates an exposure. The target address is not
 // caller-controlled: the daemon derives it from the referenced sandbox row
 // (review F2). A request that carries target_ip is rejected by the strict
@@ -692,6 +989,8 @@ type V1Event struct {
 	JobID       string          `json:"job_id,omitempty"`
 	Message     string          `json:"msg,omitempty"`
 	Payload     json.RawMessage `json:"json,omitempty"`
+	TraceID     string          `json:"trace_id,omitempty"`
+	SpanID      string          `json:"span_id,omitempty"`
 }
 
 type V1EventsResponse struct {
@@ -717,6 +1016,7 @@ type V1Message struct {
 	Kind      string          `json:"kind,omitempty"`
 	Text      string          `json:"text,omitempty"`
 	Payload   json.RawMessage `json:"json,omitempty"`
+	ReplyTo   int64           `json:"reply_to,omitempty"`
 }
 
 type V1MessagesResponse struct {
@@ -724,12 +1024,20 @@ type V1MessagesResponse struct {
 	LastID   int64       `json:"last_id,omitempty"`
 }
 
+// V1MessageReplyRequest answers an agent's question. Text must be one of the
+// question's options when it has any.
+type V1MessageReplyRequest struct {
+	Text   string `json:"text"`
+	Author string `json:"author,omitempty"`
+}
+
 type V1ArtifactMetadata struct {
 	Name      string `json:"name"`
 	Path      string `json:"path,omitempty"`
 	SizeBytes int64  `json:"size_bytes,omitempty"`
 	Sha256    string `json:"sha256,omitempty"`
 	MIME      string `json:"mime,omitempty"`
+	Kind      string `json:"kind,omitempty"`
 }
 
 type V1Artifact struct {
@@ -738,9 +1046,40 @@ type V1Artifact struct {
 	SizeBytes int64  `json:"size_bytes"`
 	Sha256    string `json:"sha256"`
 	MIME      string `json:"mime,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// V1JobDiffResponse is a job's patch artifact with per-file stats parsed
+// from it. Base and Head come from the change summary when the runner
+// uploaded one.
+type V1JobDiffResponse struct {
+	JobID        string           `json:"job_id"`
+	Ref          string           `json:"ref"`
+	Base         string           `json:"base,omitempty"`
+	Head         string           `json:"head,omitempty"`
+	Files        []V1DiffFileStat `json:"files"`
+	FilesChanged int              `json:"files_changed"`
+	Additions    int              `json:"additions"`
+	Deletions    int              `json:"deletions"`
+	Patch        string           `json:"patch"`
+	// Truncated reports that Patch holds only the start of the diff; the
+	// stats still cover all of it.
+	Truncated bool   `json:"truncated,omitempty"`
 	CreatedAt string `json:"created_at"`
 }
 
+// V1DiffFileStat summarizes one file of a diff. Status is added, modified,
+// deleted, or renamed; OldPath is set for renames only.
+type V1DiffFileStat struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
 type V1ArtifactsResponse struct {
 	JobID     string       `json:"job_id"`
 	Artifacts []V1Artifact `json:"artifacts"`
@@ -751,10 +1090,55 @@ type V1ArtifactUploadResponse struct {
 	Artifact V1ArtifactMetadata `json:"artifact"`
 }
 
+// V1SandboxRecording is an asciicast v2 recording of an interactive session
+// on a sandbox. User is who the session belonged to, as the uploader
+// reported it or, for scoped tokens, the token subject.
+type V1SandboxRecording struct {
+	ID        int64  `json:"id"`
+	VMID      int    `json:"vmid"`
+	User      string `json:"user,omitempty"`
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	Sha256    string `json:"sha256"`
+	CreatedAt string `json:"created_at"`
+}
+
+type V1SandboxRecordingsResponse struct {
+	VMID       int                  `json:"vmid"`
+	Recordings []V1SandboxRecording `json:"recordings"`
+}
+
+// V1SandboxUsagePoint is a sandbox's resource usage over one bucket that
+// starts at At. CPU is a fraction of one CPU; rates are bytes per second.
+type V1SandboxUsagePoint struct {
+	At               string  `json:"at"`
+	CPUAvg           float64 `json:"cpu_avg"`
+	CPUMax           float64 `json:"cpu_max"`
+	MemoryUsedBytes  int64   `json:"memory_used_bytes"`
+	MemoryLimitBytes int64   `json:"memory_limit_bytes"`
+	DiskReadBPS      float64 `json:"disk_read_bps"`
+	DiskWriteBPS     float64 `json:"disk_write_bps"`
+	NetInBPS         float64 `json:"net_in_bps"`
+	NetOutBPS        float64 `json:"net_out_bps"`
+}
+
+type V1SandboxUsageResponse struct {
+	VMID              int                   `json:"vmid"`
+	Range             string                `json:"range"`
+	ResolutionSeconds int                   `json:"resolution_seconds"`
+	Points            []V1SandboxUsagePoint `json:"points"`
+}
+
+// V1RunnerReportRequest is a guest runner status report. Phase names the
+// runner step the report belongs to (see runnerPhases). A heartbeat report
+// only proves the runner is alive: it must be RUNNING and leaves the job's
+// result and event log untouched.
 type V1RunnerReportRequest struct {
 	JobID     string               `json:"job_id"`
 	VMID      int                  `json:"vmid"`
 	Status    string               `json:"status"`
+	Phase     string               `json:"phase,omitempty"`
+	Heartbeat bool                 `json:"heartbeat,omitempty"`
 	Message   string               `json:"message,omitempty"`
 	Artifacts []V1ArtifactMetadata `json:"artifacts,omitempty"`
 	Result    json.RawMessage      `json:"result,omitempty"`
@@ -798,8 +1182,71 @@ type MetadataMetadataResponse struct {
 	Metadata map[string]string `json:"metadata"`
 }
 
+// MetadataEnvResponse returns the environment a sandbox is entitled to: the
+// bundle env its policies allow, overlaid with its job's env overrides.
+// Version matches the ETag header and changes whenever any value does.
+type MetadataEnvResponse struct {
+	Env     map[string]string `json:"env"`
+	Version string            `json:"version"`
+}
+
 // MetadataSecretResponse returns a single secret value.
+//
+// For an approval-gated secret, a read without an active grant returns 202
+// with Status "pending" and the RequestID to wait on; ExpiresAt is set once a
+// grant is active.
 type MetadataSecretResponse struct {
-	Name  string `json:"name"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Status    string `json:"status,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// MetadataKVRequest is the body of PUT /metadata/kv/{key}.
+type MetadataKVRequest struct {
 	Value string `json:"value"`
 }
+
+// MetadataKVEntry is one guest-published status value.
+type MetadataKVEntry struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	JobID     string `json:"job_id,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// MetadataKVListResponse lists every value a sandbox has published.
+type MetadataKVListResponse struct {
+	Entries []MetadataKVEntry `json:"entries"`
+}
+
+// MetadataMessageRequest is the body of POST /metadata/messages. Kind is
+// "note" (the default) or "question"; Options limit a question's answers.
+type MetadataMessageRequest struct {
+	Kind    string   `json:"kind,omitempty"`
+	Text    string   `json:"text"`
+	Options []string `json:"options,omitempty"`
+}
+
+// MetadataMessage is a message a sandbox posted.
+type MetadataMessage struct {
+	ID        int64    `json:"id"`
+	Kind      string   `json:"kind"`
+	Text      string   `json:"text"`
+	Options   []string `json:"options,omitempty"`
+	ScopeType string   `json:"scope_type"`
+	ScopeID   string   `json:"scope_id"`
+	Timestamp string   `json:"ts"`
+}
+
+// MetadataReplyResponse is the state of a question. Status is "pending"
+// (answered with 202) until an operator replies, then "answered" with the
+// answer in Text.
+type MetadataReplyResponse struct {
+	QuestionID int64  `json:"question_id"`
+	Status     string `json:"status"`
+	Text       string `json:"text,omitempty"`
+	Author     string `json:"author,omitempty"`
+	AnsweredAt string `json:"answered_at,omitempty"`
+}
@@ -0,0 +1,206 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+)
+
+// ApprovalAPI lists operations held by the approval policy and records
+// approvals and rejections.
+//
+// Held operations may act on any sandbox, so reads (approval.read) and
+// decisions (approval.decide) are global. Approving additionally needs the
+// operation's approver role, checked by OperationApprovals.
+type ApprovalAPI struct {
+	approvals *OperationApprovals
+}
+
+// NewApprovalAPI creates the approval API.
+func NewApprovalAPI(approvals *OperationApprovals) *ApprovalAPI {
+	return &ApprovalAPI{approvals: approvals}
+}
+
+// Register registers the approval endpoints on the given mux.
+func (api *ApprovalAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/approvals", api.handleOperations)
+	mux.HandleFunc("/v1/approvals/", api.handleOperation)
+}
+
+// V1PendingOperation is a request held for approval. Body is the request
+// body it replays; Result is the response it got once it ran.
+type V1PendingOperation struct {
+	ID                string                `json:"id"`
+	Permission        string                `json:"permission"`
+	Method            string                `json:"method"`
+	Path              string                `json:"path"`
+	Body              json.RawMessage       `json:"body,omitempty"`
+	RequestedBy       string                `json:"requested_by"`
+	ApproverRole      string                `json:"approver_role"`
+	RequiredApprovals int                   `json:"required_approvals"`
+	Approvals         []V1OperationApproval `json:"approvals"`
+	Status            string                `json:"status"`
+	CreatedAt         string                `json:"created_at"`
+	ExpiresAt         string                `json:"expires_at"`
+	DecidedAt         string                `json:"decided_at,omitempty"`
+	DecidedBy         string                `json:"decided_by,omitempty"`
+	Reason            string                `json:"reason,omitempty"`
+	ResultStatus      int                   `json:"result_status,omitempty"`
+	Result            json.RawMessage       `json:"result,omitempty"`
+}
+
+// V1OperationApproval is one user's approval.
+type V1OperationApproval struct {
+	User       string `json:"user"`
+	ApprovedAt string `json:"approved_at"`
+}
+
+// V1PendingOperationsResponse lists held operations.
+type V1PendingOperationsResponse struct {
+	Operations []V1PendingOperation `json:"operations"`
+}
+
+// V1OperationRejectRequest rejects an operation with an optional reason.
+type V1OperationRejectRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleOperations lists operations. status filters by pending (the
+// default), executing, executed, failed, rejected, expired, or all.
+func (api *ApprovalAPI) handleOperations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if !authorizeStandalone(w, r, permApprovalRead, true) {
+		return
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = db.OperationPending
+	case "all":
+		status = ""
+	case db.OperationPending, db.OperationExecuting, db.OperationExecuted, db.OperationFailed, db.OperationRejected, db.OperationExpired:
+	default:
+		writeError(w, http.StatusBadRequest, "status must be pending, executing, executed, failed, rejected, expired, or all")
+		return
+	}
+	ops, err := api.approvals.List(r.Context(), status, 200)
+	if err != nil {
+		log.Printf("approvals: list: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list operations")
+		return
+	}
+	resp := V1PendingOperationsResponse{Operations: make([]V1PendingOperation, 0, len(ops))}
+	for _, op := range ops {
+		resp.Operations = append(resp.Operations, pendingOperationToV1(op))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (api *ApprovalAPI) handleOperation(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/approvals/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var (
+		op  db.PendingOperation
+		err error
+	)
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, []string{http.MethodGet})
+			return
+		}
+		if !authorizeStandalone(w, r, permApprovalRead, true) {
+			return
+		}
+		op, err = api.approvals.Get(r.Context(), id)
+	case "approve":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, []string{http.MethodPost})
+			return
+		}
+		if !authorizeStandalone(w, r, permApprovalDecide, true) {
+			return
+		}
+		op, err = api.approvals.Approve(r.Context(), id, callerUserID(r.Context()))
+	case "reject":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, []string{http.MethodPost})
+			return
+		}
+		if !authorizeStandalone(w, r, permApprovalDecide, true) {
+			return
+		}
+		var body V1OperationRejectRequest
+		if err := decodeOptionalJSON(w, r, &body); err != nil {
+			writeJSONDecodeError(w, err)
+			return
+		}
+		op, err = api.approvals.Reject(r.Context(), id, callerUserID(r.Context()), strings.TrimSpace(body.Reason))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch {
+	case errors.Is(err, errOperationNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrOperationNotPending):
+		writeError(w, http.StatusConflict, "operation already "+op.Status)
+	case errors.Is(err, db.ErrOperationAlreadyApproved):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, errApproverUnregistered), errors.Is(err, errApproverIsRequester),
+		errors.Is(err, errApproverLacksRole), errors.Is(err, errOperationRejectDenied):
+		writeError(w, http.StatusForbidden, err.Error())
+	case err != nil:
+		log.Printf("approvals: %s %s: %v", action, id, err)
+		writeError(w, http.StatusInternalServerError, "failed to update operation")
+	default:
+		writeJSON(w, http.StatusOK, pendingOperationToV1(op))
+	}
+}
+
+func pendingOperationToV1(op db.PendingOperation) V1PendingOperation {
+	out := V1PendingOperation{
+		ID:                op.ID,
+		Permission:        op.Permission,
+		Method:            op.Method,
+		Path:              op.Path,
+		RequestedBy:       op.RequestedBy,
+		ApproverRole:      op.ApproverRole,
+		RequiredApprovals: op.RequiredApprovals,
+		Approvals:         make([]V1OperationApproval, 0, len(op.Approvals)),
+		Status:            op.Status,
+		CreatedAt:         op.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:         op.ExpiresAt.UTC().Format(time.RFC3339),
+		DecidedBy:         op.DecidedBy,
+		Reason:            op.Reason,
+		ResultStatus:      op.ResultStatus,
+	}
+	if op.Status == "" {
+		out.Status = db.OperationPending
+	}
+	if body := []byte(strings.TrimSpace(op.Body)); len(body) > 0 && json.Valid(body) {
+		out.Body = body
+	}
+	if result := []byte(strings.TrimSpace(op.ResultBody)); len(result) > 0 && json.Valid(result) {
+		out.Result = result
+	}
+	for _, a := range op.Approvals {
+		out.Approvals = append(out.Approvals, V1OperationApproval{User: a.UserID, ApprovedAt: a.ApprovedAt.UTC().Format(time.RFC3339)})
+	}
+	if !op.DecidedAt.IsZero() {
+		out.DecidedAt = op.DecidedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
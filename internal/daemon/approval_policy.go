@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+const (
+	// defaultOperationExpiry is how long a held operation waits for
+	// approval when its rule does not say.
+	defaultOperationExpiry = 24 * time.Hour
+	// maxOperationExpiry bounds how long any operation may wait.
+	maxOperationExpiry = 7 * 24 * time.Hour
+)
+
+// ApprovalPolicy marks permissions whose changes are held until other users
+// approve them. It is read from approval_policy_path:
+//
+//	rules:
+//	  - permissions: [sandbox.bulk, workspace.rebind]
+//	    approvals: 2
+//	    approver_role: admin
+//	    expires_after: 4h
+//
+// The first rule whose permissions cover a request's permission applies.
+type ApprovalPolicy struct {
+	Rules []ApprovalRule `yaml:"rules"`
+}
+
+// ApprovalRule holds requests for Permissions until Approvals users other
+// than the requester, each holding ApproverRole, approve them.
+//
+// ApproverRole is "admin" for users with the admin role, "user" for any
+// registered user, or the name of a custom role bound to the approver
+// directly or through a team.
+type ApprovalRule struct {
+	Permissions  []string      `yaml:"permissions"`
+	Approvals    int           `yaml:"approvals"`
+	ApproverRole string        `yaml:"approver_role"`
+	ExpiresAfter time.Duration `yaml:"expires_after"`
+}
+
+// LoadApprovalPolicy reads the policy file at path. An empty path yields an
+// empty policy, which holds nothing.
+func LoadApprovalPolicy(path string) (ApprovalPolicy, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ApprovalPolicy{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ApprovalPolicy{}, fmt.Errorf("read approval policy: %w", err)
+	}
+	policy, err := parseApprovalPolicy(data)
+	if err != nil {
+		return ApprovalPolicy{}, fmt.Errorf("approval policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func parseApprovalPolicy(data []byte) (ApprovalPolicy, error) {
+	var policy ApprovalPolicy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return ApprovalPolicy{}, err
+	}
+	for i := range policy.Rules {
+		if err := policy.Rules[i].normalize(); err != nil {
+			return ApprovalPolicy{}, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return policy, nil
+}
+
+func (r *ApprovalRule) normalize() error {
+	if len(r.Permissions) == 0 {
+		return errors.New("permissions is required")
+	}
+	for i, perm := range r.Permissions {
+		perm = strings.TrimSpace(perm)
+		switch {
+		case perm == "*":
+			return errors.New(`"*" would hold every change; list permissions or namespaces`)
+		case perm == "approval" || strings.HasPrefix(perm, "approval."):
+			return fmt.Errorf("%s cannot itself need approval", perm)
+		case !validRolePermission(perm):
+			return fmt.Errorf("unknown permission %q", perm)
+		}
+		r.Permissions[i] = perm
+	}
+	if r.Approvals == 0 {
+		r.Approvals = 1
+	}
+	if r.Approvals < 0 {
+		return errors.New("approvals must be positive")
+	}
+	r.ApproverRole = strings.TrimSpace(r.ApproverRole)
+	if r.ApproverRole == "" {
+		return errors.New("approver_role is required")
+	}
+	if r.ExpiresAfter == 0 {
+		r.ExpiresAfter = defaultOperationExpiry
+	}
+	if r.ExpiresAfter < time.Minute || r.ExpiresAfter > maxOperationExpiry {
+		return fmt.Errorf("expires_after must be between 1m and %s", maxOperationExpiry)
+	}
+	return nil
+}
+
+// ruleFor returns the first rule covering perm.
+func (p ApprovalPolicy) ruleFor(perm string) (ApprovalRule, bool) {
+	for _, rule := range p.Rules {
+		if grantAllowsPermission(rule.Permissions, perm) {
+			return rule, true
+		}
+	}
+	return ApprovalRule{}, false
+}
+
+// builtinApproverRole reports whether role names a user role rather than a
+// custom role.
+func builtinApproverRole(role string) bool {
+	return role == string(user.RoleAdmin) || role == string(user.RoleUser)
+}
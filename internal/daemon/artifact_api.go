@@ -12,10 +12,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
 )
 
 const (
@@ -49,6 +52,7 @@ func (api *ArtifactAPI) Register(mux *http.ServeMux) {
 		return
 	}
 	mux.HandleFunc("/upload", api.handleUpload)
+	mux.HandleFunc("/download", api.handleDownload)
 }
 
 func (api *ArtifactAPI) handleUpload(w http.ResponseWriter, r *http.Request) {
@@ -68,35 +72,12 @@ func (api *ArtifactAPI) handleUpload(w http.ResponseWriter, r *http.Request) {
 		writeRateLimitExceeded(w)
 		return
 	}
-	token := bearerToken(r.Header.Get("Authorization"))
-	if token == "" {
-		writeError(w, http.StatusUnauthorized, "missing bearer token")
-		return
-	}
-	tokenHash, err := db.HashArtifactToken(token)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid token")
-		return
-	}
-	record, err := api.store.GetArtifactToken(r.Context(), tokenHash)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeError(w, http.StatusForbidden, "invalid artifact token")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, "failed to validate artifact token")
-		return
-	}
 	now := api.now().UTC()
-	if !record.ExpiresAt.IsZero() && !record.ExpiresAt.After(now) {
-		writeError(w, http.StatusForbidden, "artifact token expired")
+	record, ok := api.artifactTokenRecord(w, r, now)
+	if !ok {
 		return
 	}
 	jobID := strings.TrimSpace(record.JobID)
-	if jobID == "" {
-		writeError(w, http.StatusInternalServerError, "artifact token missing job")
-		return
-	}
 
 	rawPath := strings.TrimSpace(r.URL.Query().Get("path"))
 	if rawPath == "" {
@@ -107,6 +88,11 @@ func (api *ArtifactAPI) handleUpload(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	kind, err := parseArtifactKind(r.URL.Query().Get("kind"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	jobDir, err := api.jobDir(jobID)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
@@ -204,6 +190,7 @@ func (api *ArtifactAPI) handleUpload(w http.ResponseWriter, r *http.Request) {
 		SizeBytes: size,
 		Sha256:    sha,
 		MIME:      mime,
+		Kind:      kind,
 		CreatedAt: now,
 	}
 	id, err := api.store.CreateArtifact(r.Context(), artifact)
@@ -222,6 +209,7 @@ func (api *ArtifactAPI) handleUpload(w http.ResponseWriter, r *http.Request) {
 			"size_bytes": artifact.SizeBytes,
 			"sha256":     artifact.Sha256,
 			"mime":       artifact.MIME,
+			"kind":       string(artifact.Kind),
 		})
 	}
 	_ = api.store.TouchArtifactToken(r.Context(), record.TokenHash, now)
@@ -234,11 +222,138 @@ func (api *ArtifactAPI) handleUpload(w http.ResponseWriter, r *http.Request) {
 			SizeBytes: artifact.SizeBytes,
 			Sha256:    artifact.Sha256,
 			MIME:      artifact.MIME,
+			Kind:      string(artifact.Kind),
 		},
 	}
 	writeJSON(w, http.StatusCreated, resp)
 }
 
+// handleDownload serves a parent job's artifact to a child guest. The child's
+// artifact token authorizes the request, and only parents the child declared
+// in depends_on with parent_artifacts enabled are readable.
+func (api *ArtifactAPI) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if api.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "artifact service unavailable")
+		return
+	}
+	if !api.remoteAllowed(r.RemoteAddr) {
+		writeError(w, http.StatusForbidden, "artifact access restricted to agent subnet")
+		return
+	}
+	if api.rateLimiter != nil && !api.rateLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	now := api.now().UTC()
+	record, ok := api.artifactTokenRecord(w, r, now)
+	if !ok {
+		return
+	}
+	child, err := api.store.GetJob(r.Context(), strings.TrimSpace(record.JobID))
+	if err != nil {
+		writeError(w, http.StatusForbidden, "invalid artifact token")
+		return
+	}
+	query := r.URL.Query()
+	parentID := strings.TrimSpace(query.Get("job_id"))
+	if !child.ParentArtifacts || !slices.Contains(child.DependsOn, parentID) {
+		writeError(w, http.StatusForbidden, "job is not a parent of the token's job")
+		return
+	}
+	relPath, err := sanitizeArtifactPath(query.Get("path"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	artifacts, err := api.store.ListArtifactsByJob(r.Context(), parentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list artifacts")
+		return
+	}
+	var selected *db.Artifact
+	for i := range artifacts {
+		if artifacts[i].Path == relPath {
+			selected = &artifacts[i]
+		}
+	}
+	if selected == nil {
+		writeError(w, http.StatusNotFound, "artifact not found")
+		return
+	}
+	jobDir, err := api.jobDir(parentID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	targetPath, err := safeJoin(jobDir, selected.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "artifact path is invalid")
+		return
+	}
+	file, err := os.Open(targetPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "artifact file missing")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to open artifact")
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to stat artifact")
+		return
+	}
+	contentType := strings.TrimSpace(selected.MIME)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, file)
+	_ = api.store.TouchArtifactToken(r.Context(), record.TokenHash, now)
+}
+
+// artifactTokenRecord validates the request's bearer artifact token and
+// returns its record, writing the error response when it is missing, unknown,
+// or expired.
+func (api *ArtifactAPI) artifactTokenRecord(w http.ResponseWriter, r *http.Request, now time.Time) (db.ArtifactToken, bool) {
+	token := bearerToken(r.Header.Get("Authorization"))
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, "missing bearer token")
+		return db.ArtifactToken{}, false
+	}
+	tokenHash, err := db.HashArtifactToken(token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid token")
+		return db.ArtifactToken{}, false
+	}
+	record, err := api.store.GetArtifactToken(r.Context(), tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusForbidden, "invalid artifact token")
+			return db.ArtifactToken{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to validate artifact token")
+		return db.ArtifactToken{}, false
+	}
+	if !record.ExpiresAt.IsZero() && !record.ExpiresAt.After(now) {
+		writeError(w, http.StatusForbidden, "artifact token expired")
+		return db.ArtifactToken{}, false
+	}
+	if strings.TrimSpace(record.JobID) == "" {
+		writeError(w, http.StatusInternalServerError, "artifact token missing job")
+		return db.ArtifactToken{}, false
+	}
+	return record, true
+}
+
 func (api *ArtifactAPI) jobDir(jobID string) (string, error) {
 	jobID = strings.TrimSpace(jobID)
 	if jobID == "" {
@@ -270,6 +385,17 @@ func (api *ArtifactAPI) remoteAllowed(addr string) bool {
 	return api.agentSubnet.Contains(ip)
 }
 
+// parseArtifactKind validates the kind an upload is tagged with. An empty
+// value leaves the artifact untyped.
+func parseArtifactKind(raw string) (models.ArtifactKind, error) {
+	switch kind := models.ArtifactKind(strings.ToLower(strings.TrimSpace(raw))); kind {
+	case "", models.ArtifactKindBundle, models.ArtifactKindPatch, models.ArtifactKindChangeSummary, models.ArtifactKindGitBundle:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown artifact kind %q", raw)
+	}
+}
+
 func sanitizeArtifactPath(raw string) (string, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
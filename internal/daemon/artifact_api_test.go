@@ -248,6 +248,67 @@ func TestArtifactUploadRejectsTraversal(t *testing.T) {
 	}
 }
 
+func TestArtifactUploadKind(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	now := time.Date(2026, 1, 30, 2, 45, 0, 0, time.UTC)
+
+	job := models.Job{
+		ID:        "job_kind",
+		RepoURL:   "https://example.com/repo.git",
+		Ref:       "main",
+		Profile:   "yolo",
+		Status:    models.JobRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := store.CreateJob(ctx, job); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	token := "artifact-token-kind"
+	hash, err := db.HashArtifactToken(token)
+	if err != nil {
+		t.Fatalf("hash token: %v", err)
+	}
+	if err := store.CreateArtifactToken(ctx, hash, job.ID, 2003, now.Add(time.Hour)); err != nil {
+		t.Fatalf("create artifact token: %v", err)
+	}
+
+	agentSubnet := mustParseCIDR(t, "10.77.0.0/16")
+	api := NewArtifactAPI(store, t.TempDir(), 1024, agentSubnet, nil)
+	api.now = func() time.Time { return now }
+	upload := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/upload?"+query, strings.NewReader("diff --git a/x b/x\n"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = "10.77.0.55:1234"
+		resp := httptest.NewRecorder()
+		api.handleUpload(resp, req)
+		return resp
+	}
+
+	if resp := upload("path=patch.diff&kind=screenshot"); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown kind, got %d", resp.Code)
+	}
+	resp := upload("path=patch.diff&kind=Patch")
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var decoded V1ArtifactUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if decoded.Artifact.Kind != string(models.ArtifactKindPatch) {
+		t.Fatalf("expected kind patch in response, got %q", decoded.Artifact.Kind)
+	}
+	artifact, err := store.LatestArtifactByKind(ctx, job.ID, models.ArtifactKindPatch)
+	if err != nil {
+		t.Fatalf("latest patch artifact: %v", err)
+	}
+	if artifact.Path != "patch.diff" {
+		t.Fatalf("expected patch.diff, got %s", artifact.Path)
+	}
+}
+
 func TestArtifactUploadEnforcesSizeLimit(t *testing.T) {
 	ctx := context.Background()
 	store := newTestStore(t)
@@ -26,7 +26,7 @@ type artifactRetentionResult struct {
 // ArtifactGC removes expired artifacts based on profile retention settings.
 type ArtifactGC struct {
 	store      *db.Store
-	profiles   map[string]models.Profile
+	profiles   *ProfileRegistry
 	rootDir    string
 	logger     *log.Logger
 	redactor   *Redactor
@@ -44,7 +44,7 @@ func NewArtifactGC(store *db.Store, profiles map[string]models.Profile, rootDir
 	}
 	return &ArtifactGC{
 		store:      store,
-		profiles:   profiles,
+		profiles:   NewProfileRegistry(profiles),
 		rootDir:    strings.TrimSpace(rootDir),
 		logger:     logger,
 		redactor:   redactor,
@@ -53,6 +53,15 @@ func NewArtifactGC(store *db.Store, profiles map[string]models.Profile, rootDir
 	}
 }
 
+// WithProfileRegistry shares a profile registry so config reloads reach the GC.
+func (g *ArtifactGC) WithProfileRegistry(profiles *ProfileRegistry) *ArtifactGC {
+	if g == nil || profiles == nil {
+		return g
+	}
+	g.profiles = profiles
+	return g
+}
+
 // Start runs the artifact GC immediately and on an interval until ctx is done.
 func (g *ArtifactGC) Start(ctx context.Context) {
 	if g == nil || g.store == nil || g.gcInterval <= 0 {
@@ -86,21 +95,16 @@ func (g *ArtifactGC) run(ctx context.Context) {
 	now := g.now().UTC()
 	deleted := 0
 	for _, record := range candidates {
-		retention, ok := g.retentionForProfile(record.JobProfile, retentionCache)
-		if !ok {
-			continue
+		profile := record.JobProfile
+		if record.Artifact.JobID == "" {
+			profile = record.SandboxProfile
 		}
-		if !isTerminalJobStatus(record.JobStatus) {
-			continue
-		}
-		if record.SandboxState != "" && record.SandboxState != models.SandboxDestroyed {
+		retention, ok := g.retentionForProfile(profile, retentionCache)
+		if !ok {
 			continue
 		}
-		base := record.JobUpdatedAt
-		if base.IsZero() {
-			base = record.Artifact.CreatedAt
-		}
-		if base.IsZero() {
+		base, ok := artifactRetentionBase(record)
+		if !ok || base.IsZero() {
 			continue
 		}
 		if base.Add(retention).After(now) {
@@ -117,6 +121,39 @@ func (g *ArtifactGC) run(ctx context.Context) {
 	}
 }
 
+// artifactRetentionBase returns the time an artifact's retention period
+// starts, or false while it must be kept regardless of age.
+//
+// A job artifact waits for its job to finish and its sandbox to be destroyed,
+// then ages from the job's last update. A sandbox artifact, such as a session
+// recording, waits for its sandbox to be destroyed and ages from the destroy;
+// when the VMID now belongs to a newer sandbox, the original is long gone and
+// the artifact ages from its own creation.
+func artifactRetentionBase(record db.ArtifactRetentionRecord) (time.Time, bool) {
+	if record.Artifact.JobID == "" {
+		if !record.SandboxCreatedAt.IsZero() && record.SandboxCreatedAt.After(record.Artifact.CreatedAt) {
+			return record.Artifact.CreatedAt, true
+		}
+		if record.SandboxState != models.SandboxDestroyed {
+			return time.Time{}, false
+		}
+		if record.SandboxUpdatedAt.IsZero() {
+			return record.Artifact.CreatedAt, true
+		}
+		return record.SandboxUpdatedAt, true
+	}
+	if !isTerminalJobStatus(record.JobStatus) {
+		return time.Time{}, false
+	}
+	if record.SandboxState != "" && record.SandboxState != models.SandboxDestroyed {
+		return time.Time{}, false
+	}
+	if record.JobUpdatedAt.IsZero() {
+		return record.Artifact.CreatedAt, true
+	}
+	return record.JobUpdatedAt, true
+}
+
 func (g *ArtifactGC) retentionForProfile(profileName string, cache map[string]artifactRetentionResult) (time.Duration, bool) {
 	profileName = strings.TrimSpace(profileName)
 	if profileName == "" {
@@ -129,7 +166,7 @@ func (g *ArtifactGC) retentionForProfile(profileName string, cache map[string]ar
 		return cached.duration, cached.configured && cached.duration > 0
 	}
 	result := artifactRetentionResult{}
-	profile, ok := g.profiles[profileName]
+	profile, ok := g.profiles.Get(profileName)
 	if !ok {
 		result.err = fmt.Errorf("unknown profile %q", profileName)
 		cache[profileName] = result
@@ -160,7 +197,10 @@ func (g *ArtifactGC) deleteArtifact(ctx context.Context, record db.ArtifactReten
 	if err != nil {
 		return err
 	}
-	jobDir := filepath.Join(root, record.Artifact.JobID)
+	jobDir, err := artifactOwnerDir(root, record.Artifact)
+	if err != nil {
+		return err
+	}
 	targetPath, err := safeJoin(jobDir, relPath)
 	if err != nil {
 		return err
@@ -28,6 +28,9 @@ import (
 // no single target VMID, so they are governed by command permission alone (bulk
 // routes additionally reject any sandbox-scoped token, since they are
 // inherently cross-sandbox). List routes additionally filter their responses.
+//
+// Registered non-admin users can further be confined to custom roles; see
+// rbac.go. A confined caller needs both the token and a role grant.
 const (
 	permSandboxList            = "sandbox.list"
 	permSandboxRead            = "sandbox.read"
@@ -44,9 +47,15 @@ const (
 	permSandboxSnapshotRestore = "sandbox.snapshot.restore"
 	permSandboxLease           = "sandbox.lease"
 	permSandboxEvents          = "sandbox.events"
+	permSandboxUsage           = "sandbox.usage"
 	permSandboxDoctor          = "sandbox.doctor"
+	permSandboxRecordingsRead  = "sandbox.recordings.read"
+	permSandboxRecordingsWrite = "sandbox.recordings.write"
 	permSandboxValidate        = "sandbox.validate"
 	permSandboxBulk            = "sandbox.bulk"
+	// sandbox.ssh is checked by the SSH gateway, through /v1/access/check,
+	// before it proxies a session into an existing sandbox.
+	permSandboxSSH = "sandbox.ssh"
 
 	permJobCreate    = "job.create"
 	permJobRead      = "job.read"
@@ -91,6 +100,7 @@ const (
 	// declares a sandbox scope is refused for them outright.
 	permSecretsRead      = "secrets.read"
 	permSecretsWrite     = "secrets.write"
+	permSecretsApprove   = "secrets.approve"
 	permIntegrationRead  = "integration.read"
 	permIntegrationWrite = "integration.write"
 	permUserRead         = "user.read"
@@ -105,18 +115,105 @@ const (
 	// Pool status carries no secrets; scoped tokens may read it, and the
 	// allocations list is filtered to their scope (PoolAPI.handlePoolStatus).
 	permPoolStatus = "pool.status"
+
+	// Reloading profiles and daemon config is global and never sandbox-scoped.
+	permAdminReload = "admin.reload"
+
+	// Backups copy every row, secrets included, and a restore replaces the
+	// whole database, so both are global and granted separately.
+	permAdminBackup  = "admin.backup"
+	permAdminRestore = "admin.restore"
+
+	// Exports include archived events, messages and the audit log for every
+	// sandbox, so they are global.
+	permAdminEventsExport = "admin.events.export"
+
+	// Rotating the integration key rewrites every integration secret.
+	permAdminRotateKey = "admin.rotate_key"
+
+	// Templates are shared by every profile, and a build runs operator
+	// scripts on the Proxmox host's storage, so both are global.
+	permTemplateRead  = "template.read"
+	permTemplateBuild = "template.build"
+
+	// A rollout changes the template every new sandbox of a profile is
+	// cloned from, so it is global like the profiles themselves.
+	permProfileUpgrade = "profile.upgrade"
+
+	// Usage reports total every sandbox's resource-hours, tokens and cost
+	// across owners and teams, so they are global.
+	permReportUsage = "report.usage"
+
+	// Custom roles decide what every registered user may do, so managing
+	// them is global.
+	permRoleRead  = "role.read"
+	permRoleWrite = "role.write"
+
+	// Access grants share one sandbox, workspace or session; they are
+	// checked against the resource they name, like sandbox actions.
+	permAccessRead  = "access.read"
+	permAccessWrite = "access.write"
+
+	// Operations held for approval may act on any sandbox, so listing and
+	// deciding them is global.
+	permApprovalRead   = "approval.read"
+	permApprovalDecide = "approval.decide"
 )
 
+// permissionCatalog lists every permission a handler checks. Custom roles
+// may only grant these, their namespaces, or "*".
+var permissionCatalog = []string{
+	permSandboxList, permSandboxRead, permSandboxCreate, permSandboxStart, permSandboxStop,
+	permSandboxPause, permSandboxResume, permSandboxUpdate, permSandboxTouch, permSandboxRevert,
+	permSandboxDestroy, permSandboxSnapshot, permSandboxSnapshotRestore, permSandboxLease,
+	permSandboxEvents, permSandboxUsage, permSandboxDoctor, permSandboxRecordingsRead,
+	permSandboxRecordingsWrite, permSandboxValidate, permSandboxBulk, permSandboxSSH,
+	permJobCreate, permJobRead, permJobArtifacts, permJobDoctor, permJobValidate,
+	permWorkspaceList, permWorkspaceRead, permWorkspaceCreate, permWorkspaceCheck, permWorkspaceFSCK,
+	permWorkspaceSnapshot, permWorkspaceSnapshotRestore, permWorkspaceAttach, permWorkspaceDetach,
+	permWorkspaceRebind, permWorkspaceFork, permWorkspaceLease,
+	permSessionList, permSessionRead, permSessionCreate, permSessionResume, permSessionStop,
+	permSessionFork, permSessionDoctor,
+	permExposureList, permExposureCreate, permExposureDelete,
+	permProfileRead, permSchemaRead, permStatusRead, permHostRead, permMessageRead, permMessageSend,
+	permSecretsRead, permSecretsWrite, permSecretsApprove,
+	permIntegrationRead, permIntegrationWrite, permIntegrationDelete,
+	permUserRead, permUserWrite,
+	permPoolStatus,
+	permAdminReload, permAdminBackup, permAdminRestore, permAdminEventsExport, permAdminRotateKey,
+	permTemplateRead, permTemplateBuild, permProfileUpgrade,
+	permReportUsage,
+	permRoleRead, permRoleWrite,
+	permAccessRead, permAccessWrite,
+	permApprovalRead, permApprovalDecide,
+}
+
+// validRolePermission reports whether perm is "*", a catalog permission, or a
+// namespace of one.
+func validRolePermission(perm string) bool {
+	if perm == "*" {
+		return true
+	}
+	for _, known := range permissionCatalog {
+		if known == perm || strings.HasPrefix(known, perm+".") {
+			return true
+		}
+	}
+	return false
+}
+
 // authorize enforces command and sandbox-scope authorization for a request.
 // It returns true when the caller may proceed; otherwise it has written a 403
 // response and the handler must return immediately.
 //
 // Trusted callers bypass enforcement: a nil identity (the local Unix socket,
 // which carries no auth middleware) and identities whose Token is nil (the
-// legacy bearer token). Only SSH-signed scoped tokens are constrained.
+// legacy bearer token). Only SSH-signed scoped tokens and callers confined
+// by custom roles are constrained.
 //
 // resolve returns the sandbox VMID the request targets. It is invoked ONLY when
-// the caller carries a sandbox-scoped token, so the (possibly database-backed)
+// the caller carries a sandbox-scoped token or holds perm only through team-
+// or tag-scoped roles or resource access, so the (possibly database-backed)
 // resolution never runs for full-access callers. Returning 0 means the request
 // has no concrete sandbox target (collection, create, or unresolvable), in
 // which case the sandbox scope is not consulted.
@@ -125,28 +222,87 @@ const (
 // (stop_all/prune/reconcile): any sandbox-scoped token is denied outright
 // because scope cannot meaningfully bound a global mutation.
 func (api *ControlAPI) authorize(w http.ResponseWriter, r *http.Request, perm string, resolve func() int, bulk bool) bool {
-	if !authorizeChecked(w, r, perm, bulk) {
+	return authorizeTarget(w, r, perm, resolve, authzResource{}, bulk)
+}
+
+// authorizeResource is authorize for routes that target a workspace or
+// session. Those carry their own owner, team and access grants, through which
+// a confined caller may act in addition to the sandbox the resource resolves
+// to.
+func (api *ControlAPI) authorizeResource(w http.ResponseWriter, r *http.Request, perm string, resolve func() int, res authzResource) bool {
+	return authorizeTarget(w, r, perm, resolve, res, false)
+}
+
+// authorizeTarget is the shared core of authorize and authorizeResource. A
+// confined caller without a global grant for perm needs, for a resolved
+// sandbox, a role grant covering it or enough access to it; for a workspace or
+// session, enough access to that resource; and with no target at all, a role
+// grant carrying perm. Access to a resource is ownership, membership of its
+// owning team, or an access grant (access.go).
+//
+// A request that passes is still held when the approval policy covers perm
+// (operation_approvals.go).
+func authorizeTarget(w http.ResponseWriter, r *http.Request, perm string, resolve func() int, res authzResource, bulk bool) bool {
+	return authorizeTargetAccess(w, r, perm, resolve, res, bulk) && !holdForApproval(w, r, perm)
+}
+
+func authorizeTargetAccess(w http.ResponseWriter, r *http.Request, perm string, resolve func() int, res authzResource, bulk bool) bool {
+	if !authorizeToken(w, r, perm, bulk) {
 		return false
 	}
-	if resolve == nil {
+	ctx := r.Context()
+	id := auth.FromContext(ctx)
+	tokenScoped := id != nil && id.Token != nil && len(id.Token.Claims.Scope) > 0
+	policy := confinedPolicy(ctx)
+	if policy != nil && policy.allows(perm, true) {
+		policy = nil
+	}
+	if !tokenScoped && policy == nil {
 		return true
 	}
-	id := auth.FromContext(r.Context())
-	if id == nil || id.Token == nil || len(id.Token.Claims.Scope) == 0 {
+	vmid := 0
+	if resolve != nil {
+		vmid = resolve()
+	}
+	if tokenScoped && vmid > 0 && !id.IsSandboxAllowed(vmid) {
+		writeAuthzDenied(w, perm)
+		return false
+	}
+	if policy == nil {
 		return true
 	}
-	if vmid := resolve(); vmid > 0 && !id.IsSandboxAllowed(vmid) {
+	switch {
+	case vmid > 0 && policy.allowsSandbox(ctx, perm, vmid):
+	case res.ID != "" && policy.allowsResource(ctx, perm, res):
+	case vmid <= 0 && policy.allows(perm, bulk):
+	default:
 		writeAuthzDenied(w, perm)
 		return false
 	}
 	return true
 }
 
-// authorizeChecked is the command and global-scope enforcement core shared by
-// ControlAPI.authorize and authorizeStandalone. It rejects sandbox-scoped
-// tokens for cross-sandbox operations (the bulk/global rule) and any token
-// that lacks perm. Trusted callers (nil identity, legacy bearer token) pass.
+// authorizeChecked is the command and global-scope enforcement core behind
+// authorizeStandalone. It applies authorizeToken, then
+// denies any caller confined by custom roles without a grant for perm (a
+// global grant when the operation is global). Trusted callers (nil identity,
+// legacy bearer token) pass. Like authorizeTarget, it holds requests the
+// approval policy covers.
 func authorizeChecked(w http.ResponseWriter, r *http.Request, perm string, global bool) bool {
+	if !authorizeToken(w, r, perm, global) {
+		return false
+	}
+	if policy := confinedPolicy(r.Context()); policy != nil && !policy.allows(perm, global) {
+		writeAuthzDenied(w, perm)
+		return false
+	}
+	return !holdForApproval(w, r, perm)
+}
+
+// authorizeToken rejects sandbox-scoped tokens for cross-sandbox operations
+// (the bulk/global rule) and any token that lacks perm. Trusted callers (nil
+// identity, legacy bearer token) pass.
+func authorizeToken(w http.ResponseWriter, r *http.Request, perm string, global bool) bool {
 	id := auth.FromContext(r.Context())
 	if id == nil || id.Token == nil {
 		return true
@@ -163,9 +319,9 @@ func authorizeChecked(w http.ResponseWriter, r *http.Request, perm string, globa
 }
 
 // authorizeStandalone enforces authorization for API handlers registered on
-// the control mux outside ControlAPI (secrets, integrations, users, pool). It
-// mirrors authorize with a nil resolver: global marks the resource as
-// cross-sandbox, so any sandbox-scoped token is denied outright. Non-global
+// the control mux outside ControlAPI (secrets, integrations, users, pool,
+// admin). It mirrors authorize with a nil resolver: global marks the resource
+// as cross-sandbox, so any sandbox-scoped token is denied outright. Non-global
 // resources stay readable to scoped tokens, which then filter their responses
 // with sandboxScopeFilter.
 func authorizeStandalone(w http.ResponseWriter, r *http.Request, perm string, global bool) bool {
@@ -179,15 +335,28 @@ func writeAuthzDenied(w http.ResponseWriter, perm string) {
 }
 
 // sandboxScopeFilter returns nil when the caller may see every sandbox (trusted
-// path, legacy token, or an unscoped SSH token), otherwise a predicate that
-// reports whether a given VMID falls within the caller's declared scope. List
-// handlers use it to filter responses for scoped tokens.
+// path, legacy token, or an unscoped SSH token without role confinement),
+// otherwise a predicate that reports whether a given VMID falls within the
+// caller's declared scope and, for callers confined by custom roles, is
+// covered by one of their grants or shared with them. List handlers use it to
+// filter responses.
 func sandboxScopeFilter(r *http.Request) func(int) bool {
-	id := auth.FromContext(r.Context())
-	if id == nil || id.Token == nil || len(id.Token.Claims.Scope) == 0 {
+	ctx := r.Context()
+	id := auth.FromContext(ctx)
+	tokenScoped := id != nil && id.Token != nil && len(id.Token.Claims.Scope) > 0
+	policy := confinedPolicy(ctx)
+	if policy != nil && policy.hasGlobalGrant() {
+		policy = nil
+	}
+	if !tokenScoped && policy == nil {
 		return nil
 	}
-	return func(vmid int) bool { return id.IsSandboxAllowed(vmid) }
+	return func(vmid int) bool {
+		if tokenScoped && !id.IsSandboxAllowed(vmid) {
+			return false
+		}
+		return policy == nil || policy.seesSandbox(ctx, vmid)
+	}
 }
 
 // --- resource → sandbox VMID resolvers (DB-backed; run only for scoped tokens) ---
@@ -256,6 +425,9 @@ func sandboxActionPermission(parts []string, method string) string {
 		if parts[1] == "lease" && parts[2] == "renew" && method == http.MethodPost {
 			return permSandboxLease
 		}
+		if parts[1] == "recordings" && method == http.MethodGet {
+			return permSandboxRecordingsRead
+		}
 	case 4:
 		if parts[1] == "snapshots" && parts[3] == "restore" && method == http.MethodPost {
 			return permSandboxSnapshotRestore
@@ -278,10 +450,21 @@ func sandboxSubPermission(action, method string) string {
 		if method == http.MethodGet {
 			return permSandboxEvents
 		}
+	case "usage":
+		if method == http.MethodGet {
+			return permSandboxUsage
+		}
 	case "doctor":
 		if method == http.MethodPost {
 			return permSandboxDoctor
 		}
+	case "recordings":
+		switch method {
+		case http.MethodGet:
+			return permSandboxRecordingsRead
+		case http.MethodPost:
+			return permSandboxRecordingsWrite
+		}
 	}
 	return ""
 }
@@ -295,7 +478,7 @@ func jobActionPermission(parts []string, method string) string {
 		}
 	case 2:
 		switch parts[1] {
-		case "artifacts":
+		case "artifacts", "diff":
 			if method == http.MethodGet {
 				return permJobArtifacts
 			}
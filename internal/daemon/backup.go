@@ -0,0 +1,432 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/secrets"
+)
+
+const (
+	backupDirPerms    = 0o700
+	backupFilePerms   = 0o600
+	backupFilePrefix  = "agentlab-"
+	backupFileSuffix  = ".db"
+	backupAgeSuffix   = ".age"
+	backupStampLayout = "20060102T150405.000Z"
+
+	backupStampSecondsLayout = "20060102T150405Z"
+)
+
+// Backup triggers recorded on backup.created and backup.failed events.
+const (
+	BackupTriggerSchedule = "schedule"
+	BackupTriggerAPI      = "api"
+)
+
+// BackupConfig controls where backups go and how many are kept.
+type BackupConfig struct {
+	Dir        string
+	DBPath     string
+	AgeKeyPath string
+	Encrypt    bool
+	Retention  int
+	Interval   time.Duration
+}
+
+// BackupInfo describes one backup file.
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	Encrypted bool      `json:"encrypted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupResult describes a backup that was just written.
+type BackupResult struct {
+	BackupInfo
+	Trigger       string   `json:"trigger"`
+	SchemaVersion int      `json:"schema_version"`
+	Pruned        []string `json:"pruned,omitempty"`
+}
+
+// RestoreResult describes a backup staged to replace the database.
+type RestoreResult struct {
+	Source          string `json:"source"`
+	StagedPath      string `json:"staged_path"`
+	SchemaVersion   int    `json:"schema_version"`
+	RestartRequired bool   `json:"restart_required"`
+}
+
+type backupFailedPayload struct {
+	Trigger string `json:"trigger"`
+	Error   string `json:"error"`
+}
+
+type backupCreatedPayload struct {
+	Trigger       string   `json:"trigger"`
+	Path          string   `json:"path"`
+	SizeBytes     int64    `json:"size_bytes"`
+	SchemaVersion int      `json:"schema_version"`
+	Encrypted     bool     `json:"encrypted"`
+	Pruned        []string `json:"pruned,omitempty"`
+}
+
+// BackupManager writes online database backups, prunes old ones, and stages
+// restores.
+//
+// Backups are taken from the live store with VACUUM INTO, so the daemon keeps
+// serving while one runs. A restore cannot swap the database under running
+// components; it is verified and staged instead, and Run applies it before
+// the store is opened on the next start.
+type BackupManager struct {
+	store  *db.Store
+	cfg    BackupConfig
+	logger *log.Logger
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewBackupManager constructs a backup manager.
+func NewBackupManager(store *db.Store, cfg BackupConfig, logger *log.Logger) *BackupManager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	cfg.Dir = strings.TrimSpace(cfg.Dir)
+	return &BackupManager{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Start writes a backup every configured interval until ctx is done. It does
+// nothing when scheduled backups are disabled.
+func (m *BackupManager) Start(ctx context.Context) {
+	if m == nil || m.store == nil || m.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Backup logs and records its own failures.
+				_, _ = m.Backup(ctx, BackupTriggerSchedule)
+			}
+		}
+	}()
+}
+
+// Backup writes a new backup to the backup directory, age-encrypting it when
+// configured, and then prunes backups beyond the retention count.
+func (m *BackupManager) Backup(ctx context.Context, trigger string) (BackupResult, error) {
+	if m == nil || m.store == nil {
+		return BackupResult{}, errors.New("backup manager unavailable")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, err := m.writeBackup(ctx, trigger)
+	if err != nil {
+		m.logger.Printf("agentlabd: backup failed: %v", err)
+		_ = emitEvent(ctx, NewStoreEventRecorder(m.store), EventKindBackupFailed, nil, nil, "database backup failed", backupFailedPayload{
+			Trigger: trigger,
+			Error:   err.Error(),
+		})
+		return BackupResult{}, err
+	}
+	pruned, err := m.prune()
+	if err != nil {
+		// The new backup is good; a prune failure only leaves extra files.
+		m.logger.Printf("agentlabd: backup prune failed: %v", err)
+	}
+	result.Pruned = pruned
+	m.logger.Printf("agentlabd: backup written to %s (%d bytes, schema v%d)", result.Path, result.SizeBytes, result.SchemaVersion)
+	_ = emitEvent(ctx, NewStoreEventRecorder(m.store), EventKindBackupCreated, nil, nil, "database backup written", backupCreatedPayload{
+		Trigger:       result.Trigger,
+		Path:          result.Path,
+		SizeBytes:     result.SizeBytes,
+		SchemaVersion: result.SchemaVersion,
+		Encrypted:     result.Encrypted,
+		Pruned:        result.Pruned,
+	})
+	return result, nil
+}
+
+func (m *BackupManager) writeBackup(ctx context.Context, trigger string) (BackupResult, error) {
+	if m.cfg.Dir == "" {
+		return BackupResult{}, errors.New("backup_dir is not configured")
+	}
+	if err := os.MkdirAll(m.cfg.Dir, backupDirPerms); err != nil {
+		return BackupResult{}, fmt.Errorf("create backup dir %s: %w", m.cfg.Dir, err)
+	}
+	created, name, path := m.nextBackupName()
+	tmpPath := filepath.Join(m.cfg.Dir, "."+name+".tmp")
+	defer os.Remove(tmpPath)
+	plainPath := tmpPath
+	if m.cfg.Encrypt {
+		// backup_dir is often synced off the host, so the plaintext copy is
+		// written to a private directory beside the database and only the
+		// ciphertext lands there.
+		stageRoot := ""
+		if m.cfg.DBPath != "" {
+			stageRoot = filepath.Dir(m.cfg.DBPath)
+			if err := os.MkdirAll(stageRoot, backupDirPerms); err != nil {
+				return BackupResult{}, fmt.Errorf("create backup staging dir: %w", err)
+			}
+		}
+		stageDir, err := os.MkdirTemp(stageRoot, ".agentlab-backup-")
+		if err != nil {
+			return BackupResult{}, fmt.Errorf("create backup staging dir: %w", err)
+		}
+		defer os.RemoveAll(stageDir)
+		plainPath = filepath.Join(stageDir, strings.TrimSuffix(name, backupAgeSuffix))
+	}
+	if err := m.store.Backup(ctx, plainPath); err != nil {
+		return BackupResult{}, err
+	}
+	version, err := db.VerifyBackup(ctx, plainPath)
+	if err != nil {
+		return BackupResult{}, err
+	}
+	if m.cfg.Encrypt {
+		plaintext, err := os.ReadFile(plainPath)
+		if err != nil {
+			return BackupResult{}, fmt.Errorf("read backup: %w", err)
+		}
+		if err := os.Remove(plainPath); err != nil {
+			return BackupResult{}, fmt.Errorf("remove unencrypted backup: %w", err)
+		}
+		encrypted, err := secrets.EncryptAge(plaintext, m.cfg.AgeKeyPath)
+		if err != nil {
+			return BackupResult{}, err
+		}
+		if err := os.WriteFile(tmpPath, encrypted, backupFilePerms); err != nil {
+			return BackupResult{}, fmt.Errorf("write encrypted backup: %w", err)
+		}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return BackupResult{}, fmt.Errorf("finalize backup %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return BackupResult{}, fmt.Errorf("stat backup %s: %w", path, err)
+	}
+	return BackupResult{
+		BackupInfo: BackupInfo{
+			Name:      name,
+			Path:      path,
+			SizeBytes: info.Size(),
+			Encrypted: m.cfg.Encrypt,
+			CreatedAt: created,
+		},
+		Trigger:       trigger,
+		SchemaVersion: version,
+	}, nil
+}
+
+// nextBackupName picks the creation time, name, and path for a new backup.
+// Names carry millisecond timestamps; when a backup with the same stamp
+// already exists, the stamp is moved forward until the name is free. Callers
+// hold m.mu, so no other backup can claim the name in between.
+func (m *BackupManager) nextBackupName() (time.Time, string, string) {
+	created := m.now().UTC().Truncate(time.Millisecond)
+	for {
+		name := backupFilePrefix + created.Format(backupStampLayout) + backupFileSuffix
+		if m.cfg.Encrypt {
+			name += backupAgeSuffix
+		}
+		path := filepath.Join(m.cfg.Dir, name)
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return created, name, path
+		}
+		created = created.Add(time.Millisecond)
+	}
+}
+
+// List returns the backups in the backup directory, newest first.
+func (m *BackupManager) List() ([]BackupInfo, error) {
+	if m == nil {
+		return nil, errors.New("backup manager unavailable")
+	}
+	if m.cfg.Dir == "" {
+		return nil, errors.New("backup_dir is not configured")
+	}
+	entries, err := os.ReadDir(m.cfg.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []BackupInfo{}, nil
+		}
+		return nil, fmt.Errorf("read backup dir %s: %w", m.cfg.Dir, err)
+	}
+	backups := make([]BackupInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		created, encrypted, ok := parseBackupName(entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      entry.Name(),
+			Path:      filepath.Join(m.cfg.Dir, entry.Name()),
+			SizeBytes: info.Size(),
+			Encrypted: encrypted,
+			CreatedAt: created,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// prune removes the oldest backups beyond the retention count and returns
+// the names it removed.
+func (m *BackupManager) prune() ([]string, error) {
+	if m.cfg.Retention <= 0 {
+		return nil, nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) <= m.cfg.Retention {
+		return nil, nil
+	}
+	var pruned []string
+	var errs []error
+	for _, backup := range backups[m.cfg.Retention:] {
+		if err := os.Remove(backup.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		pruned = append(pruned, backup.Name)
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// StageRestore verifies a backup and stages it to replace the database on the
+// next daemon start. source is a path, or the name of a file in the backup
+// directory. Encrypted (.age) backups are decrypted with the configured age
+// key.
+func (m *BackupManager) StageRestore(ctx context.Context, source string) (RestoreResult, error) {
+	if m == nil || m.store == nil {
+		return RestoreResult{}, errors.New("backup manager unavailable")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolveBackupPath(source)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("read backup %s: %w", path, err)
+	}
+	if strings.HasSuffix(path, backupAgeSuffix) {
+		data, err = secrets.DecryptAge(data, m.cfg.AgeKeyPath)
+		if err != nil {
+			return RestoreResult{}, fmt.Errorf("decrypt backup %s: %w", path, err)
+		}
+	}
+	version, err := db.StageRestore(ctx, m.cfg.DBPath, data)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	result := RestoreResult{
+		Source:          path,
+		StagedPath:      db.RestoreStagePath(m.cfg.DBPath),
+		SchemaVersion:   version,
+		RestartRequired: true,
+	}
+	m.logger.Printf("agentlabd: restore from %s staged at %s; restart agentlabd to apply", path, result.StagedPath)
+	_ = emitEvent(ctx, NewStoreEventRecorder(m.store), EventKindBackupRestoreStaged, nil, nil, "database restore staged", result)
+	return result, nil
+}
+
+type backupRestoredPayload struct {
+	PreviousDB string `json:"previous_db"`
+	Checked    int    `json:"checked"`
+	Drifted    int    `json:"drifted"`
+	Reconciled int    `json:"reconciled"`
+	Error      string `json:"error,omitempty"`
+}
+
+// reconcileAfterRestore runs the sandbox reconcile against the backend
+// inventory once after Run applied a staged restore. Sandboxes created or
+// destroyed since the backup was taken are repaired the same way
+// POST /v1/sandboxes/reconcile with apply would repair them.
+func (s *Service) reconcileAfterRestore(ctx context.Context) {
+	payload := backupRestoredPayload{PreviousDB: s.restoredFrom}
+	message := "database restored and reconciled"
+	resp, err := s.controlAPI.reconcileSandboxInventory(ctx, true)
+	if err != nil {
+		log.Printf("agentlabd: post-restore reconcile failed: %v", err)
+		payload.Error = err.Error()
+		message = "database restored; reconcile failed"
+	} else {
+		log.Printf("agentlabd: post-restore reconcile checked %d, reconciled %d, %d still drifted", resp.Checked, resp.Reconciled, resp.Drifted)
+		payload.Checked = resp.Checked
+		payload.Drifted = resp.Drifted
+		payload.Reconciled = resp.Reconciled
+	}
+	_ = emitEvent(ctx, NewStoreEventRecorder(s.store), EventKindBackupRestored, nil, nil, message, payload)
+}
+
+func (m *BackupManager) resolveBackupPath(source string) (string, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return "", errors.New("backup path is required")
+	}
+	if filepath.Base(source) == source {
+		if m.cfg.Dir == "" {
+			return "", errors.New("backup_dir is not configured")
+		}
+		return filepath.Join(m.cfg.Dir, source), nil
+	}
+	if !filepath.IsAbs(source) {
+		return "", fmt.Errorf("backup path %q must be absolute or a file name in backup_dir", source)
+	}
+	return filepath.Clean(source), nil
+}
+
+// parseBackupName reports the creation time and encryption of a file named
+// like the ones Backup writes.
+func parseBackupName(name string) (time.Time, bool, bool) {
+	encrypted := strings.HasSuffix(name, backupAgeSuffix)
+	stem := strings.TrimSuffix(name, backupAgeSuffix)
+	if !strings.HasPrefix(stem, backupFilePrefix) || !strings.HasSuffix(stem, backupFileSuffix) {
+		return time.Time{}, false, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(stem, backupFilePrefix), backupFileSuffix)
+	// time.Parse accepts a fractional second after the seconds field even
+	// when the layout has none, so this matches both the millisecond stamps
+	// Backup writes and the whole-second stamps of older backups.
+	created, err := time.Parse(backupStampSecondsLayout, stamp)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return created, encrypted, true
+}
@@ -0,0 +1,341 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+func newTestBackupManager(t *testing.T, store *db.Store, cfg BackupConfig) *BackupManager {
+	t.Helper()
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(t.TempDir(), "backups")
+	}
+	if cfg.DBPath == "" {
+		cfg.DBPath = store.Path
+	}
+	m := NewBackupManager(store, cfg, log.New(io.Discard, "", 0))
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	return m
+}
+
+func countEvents(t *testing.T, store *db.Store, kind EventKind) int {
+	t.Helper()
+	events, err := store.ListAllEvents(context.Background())
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	n := 0
+	for _, ev := range events {
+		if ev.Kind == string(kind) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBackupManagerWritesAndPrunes(t *testing.T) {
+	store := newTestStore(t)
+	m := newTestBackupManager(t, store, BackupConfig{Retention: 2})
+	ctx := context.Background()
+
+	var results []BackupResult
+	for i := 0; i < 3; i++ {
+		result, err := m.Backup(ctx, BackupTriggerAPI)
+		if err != nil {
+			t.Fatalf("backup %d: %v", i, err)
+		}
+		results = append(results, result)
+	}
+	last := results[2]
+	if last.Encrypted || !strings.HasSuffix(last.Name, ".db") {
+		t.Fatalf("backup = %+v, want plaintext .db", last)
+	}
+	if last.SchemaVersion != db.LatestSchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", last.SchemaVersion, db.LatestSchemaVersion())
+	}
+	if len(last.Pruned) != 1 || last.Pruned[0] != results[0].Name {
+		t.Fatalf("pruned = %v, want [%s]", last.Pruned, results[0].Name)
+	}
+	if _, err := db.VerifyBackup(ctx, last.Path); err != nil {
+		t.Fatalf("verify backup: %v", err)
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != results[2].Name || backups[1].Name != results[1].Name {
+		t.Fatalf("backups = %+v, want newest two", backups)
+	}
+	if got := countEvents(t, store, EventKindBackupCreated); got != 3 {
+		t.Fatalf("backup.created events = %d, want 3", got)
+	}
+}
+
+func TestBackupManagerSameInstantBackupsGetDistinctNames(t *testing.T) {
+	store := newTestStore(t)
+	m := newTestBackupManager(t, store, BackupConfig{})
+	instant := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return instant }
+	ctx := context.Background()
+
+	first, err := m.Backup(ctx, BackupTriggerSchedule)
+	if err != nil {
+		t.Fatalf("scheduled backup: %v", err)
+	}
+	second, err := m.Backup(ctx, BackupTriggerAPI)
+	if err != nil {
+		t.Fatalf("manual backup in the same instant: %v", err)
+	}
+	if first.Name != "agentlab-20240501T120000.000Z.db" || second.Name != "agentlab-20240501T120000.001Z.db" {
+		t.Fatalf("names = %s, %s", first.Name, second.Name)
+	}
+
+	// Backups written before names carried milliseconds are still listed.
+	legacy := filepath.Join(m.cfg.Dir, "agentlab-20240501T115900Z.db")
+	if err := os.WriteFile(legacy, []byte("old"), 0o600); err != nil {
+		t.Fatalf("write legacy backup: %v", err)
+	}
+	backups, err := m.List()
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	if len(backups) != 3 || backups[0].Name != second.Name || backups[1].Name != first.Name || backups[2].Name != filepath.Base(legacy) {
+		t.Fatalf("backups = %+v", backups)
+	}
+}
+
+func TestBackupManagerEncryptedKeepsPlaintextOutOfBackupDir(t *testing.T) {
+	store := newTestStore(t)
+	_, secretsDir := newSecretsTestStore(t, true)
+	stageRoot := t.TempDir()
+	m := newTestBackupManager(t, store, BackupConfig{
+		DBPath:     filepath.Join(stageRoot, "agentlab.db"),
+		AgeKeyPath: filepath.Join(secretsDir, "age.key"),
+		Encrypt:    true,
+	})
+	ctx := context.Background()
+
+	result, err := m.Backup(ctx, BackupTriggerAPI)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	assertDirEntries(t, m.cfg.Dir, result.Name)
+	assertDirEntries(t, stageRoot)
+
+	// A failed encryption leaves no plaintext behind in either place.
+	m.cfg.AgeKeyPath = filepath.Join(t.TempDir(), "missing.key")
+	if _, err := m.Backup(ctx, BackupTriggerAPI); err == nil {
+		t.Fatalf("expected backup without the age key to fail")
+	}
+	assertDirEntries(t, m.cfg.Dir, result.Name)
+	assertDirEntries(t, stageRoot)
+}
+
+func assertDirEntries(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read %s: %v", dir, err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("%s holds %v, want %v", dir, got, want)
+	}
+}
+
+func TestBackupManagerEncryptedRestore(t *testing.T) {
+	store := newTestStore(t)
+	_, secretsDir := newSecretsTestStore(t, true)
+	keyPath := filepath.Join(secretsDir, "age.key")
+	dbPath := filepath.Join(t.TempDir(), "restore", "agentlab.db")
+	m := newTestBackupManager(t, store, BackupConfig{
+		DBPath:     dbPath,
+		AgeKeyPath: keyPath,
+		Encrypt:    true,
+	})
+	ctx := context.Background()
+
+	result, err := m.Backup(ctx, BackupTriggerSchedule)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if !result.Encrypted || !strings.HasSuffix(result.Name, ".db.age") {
+		t.Fatalf("backup = %+v, want encrypted .db.age", result)
+	}
+	data, err := os.ReadFile(result.Path)
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	if bytes.HasPrefix(data, []byte("SQLite format 3")) {
+		t.Fatalf("encrypted backup holds a plaintext database")
+	}
+
+	restore, err := m.StageRestore(ctx, result.Name)
+	if err != nil {
+		t.Fatalf("stage restore: %v", err)
+	}
+	if restore.Source != result.Path || restore.StagedPath != db.RestoreStagePath(dbPath) || !restore.RestartRequired {
+		t.Fatalf("restore = %+v", restore)
+	}
+	if _, err := db.VerifyBackup(ctx, restore.StagedPath); err != nil {
+		t.Fatalf("staged restore does not verify: %v", err)
+	}
+	if got := countEvents(t, store, EventKindBackupRestoreStaged); got != 1 {
+		t.Fatalf("backup.restore_staged events = %d, want 1", got)
+	}
+
+	if _, err := m.StageRestore(ctx, "relative/agentlab.db"); err == nil {
+		t.Fatalf("expected relative path with directories to be refused")
+	}
+	m.cfg.AgeKeyPath = filepath.Join(t.TempDir(), "missing.key")
+	if _, err := m.StageRestore(ctx, result.Name); err == nil {
+		t.Fatalf("expected restore without the age key to fail")
+	}
+}
+
+func TestServiceReconcilesAfterRestore(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now().UTC()
+	// The backup predates a destroy that was later undone in Proxmox: the row
+	// says DESTROYED while the VM is running.
+	if err := store.CreateSandbox(context.Background(), models.Sandbox{
+		VMID:          1053,
+		Name:          "openclaw",
+		Profile:       "yolo",
+		State:         models.SandboxDestroyed,
+		CreatedAt:     now,
+		LastUpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+	backend := &stubBackend{
+		listVMs: []proxmox.VMSummary{{VMID: 1053, Name: "openclaw", Status: proxmox.StatusRunning}},
+		guestIP: "10.77.0.195",
+	}
+	api := NewControlAPI(store, map[string]models.Profile{}, nil, nil, nil, "", log.New(io.Discard, "", 0)).WithBackend(backend)
+	s := &Service{store: store, controlAPI: api, restoredFrom: "/var/lib/agentlab/agentlab.db.pre-restore-20240501T120000Z"}
+
+	s.reconcileAfterRestore(context.Background())
+
+	sb, err := store.GetSandbox(context.Background(), 1053)
+	if err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+	if sb.State != models.SandboxRunning {
+		t.Fatalf("state = %s, want RUNNING", sb.State)
+	}
+	events, err := store.ListAllEvents(context.Background())
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var payload backupRestoredPayload
+	for _, ev := range events {
+		if ev.Kind == string(EventKindBackupRestored) {
+			_, _, data, ok := parseEventPayload(ev.JSON)
+			if !ok {
+				t.Fatalf("backup.restored event has no payload: %s", ev.JSON)
+			}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+		}
+	}
+	if payload.PreviousDB != s.restoredFrom || payload.Reconciled != 1 || payload.Error != "" {
+		t.Fatalf("backup.restored payload = %+v", payload)
+	}
+}
+
+func TestAdminAPIBackups(t *testing.T) {
+	mux := http.NewServeMux()
+	NewAdminAPI(nil).Register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/backups", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without backups status = %d, want 503", rec.Code)
+	}
+
+	store := newTestStore(t)
+	m := newTestBackupManager(t, store, BackupConfig{DBPath: filepath.Join(t.TempDir(), "agentlab.db")})
+	mux = http.NewServeMux()
+	NewAdminAPI(nil).WithBackupManager(m).Register(mux)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/admin/backups", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE status = %d, want 405", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/backups", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d body=%s, want 201", rec.Code, rec.Body.String())
+	}
+	var created V1BackupResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode backup: %v", err)
+	}
+	if created.Trigger != BackupTriggerAPI || created.Name == "" || created.SizeBytes == 0 {
+		t.Fatalf("backup response = %+v", created)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/backups", nil))
+	var list V1BackupsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(list.Backups) != 1 || list.Backups[0].Name != created.Name {
+		t.Fatalf("list status = %d backups = %+v", rec.Code, list.Backups)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/restore", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("restore without backup status = %d, want 400", rec.Code)
+	}
+
+	junk := filepath.Join(m.cfg.Dir, "agentlab-20240101T000000Z.db")
+	if err := os.WriteFile(junk, []byte("not a database, only some padding text"), 0o600); err != nil {
+		t.Fatalf("write junk: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/restore", strings.NewReader(`{"backup":"agentlab-20240101T000000Z.db"}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("corrupt restore status = %d body=%s, want 422", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/restore", strings.NewReader(`{"backup":"`+created.Name+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d body=%s, want 200", rec.Code, rec.Body.String())
+	}
+	var restore V1RestoreResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &restore); err != nil {
+		t.Fatalf("decode restore: %v", err)
+	}
+	if !restore.RestartRequired || restore.SchemaVersion != db.LatestSchemaVersion() {
+		t.Fatalf("restore response = %+v", restore)
+	}
+}
@@ -12,12 +12,14 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/models"
 	"github.com/agentlab/agentlab/internal/secrets"
 	"github.com/agentlab/agentlab/internal/tailscale/admin"
+	"github.com/agentlab/agentlab/internal/tracing"
 )
 
 const (
@@ -38,11 +40,11 @@ const (
 // BootstrapAPI serves guest bootstrap payloads on the agent subnet.
 type BootstrapAPI struct {
 	store            *db.Store
-	profiles         map[string]models.Profile
+	profiles         *ProfileRegistry
 	secretsStore     secrets.Store
 	secretsBundle    string
 	artifactEndpoint string
-	artifactTokenTTL time.Duration
+	artifactTokenTTL atomic.Int64
 	now              func() time.Time
 	rand             io.Reader
 	agentSubnet      *net.IPNet
@@ -65,11 +67,10 @@ func NewBootstrapAPI(store *db.Store, profiles map[string]models.Profile, secret
 	}
 	api := &BootstrapAPI{
 		store:            store,
-		profiles:         profiles,
+		profiles:         NewProfileRegistry(profiles),
 		secretsStore:     secretsStore,
 		secretsBundle:    bundle,
 		artifactEndpoint: strings.TrimSpace(artifactEndpoint),
-		artifactTokenTTL: artifactTokenTTL,
 		now:              time.Now,
 		rand:             rand.Reader,
 		redactor:         redactor,
@@ -78,9 +79,32 @@ func NewBootstrapAPI(store *db.Store, profiles map[string]models.Profile, secret
 		tailscaleMinter:  daemonTailscaleMinter{},
 		logger:           log.Default(),
 	}
+	api.artifactTokenTTL.Store(int64(artifactTokenTTL))
 	return api
 }
 
+// WithProfileRegistry shares a profile registry so config reloads reach
+// bootstrap policy resolution.
+func (api *BootstrapAPI) WithProfileRegistry(profiles *ProfileRegistry) *BootstrapAPI {
+	if api == nil || profiles == nil {
+		return api
+	}
+	api.profiles = profiles
+	return api
+}
+
+// SetArtifactTokenTTL changes the lifetime of artifact tokens issued from now
+// on. Non-positive values restore the default.
+func (api *BootstrapAPI) SetArtifactTokenTTL(ttl time.Duration) {
+	if api == nil {
+		return
+	}
+	if ttl <= 0 {
+		ttl = defaultArtifactTokenTTL
+	}
+	api.artifactTokenTTL.Store(int64(ttl))
+}
+
 func (api *BootstrapAPI) Register(mux *http.ServeMux) {
 	if mux == nil {
 		return
@@ -119,6 +143,10 @@ func (api *BootstrapAPI) handleBootstrapFetch(w http.ResponseWriter, r *http.Req
 		writeError(w, http.StatusServiceUnavailable, "bootstrap service unavailable")
 		return
 	}
+	w, r, finish := startServerSpan(w, r, requestTraceContext(r, func(ctx context.Context) context.Context {
+		return sandboxTraceContext(ctx, api.store, req.VMID)
+	}), "bootstrap.fetch", tracing.Int("vmid", req.VMID))
+	defer finish()
 	job, err := api.store.GetJobBySandboxVMID(r.Context(), req.VMID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -142,13 +170,41 @@ func (api *BootstrapAPI) handleBootstrapFetch(w http.ResponseWriter, r *http.Req
 		writeError(w, http.StatusForbidden, "invalid or expired bootstrap token")
 		return
 	}
-	bundle, err := api.secretsStore.Load(r.Context(), api.secretsBundle)
+	bundle, err := api.secretsStore.LoadUnresolved(r.Context(), api.secretsBundle)
 	if err != nil {
+		if api.logger != nil {
+			api.logger.Printf("bootstrap secrets load failed for vmid=%d: %v", req.VMID, err)
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load secrets bundle")
+		return
+	}
+	// Every env key is redacted, including the ones held back below.
+	envNames := envKeys(bundle.Env)
+	// Secrets whose policy excludes this sandbox, or that need approval, are
+	// held back; approval-gated ones are read via /metadata/secrets/{name}.
+	// Provider references are resolved only for what is delivered, so a
+	// held-back or unused reference cannot fail the fetch.
+	if len(bundle.Policies) > 0 {
+		sandbox, err := api.store.GetSandbox(r.Context(), req.VMID)
+		if err != nil {
+			sandbox = models.Sandbox{VMID: req.VMID}
+		}
+		bundle.Env = filterPolicyValues(bundle.Env, bundle, secretSubject(sandbox, job.ID))
+	}
+	bundle.Metadata = nil
+	if api.artifactEndpoint != "" {
+		bundle.Artifact = secrets.ArtifactBundle{}
+	}
+	bundle, err = api.secretsStore.ResolveBundle(r.Context(), bundle)
+	if err != nil {
+		if api.logger != nil {
+			api.logger.Printf("bootstrap secrets resolve failed for vmid=%d: %v", req.VMID, err)
+		}
 		writeError(w, http.StatusInternalServerError, "failed to load secrets bundle")
 		return
 	}
 	if api.redactor != nil {
-		api.redactor.AddKeys(envKeys(bundle.Env)...)
+		api.redactor.AddKeys(envNames...)
 		if authKey := bundle.GetTailscaleAuthKey(); authKey != "" {
 			api.redactor.AddValues(authKey)
 		}
@@ -168,8 +224,10 @@ func (api *BootstrapAPI) handleBootstrapFetch(w http.ResponseWriter, r *http.Req
 	if git := bootstrapGitFromBundle(bundle); git != nil {
 		resp.Git = git
 	}
-	if len(bundle.Env) > 0 {
-		resp.Env = bundle.Env
+	// Job env overrides (set per matrix cell for job groups) win over the
+	// bundle's env for this job only.
+	if env := mergeJobEnv(bundle.Env, decodeJobEnv(job.EnvJSON)); len(env) > 0 {
+		resp.Env = env
 	}
 	if claudeSettings != "" {
 		resp.ClaudeSettingsJSON = claudeSettings
@@ -187,6 +245,17 @@ func (api *BootstrapAPI) handleBootstrapFetch(w http.ResponseWriter, r *http.Req
 	} else if artifact := bootstrapArtifactFromBundle(bundle); artifact != nil {
 		resp.Artifact = artifact
 	}
+	parentArtifacts, err := api.parentArtifactsForJob(r.Context(), job)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list parent artifacts")
+		return
+	}
+	resp.ParentArtifacts = parentArtifacts
+	// The guest bundles its commits for the daemon to push; it never sees
+	// the forge credentials.
+	if opts := decodeJobPublish(job.PublishJSON); opts != nil && resp.Artifact != nil {
+		resp.Push = &V1BootstrapPush{Branch: opts.PushBranch, BundlePath: jobPushBundleName}
+	}
 	// Issue the per-sandbox endpoint secret before the single-use token is
 	// consumed. A failure here leaves the token unconsumed, so the guest can
 	// retry the whole fetch.
@@ -197,10 +266,8 @@ func (api *BootstrapAPI) handleBootstrapFetch(w http.ResponseWriter, r *http.Req
 	}
 	resp.SandboxSecret = sandboxSecret
 	var profile *models.Profile
-	if api.profiles != nil {
-		if stored, ok := api.profiles[job.Profile]; ok {
-			profile = &stored
-		}
+	if stored, ok := api.profiles.Get(job.Profile); ok {
+		profile = &stored
 	}
 	policy, err := bootstrapPolicyFromJobAndProfile(job, profile)
 	if err != nil {
@@ -508,7 +575,7 @@ func (api *BootstrapAPI) newArtifactToken() (string, string, time.Time, error) {
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
-	expires := api.now().UTC().Add(api.artifactTokenTTL)
+	expires := api.now().UTC().Add(time.Duration(api.artifactTokenTTL.Load()))
 	return token, hash, expires, nil
 }
 
@@ -0,0 +1,242 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/config"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+// Reload triggers recorded on config.reloaded events.
+const (
+	ReloadTriggerSignal = "signal"
+	ReloadTriggerAPI    = "api"
+)
+
+// reloadableConfigFields are the config.yaml keys a reload applies in place.
+// Any other changed key is reported as restart_required and left untouched.
+var reloadableConfigFields = map[string]struct{}{
+	"profiles_dir":                {},
+	"provisioning_timeout":        {},
+	"artifact_token_ttl_minutes":  {},
+	"idle_stop_minutes_default":   {},
+	"idle_stop_cpu_threshold":     {},
+	"usage_price_currency":        {},
+	"usage_price_core_hour":       {},
+	"usage_price_memory_gib_hour": {},
+	"usage_price_input_mtok":      {},
+	"usage_price_output_mtok":     {},
+	"rbac_enabled":                {},
+	"approval_policy_path":        {},
+	"admission_policy_path":       {},
+}
+
+// ConfigReloadResult describes what a reload changed.
+type ConfigReloadResult struct {
+	Trigger         string   `json:"trigger"`
+	Profiles        int      `json:"profiles"`
+	ProfilesAdded   []string `json:"profiles_added,omitempty"`
+	ProfilesRemoved []string `json:"profiles_removed,omitempty"`
+	ProfilesChanged []string `json:"profiles_changed,omitempty"`
+	ConfigChanged   []string `json:"config_changed,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+type configReloadFailedPayload struct {
+	Trigger string `json:"trigger"`
+	Error   string `json:"error"`
+}
+
+// ConfigReloader re-reads daemon configuration on demand.
+type ConfigReloader interface {
+	Reload(ctx context.Context, trigger string) (ConfigReloadResult, error)
+}
+
+// Reload re-reads config.yaml, profiles_dir and the approval and admission
+// policy files and publishes the result to every running component.
+//
+// The new profile set is validated in full before anything changes, so a
+// broken profile or config file is rejected and the previous configuration
+// stays active. Profiles are swapped atomically: a job or sandbox create that
+// already resolved its profile keeps using it. Only the settings in
+// reloadableConfigFields take effect; other changed settings are reported as
+// restart_required. Secrets cached from external providers are dropped.
+func (s *Service) Reload(ctx context.Context, trigger string) (ConfigReloadResult, error) {
+	if s == nil {
+		return ConfigReloadResult{}, errors.New("service is nil")
+	}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	prepared, err := s.prepareReload(trigger)
+	if err != nil {
+		log.Printf("agentlabd: config reload rejected: %v", err)
+		_ = emitEvent(ctx, NewStoreEventRecorder(s.store), EventKindConfigReloadFailed, nil, nil, "config reload rejected", configReloadFailedPayload{
+			Trigger: trigger,
+			Error:   err.Error(),
+		})
+		return ConfigReloadResult{}, err
+	}
+
+	result, next := prepared.result, prepared.cfg
+	s.profileRegistry.Replace(prepared.profiles)
+	if s.jobOrchestrator != nil {
+		s.jobOrchestrator.WithProvisionTimeout(next.ProvisioningTimeout)
+	}
+	if s.bootstrapAPI != nil {
+		s.bootstrapAPI.SetArtifactTokenTTL(time.Duration(next.ArtifactTokenTTLMinutes) * time.Minute)
+	}
+	if s.idleStopper != nil {
+		s.idleStopper.UpdateThresholds(next.IdleStopMinutesDefault, next.IdleStopCPUThreshold)
+	}
+	s.reportAPI.SetPrices(UsagePricesFromConfig(next))
+	s.rbac.SetEnabled(next.RBACEnabled)
+	s.approvals.SetPolicy(prepared.approvals)
+	s.admission.SetPolicy(prepared.admission)
+	// Drop cached provider secrets so rotated values are fetched on the next
+	// bootstrap.
+	s.secretsResolver.Purge()
+	s.cfg.ProfilesDir = next.ProfilesDir
+	s.cfg.ProvisioningTimeout = next.ProvisioningTimeout
+	s.cfg.ArtifactTokenTTLMinutes = next.ArtifactTokenTTLMinutes
+	s.cfg.IdleStopMinutesDefault = next.IdleStopMinutesDefault
+	s.cfg.IdleStopCPUThreshold = next.IdleStopCPUThreshold
+	s.cfg.UsagePriceCurrency = next.UsagePriceCurrency
+	s.cfg.UsagePriceCoreHour = next.UsagePriceCoreHour
+	s.cfg.UsagePriceMemoryGiBHour = next.UsagePriceMemoryGiBHour
+	s.cfg.UsagePriceInputMTok = next.UsagePriceInputMTok
+	s.cfg.UsagePriceOutputMTok = next.UsagePriceOutputMTok
+	s.cfg.RBACEnabled = next.RBACEnabled
+	s.cfg.ApprovalPolicyPath = next.ApprovalPolicyPath
+	s.cfg.AdmissionPolicyPath = next.AdmissionPolicyPath
+
+	log.Printf("agentlabd: config reloaded (%d profiles, +%d -%d ~%d, config changed: %v, restart required: %v)",
+		result.Profiles, len(result.ProfilesAdded), len(result.ProfilesRemoved), len(result.ProfilesChanged),
+		result.ConfigChanged, result.RestartRequired)
+	_ = emitEvent(ctx, NewStoreEventRecorder(s.store), EventKindConfigReloaded, nil, nil, "config reloaded", result)
+	return result, nil
+}
+
+// preparedReload is a validated configuration waiting to be applied.
+type preparedReload struct {
+	result    ConfigReloadResult
+	cfg       config.Config
+	profiles  map[string]models.Profile
+	approvals ApprovalPolicy
+	admission AdmissionPolicy
+}
+
+// prepareReload loads and validates the next configuration, profiles and
+// policies without applying them.
+func (s *Service) prepareReload(trigger string) (preparedReload, error) {
+	next, err := config.Load(s.cfg.ConfigPath)
+	if err != nil {
+		return preparedReload{}, err
+	}
+	// --offline can only be given on the command line at startup; keep it.
+	if s.cfg.Offline {
+		next.Offline = true
+	}
+	profiles, err := LoadProfiles(next.ProfilesDir)
+	if err != nil {
+		return preparedReload{}, err
+	}
+	if err := validateProfiles(profiles); err != nil {
+		return preparedReload{}, err
+	}
+	approvalPolicy, err := LoadApprovalPolicy(next.ApprovalPolicyPath)
+	if err != nil {
+		return preparedReload{}, err
+	}
+	admissionPolicy, err := LoadAdmissionPolicy(next.AdmissionPolicyPath)
+	if err != nil {
+		return preparedReload{}, err
+	}
+
+	result := diffProfiles(s.profileRegistry.Snapshot(), profiles)
+	result.Trigger = trigger
+	for _, field := range config.ChangedFields(s.cfg, next) {
+		if _, ok := reloadableConfigFields[field]; ok {
+			result.ConfigChanged = append(result.ConfigChanged, field)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, field)
+		}
+	}
+	return preparedReload{
+		result:    result,
+		cfg:       next,
+		profiles:  profiles,
+		approvals: approvalPolicy,
+		admission: admissionPolicy,
+	}, nil
+}
+
+// validateProfiles checks every profile the way provisioning would, in name
+// order so the reported error is stable.
+func validateProfiles(profiles map[string]models.Profile) error {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateProfileForProvisioning(profiles[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffProfiles reports which profiles were added, removed, or changed between
+// two sets. A profile counts as changed when its definition differs; the
+// load timestamp is ignored.
+func diffProfiles(prev, next map[string]models.Profile) ConfigReloadResult {
+	result := ConfigReloadResult{Profiles: len(next)}
+	for name, profile := range next {
+		old, ok := prev[name]
+		if !ok {
+			result.ProfilesAdded = append(result.ProfilesAdded, name)
+			continue
+		}
+		old.UpdatedAt = profile.UpdatedAt
+		if !reflect.DeepEqual(old, profile) {
+			result.ProfilesChanged = append(result.ProfilesChanged, name)
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			result.ProfilesRemoved = append(result.ProfilesRemoved, name)
+		}
+	}
+	sort.Strings(result.ProfilesAdded)
+	sort.Strings(result.ProfilesRemoved)
+	sort.Strings(result.ProfilesChanged)
+	return result
+}
+
+// watchReloadSignal reloads the configuration on every SIGHUP until ctx is
+// done. A rejected reload is logged and recorded; the daemon keeps running.
+func (s *Service) watchReloadSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				// Reload logs and records its own failures.
+				_, _ = s.Reload(ctx, ReloadTriggerSignal)
+			}
+		}
+	}()
+}
@@ -0,0 +1,291 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/config"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/proxmox"
+	"github.com/agentlab/agentlab/internal/secrets"
+)
+
+type reloadFixture struct {
+	service    *Service
+	store      *db.Store
+	configPath string
+	profiles   string
+}
+
+func (f reloadFixture) writeConfig(t *testing.T, extra string) {
+	t.Helper()
+	payload := "profiles_dir: " + f.profiles + "\n" + extra
+	if err := os.WriteFile(f.configPath, []byte(payload), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func (f reloadFixture) writeProfile(t *testing.T, file, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(f.profiles, file), []byte(body), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+}
+
+// newReloadFixture builds a service wired like newService for the reloadable
+// components, loaded from a config file and a profiles dir on disk.
+func newReloadFixture(t *testing.T) reloadFixture {
+	t.Helper()
+	root := t.TempDir()
+	f := reloadFixture{
+		store:      newTestStore(t),
+		configPath: filepath.Join(root, "config.yaml"),
+		profiles:   filepath.Join(root, "profiles"),
+	}
+	if err := os.MkdirAll(f.profiles, 0o755); err != nil {
+		t.Fatalf("mkdir profiles: %v", err)
+	}
+	f.writeConfig(t, "provisioning_timeout: 10m\n")
+	f.writeProfile(t, "base.yaml", "name: base\ntemplate_vmid: 9000\n---\nname: lxc\ntype: lxc\nimage: ubuntu:22.04\n")
+
+	cfg, err := config.Load(f.configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	profiles, err := LoadProfiles(cfg.ProfilesDir)
+	if err != nil {
+		t.Fatalf("load profiles: %v", err)
+	}
+	logger := log.New(io.Discard, "", 0)
+	registry := NewProfileRegistry(profiles)
+	orchestrator := NewJobOrchestrator(f.store, profiles, nil, nil, nil, proxmox.SnippetStore{}, "", "", logger, nil, nil).
+		WithProfileRegistry(registry).
+		WithProvisionTimeout(cfg.ProvisioningTimeout)
+	idleStopper := NewIdleStopper(f.store, nil, profiles, nil, nil, logger, nil, IdleStopConfig{
+		Enabled:        cfg.IdleStopEnabled,
+		Interval:       cfg.IdleStopInterval,
+		DefaultMinutes: cfg.IdleStopMinutesDefault,
+		CPUThreshold:   cfg.IdleStopCPUThreshold,
+	}).WithProfileRegistry(registry)
+	bootstrapAPI := NewBootstrapAPI(f.store, profiles, secrets.Store{}, "", nil, "", time.Duration(cfg.ArtifactTokenTTLMinutes)*time.Minute, nil, nil).
+		WithProfileRegistry(registry)
+	f.service = &Service{
+		cfg:             cfg,
+		store:           f.store,
+		profileRegistry: registry,
+		bootstrapAPI:    bootstrapAPI,
+		jobOrchestrator: orchestrator,
+		idleStopper:     idleStopper,
+	}
+	return f
+}
+
+func reloadEvents(t *testing.T, store *db.Store) []db.Event {
+	t.Helper()
+	events, err := store.ListAllEvents(context.Background())
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var out []db.Event
+	for _, ev := range events {
+		if strings.HasPrefix(ev.Kind, "config.") {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func TestServiceReloadPublishesProfilesAndSettings(t *testing.T) {
+	f := newReloadFixture(t)
+	f.writeProfile(t, "base.yaml", "name: base\ntemplate_vmid: 9001\n")
+	f.writeProfile(t, "extra.yaml", "name: extra\ntemplate_vmid: 9002\n")
+	f.writeConfig(t, "provisioning_timeout: 3m\nartifact_token_ttl_minutes: 60\nidle_stop_minutes_default: 5\nidle_stop_cpu_threshold: 0.2\nmetrics_listen: 127.0.0.1:9191\n")
+
+	result, err := f.service.Reload(context.Background(), ReloadTriggerAPI)
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	want := ConfigReloadResult{
+		Trigger:         ReloadTriggerAPI,
+		Profiles:        2,
+		ProfilesAdded:   []string{"extra"},
+		ProfilesRemoved: []string{"lxc"},
+		ProfilesChanged: []string{"base"},
+		ConfigChanged:   []string{"artifact_token_ttl_minutes", "provisioning_timeout", "idle_stop_minutes_default", "idle_stop_cpu_threshold"},
+		RestartRequired: []string{"metrics_listen"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("Reload() = %+v, want %+v", result, want)
+	}
+
+	// Every consumer sees the new set through the shared registry.
+	if profile, ok := f.service.jobOrchestrator.profile("base"); !ok || profile.TemplateVM != 9001 {
+		t.Fatalf("orchestrator base profile = %+v, %v; want template 9001", profile, ok)
+	}
+	if _, ok := f.service.idleStopper.profiles.Get("extra"); !ok {
+		t.Fatalf("idle stopper missing added profile")
+	}
+	if _, ok := f.service.bootstrapAPI.profiles.Get("lxc"); ok {
+		t.Fatalf("bootstrap API still sees removed profile")
+	}
+
+	if got := time.Duration(f.service.jobOrchestrator.provisionTimeout.Load()); got != 3*time.Minute {
+		t.Fatalf("provision timeout = %s, want 3m", got)
+	}
+	if got := time.Duration(f.service.bootstrapAPI.artifactTokenTTL.Load()); got != time.Hour {
+		t.Fatalf("artifact token ttl = %s, want 1h", got)
+	}
+	if f.service.idleStopper.cfg.DefaultMinutes != 5 || f.service.idleStopper.cfg.CPUThreshold != 0.2 {
+		t.Fatalf("idle stop cfg = %+v, want 5 minutes and 0.2 threshold", f.service.idleStopper.cfg)
+	}
+	if f.service.cfg.MetricsListen == "127.0.0.1:9191" {
+		t.Fatalf("restart-required setting was applied")
+	}
+
+	events := reloadEvents(t, f.store)
+	if len(events) != 1 || events[0].Kind != string(EventKindConfigReloaded) || events[0].SandboxVMID != nil || events[0].JobID != nil {
+		t.Fatalf("events = %+v, want one config.reloaded without sandbox or job", events)
+	}
+	if !strings.Contains(events[0].JSON, `"profiles_added":["extra"]`) || !strings.Contains(events[0].JSON, `"restart_required":["metrics_listen"]`) {
+		t.Fatalf("config.reloaded payload = %s, want diff", events[0].JSON)
+	}
+
+	// A second reload with nothing changed reports an empty diff but keeps
+	// listing the pending restart.
+	again, err := f.service.Reload(context.Background(), ReloadTriggerSignal)
+	if err != nil {
+		t.Fatalf("second Reload() error = %v", err)
+	}
+	if len(again.ProfilesAdded)+len(again.ProfilesRemoved)+len(again.ProfilesChanged)+len(again.ConfigChanged) != 0 {
+		t.Fatalf("second Reload() = %+v, want no changes", again)
+	}
+	if !reflect.DeepEqual(again.RestartRequired, []string{"metrics_listen"}) {
+		t.Fatalf("second Reload() restart_required = %v, want [metrics_listen]", again.RestartRequired)
+	}
+}
+
+func TestServiceReloadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f reloadFixture)
+		wantErr string
+	}{
+		{
+			name: "profile with host mount",
+			setup: func(t *testing.T, f reloadFixture) {
+				f.writeProfile(t, "bad.yaml", "name: bad\ntemplate_vmid: 9003\nhost_path: /etc\n")
+			},
+			wantErr: "host bind mounts are not allowed",
+		},
+		{
+			name: "unparseable profile",
+			setup: func(t *testing.T, f reloadFixture) {
+				f.writeProfile(t, "bad.yaml", "name: [unterminated\n")
+			},
+			wantErr: "parse profile",
+		},
+		{
+			name: "invalid config value",
+			setup: func(t *testing.T, f reloadFixture) {
+				f.writeConfig(t, "provisioning_timeout: soon\n")
+			},
+			wantErr: "provisioning_timeout",
+		},
+		{
+			name: "single sign-on without rbac",
+			setup: func(t *testing.T, f reloadFixture) {
+				f.writeConfig(t, "provisioning_timeout: 1m\noidc_issuer: https://idp.example.com\noidc_client_id: agentlab\nrbac_enabled: false\n")
+			},
+			wantErr: "oidc_issuer requires rbac_enabled",
+		},
+		{
+			name: "missing profiles dir",
+			setup: func(t *testing.T, f reloadFixture) {
+				if err := os.WriteFile(f.configPath, []byte("profiles_dir: /nonexistent/agentlab-profiles\nprovisioning_timeout: 1m\n"), 0o600); err != nil {
+					t.Fatalf("write config: %v", err)
+				}
+			},
+			wantErr: "read profiles dir",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReloadFixture(t)
+			before := f.service.profileRegistry.Snapshot()
+			f.writeConfig(t, "provisioning_timeout: 1m\n")
+			tt.setup(t, f)
+
+			_, err := f.service.Reload(context.Background(), ReloadTriggerSignal)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Reload() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := f.service.profileRegistry.Snapshot(); !reflect.DeepEqual(got, before) {
+				t.Fatalf("profiles changed after rejected reload: %v", got)
+			}
+			if got := time.Duration(f.service.jobOrchestrator.provisionTimeout.Load()); got != 10*time.Minute {
+				t.Fatalf("provision timeout = %s after rejected reload, want 10m", got)
+			}
+			events := reloadEvents(t, f.store)
+			if len(events) != 1 || events[0].Kind != string(EventKindConfigReloadFailed) {
+				t.Fatalf("events = %+v, want one config.reload_failed", events)
+			}
+		})
+	}
+}
+
+type fakeConfigReloader struct {
+	result  ConfigReloadResult
+	err     error
+	trigger string
+}
+
+func (f *fakeConfigReloader) Reload(_ context.Context, trigger string) (ConfigReloadResult, error) {
+	f.trigger = trigger
+	return f.result, f.err
+}
+
+func TestAdminAPIReload(t *testing.T) {
+	reloader := &fakeConfigReloader{result: ConfigReloadResult{Trigger: ReloadTriggerAPI, Profiles: 3, ProfilesAdded: []string{"new"}}}
+	mux := http.NewServeMux()
+	NewAdminAPI(reloader).Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want 405", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d body=%s, want 200", rec.Code, rec.Body.String())
+	}
+	if reloader.trigger != ReloadTriggerAPI {
+		t.Fatalf("trigger = %q, want %q", reloader.trigger, ReloadTriggerAPI)
+	}
+	var resp V1ConfigReloadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Profiles != 3 || !reflect.DeepEqual(resp.ProfilesAdded, []string{"new"}) {
+		t.Fatalf("response = %+v", resp)
+	}
+
+	reloader.err = errors.New(`profile "bad" requests host mounts`)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/reload", nil))
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "host mounts") {
+		t.Fatalf("rejected reload status = %d body=%s, want 422 with reason", rec.Code, rec.Body.String())
+	}
+}
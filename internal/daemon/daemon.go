@@ -26,11 +26,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/agentlab/agentlab/internal/api"
 	"github.com/agentlab/agentlab/internal/auth"
 	backendpkg "github.com/agentlab/agentlab/internal/backend"
+	"github.com/agentlab/agentlab/internal/buildinfo"
 	"github.com/agentlab/agentlab/internal/config"
 	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/integrations"
@@ -40,6 +42,7 @@ import (
 	"github.com/agentlab/agentlab/internal/proxy"
 	"github.com/agentlab/agentlab/internal/sandbox"
 	"github.com/agentlab/agentlab/internal/secrets"
+	"github.com/agentlab/agentlab/internal/tracing"
 	"github.com/agentlab/agentlab/internal/user"
 )
 
@@ -57,12 +60,20 @@ const (
 //   - TCP server for guest VM bootstrap
 //   - TCP server for artifact upload/download
 //   - Optional TCP server for Prometheus metrics
+//   - Optional OTLP trace export
 //
 // The Service coordinates the lifecycle of all daemon components and ensures
 // graceful shutdown on context cancellation.
 type Service struct {
 	cfg               config.Config
-	profiles          map[string]models.Profile
+	profileRegistry   *ProfileRegistry
+	templateRollouts  *TemplateRollouts
+	reportAPI         *ReportAPI
+	rbac              *RBAC
+	approvals         *OperationApprovals
+	admission         *PolicyAdmission
+	controlAPI        *ControlAPI
+	bootstrapAPI      *BootstrapAPI
 	store             *db.Store
 	unixListener      net.Listener
 	controlListener   net.Listener
@@ -75,10 +86,16 @@ type Service struct {
 	artifactServer    *http.Server
 	metricsServer     *http.Server
 	sandboxManager    *SandboxManager
+	jobOrchestrator   *JobOrchestrator
 	workspaceManager  *WorkspaceManager
 	artifactGC        *ArtifactGC
+	backupManager     *BackupManager
+	retentionManager  *RetentionManager
+	secretsResolver   *secrets.Resolver
 	idleStopper       *IdleStopper
+	usageCollector    *UsageCollector
 	metrics           *Metrics
+	tracer            *tracing.Tracer
 	metadataRouting   *MetadataRouting
 	lxcBackend        *sandbox.LXCBackend
 	sandboxBackend    sandbox.Backend
@@ -92,6 +109,14 @@ type Service struct {
 	lifecycleCtx    context.Context
 	lifecycleCancel context.CancelFunc
 	tasks           *taskTracker
+
+	// reloadMu serializes config reloads from SIGHUP and the admin API.
+	reloadMu sync.Mutex
+
+	// restoredFrom is where Run moved the previous database aside when it
+	// applied a staged restore; Serve then reconciles the restored rows
+	// against the backend inventory.
+	restoredFrom string
 }
 
 // Run loads profiles, binds listeners, and serves until ctx is canceled.
@@ -99,9 +124,10 @@ type Service struct {
 // This is the main entry point for starting the daemon. It performs the following:
 // 1. Validates the configuration
 // 2. Loads profile definitions from the profiles directory
-// 3. Opens the database
+// 3. Applies a staged database restore, if any, and opens the database
 // 4. Creates and wires the service with all listeners
-// 5. Serves until the context is canceled
+// 5. Reloads profiles and config on SIGHUP
+// 6. Serves until the context is canceled
 //
 // Returns any error that occurs during startup or serving.
 func Run(ctx context.Context, cfg config.Config) error {
@@ -117,6 +143,13 @@ func Run(ctx context.Context, cfg config.Config) error {
 	if err != nil {
 		return err
 	}
+	restoredFrom, err := db.ApplyStagedRestore(cfg.DBPath, time.Now())
+	if err != nil {
+		return err
+	}
+	if restoredFrom != "" {
+		log.Printf("agentlabd: applied staged restore; previous database kept at %s", restoredFrom)
+	}
 	store, err := db.Open(cfg.DBPath)
 	if err != nil {
 		return err
@@ -126,7 +159,9 @@ func Run(ctx context.Context, cfg config.Config) error {
 		_ = store.Close()
 		return err
 	}
+	service.restoredFrom = restoredFrom
 	log.Printf("agentlabd: loaded %d profiles from %s", len(profiles), cfg.ProfilesDir)
+	service.watchReloadSignal(ctx)
 	return service.Serve(ctx)
 }
 
@@ -338,6 +373,9 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 		_ = unixListener.Close()
 		return nil, fmt.Errorf("unknown backend: %s (must be 'proxmox', 'docker', or 'libvirt')", primaryBackend)
 	}
+	if strings.TrimSpace(cfg.TracingEndpoint) != "" {
+		backend = proxmox.WithTracing(backend)
+	}
 	workspaceManager := NewWorkspaceManager(store, backend, log.Default())
 	sandboxManager := NewSandboxManager(store, backend, log.Default()).WithWorkspaceManager(workspaceManager).WithMetrics(metrics)
 
@@ -417,8 +455,12 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 	if controllerURL == "" {
 		controllerURL = buildControllerURL(cfg.BootstrapListen)
 	}
+	// Every profile consumer shares one registry so a config reload reaches
+	// all of them at once.
+	profileRegistry := NewProfileRegistry(profiles)
 	jobOrchestrator := NewJobOrchestrator(store, profiles, backend, sandboxManager, workspaceManager, snippetStore, cfg.SSHPublicKey, controllerURL, log.Default(), redactor, metrics)
 	if jobOrchestrator != nil {
+		jobOrchestrator.WithProfileRegistry(profileRegistry)
 		jobOrchestrator.WithProvisionTimeout(cfg.ProvisioningTimeout)
 		sandboxManager.WithSnippetCleaner(jobOrchestrator.CleanupSnippet)
 	}
@@ -439,7 +481,14 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 			cfg.PoolTotalCores, cfg.PoolTotalMemoryMB, resourcePool.Status().Config.CPUOverCommit, resourcePool.Status().Config.MemoryOverCommit)
 	}
 
+	// Agents ask operators questions over the metadata API; operators
+	// answer through the control API and, optionally, hear about new
+	// questions on a webhook.
+	questions := NewQuestions(store, log.Default()).
+		WithNotifier(NewNotifier(cfg.NotifyWebhookURL, cfg.NotifySlackWebhookURL, log.Default()))
+
 	controlAPI := NewControlAPI(store, profiles, sandboxManager, workspaceManager, jobOrchestrator, cfg.ArtifactDir, log.Default()).
+		WithProfileRegistry(profileRegistry).
 		WithBackend(backend).
 		WithMetrics(metrics).
 		WithMetricsEnabled(metrics != nil).
@@ -449,7 +498,9 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 		WithAgentSubnet(agentCIDR).
 		WithTailscaleStatus(defaultTailscaleDNSName).
 		WithTailscalePeerInventory(defaultTailscalePeerInventory).
-		WithResourcePool(resourcePool)
+		WithResourcePool(resourcePool).
+		WithQuestions(questions).
+		WithArtifactMaxBytes(cfg.ArtifactMaxBytes)
 	controlAPI.Register(localMux)
 
 	// Register pool status endpoint.
@@ -459,41 +510,37 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 	// control-plane SecretsAPI (registered here on localMux) and the
 	// bootstrap/metadata APIs further down. AllowPlaintext stays false
 	// server-side so staged keys are always written age-encrypted at rest.
+	// Provider references in bundle fields are resolved at bootstrap time.
+	secretsResolver := newSecretsResolver(cfg)
 	secretsStore := secrets.Store{
 		Dir:        cfg.SecretsDir,
 		AgeKeyPath: cfg.SecretsAgeKeyPath,
 		SopsPath:   cfg.SecretsSopsPath,
-	}
-	NewSecretsAPI(secretsStore, cfg.SecretsBundle, redactor, log.Default()).Register(localMux)
+		Resolver:   secretsResolver,
+	}
+	// Approval-gated secrets are granted just in time through access
+	// requests that operators decide over the secrets API.
+	secretApprovals := NewSecretApprovals(store, cfg.SecretsGrantTTL, log.Default())
+	NewSecretsAPI(secretsStore, cfg.SecretsBundle, redactor, log.Default()).
+		WithSecretApprovals(secretApprovals).
+		Register(localMux)
 
 	// Set up integrations system if enabled.
 	var integrationStore *integrations.Store
 	if cfg.IntegrationsEnabled {
-		var encKey []byte
-		if cfg.IntegrationEncKey != "" {
-			var keyErr error
-			encKey, keyErr = integrations.ParseEncryptionKeyHex(cfg.IntegrationEncKey)
-			if keyErr != nil {
-				_ = metricsListener.Close()
-				_ = artifactListener.Close()
-				_ = bootstrapListener.Close()
-				_ = unixListener.Close()
-				return nil, fmt.Errorf("parse integration_enc_key: %w", keyErr)
-			}
-		} else {
-			var keyErr error
-			encKey, keyErr = integrations.GenerateEncryptionKey()
-			if keyErr != nil {
-				_ = metricsListener.Close()
-				_ = artifactListener.Close()
-				_ = bootstrapListener.Close()
-				_ = unixListener.Close()
-				return nil, fmt.Errorf("generate integration encryption key: %w", keyErr)
-			}
+		keyring, ephemeral, keyErr := loadIntegrationKeyring(cfg)
+		if keyErr != nil {
+			_ = metricsListener.Close()
+			_ = artifactListener.Close()
+			_ = bootstrapListener.Close()
+			_ = unixListener.Close()
+			return nil, keyErr
+		}
+		if ephemeral {
 			log.Printf("warning: integration_enc_key not set; generated ephemeral key (integrations will not survive restart)")
 		}
 		var storeErr error
-		integrationStore, storeErr = integrations.NewStore(store, encKey)
+		integrationStore, storeErr = integrations.NewStoreWithKeyring(store, keyring)
 		if storeErr != nil {
 			_ = metricsListener.Close()
 			_ = artifactListener.Close()
@@ -506,13 +553,79 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 		integrationAPI.Register(localMux)
 		log.Printf("integrations system enabled")
 	}
+	if jobOrchestrator != nil {
+		// Result publishing pushes with git-proxy credentials; without
+		// integrations a job asking for it records an error instead.
+		var gitCredentials GitIntegrationLister
+		if integrationStore != nil {
+			gitCredentials = integrationStore
+		}
+		jobOrchestrator.WithResultPublisher(NewResultPublisher(store, gitCredentials, cfg.ArtifactDir, log.Default(), redactor))
+	}
 
 	// Set up multi-user support via SSH keys.
 	userStore := user.NewStore(store)
 	userRegistry := user.NewRegistry(userStore)
-	userAPI := NewUserAPI(userRegistry)
+	userAPI := NewUserAPI(userRegistry).WithOIDCIssuer(cfg.OIDCIssuer)
 	userAPI.Register(localMux)
 	log.Printf("multi-user support enabled")
+	rbac := NewRBAC(userRegistry, store, cfg.RBACEnabled)
+	NewRoleAPI(userRegistry).Register(localMux)
+	controlAPI.WithAccessControl(userRegistry, rbac)
+	closeListeners := func() {
+		for _, l := range []net.Listener{metricsListener, controlListener, artifactListener, bootstrapListener, unixListener} {
+			if l != nil {
+				_ = l.Close()
+			}
+		}
+	}
+
+	// Changes the approval policy covers are held until other users approve
+	// them, then replayed through localMux as the requester.
+	approvalPolicy, err := LoadApprovalPolicy(cfg.ApprovalPolicyPath)
+	if err != nil {
+		closeListeners()
+		return nil, err
+	}
+	approvals := NewOperationApprovals(store, userRegistry, rbac, approvalPolicy, log.Default()).WithHandler(localMux)
+	NewApprovalAPI(approvals).Register(localMux)
+	if len(approvalPolicy.Rules) > 0 {
+		log.Printf("approval policy loaded (%d rules from %s)", len(approvalPolicy.Rules), cfg.ApprovalPolicyPath)
+	}
+
+	// Organization rules for what sandboxes and jobs may be created; an
+	// empty policy admits every valid create.
+	admissionPolicy, err := LoadAdmissionPolicy(cfg.AdmissionPolicyPath)
+	if err != nil {
+		closeListeners()
+		return nil, err
+	}
+	admission := NewPolicyAdmission(admissionPolicy)
+	controlAPI.WithAdmission(admission)
+	if len(admissionPolicy.Rules) > 0 {
+		log.Printf("admission policy loaded (%d rules from %s)", len(admissionPolicy.Rules), cfg.AdmissionPolicyPath)
+	}
+
+	// Single sign-on: the daemon verifies ID tokens from the provider and
+	// answers with tokens signed by its own issuer key, acting for the user.
+	var tokenIssuer *auth.Issuer
+	if cfg.OIDCIssuer != "" {
+		var issuerErr error
+		tokenIssuer, issuerErr = auth.LoadOrCreateIssuer(cfg.OIDCSigningKeyPath, cfg.OIDCTokenTTL)
+		if issuerErr != nil {
+			closeListeners()
+			return nil, fmt.Errorf("oidc token issuer: %w", issuerErr)
+		}
+		NewOIDCLoginAPI(OIDCLoginConfig{
+			Issuer:        cfg.OIDCIssuer,
+			ClientID:      cfg.OIDCClientID,
+			UsernameClaim: cfg.OIDCUsernameClaim,
+			GroupsClaim:   cfg.OIDCGroupsClaim,
+			AdminGroups:   cfg.OIDCAdminGroups,
+			TeamGroups:    cfg.OIDCTeamGroups,
+		}, userRegistry, tokenIssuer).Register(localMux)
+		log.Printf("single sign-on enabled (issuer=%s)", cfg.OIDCIssuer)
+	}
 
 	// Register POST /v1/exec and /v1/exec/dry-run endpoints.
 	// These mirror the CLI 1:1 over HTTPS (the "SSH API shoved into a POST body").
@@ -535,9 +648,14 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 	}
 	bootstrapLimiter := NewIPRateLimiter(cfg.BootstrapRateLimitQPS, cfg.BootstrapRateLimitBurst)
 	artifactLimiter := NewIPRateLimiter(cfg.ArtifactRateLimitQPS, cfg.ArtifactRateLimitBurst)
-	NewBootstrapAPI(store, profiles, secretsStore, cfg.SecretsBundle, agentSubnet, artifactEndpoint, time.Duration(cfg.ArtifactTokenTTLMinutes)*time.Minute, redactor, bootstrapLimiter).Register(bootstrapMux)
+	bootstrapAPI := NewBootstrapAPI(store, profiles, secretsStore, cfg.SecretsBundle, agentSubnet, artifactEndpoint, time.Duration(cfg.ArtifactTokenTTLMinutes)*time.Minute, redactor, bootstrapLimiter).
+		WithProfileRegistry(profileRegistry)
+	bootstrapAPI.Register(bootstrapMux)
 	NewRunnerAPI(jobOrchestrator, agentSubnet).Register(bootstrapMux)
-	NewMetadataAPI(store, secretsStore, cfg.SecretsBundle, agentSubnet, bootstrapLimiter, log.Default()).Register(bootstrapMux)
+	NewMetadataAPI(store, secretsStore, cfg.SecretsBundle, agentSubnet, bootstrapLimiter, log.Default()).
+		WithSecretApprovals(secretApprovals).
+		WithQuestions(questions).
+		Register(bootstrapMux)
 
 	// Register integration proxy routes on bootstrap mux so sandboxes can
 	// access integrations through http://169.254.169.254/proxy/{name}/...
@@ -549,16 +667,44 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 	artifactMux.HandleFunc("/healthz", healthHandler)
 	NewArtifactAPI(store, cfg.ArtifactDir, cfg.ArtifactMaxBytes, agentSubnet, artifactLimiter).Register(artifactMux)
 
-	artifactGC := NewArtifactGC(store, profiles, cfg.ArtifactDir, log.Default(), redactor)
+	artifactGC := NewArtifactGC(store, profiles, cfg.ArtifactDir, log.Default(), redactor).
+		WithProfileRegistry(profileRegistry)
+	backupManager := NewBackupManager(store, BackupConfig{
+		Dir:        cfg.BackupDir,
+		DBPath:     cfg.DBPath,
+		AgeKeyPath: cfg.SecretsAgeKeyPath,
+		Encrypt:    cfg.BackupEncrypt,
+		Retention:  cfg.BackupRetention,
+		Interval:   cfg.BackupInterval,
+	}, log.Default())
+	retentionManager := NewRetentionManager(store, RetentionConfig{
+		Events:     cfg.EventRetention,
+		Messages:   cfg.MessageRetention,
+		AuditLog:   cfg.AuditLogRetention,
+		Interval:   cfg.CompactionInterval,
+		ArchiveDir: cfg.ArchiveDir,
+	}, log.Default())
 	idleStopper := NewIdleStopper(store, backend, profiles, sandboxManager, &ConntrackSessionDetector{}, log.Default(), metrics, IdleStopConfig{
 		Enabled:        cfg.IdleStopEnabled,
 		Interval:       cfg.IdleStopInterval,
 		DefaultMinutes: cfg.IdleStopMinutesDefault,
 		CPUThreshold:   cfg.IdleStopCPUThreshold,
-	})
+	}).WithProfileRegistry(profileRegistry)
+	usageCollector := NewUsageCollector(store, backend, metrics, log.Default(), cfg.UsageSampleInterval)
+	if lxcBackend != nil {
+		usageCollector.WithLXCBackend(newSandboxBackendAdapter(lxcBackend))
+	}
 
+	// The local socket stays trusted, except for requests carrying a token
+	// from the daemon's issuer: agentlab-dashboard forwards signed-in users'
+	// requests with their token, and those are confined like network ones.
+	var unixHandler http.Handler = localMux
+	if tokenIssuer != nil {
+		localAuth, _ := auth.NewMiddlewareWithStore(nil, "", nil)
+		unixHandler = localAuth.WithIssuer(tokenIssuer).WrapLocal(rbac.Wrap(approvals.Wrap(localMux)))
+	}
 	unixServer := &http.Server{
-		Handler:           localMux,
+		Handler:           unixHandler,
 		ReadHeaderTimeout: 5 * time.Second,
 		IdleTimeout:       2 * time.Minute,
 	}
@@ -581,16 +727,19 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 			_ = unixListener.Close()
 			return nil, fmt.Errorf("control auth setup: %w", err)
 		}
+		authMw.WithIssuer(tokenIssuer)
 		controlServer = &http.Server{
 			// WrapNetwork (not Wrap): the TCP control listener is the network
 			// trust boundary. It authenticates only; authorization is
 			// per-route. ControlAPI handlers call authorize, the standalone
 			// APIs (secrets, integrations, users, pool) call
 			// authorizeStandalone, and /v1/exec calls execAllowed. Scoped SSH
-			// tokens pass authentication and are then confined per route. The
-			// local Unix socket uses localMux directly and remains a trusted
-			// full-access path.
-			Handler:           authMw.WrapNetwork(localMux),
+			// tokens pass authentication and are then confined per route;
+			// rbac.Wrap attaches the custom-role grants of registered users
+			// in between, and approvals.Wrap lets authorization hold their
+			// changes for approval. The local Unix socket remains a trusted
+			// full-access path except for issuer tokens (see unixHandler).
+			Handler:           authMw.WrapNetwork(rbac.Wrap(approvals.Wrap(localMux))),
 			ReadHeaderTimeout: 5 * time.Second,
 			IdleTimeout:       2 * time.Minute,
 		}
@@ -632,7 +781,9 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 
 	s := &Service{
 		cfg:               cfg,
-		profiles:          profiles,
+		profileRegistry:   profileRegistry,
+		controlAPI:        controlAPI,
+		bootstrapAPI:      bootstrapAPI,
 		store:             store,
 		unixListener:      unixListener,
 		controlListener:   controlListener,
@@ -645,9 +796,14 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 		artifactServer:    artifactServer,
 		metricsServer:     metricsServer,
 		sandboxManager:    sandboxManager,
+		jobOrchestrator:   jobOrchestrator,
 		workspaceManager:  workspaceManager,
 		artifactGC:        artifactGC,
+		backupManager:     backupManager,
+		retentionManager:  retentionManager,
+		secretsResolver:   secretsResolver,
 		idleStopper:       idleStopper,
+		usageCollector:    usageCollector,
 		metrics:           metrics,
 		metadataRouting:   metadataRouting,
 		lxcBackend:        lxcBackend,
@@ -655,6 +811,9 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 		integrationStore:  integrationStore,
 		userRegistry:      userRegistry,
 		resourcePool:      resourcePool,
+		rbac:              rbac,
+		approvals:         approvals,
+		admission:         admission,
 	}
 	// Wire the daemon lifecycle runner into components that spawn detached work
 	// or run synchronous provisioning, so that work is cancelled and awaited at
@@ -667,6 +826,22 @@ func newService(cfg config.Config, profiles map[string]models.Profile, store *db
 	if controlAPI != nil {
 		controlAPI.WithBackgroundRunner(s)
 	}
+	var keyRotator *IntegrationKeyRotator
+	if integrationStore != nil {
+		keyRotator = NewIntegrationKeyRotator(store, integrationStore, cfg.IntegrationKeyringPath, log.Default())
+	}
+	NewAdminAPI(s).
+		WithBackupManager(backupManager).
+		WithRetentionManager(retentionManager).
+		WithIntegrationKeyRotator(keyRotator).
+		Register(localMux)
+	templateBuilds := NewTemplateBuilds(store, backend, snippetStore, cfg.TemplateImageDir, log.Default()).
+		WithBackgroundRunner(s)
+	NewTemplateAPI(store, templateBuilds).Register(localMux)
+	s.templateRollouts = NewTemplateRollouts(store, backend, profileRegistry, log.Default())
+	NewTemplateRolloutAPI(s.templateRollouts).Register(localMux)
+	s.reportAPI = NewReportAPI(store, profileRegistry, userRegistry, UsagePricesFromConfig(cfg))
+	s.reportAPI.Register(localMux)
 	return s, nil
 }
 
@@ -689,6 +864,21 @@ func (s *Service) Serve(ctx context.Context) error {
 	s.lifecycleCtx = lifecycleCtx
 	s.lifecycleCancel = lifecycleCancel
 	s.tasks = &taskTracker{}
+	if endpoint := strings.TrimSpace(s.cfg.TracingEndpoint); endpoint != "" {
+		tracer, err := tracing.New(tracing.Config{
+			Endpoint:       endpoint,
+			Headers:        s.cfg.TracingHeaders,
+			ServiceVersion: buildinfo.Version,
+			Logger:         log.Default(),
+		})
+		if err != nil {
+			log.Printf("warning: tracing disabled: %v", err)
+		} else {
+			s.tracer = tracer
+			tracing.SetTracer(tracer)
+			log.Printf("agentlabd: exporting traces to %s", endpoint)
+		}
+	}
 
 	serverCount := 3
 	if s.controlServer != nil {
@@ -706,10 +896,15 @@ func (s *Service) Serve(ctx context.Context) error {
 	if s.metricsServer != nil {
 		log.Printf("agentlabd: listening on metrics=%s", s.cfg.MetricsListen)
 	}
+	if s.restoredFrom != "" {
+		// A restored database describes the world as it was at backup time;
+		// repair it against the backend before anything acts on its rows.
+		s.reconcileAfterRestore(lifecycleCtx)
+	}
 	if s.resourcePool != nil && s.resourcePool.IsEnabled() {
 		// Rebuild in-memory pool accounting from live sandbox rows so a restart
 		// does not silently drop capacity enforcement (review H3).
-		if n, err := ReconstructPool(lifecycleCtx, s.resourcePool, s.store, s.profiles); err != nil {
+		if n, err := ReconstructPool(lifecycleCtx, s.resourcePool, s.store, s.profileRegistry.Snapshot()); err != nil {
 			log.Printf("agentlabd: pool reconstruction failed: %v", err)
 		} else if n > 0 {
 			log.Printf("agentlabd: reconstructed %d pool allocation(s) from live sandboxes", n)
@@ -723,12 +918,29 @@ func (s *Service) Serve(ctx context.Context) error {
 		s.sandboxManager.StartLeaseGC(lifecycleCtx)
 		s.sandboxManager.StartReconciler(lifecycleCtx)
 	}
+	if s.jobOrchestrator != nil {
+		// Release jobs whose parents finished before the last shutdown but
+		// which were never dispatched.
+		s.jobOrchestrator.ReleaseWaitingJobs(lifecycleCtx)
+	}
 	if s.idleStopper != nil {
 		s.idleStopper.Start(lifecycleCtx)
 	}
+	if s.usageCollector != nil {
+		s.usageCollector.Start(lifecycleCtx)
+	}
 	if s.artifactGC != nil {
 		s.artifactGC.Start(lifecycleCtx)
 	}
+	if s.backupManager != nil {
+		s.backupManager.Start(lifecycleCtx)
+	}
+	if s.retentionManager != nil {
+		s.retentionManager.Start(lifecycleCtx)
+	}
+	if s.templateRollouts != nil {
+		s.templateRollouts.StartGC(lifecycleCtx)
+	}
 	if s.resourcePool != nil && s.resourcePool.IsEnabled() {
 		s.startPoolReclaimer(lifecycleCtx)
 	}
@@ -843,6 +1055,15 @@ func (s *Service) shutdown() {
 	if s.metadataRouting != nil {
 		s.metadataRouting.Cleanup()
 	}
+	if s.tracer != nil {
+		// Export the spans of work that finished during shutdown.
+		tracing.SetTracer(nil)
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := s.tracer.Shutdown(flushCtx); err != nil {
+			log.Printf("agentlabd: flush traces: %v", err)
+		}
+		flushCancel()
+	}
 	if s.store != nil {
 		_ = s.store.Close()
 	}
@@ -26,10 +26,13 @@ const (
 )
 
 type doctorMeta struct {
-	Version int             `json:"version"`
-	Kind    string          `json:"kind"`
-	ID      string          `json:"id"`
-	Related *doctorRelated  `json:"related,omitempty"`
+	Version int            `json:"version"`
+	Kind    string         `json:"kind"`
+	ID      string         `json:"id"`
+	Related *doctorRelated `json:"related,omitempty"`
+	// TraceID is the OpenTelemetry trace that provisioned the job or sandbox,
+	// for finding its spans in the collector.
+	TraceID string          `json:"trace_id,omitempty"`
 	Notes   []string        `json:"notes,omitempty"`
 	Errors  []doctorSection `json:"errors,omitempty"`
 }
@@ -184,6 +187,7 @@ func (api *ControlAPI) handleSandboxDoctor(w http.ResponseWriter, r *http.Reques
 
 	input.Proxmox = api.proxmoxDoctorInfo(ctx, vmid)
 	input.Meta.Related = relatedIfPresent(related)
+	input.Meta.TraceID = doctorTraceID(ctx, api.store, related)
 
 	filename := fmt.Sprintf("%s-sandbox-%d.tar.gz", doctorBundleNamePrefix, vmid)
 	api.writeDoctorBundleResponse(w, filename, input)
@@ -285,6 +289,7 @@ func (api *ControlAPI) handleJobDoctor(w http.ResponseWriter, r *http.Request, j
 		input.Proxmox = &doctorProxmoxInfo{StatusError: "sandbox_vmid is not set", ConfigError: "sandbox_vmid is not set"}
 	}
 	input.Meta.Related = relatedIfPresent(related)
+	input.Meta.TraceID = doctorTraceID(ctx, api.store, related)
 
 	filename := fmt.Sprintf("%s-job-%s.tar.gz", doctorBundleNamePrefix, job.ID)
 	api.writeDoctorBundleResponse(w, filename, input)
@@ -367,6 +372,7 @@ func (api *ControlAPI) handleSessionDoctor(w http.ResponseWriter, r *http.Reques
 	}
 
 	input.Meta.Related = relatedIfPresent(related)
+	input.Meta.TraceID = doctorTraceID(ctx, api.store, related)
 
 	filename := fmt.Sprintf("%s-session-%s.tar.gz", doctorBundleNamePrefix, session.ID)
 	api.writeDoctorBundleResponse(w, filename, input)
@@ -449,6 +455,22 @@ func artifactsToV1Response(jobID string, artifacts []db.Artifact) *V1ArtifactsRe
 	return resp
 }
 
+// doctorTraceID returns the trace ID stored for the related job, falling back
+// to the related sandbox.
+func doctorTraceID(ctx context.Context, store *db.Store, related doctorRelated) string {
+	if related.JobID != nil {
+		if traceparent, err := store.GetJobTraceparent(ctx, *related.JobID); err == nil && traceparent != "" {
+			return traceIDFromParent(traceparent)
+		}
+	}
+	if related.SandboxVMID != nil {
+		if traceparent, err := store.GetSandboxTraceparent(ctx, *related.SandboxVMID); err == nil {
+			return traceIDFromParent(traceparent)
+		}
+	}
+	return ""
+}
+
 func relatedIfPresent(related doctorRelated) *doctorRelated {
 	if related.SandboxVMID == nil && related.JobID == nil && related.SessionID == nil && related.WorkspaceID == nil {
 		return nil
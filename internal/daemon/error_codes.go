@@ -46,6 +46,9 @@ const (
 	daemonErrorCodeArtifactsPathInvalid = daemonErrorCodeVersion + "/artifacts/invalid_path"
 	daemonErrorCodeArtifactsUnavailable = daemonErrorCodeVersion + "/artifacts/unavailable"
 
+	// Policy domain
+	daemonErrorCodePolicyDenied = daemonErrorCodeVersion + "/policy/denied"
+
 	// Generic fallbacks
 	daemonErrorCodeResourceNotFound = daemonErrorCodeVersion + "/resource/not_found"
 	daemonErrorCodeConflict         = daemonErrorCodeVersion + "/resource/conflict"
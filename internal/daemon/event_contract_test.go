@@ -11,12 +11,19 @@ import (
 
 func TestEventCatalogKindsAreCanonical(t *testing.T) {
 	knownDomains := map[EventDomain]struct{}{
-		eventDomainArtifact:  {},
-		eventDomainExposure:  {},
-		eventDomainJob:       {},
-		eventDomainRecovery:  {},
-		eventDomainSandbox:   {},
-		eventDomainWorkspace: {},
+		eventDomainArtifact:    {},
+		eventDomainConfig:      {},
+		eventDomainBackup:      {},
+		eventDomainRetention:   {},
+		eventDomainSecret:      {},
+		eventDomainIntegration: {},
+		eventDomainMessage:     {},
+		eventDomainTemplate:    {},
+		eventDomainExposure:    {},
+		eventDomainJob:         {},
+		eventDomainRecovery:    {},
+		eventDomainSandbox:     {},
+		eventDomainWorkspace:   {},
 	}
 	knownStages := map[EventStage]struct{}{
 		EventStageArtifact:  {},
@@ -25,6 +32,15 @@ func TestEventCatalogKindsAreCanonical(t *testing.T) {
 		EventStageLifecycle: {},
 		EventStageNetwork:   {},
 		EventStageRecovery:  {},
+		EventStageReload:    {},
+		EventStageBackup:    {},
+		EventStageRestore:   {},
+		EventStageCompact:   {},
+		EventStageAccess:    {},
+		EventStageRotation:  {},
+		EventStageQuestion:  {},
+		EventStageBuild:     {},
+		EventStageRollout:   {},
 		EventStageReport:    {},
 		EventStageSLO:       {},
 		EventStageSnapshot:  {},
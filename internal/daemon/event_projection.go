@@ -1,7 +1,9 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strings"
 	"time"
@@ -20,6 +22,16 @@ type EventProjection struct {
 	RecentFailureDigest []V1FailureDigest
 	recentFailureLimit  int
 	seenEventIDs        map[int64]struct{}
+	// throughEventID is the last event folded into a restored snapshot;
+	// Replay skips events at or below it.
+	throughEventID int64
+}
+
+// eventProjectionState is the stored form of a projection in event_snapshots.
+type eventProjectionState struct {
+	SandboxHealth       map[int]V1SandboxLifecycleSummary `json:"sandbox_health"`
+	JobTimelines        map[string]V1JobTimelineSummary   `json:"job_timelines"`
+	RecentFailureDigest []V1FailureDigest                 `json:"recent_failure_digest"`
 }
 
 func NewEventProjection() *EventProjection {
@@ -58,6 +70,9 @@ func (p *EventProjection) Replay(events []db.Event) {
 		return ordered[i].ID < ordered[j].ID
 	})
 	for _, ev := range ordered {
+		if ev.ID <= p.throughEventID {
+			continue
+		}
 		if _, exists := p.seenEventIDs[ev.ID]; exists {
 			continue
 		}
@@ -66,6 +81,67 @@ func (p *EventProjection) Replay(events []db.Event) {
 	}
 }
 
+// Snapshot encodes the projection for storage in event_snapshots.
+func (p *EventProjection) Snapshot() (string, error) {
+	if p == nil {
+		return "", fmt.Errorf("event projection is nil")
+	}
+	data, err := json.Marshal(eventProjectionState{
+		SandboxHealth:       p.SandboxHealth,
+		JobTimelines:        p.JobTimelines,
+		RecentFailureDigest: p.RecentFailureDigest,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encode event projection: %w", err)
+	}
+	return string(data), nil
+}
+
+// Restore replaces the projection with a stored snapshot. Events up to the
+// snapshot's ThroughEventID are already folded in and are skipped by Replay.
+func (p *EventProjection) Restore(snapshot db.EventSnapshot) error {
+	if p == nil {
+		return fmt.Errorf("event projection is nil")
+	}
+	var state eventProjectionState
+	if strings.TrimSpace(snapshot.State) != "" {
+		if err := json.Unmarshal([]byte(snapshot.State), &state); err != nil {
+			return fmt.Errorf("decode event snapshot: %w", err)
+		}
+	}
+	fresh := NewEventProjection()
+	for vmid, summary := range state.SandboxHealth {
+		fresh.SandboxHealth[vmid] = summary
+	}
+	for jobID, summary := range state.JobTimelines {
+		fresh.JobTimelines[jobID] = summary
+	}
+	fresh.RecentFailureDigest = append(fresh.RecentFailureDigest, state.RecentFailureDigest...)
+	if len(fresh.RecentFailureDigest) > fresh.recentFailureLimit {
+		fresh.RecentFailureDigest = fresh.RecentFailureDigest[len(fresh.RecentFailureDigest)-fresh.recentFailureLimit:]
+	}
+	fresh.throughEventID = snapshot.ThroughEventID
+	*p = *fresh
+	return nil
+}
+
+// loadEventProjection returns a projection seeded from the compaction
+// snapshot, if any. Callers Replay the surviving events on top.
+func loadEventProjection(ctx context.Context, store *db.Store) (*EventProjection, error) {
+	projection := NewEventProjection()
+	snapshot, ok, err := store.GetEventSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return projection, nil
+	}
+	if err := projection.Restore(snapshot); err != nil {
+		return nil, err
+	}
+	return projection, nil
+}
+
 func (p *EventProjection) applyEvent(ev db.Event) {
 	if p == nil {
 		return
@@ -133,6 +133,43 @@ func TestEventProjectionFiltersLatestFailures(t *testing.T) {
 	assert.Equal(t, string(EventKindSandboxStartFailed), projection.RecentFailureDigest[1].Kind)
 }
 
+func TestEventProjectionSnapshotRestore(t *testing.T) {
+	vmid := 3031
+	jobID := "job-snapshot"
+	now := time.Date(2026, 02, 14, 12, 0, 0, 0, time.UTC)
+	events := []db.Event{
+		eventForProjection(t, 1, now, EventKindSandboxState, &vmid, nil, "", map[string]any{
+			"from_state": "PROVISIONING",
+			"to_state":   "RUNNING",
+		}),
+		eventForProjection(t, 2, now.Add(time.Minute), EventKindJobCreated, nil, &jobID, "job created", map[string]any{
+			"status": "QUEUED",
+		}),
+		eventForProjection(t, 3, now.Add(2*time.Minute), EventKindJobRunning, nil, &jobID, "job running", nil),
+		eventForProjection(t, 4, now.Add(3*time.Minute), EventKindJobFailed, nil, &jobID, "job failed", nil),
+	}
+
+	full := NewEventProjection()
+	full.Replay(events)
+
+	folded := NewEventProjection()
+	folded.Replay(events[:2])
+	state, err := folded.Snapshot()
+	require.NoError(t, err)
+
+	restored := NewEventProjection()
+	require.NoError(t, restored.Restore(db.EventSnapshot{ThroughEventID: 2, State: state}))
+	// Events at or below the snapshot are already folded in and must not be
+	// applied twice.
+	restored.Replay(events)
+
+	assert.Equal(t, full.JobTimelines, restored.JobTimelines)
+	assert.Equal(t, full.SandboxHealth, restored.SandboxHealth)
+	assert.Equal(t, full.RecentFailureDigest, restored.RecentFailureDigest)
+
+	require.Error(t, NewEventProjection().Restore(db.EventSnapshot{ThroughEventID: 1, State: "{"}))
+}
+
 func eventForProjection(t *testing.T, id int64, ts time.Time, kind EventKind, vmid *int, jobID *string, msg string, payload any) db.Event {
 	t.Helper()
 	var payloadJSON string
@@ -7,12 +7,19 @@ type EventDomain string
 type EventStage string
 
 const (
-	eventDomainSandbox   EventDomain = "sandbox"
-	eventDomainJob       EventDomain = "job"
-	eventDomainWorkspace EventDomain = "workspace"
-	eventDomainArtifact  EventDomain = "artifact"
-	eventDomainExposure  EventDomain = "exposure"
-	eventDomainRecovery  EventDomain = "recovery"
+	eventDomainSandbox     EventDomain = "sandbox"
+	eventDomainJob         EventDomain = "job"
+	eventDomainWorkspace   EventDomain = "workspace"
+	eventDomainArtifact    EventDomain = "artifact"
+	eventDomainExposure    EventDomain = "exposure"
+	eventDomainRecovery    EventDomain = "recovery"
+	eventDomainConfig      EventDomain = "config"
+	eventDomainBackup      EventDomain = "backup"
+	eventDomainRetention   EventDomain = "retention"
+	eventDomainSecret      EventDomain = "secret"
+	eventDomainIntegration EventDomain = "integration"
+	eventDomainMessage     EventDomain = "message"
+	eventDomainTemplate    EventDomain = "template"
 )
 
 const (
@@ -25,6 +32,15 @@ const (
 	EventStageNetwork   EventStage = "network"
 	EventStageArtifact  EventStage = "artifact"
 	EventStageExposure  EventStage = "exposure"
+	EventStageReload    EventStage = "reload"
+	EventStageBackup    EventStage = "backup"
+	EventStageRestore   EventStage = "restore"
+	EventStageCompact   EventStage = "compaction"
+	EventStageAccess    EventStage = "access"
+	EventStageRotation  EventStage = "rotation"
+	EventStageQuestion  EventStage = "question"
+	EventStageBuild     EventStage = "build"
+	EventStageRollout   EventStage = "rollout"
 )
 
 const (
@@ -60,13 +76,16 @@ const (
 	EventKindSandboxStopAll          EventKind = "sandbox.stop_all"
 	EventKindSandboxStopAllResult    EventKind = "sandbox.stop_all.result"
 	EventKindSandboxIdleStop         EventKind = "sandbox.idle_stop"
+	EventKindSandboxKVUpdated        EventKind = "sandbox.kv_updated"
 
 	// Job lifecycle.
-	EventKindJobCreated  EventKind = "job.created"
-	EventKindJobRunning  EventKind = "job.running"
-	EventKindJobFailed   EventKind = "job.failed"
-	EventKindJobReport   EventKind = "job.report"
-	EventKindJobSLOStart EventKind = "job.slo.start"
+	EventKindJobCreated       EventKind = "job.created"
+	EventKindJobRunning       EventKind = "job.running"
+	EventKindJobFailed        EventKind = "job.failed"
+	EventKindJobReport        EventKind = "job.report"
+	EventKindJobSLOStart      EventKind = "job.slo.start"
+	EventKindJobPublished     EventKind = "job.published"
+	EventKindJobPublishFailed EventKind = "job.publish_failed"
 
 	// Workspace lifecycle and lease flow.
 	EventKindWorkspaceLeaseAcquired         EventKind = "workspace.lease.acquired"
@@ -86,6 +105,45 @@ const (
 	EventKindExposureCreate        EventKind = "exposure.create"
 	EventKindExposureDelete        EventKind = "exposure.delete"
 	EventKindExposureCleanupFailed EventKind = "exposure.cleanup.failed"
+
+	// Daemon configuration reloads.
+	EventKindConfigReloaded     EventKind = "config.reloaded"
+	EventKindConfigReloadFailed EventKind = "config.reload_failed"
+
+	// Database backups and restores.
+	EventKindBackupCreated       EventKind = "backup.created"
+	EventKindBackupFailed        EventKind = "backup.failed"
+	EventKindBackupRestoreStaged EventKind = "backup.restore_staged"
+	EventKindBackupRestored      EventKind = "backup.restored"
+
+	// Event, message and audit log retention.
+	EventKindRetentionCompacted        EventKind = "retention.compacted"
+	EventKindRetentionCompactionFailed EventKind = "retention.compaction_failed"
+
+	// Approval-gated secret access.
+	EventKindSecretAccessRequested EventKind = "secret.access_requested"
+	EventKindSecretAccessApproved  EventKind = "secret.access_approved"
+	EventKindSecretAccessDenied    EventKind = "secret.access_denied"
+
+	// Integration encryption key rotation.
+	EventKindIntegrationKeyRotated        EventKind = "integration.key_rotated"
+	EventKindIntegrationKeyRotationFailed EventKind = "integration.key_rotation_failed"
+
+	// Agent questions answered by an operator.
+	EventKindMessageQuestionAsked    EventKind = "message.question_asked"
+	EventKindMessageQuestionAnswered EventKind = "message.question_answered"
+
+	// VM template builds.
+	EventKindTemplateBuildStarted EventKind = "template.build.started"
+	EventKindTemplateBuildReady   EventKind = "template.build.ready"
+	EventKindTemplateBuildFailed  EventKind = "template.build.failed"
+	EventKindTemplateRetired      EventKind = "template.retired"
+
+	// Profile template rollouts.
+	EventKindTemplateRolloutStarted    EventKind = "template.rollout.started"
+	EventKindTemplateRolloutUpdated    EventKind = "template.rollout.updated"
+	EventKindTemplateRolloutPromoted   EventKind = "template.rollout.promoted"
+	EventKindTemplateRolloutRolledBack EventKind = "template.rollout.rolled_back"
 )
 
 type EventPayloadSchema struct {
@@ -208,6 +266,11 @@ var EventCatalog = map[EventKind]EventPayloadSchema{
 		Kind: EventKindSandboxIdleStop, Domain: eventDomainRecovery, Stage: EventStageRecovery, Schema: eventContractSchemaVersion,
 		Required: []string{"idle_for_minutes"}, Optional: []string{"error"}, Description: "Background idle-stop action completed.",
 	},
+	EventKindSandboxKVUpdated: {
+		Kind: EventKindSandboxKVUpdated, Domain: eventDomainSandbox, Stage: EventStageReport, Schema: eventContractSchemaVersion,
+		Required: []string{"key"}, Optional: []string{"value", "deleted"},
+		Description: "Guest wrote or deleted a status value through PUT or DELETE /metadata/kv/{key}.",
+	},
 
 	EventKindJobCreated: {
 		Kind: EventKindJobCreated, Domain: eventDomainJob, Stage: EventStageLifecycle, Schema: eventContractSchemaVersion,
@@ -225,6 +288,14 @@ var EventCatalog = map[EventKind]EventPayloadSchema{
 		Kind: EventKindJobReport, Domain: eventDomainJob, Stage: EventStageReport, Schema: eventContractSchemaVersion,
 		Required: []string{"status"}, Optional: []string{"reported_at", "artifacts", "result", "message"}, Description: "Periodic or final job report from runner.",
 	},
+	EventKindJobPublished: {
+		Kind: EventKindJobPublished, Domain: eventDomainJob, Stage: EventStageReport, Schema: eventContractSchemaVersion,
+		Required: []string{"branch", "commit"}, Optional: []string{"pr_url", "provider", "published_at"}, Description: "Job commits pushed to a branch (and a pull request opened when requested).",
+	},
+	EventKindJobPublishFailed: {
+		Kind: EventKindJobPublishFailed, Domain: eventDomainJob, Stage: EventStageReport, Schema: eventContractSchemaVersion,
+		Required: []string{"branch", "error"}, Optional: []string{"commit", "provider", "published_at"}, Description: "Pushing job commits or opening the pull request failed.",
+	},
 	EventKindJobSLOStart: {
 		Kind: EventKindJobSLOStart, Domain: eventDomainJob, Stage: EventStageSLO, Schema: eventContractSchemaVersion,
 		Required: []string{"duration_ms"}, Description: "Job start duration SLO event.",
@@ -273,7 +344,7 @@ var EventCatalog = map[EventKind]EventPayloadSchema{
 
 	EventKindArtifactUpload: {
 		Kind: EventKindArtifactUpload, Domain: eventDomainArtifact, Stage: EventStageArtifact, Schema: eventContractSchemaVersion,
-		Required: []string{"name"}, Optional: []string{"path", "vmid", "size_bytes", "sha256", "mime"}, Description: "Artifact upload completed.",
+		Required: []string{"name"}, Optional: []string{"path", "vmid", "size_bytes", "sha256", "mime", "kind", "user"}, Description: "Artifact upload completed.",
 	},
 	EventKindArtifactGC: {
 		Kind: EventKindArtifactGC, Domain: eventDomainArtifact, Stage: EventStageArtifact, Schema: eventContractSchemaVersion,
@@ -291,4 +362,114 @@ var EventCatalog = map[EventKind]EventPayloadSchema{
 		Kind: EventKindExposureCleanupFailed, Domain: eventDomainExposure, Stage: EventStageExposure, Schema: eventContractSchemaVersion,
 		Required: []string{"name", "vmid", "port", "error"}, Description: "Exposure cleanup encountered an error.",
 	},
+	EventKindConfigReloaded: {
+		Kind: EventKindConfigReloaded, Domain: eventDomainConfig, Stage: EventStageReload, Schema: eventContractSchemaVersion,
+		Required: []string{"trigger", "profiles"}, Optional: []string{"profiles_added", "profiles_removed", "profiles_changed", "config_changed", "restart_required"},
+		Description: "Profiles and reloadable config re-read and published.",
+	},
+	EventKindConfigReloadFailed: {
+		Kind: EventKindConfigReloadFailed, Domain: eventDomainConfig, Stage: EventStageReload, Schema: eventContractSchemaVersion,
+		Required: []string{"trigger", "error"}, Description: "Config reload rejected; the previous config stays active.",
+	},
+	EventKindBackupCreated: {
+		Kind: EventKindBackupCreated, Domain: eventDomainBackup, Stage: EventStageBackup, Schema: eventContractSchemaVersion,
+		Required: []string{"trigger", "path", "size_bytes", "schema_version", "encrypted"}, Optional: []string{"pruned"},
+		Description: "Database backup written.",
+	},
+	EventKindBackupFailed: {
+		Kind: EventKindBackupFailed, Domain: eventDomainBackup, Stage: EventStageBackup, Schema: eventContractSchemaVersion,
+		Required: []string{"trigger", "error"}, Description: "Database backup failed.",
+	},
+	EventKindBackupRestoreStaged: {
+		Kind: EventKindBackupRestoreStaged, Domain: eventDomainBackup, Stage: EventStageRestore, Schema: eventContractSchemaVersion,
+		Required: []string{"source", "schema_version"}, Description: "Backup verified and staged; it replaces the database on the next start.",
+	},
+	EventKindBackupRestored: {
+		Kind: EventKindBackupRestored, Domain: eventDomainBackup, Stage: EventStageRestore, Schema: eventContractSchemaVersion,
+		Required: []string{"previous_db"}, Optional: []string{"checked", "drifted", "reconciled", "error"},
+		Description: "Staged backup swapped in at startup and reconciled against the backend inventory.",
+	},
+	EventKindRetentionCompacted: {
+		Kind: EventKindRetentionCompacted, Domain: eventDomainRetention, Stage: EventStageCompact, Schema: eventContractSchemaVersion,
+		Required: []string{"events", "messages", "audit_log"}, Optional: []string{"through_event_id", "archives"},
+		Description: "Expired events folded into the projection snapshot; expired rows archived and deleted.",
+	},
+	EventKindRetentionCompactionFailed: {
+		Kind: EventKindRetentionCompactionFailed, Domain: eventDomainRetention, Stage: EventStageCompact, Schema: eventContractSchemaVersion,
+		Required: []string{"error"}, Description: "Compaction run failed; rows that were not archived are kept.",
+	},
+	EventKindSecretAccessRequested: {
+		Kind: EventKindSecretAccessRequested, Domain: eventDomainSecret, Stage: EventStageAccess, Schema: eventContractSchemaVersion,
+		Required: []string{"request_id", "secret"}, Optional: []string{"profile", "grant_ttl_seconds"},
+		Description: "Sandbox asked to read an approval-gated secret; the request waits for an operator.",
+	},
+	EventKindSecretAccessApproved: {
+		Kind: EventKindSecretAccessApproved, Domain: eventDomainSecret, Stage: EventStageAccess, Schema: eventContractSchemaVersion,
+		Required:    []string{"request_id", "secret", "decided_by", "expires_at"},
+		Description: "Operator approved a secret access request; the grant lasts until expires_at.",
+	},
+	EventKindSecretAccessDenied: {
+		Kind: EventKindSecretAccessDenied, Domain: eventDomainSecret, Stage: EventStageAccess, Schema: eventContractSchemaVersion,
+		Required:    []string{"request_id", "secret", "decided_by"},
+		Description: "Operator denied a secret access request.",
+	},
+	EventKindIntegrationKeyRotated: {
+		Kind: EventKindIntegrationKeyRotated, Domain: eventDomainIntegration, Stage: EventStageRotation, Schema: eventContractSchemaVersion,
+		Required: []string{"version", "reencrypted"}, Optional: []string{"retired"},
+		Description: "Integration secrets re-encrypted under a new key version; the previous version retired.",
+	},
+	EventKindIntegrationKeyRotationFailed: {
+		Kind: EventKindIntegrationKeyRotationFailed, Domain: eventDomainIntegration, Stage: EventStageRotation, Schema: eventContractSchemaVersion,
+		Required: []string{"error"}, Description: "Integration key rotation failed; secrets stay under the previous key.",
+	},
+	EventKindMessageQuestionAsked: {
+		Kind: EventKindMessageQuestionAsked, Domain: eventDomainMessage, Stage: EventStageQuestion, Schema: eventContractSchemaVersion,
+		Required: []string{"message_id", "text"}, Optional: []string{"options"},
+		Description: "Agent posted a question through POST /metadata/messages and is waiting for an operator.",
+	},
+	EventKindMessageQuestionAnswered: {
+		Kind: EventKindMessageQuestionAnswered, Domain: eventDomainMessage, Stage: EventStageQuestion, Schema: eventContractSchemaVersion,
+		Required:    []string{"message_id", "answer", "answered_by"},
+		Description: "Operator answered an agent's question; the waiting guest receives the answer.",
+	},
+	EventKindTemplateBuildStarted: {
+		Kind: EventKindTemplateBuildStarted, Domain: eventDomainTemplate, Stage: EventStageBuild, Schema: eventContractSchemaVersion,
+		Required: []string{"template", "version", "vmid"}, Optional: []string{"built_by"},
+		Description: "Template build accepted; the builder VM is being created from the spec's image.",
+	},
+	EventKindTemplateBuildReady: {
+		Kind: EventKindTemplateBuildReady, Domain: eventDomainTemplate, Stage: EventStageBuild, Schema: eventContractSchemaVersion,
+		Required:    []string{"template", "version", "vmid", "duration_ms"},
+		Description: "Builder VM provisioned and converted; profiles can now reference the version.",
+	},
+	EventKindTemplateBuildFailed: {
+		Kind: EventKindTemplateBuildFailed, Domain: eventDomainTemplate, Stage: EventStageBuild, Schema: eventContractSchemaVersion,
+		Required: []string{"template", "version", "error"}, Optional: []string{"vmid", "duration_ms"},
+		Description: "Template build failed; the builder VM was destroyed.",
+	},
+	EventKindTemplateRetired: {
+		Kind: EventKindTemplateRetired, Domain: eventDomainTemplate, Stage: EventStageLifecycle, Schema: eventContractSchemaVersion,
+		Required:    []string{"template", "version", "vmid"},
+		Description: "Unused template version garbage-collected; its VM was destroyed.",
+	},
+	EventKindTemplateRolloutStarted: {
+		Kind: EventKindTemplateRolloutStarted, Domain: eventDomainTemplate, Stage: EventStageRollout, Schema: eventContractSchemaVersion,
+		Required: []string{"profile", "template", "from_version", "to_version", "percent"}, Optional: []string{"started_by"},
+		Description: "Profile rollout started; the given percent of new sandboxes use the new version.",
+	},
+	EventKindTemplateRolloutUpdated: {
+		Kind: EventKindTemplateRolloutUpdated, Domain: eventDomainTemplate, Stage: EventStageRollout, Schema: eventContractSchemaVersion,
+		Required:    []string{"profile", "template", "from_version", "to_version", "percent"},
+		Description: "Share of new sandboxes routed to the new version changed.",
+	},
+	EventKindTemplateRolloutPromoted: {
+		Kind: EventKindTemplateRolloutPromoted, Domain: eventDomainTemplate, Stage: EventStageRollout, Schema: eventContractSchemaVersion,
+		Required: []string{"profile", "template", "from_version", "to_version"}, Optional: []string{"verdict"},
+		Description: "New version promoted; every new sandbox of the profile uses it.",
+	},
+	EventKindTemplateRolloutRolledBack: {
+		Kind: EventKindTemplateRolloutRolledBack, Domain: eventDomainTemplate, Stage: EventStageRollout, Schema: eventContractSchemaVersion,
+		Required: []string{"profile", "template", "from_version", "to_version"}, Optional: []string{"verdict"},
+		Description: "Rollout abandoned; new sandboxes use the previous version again.",
+	},
 }
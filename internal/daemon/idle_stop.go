@@ -85,7 +85,7 @@ type idleStopEventPayload struct {
 type IdleStopper struct {
 	store      *db.Store
 	backend    proxmox.Backend
-	profiles   map[string]models.Profile
+	profiles   *ProfileRegistry
 	manager    *SandboxManager
 	detector   SSHSessionDetector
 	logger     *log.Logger
@@ -104,7 +104,7 @@ func NewIdleStopper(store *db.Store, backend proxmox.Backend, profiles map[strin
 	return &IdleStopper{
 		store:      store,
 		backend:    backend,
-		profiles:   profiles,
+		profiles:   NewProfileRegistry(profiles),
 		manager:    manager,
 		detector:   detector,
 		logger:     logger,
@@ -115,6 +115,28 @@ func NewIdleStopper(store *db.Store, backend proxmox.Backend, profiles map[strin
 	}
 }
 
+// WithProfileRegistry shares a profile registry so config reloads reach the
+// idle stopper.
+func (s *IdleStopper) WithProfileRegistry(profiles *ProfileRegistry) *IdleStopper {
+	if s == nil || profiles == nil {
+		return s
+	}
+	s.profiles = profiles
+	return s
+}
+
+// UpdateThresholds applies reloaded idle thresholds to later evaluations.
+// Enabled and Interval only take effect at startup.
+func (s *IdleStopper) UpdateThresholds(defaultMinutes int, cpuThreshold float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.DefaultMinutes = defaultMinutes
+	s.cfg.CPUThreshold = cpuThreshold
+}
+
 // Start runs idle stop evaluation on an interval until ctx is canceled.
 func (s *IdleStopper) Start(ctx context.Context) {
 	if !s.enabled() {
@@ -165,7 +187,7 @@ func (s *IdleStopper) Evaluate(ctx context.Context) {
 }
 
 func (s *IdleStopper) evaluateSandbox(ctx context.Context, now time.Time, sb models.Sandbox) {
-	profile, ok := s.profiles[sb.Profile]
+	profile, ok := s.profiles.Get(sb.Profile)
 	if !ok {
 		s.logger.Printf("idle stop: vmid=%d unknown profile %q", sb.VMID, sb.Profile)
 		return
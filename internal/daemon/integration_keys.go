@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/agentlab/agentlab/internal/config"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/integrations"
+)
+
+// loadIntegrationKeyring picks the keys the integration store starts with.
+// A keyring file written by an earlier rotation wins; otherwise
+// integration_enc_key is key version 1. With neither, an ephemeral key is
+// generated and ephemeral is true.
+func loadIntegrationKeyring(cfg config.Config) (keyring integrations.Keyring, ephemeral bool, err error) {
+	if cfg.IntegrationKeyringPath != "" {
+		keyring, err = integrations.LoadKeyring(cfg.IntegrationKeyringPath)
+		if err == nil {
+			return keyring, false, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return integrations.Keyring{}, false, fmt.Errorf("load integration keyring: %w", err)
+		}
+	}
+	if cfg.IntegrationEncKey != "" {
+		key, err := integrations.ParseEncryptionKeyHex(cfg.IntegrationEncKey)
+		if err != nil {
+			return integrations.Keyring{}, false, fmt.Errorf("parse integration_enc_key: %w", err)
+		}
+		return integrations.SingleKeyring(key), false, nil
+	}
+	key, err := integrations.GenerateEncryptionKey()
+	if err != nil {
+		return integrations.Keyring{}, false, fmt.Errorf("generate integration encryption key: %w", err)
+	}
+	return integrations.SingleKeyring(key), true, nil
+}
+
+// IntegrationKeyRotator re-encrypts integration secrets under a fresh key and
+// persists the keyring so the new key survives a restart.
+type IntegrationKeyRotator struct {
+	store        *db.Store
+	integrations *integrations.Store
+	path         string
+	logger       *log.Logger
+}
+
+type integrationKeyRotatedPayload struct {
+	Version     int   `json:"version"`
+	Reencrypted int   `json:"reencrypted"`
+	Retired     []int `json:"retired,omitempty"`
+}
+
+type integrationKeyRotationFailedPayload struct {
+	Error string `json:"error"`
+}
+
+// NewIntegrationKeyRotator creates a rotator that writes the keyring to path.
+func NewIntegrationKeyRotator(store *db.Store, integ *integrations.Store, path string, logger *log.Logger) *IntegrationKeyRotator {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &IntegrationKeyRotator{store: store, integrations: integ, path: path, logger: logger}
+}
+
+// Rotate generates a new key and moves every integration secret to it.
+//
+// The keyring file is written with both the old and new keys before any row
+// changes. The old keys are never pruned: backups hold the database but not
+// the keyring, so a restored backup needs them to read its rows.
+func (r *IntegrationKeyRotator) Rotate(ctx context.Context) (integrations.RotateResult, error) {
+	if r == nil || r.integrations == nil {
+		return integrations.RotateResult{}, errors.New("integrations are not enabled")
+	}
+	if r.path == "" {
+		return integrations.RotateResult{}, errors.New("integration_keyring_path is not configured")
+	}
+	key, err := integrations.GenerateEncryptionKey()
+	if err != nil {
+		return integrations.RotateResult{}, err
+	}
+	result, err := r.integrations.RotateKey(ctx, key, func(keyring integrations.Keyring) error {
+		return integrations.WriteKeyring(r.path, keyring)
+	})
+	if err != nil {
+		r.logger.Printf("agentlabd: integration key rotation failed: %v", err)
+		_ = emitEvent(ctx, NewStoreEventRecorder(r.store), EventKindIntegrationKeyRotationFailed, nil, nil, "integration key rotation failed", integrationKeyRotationFailedPayload{
+			Error: err.Error(),
+		})
+		return integrations.RotateResult{}, err
+	}
+	r.logger.Printf("agentlabd: integration key rotated to version %d (%d secrets re-encrypted)", result.Version, result.Reencrypted)
+	_ = emitEvent(ctx, NewStoreEventRecorder(r.store), EventKindIntegrationKeyRotated, nil, nil, "integration key rotated", integrationKeyRotatedPayload{
+		Version:     result.Version,
+		Reencrypted: result.Reencrypted,
+		Retired:     result.Retired,
+	})
+	return result, nil
+}
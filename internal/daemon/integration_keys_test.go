@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/config"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/integrations"
+)
+
+func TestLoadIntegrationKeyring(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := integrations.GenerateEncryptionKey()
+	cfg := config.Config{IntegrationKeyringPath: filepath.Join(dir, "keyring.json")}
+
+	if _, ephemeral, err := loadIntegrationKeyring(cfg); err != nil || !ephemeral {
+		t.Fatalf("no key configured: ephemeral = %v err = %v, want ephemeral", ephemeral, err)
+	}
+
+	cfg.IntegrationEncKey = integrations.EncryptionKeyHex(key)
+	keyring, ephemeral, err := loadIntegrationKeyring(cfg)
+	if err != nil || ephemeral || keyring.Current != 1 {
+		t.Fatalf("enc key only: current = %d ephemeral = %v err = %v", keyring.Current, ephemeral, err)
+	}
+
+	rotated, _ := integrations.GenerateEncryptionKey()
+	if err := integrations.WriteKeyring(cfg.IntegrationKeyringPath, integrations.Keyring{Current: 3, Keys: map[int][]byte{3: rotated}}); err != nil {
+		t.Fatalf("write keyring: %v", err)
+	}
+	keyring, _, err = loadIntegrationKeyring(cfg)
+	if err != nil || keyring.Current != 3 {
+		t.Fatalf("keyring file: current = %d err = %v, want 3", keyring.Current, err)
+	}
+
+	cfg.IntegrationEncKey = "not-hex"
+	cfg.IntegrationKeyringPath = filepath.Join(dir, "missing.json")
+	if _, _, err := loadIntegrationKeyring(cfg); err == nil {
+		t.Fatal("invalid integration_enc_key: expected error")
+	}
+}
+
+func TestAdminAPIRotateKey(t *testing.T) {
+	mux := http.NewServeMux()
+	NewAdminAPI(nil).Register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/rotate-key", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without integrations status = %d, want 503", rec.Code)
+	}
+
+	store := newTestStore(t)
+	key, _ := integrations.GenerateEncryptionKey()
+	integStore, err := integrations.NewStore(store, key)
+	if err != nil {
+		t.Fatalf("new integration store: %v", err)
+	}
+	if err := integStore.Create(context.Background(), &integrations.Integration{
+		Name:       "github",
+		Type:       integrations.TypeHTTPProxy,
+		Target:     "https://api.github.com",
+		Secret:     "ghp_secret",
+		SecretType: "bearer",
+		AttachMode: integrations.AttachAutoAll,
+	}); err != nil {
+		t.Fatalf("create integration: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	mux = http.NewServeMux()
+	NewAdminAPI(nil).WithIntegrationKeyRotator(NewIntegrationKeyRotator(store, integStore, path, nil)).Register(mux)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/rotate-key", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want 405", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/rotate-key", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d body=%s, want 200", rec.Code, rec.Body.String())
+	}
+	var resp V1RotateKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Version != 2 || resp.Reencrypted != 1 {
+		t.Fatalf("rotate response = %+v, want version 2 with 1 row", resp)
+	}
+
+	saved, err := integrations.LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("load saved keyring: %v", err)
+	}
+	if saved.Current != 2 || len(saved.Keys) != 2 {
+		t.Fatalf("saved keyring = current %d with %d keys, want current 2 keeping version 1", saved.Current, len(saved.Keys))
+	}
+	reopened, err := integrations.NewStoreWithKeyring(store, saved)
+	if err != nil {
+		t.Fatalf("reopen with saved keyring: %v", err)
+	}
+	got, err := reopened.Get(context.Background(), "github")
+	if err != nil || got.Secret != "ghp_secret" {
+		t.Fatalf("Get after restart = %+v err = %v", got, err)
+	}
+	if n := countEvents(t, store, EventKindIntegrationKeyRotated); n != 1 {
+		t.Fatalf("integration.key_rotated events = %d, want 1", n)
+	}
+}
+
+func TestIntegrationKeyRotationKeepsBackupsReadable(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	key, _ := integrations.GenerateEncryptionKey()
+	integStore, err := integrations.NewStore(store, key)
+	if err != nil {
+		t.Fatalf("new integration store: %v", err)
+	}
+	if err := integStore.Create(ctx, &integrations.Integration{
+		Name:       "github",
+		Type:       integrations.TypeHTTPProxy,
+		Target:     "https://api.github.com",
+		Secret:     "ghp_secret",
+		SecretType: "bearer",
+		AttachMode: integrations.AttachAutoAll,
+	}); err != nil {
+		t.Fatalf("create integration: %v", err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "restore", "agentlab.db")
+	backups := newTestBackupManager(t, store, BackupConfig{DBPath: dbPath})
+	backup, err := backups.Backup(ctx, BackupTriggerAPI)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+
+	cfg := config.Config{IntegrationKeyringPath: filepath.Join(t.TempDir(), "keyring.json")}
+	for i := 0; i < 2; i++ {
+		if _, err := NewIntegrationKeyRotator(store, integStore, cfg.IntegrationKeyringPath, nil).Rotate(ctx); err != nil {
+			t.Fatalf("rotate %d: %v", i, err)
+		}
+	}
+
+	if _, err := backups.StageRestore(ctx, backup.Name); err != nil {
+		t.Fatalf("stage restore: %v", err)
+	}
+	if _, err := db.ApplyStagedRestore(dbPath, time.Now()); err != nil {
+		t.Fatalf("apply restore: %v", err)
+	}
+	restored, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open restored db: %v", err)
+	}
+	t.Cleanup(func() { _ = restored.Close() })
+
+	// The restarted daemon loads the rotated keyring, which still holds the
+	// version the backup's rows were written with.
+	keyring, _, err := loadIntegrationKeyring(cfg)
+	if err != nil || keyring.Current != 3 {
+		t.Fatalf("load keyring: current = %d err = %v, want 3", keyring.Current, err)
+	}
+	reopened, err := integrations.NewStoreWithKeyring(restored, keyring)
+	if err != nil {
+		t.Fatalf("reopen with rotated keyring: %v", err)
+	}
+	got, err := reopened.Get(ctx, "github")
+	if err != nil || got.Secret != "ghp_secret" || got.KeyVersion != 1 {
+		t.Fatalf("Get after restore = %+v err = %v, want the version 1 secret", got, err)
+	}
+}
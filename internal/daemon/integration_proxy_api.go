@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"context"
 	"log"
 	"net"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/integrations"
 	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/tracing"
 )
 
 // IntegrationProxyAPI serves integration proxy routes on the bootstrap mux
@@ -123,6 +125,19 @@ func (api *IntegrationProxyAPI) handleProxy(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	w, r, finish := startServerSpan(w, r, requestTraceContext(r, func(ctx context.Context) context.Context {
+		return sandboxTraceContext(ctx, api.dbStore, sandbox.VMID)
+	}), "integration.proxy",
+		tracing.String("integration.name", integ.Name),
+		tracing.String("integration.type", string(integ.Type)),
+		tracing.Int("vmid", sandbox.VMID),
+	)
+	defer finish()
+	// Upstreams see the proxy span as their parent.
+	if traceparent := tracing.Traceparent(r.Context()); traceparent != "" {
+		r.Header.Set("traceparent", traceparent)
+	}
+
 	// Audit log the proxy access.
 	api.auditProxyAccess(r, integ, sandboxName)
 
@@ -136,6 +151,9 @@ func (api *IntegrationProxyAPI) handleProxy(w http.ResponseWriter, r *http.Reque
 		handler := integrations.GitProxyHandler(integ, api.logger, opts)
 		handler.ServeHTTP(w, r)
 	case integrations.TypeLLMProxy:
+		if identified && api.dbStore != nil {
+			opts.OnTokenUsage = api.tokenUsageRecorder(r.Context(), integ.Name, sandbox.VMID)
+		}
 		handler := integrations.LLMProxyHandler(integ, api.logger, opts)
 		handler.ServeHTTP(w, r)
 	default:
@@ -143,6 +161,26 @@ func (api *IntegrationProxyAPI) handleProxy(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// tokenUsageRecorder stores the token usage of one LLM request against the
+// sandbox that made it, for usage reports. Recording outlives a client that
+// disconnects once the response is relayed.
+func (api *IntegrationProxyAPI) tokenUsageRecorder(ctx context.Context, integration string, vmid int) func(integrations.TokenUsage) {
+	ctx = context.WithoutCancel(ctx)
+	return func(usage integrations.TokenUsage) {
+		err := api.dbStore.RecordLLMUsage(ctx, db.LLMUsage{
+			VMID:         vmid,
+			Integration:  integration,
+			Provider:     usage.Provider,
+			Model:        usage.Model,
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+		})
+		if err != nil {
+			api.logger.Printf("credential-proxy: record llm usage vmid=%d integration=%s: %v", vmid, integration, err)
+		}
+	}
+}
+
 // sandboxBySourceIP resolves the calling sandbox from the request's source IP.
 // Only a unique sandbox in an eligible live state counts as identified; a
 // missing, stale, destroyed, or ambiguous source returns identified=false so
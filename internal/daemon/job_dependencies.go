@@ -0,0 +1,268 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+// maxJobDependencies bounds depends_on so a single job cannot fan in from an
+// unbounded parent set.
+const maxJobDependencies = 16
+
+// ErrJobDependencyUnmet marks a waiting job whose parents finished without
+// satisfying its depends_condition. The job is failed without provisioning.
+var ErrJobDependencyUnmet = errors.New("job dependency condition not met")
+
+// jobDependencySpec is the validated dependency part of a job create request.
+type jobDependencySpec struct {
+	Parents         []string
+	Condition       models.JobDependencyCondition
+	ParentArtifacts bool
+}
+
+// resolveJobDependencies validates depends_on, depends_condition and
+// parent_artifacts. Every parent must exist and be visible to the caller; a
+// sandbox-scoped token cannot chain onto jobs outside its scope. On failure it
+// returns the HTTP status to report.
+func (api *ControlAPI) resolveJobDependencies(r *http.Request, req V1JobCreateRequest) (jobDependencySpec, int, error) {
+	condition := strings.ToLower(strings.TrimSpace(req.DependsCondition))
+	if len(req.DependsOn) == 0 {
+		if condition != "" {
+			return jobDependencySpec{}, http.StatusBadRequest, errors.New("depends_condition requires depends_on")
+		}
+		if req.ParentArtifacts {
+			return jobDependencySpec{}, http.StatusBadRequest, errors.New("parent_artifacts requires depends_on")
+		}
+		return jobDependencySpec{}, 0, nil
+	}
+	if len(req.DependsOn) > maxJobDependencies {
+		return jobDependencySpec{}, http.StatusBadRequest, fmt.Errorf("depends_on allows at most %d jobs", maxJobDependencies)
+	}
+	spec := jobDependencySpec{ParentArtifacts: req.ParentArtifacts}
+	switch models.JobDependencyCondition(condition) {
+	case "":
+		spec.Condition = models.JobDependsOnSuccess
+	case models.JobDependsOnSuccess, models.JobDependsOnFailure, models.JobDependsAlways:
+		spec.Condition = models.JobDependencyCondition(condition)
+	default:
+		return jobDependencySpec{}, http.StatusBadRequest, fmt.Errorf("depends_condition must be success, failure, or always")
+	}
+	allowed := sandboxScopeFilter(r)
+	seen := make(map[string]struct{}, len(req.DependsOn))
+	for _, raw := range req.DependsOn {
+		parentID := strings.TrimSpace(raw)
+		if parentID == "" {
+			return jobDependencySpec{}, http.StatusBadRequest, errors.New("depends_on contains an empty job id")
+		}
+		if _, ok := seen[parentID]; ok {
+			continue
+		}
+		seen[parentID] = struct{}{}
+		parent, err := api.store.GetJob(r.Context(), parentID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return jobDependencySpec{}, http.StatusNotFound, fmt.Errorf("depends_on job %s not found", parentID)
+			}
+			return jobDependencySpec{}, http.StatusInternalServerError, errors.New("failed to load depends_on job")
+		}
+		if allowed != nil && (parent.SandboxVMID == nil || !allowed(*parent.SandboxVMID)) {
+			return jobDependencySpec{}, http.StatusNotFound, fmt.Errorf("depends_on job %s not found", parentID)
+		}
+		spec.Parents = append(spec.Parents, parent.ID)
+	}
+	return spec, 0, nil
+}
+
+// Schedule starts a job, or holds it in QUEUED when it depends on parent jobs
+// that have not all finished yet. Held jobs are re-evaluated each time one of
+// their parents reaches a terminal status.
+func (o *JobOrchestrator) Schedule(ctx context.Context, job models.Job) {
+	if o == nil {
+		return
+	}
+	if len(job.DependsOn) == 0 || o.store == nil {
+		o.Start(job.ID)
+		return
+	}
+	o.dispatchWaiting(ctx, []models.Job{job})
+}
+
+// ReleaseWaitingJobs re-evaluates every job held on dependencies. The daemon
+// calls it at startup so a child whose last parent finished just before a
+// restart is not left waiting forever.
+func (o *JobOrchestrator) ReleaseWaitingJobs(ctx context.Context) {
+	if o == nil || o.store == nil {
+		return
+	}
+	jobs, err := o.store.ListWaitingJobs(ctx)
+	if err != nil {
+		o.logf("list waiting jobs: %v", err)
+		return
+	}
+	o.dispatchWaiting(ctx, jobs)
+}
+
+// releaseDependents re-evaluates the jobs waiting on parentID after it reached
+// a terminal status.
+func (o *JobOrchestrator) releaseDependents(ctx context.Context, parentID string) {
+	if o == nil || o.store == nil {
+		return
+	}
+	jobs, err := o.store.ListJobsWaitingOn(ctx, parentID)
+	if err != nil {
+		o.logf("list jobs waiting on %s: %v", parentID, err)
+		return
+	}
+	o.dispatchWaiting(ctx, jobs)
+}
+
+// dispatchWaiting starts the jobs whose dependencies are met and fails those
+// whose condition can no longer be met. Failing a job releases its own
+// dependents in turn, so an unmet condition cascades down the chain.
+func (o *JobOrchestrator) dispatchWaiting(ctx context.Context, jobs []models.Job) {
+	type unmetJob struct {
+		job   models.Job
+		cause error
+	}
+	var (
+		ready []string
+		unmet []unmetJob
+	)
+	o.scheduleMu.Lock()
+	if o.scheduled == nil {
+		o.scheduled = make(map[string]struct{})
+	}
+	for _, job := range jobs {
+		if job.Status != models.JobQueued {
+			continue
+		}
+		if _, ok := o.scheduled[job.ID]; ok {
+			continue
+		}
+		met, err := o.jobDependenciesMet(ctx, job)
+		switch {
+		case err != nil:
+			o.scheduled[job.ID] = struct{}{}
+			unmet = append(unmet, unmetJob{job: job, cause: err})
+		case met:
+			o.scheduled[job.ID] = struct{}{}
+			ready = append(ready, job.ID)
+		}
+	}
+	o.scheduleMu.Unlock()
+
+	for _, jobID := range ready {
+		o.Start(jobID)
+	}
+	for _, item := range unmet {
+		_ = o.failJob(item.job, 0, item.cause)
+		o.clearScheduled(item.job.ID)
+	}
+}
+
+// jobDependenciesMet reports whether every parent has finished and, if so,
+// whether the outcome satisfies the job's condition. It returns
+// ErrJobDependencyUnmet (wrapped) when the job must fail instead of run.
+// Store errors other than a missing parent leave the job waiting.
+func (o *JobOrchestrator) jobDependenciesMet(ctx context.Context, job models.Job) (bool, error) {
+	var failedParent *models.Job
+	for _, parentID := range job.DependsOn {
+		parent, err := o.store.GetJob(ctx, parentID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, fmt.Errorf("%w: parent job %s not found", ErrJobDependencyUnmet, parentID)
+			}
+			o.logf("load parent job %s for %s: %v", parentID, job.ID, err)
+			return false, nil
+		}
+		switch parent.Status {
+		case models.JobCompleted:
+		case models.JobFailed, models.JobTimeout:
+			if failedParent == nil {
+				failedParent = &parent
+			}
+		default:
+			return false, nil
+		}
+	}
+	condition := job.DependsCondition
+	if condition == "" {
+		condition = models.JobDependsOnSuccess
+	}
+	switch condition {
+	case models.JobDependsOnSuccess:
+		if failedParent != nil {
+			return false, fmt.Errorf("%w: parent job %s finished %s (depends_condition success)", ErrJobDependencyUnmet, failedParent.ID, failedParent.Status)
+		}
+	case models.JobDependsOnFailure:
+		if failedParent == nil {
+			return false, fmt.Errorf("%w: every parent job completed (depends_condition failure)", ErrJobDependencyUnmet)
+		}
+	case models.JobDependsAlways:
+	default:
+		return false, fmt.Errorf("%w: unknown depends_condition %q", ErrJobDependencyUnmet, condition)
+	}
+	return true, nil
+}
+
+func (o *JobOrchestrator) clearScheduled(jobID string) {
+	o.scheduleMu.Lock()
+	delete(o.scheduled, jobID)
+	o.scheduleMu.Unlock()
+}
+
+func (o *JobOrchestrator) logf(format string, args ...any) {
+	if o.logger == nil {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if o.redactor != nil {
+		msg = o.redactor.Redact(msg)
+	}
+	o.logger.Printf("job orchestration: %s", msg)
+}
+
+// parentArtifactsForJob lists the artifacts of a job's parents for delivery
+// at bootstrap. Each entry carries a download URL on the artifact listener
+// that accepts the child's artifact token.
+func (api *BootstrapAPI) parentArtifactsForJob(ctx context.Context, job models.Job) ([]V1BootstrapParentArtifact, error) {
+	if !job.ParentArtifacts || len(job.DependsOn) == 0 || api.artifactEndpoint == "" {
+		return nil, nil
+	}
+	var out []V1BootstrapParentArtifact
+	for _, parentID := range job.DependsOn {
+		artifacts, err := api.store.ListArtifactsByJob(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, artifact := range artifacts {
+			out = append(out, V1BootstrapParentArtifact{
+				JobID:     parentID,
+				Name:      artifact.Name,
+				Path:      artifact.Path,
+				SizeBytes: artifact.SizeBytes,
+				Sha256:    artifact.Sha256,
+				URL:       artifactDownloadURL(api.artifactEndpoint, parentID, artifact.Path),
+			})
+		}
+	}
+	return out, nil
+}
+
+// artifactDownloadURL derives the parent-artifact download URL from the
+// configured upload endpoint (…/upload → …/download).
+func artifactDownloadURL(uploadEndpoint, jobID, path string) string {
+	base := strings.TrimRight(strings.TrimSpace(uploadEndpoint), "/")
+	base = strings.TrimSuffix(base, "/upload")
+	query := url.Values{}
+	query.Set("job_id", jobID)
+	query.Set("path", path)
+	return base + "/download?" + query.Encode()
+}
@@ -0,0 +1,266 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+// recordingJobRunner records started work without running it.
+type recordingJobRunner struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recordingJobRunner) Go(name string, _ func(ctx context.Context)) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, name)
+	return true
+}
+
+func (r *recordingJobRunner) LifecycleContext() context.Context { return context.Background() }
+
+func (r *recordingJobRunner) started() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func createDependencyTestJob(t *testing.T, store *db.Store, id string, status models.JobStatus, parents []string, condition models.JobDependencyCondition) models.Job {
+	t.Helper()
+	now := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	job := models.Job{
+		ID:               id,
+		RepoURL:          "https://example.com/repo.git",
+		Ref:              "main",
+		Profile:          "default",
+		Task:             "task",
+		Status:           status,
+		DependsOn:        parents,
+		DependsCondition: condition,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := store.CreateJob(context.Background(), job); err != nil {
+		t.Fatalf("create job %s: %v", id, err)
+	}
+	return job
+}
+
+func TestJobCreateDependencyValidation(t *testing.T) {
+	api, store := newJobGroupTestAPI(t)
+	createDependencyTestJob(t, store, "job_parent", models.JobRunning, nil, "")
+
+	cases := []struct {
+		name string
+		req  V1JobCreateRequest
+		code int
+		msg  string
+	}{
+		{"condition without parents", V1JobCreateRequest{DependsCondition: "always"}, http.StatusBadRequest, "depends_condition requires depends_on"},
+		{"artifacts without parents", V1JobCreateRequest{ParentArtifacts: true}, http.StatusBadRequest, "parent_artifacts requires depends_on"},
+		{"bad condition", V1JobCreateRequest{DependsOn: []string{"job_parent"}, DependsCondition: "sometimes"}, http.StatusBadRequest, "depends_condition must be"},
+		{"empty id", V1JobCreateRequest{DependsOn: []string{" "}}, http.StatusBadRequest, "empty job id"},
+		{"missing parent", V1JobCreateRequest{DependsOn: []string{"job_missing"}}, http.StatusNotFound, "depends_on job job_missing not found"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/jobs", nil)
+			_, code, err := api.resolveJobDependencies(req, tc.req)
+			if code != tc.code || err == nil || !strings.Contains(err.Error(), tc.msg) {
+				t.Fatalf("resolveJobDependencies() = %d, %v; want %d %q", code, err, tc.code, tc.msg)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", nil)
+	spec, code, err := api.resolveJobDependencies(req, V1JobCreateRequest{DependsOn: []string{"job_parent", "job_parent"}, ParentArtifacts: true})
+	if err != nil || code != 0 {
+		t.Fatalf("resolveJobDependencies() = %d, %v", code, err)
+	}
+	if len(spec.Parents) != 1 || spec.Condition != models.JobDependsOnSuccess || !spec.ParentArtifacts {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+}
+
+func TestJobCreateWithDependsOnStaysQueued(t *testing.T) {
+	store := newTestStore(t)
+	profiles := map[string]models.Profile{"default": {Name: "default", TemplateVM: 9000}}
+	runner := &recordingJobRunner{}
+	orchestrator := (&JobOrchestrator{store: store}).WithBackgroundRunner(runner)
+	api := NewControlAPI(store, profiles, nil, nil, orchestrator, "", nil)
+	createDependencyTestJob(t, store, "job_parent", models.JobRunning, nil, "")
+
+	payload, err := json.Marshal(V1JobCreateRequest{
+		RepoURL:   "https://example.com/repo.git",
+		Profile:   "default",
+		Task:      "summarize",
+		DependsOn: []string{"job_parent"},
+	})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	api.handleJobCreate(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs", bytes.NewReader(payload)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp V1JobResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Status != string(models.JobQueued) || len(resp.DependsOn) != 1 || resp.DependsCondition != string(models.JobDependsOnSuccess) {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if started := runner.started(); len(started) != 0 {
+		t.Fatalf("expected child to wait, started %v", started)
+	}
+
+	if err := store.UpdateJobStatus(context.Background(), "job_parent", models.JobCompleted); err != nil {
+		t.Fatalf("update parent: %v", err)
+	}
+	orchestrator.releaseDependents(context.Background(), "job_parent")
+	orchestrator.releaseDependents(context.Background(), "job_parent")
+	if started := runner.started(); len(started) != 1 || started[0] != "job:"+resp.ID {
+		t.Fatalf("expected child started once, got %v", started)
+	}
+}
+
+func TestJobDependenciesConditions(t *testing.T) {
+	store := newTestStore(t)
+	o := &JobOrchestrator{store: store}
+	createDependencyTestJob(t, store, "job_ok", models.JobCompleted, nil, "")
+	createDependencyTestJob(t, store, "job_bad", models.JobTimeout, nil, "")
+	createDependencyTestJob(t, store, "job_busy", models.JobRunning, nil, "")
+
+	cases := []struct {
+		parents   []string
+		condition models.JobDependencyCondition
+		met       bool
+		unmet     bool
+	}{
+		{[]string{"job_ok"}, models.JobDependsOnSuccess, true, false},
+		{[]string{"job_ok", "job_bad"}, models.JobDependsOnSuccess, false, true},
+		{[]string{"job_ok", "job_bad"}, models.JobDependsOnFailure, true, false},
+		{[]string{"job_ok"}, models.JobDependsOnFailure, false, true},
+		{[]string{"job_ok", "job_bad"}, models.JobDependsAlways, true, false},
+		{[]string{"job_bad", "job_busy"}, models.JobDependsAlways, false, false},
+		{[]string{"job_gone"}, models.JobDependsAlways, false, true},
+	}
+	for _, tc := range cases {
+		job := models.Job{ID: "job_child", DependsOn: tc.parents, DependsCondition: tc.condition}
+		met, err := o.jobDependenciesMet(context.Background(), job)
+		if met != tc.met || errors.Is(err, ErrJobDependencyUnmet) != tc.unmet {
+			t.Errorf("jobDependenciesMet(%v, %s) = %t, %v", tc.parents, tc.condition, met, err)
+		}
+	}
+}
+
+func TestJobDependencyFailureCascades(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	runner := &recordingJobRunner{}
+	o := NewJobOrchestrator(store, nil, nil, nil, nil, proxmox.SnippetStore{}, "", "", log.New(io.Discard, "", 0), nil, nil).WithBackgroundRunner(runner)
+	createDependencyTestJob(t, store, "job_a", models.JobFailed, nil, "")
+	createDependencyTestJob(t, store, "job_b", models.JobQueued, []string{"job_a"}, models.JobDependsOnSuccess)
+	createDependencyTestJob(t, store, "job_c", models.JobQueued, []string{"job_b"}, models.JobDependsOnSuccess)
+	createDependencyTestJob(t, store, "job_d", models.JobQueued, []string{"job_b"}, models.JobDependsOnFailure)
+
+	o.releaseDependents(ctx, "job_a")
+
+	for _, id := range []string{"job_b", "job_c"} {
+		job, err := store.GetJob(ctx, id)
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		if job.Status != models.JobFailed {
+			t.Fatalf("expected %s FAILED, got %s", id, job.Status)
+		}
+	}
+	if started := runner.started(); len(started) != 1 || started[0] != "job:job_d" {
+		t.Fatalf("expected only job_d started, got %v", started)
+	}
+}
+
+func TestArtifactDownloadParentArtifact(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	now := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	createDependencyTestJob(t, store, "job_parent", models.JobCompleted, nil, "")
+	createDependencyTestJob(t, store, "job_other", models.JobCompleted, nil, "")
+	child := createDependencyTestJob(t, store, "job_child", models.JobRunning, nil, "")
+	child.ID = "job_child_artifacts"
+	child.DependsOn = []string{"job_parent", "job_other"}
+	child.ParentArtifacts = true
+	if err := store.CreateJob(ctx, child); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+
+	root := t.TempDir()
+	body := []byte("report")
+	if err := os.MkdirAll(filepath.Join(root, "job_parent"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "job_parent", "report.txt"), body, 0o644); err != nil {
+		t.Fatalf("write artifact: %v", err)
+	}
+	if _, err := store.CreateArtifact(ctx, db.Artifact{JobID: "job_parent", Name: "report.txt", Path: "report.txt", SizeBytes: int64(len(body)), Sha256: "abc", CreatedAt: now}); err != nil {
+		t.Fatalf("create artifact: %v", err)
+	}
+
+	tokens := map[string]string{"child-token": "job_child_artifacts", "plain-token": "job_child"}
+	for token, jobID := range tokens {
+		hash, err := db.HashArtifactToken(token)
+		if err != nil {
+			t.Fatalf("hash token: %v", err)
+		}
+		if err := store.CreateArtifactToken(ctx, hash, jobID, 2001, now.Add(time.Hour)); err != nil {
+			t.Fatalf("create artifact token: %v", err)
+		}
+	}
+
+	api := NewArtifactAPI(store, root, 1024, mustParseCIDR(t, "10.77.0.0/16"), nil)
+	api.now = func() time.Time { return now }
+	download := func(token, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = "10.77.0.55:1234"
+		rec := httptest.NewRecorder()
+		api.handleDownload(rec, req)
+		return rec
+	}
+
+	url := artifactDownloadURL("http://10.77.0.1:8846/upload", "job_parent", "report.txt")
+	if url != "http://10.77.0.1:8846/download?job_id=job_parent&path=report.txt" {
+		t.Fatalf("unexpected download url %q", url)
+	}
+	rec := download("child-token", url)
+	if rec.Code != http.StatusOK || rec.Body.String() != "report" {
+		t.Fatalf("expected artifact body, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := download("plain-token", url); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without parent_artifacts, got %d", rec.Code)
+	}
+	if rec := download("child-token", "/download?job_id=job_unrelated&path=report.txt"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-parent job, got %d", rec.Code)
+	}
+	if rec := download("child-token", "/download?job_id=job_other&path=report.txt"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing artifact, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,287 @@
+package daemon
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+const (
+	// maxJobDiffPatchBytes caps the patch text embedded in the JSON response.
+	// It stays well under the CLI's JSON response cap after escaping. Stats
+	// always cover the whole patch; format=patch streams all of it.
+	maxJobDiffPatchBytes = 1024 * 1024
+	// maxChangeSummaryBytes caps the change summary read for its base commit.
+	maxChangeSummaryBytes = 1024 * 1024
+)
+
+// jobChangeSummary is the subset of the runner's changes.json the daemon reads.
+type jobChangeSummary struct {
+	Base string `json:"base"`
+	Head string `json:"head"`
+}
+
+// handleJobDiff serves GET /v1/jobs/{id}/diff: the job's patch artifact with
+// per-file stats. With format=patch the raw unified diff is returned instead.
+func (api *ControlAPI) handleJobDiff(w http.ResponseWriter, r *http.Request, jobID string) {
+	job, err := api.store.GetJob(r.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load job")
+		return
+	}
+	format := strings.TrimSpace(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "patch" {
+		writeError(w, http.StatusBadRequest, "format must be json or patch")
+		return
+	}
+	root := strings.TrimSpace(api.artifactRoot)
+	if root == "" {
+		writeError(w, http.StatusInternalServerError, "artifact root is not configured")
+		return
+	}
+	artifact, err := api.store.LatestArtifactByKind(r.Context(), job.ID, models.ArtifactKindPatch)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "job has no patch artifact")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load patch artifact")
+		return
+	}
+	file, ok := openJobArtifact(w, root, job.ID, artifact)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	if format == "patch" {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+job.ID+`.diff"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, file)
+		return
+	}
+
+	var patch strings.Builder
+	limited := &limitedBuilder{b: &patch, limit: maxJobDiffPatchBytes}
+	files, err := parseUnifiedDiff(io.TeeReader(file, limited))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read patch artifact")
+		return
+	}
+	resp := V1JobDiffResponse{
+		JobID:     job.ID,
+		Ref:       job.Ref,
+		Files:     files,
+		Patch:     patch.String(),
+		Truncated: limited.truncated,
+		CreatedAt: artifact.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for _, f := range files {
+		resp.Additions += f.Additions
+		resp.Deletions += f.Deletions
+	}
+	resp.FilesChanged = len(files)
+	if summary, ok := api.jobChangeSummary(r, root, job.ID); ok {
+		resp.Base = summary.Base
+		resp.Head = summary.Head
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// jobChangeSummary reads the base and head commits from the job's change
+// summary artifact. A missing or unreadable summary is not an error.
+func (api *ControlAPI) jobChangeSummary(r *http.Request, root, jobID string) (jobChangeSummary, bool) {
+	artifact, err := api.store.LatestArtifactByKind(r.Context(), jobID, models.ArtifactKindChangeSummary)
+	if err != nil {
+		return jobChangeSummary{}, false
+	}
+	path, err := safeJoin(filepath.Join(root, jobID), artifact.Path)
+	if err != nil {
+		return jobChangeSummary{}, false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return jobChangeSummary{}, false
+	}
+	defer file.Close()
+	var summary jobChangeSummary
+	if err := json.NewDecoder(io.LimitReader(file, maxChangeSummaryBytes)).Decode(&summary); err != nil {
+		return jobChangeSummary{}, false
+	}
+	return summary, true
+}
+
+// openJobArtifact opens an artifact file under the job's directory, writing
+// the error response itself when it cannot.
+func openJobArtifact(w http.ResponseWriter, root, jobID string, artifact db.Artifact) (*os.File, bool) {
+	path, err := safeJoin(filepath.Join(root, jobID), artifact.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "artifact path is invalid")
+		return nil, false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "artifact file missing")
+			return nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to open artifact")
+		return nil, false
+	}
+	return file, true
+}
+
+// limitedBuilder keeps the first limit bytes written to it and records
+// whether anything was dropped. It never fails, so a TeeReader keeps reading.
+type limitedBuilder struct {
+	b         *strings.Builder
+	limit     int
+	truncated bool
+}
+
+func (l *limitedBuilder) Write(p []byte) (int, error) {
+	if room := l.limit - l.b.Len(); room < len(p) {
+		if room > 0 {
+			l.b.Write(p[:room])
+		}
+		l.truncated = true
+		return len(p), nil
+	}
+	l.b.Write(p)
+	return len(p), nil
+}
+
+// parseUnifiedDiff computes per-file stats from a git unified diff.
+func parseUnifiedDiff(r io.Reader) ([]V1DiffFileStat, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var files []V1DiffFileStat
+	var cur *V1DiffFileStat
+	inHunk := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "diff --git ") {
+			files = append(files, V1DiffFileStat{Status: "modified"})
+			cur = &files[len(files)-1]
+			cur.OldPath, cur.Path = splitDiffGitPaths(strings.TrimPrefix(line, "diff --git "))
+			inHunk = false
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if inHunk {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				cur.Additions++
+			case strings.HasPrefix(line, "-"):
+				cur.Deletions++
+			case strings.HasPrefix(line, " "), strings.HasPrefix(line, `\`), strings.HasPrefix(line, "@@"):
+			default:
+				inHunk = false
+			}
+			if inHunk {
+				continue
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case strings.HasPrefix(line, "new file mode"):
+			cur.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			cur.Status = "deleted"
+		case strings.HasPrefix(line, "rename from "):
+			cur.Status = "renamed"
+			cur.OldPath = unquoteDiffPath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			cur.Path = unquoteDiffPath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "--- "):
+			if p := strings.TrimPrefix(unquoteDiffPath(strings.TrimPrefix(line, "--- ")), "a/"); p != "/dev/null" {
+				cur.OldPath = p
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if p := strings.TrimPrefix(unquoteDiffPath(strings.TrimPrefix(line, "+++ ")), "b/"); p != "/dev/null" {
+				cur.Path = p
+			}
+		case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+			cur.Binary = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i := range files {
+		switch {
+		case files[i].Status == "deleted":
+			files[i].Path = files[i].OldPath
+			files[i].OldPath = ""
+		case files[i].Status != "renamed":
+			files[i].OldPath = ""
+		}
+	}
+	return files, nil
+}
+
+// splitDiffGitPaths splits the "a/<old> b/<new>" operands of a diff --git
+// line. Unquoted paths may contain spaces, so the split assumes both paths
+// are equal when that is possible (true for everything except renames, whose
+// paths come from the rename lines).
+func splitDiffGitPaths(operands string) (string, string) {
+	if strings.HasPrefix(operands, `"`) {
+		if end := closingQuote(operands); end > 0 {
+			old := unquoteDiffPath(operands[:end+1])
+			return strings.TrimPrefix(old, "a/"), strings.TrimPrefix(unquoteDiffPath(strings.TrimSpace(operands[end+1:])), "b/")
+		}
+	}
+	if n := len(operands); n%2 == 1 {
+		half := (n - 1) / 2
+		old, rest := operands[:half], operands[half+1:]
+		if strings.HasPrefix(old, "a/") && strings.HasPrefix(rest, "b/") && old[2:] == rest[2:] {
+			return old[2:], rest[2:]
+		}
+	}
+	if idx := strings.Index(operands, " b/"); idx >= 0 {
+		return strings.TrimPrefix(operands[:idx], "a/"), operands[idx+3:]
+	}
+	return operands, operands
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteDiffPath undoes git's C-style quoting of unusual paths.
+func unquoteDiffPath(p string) string {
+	p = strings.TrimSuffix(p, "\t")
+	if len(p) >= 2 && strings.HasPrefix(p, `"`) && strings.HasSuffix(p, `"`) {
+		if unquoted, err := strconv.Unquote(p); err == nil {
+			return unquoted
+		}
+	}
+	return p
+}
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+const testJobPatch = "diff --git a/bin.dat b/bin.dat\n" +
+	"new file mode 100644\n" +
+	"index 0000000000000000000000000000000000000000..bdc955b7b2e610ad5a72302b139a2e6cb325519a\n" +
+	"GIT binary patch\n" +
+	"literal 2\n" +
+	"JcmZQz1ONa700IC2\n" +
+	"\n" +
+	"literal 0\n" +
+	"HcmV?d00001\n" +
+	"\n" +
+	"diff --git a/f.txt b/f.txt\n" +
+	"index 422c2b7..6372083 100644\n" +
+	"--- a/f.txt\n" +
+	"+++ b/f.txt\n" +
+	"@@ -1,3 +1,4 @@\n" +
+	" a\n" +
+	"---b\n" +
+	"+c\n" +
+	"+d\n" +
+	" e\n" +
+	"diff --git a/gone.txt b/gone.txt\n" +
+	"deleted file mode 100644\n" +
+	"index 587be6b..0000000\n" +
+	"--- a/gone.txt\n" +
+	"+++ /dev/null\n" +
+	"@@ -1 +0,0 @@\n" +
+	"-x\n" +
+	"\\ No newline at end of file\n" +
+	"diff --git a/sp ace.txt b/sp ace.txt\n" +
+	"new file mode 100644\n" +
+	"index 0000000..3e75765\n" +
+	"--- /dev/null\n" +
+	"+++ b/sp ace.txt\t\n" +
+	"@@ -0,0 +1 @@\n" +
+	"+new\n" +
+	"diff --git a/old/name.go b/new/name.go\n" +
+	"similarity index 90%\n" +
+	"rename from old/name.go\n" +
+	"rename to new/name.go\n" +
+	"index 1111111..2222222 100644\n" +
+	"--- a/old/name.go\n" +
+	"+++ b/new/name.go\n" +
+	"@@ -1,2 +1,2 @@\n" +
+	"-package old\n" +
+	"+package new\n" +
+	" \n"
+
+func TestParseUnifiedDiff(t *testing.T) {
+	files, err := parseUnifiedDiff(strings.NewReader(testJobPatch))
+	if err != nil {
+		t.Fatalf("parseUnifiedDiff() error = %v", err)
+	}
+	want := []V1DiffFileStat{
+		{Path: "bin.dat", Status: "added", Binary: true},
+		{Path: "f.txt", Status: "modified", Additions: 2, Deletions: 1},
+		{Path: "gone.txt", Status: "deleted", Deletions: 1},
+		{Path: "sp ace.txt", Status: "added", Additions: 1},
+		{Path: "new/name.go", OldPath: "old/name.go", Status: "renamed", Additions: 1, Deletions: 1},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("parseUnifiedDiff() =\n%+v\nwant\n%+v", files, want)
+	}
+
+	files, err = parseUnifiedDiff(strings.NewReader("diff --git \"a/tab\\there\" \"b/tab\\there\"\nBinary files a/x and b/x differ\n"))
+	if err != nil || len(files) != 1 || files[0].Path != "tab\there" || !files[0].Binary {
+		t.Fatalf("quoted binary diff = %+v, %v", files, err)
+	}
+}
+
+func TestJobDiffEndpoint(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	root := t.TempDir()
+	api := NewControlAPI(store, map[string]models.Profile{}, nil, nil, nil, root, log.New(io.Discard, "", 0))
+	mux := http.NewServeMux()
+	api.Register(mux)
+
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	if err := store.CreateJob(ctx, models.Job{ID: "job_diff", RepoURL: "https://example.com/repo.git", Ref: "main", Profile: "default", Task: "t", Status: models.JobCompleted, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get("/v1/jobs/job_diff/diff"); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "no patch artifact") {
+		t.Fatalf("expected 404 without patch, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	writeArtifact := func(name string, kind models.ArtifactKind, body string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(root, "job_diff"), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "job_diff", name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if _, err := store.CreateArtifact(ctx, db.Artifact{JobID: "job_diff", Name: name, Path: name, Kind: kind, SizeBytes: int64(len(body)), Sha256: "abc", CreatedAt: now}); err != nil {
+			t.Fatalf("create artifact %s: %v", name, err)
+		}
+	}
+	writeArtifact("patch.diff", models.ArtifactKindPatch, testJobPatch)
+	writeArtifact("changes.json", models.ArtifactKindChangeSummary, `{"base":"aaa111","head":"bbb222","files":[]}`)
+
+	rec := get("/v1/jobs/job_diff/diff")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp V1JobDiffResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.FilesChanged != 5 || resp.Additions != 4 || resp.Deletions != 3 || resp.Base != "aaa111" || resp.Head != "bbb222" || resp.Ref != "main" {
+		t.Fatalf("unexpected diff response %+v", resp)
+	}
+	if resp.Patch != testJobPatch || resp.Truncated {
+		t.Fatalf("expected full patch in response, truncated=%t", resp.Truncated)
+	}
+
+	rec = get("/v1/jobs/job_diff/diff?format=patch")
+	if rec.Code != http.StatusOK || rec.Body.String() != testJobPatch || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/x-diff") {
+		t.Fatalf("unexpected raw patch response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := get("/v1/jobs/job_diff/diff?format=html"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad format, got %d", rec.Code)
+	}
+	if rec := get("/v1/jobs/job_missing/diff"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing job, got %d", rec.Code)
+	}
+}
+
+func TestLimitedBuilderTruncates(t *testing.T) {
+	var b strings.Builder
+	l := &limitedBuilder{b: &b, limit: 5}
+	if n, err := l.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("Write() = %d, %v", n, err)
+	}
+	if n, err := l.Write([]byte("defg")); n != 4 || err != nil {
+		t.Fatalf("Write() = %d, %v", n, err)
+	}
+	if b.String() != "abcde" || !l.truncated {
+		t.Fatalf("got %q truncated=%t", b.String(), l.truncated)
+	}
+}
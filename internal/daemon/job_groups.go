@@ -0,0 +1,609 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+const (
+	maxJobGroupJobs = 64 // Upper bound on the children one matrix may expand to
+
+	jobMatrixAxisRef     = "ref"
+	jobMatrixAxisProfile = "profile"
+	jobMatrixAxisTask    = "task"
+	jobMatrixAxisEnv     = "env"
+)
+
+// jobMatrixCell is one expanded combination of matrix axis values.
+type jobMatrixCell struct {
+	ref     string
+	profile string
+	task    string
+	env     map[string]string
+	labels  map[string]string
+}
+
+func (api *ControlAPI) handleJobGroups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		// Group children always provision fresh sandboxes (the base spec may
+		// not name a workspace or session), so there is no target to scope.
+		if !api.authorize(w, r, permJobCreate, nil, false) {
+			return
+		}
+		api.handleJobGroupCreate(w, r)
+	default:
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+	}
+}
+
+func (api *ControlAPI) handleJobGroupByID(w http.ResponseWriter, r *http.Request) {
+	parts := pathTail(r, "/v1/job-groups/")
+	if len(parts) == 0 {
+		writeError(w, http.StatusNotFound, "job group not found")
+		return
+	}
+	groupID := parts[0]
+
+	// A group spans many sandboxes, so reads are governed by command
+	// permission and the children are filtered to the caller's scope.
+	perm := permJobRead
+	if len(parts) == 2 && parts[1] == "artifacts" {
+		perm = permJobArtifacts
+	}
+	if !api.authorize(w, r, perm, nil, false) {
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, []string{http.MethodGet})
+			return
+		}
+		api.handleJobGroupGet(w, r, groupID)
+		return
+	case len(parts) == 2 && parts[1] == "artifacts":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, []string{http.MethodGet})
+			return
+		}
+		api.handleJobGroupArtifacts(w, r, groupID)
+		return
+	}
+	writeError(w, http.StatusNotFound, "job group not found")
+}
+
+func (api *ControlAPI) handleJobGroupCreate(w http.ResponseWriter, r *http.Request) {
+	var req V1JobGroupCreateRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	base := &req.Base
+	base.RepoURL = strings.TrimSpace(base.RepoURL)
+	base.Ref = strings.TrimSpace(base.Ref)
+	base.Profile = strings.TrimSpace(base.Profile)
+	base.Task = strings.TrimSpace(base.Task)
+	base.Mode = strings.TrimSpace(base.Mode)
+
+	if base.RepoURL == "" {
+		writeError(w, http.StatusBadRequest, "base.repo_url is required")
+		return
+	}
+	if stringPtrTrim(base.WorkspaceID) != nil || base.WorkspaceCreate != nil || stringPtrTrim(base.SessionID) != nil || base.WorkspaceWaitSeconds != nil {
+		writeError(w, http.StatusBadRequest, "job groups cannot attach a workspace or session")
+		return
+	}
+	if base.TTLMinutes != nil && *base.TTLMinutes <= 0 {
+		writeError(w, http.StatusBadRequest, "ttl_minutes must be positive")
+		return
+	}
+	if base.Ref == "" {
+		base.Ref = defaultJobRef
+	}
+	if base.Mode == "" {
+		base.Mode = defaultJobMode
+	}
+	cells, axes, err := expandJobMatrix(*base, req.Matrix)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(axes) == 0 {
+		writeError(w, http.StatusBadRequest, "matrix requires at least one axis")
+		return
+	}
+	// Every child waits on the same parents.
+	deps, status, err := api.resolveJobDependencies(r, *base)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	// Children would race for one branch, so each pushes agent/<job_id>.
+	if base.Result != nil && strings.TrimSpace(base.Result.PushBranch) != "" {
+		writeError(w, http.StatusBadRequest, "job groups cannot set result.push_branch")
+		return
+	}
+	resultOpts, err := normalizeJobResultOptions(base.Result, base.RepoURL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	now := api.now().UTC()
+	jobs := make([]models.Job, 0, len(cells))
+	for _, cell := range cells {
+		if !api.profileExists(cell.profile) {
+			writeError(w, http.StatusBadRequest, "unknown profile: "+cell.profile)
+			return
+		}
+		ttlMinutes := derefInt(base.TTLMinutes)
+		keepalive := base.Keepalive != nil && *base.Keepalive
+		if profile, ok := api.profile(cell.profile); ok {
+			if err := validateProfileForProvisioning(profile); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			ttlMinutes, keepalive, err = applyProfileBehaviorDefaults(profile, base.TTLMinutes, base.Keepalive)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid profile behavior defaults")
+				return
+			}
+		}
+		// Each cell is a job of its own, so a cell whose profile breaks a
+		// rule denies the whole group.
+		if issues := api.admit(ctx, AdmissionRequest{Kind: admissionKindJob, Profile: cell.profile, TTLMinutes: ttlMinutes, Mode: base.Mode, RepoURL: base.RepoURL}); len(issues) > 0 {
+			writeAdmissionDenied(w, issues)
+			return
+		}
+		envJSON, err := encodeJobEnv(cell.env)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		matrixJSON, err := json.Marshal(cell.labels)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to encode matrix cell")
+			return
+		}
+		job := models.Job{
+			RepoURL:    base.RepoURL,
+			Ref:        cell.ref,
+			Profile:    cell.profile,
+			Task:       cell.task,
+			Mode:       base.Mode,
+			TTLMinutes: ttlMinutes,
+			Keepalive:  keepalive,
+			MatrixJSON: string(matrixJSON),
+			EnvJSON:    envJSON,
+			DependsOn:  deps.Parents,
+			Status:     models.JobQueued,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if len(deps.Parents) > 0 {
+			job.DependsCondition = deps.Condition
+			job.ParentArtifacts = deps.ParentArtifacts
+		}
+		jobs = append(jobs, job)
+	}
+	spec, err := json.Marshal(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode job group spec")
+		return
+	}
+
+	var group models.JobGroup
+	var createErr error
+	for i := 0; i < maxCreateJobIDIterations; i++ {
+		groupID, err := newJobGroupID()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to create job group id")
+			return
+		}
+		for j := range jobs {
+			jobID, err := newJobID()
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to create job id")
+				return
+			}
+			jobs[j].ID = jobID
+			jobs[j].GroupID = &groupID
+			jobs[j].PublishJSON, err = encodeJobPublish(resultOpts, jobID, jobs[j].Ref)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		group = models.JobGroup{
+			ID:        groupID,
+			Name:      req.Name,
+			SpecJSON:  string(spec),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		createErr = api.store.CreateJobGroup(ctx, group, jobs)
+		if createErr == nil || !isUniqueConstraint(createErr) {
+			break
+		}
+	}
+	if createErr != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create job group")
+		return
+	}
+	if api.jobOrchestrator == nil {
+		for i := range jobs {
+			_ = api.store.UpdateJobStatus(ctx, jobs[i].ID, models.JobFailed)
+		}
+		writeError(w, http.StatusInternalServerError, "job orchestration unavailable")
+		return
+	}
+	for _, job := range jobs {
+		api.jobOrchestrator.Schedule(ctx, job)
+	}
+	writeJSON(w, http.StatusCreated, jobGroupToV1(group, jobs))
+}
+
+func (api *ControlAPI) handleJobGroupGet(w http.ResponseWriter, r *http.Request, groupID string) {
+	group, jobs, ok := api.loadJobGroup(w, r, groupID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, jobGroupToV1(group, jobs))
+}
+
+func (api *ControlAPI) handleJobGroupArtifacts(w http.ResponseWriter, r *http.Request, groupID string) {
+	group, jobs, ok := api.loadJobGroup(w, r, groupID)
+	if !ok {
+		return
+	}
+	resp := V1JobGroupArtifactsResponse{
+		GroupID: group.ID,
+		Jobs:    make([]V1ArtifactsResponse, 0, len(jobs)),
+	}
+	for _, job := range jobs {
+		artifacts, err := api.store.ListArtifactsByJob(r.Context(), job.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to list artifacts")
+			return
+		}
+		entry := V1ArtifactsResponse{
+			JobID:     job.ID,
+			Artifacts: make([]V1Artifact, 0, len(artifacts)),
+		}
+		for _, artifact := range artifacts {
+			entry.Artifacts = append(entry.Artifacts, artifactToV1(artifact))
+		}
+		resp.Jobs = append(resp.Jobs, entry)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// loadJobGroup loads a group and its children, dropping children outside the
+// caller's sandbox scope. A scoped caller sees only children already placed in
+// an in-scope sandbox; queued children have no sandbox yet and stay hidden.
+func (api *ControlAPI) loadJobGroup(w http.ResponseWriter, r *http.Request, groupID string) (models.JobGroup, []models.Job, bool) {
+	groupID = strings.TrimSpace(groupID)
+	if groupID == "" {
+		writeError(w, http.StatusNotFound, "job group not found")
+		return models.JobGroup{}, nil, false
+	}
+	group, err := api.store.GetJobGroup(r.Context(), groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "job group not found")
+			return models.JobGroup{}, nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load job group")
+		return models.JobGroup{}, nil, false
+	}
+	jobs, err := api.store.ListJobsByGroup(r.Context(), group.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list group jobs")
+		return models.JobGroup{}, nil, false
+	}
+	if allowed := sandboxScopeFilter(r); allowed != nil {
+		filtered := jobs[:0]
+		for _, job := range jobs {
+			if job.SandboxVMID != nil && allowed(*job.SandboxVMID) {
+				filtered = append(filtered, job)
+			}
+		}
+		jobs = filtered
+	}
+	return group, jobs, true
+}
+
+// expandJobMatrix returns the cartesian product of the non-empty matrix axes
+// in ref × profile × task × env order, along with the names of the axes that
+// were set. Base values fill any axis the matrix leaves empty.
+func expandJobMatrix(base V1JobCreateRequest, matrix V1JobMatrix) ([]jobMatrixCell, []string, error) {
+	refs, err := normalizeMatrixValues(jobMatrixAxisRef, matrix.Ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	profiles, err := normalizeMatrixValues(jobMatrixAxisProfile, matrix.Profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	taskNames := make([]string, 0, len(matrix.Task))
+	for i := range matrix.Task {
+		variant := &matrix.Task[i]
+		variant.Task = strings.TrimSpace(variant.Task)
+		if variant.Task == "" {
+			return nil, nil, fmt.Errorf("matrix.task[%d].task is required", i)
+		}
+		variant.Name = matrixVariantName(jobMatrixAxisTask, variant.Name, i)
+		taskNames = append(taskNames, variant.Name)
+	}
+	if err := ensureUniqueMatrixValues(jobMatrixAxisTask, taskNames); err != nil {
+		return nil, nil, err
+	}
+	envNames := make([]string, 0, len(matrix.Env))
+	for i := range matrix.Env {
+		variant := &matrix.Env[i]
+		if len(variant.Env) == 0 {
+			return nil, nil, fmt.Errorf("matrix.env[%d].env is required", i)
+		}
+		variant.Name = matrixVariantName(jobMatrixAxisEnv, variant.Name, i)
+		envNames = append(envNames, variant.Name)
+	}
+	if err := ensureUniqueMatrixValues(jobMatrixAxisEnv, envNames); err != nil {
+		return nil, nil, err
+	}
+
+	var axes []string
+	if len(refs) > 0 {
+		axes = append(axes, jobMatrixAxisRef)
+	} else {
+		refs = []string{base.Ref}
+	}
+	if len(profiles) > 0 {
+		axes = append(axes, jobMatrixAxisProfile)
+	} else {
+		profiles = []string{base.Profile}
+	}
+	tasks := matrix.Task
+	if len(tasks) > 0 {
+		axes = append(axes, jobMatrixAxisTask)
+	} else {
+		tasks = []V1JobMatrixTaskVariant{{Task: base.Task}}
+	}
+	envs := matrix.Env
+	if len(envs) > 0 {
+		axes = append(axes, jobMatrixAxisEnv)
+	} else {
+		envs = []V1JobMatrixEnvVariant{{}}
+	}
+
+	total := len(refs) * len(profiles) * len(tasks) * len(envs)
+	if total > maxJobGroupJobs {
+		return nil, nil, fmt.Errorf("matrix expands to %d jobs (max %d)", total, maxJobGroupJobs)
+	}
+	cells := make([]jobMatrixCell, 0, total)
+	for _, ref := range refs {
+		for _, profile := range profiles {
+			for _, task := range tasks {
+				for _, env := range envs {
+					cell := jobMatrixCell{
+						ref:     ref,
+						profile: profile,
+						task:    task.Task,
+						env:     mergeJobEnv(base.Env, env.Env),
+						labels:  make(map[string]string, len(axes)),
+					}
+					if cell.profile == "" {
+						return nil, nil, errors.New("profile is required")
+					}
+					if cell.task == "" {
+						return nil, nil, errors.New("task is required")
+					}
+					for _, axis := range axes {
+						switch axis {
+						case jobMatrixAxisRef:
+							cell.labels[axis] = ref
+						case jobMatrixAxisProfile:
+							cell.labels[axis] = profile
+						case jobMatrixAxisTask:
+							cell.labels[axis] = task.Name
+						case jobMatrixAxisEnv:
+							cell.labels[axis] = env.Name
+						}
+					}
+					cells = append(cells, cell)
+				}
+			}
+		}
+	}
+	return cells, axes, nil
+}
+
+func normalizeMatrixValues(axis string, values []string) ([]string, error) {
+	out := make([]string, 0, len(values))
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("matrix.%s[%d] is empty", axis, i)
+		}
+		out = append(out, value)
+	}
+	if err := ensureUniqueMatrixValues(axis, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func ensureUniqueMatrixValues(axis string, values []string) error {
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			return fmt.Errorf("matrix.%s has duplicate value %q", axis, value)
+		}
+		seen[value] = struct{}{}
+	}
+	return nil
+}
+
+func matrixVariantName(axis, name string, index int) string {
+	name = strings.TrimSpace(name)
+	if name != "" {
+		return name
+	}
+	return axis + "-" + strconv.Itoa(index+1)
+}
+
+func mergeJobEnv(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+	return out
+}
+
+// encodeJobEnv validates env override keys and returns the JSON stored on the
+// job. An empty map encodes to "".
+func encodeJobEnv(env map[string]string) (string, error) {
+	if len(env) == 0 {
+		return "", nil
+	}
+	clean := make(map[string]string, len(env))
+	for key, value := range env {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return "", errors.New("env key is required")
+		}
+		if strings.ContainsAny(key, "= \t\n") {
+			return "", fmt.Errorf("invalid env key %q", key)
+		}
+		clean[key] = value
+	}
+	data, err := json.Marshal(clean)
+	if err != nil {
+		return "", fmt.Errorf("encode env: %w", err)
+	}
+	return string(data), nil
+}
+
+// aggregateJobGroupStatus folds child statuses into one group status.
+func aggregateJobGroupStatus(jobs []models.Job) models.JobStatus {
+	var queued, running, completed, failed, timedOut int
+	for _, job := range jobs {
+		switch job.Status {
+		case models.JobQueued:
+			queued++
+		case models.JobRunning:
+			running++
+		case models.JobCompleted:
+			completed++
+		case models.JobFailed:
+			failed++
+		case models.JobTimeout:
+			timedOut++
+		}
+	}
+	switch {
+	case len(jobs) == 0 || queued == len(jobs):
+		return models.JobQueued
+	case queued > 0 || running > 0:
+		return models.JobRunning
+	case completed == len(jobs):
+		return models.JobCompleted
+	case failed > 0:
+		return models.JobFailed
+	default:
+		return models.JobTimeout
+	}
+}
+
+func jobGroupToV1(group models.JobGroup, jobs []models.Job) V1JobGroupResponse {
+	resp := V1JobGroupResponse{
+		ID:        group.ID,
+		Name:      group.Name,
+		Status:    string(aggregateJobGroupStatus(jobs)),
+		Axes:      jobGroupAxes(group),
+		Counts:    make(map[string]int),
+		Jobs:      make([]V1JobResponse, 0, len(jobs)),
+		CreatedAt: group.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt: group.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for _, job := range jobs {
+		resp.Counts[string(job.Status)]++
+		resp.Jobs = append(resp.Jobs, jobToV1(job))
+	}
+	return resp
+}
+
+// jobGroupAxes recovers the axis order from the stored spec.
+func jobGroupAxes(group models.JobGroup) []string {
+	var spec V1JobGroupCreateRequest
+	if err := json.Unmarshal([]byte(group.SpecJSON), &spec); err != nil {
+		return []string{}
+	}
+	axes := make([]string, 0, 4)
+	if len(spec.Matrix.Ref) > 0 {
+		axes = append(axes, jobMatrixAxisRef)
+	}
+	if len(spec.Matrix.Profile) > 0 {
+		axes = append(axes, jobMatrixAxisProfile)
+	}
+	if len(spec.Matrix.Task) > 0 {
+		axes = append(axes, jobMatrixAxisTask)
+	}
+	if len(spec.Matrix.Env) > 0 {
+		axes = append(axes, jobMatrixAxisEnv)
+	}
+	return axes
+}
+
+// decodeJobMatrix returns a job's matrix cell, or nil for ungrouped jobs.
+func decodeJobMatrix(raw string) map[string]string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	var cell map[string]string
+	if err := json.Unmarshal([]byte(raw), &cell); err != nil || len(cell) == 0 {
+		return nil
+	}
+	return cell
+}
+
+// decodeJobEnv returns a job's env overrides, or nil when it has none.
+func decodeJobEnv(raw string) map[string]string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	var env map[string]string
+	if err := json.Unmarshal([]byte(raw), &env); err != nil || len(env) == 0 {
+		return nil
+	}
+	return env
+}
+
+func newJobGroupID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "jobgrp_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,251 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+func newJobGroupTestAPI(t *testing.T) (*ControlAPI, *db.Store) {
+	t.Helper()
+	store := newTestStore(t)
+	profiles := map[string]models.Profile{
+		"default": {Name: "default", TemplateVM: 9000},
+		"large":   {Name: "large", TemplateVM: 9001},
+	}
+	api := NewControlAPI(store, profiles, nil, nil, &JobOrchestrator{}, "", nil)
+	return api, store
+}
+
+func postJobGroup(t *testing.T, api *ControlAPI, req V1JobGroupCreateRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	api.handleJobGroupCreate(rec, httptest.NewRequest(http.MethodPost, "/v1/job-groups", bytes.NewReader(payload)))
+	return rec
+}
+
+func TestJobGroupCreateExpandsMatrix(t *testing.T) {
+	ctx := context.Background()
+	api, store := newJobGroupTestAPI(t)
+
+	rec := postJobGroup(t, api, V1JobGroupCreateRequest{
+		Name: "compare",
+		Base: V1JobCreateRequest{
+			RepoURL: "https://example.com/repo.git",
+			Profile: "default",
+			Task:    "run tests",
+			Env:     map[string]string{"MODEL": "base", "LOG": "1"},
+		},
+		Matrix: V1JobMatrix{
+			Ref: []string{"main", "dev"},
+			Env: []V1JobMatrixEnvVariant{
+				{Name: "fast", Env: map[string]string{"MODEL": "fast"}},
+				{Env: map[string]string{"MODEL": "slow"}},
+			},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp V1JobGroupResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp.ID, "jobgrp_") {
+		t.Fatalf("unexpected group id %q", resp.ID)
+	}
+	if got := strings.Join(resp.Axes, ","); got != "ref,env" {
+		t.Fatalf("expected axes ref,env, got %q", got)
+	}
+	if len(resp.Jobs) != 4 {
+		t.Fatalf("expected 4 jobs, got %d", len(resp.Jobs))
+	}
+	wantCells := []map[string]string{
+		{"ref": "main", "env": "fast"},
+		{"ref": "main", "env": "env-2"},
+		{"ref": "dev", "env": "fast"},
+		{"ref": "dev", "env": "env-2"},
+	}
+	for i, job := range resp.Jobs {
+		if job.GroupID == nil || *job.GroupID != resp.ID {
+			t.Fatalf("job %d group_id = %#v, want %s", i, job.GroupID, resp.ID)
+		}
+		if job.Matrix["ref"] != wantCells[i]["ref"] || job.Matrix["env"] != wantCells[i]["env"] {
+			t.Fatalf("job %d matrix = %v, want %v", i, job.Matrix, wantCells[i])
+		}
+		if job.Ref != wantCells[i]["ref"] {
+			t.Fatalf("job %d ref = %q, want %q", i, job.Ref, wantCells[i]["ref"])
+		}
+	}
+
+	stored, err := store.GetJob(ctx, resp.Jobs[1].ID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	env := decodeJobEnv(stored.EnvJSON)
+	if env["MODEL"] != "slow" || env["LOG"] != "1" {
+		t.Fatalf("expected env override merged over base, got %v", env)
+	}
+}
+
+func TestJobGroupCreateValidation(t *testing.T) {
+	api, _ := newJobGroupTestAPI(t)
+	workspace := "ws-1"
+	cases := []struct {
+		name string
+		req  V1JobGroupCreateRequest
+		want string
+	}{
+		{
+			name: "no axes",
+			req:  V1JobGroupCreateRequest{Base: V1JobCreateRequest{RepoURL: "r", Profile: "default", Task: "t"}},
+			want: "matrix requires at least one axis",
+		},
+		{
+			name: "workspace",
+			req: V1JobGroupCreateRequest{
+				Base:   V1JobCreateRequest{RepoURL: "r", Profile: "default", Task: "t", WorkspaceID: &workspace},
+				Matrix: V1JobMatrix{Ref: []string{"a"}},
+			},
+			want: "job groups cannot attach a workspace or session",
+		},
+		{
+			name: "duplicate axis value",
+			req: V1JobGroupCreateRequest{
+				Base:   V1JobCreateRequest{RepoURL: "r", Profile: "default", Task: "t"},
+				Matrix: V1JobMatrix{Ref: []string{"a", "a"}},
+			},
+			want: "matrix.ref has duplicate value",
+		},
+		{
+			name: "unknown profile",
+			req: V1JobGroupCreateRequest{
+				Base:   V1JobCreateRequest{RepoURL: "r", Task: "t"},
+				Matrix: V1JobMatrix{Profile: []string{"default", "missing"}},
+			},
+			want: "unknown profile: missing",
+		},
+		{
+			name: "task required",
+			req: V1JobGroupCreateRequest{
+				Base:   V1JobCreateRequest{RepoURL: "r", Profile: "default"},
+				Matrix: V1JobMatrix{Ref: []string{"a"}},
+			},
+			want: "task is required",
+		},
+		{
+			name: "too many jobs",
+			req: V1JobGroupCreateRequest{
+				Base: V1JobCreateRequest{RepoURL: "r", Profile: "default", Task: "t"},
+				Matrix: V1JobMatrix{
+					Ref:  strings.Split("a b c d e f g h i", " "),
+					Task: []V1JobMatrixTaskVariant{{Task: "1"}, {Task: "2"}, {Task: "3"}, {Task: "4"}, {Task: "5"}, {Task: "6"}, {Task: "7"}, {Task: "8"}},
+				},
+			},
+			want: "matrix expands to 72 jobs (max 64)",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := postJobGroup(t, api, tc.req)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.want) {
+				t.Fatalf("expected error %q, got %s", tc.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestJobGroupGetAggregatesStatus(t *testing.T) {
+	ctx := context.Background()
+	api, store := newJobGroupTestAPI(t)
+	group := models.JobGroup{ID: "jobgrp-1", SpecJSON: `{"matrix":{"profile":["default","large"]}}`}
+	jobs := []models.Job{
+		{ID: "job-a", RepoURL: "r", Ref: "main", Profile: "default", Status: models.JobCompleted, MatrixJSON: `{"profile":"default"}`},
+		{ID: "job-b", RepoURL: "r", Ref: "main", Profile: "large", Status: models.JobFailed, MatrixJSON: `{"profile":"large"}`},
+	}
+	if err := store.CreateJobGroup(ctx, group, jobs); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if _, err := store.CreateArtifact(ctx, db.Artifact{JobID: "job-a", Name: "out.txt", Path: "out.txt", SizeBytes: 1, Sha256: "abc"}); err != nil {
+		t.Fatalf("create artifact: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	api.handleJobGroupByID(rec, httptest.NewRequest(http.MethodGet, "/v1/job-groups/jobgrp-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp V1JobGroupResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Status != string(models.JobFailed) {
+		t.Fatalf("expected FAILED, got %s", resp.Status)
+	}
+	if resp.Counts["COMPLETED"] != 1 || resp.Counts["FAILED"] != 1 {
+		t.Fatalf("unexpected counts %v", resp.Counts)
+	}
+	if len(resp.Axes) != 1 || resp.Axes[0] != "profile" {
+		t.Fatalf("unexpected axes %v", resp.Axes)
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleJobGroupByID(rec, httptest.NewRequest(http.MethodGet, "/v1/job-groups/jobgrp-1/artifacts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var artifacts V1JobGroupArtifactsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&artifacts); err != nil {
+		t.Fatalf("decode artifacts: %v", err)
+	}
+	if len(artifacts.Jobs) != 2 || len(artifacts.Jobs[0].Artifacts) != 1 || len(artifacts.Jobs[1].Artifacts) != 0 {
+		t.Fatalf("unexpected artifacts %+v", artifacts)
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleJobGroupByID(rec, httptest.NewRequest(http.MethodGet, "/v1/job-groups/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAggregateJobGroupStatus(t *testing.T) {
+	status := func(values ...models.JobStatus) []models.Job {
+		jobs := make([]models.Job, 0, len(values))
+		for _, value := range values {
+			jobs = append(jobs, models.Job{Status: value})
+		}
+		return jobs
+	}
+	cases := []struct {
+		jobs []models.Job
+		want models.JobStatus
+	}{
+		{nil, models.JobQueued},
+		{status(models.JobQueued, models.JobQueued), models.JobQueued},
+		{status(models.JobQueued, models.JobCompleted), models.JobRunning},
+		{status(models.JobRunning, models.JobFailed), models.JobRunning},
+		{status(models.JobCompleted, models.JobCompleted), models.JobCompleted},
+		{status(models.JobCompleted, models.JobTimeout, models.JobFailed), models.JobFailed},
+		{status(models.JobCompleted, models.JobTimeout), models.JobTimeout},
+	}
+	for _, tc := range cases {
+		if got := aggregateJobGroupStatus(tc.jobs); got != tc.want {
+			t.Errorf("aggregateJobGroupStatus(%v) = %s, want %s", tc.jobs, got, tc.want)
+		}
+	}
+}
@@ -14,12 +14,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/models"
 	"github.com/agentlab/agentlab/internal/pool"
 	"github.com/agentlab/agentlab/internal/proxmox"
+	"github.com/agentlab/agentlab/internal/tracing"
 )
 
 const (
@@ -47,6 +49,8 @@ type JobReport struct {
 	JobID     string
 	VMID      int
 	Status    models.JobStatus
+	Phase     string
+	Heartbeat bool
 	Message   string
 	Artifacts []V1ArtifactMetadata
 	Result    json.RawMessage
@@ -76,7 +80,7 @@ type sloEventPayload struct {
 // to enable cleanup on sandbox destroy.
 type JobOrchestrator struct {
 	store            *db.Store
-	profiles         map[string]models.Profile
+	profiles         *ProfileRegistry
 	backend          proxmox.Backend
 	sandboxManager   *SandboxManager
 	workspaceMgr     *WorkspaceManager
@@ -89,7 +93,7 @@ type JobOrchestrator struct {
 	now              func() time.Time
 	rand             io.Reader
 	bootstrapTTL     time.Duration
-	provisionTimeout time.Duration
+	provisionTimeout atomic.Int64
 	failureTimeout   time.Duration
 	snippetsMu       sync.Mutex
 	snippets         map[int]proxmox.CloudInitSnippet
@@ -101,6 +105,14 @@ type JobOrchestrator struct {
 	// job-created sandbox so the job path no longer bypasses capacity
 	// enforcement (review H3). Nil => pool disabled.
 	resourcePool *pool.Pool
+	// scheduleMu serializes dependency evaluation so two parents finishing
+	// together release a waiting child once. scheduled holds children that
+	// were released but whose Run has not returned yet.
+	scheduleMu sync.Mutex
+	scheduled  map[string]struct{}
+	// publisher pushes commits and opens pull requests for completed jobs
+	// created with result options. Nil => result options are ignored.
+	publisher *ResultPublisher
 }
 
 // NewJobOrchestrator creates a new job orchestrator with all dependencies.
@@ -126,26 +138,27 @@ func NewJobOrchestrator(store *db.Store, profiles map[string]models.Profile, bac
 	if redactor == nil {
 		redactor = NewRedactor(nil)
 	}
-	return &JobOrchestrator{
-		store:            store,
-		profiles:         profiles,
-		backend:          backend,
-		sandboxManager:   manager,
-		workspaceMgr:     workspaceMgr,
-		snippetStore:     snippetStore,
-		sshPublicKey:     strings.TrimSpace(sshPublicKey),
-		controllerURL:    strings.TrimSpace(controllerURL),
-		logger:           logger,
-		redactor:         redactor,
-		metrics:          metrics,
-		now:              time.Now,
-		rand:             rand.Reader,
-		bootstrapTTL:     defaultBootstrapTTL,
-		provisionTimeout: defaultProvisionTimeout,
-		failureTimeout:   defaultFailureTimeout,
-		snippets:         make(map[int]proxmox.CloudInitSnippet),
-		runner:           DetachedRunner(),
-	}
+	o := &JobOrchestrator{
+		store:          store,
+		profiles:       NewProfileRegistry(profiles),
+		backend:        backend,
+		sandboxManager: manager,
+		workspaceMgr:   workspaceMgr,
+		snippetStore:   snippetStore,
+		sshPublicKey:   strings.TrimSpace(sshPublicKey),
+		controllerURL:  strings.TrimSpace(controllerURL),
+		logger:         logger,
+		redactor:       redactor,
+		metrics:        metrics,
+		now:            time.Now,
+		rand:           rand.Reader,
+		bootstrapTTL:   defaultBootstrapTTL,
+		failureTimeout: defaultFailureTimeout,
+		snippets:       make(map[int]proxmox.CloudInitSnippet),
+		runner:         DetachedRunner(),
+	}
+	o.provisionTimeout.Store(int64(defaultProvisionTimeout))
+	return o
 }
 
 // WithBackgroundRunner sets the daemon lifecycle runner used to register
@@ -189,6 +202,7 @@ func (o *JobOrchestrator) Start(jobID string) {
 	// Register against the daemon lifecycle so shutdown cancels and awaits the
 	// job instead of leaving it running against a closing store (review H2).
 	runner.Go("job:"+jobID, func(ctx context.Context) {
+		defer o.clearScheduled(jobID)
 		if err := o.Run(ctx, jobID); err != nil && o.logger != nil {
 			msg := err.Error()
 			if o.redactor != nil {
@@ -199,7 +213,7 @@ func (o *JobOrchestrator) Start(jobID string) {
 	})
 }
 
-func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
+func (o *JobOrchestrator) Run(ctx context.Context, jobID string) (err error) {
 	if o == nil || o.store == nil {
 		return errors.New("job orchestrator unavailable")
 	}
@@ -208,6 +222,8 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 	if jobID == "" {
 		return errors.New("job id is required")
 	}
+	ctx, span := tracing.Start(ctx, "job.run", tracing.WithAttributes(tracing.String("job.id", jobID)))
+	defer func() { endSpan(span, err) }()
 	job, err := o.store.GetJob(ctx, jobID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -218,6 +234,8 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 	if job.Status != models.JobQueued {
 		return nil
 	}
+	span.SetAttributes(tracing.String("job.profile", job.Profile))
+	recordJobTrace(ctx, o.store, job.ID, 0)
 	profile, ok := o.profile(job.Profile)
 	if !ok {
 		return o.failJob(job, 0, fmt.Errorf("unknown profile %q", job.Profile))
@@ -225,6 +243,9 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 	if err := validateProfileForProvisioning(profile); err != nil {
 		return o.failJob(job, 0, err)
 	}
+	if profile, err = resolveProfileTemplate(ctx, o.store, profile, job.ID); err != nil {
+		return o.failJob(job, 0, err)
+	}
 	if err := o.backend.ValidateTemplate(ctx, proxmox.VMID(profile.TemplateVM)); err != nil {
 		return o.failJob(job, 0, fmt.Errorf("template validation failed: %w", err))
 	}
@@ -245,6 +266,8 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 	if err != nil {
 		return o.failJob(job, 0, err)
 	}
+	span.SetAttributes(tracing.Int("vmid", sandbox.VMID))
+	recordJobTrace(ctx, o.store, "", sandbox.VMID)
 	if created {
 		if _, err := o.store.UpdateJobSandbox(ctx, job.ID, sandbox.VMID); err != nil {
 			return o.failJob(job, sandbox.VMID, err)
@@ -256,6 +279,9 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 		}
 	}
 
+	if err := o.store.UpdateSandboxTemplate(ctx, sandbox.VMID, profile.TemplateVM); err != nil {
+		return o.failJob(job, sandbox.VMID, err)
+	}
 	if err := o.ensureWorkspaceAvailable(ctx, job, sandbox); err != nil {
 		return o.failJob(job, sandbox.VMID, err)
 	}
@@ -276,7 +302,7 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 		return o.failJob(job, sandbox.VMID, err)
 	}
 
-	snippet, err := o.snippetStore.Create(proxmox.SnippetInput{
+	snippet, err := o.writeSnippet(ctx, proxmox.SnippetInput{
 		VMID:           proxmox.VMID(sandbox.VMID),
 		Hostname:       sandbox.Name,
 		SSHPublicKey:   o.sshPublicKey,
@@ -320,9 +346,7 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 		return o.failJob(job, sandbox.VMID, err)
 	}
 
-	ipCtx, cancel := context.WithTimeout(ctx, defaultIPLookupTimeout)
-	ip, err := o.backend.GuestIP(ipCtx, proxmox.VMID(sandbox.VMID))
-	cancel()
+	ip, err := o.discoverGuestIP(ctx, sandbox.VMID)
 	if err != nil {
 		if !errors.Is(err, proxmox.ErrGuestIPNotFound) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
 			return o.failJob(job, sandbox.VMID, err)
@@ -340,7 +364,7 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 			o.logger.Printf("sandbox %d: ignoring duplicate IP candidate %s", sandbox.VMID, candidateIP)
 		}
 	}
-	o.observeSandboxSSH(sandbox, ip)
+	o.observeSandboxSSH(ctx, sandbox, ip)
 
 	if err := o.sandboxManager.Transition(ctx, sandbox.VMID, models.SandboxReady); err != nil {
 		return o.failJob(job, sandbox.VMID, err)
@@ -382,7 +406,7 @@ func (o *JobOrchestrator) Run(ctx context.Context, jobID string) error {
 }
 
 // ProvisionSandbox provisions a non-job sandbox end-to-end and returns the updated record.
-func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (models.Sandbox, error) {
+func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (_ models.Sandbox, err error) {
 	if o == nil || o.store == nil {
 		return models.Sandbox{}, errors.New("sandbox provisioner unavailable")
 	}
@@ -391,6 +415,8 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 	if vmid <= 0 {
 		return models.Sandbox{}, errors.New("vmid must be positive")
 	}
+	ctx, span := tracing.Start(ctx, "sandbox.provision", tracing.WithAttributes(tracing.Int("vmid", vmid)))
+	defer func() { endSpan(span, err) }()
 	sandbox, err := o.store.GetSandbox(ctx, vmid)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -404,6 +430,8 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 	if sandbox.State != models.SandboxRequested {
 		return models.Sandbox{}, fmt.Errorf("sandbox %d in state %s cannot be provisioned", sandbox.VMID, sandbox.State)
 	}
+	span.SetAttributes(tracing.String("sandbox.profile", sandbox.Profile))
+	recordJobTrace(ctx, o.store, "", sandbox.VMID)
 
 	profile, ok := o.profile(sandbox.Profile)
 	if !ok {
@@ -412,6 +440,9 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 	if err := validateProfileForProvisioning(profile); err != nil {
 		return models.Sandbox{}, err
 	}
+	if profile, err = resolveProfileTemplate(ctx, o.store, profile, strconv.Itoa(sandbox.VMID)); err != nil {
+		return models.Sandbox{}, err
+	}
 	if err := o.backend.ValidateTemplate(ctx, proxmox.VMID(profile.TemplateVM)); err != nil {
 		return models.Sandbox{}, fmt.Errorf("template validation failed: %w", err)
 	}
@@ -459,6 +490,9 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 		return models.Sandbox{}, err
 	}
 
+	if err := o.store.UpdateSandboxTemplate(ctx, sandbox.VMID, profile.TemplateVM); err != nil {
+		return fail(err)
+	}
 	if err := o.sandboxManager.Transition(ctx, sandbox.VMID, models.SandboxProvisioning); err != nil {
 		return fail(err)
 	}
@@ -481,7 +515,7 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 		return fail(err)
 	}
 
-	snippet, err := o.snippetStore.Create(proxmox.SnippetInput{
+	snippet, err := o.writeSnippet(ctx, proxmox.SnippetInput{
 		VMID:           proxmox.VMID(sandbox.VMID),
 		Hostname:       sandbox.Name,
 		SSHPublicKey:   o.sshPublicKey,
@@ -533,9 +567,7 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 		o.logger.Printf("sandbox %d: VM started, waiting for IP...", sandbox.VMID)
 	}
 
-	ipCtx, cancel := context.WithTimeout(ctx, defaultIPLookupTimeout)
-	ip, err := o.backend.GuestIP(ipCtx, proxmox.VMID(sandbox.VMID))
-	cancel()
+	ip, err := o.discoverGuestIP(ctx, sandbox.VMID)
 	if err != nil {
 		if !errors.Is(err, proxmox.ErrGuestIPNotFound) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
 			return fail(err)
@@ -552,7 +584,7 @@ func (o *JobOrchestrator) ProvisionSandbox(ctx context.Context, vmid int) (model
 			o.logger.Printf("sandbox %d: obtained IP %s", sandbox.VMID, ip)
 		}
 	}
-	o.observeSandboxSSH(sandbox, ip)
+	o.observeSandboxSSH(ctx, sandbox, ip)
 
 	if err := o.sandboxManager.Transition(ctx, sandbox.VMID, models.SandboxReady); err != nil {
 		return fail(err)
@@ -606,6 +638,9 @@ func (o *JobOrchestrator) HandleReport(ctx context.Context, report JobReport) er
 	if job.SandboxVMID == nil || *job.SandboxVMID != report.VMID {
 		return ErrJobSandboxMismatch
 	}
+	if report.Heartbeat {
+		return o.store.TouchJob(ctx, job.ID, o.now().UTC())
+	}
 
 	if o.metrics != nil && job.Status != report.Status {
 		o.metrics.IncJobStatus(report.Status)
@@ -625,11 +660,13 @@ func (o *JobOrchestrator) HandleReport(ctx context.Context, report JobReport) er
 	}
 	payload := struct {
 		Status    string               `json:"status"`
+		Phase     string               `json:"phase,omitempty"`
 		Message   string               `json:"message,omitempty"`
 		Artifacts []V1ArtifactMetadata `json:"artifacts,omitempty"`
 		Result    json.RawMessage      `json:"result,omitempty"`
 	}{
 		Status:    string(report.Status),
+		Phase:     report.Phase,
 		Message:   report.Message,
 		Artifacts: report.Artifacts,
 		Result:    report.Result,
@@ -657,6 +694,7 @@ func (o *JobOrchestrator) HandleReport(ctx context.Context, report JobReport) er
 			_ = o.sandboxManager.Destroy(ctx, report.VMID)
 			o.cleanupSnippet(report.VMID)
 		}
+		o.finishJob(ctx, job, report.Status)
 		return nil
 	}
 
@@ -733,7 +771,7 @@ func (o *JobOrchestrator) ensureSandbox(ctx context.Context, job models.Job) (mo
 		workspaceID := strings.TrimSpace(*job.WorkspaceID)
 		sandbox.WorkspaceID = &workspaceID
 	}
-	created, err := createSandboxWithRetry(ctx, o.store, sandbox, o.resourcePool, o.profiles)
+	created, err := createSandboxWithRetry(ctx, o.store, sandbox, o.resourcePool, o.profiles.Snapshot())
 	if err != nil {
 		return models.Sandbox{}, false, err
 	}
@@ -924,6 +962,9 @@ func (o *JobOrchestrator) failJob(job models.Job, vmid int, cause error) error {
 	resultJSON, _ := buildJobResult(models.JobFailed, message, nil, nil, o.now().UTC())
 	failureCtx, cancel := o.withFailureTimeout()
 	defer cancel()
+	// The caller's context may already be canceled; rejoin the job's trace
+	// so the failure events keep its trace ID.
+	failureCtx = jobTraceContext(failureCtx, o.store, job.ID)
 	_ = o.store.UpdateJobResult(failureCtx, job.ID, models.JobFailed, resultJSON)
 	payload := struct {
 		Status  string `json:"status"`
@@ -943,6 +984,7 @@ func (o *JobOrchestrator) failJob(job models.Job, vmid int, cause error) error {
 		_ = o.sandboxManager.Destroy(failureCtx, vmid)
 		o.cleanupSnippet(vmid)
 	}
+	o.releaseDependents(failureCtx, job.ID)
 	return cause
 }
 
@@ -950,11 +992,7 @@ func (o *JobOrchestrator) profile(name string) (models.Profile, bool) {
 	if name == "" {
 		return models.Profile{}, false
 	}
-	if o.profiles == nil {
-		return models.Profile{}, false
-	}
-	profile, ok := o.profiles[name]
-	return profile, ok
+	return o.profiles.Get(name)
 }
 
 func (o *JobOrchestrator) bootstrapToken() (string, string, time.Time, error) {
@@ -974,6 +1012,32 @@ func (o *JobOrchestrator) bootstrapToken() (string, string, time.Time, error) {
 	return token, hash, expires, nil
 }
 
+// writeSnippet writes a cloud-init snippet under a cloudinit.snippet.write
+// span.
+func (o *JobOrchestrator) writeSnippet(ctx context.Context, input proxmox.SnippetInput) (proxmox.CloudInitSnippet, error) {
+	_, span := tracing.Start(ctx, "cloudinit.snippet.write", tracing.WithAttributes(tracing.Int("vmid", int(input.VMID))))
+	snippet, err := o.snippetStore.Create(input)
+	endSpan(span, err)
+	return snippet, err
+}
+
+// discoverGuestIP asks the backend for a started guest's IP, bounded by
+// defaultIPLookupTimeout, under a sandbox.ip_discovery span.
+func (o *JobOrchestrator) discoverGuestIP(ctx context.Context, vmid int) (string, error) {
+	ctx, span := tracing.Start(ctx, "sandbox.ip_discovery", tracing.WithAttributes(tracing.Int("vmid", vmid)))
+	ipCtx, cancel := context.WithTimeout(ctx, defaultIPLookupTimeout)
+	ip, err := o.backend.GuestIP(ipCtx, proxmox.VMID(vmid))
+	cancel()
+	span.SetAttributes(tracing.Bool("sandbox.ip_found", strings.TrimSpace(ip) != ""))
+	if errors.Is(err, proxmox.ErrGuestIPNotFound) || errors.Is(err, context.DeadlineExceeded) {
+		// Still pending rather than failed; the caller reports it as such.
+		endSpan(span, nil)
+	} else {
+		endSpan(span, err)
+	}
+	return ip, err
+}
+
 func (o *JobOrchestrator) rememberSnippet(snippet proxmox.CloudInitSnippet) {
 	if snippet.VMID <= 0 {
 		return
@@ -1048,20 +1112,35 @@ func (o *JobOrchestrator) randReader() io.Reader {
 	return rand.Reader
 }
 
-// WithProvisionTimeout overrides the default provisioning timeout.
+// WithProvisionTimeout overrides the default provisioning timeout. It is safe
+// to call while jobs run; provisioning already in flight keeps its deadline.
 func (o *JobOrchestrator) WithProvisionTimeout(timeout time.Duration) *JobOrchestrator {
 	if o == nil {
 		return o
 	}
-	o.provisionTimeout = timeout
+	o.provisionTimeout.Store(int64(timeout))
+	return o
+}
+
+// WithProfileRegistry shares a profile registry so config reloads reach new
+// jobs.
+func (o *JobOrchestrator) WithProfileRegistry(profiles *ProfileRegistry) *JobOrchestrator {
+	if o == nil || profiles == nil {
+		return o
+	}
+	o.profiles = profiles
 	return o
 }
 
 func (o *JobOrchestrator) withProvisionTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	if o == nil || o.provisionTimeout <= 0 {
+	if o == nil {
+		return ctx, func() {}
+	}
+	timeout := time.Duration(o.provisionTimeout.Load())
+	if timeout <= 0 {
 		return ctx, func() {}
 	}
-	return context.WithTimeout(ctx, o.provisionTimeout)
+	return context.WithTimeout(ctx, timeout)
 }
 
 func (o *JobOrchestrator) withFailureTimeout() (context.Context, context.CancelFunc) {
@@ -1109,7 +1188,7 @@ func (o *JobOrchestrator) observeSandboxReady(ctx context.Context, sandbox model
 	o.recordSLOEvent(ctx, EventKindSandboxSLOReady, &sandbox.VMID, nil, fmt.Sprintf("ready in %s", duration), payload)
 }
 
-func (o *JobOrchestrator) observeSandboxSSH(sandbox models.Sandbox, ip string) {
+func (o *JobOrchestrator) observeSandboxSSH(ctx context.Context, sandbox models.Sandbox, ip string) {
 	if o == nil || sandbox.VMID <= 0 || sandbox.CreatedAt.IsZero() {
 		return
 	}
@@ -1122,6 +1201,7 @@ func (o *JobOrchestrator) observeSandboxSSH(sandbox models.Sandbox, ip string) {
 	createdAt := sandbox.CreatedAt
 	vmid := sandbox.VMID
 	probeIP := strings.TrimSpace(ip)
+	parent := tracing.SpanContextFromContext(ctx)
 	probeRunner := o.runner
 	if probeRunner == nil {
 		probeRunner = DetachedRunner()
@@ -1130,7 +1210,7 @@ func (o *JobOrchestrator) observeSandboxSSH(sandbox models.Sandbox, ip string) {
 	// cancels and awaits it rather than leaving it writing to a closing store
 	// (review H2).
 	probeRunner.Go("ssh-probe:"+strconv.Itoa(vmid), func(ctx context.Context) {
-		o.probeSSHReady(ctx, vmid, createdAt, probeIP)
+		o.probeSSHReady(tracing.ContextWithSpanContext(ctx, parent), vmid, createdAt, probeIP)
 	})
 }
 
@@ -1160,6 +1240,7 @@ func (o *JobOrchestrator) probeSSHReady(ctx context.Context, vmid int, createdAt
 	// it promptly (review H2).
 	ctx, cancel := context.WithTimeout(ctx, defaultSSHProbeTimeout)
 	defer cancel()
+	ctx, span := tracing.Start(ctx, "sandbox.ssh_probe", tracing.WithAttributes(tracing.Int("vmid", vmid), tracing.String("sandbox.ip", ip)))
 	dialer := &net.Dialer{Timeout: defaultSSHProbeDialTimeout}
 	ticker := time.NewTicker(defaultSSHProbeInterval)
 	defer ticker.Stop()
@@ -1167,11 +1248,13 @@ func (o *JobOrchestrator) probeSSHReady(ctx context.Context, vmid int, createdAt
 	for {
 		if o.trySSH(ctx, dialer, ip) {
 			o.recordSSHProbeResult(ctx, vmid, createdAt, ip, nil)
+			endSpan(span, nil)
 			return
 		}
 		select {
 		case <-ctx.Done():
 			o.recordSSHProbeResult(ctx, vmid, createdAt, ip, ctx.Err())
+			endSpan(span, ctx.Err())
 			return
 		case <-ticker.C:
 		}
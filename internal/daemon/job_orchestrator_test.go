@@ -419,6 +419,13 @@ resources:
 	if len(backend.cloneCalls) != 1 || backend.cloneCalls[0] != proxmox.VMID(sandbox.VMID) {
 		t.Fatalf("expected clone called for vmid %d", sandbox.VMID)
 	}
+	inUse, err := store.ListTemplateVMIDsInUse(ctx)
+	if err != nil {
+		t.Fatalf("list template vmids in use: %v", err)
+	}
+	if !inUse[9000] {
+		t.Fatalf("expected sandbox to record template 9000, got %v", inUse)
+	}
 	if len(backend.configureCalls) != 1 {
 		t.Fatalf("expected configure called once")
 	}
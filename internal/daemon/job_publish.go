@@ -0,0 +1,373 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/forge"
+	"github.com/agentlab/agentlab/internal/integrations"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+const (
+	// jobPushBundleName is the artifact path the guest uploads its commits to.
+	jobPushBundleName = "agentlab-push.bundle"
+	// defaultPushBranchPrefix prefixes the job id when push_branch is unset.
+	defaultPushBranchPrefix = "agent/"
+	// jobPublishTimeout bounds the push and pull request calls for one job.
+	jobPublishTimeout = 10 * time.Minute
+	maxPRTitleLength  = 72
+)
+
+// errNoPushBundle reports a completed job whose guest uploaded no commits.
+var errNoPushBundle = errors.New("no commits to push")
+
+// GitIntegrationLister lists integrations, including decrypted secrets.
+// *integrations.Store satisfies it.
+type GitIntegrationLister interface {
+	List(ctx context.Context) ([]*integrations.Integration, error)
+}
+
+// normalizeJobResultOptions validates the result options of a job create
+// request. The branch default depends on the job id and is applied by
+// encodeJobPublish.
+func normalizeJobResultOptions(opts *V1JobResultOptions, repoURL string) (*V1JobResultOptions, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	clean := V1JobResultOptions{
+		PushBranch:  strings.TrimSpace(opts.PushBranch),
+		OpenPR:      opts.OpenPR,
+		PRBase:      strings.TrimSpace(opts.PRBase),
+		PRTitle:     strings.TrimSpace(opts.PRTitle),
+		Integration: strings.TrimSpace(opts.Integration),
+	}
+	if clean.PushBranch != "" {
+		if err := validateBranchName(clean.PushBranch); err != nil {
+			return nil, fmt.Errorf("result.push_branch: %w", err)
+		}
+	}
+	if clean.PRBase != "" {
+		if err := validateBranchName(clean.PRBase); err != nil {
+			return nil, fmt.Errorf("result.pr_base: %w", err)
+		}
+	}
+	if !clean.OpenPR && (clean.PRBase != "" || clean.PRTitle != "") {
+		return nil, errors.New("result.pr_base and result.pr_title require result.open_pr")
+	}
+	kind, err := forge.ParseKind(opts.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("result.provider: %w", err)
+	}
+	clean.Provider = string(kind)
+	if _, err := forge.ParseRepoURL(repoURL, ""); err != nil {
+		return nil, fmt.Errorf("result requires an http(s) repo_url: %w", err)
+	}
+	return &clean, nil
+}
+
+// encodeJobPublish fills in the defaults that depend on the job and encodes
+// the options for storage.
+func encodeJobPublish(opts *V1JobResultOptions, jobID, ref string) (string, error) {
+	if opts == nil {
+		return "", nil
+	}
+	resolved := *opts
+	if resolved.PushBranch == "" {
+		resolved.PushBranch = defaultPushBranchPrefix + jobID
+	}
+	if resolved.OpenPR && resolved.PRBase == "" {
+		resolved.PRBase = ref
+	}
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return "", fmt.Errorf("encode result options: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeJobPublish reverses encodeJobPublish. Malformed values decode to nil.
+func decodeJobPublish(raw string) *V1JobResultOptions {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var opts V1JobResultOptions
+	if err := json.Unmarshal([]byte(raw), &opts); err != nil || opts.PushBranch == "" {
+		return nil
+	}
+	return &opts
+}
+
+// validateBranchName applies the subset of git check-ref-format rules that
+// matter for a branch supplied over the API.
+func validateBranchName(name string) error {
+	switch {
+	case name == "" || name == "@":
+		return errors.New("branch name is required")
+	case len(name) > 200:
+		return errors.New("branch name is too long")
+	case strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return fmt.Errorf("invalid branch name %q", name)
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock"):
+		return fmt.Errorf("invalid branch name %q", name)
+	case strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") || strings.Contains(name, "/."):
+		return fmt.Errorf("invalid branch name %q", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("invalid branch name %q", name)
+		}
+	}
+	return nil
+}
+
+// ResultPublisher pushes a completed job's commits and opens the requested
+// pull request. It runs on the daemon with git-proxy integration credentials;
+// the guest only uploads a git bundle through its artifact token.
+type ResultPublisher struct {
+	store        *db.Store
+	integrations GitIntegrationLister
+	artifactRoot string
+	httpClient   *http.Client
+	gitPath      string
+	logger       *log.Logger
+	redactor     *Redactor
+	now          func() time.Time
+}
+
+// NewResultPublisher returns a publisher reading bundles from artifactRoot.
+// A nil lister means integrations are disabled; publishing then records an
+// error on the job instead of pushing.
+func NewResultPublisher(store *db.Store, lister GitIntegrationLister, artifactRoot string, logger *log.Logger, redactor *Redactor) *ResultPublisher {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &ResultPublisher{
+		store:        store,
+		integrations: lister,
+		artifactRoot: artifactRoot,
+		httpClient:   &http.Client{Timeout: forge.DefaultTimeout},
+		logger:       logger,
+		redactor:     redactor,
+		now:          time.Now,
+	}
+}
+
+// Publish pushes the job's bundle and opens a pull request when requested.
+// Failures are reported in the returned result rather than as an error so
+// they can be recorded on the job.
+func (p *ResultPublisher) Publish(ctx context.Context, job models.Job) V1JobPublishResult {
+	opts := decodeJobPublish(job.PublishJSON)
+	if opts == nil {
+		return V1JobPublishResult{Error: "job has no result options", PublishedAt: p.now().UTC().Format(time.RFC3339Nano)}
+	}
+	result := V1JobPublishResult{Branch: opts.PushBranch}
+	if err := p.publish(ctx, job, *opts, &result); err != nil {
+		msg := err.Error()
+		if p.redactor != nil {
+			msg = p.redactor.Redact(msg)
+		}
+		result.Error = msg
+	}
+	result.PublishedAt = p.now().UTC().Format(time.RFC3339Nano)
+	return result
+}
+
+func (p *ResultPublisher) publish(ctx context.Context, job models.Job, opts V1JobResultOptions, result *V1JobPublishResult) error {
+	if p.integrations == nil {
+		return errors.New("integrations are disabled; no git credentials to push with")
+	}
+	integ, err := p.gitIntegration(ctx, job.RepoURL, opts.Integration)
+	if err != nil {
+		return err
+	}
+	bundlePath, err := p.bundlePath(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	commit, err := forge.PushBundle(ctx, forge.PushOptions{
+		RemoteURL:  job.RepoURL,
+		Username:   integ.Username,
+		Password:   integ.Secret,
+		BundlePath: bundlePath,
+		BaseRef:    job.Ref,
+		Branch:     opts.PushBranch,
+		GitPath:    p.gitPath,
+	})
+	if err != nil {
+		return err
+	}
+	result.Commit = commit
+	if !opts.OpenPR {
+		return nil
+	}
+	kind := forge.Kind(opts.Provider)
+	if kind == "" {
+		kind = forge.Kind(integ.Provider)
+	}
+	if kind == "" {
+		kind = forge.DetectKind(integ.Target)
+	}
+	result.Provider = string(kind)
+	repo, err := forge.ParseRepoURL(job.RepoURL, integ.Target)
+	if err != nil {
+		return err
+	}
+	provider, err := forge.New(kind, integ.Secret, p.httpClient)
+	if err != nil {
+		return err
+	}
+	url, err := provider.OpenPullRequest(ctx, forge.PullRequest{
+		Repo:  repo,
+		Head:  opts.PushBranch,
+		Base:  opts.PRBase,
+		Title: pullRequestTitle(opts.PRTitle, job.Task),
+		Body:  fmt.Sprintf("Opened by agentlab for job `%s`.\n\n**Task**\n\n%s\n", job.ID, job.Task),
+	})
+	if err != nil {
+		return fmt.Errorf("open pull request: %w", err)
+	}
+	result.PRURL = url
+	return nil
+}
+
+// gitIntegration picks the git-proxy integration whose target covers repoURL,
+// restricted to the named one when set. The target check keeps a job from
+// sending one host's credentials to another.
+func (p *ResultPublisher) gitIntegration(ctx context.Context, repoURL, name string) (*integrations.Integration, error) {
+	all, err := p.integrations.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list integrations: %w", err)
+	}
+	candidates := all
+	if name != "" {
+		candidates = nil
+		for _, integ := range all {
+			if integ != nil && integ.Name == name {
+				candidates = append(candidates, integ)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("integration %s not found", name)
+		}
+	}
+	integ := integrations.GitProxyForRepo(candidates, repoURL)
+	if integ == nil {
+		if name != "" {
+			return nil, fmt.Errorf("integration %s is not a git-proxy for %s", name, repoURL)
+		}
+		return nil, fmt.Errorf("no git-proxy integration covers %s", repoURL)
+	}
+	return integ, nil
+}
+
+func (p *ResultPublisher) bundlePath(ctx context.Context, jobID string) (string, error) {
+	artifact, err := p.store.LatestArtifactByKind(ctx, jobID, models.ArtifactKindGitBundle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errNoPushBundle
+	}
+	if err != nil {
+		return "", fmt.Errorf("load push bundle: %w", err)
+	}
+	return safeJoin(filepath.Join(p.artifactRoot, jobID), artifact.Path)
+}
+
+func pullRequestTitle(title, task string) string {
+	if title != "" {
+		return title
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(task), "\n")
+	title = "agentlab: " + strings.TrimSpace(line)
+	if len(title) > maxPRTitleLength {
+		title = strings.TrimSpace(title[:maxPRTitleLength-3]) + "..."
+	}
+	return title
+}
+
+// WithResultPublisher enables pushing commits and opening pull requests for
+// jobs created with result options.
+func (o *JobOrchestrator) WithResultPublisher(p *ResultPublisher) *JobOrchestrator {
+	if o != nil {
+		o.publisher = p
+	}
+	return o
+}
+
+// finishJob runs after a job reached a terminal status. A completed job with
+// result options is published first, in the background, so dependents start
+// only once its branch exists.
+func (o *JobOrchestrator) finishJob(ctx context.Context, job models.Job, status models.JobStatus) {
+	if status != models.JobCompleted || o.publisher == nil || decodeJobPublish(job.PublishJSON) == nil {
+		o.releaseDependents(ctx, job.ID)
+		return
+	}
+	runner := o.runner
+	if runner == nil {
+		runner = DetachedRunner()
+	}
+	started := runner.Go("publish:"+job.ID, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, jobPublishTimeout)
+		defer cancel()
+		o.recordPublishResult(ctx, job.ID, o.publisher.Publish(ctx, job))
+		o.releaseDependents(ctx, job.ID)
+	})
+	if !started {
+		o.logf("publish %s skipped: daemon shutting down", job.ID)
+	}
+}
+
+// recordPublishResult stores the outcome under "publish" in the job result
+// and emits job.published or job.publish_failed.
+func (o *JobOrchestrator) recordPublishResult(ctx context.Context, jobID string, result V1JobPublishResult) {
+	job, err := o.store.GetJob(ctx, jobID)
+	if err != nil {
+		o.logf("load job %s for publish result: %v", jobID, err)
+		return
+	}
+	merged, err := mergeJobResultField(job.ResultJSON, "publish", result)
+	if err != nil {
+		o.logf("encode publish result for %s: %v", jobID, err)
+		return
+	}
+	if err := o.store.UpdateJobResult(ctx, jobID, job.Status, merged); err != nil {
+		o.logf("store publish result for %s: %v", jobID, err)
+	}
+	kind, message := EventKindJobPublished, "pushed "+result.Branch
+	if result.Error != "" {
+		kind, message = EventKindJobPublishFailed, "publish failed: "+result.Error
+	} else if result.PRURL != "" {
+		message = "opened " + result.PRURL
+	}
+	_ = emitEvent(ctx, NewStoreEventRecorder(o.store), kind, job.SandboxVMID, &job.ID, message, result)
+}
+
+// mergeJobResultField sets one top-level field of a job result document.
+func mergeJobResultField(resultJSON, key string, value any) (string, error) {
+	fields := make(map[string]json.RawMessage)
+	if strings.TrimSpace(resultJSON) != "" {
+		if err := json.Unmarshal([]byte(resultJSON), &fields); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	fields[key] = data
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
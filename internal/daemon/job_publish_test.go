@@ -0,0 +1,222 @@
+package daemon
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/integrations"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+	testutil "github.com/agentlab/agentlab/internal/testing"
+)
+
+// inlineJobRunner runs background work synchronously.
+type inlineJobRunner struct{}
+
+func (inlineJobRunner) Go(_ string, fn func(ctx context.Context)) bool {
+	fn(context.Background())
+	return true
+}
+
+func (inlineJobRunner) LifecycleContext() context.Context { return context.Background() }
+
+type staticIntegrations []*integrations.Integration
+
+func (s staticIntegrations) List(context.Context) ([]*integrations.Integration, error) {
+	return s, nil
+}
+
+func TestNormalizeJobResultOptions(t *testing.T) {
+	repo := "https://git.example.com/org/repo.git"
+	cases := []struct {
+		name string
+		opts V1JobResultOptions
+		repo string
+		msg  string
+	}{
+		{"bad branch", V1JobResultOptions{PushBranch: "feature..x"}, repo, "result.push_branch"},
+		{"branch with space", V1JobResultOptions{PushBranch: "a b"}, repo, "result.push_branch"},
+		{"title without pr", V1JobResultOptions{PushBranch: "x", PRTitle: "t"}, repo, "require result.open_pr"},
+		{"unknown provider", V1JobResultOptions{OpenPR: true, Provider: "bitbucket"}, repo, "result.provider"},
+		{"ssh repo", V1JobResultOptions{OpenPR: true}, "git@example.com:org/repo.git", "http(s) repo_url"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			if _, err := normalizeJobResultOptions(&opts, tc.repo); err == nil || !strings.Contains(err.Error(), tc.msg) {
+				t.Fatalf("normalizeJobResultOptions() error = %v, want %q", err, tc.msg)
+			}
+		})
+	}
+
+	opts, err := normalizeJobResultOptions(&V1JobResultOptions{OpenPR: true, Provider: " Gitea "}, repo)
+	if err != nil {
+		t.Fatalf("normalizeJobResultOptions() error = %v", err)
+	}
+	raw, err := encodeJobPublish(opts, "job_abc", "develop")
+	if err != nil {
+		t.Fatalf("encodeJobPublish() error = %v", err)
+	}
+	decoded := decodeJobPublish(raw)
+	if decoded == nil || decoded.PushBranch != "agent/job_abc" || decoded.PRBase != "develop" || decoded.Provider != "gitea" {
+		t.Fatalf("unexpected decoded options %+v", decoded)
+	}
+}
+
+func TestJobCompletionPushesAndOpensPullRequest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ctx := context.Background()
+	forge := testutil.NewGiteaServer(t, "agent-bot", "s3cret-token")
+	repoURL := forge.CreateRepo(t, "org/repo")
+
+	// The guest clones, commits, and bundles the new commits for upload.
+	work := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("agent-bot:s3cret-token"))
+	gitCmd(t, "", "-c", "http.extraHeader=Authorization: Basic "+auth, "clone", "--quiet", repoURL, work)
+	base := gitCmd(t, work, "rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(work, "fix.txt"), []byte("fixed\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	gitCmd(t, work, "add", "fix.txt")
+	gitCmd(t, work, "commit", "--quiet", "-m", "fix")
+	head := gitCmd(t, work, "rev-parse", "HEAD")
+
+	store := newTestStore(t)
+	artifactRoot := t.TempDir()
+	jobID := "job_publish"
+	if err := os.MkdirAll(filepath.Join(artifactRoot, jobID), 0o755); err != nil {
+		t.Fatalf("mkdir artifacts: %v", err)
+	}
+	gitCmd(t, work, "bundle", "create", filepath.Join(artifactRoot, jobID, jobPushBundleName), "HEAD", "^"+base)
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	vmid := 1200
+	if err := store.CreateSandbox(ctx, models.Sandbox{VMID: vmid, Name: "sandbox-1200", Profile: "default", State: models.SandboxRunning, Keepalive: true, CreatedAt: now, LastUpdatedAt: now}); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+	publishJSON, err := encodeJobPublish(&V1JobResultOptions{OpenPR: true}, jobID, "main")
+	if err != nil {
+		t.Fatalf("encode publish: %v", err)
+	}
+	if err := store.CreateJob(ctx, models.Job{
+		ID:          jobID,
+		RepoURL:     repoURL,
+		Ref:         "main",
+		Profile:     "default",
+		Task:        "Fix the flaky test\nmore detail",
+		Status:      models.JobRunning,
+		SandboxVMID: &vmid,
+		Keepalive:   true,
+		PublishJSON: publishJSON,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(artifactRoot, jobID, jobPushBundleName))
+	if err != nil {
+		t.Fatalf("stat bundle: %v", err)
+	}
+	if _, err := store.CreateArtifact(ctx, db.Artifact{JobID: jobID, VMID: &vmid, Name: jobPushBundleName, Path: jobPushBundleName, Kind: models.ArtifactKindGitBundle, SizeBytes: info.Size(), Sha256: strings.Repeat("0", 64), CreatedAt: now}); err != nil {
+		t.Fatalf("create artifact: %v", err)
+	}
+
+	lister := staticIntegrations{
+		{Name: "other", Type: integrations.TypeGitProxy, Target: "https://elsewhere.example.com", Secret: "wrong"},
+		{Name: "gitea", Type: integrations.TypeGitProxy, Target: forge.URL, Username: "agent-bot", Secret: "s3cret-token", Provider: "gitea"},
+	}
+	orchestrator := NewJobOrchestrator(store, nil, nil, nil, nil, proxmox.SnippetStore{}, "", "", log.New(io.Discard, "", 0), nil, nil).
+		WithBackgroundRunner(inlineJobRunner{}).
+		WithResultPublisher(NewResultPublisher(store, lister, artifactRoot, log.New(io.Discard, "", 0), nil))
+
+	if err := orchestrator.HandleReport(ctx, JobReport{JobID: jobID, VMID: vmid, Status: models.JobCompleted, Message: "done"}); err != nil {
+		t.Fatalf("handle report: %v", err)
+	}
+
+	if got := forge.BranchCommit(t, "org/repo", "agent/"+jobID); got != head {
+		t.Fatalf("pushed branch at %s, want %s", got, head)
+	}
+	pulls := forge.PullRequests()
+	if len(pulls) != 1 || pulls[0].Head != "agent/"+jobID || pulls[0].Base != "main" || pulls[0].Title != "agentlab: Fix the flaky test" {
+		t.Fatalf("unexpected pull requests %+v", pulls)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.Status != models.JobCompleted {
+		t.Fatalf("expected COMPLETED, got %s", job.Status)
+	}
+	var result struct {
+		Status  string             `json:"status"`
+		Publish V1JobPublishResult `json:"publish"`
+	}
+	if err := json.Unmarshal([]byte(job.ResultJSON), &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	wantURL := forge.URL + "/org/repo/pulls/1"
+	if result.Status != string(models.JobCompleted) || result.Publish.PRURL != wantURL || result.Publish.Commit != head || result.Publish.Error != "" {
+		t.Fatalf("unexpected result %s", job.ResultJSON)
+	}
+	events, err := store.ListEventsByJobAll(ctx, jobID)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	found := false
+	for _, event := range events {
+		if event.Kind == string(EventKindJobPublished) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected %s event", EventKindJobPublished)
+	}
+}
+
+func TestResultPublisherRequiresMatchingIntegration(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	publishJSON, err := encodeJobPublish(&V1JobResultOptions{}, "job_x", "main")
+	if err != nil {
+		t.Fatalf("encode publish: %v", err)
+	}
+	job := models.Job{ID: "job_x", RepoURL: "https://git.example.com/org/repo.git", Ref: "main", PublishJSON: publishJSON}
+	lister := staticIntegrations{{Name: "other", Type: integrations.TypeGitProxy, Target: "https://git.example.com/other", Secret: "token"}}
+
+	result := NewResultPublisher(store, lister, t.TempDir(), nil, nil).Publish(ctx, job)
+	if result.Branch != "agent/job_x" || !strings.Contains(result.Error, "no git-proxy integration covers") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = NewResultPublisher(store, nil, t.TempDir(), nil, nil).Publish(ctx, job)
+	if !strings.Contains(result.Error, "integrations are disabled") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=agent", "GIT_AUTHOR_EMAIL=agent@example.com",
+		"GIT_COMMITTER_NAME=agent", "GIT_COMMITTER_EMAIL=agent@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -2,13 +2,21 @@ package daemon
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/models"
@@ -26,16 +34,51 @@ import (
 //   - GET  /metadata/             - Index listing available endpoints
 //   - GET  /metadata/identity     - Sandbox identity (name, ID, profile, state)
 //   - GET  /metadata/metadata     - Sandbox metadata key-value pairs
+//   - GET  /metadata/env          - Current env for the sandbox, with an ETag
 //   - GET  /metadata/secrets/{name} - Access a specific secret value
+//   - GET  /metadata/kv/          - Status values this sandbox has published
+//   - GET, PUT, DELETE /metadata/kv/{key} - Read, publish or clear one status value
+//   - POST /metadata/messages   - Post a note or a question for an operator
+//   - GET  /metadata/messages/{id}/reply - Answer to a question this sandbox asked
+//
+// Secrets with a policy in the bundle are served only to matching sandboxes.
+// Approval-gated secrets answer 202 with a pending request until an operator
+// approves it; ?wait=<duration> blocks for the decision.
+//
+// The guest runner polls /metadata/env with If-None-Match to pick up rotated
+// secrets without a restart; an unchanged env answers 304 with no body.
+//
+// /metadata/kv is the one writable endpoint. Agents publish short status
+// values ("tests passing", a PR URL) that operators see in sandbox show, the
+// dashboard and the event stream. Writes are capped in key length, value
+// size, key count and rate.
+//
+// /metadata/messages lets an agent ask an operator a question and block on
+// ?wait=<duration> until it is answered. Posts share the kv write rate limit.
 type MetadataAPI struct {
 	store         *db.Store
 	secretsStore  secrets.Store
 	secretsBundle string
 	agentSubnet   *net.IPNet
 	rateLimiter   *IPRateLimiter
+	approvals     *SecretApprovals
+	questions     *Questions
+	writeLimiter  *IPRateLimiter
 	logger        *log.Logger
 }
 
+// Limits on guest-published status values. The write rate also applies to
+// posted messages.
+const (
+	metadataKVMaxKeys       = 64
+	metadataKVMaxValueBytes = 4 << 10
+	metadataKVWriteQPS      = 1
+	metadataKVWriteBurst    = 10
+)
+
+// metadataKVKeyPattern limits keys to short, URL- and shell-safe names.
+var metadataKVKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
 // NewMetadataAPI creates a new metadata API instance.
 func NewMetadataAPI(store *db.Store, secretsStore secrets.Store, secretsBundle string, agentSubnet *net.IPNet, rateLimiter *IPRateLimiter, logger *log.Logger) *MetadataAPI {
 	bundle := strings.TrimSpace(secretsBundle)
@@ -51,10 +94,30 @@ func NewMetadataAPI(store *db.Store, secretsStore secrets.Store, secretsBundle s
 		secretsBundle: bundle,
 		agentSubnet:   agentSubnet,
 		rateLimiter:   rateLimiter,
+		writeLimiter:  NewIPRateLimiter(metadataKVWriteQPS, metadataKVWriteBurst),
 		logger:        logger,
 	}
 }
 
+// WithSecretApprovals enables just-in-time approval for approval-gated
+// secrets. Without it those secrets are never served.
+func (api *MetadataAPI) WithSecretApprovals(approvals *SecretApprovals) *MetadataAPI {
+	if api == nil {
+		return api
+	}
+	api.approvals = approvals
+	return api
+}
+
+// WithQuestions enables POST /metadata/messages and the reply long-poll.
+func (api *MetadataAPI) WithQuestions(questions *Questions) *MetadataAPI {
+	if api == nil {
+		return api
+	}
+	api.questions = questions
+	return api
+}
+
 // sandboxSecretHeader carries the per-sandbox endpoint secret on requests to
 // the metadata and credential-proxy endpoints (review F4).
 const sandboxSecretHeader = "X-AgentLab-Sandbox-Secret"
@@ -109,7 +172,11 @@ func (api *MetadataAPI) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/metadata/", api.handleIndex)
 	mux.HandleFunc("/metadata/identity", api.handleIdentity)
 	mux.HandleFunc("/metadata/metadata", api.handleMetadata)
+	mux.HandleFunc("/metadata/env", api.handleEnv)
 	mux.HandleFunc("/metadata/secrets/", api.handleSecrets)
+	mux.HandleFunc("/metadata/kv/", api.handleKV)
+	mux.HandleFunc("/metadata/messages", api.handleMessages)
+	mux.HandleFunc("/metadata/messages/", api.handleMessageReply)
 }
 
 func (api *MetadataAPI) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -133,7 +200,13 @@ func (api *MetadataAPI) handleIndex(w http.ResponseWriter, r *http.Request) {
 		Endpoints: []MetadataEndpoint{
 			{Path: "/metadata/identity", Method: http.MethodGet, Description: "Sandbox identity (vmid, name, profile, state)"},
 			{Path: "/metadata/metadata", Method: http.MethodGet, Description: "Sandbox metadata key-value pairs"},
+			{Path: "/metadata/env", Method: http.MethodGet, Description: "Current env for the sandbox; send If-None-Match to poll for changes"},
 			{Path: "/metadata/secrets/{name}", Method: http.MethodGet, Description: "Access a specific secret value by name"},
+			{Path: "/metadata/kv/", Method: http.MethodGet, Description: "List status values this sandbox has published"},
+			{Path: "/metadata/kv/{key}", Method: http.MethodPut, Description: "Publish a status value visible to operators"},
+			{Path: "/metadata/kv/{key}", Method: http.MethodDelete, Description: "Clear a published status value"},
+			{Path: "/metadata/messages", Method: http.MethodPost, Description: "Post a note or a question for an operator"},
+			{Path: "/metadata/messages/{id}/reply", Method: http.MethodGet, Description: "Answer to a question; send ?wait= to block until answered"},
 			{Path: "/proxy/{name}/...", Method: http.MethodGet, Description: "Credential proxy: forward requests with injected credentials (HTTP, Git, LLM)"},
 			{Path: "/proxy/{name}/...", Method: http.MethodPost, Description: "Credential proxy: forward requests with injected credentials (HTTP, Git, LLM)"},
 		},
@@ -192,7 +265,7 @@ func (api *MetadataAPI) handleMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Load metadata from the secrets bundle associated with this sandbox's profile.
-	metadata := api.loadMetadata(r)
+	metadata := api.loadMetadata(r, sandbox)
 	resp := MetadataMetadataResponse{
 		Metadata: metadata,
 	}
@@ -210,6 +283,41 @@ func (api *MetadataAPI) handleMetadata(w http.ResponseWriter, r *http.Request) {
 	api.auditLog(r.RemoteAddr, "/metadata/metadata", r.Method, sandbox)
 }
 
+func (api *MetadataAPI) handleEnv(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if !api.remoteAllowed(r.RemoteAddr) {
+		writeError(w, http.StatusForbidden, "metadata access restricted to agent subnet")
+		return
+	}
+	if api.rateLimiter != nil && !api.rateLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	sandbox, ok := api.requireSandboxSecret(w, r)
+	if !ok {
+		return
+	}
+	env, err := api.loadEnv(r, sandbox)
+	if err != nil {
+		api.logger.Printf("metadata env load failed for vmid=%d: %v", sandbox.VMID, err)
+		writeError(w, http.StatusInternalServerError, "failed to load secrets bundle")
+		return
+	}
+	etag := envETag(env)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		// Polls that find nothing new are not audited; only served values are.
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, MetadataEnvResponse{Env: env, Version: strings.Trim(etag, `"`)})
+	api.auditLog(r.RemoteAddr, "/metadata/env", r.Method, sandbox)
+}
+
 func (api *MetadataAPI) handleSecrets(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeMethodNotAllowed(w, []string{http.MethodGet})
@@ -234,18 +342,386 @@ func (api *MetadataAPI) handleSecrets(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	value, err := api.loadSecret(r, name)
+	wait, err := parseSecretWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	value, policy, err := api.loadSecret(r, name)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "secret not found")
 		return
 	}
-	writeJSON(w, http.StatusOK, MetadataSecretResponse{
-		Name:  name,
-		Value: value,
-	})
+	resp := MetadataSecretResponse{Name: name}
+	if policy != nil {
+		jobID := api.sandboxJobID(r.Context(), sandbox.VMID)
+		if !policy.Allows(secretSubject(*sandbox, jobID)) {
+			writeError(w, http.StatusForbidden, "secret not permitted for this sandbox")
+			api.auditLog(r.RemoteAddr, "/metadata/secrets/"+name, r.Method, sandbox)
+			return
+		}
+		if policy.RequireApproval {
+			if api.approvals == nil {
+				writeError(w, http.StatusForbidden, "secret requires approval")
+				return
+			}
+			req, err := api.approvals.Request(r.Context(), *sandbox, jobID, name, *policy)
+			if err != nil {
+				api.logger.Printf("metadata: secret request %s for vmid %d: %v", name, sandbox.VMID, err)
+				writeError(w, http.StatusInternalServerError, "failed to request secret access")
+				return
+			}
+			if req.Status == db.SecretRequestPending && wait > 0 {
+				if req, err = api.approvals.Wait(r.Context(), req.ID, wait); err != nil {
+					writeError(w, http.StatusInternalServerError, "failed to load secret request")
+					return
+				}
+			}
+			switch {
+			case req.Active(api.approvals.now()):
+				resp.Status = req.Status
+				resp.RequestID = req.ID
+				resp.ExpiresAt = req.GrantExpiresAt.UTC().Format(time.RFC3339)
+			case req.Status == db.SecretRequestPending:
+				writeJSON(w, http.StatusAccepted, MetadataSecretResponse{Name: name, Status: req.Status, RequestID: req.ID})
+				return
+			default:
+				writeError(w, http.StatusForbidden, "secret access "+req.Status)
+				api.auditLog(r.RemoteAddr, "/metadata/secrets/"+name, r.Method, sandbox)
+				return
+			}
+		}
+	}
+	resp.Value, err = api.secretsStore.ResolveValue(r.Context(), name, value)
+	if err != nil {
+		api.logger.Printf("metadata: secret %s resolve failed for vmid=%d: %v", name, sandbox.VMID, err)
+		writeError(w, http.StatusInternalServerError, "failed to resolve secret")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
 	api.auditLog(r.RemoteAddr, "/metadata/secrets/"+name, r.Method, sandbox)
 }
 
+func (api *MetadataAPI) handleKV(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/metadata/kv/")
+	allowed := []string{http.MethodGet}
+	if key != "" {
+		allowed = []string{http.MethodGet, http.MethodPut, http.MethodDelete}
+	}
+	if !slices.Contains(allowed, r.Method) {
+		writeMethodNotAllowed(w, allowed)
+		return
+	}
+	if !api.remoteAllowed(r.RemoteAddr) {
+		writeError(w, http.StatusForbidden, "metadata access restricted to agent subnet")
+		return
+	}
+	if api.rateLimiter != nil && !api.rateLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	if r.Method != http.MethodGet && api.writeLimiter != nil && !api.writeLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	if key != "" && !metadataKVKeyPattern.MatchString(key) {
+		writeError(w, http.StatusBadRequest, "key must be 1-64 characters of letters, digits, '.', '_' or '-'")
+		return
+	}
+	sandbox, ok := api.requireSandboxSecret(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	switch {
+	case key == "":
+		entries, err := sandboxKVEntries(ctx, api.store, sandbox.VMID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load status values")
+			return
+		}
+		writeJSON(w, http.StatusOK, MetadataKVListResponse{Entries: entries})
+	case r.Method == http.MethodGet:
+		kv, err := api.store.GetSandboxKV(ctx, sandbox.VMID, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load status value")
+			return
+		}
+		writeJSON(w, http.StatusOK, metadataKVEntry(kv))
+	case r.Method == http.MethodPut:
+		var req MetadataKVRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeJSONDecodeError(w, err)
+			return
+		}
+		if len(req.Value) > metadataKVMaxValueBytes {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("value exceeds %d bytes", metadataKVMaxValueBytes))
+			return
+		}
+		kv := db.SandboxKV{
+			VMID:      sandbox.VMID,
+			Key:       key,
+			Value:     req.Value,
+			JobID:     api.sandboxJobID(ctx, sandbox.VMID),
+			UpdatedAt: time.Now().UTC(),
+		}
+		if err := api.store.PutSandboxKV(ctx, kv, metadataKVMaxKeys); err != nil {
+			if errors.Is(err, db.ErrSandboxKVFull) {
+				writeError(w, http.StatusConflict, fmt.Sprintf("sandbox already has %d keys", metadataKVMaxKeys))
+				return
+			}
+			api.logger.Printf("metadata: put kv %s for vmid %d: %v", key, sandbox.VMID, err)
+			writeError(w, http.StatusInternalServerError, "failed to store status value")
+			return
+		}
+		api.emitKVUpdated(ctx, kv, sandboxKVUpdatedPayload{Key: key, Value: req.Value})
+		writeJSON(w, http.StatusOK, metadataKVEntry(kv))
+	case r.Method == http.MethodDelete:
+		deleted, err := api.store.DeleteSandboxKV(ctx, sandbox.VMID, key)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete status value")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		kv := db.SandboxKV{VMID: sandbox.VMID, Key: key, JobID: api.sandboxJobID(ctx, sandbox.VMID)}
+		api.emitKVUpdated(ctx, kv, sandboxKVUpdatedPayload{Key: key, Deleted: true})
+		w.WriteHeader(http.StatusNoContent)
+	}
+	api.auditLog(r.RemoteAddr, r.URL.Path, r.Method, sandbox)
+}
+
+func (api *MetadataAPI) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+		return
+	}
+	if !api.remoteAllowed(r.RemoteAddr) {
+		writeError(w, http.StatusForbidden, "metadata access restricted to agent subnet")
+		return
+	}
+	if api.rateLimiter != nil && !api.rateLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	if api.writeLimiter != nil && !api.writeLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	sandbox, ok := api.requireSandboxSecret(w, r)
+	if !ok {
+		return
+	}
+	var req MetadataMessageRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	ctx := r.Context()
+	jobID := api.sandboxJobID(ctx, sandbox.VMID)
+	var (
+		msg db.Message
+		err error
+	)
+	switch kind := strings.TrimSpace(req.Kind); kind {
+	case "", db.MessageKindNote:
+		if len(req.Options) > 0 {
+			writeError(w, http.StatusBadRequest, "options are only valid on questions")
+			return
+		}
+		text := strings.TrimSpace(req.Text)
+		if text == "" || len(text) > maxQuestionTextBytes {
+			writeError(w, http.StatusBadRequest, errQuestionTextInvalid.Error())
+			return
+		}
+		scopeType, scopeID := sandboxMessageScope(sandbox.VMID, jobID)
+		msg, err = api.store.CreateMessage(ctx, db.Message{
+			Timestamp: time.Now().UTC(),
+			ScopeType: scopeType,
+			ScopeID:   scopeID,
+			Author:    sandboxMessageAuthor(sandbox.VMID),
+			Kind:      db.MessageKindNote,
+			Text:      text,
+		})
+	case db.MessageKindQuestion:
+		if api.questions == nil {
+			writeError(w, http.StatusServiceUnavailable, "questions are not enabled")
+			return
+		}
+		msg, err = api.questions.Ask(ctx, *sandbox, jobID, req.Text, req.Options)
+		if isQuestionInputError(err) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "kind must be note or question")
+		return
+	}
+	if err != nil {
+		api.logger.Printf("metadata: post message for vmid %d: %v", sandbox.VMID, err)
+		writeError(w, http.StatusInternalServerError, "failed to post message")
+		return
+	}
+	writeJSON(w, http.StatusCreated, metadataMessage(msg))
+	api.auditLog(r.RemoteAddr, "/metadata/messages", r.Method, sandbox)
+}
+
+// handleMessageReply serves GET /metadata/messages/{id}/reply. A sandbox can
+// only read answers to questions it asked.
+func (api *MetadataAPI) handleMessageReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if !api.remoteAllowed(r.RemoteAddr) {
+		writeError(w, http.StatusForbidden, "metadata access restricted to agent subnet")
+		return
+	}
+	if api.rateLimiter != nil && !api.rateLimiter.Allow(r.RemoteAddr) {
+		writeRateLimitExceeded(w)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/metadata/messages/")
+	idText, ok := strings.CutSuffix(rest, "/reply")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if !ok || err != nil || id <= 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	sandbox, ok := api.requireSandboxSecret(w, r)
+	if !ok {
+		return
+	}
+	if api.questions == nil {
+		writeError(w, http.StatusServiceUnavailable, "questions are not enabled")
+		return
+	}
+	wait, err := parseSecretWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx := r.Context()
+	question, err := api.questions.Get(ctx, id)
+	if err == nil && question.Author != sandboxMessageAuthor(sandbox.VMID) {
+		err = errQuestionNotFound
+	}
+	if errors.Is(err, errQuestionNotFound) {
+		writeError(w, http.StatusNotFound, "question not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load question")
+		return
+	}
+	reply, err := api.questions.Wait(ctx, id, wait)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load reply")
+		return
+	}
+	if reply == nil {
+		writeJSON(w, http.StatusAccepted, MetadataReplyResponse{QuestionID: id, Status: "pending"})
+		return
+	}
+	writeJSON(w, http.StatusOK, MetadataReplyResponse{
+		QuestionID: id,
+		Status:     "answered",
+		Text:       reply.Text,
+		Author:     reply.Author,
+		AnsweredAt: reply.Timestamp.UTC().Format(time.RFC3339),
+	})
+	api.auditLog(r.RemoteAddr, r.URL.Path, r.Method, sandbox)
+}
+
+func metadataMessage(msg db.Message) MetadataMessage {
+	return MetadataMessage{
+		ID:        msg.ID,
+		Kind:      msg.Kind,
+		Text:      msg.Text,
+		Options:   questionOptions(msg),
+		ScopeType: msg.ScopeType,
+		ScopeID:   msg.ScopeID,
+		Timestamp: msg.Timestamp.UTC().Format(time.RFC3339),
+	}
+}
+
+type sandboxKVUpdatedPayload struct {
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+func (api *MetadataAPI) emitKVUpdated(ctx context.Context, kv db.SandboxKV, payload sandboxKVUpdatedPayload) {
+	vmid := kv.VMID
+	var jobID *string
+	if kv.JobID != "" {
+		id := kv.JobID
+		jobID = &id
+	}
+	msg := "status " + kv.Key + " updated"
+	if payload.Deleted {
+		msg = "status " + kv.Key + " cleared"
+	}
+	if err := emitEvent(ctx, NewStoreEventRecorder(api.store), EventKindSandboxKVUpdated, &vmid, jobID, msg, payload); err != nil {
+		api.logger.Printf("metadata: record %s for vmid %d: %v", EventKindSandboxKVUpdated, kv.VMID, err)
+	}
+}
+
+// sandboxKVEntries loads the status values a sandbox has published, ordered
+// by key. It returns nil when there are none.
+func sandboxKVEntries(ctx context.Context, store *db.Store, vmid int) ([]MetadataKVEntry, error) {
+	rows, err := store.ListSandboxKV(ctx, vmid)
+	if err != nil {
+		return nil, err
+	}
+	var out []MetadataKVEntry
+	for _, kv := range rows {
+		out = append(out, metadataKVEntry(kv))
+	}
+	return out, nil
+}
+
+func metadataKVEntry(kv db.SandboxKV) MetadataKVEntry {
+	return MetadataKVEntry{
+		Key:       kv.Key,
+		Value:     kv.Value,
+		JobID:     kv.JobID,
+		UpdatedAt: kv.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// parseSecretWait parses the ?wait= long-poll duration for approval-gated
+// secrets. It is capped at maxSecretApprovalWait.
+func parseSecretWait(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(raw)
+	if err != nil || wait < 0 {
+		return 0, errors.New("wait must be a non-negative duration")
+	}
+	if wait > maxSecretApprovalWait {
+		wait = maxSecretApprovalWait
+	}
+	return wait, nil
+}
+
+// sandboxJobID returns the id of the job running on vmid, or "" if none.
+func (api *MetadataAPI) sandboxJobID(ctx context.Context, vmid int) string {
+	job, err := api.store.GetJobBySandboxVMID(ctx, vmid)
+	if err != nil {
+		return ""
+	}
+	return job.ID
+}
+
 // remoteAllowed checks if the request originates from the agent subnet.
 func (api *MetadataAPI) remoteAllowed(addr string) bool {
 	if api.agentSubnet == nil {
@@ -274,48 +750,112 @@ func (api *MetadataAPI) sandboxByRemoteIP(r *http.Request) (*models.Sandbox, err
 	return &sb, nil
 }
 
-// loadMetadata loads the metadata map from the secrets bundle.
-func (api *MetadataAPI) loadMetadata(r *http.Request) map[string]string {
+// loadMetadata loads the metadata map from the secrets bundle. Keys whose
+// policy excludes the sandbox or requires approval are left out, as are keys
+// whose provider reference cannot be resolved.
+func (api *MetadataAPI) loadMetadata(r *http.Request, sandbox *models.Sandbox) map[string]string {
 	if api.secretsStore.Dir == "" {
 		return nil
 	}
-	bundle, err := api.secretsStore.Load(r.Context(), api.secretsBundle)
+	bundle, err := api.secretsStore.LoadUnresolved(r.Context(), api.secretsBundle)
 	if err != nil {
 		return nil
 	}
 	if len(bundle.Metadata) == 0 {
 		return nil
 	}
-	// Return a copy to prevent mutation.
-	out := make(map[string]string, len(bundle.Metadata))
-	for k, v := range bundle.Metadata {
-		out[k] = v
+	values := bundle.Metadata
+	if len(bundle.Policies) > 0 && sandbox != nil {
+		subject := secretSubject(*sandbox, api.sandboxJobID(r.Context(), sandbox.VMID))
+		values = filterPolicyValues(values, bundle, subject)
+	}
+	// Resolve into a copy to prevent mutation.
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		resolved, err := api.secretsStore.ResolveValue(r.Context(), "metadata."+k, v)
+		if err != nil {
+			api.logger.Printf("metadata: resolve failed: %v", err)
+			continue
+		}
+		out[k] = resolved
 	}
 	return out
 }
 
-// loadSecret loads a specific secret value from the bundle's env section.
-func (api *MetadataAPI) loadSecret(r *http.Request, name string) (string, error) {
+// loadEnv builds the env bootstrap would deliver now: the bundle env filtered
+// by policy, overlaid with the running job's env overrides.
+func (api *MetadataAPI) loadEnv(r *http.Request, sandbox *models.Sandbox) (map[string]string, error) {
+	var jobEnv map[string]string
+	jobID := ""
+	if job, err := api.store.GetJobBySandboxVMID(r.Context(), sandbox.VMID); err == nil {
+		jobID = job.ID
+		jobEnv = decodeJobEnv(job.EnvJSON)
+	}
+	var bundleEnv map[string]string
+	if api.secretsStore.Dir != "" {
+		bundle, err := api.secretsStore.LoadUnresolved(r.Context(), api.secretsBundle)
+		if err != nil {
+			return nil, err
+		}
+		if len(bundle.Policies) > 0 {
+			bundle.Env = filterPolicyValues(bundle.Env, bundle, secretSubject(*sandbox, jobID))
+		}
+		bundle, err = api.secretsStore.ResolveBundle(r.Context(), secrets.Bundle{Env: bundle.Env})
+		if err != nil {
+			return nil, err
+		}
+		bundleEnv = bundle.Env
+	}
+	env := mergeJobEnv(bundleEnv, jobEnv)
+	if env == nil {
+		env = map[string]string{}
+	}
+	return env, nil
+}
+
+// envETag returns a strong ETag over env. encoding/json sorts map keys, so
+// equal maps always hash the same.
+func envETag(env map[string]string) string {
+	data, _ := json.Marshal(env)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSecret loads a specific secret value from the bundle's env section,
+// falling back to metadata. It also returns the secret's policy, or nil. The
+// value is returned as written; resolve it with resolveSecret once the policy
+// allows the read.
+func (api *MetadataAPI) loadSecret(r *http.Request, name string) (string, *secrets.SecretPolicy, error) {
 	if api.secretsStore.Dir == "" {
-		return "", sql.ErrNoRows
+		return "", nil, sql.ErrNoRows
 	}
-	bundle, err := api.secretsStore.Load(r.Context(), api.secretsBundle)
+	bundle, err := api.secretsStore.LoadUnresolved(r.Context(), api.secretsBundle)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	// Check env section first.
-	if bundle.Env != nil {
-		if v, ok := bundle.Env[name]; ok {
-			return v, nil
-		}
+	value, ok := bundle.Env[name]
+	if !ok {
+		// Check metadata section.
+		value, ok = bundle.Metadata[name]
 	}
-	// Check metadata section.
-	if bundle.Metadata != nil {
-		if v, ok := bundle.Metadata[name]; ok {
-			return v, nil
-		}
+	if !ok {
+		return "", nil, sql.ErrNoRows
+	}
+	if policy, ok := bundle.PolicyFor(name); ok {
+		return value, &policy, nil
 	}
-	return "", sql.ErrNoRows
+	return value, nil, nil
 }
 
 func formatVMID(vmid int) string {
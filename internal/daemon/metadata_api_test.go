@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -328,6 +330,178 @@ metadata:
 	}
 }
 
+func TestMetadataEnvETag(t *testing.T) {
+	store := newTestStore(t)
+	seedSecretTestSandbox(t, store, 3005, "env-sandbox", "10.77.4.31")
+	envSecret := seedSandboxSecret(t, store, 3005)
+
+	secretsDir := t.TempDir()
+	bundlePath := filepath.Join(secretsDir, "default.yaml")
+	writeBundle := func(apiKey string) {
+		t.Helper()
+		bundle := []byte("version: 1\nenv:\n  API_KEY: \"" + apiKey + "\"\n")
+		if err := os.WriteFile(bundlePath, bundle, 0o600); err != nil {
+			t.Fatalf("write bundle: %v", err)
+		}
+	}
+	writeBundle("sk-old")
+	api := NewMetadataAPI(store, secrets.Store{Dir: secretsDir, AllowPlaintext: true}, "default", mustParseCIDR(t, "10.77.0.0/16"), nil, nil)
+
+	fetch := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/metadata/env", nil)
+		req.RemoteAddr = "10.77.4.31:4321"
+		withSandboxSecret(req, envSecret)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp := httptest.NewRecorder()
+		api.handleEnv(resp, req)
+		return resp
+	}
+
+	resp := fetch("")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	etag := resp.Header().Get("ETag")
+	var decoded MetadataEnvResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if decoded.Env["API_KEY"] != "sk-old" || etag == "" || `"`+decoded.Version+`"` != etag {
+		t.Fatalf("env = %+v etag = %q", decoded, etag)
+	}
+
+	if resp := fetch(etag); resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Fatalf("unchanged env: expected empty 304, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	writeBundle("sk-new")
+	resp = fetch(etag)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("rotated env: expected 200, got %d", resp.Code)
+	}
+	if resp.Header().Get("ETag") == etag {
+		t.Fatal("rotated env kept the old ETag")
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if decoded.Env["API_KEY"] != "sk-new" {
+		t.Fatalf("rotated env API_KEY = %q, want sk-new", decoded.Env["API_KEY"])
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metadata/env", nil)
+	req.RemoteAddr = "10.77.4.31:4321"
+	resp = httptest.NewRecorder()
+	api.handleEnv(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("missing sandbox secret: expected 403, got %d", resp.Code)
+	}
+}
+
+func TestMetadataKV(t *testing.T) {
+	store := newTestStore(t)
+	seedSecretTestSandbox(t, store, 3006, "kv-sandbox", "10.77.4.32")
+	kvSecret := seedSandboxSecret(t, store, 3006)
+	api := NewMetadataAPI(store, secrets.Store{}, "default", mustParseCIDR(t, "10.77.0.0/16"), nil, nil)
+	api.writeLimiter = nil
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "10.77.4.32:4321"
+		withSandboxSecret(req, kvSecret)
+		resp := httptest.NewRecorder()
+		api.handleKV(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPut, "/metadata/kv/tests", `{"value":"passing"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("put: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp = do(http.MethodGet, "/metadata/kv/tests", "")
+	var entry MetadataKVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		t.Fatalf("decode entry: %v", err)
+	}
+	if resp.Code != http.StatusOK || entry.Value != "passing" {
+		t.Fatalf("get: status %d entry %+v", resp.Code, entry)
+	}
+	if n := countEvents(t, store, EventKindSandboxKVUpdated); n != 1 {
+		t.Fatalf("sandbox.kv_updated events = %d, want 1", n)
+	}
+
+	if resp := do(http.MethodPut, "/metadata/kv/bad%20key", `{"value":"x"}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("invalid key: expected 400, got %d", resp.Code)
+	}
+	big := `{"value":"` + strings.Repeat("x", metadataKVMaxValueBytes+1) + `"}`
+	if resp := do(http.MethodPut, "/metadata/kv/big", big); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized value: expected 413, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPost, "/metadata/kv/tests", `{"value":"x"}`); resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: expected 405, got %d", resp.Code)
+	}
+
+	for i := 1; i < metadataKVMaxKeys; i++ {
+		if resp := do(http.MethodPut, "/metadata/kv/k"+strconv.Itoa(i), `{"value":"v"}`); resp.Code != http.StatusOK {
+			t.Fatalf("put key %d: expected 200, got %d", i, resp.Code)
+		}
+	}
+	if resp := do(http.MethodPut, "/metadata/kv/one-too-many", `{"value":"v"}`); resp.Code != http.StatusConflict {
+		t.Fatalf("key limit: expected 409, got %d", resp.Code)
+	}
+
+	resp = do(http.MethodGet, "/metadata/kv/", "")
+	var list MetadataKVListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Entries) != metadataKVMaxKeys {
+		t.Fatalf("list has %d entries, want %d", len(list.Entries), metadataKVMaxKeys)
+	}
+
+	if resp := do(http.MethodDelete, "/metadata/kv/tests", ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.Code)
+	}
+	if resp := do(http.MethodGet, "/metadata/kv/tests", ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: expected 404, got %d", resp.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/metadata/kv/tests", strings.NewReader(`{"value":"x"}`))
+	req.RemoteAddr = "10.77.4.32:4321"
+	resp = httptest.NewRecorder()
+	api.handleKV(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("missing sandbox secret: expected 403, got %d", resp.Code)
+	}
+}
+
+func TestMetadataKVWriteRateLimit(t *testing.T) {
+	store := newTestStore(t)
+	seedSecretTestSandbox(t, store, 3007, "kv-limited", "10.77.4.33")
+	kvSecret := seedSandboxSecret(t, store, 3007)
+	api := NewMetadataAPI(store, secrets.Store{}, "default", mustParseCIDR(t, "10.77.0.0/16"), nil, nil)
+	api.writeLimiter = NewIPRateLimiter(1, 1)
+
+	put := func() int {
+		req := httptest.NewRequest(http.MethodPut, "/metadata/kv/status", strings.NewReader(`{"value":"working"}`))
+		req.RemoteAddr = "10.77.4.33:4321"
+		withSandboxSecret(req, kvSecret)
+		resp := httptest.NewRecorder()
+		api.handleKV(resp, req)
+		return resp.Code
+	}
+	if code := put(); code != http.StatusOK {
+		t.Fatalf("first put: expected 200, got %d", code)
+	}
+	if code := put(); code != http.StatusTooManyRequests {
+		t.Fatalf("second put: expected 429, got %d", code)
+	}
+}
+
 func TestMetadataSecrets_NotFound(t *testing.T) {
 	ctx := context.Background()
 	store := newTestStore(t)
@@ -432,7 +606,7 @@ func TestMetadataRegister(t *testing.T) {
 	api.Register(mux)
 
 	// Verify routes are registered by checking the mux handles them.
-	routes := []string{"/metadata/", "/metadata/identity", "/metadata/metadata", "/metadata/secrets/"}
+	routes := []string{"/metadata/", "/metadata/identity", "/metadata/metadata", "/metadata/env", "/metadata/secrets/"}
 	for _, route := range routes {
 		req := httptest.NewRequest(http.MethodGet, route, nil)
 		handler, pattern := mux.Handler(req)
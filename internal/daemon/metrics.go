@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// Metrics collects Prometheus counters and histograms for agentlabd.
+// Metrics collects Prometheus counters, gauges and histograms for agentlabd.
 type Metrics struct {
 	registry                      *prometheus.Registry
 	sandboxTransitionsTotal       *prometheus.CounterVec
@@ -23,6 +24,7 @@ type Metrics struct {
 	sandboxRevertTotal            *prometheus.CounterVec
 	sandboxRevertSeconds          *prometheus.HistogramVec
 	sandboxIdleStopTotal          *prometheus.CounterVec
+	sandboxUsage                  []*prometheus.GaugeVec
 	jobStatusTotal                *prometheus.CounterVec
 	jobDurationSeconds            *prometheus.HistogramVec
 	jobTimeToStartSeconds         prometheus.Histogram
@@ -132,6 +134,29 @@ func NewMetrics() *Metrics {
 		},
 		[]string{"result"},
 	)
+	// Usage gauges are labeled per sandbox and deleted when the sandbox
+	// stops running, so their cardinality tracks running sandboxes.
+	usageLabels := []string{"sandbox", "profile", "owner"}
+	newUsageGauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "agentlab",
+				Subsystem: "sandbox",
+				Name:      name,
+				Help:      help,
+			},
+			usageLabels,
+		)
+	}
+	sandboxUsage := []*prometheus.GaugeVec{
+		usageCPU:        newUsageGauge("cpu_usage_ratio", "Sandbox CPU usage as a fraction of one CPU."),
+		usageMemory:     newUsageGauge("memory_used_bytes", "Sandbox memory in use."),
+		usageMemoryMax:  newUsageGauge("memory_limit_bytes", "Sandbox memory limit."),
+		usageDiskRead:   newUsageGauge("disk_read_bytes_per_second", "Sandbox disk read rate."),
+		usageDiskWrite:  newUsageGauge("disk_write_bytes_per_second", "Sandbox disk write rate."),
+		usageNetReceive: newUsageGauge("network_receive_bytes_per_second", "Sandbox network receive rate."),
+		usageNetSend:    newUsageGauge("network_transmit_bytes_per_second", "Sandbox network transmit rate."),
+	}
 	jobStatusTotal := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "agentlab",
@@ -216,6 +241,9 @@ func NewMetrics() *Metrics {
 		workspaceSnapshotTotal,
 		workspaceSnapshotSeconds,
 	)
+	for _, gauge := range sandboxUsage {
+		registry.MustRegister(gauge)
+	}
 
 	return &Metrics{
 		registry:                      registry,
@@ -229,6 +257,7 @@ func NewMetrics() *Metrics {
 		sandboxRevertTotal:            sandboxRevertTotal,
 		sandboxRevertSeconds:          sandboxRevertSeconds,
 		sandboxIdleStopTotal:          sandboxIdleStopTotal,
+		sandboxUsage:                  sandboxUsage,
 		jobStatusTotal:                jobStatusTotal,
 		jobDurationSeconds:            jobDurationSeconds,
 		jobTimeToStartSeconds:         jobTimeToStartSeconds,
@@ -349,6 +378,49 @@ func (m *Metrics) IncSandboxIdleStop(result string) {
 	m.sandboxIdleStopTotal.WithLabelValues(result).Inc()
 }
 
+// Indexes into Metrics.sandboxUsage.
+const (
+	usageCPU = iota
+	usageMemory
+	usageMemoryMax
+	usageDiskRead
+	usageDiskWrite
+	usageNetReceive
+	usageNetSend
+)
+
+// SetSandboxUsage publishes the latest usage reading for a sandbox.
+func (m *Metrics) SetSandboxUsage(vmid int, profile, owner string, point usagePoint) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{"sandbox": strconv.Itoa(vmid), "profile": profile, "owner": owner}
+	values := map[int]float64{
+		usageCPU:        point.CPU,
+		usageMemory:     float64(point.MemoryBytes),
+		usageMemoryMax:  float64(point.MemoryLimitBytes),
+		usageDiskRead:   point.DiskReadBPS,
+		usageDiskWrite:  point.DiskWriteBPS,
+		usageNetReceive: point.NetInBPS,
+		usageNetSend:    point.NetOutBPS,
+	}
+	for i, value := range values {
+		m.sandboxUsage[i].With(labels).Set(value)
+	}
+}
+
+// DeleteSandboxUsage removes the usage series of a sandbox that is no longer
+// running.
+func (m *Metrics) DeleteSandboxUsage(vmid int) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{"sandbox": strconv.Itoa(vmid)}
+	for _, gauge := range m.sandboxUsage {
+		gauge.DeletePartialMatch(labels)
+	}
+}
+
 func (m *Metrics) ObserveSandboxRevertDuration(result string, duration time.Duration) {
 	if m == nil {
 		return
@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// Notification is an event an operator should act on, such as a question an
+// agent is waiting on. Command is the CLI invocation that acts on it.
+type Notification struct {
+	Kind      string   `json:"kind"`
+	Title     string   `json:"title"`
+	Text      string   `json:"text"`
+	VMID      int      `json:"vmid,omitempty"`
+	JobID     string   `json:"job_id,omitempty"`
+	MessageID int64    `json:"message_id,omitempty"`
+	Options   []string `json:"options,omitempty"`
+	Command   string   `json:"command,omitempty"`
+}
+
+// Notifier posts notifications to a generic JSON webhook, a Slack incoming
+// webhook, or both. Delivery is best-effort and runs in the background, so a
+// slow or failing endpoint never blocks the caller; failures are logged.
+type Notifier struct {
+	webhookURL string
+	slackURL   string
+	client     *http.Client
+	logger     *log.Logger
+	wg         sync.WaitGroup
+}
+
+// NewNotifier returns a notifier for the configured URLs, or nil when neither
+// is set. A nil notifier drops every notification.
+func NewNotifier(webhookURL, slackURL string, logger *log.Logger) *Notifier {
+	webhookURL = strings.TrimSpace(webhookURL)
+	slackURL = strings.TrimSpace(slackURL)
+	if webhookURL == "" && slackURL == "" {
+		return nil
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Notifier{
+		webhookURL: webhookURL,
+		slackURL:   slackURL,
+		client:     &http.Client{Timeout: notifyTimeout},
+		logger:     logger,
+	}
+}
+
+// Notify sends note to every configured endpoint without waiting for delivery.
+func (n *Notifier) Notify(note Notification) {
+	if n == nil {
+		return
+	}
+	if n.webhookURL != "" {
+		n.send(n.webhookURL, note)
+	}
+	if n.slackURL != "" {
+		n.send(n.slackURL, struct {
+			Text string `json:"text"`
+		}{Text: slackText(note)})
+	}
+}
+
+// Wait blocks until every notification sent so far has been delivered or has
+// failed.
+func (n *Notifier) Wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+func (n *Notifier) send(target string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		n.logger.Printf("notify: encode payload: %v", err)
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			n.logger.Printf("notify: build request: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := n.client.Do(req)
+		if err != nil {
+			// The URL may embed a token (Slack's does), so only the
+			// underlying error is logged, never the full URL.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			n.logger.Printf("notify: deliver %s: %v", redactURL(target), err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			n.logger.Printf("notify: deliver %s: status %d", redactURL(target), resp.StatusCode)
+		}
+	}()
+}
+
+// slackText renders a notification as a Slack message.
+func slackText(note Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n%s", note.Title, note.Text)
+	if len(note.Options) > 0 {
+		fmt.Fprintf(&b, "\nOptions: %s", strings.Join(note.Options, ", "))
+	}
+	if note.Command != "" {
+		fmt.Fprintf(&b, "\nReply with: `%s`", note.Command)
+	}
+	return b.String()
+}
+
+// redactURL drops the path and query, which often carry a webhook token.
+func redactURL(raw string) string {
+	if i := strings.Index(raw, "://"); i >= 0 {
+		if j := strings.Index(raw[i+3:], "/"); j >= 0 {
+			return raw[:i+3+j] + "/..."
+		}
+	}
+	return raw
+}
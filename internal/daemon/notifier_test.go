@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNotifierDeliversWebhookAndSlack(t *testing.T) {
+	var mu sync.Mutex
+	bodies := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+		if r.URL.Path == "/slack/T000/SECRET" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	n := NewNotifier(srv.URL+"/hook", srv.URL+"/slack/T000/SECRET", log.New(&logs, "", 0))
+	n.Notify(Notification{
+		Kind:      "question",
+		Title:     "Sandbox 1001 is waiting for an answer",
+		Text:      "Deploy?",
+		MessageID: 7,
+		Options:   []string{"yes", "no"},
+		Command:   "agentlab msg reply 7 yes",
+	})
+	n.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var note Notification
+	if err := json.Unmarshal(bodies["/hook"], &note); err != nil || note.MessageID != 7 || note.Command != "agentlab msg reply 7 yes" {
+		t.Fatalf("webhook payload = %s err = %v", bodies["/hook"], err)
+	}
+	var slack struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(bodies["/slack/T000/SECRET"], &slack); err != nil {
+		t.Fatalf("decode slack payload: %v", err)
+	}
+	for _, want := range []string{"*Sandbox 1001 is waiting for an answer*", "Deploy?", "Options: yes, no", "`agentlab msg reply 7 yes`"} {
+		if !strings.Contains(slack.Text, want) {
+			t.Errorf("slack text %q missing %q", slack.Text, want)
+		}
+	}
+	if !strings.Contains(logs.String(), "status 500") || strings.Contains(logs.String(), "SECRET") {
+		t.Fatalf("failure log = %q, want status without the webhook token", logs.String())
+	}
+}
+
+func TestNewNotifierDisabled(t *testing.T) {
+	n := NewNotifier(" ", "", nil)
+	if n != nil {
+		t.Fatalf("NewNotifier with no URLs = %+v, want nil", n)
+	}
+	n.Notify(Notification{Title: "dropped"})
+	n.Wait()
+}
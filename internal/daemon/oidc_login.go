@@ -0,0 +1,212 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/oidc"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+// OIDCLoginConfig configures single sign-on through an OpenID Connect
+// provider.
+type OIDCLoginConfig struct {
+	Issuer        string
+	ClientID      string
+	UsernameClaim string
+	GroupsClaim   string
+	// AdminGroups grant the admin role. When empty, roles are not synced.
+	AdminGroups []string
+	// TeamGroups maps provider groups to team IDs. When empty, groups map to
+	// teams of the same ID.
+	TeamGroups map[string]string
+}
+
+// OIDCLoginAPI exchanges ID tokens from the identity provider for
+// short-lived AgentLab tokens.
+//
+// Its routes live outside /v1 and need no AgentLab credential: the ID token
+// is the credential. Clients (agentlab-dashboard and agentlab connect --oidc)
+// run the provider's login flow themselves and post the resulting ID token
+// here. The daemon verifies it, maps the identity and groups to a registered
+// user, role and teams, and signs a token that acts for that user.
+type OIDCLoginAPI struct {
+	cfg    OIDCLoginConfig
+	users  *user.Registry
+	issuer *auth.Issuer
+
+	mu       sync.Mutex
+	verifier *oidc.Verifier
+}
+
+// NewOIDCLoginAPI creates the single sign-on API. The provider is discovered
+// on first use, so a provider outage does not keep the daemon from starting.
+func NewOIDCLoginAPI(cfg OIDCLoginConfig, users *user.Registry, issuer *auth.Issuer) *OIDCLoginAPI {
+	cfg.Issuer = strings.TrimRight(strings.TrimSpace(cfg.Issuer), "/")
+	return &OIDCLoginAPI{cfg: cfg, users: users, issuer: issuer}
+}
+
+// Register registers the single sign-on endpoints on the given mux.
+func (api *OIDCLoginAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/auth/oidc/config", api.handleConfig)
+	mux.HandleFunc("/auth/oidc/token", api.handleToken)
+}
+
+// V1OIDCConfigResponse tells clients which provider and client to sign in
+// with.
+type V1OIDCConfigResponse struct {
+	Issuer   string   `json:"issuer"`
+	ClientID string   `json:"client_id"`
+	Scopes   []string `json:"scopes"`
+}
+
+// V1OIDCTokenRequest exchanges an ID token for an AgentLab token. Nonce,
+// when set, must match the ID token's nonce claim.
+type V1OIDCTokenRequest struct {
+	IDToken string `json:"id_token"`
+	Nonce   string `json:"nonce,omitempty"`
+}
+
+// V1OIDCTokenResponse carries an AgentLab token for the signed-in user.
+type V1OIDCTokenResponse struct {
+	Token     string   `json:"token"`
+	ExpiresAt string   `json:"expires_at"`
+	UserID    string   `json:"user_id"`
+	User      string   `json:"user"`
+	Role      string   `json:"role"`
+	Teams     []string `json:"teams"`
+}
+
+func (api *OIDCLoginAPI) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	writeJSON(w, http.StatusOK, V1OIDCConfigResponse{
+		Issuer:   api.cfg.Issuer,
+		ClientID: api.cfg.ClientID,
+		Scopes:   oidc.DefaultScopes,
+	})
+}
+
+func (api *OIDCLoginAPI) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{http.MethodPost})
+		return
+	}
+	var req V1OIDCTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.IDToken) == "" {
+		writeError(w, http.StatusBadRequest, "id_token is required")
+		return
+	}
+	verifier, err := api.getVerifier(r.Context())
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "identity provider unavailable")
+		return
+	}
+	claims, err := verifier.Verify(r.Context(), strings.TrimSpace(req.IDToken), req.Nonce)
+	switch {
+	case errors.Is(err, oidc.ErrIDTokenExpired):
+		writeError(w, http.StatusUnauthorized, "id token expired")
+		return
+	case errors.Is(err, oidc.ErrInvalidIDToken):
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	case err != nil:
+		log.Printf("oidc: verify id token: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "identity provider unavailable")
+		return
+	}
+
+	u, teams, err := api.users.LoginExternal(r.Context(), api.loginFor(claims))
+	switch {
+	case errors.Is(err, user.ErrIdentityNotLinked):
+		writeError(w, http.StatusForbidden, err.Error()+"; an admin must link it with agentlab user identity add")
+		return
+	case errors.Is(err, user.ErrUnusableUsername):
+		writeError(w, http.StatusForbidden, "id token "+api.cfg.UsernameClaim+" claim "+strings.TrimPrefix(err.Error(), user.ErrUnusableUsername.Error()+": "))
+		return
+	case err != nil:
+		log.Printf("oidc: login %s: %v", claims.Subject, err)
+		writeError(w, http.StatusInternalServerError, "failed to sign in")
+		return
+	}
+	token, expires, err := api.issuer.Issue(u.ID, "oidc:"+u.Name)
+	if err != nil {
+		log.Printf("oidc: issue token for %s: %v", u.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+	if teams == nil {
+		teams = []string{}
+	}
+	writeJSON(w, http.StatusOK, V1OIDCTokenResponse{
+		Token:     token,
+		ExpiresAt: expires.UTC().Format(time.RFC3339),
+		UserID:    u.ID,
+		User:      u.Name,
+		Role:      string(u.Role),
+		Teams:     teams,
+	})
+}
+
+// getVerifier discovers the provider on first use. A failed discovery is
+// retried on the next login.
+func (api *OIDCLoginAPI) getVerifier(ctx context.Context) (*oidc.Verifier, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.verifier != nil {
+		return api.verifier, nil
+	}
+	provider, err := oidc.Discover(ctx, api.cfg.Issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	api.verifier = oidc.NewVerifier(provider, api.cfg.ClientID)
+	return api.verifier, nil
+}
+
+// loginFor maps verified claims to the user, role and teams they stand for.
+func (api *OIDCLoginAPI) loginFor(claims *oidc.Claims) user.ExternalLogin {
+	groups := claims.Strings(api.cfg.GroupsClaim)
+	login := user.ExternalLogin{
+		Issuer:  api.cfg.Issuer,
+		Subject: claims.Subject,
+		Name:    claims.String(api.cfg.UsernameClaim),
+	}
+	if len(api.cfg.AdminGroups) > 0 {
+		login.Role = user.RoleUser
+		for _, group := range groups {
+			if slices.Contains(api.cfg.AdminGroups, group) {
+				login.Role = user.RoleAdmin
+				break
+			}
+		}
+	}
+	if len(api.cfg.TeamGroups) == 0 {
+		login.Teams = groups
+		return login
+	}
+	login.ManagedTeams = []string{}
+	for group, team := range api.cfg.TeamGroups {
+		if !slices.Contains(login.ManagedTeams, team) {
+			login.ManagedTeams = append(login.ManagedTeams, team)
+		}
+		if slices.Contains(groups, group) && !slices.Contains(login.Teams, team) {
+			login.Teams = append(login.Teams, team)
+		}
+	}
+	return login
+}
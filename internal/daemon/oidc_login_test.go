@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/oidc"
+	"github.com/agentlab/agentlab/internal/oidc/oidctest"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+func TestOIDCLoginProvisionsUsersAndSyncsGroups(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	_, err := users.CreateTeam(ctx, "infra", "", "")
+	require.NoError(t, err)
+	_, err = users.AddUser(ctx, "bob", reportTestKey(t), user.RoleUser)
+	require.NoError(t, err)
+
+	provider := oidctest.New(t)
+	issuer, err := auth.LoadOrCreateIssuer(filepath.Join(t.TempDir(), "issuer.key"), time.Hour)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	NewOIDCLoginAPI(OIDCLoginConfig{
+		Issuer:        provider.URL,
+		ClientID:      oidctest.ClientID,
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		AdminGroups:   []string{"platform-admins"},
+	}, users, issuer).Register(mux)
+
+	login := func(idToken string) (int, V1OIDCTokenResponse) {
+		t.Helper()
+		body, _ := json.Marshal(V1OIDCTokenRequest{IDToken: idToken})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/oidc/token", bytes.NewReader(body)))
+		var resp V1OIDCTokenResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/config", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"client_id":"agentlab"`)
+
+	provider.SetUser("alice-sub", "alice", []string{"infra", "no-such-team"})
+	code, resp := login(provider.IDToken("", time.Minute))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "alice", resp.User)
+	assert.Equal(t, "user", resp.Role)
+	assert.Equal(t, []string{"infra"}, resp.Teams)
+	aliceToken := resp.Token
+
+	provider.SetUser("alice-sub", "alice", []string{"platform-admins"})
+	code, resp = login(provider.IDToken("", time.Minute))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "admin", resp.Role, "admin group grants the admin role")
+	assert.Empty(t, resp.Teams, "leaving the group leaves the team")
+
+	code, _ = login(provider.IDToken("", -5*time.Minute))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = login("not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// An existing user is never claimed by username alone.
+	provider.SetUser("bob-sub", "bob", nil)
+	code, _ = login(provider.IDToken("", time.Minute))
+	assert.Equal(t, http.StatusForbidden, code)
+	bob, err := users.Store().GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+	_, err = users.LinkIdentity(ctx, bob.ID, provider.URL, "bob-sub", "")
+	require.NoError(t, err)
+	code, resp = login(provider.IDToken("", time.Minute))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "bob", resp.User)
+	bobToken := resp.Token
+
+	// Issued tokens act for their user and die with them.
+	authMw, err := auth.NewMiddlewareWithStore(nil, "", nil)
+	require.NoError(t, err)
+	authMw.WithIssuer(issuer)
+	var caller string
+	handler := authMw.WrapNetwork(NewRBAC(users, store, true).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = callerUserID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})))
+	call := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/sandboxes", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	require.Equal(t, http.StatusOK, call(aliceToken))
+	assert.Equal(t, "alice", caller)
+	require.Equal(t, http.StatusOK, call(bobToken))
+	assert.Equal(t, bob.ID, caller)
+	require.NoError(t, users.RemoveUser(ctx, "", bob.ID))
+	assert.Equal(t, http.StatusUnauthorized, call(bobToken))
+}
+
+func TestOIDCLoginTeamGroupMapping(t *testing.T) {
+	api := NewOIDCLoginAPI(OIDCLoginConfig{
+		Issuer:      "https://idp.example.com/",
+		GroupsClaim: "groups",
+		TeamGroups:  map[string]string{"eng-infra": "infra", "eng-ml": "ml"},
+	}, nil, nil)
+	provider := oidctest.New(t)
+	provider.SetUser("sub", "carol", []string{"eng-infra", "marketing"})
+	claims := verifyTestClaims(t, provider)
+
+	login := api.loginFor(claims)
+	assert.Equal(t, "https://idp.example.com", login.Issuer)
+	assert.Equal(t, user.Role(""), login.Role, "roles are not synced without admin groups")
+	assert.Equal(t, []string{"infra"}, login.Teams)
+	assert.ElementsMatch(t, []string{"infra", "ml"}, login.ManagedTeams)
+}
+
+func verifyTestClaims(t *testing.T, provider *oidctest.Provider) *oidc.Claims {
+	t.Helper()
+	api := NewOIDCLoginAPI(OIDCLoginConfig{Issuer: provider.URL, ClientID: oidctest.ClientID}, nil, nil)
+	verifier, err := api.getVerifier(context.Background())
+	require.NoError(t, err)
+	claims, err := verifier.Verify(context.Background(), provider.IDToken("", time.Minute), "")
+	require.NoError(t, err)
+	return claims
+}
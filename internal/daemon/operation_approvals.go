@@ -0,0 +1,434 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+// pendingOperationHeader names the operation a held request became, so
+// clients can tell a 202 from the approval gate from any other 202.
+const pendingOperationHeader = "X-AgentLab-Pending-Operation"
+
+// maxOperationResultBytes bounds the replayed response kept with an
+// operation.
+const maxOperationResultBytes = 64 << 10
+
+var (
+	errOperationNotFound       = errors.New("operation not found")
+	errApproverUnregistered    = errors.New("only registered users can approve operations")
+	errApproverIsRequester     = errors.New("the requester cannot approve their own operation")
+	errApproverLacksRole       = errors.New("approver does not hold the required role")
+	errOperationRejectDenied   = errors.New("only the requester or an approver can reject an operation")
+	errRequesterNoLongerExists = errors.New("requester is no longer registered")
+)
+
+// OperationApprovals holds privileged requests until other users approve
+// them (four eyes).
+//
+// The approval policy marks permissions from the catalog as needing approval.
+// A change (any method but GET and HEAD) by a registered user that passes
+// authorization for such a permission is not run: authorizeTarget and
+// authorizeChecked park it as a pending operation, with its method, path and
+// body, and answer 202. Once enough other users holding the rule's approver
+// role approve it, the daemon replays the request through the control mux as
+// the requester, whose roles are checked again, and keeps the response.
+// Trusted callers (the local socket, the legacy bearer token) and keys with no
+// user record are never held. Every request, approval, rejection, expiry and
+// execution is written to the audit log.
+type OperationApprovals struct {
+	store   *db.Store
+	users   *user.Registry
+	rbac    *RBAC
+	logger  *log.Logger
+	now     func() time.Time
+	policy  atomic.Pointer[ApprovalPolicy]
+	handler atomic.Pointer[http.Handler]
+}
+
+// NewOperationApprovals returns the approval gate for policy.
+func NewOperationApprovals(store *db.Store, users *user.Registry, rbac *RBAC, policy ApprovalPolicy, logger *log.Logger) *OperationApprovals {
+	if logger == nil {
+		logger = log.Default()
+	}
+	a := &OperationApprovals{store: store, users: users, rbac: rbac, logger: logger, now: time.Now}
+	a.SetPolicy(policy)
+	return a
+}
+
+// SetPolicy replaces the approval policy. Operations already held keep the
+// rule they were held under.
+func (a *OperationApprovals) SetPolicy(policy ApprovalPolicy) {
+	if a == nil {
+		return
+	}
+	a.policy.Store(&policy)
+}
+
+// WithHandler sets the handler approved operations are replayed through,
+// the control mux inside authentication.
+func (a *OperationApprovals) WithHandler(h http.Handler) *OperationApprovals {
+	a.handler.Store(&h)
+	return a
+}
+
+type operationApprovalsKey struct{}
+
+// Wrap exposes the gate to authorization for requests from registered users.
+// It must run inside RBAC.Wrap, which resolves the caller. Replays skip it
+// and clear the gate, so an approved operation is never held again.
+func (a *OperationApprovals) Wrap(next http.Handler) http.Handler {
+	if a == nil || next == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy := a.policy.Load(); policy != nil && len(policy.Rules) > 0 && callerUserID(r.Context()) != "" {
+			r = r.WithContext(context.WithValue(r.Context(), operationApprovalsKey{}, a))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// holdForApproval parks the request as a pending operation when the approval
+// policy covers perm. It reports whether it did; the 202 response is then
+// written and the handler must return without acting.
+func holdForApproval(w http.ResponseWriter, r *http.Request, perm string) bool {
+	a, ok := r.Context().Value(operationApprovalsKey{}).(*OperationApprovals)
+	if !ok || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+	rule, ok := a.policy.Load().ruleFor(perm)
+	if !ok {
+		return false
+	}
+	op, err := a.hold(r, perm, rule)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONDecodeError(w, err)
+			return true
+		}
+		a.logger.Printf("approvals: hold %s %s: %v", r.Method, r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, "failed to hold operation for approval")
+		return true
+	}
+	w.Header().Set(pendingOperationHeader, op.ID)
+	writeJSON(w, http.StatusAccepted, pendingOperationToV1(op))
+	return true
+}
+
+func (a *OperationApprovals) hold(r *http.Request, perm string, rule ApprovalRule) (db.PendingOperation, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, maxJSONBytes))
+		if err != nil {
+			return db.PendingOperation{}, err
+		}
+	}
+	id, err := newOperationID()
+	if err != nil {
+		return db.PendingOperation{}, err
+	}
+	now := a.now().UTC()
+	path := r.URL.Path
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	op := db.PendingOperation{
+		ID:                id,
+		Permission:        perm,
+		Method:            r.Method,
+		Path:              path,
+		Body:              string(body),
+		RequestedBy:       callerUserID(r.Context()),
+		ApproverRole:      rule.ApproverRole,
+		RequiredApprovals: rule.Approvals,
+		Status:            db.OperationPending,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(rule.ExpiresAfter),
+	}
+	if err := a.store.CreatePendingOperation(r.Context(), op); err != nil {
+		return db.PendingOperation{}, err
+	}
+	a.audit(r.Context(), op.RequestedBy, "operation.request", op,
+		fmt.Sprintf("approvals=%d approver_role=%s", op.RequiredApprovals, op.ApproverRole))
+	return op, nil
+}
+
+// Get returns an operation, expiring it first if its time is up.
+func (a *OperationApprovals) Get(ctx context.Context, id string) (db.PendingOperation, error) {
+	op, err := a.store.GetPendingOperation(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.PendingOperation{}, errOperationNotFound
+	}
+	if err != nil {
+		return db.PendingOperation{}, err
+	}
+	return a.expireIfDue(ctx, op), nil
+}
+
+// List returns operations newest first, optionally filtered by status.
+// Pending operations past their expiry are expired first.
+func (a *OperationApprovals) List(ctx context.Context, status string, limit int) ([]db.PendingOperation, error) {
+	pending, err := a.store.ListPendingOperations(ctx, db.OperationPending, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range pending {
+		a.expireIfDue(ctx, op)
+	}
+	return a.store.ListPendingOperations(ctx, status, limit)
+}
+
+// Approve records approverID's approval. The approval that reaches the
+// rule's count runs the operation before Approve returns.
+func (a *OperationApprovals) Approve(ctx context.Context, id, approverID string) (db.PendingOperation, error) {
+	op, err := a.Get(ctx, id)
+	if err != nil {
+		return db.PendingOperation{}, err
+	}
+	if op.Status != db.OperationPending {
+		return op, db.ErrOperationNotPending
+	}
+	switch {
+	case approverID == "":
+		return op, errApproverUnregistered
+	case approverID == op.RequestedBy:
+		return op, errApproverIsRequester
+	}
+	ok, err := a.holdsRole(ctx, approverID, op.ApproverRole)
+	if err != nil {
+		return op, err
+	}
+	if !ok {
+		return op, fmt.Errorf("%w %s", errApproverLacksRole, op.ApproverRole)
+	}
+	op, err = a.store.ApprovePendingOperation(ctx, id, approverID, a.now().UTC())
+	if err != nil {
+		return op, err
+	}
+	a.audit(ctx, approverID, "operation.approve", op, fmt.Sprintf("approvals=%d/%d", len(op.Approvals), op.RequiredApprovals))
+	if len(op.Approvals) < op.RequiredApprovals {
+		return op, nil
+	}
+	op, err = a.store.TransitionPendingOperation(ctx, id, db.OperationExecuting, approverID, "", a.now().UTC())
+	if errors.Is(err, db.ErrOperationNotPending) {
+		// A concurrent approval reached the count first and runs it.
+		return op, nil
+	}
+	if err != nil {
+		return op, err
+	}
+	return a.execute(ctx, op)
+}
+
+// Reject ends a pending operation without running it. The requester may
+// withdraw it, any user who could approve it may refuse it, and trusted
+// callers (userID "") may reject anything.
+func (a *OperationApprovals) Reject(ctx context.Context, id, userID, reason string) (db.PendingOperation, error) {
+	op, err := a.Get(ctx, id)
+	if err != nil {
+		return db.PendingOperation{}, err
+	}
+	if op.Status != db.OperationPending {
+		return op, db.ErrOperationNotPending
+	}
+	if userID != "" && userID != op.RequestedBy {
+		ok, err := a.holdsRole(ctx, userID, op.ApproverRole)
+		if err != nil {
+			return op, err
+		}
+		if !ok {
+			return op, errOperationRejectDenied
+		}
+	}
+	decidedBy := userID
+	if decidedBy == "" {
+		decidedBy = "local"
+	}
+	op, err = a.store.TransitionPendingOperation(ctx, id, db.OperationRejected, decidedBy, reason, a.now().UTC())
+	if err != nil {
+		return op, err
+	}
+	detail := ""
+	if reason != "" {
+		detail = "reason=" + reason
+	}
+	a.audit(ctx, decidedBy, "operation.reject", op, detail)
+	return op, nil
+}
+
+// execute replays an approved operation as its requester and records the
+// response.
+func (a *OperationApprovals) execute(ctx context.Context, op db.PendingOperation) (db.PendingOperation, error) {
+	// The approver's connection may drop; the operation still runs to the
+	// end, like one submitted directly.
+	ctx = context.WithoutCancel(ctx)
+	status, body := a.replay(ctx, op)
+	outcome := db.OperationExecuted
+	if status < 200 || status >= 300 {
+		outcome = db.OperationFailed
+	}
+	finished, err := a.store.FinishPendingOperation(ctx, op.ID, outcome, status, body)
+	if err != nil {
+		return op, err
+	}
+	a.audit(ctx, op.RequestedBy, "operation.execute", finished, fmt.Sprintf("status=%d result=%s", status, outcome))
+	return finished, nil
+}
+
+func (a *OperationApprovals) replay(ctx context.Context, op db.PendingOperation) (int, string) {
+	handler := a.handler.Load()
+	if handler == nil {
+		return http.StatusServiceUnavailable, `{"error":"operation replay unavailable"}`
+	}
+	policy, err := a.rbac.policyForUser(ctx, op.RequestedBy)
+	if err != nil {
+		a.logger.Printf("approvals: resolve requester of %s: %v", op.ID, err)
+		return http.StatusServiceUnavailable, `{"error":"authorization unavailable"}`
+	}
+	if policy == nil {
+		return http.StatusForbidden, fmt.Sprintf(`{"error":%q}`, errRequesterNoLongerExists.Error())
+	}
+	// The replay acts for the requester: their roles confine it again, but
+	// the token they filed it with was checked when it was held. The gate is
+	// cleared so the approved request is not held a second time.
+	ctx = context.WithValue(ctx, operationApprovalsKey{}, nil)
+	ctx = context.WithValue(ctx, rbacPolicyKey{}, policy)
+	ctx = auth.WithIdentity(ctx, &auth.RequestIdentity{
+		Fingerprint: "approval",
+		Subject:     "operation:" + op.ID,
+		UserID:      op.RequestedBy,
+		Method:      "approved-operation",
+	})
+	req, err := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader([]byte(op.Body)))
+	if err != nil {
+		return http.StatusBadRequest, fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	if op.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := &operationRecorder{header: http.Header{}}
+	(*handler).ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.status, rec.body.String()
+}
+
+// expireIfDue expires a pending operation past its expiry and returns its
+// current state.
+func (a *OperationApprovals) expireIfDue(ctx context.Context, op db.PendingOperation) db.PendingOperation {
+	now := a.now().UTC()
+	if op.Status != db.OperationPending || now.Before(op.ExpiresAt) {
+		return op
+	}
+	expired, err := a.store.TransitionPendingOperation(ctx, op.ID, db.OperationExpired, "", "", now)
+	if err != nil {
+		if !errors.Is(err, db.ErrOperationNotPending) {
+			a.logger.Printf("approvals: expire %s: %v", op.ID, err)
+		}
+		if expired.ID != "" {
+			return expired
+		}
+		return op
+	}
+	a.audit(ctx, "", "operation.expire", expired, "")
+	return expired
+}
+
+// holdsRole reports whether userID holds role: the admin or user role, or a
+// custom role bound to them directly or through a team.
+func (a *OperationApprovals) holdsRole(ctx context.Context, userID, role string) (bool, error) {
+	if a.users == nil {
+		return false, errors.New("user registry unavailable")
+	}
+	u, err := a.users.Store().GetUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if builtinApproverRole(role) {
+		return role == string(user.RoleUser) || u.Role == user.RoleAdmin, nil
+	}
+	grants, err := a.users.Grants(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range grants {
+		if g.Role == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *OperationApprovals) audit(ctx context.Context, userID, action string, op db.PendingOperation, extra string) {
+	detail := fmt.Sprintf("permission=%s request=%q", op.Permission, op.Method+" "+op.Path)
+	if extra != "" {
+		detail += " " + extra
+	}
+	if err := a.store.InsertAuditRecord(ctx, db.AuditRecord{
+		UserID:    userID,
+		Action:    action,
+		Resource:  "operation:" + op.ID,
+		Detail:    detail,
+		Timestamp: a.now().UTC(),
+	}); err != nil {
+		a.logger.Printf("approvals: audit %s for %s: %v", action, op.ID, err)
+	}
+}
+
+// operationRecorder captures a replayed response, keeping at most
+// maxOperationResultBytes of the body.
+type operationRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *operationRecorder) Header() http.Header { return rec.header }
+
+func (rec *operationRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *operationRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if room := maxOperationResultBytes - rec.body.Len(); room > 0 {
+		if len(p) > room {
+			rec.body.Write(p[:room])
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func newOperationID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "op_" + hex.EncodeToString(buf), nil
+}
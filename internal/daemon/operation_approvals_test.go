@@ -0,0 +1,219 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+func TestOperationApprovalsHoldAndReplay(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	// The first registered user always becomes admin, so register an admin
+	// first.
+	for _, u := range []struct {
+		name string
+		role user.Role
+	}{{"alice", user.RoleAdmin}, {"carol", user.RoleAdmin}, {"bob", user.RoleUser}, {"dave", user.RoleUser}} {
+		_, err := users.AddUser(ctx, u.name, reportTestKey(t), u.role)
+		require.NoError(t, err)
+	}
+	rbac := NewRBAC(users, store, false)
+	policy, err := parseApprovalPolicy([]byte("rules:\n  - permissions: [sandbox.bulk]\n    approvals: 2\n    approver_role: admin\n"))
+	require.NoError(t, err)
+	approvals := NewOperationApprovals(store, users, rbac, policy, nil)
+
+	// The gated route records every run with its body and caller.
+	var runs []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/sandboxes/prune", func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeStandalone(w, r, permSandboxBulk, true) {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		runs = append(runs, callerUserID(r.Context())+" "+string(body))
+		writeJSON(w, http.StatusOK, map[string]int{"count": 3})
+	})
+	NewApprovalAPI(approvals.WithHandler(mux)).Register(mux)
+
+	issuer, err := auth.LoadOrCreateIssuer(filepath.Join(t.TempDir(), "issuer.key"), time.Hour)
+	require.NoError(t, err)
+	authMw, err := auth.NewMiddlewareWithStore(nil, "", nil)
+	require.NoError(t, err)
+	authMw.WithIssuer(issuer)
+	handler := authMw.WrapNetwork(rbac.Wrap(approvals.Wrap(mux)))
+
+	call := func(userID, method, path, body string) (*httptest.ResponseRecorder, V1PendingOperation) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if userID != "" {
+			token, _, err := issuer.Issue(userID, "oidc:"+userID)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		if userID != "" {
+			handler.ServeHTTP(rec, req)
+		} else {
+			mux.ServeHTTP(rec, req) // the trusted local socket
+		}
+		var op V1PendingOperation
+		_ = json.Unmarshal(rec.Body.Bytes(), &op)
+		return rec, op
+	}
+
+	rec, op := call("bob", http.MethodPost, "/v1/sandboxes/prune", `{"dry_run":false}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, op.ID, rec.Header().Get(pendingOperationHeader))
+	assert.Equal(t, db.OperationPending, op.Status)
+	assert.Equal(t, "bob", op.RequestedBy)
+	assert.JSONEq(t, `{"dry_run":false}`, string(op.Body))
+	assert.Empty(t, runs, "a held request does not run")
+
+	rec, _ = call("bob", http.MethodPost, "/v1/approvals/"+op.ID+"/approve", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "requesters cannot approve")
+	rec, _ = call("dave", http.MethodPost, "/v1/approvals/"+op.ID+"/approve", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "approvers need the role")
+
+	rec, got := call("alice", http.MethodPost, "/v1/approvals/"+op.ID+"/approve", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, db.OperationPending, got.Status)
+	require.Len(t, got.Approvals, 1)
+	rec, _ = call("alice", http.MethodPost, "/v1/approvals/"+op.ID+"/approve", "")
+	assert.Equal(t, http.StatusConflict, rec.Code, "one approval per user")
+	assert.Empty(t, runs)
+
+	rec, got = call("carol", http.MethodPost, "/v1/approvals/"+op.ID+"/approve", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, db.OperationExecuted, got.Status)
+	assert.Equal(t, http.StatusOK, got.ResultStatus)
+	assert.JSONEq(t, `{"count":3}`, string(got.Result))
+	assert.Equal(t, []string{`bob {"dry_run":false}`}, runs, "replayed once, as the requester")
+	rec, _ = call("alice", http.MethodPost, "/v1/approvals/"+op.ID+"/approve", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Trusted callers and reads are never held.
+	rec, _ = call("", http.MethodPost, "/v1/sandboxes/prune", `{}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, runs, 2)
+
+	// Rejected and expired operations never run.
+	_, rejected := call("bob", http.MethodPost, "/v1/sandboxes/prune", `{}`)
+	rec, _ = call("dave", http.MethodPost, "/v1/approvals/"+rejected.ID+"/reject", `{"reason":"no"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "only the requester or an approver may reject")
+	rec, got = call("carol", http.MethodPost, "/v1/approvals/"+rejected.ID+"/reject", `{"reason":"not during the release"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, db.OperationRejected, got.Status)
+	assert.Equal(t, "not during the release", got.Reason)
+
+	_, expired := call("bob", http.MethodPost, "/v1/sandboxes/prune", `{}`)
+	approvals.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	rec, _ = call("alice", http.MethodGet, "/v1/approvals", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"operations":[]}`, rec.Body.String())
+	rec, got = call("alice", http.MethodGet, "/v1/approvals/"+expired.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, db.OperationExpired, got.Status)
+	approvals.now = time.Now
+	assert.Len(t, runs, 2)
+
+	records, err := store.ListAuditRecordsSince(ctx, time.Time{}, 0, 100)
+	require.NoError(t, err)
+	var actions []string
+	for _, record := range records {
+		if record.Resource == "operation:"+op.ID {
+			actions = append(actions, record.UserID+" "+record.Action)
+		}
+	}
+	assert.Equal(t, []string{"bob operation.request", "alice operation.approve", "carol operation.approve", "bob operation.execute"}, actions)
+}
+
+func TestOperationApprovalsReplayChecksRequesterAgain(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	_, err := users.AddUser(ctx, "alice", reportTestKey(t), user.RoleAdmin)
+	require.NoError(t, err)
+	_, err = users.AddUser(ctx, "bob", reportTestKey(t), user.RoleUser)
+	require.NoError(t, err)
+	_, err = users.CreateRole(ctx, user.CustomRole{Name: "release-manager", Permissions: []string{"approval"}}, "")
+	require.NoError(t, err)
+	_, err = users.BindRole(ctx, user.RoleBinding{Role: "release-manager", SubjectType: user.SubjectUser, SubjectID: "alice", ScopeType: user.ScopeGlobal}, "")
+	require.NoError(t, err)
+
+	policy, err := parseApprovalPolicy([]byte("rules:\n  - permissions: [workspace]\n    approver_role: release-manager\n"))
+	require.NoError(t, err)
+	approvals := NewOperationApprovals(store, users, NewRBAC(users, store, false), policy, nil)
+	approvals.WithHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"caller": callerUserID(r.Context())})
+	}))
+
+	op, err := approvals.hold(httptest.NewRequest(http.MethodPost, "/v1/workspaces/ws-1/rebind", bytes.NewReader(nil)).
+		WithContext(context.WithValue(ctx, rbacPolicyKey{}, &rbacPolicy{userID: "bob"})), permWorkspaceRebind, policy.Rules[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, op.RequiredApprovals)
+
+	// The requester leaves before the approval: the operation fails instead
+	// of running as nobody.
+	require.NoError(t, users.RemoveUser(ctx, "", "bob"))
+	got, err := approvals.Approve(ctx, op.ID, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, db.OperationFailed, got.Status)
+	assert.Equal(t, http.StatusForbidden, got.ResultStatus)
+	assert.Contains(t, got.ResultBody, "no longer registered")
+}
+
+func TestParseApprovalPolicy(t *testing.T) {
+	policy, err := parseApprovalPolicy([]byte(`
+rules:
+  - permissions: [sandbox.bulk, workspace.lease]
+    approver_role: admin
+  - permissions: [exposure.create]
+    approvals: 2
+    approver_role: operator
+    expires_after: 4h
+`))
+	require.NoError(t, err)
+	require.Len(t, policy.Rules, 2)
+	assert.Equal(t, 1, policy.Rules[0].Approvals)
+	assert.Equal(t, defaultOperationExpiry, policy.Rules[0].ExpiresAfter)
+	assert.Equal(t, 4*time.Hour, policy.Rules[1].ExpiresAfter)
+	rule, ok := policy.ruleFor(permWorkspaceLease)
+	require.True(t, ok)
+	assert.Equal(t, "admin", rule.ApproverRole)
+	_, ok = policy.ruleFor(permWorkspaceRebind)
+	assert.False(t, ok)
+
+	empty, err := parseApprovalPolicy(nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Rules)
+
+	for name, raw := range map[string]string{
+		"wildcard":       "rules:\n  - permissions: ['*']\n    approver_role: admin\n",
+		"self-approval":  "rules:\n  - permissions: [approval.decide]\n    approver_role: admin\n",
+		"unknown":        "rules:\n  - permissions: [sandbox.launch]\n    approver_role: admin\n",
+		"no role":        "rules:\n  - permissions: [sandbox.bulk]\n",
+		"negative":       "rules:\n  - permissions: [sandbox.bulk]\n    approvals: -1\n    approver_role: admin\n",
+		"long expiry":    "rules:\n  - permissions: [sandbox.bulk]\n    approver_role: admin\n    expires_after: 720h\n",
+		"unknown field":  "rules:\n  - permission: sandbox.bulk\n    approver_role: admin\n",
+		"no permissions": "rules:\n  - approver_role: admin\n",
+	} {
+		_, err := parseApprovalPolicy([]byte(raw))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"sync/atomic"
+
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+// ProfileRegistry holds the daemon's current profile set and lets a config
+// reload publish a new one to every consumer at once.
+//
+// Readers always see a complete set: Replace swaps the whole map atomically
+// and never mutates a published map, so callers may keep and range over the
+// result of Snapshot without locking. A nil registry, like a nil map, means no
+// profiles are configured.
+type ProfileRegistry struct {
+	current atomic.Pointer[map[string]models.Profile]
+}
+
+// NewProfileRegistry creates a registry publishing profiles.
+func NewProfileRegistry(profiles map[string]models.Profile) *ProfileRegistry {
+	reg := &ProfileRegistry{}
+	reg.Replace(profiles)
+	return reg
+}
+
+// Get returns the named profile from the current set.
+func (r *ProfileRegistry) Get(name string) (models.Profile, bool) {
+	profiles := r.Snapshot()
+	if profiles == nil {
+		return models.Profile{}, false
+	}
+	profile, ok := profiles[name]
+	return profile, ok
+}
+
+// Snapshot returns the current profile set. The map must not be modified.
+func (r *ProfileRegistry) Snapshot() map[string]models.Profile {
+	if r == nil {
+		return nil
+	}
+	current := r.current.Load()
+	if current == nil {
+		return nil
+	}
+	return *current
+}
+
+// Replace publishes a new profile set. The registry takes ownership of
+// profiles; callers must not modify it afterwards.
+func (r *ProfileRegistry) Replace(profiles map[string]models.Profile) {
+	if r == nil {
+		return
+	}
+	r.current.Store(&profiles)
+}
@@ -17,8 +17,9 @@ import (
 type profileSpec struct {
 	Name       string `yaml:"name"`
 	TemplateVM int    `yaml:"template_vmid"`
-	Type       string `yaml:"type"`  // "vm" (default) or "lxc"
-	Image      string `yaml:"image"` // Container image for LXC (e.g., "ubuntu:22.04")
+	Template   string `yaml:"template"` // Built template as name or name@version
+	Type       string `yaml:"type"`     // "vm" (default) or "lxc"
+	Image      string `yaml:"image"`    // Container image for LXC (e.g., "ubuntu:22.04")
 }
 
 // LoadProfiles reads profile YAML files from dir.
@@ -83,8 +84,16 @@ func LoadProfiles(dir string) (map[string]models.Profile, error) {
 					return nil, fmt.Errorf("profile %s (document %d) of type 'lxc' requires 'image' field", path, docIndex)
 				}
 			} else {
-				if spec.TemplateVM <= 0 {
-					return nil, fmt.Errorf("profile %s (document %d) missing template_vmid", path, docIndex)
+				spec.Template = strings.TrimSpace(spec.Template)
+				switch {
+				case spec.Template != "" && spec.TemplateVM > 0:
+					return nil, fmt.Errorf("profile %s (document %d) sets both template and template_vmid", path, docIndex)
+				case spec.Template != "":
+					if _, _, err := parseTemplateRef(spec.Template); err != nil {
+						return nil, fmt.Errorf("profile %s (document %d): %w", path, docIndex, err)
+					}
+				case spec.TemplateVM <= 0:
+					return nil, fmt.Errorf("profile %s (document %d) missing template_vmid or template", path, docIndex)
 				}
 			}
 			if _, exists := profiles[spec.Name]; exists {
@@ -101,6 +110,7 @@ func LoadProfiles(dir string) (map[string]models.Profile, error) {
 			profiles[spec.Name] = models.Profile{
 				Name:       spec.Name,
 				TemplateVM: spec.TemplateVM,
+				Template:   spec.Template,
 				Type:       sandboxType,
 				Image:      spec.Image,
 				UpdatedAt:  modTime,
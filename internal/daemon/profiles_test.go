@@ -75,6 +75,36 @@ resources:
 	}
 }
 
+func TestLoadProfilesTemplateReference(t *testing.T) {
+	dir := t.TempDir()
+	data := "name: agent\ntemplate: agent-base@v12\n"
+	if err := writeFile(filepath.Join(dir, "agent.yaml"), []byte(data)); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	profiles, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	agent := profiles["agent"]
+	if agent.Template != "agent-base@v12" || agent.TemplateVM != 0 {
+		t.Fatalf("agent = template %q vmid %d, want agent-base@v12 and no vmid", agent.Template, agent.TemplateVM)
+	}
+
+	for name, data := range map[string]string{
+		"both":    "name: agent\ntemplate: agent-base\ntemplate_vmid: 9000\n",
+		"invalid": "name: agent\ntemplate: Agent Base\n",
+		"neither": "name: agent\n",
+	} {
+		dir := t.TempDir()
+		if err := writeFile(filepath.Join(dir, "agent.yaml"), []byte(data)); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+		if _, err := LoadProfiles(dir); err == nil {
+			t.Errorf("%s: LoadProfiles() succeeded, want error", name)
+		}
+	}
+}
+
 func writeFile(path string, data []byte) error {
 	// 0600 so tests don't depend on umask.
 	return os.WriteFile(path, data, 0o600)
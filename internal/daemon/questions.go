@@ -0,0 +1,309 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+const (
+	// maxQuestionWait bounds how long a guest blocks for an answer in one
+	// request; the guest polls again to keep waiting.
+	maxQuestionWait = 5 * time.Minute
+	// maxQuestionOptions bounds the choices one question may offer.
+	maxQuestionOptions = 16
+	// maxQuestionTextBytes bounds question, option and answer text.
+	maxQuestionTextBytes = 4 << 10
+	// defaultOpenQuestionsLimit caps the open-question inbox.
+	defaultOpenQuestionsLimit = 200
+)
+
+var (
+	errQuestionNotFound      = errors.New("question not found")
+	errAnswerNotAnOption     = errors.New("answer must be one of the question's options")
+	errQuestionTextInvalid   = fmt.Errorf("text is required and must be at most %d bytes", maxQuestionTextBytes)
+	errQuestionOptionInvalid = fmt.Errorf("at most %d options, each non-empty and at most %d bytes", maxQuestionOptions, maxQuestionTextBytes)
+)
+
+// isQuestionInputError reports whether err is the caller's fault.
+func isQuestionInputError(err error) bool {
+	return errors.Is(err, errQuestionTextInvalid) ||
+		errors.Is(err, errQuestionOptionInvalid) ||
+		errors.Is(err, errAnswerNotAnOption)
+}
+
+// questionPayload is the JSON body of a question message.
+type questionPayload struct {
+	Options []string `json:"options,omitempty"`
+}
+
+type questionAskedPayload struct {
+	MessageID int64    `json:"message_id"`
+	Text      string   `json:"text"`
+	Options   []string `json:"options,omitempty"`
+}
+
+type questionAnsweredPayload struct {
+	MessageID  int64  `json:"message_id"`
+	Answer     string `json:"answer"`
+	AnsweredBy string `json:"answered_by"`
+}
+
+// Questions lets an agent ask an operator a question through the messagebox
+// and block until it is answered.
+//
+// A question is a message of kind "question" in the job's scope (or the
+// sandbox's when no job runs), with its options in the JSON payload. The
+// answer is a message of kind "answer" whose reply_to names the question.
+// Each question takes one answer. Waiters wake as soon as any question is
+// answered.
+type Questions struct {
+	store    *db.Store
+	notifier *Notifier
+	logger   *log.Logger
+	now      func() time.Time
+
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// NewQuestions returns a question tracker.
+func NewQuestions(store *db.Store, logger *log.Logger) *Questions {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Questions{
+		store:   store,
+		logger:  logger,
+		now:     time.Now,
+		changed: make(chan struct{}),
+	}
+}
+
+// WithNotifier sends each new question to the configured webhooks.
+func (q *Questions) WithNotifier(notifier *Notifier) *Questions {
+	if q == nil {
+		return q
+	}
+	q.notifier = notifier
+	return q
+}
+
+// Ask posts a question from sandbox. Options, when given, are the only
+// answers an operator may choose.
+func (q *Questions) Ask(ctx context.Context, sandbox models.Sandbox, jobID, text string, options []string) (db.Message, error) {
+	if q == nil || q.store == nil {
+		return db.Message{}, errors.New("questions unavailable")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxQuestionTextBytes {
+		return db.Message{}, errQuestionTextInvalid
+	}
+	options, err := normalizeQuestionOptions(options)
+	if err != nil {
+		return db.Message{}, err
+	}
+	payload, err := json.Marshal(questionPayload{Options: options})
+	if err != nil {
+		return db.Message{}, err
+	}
+	scopeType, scopeID := sandboxMessageScope(sandbox.VMID, jobID)
+	msg, err := q.store.CreateMessage(ctx, db.Message{
+		Timestamp: q.now().UTC(),
+		ScopeType: scopeType,
+		ScopeID:   scopeID,
+		Author:    sandboxMessageAuthor(sandbox.VMID),
+		Kind:      db.MessageKindQuestion,
+		Text:      text,
+		JSON:      string(payload),
+	})
+	if err != nil {
+		return db.Message{}, err
+	}
+	q.emit(ctx, sandbox.VMID, jobID, EventKindMessageQuestionAsked, "agent asked a question", questionAskedPayload{
+		MessageID: msg.ID,
+		Text:      text,
+		Options:   options,
+	})
+	command := fmt.Sprintf("agentlab msg reply %d <answer>", msg.ID)
+	if len(options) > 0 {
+		command = fmt.Sprintf("agentlab msg reply %d %s", msg.ID, options[0])
+	}
+	q.notifier.Notify(Notification{
+		Kind:      db.MessageKindQuestion,
+		Title:     fmt.Sprintf("Sandbox %d (%s) is waiting for an answer", sandbox.VMID, sandbox.Name),
+		Text:      text,
+		VMID:      sandbox.VMID,
+		JobID:     jobID,
+		MessageID: msg.ID,
+		Options:   options,
+		Command:   command,
+	})
+	return msg, nil
+}
+
+// Answer records an operator's answer to question id.
+func (q *Questions) Answer(ctx context.Context, id int64, answer, answeredBy string) (db.Message, error) {
+	if q == nil || q.store == nil {
+		return db.Message{}, errors.New("questions unavailable")
+	}
+	question, err := q.Get(ctx, id)
+	if err != nil {
+		return db.Message{}, err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" || len(answer) > maxQuestionTextBytes {
+		return db.Message{}, errQuestionTextInvalid
+	}
+	if options := questionOptions(question); len(options) > 0 && !slices.Contains(options, answer) {
+		return db.Message{}, errAnswerNotAnOption
+	}
+	reply, err := q.store.CreateMessage(ctx, db.Message{
+		Timestamp: q.now().UTC(),
+		ScopeType: question.ScopeType,
+		ScopeID:   question.ScopeID,
+		Author:    answeredBy,
+		Kind:      db.MessageKindAnswer,
+		Text:      answer,
+		ReplyTo:   question.ID,
+	})
+	if err != nil {
+		return db.Message{}, err
+	}
+	q.broadcast()
+	vmid, jobID := questionTarget(question)
+	q.emit(ctx, vmid, jobID, EventKindMessageQuestionAnswered, "operator answered a question", questionAnsweredPayload{
+		MessageID:  question.ID,
+		Answer:     answer,
+		AnsweredBy: answeredBy,
+	})
+	return reply, nil
+}
+
+// Get loads question id. Messages of any other kind are reported as not
+// found.
+func (q *Questions) Get(ctx context.Context, id int64) (db.Message, error) {
+	msg, err := q.store.GetMessage(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && msg.Kind != db.MessageKindQuestion) {
+		return db.Message{}, errQuestionNotFound
+	}
+	return msg, err
+}
+
+// Wait blocks until question id is answered, timeout passes, or ctx is done.
+// It returns the answer, or nil when there is none yet.
+func (q *Questions) Wait(ctx context.Context, id int64, timeout time.Duration) (*db.Message, error) {
+	if timeout > maxQuestionWait {
+		timeout = maxQuestionWait
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		changed := q.changed
+		q.mu.Unlock()
+		reply, err := q.store.GetMessageReply(ctx, id)
+		if err == nil {
+			return &reply, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// Open returns unanswered questions, oldest first.
+func (q *Questions) Open(ctx context.Context) ([]db.Message, error) {
+	if q == nil || q.store == nil {
+		return nil, errors.New("questions unavailable")
+	}
+	return q.store.ListOpenQuestions(ctx, defaultOpenQuestionsLimit)
+}
+
+func (q *Questions) broadcast() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *Questions) emit(ctx context.Context, vmid int, jobID string, kind EventKind, msg string, payload any) {
+	var vmidPtr *int
+	if vmid > 0 {
+		vmidPtr = &vmid
+	}
+	var jobIDPtr *string
+	if jobID != "" {
+		jobIDPtr = &jobID
+	}
+	if err := emitEvent(ctx, NewStoreEventRecorder(q.store), kind, vmidPtr, jobIDPtr, msg, payload); err != nil {
+		q.logger.Printf("questions: record %s: %v", kind, err)
+	}
+}
+
+// sandboxMessageScope is the messagebox scope for messages from a sandbox:
+// its job when one is running, otherwise the sandbox itself.
+func sandboxMessageScope(vmid int, jobID string) (string, string) {
+	if jobID != "" {
+		return "job", jobID
+	}
+	return "sandbox", strconv.Itoa(vmid)
+}
+
+// sandboxMessageAuthor is the author recorded on messages a sandbox posts.
+func sandboxMessageAuthor(vmid int) string {
+	return "sandbox:" + strconv.Itoa(vmid)
+}
+
+// questionTarget recovers the sandbox and job a question came from.
+func questionTarget(question db.Message) (int, string) {
+	vmid, _ := strconv.Atoi(strings.TrimPrefix(question.Author, "sandbox:"))
+	jobID := ""
+	if question.ScopeType == "job" {
+		jobID = question.ScopeID
+	}
+	return vmid, jobID
+}
+
+func questionOptions(question db.Message) []string {
+	var payload questionPayload
+	if question.JSON == "" || json.Unmarshal([]byte(question.JSON), &payload) != nil {
+		return nil
+	}
+	return payload.Options
+}
+
+func normalizeQuestionOptions(options []string) ([]string, error) {
+	if len(options) > maxQuestionOptions {
+		return nil, errQuestionOptionInvalid
+	}
+	var out []string
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxQuestionTextBytes {
+			return nil, errQuestionOptionInvalid
+		}
+		if !slices.Contains(out, option) {
+			out = append(out, option)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/secrets"
+)
+
+type questionsPlane struct {
+	store    *db.Store
+	metadata *http.ServeMux
+	control  *http.ServeMux
+	notifier *Notifier
+	secret   string
+
+	mu    sync.Mutex
+	notes []Notification
+}
+
+func newQuestionsPlane(t *testing.T) *questionsPlane {
+	t.Helper()
+	store := newTestStore(t)
+	seedSecretTestSandbox(t, store, 7101, "asking-sandbox", "10.77.7.20")
+	seedSecretTestSandbox(t, store, 7102, "other-sandbox", "10.77.7.21")
+	logger := log.New(io.Discard, "", 0)
+
+	p := &questionsPlane{store: store, secret: seedSandboxSecret(t, store, 7101)}
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var note Notification
+		_ = json.NewDecoder(r.Body).Decode(&note)
+		p.mu.Lock()
+		p.notes = append(p.notes, note)
+		p.mu.Unlock()
+	}))
+	t.Cleanup(hook.Close)
+	p.notifier = NewNotifier(hook.URL+"/hook", "", logger)
+	questions := NewQuestions(store, logger).WithNotifier(p.notifier)
+
+	api := NewMetadataAPI(store, secrets.Store{}, "default", mustParseCIDR(t, "10.77.0.0/16"), nil, logger).WithQuestions(questions)
+	api.writeLimiter = nil
+	p.metadata = http.NewServeMux()
+	api.Register(p.metadata)
+	p.control = http.NewServeMux()
+	NewControlAPI(store, nil, nil, nil, nil, "", logger).WithQuestions(questions).Register(p.control)
+	return p
+}
+
+func (p *questionsPlane) guest(t *testing.T, method, path, body, remoteIP, secret string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = remoteIP + ":4321"
+	withSandboxSecret(req, secret)
+	rec := httptest.NewRecorder()
+	p.metadata.ServeHTTP(rec, req)
+	return rec
+}
+
+func (p *questionsPlane) operator(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.control.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestMetadataQuestionLifecycle(t *testing.T) {
+	p := newQuestionsPlane(t)
+
+	rec := p.guest(t, http.MethodPost, "/metadata/messages", `{"kind":"question","text":"Deploy to prod?","options":["yes","no"]}`, "10.77.7.20", p.secret)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("ask: status %d body=%s, want 201", rec.Code, rec.Body.String())
+	}
+	var question MetadataMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &question); err != nil {
+		t.Fatalf("decode question: %v", err)
+	}
+	if question.Kind != db.MessageKindQuestion || question.ScopeType != "sandbox" || question.ScopeID != "7101" || len(question.Options) != 2 {
+		t.Fatalf("question = %+v, want a sandbox-scoped question with 2 options", question)
+	}
+	replyPath := "/metadata/messages/" + strconv.FormatInt(question.ID, 10) + "/reply"
+
+	if rec := p.guest(t, http.MethodGet, replyPath, "", "10.77.7.20", p.secret); rec.Code != http.StatusAccepted {
+		t.Fatalf("reply before answer: status %d, want 202", rec.Code)
+	}
+	otherSecret := seedSandboxSecret(t, p.store, 7102)
+	if rec := p.guest(t, http.MethodGet, replyPath, "", "10.77.7.21", otherSecret); rec.Code != http.StatusNotFound {
+		t.Fatalf("reply from another sandbox: status %d, want 404", rec.Code)
+	}
+
+	rec = p.operator(t, http.MethodGet, "/v1/messages/questions", "")
+	var inbox V1MessagesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &inbox); err != nil {
+		t.Fatalf("decode inbox: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(inbox.Messages) != 1 || inbox.Messages[0].ID != question.ID {
+		t.Fatalf("inbox: status %d messages %+v, want the question", rec.Code, inbox.Messages)
+	}
+
+	waited := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		waited <- p.guest(t, http.MethodGet, replyPath+"?wait=10s", "", "10.77.7.20", p.secret)
+	}()
+
+	answerPath := "/v1/messages/" + strconv.FormatInt(question.ID, 10) + "/reply"
+	if rec := p.operator(t, http.MethodPost, answerPath, `{"text":"maybe"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("answer outside options: status %d, want 400", rec.Code)
+	}
+	if rec := p.operator(t, http.MethodPost, answerPath, `{"text":"yes","author":"alice"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("answer: status %d body=%s, want 201", rec.Code, rec.Body.String())
+	}
+	if rec := p.operator(t, http.MethodPost, answerPath, `{"text":"no"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("second answer: status %d, want 409", rec.Code)
+	}
+
+	rec = <-waited
+	var reply MetadataReplyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decode reply: %v", err)
+	}
+	if rec.Code != http.StatusOK || reply.Status != "answered" || reply.Text != "yes" || reply.Author != "alice" {
+		t.Fatalf("waited reply: status %d %+v, want yes from alice", rec.Code, reply)
+	}
+
+	rec = p.operator(t, http.MethodGet, "/v1/messages/questions", "")
+	inbox = V1MessagesResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &inbox); err != nil || len(inbox.Messages) != 0 {
+		t.Fatalf("inbox after answer = %+v err = %v, want empty", inbox.Messages, err)
+	}
+	if n := countEvents(t, p.store, EventKindMessageQuestionAsked); n != 1 {
+		t.Fatalf("message.question_asked events = %d, want 1", n)
+	}
+	if n := countEvents(t, p.store, EventKindMessageQuestionAnswered); n != 1 {
+		t.Fatalf("message.question_answered events = %d, want 1", n)
+	}
+
+	p.notifier.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.notes) != 1 || p.notes[0].MessageID != question.ID || p.notes[0].VMID != 7101 {
+		t.Fatalf("notifications = %+v, want one for the question", p.notes)
+	}
+	if want := "agentlab msg reply " + strconv.FormatInt(question.ID, 10) + " yes"; p.notes[0].Command != want {
+		t.Fatalf("notification command = %q, want %q", p.notes[0].Command, want)
+	}
+}
+
+func TestMetadataMessagesValidation(t *testing.T) {
+	p := newQuestionsPlane(t)
+
+	rec := p.guest(t, http.MethodPost, "/metadata/messages", `{"text":"started the build"}`, "10.77.7.20", p.secret)
+	var note MetadataMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil {
+		t.Fatalf("decode note: %v", err)
+	}
+	if rec.Code != http.StatusCreated || note.Kind != db.MessageKindNote {
+		t.Fatalf("note: status %d %+v, want 201 note", rec.Code, note)
+	}
+	if rec := p.guest(t, http.MethodGet, "/metadata/messages/"+strconv.FormatInt(note.ID, 10)+"/reply", "", "10.77.7.20", p.secret); rec.Code != http.StatusNotFound {
+		t.Fatalf("reply to a note: status %d, want 404", rec.Code)
+	}
+
+	options := make([]string, maxQuestionOptions+1)
+	for i := range options {
+		options[i] = "opt" + strconv.Itoa(i)
+	}
+	tooMany, _ := json.Marshal(MetadataMessageRequest{Kind: "question", Text: "pick", Options: options})
+	for name, body := range map[string]string{
+		"unknown kind":     `{"kind":"poll","text":"hi"}`,
+		"empty text":       `{"kind":"question","text":"  "}`,
+		"options on note":  `{"kind":"note","text":"hi","options":["a"]}`,
+		"empty option":     `{"kind":"question","text":"pick","options":["a",""]}`,
+		"too many options": string(tooMany),
+	} {
+		if rec := p.guest(t, http.MethodPost, "/metadata/messages", body, "10.77.7.20", p.secret); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, rec.Code)
+		}
+	}
+
+	if rec := p.guest(t, http.MethodPost, "/metadata/messages", `{"text":"hi"}`, "10.77.7.20", "wrong"); rec.Code != http.StatusForbidden {
+		t.Fatalf("wrong sandbox secret: status %d, want 403", rec.Code)
+	}
+	if rec := p.guest(t, http.MethodGet, "/metadata/messages", "", "10.77.7.20", p.secret); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /metadata/messages: status %d, want 405", rec.Code)
+	}
+	if rec := p.operator(t, http.MethodPost, "/v1/messages/999/reply", `{"text":"yes"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("answer unknown question: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,375 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+// Custom-role authorization (RBAC).
+//
+// A token's Commands claim is what the key holder chose to sign; custom roles
+// are what an operator chose to grant. With rbac_enabled set, RBAC.Wrap
+// resolves the caller of each network request from its token issuer, and a
+// registered non-admin user is confined to the grants of the roles bound to
+// them directly or through their teams. authorizeChecked then requires a grant
+// for the permission in addition to the token, ControlAPI.authorize checks
+// team- and tag-scoped grants against the sandbox a request targets, and
+// sandboxScopeFilter narrows listings to sandboxes some grant covers.
+//
+// Confined users also act through the resources they hold access to: those
+// they own, those owned by their teams, and those shared with them by an
+// access grant (access.go). That access covers actions on the resource but
+// never listing or creating, which still need a role.
+//
+// Admins, SSH keys with no user record (operators listed only in
+// authorized_keys), the legacy bearer token and the local Unix socket are not
+// confined. A user with no bindings is denied everything but the resources
+// they hold access to.
+//
+// A team-scoped grant covers sandboxes owned by the team or by any member of
+// it; a tag-scoped grant covers sandboxes carrying the tag. Only a global
+// grant covers global resources (secrets, users, roles, admin routes) and bulk
+// operations.
+
+// RBAC resolves request callers to their custom-role grants.
+type RBAC struct {
+	users   *user.Registry
+	store   *db.Store
+	enabled atomic.Bool
+}
+
+// NewRBAC creates the custom-role resolver. Enforcement starts disabled
+// unless enabled is set; SetEnabled toggles it on config reload.
+func NewRBAC(users *user.Registry, store *db.Store, enabled bool) *RBAC {
+	rb := &RBAC{users: users, store: store}
+	rb.enabled.Store(enabled)
+	return rb
+}
+
+// SetEnabled turns enforcement on or off.
+func (rb *RBAC) SetEnabled(enabled bool) {
+	if rb == nil {
+		return
+	}
+	rb.enabled.Store(enabled)
+}
+
+// Wrap attaches the caller's policy to authenticated requests. It must run
+// inside auth.Middleware.WrapNetwork (or WrapLocal on the socket) so the
+// identity is already in context. Tokens from the daemon's issuer resolve by
+// user ID; all others by key fingerprint.
+// Every request from a registered user carries the policy so handlers can
+// record ownership; only confined policies restrict access.
+func (rb *RBAC) Wrap(next http.Handler) http.Handler {
+	if rb == nil || rb.users == nil || next == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := auth.FromContext(r.Context())
+		if id == nil || id.Token == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var policy *rbacPolicy
+		var err error
+		if id.UserID != "" {
+			policy, err = rb.policyForUser(r.Context(), id.UserID)
+		} else {
+			policy, err = rb.policyFor(r.Context(), id.Fingerprint)
+		}
+		if err != nil {
+			log.Printf("rbac: resolve caller %s: %v", id.Fingerprint, err)
+			writeError(w, http.StatusServiceUnavailable, "authorization unavailable")
+			return
+		}
+		if policy == nil && id.UserID != "" {
+			// A token issued at login outlived its user: removing the user
+			// revokes it at once instead of widening it to an unregistered
+			// caller's access.
+			writeError(w, http.StatusUnauthorized, "user is no longer registered")
+			return
+		}
+		if policy != nil {
+			r = r.WithContext(context.WithValue(r.Context(), rbacPolicyKey{}, policy))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// policyFor returns the policy for the user holding fingerprint, or nil when
+// the key belongs to no registered user.
+func (rb *RBAC) policyFor(ctx context.Context, fingerprint string) (*rbacPolicy, error) {
+	u, err := rb.users.LookupByFingerprint(ctx, fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rb.policyForRecord(ctx, u)
+}
+
+// policyForUser returns the policy for the user a daemon-issued token acts
+// for, or nil when the user no longer exists.
+func (rb *RBAC) policyForUser(ctx context.Context, userID string) (*rbacPolicy, error) {
+	u, err := rb.users.Store().GetUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rb.policyForRecord(ctx, &u)
+}
+
+func (rb *RBAC) policyForRecord(ctx context.Context, u *user.User) (*rbacPolicy, error) {
+	var err error
+	policy := &rbacPolicy{userID: u.ID, role: u.Role, users: rb.users, store: rb.store}
+	if !rb.enabled.Load() || u.Role == user.RoleAdmin {
+		return policy, nil
+	}
+	policy.confined = true
+	policy.grants, err = rb.users.Grants(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+type rbacPolicyKey struct{}
+
+// rbacPolicy is one request's view of its caller's grants. Sandbox and team
+// lookups are cached for the life of the request.
+type rbacPolicy struct {
+	userID   string
+	role     user.Role
+	confined bool
+	grants   []user.Grant
+	users    *user.Registry
+	store    *db.Store
+
+	mu        sync.Mutex
+	sandboxes map[int]*models.Sandbox
+	members   map[string]map[string]bool
+	access    map[authzResource]user.AccessLevel
+}
+
+// callerUserID returns the registered user making the request, or "" for
+// trusted and unregistered callers.
+func callerUserID(ctx context.Context) string {
+	if p, ok := ctx.Value(rbacPolicyKey{}).(*rbacPolicy); ok && p != nil {
+		return p.userID
+	}
+	return ""
+}
+
+// callerRole returns the role of the registered user making the request, or
+// "" for trusted and unregistered callers.
+func callerRole(ctx context.Context) user.Role {
+	if p, ok := ctx.Value(rbacPolicyKey{}).(*rbacPolicy); ok && p != nil {
+		return p.role
+	}
+	return ""
+}
+
+// confinedPolicy returns the caller's policy when custom roles confine it, or
+// nil when the caller is not confined.
+func confinedPolicy(ctx context.Context) *rbacPolicy {
+	p, ok := ctx.Value(rbacPolicyKey{}).(*rbacPolicy)
+	if !ok || p == nil || !p.confined {
+		return nil
+	}
+	return p
+}
+
+// grantAllowsPermission matches perm against a role's permissions the way
+// auth.Token.IsCommandAllowed matches a token's commands: "*", an exact name,
+// or a dot-boundary namespace.
+func grantAllowsPermission(permissions []string, perm string) bool {
+	if perm == "" {
+		return false
+	}
+	for _, allowed := range permissions {
+		if allowed == "*" || allowed == perm || strings.HasPrefix(perm, allowed+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether any grant carries perm. When global is set only
+// global grants count, because the resource is not bound to one sandbox.
+func (p *rbacPolicy) allows(perm string, global bool) bool {
+	for _, g := range p.grants {
+		if !grantAllowsPermission(g.Permissions, perm) {
+			continue
+		}
+		if !global || g.ScopeType == user.ScopeGlobal {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsSandbox reports whether a grant carrying perm covers sandbox vmid, or
+// the caller's access to the sandbox reaches perm.
+func (p *rbacPolicy) allowsSandbox(ctx context.Context, perm string, vmid int) bool {
+	for _, g := range p.grants {
+		if grantAllowsPermission(g.Permissions, perm) && p.covers(ctx, g, vmid) {
+			return true
+		}
+	}
+	return p.sandboxAccess(ctx, vmid).Includes(accessLevelFor(perm))
+}
+
+// allowsResource reports whether the caller's access to a workspace or
+// session reaches perm.
+func (p *rbacPolicy) allowsResource(ctx context.Context, perm string, res authzResource) bool {
+	return p.resourceAccess(ctx, res).Includes(accessLevelFor(perm))
+}
+
+// seesSandbox reports whether any grant covers sandbox vmid or the caller
+// holds access to it; listings show exactly those sandboxes.
+func (p *rbacPolicy) seesSandbox(ctx context.Context, vmid int) bool {
+	for _, g := range p.grants {
+		if p.covers(ctx, g, vmid) {
+			return true
+		}
+	}
+	return p.sandboxAccess(ctx, vmid) != user.AccessNone
+}
+
+// hasGlobalGrant reports whether any grant is global, which makes every
+// sandbox visible.
+func (p *rbacPolicy) hasGlobalGrant() bool {
+	for _, g := range p.grants {
+		if g.ScopeType == user.ScopeGlobal {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *rbacPolicy) covers(ctx context.Context, g user.Grant, vmid int) bool {
+	switch g.ScopeType {
+	case user.ScopeGlobal:
+		return true
+	case user.ScopeTeam:
+		sb := p.sandbox(ctx, vmid)
+		return sb != nil && (sb.Team == g.ScopeID || (sb.Owner != "" && p.isMember(ctx, g.ScopeID, sb.Owner)))
+	case user.ScopeTag:
+		sb := p.sandbox(ctx, vmid)
+		return sb != nil && slices.Contains(parseTags(sb.Tags), g.ScopeID)
+	}
+	return false
+}
+
+func (p *rbacPolicy) sandbox(ctx context.Context, vmid int) *models.Sandbox {
+	if vmid <= 0 || p.store == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sb, ok := p.sandboxes[vmid]; ok {
+		return sb
+	}
+	if p.sandboxes == nil {
+		p.sandboxes = make(map[int]*models.Sandbox)
+	}
+	var found *models.Sandbox
+	if sb, err := p.store.GetSandbox(ctx, vmid); err == nil {
+		found = &sb
+	}
+	p.sandboxes[vmid] = found
+	return found
+}
+
+func (p *rbacPolicy) isMember(ctx context.Context, teamID, userID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	members, ok := p.members[teamID]
+	if !ok {
+		members = make(map[string]bool)
+		if list, err := p.users.ListTeamMembers(ctx, teamID); err == nil {
+			for _, m := range list {
+				members[m.UserID] = true
+			}
+		}
+		if p.members == nil {
+			p.members = make(map[string]map[string]bool)
+		}
+		p.members[teamID] = members
+	}
+	return members[userID]
+}
+
+// sandboxAccess returns the caller's access level on sandbox vmid.
+func (p *rbacPolicy) sandboxAccess(ctx context.Context, vmid int) user.AccessLevel {
+	if vmid <= 0 {
+		return user.AccessNone
+	}
+	return p.resourceAccess(ctx, authzResource{Type: user.ResourceSandbox, ID: strconv.Itoa(vmid)})
+}
+
+// resourceAccess returns the caller's access level on a sandbox, workspace or
+// session, from its owner, its owning team and the caller's access grant.
+func (p *rbacPolicy) resourceAccess(ctx context.Context, res authzResource) user.AccessLevel {
+	if p.users == nil || res.ID == "" {
+		return user.AccessNone
+	}
+	p.mu.Lock()
+	level, ok := p.access[res]
+	p.mu.Unlock()
+	if ok {
+		return level
+	}
+	id, owner, team, found := p.resourceOwners(ctx, res)
+	level = user.AccessNone
+	if found {
+		var err error
+		level, err = p.users.ResourceAccess(ctx, p.userID, res.Type, id, owner, team)
+		if err != nil {
+			level = user.AccessNone
+		}
+	}
+	p.mu.Lock()
+	if p.access == nil {
+		p.access = make(map[authzResource]user.AccessLevel)
+	}
+	p.access[res] = level
+	p.mu.Unlock()
+	return level
+}
+
+// resourceOwners looks up a resource's canonical ID, owner and owning team.
+// Sandboxes go through the request's sandbox cache.
+func (p *rbacPolicy) resourceOwners(ctx context.Context, res authzResource) (string, string, string, bool) {
+	if res.Type == user.ResourceSandbox {
+		vmid, err := strconv.Atoi(res.ID)
+		if err != nil {
+			return "", "", "", false
+		}
+		if sb := p.sandbox(ctx, vmid); sb != nil {
+			return res.ID, sb.Owner, sb.Team, true
+		}
+		return "", "", "", false
+	}
+	canonical, owner, team, err := lookupResourceOwnership(ctx, p.store, res)
+	if err != nil {
+		return "", "", "", false
+	}
+	return canonical.ID, owner, team, true
+}
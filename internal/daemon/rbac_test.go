@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+func TestRBACConfinesRegisteredUsers(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	identities := make(map[string]*auth.RequestIdentity)
+	for _, name := range []string{"root", "alice", "bob", "carol", "dave"} {
+		u, err := users.AddUser(ctx, name, reportTestKey(t), user.RoleUser)
+		require.NoError(t, err)
+		identities[name] = rbacTestIdentity(u.Fingerprint)
+	}
+	identities["operator"] = rbacTestIdentity("SHA256:not-a-registered-user")
+	_, err := users.CreateTeam(ctx, "infra", "", "alice")
+	require.NoError(t, err)
+	require.NoError(t, users.AddTeamMember(ctx, "infra", "bob", user.RoleUser, ""))
+
+	_, err = users.CreateRole(ctx, user.CustomRole{Name: "operator", Permissions: []string{"sandbox"}}, "root")
+	require.NoError(t, err)
+	_, err = users.CreateRole(ctx, user.CustomRole{Name: "viewer", Permissions: []string{permSandboxRead, permSandboxList}}, "root")
+	require.NoError(t, err)
+	_, err = users.BindRole(ctx, user.RoleBinding{Role: "operator", SubjectType: user.SubjectTeam, SubjectID: "infra", ScopeType: user.ScopeTeam, ScopeID: "infra"}, "root")
+	require.NoError(t, err)
+	_, err = users.BindRole(ctx, user.RoleBinding{Role: "viewer", SubjectType: user.SubjectUser, SubjectID: "carol", ScopeType: user.ScopeTag, ScopeID: "ci"}, "root")
+	require.NoError(t, err)
+
+	for _, sb := range []models.Sandbox{
+		{VMID: 1001, Name: "team", Profile: "default", Owner: "bob", State: models.SandboxRunning},
+		{VMID: 1002, Name: "tagged", Profile: "default", Tags: "ci,nightly", State: models.SandboxRunning},
+		{VMID: 1003, Name: "root", Profile: "default", Owner: "root", State: models.SandboxRunning},
+	} {
+		require.NoError(t, store.CreateSandbox(ctx, sb))
+	}
+
+	rbac := NewRBAC(users, store, true)
+	api := &ControlAPI{}
+	do := func(name, perm string, vmid int, global bool) int {
+		t.Helper()
+		handler := rbac.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if api.authorize(w, r, perm, func() int { return vmid }, global) {
+				w.WriteHeader(http.StatusOK)
+			}
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/v1/test", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(auth.WithIdentity(req.Context(), identities[name])))
+		return rec.Code
+	}
+
+	cases := []struct {
+		caller string
+		perm   string
+		vmid   int
+		global bool
+		want   int
+	}{
+		{"alice", permSandboxStop, 1001, false, http.StatusOK},        // teammate's sandbox
+		{"alice", permSandboxStop, 1003, false, http.StatusForbidden}, // outside the team
+		{"alice", permSandboxCreate, 0, false, http.StatusOK},         // no target yet
+		{"alice", permWorkspaceFork, 0, false, http.StatusForbidden},  // not in the role
+		{"alice", permSandboxBulk, 0, true, http.StatusForbidden},     // global needs a global grant
+		{"carol", permSandboxRead, 1002, false, http.StatusOK},        // tagged ci
+		{"carol", permSandboxStop, 1002, false, http.StatusForbidden}, // viewer only
+		{"carol", permSandboxRead, 1001, false, http.StatusForbidden}, // not tagged
+		{"dave", permSandboxList, 0, false, http.StatusForbidden},     // no bindings
+		{"root", permSandboxDestroy, 1001, false, http.StatusOK},      // admin
+		{"root", permSecretsWrite, 0, true, http.StatusOK},            // admin
+		{"operator", permSandboxDestroy, 1001, false, http.StatusOK},  // key without a user record
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, do(tc.caller, tc.perm, tc.vmid, tc.global), "%s %s %d", tc.caller, tc.perm, tc.vmid)
+	}
+
+	var filter func(int) bool
+	probe := rbac.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter = sandboxScopeFilter(r)
+		assert.Equal(t, "alice", callerUserID(r.Context()))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/sandboxes", nil)
+	probe.ServeHTTP(httptest.NewRecorder(), req.WithContext(auth.WithIdentity(req.Context(), identities["alice"])))
+	require.NotNil(t, filter)
+	assert.True(t, filter(1001))
+	assert.False(t, filter(1002))
+	assert.False(t, filter(1003))
+
+	rbac.SetEnabled(false)
+	assert.Equal(t, http.StatusOK, do("alice", permSandboxStop, 1003, false), "disabled RBAC leaves tokens in charge")
+	assert.Equal(t, http.StatusOK, do("dave", permSecretsWrite, 0, true))
+}
+
+func TestRBACTeamOwnershipAndAccessGrants(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	identities := make(map[string]*auth.RequestIdentity)
+	for _, name := range []string{"root", "alice", "bob", "erin"} {
+		u, err := users.AddUser(ctx, name, reportTestKey(t), user.RoleUser)
+		require.NoError(t, err)
+		identities[name] = rbacTestIdentity(u.Fingerprint)
+	}
+	_, err := users.CreateTeam(ctx, "infra", "", "alice")
+	require.NoError(t, err)
+	require.NoError(t, users.AddTeamMember(ctx, "infra", "bob", user.RoleUser, ""))
+
+	for _, sb := range []models.Sandbox{
+		{VMID: 2001, Name: "mine", Profile: "default", Owner: "alice", State: models.SandboxRunning},
+		{VMID: 2002, Name: "team", Profile: "default", Owner: "root", Team: "infra", State: models.SandboxRunning},
+		{VMID: 2003, Name: "shared", Profile: "default", Owner: "root", State: models.SandboxRunning},
+	} {
+		require.NoError(t, store.CreateSandbox(ctx, sb))
+	}
+	require.NoError(t, store.CreateWorkspace(ctx, models.Workspace{
+		ID: "ws-shared", Name: "shared", Storage: "local-zfs", VolumeID: "local-zfs:vm-0-disk-1", SizeGB: 10, Owner: "root",
+	}))
+	_, err = users.GrantAccess(ctx, user.AccessGrant{ResourceType: user.ResourceSandbox, ResourceID: "2003", UserID: "erin", Level: user.AccessOperate}, "root")
+	require.NoError(t, err)
+	_, err = users.GrantAccess(ctx, user.AccessGrant{ResourceType: user.ResourceWorkspace, ResourceID: "ws-shared", UserID: "erin", Level: user.AccessRead}, "root")
+	require.NoError(t, err)
+
+	rbac := NewRBAC(users, store, true)
+	api := (&ControlAPI{store: store}).WithAccessControl(users, rbac)
+	do := func(name, perm string, vmid int, res authzResource) int {
+		t.Helper()
+		handler := rbac.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if api.authorizeResource(w, r, perm, func() int { return vmid }, res) {
+				w.WriteHeader(http.StatusOK)
+			}
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/v1/test", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(auth.WithIdentity(req.Context(), identities[name])))
+		return rec.Code
+	}
+	workspace := authzResource{Type: user.ResourceWorkspace, ID: "shared"}
+
+	cases := []struct {
+		caller string
+		perm   string
+		vmid   int
+		res    authzResource
+		want   int
+	}{
+		{"alice", permSandboxDestroy, 2001, authzResource{}, http.StatusOK},       // owner
+		{"bob", permSandboxStop, 2001, authzResource{}, http.StatusForbidden},     // not shared with the team
+		{"bob", permSandboxDestroy, 2002, authzResource{}, http.StatusOK},         // owning team
+		{"erin", permSandboxStart, 2003, authzResource{}, http.StatusOK},          // operate grant
+		{"erin", permSandboxSSH, 2003, authzResource{}, http.StatusOK},            // operate grant
+		{"erin", permSandboxDestroy, 2003, authzResource{}, http.StatusForbidden}, // above the grant
+		{"erin", permSandboxRead, 2002, authzResource{}, http.StatusForbidden},    // no grant
+		{"erin", permWorkspaceRead, 0, workspace, http.StatusOK},                  // read grant, by name
+		{"erin", permWorkspaceFork, 0, workspace, http.StatusForbidden},           // above the grant
+		{"bob", permWorkspaceRead, 0, workspace, http.StatusForbidden},            // no grant
+		{"alice", permSandboxCreate, 0, authzResource{}, http.StatusForbidden},    // creating still needs a role
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, do(tc.caller, tc.perm, tc.vmid, tc.res), "%s %s %d %s", tc.caller, tc.perm, tc.vmid, tc.res)
+	}
+
+	var filter func(int) bool
+	probe := rbac.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter = sandboxScopeFilter(r)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/sandboxes", nil)
+	probe.ServeHTTP(httptest.NewRecorder(), req.WithContext(auth.WithIdentity(req.Context(), identities["erin"])))
+	require.NotNil(t, filter)
+	assert.False(t, filter(2001))
+	assert.False(t, filter(2002))
+	assert.True(t, filter(2003))
+}
+
+func TestAccessAPI(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	identities := make(map[string]*auth.RequestIdentity)
+	for _, name := range []string{"root", "alice", "bob"} {
+		u, err := users.AddUser(ctx, name, reportTestKey(t), user.RoleUser)
+		require.NoError(t, err)
+		identities[name] = rbacTestIdentity(u.Fingerprint)
+	}
+	_, err := users.CreateTeam(ctx, "infra", "", "alice")
+	require.NoError(t, err)
+	require.NoError(t, store.CreateSandbox(ctx, models.Sandbox{VMID: 3001, Name: "mine", Profile: "default", Owner: "alice", State: models.SandboxRunning}))
+
+	rbac := NewRBAC(users, store, true)
+	api := (&ControlAPI{store: store}).WithAccessControl(users, rbac)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/access", api.handleAccess)
+	mux.HandleFunc("/v1/access/", api.handleAccessByID)
+	handler := rbac.Wrap(mux)
+	call := func(name, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if name != "" {
+			req = req.WithContext(auth.WithIdentity(req.Context(), identities[name]))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("bob", http.MethodPost, "/v1/access", `{"resource":"sandbox:3001","user":"bob","level":"admin"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "no access to share yet")
+
+	rec = call("alice", http.MethodPost, "/v1/access", `{"resource":"sandbox:3001","user":"bob","level":"operate"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var grant V1AccessGrant
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &grant))
+	assert.Equal(t, "sandbox:3001", grant.Resource)
+	assert.Equal(t, "alice", grant.GrantedBy)
+
+	rec = call("", http.MethodGet, "/v1/access/check?fingerprint="+url.QueryEscape(identities["bob"].Fingerprint)+"&vmid=3001", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var check V1AccessCheckResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &check))
+	assert.True(t, check.Allowed)
+	assert.Equal(t, "bob", check.User)
+	assert.Equal(t, permSandboxSSH, check.Permission)
+
+	rec = call("", http.MethodGet, "/v1/access/check?user=bob&vmid=3001", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &check))
+	assert.True(t, check.Allowed)
+	rec = call("", http.MethodGet, "/v1/access/check?user=nobody&vmid=3001", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &check))
+	assert.False(t, check.Allowed, "unknown user IDs are denied")
+
+	rec = call("bob", http.MethodGet, "/v1/access/check?fingerprint=x", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "only the local socket may check other keys")
+
+	rec = call("alice", http.MethodPost, "/v1/access/team", `{"resource":"sandbox:3001","team":"infra"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	sb, err := store.GetSandbox(ctx, 3001)
+	require.NoError(t, err)
+	assert.Equal(t, "infra", sb.Team)
+	assert.Equal(t, "alice", sb.Owner)
+
+	rec = call("bob", http.MethodPost, "/v1/access/team", `{"resource":"sandbox:3001","team":"infra"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "operate does not carry access.write")
+
+	rec = call("alice", http.MethodDelete, "/v1/access/"+strconv.FormatInt(grant.ID, 10), "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = call("", http.MethodGet, "/v1/access?resource=sandbox:3001", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var listed V1AccessResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Empty(t, listed.Grants)
+	assert.Equal(t, "infra", listed.Team)
+}
+
+func TestValidRolePermission(t *testing.T) {
+	for _, perm := range []string{"*", "sandbox", "sandbox.start", "sandbox.snapshot", "sandbox.snapshot.restore", "workspace", "role.write"} {
+		assert.True(t, validRolePermission(perm), perm)
+	}
+	for _, perm := range []string{"", "sand", "sandbox.", "sandbox.launch", "sandbox.*"} {
+		assert.False(t, validRolePermission(perm), perm)
+	}
+}
+
+func rbacTestIdentity(fingerprint string) *auth.RequestIdentity {
+	return &auth.RequestIdentity{
+		Method:      "ssh-token",
+		Fingerprint: fingerprint,
+		Token:       &auth.Token{Claims: auth.TokenClaims{Issuer: fingerprint, Commands: []string{"*"}}},
+	}
+}
@@ -0,0 +1,443 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/config"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+// Report groupings accepted by GET /v1/reports/usage.
+const (
+	ReportGroupOwner   = "owner"
+	ReportGroupTeam    = "team"
+	ReportGroupProfile = "profile"
+	ReportGroupTag     = "tag"
+)
+
+const (
+	defaultReportWindow = 30 * 24 * time.Hour
+	maxReportWindow     = 366 * 24 * time.Hour
+	// reportGroupNone labels sandboxes without an owner, team or tag.
+	reportGroupNone = "(none)"
+)
+
+// UsagePrices are the unit prices applied to usage reports.
+type UsagePrices struct {
+	Currency      string  `json:"currency"`
+	CoreHour      float64 `json:"core_hour"`
+	MemoryGiBHour float64 `json:"memory_gib_hour"`
+	InputMTok     float64 `json:"input_mtok"`
+	OutputMTok    float64 `json:"output_mtok"`
+}
+
+// UsagePricesFromConfig returns the unit prices configured in cfg.
+func UsagePricesFromConfig(cfg config.Config) UsagePrices {
+	return UsagePrices{
+		Currency:      cfg.UsagePriceCurrency,
+		CoreHour:      cfg.UsagePriceCoreHour,
+		MemoryGiBHour: cfg.UsagePriceMemoryGiBHour,
+		InputMTok:     cfg.UsagePriceInputMTok,
+		OutputMTok:    cfg.UsagePriceOutputMTok,
+	}
+}
+
+// ReportAPI serves usage and cost reports over the control API.
+//
+// Reports aggregate every sandbox on the host, so they are global and refused
+// to sandbox-scoped tokens.
+type ReportAPI struct {
+	store    *db.Store
+	profiles *ProfileRegistry
+	users    *user.Registry
+	now      func() time.Time
+	mu       sync.RWMutex
+	prices   UsagePrices
+}
+
+// NewReportAPI creates a new report API handler. users may be nil, in which
+// case owners are reported by user ID and team grouping is empty.
+func NewReportAPI(store *db.Store, profiles *ProfileRegistry, users *user.Registry, prices UsagePrices) *ReportAPI {
+	return &ReportAPI{
+		store:    store,
+		profiles: profiles,
+		users:    users,
+		now:      time.Now,
+		prices:   prices,
+	}
+}
+
+// SetPrices replaces the unit prices used by later reports.
+func (api *ReportAPI) SetPrices(prices UsagePrices) {
+	if api == nil {
+		return
+	}
+	api.mu.Lock()
+	api.prices = prices
+	api.mu.Unlock()
+}
+
+// Register registers report API routes on the given mux.
+func (api *ReportAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/reports/usage", api.handleUsageReport)
+}
+
+func (api *ReportAPI) handleUsageReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if !authorizeStandalone(w, r, permReportUsage, true) {
+		return
+	}
+	if api.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "usage reports unavailable")
+		return
+	}
+	query := r.URL.Query()
+	groupBy := strings.ToLower(strings.TrimSpace(query.Get("group_by")))
+	if groupBy == "" {
+		groupBy = ReportGroupOwner
+	}
+	switch groupBy {
+	case ReportGroupOwner, ReportGroupTeam, ReportGroupProfile, ReportGroupTag:
+	default:
+		writeError(w, http.StatusBadRequest, "group_by must be owner, team, profile or tag")
+		return
+	}
+	now := api.now().UTC()
+	to := now
+	if raw := query.Get("to"); raw != "" {
+		parsed, err := parseReportTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "to: "+err.Error())
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultReportWindow)
+	if raw := query.Get("from"); raw != "" {
+		parsed, err := parseReportTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "from: "+err.Error())
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from) > maxReportWindow {
+		writeError(w, http.StatusBadRequest, "report window must not exceed 366 days")
+		return
+	}
+	api.mu.RLock()
+	prices := api.prices
+	api.mu.RUnlock()
+	report, err := api.usageReport(r.Context(), from, to, now, groupBy, prices)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build usage report")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseReportTime accepts an RFC3339 timestamp or a YYYY-MM-DD date, which
+// means midnight UTC.
+func parseReportTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed.UTC(), nil
+	}
+	if parsed, err := time.Parse(time.DateOnly, raw); err == nil {
+		return parsed.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC3339 timestamp or YYYY-MM-DD date", raw)
+}
+
+// sandboxUsage is one sandbox's billable usage within a report window.
+type sandboxUsage struct {
+	sandbox        models.Sandbox
+	hours          float64
+	coreHours      float64
+	memoryGiBHours float64
+	llm            db.LLMUsageTotal
+}
+
+// usageReport builds the report for [from, to). Time after now is never
+// billed.
+func (api *ReportAPI) usageReport(ctx context.Context, from, to, now time.Time, groupBy string, prices UsagePrices) (V1UsageReportResponse, error) {
+	usage, err := api.sandboxUsage(ctx, from, to, now)
+	if err != nil {
+		return V1UsageReportResponse{}, err
+	}
+	groupsOf, err := api.groupResolver(ctx, groupBy)
+	if err != nil {
+		return V1UsageReportResponse{}, err
+	}
+	rows := make(map[string]*V1UsageReportRow)
+	total := V1UsageReportRow{Group: "total"}
+	for _, u := range usage {
+		if u.hours == 0 && u.llm.Requests == 0 {
+			continue
+		}
+		addUsageToRow(&total, u, prices)
+		for _, group := range groupsOf(u.sandbox) {
+			row, ok := rows[group]
+			if !ok {
+				row = &V1UsageReportRow{Group: group}
+				rows[group] = row
+			}
+			addUsageToRow(row, u, prices)
+		}
+	}
+	resp := V1UsageReportResponse{
+		From:     from.Format(time.RFC3339),
+		To:       to.Format(time.RFC3339),
+		GroupBy:  groupBy,
+		Currency: prices.Currency,
+		Prices:   prices,
+		Rows:     make([]V1UsageReportRow, 0, len(rows)),
+		Total:    total,
+	}
+	for _, row := range rows {
+		resp.Rows = append(resp.Rows, *row)
+	}
+	sort.Slice(resp.Rows, func(i, j int) bool {
+		if resp.Rows[i].TotalCost != resp.Rows[j].TotalCost {
+			return resp.Rows[i].TotalCost > resp.Rows[j].TotalCost
+		}
+		return resp.Rows[i].Group < resp.Rows[j].Group
+	})
+	return resp, nil
+}
+
+func addUsageToRow(row *V1UsageReportRow, u sandboxUsage, prices UsagePrices) {
+	computeCost := u.coreHours*prices.CoreHour + u.memoryGiBHours*prices.MemoryGiBHour
+	tokenCost := float64(u.llm.InputTokens)/1e6*prices.InputMTok + float64(u.llm.OutputTokens)/1e6*prices.OutputMTok
+	row.Sandboxes++
+	row.Hours += u.hours
+	row.CoreHours += u.coreHours
+	row.MemoryGiBHours += u.memoryGiBHours
+	row.LLMRequests += u.llm.Requests
+	row.InputTokens += u.llm.InputTokens
+	row.OutputTokens += u.llm.OutputTokens
+	row.ComputeCost += computeCost
+	row.TokenCost += tokenCost
+	row.TotalCost += computeCost + tokenCost
+}
+
+// sandboxUsage returns the billable usage of every sandbox in [from, to).
+// Resource-hours come from sandbox.state events, so time covered by events
+// that retention has already compacted is not counted.
+func (api *ReportAPI) sandboxUsage(ctx context.Context, from, to, now time.Time) ([]sandboxUsage, error) {
+	end := to
+	if now.Before(end) {
+		end = now
+	}
+	sandboxes, err := api.store.ListSandboxes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list sandboxes: %w", err)
+	}
+	events, err := api.store.ListEventsByKindBefore(ctx, string(EventKindSandboxState), end)
+	if err != nil {
+		return nil, err
+	}
+	transitions := make(map[int][]stateTransition)
+	for _, ev := range events {
+		if ev.SandboxVMID == nil {
+			continue
+		}
+		var payload struct {
+			FromState string `json:"from_state"`
+			ToState   string `json:"to_state"`
+		}
+		if err := json.Unmarshal([]byte(ev.JSON), &payload); err != nil || payload.ToState == "" {
+			continue
+		}
+		transitions[*ev.SandboxVMID] = append(transitions[*ev.SandboxVMID], stateTransition{
+			at:   ev.Timestamp,
+			from: models.SandboxState(payload.FromState),
+			to:   models.SandboxState(payload.ToState),
+		})
+	}
+	tokens, err := api.store.SumLLMUsageBySandbox(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var profiles map[string]models.Profile
+	if api.profiles != nil {
+		profiles = api.profiles.Snapshot()
+	}
+	out := make([]sandboxUsage, 0, len(sandboxes))
+	for _, sb := range sandboxes {
+		billed := billableDuration(sb, transitions[sb.VMID], from, end)
+		cores, memoryMB, _ := profileResourceAlloc(profiles[sb.Profile])
+		hours := billed.Hours()
+		out = append(out, sandboxUsage{
+			sandbox:        sb,
+			hours:          hours,
+			coreHours:      hours * float64(cores),
+			memoryGiBHours: hours * float64(memoryMB) / 1024,
+			llm:            tokens[sb.VMID],
+		})
+	}
+	return out, nil
+}
+
+// stateTransition is one recorded sandbox.state event.
+type stateTransition struct {
+	at   time.Time
+	from models.SandboxState
+	to   models.SandboxState
+}
+
+// isBillableState reports whether a sandbox holds its CPU and memory in state.
+func isBillableState(state models.SandboxState) bool {
+	return state == models.SandboxReady || state == models.SandboxRunning
+}
+
+// billableDuration returns how long sb spent in a billable state within
+// [from, end). The state at from is the target of the last transition before
+// it, else the source of the first transition after it. A sandbox with no
+// transitions at all is assumed to have held its current state since it was
+// last updated.
+func billableDuration(sb models.Sandbox, transitions []stateTransition, from, end time.Time) time.Duration {
+	if !from.Before(end) {
+		return 0
+	}
+	if len(transitions) == 0 {
+		if !isBillableState(sb.State) {
+			return 0
+		}
+		start := from
+		if sb.LastUpdatedAt.After(start) {
+			start = sb.LastUpdatedAt
+		}
+		if !start.Before(end) {
+			return 0
+		}
+		return end.Sub(start)
+	}
+	state := transitions[0].from
+	i := 0
+	for ; i < len(transitions) && transitions[i].at.Before(from); i++ {
+		state = transitions[i].to
+	}
+	var billed time.Duration
+	cursor := from
+	for ; i < len(transitions) && transitions[i].at.Before(end); i++ {
+		if isBillableState(state) {
+			billed += transitions[i].at.Sub(cursor)
+		}
+		cursor = transitions[i].at
+		state = transitions[i].to
+	}
+	if isBillableState(state) {
+		billed += end.Sub(cursor)
+	}
+	return billed
+}
+
+// groupResolver returns a function naming the report groups a sandbox counts
+// toward. A sandbox counts toward every team its owner belongs to and every
+// tag it carries, so team and tag rows may add up to more than the total.
+func (api *ReportAPI) groupResolver(ctx context.Context, groupBy string) (func(models.Sandbox) []string, error) {
+	switch groupBy {
+	case ReportGroupProfile:
+		return func(sb models.Sandbox) []string { return []string{orNone(sb.Profile)} }, nil
+	case ReportGroupTag:
+		return func(sb models.Sandbox) []string {
+			tags := parseTags(sb.Tags)
+			if len(tags) == 0 {
+				return []string{reportGroupNone}
+			}
+			return tags
+		}, nil
+	}
+	userNames := make(map[string]string)
+	userTeams := make(map[string][]string)
+	if api.users != nil {
+		users, err := api.users.ListUsers(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+		for _, u := range users {
+			userNames[u.ID] = u.Name
+		}
+		if groupBy == ReportGroupTeam {
+			teams, err := api.users.ListTeams(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("list teams: %w", err)
+			}
+			for _, team := range teams {
+				members, err := api.users.ListTeamMembers(ctx, team.ID)
+				if err != nil {
+					return nil, fmt.Errorf("list team %s members: %w", team.Name, err)
+				}
+				for _, member := range members {
+					userTeams[member.UserID] = append(userTeams[member.UserID], team.Name)
+				}
+			}
+		}
+	}
+	if groupBy == ReportGroupTeam {
+		return func(sb models.Sandbox) []string {
+			if teams := userTeams[sb.Owner]; len(teams) > 0 {
+				return teams
+			}
+			return []string{reportGroupNone}
+		}, nil
+	}
+	return func(sb models.Sandbox) []string {
+		if name, ok := userNames[sb.Owner]; ok {
+			return []string{name}
+		}
+		return []string{orNone(sb.Owner)}
+	}, nil
+}
+
+func orNone(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return reportGroupNone
+	}
+	return value
+}
+
+// V1UsageReportRow is one group's usage and cost in a usage report.
+type V1UsageReportRow struct {
+	Group          string  `json:"group"`
+	Sandboxes      int     `json:"sandboxes"`
+	Hours          float64 `json:"hours"`
+	CoreHours      float64 `json:"core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	LLMRequests    int64   `json:"llm_requests"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	ComputeCost    float64 `json:"compute_cost"`
+	TokenCost      float64 `json:"token_cost"`
+	TotalCost      float64 `json:"total_cost"`
+}
+
+// V1UsageReportResponse is the JSON body returned by GET /v1/reports/usage.
+// Rows are sorted by descending total cost. Total counts each sandbox once,
+// even when it appears in several team or tag rows.
+type V1UsageReportResponse struct {
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	GroupBy  string             `json:"group_by"`
+	Currency string             `json:"currency"`
+	Prices   UsagePrices        `json:"prices"`
+	Rows     []V1UsageReportRow `json:"rows"`
+	Total    V1UsageReportRow   `json:"total"`
+}
@@ -0,0 +1,197 @@
+package daemon
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+func TestBillableDuration(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := from.Add(10 * time.Hour)
+	at := func(h float64) time.Time { return from.Add(time.Duration(h * float64(time.Hour))) }
+	tr := func(h float64, fromState, toState models.SandboxState) stateTransition {
+		return stateTransition{at: at(h), from: fromState, to: toState}
+	}
+
+	cases := []struct {
+		name        string
+		sandbox     models.Sandbox
+		transitions []stateTransition
+		want        time.Duration
+	}{
+		{
+			name: "started and stopped inside the window",
+			transitions: []stateTransition{
+				tr(1, models.SandboxBooting, models.SandboxReady),
+				tr(2, models.SandboxReady, models.SandboxRunning),
+				tr(4, models.SandboxRunning, models.SandboxStopped),
+			},
+			want: 3 * time.Hour,
+		},
+		{
+			name: "running since before the window",
+			transitions: []stateTransition{
+				tr(-5, models.SandboxReady, models.SandboxRunning),
+				tr(6, models.SandboxRunning, models.SandboxSuspended),
+				tr(8, models.SandboxSuspended, models.SandboxRunning),
+			},
+			want: 8 * time.Hour,
+		},
+		{
+			name: "first event in window names the earlier state",
+			transitions: []stateTransition{
+				tr(3, models.SandboxRunning, models.SandboxStopped),
+			},
+			want: 3 * time.Hour,
+		},
+		{
+			name: "stopped before the window",
+			transitions: []stateTransition{
+				tr(-3, models.SandboxReady, models.SandboxRunning),
+				tr(-1, models.SandboxRunning, models.SandboxStopped),
+			},
+			want: 0,
+		},
+		{
+			name:    "no events falls back to the current state",
+			sandbox: models.Sandbox{State: models.SandboxRunning, LastUpdatedAt: at(7)},
+			want:    3 * time.Hour,
+		},
+		{
+			name:    "no events in a stopped state",
+			sandbox: models.Sandbox{State: models.SandboxStopped, LastUpdatedAt: at(-7)},
+			want:    0,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, billableDuration(tc.sandbox, tc.transitions, from, end))
+		})
+	}
+}
+
+func TestUsageReportGroupsAndPrices(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	users := user.NewRegistry(user.NewStore(store))
+	alice, err := users.AddUser(ctx, "alice", reportTestKey(t), user.RoleUser)
+	require.NoError(t, err)
+	bob, err := users.AddUser(ctx, "bob", reportTestKey(t), user.RoleUser)
+	require.NoError(t, err)
+	_, err = users.CreateTeam(ctx, "platform", "", alice.ID)
+	require.NoError(t, err)
+
+	profiles := NewProfileRegistry(map[string]models.Profile{
+		"small": {Name: "small", RawYAML: "name: small\nresources:\n  cores: 2\n  memory_mb: 4096\n"},
+		"big":   {Name: "big", RawYAML: "name: big\nresources:\n  cores: 8\n  memory_mb: 16384\n"},
+	})
+	start := time.Now().UTC().Add(-time.Minute)
+	sandboxes := []models.Sandbox{
+		{VMID: 201, Name: "a", Profile: "small", Owner: alice.ID, Tags: "ci,nightly", State: models.SandboxRunning, CreatedAt: start, LastUpdatedAt: start},
+		{VMID: 202, Name: "b", Profile: "big", Owner: bob.ID, State: models.SandboxRunning, CreatedAt: start, LastUpdatedAt: start},
+		{VMID: 203, Name: "c", Profile: "small", State: models.SandboxStopped, CreatedAt: start, LastUpdatedAt: start},
+	}
+	for _, sb := range sandboxes {
+		require.NoError(t, store.CreateSandbox(ctx, sb))
+		if sb.State != models.SandboxRunning {
+			continue
+		}
+		vmid := sb.VMID
+		require.NoError(t, store.RecordEvent(ctx, string(EventKindSandboxState), &vmid, nil, "BOOTING -> RUNNING",
+			`{"from_state":"BOOTING","to_state":"RUNNING"}`))
+	}
+	require.NoError(t, store.RecordLLMUsage(ctx, db.LLMUsage{VMID: 201, Integration: "openai", Provider: "openai", InputTokens: 2_000_000, OutputTokens: 500_000}))
+
+	api := NewReportAPI(store, profiles, users, UsagePrices{Currency: "EUR", CoreHour: 0.5, MemoryGiBHour: 0.25, InputMTok: 1, OutputMTok: 4})
+	// Two hours after the sandboxes started; the window runs further but the
+	// future is not billed.
+	now := time.Now().UTC().Add(2 * time.Hour)
+	api.now = func() time.Time { return now }
+	report := func(groupBy string) V1UsageReportResponse {
+		t.Helper()
+		query := url.Values{
+			"from":     {start.Add(-time.Hour).Format(time.RFC3339)},
+			"to":       {now.Add(time.Hour).Format(time.RFC3339)},
+			"group_by": {groupBy},
+		}
+		rec := httptest.NewRecorder()
+		api.handleUsageReport(rec, httptest.NewRequest(http.MethodGet, "/v1/reports/usage?"+query.Encode(), nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp V1UsageReportResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+
+	byOwner := report("")
+	assert.Equal(t, ReportGroupOwner, byOwner.GroupBy)
+	assert.Equal(t, "EUR", byOwner.Currency)
+	require.Len(t, byOwner.Rows, 2, "sandbox 203 never ran and used no tokens")
+	rows := make(map[string]V1UsageReportRow)
+	for _, row := range byOwner.Rows {
+		rows[row.Group] = row
+	}
+	bobRow, aliceRow := rows["bob"], rows["alice"]
+	assert.Equal(t, "bob", byOwner.Rows[0].Group, "rows sort by descending cost")
+	assert.InDelta(t, 2, bobRow.Hours, 0.05)
+	assert.InDelta(t, 16, bobRow.CoreHours, 0.2)
+	assert.InDelta(t, 32, bobRow.MemoryGiBHours, 0.4)
+	assert.InDelta(t, 16, bobRow.ComputeCost, 0.2)
+	assert.InDelta(t, 4, aliceRow.CoreHours, 0.05)
+	assert.Equal(t, int64(2_000_000), aliceRow.InputTokens)
+	assert.InDelta(t, 4, aliceRow.TokenCost, 1e-9)
+	assert.InDelta(t, aliceRow.ComputeCost+aliceRow.TokenCost, aliceRow.TotalCost, 1e-9)
+	assert.Equal(t, 2, byOwner.Total.Sandboxes)
+	assert.InDelta(t, aliceRow.TotalCost+bobRow.TotalCost, byOwner.Total.TotalCost, 1e-9)
+
+	byTeam := report(ReportGroupTeam)
+	teams := make(map[string]V1UsageReportRow)
+	for _, row := range byTeam.Rows {
+		teams[row.Group] = row
+	}
+	assert.InDelta(t, aliceRow.TotalCost, teams["platform"].TotalCost, 1e-9)
+	assert.Equal(t, 1, teams[reportGroupNone].Sandboxes)
+
+	byTag := report(ReportGroupTag)
+	tags := make(map[string]V1UsageReportRow)
+	for _, row := range byTag.Rows {
+		tags[row.Group] = row
+	}
+	assert.InDelta(t, aliceRow.TotalCost, tags["ci"].TotalCost, 1e-9)
+	assert.InDelta(t, aliceRow.TotalCost, tags["nightly"].TotalCost, 1e-9)
+	assert.InDelta(t, byTag.Total.TotalCost, byOwner.Total.TotalCost, 1e-9, "overlapping tags do not inflate the total")
+
+	byProfile := report(ReportGroupProfile)
+	require.Len(t, byProfile.Rows, 2)
+	assert.Equal(t, "big", byProfile.Rows[0].Group)
+
+	for _, query := range []string{"group_by=region", "from=yesterday", "from=2026-03-02&to=2026-03-01", "from=2020-01-01&to=2026-01-01"} {
+		rec := httptest.NewRecorder()
+		api.handleUsageReport(rec, httptest.NewRequest(http.MethodGet, "/v1/reports/usage?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func reportTestKey(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
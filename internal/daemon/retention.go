@@ -0,0 +1,628 @@
+package daemon
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+)
+
+const (
+	archiveDirPerms       = 0o700
+	archiveFilePerms      = 0o600
+	archiveFileSuffix     = ".jsonl.gz"
+	compactionBatchSize   = 500
+	compactionMaxRows     = 50000 // Per table and run; the next run picks up the rest
+	eventRetentionDefault = "default"
+)
+
+// Archive streams and the record types written to them.
+const (
+	archiveStreamEvents   = "events"
+	archiveStreamMessages = "messages"
+	archiveStreamAudit    = "audit"
+
+	ArchiveRecordEvent   = "event"
+	ArchiveRecordMessage = "message"
+	ArchiveRecordAudit   = "audit"
+	ArchiveRecordError   = "error"
+)
+
+// RetentionConfig controls how long events, messages and audit entries are
+// kept and where expired rows are archived. A zero retention keeps rows
+// forever.
+type RetentionConfig struct {
+	Events     map[string]time.Duration
+	Messages   time.Duration
+	AuditLog   time.Duration
+	Interval   time.Duration
+	ArchiveDir string
+}
+
+// CompactionResult describes one compaction run.
+type CompactionResult struct {
+	Events         int      `json:"events"`
+	Messages       int      `json:"messages"`
+	AuditLog       int      `json:"audit_log"`
+	ThroughEventID int64    `json:"through_event_id,omitempty"`
+	Archives       []string `json:"archives,omitempty"`
+}
+
+type compactionFailedPayload struct {
+	Error string `json:"error"`
+}
+
+// RetentionManager compacts and archives expired events, messages and audit
+// log entries, and exports them for off-host retention.
+//
+// Events older than the shortest configured event retention are folded into
+// the event_snapshots projection before anything is deleted, so sandbox
+// health and job timelines survive compaction. Rows are written to gzipped
+// JSONL archives under ArchiveDir, and the archive is synced before the rows
+// are deleted.
+type RetentionManager struct {
+	store  *db.Store
+	cfg    RetentionConfig
+	logger *log.Logger
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewRetentionManager constructs a retention manager.
+func NewRetentionManager(store *db.Store, cfg RetentionConfig, logger *log.Logger) *RetentionManager {
+	if logger == nil {
+		logger = log.Default()
+	}
+	events := make(map[string]time.Duration, len(cfg.Events))
+	for domain, retention := range cfg.Events {
+		events[strings.ToLower(strings.TrimSpace(domain))] = retention
+	}
+	cfg.Events = events
+	cfg.ArchiveDir = strings.TrimSpace(cfg.ArchiveDir)
+	for domain := range events {
+		if domain != eventRetentionDefault && !isKnownEventDomain(EventDomain(domain)) {
+			logger.Printf("agentlabd: event_retention: unknown event domain %q", domain)
+		}
+	}
+	return &RetentionManager{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Enabled reports whether any retention is configured.
+func (m *RetentionManager) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	if m.cfg.Messages > 0 || m.cfg.AuditLog > 0 {
+		return true
+	}
+	return m.shortestEventRetention() > 0
+}
+
+// Start compacts immediately and then every configured interval until ctx is
+// done. It does nothing when no retention is configured.
+func (m *RetentionManager) Start(ctx context.Context) {
+	if m == nil || m.store == nil || m.cfg.Interval <= 0 || !m.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(m.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		// Compact logs and records its own failures.
+		_, _ = m.Compact(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = m.Compact(ctx)
+			}
+		}
+	}()
+}
+
+// Compact runs one compaction pass over events, messages and the audit log.
+func (m *RetentionManager) Compact(ctx context.Context) (CompactionResult, error) {
+	if m == nil || m.store == nil {
+		return CompactionResult{}, errors.New("retention manager unavailable")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().UTC()
+	var result CompactionResult
+	err := m.compact(ctx, now, &result)
+	if err != nil {
+		m.logger.Printf("agentlabd: compaction failed: %v", err)
+		_ = emitEvent(ctx, NewStoreEventRecorder(m.store), EventKindRetentionCompactionFailed, nil, nil, "event compaction failed", compactionFailedPayload{
+			Error: err.Error(),
+		})
+		return result, err
+	}
+	if result.Events == 0 && result.Messages == 0 && result.AuditLog == 0 {
+		return result, nil
+	}
+	m.logger.Printf("agentlabd: compaction archived %d events, %d messages, %d audit entries", result.Events, result.Messages, result.AuditLog)
+	_ = emitEvent(ctx, NewStoreEventRecorder(m.store), EventKindRetentionCompacted, nil, nil, "expired events compacted", result)
+	return result, nil
+}
+
+func (m *RetentionManager) compact(ctx context.Context, now time.Time, result *CompactionResult) error {
+	stamp := now.Format(backupStampLayout)
+	if err := m.compactEvents(ctx, now, stamp, result); err != nil {
+		return err
+	}
+	if err := m.compactMessages(ctx, now, stamp, result); err != nil {
+		return err
+	}
+	return m.compactAuditLog(ctx, now, stamp, result)
+}
+
+// compactEvents folds every event older than the shortest event retention
+// into the snapshot and deletes those past their own domain's retention.
+// Events that are folded but kept stay readable; projection readers skip them
+// because their id is at or below the snapshot's through_event_id.
+func (m *RetentionManager) compactEvents(ctx context.Context, now time.Time, stamp string, result *CompactionResult) error {
+	shortest := m.shortestEventRetention()
+	if shortest <= 0 {
+		return nil
+	}
+	horizon := now.Add(-shortest)
+	snapshot, _, err := m.store.GetEventSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	projection := NewEventProjection()
+	if err := projection.Restore(snapshot); err != nil {
+		return err
+	}
+
+	archive := newArchiveWriter(m.cfg.ArchiveDir, archiveStreamEvents, stamp)
+	defer archive.abort()
+	cursor := snapshot.ThroughEventID
+	var expired []int64
+	scanned := 0
+scan:
+	for scanned < compactionMaxRows {
+		events, err := m.store.ListEventsAfterID(ctx, cursor, compactionBatchSize)
+		if err != nil {
+			return err
+		}
+		var folded []db.Event
+		for _, ev := range events {
+			if !ev.Timestamp.Before(horizon) {
+				projection.Replay(folded)
+				cursor = lastEventID(folded, cursor)
+				break scan
+			}
+			folded = append(folded, ev)
+			if retention := m.eventRetention(ev.Kind); retention > 0 && ev.Timestamp.Before(now.Add(-retention)) {
+				v1 := eventToV1(ev)
+				if err := archive.write(V1ArchiveRecord{Type: ArchiveRecordEvent, Timestamp: v1.Timestamp, Event: &v1}); err != nil {
+					return err
+				}
+				expired = append(expired, ev.ID)
+			}
+		}
+		projection.Replay(folded)
+		cursor = lastEventID(folded, cursor)
+		scanned += len(events)
+		if len(events) < compactionBatchSize {
+			break
+		}
+	}
+	if cursor == snapshot.ThroughEventID {
+		return nil
+	}
+	path, err := archive.commit()
+	if err != nil {
+		return err
+	}
+	state, err := projection.Snapshot()
+	if err != nil {
+		return err
+	}
+	// The archive is durable before the rows go. If this fails the rows are
+	// kept and the next run archives them again.
+	if err := m.store.CompactEvents(ctx, db.EventSnapshot{
+		ThroughEventID:  cursor,
+		CompactedEvents: snapshot.CompactedEvents + int64(len(expired)),
+		State:           state,
+		UpdatedAt:       now,
+	}, expired); err != nil {
+		return err
+	}
+	result.Events = len(expired)
+	result.ThroughEventID = cursor
+	if path != "" {
+		result.Archives = append(result.Archives, path)
+	}
+	return nil
+}
+
+func (m *RetentionManager) compactMessages(ctx context.Context, now time.Time, stamp string, result *CompactionResult) error {
+	if m.cfg.Messages <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-m.cfg.Messages)
+	archive := newArchiveWriter(m.cfg.ArchiveDir, archiveStreamMessages, stamp)
+	defer archive.abort()
+	var ids []int64
+	var cursor int64
+	for len(ids) < compactionMaxRows {
+		messages, err := m.store.ListMessagesBefore(ctx, cutoff, cursor, compactionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			v1 := messageToV1(msg)
+			if err := archive.write(V1ArchiveRecord{Type: ArchiveRecordMessage, Timestamp: v1.Timestamp, Message: &v1}); err != nil {
+				return err
+			}
+			ids = append(ids, msg.ID)
+			cursor = msg.ID
+		}
+		if len(messages) < compactionBatchSize {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	path, err := archive.commit()
+	if err != nil {
+		return err
+	}
+	if _, err := m.store.DeleteMessages(ctx, ids); err != nil {
+		return err
+	}
+	result.Messages = len(ids)
+	result.Archives = append(result.Archives, path)
+	return nil
+}
+
+func (m *RetentionManager) compactAuditLog(ctx context.Context, now time.Time, stamp string, result *CompactionResult) error {
+	if m.cfg.AuditLog <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-m.cfg.AuditLog)
+	archive := newArchiveWriter(m.cfg.ArchiveDir, archiveStreamAudit, stamp)
+	defer archive.abort()
+	var ids []int64
+	var cursor int64
+	for len(ids) < compactionMaxRows {
+		records, err := m.store.ListAuditRecordsBefore(ctx, cutoff, cursor, compactionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			v1 := auditRecordToV1(record)
+			if err := archive.write(V1ArchiveRecord{Type: ArchiveRecordAudit, Timestamp: v1.Timestamp, Audit: &v1}); err != nil {
+				return err
+			}
+			ids = append(ids, record.ID)
+			cursor = record.ID
+		}
+		if len(records) < compactionBatchSize {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	path, err := archive.commit()
+	if err != nil {
+		return err
+	}
+	if _, err := m.store.DeleteAuditRecords(ctx, ids); err != nil {
+		return err
+	}
+	result.AuditLog = len(ids)
+	result.Archives = append(result.Archives, path)
+	return nil
+}
+
+// Export writes every archived and live event, message and audit record at
+// or after since to w as JSONL, and returns how many records it wrote. A zero
+// since exports everything. Archives come first, oldest file first, followed
+// by the live tables.
+func (m *RetentionManager) Export(ctx context.Context, since time.Time, w io.Writer) (int, error) {
+	if m == nil || m.store == nil {
+		return 0, errors.New("retention manager unavailable")
+	}
+	// Hold off compaction so rows cannot move from the live tables into an
+	// archive that has already been read.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	written := 0
+	emit := func(record V1ArchiveRecord) error {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		written++
+		return nil
+	}
+	archives, err := m.listArchives()
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range archives {
+		if err := exportArchive(path, since, emit); err != nil {
+			return written, err
+		}
+	}
+	if err := m.exportLive(ctx, since, emit); err != nil {
+		return written, err
+	}
+	return written, nil
+}
+
+func (m *RetentionManager) exportLive(ctx context.Context, since time.Time, emit func(V1ArchiveRecord) error) error {
+	for cursor := int64(0); ; {
+		events, err := m.store.ListEventsSince(ctx, since, cursor, compactionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			v1 := eventToV1(ev)
+			if err := emit(V1ArchiveRecord{Type: ArchiveRecordEvent, Timestamp: v1.Timestamp, Event: &v1}); err != nil {
+				return err
+			}
+			cursor = ev.ID
+		}
+		if len(events) < compactionBatchSize {
+			break
+		}
+	}
+	for cursor := int64(0); ; {
+		messages, err := m.store.ListMessagesSince(ctx, since, cursor, compactionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			v1 := messageToV1(msg)
+			if err := emit(V1ArchiveRecord{Type: ArchiveRecordMessage, Timestamp: v1.Timestamp, Message: &v1}); err != nil {
+				return err
+			}
+			cursor = msg.ID
+		}
+		if len(messages) < compactionBatchSize {
+			break
+		}
+	}
+	for cursor := int64(0); ; {
+		records, err := m.store.ListAuditRecordsSince(ctx, since, cursor, compactionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			v1 := auditRecordToV1(record)
+			if err := emit(V1ArchiveRecord{Type: ArchiveRecordAudit, Timestamp: v1.Timestamp, Audit: &v1}); err != nil {
+				return err
+			}
+			cursor = record.ID
+		}
+		if len(records) < compactionBatchSize {
+			break
+		}
+	}
+	return nil
+}
+
+// listArchives returns archive paths ordered by compaction time, then stream.
+func (m *RetentionManager) listArchives() ([]string, error) {
+	if m.cfg.ArchiveDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(m.cfg.ArchiveDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read archive dir %s: %w", m.cfg.ArchiveDir, err)
+	}
+	type archiveFile struct {
+		stamp  string
+		stream string
+		path   string
+	}
+	var files []archiveFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, archiveFileSuffix) {
+			continue
+		}
+		stream, stamp, ok := strings.Cut(strings.TrimSuffix(name, archiveFileSuffix), "-")
+		if !ok {
+			continue
+		}
+		files = append(files, archiveFile{stamp: stamp, stream: stream, path: filepath.Join(m.cfg.ArchiveDir, name)})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].stamp != files[j].stamp {
+			return files[i].stamp < files[j].stamp
+		}
+		return files[i].stream < files[j].stream
+	})
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+	return paths, nil
+}
+
+func exportArchive(path string, since time.Time, emit func(V1ArchiveRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open archive %s: %w", path, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read archive %s: %w", path, err)
+	}
+	defer gz.Close()
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record V1ArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("decode archive %s: %w", path, err)
+		}
+		if !since.IsZero() {
+			ts, err := time.Parse(time.RFC3339Nano, record.Timestamp)
+			if err == nil && ts.Before(since) {
+				continue
+			}
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read archive %s: %w", path, err)
+	}
+	return nil
+}
+
+// eventRetention returns the retention for an event kind: its domain's
+// setting, else the default. The domain comes from the event catalog, or the
+// kind's first dotted segment for kinds outside it.
+func (m *RetentionManager) eventRetention(kind string) time.Duration {
+	domain := ""
+	if schema, ok := EventCatalog[EventKind(kind)]; ok {
+		domain = string(schema.Domain)
+	} else {
+		domain, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(kind)), ".")
+	}
+	if retention, ok := m.cfg.Events[domain]; ok {
+		return retention
+	}
+	return m.cfg.Events[eventRetentionDefault]
+}
+
+func (m *RetentionManager) shortestEventRetention() time.Duration {
+	var shortest time.Duration
+	for _, retention := range m.cfg.Events {
+		if retention > 0 && (shortest == 0 || retention < shortest) {
+			shortest = retention
+		}
+	}
+	return shortest
+}
+
+func isKnownEventDomain(domain EventDomain) bool {
+	for _, schema := range EventCatalog {
+		if schema.Domain == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func lastEventID(events []db.Event, fallback int64) int64 {
+	if len(events) == 0 {
+		return fallback
+	}
+	return events[len(events)-1].ID
+}
+
+// archiveWriter writes one gzipped JSONL archive. The file is created on the
+// first write under a temporary name and renamed into place by commit.
+type archiveWriter struct {
+	dir    string
+	name   string
+	tmp    *os.File
+	gz     *gzip.Writer
+	enc    *json.Encoder
+	closed bool
+}
+
+func newArchiveWriter(dir, stream, stamp string) *archiveWriter {
+	return &archiveWriter{dir: dir, name: stream + "-" + stamp + archiveFileSuffix}
+}
+
+func (a *archiveWriter) write(record V1ArchiveRecord) error {
+	if a.tmp == nil {
+		if a.dir == "" {
+			return errors.New("archive_dir is not configured")
+		}
+		if err := os.MkdirAll(a.dir, archiveDirPerms); err != nil {
+			return fmt.Errorf("create archive dir %s: %w", a.dir, err)
+		}
+		tmp, err := os.CreateTemp(a.dir, "."+a.name+".*.tmp")
+		if err != nil {
+			return fmt.Errorf("create archive: %w", err)
+		}
+		if err := tmp.Chmod(archiveFilePerms); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+			return fmt.Errorf("chmod archive: %w", err)
+		}
+		a.tmp = tmp
+		a.gz = gzip.NewWriter(tmp)
+		a.enc = json.NewEncoder(a.gz)
+	}
+	if err := a.enc.Encode(record); err != nil {
+		return fmt.Errorf("write archive %s: %w", a.name, err)
+	}
+	return nil
+}
+
+// commit flushes and syncs the archive and moves it into place. It returns
+// the final path, or "" when nothing was written.
+func (a *archiveWriter) commit() (string, error) {
+	if a.tmp == nil {
+		return "", nil
+	}
+	a.closed = true
+	tmpPath := a.tmp.Name()
+	defer os.Remove(tmpPath)
+	if err := a.gz.Close(); err != nil {
+		_ = a.tmp.Close()
+		return "", fmt.Errorf("close archive %s: %w", a.name, err)
+	}
+	if err := a.tmp.Sync(); err != nil {
+		_ = a.tmp.Close()
+		return "", fmt.Errorf("sync archive %s: %w", a.name, err)
+	}
+	if err := a.tmp.Close(); err != nil {
+		return "", fmt.Errorf("close archive %s: %w", a.name, err)
+	}
+	path := filepath.Join(a.dir, a.name)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("archive %s already exists", path)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("finalize archive %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// abort discards an archive that was not committed.
+func (a *archiveWriter) abort() {
+	if a.tmp == nil || a.closed {
+		return
+	}
+	a.closed = true
+	_ = a.tmp.Close()
+	_ = os.Remove(a.tmp.Name())
+}
@@ -0,0 +1,282 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+)
+
+var retentionTestNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestRetentionManager(t *testing.T, store *db.Store, cfg RetentionConfig) *RetentionManager {
+	t.Helper()
+	if cfg.ArchiveDir == "" {
+		cfg.ArchiveDir = filepath.Join(t.TempDir(), "archive")
+	}
+	m := NewRetentionManager(store, cfg, log.New(io.Discard, "", 0))
+	m.now = func() time.Time { return retentionTestNow }
+	return m
+}
+
+// recordEventAt records an event through the event contract and backdates it.
+func recordEventAt(t *testing.T, store *db.Store, ts time.Time, kind EventKind, vmid *int, jobID *string, payload any) int64 {
+	t.Helper()
+	ctx := context.Background()
+	if err := emitEvent(ctx, NewStoreEventRecorder(store), kind, vmid, jobID, string(kind), payload); err != nil {
+		t.Fatalf("emit %s: %v", kind, err)
+	}
+	var id int64
+	if err := store.DB.QueryRowContext(ctx, `SELECT MAX(id) FROM events`).Scan(&id); err != nil {
+		t.Fatalf("read event id: %v", err)
+	}
+	if _, err := store.DB.ExecContext(ctx, `UPDATE events SET ts = ? WHERE id = ?`, ts.UTC().Format("2006-01-02T15:04:05.000000000Z07:00"), id); err != nil {
+		t.Fatalf("backdate event: %v", err)
+	}
+	return id
+}
+
+func readArchive(t *testing.T, path string) []V1ArchiveRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	return decodeArchiveRecords(t, gz)
+}
+
+func decodeArchiveRecords(t *testing.T, r io.Reader) []V1ArchiveRecord {
+	t.Helper()
+	var records []V1ArchiveRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record V1ArchiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scan records: %v", err)
+	}
+	return records
+}
+
+func TestRetentionManagerCompactsEvents(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	vmid := 1101
+	jobID := "job-retention"
+	old := retentionTestNow.Add(-72 * time.Hour)
+
+	sandboxID := recordEventAt(t, store, old, EventKindSandboxState, &vmid, nil, map[string]any{
+		"from_state": "PROVISIONING",
+		"to_state":   "RUNNING",
+	})
+	jobCreatedID := recordEventAt(t, store, old.Add(time.Minute), EventKindJobCreated, nil, &jobID, map[string]any{"status": "QUEUED"})
+	recordEventAt(t, store, retentionTestNow.Add(-time.Hour), EventKindJobFailed, nil, &jobID, nil)
+
+	m := newTestRetentionManager(t, store, RetentionConfig{
+		Events: map[string]time.Duration{"default": 48 * time.Hour, "sandbox": 0},
+	})
+	result, err := m.Compact(ctx)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if result.Events != 1 || result.ThroughEventID != jobCreatedID || len(result.Archives) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	archived := readArchive(t, result.Archives[0])
+	if len(archived) != 1 || archived[0].Type != ArchiveRecordEvent || archived[0].Event == nil || archived[0].Event.ID != jobCreatedID {
+		t.Fatalf("archive = %+v", archived)
+	}
+	info, err := os.Stat(result.Archives[0])
+	if err != nil {
+		t.Fatalf("stat archive: %v", err)
+	}
+	if info.Mode().Perm() != archiveFilePerms {
+		t.Fatalf("archive mode = %v", info.Mode().Perm())
+	}
+
+	events, err := store.ListAllEvents(ctx)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	kept := map[int64]bool{}
+	for _, ev := range events {
+		kept[ev.ID] = true
+	}
+	if !kept[sandboxID] || kept[jobCreatedID] {
+		t.Fatalf("sandbox event kept = %v, job.created kept = %v; want true, false", kept[sandboxID], kept[jobCreatedID])
+	}
+
+	projection, err := loadEventProjection(ctx, store)
+	if err != nil {
+		t.Fatalf("load projection: %v", err)
+	}
+	projection.Replay(events)
+	timeline := projection.JobTimelines[jobID]
+	if timeline.Status != "FAILED" || timeline.EventCount != 2 {
+		t.Fatalf("job timeline = %+v, want FAILED with both events", timeline)
+	}
+	if health := projection.SandboxHealth[vmid]; health.State != "RUNNING" {
+		t.Fatalf("sandbox health = %+v", health)
+	}
+	if got := countEvents(t, store, EventKindRetentionCompacted); got != 1 {
+		t.Fatalf("retention.compacted events = %d, want 1", got)
+	}
+
+	// Nothing else has expired: a second run leaves the snapshot alone.
+	again, err := m.Compact(ctx)
+	if err != nil {
+		t.Fatalf("second compact: %v", err)
+	}
+	if again.Events != 0 || again.ThroughEventID != 0 {
+		t.Fatalf("second result = %+v", again)
+	}
+}
+
+func TestRetentionManagerMessagesAuditAndExport(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	old := retentionTestNow.Add(-30 * 24 * time.Hour)
+	recent := retentionTestNow.Add(-time.Hour)
+	for _, ts := range []time.Time{old, recent} {
+		if _, err := store.CreateMessage(ctx, db.Message{Timestamp: ts, ScopeType: "job", ScopeID: "job-1", Author: "agent", Kind: "note", Text: "hello"}); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		if _, err := store.DB.ExecContext(ctx, `INSERT INTO audit_log (user_id, action, resource, timestamp) VALUES ('alice', 'sandbox.create', 'sandbox:1001', ?)`,
+			ts.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")); err != nil {
+			t.Fatalf("insert audit: %v", err)
+		}
+	}
+
+	m := newTestRetentionManager(t, store, RetentionConfig{
+		Messages: 7 * 24 * time.Hour,
+		AuditLog: 7 * 24 * time.Hour,
+	})
+	if !m.Enabled() {
+		t.Fatalf("expected retention to be enabled")
+	}
+	result, err := m.Compact(ctx)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if result.Messages != 1 || result.AuditLog != 1 || result.Events != 0 || len(result.Archives) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	remaining, err := store.ListMessagesSince(ctx, time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	if len(remaining) != 1 || !remaining[0].Timestamp.Equal(recent) {
+		t.Fatalf("remaining messages = %+v", remaining)
+	}
+
+	var all bytes.Buffer
+	n, err := m.Export(ctx, time.Time{}, &all)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	records := decodeArchiveRecords(t, &all)
+	if n != len(records) {
+		t.Fatalf("export count = %d, records = %d", n, len(records))
+	}
+	counts := map[string]int{}
+	for _, record := range records {
+		counts[record.Type]++
+	}
+	// The archived and live message and audit rows, plus the
+	// retention.compacted event.
+	if counts[ArchiveRecordMessage] != 2 || counts[ArchiveRecordAudit] != 2 || counts[ArchiveRecordEvent] != 1 {
+		t.Fatalf("export counts = %v", counts)
+	}
+
+	var since bytes.Buffer
+	if _, err := m.Export(ctx, retentionTestNow.Add(-24*time.Hour), &since); err != nil {
+		t.Fatalf("export since: %v", err)
+	}
+	for _, record := range decodeArchiveRecords(t, &since) {
+		if record.Type == ArchiveRecordAudit && record.Audit.Timestamp != recent.Format(time.RFC3339Nano) {
+			t.Fatalf("export since returned old audit record %+v", record.Audit)
+		}
+		if record.Type == ArchiveRecordMessage && record.Message.Timestamp != recent.Format(time.RFC3339Nano) {
+			t.Fatalf("export since returned old message %+v", record.Message)
+		}
+	}
+}
+
+func TestRetentionManagerDisabled(t *testing.T) {
+	store := newTestStore(t)
+	m := newTestRetentionManager(t, store, RetentionConfig{Events: map[string]time.Duration{"job": 0}})
+	if m.Enabled() {
+		t.Fatalf("zero retention must keep everything")
+	}
+	result, err := m.Compact(context.Background())
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if result.Events != 0 || len(result.Archives) != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+func TestAdminAPIEventsExport(t *testing.T) {
+	mux := http.NewServeMux()
+	NewAdminAPI(nil).Register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/events/export", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without retention status = %d, want 503", rec.Code)
+	}
+
+	store := newTestStore(t)
+	recordEventAt(t, store, retentionTestNow.Add(-48*time.Hour), EventKindJobCreated, nil, nil, nil)
+	recordEventAt(t, store, retentionTestNow.Add(-time.Hour), EventKindJobCreated, nil, nil, nil)
+	m := newTestRetentionManager(t, store, RetentionConfig{})
+	mux = http.NewServeMux()
+	NewAdminAPI(nil).WithRetentionManager(m).Register(mux)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/events/export", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/events/export?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad since status = %d, want 400", rec.Code)
+	}
+
+	since := retentionTestNow.Add(-24 * time.Hour).Format(time.RFC3339)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/events/export?since="+since, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/x-ndjson") {
+		t.Fatalf("content type = %q", ct)
+	}
+	records := decodeArchiveRecords(t, rec.Body)
+	if len(records) != 1 || records[0].Type != ArchiveRecordEvent {
+		t.Fatalf("records = %+v, want the one recent event", records)
+	}
+}
@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/user"
+)
+
+// RoleAPI manages custom roles and their bindings to users and teams.
+//
+// Roles decide what every confined user may do, so both reads and writes are
+// global: role.read and role.write, never granted to sandbox-scoped tokens.
+// Enforcement lives in RBAC and the authorize helpers, not here.
+type RoleAPI struct {
+	registry *user.Registry
+}
+
+// NewRoleAPI creates the custom role API.
+func NewRoleAPI(registry *user.Registry) *RoleAPI {
+	return &RoleAPI{registry: registry}
+}
+
+// Register registers the role endpoints on the given mux.
+func (api *RoleAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/roles", api.handleRoles)
+	mux.HandleFunc("/v1/roles/", api.handleRole)
+	mux.HandleFunc("/v1/role-bindings", api.handleBindings)
+	mux.HandleFunc("/v1/role-bindings/", api.handleBinding)
+}
+
+// V1Role is a custom role.
+type V1Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// V1RoleCreateRequest creates a custom role.
+type V1RoleCreateRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// V1RoleBinding grants a role to a subject ("user:<id>" or "team:<id>") at a
+// scope ("global", "team:<id>" or "tag:<tag>").
+type V1RoleBinding struct {
+	ID        int64  `json:"id"`
+	Role      string `json:"role"`
+	Subject   string `json:"subject"`
+	Scope     string `json:"scope"`
+	CreatedAt string `json:"created_at"`
+}
+
+// V1RoleBindingCreateRequest binds a role. Scope defaults to global.
+type V1RoleBindingCreateRequest struct {
+	Role    string `json:"role"`
+	Subject string `json:"subject"`
+	Scope   string `json:"scope,omitempty"`
+}
+
+// V1RoleListResponse lists custom roles and their bindings.
+type V1RoleListResponse struct {
+	Roles    []V1Role        `json:"roles"`
+	Bindings []V1RoleBinding `json:"bindings"`
+}
+
+func (api *RoleAPI) handleRoles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeStandalone(w, r, permRoleRead, true) {
+			return
+		}
+		api.listRoles(w, r)
+	case http.MethodPost:
+		if !authorizeStandalone(w, r, permRoleWrite, true) {
+			return
+		}
+		api.createRole(w, r)
+	default:
+		writeMethodNotAllowed(w, []string{http.MethodGet, http.MethodPost})
+	}
+}
+
+func (api *RoleAPI) handleRole(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/roles/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, []string{http.MethodDelete})
+		return
+	}
+	if !authorizeStandalone(w, r, permRoleWrite, true) {
+		return
+	}
+	if err := api.registry.RemoveRole(r.Context(), name, callerUserID(r.Context())); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "role not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to remove role")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted", "name": name})
+}
+
+func (api *RoleAPI) handleBindings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeStandalone(w, r, permRoleRead, true) {
+			return
+		}
+		api.listRoles(w, r)
+	case http.MethodPost:
+		if !authorizeStandalone(w, r, permRoleWrite, true) {
+			return
+		}
+		api.createBinding(w, r)
+	default:
+		writeMethodNotAllowed(w, []string{http.MethodGet, http.MethodPost})
+	}
+}
+
+func (api *RoleAPI) handleBinding(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/v1/role-bindings/"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, []string{http.MethodDelete})
+		return
+	}
+	if !authorizeStandalone(w, r, permRoleWrite, true) {
+		return
+	}
+	if err := api.registry.UnbindRole(r.Context(), id, callerUserID(r.Context())); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "role binding not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to remove role binding")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "deleted", "id": id})
+}
+
+func (api *RoleAPI) listRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := api.registry.ListRoles(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list roles")
+		return
+	}
+	bindings, err := api.registry.ListRoleBindings(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list role bindings")
+		return
+	}
+	resp := V1RoleListResponse{
+		Roles:    make([]V1Role, 0, len(roles)),
+		Bindings: make([]V1RoleBinding, 0, len(bindings)),
+	}
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, roleToV1(role))
+	}
+	for _, b := range bindings {
+		resp.Bindings = append(resp.Bindings, roleBindingToV1(b))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (api *RoleAPI) createRole(w http.ResponseWriter, r *http.Request) {
+	var req V1RoleCreateRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	role := user.CustomRole{
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+	}
+	seen := make(map[string]bool, len(req.Permissions))
+	for _, perm := range req.Permissions {
+		perm = strings.TrimSpace(perm)
+		if perm == "" || seen[perm] {
+			continue
+		}
+		if !validRolePermission(perm) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown permission %q", perm))
+			return
+		}
+		seen[perm] = true
+		role.Permissions = append(role.Permissions, perm)
+	}
+	created, err := api.registry.CreateRole(r.Context(), role, callerUserID(r.Context()))
+	if err != nil {
+		if isUniqueConstraint(err) {
+			writeError(w, http.StatusConflict, "role already exists")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, roleToV1(created))
+}
+
+func (api *RoleAPI) createBinding(w http.ResponseWriter, r *http.Request) {
+	var req V1RoleBindingCreateRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	subjectType, subjectID, ok := strings.Cut(strings.TrimSpace(req.Subject), ":")
+	if !ok || subjectID == "" || (subjectType != user.SubjectUser && subjectType != user.SubjectTeam) {
+		writeError(w, http.StatusBadRequest, "subject must be user:<id> or team:<id>")
+		return
+	}
+	scopeType, scopeID, err := parseRoleBindingScope(req.Scope)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	binding, err := api.registry.BindRole(r.Context(), user.RoleBinding{
+		Role:        strings.TrimSpace(req.Role),
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		ScopeType:   scopeType,
+		ScopeID:     scopeID,
+	}, callerUserID(r.Context()))
+	if err != nil {
+		switch {
+		case isUniqueConstraint(err):
+			writeError(w, http.StatusConflict, "role binding already exists")
+		case errors.Is(err, sql.ErrNoRows):
+			writeError(w, http.StatusNotFound, err.Error())
+		default:
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusCreated, roleBindingToV1(binding))
+}
+
+// parseRoleBindingScope parses "global" (or empty), "team:<id>" or
+// "tag:<tag>".
+func parseRoleBindingScope(raw string) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == user.ScopeGlobal {
+		return user.ScopeGlobal, "", nil
+	}
+	scopeType, scopeID, ok := strings.Cut(raw, ":")
+	if !ok || scopeID == "" || (scopeType != user.ScopeTeam && scopeType != user.ScopeTag) {
+		return "", "", errors.New("scope must be global, team:<id> or tag:<tag>")
+	}
+	return scopeType, scopeID, nil
+}
+
+func roleToV1(role user.CustomRole) V1Role {
+	return V1Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		CreatedAt:   role.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func roleBindingToV1(b user.RoleBinding) V1RoleBinding {
+	return V1RoleBinding{
+		ID:        b.ID,
+		Role:      b.Role,
+		Subject:   b.SubjectType + ":" + b.SubjectID,
+		Scope:     b.Scope(),
+		CreatedAt: b.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
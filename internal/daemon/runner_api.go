@@ -1,14 +1,26 @@
 package daemon
 
 import (
+	"context"
 	"errors"
 	"net"
 	"net/http"
 	"strings"
 
 	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/tracing"
 )
 
+// runnerPhases are the steps a guest runner reports progress for, in order.
+var runnerPhases = map[string]bool{
+	"bootstrap": true,
+	"setup":     true,
+	"repo":      true,
+	"agent":     true,
+	"artifacts": true,
+	"finish":    true,
+}
+
 // RunnerAPI handles guest runner status reports.
 type RunnerAPI struct {
 	orchestrator *JobOrchestrator
@@ -49,10 +61,21 @@ func (api *RunnerAPI) handleRunnerReport(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusServiceUnavailable, "job orchestration unavailable")
 		return
 	}
+	phase := strings.ToLower(strings.TrimSpace(req.Phase))
+	if phase != "" && !runnerPhases[phase] {
+		writeError(w, http.StatusBadRequest, "invalid phase")
+		return
+	}
+	if req.Heartbeat && status != models.JobRunning {
+		writeError(w, http.StatusBadRequest, "heartbeat status must be RUNNING")
+		return
+	}
 	report := JobReport{
 		JobID:     strings.TrimSpace(req.JobID),
 		VMID:      req.VMID,
 		Status:    status,
+		Phase:     phase,
+		Heartbeat: req.Heartbeat,
 		Message:   strings.TrimSpace(req.Message),
 		Artifacts: req.Artifacts,
 		Result:    req.Result,
@@ -65,7 +88,10 @@ func (api *RunnerAPI) handleRunnerReport(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "vmid must be positive")
 		return
 	}
-	if err := api.orchestrator.HandleReport(r.Context(), report); err != nil {
+	ctx, span := api.startReportSpan(r, report)
+	err = api.orchestrator.HandleReport(ctx, report)
+	endSpan(span, err)
+	if err != nil {
 		switch {
 		case errors.Is(err, ErrJobNotFound):
 			writeError(w, http.StatusNotFound, "job not found")
@@ -89,6 +115,23 @@ func (api *RunnerAPI) handleRunnerReport(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// startReportSpan starts a runner.report span in the job's trace.
+// Heartbeats are frequent and carry no state change, so they are not traced.
+func (api *RunnerAPI) startReportSpan(r *http.Request, report JobReport) (context.Context, *tracing.Span) {
+	if report.Heartbeat {
+		return r.Context(), nil
+	}
+	ctx := requestTraceContext(r, func(ctx context.Context) context.Context {
+		return jobTraceContext(ctx, api.orchestrator.store, report.JobID)
+	})
+	return tracing.Start(ctx, "runner.report", tracing.WithKind(tracing.SpanKindServer), tracing.WithAttributes(
+		tracing.String("job.id", report.JobID),
+		tracing.Int("vmid", report.VMID),
+		tracing.String("job.status", string(report.Status)),
+		tracing.String("runner.phase", report.Phase),
+	))
+}
+
 func (api *RunnerAPI) remoteAllowed(addr string) bool {
 	if api.agentSubnet == nil {
 		return true
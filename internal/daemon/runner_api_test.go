@@ -1,10 +1,17 @@
 package daemon
 
 import (
+	"context"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
 )
 
 func TestRunnerReportRejectsNonAgentSource(t *testing.T) {
@@ -21,3 +28,68 @@ func TestRunnerReportRejectsNonAgentSource(t *testing.T) {
 		t.Fatalf("expected 403, got %d", resp.Code)
 	}
 }
+
+func TestRunnerReportPhaseAndHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	orchestrator := NewJobOrchestrator(store, nil, nil, nil, nil, proxmox.SnippetStore{}, "", "", log.New(io.Discard, "", 0), nil, nil)
+	api := NewRunnerAPI(orchestrator, nil)
+
+	now := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+	vmid := 1300
+	if err := store.CreateJob(ctx, models.Job{ID: "job_hb", RepoURL: "https://example.com/repo.git", Ref: "main", Profile: "default", Status: models.JobRunning, SandboxVMID: &vmid, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	post := func(payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/runner/report", strings.NewReader(payload))
+		resp := httptest.NewRecorder()
+		api.handleRunnerReport(resp, req)
+		return resp
+	}
+
+	if resp := post(`{"job_id":"job_hb","vmid":1300,"status":"RUNNING","phase":"repo","message":"repo ready"}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	job, err := store.GetJob(ctx, "job_hb")
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	result := job.ResultJSON
+	events, err := store.ListEventsByJobAll(ctx, "job_hb")
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || !strings.Contains(events[0].JSON, `"phase":"repo"`) {
+		t.Fatalf("expected one job.report event with phase, got %+v", events)
+	}
+
+	if resp := post(`{"job_id":"job_hb","vmid":1300,"status":"RUNNING","phase":"agent","heartbeat":true}`); resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 for heartbeat, got %d: %s", resp.Code, resp.Body.String())
+	}
+	job, err = store.GetJob(ctx, "job_hb")
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.ResultJSON != result || !job.UpdatedAt.After(now) {
+		t.Fatalf("heartbeat should only advance updated_at: result=%s updated_at=%s", job.ResultJSON, job.UpdatedAt)
+	}
+	if events, _ := store.ListEventsByJobAll(ctx, "job_hb"); len(events) != 1 {
+		t.Fatalf("heartbeat should not record events, got %d", len(events))
+	}
+
+	for payload, want := range map[string]int{
+		`{"job_id":"job_hb","vmid":1300,"status":"COMPLETED","heartbeat":true}`: http.StatusBadRequest,
+		`{"job_id":"job_hb","vmid":1300,"status":"RUNNING","phase":"lunch"}`:    http.StatusBadRequest,
+		`{"job_id":"job_hb","vmid":1301,"status":"RUNNING","heartbeat":true}`:   http.StatusConflict,
+	} {
+		if resp := post(payload); resp.Code != want {
+			t.Fatalf("%s: expected %d, got %d", payload, want, resp.Code)
+		}
+	}
+	if err := store.UpdateJobStatus(ctx, "job_hb", models.JobFailed); err != nil {
+		t.Fatalf("update job: %v", err)
+	}
+	if resp := post(`{"job_id":"job_hb","vmid":1300,"status":"RUNNING","heartbeat":true}`); resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 heartbeat for finalized job, got %d", resp.Code)
+	}
+}
@@ -151,7 +151,16 @@ func (b *sandboxBackendAdapter) CurrentStats(ctx context.Context, vmid proxmox.V
 	if err != nil {
 		return proxmox.VMStats{}, err
 	}
-	return proxmox.VMStats{CPUUsage: stats.CPUUsage}, nil
+	return proxmox.VMStats{
+		CPUUsage:       stats.CPUUsage,
+		CPUs:           stats.CPUs,
+		MemoryBytes:    stats.MemoryBytes,
+		MemoryMaxBytes: stats.MemoryMaxBytes,
+		DiskReadBytes:  stats.DiskReadBytes,
+		DiskWriteBytes: stats.DiskWriteBytes,
+		NetInBytes:     stats.NetInBytes,
+		NetOutBytes:    stats.NetOutBytes,
+	}, nil
 }
 
 func (b *sandboxBackendAdapter) GuestIP(ctx context.Context, vmid proxmox.VMID) (string, error) {
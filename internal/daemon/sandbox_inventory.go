@@ -80,36 +80,43 @@ func (api *ControlAPI) handleSandboxReconcile(w http.ResponseWriter, r *http.Req
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	before, err := api.collectSandboxInventory(r.Context())
+	resp, err := api.reconcileSandboxInventory(r.Context(), req.Apply)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// reconcileSandboxInventory compares sandbox rows with the backend inventory
+// and, when apply is set, repairs the drift it can and reports the result.
+func (api *ControlAPI) reconcileSandboxInventory(ctx context.Context, apply bool) (V1SandboxReconcileResponse, error) {
+	before, err := api.collectSandboxInventory(ctx)
+	if err != nil {
+		return V1SandboxReconcileResponse{}, err
+	}
 	resp := V1SandboxReconcileResponse{
-		DryRun:  !req.Apply,
+		DryRun:  !apply,
 		Checked: len(before),
 		Drifted: countDriftedInventory(before),
 		Results: inventoryRecordsToV1(before),
 	}
-	if !req.Apply {
-		writeJSON(w, http.StatusOK, resp)
-		return
+	if !apply {
+		return resp, nil
 	}
-	if err := api.applySandboxReconcile(r.Context(), before); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
+	if err := api.applySandboxReconcile(ctx, before); err != nil {
+		return V1SandboxReconcileResponse{}, err
 	}
-	after, err := api.collectSandboxInventory(r.Context())
+	after, err := api.collectSandboxInventory(ctx)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
-		return
+		return V1SandboxReconcileResponse{}, err
 	}
 	resp.DryRun = false
 	resp.Checked = len(after)
 	resp.Drifted = countDriftedInventory(after)
 	resp.Reconciled = countReconciledInventory(before, after)
 	resp.Results = inventoryRecordsToV1(after)
-	writeJSON(w, http.StatusOK, resp)
+	return resp, nil
 }
 
 func (api *ControlAPI) collectSandboxInventory(ctx context.Context) ([]sandboxInventoryRecord, error) {
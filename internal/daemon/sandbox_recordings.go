@@ -0,0 +1,271 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/recording"
+)
+
+const (
+	// recordingContentType is the media type asciinema registers for
+	// asciicast files.
+	recordingContentType = "application/x-asciicast"
+	// maxRecordingUserLen bounds the user label stored with a recording.
+	maxRecordingUserLen = 128
+	// defaultRecordingMaxBytes applies when no artifact size limit is
+	// configured, matching the guest upload default.
+	defaultRecordingMaxBytes = 256 * 1024 * 1024
+)
+
+// artifactOwnerDir returns the directory an artifact's path is relative to:
+// the job's directory, or for sandbox artifacts sandboxes/<vmid>. Job ids
+// never contain a separator, so the two layouts cannot collide.
+func artifactOwnerDir(root string, artifact db.Artifact) (string, error) {
+	if jobID := strings.TrimSpace(artifact.JobID); jobID != "" {
+		if strings.ContainsAny(jobID, `/\`) {
+			return "", errors.New("job id contains invalid path characters")
+		}
+		return filepath.Join(root, jobID), nil
+	}
+	if artifact.VMID == nil || *artifact.VMID <= 0 {
+		return "", errors.New("artifact has neither job nor sandbox")
+	}
+	return filepath.Join(root, "sandboxes", strconv.Itoa(*artifact.VMID)), nil
+}
+
+// handleSandboxRecordingUpload serves POST /v1/sandboxes/{vmid}/recordings.
+// The body is an asciicast v2 file. The user query parameter names whose
+// session it was; a scoped token's subject takes precedence, so a caller
+// limited to some sandboxes cannot attribute a session to someone else.
+func (api *ControlAPI) handleSandboxRecordingUpload(w http.ResponseWriter, r *http.Request, vmid int) {
+	root := strings.TrimSpace(api.artifactRoot)
+	if root == "" {
+		writeError(w, http.StatusInternalServerError, "artifact root is not configured")
+		return
+	}
+	if _, err := api.store.GetSandbox(r.Context(), vmid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "sandbox not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load sandbox")
+		return
+	}
+	query := r.URL.Query()
+	user, err := recordingUser(r, query.Get("user"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := api.now().UTC()
+	path := fmt.Sprintf("session-%s-%s.cast", now.Format("20060102T150405Z"), randomSuffix())
+	name := strings.TrimSpace(query.Get("name"))
+	if name == "" {
+		name = path
+	} else if err := validateArtifactName(name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dir := filepath.Join(root, "sandboxes", strconv.Itoa(vmid))
+	if err := os.MkdirAll(dir, artifactDirPerms); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create artifact directory")
+		return
+	}
+	maxBytes := api.artifactMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultRecordingMaxBytes
+	}
+	body := http.MaxBytesReader(w, r.Body, maxBytes)
+	defer body.Close()
+
+	targetPath := filepath.Join(dir, path)
+	tmpPath := targetPath + ".tmp-" + randomSuffix()
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o640)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create artifact file")
+		return
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+	}()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), body)
+	if err != nil {
+		handleUploadReadError(w, err)
+		return
+	}
+	if size == 0 {
+		writeError(w, http.StatusBadRequest, "recording body is empty")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read recording")
+		return
+	}
+	if _, _, err := recording.NewReader(file); err != nil {
+		writeError(w, http.StatusBadRequest, "body is not an asciicast v2 recording: "+err.Error())
+		return
+	}
+	if err := file.Sync(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to persist recording")
+		return
+	}
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to finalize recording")
+		return
+	}
+
+	artifact := db.Artifact{
+		VMID:      &vmid,
+		Name:      name,
+		Path:      path,
+		SizeBytes: size,
+		Sha256:    hex.EncodeToString(hash.Sum(nil)),
+		MIME:      recordingContentType,
+		Kind:      models.ArtifactKindRecording,
+		User:      user,
+		CreatedAt: now,
+	}
+	id, err := api.store.CreateArtifact(r.Context(), artifact)
+	if err != nil {
+		_ = os.Remove(targetPath)
+		writeError(w, http.StatusInternalServerError, "failed to record recording")
+		return
+	}
+	artifact.ID = id
+
+	_ = emitEvent(r.Context(), NewStoreEventRecorder(api.store), EventKindArtifactUpload, &vmid, nil, fmt.Sprintf("session recording uploaded: %s", name), map[string]any{
+		"name":       name,
+		"path":       path,
+		"vmid":       vmid,
+		"size_bytes": size,
+		"sha256":     artifact.Sha256,
+		"mime":       artifact.MIME,
+		"kind":       string(artifact.Kind),
+		"user":       user,
+	})
+	writeJSON(w, http.StatusCreated, recordingToV1(artifact))
+}
+
+// handleSandboxRecordingsList serves GET /v1/sandboxes/{vmid}/recordings.
+// Recordings outlive the sandbox until artifact retention removes them, so a
+// destroyed sandbox still lists its sessions.
+func (api *ControlAPI) handleSandboxRecordingsList(w http.ResponseWriter, r *http.Request, vmid int) {
+	artifacts, err := api.store.ListSandboxArtifacts(r.Context(), vmid, models.ArtifactKindRecording)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list recordings")
+		return
+	}
+	resp := V1SandboxRecordingsResponse{VMID: vmid, Recordings: make([]V1SandboxRecording, 0, len(artifacts))}
+	for _, artifact := range artifacts {
+		resp.Recordings = append(resp.Recordings, recordingToV1(artifact))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSandboxRecordingDownload serves GET
+// /v1/sandboxes/{vmid}/recordings/{id} as the raw asciicast file.
+func (api *ControlAPI) handleSandboxRecordingDownload(w http.ResponseWriter, r *http.Request, vmid int, rawID string) {
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid recording id")
+		return
+	}
+	root := strings.TrimSpace(api.artifactRoot)
+	if root == "" {
+		writeError(w, http.StatusInternalServerError, "artifact root is not configured")
+		return
+	}
+	artifact, err := api.store.GetArtifact(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "recording not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load recording")
+		return
+	}
+	// The id space is shared with job artifacts; only this sandbox's
+	// recordings are served here.
+	if artifact.JobID != "" || artifact.Kind != models.ArtifactKindRecording || artifact.VMID == nil || *artifact.VMID != vmid {
+		writeError(w, http.StatusNotFound, "recording not found")
+		return
+	}
+	dir, err := artifactOwnerDir(root, artifact)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "recording path is invalid")
+		return
+	}
+	path, err := safeJoin(dir, artifact.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "recording path is invalid")
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeError(w, http.StatusNotFound, "recording file missing")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to open recording")
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", recordingContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(artifact.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(artifact.Path)+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, file)
+}
+
+// recordingUser picks the user a recording is attributed to. A full-access
+// service token, such as the gateway's or the dashboard's, names the user;
+// a scoped token or one acting for a registered user is attributed to its own
+// subject.
+func recordingUser(r *http.Request, requested string) (string, error) {
+	if id := auth.FromContext(r.Context()); id != nil && id.Token != nil && (id.UserID != "" || !id.Token.IsFullAccess()) {
+		requested = id.Subject
+	}
+	user := strings.TrimSpace(requested)
+	if len(user) > maxRecordingUserLen {
+		return "", fmt.Errorf("user must be at most %d characters", maxRecordingUserLen)
+	}
+	if strings.IndexFunc(user, unicode.IsControl) >= 0 {
+		return "", errors.New("user must not contain control characters")
+	}
+	return user, nil
+}
+
+func recordingToV1(artifact db.Artifact) V1SandboxRecording {
+	resp := V1SandboxRecording{
+		ID:        artifact.ID,
+		User:      artifact.User,
+		Name:      artifact.Name,
+		SizeBytes: artifact.SizeBytes,
+		Sha256:    artifact.Sha256,
+	}
+	if artifact.VMID != nil {
+		resp.VMID = *artifact.VMID
+	}
+	if !artifact.CreatedAt.IsZero() {
+		resp.CreatedAt = artifact.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return resp
+}
@@ -0,0 +1,220 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+const testRecording = `{"version":2,"width":80,"height":24}
+[0.5,"o","$ "]
+[1.0,"i","ls\r"]
+`
+
+func TestSandboxRecordingUploadListDownload(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	root := t.TempDir()
+	api := NewControlAPI(store, map[string]models.Profile{}, nil, nil, nil, root, log.New(io.Discard, "", 0))
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	api.now = func() time.Time { return now }
+	if err := store.CreateSandbox(ctx, models.Sandbox{VMID: 120, Name: "rec", Profile: "default", State: models.SandboxRunning, CreatedAt: now}); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	api.handleSandboxByID(rec, httptest.NewRequest(http.MethodPost, "/v1/sandboxes/120/recordings?user=alice", strings.NewReader(testRecording)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var uploaded V1SandboxRecording
+	if err := json.NewDecoder(rec.Body).Decode(&uploaded); err != nil {
+		t.Fatalf("decode upload: %v", err)
+	}
+	if uploaded.User != "alice" || uploaded.VMID != 120 || uploaded.SizeBytes != int64(len(testRecording)) {
+		t.Fatalf("uploaded = %+v", uploaded)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sandboxes", "120", uploaded.Name)); err != nil {
+		t.Fatalf("recording file: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleSandboxByID(rec, httptest.NewRequest(http.MethodGet, "/v1/sandboxes/120/recordings", nil))
+	var list V1SandboxRecordingsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Recordings) != 1 || list.Recordings[0].ID != uploaded.ID {
+		t.Fatalf("list = %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleSandboxByID(rec, httptest.NewRequest(http.MethodGet, "/v1/sandboxes/120/recordings/"+strconv.FormatInt(uploaded.ID, 10), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != testRecording {
+		t.Fatalf("download status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != recordingContentType {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	// Another sandbox cannot read the recording through its own path.
+	rec = httptest.NewRecorder()
+	api.handleSandboxByID(rec, httptest.NewRequest(http.MethodGet, "/v1/sandboxes/121/recordings/"+strconv.FormatInt(uploaded.ID, 10), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("cross-sandbox download status = %d, want 404", rec.Code)
+	}
+
+	events, err := store.ListEventsBySandbox(ctx, 120, 0, 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Kind != string(EventKindArtifactUpload) || events[0].JobID != nil {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestSandboxRecordingUploadRejects(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	api := NewControlAPI(store, map[string]models.Profile{}, nil, nil, nil, t.TempDir(), log.New(io.Discard, "", 0)).
+		WithArtifactMaxBytes(128)
+	if err := store.CreateSandbox(ctx, models.Sandbox{VMID: 130, Name: "rec", Profile: "default", State: models.SandboxRunning, CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+	cases := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"unknown sandbox", "/v1/sandboxes/131/recordings", testRecording, http.StatusNotFound},
+		{"empty body", "/v1/sandboxes/130/recordings", "", http.StatusBadRequest},
+		{"not asciicast", "/v1/sandboxes/130/recordings", "hello\n", http.StatusBadRequest},
+		{"too large", "/v1/sandboxes/130/recordings", testRecording + strings.Repeat(`[2,"o","x"]`+"\n", 20), http.StatusRequestEntityTooLarge},
+		{"bad name", "/v1/sandboxes/130/recordings?name=a/b", testRecording, http.StatusBadRequest},
+		{"control character in user", "/v1/sandboxes/130/recordings?user=a%0Ab", testRecording, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		api.handleSandboxByID(rec, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d (body %s)", tc.name, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+	list, err := store.ListSandboxArtifacts(ctx, 130, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("rejected uploads left %d artifacts", len(list))
+	}
+}
+
+func TestArtifactGCExpiresRecordingsAfterSandboxDestroyed(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	now := time.Date(2026, 1, 30, 4, 0, 0, 0, time.UTC)
+	root := t.TempDir()
+
+	// 2301 was destroyed two hours ago; 2302 is still running; 2303's VMID
+	// now belongs to a sandbox created after the recording.
+	sandboxes := []models.Sandbox{
+		{VMID: 2301, Name: "destroyed", Profile: "retention-profile", State: models.SandboxDestroyed, CreatedAt: now.Add(-5 * time.Hour), LastUpdatedAt: now.Add(-2 * time.Hour)},
+		{VMID: 2302, Name: "running", Profile: "retention-profile", State: models.SandboxRunning, CreatedAt: now.Add(-5 * time.Hour), LastUpdatedAt: now.Add(-4 * time.Hour)},
+		{VMID: 2303, Name: "recycled", Profile: "retention-profile", State: models.SandboxRunning, CreatedAt: now.Add(-time.Hour), LastUpdatedAt: now.Add(-time.Hour)},
+	}
+	paths := make(map[int]string)
+	for _, sb := range sandboxes {
+		if err := store.CreateSandbox(ctx, sb); err != nil {
+			t.Fatalf("create sandbox: %v", err)
+		}
+		vmid := sb.VMID
+		artifact := db.Artifact{
+			VMID:      &vmid,
+			Name:      "session.cast",
+			Path:      "session.cast",
+			SizeBytes: int64(len(testRecording)),
+			Sha256:    "sha",
+			Kind:      models.ArtifactKindRecording,
+			User:      "alice",
+			CreatedAt: now.Add(-3 * time.Hour),
+		}
+		dir, err := artifactOwnerDir(root, artifact)
+		if err != nil {
+			t.Fatalf("owner dir: %v", err)
+		}
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		paths[vmid] = filepath.Join(dir, artifact.Path)
+		if err := os.WriteFile(paths[vmid], []byte(testRecording), 0o640); err != nil {
+			t.Fatalf("write recording: %v", err)
+		}
+		if _, err := store.CreateArtifact(ctx, artifact); err != nil {
+			t.Fatalf("create artifact: %v", err)
+		}
+	}
+
+	profiles := map[string]models.Profile{
+		"retention-profile": {
+			Name:       "retention-profile",
+			TemplateVM: 9000,
+			RawYAML:    "name: retention-profile\ntemplate_vmid: 9000\nartifacts:\n  ttl_minutes: 60\n",
+		},
+	}
+	gc := NewArtifactGC(store, profiles, root, log.New(io.Discard, "", 0), NewRedactor(nil))
+	gc.now = func() time.Time { return now }
+	gc.run(ctx)
+
+	for vmid, wantKept := range map[int]bool{2301: false, 2302: true, 2303: false} {
+		_, err := os.Stat(paths[vmid])
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("sandbox %d recording kept = %v, want %v", vmid, kept, wantKept)
+		}
+		list, err := store.ListSandboxArtifacts(ctx, vmid, models.ArtifactKindRecording)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if kept := len(list) == 1; kept != wantKept {
+			t.Errorf("sandbox %d recording row kept = %v, want %v", vmid, kept, wantKept)
+		}
+	}
+}
+
+func TestSandboxRecordingUserAttribution(t *testing.T) {
+	service := &auth.Token{Claims: auth.TokenClaims{Subject: "dashboard", Commands: []string{"*"}}}
+	scoped := &auth.Token{Claims: auth.TokenClaims{Subject: "ci-bot", Commands: []string{"*"}, Scope: []string{"130"}}}
+	cases := []struct {
+		name string
+		id   *auth.RequestIdentity
+		want string
+	}{
+		{"local socket", nil, "alice"},
+		{"full-access service token", &auth.RequestIdentity{Subject: "dashboard", Token: service, Method: "ssh-token"}, "alice"},
+		{"scoped token", &auth.RequestIdentity{Subject: "ci-bot", Token: scoped, Method: "ssh-token"}, "ci-bot"},
+		{"user token", &auth.RequestIdentity{Subject: "bob", UserID: "u-bob", Token: service, Method: "user-token"}, "bob"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/v1/sandboxes/130/recordings?user=alice", nil)
+		if tc.id != nil {
+			r = r.WithContext(auth.WithIdentity(r.Context(), tc.id))
+		}
+		got, err := recordingUser(r, "alice")
+		if err != nil || got != tc.want {
+			t.Errorf("%s: user = %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
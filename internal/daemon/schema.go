@@ -42,7 +42,10 @@ func schemaResources() []schemaResource {
 		resourceSchema("/v1/exposures", methods("GET", "POST"), "List/create exposures", "V1ExposureCreateRequest", "V1ExposuresResponse", ""),
 		resourceSchema("/v1/exposures/{name}", methods("DELETE"), "Delete exposure", "", "V1Exposure", ""),
 		resourceSchema("/v1/host", methods("GET"), "Fetch host metadata", "", "V1HostResponse", "Includes daemon version, configured subnet, and tailscale hostname when available."),
-		resourceSchema("/v1/jobs", methods("POST"), "Create jobs", "V1JobCreateRequest", "V1JobResponse", "Creation returns status 201."),
+		resourceSchema("/v1/job-groups", methods("POST"), "Create a matrix job group", "V1JobGroupCreateRequest", "V1JobGroupResponse", "Fans a base job spec out across ref, profile, task, and env axes. Creation returns status 201."),
+		resourceSchema("/v1/job-groups/{id}", methods("GET"), "Fetch job group with aggregated status", "", "V1JobGroupResponse", ""),
+		resourceSchema("/v1/job-groups/{id}/artifacts", methods("GET"), "List artifacts of every job in a group", "", "V1JobGroupArtifactsResponse", ""),
+		resourceSchema("/v1/jobs", methods("POST"), "Create jobs", "V1JobCreateRequest", "V1JobResponse", "Creation returns status 201. Jobs with depends_on stay QUEUED until their parents finish."),
 		resourceSchema("/v1/jobs/{id}", methods("GET"), "Fetch job details", "", "V1JobResponse", "Includes event history when events_tail is provided."),
 		resourceSchema("/v1/jobs/{id}/artifacts", methods("GET"), "List job artifacts", "", "V1ArtifactsResponse", ""),
 		resourceSchema("/v1/jobs/{id}/artifacts/download", methods("GET"), "Download a job artifact", "", "application/octet-stream", "Query path or name to select artifact."),
@@ -70,6 +73,7 @@ func schemaResources() []schemaResource {
 		resourceSchema("/v1/sandboxes/{vmid}/snapshots/{name}/restore", methods("POST"), "Restore sandbox snapshot", "V1SandboxSnapshotRestoreRequest", "V1SandboxSnapshotResponse", ""),
 		resourceSchema("/v1/sandboxes/{vmid}/start", methods("POST"), "Start sandbox", "", "V1SandboxResponse", ""),
 		resourceSchema("/v1/sandboxes/{vmid}/stop", methods("POST"), "Stop sandbox", "", "V1SandboxResponse", ""),
+		resourceSchema("/v1/sandboxes/{vmid}/usage", methods("GET"), "Get sandbox resource usage history", "", "V1SandboxUsageResponse", "Supports the range query parameter, e.g. 1h, 24h or 7d."),
 		resourceSchema("/v1/sandboxes/{vmid}/touch", methods("POST"), "Touch sandbox usage timestamp", "", "V1SandboxResponse", ""),
 		resourceSchema("/v1/sandboxes/{vmid}/update", methods("POST"), "Update sandbox resources", "V1SandboxUpdateRequest", "V1SandboxResponse", ""),
 		resourceSchema("/v1/sandboxes/prune", methods("POST"), "Prune orphaned sandbox entries", "", "map[string]int", ""),
@@ -0,0 +1,345 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/secrets"
+)
+
+const (
+	// defaultSecretGrantTTL applies when neither the approver nor the
+	// secret's policy sets a grant lifetime.
+	defaultSecretGrantTTL = time.Hour
+	// maxSecretGrantTTL bounds any single grant.
+	maxSecretGrantTTL = 24 * time.Hour
+	// maxSecretApprovalWait bounds how long a metadata read blocks for a
+	// decision.
+	maxSecretApprovalWait = 5 * time.Minute
+
+	secretRequestMessageAuthor = "agentlabd"
+	secretRequestMessageKind   = "secret_request"
+	secretDecisionMessageKind  = "secret_decision"
+)
+
+var (
+	errSecretRequestNotFound = errors.New("secret access request not found")
+	errSecretGrantTTLInvalid = fmt.Errorf("grant ttl must be between 1s and %s", maxSecretGrantTTL)
+)
+
+type secretAccessRequestedPayload struct {
+	RequestID       string `json:"request_id"`
+	Secret          string `json:"secret"`
+	Profile         string `json:"profile,omitempty"`
+	GrantTTLSeconds int64  `json:"grant_ttl_seconds,omitempty"`
+}
+
+type secretAccessDecidedPayload struct {
+	RequestID string `json:"request_id"`
+	Secret    string `json:"secret"`
+	DecidedBy string `json:"decided_by"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// SecretApprovals files just-in-time access requests for approval-gated
+// secrets and turns approved requests into time-boxed grants.
+//
+// Every request and decision is posted to the messagebox (the job's scope
+// when the sandbox runs a job, otherwise the sandbox's), recorded as an event,
+// and written to the audit log. Waiters blocked on a request wake as soon as
+// any request is decided.
+type SecretApprovals struct {
+	store      *db.Store
+	logger     *log.Logger
+	defaultTTL time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// NewSecretApprovals returns an approvals tracker. A non-positive defaultTTL
+// falls back to one hour.
+func NewSecretApprovals(store *db.Store, defaultTTL time.Duration, logger *log.Logger) *SecretApprovals {
+	if defaultTTL <= 0 {
+		defaultTTL = defaultSecretGrantTTL
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SecretApprovals{
+		store:      store,
+		logger:     logger,
+		defaultTTL: defaultTTL,
+		now:        time.Now,
+		changed:    make(chan struct{}),
+	}
+}
+
+// Request returns the sandbox's current request for secret. A new pending
+// request is filed when the sandbox has none, or its last grant expired. A
+// denial is final for the life of the sandbox.
+func (a *SecretApprovals) Request(ctx context.Context, sandbox models.Sandbox, jobID, secret string, policy secrets.SecretPolicy) (db.SecretAccessRequest, error) {
+	if a == nil || a.store == nil {
+		return db.SecretAccessRequest{}, errors.New("secret approvals unavailable")
+	}
+	now := a.now().UTC()
+	latest, err := a.store.LatestSecretAccessRequest(ctx, sandbox.VMID, secret, sandbox.CreatedAt)
+	switch {
+	case err == nil:
+		if latest.Status != db.SecretRequestApproved || latest.Active(now) {
+			return latest, nil
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return db.SecretAccessRequest{}, err
+	}
+
+	ttl, err := policy.GrantDuration()
+	if err != nil {
+		return db.SecretAccessRequest{}, err
+	}
+	id, err := newSecretRequestID()
+	if err != nil {
+		return db.SecretAccessRequest{}, err
+	}
+	req := db.SecretAccessRequest{
+		ID:          id,
+		SecretName:  secret,
+		SandboxVMID: sandbox.VMID,
+		SandboxName: sandbox.Name,
+		JobID:       jobID,
+		Profile:     sandbox.Profile,
+		Status:      db.SecretRequestPending,
+		GrantTTL:    ttl,
+		RequestedAt: now,
+	}
+	if err := a.store.CreateSecretAccessRequest(ctx, req); err != nil {
+		return db.SecretAccessRequest{}, err
+	}
+	text := fmt.Sprintf("Sandbox %d (%s) requests secret %s. Approve with: agentlab secrets approve %s", sandbox.VMID, sandbox.Name, secret, id)
+	a.postMessage(ctx, req, secretRequestMessageKind, text)
+	a.emit(ctx, req, EventKindSecretAccessRequested, "secret access requested", secretAccessRequestedPayload{
+		RequestID:       id,
+		Secret:          secret,
+		Profile:         sandbox.Profile,
+		GrantTTLSeconds: int64(ttl / time.Second),
+	})
+	a.audit(ctx, "sandbox:"+strconv.Itoa(sandbox.VMID), "secret.request", req, "")
+	return req, nil
+}
+
+// Decide approves or denies a pending request. An approval lasts ttl, or
+// when ttl is zero the lifetime from the secret's policy or the default.
+func (a *SecretApprovals) Decide(ctx context.Context, id string, approve bool, decidedBy string, ttl time.Duration) (db.SecretAccessRequest, error) {
+	if a == nil || a.store == nil {
+		return db.SecretAccessRequest{}, errors.New("secret approvals unavailable")
+	}
+	if ttl < 0 || ttl > maxSecretGrantTTL {
+		return db.SecretAccessRequest{}, errSecretGrantTTLInvalid
+	}
+	current, err := a.store.GetSecretAccessRequest(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.SecretAccessRequest{}, errSecretRequestNotFound
+	}
+	if err != nil {
+		return db.SecretAccessRequest{}, err
+	}
+	if ttl == 0 {
+		ttl = current.GrantTTL
+	}
+	if ttl == 0 {
+		ttl = a.defaultTTL
+	}
+	if ttl > maxSecretGrantTTL {
+		ttl = maxSecretGrantTTL
+	}
+	now := a.now().UTC()
+	status := db.SecretRequestDenied
+	var expires time.Time
+	if approve {
+		status = db.SecretRequestApproved
+		expires = now.Add(ttl)
+	}
+	decided, err := a.store.DecideSecretAccessRequest(ctx, id, status, decidedBy, now, expires)
+	if err != nil {
+		return decided, err
+	}
+	a.broadcast()
+
+	payload := secretAccessDecidedPayload{RequestID: decided.ID, Secret: decided.SecretName, DecidedBy: decidedBy}
+	if approve {
+		payload.ExpiresAt = expires.Format(time.RFC3339)
+		a.postMessage(ctx, decided, secretDecisionMessageKind, fmt.Sprintf("Secret %s approved for sandbox %d by %s until %s",
+			decided.SecretName, decided.SandboxVMID, decidedBy, payload.ExpiresAt))
+		a.emit(ctx, decided, EventKindSecretAccessApproved, "secret access approved", payload)
+		a.audit(ctx, decidedBy, "secret.approve", decided, "expires_at="+payload.ExpiresAt)
+	} else {
+		a.postMessage(ctx, decided, secretDecisionMessageKind, fmt.Sprintf("Secret %s denied for sandbox %d by %s",
+			decided.SecretName, decided.SandboxVMID, decidedBy))
+		a.emit(ctx, decided, EventKindSecretAccessDenied, "secret access denied", payload)
+		a.audit(ctx, decidedBy, "secret.deny", decided, "")
+	}
+	return decided, nil
+}
+
+// Wait blocks until the request is decided, timeout passes, or ctx is done,
+// and returns the request's latest state.
+func (a *SecretApprovals) Wait(ctx context.Context, id string, timeout time.Duration) (db.SecretAccessRequest, error) {
+	if timeout > maxSecretApprovalWait {
+		timeout = maxSecretApprovalWait
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		a.mu.Lock()
+		changed := a.changed
+		a.mu.Unlock()
+		req, err := a.store.GetSecretAccessRequest(ctx, id)
+		if err != nil || req.Status != db.SecretRequestPending {
+			return req, err
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return req, nil
+		case <-ctx.Done():
+			return req, nil
+		}
+	}
+}
+
+// List returns requests newest first, optionally filtered by status.
+func (a *SecretApprovals) List(ctx context.Context, status string, limit int) ([]db.SecretAccessRequest, error) {
+	if a == nil || a.store == nil {
+		return nil, errors.New("secret approvals unavailable")
+	}
+	return a.store.ListSecretAccessRequests(ctx, status, limit)
+}
+
+func (a *SecretApprovals) broadcast() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// postMessage records req's request or decision in the messagebox.
+func (a *SecretApprovals) postMessage(ctx context.Context, req db.SecretAccessRequest, kind, text string) {
+	scopeType, scopeID := "sandbox", strconv.Itoa(req.SandboxVMID)
+	if req.JobID != "" {
+		scopeType, scopeID = "job", req.JobID
+	}
+	payload, _ := json.Marshal(secretRequestToV1(req))
+	if _, err := a.store.CreateMessage(ctx, db.Message{
+		Timestamp: a.now().UTC(),
+		ScopeType: scopeType,
+		ScopeID:   scopeID,
+		Author:    secretRequestMessageAuthor,
+		Kind:      kind,
+		Text:      text,
+		JSON:      string(payload),
+	}); err != nil {
+		a.logger.Printf("secret approvals: record message for %s: %v", req.ID, err)
+	}
+}
+
+func (a *SecretApprovals) emit(ctx context.Context, req db.SecretAccessRequest, kind EventKind, msg string, payload any) {
+	vmid := req.SandboxVMID
+	var jobID *string
+	if req.JobID != "" {
+		id := req.JobID
+		jobID = &id
+	}
+	if err := emitEvent(ctx, NewStoreEventRecorder(a.store), kind, &vmid, jobID, msg, payload); err != nil {
+		a.logger.Printf("secret approvals: record %s for %s: %v", kind, req.ID, err)
+	}
+}
+
+func (a *SecretApprovals) audit(ctx context.Context, userID, action string, req db.SecretAccessRequest, extra string) {
+	detail := fmt.Sprintf("request=%s vmid=%d", req.ID, req.SandboxVMID)
+	if req.JobID != "" {
+		detail += " job=" + req.JobID
+	}
+	if extra != "" {
+		detail += " " + extra
+	}
+	if err := a.store.InsertAuditRecord(ctx, db.AuditRecord{
+		UserID:    userID,
+		Action:    action,
+		Resource:  "secret:" + req.SecretName,
+		Detail:    detail,
+		Timestamp: a.now().UTC(),
+	}); err != nil {
+		a.logger.Printf("secret approvals: audit %s for %s: %v", action, req.ID, err)
+	}
+}
+
+func newSecretRequestID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "secreq_" + hex.EncodeToString(buf), nil
+}
+
+// secretSubject describes sandbox as a secret reader.
+func secretSubject(sandbox models.Sandbox, jobID string) secrets.SecretSubject {
+	return secrets.SecretSubject{
+		Profile: sandbox.Profile,
+		Tags:    secrets.SplitTags(sandbox.Tags),
+		Owner:   sandbox.Owner,
+		JobID:   jobID,
+	}
+}
+
+// filterPolicyValues drops values the subject may not receive up front: those
+// whose policy does not match the subject and those that need approval.
+// Values without a policy pass through unchanged.
+func filterPolicyValues(values map[string]string, bundle secrets.Bundle, subject secrets.SecretSubject) map[string]string {
+	if len(values) == 0 || len(bundle.Policies) == 0 {
+		return values
+	}
+	out := make(map[string]string, len(values))
+	for key, value := range values {
+		if policy, ok := bundle.PolicyFor(key); ok && (policy.RequireApproval || !policy.Allows(subject)) {
+			continue
+		}
+		out[key] = value
+	}
+	return out
+}
+
+func secretRequestToV1(req db.SecretAccessRequest) V1SecretAccessRequest {
+	out := V1SecretAccessRequest{
+		ID:          req.ID,
+		Secret:      req.SecretName,
+		VMID:        req.SandboxVMID,
+		SandboxName: req.SandboxName,
+		JobID:       req.JobID,
+		Profile:     req.Profile,
+		Status:      req.Status,
+		RequestedAt: req.RequestedAt.UTC().Format(time.RFC3339),
+		DecidedBy:   req.DecidedBy,
+	}
+	if req.GrantTTL > 0 {
+		out.GrantTTLSeconds = int64(req.GrantTTL / time.Second)
+	}
+	if !req.DecidedAt.IsZero() {
+		out.DecidedAt = req.DecidedAt.UTC().Format(time.RFC3339)
+	}
+	if !req.GrantExpiresAt.IsZero() {
+		out.ExpiresAt = req.GrantExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
@@ -0,0 +1,379 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/secrets"
+)
+
+const secretPolicyTestBundle = `version: 1
+env:
+  OPEN_KEY: "open-value"
+  GATED_KEY: "gated-value"
+  OTHER_PROFILE_KEY: "other-value"
+metadata:
+  region: "us-east"
+  gated_region: "eu-west"
+policies:
+  GATED_KEY:
+    profiles: [default]
+    require_approval: true
+    grant_ttl: 30m
+  OTHER_PROFILE_KEY:
+    profiles: [other]
+  gated_region:
+    require_approval: true
+`
+
+type secretApprovalsPlane struct {
+	store     *db.Store
+	approvals *SecretApprovals
+	metadata  *MetadataAPI
+	control   *http.ServeMux
+	secret    string
+	clock     time.Time
+}
+
+func newSecretApprovalsPlane(t *testing.T) *secretApprovalsPlane {
+	t.Helper()
+	store := newTestStore(t)
+	seedSecretTestSandbox(t, store, 7001, "gated-sandbox", "10.77.7.10")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "default.yaml"), []byte(secretPolicyTestBundle), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	secretsStore := secrets.Store{Dir: dir, AllowPlaintext: true}
+	logger := log.New(io.Discard, "", 0)
+
+	p := &secretApprovalsPlane{store: store, clock: time.Now().UTC().Add(time.Second)}
+	p.approvals = NewSecretApprovals(store, time.Hour, logger)
+	p.approvals.now = func() time.Time { return p.clock }
+	p.metadata = NewMetadataAPI(store, secretsStore, "default", mustParseCIDR(t, "10.77.0.0/16"), nil, logger).
+		WithSecretApprovals(p.approvals)
+	p.control = http.NewServeMux()
+	NewSecretsAPI(secretsStore, "default", nil, logger).WithSecretApprovals(p.approvals).Register(p.control)
+	p.secret = seedSandboxSecret(t, store, 7001)
+	return p
+}
+
+func (p *secretApprovalsPlane) readSecret(t *testing.T, name string) (int, MetadataSecretResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metadata/secrets/"+name, nil)
+	req.RemoteAddr = "10.77.7.10:4321"
+	withSandboxSecret(req, p.secret)
+	rec := httptest.NewRecorder()
+	p.metadata.handleSecrets(rec, req)
+	var decoded MetadataSecretResponse
+	if rec.Code == http.StatusOK || rec.Code == http.StatusAccepted {
+		if err := json.NewDecoder(rec.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decode secret response: %v", err)
+		}
+	}
+	return rec.Code, decoded
+}
+
+func (p *secretApprovalsPlane) decide(t *testing.T, id, action, body string) (int, V1SecretAccessRequest) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/secrets/requests/"+id+"/"+action, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	p.control.ServeHTTP(rec, req)
+	var decoded V1SecretAccessRequest
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decode decision: %v", err)
+		}
+	}
+	return rec.Code, decoded
+}
+
+func TestMetadataSecretPolicies(t *testing.T) {
+	p := newSecretApprovalsPlane(t)
+
+	if code, resp := p.readSecret(t, "OPEN_KEY"); code != http.StatusOK || resp.Value != "open-value" {
+		t.Fatalf("open secret: got %d %+v, want 200 open-value", code, resp)
+	}
+	if code, _ := p.readSecret(t, "OTHER_PROFILE_KEY"); code != http.StatusForbidden {
+		t.Fatalf("secret for another profile: got %d, want 403", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metadata/metadata", nil)
+	req.RemoteAddr = "10.77.7.10:4321"
+	withSandboxSecret(req, p.secret)
+	rec := httptest.NewRecorder()
+	p.metadata.handleMetadata(rec, req)
+	var meta MetadataMetadataResponse
+	if err := json.NewDecoder(rec.Body).Decode(&meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if meta.Metadata["region"] != "us-east" {
+		t.Fatalf("metadata region = %q, want us-east", meta.Metadata["region"])
+	}
+	if _, ok := meta.Metadata["gated_region"]; ok {
+		t.Fatalf("approval-gated metadata key was listed: %+v", meta.Metadata)
+	}
+}
+
+func TestMetadataSecretApprovalLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := newSecretApprovalsPlane(t)
+
+	code, pending := p.readSecret(t, "GATED_KEY")
+	if code != http.StatusAccepted || pending.Status != db.SecretRequestPending || pending.RequestID == "" || pending.Value != "" {
+		t.Fatalf("gated secret: got %d %+v, want 202 pending without value", code, pending)
+	}
+	// Polling again reuses the pending request instead of filing another.
+	if _, again := p.readSecret(t, "GATED_KEY"); again.RequestID != pending.RequestID {
+		t.Fatalf("second read filed request %q, want %q", again.RequestID, pending.RequestID)
+	}
+	msgs, err := p.store.ListMessagesByScopeTail(ctx, "sandbox", "7001", 10)
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Kind != secretRequestMessageKind || !strings.Contains(msgs[0].Text, "agentlab secrets approve "+pending.RequestID) {
+		t.Fatalf("request messages = %+v, want one secret_request naming the approve command", msgs)
+	}
+	if got := countEvents(t, p.store, EventKindSecretAccessRequested); got != 1 {
+		t.Fatalf("requested events = %d, want 1", got)
+	}
+
+	listReq := httptest.NewRequest(http.MethodGet, "/v1/secrets/requests", nil)
+	listRec := httptest.NewRecorder()
+	p.control.ServeHTTP(listRec, listReq)
+	var list V1SecretAccessRequestsResponse
+	if err := json.NewDecoder(listRec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode request list: %v", err)
+	}
+	if len(list.Requests) != 1 || list.Requests[0].ID != pending.RequestID || list.Requests[0].GrantTTLSeconds != 1800 {
+		t.Fatalf("pending requests = %+v", list.Requests)
+	}
+
+	code, approved := p.decide(t, pending.RequestID, "approve", `{"ttl":"10m"}`)
+	if code != http.StatusOK || approved.Status != db.SecretRequestApproved || approved.DecidedBy != "local" {
+		t.Fatalf("approve: got %d %+v", code, approved)
+	}
+	if code, _ := p.decide(t, pending.RequestID, "deny", ""); code != http.StatusConflict {
+		t.Fatalf("deny after approve: got %d, want 409", code)
+	}
+
+	code, granted := p.readSecret(t, "GATED_KEY")
+	if code != http.StatusOK || granted.Value != "gated-value" || granted.ExpiresAt == "" {
+		t.Fatalf("granted read: got %d %+v, want 200 gated-value with expiry", code, granted)
+	}
+
+	// Once the grant lapses the sandbox must ask again.
+	p.clock = p.clock.Add(11 * time.Minute)
+	code, renewed := p.readSecret(t, "GATED_KEY")
+	if code != http.StatusAccepted || renewed.RequestID == pending.RequestID {
+		t.Fatalf("read after expiry: got %d %+v, want 202 with a new request", code, renewed)
+	}
+	if code, _ := p.decide(t, renewed.RequestID, "deny", ""); code != http.StatusOK {
+		t.Fatalf("deny: got %d, want 200", code)
+	}
+	if code, _ := p.readSecret(t, "GATED_KEY"); code != http.StatusForbidden {
+		t.Fatalf("read after deny: got %d, want 403", code)
+	}
+
+	if got := countEvents(t, p.store, EventKindSecretAccessApproved); got != 1 {
+		t.Fatalf("approved events = %d, want 1", got)
+	}
+	if got := countEvents(t, p.store, EventKindSecretAccessDenied); got != 1 {
+		t.Fatalf("denied events = %d, want 1", got)
+	}
+	records, err := p.store.ListAuditRecordsSince(ctx, time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatalf("list audit records: %v", err)
+	}
+	var actions []string
+	for _, record := range records {
+		actions = append(actions, record.Action)
+	}
+	if got := strings.Join(actions, ","); got != "secret.request,secret.approve,secret.request,secret.deny" {
+		t.Fatalf("audit actions = %s", got)
+	}
+}
+
+func TestSecretApprovalsWaitWakesOnDecision(t *testing.T) {
+	ctx := context.Background()
+	p := newSecretApprovalsPlane(t)
+	sandbox, err := p.store.GetSandbox(ctx, 7001)
+	if err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+	req, err := p.approvals.Request(ctx, sandbox, "", "GATED_KEY", secrets.SecretPolicy{RequireApproval: true})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	done := make(chan db.SecretAccessRequest, 1)
+	go func() {
+		got, _ := p.approvals.Wait(ctx, req.ID, time.Minute)
+		done <- got
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := p.approvals.Decide(ctx, req.ID, true, "alice", 0); err != nil {
+		t.Fatalf("decide: %v", err)
+	}
+	select {
+	case got := <-done:
+		if got.Status != db.SecretRequestApproved || !got.GrantExpiresAt.Equal(p.clock.Add(time.Hour)) {
+			t.Fatalf("wait returned %+v, want approved with the default ttl", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not wake on decision")
+	}
+}
+
+func TestFilterPolicyValues(t *testing.T) {
+	bundle := secrets.Bundle{Policies: map[string]secrets.SecretPolicy{
+		"TEAM_KEY":  {Tags: []string{"team-*"}},
+		"JOB_KEY":   {Jobs: []string{"job_other"}},
+		"GATED_KEY": {RequireApproval: true},
+	}}
+	values := map[string]string{"OPEN": "1", "TEAM_KEY": "2", "JOB_KEY": "3", "GATED_KEY": "4"}
+	subject := secretSubject(models.Sandbox{Profile: "default", Tags: "ci, team-red"}, "job_1")
+	got := filterPolicyValues(values, bundle, subject)
+	if len(got) != 2 || got["OPEN"] != "1" || got["TEAM_KEY"] != "2" {
+		t.Fatalf("filtered values = %+v, want OPEN and TEAM_KEY", got)
+	}
+}
+
+// fetchCountingProvider serves fixed values and records the paths it fetched.
+type fetchCountingProvider struct {
+	values  map[string]string
+	fetched []string
+}
+
+func (p *fetchCountingProvider) Fetch(_ context.Context, path string) ([]byte, error) {
+	p.fetched = append(p.fetched, path)
+	value, ok := p.values[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(value), nil
+}
+
+func TestSecretReferencesResolveOnlyWhenDelivered(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	seedSecretTestSandbox(t, store, 7002, "ref-sandbox", "10.77.7.20")
+	dir := t.TempDir()
+	bundle := `version: 1
+env:
+  OPEN_KEY: env:OPEN
+  ESCAPED_KEY: literal:env:NOT_A_REF
+  LEGACY_KEY: file:report.txt
+  GATED_KEY: vault:kv/data/gated#key
+  OTHER_PROFILE_KEY: vault:kv/data/other#key
+metadata:
+  region: env:REGION
+  broken: env:MISSING
+policies:
+  GATED_KEY:
+    require_approval: true
+  OTHER_PROFILE_KEY:
+    profiles: [other]
+`
+	if err := os.WriteFile(filepath.Join(dir, "default.yaml"), []byte(bundle), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	// The vault provider has no values, so resolving a held-back reference
+	// would fail the whole read. No file provider is registered, so the
+	// legacy file: value is delivered as written.
+	provider := &fetchCountingProvider{values: map[string]string{"OPEN": "open-value", "REGION": "us-east"}}
+	resolver := secrets.NewResolver(0).Register(secrets.SchemeEnv, provider).Register(secrets.SchemeVault, provider)
+	secretsStore := secrets.Store{Dir: dir, AllowPlaintext: true, Resolver: resolver}
+	logger := log.New(io.Discard, "", 0)
+	metadata := NewMetadataAPI(store, secretsStore, "default", mustParseCIDR(t, "10.77.0.0/16"), nil, logger).
+		WithSecretApprovals(NewSecretApprovals(store, time.Hour, logger))
+	secret := seedSandboxSecret(t, store, 7002)
+	get := func(path string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.77.7.20:4321"
+		withSandboxSecret(req, secret)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := get("/metadata/env", metadata.handleEnv)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("env: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	var env MetadataEnvResponse
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("decode env: %v", err)
+	}
+	want := map[string]string{"OPEN_KEY": "open-value", "ESCAPED_KEY": "env:NOT_A_REF", "LEGACY_KEY": "file:report.txt"}
+	if len(env.Env) != len(want) || env.Env["OPEN_KEY"] != want["OPEN_KEY"] || env.Env["ESCAPED_KEY"] != want["ESCAPED_KEY"] || env.Env["LEGACY_KEY"] != want["LEGACY_KEY"] {
+		t.Fatalf("env = %+v, want %+v", env.Env, want)
+	}
+
+	rec = get("/metadata/metadata", metadata.handleMetadata)
+	var meta MetadataMetadataResponse
+	if err := json.NewDecoder(rec.Body).Decode(&meta); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if meta.Metadata["region"] != "us-east" {
+		t.Fatalf("metadata region = %q, want us-east", meta.Metadata["region"])
+	}
+	if _, ok := meta.Metadata["broken"]; ok {
+		t.Fatalf("unresolvable metadata key was listed: %+v", meta.Metadata)
+	}
+
+	// An approval-gated read files a request without touching the provider.
+	if rec = get("/metadata/secrets/GATED_KEY", metadata.handleSecrets); rec.Code != http.StatusAccepted {
+		t.Fatalf("gated secret: got %d, want 202", rec.Code)
+	}
+	if rec = get("/metadata/secrets/OTHER_PROFILE_KEY", metadata.handleSecrets); rec.Code != http.StatusForbidden {
+		t.Fatalf("secret for another profile: got %d, want 403", rec.Code)
+	}
+	for _, path := range provider.fetched {
+		if path != "OPEN" && path != "REGION" && path != "MISSING" {
+			t.Fatalf("provider fetched %q, which is never delivered", path)
+		}
+	}
+
+	// Bootstrap delivers the same env without resolving held-back fields.
+	now := time.Now().UTC()
+	vmid := 7002
+	if err := store.CreateJob(ctx, models.Job{
+		ID: "job_refs", RepoURL: "https://example.com/repo.git", Ref: "main", Profile: "default",
+		Task: "t", Mode: "safe", Status: models.JobRunning, SandboxVMID: &vmid, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	hash, err := db.HashBootstrapToken("token-refs")
+	if err != nil {
+		t.Fatalf("hash token: %v", err)
+	}
+	if err := store.CreateBootstrapToken(ctx, hash, vmid, now.Add(5*time.Minute)); err != nil {
+		t.Fatalf("create bootstrap token: %v", err)
+	}
+	bootstrap := NewBootstrapAPI(store, nil, secretsStore, "default", mustParseCIDR(t, "10.77.0.0/16"), "", time.Hour, nil, nil)
+	req := httptest.NewRequest(http.MethodPost, "/v1/bootstrap/fetch", strings.NewReader(`{"token":"token-refs","vmid":7002}`))
+	req.RemoteAddr = "10.77.7.20:1234"
+	rec = httptest.NewRecorder()
+	bootstrap.handleBootstrapFetch(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bootstrap: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	var fetched V1BootstrapFetchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&fetched); err != nil {
+		t.Fatalf("decode bootstrap: %v", err)
+	}
+	if len(fetched.Env) != len(want) || fetched.Env["OPEN_KEY"] != want["OPEN_KEY"] || fetched.Env["ESCAPED_KEY"] != want["ESCAPED_KEY"] {
+		t.Fatalf("bootstrap env = %+v, want %+v", fetched.Env, want)
+	}
+}
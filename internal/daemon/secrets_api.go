@@ -1,11 +1,17 @@
 package daemon
 
 import (
+	"database/sql"
 	"errors"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/agentlab/agentlab/internal/auth"
+	"github.com/agentlab/agentlab/internal/config"
+	"github.com/agentlab/agentlab/internal/db"
 	"github.com/agentlab/agentlab/internal/secrets"
 )
 
@@ -27,6 +33,12 @@ var errSecretsSSHKeyNotFound = errors.New("ssh key not found")
 //   - DELETE /v1/secrets/tailscale     - clear Tailscale enrollment
 //   - POST   /v1/secrets/ssh-keys      - add an SSH public key
 //   - DELETE /v1/secrets/ssh-keys/{name} - remove an SSH public key
+//   - PUT    /v1/secrets/policies/{name} - set the access policy for a secret
+//   - DELETE /v1/secrets/policies/{name} - remove a secret's access policy
+//   - GET    /v1/secrets/requests      - list secret access requests
+//   - GET    /v1/secrets/requests/{id} - show one access request
+//   - POST   /v1/secrets/requests/{id}/approve - grant a pending request
+//   - POST   /v1/secrets/requests/{id}/deny    - deny a pending request
 //
 // Responses never include raw secret values; see V1SecretsView.
 //
@@ -35,10 +47,39 @@ var errSecretsSSHKeyNotFound = errors.New("ssh key not found")
 // sandbox-scoped token is refused (review F6). The local Unix socket, which
 // carries no identity, remains a trusted full-access path.
 type SecretsAPI struct {
-	store    secrets.Store
-	bundle   string
-	redactor *Redactor
-	logger   *log.Logger
+	store     secrets.Store
+	bundle    string
+	redactor  *Redactor
+	approvals *SecretApprovals
+	logger    *log.Logger
+}
+
+// newSecretsResolver builds the resolver for provider references in bundle
+// fields. Every reference is delivered into guests, so the host-side schemes
+// are opt-in: env: references need the variable in secrets_env_allowlist, and
+// file: references need secrets_file_references and must name a file inside
+// secrets_dir. vault: references need secrets_vault_addr, and take their
+// token from secrets_vault_token_path or, failing that, VAULT_TOKEN.
+func newSecretsResolver(cfg config.Config) *secrets.Resolver {
+	resolver := secrets.NewResolver(cfg.SecretsCacheTTL)
+	if len(cfg.SecretsEnvAllowlist) > 0 {
+		resolver.Register(secrets.SchemeEnv, secrets.EnvProvider{Allow: cfg.SecretsEnvAllowlist})
+	}
+	if cfg.SecretsFileRefs {
+		resolver.Register(secrets.SchemeFile, secrets.FileProvider{Dir: cfg.SecretsDir})
+	}
+	if strings.TrimSpace(cfg.SecretsVaultAddr) != "" {
+		vault := secrets.VaultProvider{
+			Addr:      cfg.SecretsVaultAddr,
+			TokenPath: cfg.SecretsVaultTokenPath,
+			Namespace: cfg.SecretsVaultNamespace,
+		}
+		if strings.TrimSpace(vault.TokenPath) == "" {
+			vault.Token = os.Getenv("VAULT_TOKEN")
+		}
+		resolver.Register(secrets.SchemeVault, vault)
+	}
+	return resolver
 }
 
 // NewSecretsAPI constructs a SecretsAPI bound to the named bundle (defaulting to
@@ -58,6 +99,15 @@ func NewSecretsAPI(store secrets.Store, bundle string, redactor *Redactor, logge
 	return &SecretsAPI{store: store, bundle: bundle, redactor: redactor, logger: logger}
 }
 
+// WithSecretApprovals enables the access request routes.
+func (api *SecretsAPI) WithSecretApprovals(approvals *SecretApprovals) *SecretsAPI {
+	if api == nil {
+		return api
+	}
+	api.approvals = approvals
+	return api
+}
+
 // Register mounts the secrets API routes onto the given mux.
 func (api *SecretsAPI) Register(mux *http.ServeMux) {
 	if mux == nil || api == nil {
@@ -70,6 +120,9 @@ func (api *SecretsAPI) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/secrets/tailscale", api.handleTailscale)
 	mux.HandleFunc("/v1/secrets/ssh-keys", api.handleSSHKeys)
 	mux.HandleFunc("/v1/secrets/ssh-keys/", api.handleSSHKeyName)
+	mux.HandleFunc("/v1/secrets/policies/", api.handlePolicy)
+	mux.HandleFunc("/v1/secrets/requests", api.handleRequests)
+	mux.HandleFunc("/v1/secrets/requests/", api.handleRequest)
 }
 
 // authorizeRead gates the redacted bundle view on the secrets.read
@@ -91,7 +144,9 @@ func (api *SecretsAPI) handleSecrets(w http.ResponseWriter, r *http.Request) {
 		if !api.authorizeRead(w, r) {
 			return
 		}
-		bundle, err := api.store.Load(r.Context(), api.bundle)
+		// The view is redacted anyway; skip resolving provider references so
+		// it does not depend on external secret backends being reachable.
+		bundle, err := api.store.LoadUnresolved(r.Context(), api.bundle)
 		if err != nil && !isSecretsNotFound(err) {
 			api.logger.Printf("secrets load error: %v", err)
 			writeError(w, http.StatusInternalServerError, "failed to load secrets bundle")
@@ -347,6 +402,195 @@ func (api *SecretsAPI) handleSSHKeyName(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (api *SecretsAPI) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/secrets/policies/"))
+	switch r.Method {
+	case http.MethodPut:
+		if !api.authorizeWrite(w, r) {
+			return
+		}
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "secret name is required")
+			return
+		}
+		var req V1SecretPolicy
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		policy := secrets.SecretPolicy{
+			Profiles:        req.Profiles,
+			Tags:            req.Tags,
+			Owners:          req.Owners,
+			Jobs:            req.Jobs,
+			RequireApproval: req.RequireApproval,
+			GrantTTL:        strings.TrimSpace(req.GrantTTL),
+		}
+		if err := policy.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid policy: "+err.Error())
+			return
+		}
+		bundle, _, err := api.store.Mutate(r.Context(), api.bundle, func(b *secrets.Bundle) error {
+			if b.Policies == nil {
+				b.Policies = map[string]secrets.SecretPolicy{}
+			}
+			b.Policies[name] = policy
+			return nil
+		})
+		if err != nil {
+			api.writeMutateError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, api.mutationResponse(bundle))
+	case http.MethodDelete:
+		if !api.authorizeWrite(w, r) {
+			return
+		}
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "secret name is required")
+			return
+		}
+		bundle, _, err := api.store.Mutate(r.Context(), api.bundle, func(b *secrets.Bundle) error {
+			delete(b.Policies, name)
+			return nil
+		})
+		if err != nil {
+			api.writeMutateError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, api.mutationResponse(bundle))
+	default:
+		writeMethodNotAllowed(w, []string{http.MethodPut, http.MethodDelete})
+	}
+}
+
+// handleRequests lists access requests. status filters by pending (the
+// default), approved, denied, or all.
+func (api *SecretsAPI) handleRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{http.MethodGet})
+		return
+	}
+	if !api.authorizeRead(w, r) {
+		return
+	}
+	if api.approvals == nil {
+		writeError(w, http.StatusServiceUnavailable, "secret approvals unavailable")
+		return
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = db.SecretRequestPending
+	case "all":
+		status = ""
+	case db.SecretRequestPending, db.SecretRequestApproved, db.SecretRequestDenied:
+	default:
+		writeError(w, http.StatusBadRequest, "status must be pending, approved, denied, or all")
+		return
+	}
+	reqs, err := api.approvals.List(r.Context(), status, 200)
+	if err != nil {
+		api.logger.Printf("secret requests list error: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list secret requests")
+		return
+	}
+	resp := V1SecretAccessRequestsResponse{Requests: make([]V1SecretAccessRequest, 0, len(reqs))}
+	for _, req := range reqs {
+		resp.Requests = append(resp.Requests, secretRequestToV1(req))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (api *SecretsAPI) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if api.approvals == nil {
+		writeError(w, http.StatusServiceUnavailable, "secret approvals unavailable")
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/secrets/requests/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "request id is required")
+		return
+	}
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, []string{http.MethodGet})
+			return
+		}
+		if !api.authorizeRead(w, r) {
+			return
+		}
+		req, err := api.approvals.store.GetSecretAccessRequest(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, errSecretRequestNotFound.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load secret request")
+			return
+		}
+		writeJSON(w, http.StatusOK, secretRequestToV1(req))
+	case "approve", "deny":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, []string{http.MethodPost})
+			return
+		}
+		if !authorizeStandalone(w, r, permSecretsApprove, true) {
+			return
+		}
+		var body V1SecretAccessDecisionRequest
+		if r.ContentLength != 0 {
+			if err := decodeJSON(w, r, &body); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+				return
+			}
+		}
+		var ttl time.Duration
+		if raw := strings.TrimSpace(body.TTL); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil || parsed <= 0 {
+				writeError(w, http.StatusBadRequest, "ttl must be a positive duration")
+				return
+			}
+			ttl = parsed
+		}
+		req, err := api.approvals.Decide(r.Context(), id, action == "approve", secretDecider(r), ttl)
+		switch {
+		case errors.Is(err, errSecretRequestNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, errSecretGrantTTLInvalid):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, db.ErrSecretRequestDecided):
+			writeError(w, http.StatusConflict, "secret request already "+req.Status)
+		case err != nil:
+			api.logger.Printf("secret request decide error: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to decide secret request")
+		default:
+			writeJSON(w, http.StatusOK, secretRequestToV1(req))
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// secretDecider names the caller deciding a request for the audit trail.
+// The local socket carries no identity and is recorded as "local".
+func secretDecider(r *http.Request) string {
+	id := auth.FromContext(r.Context())
+	switch {
+	case id == nil:
+		return "local"
+	case strings.TrimSpace(id.Subject) != "":
+		return id.Subject
+	case strings.TrimSpace(id.Fingerprint) != "":
+		return id.Fingerprint
+	default:
+		return id.Method
+	}
+}
+
 // mutationResponse builds the standard redacted response for the given bundle.
 func (api *SecretsAPI) mutationResponse(bundle secrets.Bundle) V1SecretsMutationResponse {
 	path := ""
@@ -448,6 +692,19 @@ func redactedSecretsView(bundle secrets.Bundle) V1SecretsView {
 			AdminAPIKeyConfigured: strings.TrimSpace(bundle.Tailscale.AdminAPIKey) != "",
 		}
 	}
+	if len(bundle.Policies) > 0 {
+		view.Policies = make(map[string]V1SecretPolicy, len(bundle.Policies))
+		for name, policy := range bundle.Policies {
+			view.Policies[name] = V1SecretPolicy{
+				Profiles:        policy.Profiles,
+				Tags:            policy.Tags,
+				Owners:          policy.Owners,
+				Jobs:            policy.Jobs,
+				RequireApproval: policy.RequireApproval,
+				GrantTTL:        policy.GrantTTL,
+			}
+		}
+	}
 	return view
 }
 
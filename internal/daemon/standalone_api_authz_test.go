@@ -18,16 +18,19 @@ import (
 
 	"github.com/agentlab/agentlab/internal/auth"
 	"github.com/agentlab/agentlab/internal/integrations"
+	"github.com/agentlab/agentlab/internal/models"
 	"github.com/agentlab/agentlab/internal/pool"
+	"github.com/agentlab/agentlab/internal/proxmox"
 	"github.com/agentlab/agentlab/internal/user"
 )
 
 // TestStandaloneAPIAuthorization exercises the authorization gates on the
 // APIs registered beside ControlAPI on the control mux: SecretsAPI (review
-// F6), IntegrationAPI (review F11), UserAPI (review F13), and PoolAPI
-// (review F12). Every route must refuse a zero-permission token, every
-// mutation must refuse a sandbox-scoped token regardless of its commands,
-// and each permission must work as an explicit grant for unscoped tokens.
+// F6), IntegrationAPI (review F11), UserAPI (review F13), PoolAPI (review
+// F12), TemplateAPI, TemplateRolloutAPI, ReportAPI and RoleAPI. Every route
+// must refuse a zero-permission token, every mutation must refuse a sandbox-scoped token
+// regardless of its commands, and each permission must work as an explicit
+// grant for unscoped tokens.
 func TestStandaloneAPIAuthorization(t *testing.T) {
 	store := newTestStore(t)
 
@@ -64,6 +67,11 @@ func TestStandaloneAPIAuthorization(t *testing.T) {
 	NewIntegrationAPI(intStore, log.New(io.Discard, "", 0)).Register(mux)
 	NewUserAPI(user.NewRegistry(user.NewStore(store))).Register(mux)
 	NewPoolAPI(resourcePool).Register(mux)
+	NewTemplateAPI(store, NewTemplateBuilds(store, &stubBackend{}, proxmox.SnippetStore{}, t.TempDir(), log.New(io.Discard, "", 0))).Register(mux)
+	profiles := NewProfileRegistry(map[string]models.Profile{"default": {Name: "default", TemplateVM: 9000}})
+	NewTemplateRolloutAPI(NewTemplateRollouts(store, &stubBackend{}, profiles, log.New(io.Discard, "", 0))).Register(mux)
+	NewReportAPI(store, profiles, user.NewRegistry(user.NewStore(store)), UsagePrices{Currency: "USD"}).Register(mux)
+	NewRoleAPI(user.NewRegistry(user.NewStore(store))).Register(mux)
 
 	doReq := func(t *testing.T, id *auth.RequestIdentity, method, path, body string) (int, []byte) {
 		t.Helper()
@@ -113,6 +121,12 @@ func TestStandaloneAPIAuthorization(t *testing.T) {
 	integrationDeleter := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"integration.delete"}}}}
 	userReader := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"user.read"}}}}
 	userWriter := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"user.write"}}}}
+	templateReader := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"template.read"}}}}
+	templateBuilder := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"template.build"}}}}
+	reportReader := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"report.usage"}}}}
+	roleReader := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"role.read"}}}}
+	roleWriter := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"role.write"}}}}
+	profileUpgrader := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{Commands: []string{"profile.upgrade"}}}}
 	poolScoped := &auth.RequestIdentity{Method: "ssh-token", Token: &auth.Token{Claims: auth.TokenClaims{
 		Commands: []string{"pool.status"},
 		Scope:    []string{"sandbox:1001"},
@@ -152,6 +166,15 @@ func TestStandaloneAPIAuthorization(t *testing.T) {
 			if code, _ := doReq(t, id, http.MethodPost, "/v1/teams", `{"name":"t"}`); code != http.StatusForbidden {
 				t.Errorf("scoped POST /v1/teams: got %d, want 403", code)
 			}
+			if code, _ := doReq(t, id, http.MethodPost, "/v1/templates", `{"spec":"name: agent-base\n"}`); code != http.StatusForbidden {
+				t.Errorf("scoped POST /v1/templates: got %d, want 403", code)
+			}
+			if code, _ := doReq(t, id, http.MethodGet, "/v1/templates", ""); code != http.StatusForbidden {
+				t.Errorf("scoped GET /v1/templates: got %d, want 403", code)
+			}
+			if code, _ := doReq(t, id, http.MethodPost, "/v1/profiles/default/upgrade", `{"to":"v2"}`); code != http.StatusForbidden {
+				t.Errorf("scoped POST /v1/profiles/default/upgrade: got %d, want 403", code)
+			}
 		}
 	})
 
@@ -266,6 +289,70 @@ func TestStandaloneAPIAuthorization(t *testing.T) {
 		}
 	})
 
+	t.Run("template permissions are per-grant", func(t *testing.T) {
+		if code, _ := doReq(t, templateReader, http.MethodGet, "/v1/templates", ""); code != http.StatusOK {
+			t.Errorf("template.read GET /v1/templates: got %d, want 200", code)
+		}
+		if code, _ := doReq(t, templateReader, http.MethodPost, "/v1/templates", `{"spec":"name: agent-base\n"}`); code != http.StatusForbidden {
+			t.Errorf("template.read POST /v1/templates: got %d, want 403", code)
+		}
+		// The build grant gets past authorization; the stub backend cannot
+		// build templates.
+		if code, _ := doReq(t, templateBuilder, http.MethodPost, "/v1/templates", `{"spec":"name: agent-base\n"}`); code != http.StatusNotImplemented {
+			t.Errorf("template.build POST /v1/templates: got %d, want 501", code)
+		}
+		if code, _ := doReq(t, templateBuilder, http.MethodGet, "/v1/templates/agent-base", ""); code != http.StatusForbidden {
+			t.Errorf("template.build GET /v1/templates/agent-base: got %d, want 403", code)
+		}
+		// Past authorization, the profile has never been upgraded and cannot
+		// be: it names a template VMID rather than a built template.
+		if code, _ := doReq(t, templateReader, http.MethodGet, "/v1/profiles/default/upgrade", ""); code != http.StatusNotFound {
+			t.Errorf("template.read GET /v1/profiles/default/upgrade: got %d, want 404", code)
+		}
+		if code, _ := doReq(t, templateReader, http.MethodPost, "/v1/profiles/default/upgrade", `{"to":"v2"}`); code != http.StatusForbidden {
+			t.Errorf("template.read POST /v1/profiles/default/upgrade: got %d, want 403", code)
+		}
+		if code, _ := doReq(t, profileUpgrader, http.MethodPost, "/v1/profiles/default/upgrade", `{"to":"v2"}`); code != http.StatusBadRequest {
+			t.Errorf("profile.upgrade POST /v1/profiles/default/upgrade: got %d, want 400", code)
+		}
+		if code, _ := doReq(t, profileUpgrader, http.MethodGet, "/v1/profiles/default/upgrade", ""); code != http.StatusForbidden {
+			t.Errorf("profile.upgrade GET /v1/profiles/default/upgrade: got %d, want 403", code)
+		}
+	})
+
+	t.Run("usage reports need report.usage and refuse scoped tokens", func(t *testing.T) {
+		if code, _ := doReq(t, reportReader, http.MethodGet, "/v1/reports/usage?group_by=team", ""); code != http.StatusOK {
+			t.Errorf("report.usage GET /v1/reports/usage: got %d, want 200", code)
+		}
+		if code, _ := doReq(t, templateReader, http.MethodGet, "/v1/reports/usage", ""); code != http.StatusForbidden {
+			t.Errorf("template.read GET /v1/reports/usage: got %d, want 403", code)
+		}
+		if code, _ := doReq(t, scopedAll, http.MethodGet, "/v1/reports/usage", ""); code != http.StatusForbidden {
+			t.Errorf("scoped GET /v1/reports/usage: got %d, want 403", code)
+		}
+	})
+
+	t.Run("custom roles need role.read or role.write and refuse scoped tokens", func(t *testing.T) {
+		if code, body := doReq(t, roleWriter, http.MethodPost, "/v1/roles", `{"name":"viewer","permissions":["sandbox.read"]}`); code != http.StatusCreated {
+			t.Errorf("role.write POST /v1/roles: got %d, want 201 (body %s)", code, body)
+		}
+		if code, _ := doReq(t, roleReader, http.MethodPost, "/v1/roles", `{"name":"other","permissions":["sandbox.read"]}`); code != http.StatusForbidden {
+			t.Errorf("role.read POST /v1/roles: got %d, want 403", code)
+		}
+		if code, _ := doReq(t, roleReader, http.MethodGet, "/v1/roles", ""); code != http.StatusOK {
+			t.Errorf("role.read GET /v1/roles: got %d, want 200", code)
+		}
+		if code, _ := doReq(t, roleWriter, http.MethodGet, "/v1/role-bindings", ""); code != http.StatusForbidden {
+			t.Errorf("role.write GET /v1/role-bindings: got %d, want 403", code)
+		}
+		if code, _ := doReq(t, scopedAll, http.MethodGet, "/v1/roles", ""); code != http.StatusForbidden {
+			t.Errorf("scoped GET /v1/roles: got %d, want 403", code)
+		}
+		if code, _ := doReq(t, roleWriter, http.MethodDelete, "/v1/roles/viewer", ""); code != http.StatusOK {
+			t.Errorf("role.write DELETE /v1/roles/viewer: got %d, want 200", code)
+		}
+	})
+
 	t.Run("user and team permissions are per-grant", func(t *testing.T) {
 		keyLine, fingerprint := sshKey(t)
 
@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+)
+
+// TemplateAPI exposes template builds and the built template versions.
+//
+// Reads need template.read and builds need template.build. Templates are
+// shared by every profile, so sandbox-scoped tokens are refused for both.
+type TemplateAPI struct {
+	store  *db.Store
+	builds *TemplateBuilds
+}
+
+// NewTemplateAPI creates a new template API handler.
+func NewTemplateAPI(store *db.Store, builds *TemplateBuilds) *TemplateAPI {
+	return &TemplateAPI{store: store, builds: builds}
+}
+
+// Register registers template API routes on the given mux.
+func (api *TemplateAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/templates", api.handleTemplates)
+	mux.HandleFunc("/v1/templates/", api.handleTemplateResource)
+}
+
+func (api *TemplateAPI) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeStandalone(w, r, permTemplateRead, true) {
+			return
+		}
+		api.listTemplates(w, r, r.URL.Query().Get("name"))
+	case http.MethodPost:
+		if !authorizeStandalone(w, r, permTemplateBuild, true) {
+			return
+		}
+		api.startBuild(w, r)
+	default:
+		writeMethodNotAllowed(w, []string{"GET", "POST"})
+	}
+}
+
+// handleTemplateResource serves /v1/templates/{name} (every version) and
+// /v1/templates/{name}/{version}.
+func (api *TemplateAPI) handleTemplateResource(w http.ResponseWriter, r *http.Request) {
+	suffix := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/templates/"), "/")
+	if suffix == "" {
+		api.handleTemplates(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, []string{"GET"})
+		return
+	}
+	if !authorizeStandalone(w, r, permTemplateRead, true) {
+		return
+	}
+	parts := strings.Split(suffix, "/")
+	switch len(parts) {
+	case 1:
+		name := parts[0]
+		if !templateNamePattern.MatchString(name) {
+			writeError(w, http.StatusBadRequest, "invalid template name")
+			return
+		}
+		api.listTemplates(w, r, name)
+	case 2:
+		api.showTemplate(w, r, parts[0], parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (api *TemplateAPI) listTemplates(w http.ResponseWriter, r *http.Request, name string) {
+	templates, err := api.store.ListTemplates(r.Context(), name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list templates", err)
+		return
+	}
+	resp := V1TemplatesResponse{Templates: make([]V1Template, 0, len(templates))}
+	for _, tmpl := range templates {
+		resp.Templates = append(resp.Templates, templateToV1(tmpl, false))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (api *TemplateAPI) showTemplate(w http.ResponseWriter, r *http.Request, name, version string) {
+	tmpl, err := api.store.GetTemplate(r.Context(), name, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load template", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, templateToV1(tmpl, true))
+}
+
+// startBuild records a new template version and builds it in the
+// background. The response is the building version; callers poll it.
+func (api *TemplateAPI) startBuild(w http.ResponseWriter, r *http.Request) {
+	var req V1TemplateBuildRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	if len(req.Spec) > maxTemplateSpecBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "template spec too large")
+		return
+	}
+	tmpl, err := api.builds.Start(r.Context(), []byte(req.Spec), secretDecider(r))
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, templateToV1(tmpl, false))
+	case errors.Is(err, errTemplateSpecInvalid):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errTemplateVersionExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, errTemplateBuildUnsupported):
+		writeError(w, http.StatusNotImplemented, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "failed to start template build", err)
+	}
+}
+
+func templateToV1(tmpl db.Template, withSpec bool) V1Template {
+	out := V1Template{
+		Name:           tmpl.Name,
+		Version:        tmpl.Version,
+		Ref:            tmpl.Ref(),
+		VMID:           tmpl.VMID,
+		Status:         tmpl.Status,
+		ImageURL:       tmpl.ImageURL,
+		ImageSHA256:    tmpl.ImageSHA256,
+		SpecSHA256:     tmpl.SpecSHA256,
+		BuiltBy:        tmpl.BuiltBy,
+		BuilderVersion: tmpl.BuilderVersion,
+		Error:          tmpl.Error,
+		CreatedAt:      tmpl.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:      tmpl.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if !tmpl.FinishedAt.IsZero() {
+		out.FinishedAt = tmpl.FinishedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if withSpec {
+		out.Spec = tmpl.SpecYAML
+	}
+	return out
+}
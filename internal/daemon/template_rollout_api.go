@@ -0,0 +1,197 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+)
+
+// TemplateRolloutAPI exposes profile template rollouts under
+// /v1/profiles/{name}/upgrade.
+//
+// Reading a rollout needs template.read; starting, ramping, promoting and
+// rolling back need profile.upgrade. Both are global.
+type TemplateRolloutAPI struct {
+	rollouts *TemplateRollouts
+}
+
+// NewTemplateRolloutAPI creates a new template rollout API handler.
+func NewTemplateRolloutAPI(rollouts *TemplateRollouts) *TemplateRolloutAPI {
+	return &TemplateRolloutAPI{rollouts: rollouts}
+}
+
+// Register registers template rollout API routes on the given mux.
+func (api *TemplateRolloutAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/profiles/", api.handleProfileResource)
+}
+
+// handleProfileResource serves /v1/profiles/{name}/upgrade and its
+// percent, promote and rollback actions.
+func (api *TemplateRolloutAPI) handleProfileResource(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/profiles/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] != "upgrade" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	profile := parts[0]
+	if len(parts) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			if !authorizeStandalone(w, r, permTemplateRead, true) {
+				return
+			}
+			api.status(w, r, profile)
+		case http.MethodPost:
+			if !authorizeStandalone(w, r, permProfileUpgrade, true) {
+				return
+			}
+			api.start(w, r, profile)
+		default:
+			writeMethodNotAllowed(w, []string{"GET", "POST"})
+		}
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, []string{"POST"})
+		return
+	}
+	switch parts[2] {
+	case "percent", "promote", "rollback":
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !authorizeStandalone(w, r, permProfileUpgrade, true) {
+		return
+	}
+	switch parts[2] {
+	case "percent":
+		api.setPercent(w, r, profile)
+	case "promote":
+		api.promote(w, r, profile)
+	case "rollback":
+		api.rollback(w, r, profile)
+	}
+}
+
+func (api *TemplateRolloutAPI) status(w http.ResponseWriter, r *http.Request, profile string) {
+	rollout, cmp, err := api.rollouts.Status(r.Context(), profile)
+	if err != nil {
+		writeTemplateRolloutError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, templateRolloutToV1(rollout, cmp))
+}
+
+func (api *TemplateRolloutAPI) start(w http.ResponseWriter, r *http.Request, profile string) {
+	var req V1TemplateRolloutRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	rollout, err := api.rollouts.Start(r.Context(), templateRolloutRequest{
+		Profile:   profile,
+		To:        req.To,
+		Percent:   req.Percent,
+		StartedBy: secretDecider(r),
+	})
+	if err != nil {
+		writeTemplateRolloutError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, templateRolloutToV1(rollout, templateRolloutComparison{Verdict: templateVerdictInsufficientData}))
+}
+
+func (api *TemplateRolloutAPI) setPercent(w http.ResponseWriter, r *http.Request, profile string) {
+	var req V1TemplateRolloutPercentRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	if req.Percent == nil {
+		writeError(w, http.StatusBadRequest, "percent is required")
+		return
+	}
+	if _, err := api.rollouts.SetPercent(r.Context(), profile, *req.Percent); err != nil {
+		writeTemplateRolloutError(w, err)
+		return
+	}
+	api.status(w, r, profile)
+}
+
+func (api *TemplateRolloutAPI) promote(w http.ResponseWriter, r *http.Request, profile string) {
+	var req V1TemplateRolloutPromoteRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeJSONDecodeError(w, err)
+			return
+		}
+	}
+	rollout, cmp, err := api.rollouts.Promote(r.Context(), profile, req.Force)
+	if err != nil {
+		writeTemplateRolloutError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, templateRolloutToV1(rollout, cmp))
+}
+
+func (api *TemplateRolloutAPI) rollback(w http.ResponseWriter, r *http.Request, profile string) {
+	rollout, cmp, err := api.rollouts.Rollback(r.Context(), profile)
+	if err != nil {
+		writeTemplateRolloutError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, templateRolloutToV1(rollout, cmp))
+}
+
+func writeTemplateRolloutError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errTemplateProfileNotFound), errors.Is(err, errTemplateRolloutNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errTemplateRolloutInvalid):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, db.ErrTemplateRolloutActive), errors.Is(err, errTemplateRolloutRegressed):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "template rollout failed", err)
+	}
+}
+
+func templateRolloutToV1(rollout db.TemplateRollout, cmp templateRolloutComparison) V1TemplateRollout {
+	out := V1TemplateRollout{
+		Profile:   rollout.Profile,
+		Template:  rollout.Template,
+		From:      rollout.FromRef(),
+		To:        rollout.ToRef(),
+		Percent:   rollout.Percent,
+		Status:    rollout.Status,
+		StartedBy: rollout.StartedBy,
+		CreatedAt: rollout.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt: rollout.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		Baseline:  templateVersionStatsToV1(cmp.Baseline, rollout.FromVersion, rollout.FromVMID),
+		Canary:    templateVersionStatsToV1(cmp.Canary, rollout.ToVersion, rollout.ToVMID),
+		Verdict:   cmp.Verdict,
+		Reasons:   cmp.Reasons,
+	}
+	if !rollout.FinishedAt.IsZero() {
+		out.FinishedAt = rollout.FinishedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return out
+}
+
+func templateVersionStatsToV1(stats templateVersionStats, version string, vmid int) V1TemplateVersionStats {
+	return V1TemplateVersionStats{
+		Version:        version,
+		VMID:           vmid,
+		Sandboxes:      stats.Sandboxes,
+		Ready:          stats.Ready,
+		ReadyP50MS:     stats.ReadyP50.Milliseconds(),
+		ReadyP95MS:     stats.ReadyP95.Milliseconds(),
+		Jobs:           stats.Jobs,
+		JobsFailed:     stats.JobsFailed,
+		JobFailureRate: stats.FailureRate,
+	}
+}
@@ -0,0 +1,573 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+const (
+	defaultTemplateRolloutPercent = 10
+	defaultTemplateGCInterval     = 10 * time.Minute
+
+	// A rollout is judged only once both versions have this many sandboxes
+	// since it started; below that one slow boot decides the verdict.
+	templateRolloutMinSandboxes = 5
+	// The canary regresses when its job failure rate or its share of
+	// sandboxes that never became ready is this much worse than the
+	// baseline, or its p95 time to ready is this many times the baseline's.
+	templateRolloutRateMargin  = 0.05
+	templateRolloutReadyFactor = 1.25
+)
+
+// Rollout verdicts, from comparing the canary with the version it replaces.
+const (
+	templateVerdictInsufficientData = "insufficient_data"
+	templateVerdictHealthy          = "healthy"
+	templateVerdictRegressed        = "regressed"
+)
+
+var (
+	errTemplateRolloutInvalid   = errors.New("invalid template rollout")
+	errTemplateRolloutNotFound  = errors.New("profile has no active template rollout")
+	errTemplateRolloutRegressed = errors.New("canary regressed")
+	errTemplateProfileNotFound  = errors.New("unknown profile")
+)
+
+// templateRolloutTarget returns the template reference a provisioning
+// request uses under a rollout.
+func templateRolloutTarget(rollout db.TemplateRollout, routeKey string) string {
+	if rollout.Status == db.TemplateRolloutPromoted {
+		return rollout.ToRef()
+	}
+	if routeKey != "" && templateRolloutBucket(rollout.ID, routeKey) < rollout.Percent {
+		return rollout.ToRef()
+	}
+	return rollout.FromRef()
+}
+
+// templateRolloutBucket maps a request to 0-99. The rollout ID is mixed in so
+// the same requests are not always the canary of every rollout.
+func templateRolloutBucket(rolloutID int64, routeKey string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strconv.FormatInt(rolloutID, 10) + ":" + routeKey))
+	return int(h.Sum32() % 100)
+}
+
+// templateVersionStats summarises the sandboxes one template version
+// provisioned since a rollout started.
+type templateVersionStats struct {
+	Version     string
+	VMID        int
+	Sandboxes   int
+	Ready       int
+	ReadyP50    time.Duration
+	ReadyP95    time.Duration
+	Jobs        int
+	JobsFailed  int
+	FailureRate float64
+}
+
+// templateRolloutComparison compares a rollout's canary with its baseline.
+type templateRolloutComparison struct {
+	Baseline templateVersionStats
+	Canary   templateVersionStats
+	Verdict  string
+	Reasons  []string
+}
+
+// TemplateRollouts moves profiles between template versions: it starts a
+// canary, adjusts its share, compares the versions' provisioning SLOs, and
+// promotes or rolls back. It also garbage-collects template versions that no
+// profile resolves to and no sandbox was cloned from.
+type TemplateRollouts struct {
+	store      *db.Store
+	backend    proxmox.Backend
+	profiles   *ProfileRegistry
+	logger     *log.Logger
+	now        func() time.Time
+	gcInterval time.Duration
+
+	// mu serialises rollout changes with GC, so a pass never retires a
+	// version a rollout has just started routing to.
+	mu sync.Mutex
+	// unreferenced holds the template IDs the previous GC pass found unused.
+	// A version is retired only when two passes agree, which covers requests
+	// that resolved it just before a rollout moved on.
+	unreferenced map[int64]bool
+}
+
+// NewTemplateRollouts returns a rollout manager for the profiles in the
+// registry.
+func NewTemplateRollouts(store *db.Store, backend proxmox.Backend, profiles *ProfileRegistry, logger *log.Logger) *TemplateRollouts {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &TemplateRollouts{
+		store:      store,
+		backend:    backend,
+		profiles:   profiles,
+		logger:     logger,
+		now:        time.Now,
+		gcInterval: defaultTemplateGCInterval,
+	}
+}
+
+// templateRolloutRequest starts a rollout. To is a version or name@version
+// of the profile's template; empty means the newest ready version.
+type templateRolloutRequest struct {
+	Profile   string
+	To        string
+	Percent   int
+	StartedBy string
+}
+
+// Start begins routing a share of the profile's new sandboxes to another
+// version of its template. The profile must pin a version.
+func (r *TemplateRollouts) Start(ctx context.Context, req templateRolloutRequest) (db.TemplateRollout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	profile, ok := r.profiles.Get(strings.TrimSpace(req.Profile))
+	if !ok {
+		return db.TemplateRollout{}, fmt.Errorf("%w %q", errTemplateProfileNotFound, req.Profile)
+	}
+	base := strings.TrimSpace(profile.Template)
+	if base == "" {
+		return db.TemplateRollout{}, fmt.Errorf("%w: profile %s uses template_vmid, not a built template", errTemplateRolloutInvalid, profile.Name)
+	}
+	name, fromVersion, err := parseTemplateRef(base)
+	if err != nil {
+		return db.TemplateRollout{}, fmt.Errorf("%w: %v", errTemplateRolloutInvalid, err)
+	}
+	if fromVersion == "" {
+		return db.TemplateRollout{}, fmt.Errorf("%w: profile %s must pin a version (%s@<version>) to roll out upgrades", errTemplateRolloutInvalid, profile.Name, name)
+	}
+	current, err := r.store.CurrentTemplateRollout(ctx, profile.Name, base)
+	switch {
+	case err == nil && current.Status == db.TemplateRolloutActive:
+		return db.TemplateRollout{}, db.ErrTemplateRolloutActive
+	case err == nil:
+		fromVersion = current.ToVersion
+	case !errors.Is(err, sql.ErrNoRows):
+		return db.TemplateRollout{}, err
+	}
+	from, err := resolveTemplateRef(ctx, r.store, name+"@"+fromVersion)
+	if err != nil {
+		return db.TemplateRollout{}, fmt.Errorf("%w: current version: %v", errTemplateRolloutInvalid, err)
+	}
+	to, err := r.rolloutTarget(ctx, name, req.To)
+	if err != nil {
+		return db.TemplateRollout{}, err
+	}
+	if to.Version == from.Version {
+		return db.TemplateRollout{}, fmt.Errorf("%w: profile %s already uses %s", errTemplateRolloutInvalid, profile.Name, to.Ref())
+	}
+	percent := req.Percent
+	if percent == 0 {
+		percent = defaultTemplateRolloutPercent
+	}
+	if percent < 1 || percent > 100 {
+		return db.TemplateRollout{}, fmt.Errorf("%w: percent must be between 1 and 100", errTemplateRolloutInvalid)
+	}
+	rollout, err := r.store.CreateTemplateRollout(ctx, db.TemplateRollout{
+		Profile:     profile.Name,
+		BaseRef:     base,
+		Template:    name,
+		FromVersion: from.Version,
+		FromVMID:    from.VMID,
+		ToVersion:   to.Version,
+		ToVMID:      to.VMID,
+		Percent:     percent,
+		StartedBy:   req.StartedBy,
+		CreatedAt:   r.now().UTC(),
+	})
+	if err != nil {
+		return db.TemplateRollout{}, err
+	}
+	r.logger.Printf("profile %s: rolling out %s to %d%% of new sandboxes", rollout.Profile, rollout.ToRef(), rollout.Percent)
+	r.emit(ctx, EventKindTemplateRolloutStarted, fmt.Sprintf("profile %s: rolling out %s (%d%%)", rollout.Profile, rollout.ToRef(), rollout.Percent),
+		rolloutEventPayload(rollout, map[string]any{"percent": rollout.Percent, "started_by": rollout.StartedBy}))
+	return rollout, nil
+}
+
+// rolloutTarget resolves the version a rollout moves to.
+func (r *TemplateRollouts) rolloutTarget(ctx context.Context, name, to string) (db.Template, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		tmpl, err := r.store.LatestReadyTemplate(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Template{}, fmt.Errorf("%w: %s has no ready version", errTemplateRolloutInvalid, name)
+		}
+		return tmpl, err
+	}
+	if !strings.Contains(to, "@") {
+		to = name + "@" + to
+	}
+	toName, _, err := parseTemplateRef(to)
+	if err != nil {
+		return db.Template{}, fmt.Errorf("%w: %v", errTemplateRolloutInvalid, err)
+	}
+	if toName != name {
+		return db.Template{}, fmt.Errorf("%w: cannot roll out %s to a profile on %s", errTemplateRolloutInvalid, to, name)
+	}
+	tmpl, err := resolveTemplateRef(ctx, r.store, to)
+	if err != nil {
+		return db.Template{}, fmt.Errorf("%w: %v", errTemplateRolloutInvalid, err)
+	}
+	return tmpl, nil
+}
+
+// SetPercent changes the share of new sandboxes the active rollout sends to
+// the new version. Zero pauses the canary without ending the rollout.
+func (r *TemplateRollouts) SetPercent(ctx context.Context, profile string, percent int) (db.TemplateRollout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if percent < 0 || percent > 100 {
+		return db.TemplateRollout{}, fmt.Errorf("%w: percent must be between 0 and 100", errTemplateRolloutInvalid)
+	}
+	rollout, err := r.active(ctx, profile)
+	if err != nil {
+		return db.TemplateRollout{}, err
+	}
+	now := r.now().UTC()
+	if err := r.store.UpdateTemplateRolloutPercent(ctx, rollout.ID, percent, now); err != nil {
+		return db.TemplateRollout{}, r.finishError(err)
+	}
+	rollout.Percent = percent
+	rollout.UpdatedAt = now
+	r.emit(ctx, EventKindTemplateRolloutUpdated, fmt.Sprintf("profile %s: %s at %d%%", rollout.Profile, rollout.ToRef(), percent),
+		rolloutEventPayload(rollout, map[string]any{"percent": percent}))
+	return rollout, nil
+}
+
+// Promote moves every new sandbox of the profile to the new version. It
+// refuses a regressed canary unless force is set.
+func (r *TemplateRollouts) Promote(ctx context.Context, profile string, force bool) (db.TemplateRollout, templateRolloutComparison, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rollout, err := r.active(ctx, profile)
+	if err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	cmp, err := r.compare(ctx, rollout)
+	if err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	if cmp.Verdict == templateVerdictRegressed && !force {
+		return rollout, cmp, fmt.Errorf("%w: %s", errTemplateRolloutRegressed, strings.Join(cmp.Reasons, "; "))
+	}
+	if err := r.finish(ctx, &rollout, db.TemplateRolloutPromoted); err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	r.logger.Printf("profile %s: promoted %s (%s)", rollout.Profile, rollout.ToRef(), cmp.Verdict)
+	r.emit(ctx, EventKindTemplateRolloutPromoted, fmt.Sprintf("profile %s: promoted %s", rollout.Profile, rollout.ToRef()),
+		rolloutEventPayload(rollout, map[string]any{"verdict": cmp.Verdict}))
+	return rollout, cmp, nil
+}
+
+// Rollback ends the active rollout; new sandboxes use the previous version
+// again. Sandboxes already cloned from the new version are left running.
+func (r *TemplateRollouts) Rollback(ctx context.Context, profile string) (db.TemplateRollout, templateRolloutComparison, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rollout, err := r.active(ctx, profile)
+	if err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	cmp, err := r.compare(ctx, rollout)
+	if err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	if err := r.finish(ctx, &rollout, db.TemplateRolloutRolledBack); err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	r.logger.Printf("profile %s: rolled back %s to %s (%s)", rollout.Profile, rollout.ToRef(), rollout.FromRef(), cmp.Verdict)
+	r.emit(ctx, EventKindTemplateRolloutRolledBack, fmt.Sprintf("profile %s: rolled back %s", rollout.Profile, rollout.ToRef()),
+		rolloutEventPayload(rollout, map[string]any{"verdict": cmp.Verdict}))
+	return rollout, cmp, nil
+}
+
+// Status returns the profile's latest rollout and its comparison.
+func (r *TemplateRollouts) Status(ctx context.Context, profile string) (db.TemplateRollout, templateRolloutComparison, error) {
+	if _, ok := r.profiles.Get(strings.TrimSpace(profile)); !ok {
+		return db.TemplateRollout{}, templateRolloutComparison{}, fmt.Errorf("%w %q", errTemplateProfileNotFound, profile)
+	}
+	rollout, err := r.store.LatestTemplateRollout(ctx, profile)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.TemplateRollout{}, templateRolloutComparison{}, fmt.Errorf("%w: %s has never been upgraded", errTemplateRolloutNotFound, profile)
+	}
+	if err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	cmp, err := r.compare(ctx, rollout)
+	if err != nil {
+		return db.TemplateRollout{}, templateRolloutComparison{}, err
+	}
+	return rollout, cmp, nil
+}
+
+func (r *TemplateRollouts) active(ctx context.Context, profile string) (db.TemplateRollout, error) {
+	if _, ok := r.profiles.Get(strings.TrimSpace(profile)); !ok {
+		return db.TemplateRollout{}, fmt.Errorf("%w %q", errTemplateProfileNotFound, profile)
+	}
+	rollout, err := r.store.LatestTemplateRollout(ctx, profile)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && rollout.Status != db.TemplateRolloutActive) {
+		return db.TemplateRollout{}, fmt.Errorf("%w: %s", errTemplateRolloutNotFound, profile)
+	}
+	return rollout, err
+}
+
+func (r *TemplateRollouts) finish(ctx context.Context, rollout *db.TemplateRollout, status string) error {
+	now := r.now().UTC()
+	if err := r.store.FinishTemplateRollout(ctx, rollout.ID, status, now); err != nil {
+		return r.finishError(err)
+	}
+	rollout.Status = status
+	rollout.UpdatedAt = now
+	rollout.FinishedAt = now
+	return nil
+}
+
+// finishError reports a rollout that ended between loading and updating it.
+func (r *TemplateRollouts) finishError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errTemplateRolloutNotFound
+	}
+	return err
+}
+
+// compare gathers both versions' provisioning SLOs since the rollout started
+// and judges the canary against the baseline.
+func (r *TemplateRollouts) compare(ctx context.Context, rollout db.TemplateRollout) (templateRolloutComparison, error) {
+	baseline, err := r.versionStats(ctx, rollout.FromVersion, rollout.FromVMID, rollout.CreatedAt)
+	if err != nil {
+		return templateRolloutComparison{}, err
+	}
+	canary, err := r.versionStats(ctx, rollout.ToVersion, rollout.ToVMID, rollout.CreatedAt)
+	if err != nil {
+		return templateRolloutComparison{}, err
+	}
+	cmp := templateRolloutComparison{Baseline: baseline, Canary: canary}
+	cmp.Verdict, cmp.Reasons = judgeTemplateRollout(baseline, canary)
+	return cmp, nil
+}
+
+func (r *TemplateRollouts) versionStats(ctx context.Context, version string, vmid int, since time.Time) (templateVersionStats, error) {
+	stats := templateVersionStats{Version: version, VMID: vmid}
+	usage, err := r.store.CountTemplateUsage(ctx, vmid, since)
+	if err != nil {
+		return stats, err
+	}
+	stats.Sandboxes = usage.Sandboxes
+	stats.Jobs = usage.Jobs
+	events, err := r.store.ListTemplateEvents(ctx, vmid, since, string(EventKindSandboxSLOReady), string(EventKindJobFailed))
+	if err != nil {
+		return stats, err
+	}
+	var ready []time.Duration
+	failedJobs := make(map[string]bool)
+	for _, ev := range events {
+		switch EventKind(ev.Kind) {
+		case EventKindSandboxSLOReady:
+			var payload sloEventPayload
+			if err := json.Unmarshal([]byte(ev.JSON), &payload); err != nil {
+				continue
+			}
+			ready = append(ready, time.Duration(payload.DurationMS)*time.Millisecond)
+		case EventKindJobFailed:
+			if ev.JobID != nil {
+				failedJobs[*ev.JobID] = true
+			}
+		}
+	}
+	stats.Ready = len(ready)
+	stats.ReadyP50 = durationPercentile(ready, 50)
+	stats.ReadyP95 = durationPercentile(ready, 95)
+	stats.JobsFailed = len(failedJobs)
+	if stats.Jobs > 0 {
+		stats.FailureRate = float64(stats.JobsFailed) / float64(stats.Jobs)
+	}
+	return stats, nil
+}
+
+// judgeTemplateRollout returns the canary's verdict and, when it regressed,
+// why.
+func judgeTemplateRollout(baseline, canary templateVersionStats) (string, []string) {
+	if baseline.Sandboxes < templateRolloutMinSandboxes || canary.Sandboxes < templateRolloutMinSandboxes {
+		return templateVerdictInsufficientData, nil
+	}
+	var reasons []string
+	if baseline.Jobs > 0 && canary.Jobs > 0 && canary.FailureRate > baseline.FailureRate+templateRolloutRateMargin {
+		reasons = append(reasons, fmt.Sprintf("job failure rate %.1f%% vs %.1f%%", canary.FailureRate*100, baseline.FailureRate*100))
+	}
+	baselineReady := float64(baseline.Ready) / float64(baseline.Sandboxes)
+	canaryReady := float64(canary.Ready) / float64(canary.Sandboxes)
+	if canaryReady < baselineReady-templateRolloutRateMargin {
+		reasons = append(reasons, fmt.Sprintf("ready rate %.1f%% vs %.1f%%", canaryReady*100, baselineReady*100))
+	}
+	if baseline.ReadyP95 > 0 && float64(canary.ReadyP95) > float64(baseline.ReadyP95)*templateRolloutReadyFactor {
+		reasons = append(reasons, fmt.Sprintf("p95 time to ready %s vs %s", canary.ReadyP95, baseline.ReadyP95))
+	}
+	if len(reasons) > 0 {
+		return templateVerdictRegressed, reasons
+	}
+	return templateVerdictHealthy, nil
+}
+
+// durationPercentile returns the nearest-rank percentile of values.
+func durationPercentile(values []time.Duration, p int) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// StartGC collects unused template versions on an interval until ctx is
+// done.
+func (r *TemplateRollouts) StartGC(ctx context.Context) {
+	if r == nil || r.store == nil || r.backend == nil || r.gcInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.gcInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.collectGarbage(ctx); err != nil {
+					r.logger.Printf("template GC: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// collectGarbage destroys the VMs of ready template versions that nothing
+// uses and marks them retired. A version is kept while it is the newest ready
+// version of its template, while any profile resolves to it (in or out of a
+// canary), or while a sandbox not yet destroyed was cloned from it.
+func (r *TemplateRollouts) collectGarbage(ctx context.Context) ([]db.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	templates, err := r.store.ListTemplates(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := r.store.ListTemplateVMIDsInUse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.markReferenced(ctx, templates, inUse); err != nil {
+		return nil, err
+	}
+
+	previous := r.unreferenced
+	r.unreferenced = make(map[int64]bool)
+	var retired []db.Template
+	for _, tmpl := range templates {
+		if tmpl.Status != db.TemplateReady || inUse[tmpl.VMID] {
+			continue
+		}
+		if !previous[tmpl.ID] {
+			r.unreferenced[tmpl.ID] = true
+			continue
+		}
+		if err := r.backend.Destroy(ctx, proxmox.VMID(tmpl.VMID)); err != nil && !errors.Is(err, proxmox.ErrVMNotFound) {
+			r.logger.Printf("template GC: destroy %s (VM %d): %v", tmpl.Ref(), tmpl.VMID, err)
+			r.unreferenced[tmpl.ID] = true
+			continue
+		}
+		if err := r.store.RetireTemplate(ctx, tmpl.ID, r.now().UTC()); err != nil {
+			r.logger.Printf("template GC: retire %s: %v", tmpl.Ref(), err)
+			continue
+		}
+		r.logger.Printf("template GC: retired %s and destroyed VM %d", tmpl.Ref(), tmpl.VMID)
+		r.emit(ctx, EventKindTemplateRetired, "template retired: "+tmpl.Ref(), map[string]any{
+			"template": tmpl.Name,
+			"version":  tmpl.Version,
+			"vmid":     tmpl.VMID,
+		})
+		retired = append(retired, tmpl)
+	}
+	return retired, nil
+}
+
+// markReferenced adds to inUse the template VMs that profiles and rollouts
+// still point at, and the newest ready version of every template.
+func (r *TemplateRollouts) markReferenced(ctx context.Context, templates []db.Template, inUse map[int]bool) error {
+	newest := make(map[string]bool)
+	for _, tmpl := range templates {
+		// ListTemplates orders each template's versions newest first.
+		if tmpl.Status == db.TemplateReady && !newest[tmpl.Name] {
+			newest[tmpl.Name] = true
+			inUse[tmpl.VMID] = true
+		}
+	}
+	for _, profile := range r.profiles.Snapshot() {
+		if profile.TemplateVM > 0 {
+			inUse[profile.TemplateVM] = true
+		}
+		ref := strings.TrimSpace(profile.Template)
+		if ref == "" {
+			continue
+		}
+		rollout, err := r.store.CurrentTemplateRollout(ctx, profile.Name, ref)
+		switch {
+		case err == nil && rollout.Status == db.TemplateRolloutActive:
+			inUse[rollout.FromVMID] = true
+			inUse[rollout.ToVMID] = true
+			continue
+		case err == nil:
+			inUse[rollout.ToVMID] = true
+			continue
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		if tmpl, err := resolveTemplateRef(ctx, r.store, ref); err == nil {
+			inUse[tmpl.VMID] = true
+		}
+	}
+	return nil
+}
+
+func (r *TemplateRollouts) emit(ctx context.Context, kind EventKind, msg string, payload any) {
+	if err := emitEvent(ctx, NewStoreEventRecorder(r.store), kind, nil, nil, msg, payload); err != nil {
+		r.logger.Printf("template event %s: %v", kind, err)
+	}
+}
+
+func rolloutEventPayload(rollout db.TemplateRollout, extra map[string]any) map[string]any {
+	payload := map[string]any{
+		"profile":      rollout.Profile,
+		"template":     rollout.Template,
+		"from_version": rollout.FromVersion,
+		"to_version":   rollout.ToVersion,
+	}
+	for key, value := range extra {
+		if value == "" {
+			continue
+		}
+		payload[key] = value
+	}
+	return payload
+}
@@ -0,0 +1,335 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+// newTestTemplateRollouts seeds ready versions v1..vN of agent-base at VMIDs
+// 9000 onwards, and a profile "agent" pinned to base.
+func newTestTemplateRollouts(t *testing.T, versions int, base string) (*TemplateRollouts, *proxmox.FakeBackend) {
+	t.Helper()
+	store := newTestStore(t)
+	backend := proxmox.NewFakeBackend()
+	for i := 1; i <= versions; i++ {
+		seedReadyTemplate(t, store, "agent-base", "v"+strconv.Itoa(i), 8999+i)
+		backend.AddTemplate(proxmox.VMID(8999 + i))
+	}
+	profiles := NewProfileRegistry(map[string]models.Profile{
+		"agent":  {Name: "agent", Template: base},
+		"latest": {Name: "latest", Template: "agent-base"},
+		"fixed":  {Name: "fixed", TemplateVM: 8000},
+	})
+	return NewTemplateRollouts(store, backend, profiles, log.New(io.Discard, "", 0)), backend
+}
+
+func seedReadyTemplate(t *testing.T, store *db.Store, name, version string, vmid int) db.Template {
+	t.Helper()
+	ctx := context.Background()
+	tmpl, err := store.CreateTemplate(ctx, db.Template{Name: name, Version: version, VMID: vmid, SpecYAML: "name: " + name + "\n"})
+	require.NoError(t, err)
+	require.NoError(t, store.FinishTemplate(ctx, tmpl.ID, db.TemplateReady, "", time.Now().UTC()))
+	tmpl.Status = db.TemplateReady
+	return tmpl
+}
+
+// routeKeys returns one key that lands in a rollout's canary and one that
+// does not.
+func routeKeys(t *testing.T, rollout db.TemplateRollout) (canary, stable string) {
+	t.Helper()
+	for i := 0; i < 1000 && (canary == "" || stable == ""); i++ {
+		key := "job-" + strconv.Itoa(i)
+		if templateRolloutBucket(rollout.ID, key) < rollout.Percent {
+			canary = key
+		} else {
+			stable = key
+		}
+	}
+	require.NotEmpty(t, canary)
+	require.NotEmpty(t, stable)
+	return canary, stable
+}
+
+func resolvedVMID(t *testing.T, rollouts *TemplateRollouts, key string) int {
+	t.Helper()
+	profile, _ := rollouts.profiles.Get("agent")
+	resolved, err := resolveProfileTemplate(context.Background(), rollouts.store, profile, key)
+	require.NoError(t, err)
+	return resolved.TemplateVM
+}
+
+func TestTemplateRolloutBucketSpreadsRequests(t *testing.T) {
+	assert.Equal(t, templateRolloutBucket(7, "job-1"), templateRolloutBucket(7, "job-1"), "a request always lands in the same bucket")
+	inCanary := 0
+	for i := 0; i < 2000; i++ {
+		if templateRolloutBucket(7, strconv.Itoa(1000+i)) < 10 {
+			inCanary++
+		}
+	}
+	assert.InDelta(t, 200, inCanary, 60, "about 10%% of requests land in a 10%% canary")
+}
+
+func TestTemplateRolloutStartValidates(t *testing.T) {
+	ctx := context.Background()
+	rollouts, _ := newTestTemplateRollouts(t, 2, "agent-base@v1")
+
+	for name, req := range map[string]templateRolloutRequest{
+		"unknown profile": {Profile: "missing"},
+		"template vmid":   {Profile: "fixed"},
+		"unpinned":        {Profile: "latest"},
+		"same version":    {Profile: "agent", To: "v1"},
+		"other template":  {Profile: "agent", To: "other@v1"},
+		"missing version": {Profile: "agent", To: "v9"},
+		"percent":         {Profile: "agent", Percent: 101},
+	} {
+		_, err := rollouts.Start(ctx, req)
+		assert.Error(t, err, name)
+	}
+	_, err := rollouts.Start(ctx, templateRolloutRequest{Profile: "missing"})
+	assert.ErrorIs(t, err, errTemplateProfileNotFound)
+	_, err = rollouts.Start(ctx, templateRolloutRequest{Profile: "latest"})
+	assert.ErrorIs(t, err, errTemplateRolloutInvalid)
+}
+
+func TestTemplateRolloutRoutesPromotesAndRollsBack(t *testing.T) {
+	ctx := context.Background()
+	rollouts, _ := newTestTemplateRollouts(t, 3, "agent-base@v1")
+
+	rollout, err := rollouts.Start(ctx, templateRolloutRequest{Profile: "agent", To: "v2", StartedBy: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultTemplateRolloutPercent, rollout.Percent)
+	assert.Equal(t, "agent-base@v1", rollout.FromRef())
+	assert.Equal(t, 9001, rollout.ToVMID)
+
+	_, err = rollouts.Start(ctx, templateRolloutRequest{Profile: "agent"})
+	require.ErrorIs(t, err, db.ErrTemplateRolloutActive)
+
+	canaryKey, stableKey := routeKeys(t, rollout)
+	assert.Equal(t, 9001, resolvedVMID(t, rollouts, canaryKey))
+	assert.Equal(t, 9000, resolvedVMID(t, rollouts, stableKey))
+	assert.Equal(t, 9000, resolvedVMID(t, rollouts, ""), "requests without a key stay on the current version")
+
+	_, err = rollouts.SetPercent(ctx, "agent", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 9001, resolvedVMID(t, rollouts, stableKey))
+	_, err = rollouts.SetPercent(ctx, "agent", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 9000, resolvedVMID(t, rollouts, canaryKey), "zero pauses the canary")
+
+	promoted, cmp, err := rollouts.Promote(ctx, "agent", false)
+	require.NoError(t, err)
+	assert.Equal(t, db.TemplateRolloutPromoted, promoted.Status)
+	assert.Equal(t, templateVerdictInsufficientData, cmp.Verdict)
+	assert.Equal(t, 9001, resolvedVMID(t, rollouts, ""))
+
+	_, _, err = rollouts.Promote(ctx, "agent", false)
+	require.ErrorIs(t, err, errTemplateRolloutNotFound)
+
+	// The next rollout starts from the promoted version, and rolling it back
+	// returns there rather than to the profile's pinned version.
+	next, err := rollouts.Start(ctx, templateRolloutRequest{Profile: "agent", Percent: 50})
+	require.NoError(t, err)
+	assert.Equal(t, "agent-base@v2", next.FromRef())
+	assert.Equal(t, "agent-base@v3", next.ToRef(), "the newest ready version is the default target")
+	rolledBack, _, err := rollouts.Rollback(ctx, "agent")
+	require.NoError(t, err)
+	assert.Equal(t, db.TemplateRolloutRolledBack, rolledBack.Status)
+	assert.Equal(t, 9001, resolvedVMID(t, rollouts, canaryKey))
+
+	latest, _, err := rollouts.Status(ctx, "agent")
+	require.NoError(t, err)
+	assert.Equal(t, next.ID, latest.ID)
+
+	events, err := rollouts.store.ListAllEvents(ctx)
+	require.NoError(t, err)
+	var kinds []string
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	assert.Equal(t, []string{
+		"template.rollout.started",
+		"template.rollout.updated",
+		"template.rollout.updated",
+		"template.rollout.promoted",
+		"template.rollout.started",
+		"template.rollout.rolled_back",
+	}, kinds)
+}
+
+// seedTemplateSandboxes records n sandboxes cloned from templateVMID, each
+// with one job. ready sandboxes report sandbox.slo.ready after readyIn, and
+// failed jobs emit job.failed.
+func seedTemplateSandboxes(t *testing.T, store *db.Store, firstVMID, templateVMID, n, ready, failed int, readyIn time.Duration) {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		vmid := firstVMID + i
+		require.NoError(t, store.CreateSandbox(ctx, models.Sandbox{
+			VMID: vmid, Name: fmt.Sprintf("sandbox-%d", vmid), Profile: "agent", State: models.SandboxRunning,
+		}))
+		require.NoError(t, store.UpdateSandboxTemplate(ctx, vmid, templateVMID))
+		jobID := fmt.Sprintf("job-%d", vmid)
+		require.NoError(t, store.CreateJob(ctx, models.Job{ID: jobID, RepoURL: "https://example.test/repo.git", Ref: "main", Profile: "agent", Status: models.JobRunning}))
+		_, err := store.UpdateJobSandbox(ctx, jobID, vmid)
+		require.NoError(t, err)
+		if i < ready {
+			payload, _ := json.Marshal(sloEventPayload{DurationMS: readyIn.Milliseconds()})
+			require.NoError(t, store.RecordEvent(ctx, string(EventKindSandboxSLOReady), &vmid, nil, "ready", string(payload)))
+		}
+		if i < failed {
+			require.NoError(t, store.RecordEvent(ctx, string(EventKindJobFailed), &vmid, &jobID, "failed", ""))
+		}
+	}
+}
+
+func TestTemplateRolloutComparison(t *testing.T) {
+	ctx := context.Background()
+	rollouts, _ := newTestTemplateRollouts(t, 2, "agent-base@v1")
+	_, err := rollouts.Start(ctx, templateRolloutRequest{Profile: "agent", To: "v2", Percent: 50})
+	require.NoError(t, err)
+
+	seedTemplateSandboxes(t, rollouts.store, 1000, 9000, 10, 10, 0, 40*time.Second)
+	seedTemplateSandboxes(t, rollouts.store, 2000, 9001, 2, 2, 0, 40*time.Second)
+	_, cmp, err := rollouts.Status(ctx, "agent")
+	require.NoError(t, err)
+	assert.Equal(t, templateVerdictInsufficientData, cmp.Verdict)
+
+	seedTemplateSandboxes(t, rollouts.store, 3000, 9001, 8, 6, 3, 90*time.Second)
+	_, cmp, err = rollouts.Status(ctx, "agent")
+	require.NoError(t, err)
+	assert.Equal(t, templateVerdictRegressed, cmp.Verdict)
+	assert.Equal(t, 10, cmp.Baseline.Sandboxes)
+	assert.Equal(t, 10, cmp.Canary.Sandboxes)
+	assert.Equal(t, 8, cmp.Canary.Ready)
+	assert.Equal(t, 3, cmp.Canary.JobsFailed)
+	assert.InDelta(t, 0.3, cmp.Canary.FailureRate, 0.001)
+	assert.Equal(t, 40*time.Second, cmp.Baseline.ReadyP95)
+	assert.Equal(t, 90*time.Second, cmp.Canary.ReadyP95)
+	require.Len(t, cmp.Reasons, 3)
+	assert.Contains(t, cmp.Reasons[0], "job failure rate 30.0% vs 0.0%")
+
+	_, _, err = rollouts.Promote(ctx, "agent", false)
+	require.ErrorIs(t, err, errTemplateRolloutRegressed)
+	promoted, _, err := rollouts.Promote(ctx, "agent", true)
+	require.NoError(t, err)
+	assert.Equal(t, db.TemplateRolloutPromoted, promoted.Status)
+}
+
+func TestJudgeTemplateRolloutHealthy(t *testing.T) {
+	baseline := templateVersionStats{Sandboxes: 20, Ready: 20, ReadyP95: 40 * time.Second, Jobs: 20, JobsFailed: 1, FailureRate: 0.05}
+	canary := templateVersionStats{Sandboxes: 5, Ready: 5, ReadyP95: 45 * time.Second, Jobs: 5}
+	verdict, reasons := judgeTemplateRollout(baseline, canary)
+	assert.Equal(t, templateVerdictHealthy, verdict)
+	assert.Empty(t, reasons)
+}
+
+func TestTemplateGCRetiresUnusedVersions(t *testing.T) {
+	ctx := context.Background()
+	rollouts, backend := newTestTemplateRollouts(t, 4, "agent-base@v2")
+	store := rollouts.store
+	// v1 is unused apart from one running sandbox, v2 is the profile's
+	// pinned version, v3 is unused and v4 is the newest.
+	seedTemplateSandboxes(t, store, 1000, 9000, 1, 1, 0, time.Second)
+
+	retired, err := rollouts.collectGarbage(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, retired, "the first pass only marks unused versions")
+	retired, err = rollouts.collectGarbage(ctx)
+	require.NoError(t, err)
+	require.Len(t, retired, 1)
+	assert.Equal(t, "agent-base@v3", retired[0].Ref())
+	_, err = backend.Status(ctx, 9002)
+	assert.ErrorIs(t, err, proxmox.ErrVMNotFound, "the retired template VM is destroyed")
+	v3, err := store.GetTemplate(ctx, "agent-base", "v3")
+	require.NoError(t, err)
+	assert.Equal(t, db.TemplateRetired, v3.Status)
+
+	// Once its last sandbox is destroyed, v1 is collected too.
+	require.NoError(t, store.ForceSetSandboxState(ctx, 1000, models.SandboxDestroyed))
+	_, err = rollouts.collectGarbage(ctx)
+	require.NoError(t, err)
+	retired, err = rollouts.collectGarbage(ctx)
+	require.NoError(t, err)
+	require.Len(t, retired, 1)
+	assert.Equal(t, "agent-base@v1", retired[0].Ref())
+
+	// A rollout keeps both of its versions; promoting it releases the old one.
+	_, err = rollouts.Start(ctx, templateRolloutRequest{Profile: "agent", To: "v4"})
+	require.NoError(t, err)
+	_, _ = rollouts.collectGarbage(ctx)
+	retired, err = rollouts.collectGarbage(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, retired)
+	_, _, err = rollouts.Promote(ctx, "agent", false)
+	require.NoError(t, err)
+	_, _ = rollouts.collectGarbage(ctx)
+	retired, err = rollouts.collectGarbage(ctx)
+	require.NoError(t, err)
+	require.Len(t, retired, 1)
+	assert.Equal(t, "agent-base@v2", retired[0].Ref())
+	_, err = backend.Status(ctx, 9003)
+	assert.NoError(t, err, "the promoted version's VM is kept")
+}
+
+func TestTemplateRolloutAPI(t *testing.T) {
+	rollouts, _ := newTestTemplateRollouts(t, 2, "agent-base@v1")
+	mux := http.NewServeMux()
+	NewTemplateRolloutAPI(rollouts).Register(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/v1/profiles/agent/upgrade", "")
+	require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodPost, "/v1/profiles/agent/upgrade", `{"percent":25}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var started V1TemplateRollout
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &started))
+	assert.Equal(t, "agent-base@v1", started.From)
+	assert.Equal(t, "agent-base@v2", started.To)
+	assert.Equal(t, 25, started.Percent)
+	assert.Equal(t, db.TemplateRolloutActive, started.Status)
+
+	rec = do(http.MethodPost, "/v1/profiles/agent/upgrade", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(http.MethodPost, "/v1/profiles/agent/upgrade/percent", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = do(http.MethodPost, "/v1/profiles/agent/upgrade/percent", `{"percent":60}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var status V1TemplateRollout
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, 60, status.Percent)
+	assert.Equal(t, 9001, status.Canary.VMID)
+	assert.Equal(t, templateVerdictInsufficientData, status.Verdict)
+
+	rec = do(http.MethodPost, "/v1/profiles/agent/upgrade/rollback", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPost, "/v1/profiles/agent/upgrade/promote", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "nothing left to promote")
+
+	rec = do(http.MethodGet, "/v1/profiles/agent/upgrade/promote", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	rec = do(http.MethodGet, "/v1/profiles/agent/other", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,739 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/agentlab/agentlab/internal/buildinfo"
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+const (
+	// templateVMIDStart is the first VMID handed to built templates, matching
+	// the 9xxx range scripts/create_template.sh uses.
+	templateVMIDStart = 9000
+	templateVMIDEnd   = 9999
+
+	defaultTemplateBuildTimeout = 30 * time.Minute
+	maxTemplateBuildTimeout     = 4 * time.Hour
+	defaultTemplateStorage      = "local-zfs"
+	defaultTemplateBridge       = "vmbr1"
+	defaultTemplateCores        = 2
+	defaultTemplateMemoryMB     = 4096
+	defaultTemplateDiskGB       = 40
+	templateBuildPollInterval   = 10 * time.Second
+	maxTemplateSpecBytes        = 256 << 10
+
+	// templateBuildDir holds the build scripts inside the builder VM. It is
+	// on tmpfs, so nothing the build writes there survives into the template.
+	templateBuildDir = "/run/agentlab-template"
+)
+
+var (
+	templateNamePattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+	templateVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+	templatePackagePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+:=~_-]*$`)
+	templateSHA256Pattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	autoTemplateVersion    = regexp.MustCompile(`^v([0-9]+)$`)
+
+	errTemplateSpecInvalid      = errors.New("invalid template spec")
+	errTemplateVersionExists    = errors.New("template version already exists")
+	errTemplateBuildUnsupported = errors.New("backend cannot build templates")
+	errTemplateNotFound         = errors.New("template not found")
+	errTemplateNotReady         = errors.New("template is not ready")
+)
+
+// templateSpec is the declarative description of a VM template. The daemon
+// builds it by booting the base image, installing packages and the guest
+// agent, running the scripts in order, then converting the VM to a template.
+type templateSpec struct {
+	Name         string               `yaml:"name"`
+	Version      string               `yaml:"version"`
+	Description  string               `yaml:"description"`
+	Image        templateSpecImage    `yaml:"image"`
+	Packages     []string             `yaml:"packages"`
+	GuestAgent   *bool                `yaml:"guest_agent"`
+	Scripts      []templateSpecScript `yaml:"scripts"`
+	Proxmox      templateSpecProxmox  `yaml:"proxmox"`
+	BuildTimeout string               `yaml:"build_timeout"`
+
+	buildTimeout time.Duration
+}
+
+type templateSpecImage struct {
+	URL    string `yaml:"url"`
+	SHA256 string `yaml:"sha256"`
+}
+
+type templateSpecScript struct {
+	Name string `yaml:"name"`
+	Run  string `yaml:"run"`
+}
+
+type templateSpecProxmox struct {
+	VMID     int    `yaml:"vmid"`
+	Storage  string `yaml:"storage"`
+	Bridge   string `yaml:"bridge"`
+	Cores    int    `yaml:"cores"`
+	MemoryMB int    `yaml:"memory_mb"`
+	DiskGB   int    `yaml:"disk_gb"`
+}
+
+// parseTemplateSpec decodes a spec strictly, fills defaults, and validates
+// it. Every error wraps errTemplateSpecInvalid.
+func parseTemplateSpec(data []byte) (templateSpec, error) {
+	var spec templateSpec
+	if len(bytes.TrimSpace(data)) == 0 {
+		return spec, fmt.Errorf("%w: spec is empty", errTemplateSpecInvalid)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return spec, fmt.Errorf("%w: %v", errTemplateSpecInvalid, err)
+	}
+	if err := spec.normalize(); err != nil {
+		return spec, fmt.Errorf("%w: %v", errTemplateSpecInvalid, err)
+	}
+	return spec, nil
+}
+
+func (s *templateSpec) normalize() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if !templateNamePattern.MatchString(s.Name) {
+		return errors.New("name must be lowercase letters, digits and dashes")
+	}
+	s.Version = strings.TrimSpace(s.Version)
+	if s.Version != "" && !templateVersionPattern.MatchString(s.Version) {
+		return errors.New("version must be letters, digits, dots, dashes and underscores")
+	}
+	s.Image.URL = strings.TrimSpace(s.Image.URL)
+	u, err := url.Parse(s.Image.URL)
+	if err != nil || s.Image.URL == "" {
+		return errors.New("image.url is required")
+	}
+	switch u.Scheme {
+	case "https", "http":
+		if u.Host == "" {
+			return errors.New("image.url must name a host")
+		}
+	case "file":
+		if !filepath.IsAbs(u.Path) {
+			return errors.New("file image.url must be absolute")
+		}
+	default:
+		return fmt.Errorf("image.url scheme %q is not supported (use https, http or file)", u.Scheme)
+	}
+	s.Image.SHA256 = strings.ToLower(strings.TrimSpace(s.Image.SHA256))
+	if !templateSHA256Pattern.MatchString(s.Image.SHA256) {
+		return errors.New("image.sha256 must be 64 hex characters")
+	}
+	for i, pkg := range s.Packages {
+		pkg = strings.TrimSpace(pkg)
+		if !templatePackagePattern.MatchString(pkg) {
+			return fmt.Errorf("packages[%d] %q is not a package name", i, pkg)
+		}
+		s.Packages[i] = pkg
+	}
+	for i := range s.Scripts {
+		script := &s.Scripts[i]
+		script.Name = strings.TrimSpace(script.Name)
+		if script.Name == "" {
+			script.Name = fmt.Sprintf("script-%d", i+1)
+		}
+		if !templateNamePattern.MatchString(script.Name) {
+			return fmt.Errorf("scripts[%d].name must be lowercase letters, digits and dashes", i)
+		}
+		if strings.TrimSpace(script.Run) == "" {
+			return fmt.Errorf("scripts[%d].run is required", i)
+		}
+	}
+	p := &s.Proxmox
+	if p.VMID != 0 && (p.VMID < templateVMIDStart || p.VMID > templateVMIDEnd) {
+		return fmt.Errorf("proxmox.vmid must be between %d and %d", templateVMIDStart, templateVMIDEnd)
+	}
+	if p.Storage = strings.TrimSpace(p.Storage); p.Storage == "" {
+		p.Storage = defaultTemplateStorage
+	}
+	if p.Bridge = strings.TrimSpace(p.Bridge); p.Bridge == "" {
+		p.Bridge = defaultTemplateBridge
+	}
+	if p.Cores == 0 {
+		p.Cores = defaultTemplateCores
+	}
+	if p.MemoryMB == 0 {
+		p.MemoryMB = defaultTemplateMemoryMB
+	}
+	if p.DiskGB == 0 {
+		p.DiskGB = defaultTemplateDiskGB
+	}
+	if p.Cores < 0 || p.MemoryMB < 0 || p.DiskGB < 0 {
+		return errors.New("proxmox cores, memory_mb and disk_gb must be positive")
+	}
+	s.buildTimeout = defaultTemplateBuildTimeout
+	if raw := strings.TrimSpace(s.BuildTimeout); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 || timeout > maxTemplateBuildTimeout {
+			return fmt.Errorf("build_timeout must be a duration up to %s", maxTemplateBuildTimeout)
+		}
+		s.buildTimeout = timeout
+	}
+	return nil
+}
+
+func (s templateSpec) guestAgent() bool {
+	return s.GuestAgent == nil || *s.GuestAgent
+}
+
+// renderUserData returns the cloud-init user-data that provisions the builder
+// VM. Packages install through cloud-init; the build script then enables the
+// guest agent, runs the spec's scripts in order, resets cloud-init so clones
+// provision afresh, and writes a marker to tmpfs. The VM powers itself off
+// only when that marker exists, so a failed build leaves it running until the
+// build timeout.
+func (s templateSpec) renderUserData() (string, error) {
+	type writeFile struct {
+		Path        string `yaml:"path"`
+		Permissions string `yaml:"permissions"`
+		Content     string `yaml:"content"`
+	}
+	type powerState struct {
+		Mode      string `yaml:"mode"`
+		Condition string `yaml:"condition"`
+		Timeout   int    `yaml:"timeout"`
+	}
+	type userData struct {
+		PackageUpdate bool        `yaml:"package_update"`
+		Packages      []string    `yaml:"packages,omitempty"`
+		WriteFiles    []writeFile `yaml:"write_files"`
+		RunCmd        [][]string  `yaml:"runcmd"`
+		PowerState    powerState  `yaml:"power_state"`
+	}
+
+	packages := append([]string(nil), s.Packages...)
+	if s.guestAgent() && !containsString(packages, "qemu-guest-agent") {
+		packages = append([]string{"qemu-guest-agent"}, packages...)
+	}
+
+	var build strings.Builder
+	build.WriteString("#!/bin/bash\nset -euo pipefail\n")
+	if len(packages) > 0 {
+		// cloud-init logs package failures and carries on, so check here.
+		build.WriteString("if command -v dpkg-query >/dev/null 2>&1; then\n")
+		fmt.Fprintf(&build, "  for pkg in %s; do\n", strings.Join(packages, " "))
+		build.WriteString("    [ \"$(dpkg-query -W -f='${db:Status-Status}' \"$pkg\" 2>/dev/null)\" = installed ] || { echo \"package $pkg is not installed\" >&2; exit 1; }\n")
+		build.WriteString("  done\nfi\n")
+	}
+	if s.guestAgent() {
+		build.WriteString("systemctl enable qemu-guest-agent\n")
+	}
+	files := []writeFile{}
+	for i, script := range s.Scripts {
+		path := fmt.Sprintf("%s/scripts/%02d-%s", templateBuildDir, i+1, script.Name)
+		run := script.Run
+		if !strings.HasPrefix(run, "#!") {
+			run = "#!/bin/bash\nset -euo pipefail\n" + run
+		}
+		if !strings.HasSuffix(run, "\n") {
+			run += "\n"
+		}
+		files = append(files, writeFile{Path: path, Permissions: "0700", Content: run})
+		fmt.Fprintf(&build, "echo 'agentlab-template: running %s'\n%s\n", script.Name, path)
+	}
+	build.WriteString("cloud-init clean --logs\n")
+	build.WriteString("truncate -s 0 /etc/machine-id\nrm -f /var/lib/dbus/machine-id\n")
+	fmt.Fprintf(&build, "touch %s/ok\n", templateBuildDir)
+	files = append(files, writeFile{Path: templateBuildDir + "/build.sh", Permissions: "0700", Content: build.String()})
+
+	out, err := yaml.Marshal(userData{
+		PackageUpdate: len(packages) > 0,
+		Packages:      packages,
+		WriteFiles:    files,
+		RunCmd:        [][]string{{"/bin/bash", templateBuildDir + "/build.sh"}},
+		PowerState: powerState{
+			Mode:      "poweroff",
+			Condition: "test -f " + templateBuildDir + "/ok",
+			Timeout:   30,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return "#cloud-config\n" + string(out), nil
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// nextTemplateVersion returns v<N+1>, where N is the highest vN version of
+// the template so far.
+func nextTemplateVersion(existing []db.Template) string {
+	highest := 0
+	for _, tmpl := range existing {
+		if m := autoTemplateVersion.FindStringSubmatch(tmpl.Version); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil && n > highest {
+				highest = n
+			}
+		}
+	}
+	return "v" + strconv.Itoa(highest+1)
+}
+
+// parseTemplateRef splits "name@version". The version is empty when the
+// reference names only the template.
+func parseTemplateRef(ref string) (name, version string, err error) {
+	ref = strings.TrimSpace(ref)
+	name, version, _ = strings.Cut(ref, "@")
+	if !templateNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("template reference %q: name must be lowercase letters, digits and dashes", ref)
+	}
+	if strings.Contains(ref, "@") && !templateVersionPattern.MatchString(version) {
+		return "", "", fmt.Errorf("template reference %q: invalid version", ref)
+	}
+	return name, version, nil
+}
+
+// resolveTemplateRef finds the template a reference names. A bare name
+// resolves to the newest ready version; a pinned version must be ready.
+func resolveTemplateRef(ctx context.Context, store *db.Store, ref string) (db.Template, error) {
+	name, version, err := parseTemplateRef(ref)
+	if err != nil {
+		return db.Template{}, err
+	}
+	var tmpl db.Template
+	if version == "" {
+		tmpl, err = store.LatestReadyTemplate(ctx, name)
+	} else {
+		tmpl, err = store.GetTemplate(ctx, name, version)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Template{}, fmt.Errorf("%w: %s", errTemplateNotFound, ref)
+	}
+	if err != nil {
+		return db.Template{}, err
+	}
+	if tmpl.Status != db.TemplateReady {
+		return db.Template{}, fmt.Errorf("%w: %s is %s", errTemplateNotReady, tmpl.Ref(), tmpl.Status)
+	}
+	return tmpl, nil
+}
+
+// resolveProfileTemplate fills TemplateVM for a profile that references a
+// built template by name. Profiles that give template_vmid directly are
+// returned unchanged.
+//
+// While the profile has a rollout in progress, routeKey (a job ID or sandbox
+// VMID) decides whether the request lands in the canary share, so retries of
+// one request get the same version. An empty routeKey always resolves to the
+// version the profile uses outside the canary.
+func resolveProfileTemplate(ctx context.Context, store *db.Store, profile models.Profile, routeKey string) (models.Profile, error) {
+	ref := strings.TrimSpace(profile.Template)
+	if ref == "" {
+		return profile, nil
+	}
+	if store == nil {
+		return profile, errors.New("template store unavailable")
+	}
+	rollout, err := store.CurrentTemplateRollout(ctx, profile.Name, ref)
+	switch {
+	case err == nil:
+		ref = templateRolloutTarget(rollout, routeKey)
+	case !errors.Is(err, sql.ErrNoRows):
+		return profile, fmt.Errorf("profile %s: load template rollout: %w", profile.Name, err)
+	}
+	tmpl, err := resolveTemplateRef(ctx, store, ref)
+	if err != nil {
+		return profile, fmt.Errorf("profile %s: %w", profile.Name, err)
+	}
+	profile.TemplateVM = tmpl.VMID
+	return profile, nil
+}
+
+// TemplateBuilds runs template builds: it records each version in the
+// templates table and builds it in the background on the daemon lifecycle.
+type TemplateBuilds struct {
+	store    *db.Store
+	backend  proxmox.Backend
+	snippets proxmox.SnippetStore
+	imageDir string
+	client   *http.Client
+	runner   BackgroundRunner
+	logger   *log.Logger
+	now      func() time.Time
+	poll     time.Duration
+
+	// mu serialises version and VMID allocation; imageMu serialises image
+	// downloads so two builds of one image share a single fetch.
+	mu      sync.Mutex
+	imageMu sync.Mutex
+}
+
+// NewTemplateBuilds returns a template builder. Base images are cached in
+// imageDir by checksum.
+func NewTemplateBuilds(store *db.Store, backend proxmox.Backend, snippets proxmox.SnippetStore, imageDir string, logger *log.Logger) *TemplateBuilds {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &TemplateBuilds{
+		store:    store,
+		backend:  backend,
+		snippets: snippets,
+		imageDir: imageDir,
+		client:   &http.Client{},
+		runner:   DetachedRunner(),
+		logger:   logger,
+		now:      time.Now,
+		poll:     templateBuildPollInterval,
+	}
+}
+
+// WithBackgroundRunner runs builds under the daemon lifecycle so shutdown
+// cancels and awaits them.
+func (b *TemplateBuilds) WithBackgroundRunner(r BackgroundRunner) *TemplateBuilds {
+	if b == nil || r == nil {
+		return b
+	}
+	b.runner = r
+	return b
+}
+
+// Start validates a spec, records the new version as building, and starts
+// the build in the background.
+func (b *TemplateBuilds) Start(ctx context.Context, specYAML []byte, builtBy string) (db.Template, error) {
+	if b == nil || b.store == nil {
+		return db.Template{}, errors.New("template builds unavailable")
+	}
+	builder, ok := b.backend.(proxmox.TemplateBuilder)
+	if !ok {
+		return db.Template{}, errTemplateBuildUnsupported
+	}
+	spec, err := parseTemplateSpec(specYAML)
+	if err != nil {
+		return db.Template{}, err
+	}
+	sum := sha256.Sum256(specYAML)
+
+	tmpl, err := b.reserve(ctx, spec, db.Template{
+		Name:           spec.Name,
+		SpecYAML:       string(specYAML),
+		SpecSHA256:     hex.EncodeToString(sum[:]),
+		ImageURL:       spec.Image.URL,
+		ImageSHA256:    spec.Image.SHA256,
+		BuiltBy:        builtBy,
+		BuilderVersion: buildinfo.Version,
+	})
+	if err != nil {
+		return db.Template{}, err
+	}
+	b.emit(ctx, EventKindTemplateBuildStarted, "template build started: "+tmpl.Ref(), map[string]any{
+		"template": tmpl.Name,
+		"version":  tmpl.Version,
+		"vmid":     tmpl.VMID,
+		"built_by": tmpl.BuiltBy,
+	})
+	if !b.runner.Go("template-build "+tmpl.Ref(), func(ctx context.Context) {
+		b.build(ctx, builder, spec, tmpl)
+	}) {
+		b.fail(context.Background(), tmpl, errors.New("daemon is shutting down"), 0)
+		return db.Template{}, errors.New("daemon is shutting down")
+	}
+	return tmpl, nil
+}
+
+// reserve picks the version and VMID and inserts the building row.
+func (b *TemplateBuilds) reserve(ctx context.Context, spec templateSpec, tmpl db.Template) (db.Template, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	existing, err := b.store.ListTemplates(ctx, "")
+	if err != nil {
+		return db.Template{}, err
+	}
+	tmpl.Version = spec.Version
+	if tmpl.Version == "" {
+		var named []db.Template
+		for _, t := range existing {
+			if t.Name == spec.Name {
+				named = append(named, t)
+			}
+		}
+		tmpl.Version = nextTemplateVersion(named)
+	}
+	if tmpl.VMID, err = b.allocateVMID(ctx, spec.Proxmox.VMID, existing); err != nil {
+		return db.Template{}, err
+	}
+	tmpl.CreatedAt = b.now().UTC()
+	created, err := b.store.CreateTemplate(ctx, tmpl)
+	if isUniqueConstraint(err) {
+		return db.Template{}, fmt.Errorf("%w: %s", errTemplateVersionExists, tmpl.Ref())
+	}
+	return created, err
+}
+
+// allocateVMID returns the requested VMID when it is free, or the next free
+// VMID above every template built so far.
+func (b *TemplateBuilds) allocateVMID(ctx context.Context, requested int, existing []db.Template) (int, error) {
+	taken := map[int]bool{}
+	next := templateVMIDStart
+	for _, t := range existing {
+		if t.Status != db.TemplateFailed {
+			taken[t.VMID] = true
+		}
+		if t.VMID >= next {
+			next = t.VMID + 1
+		}
+	}
+	vms, err := b.backend.ListVMs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list vms: %w", err)
+	}
+	for _, vm := range vms {
+		taken[int(vm.VMID)] = true
+	}
+	if requested > 0 {
+		if taken[requested] {
+			return 0, fmt.Errorf("%w: proxmox.vmid %d is in use", errTemplateSpecInvalid, requested)
+		}
+		return requested, nil
+	}
+	for vmid := next; vmid <= templateVMIDEnd; vmid++ {
+		if !taken[vmid] {
+			return vmid, nil
+		}
+	}
+	return 0, fmt.Errorf("no free template VMID between %d and %d", templateVMIDStart, templateVMIDEnd)
+}
+
+// build runs one template build to completion and records the outcome.
+func (b *TemplateBuilds) build(ctx context.Context, builder proxmox.TemplateBuilder, spec templateSpec, tmpl db.Template) {
+	started := b.now()
+	ctx, cancel := context.WithTimeout(ctx, spec.buildTimeout)
+	defer cancel()
+
+	created, err := b.provision(ctx, builder, spec, tmpl)
+	if err != nil {
+		if created {
+			// The build context may be spent; clean up on a fresh one.
+			cleanupCtx, cleanupCancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+			_ = b.backend.Stop(cleanupCtx, proxmox.VMID(tmpl.VMID))
+			if destroyErr := b.backend.Destroy(cleanupCtx, proxmox.VMID(tmpl.VMID)); destroyErr != nil && !errors.Is(destroyErr, proxmox.ErrVMNotFound) {
+				b.logger.Printf("template %s: destroy builder vm %d: %v", tmpl.Ref(), tmpl.VMID, destroyErr)
+			}
+			cleanupCancel()
+		}
+		b.fail(context.WithoutCancel(ctx), tmpl, err, b.now().Sub(started))
+		return
+	}
+
+	finishCtx := context.WithoutCancel(ctx)
+	if err := b.store.FinishTemplate(finishCtx, tmpl.ID, db.TemplateReady, "", b.now().UTC()); err != nil {
+		b.logger.Printf("template %s: record ready: %v", tmpl.Ref(), err)
+		return
+	}
+	b.emit(finishCtx, EventKindTemplateBuildReady, "template ready: "+tmpl.Ref(), map[string]any{
+		"template":    tmpl.Name,
+		"version":     tmpl.Version,
+		"vmid":        tmpl.VMID,
+		"duration_ms": b.now().Sub(started).Milliseconds(),
+	})
+}
+
+// provision creates, boots and converts the builder VM. created reports
+// whether a VM exists that the caller must clean up on failure.
+func (b *TemplateBuilds) provision(ctx context.Context, builder proxmox.TemplateBuilder, spec templateSpec, tmpl db.Template) (created bool, err error) {
+	imagePath, err := b.fetchImage(ctx, spec.Image)
+	if err != nil {
+		return false, err
+	}
+	userData, err := spec.renderUserData()
+	if err != nil {
+		return false, fmt.Errorf("render user-data: %w", err)
+	}
+	snippet, err := b.snippets.CreateUserData(proxmox.VMID(tmpl.VMID), userData)
+	if err != nil {
+		return false, fmt.Errorf("write build snippet: %w", err)
+	}
+	defer func() { _ = b.snippets.Delete(snippet) }()
+
+	vmid := proxmox.VMID(tmpl.VMID)
+	err = builder.CreateFromImage(ctx, vmid, proxmox.ImageVM{
+		Name:      fmt.Sprintf("tmpl-%s-%s", tmpl.Name, strings.ReplaceAll(tmpl.Version, "_", "-")),
+		ImagePath: imagePath,
+		Storage:   spec.Proxmox.Storage,
+		Bridge:    spec.Proxmox.Bridge,
+		Cores:     spec.Proxmox.Cores,
+		MemoryMB:  spec.Proxmox.MemoryMB,
+	})
+	if err != nil {
+		return false, fmt.Errorf("create builder vm: %w", err)
+	}
+	if err := b.backend.Configure(ctx, vmid, proxmox.VMConfig{CloudInit: snippet.StoragePath, RootDiskGB: spec.Proxmox.DiskGB}); err != nil {
+		return true, fmt.Errorf("configure builder vm: %w", err)
+	}
+	if err := b.backend.Start(ctx, vmid); err != nil {
+		return true, fmt.Errorf("start builder vm: %w", err)
+	}
+	if err := b.waitPoweredOff(ctx, vmid); err != nil {
+		return true, err
+	}
+	if err := builder.ConvertToTemplate(ctx, vmid); err != nil {
+		return true, fmt.Errorf("convert to template: %w", err)
+	}
+	if err := b.backend.ValidateTemplate(ctx, vmid); err != nil {
+		return true, fmt.Errorf("validate template: %w", err)
+	}
+	return true, nil
+}
+
+// waitPoweredOff polls until the builder VM powers itself off, which it does
+// only after every build step succeeded.
+func (b *TemplateBuilds) waitPoweredOff(ctx context.Context, vmid proxmox.VMID) error {
+	ticker := time.NewTicker(b.poll)
+	defer ticker.Stop()
+	for {
+		status, err := b.backend.Status(ctx, vmid)
+		if err == nil && status == proxmox.StatusStopped {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("builder vm %d did not power off before the build timeout; a package or script failed or is still running (see the vm's serial console)", vmid)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchImage returns the path of the verified base image, downloading it
+// into the cache when it is missing.
+func (b *TemplateBuilds) fetchImage(ctx context.Context, image templateSpecImage) (string, error) {
+	b.imageMu.Lock()
+	defer b.imageMu.Unlock()
+	if strings.TrimSpace(b.imageDir) == "" {
+		return "", errors.New("template image directory is not configured")
+	}
+	if err := os.MkdirAll(b.imageDir, 0o750); err != nil {
+		return "", fmt.Errorf("create image dir: %w", err)
+	}
+	path, err := filepath.Abs(filepath.Join(b.imageDir, image.SHA256+".img"))
+	if err != nil {
+		return "", err
+	}
+	if sum, err := fileSHA256(path); err == nil && sum == image.SHA256 {
+		return path, nil
+	}
+
+	src, err := b.openImage(ctx, image.URL)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(b.imageDir, ".download-*")
+	if err != nil {
+		return "", fmt.Errorf("create image file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, copyErr := io.Copy(io.MultiWriter(tmp, hash), src)
+	closeErr := tmp.Close()
+	if copyErr != nil {
+		return "", fmt.Errorf("download image: %w", copyErr)
+	}
+	if closeErr != nil {
+		return "", fmt.Errorf("write image: %w", closeErr)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != image.SHA256 {
+		return "", fmt.Errorf("image checksum mismatch: got sha256 %s, want %s", got, image.SHA256)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("store image: %w", err)
+	}
+	return path, nil
+}
+
+func (b *TemplateBuilds) openImage(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "file" {
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, fmt.Errorf("open image: %w", err)
+		}
+		return f, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download image: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download image: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (b *TemplateBuilds) fail(ctx context.Context, tmpl db.Template, cause error, elapsed time.Duration) {
+	msg := cause.Error()
+	if err := b.store.FinishTemplate(ctx, tmpl.ID, db.TemplateFailed, msg, b.now().UTC()); err != nil {
+		b.logger.Printf("template %s: record failure: %v", tmpl.Ref(), err)
+	}
+	b.logger.Printf("template %s: build failed: %s", tmpl.Ref(), msg)
+	b.emit(ctx, EventKindTemplateBuildFailed, "template build failed: "+tmpl.Ref(), map[string]any{
+		"template":    tmpl.Name,
+		"version":     tmpl.Version,
+		"vmid":        tmpl.VMID,
+		"error":       msg,
+		"duration_ms": elapsed.Milliseconds(),
+	})
+}
+
+func (b *TemplateBuilds) emit(ctx context.Context, kind EventKind, msg string, payload any) {
+	if err := emitEvent(ctx, NewStoreEventRecorder(b.store), kind, nil, nil, msg, payload); err != nil {
+		b.logger.Printf("template event %s: %v", kind, err)
+	}
+}
@@ -0,0 +1,279 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+const testTemplateSHA = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestParseTemplateSpecDefaults(t *testing.T) {
+	spec, err := parseTemplateSpec([]byte(`
+name: agent-base
+image:
+  url: https://cloud-images.example.test/noble.img
+  sha256: ` + strings.ToUpper(testTemplateSHA) + `
+packages: [git, curl]
+scripts:
+  - run: echo hi
+`))
+	require.NoError(t, err)
+	assert.Equal(t, testTemplateSHA, spec.Image.SHA256, "checksums are lowercased")
+	assert.Equal(t, "", spec.Version, "an empty version is assigned at build time")
+	assert.Equal(t, "script-1", spec.Scripts[0].Name)
+	assert.True(t, spec.guestAgent(), "the guest agent is installed by default")
+	assert.Equal(t, defaultTemplateStorage, spec.Proxmox.Storage)
+	assert.Equal(t, defaultTemplateBridge, spec.Proxmox.Bridge)
+	assert.Equal(t, defaultTemplateDiskGB, spec.Proxmox.DiskGB)
+	assert.Equal(t, defaultTemplateBuildTimeout, spec.buildTimeout)
+}
+
+func TestParseTemplateSpecRejectsInvalid(t *testing.T) {
+	base := "name: agent-base\nimage:\n  url: https://example.test/noble.img\n  sha256: " + testTemplateSHA + "\n"
+	for name, spec := range map[string]string{
+		"empty":          "",
+		"unknown field":  base + "packges: [git]\n",
+		"bad name":       strings.Replace(base, "agent-base", "Agent Base", 1),
+		"no checksum":    "name: agent-base\nimage:\n  url: https://example.test/noble.img\n",
+		"bad scheme":     strings.Replace(base, "https://", "ftp://", 1),
+		"relative file":  strings.Replace(base, "https://example.test/noble.img", "file:noble.img", 1),
+		"bad package":    base + "packages: ['git; rm -rf /']\n",
+		"empty script":   base + "scripts:\n  - name: setup\n",
+		"vmid range":     base + "proxmox:\n  vmid: 100\n",
+		"long timeout":   base + "build_timeout: 12h\n",
+		"bad version":    base + "version: ../v1\n",
+		"bad script dir": base + "scripts:\n  - name: ../../etc\n    run: id\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTemplateSpec([]byte(spec))
+			assert.ErrorIs(t, err, errTemplateSpecInvalid)
+		})
+	}
+}
+
+func TestTemplateSpecRenderUserData(t *testing.T) {
+	spec, err := parseTemplateSpec([]byte(`
+name: agent-base
+image:
+  url: https://example.test/noble.img
+  sha256: ` + testTemplateSHA + `
+packages: [git]
+scripts:
+  - name: node
+    run: |
+      curl -fsSL https://example.test/node.sh | bash
+  - name: shebang
+    run: "#!/usr/bin/env python3\nprint('hi')"
+`))
+	require.NoError(t, err)
+	out, err := spec.renderUserData()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, "#cloud-config\n"))
+
+	var doc struct {
+		Packages   []string `yaml:"packages"`
+		WriteFiles []struct {
+			Path    string `yaml:"path"`
+			Content string `yaml:"content"`
+		} `yaml:"write_files"`
+		RunCmd     [][]string `yaml:"runcmd"`
+		PowerState struct {
+			Mode      string `yaml:"mode"`
+			Condition string `yaml:"condition"`
+		} `yaml:"power_state"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(out), &doc))
+	assert.Equal(t, []string{"qemu-guest-agent", "git"}, doc.Packages)
+	require.Len(t, doc.WriteFiles, 3)
+	assert.Equal(t, templateBuildDir+"/scripts/01-node", doc.WriteFiles[0].Path)
+	assert.True(t, strings.HasPrefix(doc.WriteFiles[0].Content, "#!/bin/bash\nset -euo pipefail\n"))
+	assert.True(t, strings.HasPrefix(doc.WriteFiles[1].Content, "#!/usr/bin/env python3\n"), "scripts with a shebang keep it")
+
+	build := doc.WriteFiles[2].Content
+	assert.Contains(t, build, "systemctl enable qemu-guest-agent")
+	assert.Less(t, strings.Index(build, "01-node"), strings.Index(build, "02-shebang"), "scripts run in spec order")
+	assert.Contains(t, build, "cloud-init clean --logs")
+	assert.True(t, strings.HasSuffix(build, "touch "+templateBuildDir+"/ok\n"), "the success marker is the last step")
+	assert.Equal(t, [][]string{{"/bin/bash", templateBuildDir + "/build.sh"}}, doc.RunCmd)
+	assert.Equal(t, "poweroff", doc.PowerState.Mode)
+	assert.Equal(t, "test -f "+templateBuildDir+"/ok", doc.PowerState.Condition)
+}
+
+func TestTemplateRefs(t *testing.T) {
+	name, version, err := parseTemplateRef("agent-base@v12")
+	require.NoError(t, err)
+	assert.Equal(t, "agent-base", name)
+	assert.Equal(t, "v12", version)
+
+	name, version, err = parseTemplateRef("agent-base")
+	require.NoError(t, err)
+	assert.Equal(t, "agent-base", name)
+	assert.Empty(t, version)
+
+	for _, ref := range []string{"", "@v1", "agent-base@", "Agent@v1"} {
+		_, _, err := parseTemplateRef(ref)
+		assert.Error(t, err, ref)
+	}
+
+	assert.Equal(t, "v1", nextTemplateVersion(nil))
+	assert.Equal(t, "v13", nextTemplateVersion([]db.Template{{Version: "v12"}, {Version: "v3"}, {Version: "2026.10"}}))
+}
+
+// newTestTemplateBuilds returns builds that run inline against a fake
+// backend, and an HTTP server that serves image as the base image.
+func newTestTemplateBuilds(t *testing.T, image []byte) (*TemplateBuilds, *proxmox.FakeBackend, string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(image)
+	}))
+	t.Cleanup(srv.Close)
+
+	backend := proxmox.NewFakeBackend()
+	builds := NewTemplateBuilds(newTestStore(t), backend,
+		proxmox.SnippetStore{Storage: "local", Dir: t.TempDir()},
+		filepath.Join(t.TempDir(), "images"), log.New(io.Discard, "", 0)).
+		WithBackgroundRunner(inlineJobRunner{})
+	builds.poll = time.Millisecond
+	return builds, backend, srv.URL + "/noble.img"
+}
+
+func testTemplateSpec(name, url, sha string) []byte {
+	return []byte("name: " + name + "\nimage:\n  url: " + url + "\n  sha256: " + sha + "\npackages: [git]\n")
+}
+
+func TestTemplateBuildLifecycle(t *testing.T) {
+	ctx := context.Background()
+	image := []byte("qcow2 image bytes")
+	sum := sha256.Sum256(image)
+	builds, backend, imageURL := newTestTemplateBuilds(t, image)
+
+	tmpl, err := builds.Start(ctx, testTemplateSpec("agent-base", imageURL, hex.EncodeToString(sum[:])), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "agent-base@v1", tmpl.Ref())
+	assert.Equal(t, templateVMIDStart, tmpl.VMID)
+
+	got, err := builds.store.GetTemplate(ctx, "agent-base", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, db.TemplateReady, got.Status, got.Error)
+	assert.Equal(t, "alice", got.BuiltBy)
+	assert.False(t, got.FinishedAt.IsZero())
+
+	cfg, err := backend.VMConfig(ctx, proxmox.VMID(tmpl.VMID))
+	require.NoError(t, err)
+	assert.Equal(t, "1", cfg["template"])
+	_, err = os.Stat(filepath.Join(builds.imageDir, hex.EncodeToString(sum[:])+".img"))
+	assert.NoError(t, err, "the verified image is cached by checksum")
+	snippets, err := os.ReadDir(builds.snippets.Dir)
+	require.NoError(t, err)
+	assert.Empty(t, snippets, "the build snippet is removed")
+
+	// The next build takes the next version and VMID.
+	next, err := builds.Start(ctx, testTemplateSpec("agent-base", imageURL, hex.EncodeToString(sum[:])), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "agent-base@v2", next.Ref())
+	assert.Equal(t, templateVMIDStart+1, next.VMID)
+
+	_, err = builds.Start(ctx, append(testTemplateSpec("agent-base", imageURL, hex.EncodeToString(sum[:])), "version: v2\n"...), "alice")
+	assert.ErrorIs(t, err, errTemplateVersionExists)
+
+	// Profiles resolve a bare name to the newest ready version.
+	profile, err := resolveProfileTemplate(ctx, builds.store, models.Profile{Name: "agent", Template: "agent-base"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, next.VMID, profile.TemplateVM)
+	profile, err = resolveProfileTemplate(ctx, builds.store, models.Profile{Name: "agent", Template: "agent-base@v1"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.VMID, profile.TemplateVM)
+	_, err = resolveProfileTemplate(ctx, builds.store, models.Profile{Name: "agent", Template: "agent-base@v9"}, "")
+	assert.ErrorIs(t, err, errTemplateNotFound)
+
+	events, err := builds.store.ListAllEvents(ctx)
+	require.NoError(t, err)
+	var kinds []string
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	assert.Equal(t, []string{
+		string(EventKindTemplateBuildStarted), string(EventKindTemplateBuildReady),
+		string(EventKindTemplateBuildStarted), string(EventKindTemplateBuildReady),
+	}, kinds)
+}
+
+func TestTemplateBuildChecksumMismatchFails(t *testing.T) {
+	ctx := context.Background()
+	builds, backend, imageURL := newTestTemplateBuilds(t, []byte("tampered"))
+
+	tmpl, err := builds.Start(ctx, testTemplateSpec("agent-base", imageURL, testTemplateSHA), "alice")
+	require.NoError(t, err)
+
+	got, err := builds.store.GetTemplate(ctx, "agent-base", tmpl.Version)
+	require.NoError(t, err)
+	assert.Equal(t, db.TemplateFailed, got.Status)
+	assert.Contains(t, got.Error, "checksum mismatch")
+	_, err = backend.Status(ctx, proxmox.VMID(tmpl.VMID))
+	assert.ErrorIs(t, err, proxmox.ErrVMNotFound, "no builder VM is created")
+
+	_, err = resolveProfileTemplate(ctx, builds.store, models.Profile{Name: "agent", Template: tmpl.Ref()}, "")
+	assert.ErrorIs(t, err, errTemplateNotReady)
+	_, err = resolveProfileTemplate(ctx, builds.store, models.Profile{Name: "agent", Template: "agent-base"}, "")
+	assert.ErrorIs(t, err, errTemplateNotFound)
+}
+
+func TestTemplateAPIBuildAndShow(t *testing.T) {
+	image := []byte("qcow2 image bytes")
+	sum := sha256.Sum256(image)
+	builds, _, imageURL := newTestTemplateBuilds(t, image)
+	mux := http.NewServeMux()
+	NewTemplateAPI(builds.store, builds).Register(mux)
+
+	do := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(body)))
+		return rec
+	}
+
+	body, err := json.Marshal(V1TemplateBuildRequest{Spec: string(testTemplateSpec("agent-base", imageURL, hex.EncodeToString(sum[:])))})
+	require.NoError(t, err)
+	rec := do(http.MethodPost, "/v1/templates", body)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var started V1Template
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &started))
+	assert.Equal(t, "agent-base@v1", started.Ref)
+	assert.Equal(t, "local", started.BuiltBy)
+
+	rec = do(http.MethodGet, "/v1/templates/agent-base/v1", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var shown V1Template
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &shown))
+	assert.Equal(t, db.TemplateReady, shown.Status)
+	assert.Contains(t, shown.Spec, "name: agent-base")
+
+	rec = do(http.MethodGet, "/v1/templates?name=agent-base", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list V1TemplatesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Templates, 1)
+	assert.Empty(t, list.Templates[0].Spec, "listings omit the spec")
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/v1/templates/agent-base/v9", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/v1/templates", []byte(`{"spec":"name: x\n"}`)).Code)
+}
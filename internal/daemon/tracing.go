@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/tracing"
+)
+
+// Spans are only recorded when tracing_endpoint is configured. Jobs and
+// sandboxes store the traceparent of the span that provisioned them, so
+// requests that arrive later from the guest (bootstrap fetch, runner reports,
+// integration proxy calls) join the same trace.
+
+// jobTraceContext continues the trace stored for jobID, if any.
+func jobTraceContext(ctx context.Context, store *db.Store, jobID string) context.Context {
+	if !tracing.Enabled() || store == nil || strings.TrimSpace(jobID) == "" {
+		return ctx
+	}
+	traceparent, err := store.GetJobTraceparent(ctx, jobID)
+	if err != nil {
+		return ctx
+	}
+	return tracing.ContextWithRemote(ctx, traceparent)
+}
+
+// sandboxTraceContext continues the trace stored for vmid, if any.
+func sandboxTraceContext(ctx context.Context, store *db.Store, vmid int) context.Context {
+	if !tracing.Enabled() || store == nil || vmid <= 0 {
+		return ctx
+	}
+	traceparent, err := store.GetSandboxTraceparent(ctx, vmid)
+	if err != nil {
+		return ctx
+	}
+	return tracing.ContextWithRemote(ctx, traceparent)
+}
+
+// requestTraceContext continues an incoming W3C traceparent header, falling
+// back to fallback when the request carries none.
+func requestTraceContext(r *http.Request, fallback func(context.Context) context.Context) context.Context {
+	ctx := r.Context()
+	if traceparent := r.Header.Get("traceparent"); traceparent != "" {
+		if _, ok := tracing.ParseTraceparent(traceparent); ok {
+			return tracing.ContextWithRemote(ctx, traceparent)
+		}
+	}
+	if fallback != nil {
+		return fallback(ctx)
+	}
+	return ctx
+}
+
+// recordJobTrace stores the current span as the trace for a job and its
+// sandbox. Failures are ignored: a missing traceparent only means later
+// spans start new traces.
+func recordJobTrace(ctx context.Context, store *db.Store, jobID string, vmid int) {
+	traceparent := tracing.Traceparent(ctx)
+	if traceparent == "" || store == nil {
+		return
+	}
+	if jobID != "" {
+		_ = store.SetJobTraceparent(ctx, jobID, traceparent)
+	}
+	if vmid > 0 {
+		_ = store.SetSandboxTraceparent(ctx, vmid, traceparent)
+	}
+}
+
+// traceIDFromParent returns the trace ID in a stored traceparent, or "".
+func traceIDFromParent(traceparent string) string {
+	sc, ok := tracing.ParseTraceparent(traceparent)
+	if !ok {
+		return ""
+	}
+	return sc.TraceID.String()
+}
+
+func endSpan(span *tracing.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
+
+// startServerSpan starts a server span for r under parent and returns the
+// request to serve and a writer that records the response status on the
+// span. Call finish when the handler returns.
+func startServerSpan(w http.ResponseWriter, r *http.Request, parent context.Context, name string, attrs ...tracing.Attribute) (http.ResponseWriter, *http.Request, func()) {
+	if !tracing.Enabled() {
+		return w, r, func() {}
+	}
+	attrs = append([]tracing.Attribute{
+		tracing.String("http.request.method", r.Method),
+		tracing.String("url.path", r.URL.Path),
+	}, attrs...)
+	ctx, span := tracing.Start(parent, name, tracing.WithKind(tracing.SpanKindServer), tracing.WithAttributes(attrs...))
+	tw := &tracedResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	finish := func() {
+		span.SetAttributes(tracing.Int("http.response.status_code", tw.status))
+		if tw.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("%s returned %d", name, tw.status))
+		}
+		span.End()
+	}
+	return tw, r.WithContext(ctx), finish
+}
+
+// tracedResponseWriter remembers the status code written. It keeps
+// http.Flusher so streaming proxies still flush.
+type tracedResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (tw *tracedResponseWriter) WriteHeader(status int) {
+	if !tw.wroteHeader {
+		tw.status = status
+		tw.wroteHeader = true
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *tracedResponseWriter) Write(b []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *tracedResponseWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (tw *tracedResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
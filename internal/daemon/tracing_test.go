@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+	"github.com/agentlab/agentlab/internal/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+type collectedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+}
+
+// spanCollector is a local OTLP/HTTP receiver.
+type spanCollector struct {
+	mu    sync.Mutex
+	spans []collectedSpan
+}
+
+func (c *spanCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []collectedSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *spanCollector) byName() map[string]collectedSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]collectedSpan, len(c.spans))
+	for _, span := range c.spans {
+		out[span.Name] = span
+	}
+	return out
+}
+
+func startTestTracer(t *testing.T) (*tracing.Tracer, *spanCollector) {
+	t.Helper()
+	collector := &spanCollector{}
+	srv := httptest.NewServer(collector)
+	t.Cleanup(srv.Close)
+	tracer, err := tracing.New(tracing.Config{Endpoint: srv.URL, FlushInterval: time.Hour, Logger: log.New(io.Discard, "", 0)})
+	require.NoError(t, err)
+	tracing.SetTracer(tracer)
+	t.Cleanup(func() {
+		tracing.SetTracer(nil)
+		_ = tracer.Shutdown(context.Background())
+	})
+	return tracer, collector
+}
+
+func TestJobTraceSpansProvisioningAndRunnerReport(t *testing.T) {
+	ctx := context.Background()
+	tracer, collector := startTestTracer(t)
+
+	store := newTestStore(t)
+	backend := proxmox.WithTracing(&orchestratorBackend{})
+	manager := NewSandboxManager(store, backend, log.New(io.Discard, "", 0))
+	profiles := map[string]models.Profile{"yolo": {Name: "yolo", TemplateVM: 9000}}
+	snippetStore := proxmox.SnippetStore{Storage: "local", Dir: t.TempDir()}
+	orchestrator := NewJobOrchestrator(store, profiles, backend, manager, nil, snippetStore, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIBtestkey agent@test", "http://10.77.0.1:8844", log.New(io.Discard, "", 0), nil, nil)
+	orchestrator.rand = bytes.NewReader(bytes.Repeat([]byte{0x01}, 64))
+
+	now := time.Now().UTC()
+	job := models.Job{ID: "job_traced", RepoURL: "https://example.com/repo.git", Ref: "main", Profile: "yolo", Task: "run tests", Status: models.JobQueued, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, store.CreateJob(ctx, job))
+	require.NoError(t, orchestrator.Run(ctx, job.ID))
+	job, err := store.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.NotNil(t, job.SandboxVMID)
+
+	body, err := json.Marshal(V1RunnerReportRequest{JobID: job.ID, VMID: *job.SandboxVMID, Status: string(models.JobCompleted), Phase: "finish"})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	NewRunnerAPI(orchestrator, nil).handleRunnerReport(rec, httptest.NewRequest(http.MethodPost, "/v1/runner/report", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	require.NoError(t, tracer.ForceFlush(ctx))
+	spans := collector.byName()
+	run, ok := spans["job.run"]
+	require.True(t, ok, "job.run span not exported")
+	for _, name := range []string{"proxmox.Clone", "proxmox.Configure", "proxmox.Start", "cloudinit.snippet.write", "sandbox.ip_discovery", "runner.report"} {
+		span, ok := spans[name]
+		require.True(t, ok, "%s span not exported", name)
+		require.Equal(t, run.TraceID, span.TraceID, name)
+	}
+	require.Equal(t, run.SpanID, spans["runner.report"].ParentSpanID)
+	require.Equal(t, spans["sandbox.ip_discovery"].SpanID, spans["proxmox.GuestIP"].ParentSpanID)
+
+	events, err := store.ListEventsByJobAll(ctx, job.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	for _, ev := range events {
+		require.Equal(t, run.TraceID, ev.TraceID, "event %s", ev.Kind)
+		require.NotEmpty(t, ev.SpanID, "event %s", ev.Kind)
+	}
+
+	require.Equal(t, run.TraceID, doctorTraceID(ctx, store, doctorRelated{JobID: &job.ID}))
+	require.Equal(t, run.TraceID, doctorTraceID(ctx, store, doctorRelated{SandboxVMID: job.SandboxVMID}))
+}
+
+func TestRequestTraceContextPrefersIncomingHeader(t *testing.T) {
+	const incoming = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	fallback := func(ctx context.Context) context.Context {
+		return tracing.ContextWithRemote(ctx, "00-11111111111111111111111111111111-2222222222222222-01")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy/github/repos", nil)
+	req.Header.Set("traceparent", incoming)
+	require.Equal(t, incoming, tracing.Traceparent(requestTraceContext(req, fallback)))
+
+	req.Header.Set("traceparent", "garbage")
+	require.Equal(t, "00-11111111111111111111111111111111-2222222222222222-01", tracing.Traceparent(requestTraceContext(req, fallback)))
+}
@@ -0,0 +1,316 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/db"
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+)
+
+// usageResolution is one downsampled series kept for every sandbox.
+type usageResolution struct {
+	Step time.Duration
+	Keep time.Duration
+}
+
+// usageResolutions are the stored series, finest first. Every sample is
+// folded into all of them; older buckets are pruned per resolution.
+var usageResolutions = []usageResolution{
+	{Step: time.Minute, Keep: 24 * time.Hour},
+	{Step: 10 * time.Minute, Keep: 7 * 24 * time.Hour},
+	{Step: time.Hour, Keep: 90 * 24 * time.Hour},
+}
+
+const (
+	defaultUsageRange  = time.Hour
+	usagePruneInterval = time.Hour
+)
+
+// usagePoint is a sandbox's resource usage at one instant or averaged over
+// one bucket. Rates are bytes per second.
+type usagePoint struct {
+	At               time.Time
+	CPU              float64
+	MemoryBytes      int64
+	MemoryLimitBytes int64
+	DiskReadBPS      float64
+	DiskWriteBPS     float64
+	NetInBPS         float64
+	NetOutBPS        float64
+}
+
+// usageReading is the last raw stats seen for a sandbox, kept to turn the
+// backends' cumulative counters into deltas.
+type usageReading struct {
+	at    time.Time
+	stats proxmox.VMStats
+}
+
+// UsageCollector samples resource usage for running sandboxes, stores it
+// downsampled, and publishes it as Prometheus gauges.
+type UsageCollector struct {
+	store     *db.Store
+	backend   proxmox.Backend
+	lxc       proxmox.Backend
+	metrics   *Metrics
+	logger    *log.Logger
+	interval  time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	last      map[int]usageReading
+	lastPrune time.Time
+}
+
+// NewUsageCollector constructs a collector. An interval of zero disables it.
+func NewUsageCollector(store *db.Store, backend proxmox.Backend, metrics *Metrics, logger *log.Logger, interval time.Duration) *UsageCollector {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &UsageCollector{
+		store:    store,
+		backend:  backend,
+		metrics:  metrics,
+		logger:   logger,
+		interval: interval,
+		now:      time.Now,
+		last:     make(map[int]usageReading),
+	}
+}
+
+// WithLXCBackend samples LXC sandboxes through backend instead of the
+// primary backend.
+func (c *UsageCollector) WithLXCBackend(backend proxmox.Backend) *UsageCollector {
+	if c == nil {
+		return c
+	}
+	c.lxc = backend
+	return c
+}
+
+// Start samples on the configured interval until ctx is canceled.
+func (c *UsageCollector) Start(ctx context.Context) {
+	if c == nil || c.interval <= 0 || c.store == nil || c.backend == nil {
+		return
+	}
+	ticker := time.NewTicker(c.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Sample(ctx)
+			}
+		}
+	}()
+}
+
+// Sample records one reading for every running sandbox. A sandbox's first
+// reading after it starts (or after a daemon restart) stores no disk or
+// network traffic, because there is no earlier counter to diff against.
+func (c *UsageCollector) Sample(ctx context.Context) {
+	if c == nil || c.store == nil || c.backend == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sandboxes, err := c.store.ListSandboxes(ctx)
+	if err != nil {
+		c.logger.Printf("usage: list sandboxes error: %v", err)
+		return
+	}
+	now := c.now().UTC()
+	running := make(map[int]models.Sandbox)
+	for _, sb := range sandboxes {
+		if sb.State == models.SandboxRunning {
+			running[sb.VMID] = sb
+		}
+	}
+	for vmid := range c.last {
+		if _, ok := running[vmid]; !ok {
+			delete(c.last, vmid)
+			c.metrics.DeleteSandboxUsage(vmid)
+		}
+	}
+	steps := make([]time.Duration, 0, len(usageResolutions))
+	for _, res := range usageResolutions {
+		steps = append(steps, res.Step)
+	}
+	for _, sb := range running {
+		c.sampleSandbox(ctx, now, sb, steps)
+	}
+	if now.Sub(c.lastPrune) >= usagePruneInterval {
+		c.prune(ctx, now)
+		c.lastPrune = now
+	}
+}
+
+func (c *UsageCollector) sampleSandbox(ctx context.Context, now time.Time, sb models.Sandbox, steps []time.Duration) {
+	backend := c.backend
+	if sb.Type == models.SandboxTypeLXC && c.lxc != nil {
+		backend = c.lxc
+	}
+	stats, err := backend.CurrentStats(ctx, proxmox.VMID(sb.VMID))
+	if err != nil {
+		c.logger.Printf("usage: vmid=%d stats error: %v", sb.VMID, err)
+		return
+	}
+	sample := db.UsageSample{
+		VMID:             sb.VMID,
+		At:               now,
+		CPUUsage:         stats.CPUUsage,
+		CPUs:             stats.CPUs,
+		MemoryBytes:      clampInt64(stats.MemoryBytes),
+		MemoryLimitBytes: clampInt64(stats.MemoryMaxBytes),
+	}
+	point := usagePoint{
+		At:               now,
+		CPU:              stats.CPUUsage,
+		MemoryBytes:      sample.MemoryBytes,
+		MemoryLimitBytes: sample.MemoryLimitBytes,
+	}
+	if prev, ok := c.last[sb.VMID]; ok {
+		sample.DiskReadBytes = counterDelta(stats.DiskReadBytes, prev.stats.DiskReadBytes)
+		sample.DiskWriteBytes = counterDelta(stats.DiskWriteBytes, prev.stats.DiskWriteBytes)
+		sample.NetInBytes = counterDelta(stats.NetInBytes, prev.stats.NetInBytes)
+		sample.NetOutBytes = counterDelta(stats.NetOutBytes, prev.stats.NetOutBytes)
+		if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+			point.DiskReadBPS = float64(sample.DiskReadBytes) / elapsed
+			point.DiskWriteBPS = float64(sample.DiskWriteBytes) / elapsed
+			point.NetInBPS = float64(sample.NetInBytes) / elapsed
+			point.NetOutBPS = float64(sample.NetOutBytes) / elapsed
+		}
+	}
+	c.last[sb.VMID] = usageReading{at: now, stats: stats}
+	if err := c.store.RecordSandboxUsage(ctx, sample, steps); err != nil {
+		c.logger.Printf("usage: vmid=%d record error: %v", sb.VMID, err)
+	}
+	c.metrics.SetSandboxUsage(sb.VMID, sb.Profile, sb.Owner, point)
+}
+
+func (c *UsageCollector) prune(ctx context.Context, now time.Time) {
+	for _, res := range usageResolutions {
+		if _, err := c.store.PruneSandboxUsage(ctx, res.Step, now.Add(-res.Keep)); err != nil {
+			c.logger.Printf("usage: prune %s buckets error: %v", res.Step, err)
+		}
+	}
+}
+
+// counterDelta returns the growth of a cumulative counter. A counter that
+// went backwards was reset (the guest restarted), so its current value is
+// the growth since the reset.
+func counterDelta(current, previous uint64) int64 {
+	if current < previous {
+		return clampInt64(current)
+	}
+	return clampInt64(current - previous)
+}
+
+func clampInt64(v uint64) int64 {
+	if v > 1<<63-1 {
+		return 1<<63 - 1
+	}
+	return int64(v)
+}
+
+// usageResolutionFor picks the finest stored resolution that still covers
+// window without returning more than a few thousand points.
+func usageResolutionFor(window time.Duration) time.Duration {
+	switch {
+	case window <= 6*time.Hour:
+		return time.Minute
+	case window <= 7*24*time.Hour:
+		return 10 * time.Minute
+	default:
+		return time.Hour
+	}
+}
+
+// parseUsageRange parses the range query parameter. It accepts Go durations
+// and a "d" suffix for days, and caps the window at the longest retention.
+func parseUsageRange(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return defaultUsageRange, nil
+	}
+	var window time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", raw)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", raw)
+		}
+		window = parsed
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("range must be positive")
+	}
+	if longest := usageResolutions[len(usageResolutions)-1].Keep; window > longest {
+		window = longest
+	}
+	return window, nil
+}
+
+// handleSandboxUsage serves GET /v1/sandboxes/{vmid}/usage.
+func (api *ControlAPI) handleSandboxUsage(w http.ResponseWriter, r *http.Request, vmid int) {
+	if api.store == nil {
+		writeError(w, http.StatusInternalServerError, "usage store unavailable")
+		return
+	}
+	window, err := parseUsageRange(r.URL.Query().Get("range"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := api.store.GetSandbox(r.Context(), vmid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "sandbox not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load sandbox")
+		return
+	}
+	resolution := usageResolutionFor(window)
+	buckets, err := api.store.ListSandboxUsage(r.Context(), vmid, resolution, time.Now().UTC().Add(-window))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load usage")
+		return
+	}
+	resp := V1SandboxUsageResponse{
+		VMID:              vmid,
+		Range:             window.String(),
+		ResolutionSeconds: int(resolution / time.Second),
+		Points:            make([]V1SandboxUsagePoint, 0, len(buckets)),
+	}
+	step := resolution.Seconds()
+	for _, bucket := range buckets {
+		resp.Points = append(resp.Points, V1SandboxUsagePoint{
+			At:               bucket.Start.UTC().Format(time.RFC3339),
+			CPUAvg:           bucket.CPUAvg,
+			CPUMax:           bucket.CPUMax,
+			MemoryUsedBytes:  bucket.MemoryAvgBytes,
+			MemoryLimitBytes: bucket.MemoryLimitBytes,
+			DiskReadBPS:      float64(bucket.DiskReadBytes) / step,
+			DiskWriteBPS:     float64(bucket.DiskWriteBytes) / step,
+			NetInBPS:         float64(bucket.NetInBytes) / step,
+			NetOutBPS:        float64(bucket.NetOutBytes) / step,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/proxmox"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usageStatsBackend serves whatever stats the test last set.
+type usageStatsBackend struct {
+	stubBackend
+	stats proxmox.VMStats
+}
+
+func (b *usageStatsBackend) CurrentStats(context.Context, proxmox.VMID) (proxmox.VMStats, error) {
+	return b.stats, nil
+}
+
+func TestUsageCollectorRecordsDeltasAndGauges(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	backend := &usageStatsBackend{}
+	metrics := NewMetrics()
+	base := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Minute)
+	sandbox := models.Sandbox{VMID: 101, Name: "busy", Profile: "default", Owner: "alice", State: models.SandboxRunning, CreatedAt: base, LastUpdatedAt: base}
+	require.NoError(t, store.CreateSandbox(ctx, sandbox))
+
+	collector := NewUsageCollector(store, backend, metrics, log.New(io.Discard, "", 0), time.Minute)
+	now := base
+	collector.now = func() time.Time { return now }
+
+	backend.stats = proxmox.VMStats{CPUUsage: 0.5, CPUs: 2, MemoryBytes: 512 << 20, MemoryMaxBytes: 1 << 30, DiskReadBytes: 1000, NetInBytes: 50}
+	collector.Sample(ctx)
+	now = base.Add(time.Minute)
+	backend.stats = proxmox.VMStats{CPUUsage: 0.25, CPUs: 2, MemoryBytes: 256 << 20, MemoryMaxBytes: 1 << 30, DiskReadBytes: 7000, NetInBytes: 6050}
+	collector.Sample(ctx)
+
+	buckets, err := store.ListSandboxUsage(ctx, sandbox.VMID, time.Minute, base)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, int64(0), buckets[0].DiskReadBytes, "first sample has no earlier counter")
+	assert.Equal(t, int64(6000), buckets[1].DiskReadBytes)
+	assert.Equal(t, int64(6000), buckets[1].NetInBytes)
+
+	assert.InDelta(t, 0.25, promtestutil.ToFloat64(metrics.sandboxUsage[usageCPU].WithLabelValues("101", "default", "alice")), 1e-9)
+	assert.InDelta(t, 100, promtestutil.ToFloat64(metrics.sandboxUsage[usageDiskRead].WithLabelValues("101", "default", "alice")), 1e-9)
+	assert.InDelta(t, float64(256<<20), promtestutil.ToFloat64(metrics.sandboxUsage[usageMemory].WithLabelValues("101", "default", "alice")), 1e-9)
+
+	rec := httptest.NewRecorder()
+	api := NewControlAPI(store, map[string]models.Profile{}, nil, nil, nil, "", log.New(io.Discard, "", 0))
+	api.handleSandboxUsage(rec, httptest.NewRequest(http.MethodGet, "/v1/sandboxes/101/usage?range=30m", nil), sandbox.VMID)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp V1SandboxUsageResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 60, resp.ResolutionSeconds)
+	assert.Equal(t, "30m0s", resp.Range)
+	require.Len(t, resp.Points, 2)
+	assert.InDelta(t, 100, resp.Points[1].DiskReadBPS, 1e-9)
+	assert.Equal(t, int64(1<<30), resp.Points[1].MemoryLimitBytes)
+
+	updated, err := store.UpdateSandboxState(ctx, sandbox.VMID, models.SandboxRunning, models.SandboxStopped)
+	require.NoError(t, err)
+	require.True(t, updated)
+	now = base.Add(2 * time.Minute)
+	collector.Sample(ctx)
+	assert.Equal(t, 0, promtestutil.CollectAndCount(metrics.sandboxUsage[usageCPU]))
+}
+
+func TestSandboxUsageRange(t *testing.T) {
+	window, err := parseUsageRange("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, window)
+	assert.Equal(t, time.Minute, usageResolutionFor(window))
+
+	window, err = parseUsageRange("7d")
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, window)
+	assert.Equal(t, 10*time.Minute, usageResolutionFor(window))
+
+	window, err = parseUsageRange("365d")
+	require.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, window)
+	assert.Equal(t, time.Hour, usageResolutionFor(window))
+
+	for _, raw := range []string{"soon", "-1h", "0s", "xd"} {
+		_, err := parseUsageRange(raw)
+		assert.Error(t, err, raw)
+	}
+
+	assert.Equal(t, int64(5), counterDelta(15, 10))
+	assert.Equal(t, int64(3), counterDelta(3, 10), "reset counters count from zero")
+}
@@ -1,21 +1,24 @@
 package daemon
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/agentlab/agentlab/internal/user"
 )
 
 // UserAPI provides HTTP endpoints for user and team management.
 //
-// The registry maps SSH identities to roles. It is not wired into
-// authentication yet, but it holds the keys that will be, so every handler is
-// gated now: reads need user.read, mutations need user.write, and any
+// The registry maps SSH identities to roles, and RBAC reads it to confine
+// registered users to their custom roles, so every handler is gated: reads need user.read, mutations need user.write, and any
 // sandbox-scoped token is refused outright because the registry is a global
 // resource with no per-scope view (review F13).
 type UserAPI struct {
-	registry *user.Registry
+	registry   *user.Registry
+	oidcIssuer string
 }
 
 // NewUserAPI creates a new user API handler.
@@ -23,6 +26,12 @@ func NewUserAPI(registry *user.Registry) *UserAPI {
 	return &UserAPI{registry: registry}
 }
 
+// WithOIDCIssuer sets the issuer that identity links default to.
+func (api *UserAPI) WithOIDCIssuer(issuer string) *UserAPI {
+	api.oidcIssuer = strings.TrimRight(strings.TrimSpace(issuer), "/")
+	return api
+}
+
 // Register registers user and team API endpoints on the given mux.
 func (api *UserAPI) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/users", api.handleUsers)
@@ -70,6 +79,10 @@ func (api *UserAPI) handleUserResource(w http.ResponseWriter, r *http.Request) {
 		api.handleUserKeys(w, r, name)
 		return
 	}
+	if len(parts) >= 2 && parts[1] == "identities" {
+		api.handleUserIdentities(w, r, name)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -158,12 +171,18 @@ func (api *UserAPI) showUser(w http.ResponseWriter, r *http.Request, name string
 		writeError(w, http.StatusNotFound, "user not found")
 		return
 	}
+	identities, err := api.registry.ListIdentities(r.Context(), u.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"id":          u.ID,
 		"name":        u.Name,
 		"role":        string(u.Role),
 		"fingerprint": u.Fingerprint,
 		"created_at":  u.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		"identities":  identitiesToMaps(identities),
 	})
 }
 
@@ -248,6 +267,124 @@ func (api *UserAPI) removeUserKey(w http.ResponseWriter, r *http.Request, userNa
 	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
 }
 
+func (api *UserAPI) handleUserIdentities(w http.ResponseWriter, r *http.Request, userName string) {
+	switch r.Method {
+	case http.MethodGet:
+		api.listUserIdentities(w, r, userName)
+	case http.MethodPost:
+		api.linkUserIdentity(w, r, userName)
+	case http.MethodDelete:
+		api.unlinkUserIdentity(w, r, userName)
+	default:
+		writeMethodNotAllowed(w, []string{"GET", "POST", "DELETE"})
+	}
+}
+
+func (api *UserAPI) listUserIdentities(w http.ResponseWriter, r *http.Request, userName string) {
+	if !api.authorizeRead(w, r) {
+		return
+	}
+	u, err := api.registry.Store().GetUserByName(r.Context(), userName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	identities, err := api.registry.ListIdentities(r.Context(), u.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"identities": identitiesToMaps(identities)})
+}
+
+// linkUserIdentity links a single sign-on identity to an existing user, which
+// single sign-on refuses to do by name alone. The issuer defaults to the
+// daemon's configured provider.
+func (api *UserAPI) linkUserIdentity(w http.ResponseWriter, r *http.Request, userName string) {
+	if !api.authorizeWrite(w, r) {
+		return
+	}
+	var req struct {
+		Issuer  string `json:"issuer"`
+		Subject string `json:"subject"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeJSONDecodeError(w, err)
+		return
+	}
+	issuer := api.identityIssuer(req.Issuer)
+	subject := strings.TrimSpace(req.Subject)
+	if issuer == "" || subject == "" {
+		writeError(w, http.StatusBadRequest, "issuer and subject are required")
+		return
+	}
+	u, err := api.registry.Store().GetUserByName(r.Context(), userName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	id, err := api.registry.LinkIdentity(r.Context(), u.ID, issuer, subject, callerUserID(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, identityToMap(id))
+}
+
+func (api *UserAPI) unlinkUserIdentity(w http.ResponseWriter, r *http.Request, userName string) {
+	if !api.authorizeWrite(w, r) {
+		return
+	}
+	issuer := api.identityIssuer(r.URL.Query().Get("issuer"))
+	subject := strings.TrimSpace(r.URL.Query().Get("subject"))
+	if issuer == "" || subject == "" {
+		writeError(w, http.StatusBadRequest, "issuer and subject are required")
+		return
+	}
+	u, err := api.registry.Store().GetUserByName(r.Context(), userName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err := api.registry.UnlinkIdentity(r.Context(), u.ID, issuer, subject, callerUserID(r.Context())); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "identity not linked to user")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+func (api *UserAPI) identityIssuer(issuer string) string {
+	issuer = strings.TrimRight(strings.TrimSpace(issuer), "/")
+	if issuer == "" {
+		return api.oidcIssuer
+	}
+	return issuer
+}
+
+func identityToMap(id user.Identity) map[string]any {
+	out := map[string]any{
+		"issuer":     id.Issuer,
+		"subject":    id.Subject,
+		"created_at": id.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if !id.LastLoginAt.IsZero() {
+		out["last_login_at"] = id.LastLoginAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func identitiesToMaps(identities []user.Identity) []map[string]any {
+	out := make([]map[string]any, 0, len(identities))
+	for _, id := range identities {
+		out = append(out, identityToMap(id))
+	}
+	return out
+}
+
 // --- Team handlers ---
 
 func (api *UserAPI) handleTeams(w http.ResponseWriter, r *http.Request) {
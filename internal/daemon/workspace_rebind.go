@@ -52,6 +52,9 @@ func (o *JobOrchestrator) RebindWorkspace(ctx context.Context, workspaceID, prof
 	if err := validateProfileForProvisioning(profile); err != nil {
 		return result, err
 	}
+	if profile, err = resolveProfileTemplate(ctx, o.store, profile, workspaceID); err != nil {
+		return result, err
+	}
 
 	workspace, err := o.workspaceMgr.Resolve(ctx, workspaceID)
 	if err != nil {
@@ -112,11 +115,13 @@ func (o *JobOrchestrator) RebindWorkspace(ctx context.Context, workspaceID, prof
 		State:         models.SandboxRequested,
 		Keepalive:     keepalive,
 		LeaseExpires:  leaseExpires,
+		Owner:         workspace.Owner,
+		Team:          workspace.Team,
 		CreatedAt:     now,
 		LastUpdatedAt: now,
 	}
 
-	created, err := createSandboxWithRetry(ctx, o.store, sandbox, o.resourcePool, o.profiles)
+	created, err := createSandboxWithRetry(ctx, o.store, sandbox, o.resourcePool, o.profiles.Snapshot())
 	if err != nil {
 		if released, _ := o.store.ReleaseWorkspaceLease(ctx, workspace.ID, leaseOwner, leaseNonce); released {
 			vmid := newVMID
@@ -160,6 +165,9 @@ func (o *JobOrchestrator) RebindWorkspace(ctx context.Context, workspaceID, prof
 		}
 	}()
 
+	if err = o.store.UpdateSandboxTemplate(ctx, created.VMID, profile.TemplateVM); err != nil {
+		return result, err
+	}
 	if err = o.sandboxManager.Transition(ctx, created.VMID, models.SandboxProvisioning); err != nil {
 		return result, err
 	}
@@ -175,7 +183,7 @@ func (o *JobOrchestrator) RebindWorkspace(ctx context.Context, workspaceID, prof
 		return result, err
 	}
 
-	snippet, err = o.snippetStore.Create(proxmox.SnippetInput{
+	snippet, err = o.writeSnippet(ctx, proxmox.SnippetInput{
 		VMID:           proxmox.VMID(created.VMID),
 		Hostname:       created.Name,
 		SSHPublicKey:   o.sshPublicKey,
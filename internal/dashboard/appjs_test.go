@@ -90,6 +90,175 @@ func TestExposuresTableBuiltWithDOMAPIs(t *testing.T) {
 	}
 }
 
+// TestJobDiffBuiltWithDOMAPIs guards the job detail diff viewer: patch lines
+// and file names come from the agent, so each must be set through textContent
+// rather than assembled into HTML.
+func TestJobDiffBuiltWithDOMAPIs(t *testing.T) {
+	src := appJSSource(t)
+	body := extractJSFunction(t, src, "renderJobDiff")
+
+	if !strings.Contains(body, "document.createElement") || !strings.Contains(body, "textContent") {
+		t.Error("renderJobDiff does not build the diff with createElement/textContent")
+	}
+	if strings.Contains(body, "esc(") {
+		t.Error("renderJobDiff interpolates values through esc(); untrusted values must go through textContent")
+	}
+	clearRe := regexp.MustCompile(`\.innerHTML\s*=\s*("[^"]*"|'[^']*'|[^;]+)`)
+	for _, m := range clearRe.FindAllStringSubmatch(body, -1) {
+		if m[1] != `""` && m[1] != `''` {
+			t.Errorf("renderJobDiff assigns non-empty innerHTML %s", m[1])
+		}
+	}
+	for _, cls := range []string{"diff-add", "diff-del", "diff-hunk", "diff-meta"} {
+		if !strings.Contains(body, `"`+cls+`"`) {
+			t.Errorf("renderJobDiff does not mark lines with %s", cls)
+		}
+	}
+	if !strings.Contains(extractJSFunction(t, src, "showJobDetail"), "/diff") {
+		t.Error("showJobDetail does not load the job diff")
+	}
+}
+
+// TestSecretRequestsBuiltWithDOMAPIs guards the pending secret requests
+// panel: secret names and request ids come from the bundle and the database,
+// and the Approve/Deny buttons must carry the id through a closure.
+func TestSecretRequestsBuiltWithDOMAPIs(t *testing.T) {
+	src := appJSSource(t)
+	body := extractJSFunction(t, src, "loadSecretRequests")
+
+	if !strings.Contains(body, "document.createElement") || !strings.Contains(body, "appendTd(") {
+		t.Error("loadSecretRequests does not build rows with DOM APIs")
+	}
+	if strings.Contains(body, "esc(") {
+		t.Error("loadSecretRequests interpolates values through esc(); untrusted values must go through textContent")
+	}
+	for _, want := range []string{`decideSecretRequest(id, "approve")`, `decideSecretRequest(id, "deny")`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("loadSecretRequests does not call %s", want)
+		}
+	}
+	if !strings.Contains(extractJSFunction(t, src, "decideSecretRequest"), "encodeURIComponent(id)") {
+		t.Error("decideSecretRequest does not escape the request id in the path")
+	}
+	if !strings.Contains(extractJSFunction(t, src, "refreshAll"), "loadSecretRequests()") {
+		t.Error("refreshAll does not load pending secret requests")
+	}
+}
+
+// TestQuestionInboxBuiltWithDOMAPIs guards the agent question inbox: the
+// question text and its options are written by the guest, so they must go
+// through textContent and reach answerQuestion only through closures.
+func TestQuestionInboxBuiltWithDOMAPIs(t *testing.T) {
+	src := appJSSource(t)
+	body := extractJSFunction(t, src, "loadQuestions")
+
+	if !strings.Contains(body, "document.createElement") || !strings.Contains(body, "appendTd(") {
+		t.Error("loadQuestions does not build rows with DOM APIs")
+	}
+	if strings.Contains(body, "esc(") || strings.Contains(body, "innerHTML = '") {
+		t.Error("loadQuestions interpolates guest text into HTML; it must go through textContent")
+	}
+	for _, want := range []string{"answerQuestion(id, option)", "answerQuestion(id, input.value)"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("loadQuestions does not call %s", want)
+		}
+	}
+	if !strings.Contains(extractJSFunction(t, src, "answerQuestion"), "encodeURIComponent(id)") {
+		t.Error("answerQuestion does not escape the question id in the path")
+	}
+	if !strings.Contains(extractJSFunction(t, src, "refreshAll"), "loadQuestions()") {
+		t.Error("refreshAll does not load open questions")
+	}
+}
+
+// TestWebTerminalRendersAsText guards the web terminal: sandbox output is
+// untrusted, so the screen must be rendered as text nodes, and the token must
+// travel as a WebSocket subprotocol that the server accepts.
+func TestWebTerminalRendersAsText(t *testing.T) {
+	src := appJSSource(t)
+	screen := extractJSFunction(t, src, "createTerminalScreen")
+	if !strings.Contains(screen, "createTextNode") || !strings.Contains(screen, `pre.textContent = ""`) {
+		t.Error("createTerminalScreen does not render output as text nodes")
+	}
+	if strings.Contains(screen, "innerHTML") {
+		t.Error("createTerminalScreen builds HTML from sandbox output")
+	}
+	open := extractJSFunction(t, src, "openTerminal")
+	if !strings.Contains(src, `TERMINAL_PROTOCOL = "`+terminalProtocol+`"`) ||
+		!strings.Contains(open, `[TERMINAL_PROTOCOL, "`+terminalTokenPrefix+`" + tok]`) {
+		t.Error("openTerminal does not offer the terminal subprotocol and token the server expects")
+	}
+	if !strings.Contains(open, "encodeURIComponent(vmid)") {
+		t.Error("openTerminal does not escape the vmid in the path")
+	}
+	if !strings.Contains(extractJSFunction(t, src, "closeModal"), "closeTerminal()") {
+		t.Error("closing the terminal modal does not close the socket")
+	}
+}
+
+// TestSandboxDetailShowsGuestStatus guards the sandbox detail modal: status
+// keys and values are written by the guest, so the summary must be built with
+// textContent.
+func TestSandboxDetailShowsGuestStatus(t *testing.T) {
+	src := appJSSource(t)
+	if !strings.Contains(extractJSFunction(t, src, "showDetail"), "data.kv") {
+		t.Error("showDetail does not render published status values")
+	}
+	body := extractJSFunction(t, src, "renderDetailFields")
+	if !strings.Contains(body, "textContent = f.label") || !strings.Contains(body, "textContent = String(f.value)") {
+		t.Error("renderDetailFields does not set labels and values as text")
+	}
+	if strings.Contains(body, "innerHTML") || strings.Contains(body, "esc(") {
+		t.Error("renderDetailFields builds HTML from untrusted values")
+	}
+}
+
+// TestSessionRecordingsBuiltWithDOMAPIs guards recording playback: user
+// names come from SSH key comments and output from the sandbox, so both must
+// reach the page as text, and closing the modal must stop playback.
+func TestSessionRecordingsBuiltWithDOMAPIs(t *testing.T) {
+	src := appJSSource(t)
+	if !strings.Contains(extractJSFunction(t, src, "showDetail"), "loadRecordings(vmid)") {
+		t.Error("showDetail does not list the sandbox's recordings")
+	}
+	list := extractJSFunction(t, src, "renderRecordings")
+	if !strings.Contains(list, "textContent = rec.user") || !strings.Contains(list, `addActionButton(li, "Play"`) {
+		t.Error("renderRecordings does not set values as text and attach Play through addActionButton")
+	}
+	play := extractJSFunction(t, src, "playRecording")
+	if !strings.Contains(play, "createTerminalScreen(") || strings.Contains(play, "innerHTML") {
+		t.Error("playRecording does not render through the terminal screen")
+	}
+	for _, body := range []string{list, play} {
+		if strings.Contains(body, "innerHTML") || strings.Contains(body, "esc(") {
+			t.Error("recording UI builds HTML from untrusted values")
+		}
+	}
+	if !strings.Contains(extractJSFunction(t, src, "closeTerminal"), "t.stop()") {
+		t.Error("closing the terminal modal does not stop playback")
+	}
+}
+
+func TestUsageSparklinesBuiltWithDOMAPIs(t *testing.T) {
+	src := appJSSource(t)
+	if !strings.Contains(extractJSFunction(t, src, "showDetail"), "loadUsage(vmid)") {
+		t.Error("showDetail does not load the sandbox's usage history")
+	}
+	if !strings.Contains(extractJSFunction(t, src, "loadUsage"), "/usage?range=") {
+		t.Error("loadUsage does not request a range of usage history")
+	}
+	render := extractJSFunction(t, src, "renderUsage")
+	line := extractJSFunction(t, src, "sparkline")
+	if !strings.Contains(line, `createElementNS(SVG_NS, "polyline")`) || strings.Contains(line, ".style") {
+		t.Error("sparkline does not draw a styled-by-class SVG polyline")
+	}
+	for _, body := range []string{render, line} {
+		if strings.Contains(body, "innerHTML") || strings.Contains(body, "esc(") {
+			t.Error("usage UI builds HTML from data")
+		}
+	}
+}
+
 // TestAppJSNoEscInAttributeOrHandlerContexts covers T08: no esc() result may
 // be interpolated into an HTML attribute value or an event-handler string.
 // esc() encodes quotes, but the only contexts proven safe for it are HTML text
@@ -24,6 +24,11 @@
 //     a same-origin Origin (or Referer) header and the custom X-Requested-With
 //     header, which a plain cross-site form submission cannot set.
 //
+// The web terminal WebSocket is a GET, but it opens a shell, so its handshake
+// must also carry a same-origin Origin (cross-site WebSocket hijacking).
+// Browsers cannot set headers on a WebSocket handshake, so it may present the
+// browser token as a "bearer.<token>" subprotocol instead.
+//
 // Every response also carries a Content-Security-Policy that forbids inline
 // script and inline event handlers (finding F3): the UI is built from
 // same-origin assets only, with listeners attached via addEventListener.
@@ -50,6 +55,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/agentlab/agentlab/internal/sandboxssh"
 )
 
 //go:embed all:static
@@ -65,6 +72,21 @@ type Server struct {
 	// than supplied by the operator, so startup logging can tell the user.
 	generatedToken bool
 	logger         *log.Logger
+
+	// Web terminal. terminal is nil until ListenAndServe loads the sandbox
+	// key, and stays nil when no key is configured.
+	sandboxKeyPath string
+	sandboxUser    string
+	sandboxPort    int
+	terminal       terminalBackend
+	terminalIdle   time.Duration
+	record         bool
+	recordSpool    string
+
+	// Single sign-on. sso is nil unless --oidc-redirect-url is set.
+	oidcRedirectURL  string
+	oidcClientSecret string
+	sso              *sso
 }
 
 // Config holds dashboard server configuration.
@@ -84,6 +106,38 @@ type Config struct {
 	// generates a random token and logs it, so loopback deployments work
 	// without pre-configuring one (finding F9).
 	BrowserToken string
+
+	// SandboxKeyPath is the SSH private key the web terminal uses to reach
+	// sandboxes, the same key agentlab-ssh-gateway uses. The terminal is
+	// disabled when it is empty.
+	SandboxKeyPath string
+
+	// SandboxUser and SandboxPort select the sandbox SSH account (default
+	// "agent" on port 22).
+	SandboxUser string
+	SandboxPort int
+
+	// TerminalIdleTimeout closes a web terminal with no input or output for
+	// this long (default 15m).
+	TerminalIdleTimeout time.Duration
+
+	// Record records every web terminal session as an asciicast v2 file,
+	// input included, and uploads it to the daemon as a recording artifact of
+	// the sandbox when the session ends.
+	Record bool
+
+	// RecordSpool holds recordings in progress and failed uploads (default:
+	// the system temp dir).
+	RecordSpool string
+
+	// OIDCRedirectURL enables single sign-on through agentlabd's identity
+	// provider. It is the dashboard's external URL ending in /auth/callback,
+	// registered with the provider as a redirect URI.
+	OIDCRedirectURL string
+
+	// OIDCClientSecret is the client secret for confidential clients. Public
+	// clients leave it empty and rely on PKCE alone.
+	OIDCClientSecret string
 }
 
 // NewServer creates a new dashboard server.
@@ -91,13 +145,53 @@ func NewServer(cfg Config, logger *log.Logger) *Server {
 	if logger == nil {
 		logger = log.Default()
 	}
+	idle := cfg.TerminalIdleTimeout
+	if idle <= 0 {
+		idle = defaultTerminalIdleTimeout
+	}
 	return &Server{
-		listen:       cfg.Listen,
-		socketPath:   cfg.SocketPath,
-		token:        cfg.Token,
-		browserToken: strings.TrimSpace(cfg.BrowserToken),
-		logger:       logger,
+		listen:         cfg.Listen,
+		socketPath:     cfg.SocketPath,
+		token:          cfg.Token,
+		browserToken:   strings.TrimSpace(cfg.BrowserToken),
+		logger:         logger,
+		sandboxKeyPath: strings.TrimSpace(cfg.SandboxKeyPath),
+		sandboxUser:    strings.TrimSpace(cfg.SandboxUser),
+		sandboxPort:    cfg.SandboxPort,
+		terminalIdle:   idle,
+		record:         cfg.Record,
+		recordSpool:    strings.TrimSpace(cfg.RecordSpool),
+
+		oidcRedirectURL:  strings.TrimSpace(cfg.OIDCRedirectURL),
+		oidcClientSecret: strings.TrimSpace(cfg.OIDCClientSecret),
+	}
+}
+
+// initSSO enables single sign-on when a redirect URL is configured.
+func (s *Server) initSSO() error {
+	if s.oidcRedirectURL == "" {
+		return nil
+	}
+	sso, err := newSSO(s.oidcRedirectURL, s.oidcClientSecret)
+	if err != nil {
+		return err
+	}
+	s.sso = sso
+	return nil
+}
+
+// initTerminal loads the sandbox key for the web terminal. Without a key the
+// terminal stays disabled and its endpoint answers 503.
+func (s *Server) initTerminal() error {
+	if s.sandboxKeyPath == "" {
+		return nil
 	}
+	signer, err := sandboxssh.LoadSigner(s.sandboxKeyPath)
+	if err != nil {
+		return fmt.Errorf("dashboard: load sandbox key: %w", err)
+	}
+	s.terminal = newSSHTerminal(signer, s.sandboxUser, s.sandboxPort, s.logger)
+	return nil
 }
 
 // ensureBrowserToken supplies the inbound token that every bind requires
@@ -137,40 +231,21 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 	if err := s.validateConfig(); err != nil {
 		return err
 	}
-
-	mux := http.NewServeMux()
-
-	// Serve embedded static files.
-	mux.HandleFunc("/", s.handleStatic)
-
-	// API proxy endpoints — forward to daemon.
-	mux.HandleFunc("/api/v1/status", s.proxyGet)
-	mux.HandleFunc("/api/v1/sandboxes/inventory", s.proxyGet)
-	mux.HandleFunc("/api/v1/sandboxes/reconcile", s.proxyPost)
-	mux.HandleFunc("/api/v1/sandboxes/validate-plan", s.proxyPost)
-	mux.HandleFunc("/api/v1/sandboxes/stop_all", s.proxyPost)
-	mux.HandleFunc("/api/v1/sandboxes/prune", s.proxyPost)
-	mux.HandleFunc("/api/v1/sandboxes", s.proxySandboxes)
-	mux.HandleFunc("/api/v1/sandboxes/", s.proxySandboxAction)
-	mux.HandleFunc("/api/v1/jobs/validate-plan", s.proxyPost)
-	mux.HandleFunc("/api/v1/jobs", s.proxyJobs)
-	mux.HandleFunc("/api/v1/jobs/", s.proxyGet)
-	mux.HandleFunc("/api/v1/workspaces", s.proxyGet)
-	mux.HandleFunc("/api/v1/workspaces/", s.proxyWorkspaceAction)
-	mux.HandleFunc("/api/v1/profiles", s.proxyGet)
-	mux.HandleFunc("/api/v1/sessions", s.proxyGet)
-	mux.HandleFunc("/api/v1/sessions/", s.proxySessionAction)
-	mux.HandleFunc("/api/v1/exposures", s.proxyExposures)
-	mux.HandleFunc("/api/v1/exposures/", s.proxyDelete)
-	mux.HandleFunc("/api/v1/messages", s.proxyMessages)
-	mux.HandleFunc("/api/v1/host", s.proxyGet)
-	mux.HandleFunc("/api/v1/pool/status", s.proxyGet)
+	if err := s.initTerminal(); err != nil {
+		return err
+	}
+	if err := s.initSSO(); err != nil {
+		return err
+	}
 
 	srv := &http.Server{
-		Handler:           s.securityHeaders(s.inboundMiddleware(mux)),
+		Handler:           s.securityHeaders(s.inboundMiddleware(s.routes())),
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       60 * time.Second,
 		IdleTimeout:       2 * time.Minute,
+		// Web terminals hijack their connection, so Shutdown does not wait
+		// for them; they watch this context to close instead.
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	listener, err := net.Listen("tcp", s.listen)
@@ -184,6 +259,14 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 		s.logger.Printf("dashboard: enter this token in the browser prompt to use the dashboard " +
 			"(it gates every /api/* request; restart generates a new one)")
 	}
+	if s.terminal == nil {
+		s.logger.Printf("dashboard: web terminal disabled (no --sandbox-key)")
+	} else if s.record {
+		s.logger.Printf("dashboard: recording web terminal sessions as sandbox artifacts")
+	}
+	if s.sso != nil {
+		s.logger.Printf("dashboard: single sign-on enabled (redirect=%s)", s.sso.redirectURL)
+	}
 	if !isLoopbackListen(s.listen) {
 		s.logger.Printf("dashboard: %s binds to a non-loopback interface. "+
 			"Ensure TLS or a trusted encrypted tunnel terminates in front of it, since the token travels "+
@@ -207,6 +290,54 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 	}
 }
 
+// routes builds the dashboard's request router.
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Serve embedded static files.
+	mux.HandleFunc("/", s.handleStatic)
+
+	// Single sign-on. The session probe is always served so the UI can tell
+	// whether to offer a sign-in button.
+	mux.HandleFunc("/auth/session", s.handleSession)
+	if s.sso != nil {
+		mux.HandleFunc("/auth/login", s.handleLogin)
+		mux.HandleFunc("/auth/callback", s.handleCallback)
+		mux.HandleFunc("/auth/logout", s.handleLogout)
+	}
+
+	// API proxy endpoints — forward to daemon.
+	mux.HandleFunc("/api/v1/status", s.proxyGet)
+	mux.HandleFunc("/api/v1/sandboxes/inventory", s.proxyGet)
+	mux.HandleFunc("/api/v1/sandboxes/reconcile", s.proxyPost)
+	mux.HandleFunc("/api/v1/sandboxes/validate-plan", s.proxyPost)
+	mux.HandleFunc("/api/v1/sandboxes/stop_all", s.proxyPost)
+	mux.HandleFunc("/api/v1/sandboxes/prune", s.proxyPost)
+	mux.HandleFunc("/api/v1/sandboxes", s.proxySandboxes)
+	mux.HandleFunc("/api/v1/sandboxes/", s.proxySandboxAction)
+	mux.HandleFunc("/api/v1/sandboxes/{vmid}/terminal", s.handleTerminal)
+	mux.HandleFunc("/api/v1/jobs/validate-plan", s.proxyPost)
+	mux.HandleFunc("/api/v1/jobs", s.proxyJobs)
+	mux.HandleFunc("/api/v1/jobs/", s.proxyGet)
+	mux.HandleFunc("/api/v1/workspaces", s.proxyGet)
+	mux.HandleFunc("/api/v1/workspaces/", s.proxyWorkspaceAction)
+	mux.HandleFunc("/api/v1/profiles", s.proxyGet)
+	mux.HandleFunc("/api/v1/sessions", s.proxyGet)
+	mux.HandleFunc("/api/v1/sessions/", s.proxySessionAction)
+	mux.HandleFunc("/api/v1/exposures", s.proxyExposures)
+	mux.HandleFunc("/api/v1/exposures/", s.proxyDelete)
+	mux.HandleFunc("/api/v1/messages", s.proxyMessages)
+	mux.HandleFunc("/api/v1/messages/questions", s.proxyGet)
+	mux.HandleFunc("/api/v1/messages/", s.proxyPost)
+	mux.HandleFunc("/api/v1/secrets/requests", s.proxyGet)
+	mux.HandleFunc("/api/v1/secrets/requests/", s.proxyPost)
+	mux.HandleFunc("/api/v1/approvals", s.proxyGet)
+	mux.HandleFunc("/api/v1/approvals/", s.proxyApprovalAction)
+	mux.HandleFunc("/api/v1/host", s.proxyGet)
+	mux.HandleFunc("/api/v1/pool/status", s.proxyGet)
+	return mux
+}
+
 // handleStatic serves embedded static files or index.html.
 func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
@@ -235,7 +366,7 @@ func (s *Server) proxyGet(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), nil)
+	s.forward(w, r, daemonPath(r.URL.Path), nil)
 }
 
 // maxForwardBytes caps the size of a request body the dashboard buffers before
@@ -271,7 +402,7 @@ func (s *Server) proxyPost(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	s.forward(w, r, daemonPath(r.URL.Path), body)
 }
 
 // proxySandboxes handles GET (list) and POST (create) for /api/v1/sandboxes.
@@ -279,13 +410,13 @@ func (s *Server) proxySandboxes(w http.ResponseWriter, r *http.Request) {
 	// Strip the /api prefix to get /v1/sandboxes.
 	switch r.Method {
 	case http.MethodGet:
-		s.forward(w, r.Method, "/v1/sandboxes", nil)
+		s.forward(w, r, "/v1/sandboxes", nil)
 	case http.MethodPost:
 		body, ok := s.readBoundedBody(w, r)
 		if !ok {
 			return
 		}
-		s.forward(w, r.Method, "/v1/sandboxes", body)
+		s.forward(w, r, "/v1/sandboxes", body)
 	default:
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 	}
@@ -297,20 +428,25 @@ func (s *Server) proxySandboxAction(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	// Keep the query string: usage history takes ?range=.
+	path := daemonPath(r.URL.Path)
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	s.forward(w, r, path, body)
 }
 
 // proxyJobs handles GET (list) and POST (create) for /api/v1/jobs.
 func (s *Server) proxyJobs(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		s.forward(w, r.Method, "/v1/jobs", nil)
+		s.forward(w, r, "/v1/jobs", nil)
 	case http.MethodPost:
 		body, ok := s.readBoundedBody(w, r)
 		if !ok {
 			return
 		}
-		s.forward(w, r.Method, "/v1/jobs", body)
+		s.forward(w, r, "/v1/jobs", body)
 	default:
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 	}
@@ -322,7 +458,7 @@ func (s *Server) proxyWorkspaceAction(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	s.forward(w, r, daemonPath(r.URL.Path), body)
 }
 
 // proxySessionAction forwards requests to /v1/sessions/{id}/...
@@ -331,7 +467,21 @@ func (s *Server) proxySessionAction(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	s.forward(w, r, daemonPath(r.URL.Path), body)
+}
+
+// proxyApprovalAction forwards GET /v1/approvals/{id} and the approve and
+// reject POSTs.
+func (s *Server) proxyApprovalAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	body, ok := s.readBoundedBody(w, r)
+	if !ok {
+		return
+	}
+	s.forward(w, r, daemonPath(r.URL.Path), body)
 }
 
 // proxyExposures handles GET (list), POST (create), DELETE for /api/v1/exposures.
@@ -340,7 +490,7 @@ func (s *Server) proxyExposures(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	s.forward(w, r, daemonPath(r.URL.Path), body)
 }
 
 // proxyDelete forwards a DELETE request to the daemon.
@@ -349,7 +499,7 @@ func (s *Server) proxyDelete(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	s.forward(w, r, daemonPath(r.URL.Path), body)
 }
 
 // proxyMessages handles GET (list) and POST (create) for /api/v1/messages.
@@ -358,7 +508,7 @@ func (s *Server) proxyMessages(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	s.forward(w, r.Method, daemonPath(r.URL.Path), body)
+	s.forward(w, r, daemonPath(r.URL.Path), body)
 }
 
 // daemonPath strips the /api prefix from the URL path, mapping dashboard
@@ -367,9 +517,9 @@ func daemonPath(p string) string {
 	return strings.TrimPrefix(p, "/api")
 }
 
-// forward sends a request to the daemon via Unix socket.
-func (s *Server) forward(w http.ResponseWriter, method, path string, body []byte) {
-	client := &http.Client{
+// daemonClient returns an HTTP client that talks to the daemon socket.
+func (s *Server) daemonClient() *http.Client {
+	return &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
 				return net.Dial("unix", s.socketPath)
@@ -377,6 +527,77 @@ func (s *Server) forward(w http.ResponseWriter, method, path string, body []byte
 		},
 		Timeout: 30 * time.Second,
 	}
+}
+
+// daemonToken returns the token for daemon requests made on behalf of ctx:
+// the signed-in user's token for a single sign-on session, the outbound
+// --token otherwise.
+func (s *Server) daemonToken(ctx context.Context) string {
+	if sess := sessionFromContext(ctx); sess != nil {
+		return sess.Token
+	}
+	return s.token
+}
+
+// daemonGet fetches a daemon JSON resource into v on behalf of ctx.
+func (s *Server) daemonGet(ctx context.Context, path string, v any) error {
+	return s.daemonDo(ctx, s.daemonToken(ctx), http.MethodGet, path, nil, v)
+}
+
+// daemonDo sends a JSON request to the daemon with the given token and
+// decodes the response into v.
+func (s *Server) daemonDo(ctx context.Context, token, method, path string, payload, v any) error {
+	if payload == nil {
+		return s.daemonSend(ctx, token, method, path, "", nil, v)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.daemonSend(ctx, token, method, path, "application/json", strings.NewReader(string(data)), v)
+}
+
+// daemonSend sends body to the daemon with the given token and content type,
+// and decodes the JSON response into v unless v is nil.
+func (s *Server) daemonSend(ctx context.Context, token, method, path, contentType string, body io.Reader, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://unix"+path, body)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.daemonClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("daemon connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxForwardBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("daemon returned status %d", resp.StatusCode)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// forward sends r's method and body to path on the daemon via Unix socket, on
+// behalf of r's caller (see daemonToken).
+func (s *Server) forward(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	client := s.daemonClient()
 
 	url := "http://unix" + path
 	var bodyReader io.Reader
@@ -384,15 +605,15 @@ func (s *Server) forward(w http.ResponseWriter, method, path string, body []byte
 		bodyReader = strings.NewReader(string(body))
 	}
 
-	req, err := http.NewRequest(method, url, bodyReader)
+	req, err := http.NewRequest(r.Method, url, bodyReader)
 	if err != nil {
 		s.logger.Printf("dashboard: error creating request: %v", err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	if s.token != "" {
-		req.Header.Set("Authorization", "Bearer "+s.token)
+	if token := s.daemonToken(r.Context()); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 	if len(body) > 0 {
 		req.Header.Set("Content-Type", "application/json")
@@ -480,15 +701,26 @@ func (s *Server) inboundMiddleware(next http.Handler) http.Handler {
 		}
 		// Fail closed: requestHasBrowserToken returns false when no token is
 		// configured, so a Server that somehow skipped ensureBrowserToken can
-		// never serve /api/* unauthenticated.
+		// never serve /api/* unauthenticated. A single sign-on session is the
+		// alternative; its requests reach the daemon as the signed-in user.
 		if !s.requestHasBrowserToken(r) {
-			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "dashboard token required"})
-			return
+			sess := s.sso.session(r)
+			if sess == nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "dashboard token required"})
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, sess))
 		}
 		if isStateChanging(r.Method) && !s.csrfOK(r) {
 			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-site request rejected"})
 			return
 		}
+		// A WebSocket handshake cannot carry X-Requested-With, but browsers
+		// always send Origin on it, so require that to be same-origin.
+		if isWebSocketUpgrade(r) && !s.sameOrigin(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-site request rejected"})
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -512,6 +744,12 @@ func (s *Server) csrfOK(r *http.Request) bool {
 	if r.Header.Get("X-Requested-With") != "XMLHttpRequest" {
 		return false
 	}
+	return s.sameOrigin(r)
+}
+
+// sameOrigin reports whether the request's Origin (or, failing that, Referer)
+// header names the dashboard's own host.
+func (s *Server) sameOrigin(r *http.Request) bool {
 	origin := r.Header.Get("Origin")
 	if origin == "" {
 		origin = r.Header.Get("Referer")
@@ -571,6 +809,13 @@ func (s *Server) requestHasBrowserToken(r *http.Request) bool {
 			return true
 		}
 	}
+	if isWebSocketUpgrade(r) {
+		for _, p := range websocketProtocols(r) {
+			if tok, ok := strings.CutPrefix(p, terminalTokenPrefix); ok && subtle.ConstantTimeCompare([]byte(tok), want) == 1 {
+				return true
+			}
+		}
+	}
 	return false
 }
 
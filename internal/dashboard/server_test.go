@@ -156,6 +156,11 @@ func TestProxyForward(t *testing.T) {
 				})
 				return
 			}
+			if r.URL.Path == "/v1/sandboxes/1001/usage" {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]any{"range": r.URL.Query().Get("range")})
+				return
+			}
 			if r.URL.Path == "/v1/sandboxes" && r.Method == http.MethodPost {
 				var body map[string]any
 				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -170,6 +175,17 @@ func TestProxyForward(t *testing.T) {
 				})
 				return
 			}
+			if r.URL.Path == "/v1/sandboxes/prune" {
+				w.Header().Set("X-AgentLab-Pending-Operation", "op_1")
+				w.WriteHeader(http.StatusAccepted)
+				json.NewEncoder(w).Encode(map[string]any{"id": "op_1", "status": "pending"})
+				return
+			}
+			if r.URL.Path == "/v1/approvals/op_1/approve" && r.Method == http.MethodPost {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]any{"id": "op_1", "status": "executed"})
+				return
+			}
 			w.WriteHeader(http.StatusNotFound)
 		}),
 	}
@@ -229,6 +245,35 @@ func TestProxyForward(t *testing.T) {
 		t.Fatalf("proxySandboxes POST returned %d, want 200", w.Code)
 	}
 
+	// Sandbox actions keep their query string.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/sandboxes/1001/usage?range=24h", nil)
+	w = httptest.NewRecorder()
+	srv.proxySandboxAction(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("proxySandboxAction usage returned %d, want 200", w.Code)
+	}
+	var usage map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&usage); err != nil {
+		t.Fatalf("failed to decode usage response: %v", err)
+	}
+	if usage["range"] != "24h" {
+		t.Errorf("forwarded range = %v, want 24h", usage["range"])
+	}
+
+	// Held operations keep their 202 and pending operation header.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/sandboxes/prune", nil)
+	w = httptest.NewRecorder()
+	srv.proxyPost(w, req)
+	if w.Code != http.StatusAccepted || w.Header().Get("X-AgentLab-Pending-Operation") != "op_1" {
+		t.Fatalf("proxyPost prune returned %d with pending operation %q, want 202 op_1", w.Code, w.Header().Get("X-AgentLab-Pending-Operation"))
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/approvals/op_1/approve", nil)
+	w = httptest.NewRecorder()
+	srv.proxyApprovalAction(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("proxyApprovalAction approve returned %d, want 200", w.Code)
+	}
+
 	// Test method not allowed.
 	req = httptest.NewRequest(http.MethodDelete, "/api/v1/status", nil)
 	w = httptest.NewRecorder()
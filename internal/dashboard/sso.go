@@ -0,0 +1,325 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/oidc"
+)
+
+const (
+	// sessionCookie holds a single sign-on session ID. The AgentLab token
+	// it stands for stays on the server.
+	sessionCookie = "dashboard_session"
+
+	// loginStateCookie binds a pending login to the browser that started it,
+	// so a callback URL planted by someone else cannot sign the victim in as
+	// the attacker.
+	loginStateCookie = "dashboard_login_state"
+
+	// loginTimeout bounds how long a user may take at the provider.
+	loginTimeout = 10 * time.Minute
+)
+
+// ssoSession is a signed-in browser. Token is the daemon-issued AgentLab
+// token that acts for the user; the session ends when it expires.
+type ssoSession struct {
+	Token   string
+	UserID  string
+	User    string
+	Role    string
+	Teams   []string
+	Expires time.Time
+}
+
+// pendingLogin is an authorization request awaiting its callback.
+type pendingLogin struct {
+	verifier string
+	nonce    string
+	started  time.Time
+}
+
+// sso signs browser users in with the authorization code flow (PKCE) against
+// the identity provider agentlabd trusts. The provider and client come from
+// the daemon's /auth/oidc/config, so the two cannot drift apart. The ID token
+// is handed to the daemon, which answers with a token acting for the user;
+// every request from the session is forwarded with it, so the daemon applies
+// the user's role and bindings rather than the dashboard's own trust.
+type sso struct {
+	redirectURL  string
+	clientSecret string
+	secure       bool
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	clientID string
+	scopes   []string
+	pending  map[string]pendingLogin
+	sessions map[string]*ssoSession
+}
+
+func newSSO(redirectURL, clientSecret string) (*sso, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("dashboard: --oidc-redirect-url must be an absolute http(s) URL, got %q", redirectURL)
+	}
+	if u.Path != "/auth/callback" {
+		return nil, fmt.Errorf("dashboard: --oidc-redirect-url must end in /auth/callback, got %q", redirectURL)
+	}
+	return &sso{
+		redirectURL:  u.String(),
+		clientSecret: clientSecret,
+		secure:       u.Scheme == "https",
+		pending:      map[string]pendingLogin{},
+		sessions:     map[string]*ssoSession{},
+	}, nil
+}
+
+type sessionKey struct{}
+
+// sessionFromContext returns the single sign-on session a request was
+// authenticated with, or nil for browser-token requests.
+func sessionFromContext(ctx context.Context) *ssoSession {
+	sess, _ := ctx.Value(sessionKey{}).(*ssoSession)
+	return sess
+}
+
+// session returns the live session named by r's session cookie, if any.
+func (s *sso) session(r *http.Request) *ssoSession {
+	if s == nil {
+		return nil
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[c.Value]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.Expires) {
+		delete(s.sessions, c.Value)
+		return nil
+	}
+	return sess
+}
+
+// prune drops expired sessions and abandoned logins. Callers hold s.mu.
+func (s *sso) prune(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.After(sess.Expires) {
+			delete(s.sessions, id)
+		}
+	}
+	for state, p := range s.pending {
+		if now.Sub(p.started) > loginTimeout {
+			delete(s.pending, state)
+		}
+	}
+}
+
+// ssoProvider discovers the daemon's identity provider on first use.
+func (s *Server) ssoProvider(ctx context.Context) (*oidc.Provider, string, []string, error) {
+	s.sso.mu.Lock()
+	provider, clientID, scopes := s.sso.provider, s.sso.clientID, s.sso.scopes
+	s.sso.mu.Unlock()
+	if provider != nil {
+		return provider, clientID, scopes, nil
+	}
+	var cfg struct {
+		Issuer   string   `json:"issuer"`
+		ClientID string   `json:"client_id"`
+		Scopes   []string `json:"scopes"`
+	}
+	if err := s.daemonDo(ctx, s.token, http.MethodGet, "/auth/oidc/config", nil, &cfg); err != nil {
+		return nil, "", nil, fmt.Errorf("single sign-on config: %w", err)
+	}
+	provider, err := oidc.Discover(ctx, cfg.Issuer, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	s.sso.mu.Lock()
+	s.sso.provider, s.sso.clientID, s.sso.scopes = provider, cfg.ClientID, cfg.Scopes
+	s.sso.mu.Unlock()
+	return provider, cfg.ClientID, cfg.Scopes, nil
+}
+
+// handleLogin starts the authorization code flow.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, clientID, scopes, err := s.ssoProvider(r.Context())
+	if err != nil {
+		s.logger.Printf("dashboard: sign-in: %v", err)
+		http.Error(w, "identity provider unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+	s.sso.mu.Lock()
+	s.sso.prune(time.Now())
+	s.sso.pending[state] = pendingLogin{verifier: verifier, nonce: nonce, started: time.Now()}
+	s.sso.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    state,
+		Path:     "/auth/",
+		MaxAge:   int(loginTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   s.sso.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, provider.AuthCodeURL(clientID, s.sso.redirectURL, state, nonce, oidc.CodeChallenge(verifier), scopes), http.StatusFound)
+}
+
+// handleCallback completes the flow: it redeems the code, trades the ID token
+// for an AgentLab token at the daemon and starts a session.
+func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "sign-in failed: "+e+" "+q.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+	state := q.Get("state")
+	c, err := r.Cookie(loginStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+		http.Error(w, "sign-in failed: login state mismatch; start again from the dashboard", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Path: "/auth/", MaxAge: -1, HttpOnly: true, Secure: s.sso.secure, SameSite: http.SameSiteLaxMode})
+	s.sso.mu.Lock()
+	pending, ok := s.sso.pending[state]
+	delete(s.sso.pending, state)
+	s.sso.mu.Unlock()
+	if !ok || time.Since(pending.started) > loginTimeout {
+		http.Error(w, "sign-in failed: login expired; start again from the dashboard", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	provider, clientID, _, err := s.ssoProvider(ctx)
+	if err != nil {
+		s.logger.Printf("dashboard: sign-in: %v", err)
+		http.Error(w, "identity provider unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	tokens, err := provider.Exchange(ctx, clientID, s.sso.clientSecret, q.Get("code"), s.sso.redirectURL, pending.verifier)
+	if err != nil {
+		s.logger.Printf("dashboard: sign-in: redeem code: %v", err)
+		http.Error(w, "sign-in failed: could not redeem the authorization code", http.StatusBadGateway)
+		return
+	}
+	var login struct {
+		Token     string   `json:"token"`
+		ExpiresAt string   `json:"expires_at"`
+		UserID    string   `json:"user_id"`
+		User      string   `json:"user"`
+		Role      string   `json:"role"`
+		Teams     []string `json:"teams"`
+	}
+	payload := map[string]string{"id_token": tokens.IDToken, "nonce": pending.nonce}
+	if err := s.daemonDo(ctx, s.token, http.MethodPost, "/auth/oidc/token", payload, &login); err != nil {
+		s.logger.Printf("dashboard: sign-in: %v", err)
+		http.Error(w, "sign-in failed: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	expires, err := time.Parse(time.RFC3339, login.ExpiresAt)
+	if err != nil || login.Token == "" {
+		http.Error(w, "sign-in failed: malformed daemon response", http.StatusBadGateway)
+		return
+	}
+
+	id := oidc.RandomString()
+	s.sso.mu.Lock()
+	s.sso.sessions[id] = &ssoSession{
+		Token:   login.Token,
+		UserID:  login.UserID,
+		User:    login.User,
+		Role:    login.Role,
+		Teams:   login.Teams,
+		Expires: expires,
+	}
+	s.sso.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.sso.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	s.logger.Printf("dashboard: %s signed in (role=%s) from %s", login.User, login.Role, r.RemoteAddr)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// handleLogout ends the caller's session.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if !s.csrfOK(r) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-site request rejected"})
+		return
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.sso.mu.Lock()
+		delete(s.sso.sessions, c.Value)
+		s.sso.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: s.sso.secure, SameSite: http.SameSiteLaxMode})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "signed_out"})
+}
+
+// handleSession tells the UI whether single sign-on is available and who is
+// signed in. It never reveals the session's token.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	resp := map[string]any{"sso_enabled": s.sso != nil, "signed_in": false}
+	if sess := s.sso.session(r); sess != nil {
+		resp["signed_in"] = true
+		resp["user"] = sess.User
+		resp["role"] = sess.Role
+		resp["teams"] = sess.Teams
+		resp["expires_at"] = sess.Expires.UTC().Format(time.RFC3339)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// checkTerminalAccess asks the daemon whether a signed-in user may open a
+// shell on vmid. The terminal dials sandboxes with the dashboard's own key,
+// so without this check any signed-in user could reach any sandbox.
+func (s *Server) checkTerminalAccess(ctx context.Context, vmid int) error {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return nil
+	}
+	var check struct {
+		Allowed bool `json:"allowed"`
+	}
+	path := fmt.Sprintf("/v1/access/check?user=%s&permission=sandbox.ssh&vmid=%d", url.QueryEscape(sess.UserID), vmid)
+	if err := s.daemonDo(ctx, s.token, http.MethodGet, path, nil, &check); err != nil {
+		return fmt.Errorf("check access: %w", err)
+	}
+	if !check.Allowed {
+		return errors.New("you are not allowed to open a terminal on this sandbox")
+	}
+	return nil
+}
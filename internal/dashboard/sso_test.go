@@ -0,0 +1,200 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agentlab/agentlab/internal/oidc/oidctest"
+)
+
+// newSSOTestServer serves the dashboard with single sign-on against a mock
+// provider. The fake daemon trades any ID token for "user-token" acting for
+// alice, and echoes the Authorization header it receives on /v1/status.
+func newSSOTestServer(t *testing.T) (*Server, *httptest.Server, *oidctest.Provider) {
+	t.Helper()
+	provider := oidctest.New(t)
+	socketPath := filepath.Join(t.TempDir(), "d.sock")
+	daemon := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/oidc/config":
+			writeJSON(w, http.StatusOK, map[string]any{"issuer": provider.URL, "client_id": oidctest.ClientID})
+		case "/auth/oidc/token":
+			var req map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req["id_token"] == "" || req["nonce"] == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id_token and nonce expected"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"token": "user-token", "expires_at": "2099-01-01T00:00:00Z",
+				"user_id": "alice", "user": "alice", "role": "user", "teams": []string{"infra"},
+			})
+		case "/v1/status":
+			writeJSON(w, http.StatusOK, map[string]string{"authorization": r.Header.Get("Authorization")})
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	})}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go daemon.Serve(ln)
+	t.Cleanup(func() { daemon.Close() })
+
+	webLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + webLn.Addr().String()
+	srv := NewServer(Config{
+		SocketPath:      socketPath,
+		Token:           "operator-token",
+		BrowserToken:    "tok",
+		OIDCRedirectURL: base + "/auth/callback",
+	}, log.New(io.Discard, "", 0))
+	if err := srv.initSSO(); err != nil {
+		t.Fatal(err)
+	}
+	web := httptest.NewUnstartedServer(srv.securityHeaders(srv.inboundMiddleware(srv.routes())))
+	web.Listener = webLn
+	web.Start()
+	t.Cleanup(web.Close)
+	return srv, web, provider
+}
+
+func getJSON(t *testing.T, client *http.Client, url string) (int, map[string]any) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestSSOLoginForwardsAsTheSignedInUser(t *testing.T) {
+	_, web, _ := newSSOTestServer(t)
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	if code, _ := getJSON(t, client, web.URL+"/api/v1/status"); code != http.StatusUnauthorized {
+		t.Fatalf("status before sign-in = %d, want 401", code)
+	}
+	_, session := getJSON(t, client, web.URL+"/auth/session")
+	if session["sso_enabled"] != true || session["signed_in"] != false {
+		t.Fatalf("session before sign-in = %v", session)
+	}
+
+	// /auth/login -> provider -> /auth/callback -> /
+	resp, err := client.Get(web.URL + "/auth/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/" {
+		t.Fatalf("sign-in ended at %s with %d", resp.Request.URL, resp.StatusCode)
+	}
+
+	_, session = getJSON(t, client, web.URL+"/auth/session")
+	if session["signed_in"] != true || session["user"] != "alice" {
+		t.Fatalf("session after sign-in = %v", session)
+	}
+	if _, ok := session["token"]; ok {
+		t.Fatal("session endpoint must not reveal the daemon token")
+	}
+	code, status := getJSON(t, client, web.URL+"/api/v1/status")
+	if code != http.StatusOK || status["authorization"] != "Bearer user-token" {
+		t.Fatalf("forwarded as %v (%d), want the user's token", status, code)
+	}
+
+	// Browser-token requests keep using the operator token.
+	req, _ := http.NewRequest(http.MethodGet, web.URL+"/api/v1/status", nil)
+	req.Header.Set("Authorization", "Bearer tok")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var operator map[string]string
+	_ = json.NewDecoder(resp.Body).Decode(&operator)
+	resp.Body.Close()
+	if operator["authorization"] != "Bearer operator-token" {
+		t.Fatalf("browser-token request forwarded as %q", operator["authorization"])
+	}
+
+	// Logout needs the CSRF headers, then ends the session.
+	logout := func(headers bool) int {
+		req, _ := http.NewRequest(http.MethodPost, web.URL+"/auth/logout", nil)
+		if headers {
+			req.Header.Set("X-Requested-With", "XMLHttpRequest")
+			req.Header.Set("Origin", web.URL)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := logout(false); code != http.StatusForbidden {
+		t.Fatalf("logout without CSRF headers = %d, want 403", code)
+	}
+	if code := logout(true); code != http.StatusOK {
+		t.Fatalf("logout = %d", code)
+	}
+	if code, _ := getJSON(t, client, web.URL+"/api/v1/status"); code != http.StatusUnauthorized {
+		t.Fatalf("status after logout = %d, want 401", code)
+	}
+}
+
+func TestSSOCallbackRejectsForeignState(t *testing.T) {
+	_, web, _ := newSSOTestServer(t)
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	// A callback the browser never started (no state cookie) is refused.
+	resp, err := noRedirect.Get(web.URL + "/auth/callback?code=x&state=planted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "state mismatch") {
+		t.Fatalf("planted callback = %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestNewSSORejectsBadRedirectURL(t *testing.T) {
+	for _, raw := range []string{"dashboard.example.com/auth/callback", "https://dashboard.example.com/", "ftp://x/auth/callback"} {
+		if _, err := newSSO(raw, ""); err == nil {
+			t.Errorf("newSSO(%q) succeeded", raw)
+		}
+	}
+	s, err := newSSO("https://dashboard.example.com/auth/callback", "")
+	if err != nil || !s.secure {
+		t.Fatalf("newSSO(https) = %+v, %v", s, err)
+	}
+}
+
+func TestSessionProbeWithoutSSO(t *testing.T) {
+	srv := testServer("")
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/session", nil))
+	if !strings.Contains(rec.Body.String(), `"sso_enabled":false`) {
+		t.Fatalf("session probe = %s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	if strings.Contains(rec.Header().Get("Location"), "authorize") {
+		t.Fatal("login must not be served without single sign-on")
+	}
+}
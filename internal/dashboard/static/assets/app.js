@@ -29,6 +29,65 @@
     }
   }
 
+  // Single sign-on state from /auth/session. With SSO enabled, a browser
+  // without the dashboard token signs in at the identity provider instead of
+  // being asked for the token; its session cookie is HttpOnly and travels on
+  // its own.
+  var sso = { enabled: false, signedIn: false, user: "" };
+
+  function signedOut() {
+    try {
+      return sessionStorage.getItem("agentlab_signed_out") === "1";
+    } catch (e) {
+      return false;
+    }
+  }
+
+  function signIn() {
+    try {
+      sessionStorage.removeItem("agentlab_signed_out");
+    } catch (e) {
+      /* nothing to clear */
+    }
+    window.location.assign("/auth/login");
+  }
+
+  async function loadSession() {
+    try {
+      var res = await fetch("/auth/session");
+      if (!res.ok) return;
+      var body = await res.json();
+      sso.enabled = !!body.sso_enabled;
+      sso.signedIn = !!body.signed_in;
+      sso.user = body.user || "";
+    } catch (e) {
+      /* an older dashboard without /auth/session: token only */
+    }
+    renderSession();
+  }
+
+  function renderSession() {
+    var badge = document.getElementById("user-badge");
+    var login = document.getElementById("btn-login");
+    var logout = document.getElementById("btn-logout");
+    badge.textContent = sso.signedIn ? sso.user : "";
+    badge.classList.toggle("hidden", !sso.signedIn);
+    logout.classList.toggle("hidden", !sso.signedIn);
+    login.classList.toggle("hidden", !sso.enabled || sso.signedIn || !!dashboardToken());
+  }
+
+  async function logout() {
+    await fetch("/auth/logout", { method: "POST", headers: { "X-Requested-With": "XMLHttpRequest" } });
+    try {
+      sessionStorage.setItem("agentlab_signed_out", "1");
+    } catch (e) {
+      /* the next 401 signs in again */
+    }
+    sso.signedIn = false;
+    sso.user = "";
+    renderSession();
+  }
+
   function applyDashboardHeaders(opts) {
     opts = opts || {};
     opts.headers = Object.assign({}, opts.headers || {});
@@ -44,6 +103,14 @@
   async function api(path, opts) {
     opts = applyDashboardHeaders(opts);
     var res = await fetch("/api" + path, opts);
+    // With single sign-on, a browser holding neither a token nor a session
+    // signs in at the identity provider, unless the user just signed out.
+    if (res.status === 401 && sso.enabled && !dashboardToken()) {
+      sso.signedIn = false;
+      renderSession();
+      if (!signedOut()) signIn();
+      throw new Error("sign in required");
+    }
     // If the server requires an inbound token we do not yet hold, ask the user
     // once, persist it to this session, and retry the original request.
     if (res.status === 401 && !opts.__agentlabRetried) {
@@ -67,6 +134,13 @@
         throw e;
       }
     }
+    // The approval policy held the request instead of running it; it runs
+    // once other users approve it under Pending Approvals.
+    var held = res.headers.get("X-AgentLab-Pending-Operation");
+    if (res.status === 202 && held) {
+      alert("Operation " + held + " is awaiting approval. It runs once approved under Pending Approvals.");
+      loadOperationApprovals();
+    }
     return res;
   }
 
@@ -91,6 +165,26 @@
     return td;
   }
 
+  // renderDetailFields fills the detail modal summary. Labels and values can
+  // come from the guest (published status keys), so they are set as text.
+  function renderDetailFields(fields) {
+    var summary = document.getElementById("detail-summary");
+    summary.textContent = "";
+    fields.forEach(function (f) {
+      var field = document.createElement("div");
+      field.className = "detail-field";
+      var label = document.createElement("div");
+      label.className = "detail-label";
+      label.textContent = f.label;
+      var value = document.createElement("div");
+      value.className = "detail-value";
+      value.textContent = String(f.value);
+      field.appendChild(label);
+      field.appendChild(value);
+      summary.appendChild(field);
+    });
+  }
+
   function codeEl(text) {
     var code = document.createElement("code");
     code.textContent = text;
@@ -344,6 +438,9 @@
       showDetail(vmid);
     });
     if (state === "running" || state === "ready") {
+      addActionButton(group, "Terminal", "btn btn-sm", function () {
+        openTerminal(vmid);
+      });
       addActionButton(group, "Stop", "btn btn-sm", function () {
         stopSandbox(vmid);
       });
@@ -614,6 +711,135 @@
     }
   }
 
+  // loadSecretRequests lists approval-gated secret reads waiting on an
+  // operator. The panel stays hidden while nothing is pending.
+  async function loadSecretRequests() {
+    var panel = document.getElementById("secret-requests");
+    var tbody = document.getElementById("secret-request-list");
+    try {
+      var data = await apiJSON("/v1/secrets/requests");
+      var list = (data && data.requests) || [];
+      tbody.innerHTML = "";
+      if (list.length === 0) {
+        panel.classList.add("hidden");
+        return;
+      }
+      panel.classList.remove("hidden");
+      list.forEach(function (req) {
+        var id = req.id;
+        var tr = document.createElement("tr");
+        appendTd(tr).appendChild(codeEl(id));
+        appendTd(tr, req.secret);
+        appendTd(tr, String(req.vmid));
+        appendTd(tr, req.job_id || "-");
+        appendTd(tr, timeAgo(req.requested_at));
+        var actions = document.createElement("td");
+        addActionButton(actions, "Approve", "btn btn-sm btn-primary", function () {
+          decideSecretRequest(id, "approve");
+        });
+        addActionButton(actions, "Deny", "btn btn-sm btn-danger", function () {
+          decideSecretRequest(id, "deny");
+        });
+        tr.appendChild(actions);
+        tbody.appendChild(tr);
+      });
+    } catch (e) {
+      panel.classList.remove("hidden");
+      errorRow(tbody, 6, e.message);
+    }
+  }
+
+  // loadOperationApprovals lists operations the approval policy holds. Each
+  // approval counts for the signed-in user; the requester can only reject.
+  async function loadOperationApprovals() {
+    var panel = document.getElementById("operation-approvals");
+    var tbody = document.getElementById("operation-approval-list");
+    try {
+      var data = await apiJSON("/v1/approvals");
+      var list = (data && data.operations) || [];
+      tbody.innerHTML = "";
+      if (list.length === 0) {
+        panel.classList.add("hidden");
+        return;
+      }
+      panel.classList.remove("hidden");
+      list.forEach(function (op) {
+        var id = op.id;
+        var tr = document.createElement("tr");
+        appendTd(tr).appendChild(codeEl(id));
+        appendTd(tr).appendChild(codeEl(op.method + " " + op.path));
+        appendTd(tr, op.requested_by);
+        appendTd(
+          tr,
+          (op.approvals || []).length + "/" + op.required_approvals + " " + op.approver_role
+        );
+        appendTd(tr, timeAgo(op.created_at));
+        var actions = document.createElement("td");
+        addActionButton(actions, "Approve", "btn btn-sm btn-primary", function () {
+          decideOperation(op, "approve");
+        });
+        addActionButton(actions, "Reject", "btn btn-sm btn-danger", function () {
+          decideOperation(op, "reject");
+        });
+        tr.appendChild(actions);
+        tbody.appendChild(tr);
+      });
+    } catch (e) {
+      panel.classList.remove("hidden");
+      errorRow(tbody, 6, e.message);
+    }
+  }
+
+  // loadQuestions lists questions agents are blocked on. A question with
+  // options gets one button per option; otherwise the operator types an
+  // answer. Question text and options come from the guest.
+  async function loadQuestions() {
+    var panel = document.getElementById("agent-questions");
+    var tbody = document.getElementById("question-list");
+    try {
+      var data = await apiJSON("/v1/messages/questions");
+      var list = (data && data.messages) || [];
+      tbody.innerHTML = "";
+      if (list.length === 0) {
+        panel.classList.add("hidden");
+        return;
+      }
+      panel.classList.remove("hidden");
+      list.forEach(function (q) {
+        var id = q.id;
+        var options = (q.json && q.json.options) || [];
+        var tr = document.createElement("tr");
+        appendTd(tr).appendChild(codeEl(String(id)));
+        appendTd(tr, q.author || "-");
+        appendTd(tr, q.scope_type + ":" + q.scope_id);
+        appendTd(tr, timeAgo(q.ts));
+        appendTd(tr, q.text);
+        var answer = document.createElement("td");
+        if (options.length > 0) {
+          options.forEach(function (option) {
+            addActionButton(answer, option, "btn btn-sm", function () {
+              answerQuestion(id, option);
+            });
+          });
+        } else {
+          var input = document.createElement("input");
+          input.type = "text";
+          input.className = "answer-input";
+          input.placeholder = "Answer";
+          answer.appendChild(input);
+          addActionButton(answer, "Reply", "btn btn-sm btn-primary", function () {
+            answerQuestion(id, input.value);
+          });
+        }
+        tr.appendChild(answer);
+        tbody.appendChild(tr);
+      });
+    } catch (e) {
+      panel.classList.remove("hidden");
+      errorRow(tbody, 6, e.message);
+    }
+  }
+
   async function refreshAll() {
     await Promise.all([
       loadStatus(),
@@ -622,6 +848,9 @@
       loadWorkspaces(),
       loadExposures(),
       loadEvents(),
+      loadSecretRequests(),
+      loadOperationApprovals(),
+      loadQuestions(),
     ]);
   }
 
@@ -704,28 +933,24 @@
           });
         }
       }
+      // Status values the guest published through /metadata/kv.
+      (data.kv || []).forEach(function (kv) {
+        fields.push({ label: "Status: " + kv.key, value: kv.value });
+      });
 
-      var summaryHtml = fields
-        .map(function (f) {
-          return (
-            '<div class="detail-field">' +
-            '<div class="detail-label">' +
-            esc(f.label) +
-            "</div>" +
-            '<div class="detail-value">' +
-            esc(String(f.value)) +
-            "</div>" +
-            "</div>"
-          );
-        })
-        .join("");
-      document.getElementById("detail-summary").innerHTML = summaryHtml;
+      renderDetailFields(fields);
+      renderJobDiff(null);
+      renderUsage(null);
+      renderRecordings(vmid, null);
       document.getElementById("detail-json").textContent = JSON.stringify(
         data,
         null,
         2
       );
       document.getElementById("modal-sandbox-detail").style.display = "flex";
+      usageVMID = vmid;
+      loadUsage(vmid);
+      loadRecordings(vmid);
     } catch (e) {
       alert("Failed to load details: " + e.message);
     }
@@ -751,21 +976,17 @@
         { label: "Sandbox VMID", value: data.sandbox_vmid || "-" },
         { label: "Created", value: timeAgo(data.created_at) },
       ];
-      var summaryHtml = fields
-        .map(function (f) {
-          return (
-            '<div class="detail-field">' +
-            '<div class="detail-label">' +
-            esc(f.label) +
-            "</div>" +
-            '<div class="detail-value">' +
-            esc(String(f.value)) +
-            "</div>" +
-            "</div>"
-          );
-        })
-        .join("");
-      document.getElementById("detail-summary").innerHTML = summaryHtml;
+      renderDetailFields(fields);
+      usageVMID = null;
+      renderUsage(null);
+      renderRecordings(null, null);
+      var diff = null;
+      try {
+        diff = await apiJSON("/v1/jobs/" + encodeURIComponent(jobId) + "/diff");
+      } catch (e) {
+        diff = null;
+      }
+      renderJobDiff(diff);
       document.getElementById("detail-json").textContent = JSON.stringify(
         data,
         null,
@@ -777,6 +998,71 @@
     }
   }
 
+  // renderJobDiff fills the detail modal's Changes section from a
+  // /v1/jobs/{id}/diff response, or hides it when diff is null. Patch lines
+  // are agent-controlled, so every line is set through textContent.
+  function renderJobDiff(diff) {
+    var section = document.getElementById("detail-diff");
+    var stats = document.getElementById("detail-diff-stats");
+    var patch = document.getElementById("detail-diff-patch");
+    stats.innerHTML = "";
+    patch.innerHTML = "";
+    if (!diff) {
+      section.style.display = "none";
+      return;
+    }
+    document.getElementById("detail-diff-summary").textContent =
+      "Changes \u2014 " +
+      (diff.files_changed || 0) +
+      " files, +" +
+      (diff.additions || 0) +
+      " \u2212" +
+      (diff.deletions || 0) +
+      (diff.truncated ? " (patch truncated)" : "");
+
+    (diff.files || []).forEach(function (f) {
+      var li = document.createElement("li");
+      var status = document.createElement("span");
+      status.className = "diff-status diff-status-" + f.status;
+      status.textContent = f.status;
+      li.appendChild(status);
+      var name = document.createElement("span");
+      name.textContent = f.old_path ? f.old_path + " \u2192 " + f.path : f.path;
+      li.appendChild(name);
+      var counts = document.createElement("span");
+      counts.className = "diff-counts";
+      counts.textContent = f.binary
+        ? "binary"
+        : "+" + f.additions + " \u2212" + f.deletions;
+      li.appendChild(counts);
+      stats.appendChild(li);
+    });
+
+    var inHunk = false;
+    (diff.patch || "").split("\n").forEach(function (line, i, lines) {
+      if (i === lines.length - 1 && line === "") return;
+      var cls = "";
+      if (line.indexOf("diff --git ") === 0) {
+        inHunk = false;
+        cls = "diff-meta";
+      } else if (line.indexOf("@@") === 0) {
+        inHunk = true;
+        cls = "diff-hunk";
+      } else if (inHunk && line.charAt(0) === "+") {
+        cls = "diff-add";
+      } else if (inHunk && line.charAt(0) === "-") {
+        cls = "diff-del";
+      } else if (!inHunk) {
+        cls = "diff-meta";
+      }
+      var div = document.createElement("div");
+      if (cls) div.className = cls;
+      div.textContent = line;
+      patch.appendChild(div);
+    });
+    section.style.display = "block";
+  }
+
   // --- Expose ---
 
   var exposeVMID = null;
@@ -813,7 +1099,699 @@
     }
   }
 
+  async function decideSecretRequest(id, action) {
+    if (!confirm((action === "approve" ? "Approve" : "Deny") + " secret request " + id + "?")) return;
+    try {
+      await api("/v1/secrets/requests/" + encodeURIComponent(id) + "/" + action, {
+        method: "POST",
+      });
+      await Promise.all([loadSecretRequests(), loadEvents()]);
+    } catch (e) {
+      alert("Failed to " + action + " secret request: " + e.message);
+    }
+  }
+
+  async function decideOperation(op, action) {
+    var opts = { method: "POST" };
+    if (action === "approve") {
+      if (!confirm("Approve " + op.method + " " + op.path + " requested by " + op.requested_by + "?")) return;
+    } else {
+      var reason = prompt("Reject " + op.method + " " + op.path + "? Reason (optional):");
+      if (reason === null) return;
+      opts.headers = { "Content-Type": "application/json" };
+      opts.body = JSON.stringify({ reason: reason });
+    }
+    try {
+      var res = await api("/v1/approvals/" + encodeURIComponent(op.id) + "/" + action, opts);
+      var result = await res.json();
+      if (result.status === "failed") {
+        alert("Operation " + op.id + " ran but failed with status " + result.result_status + ".");
+      }
+      await Promise.all([loadOperationApprovals(), loadSandboxes(), loadEvents()]);
+    } catch (e) {
+      alert("Failed to " + action + " operation: " + e.message);
+    }
+  }
+
+  async function answerQuestion(id, text) {
+    text = (text || "").trim();
+    if (!text) return;
+    try {
+      await api("/v1/messages/" + encodeURIComponent(id) + "/reply", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ text: text }),
+      });
+      await Promise.all([loadQuestions(), loadEvents()]);
+    } catch (e) {
+      alert("Failed to answer question: " + e.message);
+    }
+  }
+
+  // --- Web terminal ---
+  //
+  // The terminal talks to /api/v1/sandboxes/{vmid}/terminal over a WebSocket:
+  // binary messages carry terminal bytes both ways, text messages carry JSON
+  // control (resize from us; error and exit from the server). The browser
+  // cannot set an Authorization header on a WebSocket, so the token rides as
+  // a "bearer.<token>" subprotocol next to "agentlab.terminal.v1".
+  //
+  // createTerminalScreen is a small VT100 subset: printable text, CR/LF/BS/TAB,
+  // cursor movement, erase in line/display, and scrolling. Escape sequences
+  // it does not know (colors, modes, titles) are consumed and ignored. The
+  // screen is rendered with textContent, so output can never inject markup.
+
+  var TERMINAL_PROTOCOL = "agentlab.terminal.v1";
+  var terminal = null;
+
+  function createTerminalScreen(cols, rows) {
+    var lines = [];
+    var cx = 0;
+    var cy = 0;
+    var state = "normal";
+    var params = "";
+
+    function blankLine() {
+      var line = [];
+      for (var i = 0; i < cols; i++) line.push(" ");
+      return line;
+    }
+
+    function reset() {
+      lines = [];
+      for (var i = 0; i < rows; i++) lines.push(blankLine());
+      cx = 0;
+      cy = 0;
+    }
+
+    function lineFeed() {
+      cy++;
+      if (cy >= rows) {
+        lines.shift();
+        lines.push(blankLine());
+        cy = rows - 1;
+      }
+    }
+
+    function clampCursor() {
+      cx = Math.max(0, Math.min(cols - 1, cx));
+      cy = Math.max(0, Math.min(rows - 1, cy));
+    }
+
+    function eraseLine(row, from, to) {
+      for (var i = from; i < to; i++) lines[row][i] = " ";
+    }
+
+    function csi(final, raw) {
+      var priv = raw.charAt(0) === "?";
+      var args = (priv ? raw.slice(1) : raw).split(";").map(function (p) {
+        return parseInt(p, 10);
+      });
+      var n = args[0] > 0 ? args[0] : 1;
+      var i;
+      switch (final) {
+        case "A": cy -= n; break;
+        case "B": cy += n; break;
+        case "C": cx += n; break;
+        case "D": cx -= n; break;
+        case "G": cx = n - 1; break;
+        case "d": cy = n - 1; break;
+        case "H":
+        case "f":
+          cy = n - 1;
+          cx = args[1] > 0 ? args[1] - 1 : 0;
+          break;
+        case "J":
+          if (args[0] === 2 || args[0] === 3) {
+            for (i = 0; i < rows; i++) lines[i] = blankLine();
+          } else if (args[0] === 1) {
+            for (i = 0; i < cy; i++) lines[i] = blankLine();
+            eraseLine(cy, 0, cx + 1);
+          } else {
+            eraseLine(cy, cx, cols);
+            for (i = cy + 1; i < rows; i++) lines[i] = blankLine();
+          }
+          break;
+        case "K":
+          if (args[0] === 2) eraseLine(cy, 0, cols);
+          else if (args[0] === 1) eraseLine(cy, 0, cx + 1);
+          else eraseLine(cy, cx, cols);
+          break;
+        case "X":
+          eraseLine(cy, cx, Math.min(cols, cx + n));
+          break;
+        case "P":
+          lines[cy].splice(cx, n);
+          while (lines[cy].length < cols) lines[cy].push(" ");
+          break;
+        case "@":
+          for (i = 0; i < n; i++) lines[cy].splice(cx, 0, " ");
+          lines[cy].length = cols;
+          break;
+        case "h":
+        case "l":
+          // Entering or leaving the alternate screen starts from a clean one.
+          if (priv && (args[0] === 1049 || args[0] === 47)) reset();
+          break;
+      }
+      clampCursor();
+    }
+
+    function put(ch) {
+      if (cx >= cols) {
+        cx = 0;
+        lineFeed();
+      }
+      lines[cy][cx] = ch;
+      cx++;
+    }
+
+    function write(text) {
+      for (var k = 0; k < text.length; k++) {
+        var ch = text.charAt(k);
+        var code = text.charCodeAt(k);
+        if (state === "esc") {
+          if (ch === "[") {
+            state = "csi";
+            params = "";
+          } else if (ch === "]") {
+            state = "osc";
+          } else if (ch === "(" || ch === ")") {
+            state = "charset";
+          } else {
+            state = "normal";
+          }
+          continue;
+        }
+        if (state === "charset") {
+          state = "normal";
+          continue;
+        }
+        if (state === "csi") {
+          if (code >= 0x30 && code <= 0x3f) {
+            params += ch;
+          } else if (code >= 0x40 && code <= 0x7e) {
+            csi(ch, params);
+            state = "normal";
+          }
+          continue;
+        }
+        if (state === "osc") {
+          if (code === 0x07) state = "normal";
+          else if (code === 0x1b) state = "esc";
+          continue;
+        }
+        switch (code) {
+          case 0x1b: state = "esc"; break;
+          case 0x0d: cx = 0; break;
+          case 0x0a: lineFeed(); break;
+          case 0x08: if (cx > 0) cx--; break;
+          case 0x09: cx = Math.min(cols - 1, (Math.floor(cx / 8) + 1) * 8); break;
+          default:
+            if (code >= 0x20) put(ch);
+        }
+      }
+    }
+
+    function resize(newCols, newRows) {
+      var old = lines;
+      cols = newCols;
+      rows = newRows;
+      lines = [];
+      var start = Math.max(0, old.length - rows);
+      for (var i = 0; i < rows; i++) {
+        var line = blankLine();
+        var src = old[start + i] || [];
+        for (var j = 0; j < cols && j < src.length; j++) line[j] = src[j];
+        lines.push(line);
+      }
+      cy = Math.max(0, Math.min(rows - 1, cy - start));
+      clampCursor();
+    }
+
+    // render writes the screen into pre as text, with the cursor cell in a
+    // span so it can be styled.
+    function render(pre) {
+      var before = [];
+      for (var i = 0; i < cy; i++) before.push(lines[i].join(""));
+      var row = lines[cy];
+      var head = before.join("\n") + (cy > 0 ? "\n" : "") + row.slice(0, cx).join("");
+      var cursor = document.createElement("span");
+      cursor.className = "terminal-cursor";
+      cursor.textContent = cx < cols ? row[cx] : " ";
+      var after = [row.slice(cx + 1).join("")];
+      for (i = cy + 1; i < rows; i++) after.push(lines[i].join(""));
+      pre.textContent = "";
+      pre.appendChild(document.createTextNode(head));
+      pre.appendChild(cursor);
+      pre.appendChild(document.createTextNode(after.join("\n")));
+    }
+
+    reset();
+    return { write: write, resize: resize, render: render };
+  }
+
+  // terminalKeyBytes maps a keydown to the bytes an xterm would send, or ""
+  // when the browser should handle the key.
+  function terminalKeyBytes(e) {
+    if (e.metaKey) return "";
+    var special = {
+      Enter: "\r",
+      Backspace: "\x7f",
+      Tab: "\t",
+      Escape: "\x1b",
+      ArrowUp: "\x1b[A",
+      ArrowDown: "\x1b[B",
+      ArrowRight: "\x1b[C",
+      ArrowLeft: "\x1b[D",
+      Home: "\x1b[H",
+      End: "\x1b[F",
+      Delete: "\x1b[3~",
+      PageUp: "\x1b[5~",
+      PageDown: "\x1b[6~",
+    };
+    if (special[e.key]) return special[e.key];
+    if (e.key.length !== 1) return "";
+    if (e.ctrlKey) {
+      var c = e.key.toUpperCase().charCodeAt(0);
+      if (c >= 0x40 && c <= 0x5f) return String.fromCharCode(c - 0x40);
+      return "";
+    }
+    return e.altKey ? "\x1b" + e.key : e.key;
+  }
+
+  // terminalSize measures how many character cells fit in the pane.
+  function terminalSize(pre) {
+    var probe = document.createElement("span");
+    probe.textContent = "MMMMMMMMMM";
+    pre.appendChild(probe);
+    var cellW = probe.getBoundingClientRect().width / 10 || 8;
+    var cellH = probe.getBoundingClientRect().height || 16;
+    pre.removeChild(probe);
+    return {
+      cols: Math.max(20, Math.floor(pre.clientWidth / cellW)),
+      rows: Math.max(5, Math.floor(pre.clientHeight / cellH)),
+    };
+  }
+
+  function setTerminalStatus(text) {
+    document.getElementById("terminal-status").textContent = text;
+  }
+
+  function openTerminal(vmid) {
+    closeTerminal();
+    var tok = dashboardToken();
+    if (!tok && sso.enabled && !sso.signedIn) {
+      signIn();
+      return;
+    }
+    if (!tok && !sso.signedIn) {
+      tok = window.prompt("Dashboard access token:") || "";
+      if (!tok) return;
+      setDashboardToken(tok);
+    }
+    document.getElementById("terminal-title").textContent = "Terminal — sandbox " + vmid;
+    document.getElementById("modal-terminal").style.display = "flex";
+    var pre = document.getElementById("terminal-screen");
+    var size = terminalSize(pre);
+    var screen = createTerminalScreen(size.cols, size.rows);
+    screen.render(pre);
+    setTerminalStatus("Connecting…");
+
+    var scheme = window.location.protocol === "https:" ? "wss:" : "ws:";
+    var url = scheme + "//" + window.location.host + "/api/v1/sandboxes/" +
+      encodeURIComponent(vmid) + "/terminal?cols=" + size.cols + "&rows=" + size.rows;
+    // A signed-in browser authenticates with its session cookie instead.
+    var ws = new WebSocket(url, tok ? [TERMINAL_PROTOCOL, "bearer." + tok] : [TERMINAL_PROTOCOL]);
+    ws.binaryType = "arraybuffer";
+    var decoder = new TextDecoder();
+    var encoder = new TextEncoder();
+    var pending = false;
+    var t = { ws: ws, screen: screen, pre: pre, size: size, ended: false };
+    terminal = t;
+
+    function scheduleRender() {
+      if (pending) return;
+      pending = true;
+      window.requestAnimationFrame(function () {
+        pending = false;
+        screen.render(pre);
+      });
+    }
+
+    t.send = function (text) {
+      if (ws.readyState === WebSocket.OPEN) ws.send(encoder.encode(text));
+    };
+
+    ws.addEventListener("open", function () {
+      setTerminalStatus("Connected to sandbox " + vmid);
+      pre.focus();
+    });
+    ws.addEventListener("message", function (ev) {
+      if (typeof ev.data === "string") {
+        var ctl = {};
+        try {
+          ctl = JSON.parse(ev.data);
+        } catch (e) {
+          return;
+        }
+        t.ended = true;
+        if (ctl.type === "exit") setTerminalStatus("Shell exited with status " + ctl.status);
+        else if (ctl.type === "error") setTerminalStatus("Error: " + ctl.message);
+        return;
+      }
+      screen.write(decoder.decode(new Uint8Array(ev.data), { stream: true }));
+      scheduleRender();
+    });
+    ws.addEventListener("close", function () {
+      if (terminal === t && !t.ended) setTerminalStatus("Disconnected");
+    });
+  }
+
+  function closeTerminal() {
+    if (!terminal) return;
+    var t = terminal;
+    terminal = null;
+    t.ended = true;
+    if (t.stop) {
+      t.stop();
+      return;
+    }
+    try {
+      t.ws.close();
+    } catch (e) {
+      /* already closed */
+    }
+  }
+
+  function resizeTerminal() {
+    // A recording plays at the size it was recorded with.
+    if (!terminal || terminal.stop) return;
+    var size = terminalSize(terminal.pre);
+    if (size.cols === terminal.size.cols && size.rows === terminal.size.rows) return;
+    terminal.size = size;
+    terminal.screen.resize(size.cols, size.rows);
+    terminal.screen.render(terminal.pre);
+    if (terminal.ws.readyState === WebSocket.OPEN) {
+      terminal.ws.send(JSON.stringify({ type: "resize", cols: size.cols, rows: size.rows }));
+    }
+  }
+
+  function initTerminal() {
+    var pre = document.getElementById("terminal-screen");
+    pre.addEventListener("keydown", function (e) {
+      if (!terminal) return;
+      var bytes = terminalKeyBytes(e);
+      if (!bytes) return;
+      e.preventDefault();
+      terminal.send(bytes);
+    });
+    pre.addEventListener("paste", function (e) {
+      if (!terminal) return;
+      e.preventDefault();
+      terminal.send((e.clipboardData || window.clipboardData).getData("text"));
+    });
+    window.addEventListener("resize", resizeTerminal);
+  }
+
+  // --- Resource usage ---
+  //
+  // The daemon samples running sandboxes and keeps downsampled history. Each
+  // series is drawn as an SVG polyline built with createElementNS, so no
+  // markup or inline style is generated from the data.
+
+  var SVG_NS = "http://www.w3.org/2000/svg";
+  var SPARKLINE_WIDTH = 240;
+  var SPARKLINE_HEIGHT = 32;
+
+  // usageVMID is the sandbox shown in the detail modal, for range changes.
+  var usageVMID = null;
+
+  var USAGE_SERIES = [
+    {
+      label: "CPU",
+      value: function (p) {
+        return p.cpu_avg;
+      },
+      format: function (v) {
+        return (v * 100).toFixed(1) + "%";
+      },
+    },
+    {
+      label: "Memory",
+      value: function (p) {
+        return p.memory_used_bytes;
+      },
+      format: formatBytes,
+    },
+    {
+      label: "Disk I/O",
+      value: function (p) {
+        return p.disk_read_bps + p.disk_write_bps;
+      },
+      format: function (v) {
+        return formatBytes(Math.round(v)) + "/s";
+      },
+    },
+    {
+      label: "Network",
+      value: function (p) {
+        return p.net_in_bps + p.net_out_bps;
+      },
+      format: function (v) {
+        return formatBytes(Math.round(v)) + "/s";
+      },
+    },
+  ];
+
+  async function loadUsage(vmid) {
+    var range = document.getElementById("detail-usage-range").value;
+    try {
+      var data = await apiJSON(
+        "/v1/sandboxes/" + vmid + "/usage?range=" + encodeURIComponent(range)
+      );
+      // Ignore a response for a sandbox the modal no longer shows.
+      if (usageVMID !== vmid) return;
+      renderUsage((data && data.points) || []);
+    } catch (e) {
+      if (usageVMID === vmid) renderUsage(null);
+    }
+  }
+
+  // renderUsage draws one sparkline per series with its latest value; a
+  // null list hides the section.
+  function renderUsage(points) {
+    var section = document.getElementById("detail-usage");
+    var ul = document.getElementById("detail-usage-list");
+    ul.textContent = "";
+    if (!points) {
+      section.style.display = "none";
+      return;
+    }
+    if (points.length === 0) {
+      var empty = document.createElement("li");
+      empty.className = "usage-empty";
+      empty.textContent = "No samples in this range yet.";
+      ul.appendChild(empty);
+      section.style.display = "block";
+      return;
+    }
+    USAGE_SERIES.forEach(function (series) {
+      var values = points.map(function (p) {
+        return Number(series.value(p)) || 0;
+      });
+      var li = document.createElement("li");
+      var label = document.createElement("span");
+      label.className = "usage-label";
+      label.textContent = series.label;
+      li.appendChild(label);
+      li.appendChild(sparkline(values));
+      var latest = document.createElement("span");
+      latest.className = "diff-counts";
+      latest.textContent = series.format(values[values.length - 1]);
+      li.appendChild(latest);
+      ul.appendChild(li);
+    });
+    section.style.display = "block";
+  }
+
+  // sparkline returns an SVG element plotting values scaled to their max.
+  function sparkline(values) {
+    var svg = document.createElementNS(SVG_NS, "svg");
+    svg.setAttribute("class", "sparkline");
+    svg.setAttribute("width", String(SPARKLINE_WIDTH));
+    svg.setAttribute("height", String(SPARKLINE_HEIGHT));
+    svg.setAttribute(
+      "viewBox",
+      "0 0 " + SPARKLINE_WIDTH + " " + SPARKLINE_HEIGHT
+    );
+    var max = Math.max.apply(null, values.concat([0]));
+    var step = values.length > 1 ? SPARKLINE_WIDTH / (values.length - 1) : 0;
+    var coords = values.map(function (v, i) {
+      var y =
+        max > 0
+          ? (1 - v / max) * (SPARKLINE_HEIGHT - 2) + 1
+          : SPARKLINE_HEIGHT - 1;
+      return (i * step).toFixed(1) + "," + y.toFixed(1);
+    });
+    if (coords.length === 1) {
+      coords.push(SPARKLINE_WIDTH + "," + coords[0].split(",")[1]);
+    }
+    var line = document.createElementNS(SVG_NS, "polyline");
+    line.setAttribute("points", coords.join(" "));
+    svg.appendChild(line);
+    return svg;
+  }
+
+  function initUsageRange() {
+    document
+      .getElementById("detail-usage-range")
+      .addEventListener("change", function () {
+        if (usageVMID !== null) loadUsage(usageVMID);
+      });
+  }
+
+  // --- Session recordings ---
+  //
+  // SSH gateway sessions recorded with --record are asciicast v2 artifacts of
+  // the sandbox. Playback reuses the terminal modal and screen: output events
+  // are written with their recorded timing, resize events resize the screen,
+  // and input events are skipped since their echo is already in the output.
+
+  // RECORDING_MAX_IDLE_MS caps pauses so idle stretches do not replay in full.
+  var RECORDING_MAX_IDLE_MS = 2000;
+
+  async function loadRecordings(vmid) {
+    try {
+      var data = await apiJSON("/v1/sandboxes/" + vmid + "/recordings");
+      renderRecordings(vmid, (data && data.recordings) || []);
+    } catch (e) {
+      renderRecordings(vmid, null);
+    }
+  }
+
+  // renderRecordings lists a sandbox's recordings in the detail modal; a
+  // null list hides the section. User names come from SSH key comments, so
+  // every value is set as text.
+  function renderRecordings(vmid, list) {
+    var section = document.getElementById("detail-recordings");
+    var ul = document.getElementById("detail-recordings-list");
+    ul.textContent = "";
+    if (!list || list.length === 0) {
+      section.style.display = "none";
+      return;
+    }
+    document.getElementById("detail-recordings-summary").textContent =
+      "Session recordings (" + list.length + ")";
+    list.forEach(function (rec) {
+      var li = document.createElement("li");
+      var when = document.createElement("span");
+      when.textContent = timeAgo(rec.created_at);
+      li.appendChild(when);
+      var user = document.createElement("span");
+      user.className = "recording-user";
+      user.textContent = rec.user || "-";
+      li.appendChild(user);
+      var size = document.createElement("span");
+      size.className = "diff-counts";
+      size.textContent = formatBytes(rec.size_bytes);
+      li.appendChild(size);
+      var id = rec.id;
+      addActionButton(li, "Play", "btn btn-sm", function () {
+        playRecording(vmid, id);
+      });
+      ul.appendChild(li);
+    });
+    section.style.display = "block";
+  }
+
+  function formatBytes(n) {
+    if (!n) return "0 B";
+    if (n < 1024) return n + " B";
+    if (n < 1024 * 1024) return (n / 1024).toFixed(1) + " KiB";
+    return (n / (1024 * 1024)).toFixed(1) + " MiB";
+  }
+
+  // parseAsciicast splits an asciicast v2 file into its header and events.
+  // Malformed event lines are dropped rather than aborting playback.
+  function parseAsciicast(text) {
+    var lines = text.split("\n");
+    var header = JSON.parse(lines[0]);
+    if (!header || header.version !== 2) {
+      throw new Error("not an asciicast v2 recording");
+    }
+    var events = [];
+    for (var i = 1; i < lines.length; i++) {
+      if (!lines[i].trim()) continue;
+      try {
+        var ev = JSON.parse(lines[i]);
+        if (Array.isArray(ev) && ev.length === 3) events.push(ev);
+      } catch (e) {
+        /* skip malformed line */
+      }
+    }
+    return { header: header, events: events };
+  }
+
+  async function playRecording(vmid, id) {
+    closeTerminal();
+    var cast;
+    try {
+      var res = await api("/v1/sandboxes/" + vmid + "/recordings/" + id);
+      if (res.status === 404) throw new Error("recording not found");
+      cast = parseAsciicast(await res.text());
+    } catch (e) {
+      alert("Failed to load recording: " + e.message);
+      return;
+    }
+    document.getElementById("terminal-title").textContent =
+      "Recording " + id + " — sandbox " + vmid;
+    document.getElementById("modal-terminal").style.display = "flex";
+    var pre = document.getElementById("terminal-screen");
+    var size = { cols: cast.header.width || 80, rows: cast.header.height || 24 };
+    var screen = createTerminalScreen(size.cols, size.rows);
+    screen.render(pre);
+    setTerminalStatus("Playing");
+
+    var timer = null;
+    var t = { screen: screen, pre: pre, size: size, ended: false, send: function () {} };
+    t.stop = function () {
+      if (timer) window.clearTimeout(timer);
+    };
+    terminal = t;
+
+    var next = 0;
+    var last = 0;
+    function step() {
+      if (terminal !== t) return;
+      while (next < cast.events.length) {
+        var ev = cast.events[next];
+        var wait = (ev[0] - last) * 1000;
+        if (wait > 0) {
+          last = ev[0];
+          screen.render(pre);
+          timer = window.setTimeout(step, Math.min(wait, RECORDING_MAX_IDLE_MS));
+          return;
+        }
+        next++;
+        if (ev[1] === "o") {
+          screen.write(String(ev[2]));
+        } else if (ev[1] === "r") {
+          var dims = String(ev[2]).split("x");
+          var cols = parseInt(dims[0], 10);
+          var rows = parseInt(dims[1], 10);
+          if (cols > 0 && rows > 0) screen.resize(cols, rows);
+        }
+      }
+      screen.render(pre);
+      t.ended = true;
+      setTerminalStatus("Playback finished");
+    }
+    step();
+  }
+
   function closeModal(id) {
+    if (id === "modal-terminal") closeTerminal();
     document.getElementById(id).style.display = "none";
   }
 
@@ -860,7 +1838,7 @@
     // Close modal on backdrop click.
     document.querySelectorAll(".modal-backdrop").forEach(function (el) {
       el.addEventListener("click", function () {
-        el.parentElement.style.display = "none";
+        closeModal(el.parentElement.id);
       });
     });
   }
@@ -962,6 +1940,8 @@
     initExposeForm();
     initSnapshotForm();
     initBulkActions();
+    initTerminal();
+    initUsageRange();
 
     // Modal cancel/close buttons declare data-close-modal instead of inline
     // onclick handlers (the CSP forbids inline handlers).
@@ -972,18 +1952,24 @@
     });
 
     document.getElementById("btn-refresh").addEventListener("click", refreshAll);
+    document.getElementById("btn-login").addEventListener("click", signIn);
+    document.getElementById("btn-logout").addEventListener("click", logout);
 
-    // Load profiles for the form.
-    loadProfiles();
+    // Learn whether single sign-on is on before the first API call, so a
+    // 401 signs in rather than prompting for the token.
+    loadSession().then(function () {
+      // Load profiles for the form.
+      loadProfiles();
 
-    // Load host info once.
-    loadHostInfo();
+      // Load host info once.
+      loadHostInfo();
 
-    // Initial load.
-    refreshAll();
+      // Initial load.
+      refreshAll();
 
-    // Auto-refresh.
-    refreshTimer = setInterval(refreshAll, REFRESH_INTERVAL);
+      // Auto-refresh.
+      refreshTimer = setInterval(refreshAll, REFRESH_INTERVAL);
+    });
   }
 
   document.addEventListener("DOMContentLoaded", init);
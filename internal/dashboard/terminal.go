@@ -0,0 +1,491 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/recording"
+	"github.com/agentlab/agentlab/internal/sandboxssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// The web terminal is a WebSocket at GET /api/v1/sandboxes/{vmid}/terminal.
+// Binary messages carry terminal bytes in both directions. The browser sends
+// text messages for control ({"type":"resize","cols":C,"rows":R}); the
+// dashboard sends text messages for {"type":"error","message":...} and
+// {"type":"exit","status":N} just before it closes the socket.
+//
+// Browsers cannot set headers on a WebSocket handshake, so the page offers
+// the browser token as a second subprotocol, "bearer.<token>", next to
+// terminalProtocol. Only terminalProtocol is echoed back.
+const (
+	terminalProtocol    = "agentlab.terminal.v1"
+	terminalTokenPrefix = "bearer."
+)
+
+const (
+	defaultTerminalIdleTimeout = 15 * time.Minute
+	defaultTerminalUser        = "agent"
+	defaultTerminalPort        = 22
+	// terminalDialTimeout bounds how long the dashboard keeps retrying a
+	// sandbox SSH server that is not accepting connections yet.
+	terminalDialTimeout = 30 * time.Second
+	defaultTerminalCols = 80
+	defaultTerminalRows = 24
+	maxTerminalDim      = 1000
+	// terminalUploadTimeout bounds uploading a finished recording.
+	terminalUploadTimeout = time.Minute
+	// recordingContentType is the media type asciinema registers for
+	// asciicast files.
+	recordingContentType = "application/x-asciicast"
+)
+
+// terminalTarget is the sandbox a terminal connects to.
+type terminalTarget struct {
+	VMID      int    `json:"vmid"`
+	State     string `json:"state"`
+	IP        string `json:"ip"`
+	CreatedAt string `json:"created_at"`
+}
+
+// terminalBackend opens interactive shells on sandboxes.
+type terminalBackend interface {
+	open(ctx context.Context, target terminalTarget, cols, rows int) (terminalSession, error)
+}
+
+// terminalSession is one interactive shell. Read returns the combined
+// stdout and stderr and io.EOF when the shell exits; Write sends stdin.
+type terminalSession interface {
+	io.ReadWriteCloser
+	resize(cols, rows int) error
+	// exitStatus blocks until the shell has exited and returns its status.
+	exitStatus() int
+}
+
+// sshTerminal reaches sandboxes the same way agentlab-ssh-gateway does: with
+// the operator's sandbox key and per-VMID host key pinning (review M7).
+type sshTerminal struct {
+	signer ssh.Signer
+	user   string
+	port   int
+	pins   *sandboxssh.HostKeyPins
+	logger *log.Logger
+
+	mu sync.Mutex
+	// created remembers each VMID's creation time when its key was pinned.
+	// A destroyed and recreated sandbox reuses the VMID with a new host key,
+	// so the pin is rotated when the creation time changes.
+	created map[int]string
+}
+
+func newSSHTerminal(signer ssh.Signer, user string, port int, logger *log.Logger) *sshTerminal {
+	if user == "" {
+		user = defaultTerminalUser
+	}
+	if port <= 0 {
+		port = defaultTerminalPort
+	}
+	return &sshTerminal{
+		signer:  signer,
+		user:    user,
+		port:    port,
+		pins:    sandboxssh.NewHostKeyPins(),
+		logger:  logger,
+		created: make(map[int]string),
+	}
+}
+
+func (t *sshTerminal) open(ctx context.Context, target terminalTarget, cols, rows int) (terminalSession, error) {
+	t.mu.Lock()
+	if seen, ok := t.created[target.VMID]; ok && seen != target.CreatedAt {
+		t.pins.Rotate(target.VMID)
+	}
+	t.created[target.VMID] = target.CreatedAt
+	t.mu.Unlock()
+
+	dialCtx, cancel := context.WithTimeout(ctx, terminalDialTimeout)
+	defer cancel()
+	client, err := sandboxssh.Dial(dialCtx, target.IP, t.port, t.user, t.signer, target.VMID, t.pins, t.logger)
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	session.Stdout = pw
+	session.Stderr = pw
+	modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 14400, ssh.TTY_OP_OSPEED: 14400}
+	if err := session.RequestPty("xterm-256color", rows, cols, modes); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("request pty: %w", err)
+	}
+	if err := session.Shell(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+	s := &sshTerminalSession{client: client, session: session, stdin: stdin, out: pr, done: make(chan struct{})}
+	go func() {
+		err := session.Wait()
+		s.status = 0
+		if err != nil {
+			s.status = 1
+			var exitErr *ssh.ExitError
+			if errors.As(err, &exitErr) {
+				s.status = exitErr.ExitStatus()
+			}
+		}
+		_ = pw.Close()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+type sshTerminalSession struct {
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	out     *io.PipeReader
+	done    chan struct{}
+	status  int
+}
+
+func (s *sshTerminalSession) Read(p []byte) (int, error)  { return s.out.Read(p) }
+func (s *sshTerminalSession) Write(p []byte) (int, error) { return s.stdin.Write(p) }
+
+func (s *sshTerminalSession) resize(cols, rows int) error {
+	return s.session.WindowChange(rows, cols)
+}
+
+func (s *sshTerminalSession) exitStatus() int {
+	<-s.done
+	return s.status
+}
+
+func (s *sshTerminalSession) Close() error {
+	_ = s.session.Close()
+	return s.client.Close()
+}
+
+// terminalControl is a text message on the terminal socket.
+type terminalControl struct {
+	Type    string `json:"type"`
+	Cols    int    `json:"cols,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Status  *int   `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleTerminal serves the web terminal WebSocket for one sandbox. Failures
+// before the upgrade are plain HTTP errors; failures after it are reported as
+// an error message on the socket, since a browser cannot read the body of a
+// failed handshake.
+func (s *Server) handleTerminal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	vmid, err := strconv.Atoi(r.PathValue("vmid"))
+	if err != nil || vmid <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid vmid"})
+		return
+	}
+	if s.terminal == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "web terminal disabled; start agentlab-dashboard with --sandbox-key"})
+		return
+	}
+	if !slices.Contains(websocketProtocols(r), terminalProtocol) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "websocket subprotocol " + terminalProtocol + " required"})
+		return
+	}
+	cols := terminalDim(r.URL.Query().Get("cols"), defaultTerminalCols)
+	rows := terminalDim(r.URL.Query().Get("rows"), defaultTerminalRows)
+
+	ws, err := upgradeWebSocket(w, r, terminalProtocol)
+	if err != nil {
+		s.logger.Printf("dashboard: terminal upgrade for sandbox %d failed: %v", vmid, err)
+		return
+	}
+	defer ws.Close()
+
+	fail := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		s.logger.Printf("dashboard: terminal for sandbox %d: %s", vmid, msg)
+		writeTerminalControl(ws, terminalControl{Type: "error", Message: msg})
+	}
+
+	// The request context ends when the dashboard shuts down (BaseContext)
+	// or when this handler returns.
+	ctx := r.Context()
+	var target terminalTarget
+	if err := s.daemonGet(ctx, "/v1/sandboxes/"+strconv.Itoa(vmid), &target); err != nil {
+		fail("look up sandbox: %v", err)
+		return
+	}
+	if err := s.checkTerminalAccess(ctx, vmid); err != nil {
+		fail("%v", err)
+		return
+	}
+	state := models.SandboxState(strings.ToUpper(target.State))
+	if (state != models.SandboxRunning && state != models.SandboxReady) || strings.TrimSpace(target.IP) == "" {
+		fail("sandbox %d is %s; start it before opening a terminal", vmid, strings.ToLower(orUnknown(target.State)))
+		return
+	}
+	sess, err := s.terminal.open(ctx, target, cols, rows)
+	if err != nil {
+		fail("connect: %v", err)
+		return
+	}
+	defer sess.Close()
+
+	rec, err := s.openTerminalRecording(vmid, cols, rows)
+	if err != nil {
+		fail("start recording: %v", err)
+		return
+	}
+	if rec != nil {
+		defer s.uploadTerminalRecording(rec, vmid, terminalUser(ctx))
+	}
+
+	started := time.Now()
+	s.logger.Printf("dashboard: terminal opened for sandbox %d from %s%s", vmid, r.RemoteAddr, recordingNote(rec))
+	defer func() {
+		s.logger.Printf("dashboard: terminal for sandbox %d closed after %s", vmid, time.Since(started).Round(time.Second))
+	}()
+
+	var lastActivity atomic.Int64
+	touch := func() { lastActivity.Store(time.Now().UnixNano()) }
+	touch()
+
+	// Output pump: sandbox -> browser. When the shell exits, report its status
+	// and close the socket, which ends the input loop below.
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := sess.Read(buf)
+			if n > 0 {
+				touch()
+				if rec != nil {
+					_ = rec.rec.Output(buf[:n])
+				}
+				if werr := ws.WriteMessage(wsOpBinary, buf[:n]); werr != nil {
+					_ = sess.Close()
+					return
+				}
+			}
+			if err != nil {
+				status := sess.exitStatus()
+				writeTerminalControl(ws, terminalControl{Type: "exit", Status: &status})
+				_ = ws.Close()
+				return
+			}
+		}
+	}()
+
+	// Idle watchdog and shutdown: either closes both ends.
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go func() {
+		idle := s.terminalIdle
+		interval := idle / 4
+		if interval < 10*time.Millisecond {
+			interval = 10 * time.Millisecond
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watchDone:
+				return
+			case <-ctx.Done():
+				writeTerminalControl(ws, terminalControl{Type: "error", Message: "dashboard is shutting down"})
+				_ = sess.Close()
+				_ = ws.Close()
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, lastActivity.Load())) > idle {
+					writeTerminalControl(ws, terminalControl{Type: "error", Message: fmt.Sprintf("closed after %s of inactivity", idle)})
+					s.logger.Printf("dashboard: closing idle terminal for sandbox %d after %s", vmid, idle)
+					_ = sess.Close()
+					_ = ws.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	// Input loop: browser -> sandbox.
+input:
+	for {
+		op, data, err := ws.ReadMessage()
+		if err != nil {
+			break input
+		}
+		touch()
+		switch op {
+		case wsOpBinary:
+			if rec != nil {
+				_ = rec.rec.Input(data)
+			}
+			if _, err := sess.Write(data); err != nil {
+				break input
+			}
+		case wsOpText:
+			var ctl terminalControl
+			if json.Unmarshal(data, &ctl) != nil || ctl.Type != "resize" {
+				continue
+			}
+			c, rw := clampTerminalDim(ctl.Cols), clampTerminalDim(ctl.Rows)
+			if c == 0 || rw == 0 {
+				continue
+			}
+			_ = sess.resize(c, rw)
+			if rec != nil {
+				_ = rec.rec.Resize(c, rw)
+			}
+		}
+	}
+	_ = sess.Close()
+	<-outputDone
+}
+
+// terminalRecording is a web terminal session being spooled to an asciicast
+// v2 file. When the session ends the file is uploaded to the daemon, which
+// stores it as a recording artifact of the sandbox.
+type terminalRecording struct {
+	file *os.File
+	rec  *recording.Writer
+}
+
+// openTerminalRecording starts spooling a recording under --record-spool
+// (the system temp dir when empty). It returns nil when --record is off.
+func (s *Server) openTerminalRecording(vmid, cols, rows int) (*terminalRecording, error) {
+	if !s.record {
+		return nil, nil
+	}
+	file, err := os.CreateTemp(s.recordSpool, fmt.Sprintf("agentlab-terminal-%d-*.cast", vmid))
+	if err != nil {
+		return nil, err
+	}
+	rec, err := recording.NewWriter(file, recording.Header{
+		Width:     cols,
+		Height:    rows,
+		Timestamp: time.Now().Unix(),
+		Title:     fmt.Sprintf("sandbox %d (dashboard terminal)", vmid),
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+	return &terminalRecording{file: file, rec: rec}, nil
+}
+
+// uploadTerminalRecording sends a finished recording to the daemon with the
+// dashboard's own token, attributed to user, and removes the spool file. On
+// failure the file is kept so the audit trail is not lost, and its path is
+// logged.
+func (s *Server) uploadTerminalRecording(tr *terminalRecording, vmid int, user string) {
+	path := tr.file.Name()
+	if err := tr.file.Close(); err != nil {
+		s.logger.Printf("dashboard: terminal for sandbox %d: close recording %s: %v", vmid, path, err)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		s.logger.Printf("dashboard: terminal for sandbox %d: open recording %s: %v", vmid, path, err)
+		return
+	}
+	defer file.Close()
+	endpoint := "/v1/sandboxes/" + strconv.Itoa(vmid) + "/recordings"
+	if user != "" {
+		endpoint += "?" + url.Values{"user": {user}}.Encode()
+	}
+	// The request context is gone by now; the upload gets its own deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), terminalUploadTimeout)
+	defer cancel()
+	if err := s.daemonSend(ctx, s.token, http.MethodPost, endpoint, recordingContentType, file, nil); err != nil {
+		s.logger.Printf("dashboard: terminal for sandbox %d: upload recording (kept at %s): %v", vmid, path, err)
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// terminalUser names whose session a recording is: the signed-in user for a
+// single sign-on session, nobody for the shared browser token.
+func terminalUser(ctx context.Context) string {
+	if sess := sessionFromContext(ctx); sess != nil {
+		if sess.User != "" {
+			return sess.User
+		}
+		return sess.UserID
+	}
+	return ""
+}
+
+func recordingNote(rec *terminalRecording) string {
+	if rec == nil {
+		return ""
+	}
+	return " (recording)"
+}
+
+func writeTerminalControl(ws *wsConn, ctl terminalControl) {
+	data, err := json.Marshal(ctl)
+	if err != nil {
+		return
+	}
+	_ = ws.WriteMessage(wsOpText, data)
+}
+
+// terminalDim parses a cols or rows query value, falling back to def.
+func terminalDim(raw string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return def
+	}
+	if n = clampTerminalDim(n); n == 0 {
+		return def
+	}
+	return n
+}
+
+// clampTerminalDim bounds a terminal dimension; it returns 0 for a
+// non-positive value.
+func clampTerminalDim(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return min(n, maxTerminalDim)
+}
+
+func orUnknown(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "unknown"
+	}
+	return s
+}
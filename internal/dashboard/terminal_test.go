@@ -0,0 +1,417 @@
+package dashboard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/recording"
+)
+
+// fakeShell echoes input back as output and exits with status 3 on "exit\r".
+type fakeShell struct {
+	out  *io.PipeReader
+	pw   *io.PipeWriter
+	mu   sync.Mutex
+	cols int
+	rows int
+}
+
+func (f *fakeShell) Read(p []byte) (int, error) { return f.out.Read(p) }
+
+func (f *fakeShell) Write(p []byte) (int, error) {
+	if string(p) == "exit\r" {
+		_ = f.pw.Close()
+		return len(p), nil
+	}
+	return f.pw.Write(p)
+}
+
+func (f *fakeShell) Close() error { return f.pw.Close() }
+
+func (f *fakeShell) resize(cols, rows int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cols, f.rows = cols, rows
+	return nil
+}
+
+func (f *fakeShell) exitStatus() int { return 3 }
+
+type fakeTerminalBackend struct {
+	mu     sync.Mutex
+	shells []*fakeShell
+	opened []terminalTarget
+}
+
+func (b *fakeTerminalBackend) open(_ context.Context, target terminalTarget, cols, rows int) (terminalSession, error) {
+	pr, pw := io.Pipe()
+	shell := &fakeShell{out: pr, pw: pw, cols: cols, rows: rows}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.shells = append(b.shells, shell)
+	b.opened = append(b.opened, target)
+	return shell, nil
+}
+
+// fakeRecordings stands in for the daemon's recording artifacts of sandbox
+// 1001.
+type fakeRecordings struct {
+	mu      sync.Mutex
+	uploads []fakeRecording
+}
+
+type fakeRecording struct {
+	User  string
+	Auth  string
+	Cast  []byte
+	Valid bool
+}
+
+func (f *fakeRecordings) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == http.MethodPost {
+		cast, _ := io.ReadAll(r.Body)
+		_, _, err := recording.NewReader(bytes.NewReader(cast))
+		f.uploads = append(f.uploads, fakeRecording{User: r.URL.Query().Get("user"), Auth: r.Header.Get("Authorization"), Cast: cast, Valid: err == nil})
+		writeJSON(w, http.StatusCreated, map[string]any{"id": len(f.uploads), "vmid": 1001})
+		return
+	}
+	list := []map[string]any{}
+	for i, up := range f.uploads {
+		list = append(list, map[string]any{"id": i + 1, "vmid": 1001, "user": up.User, "size_bytes": len(up.Cast)})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"vmid": 1001, "recordings": list})
+}
+
+func (f *fakeRecordings) list() []fakeRecording {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.uploads)
+}
+
+// newTerminalTestServer serves the dashboard routes over TCP, backed by a
+// fake daemon that knows a running sandbox 1001 and a stopped sandbox 1002.
+func newTerminalTestServer(t *testing.T, cfg Config) (*Server, *fakeTerminalBackend, *httptest.Server) {
+	srv, backend, web, _ := newRecordingTerminalTestServer(t, cfg)
+	return srv, backend, web
+}
+
+// newRecordingTerminalTestServer is newTerminalTestServer that also returns
+// the recordings uploaded to the fake daemon.
+func newRecordingTerminalTestServer(t *testing.T, cfg Config) (*Server, *fakeTerminalBackend, *httptest.Server, *fakeRecordings) {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "d.sock")
+	recordings := &fakeRecordings{}
+	daemon := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sandboxes/1001/recordings":
+			recordings.serve(w, r)
+		case "/v1/sandboxes/1001":
+			writeJSON(w, http.StatusOK, map[string]any{"vmid": 1001, "state": "RUNNING", "ip": "10.77.0.5", "created_at": "2026-10-18T00:00:00Z"})
+		case "/v1/sandboxes/1002":
+			writeJSON(w, http.StatusOK, map[string]any{"vmid": 1002, "state": "STOPPED"})
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "sandbox not found"})
+		}
+	})}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go daemon.Serve(ln)
+	t.Cleanup(func() { daemon.Close() })
+
+	cfg.SocketPath = socketPath
+	cfg.BrowserToken = "tok"
+	srv := NewServer(cfg, log.New(io.Discard, "", 0))
+	backend := &fakeTerminalBackend{}
+	srv.terminal = backend
+	web := httptest.NewServer(srv.securityHeaders(srv.inboundMiddleware(srv.routes())))
+	t.Cleanup(web.Close)
+	return srv, backend, web, recordings
+}
+
+// dialTerminal performs a client WebSocket handshake. It returns the open
+// connection on 101, or nil and the HTTP status otherwise.
+func dialTerminal(t *testing.T, web *httptest.Server, path, origin string, protocols ...string) (*wsConn, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", web.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes := make([]byte, 16)
+	_, _ = rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	req, _ := http.NewRequest(http.MethodGet, web.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if len(protocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(protocols, ", "))
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, resp.StatusCode
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != websocketAccept(key) {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != terminalProtocol {
+		t.Fatalf("Sec-WebSocket-Protocol = %q, want %q", got, terminalProtocol)
+	}
+	ws := &wsConn{conn: conn, br: br, client: true}
+	t.Cleanup(func() { ws.Close() })
+	return ws, resp.StatusCode
+}
+
+func readTerminalMessage(t *testing.T, ws *wsConn) (byte, []byte) {
+	t.Helper()
+	_ = ws.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	op, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatalf("read terminal message: %v", err)
+	}
+	return op, data
+}
+
+func readTerminalControl(t *testing.T, ws *wsConn) terminalControl {
+	t.Helper()
+	op, data := readTerminalMessage(t, ws)
+	var ctl terminalControl
+	if op != wsOpText || json.Unmarshal(data, &ctl) != nil {
+		t.Fatalf("message = op %#x %q, want a control message", op, data)
+	}
+	return ctl
+}
+
+func TestTerminalRelaysShellResizeAndRecords(t *testing.T) {
+	spool := t.TempDir()
+	_, backend, web, recordings := newRecordingTerminalTestServer(t, Config{Token: "daemon-tok", Record: true, RecordSpool: spool})
+
+	ws, status := dialTerminal(t, web, "/api/v1/sandboxes/1001/terminal?cols=100&rows=30", web.URL, terminalProtocol, terminalTokenPrefix+"tok")
+	if ws == nil {
+		t.Fatalf("handshake status %d, want 101", status)
+	}
+	if err := ws.WriteMessage(wsOpBinary, []byte("ls\r")); err != nil {
+		t.Fatal(err)
+	}
+	if op, data := readTerminalMessage(t, ws); op != wsOpBinary || string(data) != "ls\r" {
+		t.Fatalf("echo = op %#x %q, want binary ls", op, data)
+	}
+	if err := ws.WriteMessage(wsOpText, []byte(`{"type":"resize","cols":120,"rows":40}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.WriteMessage(wsOpBinary, []byte("exit\r")); err != nil {
+		t.Fatal(err)
+	}
+	ctl := readTerminalControl(t, ws)
+	if ctl.Type != "exit" || ctl.Status == nil || *ctl.Status != 3 {
+		t.Fatalf("control = %+v, want exit status 3", ctl)
+	}
+
+	backend.mu.Lock()
+	shell, target := backend.shells[0], backend.opened[0]
+	backend.mu.Unlock()
+	if target.VMID != 1001 || target.IP != "10.77.0.5" {
+		t.Fatalf("opened target = %+v", target)
+	}
+	shell.mu.Lock()
+	if shell.cols != 120 || shell.rows != 40 {
+		t.Errorf("shell size = %dx%d, want 120x40 after resize", shell.cols, shell.rows)
+	}
+	shell.mu.Unlock()
+
+	// The handler uploads the recording as it returns; poll the sandbox's
+	// recordings through the dashboard until it is listed.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req, _ := http.NewRequest(http.MethodGet, web.URL+"/api/v1/sandboxes/1001/recordings", nil)
+		req.Header.Set("X-Dashboard-Token", "tok")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var listed struct {
+			Recordings []struct {
+				ID int `json:"id"`
+			} `json:"recordings"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&listed)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed.Recordings) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recording not listed: %+v", listed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	uploads := recordings.list()
+	if len(uploads) != 1 {
+		t.Fatalf("uploads = %d, want 1", len(uploads))
+	}
+	up := uploads[0]
+	if !up.Valid {
+		t.Errorf("upload is not an asciicast v2 recording:\n%s", up.Cast)
+	}
+	if up.Auth != "Bearer daemon-tok" {
+		t.Errorf("upload Authorization = %q, want the dashboard's daemon token", up.Auth)
+	}
+	if up.User != "" {
+		t.Errorf("upload user = %q, want none for the shared browser token", up.User)
+	}
+	if left, _ := os.ReadDir(spool); len(left) != 0 {
+		t.Errorf("spool not cleaned up: %v", left)
+	}
+	cast := up.Cast
+	for _, want := range []string{`"version":2`, `"width":100`, `"height":30`, `"i","ls\r"`, `"o","ls\r"`, `"r","120x40"`} {
+		if !bytes.Contains(cast, []byte(want)) {
+			t.Errorf("recording missing %s:\n%s", want, cast)
+		}
+	}
+}
+
+func TestTerminalUserNamesTheSignedInUser(t *testing.T) {
+	if got := terminalUser(context.Background()); got != "" {
+		t.Errorf("browser token: user %q, want none", got)
+	}
+	ctx := context.WithValue(context.Background(), sessionKey{}, &ssoSession{UserID: "u-1", User: "alice"})
+	if got := terminalUser(ctx); got != "alice" {
+		t.Errorf("signed in: user %q, want alice", got)
+	}
+}
+
+func TestTerminalHandshakeGuards(t *testing.T) {
+	_, _, web := newTerminalTestServer(t, Config{})
+	path := "/api/v1/sandboxes/1001/terminal"
+
+	if _, status := dialTerminal(t, web, path, web.URL, terminalProtocol); status != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", status)
+	}
+	if _, status := dialTerminal(t, web, path, "https://evil.example", terminalProtocol, terminalTokenPrefix+"tok"); status != http.StatusForbidden {
+		t.Errorf("cross-origin: status %d, want 403", status)
+	}
+	if _, status := dialTerminal(t, web, path, "", terminalProtocol, terminalTokenPrefix+"tok"); status != http.StatusForbidden {
+		t.Errorf("no origin: status %d, want 403", status)
+	}
+	if _, status := dialTerminal(t, web, path, web.URL, terminalTokenPrefix+"tok"); status != http.StatusBadRequest {
+		t.Errorf("missing subprotocol: status %d, want 400", status)
+	}
+	if _, status := dialTerminal(t, web, "/api/v1/sandboxes/abc/terminal", web.URL, terminalProtocol, terminalTokenPrefix+"tok"); status != http.StatusBadRequest {
+		t.Errorf("bad vmid: status %d, want 400", status)
+	}
+
+	ws, status := dialTerminal(t, web, "/api/v1/sandboxes/1002/terminal", web.URL, terminalProtocol, terminalTokenPrefix+"tok")
+	if ws == nil {
+		t.Fatalf("stopped sandbox handshake: status %d, want 101", status)
+	}
+	if ctl := readTerminalControl(t, ws); ctl.Type != "error" || !strings.Contains(ctl.Message, "stopped") {
+		t.Fatalf("stopped sandbox: control %+v, want an error naming the state", ctl)
+	}
+}
+
+func TestTerminalDisabledWithoutSandboxKey(t *testing.T) {
+	srv, _, web := newTerminalTestServer(t, Config{})
+	srv.terminal = nil
+	if _, status := dialTerminal(t, web, "/api/v1/sandboxes/1001/terminal", web.URL, terminalProtocol, terminalTokenPrefix+"tok"); status != http.StatusServiceUnavailable {
+		t.Fatalf("disabled terminal: status %d, want 503", status)
+	}
+}
+
+func TestTerminalIdleTimeout(t *testing.T) {
+	_, _, web := newTerminalTestServer(t, Config{TerminalIdleTimeout: 50 * time.Millisecond})
+	ws, status := dialTerminal(t, web, "/api/v1/sandboxes/1001/terminal", web.URL, terminalProtocol, terminalTokenPrefix+"tok")
+	if ws == nil {
+		t.Fatalf("handshake status %d, want 101", status)
+	}
+	ctl := readTerminalControl(t, ws)
+	if ctl.Type != "error" || !strings.Contains(ctl.Message, "inactivity") {
+		t.Fatalf("control = %+v, want an idle timeout error", ctl)
+	}
+	_ = ws.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
+
+func TestWebSocketFrameRoundTrip(t *testing.T) {
+	a, b := net.Pipe()
+	server := &wsConn{conn: a, br: bufio.NewReader(a)}
+	client := &wsConn{conn: b, br: bufio.NewReader(b), client: true}
+	big := bytes.Repeat([]byte("x"), 70000)
+
+	errCh := make(chan error, 1)
+	go func() {
+		for _, msg := range [][]byte{[]byte("hi"), big} {
+			if err := client.WriteMessage(wsOpBinary, msg); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		errCh <- client.writeFrame(wsOpPing, []byte("p"))
+	}()
+	for i, want := range [][]byte{[]byte("hi"), big} {
+		op, got, err := server.ReadMessage()
+		if err != nil || op != wsOpBinary || !bytes.Equal(got, want) {
+			t.Fatalf("message %d: op %#x len %d err %v", i, op, len(got), err)
+		}
+	}
+
+	// The ping is answered with a pong while the server waits for data.
+	go func() { _, _, _ = server.ReadMessage() }()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	_, op, data, err := client.readFrame()
+	if err != nil || op != wsOpPong || string(data) != "p" {
+		t.Fatalf("pong = op %#x %q err %v", op, data, err)
+	}
+	a.Close()
+	b.Close()
+}
+
+func TestTerminalDim(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want int
+	}{{"", 80}, {"abc", 80}, {"-1", 80}, {"132", 132}, {fmt.Sprint(maxTerminalDim + 5), maxTerminalDim}} {
+		if got := terminalDim(tc.raw, 80); got != tc.want {
+			t.Errorf("terminalDim(%q) = %d, want %d", tc.raw, got, tc.want)
+		}
+	}
+}
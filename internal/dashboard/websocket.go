@@ -0,0 +1,296 @@
+package dashboard
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file implements the subset of RFC 6455 the web terminal needs: the
+// server handshake, unfragmented and fragmented data messages, ping/pong, and
+// the close handshake. Extensions (compression) are never negotiated.
+
+// websocketGUID is the fixed key suffix from RFC 6455 section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// maxWebSocketMessage bounds a single message from the browser. Keystrokes,
+// pastes, and resize messages are small; a larger message is a protocol abuse.
+const maxWebSocketMessage = 1 << 20 // 1 MiB
+
+var errWebSocketTooLarge = errors.New("websocket message too large")
+
+// wsConn is a WebSocket connection. Reads must come from one goroutine;
+// writes are serialized so output and control messages can be sent from
+// several.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	// client masks outgoing frames and expects unmasked incoming ones. Only
+	// tests dial as a client.
+	client bool
+
+	wmu       sync.Mutex
+	closeOnce sync.Once
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to the WebSocket
+// protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(strings.TrimSpace(r.Header.Get("Upgrade")), "websocket")
+}
+
+// headerHasToken reports whether a comma-separated header contains token,
+// compared case-insensitively.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// websocketProtocols returns the subprotocols the client offered.
+func websocketProtocols(r *http.Request) []string {
+	var out []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, part := range strings.Split(v, ",") {
+			if p := strings.TrimSpace(part); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+// websocketAccept computes the Sec-WebSocket-Accept value for a client key.
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// upgradeWebSocket completes the server handshake and hijacks the connection.
+// protocol is echoed back when non-empty; the caller checks the client offered
+// it. On failure an HTTP error has already been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, protocol string) (*wsConn, error) {
+	if r.Method != http.MethodGet || !isWebSocketUpgrade(r) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "websocket upgrade required"})
+		return nil, errors.New("not a websocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeJSON(w, http.StatusUpgradeRequired, map[string]string{"error": "unsupported websocket version"})
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Sec-WebSocket-Key"})
+		return nil, errors.New("invalid websocket key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "websocket not supported"})
+		return nil, errors.New("response writer cannot hijack")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijack: %w", err)
+	}
+	// The server's read and write timeouts may already be armed on the
+	// hijacked connection; a terminal outlives them, so clear them.
+	_ = conn.SetDeadline(time.Time{})
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n")
+	if protocol != "" {
+		resp.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+	if _, err := conn.Write([]byte(resp.String())); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+	return &wsConn{conn: conn, br: brw.Reader}, nil
+}
+
+// ReadMessage returns the next text or binary message, answering pings and
+// reassembling fragments along the way. It returns io.EOF once the peer has
+// sent a close frame.
+func (c *wsConn) ReadMessage() (opcode byte, payload []byte, err error) {
+	for {
+		fin, op, data, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, data); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			_ = c.writeFrame(wsOpClose, closePayload(data))
+			return 0, nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if opcode != 0 {
+				return 0, nil, errors.New("websocket: new message before previous one finished")
+			}
+			opcode = op
+		case wsOpContinuation:
+			if opcode == 0 {
+				return 0, nil, errors.New("websocket: continuation without a message")
+			}
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %#x", op)
+		}
+		if len(payload)+len(data) > maxWebSocketMessage {
+			return 0, nil, errWebSocketTooLarge
+		}
+		payload = append(payload, data...)
+		if fin {
+			return opcode, payload, nil
+		}
+	}
+}
+
+// closePayload echoes the status code of a received close frame, as RFC 6455
+// section 5.5.1 asks.
+func closePayload(data []byte) []byte {
+	if len(data) >= 2 {
+		return data[:2]
+	}
+	return nil
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("websocket: reserved bits set without an extension")
+	}
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, nil, errors.New("websocket: unexpected frame masking")
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > 125 || !fin) {
+		return false, 0, nil, errors.New("websocket: invalid control frame")
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, errWebSocketTooLarge
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends one unfragmented message.
+func (c *wsConn) WriteMessage(opcode byte, payload []byte) error {
+	return c.writeFrame(opcode, payload)
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a normal-closure frame (best effort) and closes the connection.
+// It is safe to call more than once.
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.writeFrame(wsOpClose, []byte{0x03, 0xE8}) // 1000 normal closure
+		err = c.conn.Close()
+	})
+	return err
+}
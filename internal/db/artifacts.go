@@ -15,6 +15,10 @@ import (
 // Artifact stores artifact metadata for files uploaded from sandboxes.
 // The actual file contents are stored on disk; this struct tracks
 // metadata for retention and retrieval purposes.
+//
+// Most artifacts belong to a job. Sandbox artifacts, such as session
+// recordings, have no job: JobID is empty, VMID names the sandbox, and User
+// names who produced them.
 type Artifact struct {
 	ID        int64
 	JobID     string
@@ -24,6 +28,8 @@ type Artifact struct {
 	SizeBytes int64
 	Sha256    string
 	MIME      string
+	Kind      models.ArtifactKind
+	User      string
 	CreatedAt time.Time
 }
 
@@ -31,13 +37,20 @@ type Artifact struct {
 // for garbage collection decisions. This is used by the artifact GC process
 // to determine which artifacts can be deleted based on job completion,
 // sandbox destruction, and profile-specific retention policies.
+//
+// Job fields are zero for sandbox artifacts; the sandbox fields describe the
+// sandbox row currently holding the artifact's VMID, which may be a newer
+// sandbox when the VMID was reused.
 type ArtifactRetentionRecord struct {
-	Artifact     Artifact
-	JobProfile   string
-	JobStatus    models.JobStatus
-	JobUpdatedAt time.Time
-	SandboxVMID  *int
-	SandboxState models.SandboxState
+	Artifact         Artifact
+	JobProfile       string
+	JobStatus        models.JobStatus
+	JobUpdatedAt     time.Time
+	SandboxVMID      *int
+	SandboxState     models.SandboxState
+	SandboxProfile   string
+	SandboxCreatedAt time.Time
+	SandboxUpdatedAt time.Time
 }
 
 // CreateArtifact inserts artifact metadata and returns the row id.
@@ -46,8 +59,8 @@ func (s *Store) CreateArtifact(ctx context.Context, artifact Artifact) (int64, e
 		return 0, errors.New("db store is nil")
 	}
 	artifact.JobID = strings.TrimSpace(artifact.JobID)
-	if artifact.JobID == "" {
-		return 0, errors.New("job id is required")
+	if artifact.JobID == "" && (artifact.VMID == nil || *artifact.VMID <= 0) {
+		return 0, errors.New("job id or vmid is required")
 	}
 	artifact.Name = strings.TrimSpace(artifact.Name)
 	if artifact.Name == "" {
@@ -76,18 +89,23 @@ func (s *Store) CreateArtifact(ctx context.Context, artifact Artifact) (int64, e
 	if strings.TrimSpace(artifact.MIME) != "" {
 		mime = strings.TrimSpace(artifact.MIME)
 	}
-	res, err := s.DB.ExecContext(ctx, `INSERT INTO artifacts (job_id, vmid, name, path, size_bytes, sha256, mime, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		artifact.JobID,
+	res, err := s.DB.ExecContext(ctx, `INSERT INTO artifacts (job_id, vmid, name, path, size_bytes, sha256, mime, kind, user_name, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullIfEmpty(artifact.JobID),
 		vmid,
 		artifact.Name,
 		artifact.Path,
 		artifact.SizeBytes,
 		artifact.Sha256,
 		mime,
+		nullIfEmpty(string(artifact.Kind)),
+		nullIfEmpty(strings.TrimSpace(artifact.User)),
 		formatTime(createdAt),
 	)
 	if err != nil {
+		if artifact.JobID == "" {
+			return 0, fmt.Errorf("insert artifact for sandbox %d: %w", *artifact.VMID, err)
+		}
 		return 0, fmt.Errorf("insert artifact for job %s: %w", artifact.JobID, err)
 	}
 	id, err := res.LastInsertId()
@@ -119,16 +137,19 @@ func (s *Store) DeleteArtifact(ctx context.Context, id int64) error {
 	return nil
 }
 
-// ListArtifactRetentionCandidates returns artifacts with job metadata for GC.
+// ListArtifactRetentionCandidates returns artifacts with job and sandbox
+// metadata for GC. Sandbox artifacts join the sandbox through their own vmid.
 func (s *Store) ListArtifactRetentionCandidates(ctx context.Context) ([]ArtifactRetentionRecord, error) {
 	if s == nil || s.DB == nil {
 		return nil, errors.New("db store is nil")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT a.id, a.job_id, a.vmid, a.name, a.path, a.size_bytes, a.sha256, a.mime, a.created_at,
-		j.profile, j.status, j.updated_at, j.sandbox_vmid, s.state
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+artifactColumns("a")+`,
+		j.profile, j.status, j.updated_at, j.sandbox_vmid,
+		s.state, s.profile, s.created_at, s.updated_at
 		FROM artifacts a
-		INNER JOIN jobs j ON a.job_id = j.id
-		LEFT JOIN sandboxes s ON j.sandbox_vmid = s.vmid`)
+		LEFT JOIN jobs j ON a.job_id = j.id
+		LEFT JOIN sandboxes s ON s.vmid = CASE WHEN a.job_id IS NULL THEN a.vmid ELSE j.sandbox_vmid END
+		WHERE a.job_id IS NULL OR j.id IS NOT NULL`)
 	if err != nil {
 		return nil, fmt.Errorf("list artifact retention candidates: %w", err)
 	}
@@ -136,60 +157,53 @@ func (s *Store) ListArtifactRetentionCandidates(ctx context.Context) ([]Artifact
 
 	var out []ArtifactRetentionRecord
 	for rows.Next() {
-		var artifact Artifact
-		var vmid sql.NullInt64
-		var mime sql.NullString
-		var createdAt string
-		var profile string
-		var status string
-		var jobUpdatedAt string
-		var jobSandbox sql.NullInt64
-		var sandboxState sql.NullString
-		if err := rows.Scan(
-			&artifact.ID,
-			&artifact.JobID,
-			&vmid,
-			&artifact.Name,
-			&artifact.Path,
-			&artifact.SizeBytes,
-			&artifact.Sha256,
-			&mime,
-			&createdAt,
+		var (
+			profile          sql.NullString
+			status           sql.NullString
+			jobUpdatedAt     sql.NullString
+			jobSandbox       sql.NullInt64
+			sandboxState     sql.NullString
+			sandboxProfile   sql.NullString
+			sandboxCreatedAt sql.NullString
+			sandboxUpdatedAt sql.NullString
+		)
+		artifact, err := scanArtifactRow(rows,
 			&profile,
 			&status,
 			&jobUpdatedAt,
 			&jobSandbox,
 			&sandboxState,
-		); err != nil {
+			&sandboxProfile,
+			&sandboxCreatedAt,
+			&sandboxUpdatedAt,
+		)
+		if err != nil {
 			return nil, err
 		}
-		if vmid.Valid {
-			value := int(vmid.Int64)
-			artifact.VMID = &value
-		}
-		if mime.Valid {
-			artifact.MIME = mime.String
+		record := ArtifactRetentionRecord{
+			Artifact:       artifact,
+			JobProfile:     profile.String,
+			JobStatus:      models.JobStatus(status.String),
+			SandboxState:   models.SandboxState(sandboxState.String),
+			SandboxProfile: sandboxProfile.String,
 		}
-		if createdAt != "" {
-			parsed, err := parseTime(createdAt)
-			if err != nil {
-				return nil, fmt.Errorf("parse artifact created_at: %w", err)
+		for _, field := range []struct {
+			raw  sql.NullString
+			dest *time.Time
+			name string
+		}{
+			{jobUpdatedAt, &record.JobUpdatedAt, "job updated_at"},
+			{sandboxCreatedAt, &record.SandboxCreatedAt, "sandbox created_at"},
+			{sandboxUpdatedAt, &record.SandboxUpdatedAt, "sandbox updated_at"},
+		} {
+			if field.raw.String == "" {
+				continue
 			}
-			artifact.CreatedAt = parsed
-		}
-		var updated time.Time
-		if jobUpdatedAt != "" {
-			parsed, err := parseTime(jobUpdatedAt)
+			parsed, err := parseTime(field.raw.String)
 			if err != nil {
-				return nil, fmt.Errorf("parse job updated_at: %w", err)
+				return nil, fmt.Errorf("parse %s: %w", field.name, err)
 			}
-			updated = parsed
-		}
-		record := ArtifactRetentionRecord{
-			Artifact:     artifact,
-			JobProfile:   profile,
-			JobStatus:    models.JobStatus(status),
-			JobUpdatedAt: updated,
+			*field.dest = parsed
 		}
 		if jobSandbox.Valid {
 			value := int(jobSandbox.Int64)
@@ -197,9 +211,6 @@ func (s *Store) ListArtifactRetentionCandidates(ctx context.Context) ([]Artifact
 		} else if artifact.VMID != nil {
 			record.SandboxVMID = artifact.VMID
 		}
-		if sandboxState.Valid {
-			record.SandboxState = models.SandboxState(sandboxState.String)
-		}
 		out = append(out, record)
 	}
 	if err := rows.Err(); err != nil {
@@ -217,7 +228,7 @@ func (s *Store) ListArtifactsByJob(ctx context.Context, jobID string) ([]Artifac
 	if jobID == "" {
 		return nil, errors.New("job id is required")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, job_id, vmid, name, path, size_bytes, sha256, mime, created_at
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+artifactColumns("")+`
 		FROM artifacts WHERE job_id = ? ORDER BY created_at ASC`, jobID)
 	if err != nil {
 		return nil, fmt.Errorf("list artifacts: %w", err)
@@ -237,24 +248,107 @@ func (s *Store) ListArtifactsByJob(ctx context.Context, jobID string) ([]Artifac
 	return out, nil
 }
 
-func scanArtifactRow(scanner interface{ Scan(dest ...any) error }) (Artifact, error) {
+// LatestArtifactByKind returns the newest artifact of the given kind for a
+// job, or sql.ErrNoRows when the job has none.
+func (s *Store) LatestArtifactByKind(ctx context.Context, jobID string, kind models.ArtifactKind) (Artifact, error) {
+	if s == nil || s.DB == nil {
+		return Artifact{}, errors.New("db store is nil")
+	}
+	jobID = strings.TrimSpace(jobID)
+	if jobID == "" {
+		return Artifact{}, errors.New("job id is required")
+	}
+	if kind == "" {
+		return Artifact{}, errors.New("artifact kind is required")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+artifactColumns("")+`
+		FROM artifacts WHERE job_id = ? AND kind = ? ORDER BY created_at DESC, id DESC LIMIT 1`, jobID, string(kind))
+	return scanArtifactRow(row)
+}
+
+// ListSandboxArtifacts returns the artifacts of a sandbox that belong to no
+// job, oldest first. An empty kind lists every kind.
+func (s *Store) ListSandboxArtifacts(ctx context.Context, vmid int, kind models.ArtifactKind) ([]Artifact, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if vmid <= 0 {
+		return nil, errors.New("vmid must be positive")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+artifactColumns("")+`
+		FROM artifacts WHERE vmid = ? AND job_id IS NULL AND (? = '' OR kind = ?)
+		ORDER BY created_at ASC, id ASC`, vmid, string(kind), string(kind))
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox %d artifacts: %w", vmid, err)
+	}
+	defer rows.Close()
+	var out []Artifact
+	for rows.Next() {
+		artifact, err := scanArtifactRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, artifact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sandbox artifacts: %w", err)
+	}
+	return out, nil
+}
+
+// GetArtifact returns an artifact by id, or sql.ErrNoRows.
+func (s *Store) GetArtifact(ctx context.Context, id int64) (Artifact, error) {
+	if s == nil || s.DB == nil {
+		return Artifact{}, errors.New("db store is nil")
+	}
+	if id <= 0 {
+		return Artifact{}, errors.New("artifact id is required")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+artifactColumns("")+` FROM artifacts WHERE id = ?`, id)
+	return scanArtifactRow(row)
+}
+
+// artifactColumns lists the columns scanArtifactRow reads, qualified with
+// alias when it is non-empty.
+func artifactColumns(alias string) string {
+	cols := []string{"id", "job_id", "vmid", "name", "path", "size_bytes", "sha256", "mime", "kind", "user_name", "created_at"}
+	if alias != "" {
+		for i, col := range cols {
+			cols[i] = alias + "." + col
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// scanArtifactRow scans the artifactColumns of a row, followed by any extra
+// destinations the query selects after them.
+func scanArtifactRow(scanner interface{ Scan(dest ...any) error }, extra ...any) (Artifact, error) {
 	var artifact Artifact
+	var jobID sql.NullString
 	var vmid sql.NullInt64
 	var mime sql.NullString
+	var kind sql.NullString
+	var user sql.NullString
 	var createdAt string
-	if err := scanner.Scan(
+	dest := []any{
 		&artifact.ID,
-		&artifact.JobID,
+		&jobID,
 		&vmid,
 		&artifact.Name,
 		&artifact.Path,
 		&artifact.SizeBytes,
 		&artifact.Sha256,
 		&mime,
+		&kind,
+		&user,
 		&createdAt,
-	); err != nil {
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
 		return Artifact{}, err
 	}
+	artifact.JobID = jobID.String
+	artifact.Kind = models.ArtifactKind(kind.String)
+	artifact.User = user.String
 	if vmid.Valid {
 		value := int(vmid.Int64)
 		artifact.VMID = &value
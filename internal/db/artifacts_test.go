@@ -110,7 +110,7 @@ func TestCreateArtifact(t *testing.T) {
 			Sha256:    "abc123",
 		}
 		id, err := store.CreateArtifact(ctx, artifact)
-		assert.EqualError(t, err, "job id is required")
+		assert.EqualError(t, err, "job id or vmid is required")
 		assert.Equal(t, int64(0), id)
 	})
 
@@ -124,7 +124,7 @@ func TestCreateArtifact(t *testing.T) {
 			Sha256:    "abc123",
 		}
 		id, err := store.CreateArtifact(ctx, artifact)
-		assert.EqualError(t, err, "job id is required")
+		assert.EqualError(t, err, "job id or vmid is required")
 		assert.Equal(t, int64(0), id)
 	})
 
@@ -453,6 +453,52 @@ func TestListArtifactsByJob(t *testing.T) {
 	})
 }
 
+func TestLatestArtifactByKind(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	job := models.Job{
+		ID:        "job-1",
+		RepoURL:   "https://github.com/example/repo",
+		Ref:       "main",
+		Profile:   "default",
+		Status:    models.JobCompleted,
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, store.CreateJob(ctx, job))
+
+	_, err := store.LatestArtifactByKind(ctx, "job-1", models.ArtifactKindPatch)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	for i, artifact := range []Artifact{
+		{Name: "bundle.tar.gz", Path: "bundle.tar.gz", Kind: models.ArtifactKindBundle},
+		{Name: "patch.diff", Path: "patch.diff", Kind: models.ArtifactKindPatch},
+		{Name: "patch.diff", Path: "retry/patch.diff", Kind: models.ArtifactKindPatch},
+		{Name: "notes.txt", Path: "notes.txt"},
+	} {
+		artifact.JobID = "job-1"
+		artifact.SizeBytes = 10
+		artifact.Sha256 = "abc"
+		artifact.CreatedAt = time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC)
+		_, err := store.CreateArtifact(ctx, artifact)
+		require.NoError(t, err)
+	}
+
+	latest, err := store.LatestArtifactByKind(ctx, "job-1", models.ArtifactKindPatch)
+	require.NoError(t, err)
+	assert.Equal(t, "retry/patch.diff", latest.Path)
+	assert.Equal(t, models.ArtifactKindPatch, latest.Kind)
+
+	list, err := store.ListArtifactsByJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	assert.Equal(t, models.ArtifactKindBundle, list[0].Kind)
+	assert.Equal(t, models.ArtifactKind(""), list[3].Kind)
+
+	_, err = store.LatestArtifactByKind(ctx, "job-1", "")
+	assert.EqualError(t, err, "artifact kind is required")
+}
+
 func TestListArtifactRetentionCandidates(t *testing.T) {
 	ctx := context.Background()
 
@@ -556,6 +602,45 @@ func TestListArtifactRetentionCandidates(t *testing.T) {
 		assert.Equal(t, models.SandboxState(""), record.SandboxState)
 	})
 
+	t.Run("sandbox artifact joins its own sandbox", func(t *testing.T) {
+		store := openTestStore(t)
+
+		vmid := 200
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		err := store.CreateSandbox(ctx, models.Sandbox{
+			VMID:          vmid,
+			Name:          "shell",
+			Profile:       "yolo",
+			State:         models.SandboxDestroyed,
+			CreatedAt:     created,
+			LastUpdatedAt: created.Add(time.Hour),
+		})
+		require.NoError(t, err)
+		_, err = store.CreateArtifact(ctx, Artifact{
+			VMID:      &vmid,
+			Name:      "session.cast",
+			Path:      "recordings/session.cast",
+			SizeBytes: 10,
+			Sha256:    "abc",
+			Kind:      models.ArtifactKindRecording,
+			User:      "alice",
+			CreatedAt: created.Add(30 * time.Minute),
+		})
+		require.NoError(t, err)
+
+		list, err := store.ListArtifactRetentionCandidates(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		record := list[0]
+		assert.Empty(t, record.Artifact.JobID)
+		assert.Equal(t, "alice", record.Artifact.User)
+		assert.Empty(t, record.JobProfile)
+		assert.Equal(t, "yolo", record.SandboxProfile)
+		assert.Equal(t, models.SandboxDestroyed, record.SandboxState)
+		assert.True(t, record.SandboxCreatedAt.Equal(created))
+		assert.Equal(t, vmid, *record.SandboxVMID)
+	})
+
 	t.Run("nil store", func(t *testing.T) {
 		list, err := (*Store)(nil).ListArtifactRetentionCandidates(ctx)
 		assert.EqualError(t, err, "db store is nil")
@@ -711,3 +796,45 @@ func TestArtifactSpecialCharacters(t *testing.T) {
 		assert.Equal(t, longName, list[0].Name)
 	})
 }
+
+func TestSandboxArtifacts(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	vmid := 300
+	other := 301
+	base := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for i, a := range []Artifact{
+		{VMID: &vmid, Name: "b.cast", Path: "recordings/b.cast", Kind: models.ArtifactKindRecording, User: "bob", CreatedAt: base.Add(time.Minute)},
+		{VMID: &vmid, Name: "a.cast", Path: "recordings/a.cast", Kind: models.ArtifactKindRecording, User: "alice", CreatedAt: base},
+		{VMID: &vmid, Name: "notes.txt", Path: "notes.txt", CreatedAt: base},
+		{VMID: &other, Name: "c.cast", Path: "recordings/c.cast", Kind: models.ArtifactKindRecording, CreatedAt: base},
+	} {
+		a.SizeBytes = int64(i + 1)
+		a.Sha256 = "sha"
+		_, err := store.CreateArtifact(ctx, a)
+		require.NoError(t, err)
+	}
+
+	list, err := store.ListSandboxArtifacts(ctx, vmid, models.ArtifactKindRecording)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "a.cast", list[0].Name)
+	assert.Equal(t, "alice", list[0].User)
+	assert.Equal(t, "b.cast", list[1].Name)
+
+	all, err := store.ListSandboxArtifacts(ctx, vmid, "")
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	got, err := store.GetArtifact(ctx, list[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", got.User)
+	assert.Equal(t, vmid, *got.VMID)
+
+	_, err = store.GetArtifact(ctx, 9999)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.ListSandboxArtifacts(ctx, 0, "")
+	assert.EqualError(t, err, "vmid must be positive")
+}
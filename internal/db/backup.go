@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	backupFilePerms = 0o600 // Backups hold every secret-bearing row; owner only
+	restoreSuffix   = ".restore"
+)
+
+// Backup writes a consistent copy of the live database to dest.
+//
+// It uses VACUUM INTO, which reads a single snapshot of the database inside
+// a read transaction, so it is safe while the daemon keeps serving requests.
+// The copy is compacted and self-contained (no -wal file). dest must not
+// exist yet.
+func (s *Store) Backup(ctx context.Context, dest string) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	dest = strings.TrimSpace(dest)
+	if dest == "" {
+		return errors.New("backup destination is required")
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup destination %s already exists", dest)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat backup destination %s: %w", dest, err)
+	}
+	if _, err := s.DB.ExecContext(ctx, `VACUUM INTO ?`, dest); err != nil {
+		_ = os.Remove(dest)
+		return fmt.Errorf("backup database to %s: %w", dest, err)
+	}
+	if err := os.Chmod(dest, backupFilePerms); err != nil {
+		return fmt.Errorf("chmod backup %s: %w", dest, err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest schema migration applied to the store.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	if s == nil || s.DB == nil {
+		return 0, errors.New("db store is nil")
+	}
+	return schemaVersion(ctx, s.DB)
+}
+
+// LatestSchemaVersion returns the newest schema migration this build knows.
+func LatestSchemaVersion() int {
+	latest := 0
+	for _, m := range migrations {
+		if m.version > latest {
+			latest = m.version
+		}
+	}
+	return latest
+}
+
+// VerifyBackup checks that path holds an intact AgentLab database this build
+// can open and returns its schema version.
+//
+// The file is opened read-only. It must pass PRAGMA integrity_check and carry
+// a schema_migrations table whose versions are all known to this build. An
+// older schema is accepted: Open migrates it forward on the next start.
+func VerifyBackup(ctx context.Context, path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("stat backup %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("backup %s is a directory", path)
+	}
+	conn, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("open backup %s: %w", path, err)
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	var integrity string
+	if err := conn.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("check backup %s: %w", path, err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("backup %s failed integrity check: %s", path, integrity)
+	}
+	applied, err := loadAppliedVersions(conn)
+	if err != nil {
+		return 0, fmt.Errorf("backup %s is not an agentlab database: %w", path, err)
+	}
+	if len(applied) == 0 {
+		return 0, fmt.Errorf("backup %s has no schema migrations", path)
+	}
+	if err := verifyKnownMigrations(applied); err != nil {
+		return 0, fmt.Errorf("backup %s was written by a newer agentlabd: %w", path, err)
+	}
+	return schemaVersion(ctx, conn)
+}
+
+// RestoreStagePath returns where a verified backup waits to replace dbPath.
+func RestoreStagePath(dbPath string) string {
+	return dbPath + restoreSuffix
+}
+
+// StageRestore verifies a plaintext backup and stages it to replace dbPath
+// the next time the daemon starts.
+//
+// The live database is never touched here: the daemon holds it open, and
+// every component shares that handle. ApplyStagedRestore performs the swap
+// before the store is opened. A previously staged restore is replaced.
+func StageRestore(ctx context.Context, dbPath string, backup []byte) (int, error) {
+	if strings.TrimSpace(dbPath) == "" {
+		return 0, errors.New("db path is required")
+	}
+	if err := ensureDir(filepath.Dir(dbPath)); err != nil {
+		return 0, err
+	}
+	stage := RestoreStagePath(dbPath)
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(stage)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("create restore stage: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if _, err := tmp.Write(backup); err != nil {
+		_ = tmp.Close()
+		return 0, fmt.Errorf("write restore stage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("close restore stage: %w", err)
+	}
+	if err := os.Chmod(tmpPath, backupFilePerms); err != nil {
+		return 0, fmt.Errorf("chmod restore stage: %w", err)
+	}
+	version, err := VerifyBackup(ctx, tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, stage); err != nil {
+		return 0, fmt.Errorf("stage restore %s: %w", stage, err)
+	}
+	return version, nil
+}
+
+// ApplyStagedRestore swaps a staged restore into place at dbPath. It must run
+// before the database is opened.
+//
+// The current database and its -wal/-shm files are kept beside it with a
+// .pre-restore-<timestamp> suffix. It returns that path, or "" when no
+// restore was staged.
+func ApplyStagedRestore(dbPath string, now time.Time) (string, error) {
+	stage := RestoreStagePath(dbPath)
+	if _, err := os.Stat(stage); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("stat restore stage %s: %w", stage, err)
+	}
+	previous := dbPath + ".pre-restore-" + now.UTC().Format("20060102T150405Z")
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Rename(dbPath+suffix, previous+suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("move aside %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := os.Rename(stage, dbPath); err != nil {
+		return "", fmt.Errorf("apply restore %s: %w", stage, err)
+	}
+	return previous, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func schemaVersion(ctx context.Context, q queryRower) (int, error) {
+	var version sql.NullInt64
+	if err := q.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupAndVerify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("backup is a verified copy of the live store", func(t *testing.T) {
+		store := openTestStore(t)
+		job := models.Job{
+			ID:        "job-backup",
+			RepoURL:   "https://github.com/example/repo",
+			Ref:       "main",
+			Profile:   "default",
+			Status:    models.JobCompleted,
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		require.NoError(t, store.CreateJob(ctx, job))
+
+		dest := filepath.Join(t.TempDir(), "backup.db")
+		require.NoError(t, store.Backup(ctx, dest))
+		info, err := os.Stat(dest)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(backupFilePerms), info.Mode().Perm())
+
+		version, err := VerifyBackup(ctx, dest)
+		require.NoError(t, err)
+		assert.Equal(t, LatestSchemaVersion(), version)
+
+		live, err := store.SchemaVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, live, version)
+
+		copied, err := Open(dest)
+		require.NoError(t, err)
+		defer copied.Close()
+		got, err := copied.GetJob(ctx, "job-backup")
+		require.NoError(t, err)
+		assert.Equal(t, job.RepoURL, got.RepoURL)
+	})
+
+	t.Run("existing destination is refused", func(t *testing.T) {
+		store := openTestStore(t)
+		dest := filepath.Join(t.TempDir(), "backup.db")
+		require.NoError(t, os.WriteFile(dest, []byte("keep"), 0o600))
+		err := store.Backup(ctx, dest)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already exists")
+	})
+
+	t.Run("non-database file is rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "junk.db")
+		require.NoError(t, os.WriteFile(path, []byte("not sqlite at all, just some text padding"), 0o600))
+		_, err := VerifyBackup(ctx, path)
+		require.Error(t, err)
+	})
+
+	t.Run("newer schema is rejected", func(t *testing.T) {
+		store := openTestStore(t)
+		_, err := store.DB.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', '2030-01-01T00:00:00Z')`, LatestSchemaVersion()+1)
+		require.NoError(t, err)
+		dest := filepath.Join(t.TempDir(), "backup.db")
+		require.NoError(t, store.Backup(ctx, dest))
+
+		_, err = VerifyBackup(ctx, dest)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "newer agentlabd")
+	})
+
+	t.Run("database without migrations is rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plain.db")
+		conn, err := sql.Open("sqlite", path)
+		require.NoError(t, err)
+		_, err = conn.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY)`)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+
+		_, err = VerifyBackup(ctx, path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not an agentlab database")
+	})
+}
+
+func TestStageAndApplyRestore(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	require.NoError(t, store.Backup(ctx, backupPath))
+	data, err := os.ReadFile(backupPath)
+	require.NoError(t, err)
+
+	dbPath := filepath.Join(t.TempDir(), "agentlab.db")
+	live, err := Open(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, live.CreateJob(ctx, models.Job{
+		ID:        "job-after-backup",
+		RepoURL:   "https://github.com/example/repo",
+		Ref:       "main",
+		Profile:   "default",
+		Status:    models.JobQueued,
+		CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}))
+	require.NoError(t, live.Close())
+
+	_, err = StageRestore(ctx, dbPath, []byte("garbage"))
+	require.Error(t, err)
+	_, err = os.Stat(RestoreStagePath(dbPath))
+	assert.True(t, os.IsNotExist(err), "rejected backup must not be staged")
+
+	version, err := StageRestore(ctx, dbPath, data)
+	require.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	now := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	previous, err := ApplyStagedRestore(dbPath, now)
+	require.NoError(t, err)
+	assert.Equal(t, dbPath+".pre-restore-20240304T050607Z", previous)
+	_, err = os.Stat(RestoreStagePath(dbPath))
+	assert.True(t, os.IsNotExist(err))
+
+	restored, err := Open(dbPath)
+	require.NoError(t, err)
+	defer restored.Close()
+	_, err = restored.GetJob(ctx, "job-after-backup")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	aside, err := Open(previous)
+	require.NoError(t, err)
+	defer aside.Close()
+	_, err = aside.GetJob(ctx, "job-after-backup")
+	require.NoError(t, err)
+
+	previous, err = ApplyStagedRestore(dbPath, now)
+	require.NoError(t, err)
+	assert.Empty(t, previous, "no staged restore is a no-op")
+}
@@ -23,6 +23,10 @@ type Event struct {
 	JobID       *string
 	Message     string
 	JSON        string
+	// TraceID and SpanID identify the span the event was recorded under,
+	// when tracing is enabled.
+	TraceID string
+	SpanID  string
 }
 
 // ListEventsBySandbox returns events for a sandbox after a given ID.
@@ -40,7 +44,7 @@ func (s *Store) ListEventsBySandbox(ctx context.Context, vmid int, afterID int64
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events WHERE sandbox_vmid = ? AND id > ? ORDER BY id ASC LIMIT ?`, vmid, afterID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("list events: %w", err)
@@ -65,7 +69,7 @@ func (s *Store) ListAllEvents(ctx context.Context) ([]Event, error) {
 	if s == nil || s.DB == nil {
 		return nil, errors.New("db store is nil")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events ORDER BY id ASC`)
 	if err != nil {
 		return nil, fmt.Errorf("list all events: %w", err)
@@ -101,7 +105,7 @@ func (s *Store) ListEventsByJob(ctx context.Context, jobID string, afterID int64
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events WHERE job_id = ? AND id > ? ORDER BY id ASC LIMIT ?`, jobID, afterID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("list events: %w", err)
@@ -130,7 +134,7 @@ func (s *Store) ListEventsByJobAll(ctx context.Context, jobID string) ([]Event,
 	if jobID == "" {
 		return nil, errors.New("job id is required")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events WHERE job_id = ? ORDER BY id ASC`, jobID)
 	if err != nil {
 		return nil, fmt.Errorf("list job events: %w", err)
@@ -158,7 +162,7 @@ func (s *Store) ListEventsBySandboxAll(ctx context.Context, vmid int) ([]Event,
 	if vmid <= 0 {
 		return nil, errors.New("vmid must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events WHERE sandbox_vmid = ? ORDER BY id ASC`, vmid)
 	if err != nil {
 		return nil, fmt.Errorf("list sandbox events: %w", err)
@@ -193,7 +197,7 @@ func (s *Store) ListEventsBySandboxTail(ctx context.Context, vmid int, limit int
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events WHERE sandbox_vmid = ? ORDER BY id DESC LIMIT ?`, vmid, limit)
 	if err != nil {
 		return nil, fmt.Errorf("list events tail: %w", err)
@@ -232,7 +236,7 @@ func (s *Store) ListEventsByJobTail(ctx context.Context, jobID string, limit int
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events WHERE job_id = ? ORDER BY id DESC LIMIT ?`, jobID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("list events tail: %w", err)
@@ -266,7 +270,7 @@ func (s *Store) ListRecentFailureEvents(ctx context.Context, limit int) ([]Event
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
 		FROM events
 		WHERE lower(kind) LIKE ? OR lower(kind) LIKE ?
 		ORDER BY id DESC LIMIT ?`, "%failed%", "%timeout%", limit)
@@ -299,7 +303,9 @@ func scanEventRow(scanner interface{ Scan(dest ...any) error }) (Event, error) {
 	var jobID sql.NullString
 	var msg sql.NullString
 	var jsonPayload sql.NullString
-	if err := scanner.Scan(&ev.ID, &ts, &kind, &sandboxVMID, &jobID, &msg, &jsonPayload); err != nil {
+	var traceID sql.NullString
+	var spanID sql.NullString
+	if err := scanner.Scan(&ev.ID, &ts, &kind, &sandboxVMID, &jobID, &msg, &jsonPayload, &traceID, &spanID); err != nil {
 		return Event{}, err
 	}
 	if ts != "" {
@@ -324,5 +330,33 @@ func scanEventRow(scanner interface{ Scan(dest ...any) error }) (Event, error) {
 	if jsonPayload.Valid {
 		ev.JSON = jsonPayload.String
 	}
+	ev.TraceID = traceID.String
+	ev.SpanID = spanID.String
 	return ev, nil
 }
+
+// ListEventsByKindBefore returns every event of kind recorded before the
+// given time, in ascending ID order.
+func (s *Store) ListEventsByKindBefore(ctx context.Context, kind string, before time.Time) ([]Event, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
+		FROM events WHERE kind = ? AND ts < ? ORDER BY id ASC`, kind, formatTime(before))
+	if err != nil {
+		return nil, fmt.Errorf("list %s events: %w", kind, err)
+	}
+	defer rows.Close()
+	var out []Event
+	for rows.Next() {
+		ev, err := scanEventRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate events: %w", err)
+	}
+	return out, nil
+}
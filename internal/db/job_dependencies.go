@@ -0,0 +1,59 @@
+// ABOUTME: Lookups for jobs held in QUEUED until their parent jobs finish.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+// ListJobsWaitingOn returns QUEUED jobs that list parentID in depends_on,
+// oldest first.
+func (s *Store) ListJobsWaitingOn(ctx context.Context, parentID string) ([]models.Job, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	parentID = strings.TrimSpace(parentID)
+	if parentID == "" {
+		return nil, errors.New("parent job id is required")
+	}
+	// instr on comma-delimited values matches whole ids only; LIKE would treat
+	// the '_' in job ids as a wildcard.
+	return s.listWaitingJobs(ctx, `AND instr(',' || depends_on || ',', ',' || ? || ',') > 0`, parentID)
+}
+
+// ListWaitingJobs returns every QUEUED job that declares dependencies, oldest
+// first. The daemon re-evaluates them at startup in case a parent finished
+// while the children were not yet released.
+func (s *Store) ListWaitingJobs(ctx context.Context) ([]models.Job, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	return s.listWaitingJobs(ctx, "")
+}
+
+func (s *Store) listWaitingJobs(ctx context.Context, filter string, args ...any) ([]models.Job, error) {
+	query := `SELECT ` + jobColumns + `
+		FROM jobs WHERE status = ? AND depends_on IS NOT NULL AND depends_on != '' ` + filter + `
+		ORDER BY created_at ASC, rowid ASC`
+	rows, err := s.DB.QueryContext(ctx, query, append([]any{models.JobQueued}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("list waiting jobs: %w", err)
+	}
+	defer rows.Close()
+	var out []models.Job
+	for rows.Next() {
+		job, err := scanJobRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate waiting jobs: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agentlab/agentlab/internal/models"
+	testutil "github.com/agentlab/agentlab/internal/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobDependencies(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	require.NoError(t, store.CreateJob(ctx, testutil.NewTestJob(testutil.JobOpts{ID: "job_1"})))
+	require.NoError(t, store.CreateJob(ctx, testutil.NewTestJob(testutil.JobOpts{ID: "job_12"})))
+
+	child := testutil.NewTestJob(testutil.JobOpts{ID: "job_child", Status: models.JobQueued})
+	child.DependsOn = []string{"job_1", "job_12"}
+	child.ParentArtifacts = true
+	require.NoError(t, store.CreateJob(ctx, child))
+
+	other := testutil.NewTestJob(testutil.JobOpts{ID: "job_other", Status: models.JobQueued})
+	other.DependsOn = []string{"job_12"}
+	other.DependsCondition = models.JobDependsAlways
+	require.NoError(t, store.CreateJob(ctx, other))
+
+	got, err := store.GetJob(ctx, "job_child")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job_1", "job_12"}, got.DependsOn)
+	assert.Equal(t, models.JobDependsOnSuccess, got.DependsCondition, "condition defaults to success")
+	assert.True(t, got.ParentArtifacts)
+
+	plain, err := store.GetJob(ctx, "job_1")
+	require.NoError(t, err)
+	assert.Empty(t, plain.DependsOn)
+	assert.Empty(t, plain.DependsCondition)
+	assert.False(t, plain.ParentArtifacts)
+
+	waiting, err := store.ListJobsWaitingOn(ctx, "job_1")
+	require.NoError(t, err)
+	require.Len(t, waiting, 1, "job_1 must not match job_12 as a prefix")
+	assert.Equal(t, "job_child", waiting[0].ID)
+
+	waiting, err = store.ListJobsWaitingOn(ctx, "job_12")
+	require.NoError(t, err)
+	require.Len(t, waiting, 2)
+
+	all, err := store.ListWaitingJobs(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	require.NoError(t, store.UpdateJobStatus(ctx, "job_other", models.JobRunning))
+	waiting, err = store.ListJobsWaitingOn(ctx, "job_12")
+	require.NoError(t, err)
+	require.Len(t, waiting, 1, "only QUEUED children are waiting")
+	assert.Equal(t, "job_child", waiting[0].ID)
+
+	_, err = store.ListJobsWaitingOn(ctx, " ")
+	assert.EqualError(t, err, "parent job id is required")
+
+	bad := testutil.NewTestJob(testutil.JobOpts{ID: "job_bad"})
+	bad.DependsOn = []string{"a,b"}
+	assert.Error(t, store.CreateJob(ctx, bad))
+}
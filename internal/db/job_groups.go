@@ -0,0 +1,126 @@
+// ABOUTME: Job group database operations for matrix (fan-out) job runs.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+// CreateJobGroup inserts a job group and its child jobs in one transaction, so
+// a group is never visible with only part of its matrix. Each child's GroupID
+// is set to the group ID.
+func (s *Store) CreateJobGroup(ctx context.Context, group models.JobGroup, jobs []models.Job) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	group.ID = strings.TrimSpace(group.ID)
+	if group.ID == "" {
+		return errors.New("job group id is required")
+	}
+	if strings.TrimSpace(group.SpecJSON) == "" {
+		return errors.New("job group spec_json is required")
+	}
+	if len(jobs) == 0 {
+		return errors.New("job group requires at least one job")
+	}
+	now := time.Now().UTC()
+	createdAt := group.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	updatedAt := group.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin job group %s: %w", group.ID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO job_groups (id, name, spec_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		group.ID,
+		strings.TrimSpace(group.Name),
+		group.SpecJSON,
+		formatTime(createdAt),
+		formatTime(updatedAt),
+	); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("insert job group %s: %w", group.ID, err)
+	}
+	groupID := group.ID
+	for _, job := range jobs {
+		job.GroupID = &groupID
+		if err := insertJob(ctx, tx, job); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit job group %s: %w", group.ID, err)
+	}
+	return nil
+}
+
+// GetJobGroup loads a job group by id.
+func (s *Store) GetJobGroup(ctx context.Context, id string) (models.JobGroup, error) {
+	if s == nil || s.DB == nil {
+		return models.JobGroup{}, errors.New("db store is nil")
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return models.JobGroup{}, errors.New("job group id is required")
+	}
+	var group models.JobGroup
+	var createdAt string
+	var updatedAt string
+	err := s.DB.QueryRowContext(ctx, `SELECT id, name, spec_json, created_at, updated_at
+		FROM job_groups WHERE id = ?`, id).Scan(&group.ID, &group.Name, &group.SpecJSON, &createdAt, &updatedAt)
+	if err != nil {
+		return models.JobGroup{}, err
+	}
+	group.CreatedAt, err = parseTime(createdAt)
+	if err != nil {
+		return models.JobGroup{}, fmt.Errorf("parse created_at: %w", err)
+	}
+	group.UpdatedAt, err = parseTime(updatedAt)
+	if err != nil {
+		return models.JobGroup{}, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return group, nil
+}
+
+// ListJobsByGroup returns the child jobs of a group in insertion order, which
+// is the order the matrix was expanded in. Children share a created_at, so the
+// rowid breaks the tie.
+func (s *Store) ListJobsByGroup(ctx context.Context, groupID string) ([]models.Job, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	groupID = strings.TrimSpace(groupID)
+	if groupID == "" {
+		return nil, errors.New("job group id is required")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+jobColumns+`
+		FROM jobs WHERE group_id = ? ORDER BY rowid ASC`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs for group %s: %w", groupID, err)
+	}
+	defer rows.Close()
+	var out []models.Job
+	for rows.Next() {
+		job, err := scanJobRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate jobs for group %s: %w", groupID, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/agentlab/agentlab/internal/models"
+	testutil "github.com/agentlab/agentlab/internal/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateJobGroup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		store := openTestStore(t)
+		group := models.JobGroup{ID: "jobgrp-1", Name: "refs", SpecJSON: `{"matrix":{"ref":["main","dev"]}}`}
+		jobs := []models.Job{
+			testutil.NewTestJob(testutil.JobOpts{ID: "job-b", Ref: "main"}),
+			testutil.NewTestJob(testutil.JobOpts{ID: "job-a", Ref: "dev"}),
+		}
+		jobs[0].MatrixJSON = `{"ref":"main"}`
+		jobs[1].MatrixJSON = `{"ref":"dev"}`
+		jobs[1].EnvJSON = `{"MODEL":"fast"}`
+		require.NoError(t, store.CreateJobGroup(ctx, group, jobs))
+
+		got, err := store.GetJobGroup(ctx, "jobgrp-1")
+		require.NoError(t, err)
+		assert.Equal(t, "refs", got.Name)
+		assert.Equal(t, group.SpecJSON, got.SpecJSON)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		children, err := store.ListJobsByGroup(ctx, "jobgrp-1")
+		require.NoError(t, err)
+		require.Len(t, children, 2)
+		// Insertion order, not id order.
+		assert.Equal(t, "job-b", children[0].ID)
+		assert.Equal(t, "job-a", children[1].ID)
+		require.NotNil(t, children[1].GroupID)
+		assert.Equal(t, "jobgrp-1", *children[1].GroupID)
+		assert.Equal(t, `{"ref":"dev"}`, children[1].MatrixJSON)
+		assert.Equal(t, `{"MODEL":"fast"}`, children[1].EnvJSON)
+	})
+
+	t.Run("rolls back on child failure", func(t *testing.T) {
+		store := openTestStore(t)
+		jobs := []models.Job{
+			testutil.NewTestJob(testutil.JobOpts{ID: "job-1"}),
+			testutil.NewTestJob(testutil.JobOpts{ID: "job-1"}),
+		}
+		err := store.CreateJobGroup(ctx, models.JobGroup{ID: "jobgrp-1", SpecJSON: "{}"}, jobs)
+		require.Error(t, err)
+
+		_, err = store.GetJobGroup(ctx, "jobgrp-1")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		_, err = store.GetJob(ctx, "job-1")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		store := openTestStore(t)
+		job := testutil.NewTestJob(testutil.JobOpts{})
+		assert.EqualError(t, (*Store)(nil).CreateJobGroup(ctx, models.JobGroup{}, nil), "db store is nil")
+		assert.EqualError(t, store.CreateJobGroup(ctx, models.JobGroup{SpecJSON: "{}"}, []models.Job{job}), "job group id is required")
+		assert.EqualError(t, store.CreateJobGroup(ctx, models.JobGroup{ID: "g"}, []models.Job{job}), "job group spec_json is required")
+		assert.EqualError(t, store.CreateJobGroup(ctx, models.JobGroup{ID: "g", SpecJSON: "{}"}, nil), "job group requires at least one job")
+	})
+}
+
+func TestListJobsByGroup(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	require.NoError(t, store.CreateJob(ctx, testutil.NewTestJob(testutil.JobOpts{ID: "job-solo"})))
+
+	children, err := store.ListJobsByGroup(ctx, "jobgrp-missing")
+	require.NoError(t, err)
+	assert.Empty(t, children)
+
+	solo, err := store.GetJob(ctx, "job-solo")
+	require.NoError(t, err)
+	assert.Nil(t, solo.GroupID)
+	assert.Empty(t, solo.MatrixJSON)
+
+	_, err = store.ListJobsByGroup(ctx, " ")
+	assert.EqualError(t, err, "job group id is required")
+}
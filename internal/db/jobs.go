@@ -12,11 +12,24 @@ import (
 	"github.com/agentlab/agentlab/internal/models"
 )
 
+// jobColumns is the column list shared by every job SELECT; scanJobRow reads
+// values in this order.
+const jobColumns = `id, repo_url, ref, profile, task, mode, ttl_minutes, keepalive, status, sandbox_vmid, workspace_id, session_id, group_id, matrix_json, env_json, depends_on, depends_condition, parent_artifacts, publish_json, created_at, updated_at, result_json`
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // CreateJob inserts a new job row into the database.
 func (s *Store) CreateJob(ctx context.Context, job models.Job) error {
 	if s == nil || s.DB == nil {
 		return errors.New("db store is nil")
 	}
+	return insertJob(ctx, s.DB, job)
+}
+
+func insertJob(ctx context.Context, exec execer, job models.Job) error {
 	if job.ID == "" {
 		return errors.New("job id is required")
 	}
@@ -57,13 +70,30 @@ func (s *Store) CreateJob(ctx context.Context, job models.Job) error {
 	if job.SessionID != nil && strings.TrimSpace(*job.SessionID) != "" {
 		session = strings.TrimSpace(*job.SessionID)
 	}
+	var group interface{}
+	if job.GroupID != nil && strings.TrimSpace(*job.GroupID) != "" {
+		group = strings.TrimSpace(*job.GroupID)
+	}
+	var dependsOn, dependsCondition interface{}
+	if len(job.DependsOn) > 0 {
+		for _, parent := range job.DependsOn {
+			if strings.TrimSpace(parent) == "" || strings.Contains(parent, ",") {
+				return fmt.Errorf("job depends_on has invalid id %q", parent)
+			}
+		}
+		dependsOn = strings.Join(job.DependsOn, ",")
+		dependsCondition = string(job.DependsCondition)
+		if dependsCondition == "" {
+			dependsCondition = string(models.JobDependsOnSuccess)
+		}
+	}
 	var result interface{}
 	if job.ResultJSON != "" {
 		result = job.ResultJSON
 	}
-	_, err := s.DB.ExecContext(ctx, `INSERT INTO jobs (
-		id, repo_url, ref, profile, status, sandbox_vmid, task, mode, ttl_minutes, keepalive, workspace_id, session_id, created_at, updated_at, result_json
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	_, err := exec.ExecContext(ctx, `INSERT INTO jobs (
+		id, repo_url, ref, profile, status, sandbox_vmid, task, mode, ttl_minutes, keepalive, workspace_id, session_id, group_id, matrix_json, env_json, depends_on, depends_condition, parent_artifacts, publish_json, created_at, updated_at, result_json
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		job.ID,
 		job.RepoURL,
 		job.Ref,
@@ -76,6 +106,13 @@ func (s *Store) CreateJob(ctx context.Context, job models.Job) error {
 		job.Keepalive,
 		workspace,
 		session,
+		group,
+		nullIfEmpty(job.MatrixJSON),
+		nullIfEmpty(job.EnvJSON),
+		dependsOn,
+		dependsCondition,
+		job.ParentArtifacts,
+		nullIfEmpty(job.PublishJSON),
 		formatTime(createdAt),
 		formatTime(updatedAt),
 		result,
@@ -91,7 +128,7 @@ func (s *Store) GetJob(ctx context.Context, id string) (models.Job, error) {
 	if s == nil || s.DB == nil {
 		return models.Job{}, errors.New("db store is nil")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, repo_url, ref, profile, task, mode, ttl_minutes, keepalive, status, sandbox_vmid, workspace_id, session_id, created_at, updated_at, result_json
+	row := s.DB.QueryRowContext(ctx, `SELECT `+jobColumns+`
 		FROM jobs WHERE id = ?`, id)
 	return scanJobRow(row)
 }
@@ -104,7 +141,7 @@ func (s *Store) GetJobBySandboxVMID(ctx context.Context, vmid int) (models.Job,
 	if vmid <= 0 {
 		return models.Job{}, errors.New("vmid must be positive")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, repo_url, ref, profile, task, mode, ttl_minutes, keepalive, status, sandbox_vmid, workspace_id, session_id, created_at, updated_at, result_json
+	row := s.DB.QueryRowContext(ctx, `SELECT `+jobColumns+`
 		FROM jobs WHERE sandbox_vmid = ?
 		ORDER BY created_at DESC LIMIT 1`, vmid)
 	return scanJobRow(row)
@@ -187,6 +224,29 @@ func (s *Store) UpdateJobStatus(ctx context.Context, id string, status models.Jo
 	return nil
 }
 
+// TouchJob advances a job's updated_at without changing its status or
+// result. Runner heartbeats use it to show the guest is still alive.
+func (s *Store) TouchJob(ctx context.Context, id string, at time.Time) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if id == "" {
+		return errors.New("job id is required")
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE jobs SET updated_at = ? WHERE id = ?`, formatTime(at.UTC()), id)
+	if err != nil {
+		return fmt.Errorf("touch job %s: %w", id, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected job %s: %w", id, err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // UpdateJobResult updates status and result_json for a job.
 func (s *Store) UpdateJobResult(ctx context.Context, id string, status models.JobStatus, resultJSON string) error {
 	if s == nil || s.DB == nil {
@@ -227,6 +287,13 @@ func scanJobRow(scanner interface{ Scan(dest ...any) error }) (models.Job, error
 	var sandbox sql.NullInt64
 	var workspace sql.NullString
 	var session sql.NullString
+	var group sql.NullString
+	var matrix sql.NullString
+	var env sql.NullString
+	var dependsOn sql.NullString
+	var dependsCondition sql.NullString
+	var parentArtifacts bool
+	var publish sql.NullString
 	var createdAt string
 	var updatedAt string
 	var result sql.NullString
@@ -243,6 +310,13 @@ func scanJobRow(scanner interface{ Scan(dest ...any) error }) (models.Job, error
 		&sandbox,
 		&workspace,
 		&session,
+		&group,
+		&matrix,
+		&env,
+		&dependsOn,
+		&dependsCondition,
+		&parentArtifacts,
+		&publish,
 		&createdAt,
 		&updatedAt,
 		&result,
@@ -277,6 +351,24 @@ func scanJobRow(scanner interface{ Scan(dest ...any) error }) (models.Job, error
 		value := session.String
 		job.SessionID = &value
 	}
+	if group.Valid {
+		value := group.String
+		job.GroupID = &value
+	}
+	if matrix.Valid {
+		job.MatrixJSON = matrix.String
+	}
+	if env.Valid {
+		job.EnvJSON = env.String
+	}
+	if dependsOn.Valid && dependsOn.String != "" {
+		job.DependsOn = strings.Split(dependsOn.String, ",")
+		job.DependsCondition = models.JobDependencyCondition(dependsCondition.String)
+	}
+	job.ParentArtifacts = parentArtifacts
+	if publish.Valid {
+		job.PublishJSON = publish.String
+	}
 	var err error
 	if createdAt != "" {
 		job.CreatedAt, err = parseTime(createdAt)
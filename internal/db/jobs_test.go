@@ -399,6 +399,26 @@ func TestUpdateJobStatus(t *testing.T) {
 	})
 }
 
+func TestTouchJob(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	job := testutil.NewTestJob(testutil.JobOpts{ID: "job-1", Status: models.JobRunning})
+	require.NoError(t, store.CreateJob(ctx, job))
+	require.NoError(t, store.UpdateJobResult(ctx, "job-1", models.JobRunning, `{"message":"agent starting"}`))
+
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, store.TouchJob(ctx, "job-1", at))
+	got, err := store.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.True(t, got.UpdatedAt.Equal(at), "updated_at = %s", got.UpdatedAt)
+	assert.Equal(t, models.JobRunning, got.Status)
+	assert.Equal(t, `{"message":"agent starting"}`, got.ResultJSON)
+
+	assert.Equal(t, sql.ErrNoRows, store.TouchJob(ctx, "nonexistent", at))
+	assert.EqualError(t, store.TouchJob(ctx, "", at), "job id is required")
+	assert.EqualError(t, (*Store)(nil).TouchJob(ctx, "x", at), "db store is nil")
+}
+
 func TestUpdateJobResult(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,87 @@
+// ABOUTME: Token usage reported by LLM providers for proxied sandbox requests.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LLMUsage is the token usage of one proxied LLM request.
+type LLMUsage struct {
+	ID           int64
+	VMID         int
+	Integration  string
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+	CreatedAt    time.Time
+}
+
+// LLMUsageTotal sums a sandbox's token usage over a time range.
+type LLMUsageTotal struct {
+	VMID         int
+	Requests     int64
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// RecordLLMUsage stores the token usage of one proxied request.
+func (s *Store) RecordLLMUsage(ctx context.Context, usage LLMUsage) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if usage.VMID <= 0 {
+		return errors.New("vmid must be positive")
+	}
+	if strings.TrimSpace(usage.Integration) == "" {
+		return errors.New("integration is required")
+	}
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO llm_usage (vmid, integration, provider, model, input_tokens, output_tokens, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		usage.VMID,
+		usage.Integration,
+		usage.Provider,
+		usage.Model,
+		usage.InputTokens,
+		usage.OutputTokens,
+		formatTime(usage.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("record llm usage for sandbox %d: %w", usage.VMID, err)
+	}
+	return nil
+}
+
+// SumLLMUsageBySandbox totals token usage per sandbox for requests recorded
+// in [from, to).
+func (s *Store) SumLLMUsageBySandbox(ctx context.Context, from, to time.Time) (map[int]LLMUsageTotal, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT vmid, COUNT(*), SUM(input_tokens), SUM(output_tokens)
+		FROM llm_usage WHERE created_at >= ? AND created_at < ?
+		GROUP BY vmid`, formatTime(from), formatTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("sum llm usage: %w", err)
+	}
+	defer rows.Close()
+	out := make(map[int]LLMUsageTotal)
+	for rows.Next() {
+		var total LLMUsageTotal
+		if err := rows.Scan(&total.VMID, &total.Requests, &total.InputTokens, &total.OutputTokens); err != nil {
+			return nil, fmt.Errorf("scan llm usage: %w", err)
+		}
+		out[total.VMID] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sum llm usage: %w", err)
+	}
+	return out, nil
+}
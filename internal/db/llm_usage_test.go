@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testutil "github.com/agentlab/agentlab/internal/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLLMUsageSums(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	records := []LLMUsage{
+		{VMID: testutil.TestVMID, Integration: "openai", Provider: "openai", Model: "gpt-4o", InputTokens: 10, OutputTokens: 20, CreatedAt: base},
+		{VMID: testutil.TestVMID, Integration: "openai", Provider: "openai", InputTokens: 5, OutputTokens: 1, CreatedAt: base.Add(time.Hour)},
+		{VMID: testutil.TestVMID + 1, Integration: "claude", Provider: "anthropic", InputTokens: 7, CreatedAt: base.Add(30 * time.Minute)},
+		{VMID: testutil.TestVMID, Integration: "openai", Provider: "openai", InputTokens: 100, CreatedAt: base.Add(2 * time.Hour)},
+	}
+	for _, rec := range records {
+		require.NoError(t, store.RecordLLMUsage(ctx, rec))
+	}
+
+	totals, err := store.SumLLMUsageBySandbox(ctx, base, base.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, totals, 2)
+	assert.Equal(t, LLMUsageTotal{VMID: testutil.TestVMID, Requests: 2, InputTokens: 15, OutputTokens: 21}, totals[testutil.TestVMID])
+	assert.Equal(t, int64(7), totals[testutil.TestVMID+1].InputTokens)
+
+	require.Error(t, store.RecordLLMUsage(ctx, LLMUsage{Integration: "openai"}))
+	require.Error(t, store.RecordLLMUsage(ctx, LLMUsage{VMID: testutil.TestVMID}))
+}
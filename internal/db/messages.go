@@ -11,6 +11,7 @@ import (
 )
 
 // Message represents an append-only message stored in the messagebox.
+// ReplyTo is the id of the message this one answers, or 0.
 type Message struct {
 	ID        int64
 	Timestamp time.Time
@@ -20,8 +21,22 @@ type Message struct {
 	Kind      string
 	Text      string
 	JSON      string
+	ReplyTo   int64
 }
 
+// Message kinds with a meaning to the daemon. Other kinds are free-form.
+const (
+	MessageKindNote     = "note"
+	MessageKindQuestion = "question"
+	MessageKindAnswer   = "answer"
+)
+
+// ErrMessageAnswered is returned when replying to a message that already has
+// a reply.
+var ErrMessageAnswered = errors.New("message already answered")
+
+const messageColumns = `id, ts, scope_type, scope_id, author, kind, text, json, reply_to`
+
 // CreateMessage inserts a new message into the database.
 func (s *Store) CreateMessage(ctx context.Context, message Message) (Message, error) {
 	if s == nil || s.DB == nil {
@@ -45,8 +60,15 @@ func (s *Store) CreateMessage(ctx context.Context, message Message) (Message, er
 		timestamp = time.Now().UTC()
 	}
 
-	result, err := s.DB.ExecContext(ctx, `INSERT INTO messages (ts, scope_type, scope_id, author, kind, text, json)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+	var replyTo any
+	if message.ReplyTo > 0 {
+		replyTo = message.ReplyTo
+	}
+	// A reply is written only while its parent has none, in one statement, so
+	// two concurrent answers cannot both land.
+	result, err := s.DB.ExecContext(ctx, `INSERT INTO messages (ts, scope_type, scope_id, author, kind, text, json, reply_to)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE ? IS NULL OR NOT EXISTS (SELECT 1 FROM messages WHERE reply_to = ?)`,
 		formatTime(timestamp),
 		message.ScopeType,
 		message.ScopeID,
@@ -54,10 +76,16 @@ func (s *Store) CreateMessage(ctx context.Context, message Message) (Message, er
 		nullIfEmpty(message.Kind),
 		nullIfEmpty(message.Text),
 		nullIfEmpty(message.JSON),
+		replyTo,
+		replyTo,
+		replyTo,
 	)
 	if err != nil {
 		return Message{}, fmt.Errorf("insert message: %w", err)
 	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return Message{}, ErrMessageAnswered
+	}
 	id, err := result.LastInsertId()
 	if err != nil {
 		return Message{}, fmt.Errorf("message last insert id: %w", err)
@@ -86,7 +114,7 @@ func (s *Store) ListMessagesByScope(ctx context.Context, scopeType, scopeID stri
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, scope_type, scope_id, author, kind, text, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+messageColumns+`
 		FROM messages WHERE scope_type = ? AND scope_id = ? AND id > ? ORDER BY id ASC LIMIT ?`,
 		scopeType, scopeID, afterID, limit)
 	if err != nil {
@@ -123,7 +151,7 @@ func (s *Store) ListMessagesByScopeTail(ctx context.Context, scopeType, scopeID
 	if limit <= 0 {
 		return nil, errors.New("limit must be positive")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, scope_type, scope_id, author, kind, text, json
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+messageColumns+`
 		FROM messages WHERE scope_type = ? AND scope_id = ? ORDER BY id DESC LIMIT ?`,
 		scopeType, scopeID, limit)
 	if err != nil {
@@ -147,6 +175,42 @@ func (s *Store) ListMessagesByScopeTail(ctx context.Context, scopeType, scopeID
 	return out, nil
 }
 
+// GetMessage loads a message by id. It returns sql.ErrNoRows when there is
+// none.
+func (s *Store) GetMessage(ctx context.Context, id int64) (Message, error) {
+	if s == nil || s.DB == nil {
+		return Message{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = ?`, id)
+	return scanMessageRow(row)
+}
+
+// GetMessageReply loads the reply to message id. It returns sql.ErrNoRows
+// while the message is unanswered.
+func (s *Store) GetMessageReply(ctx context.Context, id int64) (Message, error) {
+	if s == nil || s.DB == nil {
+		return Message{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+messageColumns+`
+		FROM messages WHERE reply_to = ? ORDER BY id ASC LIMIT 1`, id)
+	return scanMessageRow(row)
+}
+
+// ListOpenQuestions returns unanswered question messages across every scope,
+// oldest first.
+func (s *Store) ListOpenQuestions(ctx context.Context, limit int) ([]Message, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	return s.queryMessages(ctx, `SELECT `+messageColumns+`
+		FROM messages q
+		WHERE kind = ? AND NOT EXISTS (SELECT 1 FROM messages a WHERE a.reply_to = q.id)
+		ORDER BY id ASC LIMIT ?`, MessageKindQuestion, limit)
+}
+
 func scanMessageRow(scanner interface{ Scan(dest ...any) error }) (Message, error) {
 	var msg Message
 	var ts string
@@ -156,9 +220,11 @@ func scanMessageRow(scanner interface{ Scan(dest ...any) error }) (Message, erro
 	var kind sql.NullString
 	var text sql.NullString
 	var jsonPayload sql.NullString
-	if err := scanner.Scan(&msg.ID, &ts, &scopeType, &scopeID, &author, &kind, &text, &jsonPayload); err != nil {
+	var replyTo sql.NullInt64
+	if err := scanner.Scan(&msg.ID, &ts, &scopeType, &scopeID, &author, &kind, &text, &jsonPayload, &replyTo); err != nil {
 		return Message{}, err
 	}
+	msg.ReplyTo = replyTo.Int64
 	if ts != "" {
 		parsed, err := parseTime(ts)
 		if err != nil {
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -60,3 +61,60 @@ func TestMessageCreateValidation(t *testing.T) {
 	_, err = store.CreateMessage(ctx, Message{ScopeType: "job"})
 	require.EqualError(t, err, "message scope_id is required")
 }
+
+func TestMessageQuestionReply(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	question, err := store.CreateMessage(ctx, Message{
+		ScopeType: "job",
+		ScopeID:   "job-1",
+		Author:    "sandbox:1001",
+		Kind:      MessageKindQuestion,
+		Text:      "May I drop the users table?",
+		JSON:      `{"options":["yes","no"]}`,
+	})
+	require.NoError(t, err)
+
+	_, err = store.GetMessageReply(ctx, question.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	open, err := store.ListOpenQuestions(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	require.Equal(t, question.ID, open[0].ID)
+
+	reply, err := store.CreateMessage(ctx, Message{
+		ScopeType: "job",
+		ScopeID:   "job-1",
+		Author:    "alice",
+		Kind:      MessageKindAnswer,
+		Text:      "no",
+		ReplyTo:   question.ID,
+	})
+	require.NoError(t, err)
+
+	// A second answer is refused.
+	_, err = store.CreateMessage(ctx, Message{
+		ScopeType: "job",
+		ScopeID:   "job-1",
+		Kind:      MessageKindAnswer,
+		Text:      "yes",
+		ReplyTo:   question.ID,
+	})
+	require.ErrorIs(t, err, ErrMessageAnswered)
+
+	got, err := store.GetMessageReply(ctx, question.ID)
+	require.NoError(t, err)
+	require.Equal(t, reply.ID, got.ID)
+	require.Equal(t, question.ID, got.ReplyTo)
+	require.Equal(t, "no", got.Text)
+
+	loaded, err := store.GetMessage(ctx, question.ID)
+	require.NoError(t, err)
+	require.Equal(t, MessageKindQuestion, loaded.Kind)
+	require.Zero(t, loaded.ReplyTo)
+
+	open, err = store.ListOpenQuestions(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, open)
+}
@@ -385,6 +385,392 @@ var migrations = []migration{
 			)`,
 		},
 	},
+	{
+		version: 20,
+		name:    "add_job_groups",
+		// A job group fans one base spec out across matrix axes. Children keep
+		// the cell they were expanded from so the group can render a grid, and
+		// env overrides travel with the job into bootstrap.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS job_groups (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL DEFAULT '',
+				spec_json TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				updated_at TEXT NOT NULL
+			)`,
+			`ALTER TABLE jobs ADD COLUMN group_id TEXT`,
+			`ALTER TABLE jobs ADD COLUMN matrix_json TEXT`,
+			`ALTER TABLE jobs ADD COLUMN env_json TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_jobs_group ON jobs(group_id)`,
+		},
+	},
+	{
+		version: 21,
+		name:    "add_job_dependencies",
+		// Jobs may wait on parent jobs. The parent list is small and only read
+		// alongside the job, so it lives on the row as comma-separated ids;
+		// lookups for waiting children scan the QUEUED set through
+		// idx_jobs_status.
+		statements: []string{
+			`ALTER TABLE jobs ADD COLUMN depends_on TEXT`,
+			`ALTER TABLE jobs ADD COLUMN depends_condition TEXT`,
+			`ALTER TABLE jobs ADD COLUMN parent_artifacts INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 22,
+		name:    "add_job_publish",
+		// Result publishing options (push branch, pull request) are decoded by
+		// the daemon, like env_json.
+		statements: []string{
+			`ALTER TABLE jobs ADD COLUMN publish_json TEXT`,
+		},
+	},
+	{
+		version: 23,
+		name:    "add_artifact_kind",
+		statements: []string{
+			`ALTER TABLE artifacts ADD COLUMN kind TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_artifacts_job_kind ON artifacts(job_id, kind)`,
+		},
+	},
+	{
+		version: 24,
+		name:    "add_event_snapshots",
+		// Compaction folds expired events into a single projection snapshot
+		// before deleting them. Events with id <= through_event_id are already
+		// reflected in state_json, whether or not they still exist.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS event_snapshots (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				through_event_id INTEGER NOT NULL,
+				compacted_events INTEGER NOT NULL DEFAULT 0,
+				state_json TEXT NOT NULL,
+				updated_at TEXT NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_events_ts ON events(ts)`,
+		},
+	},
+	{
+		version: 25,
+		name:    "add_secret_access_requests",
+		// A sandbox reading an approval-gated secret files a request. An
+		// approved request is a time-boxed grant until grant_expires_at.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS secret_access_requests (
+				id TEXT PRIMARY KEY,
+				secret_name TEXT NOT NULL,
+				sandbox_vmid INTEGER NOT NULL,
+				sandbox_name TEXT NOT NULL DEFAULT '',
+				job_id TEXT,
+				profile TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL,
+				grant_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+				requested_at TEXT NOT NULL,
+				decided_at TEXT,
+				decided_by TEXT,
+				grant_expires_at TEXT
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_secret_access_requests_sandbox ON secret_access_requests(sandbox_vmid, secret_name)`,
+			`CREATE INDEX IF NOT EXISTS idx_secret_access_requests_status ON secret_access_requests(status)`,
+		},
+	},
+	{
+		version: 26,
+		name:    "add_integration_key_version",
+		// Each integration secret records the version of the keyring key that
+		// encrypted it, so the key can be rotated. Existing rows used the
+		// single original key, version 1.
+		statements: []string{
+			`ALTER TABLE integrations ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		version: 27,
+		name:    "add_sandbox_kv",
+		// Guest-written status values, one row per sandbox and key. job_id
+		// records the job that was running when the value was last written.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS sandbox_kv (
+				vmid INTEGER NOT NULL,
+				key TEXT NOT NULL,
+				value TEXT NOT NULL,
+				job_id TEXT,
+				updated_at TEXT NOT NULL,
+				PRIMARY KEY (vmid, key),
+				FOREIGN KEY(vmid) REFERENCES sandboxes(vmid) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		version: 28,
+		name:    "add_message_reply_to",
+		// A reply names the message it answers, so a guest question can be
+		// matched with the operator's answer.
+		statements: []string{
+			`ALTER TABLE messages ADD COLUMN reply_to INTEGER`,
+			`CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON messages(reply_to)`,
+			`CREATE INDEX IF NOT EXISTS idx_messages_kind ON messages(kind)`,
+		},
+	},
+	{
+		version: 29,
+		name:    "add_sandbox_artifacts",
+		// Session recordings are artifacts of a sandbox rather than of a job,
+		// so job_id becomes optional and the recording user is kept on the
+		// row. SQLite cannot drop a NOT NULL constraint in place, so the table
+		// is rebuilt with its ids intact.
+		statements: []string{
+			`CREATE TABLE artifacts_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id TEXT,
+				vmid INTEGER,
+				name TEXT NOT NULL,
+				path TEXT NOT NULL,
+				size_bytes INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				mime TEXT,
+				created_at TEXT NOT NULL,
+				kind TEXT,
+				user_name TEXT,
+				FOREIGN KEY(job_id) REFERENCES jobs(id) ON DELETE CASCADE
+			)`,
+			`INSERT INTO artifacts_new (id, job_id, vmid, name, path, size_bytes, sha256, mime, created_at, kind)
+				SELECT id, job_id, vmid, name, path, size_bytes, sha256, mime, created_at, kind FROM artifacts`,
+			`DROP TABLE artifacts`,
+			`ALTER TABLE artifacts_new RENAME TO artifacts`,
+			`CREATE INDEX IF NOT EXISTS idx_artifacts_job ON artifacts(job_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_artifacts_vmid ON artifacts(vmid)`,
+			`CREATE INDEX IF NOT EXISTS idx_artifacts_job_kind ON artifacts(job_id, kind)`,
+			`CREATE INDEX IF NOT EXISTS idx_artifacts_vmid_kind ON artifacts(vmid, kind)`,
+		},
+	},
+	{
+		version: 30,
+		name:    "add_templates",
+		// Templates built by agentlabd from a declarative spec. Each row is
+		// one immutable version; the spec and image checksum are kept as
+		// provenance so a template's contents can be reproduced.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS templates (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				version TEXT NOT NULL,
+				vmid INTEGER NOT NULL,
+				status TEXT NOT NULL,
+				spec_yaml TEXT NOT NULL,
+				spec_sha256 TEXT NOT NULL,
+				image_url TEXT NOT NULL,
+				image_sha256 TEXT NOT NULL,
+				built_by TEXT,
+				builder_version TEXT,
+				error TEXT,
+				created_at TEXT NOT NULL,
+				updated_at TEXT NOT NULL,
+				finished_at TEXT,
+				UNIQUE(name, version)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_templates_name ON templates(name)`,
+			`CREATE INDEX IF NOT EXISTS idx_templates_vmid ON templates(vmid)`,
+		},
+	},
+	{
+		version: 31,
+		name:    "add_template_rollouts",
+		// A rollout moves a profile from one template version to another,
+		// routing a share of new sandboxes to the new version first. Each
+		// sandbox records the template it was cloned from, so the versions
+		// can be compared and unused template VMs collected.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS template_rollouts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				profile TEXT NOT NULL,
+				base_ref TEXT NOT NULL,
+				template TEXT NOT NULL,
+				from_version TEXT NOT NULL,
+				from_vmid INTEGER NOT NULL,
+				to_version TEXT NOT NULL,
+				to_vmid INTEGER NOT NULL,
+				percent INTEGER NOT NULL,
+				status TEXT NOT NULL,
+				started_by TEXT,
+				created_at TEXT NOT NULL,
+				updated_at TEXT NOT NULL,
+				finished_at TEXT
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_template_rollouts_profile ON template_rollouts(profile)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_template_rollouts_active ON template_rollouts(profile) WHERE status = 'active'`,
+			`ALTER TABLE sandboxes ADD COLUMN template_vmid INTEGER`,
+			`CREATE INDEX IF NOT EXISTS idx_sandboxes_template_vmid ON sandboxes(template_vmid)`,
+		},
+	},
+	{
+		version: 32,
+		name:    "add_trace_context",
+		// Events record the trace and span they were emitted under. Jobs and
+		// sandboxes keep the traceparent of their provisioning span so later
+		// bootstrap fetches and runner reports join the same trace.
+		statements: []string{
+			`ALTER TABLE events ADD COLUMN trace_id TEXT`,
+			`ALTER TABLE events ADD COLUMN span_id TEXT`,
+			`CREATE INDEX IF NOT EXISTS idx_events_trace_id ON events(trace_id)`,
+			`ALTER TABLE jobs ADD COLUMN traceparent TEXT`,
+			`ALTER TABLE sandboxes ADD COLUMN traceparent TEXT`,
+		},
+	},
+	{
+		version: 33,
+		name:    "add_sandbox_usage",
+		// Resource usage samples are folded into fixed buckets per
+		// resolution. Disk and network columns hold the bytes moved during
+		// the bucket, not the guest's cumulative counters.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS sandbox_usage (
+				vmid INTEGER NOT NULL,
+				resolution_seconds INTEGER NOT NULL,
+				bucket_start TEXT NOT NULL,
+				samples INTEGER NOT NULL DEFAULT 0,
+				cpu_avg REAL NOT NULL DEFAULT 0,
+				cpu_max REAL NOT NULL DEFAULT 0,
+				cpus INTEGER NOT NULL DEFAULT 0,
+				mem_avg_bytes INTEGER NOT NULL DEFAULT 0,
+				mem_max_bytes INTEGER NOT NULL DEFAULT 0,
+				mem_limit_bytes INTEGER NOT NULL DEFAULT 0,
+				disk_read_bytes INTEGER NOT NULL DEFAULT 0,
+				disk_write_bytes INTEGER NOT NULL DEFAULT 0,
+				net_in_bytes INTEGER NOT NULL DEFAULT 0,
+				net_out_bytes INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (vmid, resolution_seconds, bucket_start)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_sandbox_usage_bucket ON sandbox_usage(resolution_seconds, bucket_start)`,
+		},
+	},
+	{
+		version: 34,
+		name:    "add_llm_usage",
+		// One row per proxied LLM request that reported token usage, kept
+		// for chargeback reports.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS llm_usage (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				vmid INTEGER NOT NULL,
+				integration TEXT NOT NULL,
+				provider TEXT NOT NULL,
+				model TEXT NOT NULL DEFAULT '',
+				input_tokens INTEGER NOT NULL DEFAULT 0,
+				output_tokens INTEGER NOT NULL DEFAULT 0,
+				created_at TEXT NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_llm_usage_vmid_created ON llm_usage(vmid, created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_llm_usage_created ON llm_usage(created_at)`,
+		},
+	},
+	{
+		version: 35,
+		name:    "add_custom_roles",
+		// Custom roles group control-plane permissions; bindings grant a role
+		// to a user or team at global, team or tag scope.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS custom_roles (
+				name TEXT PRIMARY KEY,
+				description TEXT NOT NULL DEFAULT '',
+				permissions_json TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				updated_at TEXT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS role_bindings (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				role_name TEXT NOT NULL,
+				subject_type TEXT NOT NULL,
+				subject_id TEXT NOT NULL,
+				scope_type TEXT NOT NULL,
+				scope_id TEXT NOT NULL DEFAULT '',
+				created_at TEXT NOT NULL,
+				FOREIGN KEY(role_name) REFERENCES custom_roles(name) ON DELETE CASCADE
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_role_bindings_unique ON role_bindings(role_name, subject_type, subject_id, scope_type, scope_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_role_bindings_subject ON role_bindings(subject_type, subject_id)`,
+		},
+	},
+	{
+		version: 36,
+		name:    "add_team_ownership_and_access_grants",
+		// Sandboxes, workspaces and sessions may be owned by a team as well as
+		// a user; access grants share one resource with another user at the
+		// read, operate or admin level.
+		statements: []string{
+			`ALTER TABLE sandboxes ADD COLUMN team TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX IF NOT EXISTS idx_sandboxes_team ON sandboxes(team)`,
+			`ALTER TABLE workspaces ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE workspaces ADD COLUMN team TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE sessions ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE sessions ADD COLUMN team TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE IF NOT EXISTS access_grants (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				resource_type TEXT NOT NULL,
+				resource_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				level TEXT NOT NULL,
+				granted_by TEXT NOT NULL DEFAULT '',
+				created_at TEXT NOT NULL,
+				UNIQUE(resource_type, resource_id, user_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_access_grants_user ON access_grants(user_id)`,
+		},
+	},
+	{
+		version: 37,
+		name:    "add_user_identities",
+		// External identities (OIDC issuer and subject) linked to registered
+		// users, so single sign-on logins map to the same user every time.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS user_identities (
+				issuer TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				last_login_at TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY(issuer, subject),
+				FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id)`,
+		},
+	},
+	{
+		version: 38,
+		name:    "add_pending_operations",
+		// Privileged requests parked until enough other users approve them,
+		// with the request to replay and the outcome once it ran.
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS pending_operations (
+				id TEXT PRIMARY KEY,
+				permission TEXT NOT NULL,
+				method TEXT NOT NULL,
+				path TEXT NOT NULL,
+				body TEXT NOT NULL DEFAULT '',
+				requested_by TEXT NOT NULL,
+				approver_role TEXT NOT NULL,
+				required_approvals INTEGER NOT NULL,
+				status TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				expires_at TEXT NOT NULL,
+				decided_at TEXT NOT NULL DEFAULT '',
+				decided_by TEXT NOT NULL DEFAULT '',
+				reason TEXT NOT NULL DEFAULT '',
+				result_status INTEGER NOT NULL DEFAULT 0,
+				result_body TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_pending_operations_status ON pending_operations(status, created_at)`,
+			`CREATE TABLE IF NOT EXISTS pending_operation_approvals (
+				operation_id TEXT NOT NULL,
+				user_id TEXT NOT NULL,
+				approved_at TEXT NOT NULL,
+				PRIMARY KEY(operation_id, user_id),
+				FOREIGN KEY(operation_id) REFERENCES pending_operations(id) ON DELETE CASCADE
+			)`,
+		},
+	},
 }
 
 // Migrate runs any pending migrations against the provided database.
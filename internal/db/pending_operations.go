@@ -0,0 +1,314 @@
+// ABOUTME: Privileged requests parked for approval by other users, and the approvals they collect.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pending operation statuses. A pending operation either collects enough
+// approvals and runs (executing, then executed or failed), or ends rejected
+// or expired without running.
+const (
+	OperationPending   = "pending"
+	OperationExecuting = "executing"
+	OperationExecuted  = "executed"
+	OperationFailed    = "failed"
+	OperationRejected  = "rejected"
+	OperationExpired   = "expired"
+)
+
+var (
+	// ErrOperationNotPending is returned when deciding an operation that is
+	// no longer pending.
+	ErrOperationNotPending = errors.New("operation is no longer pending")
+	// ErrOperationAlreadyApproved is returned when a user approves the same
+	// operation twice.
+	ErrOperationAlreadyApproved = errors.New("operation already approved by this user")
+)
+
+// PendingOperation is a request held until RequiredApprovals users holding
+// ApproverRole approve it. Method, Path and Body are replayed as the
+// requester once it is approved.
+type PendingOperation struct {
+	ID                string
+	Permission        string
+	Method            string
+	Path              string
+	Body              string
+	RequestedBy       string
+	ApproverRole      string
+	RequiredApprovals int
+	Status            string
+	CreatedAt         time.Time
+	ExpiresAt         time.Time
+	DecidedAt         time.Time
+	DecidedBy         string
+	Reason            string
+	ResultStatus      int
+	ResultBody        string
+	Approvals         []OperationApproval
+}
+
+// OperationApproval is one user's approval of a pending operation.
+type OperationApproval struct {
+	UserID     string
+	ApprovedAt time.Time
+}
+
+const pendingOperationColumns = `id, permission, method, path, body, requested_by, approver_role,
+	required_approvals, status, created_at, expires_at, decided_at, decided_by, reason, result_status, result_body`
+
+// CreatePendingOperation inserts a new pending operation.
+func (s *Store) CreatePendingOperation(ctx context.Context, op PendingOperation) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	op.ID = strings.TrimSpace(op.ID)
+	if op.ID == "" {
+		return errors.New("operation id is required")
+	}
+	if op.Permission == "" || op.Method == "" || op.Path == "" {
+		return errors.New("operation permission, method and path are required")
+	}
+	if op.RequiredApprovals <= 0 {
+		return errors.New("operation must require at least one approval")
+	}
+	if op.CreatedAt.IsZero() {
+		op.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO pending_operations
+		(id, permission, method, path, body, requested_by, approver_role, required_approvals, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		op.ID,
+		op.Permission,
+		op.Method,
+		op.Path,
+		op.Body,
+		op.RequestedBy,
+		op.ApproverRole,
+		op.RequiredApprovals,
+		OperationPending,
+		formatTime(op.CreatedAt),
+		formatTime(op.ExpiresAt),
+	)
+	if err != nil {
+		return fmt.Errorf("insert pending operation %s: %w", op.ID, err)
+	}
+	return nil
+}
+
+// GetPendingOperation loads an operation and its approvals by id. It returns
+// sql.ErrNoRows when there is none.
+func (s *Store) GetPendingOperation(ctx context.Context, id string) (PendingOperation, error) {
+	if s == nil || s.DB == nil {
+		return PendingOperation{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+pendingOperationColumns+`
+		FROM pending_operations WHERE id = ?`, strings.TrimSpace(id))
+	op, err := scanPendingOperationRow(row)
+	if err != nil {
+		return PendingOperation{}, err
+	}
+	if op.Approvals, err = s.listOperationApprovals(ctx, op.ID); err != nil {
+		return PendingOperation{}, err
+	}
+	return op, nil
+}
+
+// ListPendingOperations returns operations newest first with their
+// approvals. An empty status matches every operation; limit <= 0 means no
+// limit.
+func (s *Store) ListPendingOperations(ctx context.Context, status string, limit int) ([]PendingOperation, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	query := `SELECT ` + pendingOperationColumns + ` FROM pending_operations`
+	var args []any
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, rowid DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list pending operations: %w", err)
+	}
+	var out []PendingOperation
+	for rows.Next() {
+		op, err := scanPendingOperationRow(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, op)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("iterate pending operations: %w", err)
+	}
+	rows.Close()
+	for i := range out {
+		if out[i].Approvals, err = s.listOperationApprovals(ctx, out[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// ApprovePendingOperation records userID's approval of a pending operation
+// and returns the operation with every approval so far. It returns
+// ErrOperationNotPending when the operation is no longer pending and
+// ErrOperationAlreadyApproved when the user already approved it.
+func (s *Store) ApprovePendingOperation(ctx context.Context, id, userID string, at time.Time) (PendingOperation, error) {
+	if s == nil || s.DB == nil {
+		return PendingOperation{}, errors.New("db store is nil")
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return PendingOperation{}, fmt.Errorf("begin approve operation %s: %w", id, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM pending_operations WHERE id = ?`, id).Scan(&status); err != nil {
+		return PendingOperation{}, err
+	}
+	if status != OperationPending {
+		return PendingOperation{}, ErrOperationNotPending
+	}
+	res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO pending_operation_approvals (operation_id, user_id, approved_at)
+		VALUES (?, ?, ?)`, id, userID, formatTime(at))
+	if err != nil {
+		return PendingOperation{}, fmt.Errorf("approve operation %s: %w", id, err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return PendingOperation{}, fmt.Errorf("rows affected approve operation %s: %w", id, err)
+	} else if affected == 0 {
+		return PendingOperation{}, ErrOperationAlreadyApproved
+	}
+	if err := tx.Commit(); err != nil {
+		return PendingOperation{}, fmt.Errorf("commit approve operation %s: %w", id, err)
+	}
+	return s.GetPendingOperation(ctx, id)
+}
+
+// TransitionPendingOperation moves a pending operation to status: executing
+// once it is approved, rejected, or expired. Only one caller wins the move;
+// the others get ErrOperationNotPending, so an operation runs at most once.
+func (s *Store) TransitionPendingOperation(ctx context.Context, id, status, decidedBy, reason string, at time.Time) (PendingOperation, error) {
+	if s == nil || s.DB == nil {
+		return PendingOperation{}, errors.New("db store is nil")
+	}
+	switch status {
+	case OperationExecuting, OperationRejected, OperationExpired:
+	default:
+		return PendingOperation{}, fmt.Errorf("invalid operation transition to %q", status)
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE pending_operations
+		SET status = ?, decided_at = ?, decided_by = ?, reason = ?
+		WHERE id = ? AND status = ?`,
+		status, formatTime(at), decidedBy, reason, strings.TrimSpace(id), OperationPending)
+	if err != nil {
+		return PendingOperation{}, fmt.Errorf("decide operation %s: %w", id, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return PendingOperation{}, fmt.Errorf("rows affected decide operation %s: %w", id, err)
+	}
+	op, err := s.GetPendingOperation(ctx, id)
+	if err != nil {
+		return PendingOperation{}, err
+	}
+	if affected == 0 {
+		return op, ErrOperationNotPending
+	}
+	return op, nil
+}
+
+// FinishPendingOperation records the outcome of an executing operation as
+// executed or failed, with the replayed response.
+func (s *Store) FinishPendingOperation(ctx context.Context, id, status string, resultStatus int, resultBody string) (PendingOperation, error) {
+	if s == nil || s.DB == nil {
+		return PendingOperation{}, errors.New("db store is nil")
+	}
+	if status != OperationExecuted && status != OperationFailed {
+		return PendingOperation{}, fmt.Errorf("invalid operation outcome %q", status)
+	}
+	if _, err := s.DB.ExecContext(ctx, `UPDATE pending_operations
+		SET status = ?, result_status = ?, result_body = ?
+		WHERE id = ? AND status = ?`,
+		status, resultStatus, resultBody, strings.TrimSpace(id), OperationExecuting); err != nil {
+		return PendingOperation{}, fmt.Errorf("finish operation %s: %w", id, err)
+	}
+	return s.GetPendingOperation(ctx, id)
+}
+
+func (s *Store) listOperationApprovals(ctx context.Context, id string) ([]OperationApproval, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT user_id, approved_at FROM pending_operation_approvals
+		WHERE operation_id = ? ORDER BY approved_at ASC, rowid ASC`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list approvals for operation %s: %w", id, err)
+	}
+	defer rows.Close()
+	var out []OperationApproval
+	for rows.Next() {
+		var a OperationApproval
+		var at string
+		if err := rows.Scan(&a.UserID, &at); err != nil {
+			return nil, err
+		}
+		if a.ApprovedAt, err = parseTime(at); err != nil {
+			return nil, fmt.Errorf("parse operation approved_at: %w", err)
+		}
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate approvals for operation %s: %w", id, err)
+	}
+	return out, nil
+}
+
+func scanPendingOperationRow(scanner interface{ Scan(dest ...any) error }) (PendingOperation, error) {
+	var op PendingOperation
+	var createdAt, expiresAt, decidedAt string
+	if err := scanner.Scan(
+		&op.ID,
+		&op.Permission,
+		&op.Method,
+		&op.Path,
+		&op.Body,
+		&op.RequestedBy,
+		&op.ApproverRole,
+		&op.RequiredApprovals,
+		&op.Status,
+		&createdAt,
+		&expiresAt,
+		&decidedAt,
+		&op.DecidedBy,
+		&op.Reason,
+		&op.ResultStatus,
+		&op.ResultBody,
+	); err != nil {
+		return PendingOperation{}, err
+	}
+	var err error
+	if op.CreatedAt, err = parseTime(createdAt); err != nil {
+		return PendingOperation{}, fmt.Errorf("parse operation created_at: %w", err)
+	}
+	if op.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return PendingOperation{}, fmt.Errorf("parse operation expires_at: %w", err)
+	}
+	if decidedAt != "" {
+		if op.DecidedAt, err = parseTime(decidedAt); err != nil {
+			return PendingOperation{}, fmt.Errorf("parse operation decided_at: %w", err)
+		}
+	}
+	return op, nil
+}
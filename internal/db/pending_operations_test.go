@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingOperationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2026, time.October, 2, 9, 0, 0, 0, time.UTC)
+
+	_, err := store.GetPendingOperation(ctx, "op_missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, store.CreatePendingOperation(ctx, PendingOperation{
+		ID:                "op_1",
+		Permission:        "sandbox.bulk",
+		Method:            "POST",
+		Path:              "/v1/sandboxes/prune",
+		RequestedBy:       "alice",
+		ApproverRole:      "admin",
+		RequiredApprovals: 2,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(time.Hour),
+	}))
+	require.NoError(t, store.CreatePendingOperation(ctx, PendingOperation{
+		ID:                "op_2",
+		Permission:        "workspace.rebind",
+		Method:            "POST",
+		Path:              "/v1/workspaces/ws-1/rebind",
+		Body:              `{"profile":"yolo"}`,
+		RequestedBy:       "alice",
+		ApproverRole:      "admin",
+		RequiredApprovals: 1,
+		CreatedAt:         now.Add(time.Minute),
+		ExpiresAt:         now.Add(time.Hour),
+	}))
+
+	pending, err := store.ListPendingOperations(ctx, OperationPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "op_2", pending[0].ID)
+	assert.Equal(t, `{"profile":"yolo"}`, pending[0].Body)
+
+	op, err := store.ApprovePendingOperation(ctx, "op_1", "bob", now.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, op.Approvals, 1)
+	assert.Equal(t, "bob", op.Approvals[0].UserID)
+	_, err = store.ApprovePendingOperation(ctx, "op_1", "bob", now.Add(3*time.Minute))
+	require.ErrorIs(t, err, ErrOperationAlreadyApproved)
+	op, err = store.ApprovePendingOperation(ctx, "op_1", "carol", now.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, op.Approvals, 2)
+
+	// Only one caller moves an operation out of pending.
+	op, err = store.TransitionPendingOperation(ctx, "op_1", OperationExecuting, "carol", "", now.Add(3*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, OperationExecuting, op.Status)
+	_, err = store.TransitionPendingOperation(ctx, "op_1", OperationExecuting, "bob", "", now.Add(3*time.Minute))
+	require.ErrorIs(t, err, ErrOperationNotPending)
+	_, err = store.ApprovePendingOperation(ctx, "op_1", "dave", now.Add(4*time.Minute))
+	require.ErrorIs(t, err, ErrOperationNotPending)
+
+	op, err = store.FinishPendingOperation(ctx, "op_1", OperationExecuted, 200, `{"count":3}`)
+	require.NoError(t, err)
+	assert.Equal(t, OperationExecuted, op.Status)
+	assert.Equal(t, 200, op.ResultStatus)
+	assert.Equal(t, "carol", op.DecidedBy)
+	assert.True(t, op.DecidedAt.Equal(now.Add(3*time.Minute)))
+
+	op, err = store.TransitionPendingOperation(ctx, "op_2", OperationRejected, "bob", "not today", now.Add(5*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, "not today", op.Reason)
+	_, err = store.FinishPendingOperation(ctx, "op_1", "pending", 0, "")
+	require.Error(t, err)
+
+	all, err := store.ListPendingOperations(ctx, "", 1)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, OperationRejected, all[0].Status)
+}
@@ -0,0 +1,309 @@
+// ABOUTME: Retention queries for compacting and exporting events, messages and the audit log.
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// deleteBatchSize bounds the number of ids bound into one DELETE statement.
+const deleteBatchSize = 500
+
+// EventSnapshot is the folded projection state of compacted events.
+//
+// Every event with an id up to ThroughEventID is reflected in State, whether
+// or not the row still exists. Readers restore State and replay only newer
+// events.
+type EventSnapshot struct {
+	ThroughEventID  int64
+	CompactedEvents int64
+	State           string
+	UpdatedAt       time.Time
+}
+
+// AuditRecord is a single audit_log row.
+type AuditRecord struct {
+	ID        int64
+	UserID    string
+	Action    string
+	Resource  string
+	Detail    string
+	Timestamp time.Time
+}
+
+// GetEventSnapshot returns the stored event snapshot. The boolean is false
+// when no events have been compacted yet.
+func (s *Store) GetEventSnapshot(ctx context.Context) (EventSnapshot, bool, error) {
+	if s == nil || s.DB == nil {
+		return EventSnapshot{}, false, errors.New("db store is nil")
+	}
+	var snapshot EventSnapshot
+	var updatedAt string
+	err := s.DB.QueryRowContext(ctx, `SELECT through_event_id, compacted_events, state_json, updated_at
+		FROM event_snapshots WHERE id = 1`).Scan(&snapshot.ThroughEventID, &snapshot.CompactedEvents, &snapshot.State, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EventSnapshot{}, false, nil
+	}
+	if err != nil {
+		return EventSnapshot{}, false, fmt.Errorf("get event snapshot: %w", err)
+	}
+	parsed, err := parseTime(updatedAt)
+	if err != nil {
+		return EventSnapshot{}, false, fmt.Errorf("parse event snapshot updated_at: %w", err)
+	}
+	snapshot.UpdatedAt = parsed
+	return snapshot, true, nil
+}
+
+// ListEventsAfterID returns up to limit events with an id greater than
+// afterID, in ascending id order.
+func (s *Store) ListEventsAfterID(ctx context.Context, afterID int64, limit int) ([]Event, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
+		FROM events WHERE id > ? ORDER BY id ASC LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list events after id: %w", err)
+	}
+	defer rows.Close()
+	var out []Event
+	for rows.Next() {
+		ev, err := scanEventRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate events after id: %w", err)
+	}
+	return out, nil
+}
+
+// ListEventsSince returns up to limit events recorded at or after since with
+// an id greater than afterID, in ascending id order. A zero since matches
+// every event.
+func (s *Store) ListEventsSince(ctx context.Context, since time.Time, afterID int64, limit int) ([]Event, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id
+		FROM events WHERE id > ? AND ts >= ? ORDER BY id ASC LIMIT ?`, afterID, sinceBound(since), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list events since: %w", err)
+	}
+	defer rows.Close()
+	var out []Event
+	for rows.Next() {
+		ev, err := scanEventRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate events since: %w", err)
+	}
+	return out, nil
+}
+
+// CompactEvents stores snapshot and deletes the given events in one
+// transaction, so a reader never sees a deleted event that the snapshot
+// does not yet reflect.
+func (s *Store) CompactEvents(ctx context.Context, snapshot EventSnapshot, ids []int64) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if snapshot.ThroughEventID <= 0 {
+		return errors.New("snapshot through_event_id must be positive")
+	}
+	if snapshot.UpdatedAt.IsZero() {
+		snapshot.UpdatedAt = time.Now().UTC()
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin compact events: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO event_snapshots (id, through_event_id, compacted_events, state_json, updated_at)
+		VALUES (1, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			through_event_id = excluded.through_event_id,
+			compacted_events = excluded.compacted_events,
+			state_json = excluded.state_json,
+			updated_at = excluded.updated_at`,
+		snapshot.ThroughEventID, snapshot.CompactedEvents, snapshot.State, formatTime(snapshot.UpdatedAt)); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("store event snapshot: %w", err)
+	}
+	if _, err := deleteByIDs(ctx, tx, "events", ids); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit compact events: %w", err)
+	}
+	return nil
+}
+
+// ListMessagesBefore returns up to limit messages recorded before cutoff with
+// an id greater than afterID, in ascending id order. A question is left out
+// until its answer is old enough to go too, so open questions stay answerable
+// and no retained answer points at a deleted question.
+func (s *Store) ListMessagesBefore(ctx context.Context, cutoff time.Time, afterID int64, limit int) ([]Message, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	before := formatTime(cutoff)
+	return s.queryMessages(ctx, `SELECT `+messageColumns+`
+		FROM messages q WHERE id > ? AND ts < ?
+		AND (kind != ? OR EXISTS (SELECT 1 FROM messages a WHERE a.reply_to = q.id AND a.ts < ?))
+		ORDER BY id ASC LIMIT ?`, afterID, before, MessageKindQuestion, before, limit)
+}
+
+// ListMessagesSince returns up to limit messages recorded at or after since
+// with an id greater than afterID, in ascending id order.
+func (s *Store) ListMessagesSince(ctx context.Context, since time.Time, afterID int64, limit int) ([]Message, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	return s.queryMessages(ctx, `SELECT `+messageColumns+`
+		FROM messages WHERE id > ? AND ts >= ? ORDER BY id ASC LIMIT ?`, afterID, sinceBound(since), limit)
+}
+
+// DeleteMessages removes the given messages and returns how many were deleted.
+func (s *Store) DeleteMessages(ctx context.Context, ids []int64) (int64, error) {
+	if s == nil || s.DB == nil {
+		return 0, errors.New("db store is nil")
+	}
+	return deleteByIDs(ctx, s.DB, "messages", ids)
+}
+
+// ListAuditRecordsBefore returns up to limit audit entries recorded before
+// cutoff with an id greater than afterID, in ascending id order.
+func (s *Store) ListAuditRecordsBefore(ctx context.Context, cutoff time.Time, afterID int64, limit int) ([]AuditRecord, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	return s.queryAuditRecords(ctx, `SELECT id, user_id, action, resource, detail, timestamp
+		FROM audit_log WHERE id > ? AND timestamp < ? ORDER BY id ASC LIMIT ?`, afterID, formatTime(cutoff), limit)
+}
+
+// ListAuditRecordsSince returns up to limit audit entries recorded at or
+// after since with an id greater than afterID, in ascending id order.
+func (s *Store) ListAuditRecordsSince(ctx context.Context, since time.Time, afterID int64, limit int) ([]AuditRecord, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	return s.queryAuditRecords(ctx, `SELECT id, user_id, action, resource, detail, timestamp
+		FROM audit_log WHERE id > ? AND timestamp >= ? ORDER BY id ASC LIMIT ?`, afterID, sinceBound(since), limit)
+}
+
+// DeleteAuditRecords removes the given audit entries and returns how many
+// were deleted.
+func (s *Store) DeleteAuditRecords(ctx context.Context, ids []int64) (int64, error) {
+	if s == nil || s.DB == nil {
+		return 0, errors.New("db store is nil")
+	}
+	return deleteByIDs(ctx, s.DB, "audit_log", ids)
+}
+
+func (s *Store) queryMessages(ctx context.Context, query string, args ...any) ([]Message, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+	defer rows.Close()
+	var out []Message
+	for rows.Next() {
+		msg, err := scanMessageRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages: %w", err)
+	}
+	return out, nil
+}
+
+func (s *Store) queryAuditRecords(ctx context.Context, query string, args ...any) ([]AuditRecord, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit log: %w", err)
+	}
+	defer rows.Close()
+	var out []AuditRecord
+	for rows.Next() {
+		var record AuditRecord
+		var ts string
+		if err := rows.Scan(&record.ID, &record.UserID, &record.Action, &record.Resource, &record.Detail, &ts); err != nil {
+			return nil, err
+		}
+		parsed, err := parseTime(ts)
+		if err != nil {
+			return nil, fmt.Errorf("parse audit timestamp: %w", err)
+		}
+		record.Timestamp = parsed
+		out = append(out, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit log: %w", err)
+	}
+	return out, nil
+}
+
+func deleteByIDs(ctx context.Context, exec execer, table string, ids []int64) (int64, error) {
+	var deleted int64
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		result, err := exec.ExecContext(ctx, `DELETE FROM `+table+` WHERE id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return deleted, fmt.Errorf("delete %s: %w", table, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			deleted += n
+		}
+	}
+	return deleted, nil
+}
+
+// sinceBound formats a lower time bound; the zero time matches every row.
+func sinceBound(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+	return formatTime(since)
+}
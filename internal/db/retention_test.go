@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactEvents(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	_, ok, err := store.GetEventSnapshot(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, kind := range []string{"sandbox.state", "job.created", "job.running"} {
+		require.NoError(t, store.RecordEvent(ctx, kind, nil, nil, kind, ""))
+	}
+	events, err := store.ListEventsAfterID(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	updated := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.CompactEvents(ctx, EventSnapshot{
+		ThroughEventID:  events[1].ID,
+		CompactedEvents: 2,
+		State:           `{"sandbox_health":{}}`,
+		UpdatedAt:       updated,
+	}, []int64{events[0].ID, events[1].ID}))
+
+	snapshot, ok, err := store.GetEventSnapshot(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, events[1].ID, snapshot.ThroughEventID)
+	assert.Equal(t, int64(2), snapshot.CompactedEvents)
+	assert.Equal(t, `{"sandbox_health":{}}`, snapshot.State)
+	assert.True(t, snapshot.UpdatedAt.Equal(updated))
+
+	remaining, err := store.ListAllEvents(ctx)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, events[2].ID, remaining[0].ID)
+
+	// A second compaction replaces the single snapshot row.
+	require.NoError(t, store.CompactEvents(ctx, EventSnapshot{
+		ThroughEventID:  events[2].ID,
+		CompactedEvents: 3,
+		State:           `{}`,
+	}, []int64{events[2].ID}))
+	snapshot, _, err = store.GetEventSnapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, events[2].ID, snapshot.ThroughEventID)
+
+	err = store.CompactEvents(ctx, EventSnapshot{}, nil)
+	require.Error(t, err)
+}
+
+func TestRetentionKeepsQuestionsWithLiveAnswers(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cutoff := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	post := func(ts time.Time, kind, text string, replyTo int64) Message {
+		t.Helper()
+		msg, err := store.CreateMessage(ctx, Message{Timestamp: ts, ScopeType: "job", ScopeID: "job-1", Kind: kind, Text: text, ReplyTo: replyTo})
+		require.NoError(t, err)
+		return msg
+	}
+
+	post(old, MessageKindQuestion, "open", 0)
+	closed := post(old, MessageKindQuestion, "closed", 0)
+	closedAnswer := post(old, MessageKindAnswer, "yes", closed.ID)
+	late := post(old, MessageKindQuestion, "answered late", 0)
+	post(recent, MessageKindAnswer, "no", late.ID)
+
+	messages, err := store.ListMessagesBefore(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	var ids []int64
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	assert.Equal(t, []int64{closed.ID, closedAnswer.ID}, ids)
+}
+
+func TestRetentionListsAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cutoff := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, ts := range []time.Time{old, recent} {
+		_, err := store.CreateMessage(ctx, Message{Timestamp: ts, ScopeType: "job", ScopeID: "job-1", Kind: "note", Text: "hi"})
+		require.NoError(t, err)
+		_, err = store.DB.ExecContext(ctx, `INSERT INTO audit_log (user_id, action, timestamp) VALUES ('u1', 'login', ?)`, formatTime(ts))
+		require.NoError(t, err)
+	}
+
+	messages, err := store.ListMessagesBefore(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Timestamp.Equal(old))
+
+	since, err := store.ListMessagesSince(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	all, err := store.ListMessagesSince(ctx, time.Time{}, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	deleted, err := store.DeleteMessages(ctx, []int64{messages[0].ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	messages, err = store.ListMessagesBefore(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	records, err := store.ListAuditRecordsBefore(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "login", records[0].Action)
+	assert.True(t, records[0].Timestamp.Equal(old))
+
+	recentRecords, err := store.ListAuditRecordsSince(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, recentRecords, 1)
+	assert.True(t, recentRecords[0].Timestamp.Equal(recent))
+
+	deleted, err = store.DeleteAuditRecords(ctx, []int64{records[0].ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = store.DeleteAuditRecords(ctx, nil)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
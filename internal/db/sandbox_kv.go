@@ -0,0 +1,146 @@
+// ABOUTME: Guest-written key/value status for sandboxes, such as test results or a PR URL.
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSandboxKVFull is returned when a write would add a key to a sandbox that
+// already holds the maximum number of keys.
+var ErrSandboxKVFull = errors.New("sandbox key limit reached")
+
+// SandboxKV is one value a guest published about itself. JobID is the job
+// that was running on the sandbox when the value was last written.
+type SandboxKV struct {
+	VMID      int
+	Key       string
+	Value     string
+	JobID     string
+	UpdatedAt time.Time
+}
+
+// PutSandboxKV inserts or replaces a value. A new key is rejected with
+// ErrSandboxKVFull when the sandbox already has maxKeys keys; overwriting an
+// existing key is always allowed. maxKeys <= 0 means no limit.
+func (s *Store) PutSandboxKV(ctx context.Context, kv SandboxKV, maxKeys int) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if kv.VMID <= 0 {
+		return errors.New("vmid must be positive")
+	}
+	kv.Key = strings.TrimSpace(kv.Key)
+	if kv.Key == "" {
+		return errors.New("key is required")
+	}
+	if kv.UpdatedAt.IsZero() {
+		kv.UpdatedAt = time.Now().UTC()
+	}
+	limit := int64(maxKeys)
+	if maxKeys <= 0 {
+		limit = -1
+	}
+	// The count check and the write are one statement so concurrent writers
+	// cannot both squeeze past the limit.
+	res, err := s.DB.ExecContext(ctx, `INSERT INTO sandbox_kv (vmid, key, value, job_id, updated_at)
+		SELECT ?, ?, ?, ?, ?
+		WHERE ? < 0
+			OR EXISTS (SELECT 1 FROM sandbox_kv WHERE vmid = ? AND key = ?)
+			OR (SELECT COUNT(*) FROM sandbox_kv WHERE vmid = ?) < ?
+		ON CONFLICT(vmid, key) DO UPDATE SET
+			value = excluded.value,
+			job_id = excluded.job_id,
+			updated_at = excluded.updated_at`,
+		kv.VMID,
+		kv.Key,
+		kv.Value,
+		nullIfEmpty(kv.JobID),
+		formatTime(kv.UpdatedAt),
+		limit,
+		kv.VMID,
+		kv.Key,
+		kv.VMID,
+		limit,
+	)
+	if err != nil {
+		return fmt.Errorf("put sandbox kv %d/%s: %w", kv.VMID, kv.Key, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("put sandbox kv %d/%s: %w", kv.VMID, kv.Key, err)
+	}
+	if affected == 0 {
+		return ErrSandboxKVFull
+	}
+	return nil
+}
+
+// GetSandboxKV loads one value. It returns sql.ErrNoRows when the key is not
+// set.
+func (s *Store) GetSandboxKV(ctx context.Context, vmid int, key string) (SandboxKV, error) {
+	if s == nil || s.DB == nil {
+		return SandboxKV{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT vmid, key, value, job_id, updated_at
+		FROM sandbox_kv WHERE vmid = ? AND key = ?`, vmid, strings.TrimSpace(key))
+	return scanSandboxKVRow(row)
+}
+
+// ListSandboxKV returns every value for a sandbox, ordered by key.
+func (s *Store) ListSandboxKV(ctx context.Context, vmid int) ([]SandboxKV, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT vmid, key, value, job_id, updated_at
+		FROM sandbox_kv WHERE vmid = ? ORDER BY key`, vmid)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox kv %d: %w", vmid, err)
+	}
+	defer rows.Close()
+	var out []SandboxKV
+	for rows.Next() {
+		kv, err := scanSandboxKVRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, kv)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSandboxKV removes one value. It reports whether the key existed.
+func (s *Store) DeleteSandboxKV(ctx context.Context, vmid int, key string) (bool, error) {
+	if s == nil || s.DB == nil {
+		return false, errors.New("db store is nil")
+	}
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM sandbox_kv WHERE vmid = ? AND key = ?`, vmid, strings.TrimSpace(key))
+	if err != nil {
+		return false, fmt.Errorf("delete sandbox kv %d/%s: %w", vmid, key, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func scanSandboxKVRow(scanner interface{ Scan(dest ...any) error }) (SandboxKV, error) {
+	var kv SandboxKV
+	var jobID sql.NullString
+	var updatedAt string
+	if err := scanner.Scan(&kv.VMID, &kv.Key, &kv.Value, &jobID, &updatedAt); err != nil {
+		return SandboxKV{}, err
+	}
+	kv.JobID = jobID.String
+	parsed, err := parseTime(updatedAt)
+	if err != nil {
+		return SandboxKV{}, fmt.Errorf("parse sandbox kv updated_at: %w", err)
+	}
+	kv.UpdatedAt = parsed
+	return kv, nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxKVLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	seedSandboxForSecret(t, store, 7101)
+
+	require.NoError(t, store.PutSandboxKV(ctx, SandboxKV{VMID: 7101, Key: "tests", Value: "running", JobID: "job_1"}, 2))
+	require.NoError(t, store.PutSandboxKV(ctx, SandboxKV{VMID: 7101, Key: "pr_url", Value: "https://example.test/pr/1"}, 2))
+
+	// A third key is over the limit, but overwriting an existing key is not.
+	err := store.PutSandboxKV(ctx, SandboxKV{VMID: 7101, Key: "extra", Value: "x"}, 2)
+	require.ErrorIs(t, err, ErrSandboxKVFull)
+	require.NoError(t, store.PutSandboxKV(ctx, SandboxKV{VMID: 7101, Key: "tests", Value: "passing", JobID: "job_1"}, 2))
+
+	got, err := store.GetSandboxKV(ctx, 7101, "tests")
+	require.NoError(t, err)
+	assert.Equal(t, "passing", got.Value)
+	assert.Equal(t, "job_1", got.JobID)
+	assert.False(t, got.UpdatedAt.IsZero())
+
+	list, err := store.ListSandboxKV(ctx, 7101)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "pr_url", list[0].Key)
+	assert.Equal(t, "", list[0].JobID)
+	assert.Equal(t, "tests", list[1].Key)
+
+	deleted, err := store.DeleteSandboxKV(ctx, 7101, "tests")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = store.DeleteSandboxKV(ctx, 7101, "tests")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	_, err = store.GetSandboxKV(ctx, 7101, "tests")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// With the key gone there is room again.
+	require.NoError(t, store.PutSandboxKV(ctx, SandboxKV{VMID: 7101, Key: "extra", Value: "x"}, 2))
+}
+
+func TestPutSandboxKVValidation(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	assert.Error(t, (*Store)(nil).PutSandboxKV(ctx, SandboxKV{VMID: 1, Key: "k"}, 0))
+	assert.Error(t, store.PutSandboxKV(ctx, SandboxKV{VMID: 0, Key: "k"}, 0))
+	assert.Error(t, store.PutSandboxKV(ctx, SandboxKV{VMID: 1, Key: "  "}, 0))
+}
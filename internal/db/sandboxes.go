@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/agentlab/agentlab/internal/models"
+	"github.com/agentlab/agentlab/internal/tracing"
 )
 
 // timeLayout is the format used for storing timestamps in SQLite.
@@ -73,8 +74,8 @@ func (s *Store) CreateSandbox(ctx context.Context, sandbox models.Sandbox) error
 		workspace = *sandbox.WorkspaceID
 	}
 	_, err := s.DB.ExecContext(ctx, `INSERT INTO sandboxes (
-		vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, meta_json, type, image, tags, prompt, owner
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, meta_json, type, image, tags, prompt, owner, team
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		sandbox.VMID,
 		sandbox.Name,
 		sandbox.Profile,
@@ -92,6 +93,7 @@ func (s *Store) CreateSandbox(ctx context.Context, sandbox models.Sandbox) error
 		sandbox.Tags,
 		sandbox.Prompt,
 		sandbox.Owner,
+		sandbox.Team,
 	)
 	if err != nil {
 		return fmt.Errorf("insert sandbox %d: %w", sandbox.VMID, err)
@@ -111,7 +113,7 @@ func (s *Store) GetSandbox(ctx context.Context, vmid int) (models.Sandbox, error
 	if s == nil || s.DB == nil {
 		return models.Sandbox{}, errors.New("db store is nil")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner
+	row := s.DB.QueryRowContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner, team
 		FROM sandboxes WHERE vmid = ?`, vmid)
 	return scanSandboxRow(row)
 }
@@ -132,7 +134,7 @@ func (s *Store) GetSandboxByIP(ctx context.Context, ip string) (models.Sandbox,
 	if ip == "" {
 		return models.Sandbox{}, errors.New("ip is required")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner
+	row := s.DB.QueryRowContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner, team
 		FROM sandboxes WHERE ip = ?`, ip)
 	return scanSandboxRow(row)
 }
@@ -176,7 +178,7 @@ func (s *Store) GetLiveSandboxByIP(ctx context.Context, ip string) (models.Sandb
 	if ip == "" {
 		return models.Sandbox{}, errors.New("ip is required")
 	}
-	query := `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner
+	query := `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner, team
 		FROM sandboxes WHERE ip = ? AND state IN (` + eligibleStateList() + `)`
 	rows, err := s.DB.QueryContext(ctx, query, ip)
 	if err != nil {
@@ -224,7 +226,7 @@ func (s *Store) ListSandboxes(ctx context.Context) ([]models.Sandbox, error) {
 	if s == nil || s.DB == nil {
 		return nil, errors.New("db store is nil")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner
+	rows, err := s.DB.QueryContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner, team
 		FROM sandboxes ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("list sandboxes: %w", err)
@@ -291,7 +293,7 @@ func (s *Store) ListExpiredSandboxes(ctx context.Context, now time.Time) ([]mode
 		return nil, errors.New("db store is nil")
 	}
 	cutoff := formatTime(now)
-	rows, err := s.DB.QueryContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner
+	rows, err := s.DB.QueryContext(ctx, `SELECT vmid, name, profile, state, ip, workspace_id, keepalive, lease_expires_at, last_used_at, created_at, updated_at, type, image, tags, prompt, owner, team
 		FROM sandboxes
 		WHERE lease_expires_at IS NOT NULL AND lease_expires_at <= ? AND state != ?`, cutoff, models.SandboxDestroyed)
 	if err != nil {
@@ -489,6 +491,35 @@ func (s *Store) UpdateSandboxTags(ctx context.Context, vmid int, tags string) er
 	return nil
 }
 
+// UpdateSandboxOwnership sets the owner and the team that co-owns a sandbox.
+// Empty values clear them.
+func (s *Store) UpdateSandboxOwnership(ctx context.Context, vmid int, owner, team string) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if vmid <= 0 {
+		return errors.New("vmid must be positive")
+	}
+	updatedAt := formatTime(time.Now().UTC())
+	res, err := s.DB.ExecContext(ctx, `UPDATE sandboxes SET owner = ?, team = ?, updated_at = ? WHERE vmid = ?`,
+		strings.TrimSpace(owner),
+		strings.TrimSpace(team),
+		updatedAt,
+		vmid,
+	)
+	if err != nil {
+		return fmt.Errorf("update sandbox %d ownership: %w", vmid, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected sandbox %d ownership: %w", vmid, err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // TouchSandbox updates only the updated_at timestamp for a sandbox.
 func (s *Store) TouchSandbox(ctx context.Context, vmid int) error {
 	if s == nil || s.DB == nil {
@@ -512,7 +543,8 @@ func (s *Store) TouchSandbox(ctx context.Context, vmid int) error {
 	return nil
 }
 
-// RecordEvent inserts an event row.
+// RecordEvent inserts an event row. The event records the trace and span in
+// ctx, if any.
 func (s *Store) RecordEvent(ctx context.Context, kind string, sandboxVMID *int, jobID *string, msg string, jsonPayload string) error {
 	if s == nil || s.DB == nil {
 		return errors.New("db store is nil")
@@ -537,8 +569,13 @@ func (s *Store) RecordEvent(ctx context.Context, kind string, sandboxVMID *int,
 	if jsonPayload != "" {
 		jsonVal = jsonPayload
 	}
-	_, err := s.DB.ExecContext(ctx, `INSERT INTO events (ts, kind, sandbox_vmid, job_id, msg, json) VALUES (?, ?, ?, ?, ?, ?)`,
-		now, kind, vmid, job, msgVal, jsonVal)
+	var traceID, spanID sql.NullString
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		traceID = sql.NullString{Valid: true, String: sc.TraceID.String()}
+		spanID = sql.NullString{Valid: true, String: sc.SpanID.String()}
+	}
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO events (ts, kind, sandbox_vmid, job_id, msg, json, trace_id, span_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		now, kind, vmid, job, msgVal, jsonVal, traceID, spanID)
 	if err != nil {
 		return fmt.Errorf("insert event %q: %w", kind, err)
 	}
@@ -560,7 +597,8 @@ func scanSandboxRow(scanner interface{ Scan(dest ...any) error }) (models.Sandbo
 	var sbTags sql.NullString
 	var sbPrompt sql.NullString
 	var sbOwner sql.NullString
-	if err := scanner.Scan(&sb.VMID, &sb.Name, &sb.Profile, &state, &ip, &workspace, &keepalive, &lease, &lastUsed, &createdAt, &updatedAt, &sbType, &sbImage, &sbTags, &sbPrompt, &sbOwner); err != nil {
+	var sbTeam sql.NullString
+	if err := scanner.Scan(&sb.VMID, &sb.Name, &sb.Profile, &state, &ip, &workspace, &keepalive, &lease, &lastUsed, &createdAt, &updatedAt, &sbType, &sbImage, &sbTags, &sbPrompt, &sbOwner, &sbTeam); err != nil {
 		return models.Sandbox{}, err
 	}
 	if state == "" {
@@ -584,6 +622,9 @@ func scanSandboxRow(scanner interface{ Scan(dest ...any) error }) (models.Sandbo
 	if sbOwner.Valid {
 		sb.Owner = sbOwner.String
 	}
+	if sbTeam.Valid {
+		sb.Team = sbTeam.String
+	}
 	if ip.Valid {
 		sb.IP = ip.String
 	}
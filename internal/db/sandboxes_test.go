@@ -714,6 +714,43 @@ func TestUpdateSandboxWorkspace(t *testing.T) {
 	})
 }
 
+func TestUpdateSandboxOwnership(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		store := openTestStore(t)
+		sb := testutil.NewTestSandbox(testutil.SandboxOpts{
+			VMID:  testutil.TestVMID,
+			State: models.SandboxRunning,
+		})
+		sb.Owner = "alice"
+		require.NoError(t, store.CreateSandbox(ctx, sb))
+
+		require.NoError(t, store.UpdateSandboxOwnership(ctx, testutil.TestVMID, "alice", "infra"))
+		got, err := store.GetSandbox(ctx, testutil.TestVMID)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", got.Owner)
+		assert.Equal(t, "infra", got.Team)
+
+		require.NoError(t, store.UpdateSandboxOwnership(ctx, testutil.TestVMID, "alice", ""))
+		got, err = store.GetSandbox(ctx, testutil.TestVMID)
+		require.NoError(t, err)
+		assert.Empty(t, got.Team)
+	})
+
+	t.Run("sandbox not found", func(t *testing.T) {
+		store := openTestStore(t)
+		err := store.UpdateSandboxOwnership(ctx, 999, "alice", "infra")
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("invalid vmid", func(t *testing.T) {
+		store := openTestStore(t)
+		err := store.UpdateSandboxOwnership(ctx, 0, "alice", "infra")
+		assert.EqualError(t, err, "vmid must be positive")
+	})
+}
+
 func TestRecordEvent(t *testing.T) {
 	ctx := context.Background()
 
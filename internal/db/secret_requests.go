@@ -0,0 +1,247 @@
+// ABOUTME: Secret access requests and the time-boxed grants they become once approved.
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Secret access request statuses.
+const (
+	SecretRequestPending  = "pending"
+	SecretRequestApproved = "approved"
+	SecretRequestDenied   = "denied"
+)
+
+// ErrSecretRequestDecided is returned when deciding a request that is no
+// longer pending.
+var ErrSecretRequestDecided = errors.New("secret access request already decided")
+
+// SecretAccessRequest is a sandbox's request to read an approval-gated
+// secret. An approved request grants access until GrantExpiresAt.
+type SecretAccessRequest struct {
+	ID             string
+	SecretName     string
+	SandboxVMID    int
+	SandboxName    string
+	JobID          string
+	Profile        string
+	Status         string
+	GrantTTL       time.Duration
+	RequestedAt    time.Time
+	DecidedAt      time.Time
+	DecidedBy      string
+	GrantExpiresAt time.Time
+}
+
+// Active reports whether the request is an approved grant that has not
+// expired at now.
+func (r SecretAccessRequest) Active(now time.Time) bool {
+	return r.Status == SecretRequestApproved && now.Before(r.GrantExpiresAt)
+}
+
+const secretRequestColumns = `id, secret_name, sandbox_vmid, sandbox_name, job_id, profile, status,
+	grant_ttl_seconds, requested_at, decided_at, decided_by, grant_expires_at`
+
+// CreateSecretAccessRequest inserts a new pending request.
+func (s *Store) CreateSecretAccessRequest(ctx context.Context, req SecretAccessRequest) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	if req.ID == "" {
+		return errors.New("secret request id is required")
+	}
+	req.SecretName = strings.TrimSpace(req.SecretName)
+	if req.SecretName == "" {
+		return errors.New("secret name is required")
+	}
+	if req.SandboxVMID <= 0 {
+		return errors.New("secret request vmid must be positive")
+	}
+	if req.RequestedAt.IsZero() {
+		req.RequestedAt = time.Now().UTC()
+	}
+	var jobID any
+	if req.JobID != "" {
+		jobID = req.JobID
+	}
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO secret_access_requests
+		(id, secret_name, sandbox_vmid, sandbox_name, job_id, profile, status, grant_ttl_seconds, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.ID,
+		req.SecretName,
+		req.SandboxVMID,
+		req.SandboxName,
+		jobID,
+		req.Profile,
+		SecretRequestPending,
+		int64(req.GrantTTL/time.Second),
+		formatTime(req.RequestedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("insert secret request %s: %w", req.ID, err)
+	}
+	return nil
+}
+
+// GetSecretAccessRequest loads a request by id.
+func (s *Store) GetSecretAccessRequest(ctx context.Context, id string) (SecretAccessRequest, error) {
+	if s == nil || s.DB == nil {
+		return SecretAccessRequest{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+secretRequestColumns+`
+		FROM secret_access_requests WHERE id = ?`, strings.TrimSpace(id))
+	return scanSecretRequestRow(row)
+}
+
+// LatestSecretAccessRequest returns the newest request by a sandbox for a
+// secret made at or after since. It returns sql.ErrNoRows when there is none.
+func (s *Store) LatestSecretAccessRequest(ctx context.Context, vmid int, secretName string, since time.Time) (SecretAccessRequest, error) {
+	if s == nil || s.DB == nil {
+		return SecretAccessRequest{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+secretRequestColumns+`
+		FROM secret_access_requests
+		WHERE sandbox_vmid = ? AND secret_name = ? AND requested_at >= ?
+		ORDER BY requested_at DESC, rowid DESC LIMIT 1`, vmid, secretName, sinceBound(since))
+	return scanSecretRequestRow(row)
+}
+
+// ListSecretAccessRequests returns requests newest first. An empty status
+// matches every request; limit <= 0 means no limit.
+func (s *Store) ListSecretAccessRequests(ctx context.Context, status string, limit int) ([]SecretAccessRequest, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	query := `SELECT ` + secretRequestColumns + ` FROM secret_access_requests`
+	var args []any
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY requested_at DESC, rowid DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list secret requests: %w", err)
+	}
+	defer rows.Close()
+	var out []SecretAccessRequest
+	for rows.Next() {
+		req, err := scanSecretRequestRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate secret requests: %w", err)
+	}
+	return out, nil
+}
+
+// DecideSecretAccessRequest approves or denies a pending request. An
+// approval grants access until expiresAt. It returns ErrSecretRequestDecided
+// when the request is no longer pending and sql.ErrNoRows when it does not
+// exist.
+func (s *Store) DecideSecretAccessRequest(ctx context.Context, id, status, decidedBy string, decidedAt, expiresAt time.Time) (SecretAccessRequest, error) {
+	if s == nil || s.DB == nil {
+		return SecretAccessRequest{}, errors.New("db store is nil")
+	}
+	if status != SecretRequestApproved && status != SecretRequestDenied {
+		return SecretAccessRequest{}, fmt.Errorf("invalid secret request status %q", status)
+	}
+	if status == SecretRequestApproved && !expiresAt.After(decidedAt) {
+		return SecretAccessRequest{}, errors.New("grant must expire after it is approved")
+	}
+	var expires any
+	if status == SecretRequestApproved {
+		expires = formatTime(expiresAt)
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE secret_access_requests
+		SET status = ?, decided_at = ?, decided_by = ?, grant_expires_at = ?
+		WHERE id = ? AND status = ?`,
+		status, formatTime(decidedAt), decidedBy, expires, strings.TrimSpace(id), SecretRequestPending)
+	if err != nil {
+		return SecretAccessRequest{}, fmt.Errorf("decide secret request %s: %w", id, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return SecretAccessRequest{}, fmt.Errorf("rows affected decide secret request %s: %w", id, err)
+	}
+	req, err := s.GetSecretAccessRequest(ctx, id)
+	if err != nil {
+		return SecretAccessRequest{}, err
+	}
+	if affected == 0 {
+		return req, ErrSecretRequestDecided
+	}
+	return req, nil
+}
+
+// InsertAuditRecord appends an entry to the audit log.
+func (s *Store) InsertAuditRecord(ctx context.Context, record AuditRecord) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if strings.TrimSpace(record.Action) == "" {
+		return errors.New("audit action is required")
+	}
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+	if _, err := s.DB.ExecContext(ctx, `INSERT INTO audit_log (user_id, action, resource, detail, timestamp) VALUES (?, ?, ?, ?, ?)`,
+		record.UserID, record.Action, record.Resource, record.Detail, formatTime(record.Timestamp)); err != nil {
+		return fmt.Errorf("insert audit log: %w", err)
+	}
+	return nil
+}
+
+func scanSecretRequestRow(scanner interface{ Scan(dest ...any) error }) (SecretAccessRequest, error) {
+	var req SecretAccessRequest
+	var jobID, decidedAt, decidedBy, expiresAt sql.NullString
+	var ttlSeconds int64
+	var requestedAt string
+	if err := scanner.Scan(
+		&req.ID,
+		&req.SecretName,
+		&req.SandboxVMID,
+		&req.SandboxName,
+		&jobID,
+		&req.Profile,
+		&req.Status,
+		&ttlSeconds,
+		&requestedAt,
+		&decidedAt,
+		&decidedBy,
+		&expiresAt,
+	); err != nil {
+		return SecretAccessRequest{}, err
+	}
+	req.JobID = jobID.String
+	req.DecidedBy = decidedBy.String
+	req.GrantTTL = time.Duration(ttlSeconds) * time.Second
+	var err error
+	if req.RequestedAt, err = parseTime(requestedAt); err != nil {
+		return SecretAccessRequest{}, fmt.Errorf("parse secret request requested_at: %w", err)
+	}
+	if decidedAt.Valid && decidedAt.String != "" {
+		if req.DecidedAt, err = parseTime(decidedAt.String); err != nil {
+			return SecretAccessRequest{}, fmt.Errorf("parse secret request decided_at: %w", err)
+		}
+	}
+	if expiresAt.Valid && expiresAt.String != "" {
+		if req.GrantExpiresAt, err = parseTime(expiresAt.String); err != nil {
+			return SecretAccessRequest{}, fmt.Errorf("parse secret request grant_expires_at: %w", err)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretAccessRequestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	_, err := store.LatestSecretAccessRequest(ctx, 1001, "ANTHROPIC_API_KEY", time.Time{})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, store.CreateSecretAccessRequest(ctx, SecretAccessRequest{
+		ID:          "secreq_1",
+		SecretName:  "ANTHROPIC_API_KEY",
+		SandboxVMID: 1001,
+		SandboxName: "sb-1001",
+		JobID:       "job_1",
+		Profile:     "yolo",
+		GrantTTL:    30 * time.Minute,
+		RequestedAt: now,
+	}))
+	require.NoError(t, store.CreateSecretAccessRequest(ctx, SecretAccessRequest{
+		ID:          "secreq_2",
+		SecretName:  "OPENAI_API_KEY",
+		SandboxVMID: 1001,
+		RequestedAt: now.Add(time.Minute),
+	}))
+
+	latest, err := store.LatestSecretAccessRequest(ctx, 1001, "ANTHROPIC_API_KEY", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "secreq_1", latest.ID)
+	assert.Equal(t, SecretRequestPending, latest.Status)
+	assert.Equal(t, "job_1", latest.JobID)
+	assert.Equal(t, 30*time.Minute, latest.GrantTTL)
+	assert.True(t, latest.RequestedAt.Equal(now))
+
+	// Requests made before a sandbox was created do not carry over to a new
+	// sandbox that reuses the vmid.
+	_, err = store.LatestSecretAccessRequest(ctx, 1001, "ANTHROPIC_API_KEY", now.Add(time.Second))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	pending, err := store.ListSecretAccessRequests(ctx, SecretRequestPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "secreq_2", pending[0].ID)
+
+	decidedAt := now.Add(5 * time.Minute)
+	approved, err := store.DecideSecretAccessRequest(ctx, "secreq_1", SecretRequestApproved, "alice", decidedAt, decidedAt.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", approved.DecidedBy)
+	assert.True(t, approved.Active(decidedAt.Add(29*time.Minute)))
+	assert.False(t, approved.Active(decidedAt.Add(30*time.Minute)))
+
+	_, err = store.DecideSecretAccessRequest(ctx, "secreq_1", SecretRequestDenied, "bob", decidedAt, time.Time{})
+	require.ErrorIs(t, err, ErrSecretRequestDecided)
+
+	denied, err := store.DecideSecretAccessRequest(ctx, "secreq_2", SecretRequestDenied, "bob", decidedAt, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, SecretRequestDenied, denied.Status)
+	assert.True(t, denied.GrantExpiresAt.IsZero())
+
+	_, err = store.DecideSecretAccessRequest(ctx, "secreq_missing", SecretRequestApproved, "alice", decidedAt, decidedAt.Add(time.Hour))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	all, err := store.ListSecretAccessRequests(ctx, "", 1)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	require.NoError(t, store.InsertAuditRecord(ctx, AuditRecord{UserID: "alice", Action: "secret.approve", Resource: "secret:ANTHROPIC_API_KEY", Timestamp: decidedAt}))
+	records, err := store.ListAuditRecordsSince(ctx, time.Time{}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "secret.approve", records[0].Action)
+}
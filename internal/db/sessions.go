@@ -50,14 +50,16 @@ func (s *Store) CreateSession(ctx context.Context, session models.Session) error
 		current = *session.CurrentVMID
 	}
 	_, err := s.DB.ExecContext(ctx, `INSERT INTO sessions (
-		id, name, workspace_id, current_vmid, profile, branch, created_at, updated_at, meta_json
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, name, workspace_id, current_vmid, profile, branch, owner, team, created_at, updated_at, meta_json
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		session.ID,
 		session.Name,
 		session.WorkspaceID,
 		current,
 		session.Profile,
 		nullIfEmpty(session.Branch),
+		strings.TrimSpace(session.Owner),
+		strings.TrimSpace(session.Team),
 		formatTime(createdAt),
 		formatTime(updatedAt),
 		nullIfEmpty(session.MetaJSON),
@@ -77,7 +79,7 @@ func (s *Store) GetSession(ctx context.Context, id string) (models.Session, erro
 	if id == "" {
 		return models.Session{}, errors.New("session id is required")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, name, workspace_id, current_vmid, profile, branch, created_at, updated_at, meta_json
+	row := s.DB.QueryRowContext(ctx, `SELECT id, name, workspace_id, current_vmid, profile, branch, owner, team, created_at, updated_at, meta_json
 		FROM sessions WHERE id = ?`, id)
 	return scanSessionRow(row)
 }
@@ -91,7 +93,7 @@ func (s *Store) GetSessionByName(ctx context.Context, name string) (models.Sessi
 	if name == "" {
 		return models.Session{}, errors.New("session name is required")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, name, workspace_id, current_vmid, profile, branch, created_at, updated_at, meta_json
+	row := s.DB.QueryRowContext(ctx, `SELECT id, name, workspace_id, current_vmid, profile, branch, owner, team, created_at, updated_at, meta_json
 		FROM sessions WHERE name = ?`, name)
 	return scanSessionRow(row)
 }
@@ -101,7 +103,7 @@ func (s *Store) ListSessions(ctx context.Context) ([]models.Session, error) {
 	if s == nil || s.DB == nil {
 		return nil, errors.New("db store is nil")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, workspace_id, current_vmid, profile, branch, created_at, updated_at, meta_json
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, workspace_id, current_vmid, profile, branch, owner, team, created_at, updated_at, meta_json
 		FROM sessions ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("list sessions: %w", err)
@@ -160,6 +162,32 @@ func (s *Store) UpdateSessionBranch(ctx context.Context, id, branch string) erro
 	return nil
 }
 
+// UpdateSessionOwnership sets the owner and the team that co-owns a session.
+// Empty values clear them.
+func (s *Store) UpdateSessionOwnership(ctx context.Context, id, owner, team string) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return errors.New("session id is required")
+	}
+	updatedAt := formatTime(time.Now().UTC())
+	res, err := s.DB.ExecContext(ctx, `UPDATE sessions SET owner = ?, team = ?, updated_at = ? WHERE id = ?`,
+		strings.TrimSpace(owner), strings.TrimSpace(team), updatedAt, id)
+	if err != nil {
+		return fmt.Errorf("update session %s ownership: %w", id, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected session %s ownership: %w", id, err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func scanSessionRow(scanner interface{ Scan(dest ...any) error }) (models.Session, error) {
 	var session models.Session
 	var current sql.NullInt64
@@ -167,7 +195,7 @@ func scanSessionRow(scanner interface{ Scan(dest ...any) error }) (models.Sessio
 	var createdAt string
 	var updatedAt string
 	var meta sql.NullString
-	if err := scanner.Scan(&session.ID, &session.Name, &session.WorkspaceID, &current, &session.Profile, &branch, &createdAt, &updatedAt, &meta); err != nil {
+	if err := scanner.Scan(&session.ID, &session.Name, &session.WorkspaceID, &current, &session.Profile, &branch, &session.Owner, &session.Team, &createdAt, &updatedAt, &meta); err != nil {
 		return models.Session{}, err
 	}
 	if current.Valid {
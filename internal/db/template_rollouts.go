@@ -0,0 +1,338 @@
+// ABOUTME: Template rollouts that move a profile between template versions, and per-sandbox template usage.
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+)
+
+// Template rollout statuses. A profile has at most one active rollout.
+const (
+	TemplateRolloutActive     = "active"
+	TemplateRolloutPromoted   = "promoted"
+	TemplateRolloutRolledBack = "rolled_back"
+)
+
+// ErrTemplateRolloutActive is returned when a profile already has an active
+// rollout.
+var ErrTemplateRolloutActive = errors.New("profile already has an active template rollout")
+
+// TemplateRollout moves a profile from one version of a template to another.
+// BaseRef is the profile's template reference when the rollout started; the
+// rollout only applies while the profile still names it.
+type TemplateRollout struct {
+	ID          int64
+	Profile     string
+	BaseRef     string
+	Template    string
+	FromVersion string
+	FromVMID    int
+	ToVersion   string
+	ToVMID      int
+	Percent     int
+	Status      string
+	StartedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  time.Time
+}
+
+// FromRef returns the name@version reference of the version being replaced.
+func (r TemplateRollout) FromRef() string {
+	return r.Template + "@" + r.FromVersion
+}
+
+// ToRef returns the name@version reference of the version being rolled out.
+func (r TemplateRollout) ToRef() string {
+	return r.Template + "@" + r.ToVersion
+}
+
+const templateRolloutColumns = `id, profile, base_ref, template, from_version, from_vmid, to_version, to_vmid,
+	percent, status, started_by, created_at, updated_at, finished_at`
+
+// CreateTemplateRollout inserts an active rollout and returns it with its id
+// set. It returns ErrTemplateRolloutActive when the profile already has one.
+func (s *Store) CreateTemplateRollout(ctx context.Context, rollout TemplateRollout) (TemplateRollout, error) {
+	if s == nil || s.DB == nil {
+		return TemplateRollout{}, errors.New("db store is nil")
+	}
+	rollout.Profile = strings.TrimSpace(rollout.Profile)
+	rollout.BaseRef = strings.TrimSpace(rollout.BaseRef)
+	if rollout.Profile == "" || rollout.BaseRef == "" || rollout.Template == "" {
+		return TemplateRollout{}, errors.New("rollout profile, base ref and template are required")
+	}
+	if rollout.FromVMID <= 0 || rollout.ToVMID <= 0 {
+		return TemplateRollout{}, errors.New("rollout vmids must be positive")
+	}
+	if rollout.Percent < 0 || rollout.Percent > 100 {
+		return TemplateRollout{}, errors.New("rollout percent must be between 0 and 100")
+	}
+	if rollout.CreatedAt.IsZero() {
+		rollout.CreatedAt = time.Now().UTC()
+	}
+	rollout.UpdatedAt = rollout.CreatedAt
+	rollout.Status = TemplateRolloutActive
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return TemplateRollout{}, fmt.Errorf("begin rollout %s: %w", rollout.Profile, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	var active int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM template_rollouts WHERE profile = ? AND status = ?`,
+		rollout.Profile, TemplateRolloutActive).Scan(&active); err != nil {
+		return TemplateRollout{}, fmt.Errorf("count active rollouts %s: %w", rollout.Profile, err)
+	}
+	if active > 0 {
+		return TemplateRollout{}, ErrTemplateRolloutActive
+	}
+	res, err := tx.ExecContext(ctx, `INSERT INTO template_rollouts
+		(profile, base_ref, template, from_version, from_vmid, to_version, to_vmid,
+		percent, status, started_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rollout.Profile,
+		rollout.BaseRef,
+		rollout.Template,
+		rollout.FromVersion,
+		rollout.FromVMID,
+		rollout.ToVersion,
+		rollout.ToVMID,
+		rollout.Percent,
+		rollout.Status,
+		nullIfEmpty(rollout.StartedBy),
+		formatTime(rollout.CreatedAt),
+		formatTime(rollout.UpdatedAt),
+	)
+	if err != nil {
+		return TemplateRollout{}, fmt.Errorf("insert rollout %s: %w", rollout.Profile, err)
+	}
+	if rollout.ID, err = res.LastInsertId(); err != nil {
+		return TemplateRollout{}, fmt.Errorf("rollout id %s: %w", rollout.Profile, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return TemplateRollout{}, fmt.Errorf("commit rollout %s: %w", rollout.Profile, err)
+	}
+	return rollout, nil
+}
+
+// LatestTemplateRollout returns the most recent rollout of a profile in any
+// status. It returns sql.ErrNoRows when the profile has none.
+func (s *Store) LatestTemplateRollout(ctx context.Context, profile string) (TemplateRollout, error) {
+	if s == nil || s.DB == nil {
+		return TemplateRollout{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+templateRolloutColumns+`
+		FROM template_rollouts WHERE profile = ?
+		ORDER BY id DESC LIMIT 1`, strings.TrimSpace(profile))
+	return scanTemplateRolloutRow(row)
+}
+
+// CurrentTemplateRollout returns the rollout that decides which template a
+// profile naming baseRef uses: the newest active or promoted one started from
+// that reference. Rolled-back rollouts are skipped, so rolling back returns
+// the profile to whatever was in force before. It returns sql.ErrNoRows when
+// no rollout applies.
+func (s *Store) CurrentTemplateRollout(ctx context.Context, profile, baseRef string) (TemplateRollout, error) {
+	if s == nil || s.DB == nil {
+		return TemplateRollout{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+templateRolloutColumns+`
+		FROM template_rollouts WHERE profile = ? AND base_ref = ? AND status != ?
+		ORDER BY id DESC LIMIT 1`, strings.TrimSpace(profile), strings.TrimSpace(baseRef), TemplateRolloutRolledBack)
+	return scanTemplateRolloutRow(row)
+}
+
+// UpdateTemplateRolloutPercent changes the share of new sandboxes an active
+// rollout sends to the new version. It returns sql.ErrNoRows when the
+// rollout is not active.
+func (s *Store) UpdateTemplateRolloutPercent(ctx context.Context, id int64, percent int, at time.Time) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if percent < 0 || percent > 100 {
+		return errors.New("rollout percent must be between 0 and 100")
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE template_rollouts SET percent = ?, updated_at = ?
+		WHERE id = ? AND status = ?`, percent, formatTime(at), id, TemplateRolloutActive)
+	if err != nil {
+		return fmt.Errorf("update rollout %d: %w", id, err)
+	}
+	return requireRowsAffected(res, fmt.Sprintf("rollout %d", id))
+}
+
+// FinishTemplateRollout promotes or rolls back an active rollout. It returns
+// sql.ErrNoRows when the rollout is not active.
+func (s *Store) FinishTemplateRollout(ctx context.Context, id int64, status string, at time.Time) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if status != TemplateRolloutPromoted && status != TemplateRolloutRolledBack {
+		return fmt.Errorf("invalid rollout status %q", status)
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE template_rollouts SET status = ?, updated_at = ?, finished_at = ?
+		WHERE id = ? AND status = ?`, status, formatTime(at), formatTime(at), id, TemplateRolloutActive)
+	if err != nil {
+		return fmt.Errorf("finish rollout %d: %w", id, err)
+	}
+	return requireRowsAffected(res, fmt.Sprintf("rollout %d", id))
+}
+
+// UpdateSandboxTemplate records the template VM a sandbox is cloned from.
+func (s *Store) UpdateSandboxTemplate(ctx context.Context, vmid, templateVMID int) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if vmid <= 0 || templateVMID <= 0 {
+		return errors.New("vmid must be positive")
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE sandboxes SET template_vmid = ? WHERE vmid = ?`, templateVMID, vmid)
+	if err != nil {
+		return fmt.Errorf("update sandbox %d template: %w", vmid, err)
+	}
+	return requireRowsAffected(res, fmt.Sprintf("sandbox %d template", vmid))
+}
+
+// ListTemplateVMIDsInUse returns the template VMs that sandboxes not yet
+// destroyed were cloned from. A linked clone depends on its template, so
+// these must not be removed.
+func (s *Store) ListTemplateVMIDsInUse(ctx context.Context) (map[int]bool, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT DISTINCT template_vmid FROM sandboxes
+		WHERE template_vmid IS NOT NULL AND state != ?`, string(models.SandboxDestroyed))
+	if err != nil {
+		return nil, fmt.Errorf("list template vmids in use: %w", err)
+	}
+	defer rows.Close()
+	out := make(map[int]bool)
+	for rows.Next() {
+		var vmid int
+		if err := rows.Scan(&vmid); err != nil {
+			return nil, fmt.Errorf("scan template vmid: %w", err)
+		}
+		out[vmid] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate template vmids: %w", err)
+	}
+	return out, nil
+}
+
+// TemplateUsage counts the sandboxes cloned from one template VM since a
+// point in time, and the jobs that ran in them.
+type TemplateUsage struct {
+	Sandboxes int
+	Jobs      int
+}
+
+// CountTemplateUsage counts sandboxes created from templateVMID at or after
+// since, and the jobs bound to those sandboxes.
+func (s *Store) CountTemplateUsage(ctx context.Context, templateVMID int, since time.Time) (TemplateUsage, error) {
+	if s == nil || s.DB == nil {
+		return TemplateUsage{}, errors.New("db store is nil")
+	}
+	var usage TemplateUsage
+	err := s.DB.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM sandboxes WHERE template_vmid = ? AND created_at >= ?),
+		(SELECT COUNT(*) FROM jobs j JOIN sandboxes s ON s.vmid = j.sandbox_vmid
+			WHERE s.template_vmid = ? AND s.created_at >= ?)`,
+		templateVMID, formatTime(since), templateVMID, formatTime(since)).Scan(&usage.Sandboxes, &usage.Jobs)
+	if err != nil {
+		return TemplateUsage{}, fmt.Errorf("count template %d usage: %w", templateVMID, err)
+	}
+	return usage, nil
+}
+
+// ListTemplateEvents returns events of the given kinds recorded for
+// sandboxes created from templateVMID at or after since, oldest first.
+func (s *Store) ListTemplateEvents(ctx context.Context, templateVMID int, since time.Time, kinds ...string) ([]Event, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	args := []any{templateVMID, formatTime(since), formatTime(since)}
+	placeholders := make([]string, len(kinds))
+	for i, kind := range kinds {
+		placeholders[i] = "?"
+		args = append(args, kind)
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT e.id, e.ts, e.kind, e.sandbox_vmid, e.job_id, e.msg, e.json, e.trace_id, e.span_id
+		FROM events e JOIN sandboxes s ON s.vmid = e.sandbox_vmid
+		WHERE s.template_vmid = ? AND s.created_at >= ? AND e.ts >= ?
+		AND e.kind IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY e.id ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list template %d events: %w", templateVMID, err)
+	}
+	defer rows.Close()
+	var out []Event
+	for rows.Next() {
+		ev, err := scanEventRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate template %d events: %w", templateVMID, err)
+	}
+	return out, nil
+}
+
+func requireRowsAffected(res sql.Result, what string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected %s: %w", what, err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanTemplateRolloutRow(scanner interface{ Scan(dest ...any) error }) (TemplateRollout, error) {
+	var rollout TemplateRollout
+	var startedBy, finishedAt sql.NullString
+	var createdAt, updatedAt string
+	if err := scanner.Scan(
+		&rollout.ID,
+		&rollout.Profile,
+		&rollout.BaseRef,
+		&rollout.Template,
+		&rollout.FromVersion,
+		&rollout.FromVMID,
+		&rollout.ToVersion,
+		&rollout.ToVMID,
+		&rollout.Percent,
+		&rollout.Status,
+		&startedBy,
+		&createdAt,
+		&updatedAt,
+		&finishedAt,
+	); err != nil {
+		return TemplateRollout{}, err
+	}
+	rollout.StartedBy = startedBy.String
+	var err error
+	if rollout.CreatedAt, err = parseTime(createdAt); err != nil {
+		return TemplateRollout{}, fmt.Errorf("parse rollout created_at: %w", err)
+	}
+	if rollout.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return TemplateRollout{}, fmt.Errorf("parse rollout updated_at: %w", err)
+	}
+	if finishedAt.Valid && finishedAt.String != "" {
+		if rollout.FinishedAt, err = parseTime(finishedAt.String); err != nil {
+			return TemplateRollout{}, fmt.Errorf("parse rollout finished_at: %w", err)
+		}
+	}
+	return rollout, nil
+}
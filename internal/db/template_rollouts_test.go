@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/models"
+	testutil "github.com/agentlab/agentlab/internal/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateRolloutLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err := store.CurrentTemplateRollout(ctx, "agent", "agent-base@v1")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	first, err := store.CreateTemplateRollout(ctx, TemplateRollout{
+		Profile:     "agent",
+		BaseRef:     "agent-base@v1",
+		Template:    "agent-base",
+		FromVersion: "v1",
+		FromVMID:    9000,
+		ToVersion:   "v2",
+		ToVMID:      9001,
+		Percent:     10,
+		StartedBy:   "alice",
+		CreatedAt:   now,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, TemplateRolloutActive, first.Status)
+	assert.Equal(t, "agent-base@v1", first.FromRef())
+	assert.Equal(t, "agent-base@v2", first.ToRef())
+
+	_, err = store.CreateTemplateRollout(ctx, TemplateRollout{
+		Profile: "agent", BaseRef: "agent-base@v1", Template: "agent-base",
+		FromVersion: "v1", FromVMID: 9000, ToVersion: "v3", ToVMID: 9002, CreatedAt: now,
+	})
+	require.ErrorIs(t, err, ErrTemplateRolloutActive)
+
+	require.NoError(t, store.UpdateTemplateRolloutPercent(ctx, first.ID, 50, now.Add(time.Minute)))
+	current, err := store.CurrentTemplateRollout(ctx, "agent", "agent-base@v1")
+	require.NoError(t, err)
+	assert.Equal(t, 50, current.Percent)
+	assert.Equal(t, "alice", current.StartedBy)
+
+	require.NoError(t, store.FinishTemplateRollout(ctx, first.ID, TemplateRolloutPromoted, now.Add(time.Hour)))
+	require.ErrorIs(t, store.FinishTemplateRollout(ctx, first.ID, TemplateRolloutRolledBack, now), sql.ErrNoRows, "only active rollouts finish")
+	require.ErrorIs(t, store.UpdateTemplateRolloutPercent(ctx, first.ID, 20, now), sql.ErrNoRows)
+
+	second, err := store.CreateTemplateRollout(ctx, TemplateRollout{
+		Profile: "agent", BaseRef: "agent-base@v1", Template: "agent-base",
+		FromVersion: "v2", FromVMID: 9001, ToVersion: "v3", ToVMID: 9002, Percent: 10, CreatedAt: now.Add(2 * time.Hour),
+	})
+	require.NoError(t, err)
+	current, err = store.CurrentTemplateRollout(ctx, "agent", "agent-base@v1")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, current.ID)
+
+	require.NoError(t, store.FinishTemplateRollout(ctx, second.ID, TemplateRolloutRolledBack, now.Add(3*time.Hour)))
+	current, err = store.CurrentTemplateRollout(ctx, "agent", "agent-base@v1")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, current.ID, "rolling back returns to the promoted rollout")
+	assert.Equal(t, now.Add(time.Hour), current.FinishedAt)
+
+	latest, err := store.LatestTemplateRollout(ctx, "agent")
+	require.NoError(t, err)
+	assert.Equal(t, TemplateRolloutRolledBack, latest.Status)
+
+	_, err = store.CurrentTemplateRollout(ctx, "agent", "agent-base@v4")
+	require.ErrorIs(t, err, sql.ErrNoRows, "a rollout stops applying once the profile names another version")
+}
+
+func TestTemplateUsage(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Now().UTC()
+
+	for _, sb := range []struct {
+		vmid, template int
+		state          models.SandboxState
+		createdAt      time.Time
+	}{
+		{vmid: 101, template: 9000, state: models.SandboxRunning, createdAt: now},
+		{vmid: 102, template: 9001, state: models.SandboxRunning, createdAt: now},
+		{vmid: 103, template: 9001, state: models.SandboxDestroyed, createdAt: now},
+		{vmid: 104, template: 9002, state: models.SandboxDestroyed, createdAt: now},
+		{vmid: 105, template: 9001, state: models.SandboxDestroyed, createdAt: now.Add(-24 * time.Hour)},
+	} {
+		require.NoError(t, store.CreateSandbox(ctx, testutil.NewTestSandbox(testutil.SandboxOpts{
+			VMID: sb.vmid, State: sb.state, CreatedAt: sb.createdAt,
+		})))
+		require.NoError(t, store.UpdateSandboxTemplate(ctx, sb.vmid, sb.template))
+	}
+	require.ErrorIs(t, store.UpdateSandboxTemplate(ctx, 999, 9000), sql.ErrNoRows)
+
+	inUse, err := store.ListTemplateVMIDsInUse(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]bool{9000: true, 9001: true}, inUse, "destroyed sandboxes do not hold their template")
+
+	require.NoError(t, store.CreateJob(ctx, testutil.NewTestJob(testutil.JobOpts{ID: "job-1"})))
+	_, err = store.UpdateJobSandbox(ctx, "job-1", 102)
+	require.NoError(t, err)
+	usage, err := store.CountTemplateUsage(ctx, 9001, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, TemplateUsage{Sandboxes: 2, Jobs: 1}, usage, "sandboxes created before the window are not counted")
+
+	vmid := 102
+	jobID := "job-1"
+	require.NoError(t, store.RecordEvent(ctx, "sandbox.slo.ready", &vmid, nil, "ready", `{"duration_ms":1200}`))
+	require.NoError(t, store.RecordEvent(ctx, "job.failed", &vmid, &jobID, "failed", ""))
+	require.NoError(t, store.RecordEvent(ctx, "sandbox.state", &vmid, nil, "running", ""))
+	other := 101
+	require.NoError(t, store.RecordEvent(ctx, "sandbox.slo.ready", &other, nil, "ready", ""))
+
+	events, err := store.ListTemplateEvents(ctx, 9001, now.Add(-time.Hour), "sandbox.slo.ready", "job.failed")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "sandbox.slo.ready", events[0].Kind)
+	assert.Equal(t, "job.failed", events[1].Kind)
+}
+
+func TestRetireTemplate(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Now().UTC()
+
+	tmpl, err := store.CreateTemplate(ctx, Template{Name: "agent-base", Version: "v1", VMID: 9000, CreatedAt: now})
+	require.NoError(t, err)
+	require.ErrorIs(t, store.RetireTemplate(ctx, tmpl.ID, now), sql.ErrNoRows, "building templates cannot be retired")
+	require.NoError(t, store.FinishTemplate(ctx, tmpl.ID, TemplateReady, "", now))
+	require.NoError(t, store.RetireTemplate(ctx, tmpl.ID, now.Add(time.Hour)))
+
+	got, err := store.GetTemplate(ctx, "agent-base", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, TemplateRetired, got.Status)
+	_, err = store.LatestReadyTemplate(ctx, "agent-base")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
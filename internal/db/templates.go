@@ -0,0 +1,230 @@
+// ABOUTME: VM templates built by agentlabd from a declarative spec, one row per version.
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Template build statuses.
+const (
+	TemplateBuilding = "building"
+	TemplateReady    = "ready"
+	TemplateFailed   = "failed"
+	TemplateRetired  = "retired"
+)
+
+// Template is one built version of a named template. The spec and image
+// checksum record what went into it.
+type Template struct {
+	ID             int64
+	Name           string
+	Version        string
+	VMID           int
+	Status         string
+	SpecYAML       string
+	SpecSHA256     string
+	ImageURL       string
+	ImageSHA256    string
+	BuiltBy        string
+	BuilderVersion string
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FinishedAt     time.Time
+}
+
+// Ref returns the template's name@version reference.
+func (t Template) Ref() string {
+	return t.Name + "@" + t.Version
+}
+
+const templateColumns = `id, name, version, vmid, status, spec_yaml, spec_sha256, image_url, image_sha256,
+	built_by, builder_version, error, created_at, updated_at, finished_at`
+
+// CreateTemplate inserts a template version in the building state and
+// returns it with its id set.
+func (s *Store) CreateTemplate(ctx context.Context, tmpl Template) (Template, error) {
+	if s == nil || s.DB == nil {
+		return Template{}, errors.New("db store is nil")
+	}
+	tmpl.Name = strings.TrimSpace(tmpl.Name)
+	tmpl.Version = strings.TrimSpace(tmpl.Version)
+	if tmpl.Name == "" || tmpl.Version == "" {
+		return Template{}, errors.New("template name and version are required")
+	}
+	if tmpl.VMID <= 0 {
+		return Template{}, errors.New("template vmid must be positive")
+	}
+	if tmpl.CreatedAt.IsZero() {
+		tmpl.CreatedAt = time.Now().UTC()
+	}
+	tmpl.UpdatedAt = tmpl.CreatedAt
+	tmpl.Status = TemplateBuilding
+	res, err := s.DB.ExecContext(ctx, `INSERT INTO templates
+		(name, version, vmid, status, spec_yaml, spec_sha256, image_url, image_sha256,
+		built_by, builder_version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tmpl.Name,
+		tmpl.Version,
+		tmpl.VMID,
+		tmpl.Status,
+		tmpl.SpecYAML,
+		tmpl.SpecSHA256,
+		tmpl.ImageURL,
+		tmpl.ImageSHA256,
+		nullIfEmpty(tmpl.BuiltBy),
+		nullIfEmpty(tmpl.BuilderVersion),
+		formatTime(tmpl.CreatedAt),
+		formatTime(tmpl.UpdatedAt),
+	)
+	if err != nil {
+		return Template{}, fmt.Errorf("insert template %s: %w", tmpl.Ref(), err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Template{}, fmt.Errorf("template id %s: %w", tmpl.Ref(), err)
+	}
+	tmpl.ID = id
+	return tmpl, nil
+}
+
+// GetTemplate loads one template version. It returns sql.ErrNoRows when the
+// version does not exist.
+func (s *Store) GetTemplate(ctx context.Context, name, version string) (Template, error) {
+	if s == nil || s.DB == nil {
+		return Template{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+templateColumns+`
+		FROM templates WHERE name = ? AND version = ?`, strings.TrimSpace(name), strings.TrimSpace(version))
+	return scanTemplateRow(row)
+}
+
+// LatestReadyTemplate returns the most recently built ready version of name.
+// It returns sql.ErrNoRows when no version is ready.
+func (s *Store) LatestReadyTemplate(ctx context.Context, name string) (Template, error) {
+	if s == nil || s.DB == nil {
+		return Template{}, errors.New("db store is nil")
+	}
+	row := s.DB.QueryRowContext(ctx, `SELECT `+templateColumns+`
+		FROM templates WHERE name = ? AND status = ?
+		ORDER BY id DESC LIMIT 1`, strings.TrimSpace(name), TemplateReady)
+	return scanTemplateRow(row)
+}
+
+// ListTemplates returns template versions ordered by name, newest version
+// first. An empty name matches every template.
+func (s *Store) ListTemplates(ctx context.Context, name string) ([]Template, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	query := `SELECT ` + templateColumns + ` FROM templates`
+	var args []any
+	if name = strings.TrimSpace(name); name != "" {
+		query += ` WHERE name = ?`
+		args = append(args, name)
+	}
+	query += ` ORDER BY name ASC, id DESC`
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+	defer rows.Close()
+	var out []Template
+	for rows.Next() {
+		tmpl, err := scanTemplateRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tmpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate templates: %w", err)
+	}
+	return out, nil
+}
+
+// FinishTemplate moves a building template to ready or failed. It returns
+// sql.ErrNoRows when no building template has that id.
+func (s *Store) FinishTemplate(ctx context.Context, id int64, status, errMsg string, finishedAt time.Time) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if status != TemplateReady && status != TemplateFailed {
+		return fmt.Errorf("invalid template status %q", status)
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE templates
+		SET status = ?, error = ?, updated_at = ?, finished_at = ?
+		WHERE id = ? AND status = ?`,
+		status, nullIfEmpty(errMsg), formatTime(finishedAt), formatTime(finishedAt), id, TemplateBuilding)
+	if err != nil {
+		return fmt.Errorf("finish template %d: %w", id, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected finish template %d: %w", id, err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RetireTemplate marks a ready template version retired once its VM has been
+// destroyed. It returns sql.ErrNoRows when no ready version has that id.
+func (s *Store) RetireTemplate(ctx context.Context, id int64, at time.Time) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE templates SET status = ?, updated_at = ?
+		WHERE id = ? AND status = ?`, TemplateRetired, formatTime(at), id, TemplateReady)
+	if err != nil {
+		return fmt.Errorf("retire template %d: %w", id, err)
+	}
+	return requireRowsAffected(res, fmt.Sprintf("template %d", id))
+}
+
+func scanTemplateRow(scanner interface{ Scan(dest ...any) error }) (Template, error) {
+	var tmpl Template
+	var builtBy, builderVersion, errMsg, finishedAt sql.NullString
+	var createdAt, updatedAt string
+	if err := scanner.Scan(
+		&tmpl.ID,
+		&tmpl.Name,
+		&tmpl.Version,
+		&tmpl.VMID,
+		&tmpl.Status,
+		&tmpl.SpecYAML,
+		&tmpl.SpecSHA256,
+		&tmpl.ImageURL,
+		&tmpl.ImageSHA256,
+		&builtBy,
+		&builderVersion,
+		&errMsg,
+		&createdAt,
+		&updatedAt,
+		&finishedAt,
+	); err != nil {
+		return Template{}, err
+	}
+	tmpl.BuiltBy = builtBy.String
+	tmpl.BuilderVersion = builderVersion.String
+	tmpl.Error = errMsg.String
+	var err error
+	if tmpl.CreatedAt, err = parseTime(createdAt); err != nil {
+		return Template{}, fmt.Errorf("parse template created_at: %w", err)
+	}
+	if tmpl.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return Template{}, fmt.Errorf("parse template updated_at: %w", err)
+	}
+	if finishedAt.Valid && finishedAt.String != "" {
+		if tmpl.FinishedAt, err = parseTime(finishedAt.String); err != nil {
+			return Template{}, fmt.Errorf("parse template finished_at: %w", err)
+		}
+	}
+	return tmpl, nil
+}
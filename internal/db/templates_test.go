@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err := store.LatestReadyTemplate(ctx, "agent-base")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	v1, err := store.CreateTemplate(ctx, Template{
+		Name:        "agent-base",
+		Version:     "v1",
+		VMID:        9001,
+		SpecYAML:    "name: agent-base\n",
+		SpecSHA256:  "spec1",
+		ImageURL:    "https://example.test/noble.img",
+		ImageSHA256: "img1",
+		BuiltBy:     "alice",
+		CreatedAt:   now,
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, v1.ID)
+	assert.Equal(t, TemplateBuilding, v1.Status)
+	assert.Equal(t, "agent-base@v1", v1.Ref())
+
+	_, err = store.CreateTemplate(ctx, Template{Name: "agent-base", Version: "v1", VMID: 9002, CreatedAt: now})
+	require.Error(t, err, "name and version are unique")
+
+	v2, err := store.CreateTemplate(ctx, Template{Name: "agent-base", Version: "v2", VMID: 9002, CreatedAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	require.NoError(t, store.FinishTemplate(ctx, v1.ID, TemplateReady, "", now.Add(10*time.Minute)))
+	require.NoError(t, store.FinishTemplate(ctx, v2.ID, TemplateFailed, "provisioning timed out", now.Add(2*time.Hour)))
+	require.ErrorIs(t, store.FinishTemplate(ctx, v1.ID, TemplateFailed, "", now), sql.ErrNoRows, "only building templates finish")
+
+	got, err := store.GetTemplate(ctx, "agent-base", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, TemplateReady, got.Status)
+	assert.Equal(t, 9001, got.VMID)
+	assert.Equal(t, "alice", got.BuiltBy)
+	assert.Equal(t, now.Add(10*time.Minute), got.FinishedAt)
+
+	latest, err := store.LatestReadyTemplate(ctx, "agent-base")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", latest.Version, "failed versions are never the latest ready one")
+
+	failed, err := store.GetTemplate(ctx, "agent-base", "v2")
+	require.NoError(t, err)
+	assert.Equal(t, "provisioning timed out", failed.Error)
+
+	_, err = store.CreateTemplate(ctx, Template{Name: "other", Version: "v1", VMID: 9003, CreatedAt: now})
+	require.NoError(t, err)
+	all, err := store.ListTemplates(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, []string{"agent-base@v2", "agent-base@v1", "other@v1"}, []string{all[0].Ref(), all[1].Ref(), all[2].Ref()})
+
+	named, err := store.ListTemplates(ctx, "other")
+	require.NoError(t, err)
+	require.Len(t, named, 1)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SetJobTraceparent stores the W3C traceparent of the span that ran a job,
+// so later work on the job continues its trace.
+func (s *Store) SetJobTraceparent(ctx context.Context, id, traceparent string) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return errors.New("job id is required")
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE jobs SET traceparent = ? WHERE id = ?`, nullIfEmpty(traceparent), id)
+	if err != nil {
+		return fmt.Errorf("update job %s traceparent: %w", id, err)
+	}
+	return requireRowsAffected(res, "job "+id+" traceparent")
+}
+
+// GetJobTraceparent returns a job's stored traceparent, or "" when the job
+// ran without tracing. It returns sql.ErrNoRows for an unknown job.
+func (s *Store) GetJobTraceparent(ctx context.Context, id string) (string, error) {
+	if s == nil || s.DB == nil {
+		return "", errors.New("db store is nil")
+	}
+	var value sql.NullString
+	if err := s.DB.QueryRowContext(ctx, `SELECT traceparent FROM jobs WHERE id = ?`, strings.TrimSpace(id)).Scan(&value); err != nil {
+		return "", err
+	}
+	return value.String, nil
+}
+
+// SetSandboxTraceparent stores the W3C traceparent of the span that
+// provisioned a sandbox.
+func (s *Store) SetSandboxTraceparent(ctx context.Context, vmid int, traceparent string) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if vmid <= 0 {
+		return errors.New("vmid must be positive")
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE sandboxes SET traceparent = ? WHERE vmid = ?`, nullIfEmpty(traceparent), vmid)
+	if err != nil {
+		return fmt.Errorf("update sandbox %d traceparent: %w", vmid, err)
+	}
+	return requireRowsAffected(res, fmt.Sprintf("sandbox %d traceparent", vmid))
+}
+
+// GetSandboxTraceparent returns a sandbox's stored traceparent, or "" when it
+// was provisioned without tracing. It returns sql.ErrNoRows for an unknown
+// sandbox.
+func (s *Store) GetSandboxTraceparent(ctx context.Context, vmid int) (string, error) {
+	if s == nil || s.DB == nil {
+		return "", errors.New("db store is nil")
+	}
+	var value sql.NullString
+	if err := s.DB.QueryRowContext(ctx, `SELECT traceparent FROM sandboxes WHERE vmid = ?`, vmid).Scan(&value); err != nil {
+		return "", err
+	}
+	return value.String, nil
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	testutil "github.com/agentlab/agentlab/internal/testing"
+	"github.com/agentlab/agentlab/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceparentColumns(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	require.NoError(t, store.CreateSandbox(ctx, testutil.NewTestSandbox(testutil.SandboxOpts{VMID: testutil.TestVMID})))
+	require.NoError(t, store.CreateJob(ctx, testutil.NewTestJob(testutil.JobOpts{ID: "job-1"})))
+
+	got, err := store.GetJobTraceparent(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	require.NoError(t, store.SetJobTraceparent(ctx, "job-1", traceparent))
+	got, err = store.GetJobTraceparent(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, traceparent, got)
+	require.ErrorIs(t, store.SetJobTraceparent(ctx, "missing", traceparent), sql.ErrNoRows)
+	_, err = store.GetJobTraceparent(ctx, "missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, store.SetSandboxTraceparent(ctx, testutil.TestVMID, traceparent))
+	got, err = store.GetSandboxTraceparent(ctx, testutil.TestVMID)
+	require.NoError(t, err)
+	assert.Equal(t, traceparent, got)
+	require.ErrorIs(t, store.SetSandboxTraceparent(ctx, 999999, traceparent), sql.ErrNoRows)
+}
+
+func TestRecordEventStoresSpanContext(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	require.NoError(t, store.CreateSandbox(ctx, testutil.NewTestSandbox(testutil.SandboxOpts{VMID: testutil.TestVMID})))
+
+	vmid := testutil.TestVMID
+	require.NoError(t, store.RecordEvent(ctx, "sandbox.untraced", &vmid, nil, "no span", ""))
+	traced := tracing.ContextWithRemote(ctx, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	require.NoError(t, store.RecordEvent(traced, "sandbox.traced", &vmid, nil, "in span", ""))
+
+	events, err := store.ListEventsBySandboxAll(ctx, vmid)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Empty(t, events[0].TraceID)
+	assert.Empty(t, events[0].SpanID)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", events[1].TraceID)
+	assert.Equal(t, "b7ad6b7169203331", events[1].SpanID)
+}
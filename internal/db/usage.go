@@ -0,0 +1,163 @@
+// ABOUTME: Downsampled per-sandbox resource usage history.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UsageSample is one resource usage reading for a sandbox. Disk and network
+// fields are the bytes moved since the previous sample.
+type UsageSample struct {
+	VMID             int
+	At               time.Time
+	CPUUsage         float64
+	CPUs             int
+	MemoryBytes      int64
+	MemoryLimitBytes int64
+	DiskReadBytes    int64
+	DiskWriteBytes   int64
+	NetInBytes       int64
+	NetOutBytes      int64
+}
+
+// UsageBucket aggregates the samples recorded for a sandbox within one
+// bucket of a resolution.
+type UsageBucket struct {
+	VMID             int
+	Resolution       time.Duration
+	Start            time.Time
+	Samples          int
+	CPUAvg           float64
+	CPUMax           float64
+	CPUs             int
+	MemoryAvgBytes   int64
+	MemoryMaxBytes   int64
+	MemoryLimitBytes int64
+	DiskReadBytes    int64
+	DiskWriteBytes   int64
+	NetInBytes       int64
+	NetOutBytes      int64
+}
+
+// RecordSandboxUsage folds sample into its bucket at each resolution.
+// Averages are running means over the bucket's samples, maxima keep the
+// highest reading, and byte counts are summed.
+func (s *Store) RecordSandboxUsage(ctx context.Context, sample UsageSample, resolutions []time.Duration) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	if sample.VMID <= 0 {
+		return errors.New("vmid must be positive")
+	}
+	if sample.At.IsZero() {
+		sample.At = time.Now().UTC()
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin sandbox usage tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, resolution := range resolutions {
+		if resolution < time.Second {
+			return fmt.Errorf("usage resolution %s is below one second", resolution)
+		}
+		bucket := sample.At.UTC().Truncate(resolution)
+		_, err := tx.ExecContext(ctx, `INSERT INTO sandbox_usage (
+				vmid, resolution_seconds, bucket_start, samples, cpu_avg, cpu_max, cpus,
+				mem_avg_bytes, mem_max_bytes, mem_limit_bytes,
+				disk_read_bytes, disk_write_bytes, net_in_bytes, net_out_bytes)
+			VALUES (?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(vmid, resolution_seconds, bucket_start) DO UPDATE SET
+				samples = samples + 1,
+				cpu_avg = (cpu_avg * samples + excluded.cpu_avg) / (samples + 1),
+				cpu_max = MAX(cpu_max, excluded.cpu_max),
+				cpus = excluded.cpus,
+				mem_avg_bytes = (mem_avg_bytes * samples + excluded.mem_avg_bytes) / (samples + 1),
+				mem_max_bytes = MAX(mem_max_bytes, excluded.mem_max_bytes),
+				mem_limit_bytes = excluded.mem_limit_bytes,
+				disk_read_bytes = disk_read_bytes + excluded.disk_read_bytes,
+				disk_write_bytes = disk_write_bytes + excluded.disk_write_bytes,
+				net_in_bytes = net_in_bytes + excluded.net_in_bytes,
+				net_out_bytes = net_out_bytes + excluded.net_out_bytes`,
+			sample.VMID,
+			int64(resolution/time.Second),
+			formatTime(bucket),
+			sample.CPUUsage,
+			sample.CPUUsage,
+			sample.CPUs,
+			sample.MemoryBytes,
+			sample.MemoryBytes,
+			sample.MemoryLimitBytes,
+			sample.DiskReadBytes,
+			sample.DiskWriteBytes,
+			sample.NetInBytes,
+			sample.NetOutBytes,
+		)
+		if err != nil {
+			return fmt.Errorf("record sandbox %d usage: %w", sample.VMID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit sandbox usage: %w", err)
+	}
+	return nil
+}
+
+// ListSandboxUsage returns a sandbox's buckets at resolution that start at or
+// after since, oldest first.
+func (s *Store) ListSandboxUsage(ctx context.Context, vmid int, resolution time.Duration, since time.Time) ([]UsageBucket, error) {
+	if s == nil || s.DB == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT bucket_start, samples, cpu_avg, cpu_max, cpus,
+			mem_avg_bytes, mem_max_bytes, mem_limit_bytes,
+			disk_read_bytes, disk_write_bytes, net_in_bytes, net_out_bytes
+		FROM sandbox_usage
+		WHERE vmid = ? AND resolution_seconds = ? AND bucket_start >= ?
+		ORDER BY bucket_start ASC`, vmid, int64(resolution/time.Second), formatTime(since.Truncate(resolution)))
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox %d usage: %w", vmid, err)
+	}
+	defer rows.Close()
+	var out []UsageBucket
+	for rows.Next() {
+		bucket := UsageBucket{VMID: vmid, Resolution: resolution}
+		var start string
+		if err := rows.Scan(&start, &bucket.Samples, &bucket.CPUAvg, &bucket.CPUMax, &bucket.CPUs,
+			&bucket.MemoryAvgBytes, &bucket.MemoryMaxBytes, &bucket.MemoryLimitBytes,
+			&bucket.DiskReadBytes, &bucket.DiskWriteBytes, &bucket.NetInBytes, &bucket.NetOutBytes); err != nil {
+			return nil, fmt.Errorf("scan sandbox usage: %w", err)
+		}
+		parsed, err := parseTime(start)
+		if err != nil {
+			return nil, fmt.Errorf("parse sandbox usage bucket_start: %w", err)
+		}
+		bucket.Start = parsed
+		out = append(out, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list sandbox %d usage: %w", vmid, err)
+	}
+	return out, nil
+}
+
+// PruneSandboxUsage deletes buckets at resolution that start before before
+// and returns the number removed.
+func (s *Store) PruneSandboxUsage(ctx context.Context, resolution time.Duration, before time.Time) (int64, error) {
+	if s == nil || s.DB == nil {
+		return 0, errors.New("db store is nil")
+	}
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM sandbox_usage WHERE resolution_seconds = ? AND bucket_start < ?`,
+		int64(resolution/time.Second), formatTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("prune sandbox usage: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune sandbox usage: %w", err)
+	}
+	return n, nil
+}
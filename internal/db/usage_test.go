@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testutil "github.com/agentlab/agentlab/internal/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxUsageBuckets(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	resolutions := []time.Duration{time.Minute, 10 * time.Minute}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	samples := []UsageSample{
+		{VMID: testutil.TestVMID, At: base.Add(5 * time.Second), CPUUsage: 0.2, CPUs: 2, MemoryBytes: 100, MemoryLimitBytes: 1000, DiskReadBytes: 10, NetInBytes: 1},
+		{VMID: testutil.TestVMID, At: base.Add(35 * time.Second), CPUUsage: 0.6, CPUs: 2, MemoryBytes: 300, MemoryLimitBytes: 1000, DiskReadBytes: 20, NetOutBytes: 7},
+		{VMID: testutil.TestVMID, At: base.Add(65 * time.Second), CPUUsage: 0.1, CPUs: 2, MemoryBytes: 200, MemoryLimitBytes: 1000, DiskWriteBytes: 5},
+	}
+	for _, sample := range samples {
+		require.NoError(t, store.RecordSandboxUsage(ctx, sample, resolutions))
+	}
+
+	minutes, err := store.ListSandboxUsage(ctx, testutil.TestVMID, time.Minute, base)
+	require.NoError(t, err)
+	require.Len(t, minutes, 2)
+	first := minutes[0]
+	assert.True(t, first.Start.Equal(base))
+	assert.Equal(t, 2, first.Samples)
+	assert.InDelta(t, 0.4, first.CPUAvg, 1e-9)
+	assert.InDelta(t, 0.6, first.CPUMax, 1e-9)
+	assert.Equal(t, int64(200), first.MemoryAvgBytes)
+	assert.Equal(t, int64(300), first.MemoryMaxBytes)
+	assert.Equal(t, int64(1000), first.MemoryLimitBytes)
+	assert.Equal(t, int64(30), first.DiskReadBytes)
+	assert.Equal(t, int64(1), first.NetInBytes)
+	assert.Equal(t, int64(7), first.NetOutBytes)
+	assert.True(t, minutes[1].Start.Equal(base.Add(time.Minute)))
+	assert.Equal(t, int64(5), minutes[1].DiskWriteBytes)
+
+	tens, err := store.ListSandboxUsage(ctx, testutil.TestVMID, 10*time.Minute, base)
+	require.NoError(t, err)
+	require.Len(t, tens, 1)
+	assert.Equal(t, 3, tens[0].Samples)
+	assert.Equal(t, int64(30), tens[0].DiskReadBytes)
+
+	later, err := store.ListSandboxUsage(ctx, testutil.TestVMID, time.Minute, base.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, later, 1)
+
+	removed, err := store.PruneSandboxUsage(ctx, time.Minute, base.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	minutes, err = store.ListSandboxUsage(ctx, testutil.TestVMID, time.Minute, base)
+	require.NoError(t, err)
+	assert.Len(t, minutes, 1)
+	tens, err = store.ListSandboxUsage(ctx, testutil.TestVMID, 10*time.Minute, base)
+	require.NoError(t, err)
+	assert.Len(t, tens, 1)
+
+	require.Error(t, store.RecordSandboxUsage(ctx, UsageSample{}, resolutions))
+}
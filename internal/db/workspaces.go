@@ -66,8 +66,8 @@ func (s *Store) CreateWorkspace(ctx context.Context, workspace models.Workspace)
 		lastAttached = attached
 	}
 	_, err := s.DB.ExecContext(ctx, `INSERT INTO workspaces (
-		id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, created_at, updated_at, meta_json
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, owner, team, created_at, updated_at, meta_json
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		workspace.ID,
 		workspace.Name,
 		workspace.Storage,
@@ -78,6 +78,8 @@ func (s *Store) CreateWorkspace(ctx context.Context, workspace models.Workspace)
 		leaseOwner,
 		leaseNonce,
 		leaseExpires,
+		strings.TrimSpace(workspace.Owner),
+		strings.TrimSpace(workspace.Team),
 		formatTime(createdAt),
 		formatTime(updatedAt),
 		nil,
@@ -93,7 +95,7 @@ func (s *Store) GetWorkspace(ctx context.Context, id string) (models.Workspace,
 	if s == nil || s.DB == nil {
 		return models.Workspace{}, errors.New("db store is nil")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, created_at, updated_at
+	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, owner, team, created_at, updated_at
 		FROM workspaces WHERE id = ?`, id)
 	return scanWorkspaceRow(row)
 }
@@ -103,7 +105,7 @@ func (s *Store) GetWorkspaceByName(ctx context.Context, name string) (models.Wor
 	if s == nil || s.DB == nil {
 		return models.Workspace{}, errors.New("db store is nil")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, created_at, updated_at
+	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, owner, team, created_at, updated_at
 		FROM workspaces WHERE name = ?`, name)
 	return scanWorkspaceRow(row)
 }
@@ -116,7 +118,7 @@ func (s *Store) GetWorkspaceByAttachedVMID(ctx context.Context, vmid int) (model
 	if vmid <= 0 {
 		return models.Workspace{}, errors.New("vmid must be positive")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, created_at, updated_at
+	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, owner, team, created_at, updated_at
 		FROM workspaces WHERE attached_vmid = ?`, vmid)
 	return scanWorkspaceRow(row)
 }
@@ -126,7 +128,7 @@ func (s *Store) ListWorkspaces(ctx context.Context) ([]models.Workspace, error)
 	if s == nil || s.DB == nil {
 		return nil, errors.New("db store is nil")
 	}
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, created_at, updated_at
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, owner, team, created_at, updated_at
 		FROM workspaces ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, fmt.Errorf("list workspaces: %w", err)
@@ -200,6 +202,32 @@ func (s *Store) DetachWorkspace(ctx context.Context, id string, vmid int) (bool,
 	return affected > 0, nil
 }
 
+// UpdateWorkspaceOwnership sets the owner and the team that co-owns a workspace.
+// Empty values clear them.
+func (s *Store) UpdateWorkspaceOwnership(ctx context.Context, id, owner, team string) error {
+	if s == nil || s.DB == nil {
+		return errors.New("db store is nil")
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return errors.New("workspace id is required")
+	}
+	updatedAt := formatTime(time.Now().UTC())
+	res, err := s.DB.ExecContext(ctx, `UPDATE workspaces SET owner = ?, team = ?, updated_at = ? WHERE id = ?`,
+		strings.TrimSpace(owner), strings.TrimSpace(team), updatedAt, id)
+	if err != nil {
+		return fmt.Errorf("update workspace %s ownership: %w", id, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected workspace %s ownership: %w", id, err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func scanWorkspaceRow(scanner interface{ Scan(dest ...any) error }) (models.Workspace, error) {
 	var ws models.Workspace
 	var attached sql.NullInt64
@@ -209,7 +237,7 @@ func scanWorkspaceRow(scanner interface{ Scan(dest ...any) error }) (models.Work
 	var leaseExpires sql.NullString
 	var createdAt string
 	var updatedAt string
-	if err := scanner.Scan(&ws.ID, &ws.Name, &ws.Storage, &ws.VolumeID, &ws.SizeGB, &attached, &lastAttached, &leaseOwner, &leaseNonce, &leaseExpires, &createdAt, &updatedAt); err != nil {
+	if err := scanner.Scan(&ws.ID, &ws.Name, &ws.Storage, &ws.VolumeID, &ws.SizeGB, &attached, &lastAttached, &leaseOwner, &leaseNonce, &leaseExpires, &ws.Owner, &ws.Team, &createdAt, &updatedAt); err != nil {
 		return models.Workspace{}, err
 	}
 	if attached.Valid {
@@ -258,7 +286,7 @@ func (s *Store) GetWorkspaceByLeaseOwner(ctx context.Context, owner string) (mod
 	if owner == "" {
 		return models.Workspace{}, errors.New("lease owner is required")
 	}
-	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, created_at, updated_at
+	row := s.DB.QueryRowContext(ctx, `SELECT id, name, storage, volid, size_gb, attached_vmid, last_attached_vmid, lease_owner, lease_nonce, lease_expires_at, owner, team, created_at, updated_at
 		FROM workspaces WHERE lease_owner = ?`, owner)
 	return scanWorkspaceRow(row)
 }
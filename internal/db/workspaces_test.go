@@ -272,6 +272,39 @@ func TestGetWorkspace(t *testing.T) {
 	})
 }
 
+func TestUpdateWorkspaceOwnership(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		store := openTestStore(t)
+		ws := models.Workspace{
+			ID:       "ws-1",
+			Name:     "test-workspace",
+			Storage:  "local-zfs",
+			VolumeID: "local-zfs:vm-100-disk-1",
+			SizeGB:   50,
+			Owner:    "alice",
+		}
+		require.NoError(t, store.CreateWorkspace(ctx, ws))
+		got, err := store.GetWorkspace(ctx, "ws-1")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", got.Owner)
+		assert.Empty(t, got.Team)
+
+		require.NoError(t, store.UpdateWorkspaceOwnership(ctx, "ws-1", "alice", "infra"))
+		got, err = store.GetWorkspaceByName(ctx, "test-workspace")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", got.Owner)
+		assert.Equal(t, "infra", got.Team)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		store := openTestStore(t)
+		err := store.UpdateWorkspaceOwnership(ctx, "nonexistent", "alice", "")
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+}
+
 func TestGetWorkspaceByName(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,192 @@
+// ABOUTME: Git hosting adapters that open pull/merge requests for pushed job branches.
+// ABOUTME: Supports GitHub, GitLab, and Gitea (including Forgejo) REST APIs.
+
+// Package forge opens pull requests on git hosting services for branches the
+// daemon pushed on behalf of a job. Each adapter speaks one provider's REST
+// API with a daemon-held token; nothing here runs inside a sandbox.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout caps each provider API call.
+const DefaultTimeout = 30 * time.Second
+
+// Kind names a git hosting provider.
+type Kind string
+
+const (
+	KindGitHub Kind = "github"
+	KindGitLab Kind = "gitlab"
+	KindGitea  Kind = "gitea"
+)
+
+// ErrUnknownKind is returned for a provider name that has no adapter.
+var ErrUnknownKind = errors.New("forge provider must be 'github', 'gitlab', or 'gitea'")
+
+// ParseKind validates a provider name. An empty name returns "" so callers
+// can fall back to DetectKind.
+func ParseKind(value string) (Kind, error) {
+	switch kind := Kind(strings.ToLower(strings.TrimSpace(value))); kind {
+	case "", KindGitHub, KindGitLab, KindGitea:
+		return kind, nil
+	default:
+		return "", ErrUnknownKind
+	}
+}
+
+// DetectKind guesses the provider from a repository or host URL: github.com
+// and GitHub Enterprise hosts named "github", hosts named "gitlab", and
+// Gitea for everything else (the common self-hosted case).
+func DetectKind(rawURL string) Kind {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	host = strings.ToLower(host)
+	switch {
+	case strings.Contains(host, "github"):
+		return KindGitHub
+	case strings.Contains(host, "gitlab"):
+		return KindGitLab
+	default:
+		return KindGitea
+	}
+}
+
+// Repo identifies a repository on a provider.
+type Repo struct {
+	// BaseURL is the scheme and host (plus any path prefix) the repository
+	// lives under, e.g. https://git.example.com.
+	BaseURL string
+	// Path is the owner/name path without the .git suffix. GitLab paths may
+	// include nested groups.
+	Path string
+}
+
+// Owner returns the first path segment.
+func (r Repo) Owner() string {
+	owner, _, _ := strings.Cut(r.Path, "/")
+	return owner
+}
+
+// Name returns the last path segment.
+func (r Repo) Name() string {
+	return r.Path[strings.LastIndex(r.Path, "/")+1:]
+}
+
+// ParseRepoURL splits an http(s) clone URL into the provider base and the
+// repository path. prefix is the integration target the repository lives
+// under; when set, the path is taken relative to it so hosts served under a
+// sub-path resolve correctly.
+func ParseRepoURL(repoURL, prefix string) (Repo, error) {
+	u, err := url.Parse(strings.TrimSpace(repoURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Repo{}, fmt.Errorf("repo url %q must be an http(s) clone url", repoURL)
+	}
+	base := u.Scheme + "://" + u.Host
+	path := strings.Trim(u.Path, "/")
+	if prefix = strings.TrimRight(strings.TrimSpace(prefix), "/"); prefix != "" {
+		full := base + "/" + path
+		if !strings.HasPrefix(full, prefix+"/") {
+			return Repo{}, fmt.Errorf("repo url %q is not under %q", repoURL, prefix)
+		}
+		base = prefix
+		path = strings.TrimPrefix(full, prefix+"/")
+	}
+	path = strings.TrimSuffix(path, ".git")
+	if !strings.Contains(path, "/") {
+		return Repo{}, fmt.Errorf("repo url %q has no owner/name path", repoURL)
+	}
+	return Repo{BaseURL: base, Path: path}, nil
+}
+
+// PullRequest describes the request to open.
+type PullRequest struct {
+	Repo  Repo
+	Head  string
+	Base  string
+	Title string
+	Body  string
+}
+
+// Provider opens pull requests on one hosting service.
+type Provider interface {
+	// OpenPullRequest opens a pull (merge) request and returns its web URL.
+	OpenPullRequest(ctx context.Context, pr PullRequest) (string, error)
+}
+
+// New returns the adapter for kind, authenticated with token. A nil client
+// uses one with DefaultTimeout.
+func New(kind Kind, token string, client *http.Client) (Provider, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, errors.New("forge token is required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	api := apiClient{http: client}
+	switch kind {
+	case KindGitHub:
+		api.authHeader = "Bearer " + token
+		return gitHub{api: api}, nil
+	case KindGitLab:
+		api.authHeader = "Bearer " + token
+		return gitLab{api: api}, nil
+	case KindGitea:
+		api.authHeader = "token " + token
+		return gitea{api: api}, nil
+	default:
+		return nil, ErrUnknownKind
+	}
+}
+
+type apiClient struct {
+	http       *http.Client
+	authHeader string
+}
+
+func (c apiClient) postJSON(ctx context.Context, urlStr string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "agentlab")
+	req.Header.Set("Authorization", c.authHeader)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s failed: %s: %s", req.URL.Path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+func requireURL(kind Kind, value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("%s response missing pull request url", kind)
+	}
+	return value, nil
+}
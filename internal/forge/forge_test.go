@@ -0,0 +1,259 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	testutil "github.com/agentlab/agentlab/internal/testing"
+)
+
+func TestParseRepoURL(t *testing.T) {
+	repo, err := ParseRepoURL("https://gitlab.example.com/group/sub/project.git", "")
+	if err != nil {
+		t.Fatalf("ParseRepoURL() error = %v", err)
+	}
+	if repo.BaseURL != "https://gitlab.example.com" || repo.Path != "group/sub/project" || repo.Owner() != "group" || repo.Name() != "project" {
+		t.Fatalf("unexpected repo %+v", repo)
+	}
+	repo, err = ParseRepoURL("https://git.example.com/gitea/org/repo.git", "https://git.example.com/gitea/")
+	if err != nil {
+		t.Fatalf("ParseRepoURL() with prefix error = %v", err)
+	}
+	if repo.BaseURL != "https://git.example.com/gitea" || repo.Path != "org/repo" {
+		t.Fatalf("unexpected prefixed repo %+v", repo)
+	}
+	for _, bad := range []string{"git@github.com:org/repo.git", "https://example.com/repo.git"} {
+		if _, err := ParseRepoURL(bad, ""); err == nil {
+			t.Errorf("ParseRepoURL(%q) expected error", bad)
+		}
+	}
+	if _, err := ParseRepoURL("https://other.example.com/org/repo.git", "https://git.example.com"); err == nil {
+		t.Errorf("expected error for repo outside prefix")
+	}
+}
+
+func TestDetectKind(t *testing.T) {
+	cases := map[string]Kind{
+		"https://github.com/org/repo.git":       KindGitHub,
+		"https://github.corp.example.com/org/r": KindGitHub,
+		"https://gitlab.com/group/project.git":  KindGitLab,
+		"https://code.example.com/org/repo.git": KindGitea,
+	}
+	for input, want := range cases {
+		if got := DetectKind(input); got != want {
+			t.Errorf("DetectKind(%q) = %q, want %q", input, got, want)
+		}
+	}
+	if _, err := ParseKind("bitbucket"); err == nil {
+		t.Fatalf("expected ParseKind error")
+	}
+}
+
+func TestProviderRequests(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.EscapedPath(), r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"html_url":"https://example.com/pr/1","web_url":"https://example.com/mr/1"}`))
+	}))
+	defer srv.Close()
+
+	pr := PullRequest{Repo: Repo{BaseURL: srv.URL, Path: "group/sub/project"}, Head: "agent/job_1", Base: "main", Title: "t", Body: "b"}
+	cases := []struct {
+		kind    Kind
+		path    string
+		auth    string
+		headKey string
+		wantURL string
+	}{
+		{KindGitHub, "/api/v3/repos/group/project/pulls", "Bearer secret", "head", "https://example.com/pr/1"},
+		{KindGitLab, "/api/v4/projects/group%2Fsub%2Fproject/merge_requests", "Bearer secret", "source_branch", "https://example.com/mr/1"},
+		{KindGitea, "/api/v1/repos/group/project/pulls", "token secret", "head", "https://example.com/pr/1"},
+	}
+	for _, tc := range cases {
+		provider, err := New(tc.kind, "secret", srv.Client())
+		if err != nil {
+			t.Fatalf("New(%s) error = %v", tc.kind, err)
+		}
+		url, err := provider.OpenPullRequest(context.Background(), pr)
+		if err != nil {
+			t.Fatalf("%s OpenPullRequest() error = %v", tc.kind, err)
+		}
+		if url != tc.wantURL || gotPath != tc.path || gotAuth != tc.auth || gotBody[tc.headKey] != "agent/job_1" {
+			t.Fatalf("%s: url=%q path=%q auth=%q body=%v", tc.kind, url, gotPath, gotAuth, gotBody)
+		}
+	}
+	if _, err := New(KindGitea, " ", nil); err == nil {
+		t.Fatalf("expected error for empty token")
+	}
+}
+
+func TestPushBundleAndOpenPullRequest(t *testing.T) {
+	ctx := context.Background()
+	server := testutil.NewGiteaServer(t, "bot", "s3cret")
+	cloneURL := server.CreateRepo(t, "org/repo")
+
+	work := t.TempDir()
+	git(t, "", "clone", "--quiet", filepath.Join(server.Root, "org/repo.git"), work)
+	base := git(t, work, "rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(work, "fix.txt"), []byte("fixed\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	git(t, work, "add", "fix.txt")
+	git(t, work, "commit", "--quiet", "-m", "fix")
+	head := git(t, work, "rev-parse", "HEAD")
+	bundle := filepath.Join(t.TempDir(), "push.bundle")
+	git(t, work, "bundle", "create", "--quiet", bundle, "HEAD", "^"+base)
+
+	header, err := ReadBundleHeader(bundle)
+	if err != nil {
+		t.Fatalf("ReadBundleHeader() error = %v", err)
+	}
+	if len(header.Prerequisites) != 1 || header.Prerequisites[0] != base || header.Refs["HEAD"] != head {
+		t.Fatalf("unexpected bundle header %+v", header)
+	}
+
+	_, err = PushBundle(ctx, PushOptions{RemoteURL: cloneURL, Username: "bot", Password: "wrong", BundlePath: bundle, BaseRef: "main", Branch: "agent/job_1"})
+	if err == nil || strings.Contains(err.Error(), "wrong") {
+		t.Fatalf("expected auth failure without leaking the secret, got %v", err)
+	}
+	commit, err := PushBundle(ctx, PushOptions{RemoteURL: cloneURL, Username: "bot", Password: "s3cret", BundlePath: bundle, BaseRef: "main", Branch: "agent/job_1"})
+	if err != nil {
+		t.Fatalf("PushBundle() error = %v", err)
+	}
+	if commit != head || server.BranchCommit(t, "org/repo", "agent/job_1") != head {
+		t.Fatalf("pushed commit %s, want %s", commit, head)
+	}
+
+	repo, err := ParseRepoURL(cloneURL, server.URL)
+	if err != nil {
+		t.Fatalf("ParseRepoURL() error = %v", err)
+	}
+	provider, err := New(KindGitea, "s3cret", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	url, err := provider.OpenPullRequest(ctx, PullRequest{Repo: repo, Head: "agent/job_1", Base: "main", Title: "Fix"})
+	if err != nil {
+		t.Fatalf("OpenPullRequest() error = %v", err)
+	}
+	if url != server.URL+"/org/repo/pulls/1" {
+		t.Fatalf("unexpected pull request url %q", url)
+	}
+	if pulls := server.PullRequests(); len(pulls) != 1 || pulls[0].Head != "agent/job_1" || pulls[0].Base != "main" {
+		t.Fatalf("unexpected pull requests %+v", pulls)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=agent", "GIT_AUTHOR_EMAIL=agent@example.com",
+		"GIT_COMMITTER_NAME=agent", "GIT_COMMITTER_EMAIL=agent@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestReadBundleHeaderRejectsMaliciousHeaders(t *testing.T) {
+	oid := strings.Repeat("a", 40)
+	for _, tc := range []struct {
+		name   string
+		header string
+	}{
+		{"option as prerequisite", "--upload-pack=touch /tmp/pwned\n"},
+		{"short prerequisite", "-abc123 base\n"},
+		{"option as object id", "--output=/tmp/x refs/heads/main\n"},
+		{"option as ref", oid + " --upload-pack=touch\n"},
+		{"ref outside refs/", oid + " main\n"},
+		{"ref with ..", oid + " refs/heads/a..b\n"},
+		{"ref with space", oid + " refs/heads/a b\n"},
+		{"ref with lock suffix", oid + " refs/heads/x.lock\n"},
+		{"uppercase object id", strings.Repeat("A", 40) + " HEAD\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "push.bundle")
+			if err := os.WriteFile(path, []byte("# v2 git bundle\n"+tc.header+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadBundleHeader(path); err == nil {
+				t.Fatalf("ReadBundleHeader() accepted %q", tc.header)
+			}
+			// PushBundle refuses before running git at all.
+			_, err := PushBundle(context.Background(), PushOptions{RemoteURL: "https://git.example.com/org/repo.git", BundlePath: path, Branch: "agent/job_1", GitPath: filepath.Join(t.TempDir(), "no-git")})
+			if err == nil || !strings.Contains(err.Error(), "git bundle") {
+				t.Fatalf("PushBundle() error = %v, want a bundle header error", err)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "push.bundle")
+	sha256 := strings.Repeat("0123456789abcdef", 4)
+	if err := os.WriteFile(path, []byte("# v3 git bundle\n@object-format=sha256\n-"+sha256+" base\n"+sha256+" refs/heads/agent/job_1\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	header, err := ReadBundleHeader(path)
+	if err != nil || len(header.Prerequisites) != 1 || header.Refs["refs/heads/agent/job_1"] != sha256 {
+		t.Fatalf("ReadBundleHeader() = %+v, %v", header, err)
+	}
+}
+
+func TestPushBundleRejectsOptionLikeNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "push.bundle")
+	if err := os.WriteFile(path, []byte("# v2 git bundle\n"+strings.Repeat("a", 40)+" HEAD\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []PushOptions{
+		{Branch: "--force"},
+		{Branch: "agent/job..1"},
+		{Branch: "agent/job_1", BaseRef: "--upload-pack=touch /tmp/pwned"},
+	} {
+		opts.RemoteURL, opts.BundlePath, opts.GitPath = "https://git.example.com/org/repo.git", path, filepath.Join(t.TempDir(), "no-git")
+		if _, err := PushBundle(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("PushBundle(branch %q, base %q) error = %v, want invalid name", opts.Branch, opts.BaseRef, err)
+		}
+	}
+}
+
+func TestValidRefName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"HEAD":                  true,
+		"main":                  true,
+		"refs/heads/agent/job1": true,
+		"":                      false,
+		"@":                     false,
+		"-main":                 false,
+		"refs/heads/":           false,
+		"/refs/heads/x":         false,
+		"refs//heads":           false,
+		"refs/heads/.hidden":    false,
+		"refs/heads/x.":         false,
+		"refs/heads/x@{1}":      false,
+		"refs/heads/x~1":        false,
+		"refs/heads/x^":         false,
+		"refs/heads/a:b":        false,
+		"refs/heads/a?":         false,
+		"refs/heads/a*":         false,
+		"refs/heads/a[":         false,
+		"refs/heads/a\\b":       false,
+		"refs/heads/a\x01":      false,
+	} {
+		if got := validRefName(name); got != want {
+			t.Errorf("validRefName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package forge
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// gitHub opens pull requests through the GitHub REST API. github.com uses
+// api.github.com; Enterprise hosts serve the API under /api/v3.
+type gitHub struct {
+	api apiClient
+}
+
+func (g gitHub) OpenPullRequest(ctx context.Context, pr PullRequest) (string, error) {
+	apiBase := strings.TrimRight(pr.Repo.BaseURL, "/") + "/api/v3"
+	if u, err := url.Parse(pr.Repo.BaseURL); err == nil && strings.EqualFold(u.Hostname(), "github.com") {
+		apiBase = "https://api.github.com"
+	}
+	body := map[string]string{"title": pr.Title, "head": pr.Head, "base": pr.Base, "body": pr.Body}
+	var resp struct {
+		HTMLURL string `json:"html_url"`
+	}
+	endpoint := apiBase + "/repos/" + url.PathEscape(pr.Repo.Owner()) + "/" + url.PathEscape(pr.Repo.Name()) + "/pulls"
+	if err := g.api.postJSON(ctx, endpoint, body, &resp); err != nil {
+		return "", err
+	}
+	return requireURL(KindGitHub, resp.HTMLURL)
+}
+
+// gitLab opens merge requests through the GitLab v4 API. The project is
+// addressed by its URL-encoded full path, so nested groups work.
+type gitLab struct {
+	api apiClient
+}
+
+func (g gitLab) OpenPullRequest(ctx context.Context, pr PullRequest) (string, error) {
+	body := map[string]string{"title": pr.Title, "source_branch": pr.Head, "target_branch": pr.Base, "description": pr.Body}
+	var resp struct {
+		WebURL string `json:"web_url"`
+	}
+	endpoint := strings.TrimRight(pr.Repo.BaseURL, "/") + "/api/v4/projects/" + url.PathEscape(pr.Repo.Path) + "/merge_requests"
+	if err := g.api.postJSON(ctx, endpoint, body, &resp); err != nil {
+		return "", err
+	}
+	return requireURL(KindGitLab, resp.WebURL)
+}
+
+// gitea opens pull requests through the Gitea v1 API, which Forgejo also
+// serves.
+type gitea struct {
+	api apiClient
+}
+
+func (g gitea) OpenPullRequest(ctx context.Context, pr PullRequest) (string, error) {
+	body := map[string]string{"title": pr.Title, "head": pr.Head, "base": pr.Base, "body": pr.Body}
+	var resp struct {
+		HTMLURL string `json:"html_url"`
+	}
+	endpoint := strings.TrimRight(pr.Repo.BaseURL, "/") + "/api/v1/repos/" + url.PathEscape(pr.Repo.Owner()) + "/" + url.PathEscape(pr.Repo.Name()) + "/pulls"
+	if err := g.api.postJSON(ctx, endpoint, body, &resp); err != nil {
+		return "", err
+	}
+	return requireURL(KindGitea, resp.HTMLURL)
+}
@@ -0,0 +1,244 @@
+package forge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PushOptions describes a git bundle to push to a remote branch.
+type PushOptions struct {
+	// RemoteURL is the http(s) clone URL of the repository.
+	RemoteURL string
+	// Username and Password are sent as HTTP Basic credentials through the
+	// process environment, never on the command line or in the remote URL.
+	Username string
+	Password string
+	// BundlePath is the git bundle the guest uploaded.
+	BundlePath string
+	// BaseRef is fetched from the remote first so the bundle's prerequisite
+	// commits resolve. Prerequisites still missing afterwards are fetched by
+	// commit id.
+	BaseRef string
+	// Branch is the destination branch name (without refs/heads/).
+	Branch string
+	// GitPath overrides the git binary (default "git").
+	GitPath string
+}
+
+// BundleHeader lists a bundle's prerequisite commits and the refs it carries.
+type BundleHeader struct {
+	Prerequisites []string
+	Refs          map[string]string
+}
+
+// ReadBundleHeader parses the text header of a v2 or v3 git bundle. The
+// bundle comes from the guest, so every object id must be a full hex id and
+// every ref HEAD or a well-formed name under refs/; anything else is rejected
+// before it can reach a git command line.
+func ReadBundleHeader(path string) (BundleHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return BundleHeader{}, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	signature, err := reader.ReadString('\n')
+	if err != nil || (signature != "# v2 git bundle\n" && signature != "# v3 git bundle\n") {
+		return BundleHeader{}, errors.New("not a git bundle")
+	}
+	header := BundleHeader{Refs: make(map[string]string)}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return BundleHeader{}, errors.New("truncated git bundle header")
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return header, nil
+		case strings.HasPrefix(line, "@"):
+			// v3 capability line.
+		case strings.HasPrefix(line, "-"):
+			oid, _, _ := strings.Cut(line[1:], " ")
+			if !validObjectID(oid) {
+				return BundleHeader{}, fmt.Errorf("invalid git bundle prerequisite %q", oid)
+			}
+			header.Prerequisites = append(header.Prerequisites, oid)
+		default:
+			oid, ref, ok := strings.Cut(line, " ")
+			if !ok {
+				return BundleHeader{}, fmt.Errorf("invalid git bundle ref line %q", line)
+			}
+			if !validObjectID(oid) {
+				return BundleHeader{}, fmt.Errorf("invalid git bundle object id %q", oid)
+			}
+			if ref != "HEAD" && (!strings.HasPrefix(ref, "refs/") || !validRefName(ref)) {
+				return BundleHeader{}, fmt.Errorf("invalid git bundle ref %q", ref)
+			}
+			header.Refs[ref] = oid
+		}
+	}
+}
+
+// PushBundle pushes the bundle's HEAD (or its only ref) to Branch on the
+// remote and returns the pushed commit. The work happens in a throwaway bare
+// repository so nothing from the guest is ever checked out or executed.
+func PushBundle(ctx context.Context, opts PushOptions) (string, error) {
+	if strings.TrimSpace(opts.RemoteURL) == "" {
+		return "", errors.New("remote url is required")
+	}
+	if strings.TrimSpace(opts.Branch) == "" {
+		return "", errors.New("branch is required")
+	}
+	if !validRefName(opts.Branch) || !validRefName("refs/heads/"+opts.Branch) {
+		return "", fmt.Errorf("invalid branch name %q", opts.Branch)
+	}
+	base := strings.TrimSpace(opts.BaseRef)
+	if base != "" && !validObjectID(base) && !validRefName(base) {
+		return "", fmt.Errorf("invalid base ref %q", opts.BaseRef)
+	}
+	header, err := ReadBundleHeader(opts.BundlePath)
+	if err != nil {
+		return "", err
+	}
+	sourceRef := "HEAD"
+	source, ok := header.Refs[sourceRef]
+	if !ok {
+		if len(header.Refs) != 1 {
+			return "", errors.New("git bundle must carry HEAD or exactly one ref")
+		}
+		for ref, oid := range header.Refs {
+			sourceRef, source = ref, oid
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "agentlab-push-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	g := gitRunner{path: opts.GitPath, dir: dir, env: gitEnv(dir, opts.Username, opts.Password)}
+	if g.path == "" {
+		g.path = "git"
+	}
+	// Every command ends its options with --end-of-options, so no value
+	// that follows can be taken for one.
+	if _, err := g.run(ctx, "init", "--quiet", "--bare", "--end-of-options", dir); err != nil {
+		return "", err
+	}
+	if len(header.Prerequisites) > 0 {
+		if base != "" {
+			// A moved or deleted base ref is not fatal; the commit-id fetch
+			// below covers it.
+			_, _ = g.run(ctx, "fetch", "--quiet", "--no-tags", "--end-of-options", opts.RemoteURL, base)
+		}
+		for _, oid := range header.Prerequisites {
+			if _, err := g.run(ctx, "cat-file", "-e", "--end-of-options", oid+"^{commit}"); err == nil {
+				continue
+			}
+			if _, err := g.run(ctx, "fetch", "--quiet", "--no-tags", "--end-of-options", opts.RemoteURL, oid); err != nil {
+				return "", fmt.Errorf("fetch base commit %s: %w", oid, err)
+			}
+		}
+	}
+	localRef := "refs/heads/" + opts.Branch
+	if _, err := g.run(ctx, "fetch", "--quiet", "--no-tags", "--end-of-options", opts.BundlePath, sourceRef); err != nil {
+		return "", fmt.Errorf("unpack bundle: %w", err)
+	}
+	if _, err := g.run(ctx, "update-ref", "--end-of-options", localRef, source); err != nil {
+		return "", err
+	}
+	if _, err := g.run(ctx, "push", "--quiet", "--end-of-options", opts.RemoteURL, localRef+":"+localRef); err != nil {
+		return "", fmt.Errorf("push %s: %w", opts.Branch, err)
+	}
+	return source, nil
+}
+
+// validObjectID reports whether s is a full SHA-1 or SHA-256 object id in
+// lowercase hex, as git writes them.
+func validObjectID(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRefName reports whether name passes git check-ref-format
+// --allow-onelevel, and does not start with "-".
+func validRefName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") {
+		return false
+	}
+	if strings.ContainsFunc(name, func(c rune) bool {
+		return c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c)
+	}) {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// gitEnv isolates git from host configuration and passes credentials as an
+// http.extraHeader through GIT_CONFIG_* variables.
+func gitEnv(home, username, password string) []string {
+	env := []string{
+		"HOME=" + home,
+		"PATH=" + os.Getenv("PATH"),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=" + os.DevNull,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+	}
+	if password != "" {
+		if username == "" {
+			username = "git"
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth,
+		)
+	}
+	return env
+}
+
+type gitRunner struct {
+	path string
+	dir  string
+	env  []string
+}
+
+func (g gitRunner) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, g.path, append([]string{"--git-dir", g.dir}, args...)...)
+	cmd.Env = g.env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
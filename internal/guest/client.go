@@ -0,0 +1,345 @@
+// ABOUTME: HTTP client for the bootstrap, runner report, artifact, and metadata env endpoints.
+// ABOUTME: Requests retry with capped exponential backoff; artifacts are verified by sha256.
+
+package guest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoJob means the daemon has no job for this VM; the runner exits cleanly.
+	ErrNoJob = errors.New("no job assigned")
+	// ErrJobFinalized means the daemon already finished the job, for example
+	// after a timeout or a cancel. The runner stops its agent.
+	ErrJobFinalized = errors.New("job already finalized")
+)
+
+// statusError is a non-2xx response. Client errors other than 408 and 429
+// are not retried.
+type statusError struct {
+	Code    int
+	Message string
+}
+
+func (e *statusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("status %d", e.Code)
+}
+
+func (e *statusError) retryable() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout || e.Code == http.StatusTooManyRequests
+}
+
+// Client talks to agentlabd from inside the guest.
+type Client struct {
+	controller string
+	http       *http.Client
+	cfg        Config
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a client for the controller URL from bootstrap.json.
+func NewClient(controller string, cfg Config) *Client {
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &Client{
+		controller: strings.TrimRight(strings.TrimSpace(controller), "/"),
+		http:       &http.Client{Transport: transport},
+		cfg:        cfg,
+		sleep:      sleepContext,
+	}
+}
+
+// FetchBootstrap exchanges the one-time bootstrap token for the job payload.
+// It returns ErrNoJob when the daemon reports no job for this VM.
+func (c *Client) FetchBootstrap(ctx context.Context, token string, vmid int) (*Bootstrap, []byte, error) {
+	body, err := json.Marshal(bootstrapFetchRequest{Token: token, VMID: vmid})
+	if err != nil {
+		return nil, nil, err
+	}
+	var raw []byte
+	err = c.retry(ctx, c.cfg.BootstrapRetryMax, 2*time.Second, func(ctx context.Context) error {
+		resp, err := c.post(ctx, c.cfg.BootstrapTimeout, c.controller+"/v1/bootstrap/fetch", "application/json", nil, bytes.NewReader(body))
+		if err != nil {
+			var se *statusError
+			if errors.As(err, &se) && se.Code == http.StatusNotFound && se.Message == "job not found" {
+				return permanent(ErrNoJob)
+			}
+			return err
+		}
+		raw = resp
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var payload Bootstrap
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, nil, fmt.Errorf("decode bootstrap payload: %w", err)
+	}
+	return &payload, raw, nil
+}
+
+// Report posts a runner report. A 409 for a finalized job returns
+// ErrJobFinalized without retrying.
+func (c *Client) Report(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	attempts := c.cfg.RetryMax
+	if report.Heartbeat {
+		// A missed heartbeat is replaced by the next one.
+		attempts = 1
+	}
+	return c.retry(ctx, attempts, c.cfg.RetryInitialBackoff, func(ctx context.Context) error {
+		_, err := c.post(ctx, c.cfg.ReportTimeout, c.controller+"/v1/runner/report", "application/json", nil, bytes.NewReader(body))
+		var se *statusError
+		if errors.As(err, &se) && se.Code == http.StatusConflict && se.Message == "job already finalized" {
+			return permanent(ErrJobFinalized)
+		}
+		return err
+	})
+}
+
+// UploadArtifact uploads path to the artifact endpoint under its base name
+// and checks that the daemon recorded the same sha256.
+func (c *Client) UploadArtifact(ctx context.Context, endpoint, token, path, kind, mime string) (ArtifactMetadata, error) {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return ArtifactMetadata{}, err
+	}
+	query := url.Values{}
+	query.Set("path", filepath.Base(path))
+	query.Set("kind", kind)
+	target := strings.TrimRight(endpoint, "/") + "?" + query.Encode()
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	var meta ArtifactMetadata
+	err = c.retry(ctx, c.cfg.RetryMax, c.cfg.RetryInitialBackoff, func(ctx context.Context) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return permanent(err)
+		}
+		defer file.Close()
+		raw, err := c.post(ctx, c.cfg.UploadTimeout, target, mime, headers, file)
+		if err != nil {
+			return err
+		}
+		var resp artifactUploadResponse
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return fmt.Errorf("decode upload response: %w", err)
+		}
+		if !strings.EqualFold(resp.Artifact.Sha256, sum) {
+			return fmt.Errorf("upload checksum mismatch: sent %s, daemon recorded %s", sum, resp.Artifact.Sha256)
+		}
+		meta = resp.Artifact
+		return nil
+	})
+	if err != nil {
+		return ArtifactMetadata{}, err
+	}
+	if meta.SizeBytes == 0 {
+		meta.SizeBytes = size
+	}
+	return meta, nil
+}
+
+// DownloadArtifact fetches url into dest and verifies sha256 when given.
+func (c *Client) DownloadArtifact(ctx context.Context, rawURL, token, dest, sha string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return err
+	}
+	return c.retry(ctx, c.cfg.RetryMax, c.cfg.RetryInitialBackoff, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, c.cfg.UploadTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return permanent(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := checkStatus(resp); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return permanent(err)
+		}
+		hash := sha256.New()
+		_, copyErr := io.Copy(io.MultiWriter(file, hash), resp.Body)
+		closeErr := file.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+		if closeErr != nil {
+			return permanent(closeErr)
+		}
+		if sha != "" && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), sha) {
+			return permanent(errors.New("checksum mismatch"))
+		}
+		return nil
+	})
+}
+
+// FetchEnv polls /metadata/env with If-None-Match: etag. It returns nil with
+// no error when the env is unchanged. A failed poll is not retried; the next
+// one replaces it.
+func (c *Client) FetchEnv(ctx context.Context, sandboxSecret, etag string) (*EnvSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.controller+"/metadata/env", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(sandboxSecretHeader, sandboxSecret)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	var snapshot EnvSnapshot
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("decode env: %w", err)
+	}
+	snapshot.Version = resp.Header.Get("ETag")
+	return &snapshot, nil
+}
+
+func (c *Client) post(ctx context.Context, timeout time.Duration, target, contentType string, headers map[string]string, body io.Reader) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return nil, permanent(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = json.Unmarshal(data, &payload)
+	return &statusError{Code: resp.StatusCode, Message: payload.Error}
+}
+
+// permanentError stops retry early.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error { return permanentError{err: err} }
+
+// retry runs fn up to attempts times, doubling the delay between attempts up
+// to RetryMaxBackoff. Permanent errors and non-retryable client errors end
+// it immediately.
+func (c *Client) retry(ctx context.Context, attempts int, delay time.Duration, fn func(context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	maxDelay := c.cfg.RetryMaxBackoff
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+		var perm permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		var se *statusError
+		if errors.As(err, &se) && !se.retryable() {
+			return err
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+		if sleepErr := c.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,116 @@
+package guest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	cfg, err := configFromLookup(func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RetryMax = 3
+	client := NewClient(server.URL+"/", cfg)
+	client.sleep = func(context.Context, time.Duration) error { return nil }
+	return client, &calls
+}
+
+func TestClientReportRetriesServerErrors(t *testing.T) {
+	var failures int32 = 2
+	client, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			writeTestError(w, http.StatusServiceUnavailable, "busy")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	if err := client.Report(context.Background(), Report{JobID: "job-1", Status: StatusRunning}); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if *calls != 3 {
+		t.Fatalf("calls = %d, want 3", *calls)
+	}
+}
+
+func TestClientReportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		msg    string
+		report Report
+		want   error
+		calls  int32
+	}{
+		{name: "finalized", status: http.StatusConflict, msg: "job already finalized", want: ErrJobFinalized, calls: 1},
+		{name: "client error not retried", status: http.StatusBadRequest, msg: "invalid phase", calls: 1},
+		{name: "heartbeat not retried", status: http.StatusBadGateway, report: Report{Heartbeat: true}, calls: 1},
+		{name: "retries exhausted", status: http.StatusBadGateway, calls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeTestError(w, tt.status, tt.msg)
+			})
+			err := client.Report(context.Background(), tt.report)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Report() error = %v, want %v", err, tt.want)
+			}
+			if *calls != tt.calls {
+				t.Fatalf("calls = %d, want %d", *calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestClientFetchBootstrapNoJob(t *testing.T) {
+	client, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTestError(w, http.StatusNotFound, "job not found")
+	})
+	if _, _, err := client.FetchBootstrap(context.Background(), "token", 101); !errors.Is(err, ErrNoJob) {
+		t.Fatalf("FetchBootstrap() error = %v, want ErrNoJob", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("calls = %d, want 1", *calls)
+	}
+}
+
+func TestClientUploadArtifactVerifiesChecksum(t *testing.T) {
+	client, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("path") != "patch.diff" || r.URL.Query().Get("kind") != ArtifactKindPatch {
+			writeTestError(w, http.StatusBadRequest, "bad query")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"job_id":"job-1","artifact":{"name":"patch.diff","sha256":"deadbeef"}}`))
+	})
+	path := filepath.Join(t.TempDir(), "patch.diff")
+	if err := os.WriteFile(path, []byte("diff --git a/x b/x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.UploadArtifact(context.Background(), client.controller+"/upload", "token", path, ArtifactKindPatch, "text/x-diff")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("UploadArtifact() error = %v, want checksum mismatch", err)
+	}
+	if *calls != 3 {
+		t.Fatalf("calls = %d, want 3 (mismatch retried)", *calls)
+	}
+}
@@ -0,0 +1,198 @@
+// ABOUTME: Runner configuration loaded from the environment and agent-runner.env.
+// ABOUTME: Variable names and defaults match the original shell runner.
+
+package guest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRunnerEnvPath = "/etc/agentlab/agent-runner.env"
+	defaultBootstrapPath = "/etc/agentlab/bootstrap.json"
+	defaultSecretsDir    = "/run/agentlab/secrets"
+	defaultRunDir        = "/run/agentlab"
+)
+
+// Config controls a guest runner. LoadConfig fills it from AGENTLAB_*
+// variables; zero durations and counts fall back to the defaults below.
+type Config struct {
+	BootstrapPath  string
+	SecretsDir     string
+	RunDir         string
+	WorkDirBase    string
+	RepoDir        string
+	ArtifactsDir   string
+	ParentArtDir   string
+	BootstrapCache bool
+
+	AgentCommand string
+	AgentArgs    string
+	AgentTool    string
+	// AgentTimeout overrides the job TTL as the agent's time limit.
+	AgentTimeout time.Duration
+	// CancelGrace is how long the agent gets to exit after SIGTERM before
+	// it is killed.
+	CancelGrace time.Duration
+
+	InnerSandbox     string
+	InnerSandboxArgs []string
+	innerSandboxSet  bool
+
+	StreamLogs        bool
+	LogInterval       time.Duration
+	LogMaxChars       int
+	HeartbeatInterval time.Duration
+	// SecretsRefreshInterval is how often the runner polls /metadata/env for
+	// rotated secrets while the agent runs. Zero disables polling.
+	SecretsRefreshInterval time.Duration
+
+	ConnectTimeout      time.Duration
+	BootstrapTimeout    time.Duration
+	ReportTimeout       time.Duration
+	UploadTimeout       time.Duration
+	RetryMax            int
+	BootstrapRetryMax   int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+}
+
+// LoadConfig reads the runner environment file (AGENTLAB_RUNNER_ENV, default
+// /etc/agentlab/agent-runner.env) when present, then the process environment.
+// Variables already set in the environment win over the file.
+func LoadConfig() (Config, error) {
+	envPath := envOr("AGENTLAB_RUNNER_ENV", defaultRunnerEnvPath)
+	fileEnv, err := readEnvFile(envPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, fmt.Errorf("read %s: %w", envPath, err)
+	}
+	get := func(key string) string {
+		if value, ok := os.LookupEnv(key); ok {
+			return value
+		}
+		return fileEnv[key]
+	}
+	return configFromLookup(get)
+}
+
+func configFromLookup(get func(string) string) (Config, error) {
+	or := func(key, fallback string) string {
+		if value := strings.TrimSpace(get(key)); value != "" {
+			return value
+		}
+		return fallback
+	}
+	cfg := Config{
+		BootstrapPath:  or("AGENTLAB_BOOTSTRAP", defaultBootstrapPath),
+		SecretsDir:     or("AGENTLAB_SECRETS_DIR", defaultSecretsDir),
+		RunDir:         or("AGENTLAB_RUN_DIR", defaultRunDir),
+		WorkDirBase:    or("AGENTLAB_WORK_DIR_BASE", "/tmp"),
+		RepoDir:        strings.TrimSpace(get("AGENTLAB_REPO_DIR")),
+		ArtifactsDir:   strings.TrimSpace(get("AGENTLAB_ARTIFACTS_DIR")),
+		ParentArtDir:   strings.TrimSpace(get("AGENTLAB_PARENT_ARTIFACTS_DIR")),
+		BootstrapCache: or("AGENTLAB_BOOTSTRAP_CACHE", "1") != "0",
+		AgentCommand:   strings.TrimSpace(get("AGENTLAB_AGENT_COMMAND")),
+		AgentArgs:      strings.TrimSpace(get("AGENTLAB_AGENT_ARGS")),
+		AgentTool:      or("AGENTLAB_AGENT", "claude"),
+		StreamLogs:     or("AGENTLAB_RUNNER_STREAM_LOGS", "1") != "0",
+	}
+	if raw := strings.TrimSpace(get("AGENTLAB_INNER_SANDBOX")); raw != "" {
+		cfg.InnerSandbox = raw
+		cfg.innerSandboxSet = true
+	}
+	if raw := strings.TrimSpace(get("AGENTLAB_INNER_SANDBOX_ARGS")); raw != "" {
+		cfg.InnerSandboxArgs = strings.Fields(raw)
+	}
+
+	var err error
+	seconds := func(key string, fallback int) time.Duration {
+		if err != nil {
+			return 0
+		}
+		var n int
+		n, err = intFromEnv(get, key, fallback)
+		return time.Duration(n) * time.Second
+	}
+	cfg.AgentTimeout = seconds("AGENTLAB_RUNNER_TIMEOUT_SECONDS", 0)
+	cfg.CancelGrace = seconds("AGENTLAB_RUNNER_CANCEL_GRACE_SECONDS", 30)
+	cfg.LogInterval = seconds("AGENTLAB_RUNNER_LOG_INTERVAL_SECONDS", 5)
+	cfg.HeartbeatInterval = seconds("AGENTLAB_RUNNER_HEARTBEAT_SECONDS", 30)
+	cfg.SecretsRefreshInterval = seconds("AGENTLAB_SECRETS_REFRESH_SECONDS", 60)
+	cfg.ConnectTimeout = seconds("AGENTLAB_CURL_CONNECT_TIMEOUT", 10)
+	cfg.BootstrapTimeout = seconds("AGENTLAB_CURL_BOOTSTRAP_MAX_TIME", 60)
+	cfg.ReportTimeout = seconds("AGENTLAB_CURL_REPORT_MAX_TIME", 20)
+	cfg.UploadTimeout = seconds("AGENTLAB_CURL_UPLOAD_MAX_TIME", 300)
+	if err != nil {
+		return Config{}, err
+	}
+	if cfg.LogMaxChars, err = intFromEnv(get, "AGENTLAB_RUNNER_LOG_MAX_CHARS", 800); err != nil {
+		return Config{}, err
+	}
+	if cfg.RetryMax, err = intFromEnv(get, "AGENTLAB_RETRY_MAX", 6); err != nil {
+		return Config{}, err
+	}
+	if cfg.BootstrapRetryMax, err = intFromEnv(get, "AGENTLAB_BOOTSTRAP_RETRY_MAX", 10); err != nil {
+		return Config{}, err
+	}
+	cfg.RetryInitialBackoff = time.Second
+	cfg.RetryMaxBackoff = 30 * time.Second
+	return cfg, nil
+}
+
+func intFromEnv(get func(string) string, key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(get(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// readEnvFile parses a shell-style KEY=VALUE file: blank lines and comments
+// are skipped, an "export " prefix is allowed, and values may be single- or
+// double-quoted. It does not expand variables.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 {
+			if q := value[0]; (q == '"' || q == '\'') && value[len(value)-1] == q {
+				value = value[1 : len(value)-1]
+			}
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,58 @@
+package guest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigFromLookup(t *testing.T) {
+	cfg, err := configFromLookup(func(key string) string {
+		return map[string]string{
+			"AGENTLAB_RUNNER_HEARTBEAT_SECONDS": "10",
+			"AGENTLAB_RUNNER_STREAM_LOGS":       "0",
+			"AGENTLAB_INNER_SANDBOX":            "none",
+			"AGENTLAB_INNER_SANDBOX_ARGS":       "--unshare-net  --new-session",
+		}[key]
+	})
+	if err != nil {
+		t.Fatalf("configFromLookup() error = %v", err)
+	}
+	if cfg.HeartbeatInterval != 10*time.Second || cfg.StreamLogs || cfg.LogInterval != 5*time.Second {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	if !cfg.innerSandboxSet || normalizeInnerSandbox(cfg.InnerSandbox) != "" || len(cfg.InnerSandboxArgs) != 2 {
+		t.Fatalf("inner sandbox cfg = %q %v %v", cfg.InnerSandbox, cfg.innerSandboxSet, cfg.InnerSandboxArgs)
+	}
+	if cfg.SecretsRefreshInterval != time.Minute {
+		t.Fatalf("secrets refresh interval = %s, want 1m", cfg.SecretsRefreshInterval)
+	}
+	if cfg.BootstrapPath != defaultBootstrapPath || cfg.RetryMax != 6 || cfg.AgentTool != "claude" {
+		t.Fatalf("defaults = %+v", cfg)
+	}
+
+	if _, err := configFromLookup(func(key string) string {
+		if key == "AGENTLAB_RETRY_MAX" {
+			return "-1"
+		}
+		return ""
+	}); err == nil {
+		t.Fatal("expected error for negative AGENTLAB_RETRY_MAX")
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-runner.env")
+	content := "# comment\n\nexport AGENTLAB_AGENT=codex\nAGENTLAB_AGENT_ARGS=\"--model x\"\nAGENTLAB_INNER_SANDBOX='bubblewrap'\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readEnvFile(path)
+	if err != nil {
+		t.Fatalf("readEnvFile() error = %v", err)
+	}
+	if got["AGENTLAB_AGENT"] != "codex" || got["AGENTLAB_AGENT_ARGS"] != "--model x" || got["AGENTLAB_INNER_SANDBOX"] != "bubblewrap" || len(got) != 3 {
+		t.Fatalf("readEnvFile() = %v", got)
+	}
+}
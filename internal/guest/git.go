@@ -0,0 +1,306 @@
+// ABOUTME: Git operations for the guest runner: auth, clone or hardened reuse,
+// ABOUTME: the job diff and change summary, and the push bundle.
+
+package guest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gitHardenArgs keep residue config in a reused .git from running commands
+// during fetch and checkout.
+var gitHardenArgs = []string{"-c", "core.hooksPath=/dev/null", "-c", "core.fsmonitor=false"}
+
+// gitAskpassScript answers git's credential prompts from the environment, so
+// the token never appears on a command line or in a config file.
+const gitAskpassScript = `#!/bin/sh
+case "$1" in
+  *Username*) printf '%s\n' "${GIT_USERNAME:-x-access-token}" ;;
+  *) printf '%s\n' "${GIT_TOKEN:-}" ;;
+esac
+`
+
+// git runs git commands with the runner's environment.
+type git struct {
+	env []string
+}
+
+func (g git) run(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := g.runTo(ctx, dir, &stdout, &stderr, args...); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+		}
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g git) runTo(ctx context.Context, dir string, stdout, stderr *bytes.Buffer, args ...string) error {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = g.env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// gitAuthEnv writes the credentials from the bootstrap payload into
+// secretsDir and returns the environment that makes git use them. An SSH key
+// wins over a token.
+func gitAuthEnv(secretsDir string, creds *BootstrapGit) ([]string, error) {
+	if creds == nil {
+		return nil, nil
+	}
+	if key := strings.TrimSpace(creds.SSHPrivateKey); key != "" {
+		keyPath := filepath.Join(secretsDir, "id_ed25519")
+		if err := os.WriteFile(keyPath, []byte(key+"\n"), 0o600); err != nil {
+			return nil, err
+		}
+		knownHostsPath := filepath.Join(secretsDir, "known_hosts")
+		knownHosts := ""
+		if hosts := strings.TrimSpace(creds.KnownHosts); hosts != "" {
+			knownHosts = hosts + "\n"
+		}
+		if err := os.WriteFile(knownHostsPath, []byte(knownHosts), 0o600); err != nil {
+			return nil, err
+		}
+		return []string{"GIT_SSH_COMMAND=ssh -i " + keyPath + " -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=" + knownHostsPath}, nil
+	}
+	if token := strings.TrimSpace(creds.Token); token != "" {
+		askpass := filepath.Join(secretsDir, "git-askpass")
+		if err := os.WriteFile(askpass, []byte(gitAskpassScript), 0o700); err != nil {
+			return nil, err
+		}
+		user := strings.TrimSpace(creds.Username)
+		if user == "" {
+			user = "x-access-token"
+		}
+		return []string{"GIT_ASKPASS=" + askpass, "GIT_USERNAME=" + user, "GIT_TOKEN=" + token, "GIT_TERMINAL_PROMPT=0"}, nil
+	}
+	return nil, nil
+}
+
+// cloneOrUpdate checks out ref of repoURL in repoDir on branch agentlab.
+//
+// A reused /work volume may hold a .git from an earlier tenant. Its local
+// config and hooks are residue, not input. Residue can execute commands
+// during fetch and checkout through hooks, filter drivers, credential
+// helpers, an fsmonitor probe, or a substitute ssh client. It can redirect a
+// fetch through an url.*.insteadOf rewrite, an http.<url>.* override, or a
+// residue remote. The reuse path scrubs those sections and keys, resets the
+// remotes to the job repo, and runs fetch and checkout with gitHardenArgs.
+func (g git) cloneOrUpdate(ctx context.Context, repoDir, repoURL, ref string) error {
+	if info, err := os.Stat(filepath.Join(repoDir, ".git")); err == nil && info.IsDir() {
+		g.scrubResidueConfig(ctx, repoDir)
+		// Residue may point any remote, not just origin, at a repository the
+		// previous tenant controls, and fetch reads every configured URL.
+		g.removeLocalSections(ctx, repoDir, `^remote\.`)
+		if _, err := g.run(ctx, repoDir, "config", "--local", "--get", "remote.origin.url"); err == nil {
+			// A residue origin survived the section removal. Force its URL
+			// list to the job repo.
+			_, _ = g.run(ctx, repoDir, "config", "--local", "--unset-all", "remote.origin.url")
+			if _, err := g.run(ctx, repoDir, "config", "--local", "--add", "remote.origin.url", repoURL); err != nil {
+				return err
+			}
+		} else if _, err := g.run(ctx, repoDir, "remote", "add", "origin", repoURL); err != nil {
+			return err
+		}
+		if _, err := g.run(ctx, repoDir, append(append([]string{}, gitHardenArgs...), "fetch", "--force", "origin", ref)...); err != nil {
+			return err
+		}
+		_, err := g.run(ctx, repoDir, append(append([]string{}, gitHardenArgs...), "checkout", "-B", "agentlab", "FETCH_HEAD")...)
+		return err
+	}
+	if err := os.RemoveAll(repoDir); err != nil {
+		return err
+	}
+	if _, err := g.run(ctx, "", "clone", repoURL, repoDir); err != nil {
+		return err
+	}
+	if _, err := g.run(ctx, repoDir, "fetch", "--force", "origin", ref); err != nil {
+		return err
+	}
+	_, err := g.run(ctx, repoDir, "checkout", "-B", "agentlab", "FETCH_HEAD")
+	return err
+}
+
+// scrubResidueConfig drops local config that executes a command or
+// redirects a fetch. Includes go first: an included file can carry every
+// other section, and a section that lives there cannot be removed by name.
+func (g git) scrubResidueConfig(ctx context.Context, repoDir string) {
+	g.removeLocalSections(ctx, repoDir, `^include(if)?\.`)
+	g.removeLocalSections(ctx, repoDir, `^(filter|url|credential|http)\.`)
+	for _, key := range []string{"core.hooksPath", "core.fsmonitor", "core.sshCommand"} {
+		_, _ = g.run(ctx, repoDir, "config", "--local", "--unset-all", key)
+	}
+}
+
+// removeLocalSections removes every local config section that owns a key
+// matching pattern; "filter.foo.smudge" removes section "filter.foo".
+func (g git) removeLocalSections(ctx context.Context, repoDir, pattern string) {
+	out, err := g.run(ctx, repoDir, "config", "--local", "--name-only", "--get-regexp", pattern)
+	if err != nil || out == "" {
+		return
+	}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(out, "\n") {
+		name = strings.TrimSpace(name)
+		idx := strings.LastIndex(name, ".")
+		if idx <= 0 {
+			continue
+		}
+		section := name[:idx]
+		if seen[section] {
+			continue
+		}
+		seen[section] = true
+		_, _ = g.run(ctx, repoDir, "config", "--local", "--remove-section", section)
+	}
+}
+
+func (g git) head(ctx context.Context, repoDir string) string {
+	sha, err := g.run(ctx, repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return sha
+}
+
+// writeJobDiff writes the job's changes against base, committed or not, to
+// patchPath and a per-file summary to changesPath. A throwaway index keeps
+// the agent's staging area untouched.
+func (g git) writeJobDiff(ctx context.Context, repoDir, base, ref, patchPath, changesPath string) error {
+	if base == "" {
+		return errors.New("base commit unknown")
+	}
+	index, err := os.CreateTemp(filepath.Dir(patchPath), "diff-index.")
+	if err != nil {
+		return err
+	}
+	indexPath := index.Name()
+	_ = index.Close()
+	_ = os.Remove(indexPath)
+	defer os.Remove(indexPath)
+
+	tmp := git{env: append(append([]string{}, g.env...), "GIT_INDEX_FILE="+indexPath)}
+	if _, err := tmp.run(ctx, repoDir, "read-tree", "HEAD"); err != nil {
+		return err
+	}
+	if _, err := tmp.run(ctx, repoDir, "add", "-A"); err != nil {
+		return err
+	}
+	var patch, numstat, names, stderr bytes.Buffer
+	if err := tmp.runTo(ctx, repoDir, &patch, &stderr, "-c", "core.quotePath=false", "diff", "--cached", "--binary", "--no-color", "--find-renames", base); err != nil {
+		return fmt.Errorf("git diff: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := tmp.runTo(ctx, repoDir, &numstat, &stderr, "diff", "--cached", "--no-renames", "--numstat", "-z", base); err != nil {
+		return fmt.Errorf("git diff --numstat: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := tmp.runTo(ctx, repoDir, &names, &stderr, "diff", "--cached", "--no-renames", "--name-status", "-z", base); err != nil {
+		return fmt.Errorf("git diff --name-status: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	summary := ChangeSummary{Base: base, Head: g.head(ctx, repoDir), Ref: ref}
+	summary.Files = parseChangeStats(numstat.String(), names.String())
+	for _, f := range summary.Files {
+		summary.Additions += f.Additions
+		summary.Deletions += f.Deletions
+	}
+	summary.FilesChanged = len(summary.Files)
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(patchPath, patch.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(changesPath, data, 0o600)
+}
+
+// parseChangeStats joins NUL-separated --numstat and --name-status output.
+func parseChangeStats(numstat, nameStatus string) []ChangedFileStat {
+	status := map[string]string{}
+	fields := strings.Split(nameStatus, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch fields[i] {
+		case "A":
+			status[fields[i+1]] = "added"
+		case "D":
+			status[fields[i+1]] = "deleted"
+		}
+	}
+	files := []ChangedFileStat{}
+	for _, record := range strings.Split(numstat, "\x00") {
+		parts := strings.SplitN(record, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		f := ChangedFileStat{Path: parts[2], Status: "modified"}
+		if s, ok := status[f.Path]; ok {
+			f.Status = s
+		}
+		if parts[0] == "-" {
+			f.Binary = true
+		} else {
+			f.Additions, _ = strconv.Atoi(parts[0])
+			f.Deletions, _ = strconv.Atoi(parts[1])
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// writePushBundle commits leftover changes and bundles the commits made since
+// base into bundlePath. It reports false when there is nothing to push.
+func (g git) writePushBundle(ctx context.Context, repoDir, base, bundlePath, jobID string) (bool, error) {
+	if base == "" {
+		return false, nil
+	}
+	status, err := g.run(ctx, repoDir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if status != "" {
+		if _, err := g.run(ctx, repoDir, "add", "-A"); err != nil {
+			return false, err
+		}
+		name := envValue(g.env, "GIT_AUTHOR_NAME", "agentlab")
+		email := envValue(g.env, "GIT_AUTHOR_EMAIL", "agentlab@localhost")
+		if _, err := g.run(ctx, repoDir, "-c", "user.name="+name, "-c", "user.email="+email, "commit", "--quiet", "--no-verify", "-m", "agentlab: job "+jobID); err != nil {
+			return false, err
+		}
+	}
+	count, err := g.run(ctx, repoDir, "rev-list", "--count", base+"..HEAD")
+	if err != nil || count == "0" {
+		return false, err
+	}
+	if _, err := g.run(ctx, repoDir, "bundle", "create", bundlePath, "HEAD", "^"+base); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// envValue returns the last value of key in env, or fallback.
+func envValue(env []string, key, fallback string) string {
+	value := fallback
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key && v != "" {
+			value = v
+		}
+	}
+	return value
+}
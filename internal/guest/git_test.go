@@ -0,0 +1,155 @@
+package guest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// plantResidueTraps plants config in an existing .git that executes a
+// command or redirects a fetch: hooks in the default directory and in a
+// residue core.hooksPath, a smudge filter through .git/info/attributes, a
+// second filter through an include.path file, a credential helper, an
+// fsmonitor probe, an ssh substitute, an insteadOf rewrite of the job repo
+// URL, and an http.<url> override. Each trap writes its own marker file.
+func plantResidueTraps(t *testing.T, dir, originURL, markers string) {
+	t.Helper()
+	trap := func(path, marker string) {
+		script := fmt.Sprintf("#!/bin/sh\necho fired >> %q\n", filepath.Join(markers, marker))
+		if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sub := range []string{"hooks", "evilhooks", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, ".git", sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trap(filepath.Join(dir, ".git", "hooks", "post-checkout"), "post-checkout")
+	trap(filepath.Join(dir, ".git", "evilhooks", "post-checkout"), "evilhook")
+	runGit(t, dir, "config", "--local", "core.hooksPath", ".git/evilhooks")
+
+	smudge := filepath.Join(markers, "smudge-filter")
+	if err := os.WriteFile(smudge, []byte(fmt.Sprintf("#!/bin/sh\nsed s/clean/SMUDGED/\necho fired >> %q\n", filepath.Join(markers, "smudge"))), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "config", "--local", "filter.evil.smudge", smudge)
+	runGit(t, dir, "config", "--local", "filter.evil.clean", "cat")
+
+	include := filepath.Join(markers, "include")
+	includeFilter := filepath.Join(markers, "include-filter")
+	if err := os.WriteFile(includeFilter, []byte(fmt.Sprintf("#!/bin/sh\nsed s/clean/INCLUDED/\necho fired >> %q\n", filepath.Join(markers, "included"))), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(include, []byte(fmt.Sprintf("[filter \"inc\"]\n\tsmudge = %s\n\tclean = cat\n", includeFilter)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "config", "--local", "include.path", include)
+	if err := os.WriteFile(filepath.Join(dir, ".git", "info", "attributes"), []byte("*.txt filter=evil\n*.bin filter=inc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	trap(filepath.Join(markers, "credhelper-cmd"), "credhelper")
+	runGit(t, dir, "config", "--local", "credential.helper", filepath.Join(markers, "credhelper-cmd"))
+	trap(filepath.Join(markers, "fsmonitor-cmd"), "fsmonitor")
+	runGit(t, dir, "config", "--local", "core.fsmonitor", filepath.Join(markers, "fsmonitor-cmd"))
+	trap(filepath.Join(markers, "ssh-cmd"), "ssh")
+	runGit(t, dir, "config", "--local", "core.sshCommand", filepath.Join(markers, "ssh-cmd"))
+
+	runGit(t, dir, "config", "--local", "url.file:///nonexistent-evil.insteadOf", originURL)
+	runGit(t, dir, "config", "--local", "http."+originURL+".sslVerify", "false")
+}
+
+func TestCloneOrUpdateScrubsResidue(t *testing.T) {
+	requireGit(t)
+	origin, originSHA := newOriginRepo(t)
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+	}{
+		{
+			name: "single url and extra remote",
+			setup: func(t *testing.T, dir string) {
+				runGit(t, dir, "remote", "add", "origin", filepath.Join(dir, "previous-tenant"))
+				runGit(t, dir, "remote", "add", "evil", filepath.Join(dir, "previous-tenant-2"))
+			},
+		},
+		{
+			name: "multiple origin urls",
+			setup: func(t *testing.T, dir string) {
+				runGit(t, dir, "remote", "add", "origin", filepath.Join(dir, "previous-tenant"))
+				runGit(t, dir, "config", "--local", "--add", "remote.origin.url", filepath.Join(dir, "previous-tenant-2"))
+			},
+		},
+		{
+			name:  "no remote",
+			setup: func(t *testing.T, dir string) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "repo")
+			markers := t.TempDir()
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			runGit(t, dir, "init", "-q", "--initial-branch=main")
+			if err := os.WriteFile(filepath.Join(dir, "leftover.txt"), []byte("leftover\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, dir)
+			plantResidueTraps(t, dir, origin, markers)
+
+			g := git{env: os.Environ()}
+			if err := g.cloneOrUpdate(context.Background(), dir, origin, "main"); err != nil {
+				t.Fatalf("cloneOrUpdate() error = %v", err)
+			}
+
+			for _, marker := range []string{"post-checkout", "evilhook", "smudge", "included", "credhelper", "fsmonitor", "ssh"} {
+				if _, err := os.Stat(filepath.Join(markers, marker)); err == nil {
+					t.Errorf("residue %s ran", marker)
+				}
+			}
+			if got := runGit(t, dir, "config", "--local", "--get-all", "remote.origin.url"); got != origin {
+				t.Errorf("origin url = %q, want %q", got, origin)
+			}
+			remotes := runGit(t, dir, "config", "--local", "--name-only", "--get-regexp", `^remote\.`)
+			for _, name := range strings.Split(remotes, "\n") {
+				if !strings.HasPrefix(name, "remote.origin.") {
+					t.Errorf("residue remote survived: %s", name)
+				}
+			}
+			if got := runGit(t, dir, "rev-parse", "HEAD"); got != originSHA {
+				t.Errorf("HEAD = %s, want %s", got, originSHA)
+			}
+			for file, want := range map[string]string{"file.txt": "clean blob content\n", "data.bin": "clean bin content\n", "leftover.txt": "leftover\n"} {
+				data, err := os.ReadFile(filepath.Join(dir, file))
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q (%v), want %q", file, data, err, want)
+				}
+			}
+			if out, err := g.run(context.Background(), dir, "config", "--local", "--name-only", "--get-regexp", `^(filter|url|credential|http|include|includeif)\.|^core\.(hookspath|fsmonitor|sshcommand)$`); err == nil && out != "" {
+				t.Errorf("residue config survived:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestParseChangeStats(t *testing.T) {
+	numstat := "3\t1\tmain.go\x00-\t-\tlogo.png\x0010\t0\tnew file.go\x000\t4\told.go\x00"
+	nameStatus := "M\x00main.go\x00M\x00logo.png\x00A\x00new file.go\x00D\x00old.go\x00"
+	got := parseChangeStats(numstat, nameStatus)
+	want := []ChangedFileStat{
+		{Path: "logo.png", Status: "modified", Binary: true},
+		{Path: "main.go", Status: "modified", Additions: 3, Deletions: 1},
+		{Path: "new file.go", Status: "added", Additions: 10},
+		{Path: "old.go", Status: "deleted", Deletions: 4},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseChangeStats() = %+v, want %+v", got, want)
+	}
+}
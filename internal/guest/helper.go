@@ -0,0 +1,197 @@
+// ABOUTME: Guest helper commands for the sandbox metadata and credential-proxy endpoints.
+// ABOUTME: Every request carries the per-sandbox secret the runner stores at boot.
+
+package guest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMetadataBase is the link-local metadata endpoint in every sandbox.
+	DefaultMetadataBase = "http://169.254.169.254"
+	// DefaultSandboxSecretFile is where the runner writes the sandbox secret.
+	DefaultSandboxSecretFile = "/run/agentlab/secrets/sandbox-secret"
+
+	sandboxSecretHeader = "X-AgentLab-Sandbox-Secret"
+
+	// askPollWait is how long each reply long-poll blocks on the daemon. It
+	// stays under the helper's HTTP timeout.
+	askPollWait = 25 * time.Second
+)
+
+// Helper queries the metadata service from inside a sandbox.
+type Helper struct {
+	Base       string
+	SecretFile string
+	HTTP       *http.Client
+}
+
+// NewHelper returns a helper using AGENTLAB_SANDBOX_SECRET_FILE or the
+// default secret path.
+func NewHelper() *Helper {
+	return &Helper{
+		Base:       DefaultMetadataBase,
+		SecretFile: envOr("AGENTLAB_SANDBOX_SECRET_FILE", DefaultSandboxSecretFile),
+		HTTP:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Identity writes the sandbox identity as indented JSON.
+func (h *Helper) Identity(ctx context.Context, out io.Writer) error {
+	return h.printJSON(ctx, "/identity", out)
+}
+
+// Metadata writes all sandbox metadata as indented JSON.
+func (h *Helper) Metadata(ctx context.Context, out io.Writer) error {
+	return h.printJSON(ctx, "/metadata", out)
+}
+
+// Secret writes the value of the named secret.
+func (h *Helper) Secret(ctx context.Context, name string, out io.Writer) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("secret name required")
+	}
+	var payload struct {
+		Value string `json:"value"`
+	}
+	if err := h.getJSON(ctx, "/secrets/"+name, &payload); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out, payload.Value)
+	return err
+}
+
+// Prompt writes the initial agent prompt, if one is set.
+func (h *Helper) Prompt(ctx context.Context, out io.Writer) error {
+	var payload struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := h.getJSON(ctx, "/metadata", &payload); err != nil {
+		return err
+	}
+	if payload.Prompt == "" {
+		return nil
+	}
+	_, err := fmt.Fprintln(out, payload.Prompt)
+	return err
+}
+
+// Proxy sends a GET through the credential proxy and copies the body to out.
+func (h *Helper) Proxy(ctx context.Context, path string, out io.Writer) error {
+	path = strings.TrimLeft(strings.TrimSpace(path), "/")
+	if path == "" {
+		return errors.New("proxy path required (e.g., llm/v1/chat/completions)")
+	}
+	body, err := h.get(ctx, "/proxy/"+path)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(body)
+	return err
+}
+
+// Ask posts a question for an operator and blocks until it is answered,
+// then writes the answer. When options are given, the answer is one of them.
+func (h *Helper) Ask(ctx context.Context, text string, options []string, out io.Writer) error {
+	if strings.TrimSpace(text) == "" {
+		return errors.New("question text required")
+	}
+	body, err := json.Marshal(map[string]any{"kind": "question", "text": text, "options": options})
+	if err != nil {
+		return err
+	}
+	data, err := h.do(ctx, http.MethodPost, "/metadata/messages", body)
+	if err != nil {
+		return err
+	}
+	var question struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &question); err != nil || question.ID <= 0 {
+		return fmt.Errorf("decode question: %s", strings.TrimSpace(string(data)))
+	}
+	path := fmt.Sprintf("/metadata/messages/%d/reply?wait=%s", question.ID, askPollWait)
+	for {
+		var reply struct {
+			Status string `json:"status"`
+			Text   string `json:"text"`
+		}
+		if err := h.getJSON(ctx, path, &reply); err != nil {
+			return err
+		}
+		if reply.Status == "answered" {
+			_, err := fmt.Fprintln(out, reply.Text)
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *Helper) printJSON(ctx context.Context, path string, out io.Writer) error {
+	body, err := h.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(out)
+	return err
+}
+
+func (h *Helper) getJSON(ctx context.Context, path string, v any) error {
+	body, err := h.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func (h *Helper) get(ctx context.Context, path string) ([]byte, error) {
+	return h.do(ctx, http.MethodGet, path, nil)
+}
+
+func (h *Helper) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	secret, err := os.ReadFile(h.SecretFile)
+	if err != nil || len(bytes.TrimSpace(secret)) == 0 {
+		return nil, fmt.Errorf("missing sandbox secret at %s; the metadata endpoint rejects requests without it", h.SecretFile)
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(h.Base, "/")+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(sandboxSecretHeader, strings.TrimSpace(string(secret)))
+	resp, err := h.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,67 @@
+package guest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHelperAskPollsUntilAnswered(t *testing.T) {
+	var polls int32
+	var asked map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(sandboxSecretHeader) != "s3cret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/metadata/messages":
+			_ = json.NewDecoder(r.Body).Decode(&asked)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":9,"kind":"question"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/metadata/messages/9/reply":
+			if r.URL.Query().Get("wait") == "" {
+				t.Errorf("reply poll without ?wait")
+			}
+			if atomic.AddInt32(&polls, 1) < 3 {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte(`{"question_id":9,"status":"pending"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"question_id":9,"status":"answered","text":"yes"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "sandbox-secret")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := &Helper{Base: server.URL, SecretFile: secretFile, HTTP: server.Client()}
+
+	var out bytes.Buffer
+	if err := h.Ask(context.Background(), "Deploy?", []string{"yes", "no"}, &out); err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if out.String() != "yes\n" {
+		t.Fatalf("answer = %q, want yes", out.String())
+	}
+	if n := atomic.LoadInt32(&polls); n != 3 {
+		t.Fatalf("reply polls = %d, want 3", n)
+	}
+	if asked["kind"] != "question" || asked["text"] != "Deploy?" {
+		t.Fatalf("question body = %v", asked)
+	}
+
+	if err := h.Ask(context.Background(), "  ", nil, &out); err == nil {
+		t.Fatal("Ask with empty text: expected error")
+	}
+}
@@ -0,0 +1,77 @@
+// ABOUTME: Literal-value redaction for agent output and report messages.
+// ABOUTME: Secrets from the bootstrap payload are replaced before any output leaves the VM.
+
+package guest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const redactLabel = "[REDACTED]"
+
+// minRedactLength skips short values, which would mangle ordinary output.
+const minRedactLength = 6
+
+// Redactor replaces known secret values with [REDACTED].
+type Redactor struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{values: map[string]struct{}{}}
+}
+
+// Add registers secret values. Empty, short, and multi-line values are
+// ignored, matching what a line-oriented filter can reliably catch.
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for _, value := range values {
+		if len(value) < minRedactLength || strings.Contains(value, "\n") {
+			continue
+		}
+		if _, ok := r.values[value]; ok {
+			continue
+		}
+		r.values[value] = struct{}{}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	// Longer values first so a secret containing another is replaced whole.
+	sorted := make([]string, 0, len(r.values))
+	for value := range r.values {
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	pairs := make([]string, 0, len(sorted)*2)
+	for _, value := range sorted {
+		pairs = append(pairs, value, redactLabel)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with every registered value replaced.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
@@ -0,0 +1,909 @@
+// ABOUTME: Guest runner: fetches the job bootstrap, prepares the repo, runs the
+// ABOUTME: agent, and reports progress, logs, heartbeats, and artifacts to agentlabd.
+
+package guest
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	artifactBundleName = "agentlab-artifacts.tar.gz"
+	defaultPushBundle  = "agentlab-push.bundle"
+	agentWrapper       = "agentlab-agent"
+	// timeoutExitCode is what timeout(1) returns; the job result keeps it.
+	timeoutExitCode = 124
+)
+
+// Runner executes the job assigned to this VM.
+type Runner struct {
+	cfg      Config
+	logger   *log.Logger
+	redactor *Redactor
+	now      func() time.Time
+
+	client  *Client
+	desc    BootstrapDescriptor
+	boot    *Bootstrap
+	env     []string
+	git     git
+	repoDir string
+	base    string
+}
+
+// NewRunner returns a runner for cfg. logger receives progress lines; it
+// should write to stderr so systemd captures them.
+func NewRunner(cfg Config, logger *log.Logger) *Runner {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	return &Runner{cfg: cfg, logger: logger, redactor: NewRedactor(), now: time.Now}
+}
+
+func (r *Runner) logf(format string, args ...any) {
+	r.logger.Print(r.redactor.Redact(fmt.Sprintf(format, args...)))
+}
+
+// Run fetches the bootstrap payload and runs the job to completion. It
+// returns nil when the daemon has no job for this VM. Canceling ctx stops the
+// agent gracefully; artifacts and the final report are still sent.
+func (r *Runner) Run(ctx context.Context) error {
+	for _, dir := range []string{r.cfg.SecretsDir, r.cfg.RunDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		if err := os.Chmod(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("missing dependency: git")
+	}
+	if err := r.loadDescriptor(); err != nil {
+		return err
+	}
+	r.client = NewClient(r.desc.Controller, r.cfg)
+	if err := r.loadBootstrap(ctx); err != nil {
+		if errors.Is(err, ErrNoJob) {
+			r.logf("no job assigned for vmid %d; exiting cleanly", r.desc.VMID)
+			return nil
+		}
+		return err
+	}
+	job := r.boot.Job
+	r.logf("bootstrap ok for job %s", job.ID)
+	r.report(ctx, StatusRunning, PhaseBootstrap, "bootstrap fetched")
+
+	if err := r.setup(ctx); err != nil {
+		r.report(ctx, StatusFailed, PhaseSetup, err.Error())
+		return err
+	}
+	if err := r.prepareRepo(ctx); err != nil {
+		r.report(ctx, StatusFailed, PhaseRepo, err.Error())
+		return err
+	}
+	r.report(ctx, StatusRunning, PhaseRepo, "repo ready")
+
+	argv, stdin, err := r.agentCommand()
+	if err != nil {
+		r.report(ctx, StatusFailed, PhaseAgent, err.Error())
+		return err
+	}
+	r.report(ctx, StatusRunning, PhaseAgent, "agent starting")
+	start := r.now()
+	outcome := r.runAgent(ctx, argv, stdin)
+	duration := r.now().Sub(start)
+
+	// The agent is done; finish even if the service is stopping.
+	finishCtx := context.WithoutCancel(ctx)
+	if outcome.finalized {
+		r.logf("daemon finalized job %s; uploading artifacts only", job.ID)
+	}
+	result := JobResult{
+		Status:          outcome.status,
+		ExitCode:        outcome.exitCode,
+		DurationSeconds: int64(duration.Seconds()),
+		Mode:            job.Mode,
+	}
+	artifacts := r.collectArtifacts(finishCtx, &result)
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	message := "agent finished"
+	if outcome.message != "" {
+		message = outcome.message
+	}
+	if err := r.client.Report(finishCtx, Report{
+		JobID:     job.ID,
+		VMID:      r.desc.VMID,
+		Status:    outcome.status,
+		Phase:     PhaseFinish,
+		Message:   r.redactor.Redact(message),
+		Artifacts: artifacts,
+		Result:    resultJSON,
+	}); err != nil {
+		r.logf("final report failed: %v", err)
+	}
+	if outcome.status != StatusCompleted {
+		r.logf("agent finished with status %s", outcome.status)
+	}
+	return nil
+}
+
+// report posts a progress report. Failures are logged, not fatal: the job
+// keeps running and the final report carries the outcome.
+func (r *Runner) report(ctx context.Context, status, phase, message string) {
+	err := r.client.Report(ctx, Report{
+		JobID:   r.boot.Job.ID,
+		VMID:    r.desc.VMID,
+		Status:  status,
+		Phase:   phase,
+		Message: r.redactor.Redact(message),
+	})
+	if err != nil {
+		r.logf("report %s/%s failed: %v", status, phase, err)
+	}
+}
+
+func (r *Runner) loadDescriptor() error {
+	data, err := os.ReadFile(r.cfg.BootstrapPath)
+	if err != nil {
+		return fmt.Errorf("bootstrap file not found at %s", r.cfg.BootstrapPath)
+	}
+	if err := json.Unmarshal(data, &r.desc); err != nil {
+		return fmt.Errorf("parse %s: %w", r.cfg.BootstrapPath, err)
+	}
+	r.desc.Controller = strings.TrimRight(strings.TrimSpace(r.desc.Controller), "/")
+	if r.desc.Token == "" || r.desc.Controller == "" || r.desc.VMID <= 0 {
+		return errors.New("bootstrap file missing token/controller/vmid")
+	}
+	r.redactor.Add(r.desc.Token)
+	return nil
+}
+
+// loadBootstrap uses the cached payload from an earlier start when present:
+// the bootstrap token is single-use, so a restarted runner cannot fetch again.
+func (r *Runner) loadBootstrap(ctx context.Context) error {
+	cachePath := filepath.Join(r.cfg.SecretsDir, "bootstrap.json")
+	var payload Bootstrap
+	if data, err := os.ReadFile(cachePath); r.cfg.BootstrapCache && err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("parse cached bootstrap payload: %w", err)
+		}
+		r.logf("using cached bootstrap payload")
+	} else {
+		r.logf("fetching bootstrap payload")
+		fetched, raw, err := r.client.FetchBootstrap(ctx, r.desc.Token, r.desc.VMID)
+		if err != nil {
+			if errors.Is(err, ErrNoJob) {
+				return err
+			}
+			return fmt.Errorf("failed to fetch bootstrap payload: %w", err)
+		}
+		if err := os.WriteFile(cachePath, raw, 0o600); err != nil {
+			return err
+		}
+		payload = *fetched
+	}
+	if payload.Job.ID == "" || payload.Job.RepoURL == "" {
+		return errors.New("bootstrap payload missing job id or repo_url")
+	}
+	if strings.TrimSpace(payload.Job.Ref) == "" {
+		payload.Job.Ref = "main"
+	}
+	r.boot = &payload
+	r.addSecrets()
+	return nil
+}
+
+func (r *Runner) addSecrets() {
+	b := r.boot
+	if b.Artifact != nil {
+		r.redactor.Add(b.Artifact.Token)
+	}
+	if b.Git != nil {
+		r.redactor.Add(b.Git.Token)
+	}
+	if b.Tailscale != nil {
+		r.redactor.Add(b.Tailscale.AuthKey)
+	}
+	r.redactor.Add(b.SandboxSecret)
+	for _, value := range b.Env {
+		r.redactor.Add(value)
+	}
+}
+
+// setup writes the per-job secrets to tmpfs and builds the environment for
+// git and the agent.
+func (r *Runner) setup(ctx context.Context) error {
+	b := r.boot
+	secret := func(name, content string, mode os.FileMode) (string, error) {
+		path := filepath.Join(r.cfg.SecretsDir, name)
+		return path, os.WriteFile(path, []byte(content), mode)
+	}
+	// Guest metadata and credential-proxy clients send the sandbox secret
+	// in X-AgentLab-Sandbox-Secret.
+	if b.SandboxSecret != "" {
+		if _, err := secret("sandbox-secret", b.SandboxSecret, 0o600); err != nil {
+			return err
+		}
+	} else {
+		r.logf("no sandbox secret in bootstrap payload; metadata and proxy clients will not authenticate")
+	}
+
+	r.env = os.Environ()
+	if len(b.Env) > 0 {
+		for _, key := range sortedKeys(b.Env) {
+			r.env = append(r.env, key+"="+b.Env[key])
+		}
+		if err := r.writeEnvScript(b.Env); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(b.ClaudeSettingsJSON) != "" {
+		path, err := secret("claude-settings.json", b.ClaudeSettingsJSON, 0o600)
+		if err != nil {
+			return err
+		}
+		r.env = append(r.env, "CLAUDE_SETTINGS_PATH="+path)
+	}
+	authEnv, err := gitAuthEnv(r.cfg.SecretsDir, b.Git)
+	if err != nil {
+		return fmt.Errorf("git credentials: %w", err)
+	}
+	r.env = append(r.env, authEnv...)
+	r.git = git{env: r.env}
+
+	if err := enrollTailscale(ctx, b.Tailscale, r.redactor, r.logf); err != nil {
+		r.logf("%v", err)
+		r.report(ctx, StatusRunning, PhaseSetup, "warning: tailscale enrollment failed (job continues)")
+	}
+	return nil
+}
+
+// writeEnvScript replaces env.sh in the secrets dir. The file is renamed into
+// place so a shell sourcing it never reads a half-written version.
+func (r *Runner) writeEnvScript(env map[string]string) error {
+	var script strings.Builder
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&script, "export %s=%s\n", key, shellQuote(env[key]))
+	}
+	path := filepath.Join(r.cfg.SecretsDir, "env.sh")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(script.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prepareRepo checks out the job ref, configures the inner sandbox, and
+// fetches parent artifacts.
+func (r *Runner) prepareRepo(ctx context.Context) error {
+	job := r.boot.Job
+	r.repoDir = r.cfg.RepoDir
+	if r.repoDir == "" {
+		r.repoDir = filepath.Join(r.cfg.WorkDirBase, "repo")
+		if isWritableDir("/work") {
+			r.repoDir = "/work/repo"
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(r.repoDir), 0o755); err != nil {
+		return err
+	}
+	r.logf("preparing repo %s in %s", job.RepoURL, r.repoDir)
+	err := r.client.retry(ctx, r.cfg.RetryMax, r.cfg.RetryInitialBackoff, func(ctx context.Context) error {
+		return r.git.cloneOrUpdate(ctx, r.repoDir, job.RepoURL, job.Ref)
+	})
+	if err != nil {
+		r.logf("repo checkout failed: %v", err)
+		return errors.New("repo checkout failed")
+	}
+	r.base = r.git.head(ctx, r.repoDir)
+
+	if err := r.fetchParentArtifacts(ctx); err != nil {
+		r.logf("%v", err)
+		return errors.New("parent artifact download failed")
+	}
+	return nil
+}
+
+func (r *Runner) parentArtifactsDir() string {
+	if r.cfg.ParentArtDir != "" {
+		return r.cfg.ParentArtDir
+	}
+	return filepath.Join(r.cfg.RunDir, "parent-artifacts")
+}
+
+// fetchParentArtifacts downloads parent job artifacts into
+// <parent dir>/<job_id>/<path>, verifying each sha256.
+func (r *Runner) fetchParentArtifacts(ctx context.Context) error {
+	parents := r.boot.ParentArtifacts
+	if len(parents) == 0 {
+		return nil
+	}
+	if r.boot.Artifact == nil || r.boot.Artifact.Token == "" {
+		return errors.New("parent artifacts listed but no artifact token in bootstrap payload")
+	}
+	root := r.parentArtifactsDir()
+	for _, parent := range parents {
+		rel := filepath.Clean(filepath.Join(parent.JobID, parent.Path))
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("parent artifact %s/%s has an unsafe path", parent.JobID, parent.Path)
+		}
+		r.logf("fetching parent artifact %s/%s", parent.JobID, parent.Path)
+		if err := r.client.DownloadArtifact(ctx, parent.URL, r.boot.Artifact.Token, filepath.Join(root, rel), parent.Sha256); err != nil {
+			return fmt.Errorf("failed to download parent artifact %s/%s: %w", parent.JobID, parent.Path, err)
+		}
+	}
+	r.logf("fetched %d parent artifact(s) into %s", len(parents), root)
+	return nil
+}
+
+// agentCommand resolves the agent argv: AGENTLAB_AGENT_COMMAND, then the
+// repo's .agentlab/run.sh, then the agentlab-agent wrapper fed the task on
+// stdin. The inner sandbox prefix wraps whichever is chosen.
+func (r *Runner) agentCommand() ([]string, string, error) {
+	job := r.boot.Job
+	kind := normalizeInnerSandbox(r.cfg.InnerSandbox)
+	extra := r.cfg.InnerSandboxArgs
+	if !r.cfg.innerSandboxSet && r.boot.Policy != nil {
+		kind = normalizeInnerSandbox(r.boot.Policy.InnerSandbox)
+	}
+	if len(extra) == 0 && kind != "" && r.boot.Policy != nil {
+		extra = r.boot.Policy.InnerSandboxArgs
+	}
+	prefix, err := innerSandboxPrefix(kind, extra, os.Getenv("HOME"), r.repoDir, r.cfg.SecretsDir)
+	if err != nil {
+		return nil, "", err
+	}
+	if kind != "" {
+		r.logf("inner sandbox enabled: %s", kind)
+	}
+
+	taskFile := filepath.Join(r.cfg.SecretsDir, "task.txt")
+	if job.Task != "" {
+		if err := os.WriteFile(taskFile, []byte(job.Task+"\n"), 0o600); err != nil {
+			return nil, "", err
+		}
+	}
+	r.env = append(r.env,
+		"AGENTLAB_TASK="+job.Task,
+		"AGENTLAB_TASK_FILE="+taskFile,
+		"AGENTLAB_JOB_ID="+job.ID,
+		"AGENTLAB_JOB_MODE="+job.Mode,
+		"AGENTLAB_JOB_PROFILE="+job.Profile,
+		"AGENTLAB_REPO_DIR="+r.repoDir,
+		"AGENTLAB_INNER_SANDBOX="+kind,
+		"AGENTLAB_PARENT_ARTIFACTS_DIR="+r.parentArtifactsDir(),
+	)
+
+	command := r.cfg.AgentCommand
+	if command == "" {
+		if script := filepath.Join(r.repoDir, ".agentlab", "run.sh"); isExecutable(script) {
+			command = script
+		} else {
+			command = agentWrapper
+		}
+	}
+	argv := append([]string{}, prefix...)
+	stdin := ""
+	switch {
+	case command == agentWrapper:
+		argv = append(argv, agentWrapper, "--agent", r.cfg.AgentTool)
+		if job.Task != "" {
+			stdin = job.Task + "\n"
+		}
+	case r.cfg.AgentArgs == "" && isExecutable(command):
+		argv = append(argv, command)
+	default:
+		argv = append(argv, "bash", "-lc", strings.TrimSpace(command+" "+r.cfg.AgentArgs))
+	}
+	return argv, stdin, nil
+}
+
+// agentOutcome is how the agent run ended.
+type agentOutcome struct {
+	status   string
+	exitCode int
+	message  string
+	// finalized is set when the daemon finished the job while the agent
+	// was still running.
+	finalized bool
+}
+
+func (r *Runner) agentTimeout() time.Duration {
+	if r.cfg.AgentTimeout > 0 {
+		return r.cfg.AgentTimeout
+	}
+	if ttl := r.boot.Job.TTLMinutes; ttl != nil && *ttl > 0 {
+		return time.Duration(*ttl) * time.Minute
+	}
+	return 0
+}
+
+// runAgent runs argv in the repo in its own process group. Output is
+// redacted, appended to agent-runner.log, and streamed to the daemon. The
+// agent is stopped with SIGTERM, then SIGKILL after CancelGrace, when ctx is
+// canceled, its time limit passes, or the daemon reports the job finalized.
+func (r *Runner) runAgent(ctx context.Context, argv []string, stdin string) agentOutcome {
+	logPath := filepath.Join(r.cfg.RunDir, "agent-runner.log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return agentOutcome{status: StatusFailed, exitCode: 1, message: "agent log unavailable"}
+	}
+	defer logFile.Close()
+
+	agentCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	if limit := r.agentTimeout(); limit > 0 {
+		var cancel context.CancelFunc
+		agentCtx, cancel = context.WithTimeoutCause(agentCtx, limit, context.DeadlineExceeded)
+		defer cancel()
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return agentOutcome{status: StatusFailed, exitCode: 1, message: "agent start failed"}
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = r.repoDir
+	cmd.Env = r.env
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		_ = pr.Close()
+		_ = pw.Close()
+		r.logf("agent start failed: %v", err)
+		return agentOutcome{status: StatusFailed, exitCode: 127, message: "agent start failed"}
+	}
+	_ = pw.Close()
+
+	streamer := newLogStreamer(r, logFile)
+	streamDone := make(chan struct{})
+	go func() {
+		streamer.copy(pr)
+		close(streamDone)
+	}()
+
+	var finalized bool
+	var finalizedMu sync.Mutex
+	hbDone := make(chan struct{})
+	go func() {
+		defer close(hbDone)
+		r.heartbeat(agentCtx, func() {
+			finalizedMu.Lock()
+			finalized = true
+			finalizedMu.Unlock()
+			stop(ErrJobFinalized)
+		})
+	}()
+	refreshDone := make(chan struct{})
+	go func() {
+		defer close(refreshDone)
+		r.refreshSecrets(agentCtx)
+	}()
+	go streamer.flushLoop(agentCtx)
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- cmd.Wait() }()
+	var waitErr error
+	select {
+	case waitErr = <-waitDone:
+	case <-agentCtx.Done():
+		r.logf("stopping agent: %v", context.Cause(agentCtx))
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case waitErr = <-waitDone:
+		case <-time.After(r.cfg.CancelGrace):
+			r.logf("agent did not exit after %s; killing", r.cfg.CancelGrace)
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			waitErr = <-waitDone
+		}
+	}
+	// Children that kept the pipe open must not hold up the report.
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	select {
+	case <-streamDone:
+	case <-time.After(5 * time.Second):
+		_ = pr.Close()
+		<-streamDone
+	}
+	stop(nil)
+	<-hbDone
+	<-refreshDone
+	streamer.flush(context.WithoutCancel(ctx))
+
+	outcome := agentOutcome{status: StatusCompleted, exitCode: exitCode(cmd, waitErr)}
+	finalizedMu.Lock()
+	outcome.finalized = finalized
+	finalizedMu.Unlock()
+	cause := context.Cause(agentCtx)
+	switch {
+	case errors.Is(cause, context.DeadlineExceeded):
+		outcome.status = StatusTimeout
+		outcome.exitCode = timeoutExitCode
+		outcome.message = "agent timed out"
+	case outcome.finalized:
+		outcome.status = StatusFailed
+		outcome.message = "agent stopped: job finalized by daemon"
+	case ctx.Err() != nil:
+		outcome.status = StatusFailed
+		outcome.message = "agent canceled"
+	case outcome.exitCode == timeoutExitCode:
+		// A wrapper that enforces its own limit with timeout(1).
+		outcome.status = StatusTimeout
+	case outcome.exitCode != 0:
+		outcome.status = StatusFailed
+	}
+	return outcome
+}
+
+// heartbeat pings the daemon until ctx ends. onFinalized runs once when the
+// daemon reports the job already finished.
+func (r *Runner) heartbeat(ctx context.Context, onFinalized func()) {
+	if r.cfg.HeartbeatInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := r.client.Report(ctx, Report{
+			JobID:     r.boot.Job.ID,
+			VMID:      r.desc.VMID,
+			Status:    StatusRunning,
+			Phase:     PhaseAgent,
+			Heartbeat: true,
+		})
+		if errors.Is(err, ErrJobFinalized) {
+			onFinalized()
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			r.logf("heartbeat failed: %v", err)
+		}
+	}
+}
+
+// refreshSecrets polls the metadata env endpoint until ctx ends and rewrites
+// env.sh when the daemon reports a change, so secrets rotated with
+// "agentlab secrets set-env" reach the sandbox without a restart. The agent's
+// own environment is fixed at start; tools that source env.sh see new values.
+// Values are added to the redactor before they are written; only key names
+// are logged.
+func (r *Runner) refreshSecrets(ctx context.Context) {
+	if r.cfg.SecretsRefreshInterval <= 0 || r.boot.SandboxSecret == "" {
+		return
+	}
+	current := r.boot.Env
+	etag := ""
+	ticker := time.NewTicker(r.cfg.SecretsRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		snapshot, err := r.client.FetchEnv(ctx, r.boot.SandboxSecret, etag)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logf("secrets refresh failed: %v", err)
+			}
+			continue
+		}
+		if snapshot == nil {
+			continue
+		}
+		etag = snapshot.Version
+		changed := changedEnvKeys(current, snapshot.Env)
+		if len(changed) == 0 {
+			continue
+		}
+		for _, value := range snapshot.Env {
+			r.redactor.Add(value)
+		}
+		if err := r.writeEnvScript(snapshot.Env); err != nil {
+			r.logf("secrets refresh: write env.sh: %v", err)
+			continue
+		}
+		current = snapshot.Env
+		r.logf("secrets refreshed: %s", strings.Join(changed, ", "))
+	}
+}
+
+// changedEnvKeys lists keys added, removed, or changed between two envs.
+func changedEnvKeys(before, after map[string]string) []string {
+	var changed []string
+	for key, value := range after {
+		if old, ok := before[key]; !ok || old != value {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func exitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState == nil {
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
+// logStreamer redacts agent output line by line, appends it to the log
+// file and the runner's own log, and batches it into RUNNING reports.
+type logStreamer struct {
+	r    *Runner
+	file io.Writer
+	mu   sync.Mutex
+	buf  strings.Builder
+}
+
+func newLogStreamer(r *Runner, file io.Writer) *logStreamer {
+	return &logStreamer{r: r, file: file}
+}
+
+func (s *logStreamer) copy(src io.Reader) {
+	reader := bufio.NewReader(src)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = s.r.redactor.Redact(line)
+			_, _ = io.WriteString(s.file, line)
+			if s.r.cfg.StreamLogs {
+				s.mu.Lock()
+				if s.buf.Len() < s.r.cfg.LogMaxChars {
+					s.buf.WriteString(line)
+				}
+				s.mu.Unlock()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *logStreamer) flushLoop(ctx context.Context) {
+	if !s.r.cfg.StreamLogs || s.r.cfg.LogInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.r.cfg.LogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flush(ctx)
+		}
+	}
+}
+
+// flush sends buffered output as one RUNNING report, capped at LogMaxChars.
+func (s *logStreamer) flush(ctx context.Context) {
+	s.mu.Lock()
+	chunk := s.buf.String()
+	s.buf.Reset()
+	s.mu.Unlock()
+	if strings.TrimSpace(chunk) == "" {
+		return
+	}
+	if max := s.r.cfg.LogMaxChars; max > 0 && len(chunk) > max {
+		chunk = chunk[:max]
+	}
+	s.r.report(ctx, StatusRunning, PhaseAgent, chunk)
+}
+
+// collectArtifacts writes the push bundle, job diff, and report.json, then
+// uploads the artifact tarball and the typed artifacts. It returns the
+// bundle metadata for the final report and sets result.Commit.
+func (r *Runner) collectArtifacts(ctx context.Context, result *JobResult) []ArtifactMetadata {
+	job := r.boot.Job
+	hasRepo := isDir(filepath.Join(r.repoDir, ".git"))
+
+	var pushBundle string
+	if result.Status == StatusCompleted && hasRepo && r.boot.Push != nil && r.boot.Push.Branch != "" {
+		name := defaultPushBundle
+		if r.boot.Push.BundlePath != "" {
+			name = filepath.Base(r.boot.Push.BundlePath)
+		}
+		pushBundle = filepath.Join(r.cfg.RunDir, name)
+		_ = os.Remove(pushBundle)
+		ok, err := r.git.writePushBundle(ctx, r.repoDir, r.base, pushBundle, job.ID)
+		if err != nil {
+			r.logf("push bundle failed: %v", err)
+		} else if !ok {
+			r.logf("no commits to push")
+		}
+		if err != nil || !ok {
+			_ = os.Remove(pushBundle)
+			pushBundle = ""
+		}
+	}
+
+	patchPath := filepath.Join(r.cfg.RunDir, "patch.diff")
+	changesPath := filepath.Join(r.cfg.RunDir, "changes.json")
+	_ = os.Remove(patchPath)
+	_ = os.Remove(changesPath)
+	if hasRepo {
+		if err := r.git.writeJobDiff(ctx, r.repoDir, r.base, job.Ref, patchPath, changesPath); err != nil {
+			r.logf("job diff unavailable: %v", err)
+			_ = os.Remove(patchPath)
+			_ = os.Remove(changesPath)
+		}
+		result.Commit = r.git.head(ctx, r.repoDir)
+	}
+
+	reportJSON, _ := json.Marshal(result)
+	reportPath := filepath.Join(r.cfg.RunDir, "report.json")
+	if err := os.WriteFile(reportPath, reportJSON, 0o600); err != nil {
+		r.logf("write report.json: %v", err)
+	}
+	artifactsDir := r.cfg.ArtifactsDir
+	if artifactsDir == "" {
+		artifactsDir = filepath.Join(r.cfg.RunDir, "artifacts")
+	}
+	if err := os.MkdirAll(artifactsDir, 0o700); err == nil {
+		_ = copyFile(filepath.Join(r.cfg.RunDir, "agent-runner.log"), filepath.Join(artifactsDir, "agent-runner.log"))
+		_ = copyFile(reportPath, filepath.Join(artifactsDir, "report.json"))
+	}
+	bundlePath := filepath.Join(r.cfg.RunDir, artifactBundleName)
+	if err := writeTarGz(bundlePath, artifactsDir); err != nil {
+		r.logf("artifact bundle failed: %v", err)
+		_ = os.Remove(bundlePath)
+		bundlePath = ""
+	}
+
+	endpoint, token := "", ""
+	if r.boot.Artifact != nil {
+		endpoint, token = r.boot.Artifact.Endpoint, r.boot.Artifact.Token
+	}
+	if endpoint == "" || token == "" {
+		r.logf("no artifact endpoint in bootstrap payload; skipping uploads")
+		return nil
+	}
+	r.report(ctx, StatusRunning, PhaseArtifacts, "uploading artifacts")
+	upload := func(path, kind, mime string) (ArtifactMetadata, bool) {
+		if path == "" || !nonEmptyFile(path) {
+			return ArtifactMetadata{}, false
+		}
+		meta, err := r.client.UploadArtifact(ctx, endpoint, token, path, kind, mime)
+		if err != nil {
+			r.logf("%s upload failed: %v", kind, err)
+			return ArtifactMetadata{}, false
+		}
+		r.logf("%s upload complete", kind)
+		return meta, true
+	}
+	var artifacts []ArtifactMetadata
+	if meta, ok := upload(bundlePath, ArtifactKindBundle, "application/gzip"); ok {
+		artifacts = append(artifacts, meta)
+	}
+	if _, ok := upload(patchPath, ArtifactKindPatch, "text/x-diff"); ok {
+		upload(changesPath, ArtifactKindChangeSummary, "application/json")
+	}
+	upload(pushBundle, ArtifactKindGitBundle, "application/octet-stream")
+	return artifacts
+}
+
+// writeTarGz archives the regular files under dir into dest.
+func writeTarGz(dest, dir string) error {
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	walkErr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = "./" + filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	for _, closeErr := range []error{tw.Close(), gz.Close(), out.Close()} {
+		if walkErr == nil {
+			walkErr = closeErr
+		}
+	}
+	return walkErr
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o600)
+}
+
+func nonEmptyFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Size() > 0
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+func isWritableDir(path string) bool {
+	return isDir(path) && syscall.Access(path, 0x2) == nil
+}
+
+// shellQuote single-quotes value for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
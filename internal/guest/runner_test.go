@@ -0,0 +1,545 @@
+package guest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBootstrapToken = "bootstrap-token-123"
+	testArtifactToken  = "artifact-token-456"
+	testGitToken       = "git-token-secret-789"
+)
+
+// fakeDaemon serves the bootstrap, report, and artifact upload endpoints.
+type fakeDaemon struct {
+	t         *testing.T
+	server    *httptest.Server
+	mu        sync.Mutex
+	bootstrap Bootstrap
+	noJob     bool
+	finalized bool
+	fetches   int
+	reports   []Report
+	uploads   map[string][]byte
+	kinds     map[string]string
+	// env is served from /metadata/env; envPolls counts requests.
+	env      map[string]string
+	envPolls int
+}
+
+func newFakeDaemon(t *testing.T, repoURL string) *fakeDaemon {
+	t.Helper()
+	d := &fakeDaemon{t: t, uploads: map[string][]byte{}, kinds: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/bootstrap/fetch", d.handleFetch)
+	mux.HandleFunc("/v1/runner/report", d.handleReport)
+	mux.HandleFunc("/upload", d.handleUpload)
+	mux.HandleFunc("/metadata/env", d.handleEnv)
+	d.server = httptest.NewServer(mux)
+	t.Cleanup(d.server.Close)
+	d.bootstrap = Bootstrap{
+		Job:      BootstrapJob{ID: "job-1", RepoURL: repoURL, Ref: "main", Task: "do the thing", Mode: "dangerous"},
+		Git:      &BootstrapGit{Token: testGitToken},
+		Env:      map[string]string{"SECRET_API_KEY": "sk-super-secret-value"},
+		Artifact: &BootstrapArtifact{Endpoint: d.server.URL + "/upload", Token: testArtifactToken},
+	}
+	return d
+}
+
+func writeTestError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func (d *fakeDaemon) handleFetch(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fetches++
+	var req bootstrapFetchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token != testBootstrapToken || req.VMID != 101 {
+		writeTestError(w, http.StatusForbidden, "invalid token")
+		return
+	}
+	if d.noJob {
+		writeTestError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	_ = json.NewEncoder(w).Encode(d.bootstrap)
+}
+
+func (d *fakeDaemon) handleReport(w http.ResponseWriter, r *http.Request) {
+	var report Report
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		writeTestError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.finalized {
+		writeTestError(w, http.StatusConflict, "job already finalized")
+		return
+	}
+	d.reports = append(d.reports, report)
+	_ = json.NewEncoder(w).Encode(map[string]string{"job_status": report.Status})
+}
+
+func (d *fakeDaemon) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testArtifactToken {
+		writeTestError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	name := r.URL.Query().Get("path")
+	sum := sha256.Sum256(body)
+	d.mu.Lock()
+	d.uploads[name] = body
+	d.kinds[name] = r.URL.Query().Get("kind")
+	d.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(artifactUploadResponse{
+		JobID:    "job-1",
+		Artifact: ArtifactMetadata{Name: name, Path: name, SizeBytes: int64(len(body)), Sha256: hex.EncodeToString(sum[:]), Kind: r.URL.Query().Get("kind")},
+	})
+}
+
+func (d *fakeDaemon) handleEnv(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.envPolls++
+	if r.Header.Get(sandboxSecretHeader) != d.bootstrap.SandboxSecret {
+		writeTestError(w, http.StatusForbidden, "invalid or missing sandbox secret")
+		return
+	}
+	data, _ := json.Marshal(d.env)
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"env": d.env})
+}
+
+func (d *fakeDaemon) snapshot() []Report {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Report(nil), d.reports...)
+}
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_SYSTEM", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "fixture")
+	t.Setenv("GIT_AUTHOR_EMAIL", "fixture@example.invalid")
+	t.Setenv("GIT_COMMITTER_NAME", "fixture")
+	t.Setenv("GIT_COMMITTER_EMAIL", "fixture@example.invalid")
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newOriginRepo creates a repository with one commit on main.
+func newOriginRepo(t *testing.T) (string, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "origin")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "init", "-q", "--initial-branch=main")
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("clean blob content\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), []byte("clean bin content\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "fixture commit")
+	return dir, runGit(t, dir, "rev-parse", "HEAD")
+}
+
+// newTestRunner wires a runner to d with every path under a temp dir.
+func newTestRunner(t *testing.T, d *fakeDaemon, agent string) (*Runner, Config) {
+	t.Helper()
+	root := t.TempDir()
+	bootstrapPath := filepath.Join(root, "bootstrap.json")
+	desc, _ := json.Marshal(BootstrapDescriptor{Token: testBootstrapToken, Controller: d.server.URL, VMID: 101})
+	if err := os.WriteFile(bootstrapPath, desc, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := configFromLookup(func(key string) string {
+		switch key {
+		case "AGENTLAB_BOOTSTRAP":
+			return bootstrapPath
+		case "AGENTLAB_SECRETS_DIR":
+			return filepath.Join(root, "secrets")
+		case "AGENTLAB_RUN_DIR":
+			return filepath.Join(root, "run")
+		case "AGENTLAB_REPO_DIR":
+			return filepath.Join(root, "work", "repo")
+		case "AGENTLAB_AGENT_COMMAND":
+			return agent
+		case "AGENTLAB_INNER_SANDBOX":
+			return "none"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatalf("configFromLookup: %v", err)
+	}
+	cfg.RetryInitialBackoff = time.Millisecond
+	cfg.LogInterval = 20 * time.Millisecond
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.CancelGrace = 2 * time.Second
+	return NewRunner(cfg, log.New(io.Discard, "", 0)), cfg
+}
+
+func writeAgentScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunnerRunCompletesJob(t *testing.T) {
+	requireGit(t)
+	origin, base := newOriginRepo(t)
+	d := newFakeDaemon(t, origin)
+	agent := writeAgentScript(t, `echo "working on $AGENTLAB_JOB_ID with $SECRET_API_KEY"
+echo "new line" >> file.txt
+echo "added" > new.txt
+`)
+	runner, cfg := newTestRunner(t, d, agent)
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	reports := d.snapshot()
+	if len(reports) == 0 {
+		t.Fatal("expected reports")
+	}
+	final := reports[len(reports)-1]
+	if final.Status != StatusCompleted || final.Phase != PhaseFinish {
+		t.Fatalf("final report = %s/%s, want COMPLETED/finish", final.Status, final.Phase)
+	}
+	var result JobResult
+	if err := json.Unmarshal(final.Result, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if result.ExitCode != 0 || result.Mode != "dangerous" || result.Commit != base {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(final.Artifacts) != 1 || final.Artifacts[0].Name != artifactBundleName {
+		t.Fatalf("final artifacts = %+v", final.Artifacts)
+	}
+
+	phases := map[string]bool{}
+	var streamed strings.Builder
+	for _, report := range reports {
+		phases[report.Phase] = true
+		if strings.Contains(report.Message, "sk-super-secret-value") {
+			t.Fatalf("report leaked a secret: %q", report.Message)
+		}
+		if report.Phase == PhaseAgent {
+			streamed.WriteString(report.Message)
+		}
+	}
+	for _, phase := range []string{PhaseBootstrap, PhaseRepo, PhaseAgent, PhaseArtifacts, PhaseFinish} {
+		if !phases[phase] {
+			t.Fatalf("missing %s report in %+v", phase, reports)
+		}
+	}
+	if !strings.Contains(streamed.String(), "working on job-1 with [REDACTED]") {
+		t.Fatalf("streamed log = %q", streamed.String())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.kinds["patch.diff"] != ArtifactKindPatch || d.kinds["changes.json"] != ArtifactKindChangeSummary {
+		t.Fatalf("upload kinds = %+v", d.kinds)
+	}
+	if !strings.Contains(string(d.uploads["patch.diff"]), "+added") {
+		t.Fatalf("patch = %s", d.uploads["patch.diff"])
+	}
+	var summary ChangeSummary
+	if err := json.Unmarshal(d.uploads["changes.json"], &summary); err != nil {
+		t.Fatalf("decode changes.json: %v", err)
+	}
+	if summary.FilesChanged != 2 || summary.Base != base {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	names := tarNames(t, d.uploads[artifactBundleName])
+	if !names["./agent-runner.log"] || !names["./report.json"] {
+		t.Fatalf("bundle entries = %v", names)
+	}
+	logData, err := os.ReadFile(filepath.Join(cfg.RunDir, "agent-runner.log"))
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if strings.Contains(string(logData), "sk-super-secret-value") {
+		t.Fatalf("log leaked a secret: %s", logData)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.SecretsDir, "bootstrap.json")); err != nil {
+		t.Fatalf("bootstrap cache missing: %v", err)
+	}
+}
+
+func tarNames(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	gz, err := gzip.NewReader(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	names := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		names[header.Name] = true
+	}
+}
+
+func TestRunnerRunNoJob(t *testing.T) {
+	requireGit(t)
+	d := newFakeDaemon(t, "unused")
+	d.noJob = true
+	runner, _ := newTestRunner(t, d, "true")
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if d.fetches != 1 {
+		t.Fatalf("fetches = %d, want 1 (no retry)", d.fetches)
+	}
+	if reports := d.snapshot(); len(reports) != 0 {
+		t.Fatalf("reports = %+v, want none", reports)
+	}
+}
+
+func TestRunnerRunUsesCachedBootstrap(t *testing.T) {
+	requireGit(t)
+	origin, _ := newOriginRepo(t)
+	d := newFakeDaemon(t, origin)
+	runner, _ := newTestRunner(t, d, "true")
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("first Run() error = %v", err)
+	}
+	second := NewRunner(runner.cfg, log.New(io.Discard, "", 0))
+	if err := second.Run(context.Background()); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if d.fetches != 1 {
+		t.Fatalf("fetches = %d, want 1", d.fetches)
+	}
+}
+
+func TestRunnerAgentFailureAndTimeout(t *testing.T) {
+	requireGit(t)
+	origin, _ := newOriginRepo(t)
+	tests := []struct {
+		name     string
+		agent    string
+		timeout  time.Duration
+		status   string
+		exitCode int
+	}{
+		{name: "exit code", agent: "exit 3", status: StatusFailed, exitCode: 3},
+		{name: "timeout", agent: "sleep 30", timeout: 200 * time.Millisecond, status: StatusTimeout, exitCode: timeoutExitCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newFakeDaemon(t, origin)
+			runner, _ := newTestRunner(t, d, writeAgentScript(t, tt.agent+"\n"))
+			runner.cfg.AgentTimeout = tt.timeout
+			start := time.Now()
+			if err := runner.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("Run() took %s", time.Since(start))
+			}
+			reports := d.snapshot()
+			final := reports[len(reports)-1]
+			var result JobResult
+			_ = json.Unmarshal(final.Result, &result)
+			if final.Status != tt.status || result.ExitCode != tt.exitCode {
+				t.Fatalf("final = %s exit %d, want %s exit %d", final.Status, result.ExitCode, tt.status, tt.exitCode)
+			}
+		})
+	}
+}
+
+func TestRunnerCancelStopsAgentAndReports(t *testing.T) {
+	requireGit(t)
+	origin, _ := newOriginRepo(t)
+	d := newFakeDaemon(t, origin)
+	marker := filepath.Join(t.TempDir(), "terminated")
+	agent := writeAgentScript(t, fmt.Sprintf("trap 'echo term > %s; exit 143' TERM\nwhile :; do sleep 0.05; done\n", marker))
+	runner, _ := newTestRunner(t, d, agent)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for {
+			for _, report := range d.snapshot() {
+				if report.Phase == PhaseAgent {
+					cancel()
+					return
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	if err := runner.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("agent did not receive SIGTERM: %v", err)
+	}
+	reports := d.snapshot()
+	final := reports[len(reports)-1]
+	if final.Status != StatusFailed || final.Message != "agent canceled" {
+		t.Fatalf("final report = %+v", final)
+	}
+}
+
+func TestRunnerHeartbeatStopsFinalizedJob(t *testing.T) {
+	requireGit(t)
+	origin, _ := newOriginRepo(t)
+	d := newFakeDaemon(t, origin)
+	runner, _ := newTestRunner(t, d, writeAgentScript(t, "sleep 30\n"))
+	runner.cfg.StreamLogs = false
+	go func() {
+		for {
+			for _, report := range d.snapshot() {
+				if report.Heartbeat {
+					d.mu.Lock()
+					d.finalized = true
+					d.mu.Unlock()
+					return
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	start := time.Now()
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatalf("agent was not stopped after the job was finalized")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.uploads[artifactBundleName]; !ok {
+		t.Fatalf("artifacts not uploaded after finalize: %v", d.kinds)
+	}
+}
+
+func TestRunnerRefreshesRotatedSecrets(t *testing.T) {
+	requireGit(t)
+	origin, _ := newOriginRepo(t)
+	d := newFakeDaemon(t, origin)
+	d.bootstrap.SandboxSecret = "sandbox-secret-abcdef"
+	d.env = map[string]string{"SECRET_API_KEY": "sk-rotated-value", "NEW_TOKEN": "tok-added-value"}
+	runner, cfg := newTestRunner(t, d, "")
+	runner.cfg.SecretsRefreshInterval = 20 * time.Millisecond
+	envPath := filepath.Join(cfg.SecretsDir, "env.sh")
+	runner.cfg.AgentCommand = writeAgentScript(t, fmt.Sprintf(`for i in $(seq 200); do
+  . %s
+  if [ "$SECRET_API_KEY" = "sk-rotated-value" ]; then echo "rotated to $SECRET_API_KEY and $NEW_TOKEN"; exit 0; fi
+  sleep 0.05
+done
+exit 1
+`, envPath))
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	reports := d.snapshot()
+	final := reports[len(reports)-1]
+	var result JobResult
+	if err := json.Unmarshal(final.Result, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if final.Status != StatusCompleted || result.ExitCode != 0 {
+		t.Fatalf("agent never saw the rotated secret: final = %s exit %d", final.Status, result.ExitCode)
+	}
+	for _, report := range reports {
+		if strings.Contains(report.Message, "sk-rotated-value") || strings.Contains(report.Message, "tok-added-value") {
+			t.Fatalf("report leaked a refreshed secret: %q", report.Message)
+		}
+	}
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		t.Fatalf("read env.sh: %v", err)
+	}
+	if !strings.Contains(string(data), "export NEW_TOKEN='tok-added-value'") {
+		t.Fatalf("env.sh = %s", data)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.envPolls == 0 {
+		t.Fatal("runner never polled /metadata/env")
+	}
+}
+
+func TestChangedEnvKeys(t *testing.T) {
+	got := changedEnvKeys(
+		map[string]string{"A": "1", "B": "2", "C": "3"},
+		map[string]string{"A": "1", "B": "20", "D": "4"},
+	)
+	if strings.Join(got, ",") != "B,C,D" {
+		t.Fatalf("changedEnvKeys() = %v, want [B C D]", got)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor()
+	r.Add("short", "", "multi\nline-secret", "token-abc", "token-abcdef")
+	got := r.Redact("a token-abcdef b token-abc c short multi\nline-secret")
+	want := "a [REDACTED] b [REDACTED] c short multi\nline-secret"
+	if got != want {
+		t.Fatalf("Redact() = %q, want %q", got, want)
+	}
+	var nilRedactor *Redactor
+	if nilRedactor.Redact("x") != "x" {
+		t.Fatal("nil redactor should pass input through")
+	}
+}
@@ -0,0 +1,111 @@
+// ABOUTME: Inner bubblewrap sandbox and Tailscale enrollment for the guest runner.
+// ABOUTME: Both follow the bootstrap policy; the runner env file can override the sandbox.
+
+package guest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const innerSandboxBubblewrap = "bubblewrap"
+
+// normalizeInnerSandbox maps the accepted spellings of the inner sandbox
+// setting to "" (disabled) or "bubblewrap". Unknown values pass through and
+// fail in innerSandboxPrefix.
+func normalizeInnerSandbox(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "none", "off", "false", "0", "disabled":
+		return ""
+	case "true", "yes", "1", "bubblewrap", "bwrap":
+		return innerSandboxBubblewrap
+	default:
+		return value
+	}
+}
+
+// innerSandboxPrefix returns the bwrap argv that wraps the agent command:
+// a read-only root with the repo, temp dirs, /run, and $HOME writable and
+// the secrets directory read-only.
+func innerSandboxPrefix(kind string, extra []string, home, repoDir, secretsDir string) ([]string, error) {
+	if kind == "" {
+		return nil, nil
+	}
+	if kind != innerSandboxBubblewrap {
+		return nil, fmt.Errorf("unknown inner sandbox: %s", kind)
+	}
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		return nil, fmt.Errorf("inner sandbox requested but bubblewrap (bwrap) not installed")
+	}
+	args := []string{"bwrap", "--die-with-parent", "--unshare-all", "--share-net", "--ro-bind", "/", "/", "--proc", "/proc", "--dev", "/dev"}
+	for _, dir := range []string{"/tmp", "/var/tmp", "/run", home} {
+		if isDir(dir) {
+			args = append(args, "--bind", dir, dir)
+		}
+	}
+	if repoDir != "" {
+		args = append(args, "--bind", repoDir, repoDir)
+	}
+	if secretsDir != "" {
+		args = append(args, "--ro-bind", secretsDir, secretsDir)
+	}
+	args = append(args, extra...)
+	return append(args, "--"), nil
+}
+
+// enrollTailscale runs `tailscale up` with the bootstrap auth key. Failure
+// is a warning: the agent subnet still reaches the daemon. The auth key is
+// never logged.
+func enrollTailscale(ctx context.Context, ts *BootstrapTailscale, redactor *Redactor, logf func(string, ...any)) error {
+	if ts == nil || strings.TrimSpace(ts.AuthKey) == "" {
+		logf("no tailscale authkey in bootstrap payload; skipping enrollment")
+		return nil
+	}
+	if _, err := exec.LookPath("tailscale"); err != nil {
+		logf("tailscale CLI not installed; skipping enrollment")
+		return nil
+	}
+	_ = exec.CommandContext(ctx, "sudo", "systemctl", "start", "tailscaled.service").Run()
+	args := []string{"tailscale", "up", "--authkey=" + ts.AuthKey}
+	if host := strings.TrimSpace(ts.Hostname); host != "" {
+		args = append(args, "--hostname="+host)
+	}
+	if len(ts.Tags) > 0 {
+		args = append(args, "--advertise-tags="+strings.Join(ts.Tags, ","))
+	}
+	for _, arg := range ts.ExtraArgs {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+	logf("bringing tailscale up (hostname=%s)", defaultString(ts.Hostname, "default"))
+	out, err := exec.CommandContext(ctx, "sudo", args...).CombinedOutput()
+	if err != nil {
+		msg := redactor.Redact(strings.TrimSpace(string(out)))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return fmt.Errorf("tailscale up failed: %s", defaultString(msg, err.Error()))
+	}
+	logf("tailscale up complete")
+	return nil
+}
+
+func isDir(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func defaultString(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,172 @@
+// ABOUTME: Wire types the guest agent exchanges with agentlabd.
+// ABOUTME: They mirror the daemon's V1 bootstrap, runner report, and artifact types.
+
+package guest
+
+import "encoding/json"
+
+// Job statuses a runner may report.
+const (
+	StatusRunning   = "RUNNING"
+	StatusCompleted = "COMPLETED"
+	StatusFailed    = "FAILED"
+	StatusTimeout   = "TIMEOUT"
+)
+
+// Runner phases, reported in order as the job progresses.
+const (
+	PhaseBootstrap = "bootstrap"
+	PhaseSetup     = "setup"
+	PhaseRepo      = "repo"
+	PhaseAgent     = "agent"
+	PhaseArtifacts = "artifacts"
+	PhaseFinish    = "finish"
+)
+
+// Artifact kinds understood by the artifact upload endpoint.
+const (
+	ArtifactKindBundle        = "bundle"
+	ArtifactKindPatch         = "patch"
+	ArtifactKindChangeSummary = "change_summary"
+	ArtifactKindGitBundle     = "git_bundle"
+)
+
+// EnvSnapshot is the env the daemon currently grants this sandbox, from
+// GET /metadata/env. Version is the response ETag.
+type EnvSnapshot struct {
+	Env     map[string]string `json:"env"`
+	Version string            `json:"-"`
+}
+
+// BootstrapDescriptor is the on-disk bootstrap.json written by cloud-init.
+type BootstrapDescriptor struct {
+	Token      string `json:"token"`
+	Controller string `json:"controller"`
+	VMID       int    `json:"vmid"`
+}
+
+type bootstrapFetchRequest struct {
+	Token string `json:"token"`
+	VMID  int    `json:"vmid"`
+}
+
+// Bootstrap is the payload returned by POST /v1/bootstrap/fetch.
+type Bootstrap struct {
+	Job                BootstrapJob              `json:"job"`
+	Git                *BootstrapGit             `json:"git,omitempty"`
+	Env                map[string]string         `json:"env,omitempty"`
+	ClaudeSettingsJSON string                    `json:"claude_settings_json,omitempty"`
+	Artifact           *BootstrapArtifact        `json:"artifact,omitempty"`
+	Policy             *BootstrapPolicy          `json:"policy,omitempty"`
+	Tailscale          *BootstrapTailscale       `json:"tailscale,omitempty"`
+	ParentArtifacts    []BootstrapParentArtifact `json:"parent_artifacts,omitempty"`
+	Push               *BootstrapPush            `json:"push,omitempty"`
+	SandboxSecret      string                    `json:"sandbox_secret,omitempty"`
+}
+
+type BootstrapJob struct {
+	ID         string `json:"id"`
+	RepoURL    string `json:"repo_url"`
+	Ref        string `json:"ref"`
+	Task       string `json:"task,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Profile    string `json:"profile,omitempty"`
+	Keepalive  bool   `json:"keepalive,omitempty"`
+	TTLMinutes *int   `json:"ttl_minutes,omitempty"`
+}
+
+type BootstrapGit struct {
+	Token         string `json:"token,omitempty"`
+	Username      string `json:"username,omitempty"`
+	SSHPrivateKey string `json:"ssh_private_key,omitempty"`
+	SSHPublicKey  string `json:"ssh_public_key,omitempty"`
+	KnownHosts    string `json:"known_hosts,omitempty"`
+}
+
+type BootstrapArtifact struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+type BootstrapPolicy struct {
+	Mode             string   `json:"mode,omitempty"`
+	InnerSandbox     string   `json:"inner_sandbox,omitempty"`
+	InnerSandboxArgs []string `json:"inner_sandbox_args,omitempty"`
+}
+
+type BootstrapTailscale struct {
+	AuthKey   string   `json:"authkey,omitempty"`
+	Hostname  string   `json:"hostname,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	ExtraArgs []string `json:"extra_args,omitempty"`
+}
+
+type BootstrapParentArtifact struct {
+	JobID     string `json:"job_id"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	Sha256    string `json:"sha256"`
+	URL       string `json:"url"`
+}
+
+type BootstrapPush struct {
+	Branch     string `json:"branch"`
+	BundlePath string `json:"bundle_path"`
+}
+
+// Report is the body of POST /v1/runner/report.
+type Report struct {
+	JobID     string             `json:"job_id"`
+	VMID      int                `json:"vmid"`
+	Status    string             `json:"status"`
+	Phase     string             `json:"phase,omitempty"`
+	Heartbeat bool               `json:"heartbeat,omitempty"`
+	Message   string             `json:"message,omitempty"`
+	Artifacts []ArtifactMetadata `json:"artifacts,omitempty"`
+	Result    json.RawMessage    `json:"result,omitempty"`
+}
+
+type ArtifactMetadata struct {
+	Name      string `json:"name"`
+	Path      string `json:"path,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	Sha256    string `json:"sha256,omitempty"`
+	MIME      string `json:"mime,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+}
+
+type artifactUploadResponse struct {
+	JobID    string           `json:"job_id"`
+	Artifact ArtifactMetadata `json:"artifact"`
+}
+
+// JobResult is the result object the runner reports on completion and
+// writes to report.json.
+type JobResult struct {
+	Status          string `json:"status"`
+	ExitCode        int    `json:"exit_code"`
+	DurationSeconds int64  `json:"duration_seconds"`
+	Commit          string `json:"commit"`
+	Mode            string `json:"mode"`
+}
+
+// ChangeSummary is the runner's changes.json: the job's changes against the
+// commit it started from.
+type ChangeSummary struct {
+	Base         string            `json:"base"`
+	Head         string            `json:"head"`
+	Ref          string            `json:"ref"`
+	Files        []ChangedFileStat `json:"files"`
+	FilesChanged int               `json:"files_changed"`
+	Additions    int               `json:"additions"`
+	Deletions    int               `json:"deletions"`
+}
+
+type ChangedFileStat struct {
+	Path      string `json:"path"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary"`
+}
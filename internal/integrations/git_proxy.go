@@ -137,3 +137,24 @@ func GitProxyConfigURL(integ *Integration, metadataBaseURL string) string {
 	base := strings.TrimRight(metadataBaseURL, "/")
 	return fmt.Sprintf("url.%s/proxy/%s/.insteadOf=%s", base, integ.Name, integ.Target)
 }
+
+// GitProxyForRepo returns the git-proxy integration whose target covers
+// repoURL, preferring the longest (most specific) target. It returns nil when
+// none matches.
+func GitProxyForRepo(integs []*Integration, repoURL string) *Integration {
+	repo := strings.TrimSpace(repoURL)
+	var best *Integration
+	for _, integ := range integs {
+		if integ == nil || integ.Type != TypeGitProxy {
+			continue
+		}
+		target := strings.TrimRight(integ.Target, "/")
+		if target == "" || !strings.HasPrefix(repo, target+"/") {
+			continue
+		}
+		if best == nil || len(target) > len(strings.TrimRight(best.Target, "/")) {
+			best = integ
+		}
+	}
+	return best
+}
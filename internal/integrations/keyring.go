@@ -0,0 +1,141 @@
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Keyring holds the versioned AES-256 keys for integration secrets. New
+// secrets are encrypted with the Current key; each row records the version it
+// was encrypted with. Older versions are kept after a rotation so rows restored
+// from a backup stay readable.
+type Keyring struct {
+	Current int
+	Keys    map[int][]byte
+}
+
+// keyringFile is the on-disk form of a Keyring. Keys are hex-encoded.
+type keyringFile struct {
+	Current int               `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// SingleKeyring returns a keyring holding key as version 1, the version every
+// row written before key rotation existed carries.
+func SingleKeyring(key []byte) Keyring {
+	return Keyring{Current: 1, Keys: map[int][]byte{1: key}}
+}
+
+// Validate checks that the current key is present and every key is 32 bytes.
+func (k Keyring) Validate() error {
+	if k.Current <= 0 {
+		return fmt.Errorf("keyring current version must be positive, got %d", k.Current)
+	}
+	if _, ok := k.Keys[k.Current]; !ok {
+		return fmt.Errorf("keyring has no key for current version %d", k.Current)
+	}
+	for version, key := range k.Keys {
+		if version <= 0 {
+			return fmt.Errorf("keyring version must be positive, got %d", version)
+		}
+		if len(key) != 32 {
+			return fmt.Errorf("keyring key %d must be 32 bytes, got %d", version, len(key))
+		}
+	}
+	return nil
+}
+
+// Versions returns the key versions in ascending order.
+func (k Keyring) Versions() []int {
+	versions := make([]int, 0, len(k.Keys))
+	for version := range k.Keys {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+func (k Keyring) clone() Keyring {
+	out := Keyring{Current: k.Current, Keys: make(map[int][]byte, len(k.Keys))}
+	for version, key := range k.Keys {
+		out.Keys[version] = append([]byte(nil), key...)
+	}
+	return out
+}
+
+// LoadKeyring reads a keyring file written by WriteKeyring. It returns an
+// error wrapping os.ErrNotExist when the file is absent.
+func LoadKeyring(path string) (Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Keyring{}, err
+	}
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Keyring{}, fmt.Errorf("parse keyring %s: %w", path, err)
+	}
+	keyring := Keyring{Current: file.Current, Keys: make(map[int][]byte, len(file.Keys))}
+	for rawVersion, hexKey := range file.Keys {
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil {
+			return Keyring{}, fmt.Errorf("keyring %s: invalid version %q", path, rawVersion)
+		}
+		key, err := ParseEncryptionKeyHex(hexKey)
+		if err != nil {
+			return Keyring{}, fmt.Errorf("keyring %s: key %d: %w", path, version, err)
+		}
+		keyring.Keys[version] = key
+	}
+	if err := keyring.Validate(); err != nil {
+		return Keyring{}, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// WriteKeyring atomically replaces the keyring file at path with mode 0600.
+func WriteKeyring(path string, keyring Keyring) error {
+	if err := keyring.Validate(); err != nil {
+		return err
+	}
+	file := keyringFile{Current: keyring.Current, Keys: make(map[string]string, len(keyring.Keys))}
+	for version, key := range keyring.Keys {
+		file.Keys[strconv.Itoa(version)] = EncryptionKeyHex(key)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create keyring dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".keyring-*")
+	if err != nil {
+		return fmt.Errorf("create keyring temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write keyring: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("replace keyring: %w", err)
+	}
+	return nil
+}
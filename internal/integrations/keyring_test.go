@@ -0,0 +1,57 @@
+package integrations
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyringWriteAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	k1, _ := GenerateEncryptionKey()
+	k2, _ := GenerateEncryptionKey()
+	keyring := Keyring{Current: 2, Keys: map[int][]byte{1: k1, 2: k2}}
+	if err := WriteKeyring(path, keyring); err != nil {
+		t.Fatalf("WriteKeyring() error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat keyring: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("keyring mode = %v, want 0600", info.Mode().Perm())
+	}
+	loaded, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("LoadKeyring() error: %v", err)
+	}
+	if loaded.Current != 2 || !bytes.Equal(loaded.Keys[1], k1) || !bytes.Equal(loaded.Keys[2], k2) {
+		t.Fatalf("LoadKeyring() = %+v, want the written keyring", loaded.Versions())
+	}
+}
+
+func TestLoadKeyringMissing(t *testing.T) {
+	_, err := LoadKeyring(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadKeyring() error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestKeyringValidate(t *testing.T) {
+	k1, _ := GenerateEncryptionKey()
+	cases := []struct {
+		name    string
+		keyring Keyring
+	}{
+		{name: "no current", keyring: Keyring{Keys: map[int][]byte{1: k1}}},
+		{name: "current missing", keyring: Keyring{Current: 2, Keys: map[int][]byte{1: k1}}},
+		{name: "short key", keyring: Keyring{Current: 1, Keys: map[int][]byte{1: []byte("short")}}},
+	}
+	for _, tc := range cases {
+		if err := tc.keyring.Validate(); err == nil {
+			t.Errorf("%s: Validate() expected error", tc.name)
+		}
+	}
+}
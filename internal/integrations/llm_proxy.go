@@ -32,6 +32,10 @@ type ProxyHandlerOptions struct {
 	// but active stream (large git clone, long LLM completion) is allowed to
 	// exceed the former flat 5m cap, while a truly stalled connection is reclaimed.
 	ResponseBodyIdleTimeout time.Duration
+	// OnTokenUsage, when set, receives the token counts the LLM provider
+	// reported for each proxied request. It is called after the response has
+	// been relayed and only when usage was found.
+	OnTokenUsage func(TokenUsage)
 }
 
 // LLMProxyHandler returns an http.Handler that proxies OpenAI-compatible LLM
@@ -139,10 +143,23 @@ func LLMProxyHandler(integ *Integration, logger *log.Logger, opts ...ProxyHandle
 		// Stream the response body, bounded by the idle deadline so a stalled
 		// upstream is reclaimed (review M5). For SSE responses the scanner
 		// flushes after each event; both paths share the same idle watchdog.
+		var meter *tokenMeter
+		if opt.OnTokenUsage != nil {
+			meter = newTokenMeter(provider)
+		}
 		if isSSEResponse(resp) {
-			streamSSE(w, resp.Body, bodyIdle, cancel, logger, integ.Name)
+			streamSSE(w, resp.Body, bodyIdle, cancel, logger, integ.Name, meter)
 		} else {
-			copyResponseBody(w, resp.Body, bodyIdle, cancel, logger, "llm-"+integ.Name)
+			var body io.Reader = resp.Body
+			if meter != nil {
+				body = io.TeeReader(resp.Body, meter)
+			}
+			copyResponseBody(w, body, bodyIdle, cancel, logger, "llm-"+integ.Name)
+		}
+		if meter != nil && resp.StatusCode < http.StatusBadRequest {
+			if usage := meter.finish(); !usage.Empty() {
+				opt.OnTokenUsage(usage)
+			}
 		}
 	})
 }
@@ -177,8 +194,9 @@ func isSSEResponse(resp *http.Response) bool {
 
 // streamSSE copies an SSE stream from the response body to the writer,
 // flushing after each line, and aborts (via cancel) if no bytes arrive within
-// the idle window (review M5).
-func streamSSE(w http.ResponseWriter, body io.Reader, idle time.Duration, cancel context.CancelFunc, logger *log.Logger, name string) {
+// the idle window (review M5). A non-nil meter sees every line for token
+// accounting.
+func streamSSE(w http.ResponseWriter, body io.Reader, idle time.Duration, cancel context.CancelFunc, logger *log.Logger, name string, meter *tokenMeter) {
 	flusher, canFlush := w.(http.Flusher)
 	var last atomic.Int64
 	last.Store(time.Now().UnixNano())
@@ -193,6 +211,9 @@ func streamSSE(w http.ResponseWriter, body io.Reader, idle time.Duration, cancel
 		if canFlush {
 			flusher.Flush()
 		}
+		if meter != nil {
+			meter.observeLine(line)
+		}
 	}
 	if err := scanner.Err(); err != nil {
 		logger.Printf("llm-proxy %s: sse stream error: %v", name, err)
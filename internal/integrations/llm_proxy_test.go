@@ -308,3 +308,92 @@ func TestLLMProxyHandlerGET(t *testing.T) {
 		t.Errorf("response = %s, want models list", body)
 	}
 }
+
+func TestLLMProxyHandlerReportsTokenUsage(t *testing.T) {
+	cases := []struct {
+		name        string
+		provider    string
+		contentType string
+		body        string
+		want        TokenUsage
+	}{
+		{
+			name:        "openai json",
+			provider:    "openai",
+			contentType: "application/json",
+			body:        `{"model":"gpt-4o","usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42}}`,
+			want:        TokenUsage{Provider: "openai", Model: "gpt-4o", InputTokens: 12, OutputTokens: 30},
+		},
+		{
+			name:        "anthropic stream",
+			provider:    "anthropic",
+			contentType: "text/event-stream",
+			body: "event: message_start\n" +
+				`data: {"type":"message_start","message":{"model":"claude-test","usage":{"input_tokens":25,"output_tokens":1}}}` + "\n\n" +
+				"event: message_delta\n" +
+				`data: {"type":"message_delta","usage":{"output_tokens":15}}` + "\n\n",
+			want: TokenUsage{Provider: "anthropic", Model: "claude-test", InputTokens: 25, OutputTokens: 15},
+		},
+		{
+			name:        "ollama json",
+			provider:    "ollama",
+			contentType: "application/json",
+			body:        `{"model":"llama3","done":true,"prompt_eval_count":7,"eval_count":9}`,
+			want:        TokenUsage{Provider: "ollama", Model: "llama3", InputTokens: 7, OutputTokens: 9},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				io.WriteString(w, tc.body)
+			}))
+			defer upstream.Close()
+
+			integ := &Integration{
+				Name:       "llm",
+				Type:       TypeLLMProxy,
+				Target:     upstream.URL,
+				Secret:     "sk-test",
+				Provider:   tc.provider,
+				AttachMode: AttachAutoAll,
+			}
+			got := make(chan TokenUsage, 1)
+			handler := LLMProxyHandler(integ, log.New(io.Discard, "", 0), ProxyHandlerOptions{
+				OnTokenUsage: func(u TokenUsage) { got <- u },
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/proxy/llm/v1/messages", strings.NewReader(`{}`)))
+			if rec.Body.String() != tc.body {
+				t.Fatalf("body not relayed: %q", rec.Body.String())
+			}
+			select {
+			case usage := <-got:
+				if usage != tc.want {
+					t.Errorf("usage = %+v, want %+v", usage, tc.want)
+				}
+			default:
+				t.Fatal("OnTokenUsage was not called")
+			}
+		})
+	}
+}
+
+func TestLLMProxyHandlerSkipsUsageOnError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"usage":{"prompt_tokens":5}}`)
+	}))
+	defer upstream.Close()
+
+	integ := &Integration{Name: "llm", Type: TypeLLMProxy, Target: upstream.URL, Provider: "openai", AttachMode: AttachAutoAll}
+	called := false
+	handler := LLMProxyHandler(integ, log.New(io.Discard, "", 0), ProxyHandlerOptions{
+		OnTokenUsage: func(TokenUsage) { called = true },
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/proxy/llm/v1/chat/completions", strings.NewReader(`{}`)))
+	if called {
+		t.Error("OnTokenUsage called for an error response")
+	}
+}
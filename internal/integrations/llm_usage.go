@@ -0,0 +1,116 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// maxUsageCaptureBytes caps how much of a non-streaming response body is kept
+// to read token usage from. Larger bodies are still proxied in full; only the
+// usage accounting is skipped.
+const maxUsageCaptureBytes = 1 << 20
+
+// TokenUsage is the token accounting an LLM provider reported for one proxied
+// request.
+type TokenUsage struct {
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// Empty reports whether no tokens were counted.
+func (u TokenUsage) Empty() bool {
+	return u.InputTokens == 0 && u.OutputTokens == 0
+}
+
+// usagePayload covers the usage fields of the supported providers:
+// OpenAI (prompt_tokens/completion_tokens), Anthropic (input_tokens/
+// output_tokens, nested under message in stream start events) and Ollama
+// (prompt_eval_count/eval_count).
+type usagePayload struct {
+	Model           string        `json:"model"`
+	Usage           *usageCounts  `json:"usage"`
+	Message         *usagePayload `json:"message"`
+	PromptEvalCount int64         `json:"prompt_eval_count"`
+	EvalCount       int64         `json:"eval_count"`
+}
+
+type usageCounts struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+}
+
+// tokenMeter accumulates usage from response bodies. Streaming providers
+// repeat or grow the counts across events, so each field keeps its maximum.
+type tokenMeter struct {
+	usage    TokenUsage
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func newTokenMeter(provider string) *tokenMeter {
+	return &tokenMeter{usage: TokenUsage{Provider: provider}}
+}
+
+// Write captures a non-streaming body for parsing once it is complete.
+func (m *tokenMeter) Write(p []byte) (int, error) {
+	if m.overflow {
+		return len(p), nil
+	}
+	if m.buf.Len()+len(p) > maxUsageCaptureBytes {
+		m.overflow = true
+		m.buf.Reset()
+		return len(p), nil
+	}
+	return m.buf.Write(p)
+}
+
+// observeLine reads usage from one SSE line. Ollama streams bare JSON lines,
+// so lines without a data: prefix are tried as well.
+func (m *tokenMeter) observeLine(line string) {
+	line = strings.TrimSpace(line)
+	if data, ok := strings.CutPrefix(line, "data:"); ok {
+		line = strings.TrimSpace(data)
+	}
+	if !strings.HasPrefix(line, "{") {
+		return
+	}
+	m.observe([]byte(line))
+}
+
+// finish parses any captured body and returns the accumulated usage.
+func (m *tokenMeter) finish() TokenUsage {
+	if !m.overflow && m.buf.Len() > 0 {
+		m.observe(m.buf.Bytes())
+		m.buf.Reset()
+	}
+	return m.usage
+}
+
+func (m *tokenMeter) observe(raw []byte) {
+	var payload usagePayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return
+	}
+	m.apply(payload)
+	if payload.Message != nil {
+		m.apply(*payload.Message)
+	}
+}
+
+func (m *tokenMeter) apply(payload usagePayload) {
+	if payload.Model != "" && m.usage.Model == "" {
+		m.usage.Model = payload.Model
+	}
+	input, output := payload.PromptEvalCount, payload.EvalCount
+	if u := payload.Usage; u != nil {
+		input = max(input, u.PromptTokens, u.InputTokens)
+		output = max(output, u.CompletionTokens, u.OutputTokens)
+	}
+	m.usage.InputTokens = max(m.usage.InputTokens, input)
+	m.usage.OutputTokens = max(m.usage.OutputTokens, output)
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/agentlab/agentlab/internal/db"
@@ -16,25 +17,51 @@ import (
 
 // Store manages integration persistence with encrypted secrets at rest.
 //
-// Secrets are encrypted using AES-GCM with a daemon-managed encryption key.
+// Secrets are encrypted using AES-GCM with a daemon-managed keyring. Each row
+// records the key version that encrypted it, so RotateKey can move every row
+// to a new key without a window where secrets are unreadable.
 // They are only decrypted in the proxy hot path, never written to disk in plaintext.
 type Store struct {
-	store  *db.Store
-	encKey []byte // AES-256 encryption key
+	store *db.Store
+
+	mu      sync.RWMutex
+	keyring Keyring
+}
+
+// RotateResult summarizes a completed key rotation.
+type RotateResult struct {
+	Version     int   `json:"version"`
+	Reencrypted int   `json:"reencrypted"`
+	Retired     []int `json:"retired,omitempty"`
 }
 
 // NewStore creates a new integration store.
-// The encKey must be exactly 32 bytes (AES-256).
+// The encKey must be exactly 32 bytes (AES-256). It becomes key version 1.
 func NewStore(store *db.Store, encKey []byte) (*Store, error) {
 	if len(encKey) != 32 {
 		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(encKey))
 	}
+	return NewStoreWithKeyring(store, SingleKeyring(encKey))
+}
+
+// NewStoreWithKeyring creates an integration store backed by a versioned keyring.
+func NewStoreWithKeyring(store *db.Store, keyring Keyring) (*Store, error) {
+	if err := keyring.Validate(); err != nil {
+		return nil, err
+	}
 	return &Store{
-		store:  store,
-		encKey: encKey,
+		store:   store,
+		keyring: keyring.clone(),
 	}, nil
 }
 
+// Keyring returns a copy of the store's current keyring.
+func (s *Store) Keyring() Keyring {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keyring.clone()
+}
+
 // GenerateEncryptionKey generates a new random 32-byte AES-256 key.
 func GenerateEncryptionKey() ([]byte, error) {
 	key := make([]byte, 32)
@@ -66,15 +93,19 @@ func (s *Store) Create(ctx context.Context, integ *Integration) error {
 	if err := integ.Validate(); err != nil {
 		return err
 	}
-	encryptedSecret, err := s.encrypt(integ.Secret)
+	// Hold the read lock across the insert so a concurrent rotation cannot
+	// retire the key version between encrypting and writing the row.
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	encryptedSecret, version, err := s.encrypt(integ.Secret)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEncryptFailed, err)
 	}
 	now := time.Now().UTC().Format(time.RFC3339)
 	result, err := s.store.DB.ExecContext(ctx,
-		`INSERT INTO integrations (name, type, target, encrypted_secret, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		integ.Name, string(integ.Type), integ.Target, encryptedSecret,
+		`INSERT INTO integrations (name, type, target, encrypted_secret, key_version, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		integ.Name, string(integ.Type), integ.Target, encryptedSecret, version,
 		integ.SecretType, integ.SecretHeader, integ.Username, integ.Provider,
 		string(integ.AttachMode), integ.AttachSelector,
 		now, now,
@@ -87,6 +118,7 @@ func (s *Store) Create(ctx context.Context, integ *Integration) error {
 	}
 	id, _ := result.LastInsertId()
 	integ.ID = id
+	integ.KeyVersion = version
 	integ.CreatedAt = time.Now().UTC()
 	integ.UpdatedAt = integ.CreatedAt
 	return nil
@@ -94,24 +126,30 @@ func (s *Store) Create(ctx context.Context, integ *Integration) error {
 
 // Get retrieves an integration by name, decrypting its secret.
 func (s *Store) Get(ctx context.Context, name string) (*Integration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	row := s.store.DB.QueryRowContext(ctx,
-		`SELECT id, name, type, target, encrypted_secret, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at
+		`SELECT id, name, type, target, encrypted_secret, key_version, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at
 		 FROM integrations WHERE name = ?`, name)
 	return s.scanIntegration(row)
 }
 
 // GetByID retrieves an integration by ID, decrypting its secret.
 func (s *Store) GetByID(ctx context.Context, id int64) (*Integration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	row := s.store.DB.QueryRowContext(ctx,
-		`SELECT id, name, type, target, encrypted_secret, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at
+		`SELECT id, name, type, target, encrypted_secret, key_version, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at
 		 FROM integrations WHERE id = ?`, id)
 	return s.scanIntegration(row)
 }
 
 // List returns all integrations, decrypting their secrets.
 func (s *Store) List(ctx context.Context) ([]*Integration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	rows, err := s.store.DB.QueryContext(ctx,
-		`SELECT id, name, type, target, encrypted_secret, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at
+		`SELECT id, name, type, target, encrypted_secret, key_version, secret_type, secret_header, username, provider, attach_mode, attach_selector, created_at, updated_at
 		 FROM integrations ORDER BY name`)
 	if err != nil {
 		return nil, fmt.Errorf("list integrations: %w", err)
@@ -162,7 +200,7 @@ func (s *Store) scanIntegration(row *sql.Row) (*Integration, error) {
 	var integ Integration
 	var iType, attachMode, encSecret, createdAt, updatedAt string
 	err := row.Scan(
-		&integ.ID, &integ.Name, &iType, &integ.Target, &encSecret,
+		&integ.ID, &integ.Name, &iType, &integ.Target, &encSecret, &integ.KeyVersion,
 		&integ.SecretType, &integ.SecretHeader, &integ.Username, &integ.Provider,
 		&attachMode, &integ.AttachSelector, &createdAt, &updatedAt,
 	)
@@ -174,7 +212,7 @@ func (s *Store) scanIntegration(row *sql.Row) (*Integration, error) {
 	}
 	integ.Type = IntegrationType(iType)
 	integ.AttachMode = AttachmentMode(attachMode)
-	secret, err := s.decrypt(encSecret)
+	secret, err := s.decrypt(encSecret, integ.KeyVersion)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
 	}
@@ -188,7 +226,7 @@ func (s *Store) scanIntegrationFromRows(rows *sql.Rows) (*Integration, error) {
 	var integ Integration
 	var iType, attachMode, encSecret, createdAt, updatedAt string
 	err := rows.Scan(
-		&integ.ID, &integ.Name, &iType, &integ.Target, &encSecret,
+		&integ.ID, &integ.Name, &iType, &integ.Target, &encSecret, &integ.KeyVersion,
 		&integ.SecretType, &integ.SecretHeader, &integ.Username, &integ.Provider,
 		&attachMode, &integ.AttachSelector, &createdAt, &updatedAt,
 	)
@@ -197,7 +235,7 @@ func (s *Store) scanIntegrationFromRows(rows *sql.Rows) (*Integration, error) {
 	}
 	integ.Type = IntegrationType(iType)
 	integ.AttachMode = AttachmentMode(attachMode)
-	secret, err := s.decrypt(encSecret)
+	secret, err := s.decrypt(encSecret, integ.KeyVersion)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
 	}
@@ -207,31 +245,137 @@ func (s *Store) scanIntegrationFromRows(rows *sql.Rows) (*Integration, error) {
 	return &integ, nil
 }
 
-// encrypt encrypts a plaintext string using AES-GCM.
-func (s *Store) encrypt(plaintext string) (string, error) {
-	block, err := aes.NewCipher(s.encKey)
+// RotateKey re-encrypts every integration secret under newKey, which becomes
+// the next key version. save is called with a keyring holding both the old
+// and new keys before any row changes, so a crash mid-rotation leaves every
+// row readable on restart. Rows are rewritten in one transaction. The old
+// versions stay in the keyring: no live row uses them once it commits, but a
+// database restored from an earlier backup still does.
+func (s *Store) RotateKey(ctx context.Context, newKey []byte, save func(Keyring) error) (RotateResult, error) {
+	if len(newKey) != 32 {
+		return RotateResult{}, fmt.Errorf("encryption key must be 32 bytes, got %d", len(newKey))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.keyring.clone()
+	version := 0
+	for v := range next.Keys {
+		if v > version {
+			version = v
+		}
+	}
+	version++
+	next.Keys[version] = append([]byte(nil), newKey...)
+	next.Current = version
+	if save != nil {
+		if err := save(next.clone()); err != nil {
+			return RotateResult{}, fmt.Errorf("save keyring: %w", err)
+		}
+	}
+	previous := s.keyring
+	s.keyring = next
+
+	count, err := s.reencryptAll(ctx)
 	if err != nil {
-		return "", err
+		// Rows are unchanged; the old current key stays in charge. The saved
+		// keyring still holds it, so nothing becomes unreadable.
+		s.keyring.Current = previous.Current
+		return RotateResult{}, err
+	}
+
+	return RotateResult{Version: version, Reencrypted: count, Retired: []int{previous.Current}}, nil
+}
+
+// reencryptAll rewrites every row under the current key. Callers hold s.mu.
+func (s *Store) reencryptAll(ctx context.Context) (int, error) {
+	tx, err := s.store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin rotation: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, encrypted_secret, key_version FROM integrations`)
+	if err != nil {
+		return 0, fmt.Errorf("list integrations: %w", err)
+	}
+	type pending struct {
+		id     int64
+		secret string
+	}
+	var updates []pending
+	for rows.Next() {
+		var (
+			id      int64
+			enc     string
+			version int
+		)
+		if err := rows.Scan(&id, &enc, &version); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan integration: %w", err)
+		}
+		plaintext, err := s.decrypt(enc, version)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%w: integration %d: %v", ErrDecryptFailed, id, err)
+		}
+		updates = append(updates, pending{id: id, secret: plaintext})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, u := range updates {
+		enc, version, err := s.encrypt(u.secret)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrEncryptFailed, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE integrations SET encrypted_secret = ?, key_version = ?, updated_at = ? WHERE id = ?`,
+			enc, version, now, u.id); err != nil {
+			return 0, fmt.Errorf("update integration %d: %w", u.id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit rotation: %w", err)
+	}
+	return len(updates), nil
+}
+
+// encrypt encrypts a plaintext string using AES-GCM under the current key.
+// It returns the hex ciphertext and the key version used. Callers hold s.mu.
+func (s *Store) encrypt(plaintext string) (string, int, error) {
+	version := s.keyring.Current
+	block, err := aes.NewCipher(s.keyring.Keys[version])
+	if err != nil {
+		return "", 0, err
 	}
 	aesGCM, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	nonce := make([]byte, aesGCM.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+		return "", 0, err
 	}
 	ciphertext := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
-	return hex.EncodeToString(ciphertext), nil
+	return hex.EncodeToString(ciphertext), version, nil
 }
 
-// decrypt decrypts a hex-encoded AES-GCM ciphertext.
-func (s *Store) decrypt(encHex string) (string, error) {
+// decrypt decrypts a hex-encoded AES-GCM ciphertext with the given key version.
+func (s *Store) decrypt(encHex string, version int) (string, error) {
 	data, err := hex.DecodeString(encHex)
 	if err != nil {
 		return "", err
 	}
-	block, err := aes.NewCipher(s.encKey)
+	key, ok := s.keyring.Keys[version]
+	if !ok {
+		return "", fmt.Errorf("no key for version %d", version)
+	}
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
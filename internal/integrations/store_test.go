@@ -298,3 +298,97 @@ func TestNewStoreInvalidKey(t *testing.T) {
 		t.Error("expected error for short key")
 	}
 }
+
+func TestStoreRotateKey(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, name := range []string{"alpha", "beta"} {
+		integ := &Integration{
+			Name:       name,
+			Type:       TypeHTTPProxy,
+			Target:     "https://api.example.com",
+			Secret:     "secret-" + name,
+			SecretType: "bearer",
+			AttachMode: AttachAutoAll,
+		}
+		if err := s.Create(ctx, integ); err != nil {
+			t.Fatalf("Create(%s) error: %v", name, err)
+		}
+		if integ.KeyVersion != 1 {
+			t.Fatalf("Create(%s) key version = %d, want 1", name, integ.KeyVersion)
+		}
+	}
+
+	newKey, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("GenerateEncryptionKey(): %v", err)
+	}
+	var saved Keyring
+	result, err := s.RotateKey(ctx, newKey, func(k Keyring) error {
+		saved = k
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RotateKey() error: %v", err)
+	}
+	if result.Version != 2 || result.Reencrypted != 2 {
+		t.Fatalf("RotateKey() = %+v, want version 2 with 2 rows", result)
+	}
+	if len(result.Retired) != 1 || result.Retired[0] != 1 {
+		t.Fatalf("RotateKey() retired = %v, want [1]", result.Retired)
+	}
+	if saved.Current != 2 || len(saved.Keys) != 2 {
+		t.Fatalf("saved keyring = current %d with %d keys, want current 2 with both keys", saved.Current, len(saved.Keys))
+	}
+	keyring := s.Keyring()
+	if keyring.Current != 2 || len(keyring.Keys) != 2 {
+		t.Fatalf("Keyring() = current %d with %d keys, want current 2 keeping version 1", keyring.Current, len(keyring.Keys))
+	}
+
+	all, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	for _, integ := range all {
+		if integ.KeyVersion != 2 {
+			t.Errorf("%s key version = %d, want 2", integ.Name, integ.KeyVersion)
+		}
+		if integ.Secret != "secret-"+integ.Name {
+			t.Errorf("%s secret = %q after rotation", integ.Name, integ.Secret)
+		}
+	}
+}
+
+func TestStoreRotateKeySaveFailureKeepsRows(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	integ := &Integration{
+		Name:       "alpha",
+		Type:       TypeHTTPProxy,
+		Target:     "https://api.example.com",
+		Secret:     "secret-alpha",
+		SecretType: "bearer",
+		AttachMode: AttachAutoAll,
+	}
+	if err := s.Create(ctx, integ); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	newKey, _ := GenerateEncryptionKey()
+	if _, err := s.RotateKey(ctx, newKey, func(Keyring) error { return os.ErrPermission }); err == nil {
+		t.Fatal("RotateKey() expected error when the keyring cannot be saved")
+	}
+	got, err := s.Get(ctx, "alpha")
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if got.KeyVersion != 1 || got.Secret != "secret-alpha" {
+		t.Fatalf("Get() = version %d secret %q, want untouched row", got.KeyVersion, got.Secret)
+	}
+	if s.Keyring().Current != 1 {
+		t.Fatalf("Keyring().Current = %d, want 1", s.Keyring().Current)
+	}
+}
@@ -56,7 +56,8 @@ const (
 //   - Secret: The secret value (API key, token, password) - encrypted at rest
 //   - SecretType: How the secret is injected (bearer, header, basic-auth)
 //   - SecretHeader: Custom header name for header-type secrets
-//   - Provider: LLM provider name for llm-proxy type (openai, anthropic, ollama; auto-detected from target if empty)
+//   - Provider: LLM provider name for llm-proxy type (openai, anthropic, ollama; auto-detected from target if empty),
+//     or git hosting provider for git-proxy type (github, gitlab, gitea; auto-detected from target if empty)
 //   - AttachMode: How the integration is attached to sandboxes
 //   - AttachSelector: Specific sandbox name or tag value
 //   - CreatedAt: When the integration was created
@@ -70,9 +71,10 @@ type Integration struct {
 	SecretType     string // "bearer", "header", "basic-auth"
 	SecretHeader   string // custom header name (for SecretType="header")
 	Username       string // username for basic-auth / git
-	Provider       string // LLM provider for llm-proxy: "openai", "anthropic", "ollama"
+	Provider       string // LLM provider for llm-proxy, git host for git-proxy
 	AttachMode     AttachmentMode
 	AttachSelector string
+	KeyVersion     int // keyring version that encrypted Secret
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
@@ -285,6 +287,14 @@ func (i *Integration) Validate() error {
 			return ErrInvalidProvider
 		}
 	}
+	if i.Type == TypeGitProxy && i.Provider != "" {
+		switch i.Provider {
+		case "github", "gitlab", "gitea":
+			// valid
+		default:
+			return ErrInvalidGitProvider
+		}
+	}
 	switch i.SecretType {
 	case "bearer", "header", "basic-auth", "":
 		// valid (empty defaults to "bearer")
@@ -221,6 +221,30 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: ErrInvalidTargetHost,
 		},
+		{
+			name: "git-proxy with known provider",
+			integ: &Integration{
+				Name:       "forge",
+				Type:       TypeGitProxy,
+				Target:     "https://git.example.com",
+				Secret:     "tok",
+				Provider:   "gitea",
+				AttachMode: AttachAutoAll,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "git-proxy with unknown provider",
+			integ: &Integration{
+				Name:       "forge",
+				Type:       TypeGitProxy,
+				Target:     "https://git.example.com",
+				Secret:     "tok",
+				Provider:   "openai",
+				AttachMode: AttachAutoAll,
+			},
+			wantErr: ErrInvalidGitProvider,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -326,3 +350,29 @@ func TestProxyPath(t *testing.T) {
 		})
 	}
 }
+
+func TestGitProxyForRepo(t *testing.T) {
+	integs := []*Integration{
+		{Name: "llm", Type: TypeLLMProxy, Target: "https://github.com"},
+		{Name: "github", Type: TypeGitProxy, Target: "https://github.com"},
+		{Name: "github-org", Type: TypeGitProxy, Target: "https://github.com/org/"},
+		{Name: "gitea", Type: TypeGitProxy, Target: "https://git.example.com"},
+	}
+	cases := map[string]string{
+		"https://github.com/org/repo.git":      "github-org",
+		"https://github.com/other/repo.git":    "github",
+		"https://git.example.com/a/b.git":      "gitea",
+		"https://git.example.com.evil/a/b.git": "",
+		"git@github.com:org/repo.git":          "",
+	}
+	for repoURL, want := range cases {
+		got := GitProxyForRepo(integs, repoURL)
+		name := ""
+		if got != nil {
+			name = got.Name
+		}
+		if name != want {
+			t.Errorf("GitProxyForRepo(%q) = %q, want %q", repoURL, name, want)
+		}
+	}
+}
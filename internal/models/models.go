@@ -63,6 +63,7 @@ const (
 //   - IP: IP address of the VM/container in the agent subnet
 //   - WorkspaceID: ID of attached workspace volume (optional)
 //   - Owner: User ID of the sandbox owner (empty for single-user mode)
+//   - Team: Team that co-owns the sandbox (empty if not team-owned)
 //   - Keepalive: Whether the sandbox lease auto-renews
 //   - LeaseExpires: When the sandbox lease expires (zero if no TTL)
 //   - LastUsedAt: When the sandbox was last touched by a user interaction
@@ -80,6 +81,7 @@ type Sandbox struct {
 	IP            string
 	WorkspaceID   *string
 	Owner         string // User ID of the owner (empty in single-user mode)
+	Team          string // Team ID that co-owns the sandbox (optional)
 	Keepalive     bool
 	LeaseExpires  time.Time
 	LastUsedAt    time.Time
@@ -117,6 +119,41 @@ const (
 	JobTimeout JobStatus = "TIMEOUT"
 )
 
+// JobDependencyCondition decides whether a job may start once all of its
+// parent jobs have finished.
+type JobDependencyCondition string
+
+const (
+	// JobDependsOnSuccess starts the job only if every parent COMPLETED.
+	JobDependsOnSuccess JobDependencyCondition = "success"
+	// JobDependsOnFailure starts the job only if at least one parent FAILED or
+	// timed out.
+	JobDependsOnFailure JobDependencyCondition = "failure"
+	// JobDependsAlways starts the job whatever the parents' outcome.
+	JobDependsAlways JobDependencyCondition = "always"
+)
+
+// ArtifactKind types an uploaded artifact so readers can find well-known
+// outputs without relying on file names. Untyped artifacts have an empty kind.
+type ArtifactKind string
+
+const (
+	// ArtifactKindBundle is the runner's tarball of logs and reports.
+	ArtifactKindBundle ArtifactKind = "bundle"
+	// ArtifactKindPatch is a unified diff of the job's changes against the
+	// base ref (patch.diff).
+	ArtifactKindPatch ArtifactKind = "patch"
+	// ArtifactKindChangeSummary is the per-file change summary that goes
+	// with the patch (changes.json).
+	ArtifactKindChangeSummary ArtifactKind = "change_summary"
+	// ArtifactKindGitBundle is a git bundle of the job's commits, uploaded
+	// for result publishing.
+	ArtifactKindGitBundle ArtifactKind = "git_bundle"
+	// ArtifactKindRecording is an asciicast v2 recording of an interactive
+	// session. Recordings belong to a sandbox, not a job.
+	ArtifactKindRecording ArtifactKind = "recording"
+)
+
 // Job represents a unit of work to be executed in a sandbox.
 //
 // A job specifies:
@@ -138,6 +175,13 @@ const (
 //   - Keepalive: Whether to auto-renew the lease
 //   - WorkspaceID: ID of attached workspace volume (optional)
 //   - SessionID: Optional session identifier for session-backed runs
+//   - GroupID: ID of the job group this job was fanned out from (optional)
+//   - MatrixJSON: JSON-encoded matrix cell (axis → value) for group children
+//   - EnvJSON: JSON-encoded environment overrides applied at bootstrap
+//   - DependsOn: IDs of parent jobs that must finish before this job starts
+//   - DependsCondition: Parent outcome required to start (empty without DependsOn)
+//   - ParentArtifacts: Whether parent artifacts are offered to the guest at bootstrap
+//   - PublishJSON: JSON-encoded result publishing options (push branch, pull request)
 //   - Status: Current job status
 //   - SandboxVMID: VM ID of the assigned sandbox (set when RUNNING)
 //   - CreatedAt: When the job was created
@@ -154,11 +198,38 @@ type Job struct {
 	Keepalive   bool
 	WorkspaceID *string
 	SessionID   *string
-	Status      JobStatus
-	SandboxVMID *int
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	ResultJSON  string
+	GroupID     *string
+	MatrixJSON  string
+	EnvJSON     string
+	DependsOn   []string
+	// DependsCondition is only meaningful when DependsOn is non-empty.
+	DependsCondition JobDependencyCondition
+	ParentArtifacts  bool
+	PublishJSON      string
+	Status           JobStatus
+	SandboxVMID      *int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	ResultJSON       string
+}
+
+// JobGroup links the child jobs of a matrix (fan-out) run.
+//
+// The group stores the spec it was created from; its status is not persisted
+// but aggregated from the children's JobStatus on read.
+//
+// Fields:
+//   - ID: Unique group identifier (jobgrp_<hex>)
+//   - Name: Optional human-readable label
+//   - SpecJSON: JSON-encoded base job spec and matrix axes
+//   - CreatedAt: When the group was created
+//   - UpdatedAt: When the group was last updated
+type JobGroup struct {
+	ID        string
+	Name      string
+	SpecJSON  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 // Profile defines the configuration template for sandbox provisioning.
@@ -173,6 +244,8 @@ type Job struct {
 // Fields:
 //   - Name: Profile identifier (matches filename)
 //   - TemplateVM: Proxmox VM ID of the template to clone (VM sandboxes)
+//   - Template: Built template reference (name or name@version); when set,
+//     TemplateVM is resolved from the templates table at provisioning time
 //   - Type: Sandbox type ("vm" or "lxc", defaults to "vm")
 //   - Image: Container image for LXC sandboxes (e.g., "ubuntu:22.04")
 //   - UpdatedAt: When the profile was last loaded from disk
@@ -180,6 +253,7 @@ type Job struct {
 type Profile struct {
 	Name       string
 	TemplateVM int
+	Template   string
 	Type       SandboxType // "vm" (default) or "lxc"
 	Image      string      // Container image for LXC (e.g., "ubuntu:22.04")
 	UpdatedAt  time.Time
@@ -205,6 +279,8 @@ type Profile struct {
 //   - LeaseOwner: Current lease owner identifier (empty if unleased)
 //   - LeaseNonce: Lease CAS nonce for renew/release operations
 //   - LeaseExpires: Lease expiration timestamp (zero if no lease)
+//   - Owner: User ID of the workspace owner (empty in single-user mode)
+//   - Team: Team that co-owns the workspace (empty if not team-owned)
 //   - CreatedAt: When the workspace was created
 //   - LastUpdated: When the workspace was last attached/detached
 type Workspace struct {
@@ -218,6 +294,8 @@ type Workspace struct {
 	LeaseOwner     string
 	LeaseNonce     string
 	LeaseExpires   time.Time
+	Owner          string
+	Team           string
 	CreatedAt      time.Time
 	LastUpdated    time.Time
 }
@@ -254,6 +332,8 @@ type WorkspaceSnapshot struct {
 //   - CurrentVMID: Active sandbox VM ID (nil if stopped)
 //   - Profile: Profile name for resume defaults
 //   - Branch: Optional branch label for session tracking
+//   - Owner: User ID of the session owner (empty in single-user mode)
+//   - Team: Team that co-owns the session (empty if not team-owned)
 //   - CreatedAt: When the session was created
 //   - UpdatedAt: When the session was last updated
 //   - MetaJSON: Optional metadata (JSON-encoded)
@@ -264,6 +344,8 @@ type Session struct {
 	CurrentVMID *int
 	Profile     string
 	Branch      string
+	Owner       string
+	Team        string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	MetaJSON    string
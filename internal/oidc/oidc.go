@@ -0,0 +1,277 @@
+// Package oidc implements the OpenID Connect relying-party pieces AgentLab
+// needs: provider discovery, ID token verification against the provider's
+// JWKS, the authorization code flow (with PKCE) that agentlab-dashboard uses
+// to sign users in, and the device authorization grant (RFC 8628) that
+// agentlab connect uses on machines without a browser.
+//
+// Only the ID token matters to AgentLab. agentlabd verifies it, maps its
+// subject and groups to a registered user and teams, and answers with a
+// short-lived AgentLab token signed by the daemon; IdP access and refresh
+// tokens are never stored.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultScopes are requested by both login flows. "groups" is not a standard
+// scope, but most providers need it to include the groups claim, and the rest
+// ignore unknown scopes.
+var DefaultScopes = []string{"openid", "profile", "email", "groups"}
+
+// maxResponseBytes bounds every response read from the provider.
+const maxResponseBytes = 1 << 20
+
+// Provider is an OpenID Connect provider's discovery document.
+type Provider struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+	JWKSURI                     string `json:"jwks_uri"`
+
+	client *http.Client
+}
+
+// Discover fetches the provider's discovery document from
+// <issuer>/.well-known/openid-configuration. The document must name the same
+// issuer, so tokens cannot be verified against a look-alike provider.
+func Discover(ctx context.Context, issuer string, client *http.Client) (*Provider, error) {
+	issuer = strings.TrimRight(strings.TrimSpace(issuer), "/")
+	if issuer == "" {
+		return nil, errors.New("oidc: issuer is required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var p Provider
+	if err := doJSON(client, req, &p); err != nil {
+		return nil, fmt.Errorf("oidc: discover %s: %w", issuer, err)
+	}
+	if strings.TrimRight(p.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc: discovery document names issuer %q, want %q", p.Issuer, issuer)
+	}
+	if p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: discovery document for %s lacks token_endpoint or jwks_uri", issuer)
+	}
+	p.client = client
+	return &p, nil
+}
+
+// TokenResponse is the token endpoint's answer to either login flow.
+type TokenResponse struct {
+	AccessToken string `json:"access_token,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+	TokenType   string `json:"token_type,omitempty"`
+	ExpiresIn   int    `json:"expires_in,omitempty"`
+}
+
+// tokenError is the token endpoint's error body (RFC 6749 §5.2).
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *tokenError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+// AuthCodeURL builds the authorization request that starts the code flow.
+// codeChallenge is the S256 challenge of the verifier later passed to
+// Exchange.
+func (p *Provider) AuthCodeURL(clientID, redirectURI, state, nonce, codeChallenge string, scopes []string) string {
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code. clientSecret may be empty for a
+// public client; the PKCE verifier is always sent.
+func (p *Provider) Exchange(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*TokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", codeVerifier)
+	return p.token(ctx, clientID, clientSecret, form)
+}
+
+// DeviceAuthorization is the provider's answer to a device authorization
+// request: the code the user enters and where to enter it.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// StartDevice begins the device authorization grant.
+func (p *Provider) StartDevice(ctx context.Context, clientID string, scopes []string) (*DeviceAuthorization, error) {
+	if p.DeviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("oidc: provider %s does not support device authorization", p.Issuer)
+	}
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	form := url.Values{}
+	form.Set("client_id", clientID)
+	form.Set("scope", strings.Join(scopes, " "))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.DeviceAuthorizationEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var da DeviceAuthorization
+	if err := doJSON(p.httpClient(), req, &da); err != nil {
+		return nil, fmt.Errorf("oidc: device authorization: %w", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" {
+		return nil, errors.New("oidc: device authorization response lacks device_code or user_code")
+	}
+	return &da, nil
+}
+
+// PollDevice polls the token endpoint until the user approves or denies the
+// device request, the code expires, or ctx ends. It honours the provider's
+// interval and its slow_down requests.
+func (p *Provider) PollDevice(ctx context.Context, clientID string, da *DeviceAuthorization) (*TokenResponse, error) {
+	interval := time.Duration(da.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if da.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(da.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	form.Set("device_code", da.DeviceCode)
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("oidc: device code expired before it was approved")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		resp, err := p.token(ctx, clientID, "", form)
+		var terr *tokenError
+		switch {
+		case err == nil:
+			return resp, nil
+		case errors.As(err, &terr) && terr.Code == "authorization_pending":
+		case errors.As(err, &terr) && terr.Code == "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+	}
+}
+
+func (p *Provider) token(ctx context.Context, clientID, clientSecret string, form url.Values) (*TokenResponse, error) {
+	form.Set("client_id", clientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+	var tr TokenResponse
+	if err := doJSON(p.httpClient(), req, &tr); err != nil {
+		var terr *tokenError
+		if errors.As(err, &terr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	if tr.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token (is the openid scope allowed?)")
+	}
+	return &tr, nil
+}
+
+func (p *Provider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	return http.DefaultClient
+}
+
+// doJSON sends req and decodes a JSON response into v. A 4xx body carrying an
+// OAuth error code is returned as *tokenError.
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		var terr tokenError
+		if json.Unmarshal(data, &terr) == nil && terr.Code != "" {
+			return &terr
+		}
+		return errors.New("unexpected status " + strconv.Itoa(resp.StatusCode))
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// RandomString returns a URL-safe random string for states, nonces and PKCE
+// verifiers.
+func RandomString() string {
+	var buf [32]byte
+	_, _ = rand.Read(buf[:])
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
+
+// CodeChallenge returns the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,150 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentlab/agentlab/internal/oidc"
+	"github.com/agentlab/agentlab/internal/oidc/oidctest"
+)
+
+func discover(t *testing.T, mock *oidctest.Provider) *oidc.Provider {
+	t.Helper()
+	p, err := oidc.Discover(context.Background(), mock.URL, nil)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	return p
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	mock := oidctest.New(t)
+	if _, err := oidc.Discover(context.Background(), mock.URL+"/other", nil); err == nil {
+		t.Fatal("expected an error for a discovery document naming another issuer")
+	}
+	p := discover(t, mock)
+	if p.TokenEndpoint != mock.URL+"/token" || p.DeviceAuthorizationEndpoint != mock.URL+"/device" {
+		t.Fatalf("unexpected endpoints: %+v", p)
+	}
+}
+
+func TestVerifierChecksSignatureAndClaims(t *testing.T) {
+	mock := oidctest.New(t)
+	mock.SetUser("sub-1", "alice", []string{"infra", "ops"})
+	v := oidc.NewVerifier(discover(t, mock), oidctest.ClientID)
+	ctx := context.Background()
+
+	claims, err := v.Verify(ctx, mock.IDToken("n-1", time.Minute), "n-1")
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.Subject != "sub-1" || claims.PreferredUsername != "alice" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if got := claims.Strings("groups"); len(got) != 2 || got[0] != "infra" || got[1] != "ops" {
+		t.Fatalf("groups = %v", got)
+	}
+	if got := claims.String("preferred_username"); got != "alice" {
+		t.Fatalf("String(preferred_username) = %q", got)
+	}
+
+	if _, err := v.Verify(ctx, mock.IDToken("", -5*time.Minute), ""); !errors.Is(err, oidc.ErrIDTokenExpired) {
+		t.Fatalf("expired token: err = %v", err)
+	}
+	if _, err := v.Verify(ctx, mock.IDToken("n-1", time.Minute), "n-2"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("nonce mismatch: err = %v", err)
+	}
+
+	foreign := mock.SignClaims(map[string]any{
+		"iss": mock.URL, "sub": "sub-1", "aud": "someone-else", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	if _, err := v.Verify(ctx, foreign, ""); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("foreign audience: err = %v", err)
+	}
+
+	parts := strings.Split(mock.IDToken("", time.Minute), ".")
+	tampered := parts[0] + "." + strings.Split(foreign, ".")[1] + "." + parts[2]
+	if _, err := v.Verify(ctx, tampered, ""); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("tampered payload: err = %v", err)
+	}
+
+	unsigned := "eyJhbGciOiJub25lIiwia2lkIjoidGVzdC1rZXkifQ." + parts[1] + "."
+	if _, err := v.Verify(ctx, unsigned, ""); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("alg none: err = %v", err)
+	}
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	mock := oidctest.New(t)
+	p := discover(t, mock)
+	ctx := context.Background()
+
+	verifier := oidc.RandomString()
+	redirectURI := "http://127.0.0.1:8080/auth/callback"
+	authURL := p.AuthCodeURL(oidctest.ClientID, redirectURI, "state-1", "nonce-1", oidc.CodeChallenge(verifier), nil)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || loc.Query().Get("state") != "state-1" {
+		t.Fatalf("unexpected redirect %q", resp.Header.Get("Location"))
+	}
+	code := loc.Query().Get("code")
+
+	if _, err := p.Exchange(ctx, oidctest.ClientID, "", code, redirectURI, "wrong-verifier"); err == nil {
+		t.Fatal("expected the exchange to fail with the wrong PKCE verifier")
+	}
+
+	resp, err = client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	loc, _ = url.Parse(resp.Header.Get("Location"))
+	tokens, err := p.Exchange(ctx, oidctest.ClientID, "", loc.Query().Get("code"), redirectURI, verifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if _, err := oidc.NewVerifier(p, oidctest.ClientID).Verify(ctx, tokens.IDToken, "nonce-1"); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	mock := oidctest.New(t)
+	p := discover(t, mock)
+	ctx := context.Background()
+
+	da, err := p.StartDevice(ctx, oidctest.ClientID, nil)
+	if err != nil {
+		t.Fatalf("StartDevice() error = %v", err)
+	}
+	if da.UserCode == "" || da.VerificationURI == "" {
+		t.Fatalf("unexpected device authorization: %+v", da)
+	}
+	tokens, err := p.PollDevice(ctx, oidctest.ClientID, da)
+	if err != nil {
+		t.Fatalf("PollDevice() error = %v", err)
+	}
+	if tokens.IDToken == "" {
+		t.Fatal("PollDevice() returned no id_token")
+	}
+
+	mock.DenyDevice()
+	da, err = p.StartDevice(ctx, oidctest.ClientID, nil)
+	if err != nil {
+		t.Fatalf("StartDevice() error = %v", err)
+	}
+	if _, err := p.PollDevice(ctx, oidctest.ClientID, da); err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Fatalf("denied device request: err = %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Package oidctest provides a local mock OpenID Connect provider for tests.
+//
+// The provider serves discovery, a JWKS, an authorization endpoint that
+// approves every request at once, a token endpoint for the authorization code
+// and device code grants, and a device authorization endpoint. ID tokens are
+// RS256-signed for the user set with SetUser.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ClientID is the client the mock provider issues tokens to.
+const ClientID = "agentlab"
+
+// keyID names the provider's only signing key.
+const keyID = "test-key"
+
+// Provider is a running mock OIDC provider.
+type Provider struct {
+	// URL is the provider's issuer URL.
+	URL string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu      sync.Mutex
+	claims  map[string]any
+	codes   map[string]authCode
+	devices map[string]int // device code -> remaining pending polls
+	denied  bool
+}
+
+type authCode struct {
+	nonce     string
+	challenge string
+	redirect  string
+}
+
+// New starts a mock provider that is closed when the test ends. Its user is
+// "alice" in no groups until SetUser says otherwise.
+func New(t testing.TB) *Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("oidctest: generate key: %v", err)
+	}
+	p := &Provider{
+		key:     key,
+		claims:  map[string]any{"sub": "alice-subject", "preferred_username": "alice"},
+		codes:   map[string]authCode{},
+		devices: map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/jwks", p.handleJWKS)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/device", p.handleDevice)
+	p.server = httptest.NewServer(mux)
+	p.URL = p.server.URL
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// SetUser sets the claims of every ID token issued from now on. sub is
+// required; groups may be nil.
+func (p *Provider) SetUser(sub, username string, groups []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.claims = map[string]any{"sub": sub, "preferred_username": username}
+	if groups != nil {
+		p.claims["groups"] = groups
+	}
+}
+
+// DenyDevice makes pending device requests end in access_denied.
+func (p *Provider) DenyDevice() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.denied = true
+}
+
+// IDToken signs an ID token for the current user with the given nonce,
+// issued to ClientID and valid for ttl (negative for an expired token).
+func (p *Provider) IDToken(nonce string, ttl time.Duration) string {
+	p.mu.Lock()
+	claims := make(map[string]any, len(p.claims)+6)
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	p.mu.Unlock()
+	now := time.Now()
+	claims["iss"] = p.URL
+	claims["aud"] = ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return p.sign(claims)
+}
+
+// SignClaims signs arbitrary claims with the provider key, for tests that
+// need a malformed or foreign token.
+func (p *Provider) SignClaims(claims map[string]any) string {
+	return p.sign(claims)
+}
+
+func (p *Provider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signingInput + "." + b64(sig)
+}
+
+func (p *Provider) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                        p.URL,
+		"authorization_endpoint":        p.URL + "/authorize",
+		"token_endpoint":                p.URL + "/token",
+		"device_authorization_endpoint": p.URL + "/device",
+		"jwks_uri":                      p.URL + "/jwks",
+	})
+}
+
+func (p *Provider) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   b64(pub.N.Bytes()),
+		"e":   b64(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// handleAuthorize approves the request immediately and redirects back with a
+// code, as if the user had signed in.
+func (p *Provider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = authCode{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), redirect: redirect.String()}
+	p.mu.Unlock()
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) handleDevice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != ClientID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := randomString()
+	p.mu.Lock()
+	p.devices[code] = 1
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":               code,
+		"user_code":                 "WDJB-MJHT",
+		"verification_uri":          p.URL + "/activate",
+		"verification_uri_complete": p.URL + "/activate?user_code=WDJB-MJHT",
+		"expires_in":                60,
+		"interval":                  1,
+	})
+}
+
+func (p *Provider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	form := r.PostForm
+	if form.Get("client_id") != ClientID {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	switch form.Get("grant_type") {
+	case "authorization_code":
+		p.mu.Lock()
+		code, ok := p.codes[form.Get("code")]
+		delete(p.codes, form.Get("code"))
+		p.mu.Unlock()
+		if !ok || code.redirect != form.Get("redirect_uri") || code.challenge != challenge(form.Get("code_verifier")) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		p.writeTokens(w, code.nonce)
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		p.mu.Lock()
+		pending, ok := p.devices[form.Get("device_code")]
+		denied := p.denied
+		if ok && pending > 0 {
+			p.devices[form.Get("device_code")] = pending - 1
+		} else {
+			delete(p.devices, form.Get("device_code"))
+		}
+		p.mu.Unlock()
+		switch {
+		case !ok:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expired_token"})
+		case denied:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access_denied", "error_description": "the user denied the request"})
+		case pending > 0:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+		default:
+			p.writeTokens(w, "")
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+	}
+}
+
+func (p *Provider) writeTokens(w http.ResponseWriter, nonce string) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.IDToken(nonce, 5*time.Minute),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return b64(sum[:])
+}
+
+func randomString() string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return b64(buf[:])
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
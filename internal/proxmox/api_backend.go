@@ -49,7 +49,10 @@ type APIBackend struct {
 	Sleep func(ctx context.Context, d time.Duration) error // Custom sleep function for testing
 }
 
-var _ Backend = (*APIBackend)(nil)
+var (
+	_ Backend         = (*APIBackend)(nil)
+	_ TemplateBuilder = (*APIBackend)(nil)
+)
 
 // APIResponse represents the standard Proxmox API response structure.
 type APIResponse struct {
@@ -493,7 +496,7 @@ func (b *APIBackend) ListVMs(ctx context.Context) ([]VMSummary, error) {
 }
 
 // CurrentStats retrieves VM runtime statistics.
-// ABOUTME: CPUUsage is reported by Proxmox status/current.
+// ABOUTME: CPU, memory, disk and network counters are reported by Proxmox status/current.
 func (b *APIBackend) CurrentStats(ctx context.Context, vmid VMID) (VMStats, error) {
 	node, err := b.ensureNode(ctx)
 	if err != nil {
@@ -506,14 +509,7 @@ func (b *APIBackend) CurrentStats(ctx context.Context, vmid VMID) (VMStats, erro
 		return VMStats{}, err
 	}
 
-	var result struct {
-		CPU float64 `json:"cpu"`
-	}
-	if err := json.Unmarshal(data, &result); err != nil {
-		return VMStats{}, fmt.Errorf("parse current stats: %w", err)
-	}
-
-	return VMStats{CPUUsage: result.CPU}, nil
+	return parseCurrentStats(data)
 }
 
 // GuestIP retrieves the guest IP address.
@@ -1082,6 +1078,55 @@ func (b *APIBackend) ValidateTemplate(ctx context.Context, template VMID) error
 	return nil
 }
 
+// CreateFromImage creates a VM whose root disk is imported from an image.
+// ABOUTME: import-from with an absolute path requires a root@pam API token.
+func (b *APIBackend) CreateFromImage(ctx context.Context, vmid VMID, spec ImageVM) error {
+	if err := validateImageVM(spec); err != nil {
+		return err
+	}
+	node, err := b.ensureNode(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("vmid", strconv.Itoa(int(vmid)))
+	for _, kv := range imageVMConfig(spec) {
+		params.Set(kv[0], kv[1])
+	}
+	task, err := b.doPost(ctx, fmt.Sprintf("/nodes/%s/qemu", node), params)
+	if err != nil {
+		return err
+	}
+	if upid := parseTaskUPID(task); upid != "" {
+		return b.waitForTask(ctx, node, upid)
+	}
+	return nil
+}
+
+// ConvertToTemplate removes the build snippet reference and converts the VM.
+// ABOUTME: Proxmox refuses to convert a running VM.
+func (b *APIBackend) ConvertToTemplate(ctx context.Context, vmid VMID) error {
+	node, err := b.ensureNode(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("delete", "cicustom")
+	if _, err := b.doPut(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/config", node, vmid), params); err != nil {
+		return err
+	}
+	task, err := b.doPost(ctx, fmt.Sprintf("/nodes/%s/qemu/%d/template", node, vmid), nil)
+	if err != nil {
+		return err
+	}
+	if upid := parseTaskUPID(task); upid != "" {
+		return b.waitForTask(ctx, node, upid)
+	}
+	return nil
+}
+
 // HTTP client methods
 
 func (b *APIBackend) client() *http.Client {
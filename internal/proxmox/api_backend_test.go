@@ -733,3 +733,61 @@ func TestNewAPIHTTPClientInsecureOverride(t *testing.T) {
 		t.Fatalf("expected TLS verification disabled when insecure override is set")
 	}
 }
+
+func TestAPIBackendCreateFromImageAndConvert(t *testing.T) {
+	var calls []apiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		form, _ := url.ParseQuery(string(body))
+		calls = append(calls, apiRequest{method: r.Method, path: r.URL.Path, form: form})
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":null}`))
+	}))
+	defer srv.Close()
+
+	backend := &APIBackend{
+		BaseURL:    srv.URL + "/api2/json",
+		Node:       "pve",
+		HTTPClient: srv.Client(),
+	}
+
+	err := backend.CreateFromImage(context.Background(), 9001, ImageVM{
+		Name:      "agent-base-v1",
+		ImagePath: "/var/lib/agentlab/images/noble.img",
+		Storage:   "local-zfs",
+		Bridge:    "vmbr1",
+	})
+	if err != nil {
+		t.Fatalf("CreateFromImage() error = %v", err)
+	}
+	if err := backend.ConvertToTemplate(context.Background(), 9001); err != nil {
+		t.Fatalf("ConvertToTemplate() error = %v", err)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 API calls, got %d", len(calls))
+	}
+	create := calls[0]
+	if create.method != http.MethodPost || create.path != "/api2/json/nodes/pve/qemu" {
+		t.Fatalf("create call = %s %s", create.method, create.path)
+	}
+	if create.form.Get("vmid") != "9001" {
+		t.Fatalf("create vmid = %q", create.form.Get("vmid"))
+	}
+	if got := create.form.Get("scsi0"); got != "local-zfs:0,import-from=/var/lib/agentlab/images/noble.img" {
+		t.Fatalf("create scsi0 = %q", got)
+	}
+	if got := create.form.Get("ide2"); got != "local-zfs:cloudinit" {
+		t.Fatalf("create ide2 = %q", got)
+	}
+	if got := create.form.Get("agent"); got != "enabled=1" {
+		t.Fatalf("create agent = %q", got)
+	}
+	if calls[1].method != http.MethodPut || calls[1].path != "/api2/json/nodes/pve/qemu/9001/config" || calls[1].form.Get("delete") != "cicustom" {
+		t.Fatalf("config call = %s %s %v", calls[1].method, calls[1].path, calls[1].form)
+	}
+	if calls[2].method != http.MethodPost || calls[2].path != "/api2/json/nodes/pve/qemu/9001/template" {
+		t.Fatalf("template call = %s %s", calls[2].method, calls[2].path)
+	}
+}
@@ -79,16 +79,32 @@ func (s SnippetStore) Create(input SnippetInput) (CloudInitSnippet, error) {
 		return CloudInitSnippet{}, errors.New("controller URL is required")
 	}
 
-	storage, err := normalizeSnippetStorage(s.Storage)
+	content, err := renderCloudInitUserData(hostname, sshKey, token, controller, int(input.VMID))
 	if err != nil {
 		return CloudInitSnippet{}, err
 	}
-	dir, err := normalizeSnippetDir(s.Dir)
+	return s.write(input.VMID, content)
+}
+
+// CreateUserData writes caller-rendered cloud-init user-data as a snippet for
+// vmid. The template build pipeline uses it for its provisioning script.
+func (s SnippetStore) CreateUserData(vmid VMID, content string) (CloudInitSnippet, error) {
+	if vmid <= 0 {
+		return CloudInitSnippet{}, errors.New("vmid must be greater than zero")
+	}
+	if !strings.HasPrefix(content, "#cloud-config\n") {
+		return CloudInitSnippet{}, errors.New("user-data must start with #cloud-config")
+	}
+	return s.write(vmid, content)
+}
+
+// write stores content under a unique, unguessable filename for vmid.
+func (s SnippetStore) write(vmid VMID, content string) (CloudInitSnippet, error) {
+	storage, err := normalizeSnippetStorage(s.Storage)
 	if err != nil {
 		return CloudInitSnippet{}, err
 	}
-
-	content, err := renderCloudInitUserData(hostname, sshKey, token, controller, int(input.VMID))
+	dir, err := normalizeSnippetDir(s.Dir)
 	if err != nil {
 		return CloudInitSnippet{}, err
 	}
@@ -101,7 +117,7 @@ func (s SnippetStore) Create(input SnippetInput) (CloudInitSnippet, error) {
 		if err != nil {
 			return CloudInitSnippet{}, fmt.Errorf("generate suffix: %w", err)
 		}
-		filename := fmt.Sprintf("agentlab-%d-%s.yaml", input.VMID, suffix)
+		filename := fmt.Sprintf("agentlab-%d-%s.yaml", vmid, suffix)
 		fullPath := filepath.Join(dir, filename)
 		file, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
 		if err != nil {
@@ -127,7 +143,7 @@ func (s SnippetStore) Create(input SnippetInput) (CloudInitSnippet, error) {
 		}
 		storagePath := fmt.Sprintf("%s:snippets/%s", storage, filename)
 		return CloudInitSnippet{
-			VMID:        input.VMID,
+			VMID:        vmid,
 			Filename:    filename,
 			FullPath:    fullPath,
 			Storage:     storage,
@@ -208,3 +208,31 @@ func TestSnippetStoreRejectsRelativeDir(t *testing.T) {
 		t.Fatalf("expected error for relative snippets dir")
 	}
 }
+
+func TestSnippetStoreCreateUserData(t *testing.T) {
+	dir := t.TempDir()
+	store := SnippetStore{
+		Dir:  dir,
+		Rand: bytes.NewReader([]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00, 0x11}),
+	}
+
+	content := "#cloud-config\npackages:\n  - git\n"
+	snippet, err := store.CreateUserData(9001, content)
+	if err != nil {
+		t.Fatalf("CreateUserData() error = %v", err)
+	}
+	if snippet.StoragePath != "local:snippets/agentlab-9001-aabbccddeeff0011.yaml" {
+		t.Fatalf("StoragePath = %q", snippet.StoragePath)
+	}
+	got, err := os.ReadFile(snippet.FullPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("content = %q, want %q", got, content)
+	}
+
+	if _, err := store.CreateUserData(9001, "packages: []\n"); err == nil {
+		t.Fatalf("expected error for user-data without #cloud-config header")
+	}
+}
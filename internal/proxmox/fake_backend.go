@@ -21,6 +21,11 @@ type FakeBackend struct {
 	nextVolumeSeq   int
 }
 
+var (
+	_ Backend         = (*FakeBackend)(nil)
+	_ TemplateBuilder = (*FakeBackend)(nil)
+)
+
 type fakeVM struct {
 	vmid      VMID
 	name      string
@@ -29,6 +34,10 @@ type fakeVM struct {
 	ip        string
 	snapshots map[string]struct{}
 	volumes   map[string]string // slot -> volumeID
+	// builder marks a VM created from an image; it powers itself off as soon
+	// as it starts, as a provisioned template builder does.
+	builder  bool
+	template bool
 }
 
 type fakeVolume struct {
@@ -134,6 +143,9 @@ func (b *FakeBackend) Start(_ context.Context, vmid VMID) error {
 		return ErrVMNotFound
 	}
 	vm.status = StatusRunning
+	if vm.builder {
+		vm.status = StatusStopped
+	}
 	return nil
 }
 
@@ -148,6 +160,26 @@ func (b *FakeBackend) Stop(_ context.Context, vmid VMID) error {
 	return nil
 }
 
+// Suspend and Resume only check that the VM exists: a paused Proxmox VM
+// still reports running.
+func (b *FakeBackend) Suspend(_ context.Context, vmid VMID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.vms[vmid]; !ok {
+		return ErrVMNotFound
+	}
+	return nil
+}
+
+func (b *FakeBackend) Resume(_ context.Context, vmid VMID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.vms[vmid]; !ok {
+		return ErrVMNotFound
+	}
+	return nil
+}
+
 func (b *FakeBackend) Destroy(_ context.Context, vmid VMID) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -255,7 +287,7 @@ func (b *FakeBackend) CurrentStats(_ context.Context, vmid VMID) (VMStats, error
 	if _, ok := b.vms[vmid]; !ok {
 		return VMStats{}, ErrVMNotFound
 	}
-	return VMStats{CPUUsage: 0.01}, nil
+	return VMStats{CPUUsage: 0.01, CPUs: 1, MemoryBytes: 256 << 20, MemoryMaxBytes: 1 << 30}, nil
 }
 
 func (b *FakeBackend) GuestIP(_ context.Context, vmid VMID) (string, error) {
@@ -282,6 +314,9 @@ func (b *FakeBackend) VMConfig(_ context.Context, vmid VMID) (map[string]string,
 		"name":   vm.name,
 		"bridge": vm.config.Bridge,
 	}
+	if vm.template {
+		cfg["template"] = "1"
+	}
 	return cfg, nil
 }
 
@@ -476,6 +511,47 @@ func (b *FakeBackend) ValidateTemplate(_ context.Context, template VMID) error {
 	return nil
 }
 
+func (b *FakeBackend) CreateFromImage(_ context.Context, vmid VMID, spec ImageVM) error {
+	if err := validateImageVM(spec); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.vms[vmid]; ok {
+		return fmt.Errorf("vm %d already exists", vmid)
+	}
+	b.vms[vmid] = &fakeVM{
+		vmid:   vmid,
+		name:   strings.TrimSpace(spec.Name),
+		status: StatusStopped,
+		config: VMConfig{
+			Name:     strings.TrimSpace(spec.Name),
+			Cores:    spec.Cores,
+			MemoryMB: spec.MemoryMB,
+			Bridge:   strings.TrimSpace(spec.Bridge),
+		},
+		snapshots: make(map[string]struct{}),
+		volumes:   make(map[string]string),
+		builder:   true,
+	}
+	return nil
+}
+
+func (b *FakeBackend) ConvertToTemplate(_ context.Context, vmid VMID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	vm, ok := b.vms[vmid]
+	if !ok {
+		return ErrVMNotFound
+	}
+	if vm.status != StatusStopped {
+		return fmt.Errorf("vm %d is %s", vmid, vm.status)
+	}
+	vm.config.CloudInit = ""
+	vm.template = true
+	return nil
+}
+
 func fakeIPForVM(vmid VMID) string {
 	octet := int(vmid)%250 + 2
 	return fmt.Sprintf("10.77.0.%d", octet)
@@ -14,6 +14,8 @@ package proxmox
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -32,9 +34,44 @@ const (
 	StatusStopped Status = "stopped"
 )
 
-// VMStats contains runtime statistics for a VM.
+// VMStats contains runtime statistics for a VM. The disk and network fields
+// are cumulative counters since the guest last started.
 type VMStats struct {
-	CPUUsage float64 // Fractional CPU usage from status/current (0.0-1.0+).
+	CPUUsage       float64 // Fractional CPU usage from status/current (0.0-1.0+).
+	CPUs           int     // vCPUs available to the guest.
+	MemoryBytes    uint64  // Guest memory in use.
+	MemoryMaxBytes uint64  // Guest memory limit.
+	DiskReadBytes  uint64
+	DiskWriteBytes uint64
+	NetInBytes     uint64
+	NetOutBytes    uint64
+}
+
+// parseCurrentStats decodes a Proxmox status/current response.
+func parseCurrentStats(data []byte) (VMStats, error) {
+	var result struct {
+		CPU       float64 `json:"cpu"`
+		CPUs      float64 `json:"cpus"`
+		Mem       float64 `json:"mem"`
+		MaxMem    float64 `json:"maxmem"`
+		DiskRead  float64 `json:"diskread"`
+		DiskWrite float64 `json:"diskwrite"`
+		NetIn     float64 `json:"netin"`
+		NetOut    float64 `json:"netout"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return VMStats{}, fmt.Errorf("parse current stats: %w", err)
+	}
+	return VMStats{
+		CPUUsage:       result.CPU,
+		CPUs:           int(result.CPUs),
+		MemoryBytes:    uint64(result.Mem),
+		MemoryMaxBytes: uint64(result.MaxMem),
+		DiskReadBytes:  uint64(result.DiskRead),
+		DiskWriteBytes: uint64(result.DiskWrite),
+		NetInBytes:     uint64(result.NetIn),
+		NetOutBytes:    uint64(result.NetOut),
+	}, nil
 }
 
 // VMSummary contains basic inventory metadata for a VM.
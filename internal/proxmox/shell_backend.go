@@ -83,7 +83,10 @@ type ShellBackend struct {
 	Sleep              func(ctx context.Context, d time.Duration) error // Custom sleep for testing
 }
 
-var _ Backend = (*ShellBackend)(nil)
+var (
+	_ Backend         = (*ShellBackend)(nil)
+	_ TemplateBuilder = (*ShellBackend)(nil)
+)
 
 func (b *ShellBackend) Clone(ctx context.Context, template VMID, target VMID, name string) error {
 	full := b.cloneIsFull()
@@ -418,7 +421,7 @@ func (b *ShellBackend) ListVMs(ctx context.Context) ([]VMSummary, error) {
 }
 
 // CurrentStats retrieves VM runtime statistics via pvesh.
-// ABOUTME: CPUUsage is reported by Proxmox status/current.
+// ABOUTME: CPU, memory, disk and network counters are reported by Proxmox status/current.
 func (b *ShellBackend) CurrentStats(ctx context.Context, vmid VMID) (VMStats, error) {
 	node, err := b.ensureNode(ctx)
 	if err != nil {
@@ -429,13 +432,7 @@ func (b *ShellBackend) CurrentStats(ctx context.Context, vmid VMID) (VMStats, er
 	if err != nil {
 		return VMStats{}, err
 	}
-	var result struct {
-		CPU float64 `json:"cpu"`
-	}
-	if err := json.Unmarshal([]byte(out), &result); err != nil {
-		return VMStats{}, fmt.Errorf("parse current stats: %w", err)
-	}
-	return VMStats{CPUUsage: result.CPU}, nil
+	return parseCurrentStats([]byte(out))
 }
 
 func (b *ShellBackend) GuestIP(ctx context.Context, vmid VMID) (string, error) {
@@ -842,6 +839,28 @@ func (b *ShellBackend) ValidateTemplate(ctx context.Context, template VMID) erro
 	return nil
 }
 
+// CreateFromImage runs `qm create`, importing the root disk from the image.
+func (b *ShellBackend) CreateFromImage(ctx context.Context, vmid VMID, spec ImageVM) error {
+	if err := validateImageVM(spec); err != nil {
+		return err
+	}
+	args := []string{"create", strconv.Itoa(int(vmid))}
+	for _, kv := range imageVMConfig(spec) {
+		args = append(args, "--"+kv[0], kv[1])
+	}
+	_, err := b.run(ctx, b.qmPath(), args...)
+	return err
+}
+
+// ConvertToTemplate removes the build snippet reference and runs `qm template`.
+func (b *ShellBackend) ConvertToTemplate(ctx context.Context, vmid VMID) error {
+	if _, err := b.run(ctx, b.qmPath(), "set", strconv.Itoa(int(vmid)), "--delete", "cicustom"); err != nil {
+		return err
+	}
+	_, err := b.run(ctx, b.qmPath(), "template", strconv.Itoa(int(vmid)))
+	return err
+}
+
 func (b *ShellBackend) guestIPAttempts() int {
 	if b.GuestIPAttempts > 0 {
 		return b.GuestIPAttempts
@@ -325,15 +325,16 @@ func TestShellBackendSuspendResume(t *testing.T) {
 }
 
 func TestShellBackendCurrentStats(t *testing.T) {
-	runner := &fakeRunner{responses: []runnerResponse{{stdout: `{"cpu":0.07}`}}}
+	runner := &fakeRunner{responses: []runnerResponse{{stdout: `{"cpu":0.07,"cpus":2,"mem":536870912,"maxmem":2147483648,"diskread":1024,"diskwrite":2048,"netin":4096,"netout":8192}`}}}
 	backend := &ShellBackend{Runner: runner, Node: "pve"}
 
 	stats, err := backend.CurrentStats(context.Background(), 101)
 	if err != nil {
 		t.Fatalf("CurrentStats() error = %v", err)
 	}
-	if stats.CPUUsage != 0.07 {
-		t.Fatalf("CurrentStats().CPUUsage = %v, want %v", stats.CPUUsage, 0.07)
+	wantStats := VMStats{CPUUsage: 0.07, CPUs: 2, MemoryBytes: 512 << 20, MemoryMaxBytes: 2 << 30, DiskReadBytes: 1024, DiskWriteBytes: 2048, NetInBytes: 4096, NetOutBytes: 8192}
+	if stats != wantStats {
+		t.Fatalf("CurrentStats() = %+v, want %+v", stats, wantStats)
 	}
 
 	want := []runnerCall{{
@@ -721,3 +722,59 @@ func TestShellBackendSnapshotOps(t *testing.T) {
 		t.Fatalf("Snapshot calls = %#v, want %#v", runner.calls, want)
 	}
 }
+
+func TestShellBackendCreateFromImageAndConvert(t *testing.T) {
+	runner := &fakeRunner{responses: []runnerResponse{{}, {}, {}}}
+	backend := &ShellBackend{Runner: runner}
+
+	err := backend.CreateFromImage(context.Background(), 9001, ImageVM{
+		Name:      "agent-base-v1",
+		ImagePath: "/var/lib/agentlab/images/noble.img",
+		Storage:   "local-zfs",
+		Bridge:    "vmbr1",
+		Cores:     2,
+		MemoryMB:  4096,
+	})
+	if err != nil {
+		t.Fatalf("CreateFromImage() error = %v", err)
+	}
+	if err := backend.ConvertToTemplate(context.Background(), 9001); err != nil {
+		t.Fatalf("ConvertToTemplate() error = %v", err)
+	}
+
+	want := []runnerCall{
+		{name: "qm", args: []string{
+			"create", "9001",
+			"--name", "agent-base-v1",
+			"--memory", "4096",
+			"--cores", "2",
+			"--net0", "virtio,bridge=vmbr1",
+			"--scsihw", "virtio-scsi-pci",
+			"--scsi0", "local-zfs:0,import-from=/var/lib/agentlab/images/noble.img",
+			"--ide2", "local-zfs:cloudinit",
+			"--boot", "order=scsi0",
+			"--serial0", "socket",
+			"--vga", "serial0",
+			"--agent", "enabled=1",
+			"--ipconfig0", "ip=dhcp",
+		}},
+		{name: "qm", args: []string{"set", "9001", "--delete", "cicustom"}},
+		{name: "qm", args: []string{"template", "9001"}},
+	}
+	if !reflect.DeepEqual(runner.calls, want) {
+		t.Fatalf("calls = %#v, want %#v", runner.calls, want)
+	}
+}
+
+func TestShellBackendCreateFromImageRejectsRelativePath(t *testing.T) {
+	runner := &fakeRunner{}
+	backend := &ShellBackend{Runner: runner}
+
+	err := backend.CreateFromImage(context.Background(), 9001, ImageVM{ImagePath: "noble.img", Storage: "local-zfs"})
+	if err == nil {
+		t.Fatalf("expected error for relative image path")
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("expected no commands, got %#v", runner.calls)
+	}
+}
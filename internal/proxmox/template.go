@@ -1,7 +1,10 @@
 package proxmox
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -84,3 +87,65 @@ func parseAgentConfigString(value string) (bool, error) {
 	// If the field is present but doesn't specify enabled/disabled, assume enabled.
 	return true, nil
 }
+
+// ImageVM describes a VM built directly from a cloud image rather than cloned
+// from a template. The template build pipeline boots one, provisions it
+// through cloud-init, then converts it with ConvertToTemplate.
+type ImageVM struct {
+	Name      string
+	ImagePath string // absolute path to the image on the Proxmox node
+	Storage   string // storage receiving the imported root disk and the cloud-init drive
+	Bridge    string
+	Cores     int
+	MemoryMB  int
+}
+
+// TemplateBuilder is an optional interface for backends that can create VMs
+// from cloud images and convert them into templates. The daemon checks for it
+// with a type assertion; backends without it cannot build templates.
+type TemplateBuilder interface {
+	// CreateFromImage creates a stopped VM whose root disk is imported from
+	// spec.ImagePath, with a cloud-init drive, a serial console and the
+	// qemu-guest-agent enabled.
+	CreateFromImage(ctx context.Context, vmid VMID, spec ImageVM) error
+	// ConvertToTemplate drops the VM's custom cloud-init snippet and marks
+	// it as a template.
+	ConvertToTemplate(ctx context.Context, vmid VMID) error
+}
+
+func validateImageVM(spec ImageVM) error {
+	if !filepath.IsAbs(spec.ImagePath) {
+		return fmt.Errorf("image path %q must be absolute", spec.ImagePath)
+	}
+	if strings.TrimSpace(spec.Storage) == "" {
+		return fmt.Errorf("storage is required")
+	}
+	return nil
+}
+
+// imageVMConfig returns the Proxmox config keys for a VM built from an image,
+// shared by the API and shell backends.
+func imageVMConfig(spec ImageVM) [][2]string {
+	storage := strings.TrimSpace(spec.Storage)
+	cfg := [][2]string{}
+	if name := strings.TrimSpace(spec.Name); name != "" {
+		cfg = append(cfg, [2]string{"name", name})
+	}
+	if spec.MemoryMB > 0 {
+		cfg = append(cfg, [2]string{"memory", strconv.Itoa(spec.MemoryMB)})
+	}
+	if spec.Cores > 0 {
+		cfg = append(cfg, [2]string{"cores", strconv.Itoa(spec.Cores)})
+	}
+	return append(cfg,
+		[2]string{"net0", buildNet0("virtio", spec.Bridge, nil, "")},
+		[2]string{"scsihw", "virtio-scsi-pci"},
+		[2]string{"scsi0", fmt.Sprintf("%s:0,import-from=%s", storage, spec.ImagePath)},
+		[2]string{"ide2", storage + ":cloudinit"},
+		[2]string{"boot", "order=scsi0"},
+		[2]string{"serial0", "socket"},
+		[2]string{"vga", "serial0"},
+		[2]string{"agent", "enabled=1"},
+		[2]string{"ipconfig0", "ip=dhcp"},
+	)
+}
@@ -0,0 +1,217 @@
+package proxmox
+
+import (
+	"context"
+
+	"github.com/agentlab/agentlab/internal/tracing"
+)
+
+// WithTracing wraps backend so every Backend call records a client span
+// named proxmox.<Method> under the span in its context. The wrapper keeps
+// TemplateBuilder support when backend has it.
+func WithTracing(backend Backend) Backend {
+	if backend == nil {
+		return nil
+	}
+	traced := tracedBackend{next: backend}
+	if builder, ok := backend.(TemplateBuilder); ok {
+		return tracedTemplateBuilder{tracedBackend: traced, builder: builder}
+	}
+	return traced
+}
+
+type tracedBackend struct {
+	next Backend
+}
+
+func startBackendSpan(ctx context.Context, method string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "proxmox."+method, tracing.WithKind(tracing.SpanKindClient), tracing.WithAttributes(attrs...))
+}
+
+func vmidAttr(vmid VMID) tracing.Attribute {
+	return tracing.Int("proxmox.vmid", int(vmid))
+}
+
+func endSpan(span *tracing.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
+
+func (b tracedBackend) Clone(ctx context.Context, template VMID, target VMID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "Clone", vmidAttr(target), tracing.Int("proxmox.template_vmid", int(template)))
+	defer func() { endSpan(span, err) }()
+	return b.next.Clone(ctx, template, target, name)
+}
+
+func (b tracedBackend) Configure(ctx context.Context, vmid VMID, cfg VMConfig) (err error) {
+	ctx, span := startBackendSpan(ctx, "Configure", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Configure(ctx, vmid, cfg)
+}
+
+func (b tracedBackend) Start(ctx context.Context, vmid VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "Start", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Start(ctx, vmid)
+}
+
+func (b tracedBackend) Stop(ctx context.Context, vmid VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "Stop", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Stop(ctx, vmid)
+}
+
+func (b tracedBackend) Suspend(ctx context.Context, vmid VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "Suspend", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Suspend(ctx, vmid)
+}
+
+func (b tracedBackend) Resume(ctx context.Context, vmid VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "Resume", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Resume(ctx, vmid)
+}
+
+func (b tracedBackend) Destroy(ctx context.Context, vmid VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "Destroy", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Destroy(ctx, vmid)
+}
+
+func (b tracedBackend) SnapshotCreate(ctx context.Context, vmid VMID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "SnapshotCreate", vmidAttr(vmid), tracing.String("proxmox.snapshot", name))
+	defer func() { endSpan(span, err) }()
+	return b.next.SnapshotCreate(ctx, vmid, name)
+}
+
+func (b tracedBackend) SnapshotRollback(ctx context.Context, vmid VMID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "SnapshotRollback", vmidAttr(vmid), tracing.String("proxmox.snapshot", name))
+	defer func() { endSpan(span, err) }()
+	return b.next.SnapshotRollback(ctx, vmid, name)
+}
+
+func (b tracedBackend) SnapshotDelete(ctx context.Context, vmid VMID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "SnapshotDelete", vmidAttr(vmid), tracing.String("proxmox.snapshot", name))
+	defer func() { endSpan(span, err) }()
+	return b.next.SnapshotDelete(ctx, vmid, name)
+}
+
+func (b tracedBackend) SnapshotList(ctx context.Context, vmid VMID) (_ []Snapshot, err error) {
+	ctx, span := startBackendSpan(ctx, "SnapshotList", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.SnapshotList(ctx, vmid)
+}
+
+func (b tracedBackend) Status(ctx context.Context, vmid VMID) (_ Status, err error) {
+	ctx, span := startBackendSpan(ctx, "Status", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.Status(ctx, vmid)
+}
+
+func (b tracedBackend) ListVMs(ctx context.Context) (_ []VMSummary, err error) {
+	ctx, span := startBackendSpan(ctx, "ListVMs")
+	defer func() { endSpan(span, err) }()
+	return b.next.ListVMs(ctx)
+}
+
+func (b tracedBackend) CurrentStats(ctx context.Context, vmid VMID) (_ VMStats, err error) {
+	ctx, span := startBackendSpan(ctx, "CurrentStats", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.CurrentStats(ctx, vmid)
+}
+
+func (b tracedBackend) GuestIP(ctx context.Context, vmid VMID) (_ string, err error) {
+	ctx, span := startBackendSpan(ctx, "GuestIP", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.GuestIP(ctx, vmid)
+}
+
+func (b tracedBackend) VMConfig(ctx context.Context, vmid VMID) (_ map[string]string, err error) {
+	ctx, span := startBackendSpan(ctx, "VMConfig", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.next.VMConfig(ctx, vmid)
+}
+
+func (b tracedBackend) CreateVolume(ctx context.Context, storage, name string, sizeGB int) (_ string, err error) {
+	ctx, span := startBackendSpan(ctx, "CreateVolume", tracing.String("proxmox.storage", storage), tracing.Int("proxmox.size_gb", sizeGB))
+	defer func() { endSpan(span, err) }()
+	return b.next.CreateVolume(ctx, storage, name, sizeGB)
+}
+
+func (b tracedBackend) AttachVolume(ctx context.Context, vmid VMID, volumeID, slot string) (err error) {
+	ctx, span := startBackendSpan(ctx, "AttachVolume", vmidAttr(vmid), tracing.String("proxmox.volume", volumeID))
+	defer func() { endSpan(span, err) }()
+	return b.next.AttachVolume(ctx, vmid, volumeID, slot)
+}
+
+func (b tracedBackend) DetachVolume(ctx context.Context, vmid VMID, slot string) (err error) {
+	ctx, span := startBackendSpan(ctx, "DetachVolume", vmidAttr(vmid), tracing.String("proxmox.slot", slot))
+	defer func() { endSpan(span, err) }()
+	return b.next.DetachVolume(ctx, vmid, slot)
+}
+
+func (b tracedBackend) DeleteVolume(ctx context.Context, volumeID string) (err error) {
+	ctx, span := startBackendSpan(ctx, "DeleteVolume", tracing.String("proxmox.volume", volumeID))
+	defer func() { endSpan(span, err) }()
+	return b.next.DeleteVolume(ctx, volumeID)
+}
+
+func (b tracedBackend) VolumeInfo(ctx context.Context, volumeID string) (_ VolumeInfo, err error) {
+	ctx, span := startBackendSpan(ctx, "VolumeInfo", tracing.String("proxmox.volume", volumeID))
+	defer func() { endSpan(span, err) }()
+	return b.next.VolumeInfo(ctx, volumeID)
+}
+
+func (b tracedBackend) VolumeSnapshotCreate(ctx context.Context, volumeID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "VolumeSnapshotCreate", tracing.String("proxmox.volume", volumeID), tracing.String("proxmox.snapshot", name))
+	defer func() { endSpan(span, err) }()
+	return b.next.VolumeSnapshotCreate(ctx, volumeID, name)
+}
+
+func (b tracedBackend) VolumeSnapshotRestore(ctx context.Context, volumeID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "VolumeSnapshotRestore", tracing.String("proxmox.volume", volumeID), tracing.String("proxmox.snapshot", name))
+	defer func() { endSpan(span, err) }()
+	return b.next.VolumeSnapshotRestore(ctx, volumeID, name)
+}
+
+func (b tracedBackend) VolumeSnapshotDelete(ctx context.Context, volumeID, name string) (err error) {
+	ctx, span := startBackendSpan(ctx, "VolumeSnapshotDelete", tracing.String("proxmox.volume", volumeID), tracing.String("proxmox.snapshot", name))
+	defer func() { endSpan(span, err) }()
+	return b.next.VolumeSnapshotDelete(ctx, volumeID, name)
+}
+
+func (b tracedBackend) VolumeClone(ctx context.Context, sourceVolumeID, targetVolumeID string) (err error) {
+	ctx, span := startBackendSpan(ctx, "VolumeClone", tracing.String("proxmox.volume", sourceVolumeID), tracing.String("proxmox.target_volume", targetVolumeID))
+	defer func() { endSpan(span, err) }()
+	return b.next.VolumeClone(ctx, sourceVolumeID, targetVolumeID)
+}
+
+func (b tracedBackend) VolumeCloneFromSnapshot(ctx context.Context, sourceVolumeID, snapshotName, targetVolumeID string) (err error) {
+	ctx, span := startBackendSpan(ctx, "VolumeCloneFromSnapshot", tracing.String("proxmox.volume", sourceVolumeID), tracing.String("proxmox.snapshot", snapshotName), tracing.String("proxmox.target_volume", targetVolumeID))
+	defer func() { endSpan(span, err) }()
+	return b.next.VolumeCloneFromSnapshot(ctx, sourceVolumeID, snapshotName, targetVolumeID)
+}
+
+func (b tracedBackend) ValidateTemplate(ctx context.Context, template VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "ValidateTemplate", tracing.Int("proxmox.template_vmid", int(template)))
+	defer func() { endSpan(span, err) }()
+	return b.next.ValidateTemplate(ctx, template)
+}
+
+type tracedTemplateBuilder struct {
+	tracedBackend
+	builder TemplateBuilder
+}
+
+func (b tracedTemplateBuilder) CreateFromImage(ctx context.Context, vmid VMID, spec ImageVM) (err error) {
+	ctx, span := startBackendSpan(ctx, "CreateFromImage", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.builder.CreateFromImage(ctx, vmid, spec)
+}
+
+func (b tracedTemplateBuilder) ConvertToTemplate(ctx context.Context, vmid VMID) (err error) {
+	ctx, span := startBackendSpan(ctx, "ConvertToTemplate", vmidAttr(vmid))
+	defer func() { endSpan(span, err) }()
+	return b.builder.ConvertToTemplate(ctx, vmid)
+}
@@ -0,0 +1,33 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithTracingPassesThroughAndKeepsTemplateBuilder(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeBackend()
+	traced := WithTracing(fake)
+	if _, ok := traced.(TemplateBuilder); !ok {
+		t.Fatal("WithTracing dropped TemplateBuilder")
+	}
+	if err := traced.Clone(ctx, 9000, 101, "sandbox-101"); !errors.Is(err, ErrVMNotFound) {
+		t.Fatalf("Clone from a missing template = %v, want ErrVMNotFound", err)
+	}
+	if err := traced.(TemplateBuilder).CreateFromImage(ctx, 9000, ImageVM{Name: "base", ImagePath: "/var/lib/vz/images/base.qcow2", Storage: "local-lvm", Bridge: "vmbr1", Cores: 2, MemoryMB: 2048}); err != nil {
+		t.Fatalf("CreateFromImage: %v", err)
+	}
+	if err := traced.ValidateTemplate(ctx, 9000); err != nil {
+		t.Fatalf("ValidateTemplate: %v", err)
+	}
+
+	plain := WithTracing(struct{ Backend }{fake})
+	if _, ok := plain.(TemplateBuilder); ok {
+		t.Fatal("WithTracing added TemplateBuilder to a backend without it")
+	}
+	if WithTracing(nil) != nil {
+		t.Fatal("WithTracing(nil) should stay nil")
+	}
+}
@@ -0,0 +1,126 @@
+// Package recording writes interactive terminal sessions in asciicast v2
+// format (https://docs.asciinema.org/manual/asciicast/v2/), so a session can be
+// audited or replayed later with any asciicast player.
+//
+// A recording is newline-delimited JSON: a header object, then one
+// [elapsed_seconds, code, data] event per line. Code "o" is output from the
+// sandbox, "i" is input typed by the user, and "r" is a terminal resize whose
+// data is "COLSxROWS".
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event codes defined by asciicast v2.
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// Header is the first line of an asciicast v2 recording.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Writer appends timed events to an asciicast v2 stream. It is safe for
+// concurrent use, so output and input can be recorded from separate
+// goroutines.
+type Writer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	now   func() time.Time
+	// pending holds the trailing bytes of an incomplete UTF-8 sequence per
+	// event code, so a multi-byte character split across reads is recorded
+	// whole instead of as replacement characters.
+	pending map[string][]byte
+}
+
+// NewWriter writes the header and returns a Writer whose event times are
+// relative to now. Width and Height default to 80x24.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	return newWriter(w, h, time.Now)
+}
+
+func newWriter(w io.Writer, h Header, now func() time.Time) (*Writer, error) {
+	start := now()
+	h.Version = 2
+	if h.Width <= 0 {
+		h.Width = 80
+	}
+	if h.Height <= 0 {
+		h.Height = 24
+	}
+	if h.Timestamp == 0 {
+		h.Timestamp = start.Unix()
+	}
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("write asciicast header: %w", err)
+	}
+	return &Writer{w: w, start: start, now: now, pending: make(map[string][]byte)}, nil
+}
+
+// Output records bytes the sandbox wrote to the terminal.
+func (w *Writer) Output(p []byte) error {
+	return w.text(EventOutput, p)
+}
+
+// Input records bytes the user sent to the sandbox.
+func (w *Writer) Input(p []byte) error {
+	return w.text(EventInput, p)
+}
+
+// Resize records a terminal size change.
+func (w *Writer) Resize(cols, rows int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.event(EventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (w *Writer) text(code string, p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := append(w.pending[code], p...)
+	cut := len(data)
+	// Hold back at most one incomplete rune; anything else invalid is
+	// recorded as-is and replaced by the JSON encoder.
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	w.pending[code] = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return nil
+	}
+	return w.event(code, string(data[:cut]))
+}
+
+func (w *Writer) event(code, data string) error {
+	elapsed := w.now().Sub(w.start).Seconds()
+	line, err := json.Marshal([]any{elapsed, code, data})
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
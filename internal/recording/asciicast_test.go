@@ -0,0 +1,87 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriterRecordsTimedEvents(t *testing.T) {
+	clock := time.Unix(1_700_000_000, 0)
+	now := func() time.Time { return clock }
+	var buf bytes.Buffer
+	w, err := newWriter(&buf, Header{Width: 100, Height: 30, Title: "sandbox 1001"}, now)
+	if err != nil {
+		t.Fatalf("newWriter: %v", err)
+	}
+
+	clock = clock.Add(500 * time.Millisecond)
+	if err := w.Output([]byte("caf\xc3")); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(250 * time.Millisecond)
+	if err := w.Output([]byte("\xa9 $ ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Input([]byte("ls\r")); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(time.Second)
+	if err := w.Resize(120, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	if !scanner.Scan() {
+		t.Fatal("missing header")
+	}
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Timestamp != 1_700_000_000 || header.Title != "sandbox 1001" {
+		t.Fatalf("header = %+v", header)
+	}
+
+	want := []struct {
+		at   float64
+		code string
+		data string
+	}{
+		{0.5, EventOutput, "caf"},
+		{0.75, EventOutput, "é $ "},
+		{0.75, EventInput, "ls\r"},
+		{1.75, EventResize, "120x40"},
+	}
+	for i, w := range want {
+		if !scanner.Scan() {
+			t.Fatalf("event %d missing", i)
+		}
+		var ev []any
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("decode event %d: %v", i, err)
+		}
+		if len(ev) != 3 || ev[0] != w.at || ev[1] != w.code || ev[2] != w.data {
+			t.Fatalf("event %d = %v, want [%v %q %q]", i, ev, w.at, w.code, w.data)
+		}
+	}
+	if scanner.Scan() {
+		t.Fatalf("unexpected extra line %q", scanner.Text())
+	}
+}
+
+func TestNewWriterDefaultsSize(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, Header{}); err != nil {
+		t.Fatal(err)
+	}
+	var header Header
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 80 || header.Height != 24 || header.Timestamp == 0 {
+		t.Fatalf("header = %+v, want 80x24 with a timestamp", header)
+	}
+}
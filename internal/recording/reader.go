@@ -0,0 +1,156 @@
+package recording
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLineBytes bounds one event line. Output is recorded per read, so lines
+// stay far below this; a longer line means the file is not a recording.
+const maxLineBytes = 4 << 20
+
+// Event is one timed entry of a recording.
+type Event struct {
+	Time float64
+	Code string
+	Data string
+}
+
+// Reader reads an asciicast v2 recording event by event.
+type Reader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+// NewReader reads and validates the header, then returns a Reader positioned
+// at the first event.
+func NewReader(r io.Reader) (*Reader, Header, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, Header{}, fmt.Errorf("read asciicast header: %w", err)
+		}
+		return nil, Header{}, errors.New("asciicast recording is empty")
+	}
+	h, err := ParseHeader(sc.Bytes())
+	if err != nil {
+		return nil, Header{}, err
+	}
+	return &Reader{sc: sc, line: 1}, h, nil
+}
+
+// ParseHeader decodes an asciicast header line and checks it is version 2.
+func ParseHeader(line []byte) (Header, error) {
+	var h Header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return Header{}, fmt.Errorf("invalid asciicast header: %w", err)
+	}
+	if h.Version != 2 {
+		return Header{}, fmt.Errorf("unsupported asciicast version %d", h.Version)
+	}
+	return h, nil
+}
+
+// Next returns the next event, or io.EOF at the end of the recording. Blank
+// lines are skipped.
+func (r *Reader) Next() (Event, error) {
+	for r.sc.Scan() {
+		r.line++
+		line := r.sc.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var raw []json.RawMessage
+		if err := json.Unmarshal(line, &raw); err != nil || len(raw) != 3 {
+			return Event{}, fmt.Errorf("line %d: malformed asciicast event", r.line)
+		}
+		var ev Event
+		if err := json.Unmarshal(raw[0], &ev.Time); err != nil {
+			return Event{}, fmt.Errorf("line %d: malformed event time", r.line)
+		}
+		if err := json.Unmarshal(raw[1], &ev.Code); err != nil {
+			return Event{}, fmt.Errorf("line %d: malformed event code", r.line)
+		}
+		if err := json.Unmarshal(raw[2], &ev.Data); err != nil {
+			return Event{}, fmt.Errorf("line %d: malformed event data", r.line)
+		}
+		return ev, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// PlayOptions controls Play.
+type PlayOptions struct {
+	// Speed multiplies playback speed; values <= 0 mean 1.
+	Speed float64
+	// MaxIdle caps the pause between two events; 0 keeps recorded pauses.
+	MaxIdle time.Duration
+	// Sleep waits between events; nil uses a context-aware timer. Tests
+	// replace it to play back instantly.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Play writes a recording's output events to w with their recorded timing.
+// Input and resize events are not replayed: output already shows what the
+// user typed, as the terminal echoed it.
+func Play(ctx context.Context, src io.Reader, w io.Writer, opts PlayOptions) error {
+	r, _, err := NewReader(src)
+	if err != nil {
+		return err
+	}
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	sleep := opts.Sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+	var last float64
+	for {
+		ev, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ev.Code != EventOutput {
+			continue
+		}
+		gap := time.Duration((ev.Time - last) / speed * float64(time.Second))
+		last = ev.Time
+		if opts.MaxIdle > 0 && gap > opts.MaxIdle {
+			gap = opts.MaxIdle
+		}
+		if gap > 0 {
+			if err := sleep(ctx, gap); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, ev.Data); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
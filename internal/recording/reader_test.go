@@ -0,0 +1,75 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayHonorsTimingSpeedAndIdleCap(t *testing.T) {
+	clock := time.Unix(1_700_000_000, 0)
+	now := func() time.Time { return clock }
+	var rec bytes.Buffer
+	w, err := newWriter(&rec, Header{}, now)
+	if err != nil {
+		t.Fatalf("newWriter: %v", err)
+	}
+	clock = clock.Add(time.Second)
+	_ = w.Output([]byte("$ "))
+	clock = clock.Add(200 * time.Millisecond)
+	_ = w.Input([]byte("ls\r"))
+	_ = w.Output([]byte("ls\r\n"))
+	clock = clock.Add(time.Minute)
+	_ = w.Resize(100, 30)
+	_ = w.Output([]byte("done\r\n"))
+
+	var sleeps []time.Duration
+	var out bytes.Buffer
+	err = Play(context.Background(), &rec, &out, PlayOptions{
+		Speed:   2,
+		MaxIdle: 5 * time.Second,
+		Sleep: func(_ context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if got, want := out.String(), "$ ls\r\ndone\r\n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+	want := []time.Duration{500 * time.Millisecond, 100 * time.Millisecond, 5 * time.Second}
+	if len(sleeps) != len(want) {
+		t.Fatalf("sleeps = %v, want %v", sleeps, want)
+	}
+	for i := range want {
+		if diff := sleeps[i] - want[i]; diff > time.Millisecond || diff < -time.Millisecond {
+			t.Fatalf("sleeps = %v, want %v", sleeps, want)
+		}
+	}
+}
+
+func TestNewReaderRejectsNonRecordings(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":     "",
+		"not json":  "hello\n",
+		"version 1": `{"version":1,"width":80,"height":24}` + "\n",
+	} {
+		if _, _, err := NewReader(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: NewReader succeeded, want error", name)
+		}
+	}
+}
+
+func TestReaderReportsMalformedEvent(t *testing.T) {
+	r, _, err := NewReader(strings.NewReader(`{"version":2,"width":80,"height":24}` + "\n" + `[0.5,"o"]` + "\n"))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Next error = %v, want malformed event on line 2", err)
+	}
+}
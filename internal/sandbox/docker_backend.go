@@ -288,6 +288,14 @@ func (b *DockerBackend) CurrentStats(ctx context.Context, id int) (ContainerStat
 	if err != nil {
 		return ContainerStats{}, err
 	}
+	return parseDockerStats(data)
+}
+
+// parseDockerStats converts a one-shot Docker stats response. Disk counters
+// sum the read and write entries of every device, and network counters sum
+// every interface; a section Docker leaves out or sends as null counts as
+// zero.
+func parseDockerStats(data []byte) (ContainerStats, error) {
 	var stats struct {
 		CPUStats struct {
 			CPUUsage struct {
@@ -302,17 +310,51 @@ func (b *DockerBackend) CurrentStats(ctx context.Context, id int) (ContainerStat
 			} `json:"cpu_usage"`
 			SystemCPUUsage uint64 `json:"system_cpu_usage"`
 		} `json:"precpu_stats"`
+		MemoryStats struct {
+			Usage uint64 `json:"usage"`
+			Limit uint64 `json:"limit"`
+		} `json:"memory_stats"`
+		BlkioStats struct {
+			IOServiceBytesRecursive []struct {
+				Op    string `json:"op"`
+				Value uint64 `json:"value"`
+			} `json:"io_service_bytes_recursive"`
+		} `json:"blkio_stats"`
+		Networks map[string]struct {
+			RxBytes uint64 `json:"rx_bytes"`
+			TxBytes uint64 `json:"tx_bytes"`
+		} `json:"networks"`
 	}
 	if err := json.Unmarshal(data, &stats); err != nil {
 		return ContainerStats{}, err
 	}
-	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
-	systemDelta := float64(stats.CPUStats.SystemCPUUsage - stats.PreCPUStats.SystemCPUUsage)
+	// The counters are unsigned; a container restarted between the two
+	// samples reports a smaller total, which must not wrap around.
 	var cpuUsage float64
-	if systemDelta > 0 && stats.CPUStats.OnlineCPUs > 0 {
-		cpuUsage = (cpuDelta / systemDelta) * float64(stats.CPUStats.OnlineCPUs)
+	cpu, preCPU := stats.CPUStats.CPUUsage.TotalUsage, stats.PreCPUStats.CPUUsage.TotalUsage
+	system, preSystem := stats.CPUStats.SystemCPUUsage, stats.PreCPUStats.SystemCPUUsage
+	if cpu >= preCPU && system > preSystem && stats.CPUStats.OnlineCPUs > 0 {
+		cpuUsage = float64(cpu-preCPU) / float64(system-preSystem) * float64(stats.CPUStats.OnlineCPUs)
+	}
+	out := ContainerStats{
+		CPUUsage:       cpuUsage,
+		CPUs:           stats.CPUStats.OnlineCPUs,
+		MemoryBytes:    stats.MemoryStats.Usage,
+		MemoryMaxBytes: stats.MemoryStats.Limit,
+	}
+	for _, entry := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			out.DiskReadBytes += entry.Value
+		case "write":
+			out.DiskWriteBytes += entry.Value
+		}
+	}
+	for _, nic := range stats.Networks {
+		out.NetInBytes += nic.RxBytes
+		out.NetOutBytes += nic.TxBytes
 	}
-	return ContainerStats{CPUUsage: cpuUsage}, nil
+	return out, nil
 }
 
 func (b *DockerBackend) ValidateTemplate(ctx context.Context, templateOrImage string) error {
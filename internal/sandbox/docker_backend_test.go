@@ -0,0 +1,104 @@
+package sandbox
+
+import (
+	"testing"
+)
+
+func TestParseDockerStats(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want ContainerStats
+	}{
+		{
+			name: "cgroup v1 with several devices and interfaces",
+			data: `{
+  "cpu_stats": {"cpu_usage": {"total_usage": 300}, "system_cpu_usage": 2000, "online_cpus": 4},
+  "precpu_stats": {"cpu_usage": {"total_usage": 100}, "system_cpu_usage": 1000},
+  "memory_stats": {"usage": 1048576, "limit": 4194304},
+  "blkio_stats": {"io_service_bytes_recursive": [
+    {"major": 8, "minor": 0, "op": "Read", "value": 100},
+    {"major": 8, "minor": 0, "op": "Write", "value": 200},
+    {"major": 8, "minor": 0, "op": "Sync", "value": 250},
+    {"major": 8, "minor": 0, "op": "Async", "value": 50},
+    {"major": 8, "minor": 0, "op": "Total", "value": 300},
+    {"major": 8, "minor": 16, "op": "Read", "value": 10},
+    {"major": 8, "minor": 16, "op": "Write", "value": 20}
+  ]},
+  "networks": {
+    "eth0": {"rx_bytes": 1000, "tx_bytes": 2000, "rx_packets": 9},
+    "eth1": {"rx_bytes": 30, "tx_bytes": 40}
+  }
+}`,
+			want: ContainerStats{
+				CPUUsage:       0.8,
+				CPUs:           4,
+				MemoryBytes:    1 << 20,
+				MemoryMaxBytes: 4 << 20,
+				DiskReadBytes:  110,
+				DiskWriteBytes: 220,
+				NetInBytes:     1030,
+				NetOutBytes:    2040,
+			},
+		},
+		{
+			name: "cgroup v2 lowercase ops",
+			data: `{
+  "blkio_stats": {"io_service_bytes_recursive": [
+    {"major": 259, "minor": 0, "op": "read", "value": 4096},
+    {"major": 259, "minor": 0, "op": "write", "value": 8192}
+  ]},
+  "networks": {"eth0": {"rx_bytes": 1, "tx_bytes": 2}}
+}`,
+			want: ContainerStats{DiskReadBytes: 4096, DiskWriteBytes: 8192, NetInBytes: 1, NetOutBytes: 2},
+		},
+		{
+			name: "null blkio and no networks",
+			data: `{
+  "memory_stats": {"usage": 5},
+  "blkio_stats": {"io_service_bytes_recursive": null}
+}`,
+			want: ContainerStats{MemoryBytes: 5},
+		},
+		{
+			name: "missing counter fields",
+			data: `{
+  "cpu_stats": {"online_cpus": 2},
+  "blkio_stats": {"io_service_bytes_recursive": [{"op": "Read"}]},
+  "networks": {"eth0": {}}
+}`,
+			want: ContainerStats{CPUs: 2},
+		},
+		{
+			name: "first sample has no previous cpu reading",
+			data: `{
+  "cpu_stats": {"cpu_usage": {"total_usage": 500}, "system_cpu_usage": 1000, "online_cpus": 1},
+  "precpu_stats": {}
+}`,
+			want: ContainerStats{CPUUsage: 0.5, CPUs: 1},
+		},
+		{
+			name: "restarted container does not wrap the cpu delta",
+			data: `{
+  "cpu_stats": {"cpu_usage": {"total_usage": 100}, "system_cpu_usage": 2000, "online_cpus": 1},
+  "precpu_stats": {"cpu_usage": {"total_usage": 900}, "system_cpu_usage": 1000}
+}`,
+			want: ContainerStats{CPUs: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDockerStats([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parseDockerStats() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDockerStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseDockerStats([]byte("not json")); err == nil {
+		t.Error("parseDockerStats() accepted malformed JSON")
+	}
+}
@@ -314,8 +314,10 @@ func (b *LibvirtBackend) CurrentStats(ctx context.Context, id int) (ContainerSta
 	if err != nil {
 		return ContainerStats{}, fmt.Errorf("libvirt cpu-stats %s: %w", name, err)
 	}
+	var stats ContainerStats
 	// Parse output looking for CPU utilization percentage.
 	lines := strings.Split(string(out), "\n")
+cpuLoop:
 	for _, line := range lines {
 		if !strings.Contains(line, "CPU utilization") && !strings.Contains(line, "utilization") {
 			continue
@@ -324,11 +326,59 @@ func (b *LibvirtBackend) CurrentStats(ctx context.Context, id int) (ContainerSta
 		for _, f := range fields {
 			val, err := strconv.ParseFloat(f, 64)
 			if err == nil {
-				return ContainerStats{CPUUsage: val / 100.0}, nil
+				stats.CPUUsage = val / 100.0
+				break cpuLoop
 			}
 		}
 	}
-	return ContainerStats{}, nil
+	// Memory, disk and network counters are best effort: older libvirt
+	// releases lack some domstats groups.
+	if out, err := b.virsh(cmdCtx, "domstats", name, "--balloon", "--block", "--interface", "--vcpu"); err == nil {
+		parseDomstats(string(out), &stats)
+	}
+	return stats, nil
+}
+
+// parseDomstats fills stats from `virsh domstats` key=value output. Balloon
+// sizes are reported in KiB.
+func parseDomstats(out string, stats *ContainerStats) {
+	var balloonCurrent, balloonUnused, balloonRSS uint64
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case key == "balloon.current":
+			balloonCurrent = n
+		case key == "balloon.unused":
+			balloonUnused = n
+		case key == "balloon.rss":
+			balloonRSS = n
+		case key == "balloon.maximum":
+			stats.MemoryMaxBytes = n * 1024
+		case key == "vcpu.current":
+			stats.CPUs = int(n)
+		case strings.HasPrefix(key, "block.") && strings.HasSuffix(key, ".rd.bytes"):
+			stats.DiskReadBytes += n
+		case strings.HasPrefix(key, "block.") && strings.HasSuffix(key, ".wr.bytes"):
+			stats.DiskWriteBytes += n
+		case strings.HasPrefix(key, "net.") && strings.HasSuffix(key, ".rx.bytes"):
+			stats.NetInBytes += n
+		case strings.HasPrefix(key, "net.") && strings.HasSuffix(key, ".tx.bytes"):
+			stats.NetOutBytes += n
+		}
+	}
+	switch {
+	case balloonCurrent > 0 && balloonUnused > 0 && balloonUnused <= balloonCurrent:
+		stats.MemoryBytes = (balloonCurrent - balloonUnused) * 1024
+	case balloonRSS > 0:
+		stats.MemoryBytes = balloonRSS * 1024
+	}
 }
 
 func (b *LibvirtBackend) ValidateTemplate(ctx context.Context, templateOrImage string) error {
@@ -0,0 +1,105 @@
+package sandbox
+
+import (
+	"testing"
+)
+
+func TestParseDomstats(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want ContainerStats
+	}{
+		{
+			name: "all groups",
+			out: `Domain: 'agentlab-1001'
+  balloon.current=1048576
+  balloon.maximum=2097152
+  balloon.unused=524288
+  balloon.rss=900000
+  vcpu.current=2
+  vcpu.maximum=4
+  block.count=2
+  block.0.name=vda
+  block.0.rd.reqs=17
+  block.0.rd.bytes=1000
+  block.0.wr.bytes=2000
+  block.0.allocation=99999
+  block.1.rd.bytes=10
+  net.count=1
+  net.0.name=vnet0
+  net.0.rx.bytes=300
+  net.0.rx.pkts=3
+  net.0.tx.bytes=400
+  net.0.tx.drop=1
+`,
+			want: ContainerStats{
+				CPUs:           2,
+				MemoryBytes:    512 << 20,
+				MemoryMaxBytes: 2 << 30,
+				DiskReadBytes:  1010,
+				DiskWriteBytes: 2000,
+				NetInBytes:     300,
+				NetOutBytes:    400,
+			},
+		},
+		{
+			name: "several interfaces",
+			out: `  net.count=3
+  net.0.rx.bytes=100
+  net.0.tx.bytes=1
+  net.1.rx.bytes=200
+  net.1.tx.bytes=2
+  net.2.rx.bytes=300
+  net.2.tx.bytes=3
+`,
+			want: ContainerStats{NetInBytes: 600, NetOutBytes: 6},
+		},
+		{
+			name: "no guest balloon driver falls back to rss",
+			out: `  balloon.current=1048576
+  balloon.maximum=1048576
+  balloon.rss=262144
+`,
+			want: ContainerStats{MemoryBytes: 256 << 20, MemoryMaxBytes: 1 << 30},
+		},
+		{
+			name: "unused larger than current is ignored",
+			out: `  balloon.current=1024
+  balloon.unused=4096
+  balloon.rss=2048
+`,
+			want: ContainerStats{MemoryBytes: 2 << 20},
+		},
+		{
+			name: "missing groups leave counters zero",
+			out: `Domain: 'agentlab-1001'
+  vcpu.current=1
+`,
+			want: ContainerStats{CPUs: 1},
+		},
+		{
+			name: "malformed lines are skipped",
+			out: `  block.0.rd.bytes=abc
+  block.0.wr.bytes=-5
+  net.0.rx.bytes
+  =7
+  block.1.rd.bytes=5
+`,
+			want: ContainerStats{DiskReadBytes: 5},
+		},
+		{
+			name: "empty output",
+			want: ContainerStats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats ContainerStats
+			parseDomstats(tt.out, &stats)
+			if stats != tt.want {
+				t.Errorf("parseDomstats() = %+v, want %+v", stats, tt.want)
+			}
+		})
+	}
+}
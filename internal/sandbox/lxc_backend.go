@@ -276,7 +276,17 @@ func (b *LXCBackend) CurrentStats(ctx context.Context, id int) (ContainerStats,
 		return ContainerStats{}, err
 	}
 	cpu, _ := toFloat64(data["cpu"])
-	return ContainerStats{CPUUsage: cpu}, nil
+	cpus, _ := toFloat64(data["cpus"])
+	return ContainerStats{
+		CPUUsage:       cpu,
+		CPUs:           int(cpus),
+		MemoryBytes:    toUint64(data["mem"]),
+		MemoryMaxBytes: toUint64(data["maxmem"]),
+		DiskReadBytes:  toUint64(data["diskread"]),
+		DiskWriteBytes: toUint64(data["diskwrite"]),
+		NetInBytes:     toUint64(data["netin"]),
+		NetOutBytes:    toUint64(data["netout"]),
+	}, nil
 }
 
 func (b *LXCBackend) ValidateTemplate(ctx context.Context, templateOrImage string) error {
@@ -511,6 +521,15 @@ func toInt(v any) (int, bool) {
 	}
 }
 
+// toUint64 converts a non-negative numeric field, returning 0 when absent.
+func toUint64(v any) uint64 {
+	f, ok := toFloat64(v)
+	if !ok || f < 0 {
+		return 0
+	}
+	return uint64(f)
+}
+
 func toFloat64(v any) (float64, bool) {
 	switch n := v.(type) {
 	case float64:
@@ -54,8 +54,17 @@ const (
 )
 
 // ContainerStats contains runtime statistics for a sandbox.
+// Disk and network byte fields are cumulative counters since the sandbox
+// started; callers compute rates from successive samples.
 type ContainerStats struct {
-	CPUUsage float64 // Fractional CPU usage (0.0-1.0+)
+	CPUUsage       float64 // Fractional CPU usage (0.0-1.0+)
+	CPUs           int     // vCPUs or CPU limit available to the sandbox
+	MemoryBytes    uint64  // Memory in use
+	MemoryMaxBytes uint64  // Memory limit
+	DiskReadBytes  uint64
+	DiskWriteBytes uint64
+	NetInBytes     uint64
+	NetOutBytes    uint64
 }
 
 // ContainerSummary contains basic inventory metadata for a sandbox.
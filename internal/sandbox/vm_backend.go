@@ -123,7 +123,16 @@ func (b *VMBackend) CurrentStats(ctx context.Context, id int) (ContainerStats, e
 	if err != nil {
 		return ContainerStats{}, err
 	}
-	return ContainerStats{CPUUsage: stats.CPUUsage}, nil
+	return ContainerStats{
+		CPUUsage:       stats.CPUUsage,
+		CPUs:           stats.CPUs,
+		MemoryBytes:    stats.MemoryBytes,
+		MemoryMaxBytes: stats.MemoryMaxBytes,
+		DiskReadBytes:  stats.DiskReadBytes,
+		DiskWriteBytes: stats.DiskWriteBytes,
+		NetInBytes:     stats.NetInBytes,
+		NetOutBytes:    stats.NetOutBytes,
+	}, nil
 }
 
 func (b *VMBackend) ValidateTemplate(ctx context.Context, templateOrImage string) error {
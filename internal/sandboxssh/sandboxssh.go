@@ -0,0 +1,116 @@
+// Package sandboxssh dials interactive SSH sessions into sandboxes.
+//
+// It is shared by agentlab-ssh-gateway and the dashboard web terminal so both
+// reach a sandbox the same way: with the operator's sandbox key, and with the
+// sandbox host key pinned per VMID on first use (review M7).
+package sandboxssh
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyPins records the first observed sandbox host key for each VMID
+// (TOFU) and rejects any later differing key, so a host reusing an agent-subnet
+// address cannot impersonate the target sandbox (review M7).
+//
+// Rotation policy: a freshly allocated VMID has no pin, so its first-presented
+// key is recorded. An existing VMID whose VM is recreated must have its pin
+// cleared via Rotate before the new key is accepted; otherwise the mismatch
+// is treated as impersonation and the connection is refused.
+type HostKeyPins struct {
+	mu   sync.Mutex
+	pins map[int]string // vmid -> marshaled public key wire format
+}
+
+// NewHostKeyPins returns an empty pin store.
+func NewHostKeyPins() *HostKeyPins {
+	return &HostKeyPins{pins: make(map[int]string)}
+}
+
+// Callback returns an ssh.HostKeyCallback that enforces the per-VMID pin.
+func (s *HostKeyPins) Callback(vmid int, logger *log.Logger) ssh.HostKeyCallback {
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		wire := string(key.Marshal())
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		existing, ok := s.pins[vmid]
+		if !ok {
+			s.pins[vmid] = wire
+			if logger != nil {
+				logger.Printf("pinning sandbox host key for vmid %d (%s)", vmid, ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}
+		if existing != wire {
+			expected := "unknown"
+			if pub, err := ssh.ParsePublicKey([]byte(existing)); err == nil {
+				expected = ssh.FingerprintSHA256(pub)
+			}
+			return fmt.Errorf("sandbox host key for vmid %d changed: expected %s, got %s (possible impersonation)",
+				vmid, expected, ssh.FingerprintSHA256(key))
+		}
+		return nil
+	}
+}
+
+// Rotate drops the pinned host key for a VMID so a recreated VM may present a
+// fresh key (review M7 rotation policy).
+func (s *HostKeyPins) Rotate(vmid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pins, vmid)
+}
+
+// HasPin reports whether a host key is already pinned for the VMID.
+func (s *HostKeyPins) HasPin(vmid int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pins[vmid]
+	return ok
+}
+
+// Dial dials a sandbox SSH server, enforcing TOFU host-key pinning per VMID
+// (review M7). pins records the first key seen for vmid and rejects any later
+// mismatch. Dial retries every 2 seconds until ctx is done, so a sandbox that
+// is still booting its SSH server is reached once it comes up.
+func Dial(ctx context.Context, ip string, port int, user string, signer ssh.Signer, vmid int, pins *HostKeyPins, logger *log.Logger) (*ssh.Client, error) {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: pins.Callback(vmid, logger),
+		Timeout:         10 * time.Second,
+	}
+	for {
+		client, err := ssh.Dial("tcp", address, config)
+		if err == nil {
+			return client, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("dial sandbox %s: %w", address, err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// LoadSigner reads the private key used to authenticate to sandboxes.
+func LoadSigner(path string) (ssh.Signer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sandbox key path is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
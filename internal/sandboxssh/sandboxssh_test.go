@@ -0,0 +1,77 @@
+package sandboxssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// TestHostKeyPins_TOFUAndRotation proves the per-VMID host-key rotation
+// policy (review M7):
+//   - the first key observed for a VMID is pinned (TOFU),
+//   - a differing key for the same VMID is rejected as impersonation,
+//   - Rotate drops the pin so a recreated VM may present a fresh key,
+//   - pins are independent per VMID.
+func TestHostKeyPins_TOFUAndRotation(t *testing.T) {
+	pins := NewHostKeyPins()
+	logger := log.New(io.Discard, "", 0)
+	vmid := 4011
+
+	keyA := testSSHPublicKey(t)
+	keyB := testSSHPublicKey(t)
+	require.NotEqual(t,
+		ssh.FingerprintSHA256(keyA), ssh.FingerprintSHA256(keyB),
+		"precondition: distinct keys")
+
+	cb := pins.Callback(vmid, logger)
+
+	// First observed key is pinned (TOFU).
+	require.NoError(t, cb("sbx:22", nil, keyA))
+	assert.True(t, pins.HasPin(vmid))
+
+	// A different host presenting a different key is rejected.
+	err := cb("sbx:22", nil, keyB)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "changed")
+
+	// The originally pinned key is still accepted.
+	require.NoError(t, cb("sbx:22", nil, keyA))
+
+	// After Rotate, a recreated VM may present a fresh key.
+	pins.Rotate(vmid)
+	assert.False(t, pins.HasPin(vmid))
+	require.NoError(t, cb("sbx:22", nil, keyB), "post-rotation new key must pin")
+	assert.True(t, pins.HasPin(vmid))
+
+	// And the old key is now the one rejected.
+	require.Error(t, cb("sbx:22", nil, keyA))
+}
+
+// TestHostKeyPins_IndependentPerVMID proves that pinning keyA to one VMID
+// does not constrain a different VMID, which must pin its own first-seen key.
+func TestHostKeyPins_IndependentPerVMID(t *testing.T) {
+	pins := NewHostKeyPins()
+	logger := log.New(io.Discard, "", 0)
+	keyA := testSSHPublicKey(t)
+
+	require.NoError(t, pins.Callback(100, logger)("a:22", nil, keyA))
+	// A different VMID is unpinned; its first key (even keyA) is accepted.
+	require.NoError(t, pins.Callback(200, logger)("b:22", nil, keyA))
+	assert.True(t, pins.HasPin(100))
+	assert.True(t, pins.HasPin(200))
+}
+
+func testSSHPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return sshPub
+}
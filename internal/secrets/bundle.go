@@ -56,6 +56,9 @@ type Bundle struct {
 	Metadata  map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 	SSH       *SSHKeysBundle    `json:"ssh,omitempty" yaml:"ssh,omitempty"`
 	Tailscale *TailscaleBundle  `json:"tailscale,omitempty" yaml:"tailscale,omitempty"`
+	// Policies restrict who may read env and metadata secrets, keyed by
+	// secret name.
+	Policies map[string]SecretPolicy `json:"policies,omitempty" yaml:"policies,omitempty"`
 }
 
 // GitBundle stores git-related credentials.
@@ -237,6 +240,9 @@ type Store struct {
 	SopsAllowlist  []string
 	AllowPlaintext bool
 	SopsDecrypt    func(ctx context.Context, path string, env []string) ([]byte, error)
+	// Resolver resolves provider references (vault:, env:, file:) in bundle
+	// fields on Load. When nil, references are returned as written.
+	Resolver *Resolver
 }
 
 // Load locates, decrypts, and parses the bundle by name or path.
@@ -255,23 +261,64 @@ type Store struct {
 //   - ctx: Context for cancellation
 //   - name: Bundle name, absolute path, or relative path
 //
+// Provider references in bundle fields are resolved through Resolver, when
+// one is set. Every field is resolved, so one unreachable reference fails the
+// load; code that delivers only some fields should use LoadUnresolved and
+// resolve what it delivers with ResolveValue or ResolveBundle.
+//
 // Returns the decrypted bundle or an error if not found or decryption fails.
 func (s Store) Load(ctx context.Context, name string) (Bundle, error) {
+	bundle, err := s.LoadUnresolved(ctx, name)
+	if err != nil {
+		return Bundle{}, err
+	}
+	if s.Resolver == nil {
+		return bundle, nil
+	}
+	resolved, err := s.Resolver.ResolveBundle(ctx, bundle)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("resolve bundle %s: %w", strings.TrimSpace(name), err)
+	}
+	return resolved, nil
+}
+
+// ResolveValue resolves one field of a bundle loaded with LoadUnresolved.
+// field names it in the error, for example env.API_KEY. Without a Resolver
+// the value is returned as written.
+func (s Store) ResolveValue(ctx context.Context, field, value string) (string, error) {
+	if s.Resolver == nil {
+		return value, nil
+	}
+	resolved, err := s.Resolver.Resolve(ctx, value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	return resolved, nil
+}
+
+// ResolveBundle resolves the fields of a bundle loaded with LoadUnresolved.
+// Clear the fields that are not delivered first; empty fields cost nothing.
+func (s Store) ResolveBundle(ctx context.Context, bundle Bundle) (Bundle, error) {
+	if s.Resolver == nil {
+		return bundle, nil
+	}
+	return s.Resolver.ResolveBundle(ctx, bundle)
+}
+
+// LoadUnresolved loads the bundle like Load but leaves provider references
+// as written. Use it when the bundle is written back or shown to an operator.
+func (s Store) LoadUnresolved(ctx context.Context, name string) (Bundle, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return Bundle{}, errors.New("bundle name is required")
 	}
-	path, err := s.resolvePath(name)
-	if err != nil {
-		return Bundle{}, err
-	}
-	payload, err := s.decrypt(ctx, path)
+	payload, err := BundleFileProvider{Store: s}.Fetch(ctx, name)
 	if err != nil {
 		return Bundle{}, err
 	}
 	bundle, err := parseBundle(payload)
 	if err != nil {
-		return Bundle{}, fmt.Errorf("parse bundle %s: %w", path, err)
+		return Bundle{}, fmt.Errorf("parse bundle %s: %w", name, err)
 	}
 	return bundle, nil
 }
@@ -474,6 +521,9 @@ func parseBundle(data []byte) (Bundle, error) {
 	if bundle.Version != BundleVersion {
 		return Bundle{}, fmt.Errorf("unsupported bundle version %d", bundle.Version)
 	}
+	if err := bundle.ValidatePolicies(); err != nil {
+		return Bundle{}, err
+	}
 	return bundle, nil
 }
 
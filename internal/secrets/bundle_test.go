@@ -236,6 +236,43 @@ func TestEncryptAgeRoundTrip(t *testing.T) {
 	}
 }
 
+func TestDecryptAgeRoundTrip(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("generate age identity: %v", err)
+	}
+	keyPath := filepath.Join(tmp, "age.key")
+	if err := osWriteFile(keyPath, []byte(identity.String()+"\n")); err != nil {
+		t.Fatalf("write age key: %v", err)
+	}
+	plaintext := []byte("SQLite format 3\x00payload")
+	encrypted, err := EncryptAge(plaintext, keyPath)
+	if err != nil {
+		t.Fatalf("encrypt age payload: %v", err)
+	}
+	decrypted, err := DecryptAge(encrypted, keyPath)
+	if err != nil {
+		t.Fatalf("decrypt age payload: %v", err)
+	}
+	if string(decrypted) != string(plaintext) {
+		t.Fatalf("decrypted = %q, want %q", decrypted, plaintext)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("generate age identity: %v", err)
+	}
+	otherKey := filepath.Join(tmp, "other.key")
+	if err := osWriteFile(otherKey, []byte(other.String()+"\n")); err != nil {
+		t.Fatalf("write age key: %v", err)
+	}
+	if _, err := DecryptAge(encrypted, otherKey); err == nil {
+		t.Fatalf("expected decrypt with the wrong key to fail")
+	}
+}
+
 func osWriteFile(path string, data []byte) error {
 	return os.WriteFile(path, data, 0o600)
 }
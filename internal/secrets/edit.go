@@ -3,6 +3,7 @@ package secrets
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -24,6 +25,9 @@ func (b Bundle) Normalized() Bundle {
 	if len(out.Metadata) == 0 {
 		out.Metadata = nil
 	}
+	if len(out.Policies) == 0 {
+		out.Policies = nil
+	}
 	if out.SSH != nil && len(out.SSH.Keys) == 0 {
 		out.SSH = nil
 	}
@@ -107,6 +111,30 @@ func EncryptAge(plaintext []byte, keyPath string) ([]byte, error) {
 	return encrypted.Bytes(), nil
 }
 
+// DecryptAge decrypts an age payload with the identities in the configured age key.
+func DecryptAge(ciphertext []byte, keyPath string) ([]byte, error) {
+	if strings.TrimSpace(keyPath) == "" {
+		return nil, fmt.Errorf("age key path is required")
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read age key %s: %w", keyPath, err)
+	}
+	identities, err := parseAgeIdentities(keyData)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age payload: %w", err)
+	}
+	plaintext, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read age payload: %w", err)
+	}
+	return plaintext, nil
+}
+
 func loadAgeRecipients(keyPath string) ([]age.Recipient, error) {
 	if strings.TrimSpace(keyPath) == "" {
 		return nil, fmt.Errorf("age key path is required")
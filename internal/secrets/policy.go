@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecretPolicy restricts which sandboxes may read a named secret and whether
+// a read needs human approval.
+//
+// Each non-empty list must match the reader; within a list any entry may
+// match. Entries are path.Match patterns, so "team-*" matches every team tag.
+// A secret without a policy is readable by every sandbox, as before.
+type SecretPolicy struct {
+	Profiles        []string `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	Tags            []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Owners          []string `json:"owners,omitempty" yaml:"owners,omitempty"`
+	Jobs            []string `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	RequireApproval bool     `json:"require_approval,omitempty" yaml:"require_approval,omitempty"`
+	GrantTTL        string   `json:"grant_ttl,omitempty" yaml:"grant_ttl,omitempty"`
+}
+
+// SecretSubject describes the sandbox reading a secret.
+type SecretSubject struct {
+	Profile string
+	Tags    []string
+	Owner   string
+	JobID   string
+}
+
+// Allows reports whether subject matches every constraint of the policy.
+// Approval is checked separately.
+func (p SecretPolicy) Allows(subject SecretSubject) bool {
+	if len(p.Profiles) > 0 && !matchAny(p.Profiles, subject.Profile) {
+		return false
+	}
+	if len(p.Owners) > 0 && !matchAny(p.Owners, subject.Owner) {
+		return false
+	}
+	if len(p.Jobs) > 0 && !matchAny(p.Jobs, subject.JobID) {
+		return false
+	}
+	if len(p.Tags) > 0 {
+		matched := false
+		for _, tag := range subject.Tags {
+			if matchAny(p.Tags, tag) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// GrantDuration returns the parsed grant_ttl, or zero when unset.
+func (p SecretPolicy) GrantDuration() (time.Duration, error) {
+	raw := strings.TrimSpace(p.GrantTTL)
+	if raw == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("grant_ttl: %w", err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("grant_ttl must be positive")
+	}
+	return ttl, nil
+}
+
+// Validate checks patterns and the grant TTL.
+func (p SecretPolicy) Validate() error {
+	for _, list := range [][]string{p.Profiles, p.Tags, p.Owners, p.Jobs} {
+		for _, pattern := range list {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("empty pattern")
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	_, err := p.GrantDuration()
+	return err
+}
+
+// PolicyFor returns the policy for the named secret, if any.
+func (b Bundle) PolicyFor(name string) (SecretPolicy, bool) {
+	policy, ok := b.Policies[name]
+	return policy, ok
+}
+
+// ValidatePolicies checks every policy in the bundle.
+func (b Bundle) ValidatePolicies() error {
+	names := make([]string, 0, len(b.Policies))
+	for name := range b.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := b.Policies[name].Validate(); err != nil {
+			return fmt.Errorf("policies.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SplitTags splits a comma-separated sandbox tag list.
+func SplitTags(tags string) []string {
+	var out []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func matchAny(patterns []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,507 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reference schemes understood by the default resolver.
+const (
+	SchemeVault = "vault"
+	SchemeEnv   = "env"
+	SchemeFile  = "file"
+	// SchemeLiteral escapes a value that would otherwise parse as a
+	// reference: literal:env:FOO resolves to the string env:FOO.
+	SchemeLiteral = "literal"
+)
+
+// maxProviderResponseBytes caps the size of a secret fetched from a provider.
+const maxProviderResponseBytes = 1 << 20
+
+// SecretProvider fetches raw secret material by path.
+//
+// The meaning of path depends on the provider: a bundle name for file
+// bundles, a KV v2 API path for Vault, a variable name for the environment,
+// or a file path for file references.
+type SecretProvider interface {
+	Fetch(ctx context.Context, path string) ([]byte, error)
+}
+
+// BundleFileProvider fetches decrypted bundle payloads from the store's
+// secrets directory. It is the provider behind Store.Load.
+type BundleFileProvider struct {
+	Store Store
+}
+
+// Fetch resolves the bundle name and returns its decrypted payload.
+func (p BundleFileProvider) Fetch(ctx context.Context, name string) ([]byte, error) {
+	path, err := p.Store.ResolvePath(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.Store.decrypt(ctx, path)
+}
+
+// EnvProvider fetches secrets from the daemon's environment variables. Only
+// the variables listed in Allow can be read, so a bundle writer cannot reach
+// the rest of the daemon's environment.
+type EnvProvider struct {
+	Allow []string
+	// Lookup overrides os.LookupEnv, mainly for tests.
+	Lookup func(key string) (string, bool)
+}
+
+// Fetch returns the value of the named environment variable.
+func (p EnvProvider) Fetch(ctx context.Context, name string) ([]byte, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("env reference requires a variable name")
+	}
+	if !slices.Contains(p.Allow, name) {
+		return nil, fmt.Errorf("env %s is not in the env reference allowlist", name)
+	}
+	lookup := p.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	value, ok := lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("env %s not found", name)
+	}
+	return []byte(value), nil
+}
+
+// FileProvider fetches secrets from files below Dir. Paths must be relative
+// to Dir; absolute paths and paths that leave Dir, directly or through a
+// symlink, are refused.
+type FileProvider struct {
+	Dir string
+}
+
+// Fetch returns the contents of the referenced file.
+func (p FileProvider) Fetch(ctx context.Context, path string) ([]byte, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, errors.New("file reference requires a path")
+	}
+	if strings.TrimSpace(p.Dir) == "" {
+		return nil, errors.New("file references have no directory configured")
+	}
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("file reference %s must be a relative path inside the secrets directory", path)
+	}
+	path = filepath.Join(p.Dir, path)
+	if err := p.checkWithinDir(path); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("secret file %s not found", path)
+		}
+		return nil, fmt.Errorf("read secret file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// checkWithinDir refuses path when a symlink resolves it outside Dir. A path
+// that does not exist is left for the read to report.
+func (p FileProvider) checkWithinDir(path string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil
+	}
+	root, err := filepath.EvalSymlinks(p.Dir)
+	if err != nil {
+		root = filepath.Clean(p.Dir)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("file reference %s resolves outside the secrets directory", path)
+	}
+	return nil
+}
+
+// VaultProvider fetches secrets from a Vault-compatible KV v2 engine.
+//
+// Paths are API paths below /v1, such as kv/data/agent. Fetch returns the
+// secret's data object encoded as JSON.
+type VaultProvider struct {
+	Addr      string
+	Token     string
+	TokenPath string
+	Namespace string
+	Client    *http.Client
+}
+
+type vaultKVResponse struct {
+	Data struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Fetch reads a KV v2 secret and returns its data object as JSON.
+func (p VaultProvider) Fetch(ctx context.Context, path string) ([]byte, error) {
+	addr := strings.TrimRight(strings.TrimSpace(p.Addr), "/")
+	if addr == "" {
+		return nil, errors.New("vault address is not configured")
+	}
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return nil, errors.New("vault reference requires a path")
+	}
+	token, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := url.JoinPath(addr, "v1", path)
+	if err != nil {
+		return nil, fmt.Errorf("build vault url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("X-Vault-Request", "true")
+	if ns := strings.TrimSpace(p.Namespace); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault read %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProviderResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("vault read %s: %w", path, err)
+	}
+	var decoded vaultKVResponse
+	_ = json.Unmarshal(body, &decoded)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("vault secret %s not found", path)
+	case resp.StatusCode != http.StatusOK:
+		if len(decoded.Errors) > 0 {
+			return nil, fmt.Errorf("vault read %s: status %d: %s", path, resp.StatusCode, strings.Join(decoded.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault read %s: status %d", path, resp.StatusCode)
+	}
+	if decoded.Data.Data == nil {
+		return nil, fmt.Errorf("vault read %s: response has no data (is this a KV v2 path?)", path)
+	}
+	data, err := json.Marshal(decoded.Data.Data)
+	if err != nil {
+		return nil, fmt.Errorf("encode vault secret %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// token returns the configured token, re-reading TokenPath on every call so
+// a renewed token file takes effect without a restart.
+func (p VaultProvider) token() (string, error) {
+	if token := strings.TrimSpace(p.Token); token != "" {
+		return token, nil
+	}
+	if strings.TrimSpace(p.TokenPath) == "" {
+		return "", errors.New("vault token is not configured")
+	}
+	data, err := os.ReadFile(p.TokenPath)
+	if err != nil {
+		return "", fmt.Errorf("read vault token %s: %w", p.TokenPath, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("vault token file %s is empty", p.TokenPath)
+	}
+	return token, nil
+}
+
+// Reference points a bundle field at a secret held by a provider, written as
+// scheme:path#field (for example vault:kv/data/agent#anthropic_key).
+type Reference struct {
+	Scheme string
+	Path   string
+	Field  string
+}
+
+// String renders the reference in scheme:path#field form.
+func (r Reference) String() string {
+	if r.Field == "" {
+		return r.Scheme + ":" + r.Path
+	}
+	return r.Scheme + ":" + r.Path + "#" + r.Field
+}
+
+// ParseReference parses value as a provider reference. The boolean is false
+// when value does not start with a known scheme and should be used verbatim.
+func ParseReference(value string) (Reference, bool) {
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return Reference{}, false
+	}
+	switch scheme {
+	case SchemeLiteral:
+		// The escaped value is kept whole, including any '#'.
+		_, rest, _ = strings.Cut(value, ":")
+		return Reference{Scheme: scheme, Path: rest}, true
+	case SchemeVault, SchemeEnv, SchemeFile:
+	default:
+		return Reference{}, false
+	}
+	ref := Reference{Scheme: scheme, Path: rest}
+	if path, field, ok := strings.Cut(rest, "#"); ok {
+		ref.Path = path
+		ref.Field = field
+	}
+	return ref, true
+}
+
+type cachedSecret struct {
+	data    []byte
+	expires time.Time
+}
+
+// Resolver resolves provider references in bundle fields.
+//
+// Fetched secrets are cached per scheme and path for TTL, so several fields
+// that reference the same Vault secret cost one request. A zero TTL disables
+// caching. A Resolver is safe for concurrent use.
+type Resolver struct {
+	TTL time.Duration
+
+	mu        sync.Mutex
+	providers map[string]SecretProvider
+	cache     map[string]cachedSecret
+	now       func() time.Time
+}
+
+// NewResolver returns a resolver with no providers registered.
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{
+		TTL:       ttl,
+		providers: make(map[string]SecretProvider),
+		cache:     make(map[string]cachedSecret),
+		now:       time.Now,
+	}
+}
+
+// Register installs provider for references with the given scheme.
+func (r *Resolver) Register(scheme string, provider SecretProvider) *Resolver {
+	if r == nil || provider == nil {
+		return r
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[scheme] = provider
+	return r
+}
+
+// Purge drops every cached secret.
+func (r *Resolver) Purge() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = make(map[string]cachedSecret)
+}
+
+// Resolve returns the secret value for value when it is a reference, or value
+// unchanged otherwise. A literal: prefix is stripped. A reference whose scheme
+// has no provider registered is used as written, so a plain value saved
+// before references existed keeps working on hosts that do not use them. A
+// nil Resolver leaves every reference as written.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok := ParseReference(value)
+	if !ok {
+		return value, nil
+	}
+	if ref.Scheme == SchemeLiteral {
+		return ref.Path, nil
+	}
+	if !r.registered(ref.Scheme) {
+		return value, nil
+	}
+	data, err := r.fetch(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	resolved, err := selectField(ref, data)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	return resolved, nil
+}
+
+// registered reports whether a provider handles scheme.
+func (r *Resolver) registered(scheme string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.providers[scheme]
+	return ok
+}
+
+func (r *Resolver) fetch(ctx context.Context, ref Reference) ([]byte, error) {
+	key := ref.Scheme + ":" + ref.Path
+	r.mu.Lock()
+	provider, ok := r.providers[ref.Scheme]
+	cached, hit := r.cache[key]
+	now := r.now()
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no secret provider configured for %q references", ref.Scheme)
+	}
+	if hit && now.Before(cached.expires) {
+		return cached.data, nil
+	}
+	data, err := provider.Fetch(ctx, ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	if r.TTL > 0 {
+		r.mu.Lock()
+		r.cache[key] = cachedSecret{data: data, expires: now.Add(r.TTL)}
+		r.mu.Unlock()
+	}
+	return data, nil
+}
+
+// selectField extracts the referenced field from a fetched secret. Without a
+// field, a single-key object yields its only value and anything else is used
+// whole, minus a trailing newline.
+func selectField(ref Reference, data []byte) (string, error) {
+	if ref.Field == "" {
+		if ref.Scheme == SchemeVault {
+			fields, err := decodeSecretFields(data)
+			if err != nil {
+				return "", err
+			}
+			if len(fields) != 1 {
+				return "", fmt.Errorf("secret has %d fields; add #field to pick one", len(fields))
+			}
+			for _, value := range fields {
+				return value, nil
+			}
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	fields, err := decodeSecretFields(data)
+	if err != nil {
+		return "", err
+	}
+	value, ok := fields[ref.Field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found (have %s)", ref.Field, strings.Join(sortedKeys(fields), ", "))
+	}
+	return value, nil
+}
+
+// decodeSecretFields parses a JSON or YAML object into string fields.
+func decodeSecretFields(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON or YAML object: %w", err)
+	}
+	if raw == nil {
+		return nil, errors.New("secret is not a JSON or YAML object")
+	}
+	fields := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			fields[key] = v
+		case nil:
+			fields[key] = ""
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("encode field %q: %w", key, err)
+			}
+			fields[key] = string(encoded)
+		}
+	}
+	return fields, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ResolveBundle returns a copy of bundle with every reference in a secret
+// field replaced by its value. The input bundle is not modified. Callers that
+// deliver only part of a bundle should clear the rest first, so a reference
+// they do not deliver is never fetched.
+func (r *Resolver) ResolveBundle(ctx context.Context, bundle Bundle) (Bundle, error) {
+	out := bundle
+	var firstErr error
+	resolve := func(field, value string) string {
+		if firstErr != nil {
+			return value
+		}
+		resolved, err := r.Resolve(ctx, value)
+		if err != nil {
+			firstErr = fmt.Errorf("%s: %w", field, err)
+			return value
+		}
+		return resolved
+	}
+	resolveMap := func(section string, in map[string]string) map[string]string {
+		if in == nil {
+			return nil
+		}
+		resolved := make(map[string]string, len(in))
+		for _, key := range sortedKeys(in) {
+			resolved[key] = resolve(section+"."+key, in[key])
+		}
+		return resolved
+	}
+
+	out.Git.Token = resolve("git.token", out.Git.Token)
+	out.Git.Username = resolve("git.username", out.Git.Username)
+	out.Git.SSHPrivateKey = resolve("git.ssh_private_key", out.Git.SSHPrivateKey)
+	out.Git.SSHPublicKey = resolve("git.ssh_public_key", out.Git.SSHPublicKey)
+	out.Git.KnownHosts = resolve("git.known_hosts", out.Git.KnownHosts)
+	out.Env = resolveMap("env", bundle.Env)
+	out.Metadata = resolveMap("metadata", bundle.Metadata)
+	out.Claude.SettingsJSON = resolve("claude.settings_json", out.Claude.SettingsJSON)
+	out.Artifact.Endpoint = resolve("artifact.endpoint", out.Artifact.Endpoint)
+	out.Artifact.Token = resolve("artifact.token", out.Artifact.Token)
+	if bundle.Tailscale != nil {
+		tailscale := *bundle.Tailscale
+		tailscale.AuthKey = resolve("tailscale.authkey", tailscale.AuthKey)
+		tailscale.AdminAPIKey = resolve("tailscale.admin_api_key", tailscale.AdminAPIKey)
+		out.Tailscale = &tailscale
+	}
+	if firstErr != nil {
+		return Bundle{}, firstErr
+	}
+	return out, nil
+}
@@ -0,0 +1,290 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newVaultStandIn serves KV v2 reads for the given secrets and counts requests.
+func newVaultStandIn(t *testing.T, token string, data map[string]map[string]any, hits *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		secret, ok := data[strings.TrimPrefix(r.URL.Path, "/v1/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     secret,
+				"metadata": map[string]any{"version": 3},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		value string
+		want  Reference
+		ok    bool
+	}{
+		{"vault:kv/data/agent#anthropic_key", Reference{Scheme: SchemeVault, Path: "kv/data/agent", Field: "anthropic_key"}, true},
+		{"env:GITHUB_TOKEN", Reference{Scheme: SchemeEnv, Path: "GITHUB_TOKEN"}, true},
+		{"file:/run/secrets/creds.json#token", Reference{Scheme: SchemeFile, Path: "/run/secrets/creds.json", Field: "token"}, true},
+		{"literal:vault:kv/data/agent#key", Reference{Scheme: SchemeLiteral, Path: "vault:kv/data/agent#key"}, true},
+		{"ghp_plainvalue", Reference{}, false},
+		{"https://10.77.0.1:8846/upload", Reference{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := ParseReference(tc.value)
+		if ok != tc.ok || got != tc.want {
+			t.Fatalf("ParseReference(%q) = %+v, %v; want %+v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+		if ok && got.String() != tc.value {
+			t.Fatalf("String() = %q, want %q", got.String(), tc.value)
+		}
+	}
+}
+
+func TestVaultProviderFetch(t *testing.T) {
+	t.Parallel()
+	hits := 0
+	server := newVaultStandIn(t, "s.test", map[string]map[string]any{
+		"kv/data/agent": {"anthropic_key": "sk-ant-vault", "retries": 3},
+	}, &hits)
+	tokenPath := filepath.Join(t.TempDir(), "vault-token")
+	if err := os.WriteFile(tokenPath, []byte("s.test\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	provider := VaultProvider{Addr: server.URL + "/", TokenPath: tokenPath}
+
+	data, err := provider.Fetch(context.Background(), "kv/data/agent")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if fields["anthropic_key"] != "sk-ant-vault" {
+		t.Fatalf("fields = %v", fields)
+	}
+
+	if _, err := provider.Fetch(context.Background(), "kv/data/missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("missing secret error = %v", err)
+	}
+	denied := VaultProvider{Addr: server.URL, Token: "wrong"}
+	if _, err := denied.Fetch(context.Background(), "kv/data/agent"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("denied error = %v", err)
+	}
+	if _, err := (VaultProvider{Addr: server.URL}).Fetch(context.Background(), "kv/data/agent"); err == nil {
+		t.Fatalf("expected error without a token")
+	}
+}
+
+func TestHostProvidersStayConfined(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	root := t.TempDir()
+	dir := filepath.Join(root, "secrets")
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for path, body := range map[string]string{
+		filepath.Join(dir, "nested", "token"): "inside\n",
+		filepath.Join(root, "age.key"):        "outside\n",
+	} {
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "age.key"), filepath.Join(dir, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	files := FileProvider{Dir: dir}
+	if got, err := files.Fetch(ctx, "nested/token"); err != nil || string(got) != "inside\n" {
+		t.Fatalf("relative file = %q, %v", got, err)
+	}
+	for _, path := range []string{filepath.Join(root, "age.key"), "../age.key", "nested/../../age.key", "link"} {
+		if _, err := files.Fetch(ctx, path); err == nil {
+			t.Fatalf("file reference %q was read", path)
+		}
+	}
+	if _, err := (FileProvider{}).Fetch(ctx, "nested/token"); err == nil {
+		t.Fatalf("file reference without a directory was read")
+	}
+
+	env := EnvProvider{Allow: []string{"AGENT_TOKEN"}, Lookup: func(key string) (string, bool) { return "value-of-" + key, true }}
+	if got, err := env.Fetch(ctx, "AGENT_TOKEN"); err != nil || string(got) != "value-of-AGENT_TOKEN" {
+		t.Fatalf("allowlisted env = %q, %v", got, err)
+	}
+	if _, err := env.Fetch(ctx, "VAULT_TOKEN"); err == nil {
+		t.Fatalf("env reference outside the allowlist was read")
+	}
+}
+
+func TestResolverCachesWithTTL(t *testing.T) {
+	t.Parallel()
+	hits := 0
+	server := newVaultStandIn(t, "s.test", map[string]map[string]any{
+		"kv/data/agent": {"anthropic_key": "sk-ant-vault", "openai_key": "sk-openai"},
+	}, &hits)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	resolver := NewResolver(time.Minute).Register(SchemeVault, VaultProvider{Addr: server.URL, Token: "s.test"})
+	resolver.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, tc := range []struct{ ref, want string }{
+		{"vault:kv/data/agent#anthropic_key", "sk-ant-vault"},
+		{"vault:kv/data/agent#openai_key", "sk-openai"},
+	} {
+		got, err := resolver.Resolve(ctx, tc.ref)
+		if err != nil {
+			t.Fatalf("resolve %s: %v", tc.ref, err)
+		}
+		if got != tc.want {
+			t.Fatalf("resolve %s = %q, want %q", tc.ref, got, tc.want)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("vault requests = %d, want 1 (cached)", hits)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := resolver.Resolve(ctx, "vault:kv/data/agent#anthropic_key"); err != nil {
+		t.Fatalf("resolve after ttl: %v", err)
+	}
+	if hits != 2 {
+		t.Fatalf("vault requests after ttl = %d, want 2", hits)
+	}
+	resolver.Purge()
+	if _, err := resolver.Resolve(ctx, "vault:kv/data/agent#anthropic_key"); err != nil {
+		t.Fatalf("resolve after purge: %v", err)
+	}
+	if hits != 3 {
+		t.Fatalf("vault requests after purge = %d, want 3", hits)
+	}
+
+	if _, err := resolver.Resolve(ctx, "vault:kv/data/agent"); err == nil || !strings.Contains(err.Error(), "#field") {
+		t.Fatalf("multi-field reference without #field error = %v", err)
+	}
+	if _, err := resolver.Resolve(ctx, "vault:kv/data/agent#nope"); err == nil || !strings.Contains(err.Error(), "anthropic_key, openai_key") {
+		t.Fatalf("unknown field error = %v", err)
+	}
+	// Plain values from before references existed pass through on hosts
+	// that never configured the matching provider.
+	for _, value := range []string{"env:prod", "file:report.txt", "env:HOME#x"} {
+		if got, err := resolver.Resolve(ctx, value); err != nil || got != value {
+			t.Fatalf("unregistered scheme %q = %q, %v; want it verbatim", value, got, err)
+		}
+	}
+	if got, err := resolver.Resolve(ctx, "plain-value"); err != nil || got != "plain-value" {
+		t.Fatalf("plain value = %q, %v", got, err)
+	}
+	if got, err := resolver.Resolve(ctx, "literal:vault:kv/data/agent#key"); err != nil || got != "vault:kv/data/agent#key" {
+		t.Fatalf("escaped value = %q, %v", got, err)
+	}
+	var unset *Resolver
+	if got, err := unset.Resolve(ctx, "vault:kv/data/agent#key"); err != nil || got != "vault:kv/data/agent#key" {
+		t.Fatalf("nil resolver = %q, %v", got, err)
+	}
+}
+
+func TestStoreLoadResolvesReferences(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	hits := 0
+	server := newVaultStandIn(t, "s.test", map[string]map[string]any{
+		"kv/data/agent": {"anthropic_key": "sk-ant-vault"},
+	}, &hits)
+	if err := os.WriteFile(filepath.Join(dir, "known_hosts"), []byte("github.com ssh-ed25519 AAAA\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+	bundleYAML := `version: 1
+git:
+  token: env:TEST_GIT_TOKEN
+  known_hosts: file:known_hosts
+env:
+  ANTHROPIC_API_KEY: vault:kv/data/agent#anthropic_key
+  PLAIN: value
+`
+	if err := os.WriteFile(filepath.Join(dir, "default.yaml"), []byte(bundleYAML), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	resolver := NewResolver(time.Minute).
+		Register(SchemeVault, VaultProvider{Addr: server.URL, Token: "s.test"}).
+		Register(SchemeEnv, EnvProvider{Allow: []string{"TEST_GIT_TOKEN"}, Lookup: func(key string) (string, bool) {
+			if key == "TEST_GIT_TOKEN" {
+				return "ghp_env", true
+			}
+			return "", false
+		}}).
+		Register(SchemeFile, FileProvider{Dir: dir})
+	store := Store{Dir: dir, AllowPlaintext: true, Resolver: resolver}
+	ctx := context.Background()
+
+	bundle, err := store.Load(ctx, "default")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if bundle.Env["ANTHROPIC_API_KEY"] != "sk-ant-vault" || bundle.Env["PLAIN"] != "value" {
+		t.Fatalf("env = %v", bundle.Env)
+	}
+	if bundle.Git.Token != "ghp_env" || bundle.Git.KnownHosts != "github.com ssh-ed25519 AAAA" {
+		t.Fatalf("git = %+v", bundle.Git)
+	}
+
+	raw, err := store.LoadUnresolved(ctx, "default")
+	if err != nil {
+		t.Fatalf("load unresolved: %v", err)
+	}
+	if raw.Env["ANTHROPIC_API_KEY"] != "vault:kv/data/agent#anthropic_key" {
+		t.Fatalf("unresolved env = %v", raw.Env)
+	}
+
+	// Writing the bundle back keeps references rather than resolved values.
+	if _, _, err := store.Mutate(ctx, "default", func(b *Bundle) error {
+		b.Env["EXTRA"] = "1"
+		return nil
+	}); err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	written, err := os.ReadFile(filepath.Join(dir, "default.yaml"))
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	if strings.Contains(string(written), "sk-ant-vault") || !strings.Contains(string(written), "vault:kv/data/agent#anthropic_key") {
+		t.Fatalf("written bundle = %s", written)
+	}
+
+	unset := EnvProvider{Allow: []string{"TEST_GIT_TOKEN"}, Lookup: func(string) (string, bool) { return "", false }}
+	broken := Store{Dir: dir, AllowPlaintext: true, Resolver: NewResolver(0).Register(SchemeEnv, unset)}
+	if _, err := broken.Load(ctx, "default"); err == nil || !strings.Contains(err.Error(), "git.token") {
+		t.Fatalf("unresolvable reference error = %v", err)
+	}
+
+	// Without any provider configured, the bundle loads as written.
+	plain, err := Store{Dir: dir, AllowPlaintext: true, Resolver: NewResolver(0)}.Load(ctx, "default")
+	if err != nil || plain.Git.Token != "env:TEST_GIT_TOKEN" || plain.Env["ANTHROPIC_API_KEY"] != "vault:kv/data/agent#anthropic_key" {
+		t.Fatalf("load without providers = %+v, %v", plain, err)
+	}
+}
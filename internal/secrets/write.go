@@ -54,7 +54,8 @@ func (s Store) loadForMutation(ctx context.Context, name string) (Bundle, string
 		}
 		return Bundle{Version: BundleVersion}, path, nil
 	}
-	bundle, err := s.Load(ctx, name)
+	// Keep provider references intact so they are written back as references.
+	bundle, err := s.LoadUnresolved(ctx, name)
 	if err != nil {
 		return Bundle{}, "", err
 	}
@@ -0,0 +1,158 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// GiteaPullRequest is a pull request recorded by GiteaServer.
+type GiteaPullRequest struct {
+	Owner  string `json:"-"`
+	Repo   string `json:"-"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Head   string `json:"head"`
+	Base   string `json:"base"`
+	Body   string `json:"body"`
+}
+
+// GiteaServer is a local stand-in for a Gitea host. It serves git smart HTTP
+// (clone, fetch, push) through git-http-backend and the pull request creation
+// endpoint of the Gitea v1 API. Git requests require Basic auth with the
+// configured user and token; API requests require "Authorization: token".
+type GiteaServer struct {
+	*httptest.Server
+	Root  string
+	User  string
+	Token string
+
+	mu    sync.Mutex
+	pulls []GiteaPullRequest
+}
+
+// NewGiteaServer starts a GiteaServer. The test is skipped when git or
+// git-http-backend is unavailable.
+func NewGiteaServer(t testing.TB, user, token string) *GiteaServer {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skipf("git --exec-path: %v", err)
+	}
+	backend := filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git-http-backend not available")
+	}
+	s := &GiteaServer{Root: t.TempDir(), User: user, Token: token}
+	git := &cgi.Handler{
+		Path: backend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + s.Root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/repos/", s.handlePulls)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.User || password != s.Token {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gitea"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.Header.Del("Authorization")
+		git.ServeHTTP(w, r)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// CreateRepo creates owner/name with one commit on main and returns its clone
+// URL.
+func (s *GiteaServer) CreateRepo(t testing.TB, path string) string {
+	t.Helper()
+	bare := filepath.Join(s.Root, path+".git")
+	work := t.TempDir()
+	runGit(t, "", "init", "--quiet", "--bare", "-b", "main", bare)
+	runGit(t, bare, "config", "http.receivepack", "true")
+	runGit(t, "", "init", "--quiet", "-b", "main", work)
+	if err := os.WriteFile(filepath.Join(work, "README.md"), []byte("# "+path+"\n"), 0o644); err != nil {
+		t.Fatalf("write README: %v", err)
+	}
+	runGit(t, work, "add", "README.md")
+	runGit(t, work, "commit", "--quiet", "-m", "initial")
+	runGit(t, work, "push", "--quiet", bare, "main")
+	return s.URL + "/" + path + ".git"
+}
+
+// BranchCommit returns the commit a branch of owner/name points at.
+func (s *GiteaServer) BranchCommit(t testing.TB, path, branch string) string {
+	t.Helper()
+	return runGit(t, filepath.Join(s.Root, path+".git"), "rev-parse", "refs/heads/"+branch)
+}
+
+// PullRequests returns the pull requests opened so far.
+func (s *GiteaServer) PullRequests() []GiteaPullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]GiteaPullRequest(nil), s.pulls...)
+}
+
+func (s *GiteaServer) handlePulls(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/repos/"), "/")
+	if len(parts) != 3 || parts[2] != "pulls" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "token "+s.Token {
+		http.Error(w, `{"message":"token is required"}`, http.StatusUnauthorized)
+		return
+	}
+	var pr GiteaPullRequest
+	if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+		http.Error(w, `{"message":"invalid body"}`, http.StatusUnprocessableEntity)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(s.Root, parts[0], parts[1]+".git")); err != nil {
+		http.Error(w, `{"message":"repository not found"}`, http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	pr.Owner, pr.Repo = parts[0], parts[1]
+	pr.Number = len(s.pulls) + 1
+	s.pulls = append(s.pulls, pr)
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"number":   pr.Number,
+		"html_url": fmt.Sprintf("%s/%s/%s/pulls/%d", s.URL, pr.Owner, pr.Repo, pr.Number),
+	})
+}
+
+func runGit(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=agentlab", "GIT_AUTHOR_EMAIL=agentlab@example.com",
+		"GIT_COMMITTER_NAME=agentlab", "GIT_COMMITTER_EMAIL=agentlab@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
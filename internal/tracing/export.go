@@ -0,0 +1,337 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultServiceName   = "agentlabd"
+	defaultFlushInterval = 5 * time.Second
+	defaultBatchSize     = 256
+	defaultMaxQueue      = 4096
+	defaultExportTimeout = 10 * time.Second
+	otlpTracesPath       = "/v1/traces"
+	instrumentationScope = "github.com/agentlab/agentlab"
+)
+
+// Config configures a Tracer.
+type Config struct {
+	// Endpoint is the collector's OTLP/HTTP base URL, such as
+	// http://127.0.0.1:4318. /v1/traces is appended unless the URL already
+	// ends with it.
+	Endpoint string
+	// Headers are sent with every export, for collectors that need auth.
+	Headers map[string]string
+	// ServiceName defaults to agentlabd.
+	ServiceName    string
+	ServiceVersion string
+	// FlushInterval is how often queued spans are exported. Default 5s.
+	FlushInterval time.Duration
+	// BatchSize exports early once this many spans are queued. Default 256.
+	BatchSize int
+	Client    *http.Client
+	Logger    *log.Logger
+}
+
+// Tracer queues finished spans and exports them in batches.
+type Tracer struct {
+	url           string
+	headers       map[string]string
+	resource      otlpResource
+	client        *http.Client
+	logger        *log.Logger
+	flushInterval time.Duration
+	batchSize     int
+	maxQueue      int
+	now           func() time.Time
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// New validates cfg and starts a Tracer's export loop. Call Shutdown to
+// flush and stop it.
+func New(cfg Config) (*Tracer, error) {
+	endpoint := strings.TrimSpace(cfg.Endpoint)
+	if endpoint == "" {
+		return nil, errors.New("tracing endpoint is required")
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("tracing endpoint must be an http(s) URL: %q", endpoint)
+	}
+	if !strings.HasSuffix(parsed.Path, otlpTracesPath) {
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/") + otlpTracesPath
+	}
+	service := strings.TrimSpace(cfg.ServiceName)
+	if service == "" {
+		service = defaultServiceName
+	}
+	attrs := []otlpKeyValue{keyValue(String("service.name", service))}
+	if v := strings.TrimSpace(cfg.ServiceVersion); v != "" {
+		attrs = append(attrs, keyValue(String("service.version", v)))
+	}
+	t := &Tracer{
+		url:           parsed.String(),
+		headers:       cfg.Headers,
+		resource:      otlpResource{Attributes: attrs},
+		client:        cfg.Client,
+		logger:        cfg.Logger,
+		flushInterval: cfg.FlushInterval,
+		batchSize:     cfg.BatchSize,
+		maxQueue:      defaultMaxQueue,
+		now:           time.Now,
+		kick:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if t.client == nil {
+		t.client = &http.Client{Timeout: defaultExportTimeout}
+	}
+	if t.logger == nil {
+		t.logger = log.Default()
+	}
+	if t.flushInterval <= 0 {
+		t.flushInterval = defaultFlushInterval
+	}
+	if t.batchSize <= 0 {
+		t.batchSize = defaultBatchSize
+	}
+	go t.loop()
+	return t, nil
+}
+
+// Shutdown exports every queued span and stops the export loop.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.once.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.flush(ctx)
+}
+
+// ForceFlush exports every queued span now.
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.flush(ctx)
+}
+
+func (t *Tracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		case <-t.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), defaultExportTimeout)
+		if err := t.flush(ctx); err != nil {
+			t.logger.Printf("tracing: export spans: %v", err)
+		}
+		cancel()
+	}
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	if len(t.queue) >= t.maxQueue {
+		t.dropped++
+		t.mu.Unlock()
+		return
+	}
+	t.queue = append(t.queue, span)
+	full := len(t.queue) >= t.batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.queue
+	t.queue = nil
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		t.logger.Printf("tracing: dropped %d spans because the export queue was full", dropped)
+	}
+	for len(spans) > 0 {
+		n := min(len(spans), t.batchSize)
+		if err := t.export(ctx, spans[:n]); err != nil {
+			return err
+		}
+		spans = spans[n:]
+	}
+	return nil
+}
+
+func (t *Tracer) export(ctx context.Context, spans []*Span) error {
+	req := otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: t.resource,
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: make([]otlpSpan, 0, len(spans)),
+		}},
+	}}}
+	for _, span := range spans {
+		req.ResourceSpans[0].ScopeSpans[0].Spans = append(req.ResourceSpans[0].ScopeSpans[0].Spans, span.otlp())
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		httpReq.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON wire types. Trace and span IDs are hex, and 64-bit integers are
+// decimal strings, as the OTLP JSON encoding requires.
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpStatusError is STATUS_CODE_ERROR. Spans without an error leave the
+// status unset.
+const otlpStatusError = 2
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := otlpSpan{
+		TraceID:           s.sc.TraceID.String(),
+		SpanID:            s.sc.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parent.IsValid() {
+		out.ParentSpanID = s.parent.String()
+	}
+	for _, attr := range s.attrs {
+		out.Attributes = append(out.Attributes, keyValue(attr))
+	}
+	if s.failed {
+		out.Status = otlpStatus{Code: otlpStatusError, Message: s.errorMsg}
+	}
+	return out
+}
+
+func keyValue(attr Attribute) otlpKeyValue {
+	kv := otlpKeyValue{Key: attr.Key}
+	switch v := attr.Value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		kv.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
@@ -0,0 +1,284 @@
+// Package tracing records spans for agentlabd and exports them to an
+// OpenTelemetry collector over OTLP/HTTP with JSON encoding.
+//
+// The package keeps one process-wide Tracer, set with SetTracer. Until one is
+// set, Start returns a nil *Span and leaves the context unchanged, so callers
+// can instrument code unconditionally. All *Span methods are nil-safe.
+//
+// Span context travels in context.Context. Work that resumes a trace later,
+// such as a runner report for a job provisioned minutes earlier, stores the
+// W3C traceparent of the earlier span and continues from it with
+// ContextWithRemote.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies one trace.
+type TraceID [16]byte
+
+// String returns the lowercase hex form of the trace ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is non-zero.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies one span within a trace.
+type SpanID [8]byte
+
+// String returns the lowercase hex form of the span ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is non-zero.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span that propagates to its children.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Traceparent returns the W3C traceparent header value for sc, or "" when sc
+// is not valid. Spans are always marked sampled.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01"
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// SpanKind mirrors the OTLP span kinds used by agentlabd.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute is one key/value pair on a span. Value is a string, bool, int,
+// int64 or float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Option configures a span in Start.
+type Option func(*Span)
+
+// WithKind sets the span kind. The default is SpanKindInternal.
+func WithKind(kind SpanKind) Option {
+	return func(s *Span) { s.kind = kind }
+}
+
+// WithAttributes sets attributes when the span starts.
+func WithAttributes(attrs ...Attribute) Option {
+	return func(s *Span) { s.attrs = append(s.attrs, attrs...) }
+}
+
+// Span is one timed operation. The zero value is not usable; spans come from
+// Start.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   SpanKind
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+
+	mu       sync.Mutex
+	end      time.Time
+	attrs    []Attribute
+	errorMsg string
+	failed   bool
+	ended    bool
+}
+
+// SpanContext returns the span's IDs, or the zero SpanContext for a nil span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span failed with err's message. A nil err is
+// ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.errorMsg = err.Error()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = s.tracer.now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx whose spans become children
+// of sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// ContextWithRemote continues the trace named by a stored or received
+// traceparent. An empty or malformed value leaves ctx unchanged.
+func ContextWithRemote(ctx context.Context, traceparent string) context.Context {
+	sc, ok := ParseTraceparent(traceparent)
+	if !ok {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// SpanContextFromContext returns the current span context in ctx, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Traceparent returns the W3C traceparent for the current span in ctx, or ""
+// when there is none.
+func Traceparent(ctx context.Context) string {
+	return SpanContextFromContext(ctx).Traceparent()
+}
+
+var global atomic.Pointer[Tracer]
+
+// SetTracer installs t as the process-wide tracer. A nil t disables tracing.
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Enabled reports whether a process-wide tracer is installed.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+// Start begins a span named name as a child of the span in ctx, or as the
+// root of a new trace. It returns a context carrying the new span. When
+// tracing is disabled it returns ctx and a nil span.
+func Start(ctx context.Context, name string, opts ...Option) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	return t.Start(ctx, name, opts...)
+}
+
+// Start begins a span on t. See the package-level Start.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...Option) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   SpanKindInternal,
+		start:  t.now(),
+	}
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+	}
+	span.sc.SpanID = newSpanID()
+	for _, opt := range opts {
+		opt(span)
+	}
+	return ContextWithSpanContext(ctx, span.sc), span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(fmt.Sprintf("tracing: read random trace id: %v", err))
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(fmt.Sprintf("tracing: read random span id: %v", err))
+		}
+	}
+	return id
+}
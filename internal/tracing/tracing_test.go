@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+	got, ok := ParseTraceparent(sc.Traceparent())
+	if !ok || got != sc {
+		t.Fatalf("ParseTraceparent(%q) = %v, %v", sc.Traceparent(), got, ok)
+	}
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-0000000000000001-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-zzf7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Fatalf("ParseTraceparent(%q) accepted a malformed value", bad)
+		}
+	}
+}
+
+func TestStartWithoutTracerIsNoop(t *testing.T) {
+	SetTracer(nil)
+	ctx := context.Background()
+	got, span := Start(ctx, "noop")
+	if span != nil || got != ctx {
+		t.Fatalf("Start without a tracer = %v, %v", got, span)
+	}
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("boom"))
+	span.End()
+	if Traceparent(got) != "" {
+		t.Fatalf("Traceparent = %q, want empty", Traceparent(got))
+	}
+}
+
+// receiver is a local OTLP/HTTP collector that keeps every exported span.
+type receiver struct {
+	mu    sync.Mutex
+	auth  string
+	spans []otlpSpan
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var req otlpExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.auth = r.Header.Get("Authorization")
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			rc.spans = append(rc.spans, ss.Spans...)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestTracerExportsSpansToCollector(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	tracer, err := New(Config{
+		Endpoint:      srv.URL,
+		Headers:       map[string]string{"Authorization": "Bearer collector"},
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, root := Start(context.Background(), "job.run", WithAttributes(String("job.id", "job-1")))
+	_, child := Start(ctx, "proxmox.Clone", WithKind(SpanKindClient))
+	child.SetAttributes(Int("vmid", 1001))
+	child.RecordError(errors.New("clone failed"))
+	child.End()
+	root.End()
+
+	// A report that arrives later continues the stored trace.
+	resumed, report := Start(ContextWithRemote(context.Background(), Traceparent(ctx)), "runner.report", WithKind(SpanKindServer))
+	report.End()
+	if SpanContextFromContext(resumed).TraceID != root.SpanContext().TraceID {
+		t.Fatal("resumed span is not in the job's trace")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.auth != "Bearer collector" {
+		t.Fatalf("Authorization = %q", rc.auth)
+	}
+	if len(rc.spans) != 3 {
+		t.Fatalf("exported %d spans, want 3", len(rc.spans))
+	}
+	byName := map[string]otlpSpan{}
+	for _, span := range rc.spans {
+		byName[span.Name] = span
+	}
+	rootSpan, childSpan, reportSpan := byName["job.run"], byName["proxmox.Clone"], byName["runner.report"]
+	traceID := root.SpanContext().TraceID.String()
+	for _, span := range rc.spans {
+		if span.TraceID != traceID {
+			t.Fatalf("span %s trace = %s, want %s", span.Name, span.TraceID, traceID)
+		}
+	}
+	if rootSpan.ParentSpanID != "" {
+		t.Fatalf("root parent = %q", rootSpan.ParentSpanID)
+	}
+	if childSpan.ParentSpanID != rootSpan.SpanID || reportSpan.ParentSpanID != rootSpan.SpanID {
+		t.Fatalf("children not linked to root %s: %s, %s", rootSpan.SpanID, childSpan.ParentSpanID, reportSpan.ParentSpanID)
+	}
+	if childSpan.Kind != SpanKindClient || childSpan.Status.Code != otlpStatusError || childSpan.Status.Message != "clone failed" {
+		t.Fatalf("child span = %+v", childSpan)
+	}
+	if len(childSpan.Attributes) != 1 || childSpan.Attributes[0].Value.IntValue == nil || *childSpan.Attributes[0].Value.IntValue != "1001" {
+		t.Fatalf("child attributes = %+v", childSpan.Attributes)
+	}
+	if rootSpan.Status.Code != 0 {
+		t.Fatalf("root status = %+v, want unset", rootSpan.Status)
+	}
+}
+
+func TestNewRejectsBadEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"", "collector:4318", "ftp://collector"} {
+		if _, err := New(Config{Endpoint: endpoint}); err == nil {
+			t.Fatalf("New(%q) accepted a bad endpoint", endpoint)
+		}
+	}
+}
@@ -2,10 +2,18 @@ package user
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
+
+	"github.com/agentlab/agentlab/internal/models"
 )
 
 // Registry manages users, teams, and access control.
@@ -143,9 +151,11 @@ func (r *Registry) IsAdmin(ctx context.Context, userID string) bool {
 	return u.Role == RoleAdmin
 }
 
-// CanAccessSandbox checks whether a user can access a specific sandbox.
-// Admins can access all sandboxes. Regular users can only access their own.
-func (r *Registry) CanAccessSandbox(ctx context.Context, userID string, sandboxOwner string) bool {
+// CanAccessSandbox checks whether a user holds at least level on a sandbox.
+// Admins can access all sandboxes. Regular users can access their own, those
+// owned by a team they belong to, and those shared with them by an access
+// grant.
+func (r *Registry) CanAccessSandbox(ctx context.Context, userID string, sb models.Sandbox, level AccessLevel) bool {
 	if r.store == nil {
 		// If no user system, allow all access (single-user mode).
 		return true
@@ -158,8 +168,44 @@ func (r *Registry) CanAccessSandbox(ctx context.Context, userID string, sandboxO
 	if r.IsAdmin(ctx, userID) {
 		return true
 	}
-	// Users can access their own sandboxes and unowned sandboxes.
-	return sandboxOwner == "" || sandboxOwner == userID
+	// Unowned sandboxes predate ownership and stay open to every user.
+	if sb.Owner == "" && sb.Team == "" {
+		return true
+	}
+	held, err := r.ResourceAccess(ctx, userID, ResourceSandbox, strconv.Itoa(sb.VMID), sb.Owner, sb.Team)
+	return err == nil && held.Includes(level)
+}
+
+// ResourceAccess returns the level a user holds on a resource with the given
+// owner and owning team. The owner and members of the owning team hold admin;
+// everyone else holds what an access grant gives them, if anything.
+func (r *Registry) ResourceAccess(ctx context.Context, userID, resourceType, resourceID, owner, team string) (AccessLevel, error) {
+	if r.store == nil {
+		return AccessNone, errors.New("registry store is nil")
+	}
+	if userID == "" {
+		return AccessNone, nil
+	}
+	if owner == userID {
+		return AccessAdmin, nil
+	}
+	if team != "" {
+		member, err := r.IsTeamMember(ctx, team, userID)
+		if err != nil {
+			return AccessNone, err
+		}
+		if member {
+			return AccessAdmin, nil
+		}
+	}
+	g, err := r.store.GetUserAccessGrant(ctx, resourceType, resourceID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AccessNone, nil
+	}
+	if err != nil {
+		return AccessNone, err
+	}
+	return g.Level, nil
 }
 
 // ListUsers returns all registered users with their sandbox counts.
@@ -240,6 +286,18 @@ func (r *Registry) RemoveTeamMember(ctx context.Context, teamID, userID string,
 	return nil
 }
 
+// IsTeamMember reports whether a user belongs to a team.
+func (r *Registry) IsTeamMember(ctx context.Context, teamID, userID string) (bool, error) {
+	if r.store == nil {
+		return false, errors.New("registry store is nil")
+	}
+	teams, err := r.store.ListUserTeams(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(teams, teamID), nil
+}
+
 // ListTeamMembers returns all members of a team with their user details.
 func (r *Registry) ListTeamMembers(ctx context.Context, teamID string) ([]TeamMember, error) {
 	if r.store == nil {
@@ -256,6 +314,404 @@ func (r *Registry) ListTeams(ctx context.Context) ([]Team, error) {
 	return r.store.ListTeams(ctx)
 }
 
+// --- Custom roles ---
+
+// roleNamePattern keeps role names safe to use in URLs and audit resources.
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// CreateRole creates a custom role. Permission names are validated by the
+// caller, which owns the permission catalog.
+func (r *Registry) CreateRole(ctx context.Context, role CustomRole, requesterID string) (CustomRole, error) {
+	if r.store == nil {
+		return CustomRole{}, errors.New("registry store is nil")
+	}
+	if !roleNamePattern.MatchString(role.Name) {
+		return CustomRole{}, fmt.Errorf("invalid role name %q: use lowercase letters, digits, '.', '_' or '-'", role.Name)
+	}
+	if Role(role.Name).IsValid() {
+		return CustomRole{}, fmt.Errorf("role name %q is reserved", role.Name)
+	}
+	if len(role.Permissions) == 0 {
+		return CustomRole{}, errors.New("role needs at least one permission")
+	}
+	created, err := r.store.CreateCustomRole(ctx, role)
+	if err != nil {
+		return CustomRole{}, err
+	}
+	_ = r.store.Audit(ctx, requesterID, "role.add", "role:"+role.Name, "permissions="+strings.Join(role.Permissions, ","))
+	return created, nil
+}
+
+// RemoveRole deletes a custom role together with its bindings.
+func (r *Registry) RemoveRole(ctx context.Context, name, requesterID string) error {
+	if r.store == nil {
+		return errors.New("registry store is nil")
+	}
+	if err := r.store.DeleteCustomRole(ctx, name); err != nil {
+		return err
+	}
+	_ = r.store.Audit(ctx, requesterID, "role.remove", "role:"+name, "")
+	return nil
+}
+
+// ListRoles returns all custom roles.
+func (r *Registry) ListRoles(ctx context.Context) ([]CustomRole, error) {
+	if r.store == nil {
+		return nil, errors.New("registry store is nil")
+	}
+	return r.store.ListCustomRoles(ctx)
+}
+
+// BindRole grants a custom role to a user or team at a scope. The role, the
+// subject and a team scope must all exist.
+func (r *Registry) BindRole(ctx context.Context, b RoleBinding, requesterID string) (RoleBinding, error) {
+	if r.store == nil {
+		return RoleBinding{}, errors.New("registry store is nil")
+	}
+	if _, err := r.store.GetCustomRole(ctx, b.Role); err != nil {
+		return RoleBinding{}, fmt.Errorf("role %q: %w", b.Role, err)
+	}
+	switch b.SubjectType {
+	case SubjectUser:
+		if _, err := r.store.GetUser(ctx, b.SubjectID); err != nil {
+			return RoleBinding{}, fmt.Errorf("user %q: %w", b.SubjectID, err)
+		}
+	case SubjectTeam:
+		if _, err := r.store.GetTeam(ctx, b.SubjectID); err != nil {
+			return RoleBinding{}, fmt.Errorf("team %q: %w", b.SubjectID, err)
+		}
+	default:
+		return RoleBinding{}, fmt.Errorf("invalid subject type %q (must be user or team)", b.SubjectType)
+	}
+	switch b.ScopeType {
+	case ScopeGlobal:
+		b.ScopeID = ""
+	case ScopeTeam:
+		if _, err := r.store.GetTeam(ctx, b.ScopeID); err != nil {
+			return RoleBinding{}, fmt.Errorf("scope team %q: %w", b.ScopeID, err)
+		}
+	case ScopeTag:
+		if b.ScopeID == "" {
+			return RoleBinding{}, errors.New("tag scope needs a tag")
+		}
+	default:
+		return RoleBinding{}, fmt.Errorf("invalid scope type %q (must be global, team or tag)", b.ScopeType)
+	}
+	created, err := r.store.CreateRoleBinding(ctx, b)
+	if err != nil {
+		return RoleBinding{}, err
+	}
+	_ = r.store.Audit(ctx, requesterID, "role.bind", "role:"+b.Role,
+		"subject="+b.SubjectType+":"+b.SubjectID+" scope="+b.Scope())
+	return created, nil
+}
+
+// UnbindRole removes a role binding.
+func (r *Registry) UnbindRole(ctx context.Context, id int64, requesterID string) error {
+	if r.store == nil {
+		return errors.New("registry store is nil")
+	}
+	if err := r.store.DeleteRoleBinding(ctx, id); err != nil {
+		return err
+	}
+	_ = r.store.Audit(ctx, requesterID, "role.unbind", "role_binding:"+strconv.FormatInt(id, 10), "")
+	return nil
+}
+
+// ListRoleBindings returns all role bindings.
+func (r *Registry) ListRoleBindings(ctx context.Context) ([]RoleBinding, error) {
+	if r.store == nil {
+		return nil, errors.New("registry store is nil")
+	}
+	return r.store.ListRoleBindings(ctx)
+}
+
+// Grants resolves the custom roles bound to a user, directly or through any
+// team the user belongs to.
+func (r *Registry) Grants(ctx context.Context, userID string) ([]Grant, error) {
+	if r.store == nil {
+		return nil, errors.New("registry store is nil")
+	}
+	teams, err := r.store.ListUserTeams(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	memberOf := make(map[string]bool, len(teams))
+	for _, team := range teams {
+		memberOf[team] = true
+	}
+	bindings, err := r.store.ListRoleBindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := r.store.ListCustomRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	permissions := make(map[string][]string, len(roles))
+	for _, role := range roles {
+		permissions[role.Name] = role.Permissions
+	}
+	var grants []Grant
+	for _, b := range bindings {
+		applies := (b.SubjectType == SubjectUser && b.SubjectID == userID) ||
+			(b.SubjectType == SubjectTeam && memberOf[b.SubjectID])
+		if !applies {
+			continue
+		}
+		grants = append(grants, Grant{
+			Role:        b.Role,
+			Permissions: permissions[b.Role],
+			ScopeType:   b.ScopeType,
+			ScopeID:     b.ScopeID,
+		})
+	}
+	return grants, nil
+}
+
+// --- Access grants ---
+
+// GrantAccess shares a sandbox, workspace or session with a user, replacing
+// any level the user already held through a grant. The resource itself is
+// validated by the caller, which owns the resource tables.
+func (r *Registry) GrantAccess(ctx context.Context, g AccessGrant, requesterID string) (AccessGrant, error) {
+	if r.store == nil {
+		return AccessGrant{}, errors.New("registry store is nil")
+	}
+	switch g.ResourceType {
+	case ResourceSandbox, ResourceWorkspace, ResourceSession:
+	default:
+		return AccessGrant{}, fmt.Errorf("invalid resource type %q (must be sandbox, workspace or session)", g.ResourceType)
+	}
+	if g.ResourceID == "" {
+		return AccessGrant{}, errors.New("resource id is required")
+	}
+	if !g.Level.IsValid() {
+		return AccessGrant{}, fmt.Errorf("invalid access level %q (must be read, operate or admin)", g.Level)
+	}
+	if _, err := r.store.GetUser(ctx, g.UserID); err != nil {
+		return AccessGrant{}, fmt.Errorf("user %q: %w", g.UserID, err)
+	}
+	g.GrantedBy = requesterID
+	created, err := r.store.PutAccessGrant(ctx, g)
+	if err != nil {
+		return AccessGrant{}, err
+	}
+	_ = r.store.Audit(ctx, requesterID, "access.grant", g.Resource(), "user="+g.UserID+" level="+string(g.Level))
+	return created, nil
+}
+
+// RevokeAccess removes an access grant.
+func (r *Registry) RevokeAccess(ctx context.Context, id int64, requesterID string) error {
+	if r.store == nil {
+		return errors.New("registry store is nil")
+	}
+	g, err := r.store.GetAccessGrant(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.store.DeleteAccessGrant(ctx, id); err != nil {
+		return err
+	}
+	_ = r.store.Audit(ctx, requesterID, "access.revoke", g.Resource(), "user="+g.UserID)
+	return nil
+}
+
+// GetAccessGrant returns an access grant by ID.
+func (r *Registry) GetAccessGrant(ctx context.Context, id int64) (AccessGrant, error) {
+	if r.store == nil {
+		return AccessGrant{}, errors.New("registry store is nil")
+	}
+	return r.store.GetAccessGrant(ctx, id)
+}
+
+// ListAccessGrants returns the grants on one resource, or on every resource
+// when resourceType is empty.
+func (r *Registry) ListAccessGrants(ctx context.Context, resourceType, resourceID string) ([]AccessGrant, error) {
+	if r.store == nil {
+		return nil, errors.New("registry store is nil")
+	}
+	return r.store.ListAccessGrants(ctx, resourceType, resourceID)
+}
+
+// --- External identities ---
+
+// ErrIdentityNotLinked is returned when a single sign-on login names an
+// existing user that has not been linked to the login's identity. Linking by
+// name alone would let anyone able to choose their username at the provider
+// take over the account.
+var ErrIdentityNotLinked = errors.New("user exists but is not linked to this identity")
+
+// ErrUnusableUsername is returned when a single sign-on login for an unknown
+// identity carries no username that can name a new user.
+var ErrUnusableUsername = errors.New("username cannot name a user")
+
+// externalNamePattern keeps provisioned user names safe to use in URLs and
+// audit resources. Email-style names are allowed.
+var externalNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,127}$`)
+
+// LoginExternal maps a login vouched for by an external identity provider to
+// a registered user. An unknown identity provisions a new user named after
+// the login, holding no SSH key. The user's role and team memberships are
+// then brought in line with the provider's groups, so removing someone from a
+// group at the provider takes effect at their next login. It returns the user
+// and the teams they belong to afterwards.
+func (r *Registry) LoginExternal(ctx context.Context, login ExternalLogin) (User, []string, error) {
+	if r.store == nil {
+		return User{}, nil, errors.New("registry store is nil")
+	}
+	if login.Issuer == "" || login.Subject == "" {
+		return User{}, nil, errors.New("issuer and subject are required")
+	}
+	if login.Role != "" && !login.Role.IsValid() {
+		return User{}, nil, fmt.Errorf("invalid role: %s", login.Role)
+	}
+	var u User
+	id, err := r.store.GetIdentity(ctx, login.Issuer, login.Subject)
+	switch {
+	case err == nil:
+		u, err = r.store.GetUser(ctx, id.UserID)
+		if err != nil {
+			return User{}, nil, fmt.Errorf("load user %s: %w", id.UserID, err)
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		u, err = r.provisionExternal(ctx, login)
+		if err != nil {
+			return User{}, nil, err
+		}
+	default:
+		return User{}, nil, err
+	}
+
+	if login.Role != "" && login.Role != u.Role {
+		if err := r.store.SetUserRole(ctx, u.ID, login.Role); err != nil {
+			return User{}, nil, err
+		}
+		_ = r.store.Audit(ctx, u.ID, "user.role", "user:"+u.Name, "role="+string(login.Role)+" source=oidc")
+		u.Role = login.Role
+	}
+	teams, err := r.syncExternalTeams(ctx, u, login)
+	if err != nil {
+		return User{}, nil, err
+	}
+	_ = r.store.TouchIdentity(ctx, login.Issuer, login.Subject, time.Now().UTC())
+	_ = r.store.Audit(ctx, u.ID, "user.login", "user:"+u.Name, "method=oidc subject="+login.Subject)
+	return u, teams, nil
+}
+
+func (r *Registry) provisionExternal(ctx context.Context, login ExternalLogin) (User, error) {
+	name := strings.TrimSpace(login.Name)
+	if !externalNamePattern.MatchString(name) {
+		return User{}, fmt.Errorf("%w: %q", ErrUnusableUsername, login.Name)
+	}
+	if _, err := r.store.GetUserByName(ctx, name); err == nil {
+		return User{}, fmt.Errorf("%w: %q", ErrIdentityNotLinked, name)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return User{}, err
+	}
+	role := login.Role
+	if role == "" {
+		role = RoleUser
+	}
+	u, err := r.store.CreateUser(ctx, User{
+		ID:   name,
+		Name: name,
+		Role: role,
+		// OIDC users hold no SSH key; the placeholder keeps the primary
+		// fingerprint unique and can never match a real key fingerprint.
+		Fingerprint: "oidc:" + login.Issuer + "#" + login.Subject,
+	}, "")
+	if err != nil {
+		return User{}, err
+	}
+	if err := r.store.LinkIdentity(ctx, Identity{Issuer: login.Issuer, Subject: login.Subject, UserID: u.ID}); err != nil {
+		return User{}, err
+	}
+	_ = r.store.Audit(ctx, u.ID, "user.add", "user:"+name, "role="+string(role)+" source=oidc subject="+login.Subject)
+	return u, nil
+}
+
+// syncExternalTeams adds the user to the login's teams that exist and removes
+// them from the managed teams the login no longer names.
+func (r *Registry) syncExternalTeams(ctx context.Context, u User, login ExternalLogin) ([]string, error) {
+	current, err := r.store.ListUserTeams(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range login.Teams {
+		if slices.Contains(current, team) {
+			continue
+		}
+		if _, err := r.store.GetTeam(ctx, team); errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if err := r.store.AddTeamMember(ctx, team, u.ID, RoleUser); err != nil {
+			return nil, err
+		}
+		_ = r.store.Audit(ctx, u.ID, "team.member.add", "team:"+team, "user="+u.ID+" role=user source=oidc")
+		current = append(current, team)
+	}
+	kept := current[:0]
+	for _, team := range current {
+		managed := login.ManagedTeams == nil || slices.Contains(login.ManagedTeams, team)
+		if managed && !slices.Contains(login.Teams, team) {
+			if err := r.store.RemoveTeamMember(ctx, team, u.ID); err != nil {
+				return nil, err
+			}
+			_ = r.store.Audit(ctx, u.ID, "team.member.remove", "team:"+team, "user="+u.ID+" source=oidc")
+			continue
+		}
+		kept = append(kept, team)
+	}
+	slices.Sort(kept)
+	return kept, nil
+}
+
+// LinkIdentity links an external identity to an existing user, so the
+// user's next single sign-on login maps to them.
+func (r *Registry) LinkIdentity(ctx context.Context, userID, issuer, subject, requesterID string) (Identity, error) {
+	if r.store == nil {
+		return Identity{}, errors.New("registry store is nil")
+	}
+	if issuer == "" || subject == "" {
+		return Identity{}, errors.New("issuer and subject are required")
+	}
+	if _, err := r.store.GetUser(ctx, userID); err != nil {
+		return Identity{}, fmt.Errorf("user %q: %w", userID, err)
+	}
+	if existing, err := r.store.GetIdentity(ctx, issuer, subject); err == nil {
+		return Identity{}, fmt.Errorf("identity already linked to user %q", existing.UserID)
+	}
+	id := Identity{Issuer: issuer, Subject: subject, UserID: userID, CreatedAt: time.Now().UTC()}
+	if err := r.store.LinkIdentity(ctx, id); err != nil {
+		return Identity{}, err
+	}
+	_ = r.store.Audit(ctx, requesterID, "user.identity.link", "user:"+userID, "issuer="+issuer+" subject="+subject)
+	return id, nil
+}
+
+// UnlinkIdentity removes an external identity from a user.
+func (r *Registry) UnlinkIdentity(ctx context.Context, userID, issuer, subject, requesterID string) error {
+	if r.store == nil {
+		return errors.New("registry store is nil")
+	}
+	if err := r.store.UnlinkIdentity(ctx, userID, issuer, subject); err != nil {
+		return err
+	}
+	_ = r.store.Audit(ctx, requesterID, "user.identity.unlink", "user:"+userID, "issuer="+issuer+" subject="+subject)
+	return nil
+}
+
+// ListIdentities returns the external identities linked to a user.
+func (r *Registry) ListIdentities(ctx context.Context, userID string) ([]Identity, error) {
+	if r.store == nil {
+		return nil, errors.New("registry store is nil")
+	}
+	return r.store.ListIdentities(ctx, userID)
+}
+
 // --- Audit ---
 
 // RecordAction records an action in the audit log.
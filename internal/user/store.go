@@ -3,6 +3,7 @@ package user
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -395,6 +396,399 @@ func (s *Store) ListTeamMembers(ctx context.Context, teamID string) ([]TeamMembe
 	return members, rows.Err()
 }
 
+// ListUserTeams returns the IDs of every team the user belongs to.
+func (s *Store) ListUserTeams(ctx context.Context, userID string) ([]string, error) {
+	if s.db == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.db.DB.QueryContext(ctx,
+		`SELECT team_id FROM team_members WHERE user_id = ? ORDER BY team_id ASC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list teams for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+	var teams []string
+	for rows.Next() {
+		var teamID string
+		if err := rows.Scan(&teamID); err != nil {
+			return nil, err
+		}
+		teams = append(teams, teamID)
+	}
+	return teams, rows.Err()
+}
+
+// --- Custom Role operations ---
+
+// CreateCustomRole creates a new custom role.
+func (s *Store) CreateCustomRole(ctx context.Context, role CustomRole) (CustomRole, error) {
+	if s.db == nil {
+		return CustomRole{}, errors.New("db store is nil")
+	}
+	if role.Name == "" {
+		return CustomRole{}, errors.New("role name is required")
+	}
+	permissions, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return CustomRole{}, fmt.Errorf("encode permissions for role %s: %w", role.Name, err)
+	}
+	now := time.Now().UTC()
+	if role.CreatedAt.IsZero() {
+		role.CreatedAt = now
+	}
+	if role.UpdatedAt.IsZero() {
+		role.UpdatedAt = now
+	}
+	_, err = s.db.DB.ExecContext(ctx,
+		`INSERT INTO custom_roles (name, description, permissions_json, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		role.Name, role.Description, string(permissions), formatTime(role.CreatedAt), formatTime(role.UpdatedAt))
+	if err != nil {
+		return CustomRole{}, fmt.Errorf("insert role %s: %w", role.Name, err)
+	}
+	return role, nil
+}
+
+// GetCustomRole retrieves a custom role by name.
+func (s *Store) GetCustomRole(ctx context.Context, name string) (CustomRole, error) {
+	if s.db == nil {
+		return CustomRole{}, errors.New("db store is nil")
+	}
+	row := s.db.DB.QueryRowContext(ctx,
+		`SELECT name, description, permissions_json, created_at, updated_at FROM custom_roles WHERE name = ?`, name)
+	return scanCustomRole(row)
+}
+
+// ListCustomRoles returns all custom roles ordered by name.
+func (s *Store) ListCustomRoles(ctx context.Context) ([]CustomRole, error) {
+	if s.db == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.db.DB.QueryContext(ctx,
+		`SELECT name, description, permissions_json, created_at, updated_at FROM custom_roles ORDER BY name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list roles: %w", err)
+	}
+	defer rows.Close()
+	var roles []CustomRole
+	for rows.Next() {
+		role, err := scanCustomRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// DeleteCustomRole removes a custom role and, through the foreign key, every
+// binding of it.
+func (s *Store) DeleteCustomRole(ctx context.Context, name string) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	res, err := s.db.DB.ExecContext(ctx, `DELETE FROM custom_roles WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete role %s: %w", name, err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanCustomRole(row interface{ Scan(...any) error }) (CustomRole, error) {
+	var role CustomRole
+	var permissions, createdAt, updatedAt string
+	if err := row.Scan(&role.Name, &role.Description, &permissions, &createdAt, &updatedAt); err != nil {
+		return CustomRole{}, err
+	}
+	if err := json.Unmarshal([]byte(permissions), &role.Permissions); err != nil {
+		return CustomRole{}, fmt.Errorf("decode permissions for role %s: %w", role.Name, err)
+	}
+	role.CreatedAt, _ = parseTime(createdAt)
+	role.UpdatedAt, _ = parseTime(updatedAt)
+	return role, nil
+}
+
+// --- Role Binding operations ---
+
+// CreateRoleBinding grants a custom role to a user or team at a scope.
+func (s *Store) CreateRoleBinding(ctx context.Context, b RoleBinding) (RoleBinding, error) {
+	if s.db == nil {
+		return RoleBinding{}, errors.New("db store is nil")
+	}
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now().UTC()
+	}
+	res, err := s.db.DB.ExecContext(ctx,
+		`INSERT INTO role_bindings (role_name, subject_type, subject_id, scope_type, scope_id, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		b.Role, b.SubjectType, b.SubjectID, b.ScopeType, b.ScopeID, formatTime(b.CreatedAt))
+	if err != nil {
+		return RoleBinding{}, fmt.Errorf("bind role %s to %s:%s: %w", b.Role, b.SubjectType, b.SubjectID, err)
+	}
+	b.ID, _ = res.LastInsertId()
+	return b, nil
+}
+
+// DeleteRoleBinding removes a role binding by ID.
+func (s *Store) DeleteRoleBinding(ctx context.Context, id int64) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	res, err := s.db.DB.ExecContext(ctx, `DELETE FROM role_bindings WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete role binding %d: %w", id, err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListRoleBindings returns all role bindings ordered by ID.
+func (s *Store) ListRoleBindings(ctx context.Context) ([]RoleBinding, error) {
+	if s.db == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.db.DB.QueryContext(ctx,
+		`SELECT id, role_name, subject_type, subject_id, scope_type, scope_id, created_at FROM role_bindings ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list role bindings: %w", err)
+	}
+	defer rows.Close()
+	var bindings []RoleBinding
+	for rows.Next() {
+		var b RoleBinding
+		var createdAt string
+		if err := rows.Scan(&b.ID, &b.Role, &b.SubjectType, &b.SubjectID, &b.ScopeType, &b.ScopeID, &createdAt); err != nil {
+			return nil, err
+		}
+		b.CreatedAt, _ = parseTime(createdAt)
+		bindings = append(bindings, b)
+	}
+	return bindings, rows.Err()
+}
+
+// --- Access Grant operations ---
+
+// PutAccessGrant shares a resource with a user. Granting again replaces the
+// level of the existing grant.
+func (s *Store) PutAccessGrant(ctx context.Context, g AccessGrant) (AccessGrant, error) {
+	if s.db == nil {
+		return AccessGrant{}, errors.New("db store is nil")
+	}
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.db.DB.ExecContext(ctx,
+		`INSERT INTO access_grants (resource_type, resource_id, user_id, level, granted_by, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(resource_type, resource_id, user_id) DO UPDATE SET level = excluded.level, granted_by = excluded.granted_by, created_at = excluded.created_at`,
+		g.ResourceType, g.ResourceID, g.UserID, string(g.Level), g.GrantedBy, formatTime(g.CreatedAt))
+	if err != nil {
+		return AccessGrant{}, fmt.Errorf("grant %s to %s: %w", g.Resource(), g.UserID, err)
+	}
+	row := s.db.DB.QueryRowContext(ctx,
+		`SELECT id, resource_type, resource_id, user_id, level, granted_by, created_at FROM access_grants
+		WHERE resource_type = ? AND resource_id = ? AND user_id = ?`, g.ResourceType, g.ResourceID, g.UserID)
+	return scanAccessGrant(row)
+}
+
+// GetAccessGrant retrieves an access grant by ID.
+func (s *Store) GetAccessGrant(ctx context.Context, id int64) (AccessGrant, error) {
+	if s.db == nil {
+		return AccessGrant{}, errors.New("db store is nil")
+	}
+	row := s.db.DB.QueryRowContext(ctx,
+		`SELECT id, resource_type, resource_id, user_id, level, granted_by, created_at FROM access_grants WHERE id = ?`, id)
+	return scanAccessGrant(row)
+}
+
+// DeleteAccessGrant removes an access grant by ID.
+func (s *Store) DeleteAccessGrant(ctx context.Context, id int64) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	res, err := s.db.DB.ExecContext(ctx, `DELETE FROM access_grants WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete access grant %d: %w", id, err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListAccessGrants returns the grants on one resource, or on every resource
+// when resourceType is empty, ordered by ID.
+func (s *Store) ListAccessGrants(ctx context.Context, resourceType, resourceID string) ([]AccessGrant, error) {
+	if s.db == nil {
+		return nil, errors.New("db store is nil")
+	}
+	query := `SELECT id, resource_type, resource_id, user_id, level, granted_by, created_at FROM access_grants`
+	var args []any
+	if resourceType != "" {
+		query += ` WHERE resource_type = ? AND resource_id = ?`
+		args = append(args, resourceType, resourceID)
+	}
+	rows, err := s.db.DB.QueryContext(ctx, query+` ORDER BY id ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list access grants: %w", err)
+	}
+	defer rows.Close()
+	var grants []AccessGrant
+	for rows.Next() {
+		g, err := scanAccessGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// GetUserAccessGrant returns the user's grant on one resource.
+func (s *Store) GetUserAccessGrant(ctx context.Context, resourceType, resourceID, userID string) (AccessGrant, error) {
+	if s.db == nil {
+		return AccessGrant{}, errors.New("db store is nil")
+	}
+	row := s.db.DB.QueryRowContext(ctx,
+		`SELECT id, resource_type, resource_id, user_id, level, granted_by, created_at FROM access_grants
+		WHERE resource_type = ? AND resource_id = ? AND user_id = ?`, resourceType, resourceID, userID)
+	return scanAccessGrant(row)
+}
+
+func scanAccessGrant(row interface{ Scan(...any) error }) (AccessGrant, error) {
+	var g AccessGrant
+	var level, createdAt string
+	if err := row.Scan(&g.ID, &g.ResourceType, &g.ResourceID, &g.UserID, &level, &g.GrantedBy, &createdAt); err != nil {
+		return AccessGrant{}, err
+	}
+	g.Level = AccessLevel(level)
+	g.CreatedAt, _ = parseTime(createdAt)
+	return g, nil
+}
+
+// --- External identity operations ---
+
+// LinkIdentity links an external identity to a user.
+func (s *Store) LinkIdentity(ctx context.Context, id Identity) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	if id.CreatedAt.IsZero() {
+		id.CreatedAt = time.Now().UTC()
+	}
+	lastLogin := ""
+	if !id.LastLoginAt.IsZero() {
+		lastLogin = formatTime(id.LastLoginAt)
+	}
+	_, err := s.db.DB.ExecContext(ctx,
+		`INSERT INTO user_identities (issuer, subject, user_id, created_at, last_login_at) VALUES (?, ?, ?, ?, ?)`,
+		id.Issuer, id.Subject, id.UserID, formatTime(id.CreatedAt), lastLogin)
+	if err != nil {
+		return fmt.Errorf("link identity %s to user %s: %w", id.Subject, id.UserID, err)
+	}
+	return nil
+}
+
+// UnlinkIdentity removes a user's external identity.
+func (s *Store) UnlinkIdentity(ctx context.Context, userID, issuer, subject string) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	res, err := s.db.DB.ExecContext(ctx,
+		`DELETE FROM user_identities WHERE user_id = ? AND issuer = ? AND subject = ?`, userID, issuer, subject)
+	if err != nil {
+		return fmt.Errorf("unlink identity %s from user %s: %w", subject, userID, err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetIdentity retrieves the identity an issuer knows by subject.
+func (s *Store) GetIdentity(ctx context.Context, issuer, subject string) (Identity, error) {
+	if s.db == nil {
+		return Identity{}, errors.New("db store is nil")
+	}
+	row := s.db.DB.QueryRowContext(ctx,
+		`SELECT issuer, subject, user_id, created_at, last_login_at FROM user_identities WHERE issuer = ? AND subject = ?`,
+		issuer, subject)
+	return scanIdentity(row)
+}
+
+// ListIdentities returns the external identities linked to a user.
+func (s *Store) ListIdentities(ctx context.Context, userID string) ([]Identity, error) {
+	if s.db == nil {
+		return nil, errors.New("db store is nil")
+	}
+	rows, err := s.db.DB.QueryContext(ctx,
+		`SELECT issuer, subject, user_id, created_at, last_login_at FROM user_identities WHERE user_id = ? ORDER BY created_at ASC`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("list identities for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+	var ids []Identity
+	for rows.Next() {
+		id, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// TouchIdentity records a login through an external identity.
+func (s *Store) TouchIdentity(ctx context.Context, issuer, subject string, at time.Time) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	_, err := s.db.DB.ExecContext(ctx,
+		`UPDATE user_identities SET last_login_at = ? WHERE issuer = ? AND subject = ?`,
+		formatTime(at), issuer, subject)
+	return err
+}
+
+func scanIdentity(row interface{ Scan(...any) error }) (Identity, error) {
+	var id Identity
+	var createdAt, lastLogin string
+	if err := row.Scan(&id.Issuer, &id.Subject, &id.UserID, &createdAt, &lastLogin); err != nil {
+		return Identity{}, err
+	}
+	id.CreatedAt, _ = parseTime(createdAt)
+	if lastLogin != "" {
+		id.LastLoginAt, _ = parseTime(lastLogin)
+	}
+	return id, nil
+}
+
+// SetUserRole changes a user's role.
+func (s *Store) SetUserRole(ctx context.Context, id string, role Role) error {
+	if s.db == nil {
+		return errors.New("db store is nil")
+	}
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+	res, err := s.db.DB.ExecContext(ctx,
+		`UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, string(role), formatTime(time.Now().UTC()), id)
+	if err != nil {
+		return fmt.Errorf("set role for user %s: %w", id, err)
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // --- Audit Log operations ---
 
 // Audit records an action in the audit log.
@@ -1,10 +1,13 @@
 // Package user provides multi-user support via SSH keys for AgentLab.
 //
 // Design (ref: IMPROVEMENT_PLAN.md §9):
-//   - Users are identified by SSH key fingerprints (no passwords).
+//   - Users are identified by SSH key fingerprints (no passwords), or by an
+//     external identity (OIDC issuer and subject) linked to the user.
 //   - A user can have multiple SSH keys associated with their account.
 //   - Roles: admin (all sandboxes, user management) and user (own sandboxes only).
 //   - Teams group users for resource sharing and quota management.
+//   - Custom roles group control-plane permissions and are bound to users or
+//     teams at global, team or tag scope.
 //   - All user actions are recorded in an audit log.
 package user
 
@@ -72,6 +75,148 @@ type TeamMember struct {
 	JoinedAt  time.Time // When the user joined
 }
 
+// CustomRole is a named set of control-plane permissions. Entries are
+// permission names ("sandbox.start") or namespaces ("sandbox"), matched the
+// same way as a token's command list.
+type CustomRole struct {
+	Name        string    // Unique role name (e.g., "sandbox-operator")
+	Description string    // Optional description
+	Permissions []string  // Granted permissions or permission namespaces
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Role binding subjects: who a custom role is granted to.
+const (
+	SubjectUser = "user"
+	SubjectTeam = "team"
+)
+
+// Role binding scopes: which sandboxes a granted role applies to.
+const (
+	// ScopeGlobal applies the role everywhere, including global resources.
+	ScopeGlobal = "global"
+	// ScopeTeam applies the role to sandboxes owned by members of a team.
+	ScopeTeam = "team"
+	// ScopeTag applies the role to sandboxes carrying a tag.
+	ScopeTag = "tag"
+)
+
+// RoleBinding grants a custom role to a user or team at a scope.
+type RoleBinding struct {
+	ID          int64     // Auto-increment ID
+	Role        string    // Custom role name
+	SubjectType string    // "user" or "team"
+	SubjectID   string    // User ID or team ID
+	ScopeType   string    // "global", "team" or "tag"
+	ScopeID     string    // Team ID or tag (empty for global)
+	CreatedAt   time.Time // When the binding was created
+}
+
+// Scope renders the binding's scope as "global", "team:<id>" or "tag:<tag>".
+func (b RoleBinding) Scope() string {
+	if b.ScopeType == ScopeGlobal {
+		return ScopeGlobal
+	}
+	return b.ScopeType + ":" + b.ScopeID
+}
+
+// Grant is a custom role's permissions as they reach one user through a
+// binding, either directly or through a team.
+type Grant struct {
+	Role        string   // Custom role name
+	Permissions []string // The role's permissions
+	ScopeType   string   // "global", "team" or "tag"
+	ScopeID     string   // Team ID or tag (empty for global)
+}
+
+// AccessLevel is how much a user may do with a sandbox, workspace or session
+// they do not own. Levels are ordered: admin includes operate, which includes
+// read.
+type AccessLevel string
+
+const (
+	// AccessNone grants nothing.
+	AccessNone AccessLevel = ""
+	// AccessRead allows inspecting the resource, its events and artifacts.
+	AccessRead AccessLevel = "read"
+	// AccessOperate adds day-to-day use: start, stop, SSH, jobs and snapshots.
+	AccessOperate AccessLevel = "operate"
+	// AccessAdmin adds destructive changes and sharing the resource further.
+	AccessAdmin AccessLevel = "admin"
+)
+
+// ValidAccessLevels contains all grantable access levels, lowest first.
+var ValidAccessLevels = []AccessLevel{AccessRead, AccessOperate, AccessAdmin}
+
+// IsValid checks whether an access level can be granted.
+func (l AccessLevel) IsValid() bool {
+	return l.rank() > 0
+}
+
+// Includes reports whether l is at least other.
+func (l AccessLevel) Includes(other AccessLevel) bool {
+	return other.rank() > 0 && l.rank() >= other.rank()
+}
+
+func (l AccessLevel) rank() int {
+	for i, v := range ValidAccessLevels {
+		if l == v {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Shareable resource types for access grants.
+const (
+	ResourceSandbox   = "sandbox"
+	ResourceWorkspace = "workspace"
+	ResourceSession   = "session"
+)
+
+// AccessGrant shares one sandbox, workspace or session with a user.
+type AccessGrant struct {
+	ID           int64       // Auto-increment ID
+	ResourceType string      // "sandbox", "workspace" or "session"
+	ResourceID   string      // VMID, workspace ID or session ID
+	UserID       string      // User the resource is shared with
+	Level        AccessLevel // "read", "operate" or "admin"
+	GrantedBy    string      // User who granted access (empty for operators)
+	CreatedAt    time.Time   // When access was granted
+}
+
+// Resource renders the granted resource as "<type>:<id>".
+func (g AccessGrant) Resource() string {
+	return g.ResourceType + ":" + g.ResourceID
+}
+
+// Identity links an external identity provider's subject to a registered
+// user, so every single sign-on login maps to the same user.
+type Identity struct {
+	Issuer      string    // Provider issuer URL
+	Subject     string    // Provider subject, stable for the life of the account
+	UserID      string    // Registered user
+	CreatedAt   time.Time // When the identity was linked
+	LastLoginAt time.Time // Most recent login (zero if never)
+}
+
+// ExternalLogin is a sign-in vouched for by an external identity provider.
+type ExternalLogin struct {
+	Issuer  string // Provider issuer URL
+	Subject string // Provider subject
+	Name    string // Username claim, used to name a newly provisioned user
+	// Role is the role the provider's groups map to. Empty leaves the role of
+	// an existing user unchanged and provisions new users as RoleUser.
+	Role Role
+	// Teams are the teams the provider's groups map to. The user is added to
+	// those that exist and removed from every other team in ManagedTeams.
+	Teams []string
+	// ManagedTeams are the teams whose membership the provider decides. Nil
+	// means every team.
+	ManagedTeams []string
+}
+
 // AuditEntry represents a recorded user action for compliance and debugging.
 type AuditEntry struct {
 	ID          int64     // Auto-increment ID
@@ -2,6 +2,7 @@
 set -euo pipefail
 
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+REPO_ROOT="$(cd "${SCRIPT_DIR}/.." && pwd)"
 
 log() {
   printf "[create_template] %s\n" "$*"
@@ -33,6 +34,7 @@ Options:
   --packages <list>        Comma/space separated package list
   --skip-customize         Skip virt-customize package/user prep
   --skip-agent-tools       Skip installing agent CLIs + wrapper
+  --guest-binary <path>    agentlab-guest binary to install (default: dist/agentlab-guest_linux_amd64)
   --claude-version <v>     Claude Code CLI version (default: 1.0.100)
   --codex-version <v>      OpenAI Codex CLI version (default: 0.28.0)
   --opencode-version <v>   OpenCode CLI version (default: 0.6.4)
@@ -43,6 +45,8 @@ Notes:
   - Requires Proxmox qm on the host.
   - For package install + user setup, install libguestfs-tools (virt-customize).
   - Checksum verification uses sha256sum (or shasum -a 256).
+  - If the agentlab-guest binary is missing and go is installed, it is built
+    from cmd/agentlab-guest (linux/amd64, CGO disabled).
 USAGE
 }
 
@@ -68,7 +72,7 @@ SKIP_AGENT_TOOLS=0
 REFRESH=0
 
 AGENTLAB_AGENT_WRAPPER="${SCRIPT_DIR}/guest/agentlab-agent"
-AGENTLAB_RUNNER_SCRIPT="${SCRIPT_DIR}/guest/agent-runner"
+AGENTLAB_GUEST_BIN="${AGENTLAB_GUEST_BIN:-${REPO_ROOT}/dist/agentlab-guest_linux_amd64}"
 AGENTLAB_RUNNER_SERVICE="${SCRIPT_DIR}/guest/agent-runner.service"
 AGENTLAB_RUNNER_ENV="${SCRIPT_DIR}/guest/agent-runner.env"
 AGENTLAB_SECRETS_CLEANUP_SCRIPT="${SCRIPT_DIR}/guest/agent-secrets-cleanup"
@@ -181,6 +185,11 @@ while [[ $# -gt 0 ]]; do
       OPENCODE_VERSION="$2"
       shift 2
       ;;
+    --guest-binary)
+      [[ $# -lt 2 ]] && die "--guest-binary requires a value"
+      AGENTLAB_GUEST_BIN="$2"
+      shift 2
+      ;;
     --refresh)
       REFRESH=1
       shift
@@ -241,7 +250,13 @@ EOF
 fi
 
 if [[ "$SKIP_CUSTOMIZE" == "0" ]]; then
-  [[ -f "$AGENTLAB_RUNNER_SCRIPT" ]] || die "agent-runner script not found at $AGENTLAB_RUNNER_SCRIPT"
+  if [[ ! -f "$AGENTLAB_GUEST_BIN" ]] && command -v go >/dev/null 2>&1; then
+    log "Building agentlab-guest into $AGENTLAB_GUEST_BIN"
+    mkdir -p "$(dirname "$AGENTLAB_GUEST_BIN")"
+    (cd "$REPO_ROOT" && CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -trimpath -o "$AGENTLAB_GUEST_BIN" ./cmd/agentlab-guest) \
+      || die "failed to build agentlab-guest"
+  fi
+  [[ -f "$AGENTLAB_GUEST_BIN" ]] || die "agentlab-guest binary not found at $AGENTLAB_GUEST_BIN (run make build or pass --guest-binary)"
   [[ -f "$AGENTLAB_RUNNER_SERVICE" ]] || die "agent-runner service not found at $AGENTLAB_RUNNER_SERVICE"
   [[ -f "$AGENTLAB_RUNNER_ENV" ]] || die "agent-runner env not found at $AGENTLAB_RUNNER_ENV"
   [[ -f "$AGENTLAB_SECRETS_CLEANUP_SCRIPT" ]] || die "agent secrets cleanup script not found at $AGENTLAB_SECRETS_CLEANUP_SCRIPT"
@@ -367,14 +382,14 @@ if [[ "$SKIP_CUSTOMIZE" == "0" ]]; then
     --run-command "install -d -m 0755 /etc/ssh/sshd_config.d"
     --run-command "printf 'PasswordAuthentication no\\nPermitRootLogin prohibit-password\\n' > /etc/ssh/sshd_config.d/99-agentlab.conf"
     --run-command "systemctl enable qemu-guest-agent"
-    --upload "${AGENTLAB_RUNNER_SCRIPT}:/usr/local/bin/agent-runner"
+    --upload "${AGENTLAB_GUEST_BIN}:/usr/local/bin/agentlab-guest"
     --upload "${AGENTLAB_RUNNER_SERVICE}:/etc/systemd/system/agent-runner.service"
     --upload "${AGENTLAB_RUNNER_ENV}:/etc/agentlab/agent-runner.env"
     --upload "${AGENTLAB_SECRETS_CLEANUP_SCRIPT}:/usr/local/bin/agent-secrets-cleanup"
     --upload "${AGENTLAB_WORKSPACE_SETUP_SCRIPT}:/usr/local/bin/agentlab-workspace-setup"
     --upload "${AGENTLAB_WORKSPACE_SETUP_SERVICE}:/etc/systemd/system/agentlab-workspace-setup.service"
     --upload "${AGENTLAB_WORK_MOUNT_UNIT}:/etc/systemd/system/work.mount"
-    --run-command "chmod 0755 /usr/local/bin/agent-runner"
+    --run-command "chmod 0755 /usr/local/bin/agentlab-guest"
     --run-command "chmod 0755 /usr/local/bin/agent-secrets-cleanup"
     --run-command "chmod 0755 /usr/local/bin/agentlab-workspace-setup"
     --run-command "chmod 0644 /etc/agentlab/agent-runner.env"